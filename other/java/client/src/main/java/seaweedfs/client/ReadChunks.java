@@ -84,8 +84,8 @@ public class ReadChunks {
                     point.x,
                     chunk.getFileId(),
                     chunk.getModifiedTsNs(),
-                    prevX - chunk.getOffset(),
-                    chunk.getOffset() == prevX && chunk.getSize() == prevX - startPoint.x,
+                    prevX - chunk.getOffset() + chunk.getPackOffset(),
+                    chunk.getPackSize() == 0 && chunk.getOffset() == prevX && chunk.getSize() == prevX - startPoint.x,
                     chunk.getCipherKey().toByteArray(),
                     chunk.getIsCompressed()
             ));
@@ -78,11 +78,8 @@ func (lm *LockManager) Unlock(path string, token string) (isUnlocked bool, err e
 				return nil, true
 			}
 			if oldValue.Token == token {
-				if oldValue.ExpiredAtNs <= now.UnixNano() {
-					isUnlocked = true
-					return nil, true
-				}
-				return oldValue, false
+				isUnlocked = true
+				return nil, true
 			} else {
 				isUnlocked = false
 				err = UnlockErrorTokenMismatch
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
 	"github.com/seaweedfs/seaweedfs/weed/filer"
@@ -20,6 +21,7 @@ import (
 	weed_server "github.com/seaweedfs/seaweedfs/weed/server"
 	stats_collect "github.com/seaweedfs/seaweedfs/weed/stats"
 	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/wdclient"
 )
 
 var (
@@ -61,6 +63,21 @@ type FilerOptions struct {
 	showUIDirectoryDelete   *bool
 	downloadMaxMBps         *int
 	diskType                *string
+	packIntervalMinutes     *int
+	packMaxFileKB           *int
+	packMinFiles            *int
+	dirChecksums            *bool
+	usageStats              *bool
+	xattrIndexKeys          *string
+	resolveSymlinks         *bool
+	metaCacheEntries        *int64
+	metaCacheTtl            *time.Duration
+	asOfMaxAge              *time.Duration
+	clientRequestsPerSecond *float64
+	clientConcurrency       *int
+	clientMBps              *int
+	clientLimitOverrides    *string
+	readPreference          *string
 }
 
 func init() {
@@ -79,6 +96,7 @@ func init() {
 	f.dirListingLimit = cmdFiler.Flag.Int("dirListLimit", 100000, "limit sub dir listing size")
 	f.dataCenter = cmdFiler.Flag.String("dataCenter", "", "prefer to read and write to volumes in this data center")
 	f.rack = cmdFiler.Flag.String("rack", "", "prefer to write to volumes in this rack")
+	f.readPreference = cmdFiler.Flag.String("readPreference", "preferLocal", "[preferLocal|localOnly] read from the replicas on the same rack and data center first, or only from the replicas in the data center if it has any, also for the s3 and webdav gateways reading through this filer")
 	f.disableHttp = cmdFiler.Flag.Bool("disableHttp", false, "disable http request, only gRpc operations are allowed")
 	f.cipher = cmdFiler.Flag.Bool("encryptVolumeData", false, "encrypt data on volume servers")
 	f.metricsHttpPort = cmdFiler.Flag.Int("metricsPort", 0, "Prometheus metrics listen port")
@@ -91,6 +109,20 @@ func init() {
 	f.showUIDirectoryDelete = cmdFiler.Flag.Bool("ui.deleteDir", true, "enable filer UI show delete directory button")
 	f.downloadMaxMBps = cmdFiler.Flag.Int("downloadMaxMBps", 0, "download max speed for each download request, in MB per second")
 	f.diskType = cmdFiler.Flag.String("disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
+	f.packIntervalMinutes = cmdFiler.Flag.Int("packSmallFiles.intervalMinutes", 60, "interval to pack small files in folders configured by \"fs.configure -packSmallFiles\", 0 to disable")
+	f.packMaxFileKB = cmdFiler.Flag.Int("packSmallFiles.maxKB", 64, "pack files smaller than this limit")
+	f.packMinFiles = cmdFiler.Flag.Int("packSmallFiles.minFiles", 16, "only pack a folder with at least this many small files")
+	f.dirChecksums = cmdFiler.Flag.Bool("dirChecksums", false, "maintain a checksum of each directory tree, to find changed sub trees quickly")
+	f.usageStats = cmdFiler.Flag.Bool("usageStats", false, "maintain the total file size and file count of each collection and top level directory")
+	f.xattrIndexKeys = cmdFiler.Flag.String("xattrIndex.keys", "", "comma separated extended attribute keys to index, e.g. \"user.project,Seaweed-Team\", for \"fs.find.xattr\"")
+	f.metaCacheEntries = cmdFiler.Flag.Int64("metaCache.entries", 0, "cache this many entries and listed entries of the filer store in memory, for slow filer stores, 0 to disable")
+	f.metaCacheTtl = cmdFiler.Flag.Duration("metaCache.ttl", time.Minute, "how long to cache the filer store entries in memory")
+	f.asOfMaxAge = cmdFiler.Flag.Duration("asOf.maxAge", 24*time.Hour, "how far back to read and list as of a past time, replaying the metadata log since then")
+	f.resolveSymlinks = cmdFiler.Flag.Bool("resolveSymlinks", false, "read the targets of symlinks over http and s3, instead of the symlinks, unless \"?resolveSymlinks=false\"")
+	f.clientRequestsPerSecond = cmdFiler.Flag.Float64("clientLimit.requestsPerSecond", 0, "limit the requests per second of each client, by its jwt subject or ip address, 0 means no limit")
+	f.clientConcurrency = cmdFiler.Flag.Int("clientLimit.concurrentRequests", 0, "limit the concurrent requests of each client, 0 means no limit")
+	f.clientMBps = cmdFiler.Flag.Int("clientLimit.MBps", 0, "limit the http upload and download speed of each client, in MB per second, 0 means no limit")
+	f.clientLimitOverrides = cmdFiler.Flag.String("clientLimit.overrides", "", "limits of specific clients, e.g. \"backup=0/4/50,10.0.0.8=500/0/0\" for <client>=<requestsPerSecond>/<concurrentRequests>/<MBps>")
 
 	// start s3 on filer
 	filerStartS3 = cmdFiler.Flag.Bool("s3", false, "whether to start S3 gateway")
@@ -234,6 +266,15 @@ func (fo *FilerOptions) startFiler() {
 
 	filerAddress := pb.NewServerAddress(*fo.ip, *fo.port, *fo.portGrpc)
 
+	clientLimitOverrides, err := weed_server.ParseClientLimitOverrides(*fo.clientLimitOverrides)
+	if err != nil {
+		glog.Fatalf("invalid -clientLimit.overrides: %v", err)
+	}
+	readPreference, err := wdclient.ParseReadPreference(*fo.readPreference)
+	if err != nil {
+		glog.Fatalf("invalid -readPreference: %v", err)
+	}
+
 	fs, nfs_err := weed_server.NewFilerServer(defaultMux, publicVolumeMux, &weed_server.FilerOption{
 		Masters:               fo.masters,
 		FilerGroup:            *fo.filerGroup,
@@ -253,6 +294,23 @@ func (fo *FilerOptions) startFiler() {
 		ShowUIDirectoryDelete: *fo.showUIDirectoryDelete,
 		DownloadMaxBytesPs:    int64(*fo.downloadMaxMBps) * 1024 * 1024,
 		DiskType:              *fo.diskType,
+		PackInterval:          time.Duration(*fo.packIntervalMinutes) * time.Minute,
+		PackMaxFileSize:       int64(*fo.packMaxFileKB) * 1024,
+		PackMinFiles:          *fo.packMinFiles,
+		DirChecksums:          *fo.dirChecksums,
+		UsageStats:            *fo.usageStats,
+		XattrIndexKeys:        util.StringSplit(*fo.xattrIndexKeys, ","),
+		ResolveSymlinks:       *fo.resolveSymlinks,
+		MetaCacheEntries:      *fo.metaCacheEntries,
+		MetaCacheTtl:          *fo.metaCacheTtl,
+		AsOfMaxAge:            *fo.asOfMaxAge,
+		ClientLimits: weed_server.ClientLimits{
+			RequestsPerSecond:  *fo.clientRequestsPerSecond,
+			ConcurrentRequests: int64(*fo.clientConcurrency),
+			BytesPerSecond:     int64(*fo.clientMBps) * 1024 * 1024,
+		},
+		ClientLimitOverrides: clientLimitOverrides,
+		ReadPreference:       readPreference,
 	})
 	if nfs_err != nil {
 		glog.Fatalf("Filer startup error: %v", nfs_err)
@@ -294,7 +352,8 @@ func (fo *FilerOptions) startFiler() {
 	if err != nil {
 		glog.Fatalf("failed to listen on grpc port %d: %v", grpcPort, err)
 	}
-	grpcS := pb.NewGrpcServer(security.LoadServerTLS(util.GetViper(), "grpc.filer"))
+	grpcTlsOption, grpcAuthOption := security.LoadServerTLS(util.GetViper(), "grpc.filer")
+	grpcS := pb.NewGrpcServer(append([]grpc.ServerOption{grpcTlsOption, grpcAuthOption}, fs.GrpcServerOptions()...)...)
 	filer_pb.RegisterSeaweedFilerServer(grpcS, fs)
 	reflection.Register(grpcS)
 	if grpcLocalL != nil {
@@ -0,0 +1,358 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/remote_pb"
+	"github.com/seaweedfs/seaweedfs/weed/remote_storage"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// A metadata archive on remote storage has this layout:
+//
+//	<path>/snapshot/<tsNs>.snapshot                  full namespace listing as of tsNs
+//	<path>/log/<yyyy-mm-dd>/<startTsNs>-<stopTsNs>.log metadata changes in (startTsNs, stopTsNs]
+//
+// Both kinds of objects are sequences of 4-byte size prefixed filer_pb.LogEntry,
+// the same format as the filer persisted metadata logs, each carrying one
+// filer_pb.SubscribeMetadataResponse. Snapshot entries are creation events.
+const (
+	metaArchiveSnapshotDir = "snapshot"
+	metaArchiveLogDir      = "log"
+	metaArchiveTsFormat    = "%019d"
+)
+
+type metaArchiveObject struct {
+	path      string
+	size      int64
+	startTsNs int64
+	stopTsNs  int64
+}
+
+type metaArchive struct {
+	client remote_storage.RemoteStorageClient
+	loc    *remote_pb.RemoteStorageLocation
+}
+
+// newMetaArchive opens an archive location like "<remoteStorageName>/<bucket>/<path>",
+// with the remote storage configured by "remote.configure" on the filer.
+func newMetaArchive(grpcDialOption grpc.DialOption, filerAddress pb.ServerAddress, archive string) (*metaArchive, error) {
+	storageName := remote_storage.ParseLocationName(archive)
+	conf, err := filer.ReadRemoteStorageConf(grpcDialOption, filerAddress, storageName)
+	if err != nil {
+		return nil, fmt.Errorf("read remote storage %s configuration: %v", storageName, err)
+	}
+	loc, err := remote_storage.ParseRemoteLocation(conf.Type, archive)
+	if err != nil {
+		return nil, err
+	}
+	client, err := remote_storage.GetRemoteStorage(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &metaArchive{client: client, loc: loc}, nil
+}
+
+func (a *metaArchive) location(relativePath string) *remote_pb.RemoteStorageLocation {
+	return &remote_pb.RemoteStorageLocation{
+		Name:   a.loc.Name,
+		Bucket: a.loc.Bucket,
+		Path:   util.Join(a.loc.Path, relativePath),
+	}
+}
+
+func (a *metaArchive) writeObject(relativePath string, size int64, reader io.Reader) error {
+	now := time.Now().Unix()
+	entry := &filer_pb.Entry{
+		Name: relativePath[strings.LastIndex(relativePath, "/")+1:],
+		Attributes: &filer_pb.FuseAttributes{
+			FileSize: uint64(size),
+			Mtime:    now,
+			Crtime:   now,
+			FileMode: 0644,
+		},
+	}
+	if _, err := a.client.WriteFile(a.location(relativePath), entry, reader); err != nil {
+		return fmt.Errorf("write archive %s: %v", relativePath, err)
+	}
+	return nil
+}
+
+func (a *metaArchive) readObject(object metaArchiveObject) ([]byte, error) {
+	data, err := a.client.ReadFile(a.location(object.path), 0, object.size)
+	if err != nil {
+		return nil, fmt.Errorf("read archive %s: %v", object.path, err)
+	}
+	return data, nil
+}
+
+// list returns snapshots and log segments, both sorted by time.
+func (a *metaArchive) list() (snapshots, segments []metaArchiveObject, err error) {
+	rootPath := strings.TrimSuffix(a.loc.Path, "/")
+	err = a.client.Traverse(a.loc, func(dir string, name string, isDirectory bool, remoteEntry *filer_pb.RemoteEntry) error {
+		if isDirectory {
+			return nil
+		}
+		relativePath := strings.TrimPrefix(string(util.NewFullPath(dir, name)), rootPath+"/")
+		object := metaArchiveObject{path: relativePath, size: remoteEntry.RemoteSize}
+		switch {
+		case strings.HasPrefix(relativePath, metaArchiveSnapshotDir+"/") && strings.HasSuffix(name, ".snapshot"):
+			tsNs, parseErr := strconv.ParseInt(strings.TrimSuffix(name, ".snapshot"), 10, 64)
+			if parseErr != nil {
+				return nil
+			}
+			object.startTsNs, object.stopTsNs = tsNs, tsNs
+			snapshots = append(snapshots, object)
+		case strings.HasPrefix(relativePath, metaArchiveLogDir+"/") && strings.HasSuffix(name, ".log"):
+			parts := strings.Split(strings.TrimSuffix(name, ".log"), "-")
+			if len(parts) != 2 {
+				return nil
+			}
+			startTsNs, startErr := strconv.ParseInt(parts[0], 10, 64)
+			stopTsNs, stopErr := strconv.ParseInt(parts[1], 10, 64)
+			if startErr != nil || stopErr != nil {
+				return nil
+			}
+			object.startTsNs, object.stopTsNs = startTsNs, stopTsNs
+			segments = append(segments, object)
+		}
+		return nil
+	})
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].startTsNs < snapshots[j].startTsNs })
+	sort.Slice(segments, func(i, j int) bool { return segments[i].startTsNs < segments[j].startTsNs })
+	return
+}
+
+// lastArchivedTsNs returns the time up to which the metadata changes have been archived.
+func (a *metaArchive) lastArchivedTsNs() (tsNs int64, err error) {
+	snapshots, segments, err := a.list()
+	if err != nil {
+		return 0, err
+	}
+	for _, object := range append(snapshots, segments...) {
+		if object.stopTsNs > tsNs {
+			tsNs = object.stopTsNs
+		}
+	}
+	return tsNs, nil
+}
+
+func appendMetaArchiveRecord(buf *bytes.Buffer, resp *filer_pb.SubscribeMetadataResponse) error {
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("marshal %v: %v", resp, err)
+	}
+	logEntry, err := proto.Marshal(&filer_pb.LogEntry{
+		TsNs: resp.TsNs,
+		Data: data,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal log entry: %v", err)
+	}
+	sizeBuf := make([]byte, 4)
+	util.Uint32toBytes(sizeBuf, uint32(len(logEntry)))
+	buf.Write(sizeBuf)
+	buf.Write(logEntry)
+	return nil
+}
+
+// eachMetaArchiveRecord calls fn for each record with startTsNs < tsNs <= stopTsNs.
+// A zero stopTsNs means no upper limit.
+func eachMetaArchiveRecord(data []byte, startTsNs, stopTsNs int64, fn func(resp *filer_pb.SubscribeMetadataResponse) error) error {
+	_, err := filer.ReadEachLogEntry(bytes.NewReader(data), make([]byte, 4), startTsNs, stopTsNs, func(logEntry *filer_pb.LogEntry) error {
+		resp := &filer_pb.SubscribeMetadataResponse{}
+		if err := proto.Unmarshal(logEntry.Data, resp); err != nil {
+			return fmt.Errorf("unmarshal archived event: %v", err)
+		}
+		return fn(resp)
+	})
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// writeSnapshot lists the filer directory tree into a snapshot as of snapshotTsNs.
+// Changes during the listing are covered by replaying the logs archived after snapshotTsNs.
+func (a *metaArchive) writeSnapshot(filerClient filer_pb.FilerClient, dir string, snapshotTsNs int64) error {
+	var buf bytes.Buffer
+	var bufLock sync.Mutex
+	var recordErr error
+	traverseErr := filer_pb.TraverseBfs(filerClient, util.FullPath(dir), func(parentPath util.FullPath, entry *filer_pb.Entry) {
+		bufLock.Lock()
+		defer bufLock.Unlock()
+		if err := appendMetaArchiveRecord(&buf, &filer_pb.SubscribeMetadataResponse{
+			Directory: string(parentPath),
+			EventNotification: &filer_pb.EventNotification{
+				NewEntry:      entry,
+				NewParentPath: string(parentPath),
+			},
+			TsNs: snapshotTsNs,
+		}); err != nil {
+			recordErr = err
+		}
+	})
+	if traverseErr != nil {
+		return fmt.Errorf("traverse %s: %v", dir, traverseErr)
+	}
+	if recordErr != nil {
+		return recordErr
+	}
+	snapshotPath := fmt.Sprintf("%s/"+metaArchiveTsFormat+".snapshot", metaArchiveSnapshotDir, snapshotTsNs)
+	return a.writeObject(snapshotPath, int64(buf.Len()), &buf)
+}
+
+// metaArchiveSegmentWriter buffers metadata changes and uploads them as log segments.
+type metaArchiveSegmentWriter struct {
+	archive   *metaArchive
+	maxSize   int
+	buf       bytes.Buffer
+	startTsNs int64
+	lastTsNs  int64
+	bufLock   sync.Mutex
+	flushLock sync.Mutex
+}
+
+func (w *metaArchiveSegmentWriter) add(resp *filer_pb.SubscribeMetadataResponse) error {
+	w.bufLock.Lock()
+	err := appendMetaArchiveRecord(&w.buf, resp)
+	if err == nil {
+		w.lastTsNs = resp.TsNs
+	}
+	isFull := w.buf.Len() >= w.maxSize
+	w.bufLock.Unlock()
+	if err != nil {
+		return err
+	}
+	if isFull {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush uploads the buffered changes as segment (startTsNs, lastTsNs].
+func (w *metaArchiveSegmentWriter) flush() error {
+	w.flushLock.Lock()
+	defer w.flushLock.Unlock()
+
+	w.bufLock.Lock()
+	if w.buf.Len() == 0 {
+		w.bufLock.Unlock()
+		return nil
+	}
+	data := append([]byte(nil), w.buf.Bytes()...)
+	startTsNs, stopTsNs := w.startTsNs, w.lastTsNs
+	w.buf.Reset()
+	w.bufLock.Unlock()
+
+	day := time.Unix(0, startTsNs).UTC().Format("2006-01-02")
+	segmentPath := fmt.Sprintf("%s/%s/"+metaArchiveTsFormat+"-"+metaArchiveTsFormat+".log", metaArchiveLogDir, day, startTsNs, stopTsNs)
+	if err := w.archive.writeObject(segmentPath, int64(len(data)), bytes.NewReader(data)); err != nil {
+		// put the data back so the next flush retries it
+		w.bufLock.Lock()
+		rest := append([]byte(nil), w.buf.Bytes()...)
+		w.buf.Reset()
+		w.buf.Write(data)
+		w.buf.Write(rest)
+		w.bufLock.Unlock()
+		return err
+	}
+
+	w.bufLock.Lock()
+	w.startTsNs = stopTsNs
+	w.bufLock.Unlock()
+	return nil
+}
+
+func runFilerMetaArchive() bool {
+	filerAddress := pb.ServerAddress(*metaBackup.filerAddress)
+	archive, err := newMetaArchive(metaBackup.grpcDialOption, filerAddress, *metaBackup.archive)
+	if err != nil {
+		glog.Errorf("open metadata archive %s: %v", *metaBackup.archive, err)
+		return true
+	}
+
+	lastTsNs, err := archive.lastArchivedTsNs()
+	if err != nil {
+		glog.Errorf("list metadata archive %s: %v", *metaBackup.archive, err)
+		return true
+	}
+
+	if *metaBackup.restart || lastTsNs == 0 {
+		glog.V(0).Infof("snapshotting metadata tree...")
+		snapshotTsNs := time.Now().UnixNano()
+		if err := archive.writeSnapshot(&metaBackup, *metaBackup.filerDirectory, snapshotTsNs); err != nil {
+			glog.Errorf("snapshot meta data: %v", err)
+			return true
+		}
+		glog.V(0).Infof("metadata snapshot archived as of %v", time.Unix(0, snapshotTsNs))
+		lastTsNs = snapshotTsNs
+	}
+
+	writer := &metaArchiveSegmentWriter{
+		archive:   archive,
+		maxSize:   *metaBackup.segmentSizeMB * 1024 * 1024,
+		startTsNs: lastTsNs,
+		lastTsNs:  lastTsNs,
+	}
+
+	go func() {
+		for range time.Tick(*metaBackup.flushInterval) {
+			if err := writer.flush(); err != nil {
+				glog.Errorf("archive metadata changes: %v", err)
+			}
+		}
+	}()
+
+	for {
+		err := metaBackup.streamMetadataArchive(writer)
+		if err != nil {
+			glog.Errorf("filer meta archive from %s: %v", *metaBackup.filerAddress, err)
+			time.Sleep(1747 * time.Millisecond)
+		}
+	}
+}
+
+func (metaBackup *FilerMetaBackupOptions) streamMetadataArchive(writer *metaArchiveSegmentWriter) error {
+
+	writer.bufLock.Lock()
+	startTsNs := writer.lastTsNs
+	writer.bufLock.Unlock()
+	glog.V(0).Infof("archiving from %v", time.Unix(0, startTsNs))
+
+	eachEntryFunc := func(resp *filer_pb.SubscribeMetadataResponse) error {
+		if filer_pb.IsEmpty(resp) {
+			return nil
+		}
+		return writer.add(resp)
+	}
+
+	metaBackup.clientEpoch++
+
+	metadataFollowOption := &pb.MetadataFollowOption{
+		ClientName:     "meta_archive",
+		ClientId:       metaBackup.clientId,
+		ClientEpoch:    metaBackup.clientEpoch,
+		SelfSignature:  0,
+		PathPrefix:     *metaBackup.filerDirectory,
+		StartTsNs:      startTsNs,
+		StopTsNs:       0,
+		EventErrorType: pb.TrivialOnError,
+	}
+
+	return pb.FollowMetadata(pb.ServerAddress(*metaBackup.filerAddress), metaBackup.grpcDialOption, metadataFollowOption, eachEntryFunc)
+}
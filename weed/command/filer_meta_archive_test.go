@@ -0,0 +1,39 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestMetaArchiveRecords(t *testing.T) {
+	var buf bytes.Buffer
+	for tsNs := int64(1); tsNs <= 5; tsNs++ {
+		if err := appendMetaArchiveRecord(&buf, &filer_pb.SubscribeMetadataResponse{
+			Directory: "/dir",
+			EventNotification: &filer_pb.EventNotification{
+				NewEntry:      &filer_pb.Entry{Name: "file"},
+				NewParentPath: "/dir",
+			},
+			TsNs: tsNs,
+		}); err != nil {
+			t.Fatalf("append record: %v", err)
+		}
+	}
+
+	var replayed []int64
+	err := eachMetaArchiveRecord(buf.Bytes(), 1, 4, func(resp *filer_pb.SubscribeMetadataResponse) error {
+		if resp.EventNotification.NewEntry.Name != "file" {
+			t.Errorf("unexpected entry %v", resp.EventNotification.NewEntry)
+		}
+		replayed = append(replayed, resp.TsNs)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("read records: %v", err)
+	}
+	if len(replayed) != 3 || replayed[0] != 2 || replayed[2] != 4 {
+		t.Errorf("replayed %v, expected (1, 4]", replayed)
+	}
+}
@@ -26,6 +26,9 @@ type FilerMetaBackupOptions struct {
 	filerDirectory    *string
 	restart           *bool
 	backupFilerConfig *string
+	archive           *string
+	flushInterval     *time.Duration
+	segmentSizeMB     *int
 
 	store       filer.FilerStore
 	clientId    int32
@@ -38,11 +41,14 @@ func init() {
 	metaBackup.filerDirectory = cmdFilerMetaBackup.Flag.String("filerDir", "/", "a folder on the filer")
 	metaBackup.restart = cmdFilerMetaBackup.Flag.Bool("restart", false, "copy the full metadata before async incremental backup")
 	metaBackup.backupFilerConfig = cmdFilerMetaBackup.Flag.String("config", "", "path to filer.toml specifying backup filer store")
+	metaBackup.archive = cmdFilerMetaBackup.Flag.String("archive", "", "archive the metadata changelog to a remote storage location <remoteStorageName>/<bucket>/<path>, instead of a backup filer store")
+	metaBackup.flushInterval = cmdFilerMetaBackup.Flag.Duration("archive.flushInterval", time.Minute, "upload archived changes at least this often")
+	metaBackup.segmentSizeMB = cmdFilerMetaBackup.Flag.Int("archive.segmentSizeMB", 8, "upload archived changes when the buffered segment reaches this size")
 	metaBackup.clientId = util.RandomInt32()
 }
 
 var cmdFilerMetaBackup = &Command{
-	UsageLine: "filer.meta.backup [-filer=localhost:8888] [-filerDir=/] [-restart] -config=/path/to/backup_filer.toml",
+	UsageLine: "filer.meta.backup [-filer=localhost:8888] [-filerDir=/] [-restart] -config=/path/to/backup_filer.toml | -archive=<remoteStorageName>/<bucket>/<path>",
 	Short:     "continuously backup filer meta data changes to anther filer store specified in a backup_filer.toml",
 	Long: `continuously backup filer meta data changes. 
 The backup writes to another filer store specified in a backup_filer.toml.
@@ -58,23 +64,19 @@ func runFilerMetaBackup(cmd *Command, args []string) bool {
 	util.LoadConfiguration("security", false)
 	metaBackup.grpcDialOption = security.LoadClientTLS(util.GetViper(), "grpc.client")
 
-	// load backup_filer.toml
-	v := viper.New()
-	v.SetConfigFile(*metaBackup.backupFilerConfig)
-
-	if err := v.ReadInConfig(); err != nil { // Handle errors reading the config file
-		glog.Fatalf("Failed to load %s file.\nPlease use this command to generate the a %s.toml file\n"+
-			"    weed scaffold -config=%s -output=.\n\n\n",
-			*metaBackup.backupFilerConfig, "backup_filer", "filer")
+	if *metaBackup.archive != "" {
+		return runFilerMetaArchive()
 	}
 
-	if err := metaBackup.initStore(v); err != nil {
+	store, err := loadBackupFilerStore(*metaBackup.backupFilerConfig)
+	if err != nil {
 		glog.V(0).Infof("init backup filer store: %v", err)
 		return true
 	}
+	metaBackup.store = store
 
 	missingPreviousBackup := false
-	_, err := metaBackup.getOffset()
+	_, err = metaBackup.getOffset()
 	if err != nil {
 		missingPreviousBackup = true
 	}
@@ -103,9 +105,18 @@ func runFilerMetaBackup(cmd *Command, args []string) bool {
 	return true
 }
 
-func (metaBackup *FilerMetaBackupOptions) initStore(v *viper.Viper) error {
+// loadBackupFilerStore initializes the filer store enabled in a backup_filer.toml.
+func loadBackupFilerStore(configFile string) (filer.FilerStore, error) {
+	v := viper.New()
+	v.SetConfigFile(configFile)
+
+	if err := v.ReadInConfig(); err != nil { // Handle errors reading the config file
+		glog.Fatalf("Failed to load %s file.\nPlease use this command to generate the a %s.toml file\n"+
+			"    weed scaffold -config=%s -output=.\n\n\n",
+			configFile, "backup_filer", "filer")
+	}
+
 	// load configuration for default filer store
-	hasDefaultStoreConfigured := false
 	for _, store := range filer.Stores {
 		if v.GetBool(store.GetName() + ".enabled") {
 			store = reflect.New(reflect.ValueOf(store).Elem().Type()).Interface().(filer.FilerStore)
@@ -113,16 +124,11 @@ func (metaBackup *FilerMetaBackupOptions) initStore(v *viper.Viper) error {
 				glog.Fatalf("failed to initialize store for %s: %+v", store.GetName(), err)
 			}
 			glog.V(0).Infof("configured filer store to %s", store.GetName())
-			hasDefaultStoreConfigured = true
-			metaBackup.store = filer.NewFilerStoreWrapper(store)
-			break
+			return filer.NewFilerStoreWrapper(store), nil
 		}
 	}
-	if !hasDefaultStoreConfigured {
-		return fmt.Errorf("no filer store enabled in %s", v.ConfigFileUsed())
-	}
 
-	return nil
+	return nil, fmt.Errorf("no filer store enabled in %s", v.ConfigFileUsed())
 }
 
 func (metaBackup *FilerMetaBackupOptions) traverseMetadata() (err error) {
@@ -0,0 +1,190 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/security"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+var (
+	metaRestore FilerMetaRestoreOptions
+)
+
+type FilerMetaRestoreOptions struct {
+	filerAddress       *string
+	archive            *string
+	restoreFilerConfig *string
+	filerDirectory     *string
+	restoreTime        *string
+	timeAgo            *time.Duration
+}
+
+func init() {
+	cmdFilerMetaRestore.Run = runFilerMetaRestore // break init cycle
+	metaRestore.filerAddress = cmdFilerMetaRestore.Flag.String("filer", "localhost:8888", "filer hostname:port, to read the remote storage configuration")
+	metaRestore.archive = cmdFilerMetaRestore.Flag.String("archive", "", "the metadata archive <remoteStorageName>/<bucket>/<path> written by filer.meta.backup")
+	metaRestore.restoreFilerConfig = cmdFilerMetaRestore.Flag.String("config", "", "path to filer.toml specifying the filer store to restore into")
+	metaRestore.filerDirectory = cmdFilerMetaRestore.Flag.String("filerDir", "/", "only restore this folder")
+	metaRestore.restoreTime = cmdFilerMetaRestore.Flag.String("time", "", "restore the metadata as of this time, in RFC3339 format, e.g. 2006-01-02T15:04:05Z. Default to the latest.")
+	metaRestore.timeAgo = cmdFilerMetaRestore.Flag.Duration("timeAgo", 0, "restore the metadata as of this long ago, e.g. 2h. Ignored if -time is set.")
+}
+
+var cmdFilerMetaRestore = &Command{
+	UsageLine: "filer.meta.restore -archive=<remoteStorageName>/<bucket>/<path> -config=/path/to/restore_filer.toml [-filerDir=/] [-time=2006-01-02T15:04:05Z]",
+	Short:     "rebuild filer meta data from an archive written by filer.meta.backup",
+	Long: `rebuild filer meta data, as of any archived time, from an archive written by "weed filer.meta.backup -archive".
+
+The restore starts from the latest archived snapshot before the restore time,
+and replays the archived metadata changes up to the restore time.
+The result is written to the filer store specified in a restore_filer.toml.
+Stop the filers using this store before restoring into it.
+
+	weed filer.meta.restore -archive=s3_1/metabackup/cluster1 -config=/path/to/restore_filer.toml
+	weed filer.meta.restore -archive=s3_1/metabackup/cluster1 -config=/path/to/restore_filer.toml -time=2023-10-01T12:00:00Z
+	weed filer.meta.restore -archive=s3_1/metabackup/cluster1 -config=/path/to/restore_filer.toml -timeAgo=2h -filerDir=/buckets/important
+
+  `,
+}
+
+func runFilerMetaRestore(cmd *Command, args []string) bool {
+
+	util.LoadConfiguration("security", false)
+	grpcDialOption := security.LoadClientTLS(util.GetViper(), "grpc.client")
+
+	if *metaRestore.archive == "" || *metaRestore.restoreFilerConfig == "" {
+		return false
+	}
+
+	var restoreTsNs int64
+	if *metaRestore.restoreTime != "" {
+		restoreTime, err := time.Parse(time.RFC3339, *metaRestore.restoreTime)
+		if err != nil {
+			glog.Errorf("parse restore time %s: %v", *metaRestore.restoreTime, err)
+			return true
+		}
+		restoreTsNs = restoreTime.UnixNano()
+	} else if *metaRestore.timeAgo > 0 {
+		restoreTsNs = time.Now().Add(-*metaRestore.timeAgo).UnixNano()
+	}
+
+	archive, err := newMetaArchive(grpcDialOption, pb.ServerAddress(*metaRestore.filerAddress), *metaRestore.archive)
+	if err != nil {
+		glog.Errorf("open metadata archive %s: %v", *metaRestore.archive, err)
+		return true
+	}
+
+	store, err := loadBackupFilerStore(*metaRestore.restoreFilerConfig)
+	if err != nil {
+		glog.Errorf("init restore filer store: %v", err)
+		return true
+	}
+	defer store.Shutdown()
+
+	if err := restoreMetadata(archive, store, util.FullPath(*metaRestore.filerDirectory), restoreTsNs); err != nil {
+		glog.Errorf("restore metadata: %v", err)
+	}
+
+	return true
+}
+
+// restoreMetadata replays the latest snapshot no later than restoreTsNs, and the changes after it
+// up to restoreTsNs, into the store. A zero restoreTsNs restores everything archived.
+func restoreMetadata(archive *metaArchive, store filer.FilerStore, dir util.FullPath, restoreTsNs int64) error {
+	snapshots, segments, err := archive.list()
+	if err != nil {
+		return fmt.Errorf("list archive: %v", err)
+	}
+
+	var snapshot *metaArchiveObject
+	for i := range snapshots {
+		if restoreTsNs == 0 || snapshots[i].startTsNs <= restoreTsNs {
+			snapshot = &snapshots[i]
+		}
+	}
+	if snapshot == nil {
+		return fmt.Errorf("no archived snapshot before %v", time.Unix(0, restoreTsNs))
+	}
+
+	ctx := context.Background()
+	applyFn := func(resp *filer_pb.SubscribeMetadataResponse) error {
+		return applyMetadataEvent(ctx, store, dir, resp)
+	}
+
+	glog.V(0).Infof("restoring snapshot %s", snapshot.path)
+	data, err := archive.readObject(*snapshot)
+	if err != nil {
+		return err
+	}
+	if err = eachMetaArchiveRecord(data, 0, 0, applyFn); err != nil {
+		return fmt.Errorf("restore snapshot %s: %v", snapshot.path, err)
+	}
+
+	lastTsNs := snapshot.stopTsNs
+	for _, segment := range segments {
+		if segment.stopTsNs <= snapshot.stopTsNs {
+			continue
+		}
+		if restoreTsNs != 0 && segment.startTsNs >= restoreTsNs {
+			break
+		}
+		glog.V(0).Infof("replaying %s", segment.path)
+		data, err := archive.readObject(segment)
+		if err != nil {
+			return err
+		}
+		if err = eachMetaArchiveRecord(data, snapshot.stopTsNs, restoreTsNs, applyFn); err != nil {
+			return fmt.Errorf("replay %s: %v", segment.path, err)
+		}
+		lastTsNs = segment.stopTsNs
+	}
+	if restoreTsNs != 0 && lastTsNs > restoreTsNs {
+		lastTsNs = restoreTsNs
+	}
+
+	glog.V(0).Infof("restored %s as of %v", dir, time.Unix(0, lastTsNs))
+	return nil
+}
+
+// applyMetadataEvent applies one metadata change to the store, limited to the directory dir.
+// Renames across the directory boundary become deletions or creations.
+func applyMetadataEvent(ctx context.Context, store filer.FilerStore, dir util.FullPath, resp *filer_pb.SubscribeMetadataResponse) error {
+	message := resp.EventNotification
+
+	var oldPath, newPath util.FullPath
+	if message.OldEntry != nil {
+		oldPath = util.FullPath(resp.Directory).Child(message.OldEntry.Name)
+	}
+	if message.NewEntry != nil {
+		newPath = util.FullPath(message.NewParentPath).Child(message.NewEntry.Name)
+	}
+
+	if oldPath != "" && oldPath != newPath && isUnderOrEqual(oldPath, dir) {
+		if message.OldEntry.IsDirectory {
+			if err := store.DeleteFolderChildren(ctx, oldPath); err != nil {
+				return fmt.Errorf("delete %s: %v", oldPath, err)
+			}
+		}
+		if err := store.DeleteEntry(ctx, oldPath); err != nil {
+			return fmt.Errorf("delete %s: %v", oldPath, err)
+		}
+	}
+
+	if newPath != "" && isUnderOrEqual(newPath, dir) {
+		if err := store.InsertEntry(ctx, filer.FromPbEntry(message.NewParentPath, message.NewEntry)); err != nil {
+			return fmt.Errorf("insert %s: %v", newPath, err)
+		}
+	}
+
+	return nil
+}
+
+func isUnderOrEqual(path, dir util.FullPath) bool {
+	return path == dir || path.IsUnder(dir)
+}
@@ -27,6 +27,7 @@ var cmdFilerMetaTail = &Command{
 	weed filer.meta.tail -timeAgo=30h | jq .
 	weed filer.meta.tail -timeAgo=30h -untilTimeAgo=20h | jq .
 	weed filer.meta.tail -timeAgo=30h | jq .eventNotification.newEntry.name
+	weed filer.meta.tail -timeAgo=30h -filter='path.endsWith(".log") && size > 1048576'
 
 	weed filer.meta.tail -timeAgo=30h -es=http://<elasticSearchServerHost>:<port> -es.index=seaweedfs
 
@@ -39,6 +40,7 @@ var (
 	tailStart   = cmdFilerMetaTail.Flag.Duration("timeAgo", 0, "start time before now. \"300ms\", \"1.5h\" or \"2h45m\". Valid time units are \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"")
 	tailStop    = cmdFilerMetaTail.Flag.Duration("untilTimeAgo", 0, "read until this time ago. \"300ms\", \"1.5h\" or \"2h45m\". Valid time units are \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"")
 	tailPattern = cmdFilerMetaTail.Flag.String("pattern", "", "full path or just filename pattern, ex: \"/home/?opher\", \"*.pdf\", see https://golang.org/pkg/path/filepath/#Match ")
+	tailFilter  = cmdFilerMetaTail.Flag.String("filter", "", "CEL expression evaluated on the filer for each event, over path, directory, name, event, is_directory, size, mtime, mime, xattrs")
 	esServers   = cmdFilerMetaTail.Flag.String("es", "", "comma-separated elastic servers http://<host:port>")
 	esIndex     = cmdFilerMetaTail.Flag.String("es.index", "seaweedfs", "ES index name")
 )
@@ -115,6 +117,7 @@ func runFilerMetaTail(cmd *Command, args []string) bool {
 		PathPrefix:             *tailTarget,
 		AdditionalPathPrefixes: nil,
 		DirectoriesToWatch:     nil,
+		FilterExpression:       *tailFilter,
 		StartTsNs:              time.Now().Add(-*tailStart).UnixNano(),
 		StopTsNs:               untilTsNs,
 		EventErrorType:         pb.TrivialOnError,
@@ -18,9 +18,11 @@ type RemoteSyncOptions struct {
 	grpcDialOption     grpc.DialOption
 	readChunkFromFiler *bool
 	timeAgo            *time.Duration
+	pullInterval       *time.Duration
 	dir                *string
 	clientId           int32
 	clientEpoch        int32
+	pullSignature      int32
 }
 
 var _ = filer_pb.FilerClient(&RemoteSyncOptions{})
@@ -49,7 +51,9 @@ func init() {
 	remoteSyncOptions.storageClass = cmdFilerRemoteSynchronize.Flag.String("storageClass", "", "override amz storage class, empty to delete")
 	remoteSyncOptions.readChunkFromFiler = cmdFilerRemoteSynchronize.Flag.Bool("filerProxy", false, "read file chunks from filer instead of volume servers")
 	remoteSyncOptions.timeAgo = cmdFilerRemoteSynchronize.Flag.Duration("timeAgo", 0, "start time before now, skipping previous metadata changes. \"300ms\", \"1.5h\" or \"2h45m\". Valid time units are \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"")
+	remoteSyncOptions.pullInterval = cmdFilerRemoteSynchronize.Flag.Duration("pullInterval", 0, "if positive, also pull the changes made directly on the remote storage at this interval, e.g. \"5m\"")
 	remoteSyncOptions.clientId = util.RandomInt32()
+	remoteSyncOptions.pullSignature = util.RandomInt32()
 }
 
 var cmdFilerRemoteSynchronize = &Command{
@@ -68,6 +72,14 @@ var cmdFilerRemoteSynchronize = &Command{
 	2. last sync timestamp for this directory
 	3. directory creation time
 
+	With -pullInterval, the changes made directly on the remote storage are also pulled back,
+	by comparing the remote listing with the local files at the interval.
+	New and changed remote files are updated with only their metadata, and cached on read,
+	and deleted remote files are deleted locally.
+	The local changes not yet written to the remote storage are kept.
+
+		weed filer.remote.sync -dir=/mount/s3_on_cloud -pullInterval=5m
+
 `,
 }
 
@@ -89,6 +101,10 @@ func runFilerRemoteSynchronize(cmd *Command, args []string) bool {
 	)
 
 	if dir != "" {
+		if *remoteSyncOptions.pullInterval > 0 {
+			fmt.Printf("pull remote storage changes to %s every %v...\n", dir, *remoteSyncOptions.pullInterval)
+			go loopPullingRemoteChanges(&remoteSyncOptions, dir, remoteSyncOptions.pullSignature)
+		}
 		fmt.Printf("synchronize %s to remote storage...\n", dir)
 		util.RetryForever("filer.remote.sync "+dir, func() error {
 			return followUpdatesAndUploadToRemote(&remoteSyncOptions, filerSource, dir)
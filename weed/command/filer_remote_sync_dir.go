@@ -68,7 +68,7 @@ func followUpdatesAndUploadToRemote(option *RemoteSyncOptions, filerSource *sour
 		ClientName:             "filer.remote.sync",
 		ClientId:               option.clientId,
 		ClientEpoch:            option.clientEpoch,
-		SelfSignature:          0,
+		SelfSignature:          option.pullSignature, // skip the changes pulled from the remote storage
 		PathPrefix:             mountedDir,
 		AdditionalPathPrefixes: []string{filer.DirectoryEtcRemote},
 		DirectoriesToWatch:     nil,
@@ -0,0 +1,164 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/remote_storage"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The remote changes are pulled by comparing the remote listing with the local entries:
+//   - a new remote file is created locally, with only its metadata, to be cached on read
+//   - a changed remote file replaces the local metadata and drops the cached content
+//   - a deleted remote file is deleted locally, if it was synced before
+// The local entries not yet written to the remote storage, i.e. without remote entry or with a
+// newer mtime, are kept, so the local changes win over the concurrent remote changes.
+// The pulled changes carry the pull signature, so they are not pushed back to the remote storage.
+
+func loopPullingRemoteChanges(option *RemoteSyncOptions, mountedDir string, pullSignature int32) {
+	for {
+		if err := pullRemoteChanges(option, mountedDir, pullSignature); err != nil {
+			glog.Errorf("pull remote changes to %s: %v", mountedDir, err)
+		}
+		time.Sleep(*option.pullInterval)
+	}
+}
+
+func pullRemoteChanges(option *RemoteSyncOptions, mountedDir string, pullSignature int32) error {
+
+	_, _, remoteStorageMountLocation, remoteConf, detectErr := filer.DetectMountInfo(option.grpcDialOption, pb.ServerAddress(*option.filerAddress), mountedDir)
+	if detectErr != nil {
+		return fmt.Errorf("read mount info: %v", detectErr)
+	}
+	client, err := remote_storage.GetRemoteStorage(remoteConf)
+	if err != nil {
+		return err
+	}
+
+	localMountedDir := util.FullPath(mountedDir)
+	signatures := []int32{pullSignature}
+	remoteFiles := make(map[util.FullPath]bool)
+	var created, updated, deleted int
+	pullStartTsNs := time.Now().UnixNano()
+
+	err = option.WithFilerClient(false, func(filerClient filer_pb.SeaweedFilerClient) error {
+		return client.Traverse(remoteStorageMountLocation, func(remoteDir, name string, isDirectory bool, remoteEntry *filer_pb.RemoteEntry) error {
+			localDir := filer.MapRemoteStorageLocationPathToFullPath(localMountedDir, remoteStorageMountLocation, remoteDir)
+			if !isDirectory {
+				remoteFiles[localDir.Child(name)] = true
+			}
+
+			var existingEntry *filer_pb.Entry
+			lookupResp, lookupErr := filer_pb.LookupEntry(filerClient, &filer_pb.LookupDirectoryEntryRequest{
+				Directory: string(localDir),
+				Name:      name,
+			})
+			if lookupErr == nil {
+				existingEntry = lookupResp.Entry
+			} else if lookupErr != filer_pb.ErrNotFound {
+				return lookupErr
+			}
+
+			switch pullActionOf(existingEntry, isDirectory, remoteEntry) {
+			case pullCreate:
+				glog.V(0).Infof("pull new %s", localDir.Child(name))
+				created++
+				return filer_pb.CreateEntry(filerClient, &filer_pb.CreateEntryRequest{
+					Directory: string(localDir),
+					Entry: &filer_pb.Entry{
+						Name:        name,
+						IsDirectory: isDirectory,
+						Attributes: &filer_pb.FuseAttributes{
+							FileSize: uint64(remoteEntry.RemoteSize),
+							Mtime:    remoteEntry.RemoteMtime,
+							FileMode: uint32(0644),
+						},
+						RemoteEntry: remoteEntry,
+					},
+					Signatures: signatures,
+				})
+			case pullUpdate:
+				glog.V(0).Infof("pull changed %s", localDir.Child(name))
+				updated++
+				existingEntry.RemoteEntry = remoteEntry
+				existingEntry.Attributes.FileSize = uint64(remoteEntry.RemoteSize)
+				existingEntry.Attributes.Mtime = remoteEntry.RemoteMtime
+				existingEntry.Attributes.Md5 = nil
+				existingEntry.Chunks = nil
+				existingEntry.Content = nil
+				_, updateErr := filerClient.UpdateEntry(context.Background(), &filer_pb.UpdateEntryRequest{
+					Directory:  string(localDir),
+					Entry:      existingEntry,
+					Signatures: signatures,
+				})
+				return updateErr
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("traverse %s: %v", remote_storage.FormatLocation(remoteStorageMountLocation), err)
+	}
+
+	var toDelete []util.FullPath
+	var toDeleteLock sync.Mutex
+	err = filer_pb.TraverseBfs(option, localMountedDir, func(parentPath util.FullPath, entry *filer_pb.Entry) {
+		if fullPath := parentPath.Child(entry.Name); isDeletedRemotely(entry, remoteFiles[fullPath], pullStartTsNs) {
+			toDeleteLock.Lock()
+			toDelete = append(toDelete, fullPath)
+			toDeleteLock.Unlock()
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("traverse %s: %v", mountedDir, err)
+	}
+	for _, fullPath := range toDelete {
+		dir, name := fullPath.DirAndName()
+		glog.V(0).Infof("pull deleted %s", fullPath)
+		if err = filer_pb.Remove(option, dir, name, true, false, false, false, signatures); err != nil {
+			return fmt.Errorf("delete %s: %v", fullPath, err)
+		}
+		deleted++
+	}
+
+	glog.V(1).Infof("pulled %s from %s: %d created, %d updated, %d deleted", mountedDir, remote_storage.FormatLocation(remoteStorageMountLocation), created, updated, deleted)
+	return nil
+}
+
+type pullAction int
+
+const (
+	pullSkip pullAction = iota
+	pullCreate
+	pullUpdate
+)
+
+// pullActionOf decides how to pull a remote entry, with the existing local entry or nil if not found
+func pullActionOf(existingEntry *filer_pb.Entry, isDirectory bool, remoteEntry *filer_pb.RemoteEntry) pullAction {
+	if existingEntry == nil {
+		return pullCreate
+	}
+	if isDirectory || existingEntry.IsDirectory || shouldSendToRemote(existingEntry) {
+		return pullSkip
+	}
+	if existingEntry.RemoteEntry.RemoteETag == remoteEntry.RemoteETag && existingEntry.RemoteEntry.RemoteMtime >= remoteEntry.RemoteMtime {
+		return pullSkip
+	}
+	return pullUpdate
+}
+
+// isDeletedRemotely tells whether a local entry missing from the remote listing was deleted remotely:
+// it must have been synced, and not written to the remote storage after the listing started
+func isDeletedRemotely(entry *filer_pb.Entry, isListedRemotely bool, pullStartTsNs int64) bool {
+	if isListedRemotely || entry.IsDirectory || entry.RemoteEntry == nil || shouldSendToRemote(entry) {
+		return false
+	}
+	return entry.RemoteEntry.LastLocalSyncTsNs < pullStartTsNs
+}
@@ -0,0 +1,80 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func syncedEntry(isDirectory bool, mtime int64, eTag string, lastLocalSyncTsNs int64) *filer_pb.Entry {
+	return &filer_pb.Entry{
+		Name:        "file",
+		IsDirectory: isDirectory,
+		Attributes:  &filer_pb.FuseAttributes{Mtime: mtime},
+		RemoteEntry: &filer_pb.RemoteEntry{RemoteMtime: mtime, RemoteETag: eTag, LastLocalSyncTsNs: lastLocalSyncTsNs},
+	}
+}
+
+func TestPullActionOf(t *testing.T) {
+	remoteEntry := &filer_pb.RemoteEntry{RemoteMtime: 100, RemoteETag: "etag2", RemoteSize: 5}
+
+	for _, tc := range []struct {
+		name          string
+		existingEntry *filer_pb.Entry
+		isDirectory   bool
+		expected      pullAction
+	}{
+		{"new file", nil, false, pullCreate},
+		{"new directory", nil, true, pullCreate},
+		{"unchanged", syncedEntry(false, 100, "etag2", 0), false, pullSkip},
+		{"unchanged with a newer local copy", syncedEntry(false, 200, "etag2", 0), false, pullSkip},
+		{"changed etag", syncedEntry(false, 100, "etag1", 0), false, pullUpdate},
+		{"changed etag of an older copy", syncedEntry(false, 50, "etag1", 0), false, pullUpdate},
+		{"newer remote mtime", syncedEntry(false, 50, "etag2", 0), false, pullUpdate},
+		{"local file not synced", &filer_pb.Entry{Name: "file", Attributes: &filer_pb.FuseAttributes{Mtime: 50}}, false, pullSkip},
+		{"local file changed after synced", &filer_pb.Entry{
+			Name:        "file",
+			Attributes:  &filer_pb.FuseAttributes{Mtime: 150},
+			RemoteEntry: &filer_pb.RemoteEntry{RemoteMtime: 50, RemoteETag: "etag1"},
+		}, false, pullSkip},
+		{"remote directory", syncedEntry(true, 50, "", 0), true, pullSkip},
+		{"remote file over a local directory", syncedEntry(true, 50, "etag1", 0), false, pullSkip},
+		{"remote directory over a local file", syncedEntry(false, 50, "etag1", 0), true, pullSkip},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if action := pullActionOf(tc.existingEntry, tc.isDirectory, remoteEntry); action != tc.expected {
+				t.Errorf("pull action %d, expected %d", action, tc.expected)
+			}
+		})
+	}
+}
+
+func TestIsDeletedRemotely(t *testing.T) {
+	const pullStartTsNs = 1000
+
+	for _, tc := range []struct {
+		name             string
+		entry            *filer_pb.Entry
+		isListedRemotely bool
+		expected         bool
+	}{
+		{"synced file listed", syncedEntry(false, 100, "etag1", 500), true, false},
+		{"synced file not listed", syncedEntry(false, 100, "etag1", 500), false, true},
+		{"synced file pulled, never pushed", syncedEntry(false, 100, "etag1", 0), false, true},
+		{"pushed after the listing started", syncedEntry(false, 100, "etag1", pullStartTsNs), false, false},
+		{"pushed after the listing started, later", syncedEntry(false, 100, "etag1", pullStartTsNs+1), false, false},
+		{"local file not synced", &filer_pb.Entry{Name: "file", Attributes: &filer_pb.FuseAttributes{Mtime: 100}}, false, false},
+		{"local file changed after synced", &filer_pb.Entry{
+			Name:        "file",
+			Attributes:  &filer_pb.FuseAttributes{Mtime: 150},
+			RemoteEntry: &filer_pb.RemoteEntry{RemoteMtime: 100, LastLocalSyncTsNs: 500},
+		}, false, false},
+		{"directory", syncedEntry(true, 100, "", 500), false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if deleted := isDeletedRemotely(tc.entry, tc.isListedRemotely, pullStartTsNs); deleted != tc.expected {
+				t.Errorf("deleted remotely %v, expected %v", deleted, tc.expected)
+			}
+		})
+	}
+}
@@ -46,6 +46,9 @@ type SyncOptions struct {
 	metricsHttpIp   *string
 	metricsHttpPort *int
 	concurrency     *int
+	conflict        *string
+	conflictRules   *string
+	topology        *string
 	clientId        int32
 	clientEpoch     int32
 }
@@ -85,6 +88,9 @@ func init() {
 	syncOptions.aFromTsMs = cmdFilerSynchronize.Flag.Int64("a.fromTsMs", 0, "synchronization from timestamp on filer A. The unit is millisecond")
 	syncOptions.bFromTsMs = cmdFilerSynchronize.Flag.Int64("b.fromTsMs", 0, "synchronization from timestamp on filer B. The unit is millisecond")
 	syncOptions.concurrency = cmdFilerSynchronize.Flag.Int("concurrency", DefaultConcurrencyLimit, "The maximum number of files that will be synced concurrently.")
+	syncOptions.conflict = cmdFilerSynchronize.Flag.String("conflict", "overwrite", "[overwrite|lww|rename|queue] how to resolve a change meeting a target entry modified on the other side")
+	syncOptions.conflictRules = cmdFilerSynchronize.Flag.String("conflictRules", "", "comma separated per-path conflict strategies overriding -conflict, relative to a.path and b.path, e.g. \"/shared:rename,/logs:lww,/finance:queue\"")
+	syncOptions.topology = cmdFilerSynchronize.Flag.String("topology", "", "comma separated per-path directions, relative to a.path and b.path, e.g. \"/shared:both,/ingest:a2b,/reports:b2a,/scratch:none\"")
 	syncCpuProfile = cmdFilerSynchronize.Flag.String("cpuprofile", "", "cpu profile output file")
 	syncMemProfile = cmdFilerSynchronize.Flag.String("memprofile", "", "memory profile output file")
 	syncOptions.metricsHttpIp = cmdFilerSynchronize.Flag.String("metricsIp", "", "metrics listen ip")
@@ -107,6 +113,21 @@ var cmdFilerSynchronize = &Command{
 	If restarted, the synchronization will resume from the previous checkpoints, persisted every minute.
 	A fresh sync will start from the earliest metadata logs.
 
+	In active-active mode, both sides may change the same file concurrently. With -conflict other than "overwrite",
+	each change is checked against the current target entry, and a conflict is detected when the target content
+	is neither the version the change was based on, nor the incoming version. Conflicts are resolved by:
+
+	* lww: last writer wins, by mtime.
+	* rename: the target version is kept, and the incoming version is written as "<name>.conflict-<signature>-<ts><.ext>".
+	* queue: the target version is kept, and the incoming change is queued for manual resolution.
+
+	With -conflictRules, each path can be resolved by its own strategy, e.g. "-conflict=lww -conflictRules=/shared:rename".
+
+	Every conflict is recorded on the target filer under ` + replication.ConflictQueueDir + `,
+	and can be reported with "fs.sync.conflicts" in weed shell.
+
+	With -topology, each path can be synchronized in both directions, only a->b, only b->a, or not at all.
+
 `,
 }
 
@@ -120,6 +141,22 @@ func runFilerSynchronize(cmd *Command, args []string) bool {
 	filerA := pb.ServerAddress(*syncOptions.filerA)
 	filerB := pb.ServerAddress(*syncOptions.filerB)
 
+	conflictStrategy, err := replication.ParseConflictStrategy(*syncOptions.conflict)
+	if err != nil {
+		glog.Errorf("parse -conflict: %v", err)
+		return false
+	}
+	conflictPolicy, err := replication.ParseConflictPolicy(conflictStrategy, *syncOptions.conflictRules)
+	if err != nil {
+		glog.Errorf("parse -conflictRules: %v", err)
+		return false
+	}
+	syncTopology, err := replication.ParseSyncTopology(*syncOptions.topology)
+	if err != nil {
+		glog.Errorf("parse -topology: %v", err)
+		return false
+	}
+
 	// start filer.sync metrics server
 	go statsCollect.StartMetricsServer(*syncOptions.metricsHttpIp, *syncOptions.metricsHttpPort)
 
@@ -163,6 +200,9 @@ func runFilerSynchronize(cmd *Command, args []string) bool {
 				*syncOptions.bDiskType,
 				*syncOptions.bDebug,
 				*syncOptions.concurrency,
+				conflictPolicy,
+				syncTopology,
+				true,
 				aFilerSignature,
 				bFilerSignature)
 			if err != nil {
@@ -200,6 +240,9 @@ func runFilerSynchronize(cmd *Command, args []string) bool {
 					*syncOptions.aDiskType,
 					*syncOptions.aDebug,
 					*syncOptions.concurrency,
+					conflictPolicy,
+					syncTopology,
+					false,
 					bFilerSignature,
 					aFilerSignature)
 				if err != nil {
@@ -232,7 +275,8 @@ func initOffsetFromTsMs(grpcDialOption grpc.DialOption, targetFiler pb.ServerAdd
 }
 
 func doSubscribeFilerMetaChanges(clientId int32, clientEpoch int32, grpcDialOption grpc.DialOption, sourceFiler pb.ServerAddress, sourcePath string, sourceExcludePaths []string, sourceReadChunkFromFiler bool, targetFiler pb.ServerAddress, targetPath string,
-	replicationStr, collection string, ttlSec int, sinkWriteChunkByFiler bool, diskType string, debug bool, concurrency int, sourceFilerSignature int32, targetFilerSignature int32) error {
+	replicationStr, collection string, ttlSec int, sinkWriteChunkByFiler bool, diskType string, debug bool, concurrency int,
+	conflictPolicy *replication.ConflictPolicy, syncTopology *replication.SyncTopology, isFromA bool, sourceFilerSignature int32, targetFilerSignature int32) error {
 
 	// if first time, start from now
 	// if has previously synced, resume from that point of time
@@ -252,6 +296,11 @@ func doSubscribeFilerMetaChanges(clientId int32, clientEpoch int32, grpcDialOpti
 
 	persistEventFn := genProcessFunction(sourcePath, targetPath, sourceExcludePaths, filerSink, debug)
 
+	var conflictCheckFn func(resp *filer_pb.SubscribeMetadataResponse) (*filer_pb.SubscribeMetadataResponse, error)
+	if !conflictPolicy.IsOverwriteOnly() {
+		conflictCheckFn = genConflictCheckFunction(grpcDialOption, sourceFiler, targetFiler, sourcePath, targetPath, filerSink, conflictPolicy, sourceFilerSignature)
+	}
+
 	processEventFn := func(resp *filer_pb.SubscribeMetadataResponse) error {
 		message := resp.EventNotification
 		for _, sig := range message.Signatures {
@@ -260,6 +309,16 @@ func doSubscribeFilerMetaChanges(clientId int32, clientEpoch int32, grpcDialOpti
 				return nil
 			}
 		}
+		if !syncTopology.Allows(relativeSyncPath(resp, sourcePath), isFromA) {
+			return nil
+		}
+		if conflictCheckFn != nil {
+			checked, err := conflictCheckFn(resp)
+			if err != nil || checked == nil {
+				return err
+			}
+			resp = checked
+		}
 		return persistEventFn(resp)
 	}
 
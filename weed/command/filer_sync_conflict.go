@@ -0,0 +1,113 @@
+package command
+
+import (
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/replication"
+	"github.com/seaweedfs/seaweedfs/weed/replication/sink"
+	statsCollect "github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// relativeSyncPath returns the path of the event entry relative to the synchronized directory.
+func relativeSyncPath(resp *filer_pb.SubscribeMetadataResponse, sourcePath string) string {
+	message := resp.EventNotification
+	var fullPath string
+	if message.NewEntry != nil {
+		fullPath = string(util.FullPath(message.NewParentPath).Child(message.NewEntry.Name))
+	} else if message.OldEntry != nil {
+		fullPath = string(util.FullPath(resp.Directory).Child(message.OldEntry.Name))
+	}
+	if sourcePath == "/" {
+		return fullPath
+	}
+	return "/" + strings.TrimPrefix(strings.TrimPrefix(fullPath, sourcePath), "/")
+}
+
+// genConflictCheckFunction compares each incoming change with the current target entry.
+// It returns the event to replicate, which may be rewritten into a conflicted copy,
+// or nil if the change should not be replicated.
+func genConflictCheckFunction(grpcDialOption grpc.DialOption, sourceFiler, targetFiler pb.ServerAddress, sourcePath, targetPath string, dataSink sink.ReplicationSink,
+	conflictPolicy *replication.ConflictPolicy, sourceFilerSignature int32) func(resp *filer_pb.SubscribeMetadataResponse) (*filer_pb.SubscribeMetadataResponse, error) {
+
+	return func(resp *filer_pb.SubscribeMetadataResponse) (*filer_pb.SubscribeMetadataResponse, error) {
+		message := resp.EventNotification
+		if filer_pb.IsEmpty(resp) || dataSink.IsIncremental() {
+			return resp, nil
+		}
+		strategy := conflictPolicy.StrategyOf(relativeSyncPath(resp, sourcePath))
+		if strategy == replication.ConflictOverwrite {
+			return resp, nil
+		}
+
+		// find the target entry this change applies to, and the version the change was based on
+		var sourceKey util.FullPath
+		var base, incoming *filer_pb.Entry
+		if message.OldEntry != nil && strings.HasPrefix(resp.Directory, sourcePath) {
+			sourceKey, base = util.FullPath(resp.Directory).Child(message.OldEntry.Name), message.OldEntry
+		}
+		if message.NewEntry != nil && strings.HasPrefix(message.NewParentPath, sourcePath) {
+			incoming = message.NewEntry
+			if sourceKey == "" {
+				sourceKey = util.FullPath(message.NewParentPath).Child(message.NewEntry.Name)
+			}
+		}
+		if sourceKey == "" || !strings.HasPrefix(string(sourceKey), sourcePath) {
+			return resp, nil
+		}
+		targetKey := buildKey(dataSink, message, targetPath, sourceKey, sourcePath)
+
+		var existing *filer_pb.Entry
+		err := pb.WithFilerClient(false, sourceFilerSignature, targetFiler, grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+			dir, name := util.FullPath(targetKey).DirAndName()
+			lookupResp, lookupErr := filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{
+				Directory: dir,
+				Name:      name,
+			})
+			if lookupErr == filer_pb.ErrNotFound {
+				return nil
+			}
+			if lookupErr != nil {
+				return lookupErr
+			}
+			existing = lookupResp.Entry
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if !replication.IsConflict(existing, base, incoming) {
+			return resp, nil
+		}
+
+		resolution := replication.ResolveConflict(strategy, existing, incoming, resp.TsNs)
+		glog.V(0).Infof("conflict on %s from %s to %s: %s", targetKey, sourceFiler, targetFiler, resolution)
+		statsCollect.FilerSyncConflictCounter.WithLabelValues(sourceFiler.String(), targetFiler.String(), resolution.String()).Inc()
+
+		record := replication.NewConflictRecord(targetKey, sourceFiler.String(), targetFiler.String(), strategy, resolution, existing, resp)
+		if err = pb.WithFilerClient(false, sourceFilerSignature, targetFiler, grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+			return replication.SaveConflictRecord(client, record, []int32{sourceFilerSignature})
+		}); err != nil {
+			return nil, err
+		}
+
+		switch resolution {
+		case replication.ApplyIncoming:
+			return resp, nil
+		case replication.WriteConflictedCopy:
+			conflicted := proto.Clone(resp).(*filer_pb.SubscribeMetadataResponse)
+			conflicted.Directory = message.NewParentPath
+			conflicted.EventNotification.OldEntry = nil
+			conflicted.EventNotification.NewEntry.Name = replication.ConflictedCopyName(message.NewEntry.Name, sourceFilerSignature, resp.TsNs)
+			return conflicted, nil
+		}
+		return nil, nil
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/seaweedfs/raft/protobuf"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
 	stats_collect "github.com/seaweedfs/seaweedfs/weed/stats"
@@ -27,6 +28,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/security"
 	weed_server "github.com/seaweedfs/seaweedfs/weed/server"
 	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
@@ -41,21 +43,52 @@ type MasterOptions struct {
 	ipBind            *string
 	metaFolder        *string
 	peers             *string
+	learners          *string
 	volumeSizeLimitMB *uint
 	volumePreallocate *bool
 	// pulseSeconds       *int
-	defaultReplication *string
-	garbageThreshold   *float64
-	whiteList          *string
-	disableHttp        *bool
-	metricsAddress     *string
-	metricsIntervalSec *int
-	raftResumeState    *bool
-	metricsHttpPort    *int
-	heartbeatInterval  *time.Duration
-	electionTimeout    *time.Duration
-	raftHashicorp      *bool
-	raftBootstrap      *bool
+	defaultReplication   *string
+	garbageThreshold     *float64
+	whiteList            *string
+	disableHttp          *bool
+	metricsAddress       *string
+	metricsIntervalSec   *int
+	raftResumeState      *bool
+	metricsHttpPort      *int
+	heartbeatInterval    *time.Duration
+	electionTimeout      *time.Duration
+	raftHashicorp        *bool
+	raftBootstrap        *bool
+	drainInterval        *time.Duration
+	drainMBPerSecond     *int
+	tierColdAfter        *time.Duration
+	tierHotReadsPerHour  *int
+	tierHotDiskType      *string
+	tierColdDiskType     *string
+	tierMBPerSecond      *int
+	archiveCollections   *string
+	placementCollections *string
+	rebalanceInterval    *time.Duration
+	rebalanceWindow      *string
+	rebalanceMaxMoves    *int
+	rebalanceMBPerSecond *int
+	rebalanceBand        *float64
+	growthCollections    *string
+	quotaCollections     *string
+	ecMaxShardsPerRack   *int
+	ecMaxShardsPerDc     *int
+	admissionConcurrent  *int
+	admissionQueued      *int
+	admissionTimeout     *time.Duration
+	admissionPerClient   *int
+	repairConcurrent     *int
+	repairDelay          *time.Duration
+	repairMBPerSecond    *int
+	volumeIdRange        *string
+	lifecycleCollections *string
+	lifecycleDryRun      *bool
+	shutdownDrainTimeout *time.Duration
+	historyRetention     *time.Duration
 }
 
 func init() {
@@ -66,6 +99,7 @@ func init() {
 	m.ipBind = cmdMaster.Flag.String("ip.bind", "", "ip address to bind to. If empty, default to same as -ip option.")
 	m.metaFolder = cmdMaster.Flag.String("mdir", os.TempDir(), "data directory to store meta data")
 	m.peers = cmdMaster.Flag.String("peers", "", "all master nodes in comma separated ip:port list, example: 127.0.0.1:9093,127.0.0.1:9094,127.0.0.1:9095")
+	m.learners = cmdMaster.Flag.String("learners", "", "the masters among -peers joining as non-voting learners, which replicate the state without counting for the quorum, comma separated ip:port list, only with -raftHashicorp")
 	m.volumeSizeLimitMB = cmdMaster.Flag.Uint("volumeSizeLimitMB", 30*1000, "Master stops directing writes to oversized volumes.")
 	m.volumePreallocate = cmdMaster.Flag.Bool("volumePreallocate", false, "Preallocate disk space for volumes.")
 	// m.pulseSeconds = cmdMaster.Flag.Int("pulseSeconds", 5, "number of seconds between heartbeats")
@@ -81,6 +115,36 @@ func init() {
 	m.electionTimeout = cmdMaster.Flag.Duration("electionTimeout", 10*time.Second, "election timeout of master servers")
 	m.raftHashicorp = cmdMaster.Flag.Bool("raftHashicorp", false, "use hashicorp raft")
 	m.raftBootstrap = cmdMaster.Flag.Bool("raftBootstrap", false, "Whether to bootstrap the Raft cluster")
+	m.drainInterval = cmdMaster.Flag.Duration("drain.interval", 10*time.Second, "pause between moving the volumes or ec shards away from a draining volume server")
+	m.drainMBPerSecond = cmdMaster.Flag.Int("drain.MBPerSecond", 0, "limit the copying speed of each volume moved away from a draining volume server, 0 means no limit")
+	m.tierColdAfter = cmdMaster.Flag.Duration("tier.coldAfter", 0, "move the volumes not read or written for this long from the hot disk type to the cold disk type, 0 to disable")
+	m.tierHotReadsPerHour = cmdMaster.Flag.Int("tier.hotReadsPerHour", 0, "move the volumes read at least this many times per hour from the cold disk type to the hot disk type, 0 to disable")
+	m.tierHotDiskType = cmdMaster.Flag.String("tier.hotDiskType", "ssd", "the disk type of the hot volumes")
+	m.tierColdDiskType = cmdMaster.Flag.String("tier.coldDiskType", "hdd", "the disk type of the cold volumes")
+	m.tierMBPerSecond = cmdMaster.Flag.Int("tier.MBPerSecond", 0, "limit the copying speed of each volume moved between the disk types, 0 means no limit")
+	m.archiveCollections = cmdMaster.Flag.String("ec.archiveCollections", "", "comma separated collections written without replicas and erasure coded once a volume is full, each with an optional ec scheme, e.g. logs,backup:8+3")
+	m.placementCollections = cmdMaster.Flag.String("placement.collections", "", "semicolon separated collections, each placed only on the volume servers with the matching -tags, e.g. \"logs:hw=hdd;images:zone=a|b,hw=nvme\"")
+	m.rebalanceInterval = cmdMaster.Flag.Duration("rebalance.interval", 0, "move the volumes from the most utilized volume servers to the least utilized ones this often, 0 to disable")
+	m.rebalanceWindow = cmdMaster.Flag.String("rebalance.window", "", "only rebalance within this daily time window, e.g. 01:00-05:00, default to any time")
+	m.rebalanceMaxMoves = cmdMaster.Flag.Int("rebalance.maxMoves", 2, "the max number of volumes moved concurrently when rebalancing")
+	m.rebalanceMBPerSecond = cmdMaster.Flag.Int("rebalance.MBPerSecond", 0, "limit the copying speed of each volume moved when rebalancing, 0 means no limit")
+	m.growthCollections = cmdMaster.Flag.String("volumeGrowth.collections", "", "semicolon separated collections, each with its volume growth policy overriding master.volume_growth in master.toml, e.g. \"logs:threshold=0.8,count=2,disk=hdd,maxVolumes=100;images:maxSize=10TiB\"")
+	m.quotaCollections = cmdMaster.Flag.String("quota.collections", "", "semicolon separated collections, each with its quota of volumes and logical bytes, and the usage percentage to warn at, e.g. \"tenant1:maxVolumes=100,maxSize=10TiB,warn=90;tenant2:maxSize=1TiB\"")
+	m.ecMaxShardsPerRack = cmdMaster.Flag.Int("ec.maxShardsPerRack", 0, "the max ec shards of each volume on one rack, at most the parity shards for the volumes to survive a rack failure, e.g. 4 for 10+4, 0 means no limit")
+	m.ecMaxShardsPerDc = cmdMaster.Flag.Int("ec.maxShardsPerDataCenter", 0, "the max ec shards of each volume in one data center, at most the parity shards for the volumes to survive a data center failure, 0 means no limit")
+	m.admissionConcurrent = cmdMaster.Flag.Int("admission.maxConcurrent", 0, "the max assign and lookup requests handled at a time, to keep the leader responsive under request storms, 0 means no limit")
+	m.admissionQueued = cmdMaster.Flag.Int("admission.maxQueued", 1000, "the max assign and lookup requests waiting beyond admission.maxConcurrent, the others are rejected with a retry hint")
+	m.admissionTimeout = cmdMaster.Flag.Duration("admission.queueTimeout", 2*time.Second, "how long an assign or lookup request waits in the admission queue before rejected with a retry hint")
+	m.admissionPerClient = cmdMaster.Flag.Int("admission.maxPerClient", 0, "the max assign and lookup requests of one client handled or waiting at a time, 0 means no limit")
+	m.repairConcurrent = cmdMaster.Flag.Int("repair.maxConcurrent", 0, "the max volumes re-replicated by the leader at a time after the volume servers fail, the volumes with the fewest replicas left first, 0 leaves the repair to volume.fix.replication")
+	m.repairDelay = cmdMaster.Flag.Duration("repair.delay", 15*time.Minute, "how long a volume misses replicas before the leader re-replicates it, to ride out the volume server restarts")
+	m.repairMBPerSecond = cmdMaster.Flag.Int("repair.MBPerSecond", 0, "limit the total copying speed of the volumes re-replicated at a time, 0 means no limit")
+	m.volumeIdRange = cmdMaster.Flag.String("volumeIdRange", "", "the inclusive range of the volume ids to allocate, e.g. 1000000-1999999, disjoint across the clusters to be merged or replicated to each other later, default to all volume ids")
+	m.lifecycleCollections = cmdMaster.Flag.String("lifecycle.collections", "", "semicolon separated collections, each with its lifecycle by the time since the last write of each volume: seal as read only, move to the remote tier, and delete, e.g. \"logs:sealAfter=1d,tierAfter=7d,tierBackend=s3.default,deleteAfter=30d;backup:deleteAfter=90d\"")
+	m.lifecycleDryRun = cmdMaster.Flag.Bool("lifecycle.dryRun", false, "only report the lifecycle actions due, in the log and by collection.lifecycle in weed shell, without applying them")
+	m.shutdownDrainTimeout = cmdMaster.Flag.Duration("shutdown.drainTimeout", 10*time.Second, "on shutdown, how long to wait for the assign and lookup requests in flight, after rejecting the new ones for the clients to retry on the other masters")
+	m.historyRetention = cmdMaster.Flag.Duration("history.retention", 30*24*time.Hour, "how long to keep the topology changes recorded by the leader in -mdir, listed by cluster.history in weed shell, 0 to not record them")
+	m.rebalanceBand = cmdMaster.Flag.Float64("rebalance.band", 10, "keep the volume slot utilization of the volume servers within this many percent of each other")
 }
 
 var cmdMaster = &Command{
@@ -137,7 +201,10 @@ func startMaster(masterOption MasterOptions, masterWhiteList []string) {
 		*masterOption.ipBind = *masterOption.ip
 	}
 
-	myMasterAddress, peers := checkPeers(*masterOption.ip, *masterOption.port, *masterOption.portGrpc, *masterOption.peers)
+	myMasterAddress, peers, learners := checkPeers(*masterOption.ip, *masterOption.port, *masterOption.portGrpc, *masterOption.peers, *masterOption.learners)
+	if len(learners) > 0 && !*masterOption.raftHashicorp {
+		glog.Fatalf("-learners is only supported with -raftHashicorp")
+	}
 
 	masterPeers := make(map[string]pb.ServerAddress)
 	for _, peer := range peers {
@@ -165,6 +232,7 @@ func startMaster(masterOption MasterOptions, masterWhiteList []string) {
 		HeartbeatInterval: *masterOption.heartbeatInterval,
 		ElectionTimeout:   *masterOption.electionTimeout,
 		RaftBootstrap:     *masterOption.raftBootstrap,
+		Learners:          learners,
 	}
 	var raftServer *weed_server.RaftServer
 	var err error
@@ -190,7 +258,8 @@ func startMaster(masterOption MasterOptions, masterWhiteList []string) {
 	if err != nil {
 		glog.Fatalf("master failed to listen on grpc port %d: %v", grpcPort, err)
 	}
-	grpcS := pb.NewGrpcServer(security.LoadServerTLS(util.GetViper(), "grpc.master"))
+	grpcTlsOption, grpcAuthOption := security.LoadServerTLS(util.GetViper(), "grpc.master")
+	grpcS := pb.NewGrpcServer(append([]grpc.ServerOption{grpcTlsOption, grpcAuthOption}, ms.GrpcServerOptions()...)...)
 	master_pb.RegisterSeaweedServer(grpcS, ms)
 	if *masterOption.raftHashicorp {
 		raftServer.TransportManager.Register(grpcS)
@@ -265,8 +334,8 @@ func startMaster(masterOption MasterOptions, masterWhiteList []string) {
 	select {}
 }
 
-func checkPeers(masterIp string, masterPort int, masterGrpcPort int, peers string) (masterAddress pb.ServerAddress, cleanedPeers []pb.ServerAddress) {
-	glog.V(0).Infof("current: %s:%d peers:%s", masterIp, masterPort, peers)
+func checkPeers(masterIp string, masterPort int, masterGrpcPort int, peers string, learners string) (masterAddress pb.ServerAddress, cleanedPeers []pb.ServerAddress, learnerPeers map[string]bool) {
+	glog.V(0).Infof("current: %s:%d peers:%s learners:%s", masterIp, masterPort, peers, learners)
 	masterAddress = pb.NewServerAddress(masterIp, masterPort, masterGrpcPort)
 	cleanedPeers = pb.ServerAddresses(peers).ToAddresses()
 
@@ -281,8 +350,30 @@ func checkPeers(masterIp string, masterPort int, masterGrpcPort int, peers strin
 	if !hasSelf {
 		cleanedPeers = append(cleanedPeers, masterAddress)
 	}
-	if len(cleanedPeers)%2 == 0 {
-		glog.Fatalf("Only odd number of masters are supported: %+v", cleanedPeers)
+	learnerPeers, err := checkLearners(cleanedPeers, learners)
+	if err != nil {
+		glog.Fatalf("%v", err)
+	}
+	if (len(cleanedPeers)-len(learnerPeers))%2 == 0 {
+		glog.Fatalf("Only odd number of voting masters are supported: %+v, learners %s", cleanedPeers, learners)
+	}
+	return
+}
+
+// checkLearners finds the peers of the learners, which must be among the peers
+func checkLearners(peers []pb.ServerAddress, learners string) (learnerPeers map[string]bool, err error) {
+	learnerPeers = make(map[string]bool)
+	for _, learner := range pb.ServerAddresses(learners).ToAddresses() {
+		found := false
+		for _, peer := range peers {
+			if peer.ToHttpAddress() == learner.ToHttpAddress() {
+				learnerPeers[string(peer)] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("learner %s is not among the peers %+v", learner, peers)
+		}
 	}
 	return
 }
@@ -299,6 +390,34 @@ func isTheFirstOne(self pb.ServerAddress, peers []pb.ServerAddress) bool {
 
 func (m *MasterOptions) toMasterOption(whiteList []string) *weed_server.MasterOption {
 	masterAddress := pb.NewServerAddress(*m.ip, *m.port, *m.portGrpc)
+	archiveCollections, err := weed_server.ParseArchiveCollections(*m.archiveCollections)
+	if err != nil {
+		glog.Fatalf("ec.archiveCollections: %v", err)
+	}
+	placementCollections, err := weed_server.ParsePlacementCollections(*m.placementCollections)
+	if err != nil {
+		glog.Fatalf("placement.collections: %v", err)
+	}
+	growthCollections, err := weed_server.ParseVolumeGrowthCollections(*m.growthCollections)
+	if err != nil {
+		glog.Fatalf("volumeGrowth.collections: %v", err)
+	}
+	quotaCollections, err := weed_server.ParseCollectionQuotas(*m.quotaCollections)
+	if err != nil {
+		glog.Fatalf("quota.collections: %v", err)
+	}
+	volumeIdRange, err := topology.ParseVolumeIdRange(*m.volumeIdRange)
+	if err != nil {
+		glog.Fatalf("volumeIdRange: %v", err)
+	}
+	lifecycleCollections, err := weed_server.ParseLifecycleCollections(*m.lifecycleCollections)
+	if err != nil {
+		glog.Fatalf("lifecycle.collections: %v", err)
+	}
+	rebalanceWindow, err := weed_server.ParseRebalanceWindow(*m.rebalanceWindow)
+	if err != nil {
+		glog.Fatalf("rebalance.window: %v", err)
+	}
 	return &weed_server.MasterOption{
 		Master:            masterAddress,
 		MetaFolder:        *m.metaFolder,
@@ -311,5 +430,45 @@ func (m *MasterOptions) toMasterOption(whiteList []string) *weed_server.MasterOp
 		DisableHttp:             *m.disableHttp,
 		MetricsAddress:          *m.metricsAddress,
 		MetricsIntervalSec:      *m.metricsIntervalSec,
+		DrainInterval:           *m.drainInterval,
+		DrainBytePerSecond:      int64(*m.drainMBPerSecond) * 1024 * 1024,
+		TierPolicy: weed_server.TierPolicy{
+			ColdAfter:       *m.tierColdAfter,
+			HotReadsPerHour: *m.tierHotReadsPerHour,
+			HotDiskType:     *m.tierHotDiskType,
+			ColdDiskType:    *m.tierColdDiskType,
+			BytePerSecond:   int64(*m.tierMBPerSecond) * 1024 * 1024,
+		},
+		ArchiveCollections:   archiveCollections,
+		PlacementCollections: placementCollections,
+		RebalancePolicy: weed_server.RebalancePolicy{
+			Interval:           *m.rebalanceInterval,
+			Window:             rebalanceWindow,
+			MaxConcurrentMoves: *m.rebalanceMaxMoves,
+			BytePerSecond:      int64(*m.rebalanceMBPerSecond) * 1024 * 1024,
+			Band:               *m.rebalanceBand / 100,
+		},
+		VolumeGrowthCollections: growthCollections,
+		QuotaCollections:        quotaCollections,
+		VolumeIdRange:           volumeIdRange,
+		LifecycleCollections:    lifecycleCollections,
+		LifecycleDryRun:         *m.lifecycleDryRun,
+		ShutdownDrainTimeout:    *m.shutdownDrainTimeout,
+		HistoryRetention:        *m.historyRetention,
+		EcPlacementPolicy: weed_server.EcPlacementPolicy{
+			MaxShardsPerRack:       *m.ecMaxShardsPerRack,
+			MaxShardsPerDataCenter: *m.ecMaxShardsPerDc,
+		},
+		AdmissionPolicy: weed_server.AdmissionPolicy{
+			MaxConcurrent: *m.admissionConcurrent,
+			MaxQueued:     *m.admissionQueued,
+			QueueTimeout:  *m.admissionTimeout,
+			MaxPerClient:  *m.admissionPerClient,
+		},
+		RepairPolicy: weed_server.RepairPolicy{
+			MaxConcurrent: *m.repairConcurrent,
+			Delay:         *m.repairDelay,
+			BytePerSecond: int64(*m.repairMBPerSecond) * 1024 * 1024,
+		},
 	}
 }
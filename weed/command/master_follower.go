@@ -39,6 +39,36 @@ func init() {
 	mf.metricsAddress = aws.String("")
 	mf.metricsIntervalSec = aws.Int(0)
 	mf.raftResumeState = aws.Bool(false)
+	mf.drainInterval = new(time.Duration)
+	mf.drainMBPerSecond = aws.Int(0)
+	mf.tierColdAfter = new(time.Duration)
+	mf.tierHotReadsPerHour = aws.Int(0)
+	mf.tierHotDiskType = aws.String("")
+	mf.tierColdDiskType = aws.String("")
+	mf.tierMBPerSecond = aws.Int(0)
+	mf.archiveCollections = aws.String("")
+	mf.placementCollections = aws.String("")
+	mf.rebalanceInterval = new(time.Duration)
+	mf.rebalanceWindow = aws.String("")
+	mf.rebalanceMaxMoves = aws.Int(0)
+	mf.rebalanceMBPerSecond = aws.Int(0)
+	mf.rebalanceBand = aws.Float64(0)
+	mf.growthCollections = aws.String("")
+	mf.quotaCollections = aws.String("")
+	mf.ecMaxShardsPerRack = aws.Int(0)
+	mf.ecMaxShardsPerDc = aws.Int(0)
+	mf.admissionConcurrent = aws.Int(0)
+	mf.admissionQueued = aws.Int(0)
+	mf.admissionTimeout = new(time.Duration)
+	mf.admissionPerClient = aws.Int(0)
+	mf.repairConcurrent = aws.Int(0)
+	mf.repairDelay = new(time.Duration)
+	mf.repairMBPerSecond = aws.Int(0)
+	mf.volumeIdRange = aws.String("")
+	mf.lifecycleCollections = aws.String("")
+	mf.lifecycleDryRun = aws.Bool(false)
+	mf.shutdownDrainTimeout = new(time.Duration)
+	mf.historyRetention = new(time.Duration)
 }
 
 var cmdMasterFollower = &Command{
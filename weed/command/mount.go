@@ -21,6 +21,8 @@ type MountOptions struct {
 	cacheDirForWrite   *string
 	cacheSizeMBForRead *int64
 	dataCenter         *string
+	rack               *string
+	readPreference     *string
 	allowOthers        *bool
 	umaskString        *string
 	nonempty           *bool
@@ -59,6 +61,8 @@ func init() {
 	mountOptions.cacheSizeMBForRead = cmdMount.Flag.Int64("cacheCapacityMB", 0, "file chunk read cache capacity in MB")
 	mountOptions.cacheDirForWrite = cmdMount.Flag.String("cacheDirWrite", os.TempDir(), "buffer writes mostly for large files")
 	mountOptions.dataCenter = cmdMount.Flag.String("dataCenter", "", "prefer to write to the data center")
+	mountOptions.rack = cmdMount.Flag.String("rack", "", "the rack of this client, to read from the replicas on the same rack first")
+	mountOptions.readPreference = cmdMount.Flag.String("readPreference", "preferLocal", "[preferLocal|localOnly] read from the nearest replicas first, or only from the replicas in the data center if it has any")
 	mountOptions.allowOthers = cmdMount.Flag.Bool("allowOthers", true, "allows other users to access the file system")
 	mountOptions.umaskString = cmdMount.Flag.String("umask", "022", "octal umask, e.g., 022, 0111")
 	mountOptions.nonempty = cmdMount.Flag.Bool("nonempty", false, "allows the mounting over a non-empty directory")
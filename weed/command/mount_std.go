@@ -16,6 +16,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/pb/mount_pb"
 	"github.com/seaweedfs/seaweedfs/weed/security"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/wdclient"
 	"google.golang.org/grpc/reflection"
 	"net"
 	"net/http"
@@ -63,6 +64,11 @@ func RunMount(option *MountOptions, umask os.FileMode) bool {
 		fmt.Printf("Please specify a reasonable buffer size.")
 		return false
 	}
+	readPreference, readPreferenceErr := wdclient.ParseReadPreference(*option.readPreference)
+	if readPreferenceErr != nil {
+		fmt.Printf("invalid -readPreference: %v\n", readPreferenceErr)
+		return false
+	}
 
 	// try to connect to filer
 	filerAddresses := pb.ServerAddresses(*option.filer).ToAddresses()
@@ -229,6 +235,8 @@ func RunMount(option *MountOptions, umask os.FileMode) bool {
 		CacheSizeMBForRead: *option.cacheSizeMBForRead,
 		CacheDirForWrite:   *option.cacheDirForWrite,
 		DataCenter:         *option.dataCenter,
+		Rack:               *option.rack,
+		ReadPreference:     readPreference,
 		Quota:              int64(*option.collectionQuota) * 1024 * 1024,
 		MountUid:           uid,
 		MountGid:           gid,
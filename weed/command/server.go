@@ -89,6 +89,7 @@ func init() {
 	masterOptions.portGrpc = cmdServer.Flag.Int("master.port.grpc", 0, "master server grpc listen port")
 	masterOptions.metaFolder = cmdServer.Flag.String("master.dir", "", "data directory to store meta data, default to same as -dir specified")
 	masterOptions.peers = cmdServer.Flag.String("master.peers", "", "all master nodes in comma separated ip:masterPort list")
+	masterOptions.learners = cmdServer.Flag.String("master.learners", "", "the masters among -master.peers joining as non-voting learners, comma separated ip:masterPort list, only with -master.raftHashicorp")
 	masterOptions.volumeSizeLimitMB = cmdServer.Flag.Uint("master.volumeSizeLimitMB", 30*1000, "Master stops directing writes to oversized volumes.")
 	masterOptions.volumePreallocate = cmdServer.Flag.Bool("master.volumePreallocate", false, "Preallocate disk space for volumes.")
 	masterOptions.defaultReplication = cmdServer.Flag.String("master.defaultReplication", "", "Default replication type if not specified.")
@@ -100,6 +101,36 @@ func init() {
 	masterOptions.raftBootstrap = cmdMaster.Flag.Bool("master.raftBootstrap", false, "Whether to bootstrap the Raft cluster")
 	masterOptions.heartbeatInterval = cmdServer.Flag.Duration("master.heartbeatInterval", 300*time.Millisecond, "heartbeat interval of master servers, and will be randomly multiplied by [1, 1.25)")
 	masterOptions.electionTimeout = cmdServer.Flag.Duration("master.electionTimeout", 10*time.Second, "election timeout of master servers")
+	masterOptions.drainInterval = cmdServer.Flag.Duration("master.drain.interval", 10*time.Second, "pause between moving the volumes or ec shards away from a draining volume server")
+	masterOptions.drainMBPerSecond = cmdServer.Flag.Int("master.drain.MBPerSecond", 0, "limit the copying speed of each volume moved away from a draining volume server, 0 means no limit")
+	masterOptions.tierColdAfter = cmdServer.Flag.Duration("master.tier.coldAfter", 0, "move the volumes not read or written for this long from the hot disk type to the cold disk type, 0 to disable")
+	masterOptions.tierHotReadsPerHour = cmdServer.Flag.Int("master.tier.hotReadsPerHour", 0, "move the volumes read at least this many times per hour from the cold disk type to the hot disk type, 0 to disable")
+	masterOptions.tierHotDiskType = cmdServer.Flag.String("master.tier.hotDiskType", "ssd", "the disk type of the hot volumes")
+	masterOptions.tierColdDiskType = cmdServer.Flag.String("master.tier.coldDiskType", "hdd", "the disk type of the cold volumes")
+	masterOptions.tierMBPerSecond = cmdServer.Flag.Int("master.tier.MBPerSecond", 0, "limit the copying speed of each volume moved between the disk types, 0 means no limit")
+	masterOptions.archiveCollections = cmdServer.Flag.String("master.ec.archiveCollections", "", "comma separated collections written without replicas and erasure coded once a volume is full, each with an optional ec scheme, e.g. logs,backup:8+3")
+	masterOptions.placementCollections = cmdServer.Flag.String("master.placement.collections", "", "semicolon separated collections, each placed only on the volume servers with the matching -tags, e.g. \"logs:hw=hdd;images:zone=a|b,hw=nvme\"")
+	masterOptions.rebalanceInterval = cmdServer.Flag.Duration("master.rebalance.interval", 0, "move the volumes from the most utilized volume servers to the least utilized ones this often, 0 to disable")
+	masterOptions.rebalanceWindow = cmdServer.Flag.String("master.rebalance.window", "", "only rebalance within this daily time window, e.g. 01:00-05:00, default to any time")
+	masterOptions.rebalanceMaxMoves = cmdServer.Flag.Int("master.rebalance.maxMoves", 2, "the max number of volumes moved concurrently when rebalancing")
+	masterOptions.rebalanceMBPerSecond = cmdServer.Flag.Int("master.rebalance.MBPerSecond", 0, "limit the copying speed of each volume moved when rebalancing, 0 means no limit")
+	masterOptions.growthCollections = cmdServer.Flag.String("master.volumeGrowth.collections", "", "semicolon separated collections, each with its volume growth policy overriding master.volume_growth in master.toml, e.g. \"logs:threshold=0.8,count=2,disk=hdd,maxVolumes=100;images:maxSize=10TiB\"")
+	masterOptions.quotaCollections = cmdServer.Flag.String("master.quota.collections", "", "semicolon separated collections, each with its quota of volumes and logical bytes, and the usage percentage to warn at, e.g. \"tenant1:maxVolumes=100,maxSize=10TiB,warn=90;tenant2:maxSize=1TiB\"")
+	masterOptions.ecMaxShardsPerRack = cmdServer.Flag.Int("master.ec.maxShardsPerRack", 0, "the max ec shards of each volume on one rack, at most the parity shards for the volumes to survive a rack failure, e.g. 4 for 10+4, 0 means no limit")
+	masterOptions.ecMaxShardsPerDc = cmdServer.Flag.Int("master.ec.maxShardsPerDataCenter", 0, "the max ec shards of each volume in one data center, at most the parity shards for the volumes to survive a data center failure, 0 means no limit")
+	masterOptions.admissionConcurrent = cmdServer.Flag.Int("master.admission.maxConcurrent", 0, "the max assign and lookup requests handled at a time, to keep the leader responsive under request storms, 0 means no limit")
+	masterOptions.admissionQueued = cmdServer.Flag.Int("master.admission.maxQueued", 1000, "the max assign and lookup requests waiting beyond admission.maxConcurrent, the others are rejected with a retry hint")
+	masterOptions.admissionTimeout = cmdServer.Flag.Duration("master.admission.queueTimeout", 2*time.Second, "how long an assign or lookup request waits in the admission queue before rejected with a retry hint")
+	masterOptions.admissionPerClient = cmdServer.Flag.Int("master.admission.maxPerClient", 0, "the max assign and lookup requests of one client handled or waiting at a time, 0 means no limit")
+	masterOptions.repairConcurrent = cmdServer.Flag.Int("master.repair.maxConcurrent", 0, "the max volumes re-replicated by the leader at a time after the volume servers fail, the volumes with the fewest replicas left first, 0 leaves the repair to volume.fix.replication")
+	masterOptions.repairDelay = cmdServer.Flag.Duration("master.repair.delay", 15*time.Minute, "how long a volume misses replicas before the leader re-replicates it, to ride out the volume server restarts")
+	masterOptions.repairMBPerSecond = cmdServer.Flag.Int("master.repair.MBPerSecond", 0, "limit the total copying speed of the volumes re-replicated at a time, 0 means no limit")
+	masterOptions.volumeIdRange = cmdServer.Flag.String("master.volumeIdRange", "", "the inclusive range of the volume ids to allocate, e.g. 1000000-1999999, disjoint across the clusters to be merged or replicated to each other later, default to all volume ids")
+	masterOptions.lifecycleCollections = cmdServer.Flag.String("master.lifecycle.collections", "", "semicolon separated collections, each with its lifecycle by the time since the last write of each volume: seal as read only, move to the remote tier, and delete, e.g. \"logs:sealAfter=1d,tierAfter=7d,tierBackend=s3.default,deleteAfter=30d;backup:deleteAfter=90d\"")
+	masterOptions.lifecycleDryRun = cmdServer.Flag.Bool("master.lifecycle.dryRun", false, "only report the lifecycle actions due, in the log and by collection.lifecycle in weed shell, without applying them")
+	masterOptions.shutdownDrainTimeout = cmdServer.Flag.Duration("master.shutdown.drainTimeout", 10*time.Second, "on shutdown, how long to wait for the assign and lookup requests in flight, after rejecting the new ones for the clients to retry on the other masters")
+	masterOptions.historyRetention = cmdServer.Flag.Duration("master.history.retention", 30*24*time.Hour, "how long to keep the topology changes recorded by the leader in -mdir, listed by cluster.history in weed shell, 0 to not record them")
+	masterOptions.rebalanceBand = cmdServer.Flag.Float64("master.rebalance.band", 10, "keep the volume slot utilization of the volume servers within this many percent of each other")
 
 	filerOptions.filerGroup = cmdServer.Flag.String("filer.filerGroup", "", "share metadata with other filers in the same filerGroup")
 	filerOptions.collection = cmdServer.Flag.String("filer.collection", "", "all data will be stored in this collection")
@@ -117,16 +148,38 @@ func init() {
 	filerOptions.showUIDirectoryDelete = cmdServer.Flag.Bool("filer.ui.deleteDir", true, "enable filer UI show delete directory button")
 	filerOptions.downloadMaxMBps = cmdServer.Flag.Int("filer.downloadMaxMBps", 0, "download max speed for each download request, in MB per second")
 	filerOptions.diskType = cmdServer.Flag.String("filer.disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
+	filerOptions.packIntervalMinutes = cmdServer.Flag.Int("filer.packSmallFiles.intervalMinutes", 60, "interval to pack small files in folders configured by \"fs.configure -packSmallFiles\", 0 to disable")
+	filerOptions.packMaxFileKB = cmdServer.Flag.Int("filer.packSmallFiles.maxKB", 64, "pack files smaller than this limit")
+	filerOptions.packMinFiles = cmdServer.Flag.Int("filer.packSmallFiles.minFiles", 16, "only pack a folder with at least this many small files")
+	filerOptions.dirChecksums = cmdServer.Flag.Bool("filer.dirChecksums", false, "maintain a checksum of each directory tree, to find changed sub trees quickly")
+	filerOptions.usageStats = cmdServer.Flag.Bool("filer.usageStats", false, "maintain the total file size and file count of each collection and top level directory")
+	filerOptions.xattrIndexKeys = cmdServer.Flag.String("filer.xattrIndex.keys", "", "comma separated extended attribute keys to index, e.g. \"user.project,Seaweed-Team\", for \"fs.find.xattr\"")
+	filerOptions.metaCacheEntries = cmdServer.Flag.Int64("filer.metaCache.entries", 0, "cache this many entries and listed entries of the filer store in memory, for slow filer stores, 0 to disable")
+	filerOptions.metaCacheTtl = cmdServer.Flag.Duration("filer.metaCache.ttl", time.Minute, "how long to cache the filer store entries in memory")
+	filerOptions.asOfMaxAge = cmdServer.Flag.Duration("filer.asOf.maxAge", 24*time.Hour, "how far back to read and list as of a past time, replaying the metadata log since then")
+	filerOptions.resolveSymlinks = cmdServer.Flag.Bool("filer.resolveSymlinks", false, "read the targets of symlinks over http and s3, instead of the symlinks, unless \"?resolveSymlinks=false\"")
+	filerOptions.clientRequestsPerSecond = cmdServer.Flag.Float64("filer.clientLimit.requestsPerSecond", 0, "limit the requests per second of each client, by its jwt subject or ip address, 0 means no limit")
+	filerOptions.clientConcurrency = cmdServer.Flag.Int("filer.clientLimit.concurrentRequests", 0, "limit the concurrent requests of each client, 0 means no limit")
+	filerOptions.clientMBps = cmdServer.Flag.Int("filer.clientLimit.MBps", 0, "limit the http upload and download speed of each client, in MB per second, 0 means no limit")
+	filerOptions.readPreference = cmdServer.Flag.String("filer.readPreference", "preferLocal", "[preferLocal|localOnly] read from the replicas on the same rack and data center first, or only from the replicas in the data center if it has any")
+	filerOptions.clientLimitOverrides = cmdServer.Flag.String("filer.clientLimit.overrides", "", "limits of specific clients, e.g. \"backup=0/4/50,10.0.0.8=500/0/0\" for <client>=<requestsPerSecond>/<concurrentRequests>/<MBps>")
 
 	serverOptions.v.port = cmdServer.Flag.Int("volume.port", 8080, "volume server http listen port")
 	serverOptions.v.portGrpc = cmdServer.Flag.Int("volume.port.grpc", 0, "volume server grpc listen port")
 	serverOptions.v.publicPort = cmdServer.Flag.Int("volume.port.public", 0, "volume server public port")
-	serverOptions.v.indexType = cmdServer.Flag.String("volume.index", "memory", "Choose [memory|leveldb|leveldbMedium|leveldbLarge] mode for memory~performance balance.")
-	serverOptions.v.diskType = cmdServer.Flag.String("volume.disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
+	serverOptions.v.indexType = cmdServer.Flag.String("volume.index", "memory", "Choose [memory|leveldb|leveldbMedium|leveldbLarge|pebble] mode for memory~performance balance.")
+	serverOptions.v.diskType = cmdServer.Flag.String("volume.disk", "", "[hdd|ssd|object|<tag>] hard drive or solid state drive, the object store of -volume.objectStore.backend, or any tag")
 	serverOptions.v.fixJpgOrientation = cmdServer.Flag.Bool("volume.images.fix.orientation", false, "Adjust jpg orientation when uploading.")
 	serverOptions.v.readMode = cmdServer.Flag.String("volume.readMode", "proxy", "[local|proxy|redirect] how to deal with non-local volume: 'not found|read in remote node|redirect volume location'.")
 	serverOptions.v.compactionMBPerSecond = cmdServer.Flag.Int("volume.compactionMBps", 0, "limit compaction speed in mega bytes per second")
+	serverOptions.v.vacuumConcurrency = cmdServer.Flag.Int("volume.vacuum.concurrency", 0, "the most concurrent compactions on this volume server, queueing the others by their garbage ratio, 0 for no limit")
+	serverOptions.v.vacuumConcurrencyPerDisk = cmdServer.Flag.Int("volume.vacuum.concurrencyPerDisk", 0, "the most concurrent compactions on each disk, queueing the others by their garbage ratio, 0 for no limit")
 	serverOptions.v.fileSizeLimitMB = cmdServer.Flag.Int("volume.fileSizeLimitMB", 256, "limit file size to avoid out of memory")
+	serverOptions.v.weight = cmdServer.Flag.Float64("volume.weight", 1, "the capacity and performance weight of this volume server relative to the others, e.g. 5 for a 20TB server among 4TB servers with the same -max, scaling its share of the new volumes, and of the volumes and ec shards in volume.balance and ec.balance")
+	serverOptions.v.tags = cmdServer.Flag.String("volume.tags", "", "comma separated tags of this volume server for the placement constraints of the master, e.g. \"zone=a,hw=nvme,tenant=x\"")
+	serverOptions.v.sendfileSizeMB = cmdServer.Flag.Int("volume.sendfileMB", 0, "send the uncompressed needle reads of at least this size from the .dat file to the http connection by the kernel, without copying them or verifying their checksums, 0 to copy all reads")
+	serverOptions.v.pageCacheStreamSizeMB = cmdServer.Flag.Int("volume.pageCache.streamMB", 0, "drop the pages of the needle reads and the volume file copies of at least this size from the OS page cache behind them, to keep the hot needles cached during the bulk transfers, 0 to keep all pages")
+	serverOptions.v.pebbleCacheSizeMB = cmdServer.Flag.Int("volume.index.pebbleCacheMB", 64, "the block cache in MB shared by the pebble indexes of all volumes")
 	serverOptions.v.ldbTimeout = cmdServer.Flag.Int64("volume.index.leveldbTimeout", 0, "alive time for leveldb (default to 0). If leveldb of volume is not accessed in ldbTimeout hours, it will be off loaded to reduce opened files and memory consumption.")
 	serverOptions.v.concurrentUploadLimitMB = cmdServer.Flag.Int("volume.concurrentUploadLimitMB", 64, "limit total concurrent upload size")
 	serverOptions.v.concurrentDownloadLimitMB = cmdServer.Flag.Int("volume.concurrentDownloadLimitMB", 64, "limit total concurrent download size")
@@ -137,6 +190,32 @@ func init() {
 	serverOptions.v.inflightUploadDataTimeout = cmdServer.Flag.Duration("volume.inflightUploadDataTimeout", 60*time.Second, "inflight upload data wait timeout of volume servers")
 	serverOptions.v.hasSlowRead = cmdServer.Flag.Bool("volume.hasSlowRead", true, "<experimental> if true, this prevents slow reads from blocking other requests, but large file read P99 latency will increase.")
 	serverOptions.v.readBufferSizeMB = cmdServer.Flag.Int("volume.readBufferSizeMB", 4, "<experimental> larger values can optimize query performance but will increase some memory usage,Use with hasSlowRead normally")
+	serverOptions.v.scrubMBPerSecond = cmdServer.Flag.Int("volume.scrubMBps", 0, "continuously verify the needle checksums at this speed in mega bytes per second, and repair the corrupted needles from replicas or ec shards, 0 to disable")
+	serverOptions.v.scrubInterval = cmdServer.Flag.Duration("volume.scrubInterval", time.Hour, "pause between two rounds of scrubbing all volumes")
+	serverOptions.v.readOnlyIndex = cmdServer.Flag.String("volume.index.readOnly", "", "Choose [sorted|mmap|compact] to look up the needles of the read only volumes, including the ones marked read only at runtime, in the sorted or compact index files instead of memory. Empty only uses the sorted or already converted compact index files for volumes loaded as read only.")
+	serverOptions.v.qosCollections = cmdServer.Flag.String("volume.qos.collections", "", "cap the http requests of collections, e.g. \"logs=200/50,*=1000/0\" for <collection>=<iops>/<MBps>, where * applies to the other collections and 0 means no cap")
+	serverOptions.v.qosConcurrency = cmdServer.Flag.Int("volume.qos.concurrentRequests", 0, "limit the concurrent http requests, shared fairly between the collections under contention, 0 means no limit")
+	serverOptions.v.diskFailureThreshold = cmdServer.Flag.Int("volume.disk.failureThreshold", 10, "the number of I/O errors on a disk within a minute to mark its volumes read only and have the master re-replicate them, 0 to disable")
+	serverOptions.v.discardDelay = cmdServer.Flag.Duration("volume.disk.discardDelay", 0, "discard the free space of a disk, like fstrim, this long after its volumes are vacuumed or deleted, for the thin provisioned and SSD disks. 0 to disable")
+	serverOptions.v.groupCommitDelay = cmdServer.Flag.Duration("volume.fsync.groupCommitDelay", 0, "fsync the writes requested with fsync, waiting at most this long for the concurrent writes of the volume to share one fsync, 0 to skip the fsync")
+	serverOptions.v.replicaStream = cmdServer.Flag.Bool("volume.replicaStream", false, "keep tailing the needles appended to the other replicas of each writable volume, and apply the missing ones")
+	serverOptions.v.diskReadCacheDir = cmdServer.Flag.String("volume.hdd.cacheDir", "", "directory on a faster device to cache the recently read needles of the volumes on the hard drives, default to hdd_cache under the first ssd folder of -dir")
+	serverOptions.v.diskReadCacheSizeMB = cmdServer.Flag.Int("volume.hdd.cacheSizeMB", 0, "cache the recently read needles of the hard drive volumes, except the sequential scans, up to this size, 0 to disable")
+	serverOptions.v.objectStoreBackend = cmdServer.Flag.String("volume.objectStore.backend", "", "the storage backend in master.toml, e.g. s3.default, keeping the .dat files of the volumes on the folders of the \"object\" disk type")
+	serverOptions.v.objectStoreSegmentSizeMB = cmdServer.Flag.Int("volume.objectStore.segmentSizeMB", 64, "the .dat files on the object store are uploaded in segments of this size, buffered on the local disk until full")
+	serverOptions.v.ecReadHedgeDelay = cmdServer.Flag.Duration("volume.ec.readHedgeDelay", 100*time.Millisecond, "when a remote ec shard is not read within this delay, also recover it from the other shards, 0 to wait for the shard")
+	serverOptions.v.tierCacheDir = cmdServer.Flag.String("volume.tier.cacheDir", "", "directory to cache the recently read needles of the volumes tiered to remote storages, default to tier_cache under the first folder of -dir")
+	serverOptions.v.tierCacheSizeMB = cmdServer.Flag.Int("volume.tier.cacheSizeMB", 0, "cache the recently read needles of the remote tiered volumes on local disk up to this size, 0 to disable")
+	serverOptions.v.zoned = cmdServer.Flag.Bool("volume.zoned", false, "use the zoned layout for all folders, which only appends to the volume files, even if the SMR or ZNS disks are not detected")
+	serverOptions.v.compactInPlace = cmdServer.Flag.Bool("volume.compaction.inPlace", false, "reclaim the deleted space by punching holes in the volume files, instead of copying the live needles to new volume files")
+	serverOptions.v.needleWal = cmdServer.Flag.Bool("volume.wal", false, "acknowledge the writes after fdatasync of a write-ahead log on each disk, which is replayed on startup")
+	serverOptions.v.ioUring = cmdServer.Flag.Bool("volume.ioUring", false, "on linux, batch the small needle reads with io_uring into registered buffers, falling back to pread if not available")
+	serverOptions.v.encryptedCollections = cmdServer.Flag.String("volume.encryption.collections", "", "comma separated collections to encrypt the new volumes with the data keys wrapped by the key manager in security.toml, or \"*\" for all collections")
+	serverOptions.v.checksumAlgorithm = cmdServer.Flag.String("volume.checksum", "crc32c", "checksum algorithm of the needle data in the new volumes, crc32c or xxhash64, the same on all volume servers for the replicas to match")
+	serverOptions.v.needleFilterCollections = cmdServer.Flag.String("volume.bloomFilter.collections", "", "comma separated collections whose volumes keep a bloom filter of the needle ids in memory, to answer the lookups of missing needles without the index or the disk, or \"*\" for all collections")
+	serverOptions.v.deduplicatedCollections = cmdServer.Flag.String("volume.dedup.collections", "", "comma separated collections whose new volumes store the identical needle contents once, e.g. for backups or build artifacts, or \"*\" for all collections")
+	serverOptions.v.inPlaceOverwriteCollections = cmdServer.Flag.String("volume.overwriteInPlace.collections", "", "comma separated collections whose volumes overwrite a needle written again with the same size in place, instead of appending it as garbage to vacuum, e.g. for fixed size records, or \"*\" for all collections")
+	serverOptions.v.zstdCollections = cmdServer.Flag.String("volume.compression.zstd.collections", "", "comma separated collections to compress the uploaded needle data with zstd, skipping the already compressed content, or \"*\" for all collections")
 
 	s3Options.port = cmdServer.Flag.Int("s3.port", 8333, "s3 server http listen port")
 	s3Options.portHttps = cmdServer.Flag.Int("s3.port.https", 0, "s3 server https listen port")
@@ -191,7 +270,7 @@ func runServer(cmd *Command, args []string) bool {
 	}
 
 	if *isStartingMasterServer {
-		_, peerList := checkPeers(*serverIp, *masterOptions.port, *masterOptions.portGrpc, *masterOptions.peers)
+		_, peerList, _ := checkPeers(*serverIp, *masterOptions.port, *masterOptions.portGrpc, *masterOptions.peers, *masterOptions.learners)
 		peers := strings.Join(pb.ToAddressStrings(peerList), ",")
 		masterOptions.peers = &peers
 	}
@@ -3,6 +3,8 @@ package command
 import (
 	"fmt"
 	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"os"
+	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/security"
 	"github.com/seaweedfs/seaweedfs/weed/shell"
@@ -10,9 +12,13 @@ import (
 )
 
 var (
-	shellOptions      shell.ShellOptions
-	shellInitialFiler *string
-	shellCluster      *string
+	shellOptions        shell.ShellOptions
+	shellInitialFiler   *string
+	shellCluster        *string
+	shellScript         *string
+	shellFailFast       *bool
+	shellVars           *string
+	shellConnectTimeout *time.Duration
 )
 
 func init() {
@@ -21,20 +27,45 @@ func init() {
 	shellOptions.FilerGroup = cmdShell.Flag.String("filerGroup", "", "filerGroup for the filers")
 	shellInitialFiler = cmdShell.Flag.String("filer", "", "filer host and port, e.g. localhost:8888")
 	shellCluster = cmdShell.Flag.String("cluster", "", "cluster defined in shell.toml")
+	shellOptions.Output = cmdShell.Flag.String("o", shell.OutputText, "the output format of the commands, text or json with one JSON object per command")
+	shellScript = cmdShell.Flag.String("c", "", "run these commands separated by ';' instead of the prompt")
+	shellFailFast = cmdShell.Flag.Bool("e", false, "stop the script at the first failed command")
+	shellVars = cmdShell.Flag.String("vars", "", "the script variables for ${name}, as name=value,name=value")
+	shellConnectTimeout = cmdShell.Flag.Duration("connectTimeout", time.Minute, "with a script, fail if no master connects within this time")
 }
 
 var cmdShell = &Command{
-	UsageLine: "shell",
+	UsageLine: "shell [-c \"cmd1; cmd2\" | script_file] [-e] [-vars name=value,...]",
 	Short:     "run interactive administrative commands",
 	Long: `run interactive administrative commands.
 
 	Generate shell.toml via "weed scaffold -config=shell"
 
+	With "-o json", each command prints one JSON object per line, for the scripts, e.g.
+		echo "volume.list" | weed shell -o json
+
+	With -c or a script file, the commands run without the prompt, e.g. from cron or CI.
+	In the script, the lines starting with # are comments, and ${name} is replaced by
+	the variable from -vars, or else the environment variable.
+		weed shell -e -c "lock; volume.fix.replication -force; unlock"
+		weed shell -e -vars collection=logs maintenance.txt
+
+	The exit code is of the first failure, and with -e the script stops there:
+		1  a command failed
+		2  an unknown command
+		3  a command with an undefined variable, or the script can not be read
+		4  no master connected within -connectTimeout
+
   `,
 }
 
 func runShell(command *Command, args []string) bool {
 
+	if *shellOptions.Output != shell.OutputText && *shellOptions.Output != shell.OutputJson {
+		fmt.Fprintf(os.Stderr, "unknown output format %s, expecting text or json\n", *shellOptions.Output)
+		return false
+	}
+
 	util.LoadConfiguration("security", false)
 	shellOptions.GrpcDialOption = security.LoadClientTLS(util.GetViper(), "grpc.client")
 
@@ -50,15 +81,48 @@ func runShell(command *Command, args []string) bool {
 		} else {
 			*shellOptions.Masters = v.GetString("cluster." + cluster + ".master")
 			*shellInitialFiler = v.GetString("cluster." + cluster + ".filer")
-			fmt.Printf("master: %s filer: %s\n", *shellOptions.Masters, *shellInitialFiler)
+			fmt.Fprintf(os.Stderr, "master: %s filer: %s\n", *shellOptions.Masters, *shellInitialFiler)
 		}
 	}
 
 	shellOptions.FilerAddress = pb.ServerAddress(*shellInitialFiler)
 	shellOptions.Directory = "/"
 
+	if *shellScript != "" || len(args) > 0 {
+		if exitCode := runShellScript(args); exitCode != shell.ExitOk {
+			os.Exit(exitCode)
+		}
+		return true
+	}
+
 	shell.RunShell(shellOptions)
 
 	return true
 
 }
+
+func runShellScript(args []string) int {
+	script := *shellScript
+	if len(args) > 0 {
+		if script != "" || len(args) > 1 {
+			fmt.Fprintf(os.Stderr, "expecting either -c or one script file\n")
+			return shell.ExitBadScript
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read the script: %v\n", err)
+			return shell.ExitBadScript
+		}
+		script = string(data)
+	}
+	vars, err := shell.ParseScriptVars(*shellVars)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "parse -vars: %v\n", err)
+		return shell.ExitBadScript
+	}
+	return shell.RunBatch(shellOptions, script, shell.BatchOptions{
+		FailFast:       *shellFailFast,
+		Vars:           vars,
+		ConnectTimeout: *shellConnectTimeout,
+	})
+}
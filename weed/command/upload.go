@@ -31,6 +31,7 @@ type UploadOptions struct {
 	diskType     *string
 	maxMB        *int
 	usePublicUrl *bool
+	stripeWidth  *int
 }
 
 func init() {
@@ -46,6 +47,7 @@ func init() {
 	upload.ttl = cmdUpload.Flag.String("ttl", "", "time to live, e.g.: 1m, 1h, 1d, 1M, 1y")
 	upload.maxMB = cmdUpload.Flag.Int("maxMB", 4, "split files larger than the limit")
 	upload.usePublicUrl = cmdUpload.Flag.Bool("usePublicUrl", false, "upload to public url from volume server")
+	upload.stripeWidth = cmdUpload.Flag.Int("stripeWidth", 0, "stripe the chunks of files larger than maxMB across this many volumes, to be read in parallel")
 }
 
 var cmdUpload = &Command{
@@ -64,6 +66,9 @@ var cmdUpload = &Command{
   If "maxMB" is set to a positive number, files larger than it would be split into chunks and uploaded separately.
   The list of file ids of those chunks would be stored in an additional chunk, and this additional chunk's file id would be returned.
 
+  If "stripeWidth" is set larger than 1, the chunks are striped round-robin across that many volumes, uploaded and read in parallel,
+  so a file can be larger than one volume.
+
   `,
 }
 
@@ -97,6 +102,7 @@ func runUpload(cmd *Command, args []string) bool {
 					if e != nil {
 						return e
 					}
+					setStripeWidth(parts, *upload.stripeWidth)
 					results, e := operation.SubmitFiles(func() pb.ServerAddress { return pb.ServerAddress(*upload.master) }, grpcDialOption, parts, *upload.replication, *upload.collection, *upload.dataCenter, *upload.ttl, *upload.diskType, *upload.maxMB, *upload.usePublicUrl)
 					bytes, _ := json.Marshal(results)
 					fmt.Println(string(bytes))
@@ -119,6 +125,7 @@ func runUpload(cmd *Command, args []string) bool {
 			fmt.Println(e.Error())
 			return false
 		}
+		setStripeWidth(parts, *upload.stripeWidth)
 		results, err := operation.SubmitFiles(func() pb.ServerAddress { return pb.ServerAddress(*upload.master) }, grpcDialOption, parts, *upload.replication, *upload.collection, *upload.dataCenter, *upload.ttl, *upload.diskType, *upload.maxMB, *upload.usePublicUrl)
 		if err != nil {
 			fmt.Println(err.Error())
@@ -141,3 +148,9 @@ func readMasterConfiguration(grpcDialOption grpc.DialOption, masterAddress pb.Se
 	})
 	return
 }
+
+func setStripeWidth(parts []operation.FilePart, stripeWidth int) {
+	for i := range parts {
+		parts[i].StripeWidth = stripeWidth
+	}
+}
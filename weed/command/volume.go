@@ -65,10 +65,43 @@ type VolumeServerOptions struct {
 	preStopSeconds            *int
 	metricsHttpPort           *int
 	// pulseSeconds          *int
-	inflightUploadDataTimeout *time.Duration
-	hasSlowRead               *bool
-	readBufferSizeMB          *int
-	ldbTimeout                *int64
+	inflightUploadDataTimeout   *time.Duration
+	hasSlowRead                 *bool
+	readBufferSizeMB            *int
+	ldbTimeout                  *int64
+	scrubMBPerSecond            *int
+	scrubInterval               *time.Duration
+	zstdCollections             *string
+	encryptedCollections        *string
+	deduplicatedCollections     *string
+	checksumAlgorithm           *string
+	ioUring                     *bool
+	needleWal                   *bool
+	compactInPlace              *bool
+	zoned                       *bool
+	readOnlyIndex               *string
+	tierCacheDir                *string
+	tierCacheSizeMB             *int
+	qosCollections              *string
+	qosConcurrency              *int
+	replicaStream               *bool
+	groupCommitDelay            *time.Duration
+	diskFailureThreshold        *int
+	discardDelay                *time.Duration
+	needleFilterCollections     *string
+	diskReadCacheDir            *string
+	diskReadCacheSizeMB         *int
+	objectStoreBackend          *string
+	objectStoreSegmentSizeMB    *int
+	ecReadHedgeDelay            *time.Duration
+	pebbleCacheSizeMB           *int
+	inPlaceOverwriteCollections *string
+	vacuumConcurrency           *int
+	vacuumConcurrencyPerDisk    *int
+	pageCacheStreamSizeMB       *int
+	sendfileSizeMB              *int
+	tags                        *string
+	weight                      *float64
 }
 
 func init() {
@@ -85,14 +118,21 @@ func init() {
 	v.idleConnectionTimeout = cmdVolume.Flag.Int("idleTimeout", 30, "connection idle seconds")
 	v.dataCenter = cmdVolume.Flag.String("dataCenter", "", "current volume server's data center name")
 	v.rack = cmdVolume.Flag.String("rack", "", "current volume server's rack name")
-	v.indexType = cmdVolume.Flag.String("index", "memory", "Choose [memory|leveldb|leveldbMedium|leveldbLarge] mode for memory~performance balance.")
-	v.diskType = cmdVolume.Flag.String("disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
+	v.indexType = cmdVolume.Flag.String("index", "memory", "Choose [memory|leveldb|leveldbMedium|leveldbLarge|pebble] mode for memory~performance balance.")
+	v.diskType = cmdVolume.Flag.String("disk", "", "[hdd|ssd|object|<tag>] hard drive or solid state drive, the object store of -objectStore.backend, or any tag")
 	v.fixJpgOrientation = cmdVolume.Flag.Bool("images.fix.orientation", false, "Adjust jpg orientation when uploading.")
 	v.readMode = cmdVolume.Flag.String("readMode", "proxy", "[local|proxy|redirect] how to deal with non-local volume: 'not found|proxy to remote node|redirect volume location'.")
 	v.cpuProfile = cmdVolume.Flag.String("cpuprofile", "", "cpu profile output file")
 	v.memProfile = cmdVolume.Flag.String("memprofile", "", "memory profile output file")
 	v.compactionMBPerSecond = cmdVolume.Flag.Int("compactionMBps", 0, "limit background compaction or copying speed in mega bytes per second")
+	v.vacuumConcurrency = cmdVolume.Flag.Int("vacuum.concurrency", 0, "the most concurrent compactions on this volume server, queueing the others by their garbage ratio, 0 for no limit")
+	v.vacuumConcurrencyPerDisk = cmdVolume.Flag.Int("vacuum.concurrencyPerDisk", 0, "the most concurrent compactions on each disk, queueing the others by their garbage ratio, 0 for no limit")
 	v.fileSizeLimitMB = cmdVolume.Flag.Int("fileSizeLimitMB", 256, "limit file size to avoid out of memory")
+	v.weight = cmdVolume.Flag.Float64("weight", 1, "the capacity and performance weight of this volume server relative to the others, e.g. 5 for a 20TB server among 4TB servers with the same -max, scaling its share of the new volumes, and of the volumes and ec shards in volume.balance and ec.balance")
+	v.tags = cmdVolume.Flag.String("tags", "", "comma separated tags of this volume server for the placement constraints of the master, e.g. \"zone=a,hw=nvme,tenant=x\"")
+	v.sendfileSizeMB = cmdVolume.Flag.Int("sendfileMB", 0, "send the uncompressed needle reads of at least this size from the .dat file to the http connection by the kernel, without copying them or verifying their checksums, 0 to copy all reads")
+	v.pageCacheStreamSizeMB = cmdVolume.Flag.Int("pageCache.streamMB", 0, "drop the pages of the needle reads and the volume file copies of at least this size from the OS page cache behind them, to keep the hot needles cached during the bulk transfers, 0 to keep all pages")
+	v.pebbleCacheSizeMB = cmdVolume.Flag.Int("index.pebbleCacheMB", 64, "the block cache in MB shared by the pebble indexes of all volumes")
 	v.ldbTimeout = cmdVolume.Flag.Int64("index.leveldbTimeout", 0, "alive time for leveldb (default to 0). If leveldb of volume is not accessed in ldbTimeout hours, it will be off loaded to reduce opened files and memory consumption.")
 	v.concurrentUploadLimitMB = cmdVolume.Flag.Int("concurrentUploadLimitMB", 256, "limit total concurrent upload size")
 	v.concurrentDownloadLimitMB = cmdVolume.Flag.Int("concurrentDownloadLimitMB", 256, "limit total concurrent download size")
@@ -102,6 +142,32 @@ func init() {
 	v.inflightUploadDataTimeout = cmdVolume.Flag.Duration("inflightUploadDataTimeout", 60*time.Second, "inflight upload data wait timeout of volume servers")
 	v.hasSlowRead = cmdVolume.Flag.Bool("hasSlowRead", true, "<experimental> if true, this prevents slow reads from blocking other requests, but large file read P99 latency will increase.")
 	v.readBufferSizeMB = cmdVolume.Flag.Int("readBufferSizeMB", 4, "<experimental> larger values can optimize query performance but will increase some memory usage,Use with hasSlowRead normally.")
+	v.scrubMBPerSecond = cmdVolume.Flag.Int("scrubMBps", 0, "continuously verify the needle checksums at this speed in mega bytes per second, and repair the corrupted needles from replicas or ec shards, 0 to disable")
+	v.scrubInterval = cmdVolume.Flag.Duration("scrubInterval", time.Hour, "pause between two rounds of scrubbing all volumes")
+	v.readOnlyIndex = cmdVolume.Flag.String("index.readOnly", "", "Choose [sorted|mmap|compact] to look up the needles of the read only volumes, including the ones marked read only at runtime, in the sorted or compact index files instead of memory. Empty only uses the sorted or already converted compact index files for volumes loaded as read only.")
+	v.qosCollections = cmdVolume.Flag.String("qos.collections", "", "cap the http requests of collections, e.g. \"logs=200/50,*=1000/0\" for <collection>=<iops>/<MBps>, where * applies to the other collections and 0 means no cap")
+	v.qosConcurrency = cmdVolume.Flag.Int("qos.concurrentRequests", 0, "limit the concurrent http requests, shared fairly between the collections under contention, 0 means no limit")
+	v.diskFailureThreshold = cmdVolume.Flag.Int("disk.failureThreshold", 10, "the number of I/O errors on a disk within a minute to mark its volumes read only and have the master re-replicate them, 0 to disable")
+	v.discardDelay = cmdVolume.Flag.Duration("disk.discardDelay", 0, "discard the free space of a disk, like fstrim, this long after its volumes are vacuumed or deleted, for the thin provisioned and SSD disks. 0 to disable")
+	v.groupCommitDelay = cmdVolume.Flag.Duration("fsync.groupCommitDelay", 0, "fsync the writes requested with fsync, waiting at most this long for the concurrent writes of the volume to share one fsync, 0 to skip the fsync")
+	v.replicaStream = cmdVolume.Flag.Bool("replicaStream", false, "keep tailing the needles appended to the other replicas of each writable volume, and apply the missing ones")
+	v.diskReadCacheDir = cmdVolume.Flag.String("hdd.cacheDir", "", "directory on a faster device to cache the recently read needles of the volumes on the hard drives, default to hdd_cache under the first ssd folder of -dir")
+	v.diskReadCacheSizeMB = cmdVolume.Flag.Int("hdd.cacheSizeMB", 0, "cache the recently read needles of the hard drive volumes, except the sequential scans, up to this size, 0 to disable")
+	v.objectStoreBackend = cmdVolume.Flag.String("objectStore.backend", "", "the storage backend in master.toml, e.g. s3.default, keeping the .dat files of the volumes on the folders of the \"object\" disk type")
+	v.objectStoreSegmentSizeMB = cmdVolume.Flag.Int("objectStore.segmentSizeMB", 64, "the .dat files on the object store are uploaded in segments of this size, buffered on the local disk until full")
+	v.ecReadHedgeDelay = cmdVolume.Flag.Duration("ec.readHedgeDelay", 100*time.Millisecond, "when a remote ec shard is not read within this delay, also recover it from the other shards, 0 to wait for the shard")
+	v.tierCacheDir = cmdVolume.Flag.String("tier.cacheDir", "", "directory to cache the recently read needles of the volumes tiered to remote storages, default to tier_cache under the first folder of -dir")
+	v.tierCacheSizeMB = cmdVolume.Flag.Int("tier.cacheSizeMB", 0, "cache the recently read needles of the remote tiered volumes on local disk up to this size, 0 to disable")
+	v.zoned = cmdVolume.Flag.Bool("zoned", false, "use the zoned layout for all folders, which only appends to the volume files, even if the SMR or ZNS disks are not detected")
+	v.compactInPlace = cmdVolume.Flag.Bool("compaction.inPlace", false, "reclaim the deleted space by punching holes in the volume files, instead of copying the live needles to new volume files")
+	v.needleWal = cmdVolume.Flag.Bool("wal", false, "acknowledge the writes after fdatasync of a write-ahead log on each disk, which is replayed on startup")
+	v.ioUring = cmdVolume.Flag.Bool("ioUring", false, "on linux, batch the small needle reads with io_uring into registered buffers, falling back to pread if not available")
+	v.encryptedCollections = cmdVolume.Flag.String("encryption.collections", "", "comma separated collections to encrypt the new volumes with the data keys wrapped by the key manager in security.toml, or \"*\" for all collections")
+	v.checksumAlgorithm = cmdVolume.Flag.String("checksum", "crc32c", "checksum algorithm of the needle data in the new volumes, crc32c or xxhash64, the same on all volume servers for the replicas to match")
+	v.needleFilterCollections = cmdVolume.Flag.String("bloomFilter.collections", "", "comma separated collections whose volumes keep a bloom filter of the needle ids in memory, to answer the lookups of missing needles without the index or the disk, or \"*\" for all collections")
+	v.deduplicatedCollections = cmdVolume.Flag.String("dedup.collections", "", "comma separated collections whose new volumes store the identical needle contents once, e.g. for backups or build artifacts, or \"*\" for all collections")
+	v.inPlaceOverwriteCollections = cmdVolume.Flag.String("overwriteInPlace.collections", "", "comma separated collections whose volumes overwrite a needle written again with the same size in place, instead of appending it as garbage to vacuum, e.g. for fixed size records, or \"*\" for all collections")
+	v.zstdCollections = cmdVolume.Flag.String("compression.zstd.collections", "", "comma separated collections to compress the uploaded needle data with zstd, skipping the already compressed content, or \"*\" for all collections")
 }
 
 var cmdVolume = &Command{
@@ -234,6 +300,13 @@ func (v VolumeServerOptions) startVolumeServer(volumeFolders, maxVolumeCounts, v
 		volumeNeedleMapKind = storage.NeedleMapLevelDbMedium
 	case "leveldbLarge":
 		volumeNeedleMapKind = storage.NeedleMapLevelDbLarge
+	case "pebble":
+		volumeNeedleMapKind = storage.NeedleMapPebble
+	}
+
+	qosCollections, err := weed_server.ParseCollectionQos(*v.qosCollections)
+	if err != nil {
+		glog.Fatalf("invalid -qos.collections: %v", err)
 	}
 
 	volumeServer := weed_server.NewVolumeServer(volumeMux, publicVolumeMux,
@@ -252,6 +325,41 @@ func (v VolumeServerOptions) startVolumeServer(volumeFolders, maxVolumeCounts, v
 		*v.hasSlowRead,
 		*v.readBufferSizeMB,
 		*v.ldbTimeout,
+		storage.ScrubOption{
+			BytesPerSecond: int64(*v.scrubMBPerSecond) * 1024 * 1024,
+			Interval:       *v.scrubInterval,
+		},
+		util.StringSplit(*v.zstdCollections, ","),
+		util.StringSplit(*v.encryptedCollections, ","),
+		util.StringSplit(*v.deduplicatedCollections, ","),
+		*v.checksumAlgorithm,
+		*v.ioUring,
+		*v.needleWal,
+		*v.compactInPlace,
+		*v.zoned,
+		*v.readOnlyIndex,
+		*v.tierCacheDir,
+		*v.tierCacheSizeMB,
+		qosCollections,
+		*v.qosConcurrency,
+		*v.replicaStream,
+		*v.groupCommitDelay,
+		*v.diskFailureThreshold,
+		*v.discardDelay,
+		util.StringSplit(*v.needleFilterCollections, ","),
+		*v.diskReadCacheDir,
+		*v.diskReadCacheSizeMB,
+		*v.objectStoreBackend,
+		*v.objectStoreSegmentSizeMB,
+		*v.ecReadHedgeDelay,
+		*v.pebbleCacheSizeMB,
+		util.StringSplit(*v.inPlaceOverwriteCollections, ","),
+		*v.vacuumConcurrency,
+		*v.vacuumConcurrencyPerDisk,
+		*v.pageCacheStreamSizeMB,
+		*v.sendfileSizeMB,
+		*v.tags,
+		*v.weight,
 	)
 	// starting grpc server
 	grpcS := v.startGrpcService(volumeServer)
@@ -287,6 +395,9 @@ func (v VolumeServerOptions) startVolumeServer(volumeFolders, maxVolumeCounts, v
 
 	select {
 	case <-stopChan:
+	case <-volumeServer.Drained():
+		fmt.Println("volume server has been drained")
+		shutdown(publicHttpDown, clusterHttpServer, grpcS, volumeServer)
 	}
 
 }
@@ -72,6 +72,14 @@ func (f *Filer) LoadConfiguration(config *util.ViperProxy) (isFresh bool) {
 		if err := store.Initialize(config, key+"."); err != nil {
 			glog.Fatalf("Failed to initialize store for %s: %+v", key, err)
 		}
+		if config.GetBool(key + ".migrate") {
+			fsw, ok := f.Store.(*FilerStoreWrapper)
+			if !ok {
+				glog.Fatalf("can not migrate filer store to %s", key)
+			}
+			fsw.MigrateDefaultStore(store, config.GetInt(key+".migrateEntriesPerSecond"), config.GetBool(key+".migrateAutoCutOver"))
+			continue
+		}
 		location := config.GetString(key + ".location")
 		if location == "" {
 			glog.Errorf("path-specific filer store needs %s", key+".location")
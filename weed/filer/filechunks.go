@@ -20,6 +20,15 @@ func TotalSize(chunks []*filer_pb.FileChunk) (size uint64) {
 	return
 }
 
+// StoredChunkSize is the size of the blob stored on the volume server,
+// which for a packed chunk covers the data of all the files sharing it.
+func StoredChunkSize(chunk *filer_pb.FileChunk) uint64 {
+	if chunk.PackSize > 0 {
+		return chunk.PackSize
+	}
+	return chunk.Size
+}
+
 func FileSize(entry *filer_pb.Entry) (size uint64) {
 	if entry == nil || entry.Attributes == nil {
 		return 0
@@ -237,8 +246,8 @@ func MergeIntoVisibles(visibles *IntervalList[*VisibleInterval], start int64, st
 		stop:          stop,
 		fileId:        chunk.GetFileIdString(),
 		modifiedTsNs:  chunk.ModifiedTsNs,
-		offsetInChunk: start - chunk.Offset, // the starting position in the chunk
-		chunkSize:     chunk.Size,           // size of the chunk
+		offsetInChunk: start - chunk.Offset + chunk.PackOffset, // the starting position in the chunk
+		chunkSize:     StoredChunkSize(chunk),                  // size of the chunk
 		cipherKey:     chunk.CipherKey,
 		isGzipped:     chunk.IsCompressed,
 	}
@@ -250,10 +259,10 @@ func MergeIntoChunkViews(chunkViews *IntervalList[*ChunkView], start int64, stop
 
 	chunkView := &ChunkView{
 		FileId:        chunk.GetFileIdString(),
-		OffsetInChunk: start - chunk.Offset,
+		OffsetInChunk: start - chunk.Offset + chunk.PackOffset,
 		ViewSize:      uint64(stop - start),
 		ViewOffset:    start,
-		ChunkSize:     chunk.Size,
+		ChunkSize:     StoredChunkSize(chunk),
 		CipherKey:     chunk.CipherKey,
 		IsGzipped:     chunk.IsCompressed,
 		ModifiedTsNs:  chunk.ModifiedTsNs,
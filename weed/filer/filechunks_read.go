@@ -102,8 +102,8 @@ func addToVisibles(visibles *IntervalList[*VisibleInterval], prevX int64, startP
 			stop:          point.x,
 			fileId:        chunk.GetFileIdString(),
 			modifiedTsNs:  chunk.ModifiedTsNs,
-			offsetInChunk: prevX - chunk.Offset,
-			chunkSize:     chunk.Size,
+			offsetInChunk: prevX - chunk.Offset + chunk.PackOffset,
+			chunkSize:     StoredChunkSize(chunk),
 			cipherKey:     chunk.CipherKey,
 			isGzipped:     chunk.IsCompressed,
 		}
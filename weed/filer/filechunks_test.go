@@ -553,3 +553,20 @@ func TestViewFromVisibleIntervals3(t *testing.T) {
 	}
 
 }
+
+func TestViewFromPackedChunks(t *testing.T) {
+	chunks := []*filer_pb.FileChunk{
+		{Offset: 0, Size: 100, FileId: "pack", ModifiedTsNs: 1, PackOffset: 300, PackSize: 1000},
+	}
+
+	views := ViewFromChunks(nil, chunks, 10, 50)
+	if views.Len() != 1 {
+		t.Fatalf("expected 1 view, got %d", views.Len())
+	}
+	view := views.Front().Value
+	assert.Equal(t, int64(310), view.OffsetInChunk, "offset in the shared chunk")
+	assert.Equal(t, uint64(50), view.ViewSize, "view size")
+	assert.Equal(t, uint64(1000), view.ChunkSize, "shared chunk size")
+	assert.False(t, view.IsFullChunk(), "a packed file is never the full chunk")
+	assert.Equal(t, uint64(100), TotalSize(chunks), "file size")
+}
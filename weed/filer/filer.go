@@ -2,11 +2,13 @@ package filer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/seaweedfs/seaweedfs/weed/cluster/lock_manager"
 	"os"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/cluster"
@@ -33,6 +35,9 @@ var (
 	OS_GID = uint32(os.Getgid())
 )
 
+// ErrUnexpectedFileSize is returned when appending to a file not of the expected size.
+var ErrUnexpectedFileSize = errors.New("unexpected file size")
+
 type Filer struct {
 	UniqueFilerId       int32
 	UniqueFilerEpoch    int32
@@ -50,6 +55,13 @@ type Filer struct {
 	FilerConf           *FilerConf
 	RemoteStorage       *FilerRemoteStorage
 	Dlm                 *lock_manager.DistributedLockManager
+	OperationHooks      []OperationHook
+	DirChecksums        *DirChecksums
+	Usage               *UsageTracker
+	XattrIndex          *XattrIndex
+	EnvelopeEncryption  *EnvelopeEncryption
+	ChunkAuditor        *ChunkAuditor
+	consumerOffsetsLock sync.Mutex
 }
 
 func NewFiler(masters pb.ServerDiscovery, grpcDialOption grpc.DialOption, filerHost pb.ServerAddress, filerGroup string, collection string, replication string, dataCenter string, notifyFn func()) *Filer {
@@ -65,6 +77,7 @@ func NewFiler(masters pb.ServerDiscovery, grpcDialOption grpc.DialOption, filerH
 	if f.UniqueFilerId < 0 {
 		f.UniqueFilerId = -f.UniqueFilerId
 	}
+	f.ChunkAuditor = NewChunkAuditor(f)
 
 	f.LocalMetaLogBuffer = log_buffer.NewLogBuffer("local", LogFlushInterval, f.logFlushFunc, notifyFn)
 	f.metaLogCollection = collection
@@ -210,8 +223,13 @@ func (f *Filer) CreateEntry(ctx context.Context, entry *Entry, o_excl bool, isFr
 		}
 	*/
 
+	hookOperation := HookCreate
 	if oldEntry == nil {
 
+		if err := f.applyBeforeOperation(ctx, hookOperation, nil, entry); err != nil {
+			return err
+		}
+
 		if !skipCreateParentDir {
 			dirParts := strings.Split(string(entry.FullPath), "/")
 			if err := f.ensureParentDirectoryEntry(ctx, entry, dirParts, len(dirParts)-1, isFromOtherCluster); err != nil {
@@ -229,6 +247,10 @@ func (f *Filer) CreateEntry(ctx context.Context, entry *Entry, o_excl bool, isFr
 			glog.V(3).Infof("EEXIST: entry %s already exists", entry.FullPath)
 			return fmt.Errorf("EEXIST: entry %s already exists", entry.FullPath)
 		}
+		hookOperation = HookUpdate
+		if err := f.applyBeforeOperation(ctx, hookOperation, oldEntry, entry); err != nil {
+			return err
+		}
 		glog.V(4).Infof("UpdateEntry %s: old entry: %v", entry.FullPath, oldEntry.Name())
 		if err := f.UpdateEntry(ctx, oldEntry, entry); err != nil {
 			glog.Errorf("update entry %s: %v", entry.FullPath, err)
@@ -238,7 +260,15 @@ func (f *Filer) CreateEntry(ctx context.Context, entry *Entry, o_excl bool, isFr
 
 	f.NotifyUpdateEvent(ctx, oldEntry, entry, true, isFromOtherCluster, signatures)
 
-	f.deleteChunksIfNotNew(oldEntry, entry)
+	f.AfterOperation(ctx, hookOperation, oldEntry, entry)
+
+	AfterCommit(ctx, func() {
+		if isWithReferencedChunks(ctx) {
+			f.deleteChunksIfNotNew(oldEntry, nil)
+		} else {
+			f.deleteChunksIfNotNew(oldEntry, entry)
+		}
+	})
 
 	glog.V(4).Infof("CreateEntry %s: created", entry.FullPath)
 
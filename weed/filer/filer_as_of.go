@@ -0,0 +1,246 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/util/log_buffer"
+)
+
+// The filer stores only keep the latest version of each entry. The version at a past time
+// is found in the metadata log instead: the first change to a path after that time carries
+// the entry as it was before the change. A path without any later change has not changed
+// since then. This only works as far back as the metadata log is kept, and the chunks of
+// an old version may have been deleted already, so the old content may not be readable.
+// Since the metadata log is replayed from that time on, how far back is limited by the filer.
+
+// AsOfHeader asks for a read as of a past time, in RFC3339 format or as unix seconds.
+const AsOfHeader = "X-Seaweed-As-Of"
+
+// ParseAsOf parses the time, which must be within maxAge.
+func ParseAsOf(asOf string, maxAge time.Duration) (t time.Time, err error) {
+	if seconds, parseErr := strconv.ParseInt(asOf, 10, 64); parseErr == nil {
+		t = time.Unix(seconds, 0)
+	} else if t, err = time.Parse(time.RFC3339Nano, asOf); err != nil {
+		return t, fmt.Errorf("invalid %s %q, expecting RFC3339 or unix seconds", AsOfHeader, asOf)
+	}
+	if t.After(time.Now()) {
+		return t, fmt.Errorf("%s %q is in the future", AsOfHeader, asOf)
+	}
+	if t.Before(time.Now().Add(-maxAge)) {
+		return t, fmt.Errorf("%s %q is more than %v ago", AsOfHeader, asOf, maxAge)
+	}
+	return t, nil
+}
+
+// asOfState is the state of a path as of a past time, once a later change of it is found.
+type asOfState struct {
+	entry *Entry // nil if the path did not exist
+}
+
+// FindEntryAsOf returns the entry of the path as it was at the time.
+func (f *Filer) FindEntryAsOf(ctx context.Context, p util.FullPath, asOf time.Time) (*Entry, error) {
+
+	dir, name := p.DirAndName()
+	var state *asOfState
+	err := f.readMetaLogSince(asOf, dir, func(event *filer_pb.SubscribeMetadataResponse) bool {
+		if state = stateBeforeEvent(event, dir, name); state != nil {
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil {
+		return f.FindEntry(ctx, p)
+	}
+	if state.entry == nil {
+		return nil, filer_pb.ErrNotFound
+	}
+	return state.entry, nil
+}
+
+// ListDirectoryEntriesAsOf lists the directory as it was at the time, by name, after startFileName.
+func (f *Filer) ListDirectoryEntriesAsOf(ctx context.Context, p util.FullPath, asOf time.Time, startFileName string, inclusive bool, limit int64, namePattern, namePatternExclude string) (entries []*Entry, hasMore bool, err error) {
+
+	if strings.HasSuffix(string(p), "/") && len(p) > 1 {
+		p = p[0 : len(p)-1]
+	}
+	dir := string(p)
+
+	states := make(map[string]*asOfState)
+	err = f.readMetaLogSince(asOf, dir, func(event *filer_pb.SubscribeMetadataResponse) bool {
+		for _, name := range changedNamesOf(event, dir) {
+			if _, found := states[name]; found {
+				continue
+			}
+			if state := stateBeforeEvent(event, dir, name); state != nil {
+				states[name] = state
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// the unchanged entries are listed page by page, until enough with the changed ones
+	byName := make(map[string]*Entry)
+	var unchanged int64
+	lastFileName, includeLastFile := startFileName, inclusive
+	for unchanged <= limit {
+		var count int
+		lastFileName, err = f.StreamListDirectoryEntries(ctx, p, lastFileName, includeLastFile, PaginationSize, "", "", "", func(entry *Entry) bool {
+			count++
+			if _, found := states[entry.Name()]; !found && matchesNamePatterns(entry.Name(), namePattern, namePatternExclude) {
+				byName[entry.Name()] = entry
+				unchanged++
+			}
+			return true
+		})
+		if err == filer_pb.ErrNotFound {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if count < PaginationSize {
+			break
+		}
+		includeLastFile = false
+	}
+	for name, state := range states {
+		if state.entry != nil {
+			byName[name] = state.entry
+		}
+	}
+
+	var names []string
+	for name := range byName {
+		if name < startFileName || name == startFileName && !inclusive {
+			continue
+		}
+		if !matchesNamePatterns(name, namePattern, namePatternExclude) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if hasMore = int64(len(names)) > limit; hasMore {
+		names = names[:limit]
+	}
+	for _, name := range names {
+		entries = append(entries, byName[name])
+	}
+	return entries, hasMore, nil
+}
+
+// stateBeforeEvent returns the state of dir/name before the event, or nil if the event does not touch it.
+func stateBeforeEvent(event *filer_pb.SubscribeMetadataResponse, dir, name string) *asOfState {
+	notification := event.EventNotification
+	if notification.OldEntry != nil && event.Directory == dir && notification.OldEntry.Name == name {
+		return &asOfState{entry: FromPbEntry(dir, notification.OldEntry)}
+	}
+	if notification.NewEntry != nil && newParentPathOf(event) == dir && notification.NewEntry.Name == name {
+		return &asOfState{}
+	}
+	return nil
+}
+
+// changedNamesOf returns the names under dir touched by the event.
+func changedNamesOf(event *filer_pb.SubscribeMetadataResponse, dir string) (names []string) {
+	notification := event.EventNotification
+	if notification.OldEntry != nil && event.Directory == dir {
+		names = append(names, notification.OldEntry.Name)
+	}
+	if notification.NewEntry != nil && newParentPathOf(event) == dir {
+		names = append(names, notification.NewEntry.Name)
+	}
+	return
+}
+
+func newParentPathOf(event *filer_pb.SubscribeMetadataResponse) string {
+	if event.EventNotification.NewParentPath != "" {
+		return event.EventNotification.NewParentPath
+	}
+	return event.Directory
+}
+
+func matchesNamePatterns(name, namePattern, namePatternExclude string) bool {
+	if namePatternExclude != "" {
+		if matched, err := filepath.Match(namePatternExclude, name); err == nil && matched {
+			return false
+		}
+	}
+	if namePattern != "" {
+		if matched, err := filepath.Match(namePattern, name); err == nil && !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// readMetaLogSince visits the metadata changes in dir from the time until now, first from the
+// persisted log, then from the log still in memory, until fn returns false.
+// The log entries are partitioned by the directory of each change, and a rename is logged per
+// entry, as a create in the new directory and a delete in the old one, so the other directories
+// are skipped by the partition key hash without unmarshalling them.
+func (f *Filer) readMetaLogSince(since time.Time, dir string, fn func(event *filer_pb.SubscribeMetadataResponse) bool) error {
+
+	logBuffer := f.LocalMetaLogBuffer
+	if f.MetaAggregator != nil {
+		logBuffer = f.MetaAggregator.MetaLogBuffer
+	}
+
+	dirHash := util.HashToInt32([]byte(dir))
+	isDone := false
+	eachLogEntryFn := func(logEntry *filer_pb.LogEntry) error {
+		if isDone || logEntry.PartitionKeyHash != dirHash || logEntry.TsNs <= since.UnixNano() {
+			return nil
+		}
+		event := &filer_pb.SubscribeMetadataResponse{}
+		if err := proto.Unmarshal(logEntry.Data, event); err != nil {
+			return fmt.Errorf("unexpected unmarshal filer_pb.SubscribeMetadataResponse: %v", err)
+		}
+		if event.EventNotification == nil || event.TsNs <= since.UnixNano() {
+			return nil
+		}
+		isDone = !fn(event)
+		return nil
+	}
+
+	untilNs := time.Now().UnixNano()
+	lastReadTime := since
+	for retry := 0; retry < 3; retry++ {
+		processedTsNs, _, err := f.ReadPersistedLogBuffer(lastReadTime, untilNs, eachLogEntryFn)
+		if err != nil {
+			return fmt.Errorf("reading from persisted logs: %v", err)
+		}
+		if isDone {
+			return nil
+		}
+		if processedTsNs != 0 {
+			lastReadTime = time.Unix(0, processedTsNs)
+		}
+		lastReadTime, _, err = logBuffer.LoopProcessLogData("asOf", lastReadTime, untilNs, func() bool {
+			return false
+		}, eachLogEntryFn)
+		if err == log_buffer.ResumeFromDiskError {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("reading from in memory logs since %v: %v", lastReadTime, log_buffer.ResumeFromDiskError)
+}
@@ -0,0 +1,105 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/util/log_buffer"
+)
+
+func TestStateBeforeEvent(t *testing.T) {
+	created := &filer_pb.SubscribeMetadataResponse{
+		Directory: "/dir",
+		EventNotification: &filer_pb.EventNotification{
+			NewEntry: &filer_pb.Entry{Name: "a.txt"},
+		},
+	}
+	if state := stateBeforeEvent(created, "/dir", "a.txt"); state == nil || state.entry != nil {
+		t.Errorf("a created file did not exist before: %+v", state)
+	}
+	if state := stateBeforeEvent(created, "/dir", "b.txt"); state != nil {
+		t.Errorf("unrelated file: %+v", state)
+	}
+
+	renamed := &filer_pb.SubscribeMetadataResponse{
+		Directory: "/dir",
+		EventNotification: &filer_pb.EventNotification{
+			OldEntry:      &filer_pb.Entry{Name: "a.txt", Attributes: &filer_pb.FuseAttributes{FileSize: 3}},
+			NewEntry:      &filer_pb.Entry{Name: "b.txt"},
+			NewParentPath: "/other",
+		},
+	}
+	if state := stateBeforeEvent(renamed, "/dir", "a.txt"); state == nil || state.entry == nil || state.entry.FileSize != 3 {
+		t.Errorf("a renamed file existed at the old path: %+v", state)
+	}
+	if state := stateBeforeEvent(renamed, "/other", "b.txt"); state == nil || state.entry != nil {
+		t.Errorf("a renamed file did not exist at the new path: %+v", state)
+	}
+	if names := changedNamesOf(renamed, "/other"); len(names) != 1 || names[0] != "b.txt" {
+		t.Errorf("changed names under /other: %v", names)
+	}
+}
+
+func TestParseAsOf(t *testing.T) {
+	maxAge := time.Since(time.Unix(1600000000, 0))
+	if asOf, err := ParseAsOf("1700000000", maxAge); err != nil || asOf.Unix() != 1700000000 {
+		t.Errorf("unix seconds: %v %v", asOf, err)
+	}
+	if asOf, err := ParseAsOf("2023-11-14T22:13:20Z", maxAge); err != nil || asOf.Unix() != 1700000000 {
+		t.Errorf("rfc3339: %v %v", asOf, err)
+	}
+	if _, err := ParseAsOf("yesterday", maxAge); err == nil {
+		t.Errorf("expecting an error")
+	}
+	if _, err := ParseAsOf("1700000000", time.Hour); err == nil {
+		t.Errorf("expecting an error for a time older than the max age")
+	}
+}
+
+func TestListDirectoryEntriesAsOf(t *testing.T) {
+	f := newTestFiler()
+	f.LocalMetaLogBuffer = log_buffer.NewLogBuffer("local", time.Hour, func(startTime, stopTime time.Time, buf []byte) {}, nil)
+	ctx := context.Background()
+
+	// more entries than a listing page, so the unchanged entries are listed page by page
+	for i := 0; i < PaginationSize+10; i++ {
+		f.Store.InsertEntry(ctx, &Entry{FullPath: util.NewFullPath("/dir", fmt.Sprintf("f%05d", i)), Attr: Attr{Mode: 0644}})
+	}
+	asOf := time.Now()
+	time.Sleep(time.Millisecond)
+
+	deleted := &Entry{FullPath: "/dir/f00001a", Attr: Attr{Mode: 0644, FileSize: 7}}
+	f.logMetaEvent(ctx, string(deleted.FullPath), &filer_pb.EventNotification{OldEntry: deleted.ToProtoEntry()})
+	created := &Entry{FullPath: "/dir/f00002", Attr: Attr{Mode: 0644}}
+	f.logMetaEvent(ctx, string(created.FullPath), &filer_pb.EventNotification{NewEntry: created.ToProtoEntry(), NewParentPath: "/dir"})
+	f.Store.InsertEntry(ctx, &Entry{FullPath: "/dir/f00002a", Attr: Attr{Mode: 0644}})
+	f.logMetaEvent(ctx, "/dir/f00002a", &filer_pb.EventNotification{NewEntry: &filer_pb.Entry{Name: "f00002a"}, NewParentPath: "/dir"})
+	f.logMetaEvent(ctx, "/other/f00001b", &filer_pb.EventNotification{OldEntry: &filer_pb.Entry{Name: "f00001b"}})
+
+	for _, tc := range []struct {
+		startFileName string
+		limit         int64
+		expected      []string
+		hasMore       bool
+	}{
+		{"", 4, []string{"f00000", "f00001", "f00001a", "f00003"}, true},
+		{"f00001", 2, []string{"f00001a", "f00003"}, true},
+		{fmt.Sprintf("f%05d", PaginationSize+7), 5, []string{fmt.Sprintf("f%05d", PaginationSize+8), fmt.Sprintf("f%05d", PaginationSize+9)}, false},
+	} {
+		entries, hasMore, err := f.ListDirectoryEntriesAsOf(ctx, "/dir", asOf, tc.startFileName, false, tc.limit, "", "")
+		if err != nil {
+			t.Fatalf("list after %q: %v", tc.startFileName, err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if fmt.Sprint(names) != fmt.Sprint(tc.expected) || hasMore != tc.hasMore {
+			t.Errorf("list after %q: %v, has more %v, expected %v, has more %v", tc.startFileName, names, hasMore, tc.expected, tc.hasMore)
+		}
+	}
+}
@@ -0,0 +1,587 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/idx"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The chunk audit cross-references the chunks of the entries with the needles on the volume servers,
+// in both directions:
+//   - orphan chunks are stored on the volume servers, but not referenced by any entry
+//   - dangling references are chunks of the entries, but missing on the volume servers
+//
+// Only the needles and chunks written before the cutoff are checked, since the newer ones may be in
+// the middle of an upload. The entries changed while listing, or after the audit until the purge, are
+// recorded, so a renamed or newly created entry does not turn its chunks into orphans.
+
+const (
+	// ChunkAuditPurgeWindow is how long the orphans of a finished audit can be purged
+	ChunkAuditPurgeWindow = time.Hour
+
+	chunkAuditDanglingLimit = 10000
+)
+
+type ChunkAuditOptions struct {
+	Collection    string
+	VolumeIds     map[uint32]bool
+	CutoffTimeAgo time.Duration
+}
+
+type OrphanChunk struct {
+	FileId string
+	Size   uint64
+	// the number of replicas storing the orphan
+	Replicas int
+}
+
+type DanglingChunkReference struct {
+	FileId string
+	Path   util.FullPath
+	Reason string
+}
+
+type VolumeChunkAudit struct {
+	VolumeId    uint32
+	Collection  string
+	IsEcVolume  bool
+	IsReadOnly  bool
+	Servers     []pb.ServerAddress
+	InUseCount  uint64
+	OrphanSize  uint64
+	Orphans     []*OrphanChunk
+	PurgedCount uint64
+}
+
+type ChunkAuditReport struct {
+	Id               string
+	Options          ChunkAuditOptions
+	StartedAt        time.Time
+	FinishedAt       time.Time
+	Error            string
+	ScannedEntries   uint64
+	ReferencedChunks uint64
+	Volumes          []*VolumeChunkAudit
+	DanglingCount    uint64
+	Dangling         []*DanglingChunkReference
+	PurgedAt         time.Time
+	PurgeErrors      []string
+}
+
+func (r *ChunkAuditReport) IsRunning() bool {
+	return r.FinishedAt.IsZero()
+}
+
+type changedChunks struct {
+	path   util.FullPath
+	chunks []*filer_pb.FileChunk
+}
+
+type ChunkAuditor struct {
+	filer *Filer
+
+	lock    sync.Mutex
+	report  *ChunkAuditReport
+	changes []*changedChunks
+	// recording the changed entries, from the start of the audit until the purge
+	recording bool
+
+	scannedEntries   uint64
+	referencedChunks uint64
+}
+
+func NewChunkAuditor(f *Filer) *ChunkAuditor {
+	return &ChunkAuditor{filer: f}
+}
+
+// Report returns the running or the last audit, or nil if none.
+func (ca *ChunkAuditor) Report() *ChunkAuditReport {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.report == nil {
+		return nil
+	}
+	report := *ca.report
+	report.Volumes = make([]*VolumeChunkAudit, len(ca.report.Volumes))
+	for i, volumeAudit := range ca.report.Volumes {
+		v := *volumeAudit
+		report.Volumes[i] = &v
+	}
+	if report.IsRunning() {
+		report.ScannedEntries = atomic.LoadUint64(&ca.scannedEntries)
+		report.ReferencedChunks = atomic.LoadUint64(&ca.referencedChunks)
+	}
+	return &report
+}
+
+// Start runs an audit in the background, unless one is running.
+func (ca *ChunkAuditor) Start(options ChunkAuditOptions) (*ChunkAuditReport, error) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.report != nil && ca.report.IsRunning() {
+		return nil, fmt.Errorf("chunk audit %s is running", ca.report.Id)
+	}
+	now := time.Now()
+	ca.report = &ChunkAuditReport{
+		Id:        fmt.Sprintf("%d", now.UnixNano()),
+		Options:   options,
+		StartedAt: now,
+	}
+	ca.changes, ca.recording = nil, true
+	atomic.StoreUint64(&ca.scannedEntries, 0)
+	atomic.StoreUint64(&ca.referencedChunks, 0)
+
+	report := ca.report
+	go ca.run(report)
+
+	started := *report
+	return &started, nil
+}
+
+func (ca *ChunkAuditor) onEvent(newEntry *Entry) {
+	if newEntry != nil && len(newEntry.GetChunks()) > 0 {
+		ca.recordChange(newEntry.FullPath, newEntry.GetChunks())
+	}
+}
+
+func (ca *ChunkAuditor) onPeerEvent(event *filer_pb.SubscribeMetadataResponse) {
+	newEntry := event.EventNotification.GetNewEntry()
+	if newEntry != nil && len(newEntry.GetChunks()) > 0 {
+		ca.recordChange(util.NewFullPath(event.EventNotification.NewParentPath, newEntry.Name), newEntry.GetChunks())
+	}
+}
+
+func (ca *ChunkAuditor) recordChange(path util.FullPath, chunks []*filer_pb.FileChunk) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if !ca.recording {
+		return
+	}
+	if report := ca.report; !report.IsRunning() && time.Since(report.FinishedAt) > ChunkAuditPurgeWindow {
+		// too late to purge, no need to record more
+		ca.changes, ca.recording = nil, false
+		return
+	}
+	ca.changes = append(ca.changes, &changedChunks{path: path, chunks: chunks})
+}
+
+// auditVolume is one volume, with the needle ids from the index file of each replica
+type auditVolume struct {
+	collection string
+	isEcVolume bool
+	isReadOnly bool
+	servers    []pb.ServerAddress
+	idxFiles   []string
+}
+
+type chunkReferences map[uint32]map[types.NeedleId]util.FullPath
+
+func (ca *ChunkAuditor) run(report *ChunkAuditReport) {
+	result := &ChunkAuditReport{Options: report.Options, StartedAt: report.StartedAt}
+	err := ca.audit(result)
+
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	report.Volumes, report.Dangling, report.DanglingCount = result.Volumes, result.Dangling, result.DanglingCount
+	report.ScannedEntries = atomic.LoadUint64(&ca.scannedEntries)
+	report.ReferencedChunks = atomic.LoadUint64(&ca.referencedChunks)
+	report.FinishedAt = time.Now()
+	if err != nil {
+		glog.Errorf("chunk audit %s: %v", report.Id, err)
+		report.Error = err.Error()
+		ca.changes, ca.recording = nil, false
+		return
+	}
+	glog.V(0).Infof("chunk audit %s: %d entries, %d chunks, %d volumes, %d dangling references",
+		report.Id, report.ScannedEntries, report.ReferencedChunks, len(report.Volumes), report.DanglingCount)
+}
+
+func (ca *ChunkAuditor) audit(report *ChunkAuditReport) error {
+	options := report.Options
+	cutoffTsNs := report.StartedAt.Add(-options.CutoffTimeAgo).UnixNano()
+
+	tempDir, err := os.MkdirTemp("", "chunk_audit")
+	if err != nil {
+		return fmt.Errorf("create temp folder: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	volumes, err := ca.collectVolumes(options)
+	if err != nil {
+		return fmt.Errorf("collect volumes: %v", err)
+	}
+	for vid, v := range volumes {
+		for i, server := range v.servers {
+			idxFile := filepath.Join(tempDir, fmt.Sprintf("%d_%d.idx", vid, i))
+			if err = ca.copyVolumeIndex(server, vid, v, cutoffTsNs, idxFile); err != nil {
+				return fmt.Errorf("collect needles of volume %d on %s: %v", vid, server, err)
+			}
+			v.idxFiles = append(v.idxFiles, idxFile)
+		}
+	}
+
+	references := make(chunkReferences)
+	danglingCheckable := make(map[uint32]map[types.NeedleId]bool)
+	addChunks := func(path util.FullPath, chunks []*filer_pb.FileChunk, checkDangling bool) error {
+		dataChunks, manifestChunks, resolveErr := ResolveChunkManifest(ca.filer.MasterClient.GetLookupFileIdFunction(), chunks, 0, math.MaxInt64)
+		if resolveErr != nil {
+			return fmt.Errorf("resolve chunk manifest of %s: %v", path, resolveErr)
+		}
+		for _, chunk := range append(dataChunks, manifestChunks...) {
+			fid, parseErr := filer_pb.ToFileIdObject(chunk.GetFileIdString())
+			if parseErr != nil {
+				continue
+			}
+			if len(options.VolumeIds) > 0 && !options.VolumeIds[fid.VolumeId] {
+				continue
+			}
+			atomic.AddUint64(&ca.referencedChunks, 1)
+			volumeReferences, found := references[fid.VolumeId]
+			if !found {
+				volumeReferences = make(map[types.NeedleId]util.FullPath)
+				references[fid.VolumeId] = volumeReferences
+				danglingCheckable[fid.VolumeId] = make(map[types.NeedleId]bool)
+			}
+			needleId := types.NeedleId(fid.FileKey)
+			volumeReferences[needleId] = path
+			if checkDangling && chunk.ModifiedTsNs <= cutoffTsNs {
+				danglingCheckable[fid.VolumeId][needleId] = true
+			}
+		}
+		return nil
+	}
+
+	if err = ca.filer.walkEntries(context.Background(), ca.auditPath(options), func(entry *Entry) error {
+		if entry.IsDirectory() {
+			return nil
+		}
+		atomic.AddUint64(&ca.scannedEntries, 1)
+		return addChunks(entry.FullPath, entry.GetChunks(), true)
+	}); err != nil {
+		return err
+	}
+	for _, change := range ca.takeChanges() {
+		if err = addChunks(change.path, change.chunks, false); err != nil {
+			return err
+		}
+	}
+
+	for vid, v := range volumes {
+		volumeAudit, dangling, err := compareVolume(vid, v, references[vid], danglingCheckable[vid])
+		if err != nil {
+			return fmt.Errorf("compare volume %d: %v", vid, err)
+		}
+		report.Volumes = append(report.Volumes, volumeAudit)
+		report.addDangling(dangling...)
+	}
+	sort.Slice(report.Volumes, func(i, j int) bool {
+		return report.Volumes[i].VolumeId < report.Volumes[j].VolumeId
+	})
+
+	// without any filter, the chunks on the unknown volumes are dangling, too
+	if options.Collection == "" && len(options.VolumeIds) == 0 {
+		for vid, volumeReferences := range references {
+			if _, found := volumes[vid]; found {
+				continue
+			}
+			for needleId, path := range volumeReferences {
+				if danglingCheckable[vid][needleId] {
+					report.addDangling(&DanglingChunkReference{FileId: needleId.FileId(vid), Path: path, Reason: "volume not found"})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *ChunkAuditReport) addDangling(dangling ...*DanglingChunkReference) {
+	for _, d := range dangling {
+		r.DanglingCount++
+		if len(r.Dangling) < chunkAuditDanglingLimit {
+			r.Dangling = append(r.Dangling, d)
+		}
+	}
+}
+
+func (ca *ChunkAuditor) takeChanges() []*changedChunks {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	changes := ca.changes
+	ca.changes = nil
+	return changes
+}
+
+func (ca *ChunkAuditor) auditPath(options ChunkAuditOptions) util.FullPath {
+	if options.Collection != "" && ca.filer.DirBucketsPath != "" {
+		return util.NewFullPath(ca.filer.DirBucketsPath, options.Collection)
+	}
+	return "/"
+}
+
+func (ca *ChunkAuditor) collectVolumes(options ChunkAuditOptions) (map[uint32]*auditVolume, error) {
+	var resp *master_pb.VolumeListResponse
+	if err := ca.filer.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) (err error) {
+		resp, err = client.VolumeList(context.Background(), &master_pb.VolumeListRequest{})
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	volumes := make(map[uint32]*auditVolume)
+	add := func(vid uint32, collection string, isEcVolume, isReadOnly bool, server pb.ServerAddress) {
+		if len(options.VolumeIds) > 0 && !options.VolumeIds[vid] {
+			return
+		}
+		if options.Collection != "" && collection != options.Collection {
+			return
+		}
+		v, found := volumes[vid]
+		if !found {
+			v = &auditVolume{collection: collection, isEcVolume: isEcVolume}
+			volumes[vid] = v
+		}
+		v.isReadOnly = v.isReadOnly || isReadOnly
+		v.servers = append(v.servers, server)
+	}
+	for _, dc := range resp.TopologyInfo.GetDataCenterInfos() {
+		for _, rack := range dc.RackInfos {
+			for _, dn := range rack.DataNodeInfos {
+				server := pb.NewServerAddressFromDataNode(dn)
+				for _, diskInfo := range dn.DiskInfos {
+					for _, vi := range diskInfo.VolumeInfos {
+						add(vi.Id, vi.Collection, false, vi.ReadOnly, server)
+					}
+					for _, ecShardInfo := range diskInfo.EcShardInfos {
+						add(ecShardInfo.Id, ecShardInfo.Collection, true, true, server)
+					}
+				}
+			}
+		}
+	}
+	return volumes, nil
+}
+
+// copyVolumeIndex saves the index of the volume replica, without the needles appended after the cutoff
+func (ca *ChunkAuditor) copyVolumeIndex(server pb.ServerAddress, vid uint32, v *auditVolume, cutoffTsNs int64, idxFile string) error {
+	return operation.WithVolumeServerClient(true, server, ca.filer.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		ext := ".idx"
+		if v.isEcVolume {
+			ext = ".ecx"
+		}
+		copyFileClient, err := client.CopyFile(context.Background(), &volume_server_pb.CopyFileRequest{
+			VolumeId:           vid,
+			Ext:                ext,
+			CompactionRevision: math.MaxUint32,
+			StopOffset:         math.MaxInt64,
+			Collection:         v.collection,
+			IsEcVolume:         v.isEcVolume,
+		})
+		if err != nil {
+			return fmt.Errorf("copy %d%s: %v", vid, ext, err)
+		}
+		var buf bytes.Buffer
+		for {
+			resp, recvErr := copyFileClient.Recv()
+			if errors.Is(recvErr, io.EOF) {
+				break
+			}
+			if recvErr != nil {
+				return recvErr
+			}
+			buf.Write(resp.FileContent)
+		}
+		if !v.isReadOnly {
+			// the index is in the order of appending, so the needles after the cutoff are at the end
+			index, err := idx.FirstInvalidIndex(buf.Bytes(), func(key types.NeedleId, offset types.Offset, size types.Size) (bool, error) {
+				resp, err := client.ReadNeedleMeta(context.Background(), &volume_server_pb.ReadNeedleMetaRequest{
+					VolumeId: vid,
+					NeedleId: uint64(key),
+					Offset:   offset.ToActualOffset(),
+					Size:     int32(size),
+				})
+				if err != nil {
+					return false, fmt.Errorf("read needle meta %d: %v", key, err)
+				}
+				return resp.AppendAtNs <= uint64(cutoffTsNs), nil
+			})
+			if err != nil {
+				return err
+			}
+			buf.Truncate(index * types.NeedleMapEntrySize)
+		}
+		return os.WriteFile(idxFile, buf.Bytes(), 0644)
+	})
+}
+
+// compareVolume finds the needles not referenced on each replica, and the references missing on the replicas
+func compareVolume(vid uint32, v *auditVolume, references map[types.NeedleId]util.FullPath, danglingCheckable map[types.NeedleId]bool) (*VolumeChunkAudit, []*DanglingChunkReference, error) {
+	volumeAudit := &VolumeChunkAudit{
+		VolumeId:   vid,
+		Collection: v.collection,
+		IsEcVolume: v.isEcVolume,
+		IsReadOnly: v.isReadOnly,
+		Servers:    v.servers,
+		InUseCount: uint64(len(references)),
+	}
+	orphans := make(map[types.NeedleId]*OrphanChunk)
+	missing := make(map[types.NeedleId]int)
+
+	for _, idxFile := range v.idxFiles {
+		db := needle_map.NewMemDb()
+		if err := db.LoadFromIdx(idxFile); err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		for needleId := range references {
+			if danglingCheckable[needleId] {
+				if n, found := db.Get(needleId); !found || n.Size.IsDeleted() {
+					missing[needleId]++
+				}
+			}
+			db.Delete(needleId)
+		}
+		err := db.AscendingVisit(func(n needle_map.NeedleValue) error {
+			if n.Size.IsDeleted() {
+				return nil
+			}
+			orphan, found := orphans[n.Key]
+			if !found {
+				orphan = &OrphanChunk{FileId: n.Key.FileId(vid), Size: uint64(n.Size)}
+				orphans[n.Key] = orphan
+			}
+			orphan.Replicas++
+			return nil
+		})
+		db.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, orphan := range orphans {
+		volumeAudit.Orphans = append(volumeAudit.Orphans, orphan)
+		volumeAudit.OrphanSize += orphan.Size
+	}
+	sort.Slice(volumeAudit.Orphans, func(i, j int) bool {
+		return volumeAudit.Orphans[i].FileId < volumeAudit.Orphans[j].FileId
+	})
+
+	var dangling []*DanglingChunkReference
+	for needleId, missingCount := range missing {
+		reason := "missing on all replicas"
+		if missingCount < len(v.idxFiles) {
+			reason = fmt.Sprintf("missing on %d of %d replicas", missingCount, len(v.idxFiles))
+		}
+		dangling = append(dangling, &DanglingChunkReference{FileId: needleId.FileId(vid), Path: references[needleId], Reason: reason})
+	}
+	sort.Slice(dangling, func(i, j int) bool {
+		return dangling[i].FileId < dangling[j].FileId
+	})
+	return volumeAudit, dangling, nil
+}
+
+// Purge deletes the orphans of the finished audit. Only the orphans on all replicas of a writable volume
+// are deleted, unless forcePurging, and the chunks of the entries changed since the audit started are kept.
+func (ca *ChunkAuditor) Purge(reportId string, forcePurging bool) (*ChunkAuditReport, error) {
+	ca.lock.Lock()
+	report := ca.report
+	switch {
+	case report == nil || report.Id != reportId:
+		ca.lock.Unlock()
+		return nil, fmt.Errorf("chunk audit %s not found, the latest audit should be purged", reportId)
+	case report.IsRunning():
+		ca.lock.Unlock()
+		return nil, fmt.Errorf("chunk audit %s is running", reportId)
+	case report.Error != "":
+		ca.lock.Unlock()
+		return nil, fmt.Errorf("chunk audit %s failed: %s", reportId, report.Error)
+	case !report.PurgedAt.IsZero():
+		ca.lock.Unlock()
+		return nil, fmt.Errorf("chunk audit %s was purged at %v", reportId, report.PurgedAt)
+	case time.Since(report.FinishedAt) > ChunkAuditPurgeWindow:
+		ca.lock.Unlock()
+		return nil, fmt.Errorf("chunk audit %s finished at %v, more than %v ago, please audit again", reportId, report.FinishedAt, ChunkAuditPurgeWindow)
+	}
+	report.PurgedAt = time.Now()
+	changes := ca.changes
+	ca.changes, ca.recording = nil, false
+	ca.lock.Unlock()
+
+	changedNeedles := make(map[string]bool)
+	for _, change := range changes {
+		dataChunks, manifestChunks, err := ResolveChunkManifest(ca.filer.MasterClient.GetLookupFileIdFunction(), change.chunks, 0, math.MaxInt64)
+		if err != nil {
+			return nil, fmt.Errorf("resolve chunk manifest of changed %s: %v", change.path, err)
+		}
+		for _, chunk := range append(dataChunks, manifestChunks...) {
+			if fid, err := filer_pb.ToFileIdObject(chunk.GetFileIdString()); err == nil {
+				changedNeedles[types.NeedleId(fid.FileKey).FileId(fid.VolumeId)] = true
+			}
+		}
+	}
+
+	var purgeErrors []string
+	purged := make(map[uint32]uint64)
+	for _, volumeAudit := range report.Volumes {
+		if volumeAudit.IsEcVolume || volumeAudit.IsReadOnly {
+			if len(volumeAudit.Orphans) > 0 {
+				purgeErrors = append(purgeErrors, fmt.Sprintf("skip purging read only volume %d", volumeAudit.VolumeId))
+			}
+			continue
+		}
+		var fileIds []string
+		for _, orphan := range volumeAudit.Orphans {
+			if changedNeedles[orphan.FileId] {
+				continue
+			}
+			if orphan.Replicas == len(volumeAudit.Servers) || forcePurging {
+				fileIds = append(fileIds, orphan.FileId)
+			}
+		}
+		if len(fileIds) == 0 {
+			continue
+		}
+		glog.V(0).Infof("chunk audit %s purges %d orphans of volume %d", reportId, len(fileIds), volumeAudit.VolumeId)
+		for _, server := range volumeAudit.Servers {
+			results, err := operation.DeleteFilesAtOneVolumeServer(server, ca.filer.GrpcDialOption, fileIds, false)
+			if err != nil {
+				purgeErrors = append(purgeErrors, fmt.Sprintf("purge volume %d on %s: %v", volumeAudit.VolumeId, server, err))
+				continue
+			}
+			for _, result := range results {
+				if result.Error != "" {
+					purgeErrors = append(purgeErrors, fmt.Sprintf("purge %s on %s: %s", result.FileId, server, result.Error))
+				}
+			}
+		}
+		purged[volumeAudit.VolumeId] = uint64(len(fileIds))
+	}
+
+	ca.lock.Lock()
+	for _, volumeAudit := range report.Volumes {
+		volumeAudit.PurgedCount = purged[volumeAudit.VolumeId]
+	}
+	report.PurgeErrors = purgeErrors
+	ca.lock.Unlock()
+	return ca.Report(), nil
+}
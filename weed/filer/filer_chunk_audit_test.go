@@ -0,0 +1,72 @@
+package filer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func writeTestIdx(t *testing.T, idxFile string, needleIds ...types.NeedleId) {
+	db := needle_map.NewMemDb()
+	defer db.Close()
+	for i, needleId := range needleIds {
+		db.Set(needleId, types.ToOffset(int64(i+1)*8), 100)
+	}
+	if err := db.SaveToIdx(idxFile); err != nil {
+		t.Fatalf("save %s: %v", idxFile, err)
+	}
+}
+
+func TestCompareVolume(t *testing.T) {
+	dir := t.TempDir()
+	v := &auditVolume{
+		servers:  make([]pb.ServerAddress, 2),
+		idxFiles: []string{filepath.Join(dir, "1_0.idx"), filepath.Join(dir, "1_1.idx")},
+	}
+	writeTestIdx(t, v.idxFiles[0], 1, 2, 3, 4)
+	writeTestIdx(t, v.idxFiles[1], 1, 2, 4, 5)
+
+	references := map[types.NeedleId]util.FullPath{
+		1: "/a",
+		2: "/b",
+		// missing on the second replica
+		3: "/c",
+		// missing on both replicas
+		6: "/d",
+		// too new to be checked
+		7: "/e",
+	}
+	danglingCheckable := map[types.NeedleId]bool{1: true, 2: true, 3: true, 6: true}
+
+	volumeAudit, dangling, err := compareVolume(1, v, references, danglingCheckable)
+	if err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+
+	if len(volumeAudit.Orphans) != 2 {
+		t.Fatalf("orphans: %+v", volumeAudit.Orphans)
+	}
+	if o := volumeAudit.Orphans[0]; o.FileId != types.NeedleId(4).FileId(1) || o.Replicas != 2 || o.Size != 100 {
+		t.Errorf("orphan on all replicas: %+v", o)
+	}
+	if o := volumeAudit.Orphans[1]; o.FileId != types.NeedleId(5).FileId(1) || o.Replicas != 1 {
+		t.Errorf("orphan on one replica: %+v", o)
+	}
+	if volumeAudit.OrphanSize != 200 {
+		t.Errorf("orphan size %d", volumeAudit.OrphanSize)
+	}
+
+	if len(dangling) != 2 {
+		t.Fatalf("dangling: %+v", dangling)
+	}
+	if d := dangling[0]; d.Path != "/c" || d.Reason != "missing on 1 of 2 replicas" {
+		t.Errorf("dangling on one replica: %+v", d)
+	}
+	if d := dangling[1]; d.Path != "/d" || d.Reason != "missing on all replicas" {
+		t.Errorf("dangling on all replicas: %+v", d)
+	}
+}
@@ -169,6 +169,10 @@ func mergePathConf(a, b *filer_pb.FilerConf_PathConf) {
 	a.DataCenter = util.Nvl(b.DataCenter, a.DataCenter)
 	a.Rack = util.Nvl(b.Rack, a.Rack)
 	a.DataNode = util.Nvl(b.DataNode, a.DataNode)
+	a.PackSmallFiles = b.PackSmallFiles || a.PackSmallFiles
+	a.Compression = util.Nvl(b.Compression, a.Compression)
+	a.Dedupe = b.Dedupe || a.Dedupe
+	a.EncryptionKeyId = util.Nvl(b.EncryptionKeyId, a.EncryptionKeyId)
 }
 
 func (fc *FilerConf) ToProto() *filer_pb.FilerConf {
@@ -0,0 +1,162 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+)
+
+// A consistency token names the last metadata change of a write, by the filer logging it
+// and the event time. Another filer has applied the change, and invalidated its caches,
+// once its metadata subscription to that filer has received the events up to that time.
+// A read presenting the token waits for that, so it sees the write or anything newer.
+
+// ConsistencyTokenHeader carries the token in the http responses of writes, and in the requests of reads.
+const ConsistencyTokenHeader = "X-Seaweedfs-Consistency-Token"
+
+// ConsistencyWaitTimeout limits how long a read waits for the filer to catch up with a token.
+var ConsistencyWaitTimeout = 5 * time.Second
+
+type ConsistencyToken struct {
+	Filer pb.ServerAddress
+	TsNs  int64
+}
+
+func (t ConsistencyToken) String() string {
+	return fmt.Sprintf("%d@%s", t.TsNs, string(t.Filer))
+}
+
+func ParseConsistencyToken(token string) (t ConsistencyToken, err error) {
+	tsNs, address, found := strings.Cut(token, "@")
+	if !found || address == "" {
+		return t, fmt.Errorf("invalid consistency token %q", token)
+	}
+	if t.TsNs, err = strconv.ParseInt(tsNs, 10, 64); err != nil {
+		return t, fmt.Errorf("invalid consistency token %q: %v", token, err)
+	}
+	t.Filer = pb.ServerAddress(address)
+	return t, nil
+}
+
+type writeRecorderKey struct{}
+
+type writeRecorder struct {
+	lastTsNs int64
+}
+
+// RecordWrites returns a context recording the metadata changes made with it,
+// for ConsistencyToken. A context already recording is returned as is.
+func RecordWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(writeRecorderKey{}).(*writeRecorder); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, writeRecorderKey{}, &writeRecorder{})
+}
+
+func recordWrite(ctx context.Context, tsNs int64) {
+	recorder, ok := ctx.Value(writeRecorderKey{}).(*writeRecorder)
+	if !ok {
+		return
+	}
+	for {
+		lastTsNs := atomic.LoadInt64(&recorder.lastTsNs)
+		if tsNs <= lastTsNs || atomic.CompareAndSwapInt64(&recorder.lastTsNs, lastTsNs, tsNs) {
+			return
+		}
+	}
+}
+
+// ConsistencyToken returns the token of the writes recorded in the context, or "" if none.
+func (f *Filer) ConsistencyToken(ctx context.Context) string {
+	recorder, ok := ctx.Value(writeRecorderKey{}).(*writeRecorder)
+	if !ok || f.MetaAggregator == nil {
+		return ""
+	}
+	tsNs := atomic.LoadInt64(&recorder.lastTsNs)
+	if tsNs == 0 {
+		return ""
+	}
+	return ConsistencyToken{Filer: f.MetaAggregator.self, TsNs: tsNs}.String()
+}
+
+// WaitForConsistency waits until the changes up to the token are visible on this filer.
+func (f *Filer) WaitForConsistency(ctx context.Context, token string) error {
+	if token == "" {
+		return nil
+	}
+	t, err := ParseConsistencyToken(token)
+	if err != nil {
+		return err
+	}
+	if f.MetaAggregator == nil {
+		return fmt.Errorf("filer is not following its peers yet")
+	}
+	if t.Filer == f.MetaAggregator.self {
+		// own writes are in the store before the events are logged
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, ConsistencyWaitTimeout)
+	defer cancel()
+	if err = f.MetaAggregator.waitForPeer(ctx, t.Filer, t.TsNs); err != nil {
+		return fmt.Errorf("wait for changes of %s up to %v: %v", t.Filer, time.Unix(0, t.TsNs), err)
+	}
+	return nil
+}
+
+// peerProgress tracks the time of the last event received from each peer.
+type peerProgress struct {
+	sync.Mutex
+	lastTsNs map[pb.ServerAddress]int64
+	waiters  int
+	changed  chan struct{}
+}
+
+func newPeerProgress() *peerProgress {
+	return &peerProgress{
+		lastTsNs: make(map[pb.ServerAddress]int64),
+		changed:  make(chan struct{}),
+	}
+}
+
+func (p *peerProgress) update(peer pb.ServerAddress, tsNs int64) {
+	p.Lock()
+	defer p.Unlock()
+	if tsNs <= p.lastTsNs[peer] {
+		return
+	}
+	p.lastTsNs[peer] = tsNs
+	if p.waiters > 0 {
+		close(p.changed)
+		p.changed = make(chan struct{})
+	}
+}
+
+func (p *peerProgress) wait(ctx context.Context, peer pb.ServerAddress, tsNs int64) error {
+	p.Lock()
+	p.waiters++
+	defer func() {
+		p.Lock()
+		p.waiters--
+		p.Unlock()
+	}()
+	for {
+		if p.lastTsNs[peer] >= tsNs {
+			p.Unlock()
+			return nil
+		}
+		changed := p.changed
+		p.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		p.Lock()
+	}
+}
@@ -0,0 +1,50 @@
+package filer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsistencyToken(t *testing.T) {
+	token := ConsistencyToken{Filer: "localhost:8888.18888", TsNs: 1234}
+	parsed, err := ParseConsistencyToken(token.String())
+	assert.Nil(t, err)
+	assert.Equal(t, token, parsed)
+
+	_, err = ParseConsistencyToken("1234")
+	assert.NotNil(t, err)
+	_, err = ParseConsistencyToken("abc@localhost:8888")
+	assert.NotNil(t, err)
+}
+
+func TestRecordWrites(t *testing.T) {
+	ctx := RecordWrites(context.Background())
+	assert.Equal(t, ctx, RecordWrites(ctx))
+	recordWrite(ctx, 3)
+	recordWrite(ctx, 2)
+	assert.Equal(t, int64(3), ctx.Value(writeRecorderKey{}).(*writeRecorder).lastTsNs)
+
+	// not recording
+	recordWrite(context.Background(), 1)
+}
+
+func TestPeerProgressWait(t *testing.T) {
+	p := newPeerProgress()
+	p.update("peer", 5)
+	assert.Nil(t, p.wait(context.Background(), "peer", 5))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		p.update("other", 10)
+		p.update("peer", 10)
+	}()
+	assert.Nil(t, p.wait(context.Background(), "peer", 8))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, p.wait(ctx, "peer", 20))
+	assert.Equal(t, 0, p.waiters)
+}
@@ -0,0 +1,141 @@
+package filer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A named consumer of the metadata changes commits the timestamp of the last processed change,
+// and resumes after it when subscribing again with the same name, so no change is processed twice
+// or skipped. The offsets are kept in the filer store, so they survive the restarts of both the
+// consumer and the filer, and are visible to the filers sharing the filer store.
+
+const (
+	consumerOffsetKeyPrefix = "consumer.offset."
+	consumerNamesKey        = "consumer.names"
+)
+
+type ConsumerOffset struct {
+	Name        string `json:"name"`
+	TsNs        int64  `json:"tsNs"`
+	CommittedAt int64  `json:"committedAt"`
+}
+
+func consumerOffsetKey(name string) []byte {
+	return []byte(consumerOffsetKeyPrefix + name)
+}
+
+// GetConsumerOffset returns the committed offset of the consumer, or nil if never committed.
+func (f *Filer) GetConsumerOffset(ctx context.Context, name string) (*ConsumerOffset, error) {
+	data, err := f.Store.KvGet(ctx, consumerOffsetKey(name))
+	if err == ErrKvNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read offset of consumer %s: %v", name, err)
+	}
+	offset := &ConsumerOffset{}
+	if err = json.Unmarshal(data, offset); err != nil {
+		return nil, fmt.Errorf("unmarshal offset of consumer %s: %v", name, err)
+	}
+	return offset, nil
+}
+
+// CommitConsumerOffset saves the offset of the consumer. An offset older than the committed
+// one is ignored, e.g. committed late by a previous connection, unless to rewind the consumer.
+func (f *Filer) CommitConsumerOffset(ctx context.Context, name string, tsNs int64, rewind bool) (*ConsumerOffset, error) {
+	if name == "" {
+		return nil, fmt.Errorf("empty consumer name")
+	}
+
+	f.consumerOffsetsLock.Lock()
+	defer f.consumerOffsetsLock.Unlock()
+
+	existing, err := f.GetConsumerOffset(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.TsNs >= tsNs && !rewind {
+		return existing, nil
+	}
+	if existing == nil {
+		if err = f.updateConsumerNames(ctx, func(names map[string]bool) { names[name] = true }); err != nil {
+			return nil, err
+		}
+	}
+
+	offset := &ConsumerOffset{Name: name, TsNs: tsNs, CommittedAt: time.Now().UnixNano()}
+	data, err := json.Marshal(offset)
+	if err != nil {
+		return nil, err
+	}
+	if err = f.Store.KvPut(ctx, consumerOffsetKey(name), data); err != nil {
+		return nil, fmt.Errorf("save offset of consumer %s: %v", name, err)
+	}
+	return offset, nil
+}
+
+func (f *Filer) DeleteConsumerOffset(ctx context.Context, name string) error {
+	f.consumerOffsetsLock.Lock()
+	defer f.consumerOffsetsLock.Unlock()
+
+	if err := f.Store.KvDelete(ctx, consumerOffsetKey(name)); err != nil && err != ErrKvNotFound {
+		return fmt.Errorf("delete offset of consumer %s: %v", name, err)
+	}
+	return f.updateConsumerNames(ctx, func(names map[string]bool) { delete(names, name) })
+}
+
+// ListConsumerOffsets returns the committed offsets of all consumers, ordered by name.
+func (f *Filer) ListConsumerOffsets(ctx context.Context) (offsets []*ConsumerOffset, err error) {
+	names, err := f.readConsumerNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for name := range names {
+		offset, err := f.GetConsumerOffset(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if offset != nil {
+			offsets = append(offsets, offset)
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i].Name < offsets[j].Name
+	})
+	return
+}
+
+func (f *Filer) readConsumerNames(ctx context.Context) (map[string]bool, error) {
+	names := make(map[string]bool)
+	data, err := f.Store.KvGet(ctx, []byte(consumerNamesKey))
+	if err == ErrKvNotFound {
+		return names, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read consumer names: %v", err)
+	}
+	if err = json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("unmarshal consumer names: %v", err)
+	}
+	return names, nil
+}
+
+func (f *Filer) updateConsumerNames(ctx context.Context, fn func(names map[string]bool)) error {
+	names, err := f.readConsumerNames(ctx)
+	if err != nil {
+		return err
+	}
+	fn(names)
+	data, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	if err = f.Store.KvPut(ctx, []byte(consumerNamesKey), data); err != nil {
+		return fmt.Errorf("save consumer names: %v", err)
+	}
+	return nil
+}
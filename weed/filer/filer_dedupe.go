@@ -0,0 +1,99 @@
+package filer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Files under the folders configured with "fs.configure -dedupe" share the chunks of the same content.
+// The sha256 digest of each chunk content is mapped to a shared chunk in the filer store,
+// per collection, ttl and disk type, so a chunk is only reused on the same kind of volumes.
+// Each file referring to a deduplicated chunk holds one pack reference to it,
+// so the chunk is deleted after the last file releases it, same as the packed small files,
+// and the digest mapping is deleted with it.
+
+func dedupeKey(collection, ttl, diskType string, digest []byte) []byte {
+	return []byte(fmt.Sprintf("dedupe.%s.%s.%s.%s", collection, ttl, diskType, hex.EncodeToString(digest)))
+}
+
+func ContentDigest(data []byte) []byte {
+	digest := sha256.Sum256(data)
+	return digest[:]
+}
+
+// ReuseDedupedChunk returns the shared chunk with the same content digest, after adding one reference to it,
+// or nil if there is no such chunk.
+func (f *Filer) ReuseDedupedChunk(ctx context.Context, collection, ttl, diskType string, digest []byte) (*filer_pb.FileChunk, error) {
+	value, err := f.Store.KvGet(ctx, dedupeKey(collection, ttl, diskType, digest))
+	if err == ErrKvNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get dedupe %x: %v", digest, err)
+	}
+	chunk := &filer_pb.FileChunk{}
+	if err = proto.Unmarshal(value, chunk); err != nil {
+		return nil, fmt.Errorf("decode dedupe %x: %v", digest, err)
+	}
+	if err = f.AddPackReferences(ctx, chunk.GetFileIdString(), 1); err != nil {
+		// the chunk was released by all its files, and is to be deleted
+		glog.V(3).Infof("dedupe %x: %v", digest, err)
+		return nil, nil
+	}
+	return chunk, nil
+}
+
+// RecordDedupedChunk shares the newly uploaded chunk with one reference, and maps the content digest to it.
+func (f *Filer) RecordDedupedChunk(ctx context.Context, collection, ttl, diskType string, digest []byte, chunk *filer_pb.FileChunk) (*filer_pb.FileChunk, error) {
+	key := dedupeKey(collection, ttl, diskType, digest)
+	if err := f.setPackReferences(ctx, chunk.GetFileIdString(), 1, key); err != nil {
+		return chunk, err
+	}
+	shared := AsSharedChunk(chunk)
+	value, err := proto.Marshal(shared)
+	if err != nil {
+		return shared, fmt.Errorf("encode dedupe %x: %v", digest, err)
+	}
+	if err = f.Store.KvPut(ctx, key, value); err != nil {
+		return shared, fmt.Errorf("put dedupe %x: %v", digest, err)
+	}
+	return shared, nil
+}
+
+// deleteDedupeKey deletes the digest mapping of the released chunk,
+// unless the same content has been mapped to another chunk since.
+func (f *Filer) deleteDedupeKey(ctx context.Context, key []byte, fileId string) {
+	value, err := f.Store.KvGet(ctx, key)
+	if err != nil {
+		if err != ErrKvNotFound {
+			glog.V(0).Infof("get %s: %v", key, err)
+		}
+		return
+	}
+	chunk := &filer_pb.FileChunk{}
+	if err = proto.Unmarshal(value, chunk); err == nil && chunk.GetFileIdString() != fileId {
+		return
+	}
+	if err = f.Store.KvDelete(ctx, key); err != nil {
+		glog.V(0).Infof("delete %s: %v", key, err)
+	}
+}
+
+type referencedChunksKey struct{}
+
+// WithReferencedChunks marks that each chunk of the written entry holds its own reference,
+// e.g. the deduplicated chunks of an overwritten file, so all chunks of the old entry are released,
+// even the ones the new entry refers to again.
+func WithReferencedChunks(ctx context.Context) context.Context {
+	return context.WithValue(ctx, referencedChunksKey{}, true)
+}
+
+func isWithReferencedChunks(ctx context.Context) bool {
+	return ctx.Value(referencedChunksKey{}) != nil
+}
@@ -26,6 +26,9 @@ func (f *Filer) DeleteEntryMetaAndData(ctx context.Context, p util.FullPath, isR
 	if findErr != nil {
 		return findErr
 	}
+	if _, err = f.BeforeOperation(ctx, HookDelete, entry, nil); err != nil {
+		return err
+	}
 	isDeleteCollection := f.isBucket(entry)
 	if entry.IsDirectory() {
 		// delete the folder children, not including the folder itself
@@ -33,7 +36,7 @@ func (f *Filer) DeleteEntryMetaAndData(ctx context.Context, p util.FullPath, isR
 			// A case not handled:
 			// what if the chunk is in a different collection?
 			if shouldDeleteChunks {
-				f.maybeDeleteHardLinks(hardLinkIds)
+				AfterCommit(ctx, func() { f.maybeDeleteHardLinks(hardLinkIds) })
 			}
 			return nil
 		})
@@ -49,14 +52,18 @@ func (f *Filer) DeleteEntryMetaAndData(ctx context.Context, p util.FullPath, isR
 		return fmt.Errorf("delete file %s: %v", p, err)
 	}
 
-	if shouldDeleteChunks && !isDeleteCollection {
-		f.DirectDeleteChunks(entry.GetChunks())
-	}
+	AfterCommit(ctx, func() {
+		if shouldDeleteChunks && !isDeleteCollection {
+			f.DirectDeleteChunks(entry.GetChunks())
+		}
 
-	if isDeleteCollection {
-		collectionName := entry.Name()
-		f.doDeleteCollection(collectionName)
-	}
+		if isDeleteCollection {
+			collectionName := entry.Name()
+			f.doDeleteCollection(collectionName)
+		}
+	})
+
+	f.AfterOperation(ctx, HookDelete, entry, nil)
 
 	return nil
 }
@@ -114,7 +121,7 @@ func (f *Filer) doBatchDeleteFolderMetaAndData(ctx context.Context, entry *Entry
 	}
 
 	f.NotifyUpdateEvent(ctx, entry, nil, shouldDeleteChunks, isFromOtherCluster, signatures)
-	f.DeleteChunks(chunksToDelete)
+	AfterCommit(ctx, func() { f.DeleteChunks(chunksToDelete) })
 
 	return nil
 }
@@ -1,6 +1,7 @@
 package filer
 
 import (
+	"context"
 	"github.com/seaweedfs/seaweedfs/weed/storage"
 	"math"
 	"strings"
@@ -97,6 +98,14 @@ func (f *Filer) doDeleteFileIds(fileIds []string) {
 func (f *Filer) DirectDeleteChunks(chunks []*filer_pb.FileChunk) {
 	var fileIdsToDelete []string
 	for _, chunk := range chunks {
+		if IsPackedChunk(chunk) {
+			if isLast, err := f.ReleasePackedChunk(context.Background(), chunk.GetFileIdString()); err != nil {
+				glog.V(0).Infof("release packed chunk %s: %v", chunk.GetFileIdString(), err)
+			} else if isLast {
+				fileIdsToDelete = append(fileIdsToDelete, chunk.GetFileIdString())
+			}
+			continue
+		}
 		if !chunk.IsChunkManifest {
 			fileIdsToDelete = append(fileIdsToDelete, chunk.GetFileIdString())
 			continue
@@ -117,7 +126,7 @@ func (f *Filer) DirectDeleteChunks(chunks []*filer_pb.FileChunk) {
 func (f *Filer) DeleteChunks(chunks []*filer_pb.FileChunk) {
 	for _, chunk := range chunks {
 		if !chunk.IsChunkManifest {
-			f.fileIdDeletionQueue.EnQueue(chunk.GetFileIdString())
+			f.deleteChunk(chunk)
 			continue
 		}
 		dataChunks, manifestResolveErr := ResolveOneChunkManifest(f.MasterClient.LookupFileId, chunk)
@@ -125,7 +134,7 @@ func (f *Filer) DeleteChunks(chunks []*filer_pb.FileChunk) {
 			glog.V(0).Infof("failed to resolve manifest %s: %v", chunk.FileId, manifestResolveErr)
 		}
 		for _, dChunk := range dataChunks {
-			f.fileIdDeletionQueue.EnQueue(dChunk.GetFileIdString())
+			f.deleteChunk(dChunk)
 		}
 		f.fileIdDeletionQueue.EnQueue(chunk.GetFileIdString())
 	}
@@ -133,7 +142,7 @@ func (f *Filer) DeleteChunks(chunks []*filer_pb.FileChunk) {
 
 func (f *Filer) DeleteChunksNotRecursive(chunks []*filer_pb.FileChunk) {
 	for _, chunk := range chunks {
-		f.fileIdDeletionQueue.EnQueue(chunk.GetFileIdString())
+		f.deleteChunk(chunk)
 	}
 }
 
@@ -0,0 +1,572 @@
+package filer
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// A directory checksum is the XOR of the checksums of its children.
+// The checksum of a file covers its name, size and ETag, and the checksum of a sub directory
+// covers its name and the sub directory checksum, so the checksum of a directory changes
+// whenever anything in its tree changes. Because of the XOR, a change is applied by
+// XOR-ing the old and the new checksum of the changed child into the parent directory,
+// and then into each ancestor.
+//
+// The checksums are kept in the filer store, only for directories already tracked, i.e.
+// created under a tracked directory or rebuilt. The changes are applied in the background.
+//
+// The checksums of a filer store are maintained by one filer, holding the cluster lock of the store.
+// It counts its own changes and the changes of the peer filers from the meta aggregator, whether
+// the peers share the filer store or their changes are replayed into it. The other filers drop
+// their changes, and a filer taking over rebuilds the checksums, since the changes queued by the
+// previous owner are lost.
+//
+// The queued file changes are coalesced into one delta per directory, and the whole queue is
+// dropped for a rebuild when too long. A rebuild may count the changes made while it runs, or
+// arriving later from the peers, already. So for a while after a rebuild, the directories
+// changed under it are recomputed from their children instead of applying the deltas.
+
+const (
+	dirChecksumQueueLimit = 100000
+	dirChecksumSettleTime = time.Minute
+)
+
+type DirChecksum [md5.Size]byte
+
+func (c DirChecksum) String() string {
+	return hex.EncodeToString(c[:])
+}
+
+func (c DirChecksum) xor(other DirChecksum) (result DirChecksum) {
+	for i := range c {
+		result[i] = c[i] ^ other[i]
+	}
+	return
+}
+
+func dirChecksumKey(dir util.FullPath) []byte {
+	return []byte("dirSum." + string(dir))
+}
+
+func fileChecksum(name string, fileSize uint64, etag string) DirChecksum {
+	h := md5.New()
+	h.Write([]byte{'f'})
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	size := make([]byte, 8)
+	util.Uint64toBytes(size, fileSize)
+	h.Write(size)
+	h.Write([]byte(etag))
+	var c DirChecksum
+	copy(c[:], h.Sum(nil))
+	return c
+}
+
+func subDirChecksum(name string, sum DirChecksum) DirChecksum {
+	h := md5.New()
+	h.Write([]byte{'d'})
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write(sum[:])
+	var c DirChecksum
+	copy(c[:], h.Sum(nil))
+	return c
+}
+
+// checksummedEntry keeps what the checksum needs, since the entry can change after queued.
+type checksummedEntry struct {
+	fullPath    util.FullPath
+	isDirectory bool
+	fileSize    uint64
+	etag        string
+}
+
+func (c *checksummedEntry) dir() util.FullPath {
+	dir, _ := c.fullPath.DirAndName()
+	return util.FullPath(dir)
+}
+
+func newChecksummedEntry(entry *Entry) *checksummedEntry {
+	if entry == nil {
+		return nil
+	}
+	c := &checksummedEntry{
+		fullPath:    entry.FullPath,
+		isDirectory: entry.IsDirectory(),
+	}
+	if !c.isDirectory {
+		c.fileSize = entry.Size()
+		c.etag = ETagEntry(entry)
+	}
+	return c
+}
+
+type dirChecksumTask struct {
+	deltas             map[util.FullPath]DirChecksum // the coalesced file changes, by directory
+	oldEntry, newEntry *checksummedEntry             // a directory change
+	tsNs               int64                         // the time of the earliest change
+	rebuildDir         util.FullPath
+	done               chan error // a rebuild, or a wait for the queued changes
+}
+
+// isCountedByRebuild tells whether a change at tsNs in the directory may be counted by a rebuild already.
+func isCountedByRebuild(rebuiltTsNs map[util.FullPath]int64, dir util.FullPath, tsNs int64) bool {
+	for rebuiltDir, rebuiltTsNs := range rebuiltTsNs {
+		if tsNs <= rebuiltTsNs+int64(dirChecksumSettleTime) && (dir == rebuiltDir || strings.HasPrefix(string(dir), strings.TrimSuffix(string(rebuiltDir), "/")+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+type DirChecksums struct {
+	filer     *Filer
+	isOwner   int32
+	tasks     []*dirChecksumTask
+	queued    int
+	tasksLock sync.Mutex
+	tasksCond *sync.Cond
+	// when each rebuild finished, only used by the task loop
+	rebuiltTsNs map[util.FullPath]int64
+}
+
+func NewDirChecksums(f *Filer) *DirChecksums {
+	dc := &DirChecksums{
+		filer:       f,
+		rebuiltTsNs: make(map[util.FullPath]int64),
+	}
+	dc.tasksCond = sync.NewCond(&dc.tasksLock)
+	go dc.loopProcessingTasks()
+	if f.Dlm == nil || f.Dlm.Host == "" {
+		// without the address of the filer, e.g. in the tools, it is the only owner
+		dc.takeOver()
+	} else {
+		go dc.loopTakingOver()
+	}
+	return dc
+}
+
+func dirChecksumLockKey(signature int32) string {
+	return fmt.Sprintf("dirChecksums.lock.%d", signature)
+}
+
+// loopTakingOver holds the cluster lock of the filer store, and rebuilds the checksums
+// whenever this filer becomes their owner.
+func (dc *DirChecksums) loopTakingOver() {
+	f := dc.filer
+	lockClient := cluster.NewLockClient(f.GrpcDialOption, f.Dlm.Host)
+	lock := lockClient.StartLock(dirChecksumLockKey(f.Signature), string(f.Dlm.Host))
+	for {
+		time.Sleep(time.Second)
+		isLocked := lock.IsLocked()
+		if isLocked && !dc.IsOwner() {
+			dc.takeOver()
+		}
+		if !isLocked && dc.IsOwner() {
+			// lost the lock, and stopped renewing it
+			glog.V(0).Infof("stop maintaining the directory checksums, and wait for the lock again")
+			atomic.StoreInt32(&dc.isOwner, 0)
+			lock.StopLock()
+			lock = lockClient.StartLock(dirChecksumLockKey(f.Signature), string(f.Dlm.Host))
+		}
+	}
+}
+
+func (dc *DirChecksums) takeOver() {
+	glog.V(0).Infof("maintain the directory checksums, starting with a rebuild")
+	atomic.StoreInt32(&dc.isOwner, 1)
+	dc.enqueue(&dirChecksumTask{rebuildDir: "/"})
+}
+
+// IsOwner tells whether this filer maintains the checksums of its filer store.
+func (dc *DirChecksums) IsOwner() bool {
+	return atomic.LoadInt32(&dc.isOwner) == 1
+}
+
+func (dc *DirChecksums) enqueue(task *dirChecksumTask) {
+	dc.tasksLock.Lock()
+	dc.appendTask(task)
+	dc.tasksLock.Unlock()
+	dc.tasksCond.Signal()
+}
+
+// appendTask queues the task. The queue is dropped for a rebuild when too many changes are queued.
+func (dc *DirChecksums) appendTask(task *dirChecksumTask) {
+	dc.tasks = append(dc.tasks, task)
+	dc.queued++
+	dc.limitQueue()
+}
+
+func (dc *DirChecksums) limitQueue() {
+	if dc.queued <= dirChecksumQueueLimit {
+		return
+	}
+	glog.Warningf("directory checksums are %d changes behind, rebuild them instead", dc.queued)
+	tasks := []*dirChecksumTask{{rebuildDir: "/"}}
+	for _, t := range dc.tasks {
+		if t.done != nil {
+			tasks = append(tasks, t)
+		}
+	}
+	dc.tasks, dc.queued = tasks, len(tasks)
+}
+
+func (dc *DirChecksums) onEvent(oldEntry, newEntry *Entry) {
+	dc.queueChange(newChecksummedEntry(oldEntry), newChecksummedEntry(newEntry), time.Now().UnixNano())
+}
+
+// onPeerEvent counts a change of a peer filer, sharing the filer store or replayed into it.
+func (dc *DirChecksums) onPeerEvent(event *filer_pb.SubscribeMetadataResponse) {
+	oldEntry, newEntry := entriesOfEvent(event)
+	dc.queueChange(newChecksummedEntry(oldEntry), newChecksummedEntry(newEntry), event.TsNs)
+}
+
+func (dc *DirChecksums) queueChange(oldEntry, newEntry *checksummedEntry, tsNs int64) {
+	if !dc.IsOwner() || (oldEntry == nil && newEntry == nil) {
+		return
+	}
+	dc.tasksLock.Lock()
+	defer dc.tasksCond.Signal()
+	defer dc.tasksLock.Unlock()
+
+	if (oldEntry != nil && oldEntry.isDirectory) || (newEntry != nil && newEntry.isDirectory) {
+		dc.appendTask(&dirChecksumTask{oldEntry: oldEntry, newEntry: newEntry, tsNs: tsNs})
+		return
+	}
+
+	// the file changes are coalesced into the deltas of their directories
+	var task *dirChecksumTask
+	if n := len(dc.tasks); n > 0 && dc.tasks[n-1].deltas != nil {
+		task = dc.tasks[n-1]
+	} else {
+		task = &dirChecksumTask{deltas: make(map[util.FullPath]DirChecksum), tsNs: tsNs}
+		dc.appendTask(task)
+	}
+	if tsNs < task.tsNs {
+		task.tsNs = tsNs
+	}
+	for _, entry := range []*checksummedEntry{oldEntry, newEntry} {
+		if entry == nil {
+			continue
+		}
+		dir, name := entry.fullPath.DirAndName()
+		delta, found := task.deltas[util.FullPath(dir)]
+		if !found {
+			dc.queued++
+		}
+		task.deltas[util.FullPath(dir)] = delta.xor(fileChecksum(name, entry.fileSize, entry.etag))
+	}
+	dc.limitQueue()
+}
+
+// Rebuild recomputes the checksums of the directory tree, and marks it as tracked.
+// It waits for the queued changes, and the changes during the rebuild wait for it.
+func (dc *DirChecksums) Rebuild(dir util.FullPath) error {
+	if !dc.IsOwner() {
+		return fmt.Errorf("the directory checksums are maintained by the filer holding the lock %s", dirChecksumLockKey(dc.filer.Signature))
+	}
+	done := make(chan error, 1)
+	dc.enqueue(&dirChecksumTask{
+		rebuildDir: dir,
+		done:       done,
+	})
+	return <-done
+}
+
+// waitForQueuedChanges waits until the changes queued so far are counted.
+func (dc *DirChecksums) waitForQueuedChanges() error {
+	done := make(chan error, 1)
+	dc.enqueue(&dirChecksumTask{done: done})
+	return <-done
+}
+
+func (dc *DirChecksums) loopProcessingTasks() {
+	for {
+		dc.tasksLock.Lock()
+		for len(dc.tasks) == 0 {
+			dc.tasksCond.Wait()
+		}
+		tasks := dc.tasks
+		dc.tasks, dc.queued = nil, 0
+		dc.tasksLock.Unlock()
+
+		for _, task := range tasks {
+			err := dc.process(context.Background(), task)
+			if task.done != nil {
+				task.done <- err
+			} else if err != nil {
+				glog.Warningf("update directory checksums: %v", err)
+			}
+		}
+	}
+}
+
+func (dc *DirChecksums) process(ctx context.Context, task *dirChecksumTask) error {
+	if !dc.IsOwner() {
+		// the new owner rebuilds the checksums
+		return fmt.Errorf("not maintaining the directory checksums")
+	}
+	switch {
+	case task.rebuildDir != "":
+		err := dc.rebuild(ctx, task.rebuildDir)
+		dc.rebuiltTsNs[task.rebuildDir] = time.Now().UnixNano()
+		return err
+	case task.deltas != nil:
+		for dir, delta := range task.deltas {
+			var err error
+			if isCountedByRebuild(dc.rebuiltTsNs, dir, task.tsNs) {
+				err = dc.recompute(ctx, dir)
+			} else {
+				err = dc.propagate(ctx, dir, delta)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case task.oldEntry != nil || task.newEntry != nil:
+		return dc.apply(ctx, task.oldEntry, task.newEntry, task.tsNs)
+	}
+	return nil
+}
+
+// Get returns the checksum of the directory, and whether the directory is tracked.
+func (dc *DirChecksums) Get(ctx context.Context, dir util.FullPath) (sum DirChecksum, isTracked bool, err error) {
+	value, err := dc.filer.Store.KvGet(ctx, dirChecksumKey(dir))
+	if err == ErrKvNotFound {
+		return sum, false, nil
+	}
+	if err != nil {
+		return sum, false, err
+	}
+	copy(sum[:], value)
+	return sum, true, nil
+}
+
+// ChildChecksum returns the checksum of the entry as counted in its parent directory.
+func (dc *DirChecksums) ChildChecksum(ctx context.Context, entry *Entry) (DirChecksum, error) {
+	return dc.childChecksum(ctx, newChecksummedEntry(entry))
+}
+
+func (dc *DirChecksums) childChecksum(ctx context.Context, entry *checksummedEntry) (DirChecksum, error) {
+	name := entry.fullPath.Name()
+	if !entry.isDirectory {
+		return fileChecksum(name, entry.fileSize, entry.etag), nil
+	}
+	sum, _, err := dc.Get(ctx, entry.fullPath)
+	return subDirChecksum(name, sum), err
+}
+
+func (dc *DirChecksums) put(ctx context.Context, dir util.FullPath, sum DirChecksum) error {
+	return dc.filer.Store.KvPut(ctx, dirChecksumKey(dir), sum[:])
+}
+
+func (dc *DirChecksums) apply(ctx context.Context, oldEntry, newEntry *checksummedEntry, tsNs int64) error {
+	if (oldEntry != nil && isCountedByRebuild(dc.rebuiltTsNs, oldEntry.dir(), tsNs)) ||
+		(newEntry != nil && isCountedByRebuild(dc.rebuiltTsNs, newEntry.dir(), tsNs)) {
+		return dc.recomputeChange(ctx, oldEntry, newEntry)
+	}
+
+	var oldParent, newParent string
+	var oldSum, newSum DirChecksum
+	var err error
+
+	if oldEntry != nil {
+		oldParent, _ = oldEntry.fullPath.DirAndName()
+		if oldSum, err = dc.childChecksum(ctx, oldEntry); err != nil {
+			return err
+		}
+		if oldEntry.isDirectory && (newEntry == nil || newEntry.fullPath != oldEntry.fullPath) {
+			// the directory is gone, stop tracking it
+			if err = dc.filer.Store.KvDelete(ctx, dirChecksumKey(oldEntry.fullPath)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if newEntry != nil {
+		newParent, _ = newEntry.fullPath.DirAndName()
+		if newEntry.isDirectory && (oldEntry == nil || newEntry.fullPath != oldEntry.fullPath) {
+			// a new directory is tracked if its parent is tracked
+			if _, isTracked, err := dc.Get(ctx, util.FullPath(newParent)); err != nil {
+				return err
+			} else if isTracked {
+				if err = dc.put(ctx, newEntry.fullPath, DirChecksum{}); err != nil {
+					return err
+				}
+			}
+		}
+		if newSum, err = dc.childChecksum(ctx, newEntry); err != nil {
+			return err
+		}
+	}
+
+	if oldEntry != nil && newEntry != nil && oldParent == newParent {
+		return dc.propagate(ctx, util.FullPath(newParent), oldSum.xor(newSum))
+	}
+	if oldEntry != nil {
+		if err = dc.propagate(ctx, util.FullPath(oldParent), oldSum); err != nil {
+			return err
+		}
+	}
+	if newEntry != nil {
+		return dc.propagate(ctx, util.FullPath(newParent), newSum)
+	}
+	return nil
+}
+
+// recomputeChange recomputes the directories of a change, which a rebuild may count already.
+func (dc *DirChecksums) recomputeChange(ctx context.Context, oldEntry, newEntry *checksummedEntry) error {
+	var dirs []util.FullPath
+	if oldEntry != nil {
+		if oldEntry.isDirectory && (newEntry == nil || newEntry.fullPath != oldEntry.fullPath) {
+			if err := dc.filer.Store.KvDelete(ctx, dirChecksumKey(oldEntry.fullPath)); err != nil {
+				return err
+			}
+		}
+		dirs = append(dirs, oldEntry.dir())
+	}
+	if newEntry != nil {
+		if newEntry.isDirectory {
+			dirs = append(dirs, newEntry.fullPath)
+		}
+		dirs = append(dirs, newEntry.dir())
+	}
+	for _, dir := range dirs {
+		if err := dc.recompute(ctx, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recompute sets the checksum of the directory from its children, and applies the change to its ancestors.
+// A new directory is tracked if its parent is tracked.
+func (dc *DirChecksums) recompute(ctx context.Context, dir util.FullPath) error {
+	oldSum, isTracked, err := dc.Get(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if !isTracked && dir != "/" {
+		parent, _ := dir.DirAndName()
+		if _, isTracked, err = dc.Get(ctx, util.FullPath(parent)); err != nil {
+			return err
+		}
+	}
+	if !isTracked {
+		return nil
+	}
+	if _, err = dc.filer.FindEntry(ctx, dir); err == filer_pb.ErrNotFound {
+		// deleted, and not counted by its parent any more
+		return nil
+	} else if err != nil {
+		return err
+	}
+	newSum, err := dc.sumChildren(ctx, dir, func(subDir util.FullPath) (DirChecksum, error) {
+		sum, _, err := dc.Get(ctx, subDir)
+		return sum, err
+	})
+	if err != nil {
+		return err
+	}
+	if err = dc.put(ctx, dir, newSum); err != nil {
+		return err
+	}
+	if dir == "/" {
+		return nil
+	}
+	parent, name := dir.DirAndName()
+	return dc.propagate(ctx, util.FullPath(parent), subDirChecksum(name, oldSum).xor(subDirChecksum(name, newSum)))
+}
+
+// propagate applies the change of a child to the directory and its ancestors.
+func (dc *DirChecksums) propagate(ctx context.Context, dir util.FullPath, delta DirChecksum) error {
+	for delta != (DirChecksum{}) {
+		oldSum, isTracked, err := dc.Get(ctx, dir)
+		if err != nil {
+			return err
+		}
+		if !isTracked {
+			return nil
+		}
+		newSum := oldSum.xor(delta)
+		if err = dc.put(ctx, dir, newSum); err != nil {
+			return err
+		}
+		if dir == "/" {
+			return nil
+		}
+		parent, name := dir.DirAndName()
+		delta = subDirChecksum(name, oldSum).xor(subDirChecksum(name, newSum))
+		dir = util.FullPath(parent)
+	}
+	return nil
+}
+
+func (dc *DirChecksums) rebuild(ctx context.Context, dir util.FullPath) error {
+	newSum, err := dc.computeTree(ctx, dir)
+	if err != nil {
+		return err
+	}
+	glog.V(0).Infof("rebuilt directory checksum of %s: %v", dir, newSum)
+	if dir == "/" {
+		return nil
+	}
+	// the parent may not count the directory as it was, if at all
+	parent, _ := dir.DirAndName()
+	if _, isTracked, err := dc.Get(ctx, util.FullPath(parent)); err != nil || !isTracked {
+		return err
+	}
+	return dc.recompute(ctx, util.FullPath(parent))
+}
+
+func (dc *DirChecksums) computeTree(ctx context.Context, dir util.FullPath) (DirChecksum, error) {
+	sum, err := dc.sumChildren(ctx, dir, func(subDir util.FullPath) (DirChecksum, error) {
+		return dc.computeTree(ctx, subDir)
+	})
+	if err != nil {
+		return sum, err
+	}
+	return sum, dc.put(ctx, dir, sum)
+}
+
+// sumChildren lists the directory, with the checksums of the sub directories from subDirSum.
+func (dc *DirChecksums) sumChildren(ctx context.Context, dir util.FullPath, subDirSum func(subDir util.FullPath) (DirChecksum, error)) (sum DirChecksum, err error) {
+	lastFileName := ""
+	for {
+		var subDirs []util.FullPath
+		var count int
+		lastFileName, err = dc.filer.StreamListDirectoryEntries(ctx, dir, lastFileName, false, PaginationSize, "", "", "", func(entry *Entry) bool {
+			count++
+			if entry.IsDirectory() {
+				subDirs = append(subDirs, entry.FullPath)
+			} else {
+				sum = sum.xor(fileChecksum(entry.Name(), entry.Size(), ETagEntry(entry)))
+			}
+			return true
+		})
+		if err != nil {
+			return sum, err
+		}
+		for _, subDir := range subDirs {
+			subSum, err := subDirSum(subDir)
+			if err != nil {
+				return sum, err
+			}
+			sum = sum.xor(subDirChecksum(subDir.Name(), subSum))
+		}
+		if count < PaginationSize {
+			return sum, nil
+		}
+	}
+}
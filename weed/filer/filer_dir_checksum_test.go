@@ -0,0 +1,278 @@
+package filer
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// checksumTestTree changes the filer store, and counts the changes in the order of the filer events.
+type checksumTestTree struct {
+	t  *testing.T
+	f  *Filer
+	dc *DirChecksums
+}
+
+func newChecksumTestTree(t *testing.T) *checksumTestTree {
+	f := newTestFiler()
+	dc := NewDirChecksums(f)
+	if err := dc.waitForQueuedChanges(); err != nil {
+		t.Fatalf("initial rebuild: %v", err)
+	}
+	return &checksumTestTree{t: t, f: f, dc: dc}
+}
+
+func (tree *checksumTestTree) find(p util.FullPath) *Entry {
+	entry, err := tree.f.Store.FindEntry(context.Background(), p)
+	if err == filer_pb.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		tree.t.Fatalf("find %s: %v", p, err)
+	}
+	return entry
+}
+
+func (tree *checksumTestTree) insert(entry *Entry) {
+	oldEntry := tree.find(entry.FullPath)
+	if err := tree.f.Store.InsertEntry(context.Background(), entry); err != nil {
+		tree.t.Fatalf("insert %s: %v", entry.FullPath, err)
+	}
+	tree.dc.onEvent(oldEntry, entry)
+}
+
+func (tree *checksumTestTree) write(p util.FullPath, content string) {
+	md5sum := md5.Sum([]byte(content))
+	tree.insert(&Entry{FullPath: p, Attr: Attr{Mode: 0644, FileSize: uint64(len(content)), Md5: md5sum[:]}})
+}
+
+func (tree *checksumTestTree) mkdir(p util.FullPath) {
+	tree.insert(&Entry{FullPath: p, Attr: Attr{Mode: 0755 | 1<<31}})
+}
+
+func (tree *checksumTestTree) children(dir util.FullPath) (children []*Entry) {
+	_, err := tree.f.Store.ListDirectoryEntries(context.Background(), dir, "", false, 1000, func(entry *Entry) bool {
+		children = append(children, entry)
+		return true
+	})
+	if err != nil {
+		tree.t.Fatalf("list %s: %v", dir, err)
+	}
+	return
+}
+
+// remove deletes the entry recursively, the children first
+func (tree *checksumTestTree) remove(p util.FullPath) {
+	entry := tree.find(p)
+	if entry.IsDirectory() {
+		for _, child := range tree.children(p) {
+			tree.remove(child.FullPath)
+		}
+	}
+	if err := tree.f.Store.DeleteOneEntry(context.Background(), entry); err != nil {
+		tree.t.Fatalf("delete %s: %v", p, err)
+	}
+	tree.dc.onEvent(entry, nil)
+}
+
+// rename moves the entry the way the filer does, creating the new entry before moving the children
+func (tree *checksumTestTree) rename(oldPath, newPath util.FullPath) {
+	entry := tree.find(oldPath)
+	newEntry := *entry
+	newEntry.FullPath = newPath
+	tree.insert(&newEntry)
+	if entry.IsDirectory() {
+		for _, child := range tree.children(oldPath) {
+			tree.rename(child.FullPath, newPath.Child(child.Name()))
+		}
+	}
+	if err := tree.f.Store.DeleteOneEntry(context.Background(), entry); err != nil {
+		tree.t.Fatalf("delete %s: %v", oldPath, err)
+	}
+	tree.dc.onEvent(entry, nil)
+}
+
+// expected computes the checksum of the directory tree, without the stored checksums
+func (tree *checksumTestTree) expected(dir util.FullPath) DirChecksum {
+	sum, err := tree.dc.sumChildren(context.Background(), dir, func(subDir util.FullPath) (DirChecksum, error) {
+		return tree.expected(subDir), nil
+	})
+	if err != nil {
+		tree.t.Fatalf("compute %s: %v", dir, err)
+	}
+	return sum
+}
+
+// check compares the stored checksums of the directory tree with the computed ones
+func (tree *checksumTestTree) check(dir util.FullPath) {
+	tree.t.Helper()
+	if dir == "/" {
+		if err := tree.dc.waitForQueuedChanges(); err != nil {
+			tree.t.Fatal(err)
+		}
+	}
+	sum, isTracked, err := tree.dc.Get(context.Background(), dir)
+	if err != nil || !isTracked {
+		tree.t.Fatalf("get %s: tracked %v, %v", dir, isTracked, err)
+	}
+	if expected := tree.expected(dir); sum != expected {
+		tree.t.Errorf("%s checksum %v, expected %v", dir, sum, expected)
+	}
+	for _, child := range tree.children(dir) {
+		if child.IsDirectory() {
+			tree.check(child.FullPath)
+		}
+	}
+}
+
+func (tree *checksumTestTree) checkUntracked(dir util.FullPath) {
+	tree.t.Helper()
+	if _, isTracked, err := tree.dc.Get(context.Background(), dir); err != nil || isTracked {
+		tree.t.Errorf("%s still tracked: %v", dir, err)
+	}
+}
+
+func TestDirChecksumsChanges(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		afterRebuild bool
+	}{
+		{"applied as deltas", false},
+		{"recomputed after a rebuild", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tree := newChecksumTestTree(t)
+			if !tc.afterRebuild {
+				// the loop is idle, and sees the change once the next task is queued
+				tree.dc.rebuiltTsNs = make(map[util.FullPath]int64)
+			}
+			emptySum, _, _ := tree.dc.Get(context.Background(), "/")
+
+			tree.mkdir("/a")
+			tree.mkdir("/a/b")
+			tree.write("/a/b/f1", "hello")
+			tree.write("/a/b/f2", "world")
+			tree.write("/a/f3", "!")
+			tree.write("/f4", "top")
+			tree.check("/")
+
+			// changing a file changes each ancestor
+			before, _, _ := tree.dc.Get(context.Background(), "/a")
+			tree.write("/a/b/f1", "hello again")
+			tree.check("/")
+			if after, _, _ := tree.dc.Get(context.Background(), "/a"); after == before {
+				t.Errorf("unchanged checksum of /a")
+			}
+
+			// the renamed tree keeps its checksum
+			bSum, _, _ := tree.dc.Get(context.Background(), "/a/b")
+			tree.mkdir("/c")
+			tree.rename("/a/b", "/c/b")
+			tree.check("/")
+			tree.checkUntracked("/a/b")
+			if movedSum, _, _ := tree.dc.Get(context.Background(), "/c/b"); movedSum != bSum {
+				t.Errorf("renamed checksum %v, expected %v", movedSum, bSum)
+			}
+
+			// deleting the directories stops tracking them
+			tree.remove("/c")
+			tree.check("/")
+			tree.checkUntracked("/c")
+			tree.checkUntracked("/c/b")
+
+			tree.remove("/a")
+			tree.remove("/f4")
+			tree.check("/")
+			if sum, _, _ := tree.dc.Get(context.Background(), "/"); sum != emptySum {
+				t.Errorf("checksum of the emptied root %v, expected %v", sum, emptySum)
+			}
+		})
+	}
+}
+
+func TestDirChecksumsRebuild(t *testing.T) {
+	tree := newChecksumTestTree(t)
+	tree.dc.rebuiltTsNs = make(map[util.FullPath]int64)
+	ctx := context.Background()
+	tree.write("/f", "counted")
+
+	// written without counting, e.g. before the checksums were maintained,
+	// so the rebuild also fixes the parent
+	beforeRebuild := time.Now().UnixNano()
+	for _, p := range []util.FullPath{"/x", "/x/y"} {
+		tree.f.Store.InsertEntry(ctx, &Entry{FullPath: p, Attr: Attr{Mode: 0755 | 1<<31}})
+	}
+	late := &Entry{FullPath: "/x/y/late", Attr: Attr{Mode: 0644, FileSize: 4}}
+	tree.f.Store.InsertEntry(ctx, late)
+	if err := tree.dc.Rebuild("/x"); err != nil {
+		t.Fatal(err)
+	}
+	tree.check("/")
+
+	// the change counted by the rebuild already, arriving late
+	tree.dc.queueChange(nil, newChecksummedEntry(late), beforeRebuild)
+	tree.check("/")
+
+	// a change of a peer filer, sharing the filer store
+	peerEntry := &Entry{FullPath: "/x/peer", Attr: Attr{Mode: 0644, FileSize: 6}}
+	tree.f.Store.InsertEntry(ctx, peerEntry)
+	tree.dc.onPeerEvent(&filer_pb.SubscribeMetadataResponse{
+		Directory:         "/x",
+		EventNotification: &filer_pb.EventNotification{NewEntry: peerEntry.ToProtoEntry()},
+		TsNs:              time.Now().UnixNano(),
+	})
+	tree.check("/")
+
+	// applied as deltas again once settled
+	tree.dc.rebuiltTsNs["/x"] -= int64(2 * dirChecksumSettleTime)
+	tree.write("/x/y/after", "settled")
+	tree.remove("/x/y/late")
+	tree.check("/")
+}
+
+func TestDirChecksumsOwner(t *testing.T) {
+	tree := newChecksumTestTree(t)
+	atomic.StoreInt32(&tree.dc.isOwner, 0)
+
+	before, _, _ := tree.dc.Get(context.Background(), "/")
+	tree.write("/dropped", "by the other filers")
+	if err := tree.dc.waitForQueuedChanges(); err == nil {
+		t.Errorf("counted changes without owning the checksums")
+	}
+	if after, _, _ := tree.dc.Get(context.Background(), "/"); after != before {
+		t.Errorf("changed checksum %v without owning the checksums", after)
+	}
+	if err := tree.dc.Rebuild("/"); err == nil {
+		t.Errorf("rebuilt without owning the checksums")
+	}
+}
+
+func TestDirChecksumsQueueLimit(t *testing.T) {
+	dc := &DirChecksums{isOwner: 1}
+	dc.tasksCond = sync.NewCond(&dc.tasksLock)
+
+	// the changes in the same directory are coalesced
+	for i := 0; i < 10; i++ {
+		dc.queueChange(nil, &checksummedEntry{fullPath: util.NewFullPath("/d", fmt.Sprintf("f%d", i))}, int64(i))
+	}
+	if len(dc.tasks) != 1 || dc.queued != 2 || len(dc.tasks[0].deltas) != 1 {
+		t.Errorf("queued %d tasks, %d changes", len(dc.tasks), dc.queued)
+	}
+
+	// too many changes are dropped for a rebuild, still answering the waiting tasks
+	waiting := &dirChecksumTask{rebuildDir: "/x", done: make(chan error, 1)}
+	dc.enqueue(waiting)
+	for i := 0; i < dirChecksumQueueLimit; i++ {
+		dc.queueChange(nil, &checksummedEntry{fullPath: util.NewFullPath(fmt.Sprintf("/d%d", i), "f")}, int64(i))
+	}
+	if len(dc.tasks) < 2 || dc.tasks[0].rebuildDir != "/" || dc.tasks[1] != waiting || dc.queued > 10 {
+		t.Errorf("queued %d tasks, %d changes", len(dc.tasks), dc.queued)
+	}
+}
@@ -0,0 +1,196 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The disk usage of a directory tree tells the logical size, i.e. the sum of the file sizes,
+// from the physical size, i.e. the bytes stored on the volume servers, before replication.
+// A hard linked file is counted once, and a chunk shared by the deduplicated or packed files
+// is counted once with its whole size, so the physical size is what deleting the tree releases
+// at most. The usage is also broken down by the collection, the owner, and the TTL class of the files.
+
+type DiskUsage struct {
+	Path              util.FullPath
+	Key               string // the collection, the owner, or the TTL class of a breakdown
+	LogicalSize       uint64
+	PhysicalSize      uint64
+	FileCount         uint64
+	DirectoryCount    uint64
+	ChunkCount        uint64
+	SharedChunkCount  uint64
+	HardLinkFileCount uint64
+}
+
+type diskUsageCounter struct {
+	DiskUsage
+	chunks    map[string]bool
+	hardLinks map[string]bool
+}
+
+func newDiskUsageCounter(p util.FullPath) *diskUsageCounter {
+	return &diskUsageCounter{
+		DiskUsage: DiskUsage{Path: p},
+		chunks:    make(map[string]bool),
+		hardLinks: make(map[string]bool),
+	}
+}
+
+func (c *diskUsageCounter) addDirectory() {
+	c.DirectoryCount++
+}
+
+func (c *diskUsageCounter) addFile(entry *Entry, dataChunks []*filer_pb.FileChunk) {
+	if len(entry.HardLinkId) > 0 {
+		if c.hardLinks[string(entry.HardLinkId)] {
+			c.HardLinkFileCount++
+			return
+		}
+		c.hardLinks[string(entry.HardLinkId)] = true
+	}
+	c.FileCount++
+	c.LogicalSize += entry.Size()
+	for _, chunk := range dataChunks {
+		fileId := chunk.GetFileIdString()
+		if c.chunks[fileId] {
+			c.SharedChunkCount++
+			continue
+		}
+		c.chunks[fileId] = true
+		c.ChunkCount++
+		if chunk.PackSize > 0 {
+			c.PhysicalSize += chunk.PackSize
+		} else {
+			c.PhysicalSize += chunk.Size
+		}
+	}
+}
+
+// DiskUsageOptions chooses what is counted besides the total of the directory tree
+type DiskUsageOptions struct {
+	Depth     int  // also count each child down to this depth, 1 for the direct children
+	Breakdown bool // also count the files by the collection, the owner, and the TTL class
+}
+
+type DiskUsageReport struct {
+	Total       *DiskUsage
+	Children    []*DiskUsage // by the path
+	Collections []*DiskUsage // by the key, the collection of the files
+	Owners      []*DiskUsage // by the key, the user name, or else the uid of the files
+	TtlClasses  []*DiskUsage // by the key, the TTL of the files, empty for none
+}
+
+// diskUsageGroups counts the usage of each child path, or of each key of a breakdown
+type diskUsageGroups map[string]*diskUsageCounter
+
+func (g diskUsageGroups) get(p util.FullPath, key string) *diskUsageCounter {
+	c := g[key]
+	if c == nil {
+		c = newDiskUsageCounter(p)
+		g[key] = c
+	}
+	return c
+}
+
+func (g diskUsageGroups) sorted(isBreakdown bool) (usages []*DiskUsage) {
+	keys := make([]string, 0, len(g))
+	for key := range g {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if isBreakdown {
+			g[key].Key = key
+		}
+		usages = append(usages, &g[key].DiskUsage)
+	}
+	return
+}
+
+// DiskUsage counts the usage of the directory tree, of each child down to the depth, and of each breakdown.
+// The chunks shared between the children or the breakdowns are counted in each of them, and once in the total.
+func (f *Filer) DiskUsage(ctx context.Context, dir util.FullPath, options DiskUsageOptions) (*DiskUsageReport, error) {
+	if strings.HasSuffix(string(dir), "/") && len(dir) > 1 {
+		dir = dir[:len(dir)-1]
+	}
+	root, err := f.FindEntry(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("find %s: %v", dir, err)
+	}
+
+	totalCounter := newDiskUsageCounter(dir)
+	children, collections, owners, ttlClasses := make(diskUsageGroups), make(diskUsageGroups), make(diskUsageGroups), make(diskUsageGroups)
+	addFile := func(entry *Entry, counters ...*diskUsageCounter) error {
+		dataChunks, _, resolveErr := ResolveChunkManifest(f.MasterClient.GetLookupFileIdFunction(), entry.GetChunks(), 0, math.MaxInt64)
+		if resolveErr != nil {
+			return fmt.Errorf("resolve chunk manifest of %s: %v", entry.FullPath, resolveErr)
+		}
+		totalCounter.addFile(entry, dataChunks)
+		if options.Breakdown {
+			counters = append(counters,
+				collections.get(dir, f.collectionOf(entry.FullPath, f.metaLogCollection)),
+				owners.get(dir, ownerOf(entry)),
+				ttlClasses.get(dir, needle.SecondsToTTL(entry.TtlSec)))
+		}
+		for _, c := range counters {
+			c.addFile(entry, dataChunks)
+		}
+		return nil
+	}
+
+	if !root.IsDirectory() {
+		if err = addFile(root); err != nil {
+			return nil, err
+		}
+	} else {
+		err = f.walkEntries(ctx, dir, func(entry *Entry) error {
+			// the children containing the entry, down to the depth
+			var counters []*diskUsageCounter
+			name := strings.TrimPrefix(string(entry.FullPath)[len(dir):], "/")
+			parts := strings.Split(name, "/")
+			for depth := 1; depth <= options.Depth && depth <= len(parts); depth++ {
+				childPath := dir.Child(strings.Join(parts[:depth], "/"))
+				child := children.get(childPath, string(childPath))
+				if entry.IsDirectory() && entry.FullPath == childPath {
+					continue
+				}
+				counters = append(counters, child)
+			}
+			if entry.IsDirectory() {
+				totalCounter.addDirectory()
+				for _, c := range counters {
+					c.addDirectory()
+				}
+				return nil
+			}
+			return addFile(entry, counters...)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &DiskUsageReport{
+		Total:       &totalCounter.DiskUsage,
+		Children:    children.sorted(false),
+		Collections: collections.sorted(true),
+		Owners:      owners.sorted(true),
+		TtlClasses:  ttlClasses.sorted(true),
+	}, nil
+}
+
+func ownerOf(entry *Entry) string {
+	if entry.UserName != "" {
+		return entry.UserName
+	}
+	return strconv.FormatUint(uint64(entry.Uid), 10)
+}
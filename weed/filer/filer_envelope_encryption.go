@@ -0,0 +1,294 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/kms"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The files under a directory configured with "fs.configure -encryptionKeyId" are encrypted,
+// and the cipher keys of their chunks are stored encrypted by a data key,
+// which is wrapped by the master key in the key manager, e.g. a KMS.
+// The entries keep the key id and the wrapped data key in their extended attributes.
+//
+// The cipher keys are wrapped when the entries are written to the filer store, and unwrapped
+// when read, so the clients of the filer see the cipher keys as before. Without the master key,
+// neither the filer store nor a metadata backup can decrypt the chunks.
+// The metadata changes streamed to the subscribers are not wrapped.
+//
+// A data key is reused for the writes in a short period, to save the calls to the key manager.
+// Re-wrapping the entries of a directory, e.g. after rotating the master key or changing the
+// key id, wraps them with new data keys.
+
+const (
+	ExtEncryptionKeyId   = "Seaweed-Encryption-Key-Id"
+	ExtEncryptionDataKey = "Seaweed-Encryption-Data-Key"
+
+	// the length of the cipher keys from util.GenCipherKey, the wrapped cipher keys are longer
+	plainCipherKeyLength   = 32
+	dataKeyReuseDuration   = 5 * time.Minute
+	maxUnwrappedDataKeys   = 10240
+	envelopeKeyCallTimeout = 10 * time.Second
+)
+
+type envelopeDataKey struct {
+	dataKey   util.CipherKey
+	wrapped   []byte
+	createdAt time.Time
+}
+
+type EnvelopeEncryption struct {
+	keyManager kms.KeyManager
+	keyIdOf    func(p util.FullPath) string
+
+	lock    sync.Mutex
+	current map[string]*envelopeDataKey
+	// data keys by their wrapped data key, to avoid calling the key manager on each read
+	unwrapped map[string]util.CipherKey
+}
+
+func NewEnvelopeEncryption(keyManager kms.KeyManager, keyIdOf func(p util.FullPath) string) *EnvelopeEncryption {
+	return &EnvelopeEncryption{
+		keyManager: keyManager,
+		keyIdOf:    keyIdOf,
+		current:    make(map[string]*envelopeDataKey),
+		unwrapped:  make(map[string]util.CipherKey),
+	}
+}
+
+// LoadEnvelopeEncryption loads the key manager under "filer.kms" in filer.toml.
+func (f *Filer) LoadEnvelopeEncryption(config util.Configuration) {
+	keyManager, err := kms.LoadKeyManager(config, "filer.kms.")
+	if err != nil {
+		glog.Fatalf("load key manager: %v", err)
+	}
+	if keyManager == nil {
+		return
+	}
+	f.EnvelopeEncryption = NewEnvelopeEncryption(keyManager, func(p util.FullPath) string {
+		return f.FilerConf.MatchStorageRule(string(p)).EncryptionKeyId
+	})
+	if fsw, ok := f.Store.(*FilerStoreWrapper); ok {
+		fsw.envelope = f.EnvelopeEncryption
+	}
+}
+
+// currentDataKey returns the data key for the new writes with the master key.
+func (e *EnvelopeEncryption) currentDataKey(ctx context.Context, keyId string) (*envelopeDataKey, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if current, found := e.current[keyId]; found && time.Since(current.createdAt) < dataKeyReuseDuration {
+		return current, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, envelopeKeyCallTimeout)
+	defer cancel()
+	dataKey := util.GenCipherKey()
+	wrapped, err := e.keyManager.WrapKey(ctx, keyId, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	current := &envelopeDataKey{dataKey: dataKey, wrapped: wrapped, createdAt: time.Now()}
+	e.current[keyId] = current
+	e.unwrapped[string(wrapped)] = dataKey
+	return current, nil
+}
+
+func (e *EnvelopeEncryption) unwrapDataKey(ctx context.Context, keyId string, wrapped []byte) (util.CipherKey, error) {
+	e.lock.Lock()
+	dataKey, found := e.unwrapped[string(wrapped)]
+	e.lock.Unlock()
+	if found {
+		return dataKey, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, envelopeKeyCallTimeout)
+	defer cancel()
+	dataKey, err := e.keyManager.UnwrapKey(ctx, keyId, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	e.lock.Lock()
+	if len(e.unwrapped) >= maxUnwrappedDataKeys {
+		e.unwrapped = make(map[string]util.CipherKey)
+	}
+	e.unwrapped[string(wrapped)] = dataKey
+	e.lock.Unlock()
+	return dataKey, nil
+}
+
+// wrapEntry returns the entry to store, with the plain cipher keys wrapped by the data key of
+// the master key configured for its path. The entry itself is not changed.
+func (e *EnvelopeEncryption) wrapEntry(ctx context.Context, entry *Entry) (*Entry, error) {
+
+	keyId := e.keyIdOf(entry.FullPath)
+	storedKeyId, wrappedDataKey := entry.Extended[ExtEncryptionKeyId], entry.Extended[ExtEncryptionDataKey]
+	if keyId == "" && storedKeyId == nil {
+		return entry, nil
+	}
+
+	var hasCipherKey, hasWrappedCipherKey bool
+	for _, chunk := range entry.GetChunks() {
+		hasCipherKey = hasCipherKey || len(chunk.CipherKey) > 0
+		hasWrappedCipherKey = hasWrappedCipherKey || len(chunk.CipherKey) > plainCipherKeyLength
+	}
+
+	wrapped := entry.ShallowClone()
+	wrapped.Extended = make(map[string][]byte, len(entry.Extended))
+	for k, v := range entry.Extended {
+		wrapped.Extended[k] = v
+	}
+
+	var dataKey util.CipherKey
+	switch {
+	case hasWrappedCipherKey:
+		// the entry was read when its data key could not be unwrapped, so keep its data key
+		if storedKeyId == nil {
+			return nil, fmt.Errorf("%s has wrapped cipher keys without data key", entry.FullPath)
+		}
+		var err error
+		if dataKey, err = e.unwrapDataKey(ctx, string(storedKeyId), wrappedDataKey); err != nil {
+			glog.Warningf("keep plain cipher keys of %s: %v", entry.FullPath, err)
+			return wrapped, nil
+		}
+	case keyId == "" || !hasCipherKey:
+		delete(wrapped.Extended, ExtEncryptionKeyId)
+		delete(wrapped.Extended, ExtEncryptionDataKey)
+		return wrapped, nil
+	default:
+		current, err := e.currentDataKey(ctx, keyId)
+		if err != nil {
+			return nil, fmt.Errorf("data key of %s: %v", keyId, err)
+		}
+		dataKey = current.dataKey
+		wrapped.Extended[ExtEncryptionKeyId] = []byte(keyId)
+		wrapped.Extended[ExtEncryptionDataKey] = current.wrapped
+	}
+
+	wrapped.Chunks = make([]*filer_pb.FileChunk, 0, len(entry.GetChunks()))
+	for _, chunk := range entry.GetChunks() {
+		if len(chunk.CipherKey) == plainCipherKeyLength {
+			cipherKey, err := util.Encrypt(chunk.CipherKey, dataKey)
+			if err != nil {
+				return nil, fmt.Errorf("wrap cipher key of %s: %v", entry.FullPath, err)
+			}
+			chunk = proto.Clone(chunk).(*filer_pb.FileChunk)
+			chunk.CipherKey = cipherKey
+		}
+		wrapped.Chunks = append(wrapped.Chunks, chunk)
+	}
+	return wrapped, nil
+}
+
+// unwrapEntry decrypts the wrapped cipher keys of the entry read from the filer store.
+func (e *EnvelopeEncryption) unwrapEntry(ctx context.Context, entry *Entry) error {
+
+	keyId, wrappedDataKey := entry.Extended[ExtEncryptionKeyId], entry.Extended[ExtEncryptionDataKey]
+	if keyId == nil {
+		return nil
+	}
+
+	dataKey, err := e.unwrapDataKey(ctx, string(keyId), wrappedDataKey)
+	if err != nil {
+		return fmt.Errorf("unwrap data key of %s with %s: %v", entry.FullPath, keyId, err)
+	}
+
+	for _, chunk := range entry.GetChunks() {
+		if len(chunk.CipherKey) <= plainCipherKeyLength {
+			continue
+		}
+		cipherKey, err := util.Decrypt(chunk.CipherKey, dataKey)
+		if err != nil {
+			return fmt.Errorf("unwrap cipher key of %s: %v", entry.FullPath, err)
+		}
+		chunk.CipherKey = cipherKey
+	}
+	return nil
+}
+
+func (fsw *FilerStoreWrapper) maybeWrapCipherKeys(ctx context.Context, entry *Entry) (*Entry, error) {
+	if fsw.envelope == nil {
+		return entry, nil
+	}
+	return fsw.envelope.wrapEntry(ctx, entry)
+}
+
+func (fsw *FilerStoreWrapper) maybeUnwrapCipherKeys(ctx context.Context, entry *Entry) {
+	if fsw.envelope == nil {
+		return
+	}
+	if err := fsw.envelope.unwrapEntry(ctx, entry); err != nil {
+		glog.Errorf("%v", err)
+	}
+}
+
+// RewrapEncryptionKeys wraps the cipher keys of the files under the directory again with new data keys,
+// by the master keys currently configured for their paths, e.g. after the master key is rotated.
+func (f *Filer) RewrapEncryptionKeys(ctx context.Context, dir util.FullPath) (rewrappedCount int64, err error) {
+	if f.EnvelopeEncryption == nil {
+		return 0, fmt.Errorf("no key manager is configured")
+	}
+
+	e := f.EnvelopeEncryption
+	e.lock.Lock()
+	e.current = make(map[string]*envelopeDataKey)
+	e.lock.Unlock()
+
+	err = f.rewrapTree(ctx, dir, &rewrappedCount)
+	return
+}
+
+func (f *Filer) rewrapTree(ctx context.Context, dir util.FullPath, rewrappedCount *int64) error {
+
+	lastFileName := ""
+	for {
+		var entries []*Entry
+		_, err := f.StreamListDirectoryEntries(ctx, dir, lastFileName, false, PaginationSize, "", "", "", func(entry *Entry) bool {
+			entries = append(entries, entry)
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("list %s: %v", dir, err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDirectory() {
+				if entry.FullPath == SystemDir {
+					continue
+				}
+				if err = f.rewrapTree(ctx, entry.FullPath, rewrappedCount); err != nil {
+					return err
+				}
+				continue
+			}
+			if entry.Extended[ExtEncryptionKeyId] == nil && f.EnvelopeEncryption.keyIdOf(entry.FullPath) == "" {
+				continue
+			}
+			for _, chunk := range entry.GetChunks() {
+				if len(chunk.CipherKey) > plainCipherKeyLength {
+					return fmt.Errorf("%s has cipher keys not unwrapped", entry.FullPath)
+				}
+			}
+			// the store wraps the cipher keys again, without any metadata change for the clients
+			if err = f.Store.UpdateEntry(ctx, entry); err != nil {
+				return fmt.Errorf("rewrap %s: %v", entry.FullPath, err)
+			}
+			*rewrappedCount++
+		}
+
+		if len(entries) < PaginationSize {
+			return nil
+		}
+		lastFileName = entries[len(entries)-1].Name()
+	}
+}
@@ -0,0 +1,82 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/kms"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestEnvelopeEncryption(t *testing.T) {
+	keyManager, err := kms.NewLocalKeyManager([]string{
+		"acme:" + base64.StdEncoding.EncodeToString(util.GenCipherKey()),
+	})
+	if err != nil {
+		t.Fatalf("key manager: %v", err)
+	}
+	e := NewEnvelopeEncryption(keyManager, func(p util.FullPath) string {
+		if strings.HasPrefix(string(p), "/acme/") {
+			return "acme"
+		}
+		return ""
+	})
+	ctx := context.Background()
+
+	cipherKey := util.GenCipherKey()
+	entry := &Entry{
+		FullPath: "/acme/a.txt",
+		Chunks:   []*filer_pb.FileChunk{{FileId: "1,01", CipherKey: cipherKey}, {FileId: "1,02"}},
+	}
+
+	wrapped, err := e.wrapEntry(ctx, entry)
+	if err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	if !bytes.Equal(entry.Chunks[0].CipherKey, cipherKey) || entry.Extended != nil {
+		t.Errorf("the entry to write is changed")
+	}
+	if string(wrapped.Extended[ExtEncryptionKeyId]) != "acme" || len(wrapped.Chunks[0].CipherKey) <= plainCipherKeyLength {
+		t.Fatalf("not wrapped: %+v", wrapped)
+	}
+	if wrapped.Chunks[1].CipherKey != nil {
+		t.Errorf("unencrypted chunk has cipher key")
+	}
+
+	// already wrapped cipher keys, read without the key manager, are not wrapped again
+	again, err := e.wrapEntry(ctx, wrapped)
+	if err != nil || !bytes.Equal(again.Chunks[0].CipherKey, wrapped.Chunks[0].CipherKey) {
+		t.Errorf("wrapped again: %v", err)
+	}
+
+	// a new key manager, e.g. after restart, unwraps the data key again
+	restarted := NewEnvelopeEncryption(keyManager, e.keyIdOf)
+	if err = restarted.unwrapEntry(ctx, wrapped); err != nil {
+		t.Fatalf("unwrap: %v", err)
+	}
+	if !bytes.Equal(wrapped.Chunks[0].CipherKey, cipherKey) {
+		t.Errorf("unwrapped cipher key differs")
+	}
+
+	// moved out of the encrypted folder
+	wrapped.FullPath = "/public/a.txt"
+	plain, err := e.wrapEntry(ctx, wrapped)
+	if err != nil {
+		t.Fatalf("wrap moved: %v", err)
+	}
+	if _, found := plain.Extended[ExtEncryptionKeyId]; found || !bytes.Equal(plain.Chunks[0].CipherKey, cipherKey) {
+		t.Errorf("moved entry is still wrapped")
+	}
+
+	// unknown master key
+	if err = restarted.unwrapEntry(ctx, &Entry{FullPath: "/acme/b.txt", Extended: map[string][]byte{
+		ExtEncryptionKeyId:   []byte("unknown"),
+		ExtEncryptionDataKey: []byte("x"),
+	}}); err == nil {
+		t.Errorf("unwrapped with unknown master key")
+	}
+}
@@ -0,0 +1,223 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// Each hard linked entry keeps its HardLinkId, and the shared attributes and chunks are kept
+// in the kv store under that id, with the count of the linked entries. A wrong count leaks the
+// shared content, or deletes it while still linked. CheckHardLinks walks the whole tree to count
+// the actual references, since a hard link may be referenced from anywhere.
+
+var ErrUnsupportedHardLinkListing = errors.New("unsupported hard link listing")
+
+// HardLinkListingStore is implemented by the stores able to list all hard links in the kv store,
+// so the orphaned hard links, without any linked entry, can be found.
+type HardLinkListingStore interface {
+	ListHardLinks(ctx context.Context, eachHardLinkFn func(hardLinkId HardLinkId, entry *Entry) bool) error
+}
+
+func (fsw *FilerStoreWrapper) ListHardLinks(ctx context.Context, eachHardLinkFn func(hardLinkId HardLinkId, entry *Entry) bool) error {
+	listingStore, ok := fsw.getDefaultStore().(HardLinkListingStore)
+	if !ok {
+		return ErrUnsupportedHardLinkListing
+	}
+	return listingStore.ListHardLinks(ctx, eachHardLinkFn)
+}
+
+type HardLinkProblemKind string
+
+const (
+	// the kv store misses the hard link of the linked entries
+	HardLinkDangling HardLinkProblemKind = "dangling"
+	// the count differs from the actual linked entries
+	HardLinkMiscounted HardLinkProblemKind = "miscounted"
+	// no entry links to the hard link
+	HardLinkOrphaned HardLinkProblemKind = "orphaned"
+)
+
+type HardLinkProblem struct {
+	Kind       HardLinkProblemKind
+	HardLinkId HardLinkId
+	Paths      []util.FullPath
+	Counter    int32
+	References int32
+	// the size of the chunks, for orphaned hard links
+	ReclaimableBytes uint64
+	Repaired         bool
+}
+
+type HardLinkReport struct {
+	HardLinks        int64
+	Dangling         int64
+	Miscounted       int64
+	Orphaned         int64
+	ReclaimableBytes uint64
+	Repaired         int64
+	// false if the store can not list the hard links to find the orphaned ones
+	OrphansChecked bool
+}
+
+type hardLinkReferences struct {
+	entry *Entry
+	paths []util.FullPath
+}
+
+// CheckHardLinks validates the counts of all hard links. With repair, it fixes the counts,
+// restores the dangling hard links from their linked entries, and deletes the orphaned hard
+// links with their chunks. It should run when no hard links are being created or moved,
+// since a hard link changed during the walk may be taken as broken.
+func (f *Filer) CheckHardLinks(ctx context.Context, repair bool, eachProblemFn func(problem *HardLinkProblem) error) (report *HardLinkReport, err error) {
+
+	report = &HardLinkReport{}
+
+	// list the stored hard links before the walk, so the ones created during the walk are not orphaned
+	stored := make(map[string]*Entry)
+	err = f.Store.ListHardLinks(ctx, func(hardLinkId HardLinkId, entry *Entry) bool {
+		stored[string(hardLinkId)] = entry
+		return true
+	})
+	if err != nil && err != ErrUnsupportedHardLinkListing {
+		return nil, fmt.Errorf("list hard links: %v", err)
+	}
+	report.OrphansChecked = err == nil
+
+	references := make(map[string]*hardLinkReferences)
+	if err = f.collectHardLinks(ctx, "/", references); err != nil {
+		return nil, err
+	}
+
+	for id, refs := range references {
+		report.HardLinks++
+		hardLinkId := HardLinkId(id)
+		problem := &HardLinkProblem{
+			HardLinkId: hardLinkId,
+			Paths:      refs.paths,
+			References: int32(len(refs.paths)),
+		}
+
+		value, kvErr := f.Store.KvGet(ctx, hardLinkId)
+		if kvErr == ErrKvNotFound {
+			problem.Kind = HardLinkDangling
+			report.Dangling++
+		} else if kvErr != nil {
+			return nil, fmt.Errorf("read hard link %x: %v", hardLinkId, kvErr)
+		} else {
+			shared := &Entry{}
+			if decodeErr := shared.DecodeAttributesAndChunks(value); decodeErr != nil {
+				return nil, fmt.Errorf("decode hard link %x: %v", hardLinkId, decodeErr)
+			}
+			if shared.HardLinkCounter == problem.References {
+				continue
+			}
+			problem.Kind = HardLinkMiscounted
+			problem.Counter = shared.HardLinkCounter
+			report.Miscounted++
+			// keep the shared content, but fix the count
+			refs.entry = shared
+		}
+
+		if repair {
+			refs.entry.HardLinkId = hardLinkId
+			refs.entry.HardLinkCounter = problem.References
+			if err = f.putHardLink(ctx, refs.entry); err != nil {
+				return nil, fmt.Errorf("repair hard link %x: %v", hardLinkId, err)
+			}
+			problem.Repaired = true
+			report.Repaired++
+		}
+		if err = eachProblemFn(problem); err != nil {
+			return nil, err
+		}
+	}
+
+	for id, shared := range stored {
+		if _, found := references[id]; found {
+			continue
+		}
+		hardLinkId := HardLinkId(id)
+		problem := &HardLinkProblem{
+			Kind:             HardLinkOrphaned,
+			HardLinkId:       hardLinkId,
+			Counter:          shared.HardLinkCounter,
+			ReclaimableBytes: chunksSize(shared.GetChunks()),
+		}
+		report.Orphaned++
+		report.ReclaimableBytes += problem.ReclaimableBytes
+		if repair {
+			if err = f.Store.KvDelete(ctx, hardLinkId); err != nil {
+				return nil, fmt.Errorf("delete hard link %x: %v", hardLinkId, err)
+			}
+			f.DeleteChunks(shared.GetChunks())
+			problem.Repaired = true
+			report.Repaired++
+		}
+		if err = eachProblemFn(problem); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// putHardLink writes the shared attributes and chunks of the entry, with its count.
+func (f *Filer) putHardLink(ctx context.Context, entry *Entry) error {
+	blob, err := entry.EncodeAttributesAndChunks()
+	if err != nil {
+		return err
+	}
+	return f.Store.KvPut(ctx, entry.HardLinkId, blob)
+}
+
+func (f *Filer) collectHardLinks(ctx context.Context, dir util.FullPath, references map[string]*hardLinkReferences) error {
+
+	lastFileName := ""
+	for {
+		var entries []*Entry
+		_, err := f.StreamListDirectoryEntries(ctx, dir, lastFileName, false, PaginationSize, "", "", "", func(entry *Entry) bool {
+			entries = append(entries, entry)
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("list %s: %v", dir, err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDirectory() {
+				if entry.FullPath == SystemDir {
+					continue
+				}
+				if err = f.collectHardLinks(ctx, entry.FullPath, references); err != nil {
+					return err
+				}
+				continue
+			}
+			if len(entry.HardLinkId) == 0 {
+				continue
+			}
+			refs, found := references[string(entry.HardLinkId)]
+			if !found {
+				refs = &hardLinkReferences{entry: entry}
+				references[string(entry.HardLinkId)] = refs
+			}
+			refs.paths = append(refs.paths, entry.FullPath)
+		}
+
+		if len(entries) < PaginationSize {
+			return nil
+		}
+		lastFileName = entries[len(entries)-1].Name()
+	}
+}
+
+func chunksSize(chunks []*filer_pb.FileChunk) (size uint64) {
+	for _, chunk := range chunks {
+		size += chunk.Size
+	}
+	return
+}
@@ -0,0 +1,148 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+type HookOperation string
+
+const (
+	HookCreate HookOperation = "create"
+	HookUpdate HookOperation = "update"
+	HookDelete HookOperation = "delete"
+	HookRename HookOperation = "rename"
+)
+
+// OperationHook is called before and after files and directories are created, updated, deleted or renamed.
+// It is implemented by a gRPC sidecar, see GrpcOperationHook, or by a Go plugin, see LoadPluginOperationHook.
+type OperationHook interface {
+	GetName() string
+	// BeforeOperation rejects the operation with an error, or rewrites the new entry of a create or an update.
+	BeforeOperation(ctx context.Context, req *filer_pb.FilerHookRequest) (*filer_pb.FilerHookResponse, error)
+	// AfterOperation is called after the operation succeeded, e.g. to trigger side effects.
+	AfterOperation(ctx context.Context, req *filer_pb.FilerHookRequest)
+}
+
+type skipOperationHooksKey struct{}
+
+// WithoutOperationHooks marks the internal changes of an operation already checked by the hooks,
+// e.g. each entry moved by a rename.
+func WithoutOperationHooks(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipOperationHooksKey{}, true)
+}
+
+func (f *Filer) isHooked(ctx context.Context, p util.FullPath) bool {
+	if len(f.OperationHooks) == 0 || ctx.Value(skipOperationHooksKey{}) != nil {
+		return false
+	}
+	return !strings.HasPrefix(string(p), DirectoryEtcSeaweedFS) && !strings.HasPrefix(string(p), SystemLogDir)
+}
+
+func newHookRequest(op HookOperation, oldEntry, newEntry *Entry) *filer_pb.FilerHookRequest {
+	req := &filer_pb.FilerHookRequest{
+		Operation:         string(op),
+		EventNotification: &filer_pb.EventNotification{},
+	}
+	if oldEntry != nil {
+		req.Directory, _ = oldEntry.FullPath.DirAndName()
+		req.EventNotification.OldEntry = oldEntry.ToProtoEntry()
+	}
+	if newEntry != nil {
+		newParent, _ := newEntry.FullPath.DirAndName()
+		if req.Directory == "" {
+			req.Directory = newParent
+		}
+		req.EventNotification.NewEntry = newEntry.ToProtoEntry()
+		req.EventNotification.NewParentPath = newParent
+	}
+	return req
+}
+
+// BeforeOperation asks the hooks whether the operation is allowed.
+// For a create or an update, the returned entry is the new entry, as rewritten by the hooks.
+func (f *Filer) BeforeOperation(ctx context.Context, op HookOperation, oldEntry, newEntry *Entry) (*Entry, error) {
+	p := newEntry
+	if p == nil {
+		p = oldEntry
+	}
+	if !f.isHooked(ctx, p.FullPath) {
+		return newEntry, nil
+	}
+	for _, hook := range f.OperationHooks {
+		req := newHookRequest(op, oldEntry, newEntry)
+		resp, err := hook.BeforeOperation(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("hook %s: %v", hook.GetName(), err)
+		}
+		if resp.Error != "" {
+			return nil, fmt.Errorf("%s %s rejected by hook %s: %s", op, p.FullPath, hook.GetName(), resp.Error)
+		}
+		if resp.NewEntry != nil && newEntry != nil && (op == HookCreate || op == HookUpdate) {
+			// the hooks can not rename the entry
+			resp.NewEntry.Name = newEntry.Name()
+			newEntry = FromPbEntry(req.EventNotification.NewParentPath, resp.NewEntry)
+		}
+	}
+	return newEntry, nil
+}
+
+// applyBeforeOperation rewrites the entry in place, if changed by the hooks.
+func (f *Filer) applyBeforeOperation(ctx context.Context, op HookOperation, oldEntry, entry *Entry) error {
+	hookedEntry, err := f.BeforeOperation(ctx, op, oldEntry, entry)
+	if err != nil {
+		return err
+	}
+	if hookedEntry != entry {
+		*entry = *hookedEntry
+	}
+	return nil
+}
+
+// AfterOperation notifies the hooks of the finished operation.
+func (f *Filer) AfterOperation(ctx context.Context, op HookOperation, oldEntry, newEntry *Entry) {
+	if deferUntilCommit(ctx, func() { f.AfterOperation(ctx, op, oldEntry, newEntry) }) {
+		return
+	}
+	p := newEntry
+	if p == nil {
+		p = oldEntry
+	}
+	if !f.isHooked(ctx, p.FullPath) {
+		return
+	}
+	for _, hook := range f.OperationHooks {
+		hook.AfterOperation(ctx, newHookRequest(op, oldEntry, newEntry))
+	}
+}
+
+// LoadOperationHooks sets up the hooks configured in filer.toml.
+func (f *Filer) LoadOperationHooks(config util.Configuration) {
+	if config.GetBool("filer.hook.grpc.enabled") {
+		config.SetDefault("filer.hook.grpc.path_prefix", "/")
+		config.SetDefault("filer.hook.grpc.timeout_seconds", 5)
+		hook := NewGrpcOperationHook(
+			config.GetString("filer.hook.grpc.address"),
+			config.GetString("filer.hook.grpc.path_prefix"),
+			config.GetBool("filer.hook.grpc.fail_open"),
+			config.GetInt("filer.hook.grpc.timeout_seconds"),
+			f.GrpcDialOption,
+		)
+		glog.V(0).Infof("configured operation hook %s", hook.GetName())
+		f.OperationHooks = append(f.OperationHooks, hook)
+	}
+	if config.GetBool("filer.hook.plugin.enabled") {
+		pluginPath := config.GetString("filer.hook.plugin.path")
+		hook, err := LoadPluginOperationHook(pluginPath)
+		if err != nil {
+			glog.Fatalf("load operation hook plugin %s: %v", pluginPath, err)
+		}
+		glog.V(0).Infof("configured operation hook %s", hook.GetName())
+		f.OperationHooks = append(f.OperationHooks, hook)
+	}
+}
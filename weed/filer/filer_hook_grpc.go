@@ -0,0 +1,87 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"google.golang.org/grpc"
+)
+
+// GrpcOperationHook calls a sidecar implementing the filer_pb.SeaweedFilerHook service.
+type GrpcOperationHook struct {
+	address        string
+	pathPrefix     string
+	failOpen       bool
+	timeout        time.Duration
+	grpcDialOption grpc.DialOption
+}
+
+func NewGrpcOperationHook(address, pathPrefix string, failOpen bool, timeoutSeconds int, grpcDialOption grpc.DialOption) *GrpcOperationHook {
+	return &GrpcOperationHook{
+		address:        address,
+		pathPrefix:     pathPrefix,
+		failOpen:       failOpen,
+		timeout:        time.Duration(timeoutSeconds) * time.Second,
+		grpcDialOption: grpcDialOption,
+	}
+}
+
+func (h *GrpcOperationHook) GetName() string {
+	return "grpc:" + h.address
+}
+
+func (h *GrpcOperationHook) isMatched(req *filer_pb.FilerHookRequest) bool {
+	if strings.HasPrefix(req.Directory+"/", h.pathPrefix) {
+		return true
+	}
+	return req.EventNotification.NewParentPath != "" && strings.HasPrefix(req.EventNotification.NewParentPath+"/", h.pathPrefix)
+}
+
+func (h *GrpcOperationHook) withClient(fn func(client filer_pb.SeaweedFilerHookClient) error) error {
+	return pb.WithGrpcClient(false, 0, func(grpcConnection *grpc.ClientConn) error {
+		return fn(filer_pb.NewSeaweedFilerHookClient(grpcConnection))
+	}, h.address, false, h.grpcDialOption)
+}
+
+func (h *GrpcOperationHook) BeforeOperation(ctx context.Context, req *filer_pb.FilerHookRequest) (resp *filer_pb.FilerHookResponse, err error) {
+	if !h.isMatched(req) {
+		return &filer_pb.FilerHookResponse{}, nil
+	}
+	err = h.withClient(func(client filer_pb.SeaweedFilerHookClient) error {
+		ctx, cancel := context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+		resp, err = client.BeforeOperation(ctx, req)
+		return err
+	})
+	if err != nil {
+		if h.failOpen {
+			glog.Warningf("hook %s is unavailable, allow %s under %s: %v", h.GetName(), req.Operation, req.Directory, err)
+			return &filer_pb.FilerHookResponse{}, nil
+		}
+		return nil, fmt.Errorf("unavailable: %v", err)
+	}
+	return resp, nil
+}
+
+func (h *GrpcOperationHook) AfterOperation(ctx context.Context, req *filer_pb.FilerHookRequest) {
+	if !h.isMatched(req) {
+		return
+	}
+	// the operation is already done, do not let the client wait for the side effects
+	go func() {
+		err := h.withClient(func(client filer_pb.SeaweedFilerHookClient) error {
+			ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+			defer cancel()
+			_, err := client.AfterOperation(ctx, req)
+			return err
+		})
+		if err != nil {
+			glog.Warningf("hook %s after %s under %s: %v", h.GetName(), req.Operation, req.Directory, err)
+		}
+	}()
+}
@@ -0,0 +1,30 @@
+package filer
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// PluginOperationHookSymbol is the exported variable of a Go plugin, implementing OperationHook.
+// The plugin has to be built with the same Go version and the same SeaweedFS source:
+//
+//	var OperationHook filer.OperationHook = &myHook{}
+//
+//	go build -buildmode=plugin -o hook.so ./myhook
+const PluginOperationHookSymbol = "OperationHook"
+
+func LoadPluginOperationHook(pluginPath string) (OperationHook, error) {
+	p, err := plugin.Open(pluginPath)
+	if err != nil {
+		return nil, err
+	}
+	symbol, err := p.Lookup(PluginOperationHookSymbol)
+	if err != nil {
+		return nil, err
+	}
+	hook, ok := symbol.(*OperationHook)
+	if !ok || *hook == nil {
+		return nil, fmt.Errorf("%s is %T, not filer.OperationHook", PluginOperationHookSymbol, symbol)
+	}
+	return *hook, nil
+}
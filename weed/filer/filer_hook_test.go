@@ -0,0 +1,57 @@
+package filer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/stretchr/testify/assert"
+)
+
+type namingHook struct {
+	afterCount int
+}
+
+func (h *namingHook) GetName() string {
+	return "naming"
+}
+
+func (h *namingHook) BeforeOperation(ctx context.Context, req *filer_pb.FilerHookRequest) (*filer_pb.FilerHookResponse, error) {
+	entry := req.EventNotification.NewEntry
+	if entry != nil && strings.Contains(entry.Name, " ") {
+		return &filer_pb.FilerHookResponse{Error: "no spaces in names"}, nil
+	}
+	if entry != nil && req.Operation == string(HookCreate) {
+		entry.Attributes.Mime = "application/x-checked"
+		return &filer_pb.FilerHookResponse{NewEntry: entry}, nil
+	}
+	return &filer_pb.FilerHookResponse{}, nil
+}
+
+func (h *namingHook) AfterOperation(ctx context.Context, req *filer_pb.FilerHookRequest) {
+	h.afterCount++
+}
+
+func TestOperationHooks(t *testing.T) {
+	hook := &namingHook{}
+	f := &Filer{OperationHooks: []OperationHook{hook}}
+	ctx := context.Background()
+
+	_, err := f.BeforeOperation(ctx, HookCreate, nil, &Entry{FullPath: "/dir/bad name"})
+	assert.NotNil(t, err)
+
+	entry, err := f.BeforeOperation(ctx, HookCreate, nil, &Entry{FullPath: "/dir/good_name"})
+	assert.Nil(t, err)
+	assert.Equal(t, "application/x-checked", entry.Mime)
+	assert.Equal(t, "/dir/good_name", string(entry.FullPath))
+
+	// system entries and internal changes skip the hooks
+	_, err = f.BeforeOperation(ctx, HookCreate, nil, &Entry{FullPath: DirectoryEtcSeaweedFS + "/a b"})
+	assert.Nil(t, err)
+	_, err = f.BeforeOperation(WithoutOperationHooks(ctx), HookCreate, nil, &Entry{FullPath: "/dir/bad name"})
+	assert.Nil(t, err)
+
+	f.AfterOperation(ctx, HookDelete, entry, nil)
+	assert.Equal(t, 1, hook.afterCount)
+}
@@ -0,0 +1,76 @@
+package filer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const listCursorVersion = 1
+
+// ListCursor marks the position of a paginated directory listing.
+// It only refers to the last listed file name, not to any filer or store state,
+// so it stays valid across filer restarts and can be passed to any filer of the cluster.
+type ListCursor struct {
+	Version            int    `json:"v"`
+	Directory          string `json:"d"`
+	LastFileName       string `json:"l"`
+	Prefix             string `json:"p,omitempty"`
+	NamePattern        string `json:"n,omitempty"`
+	NamePatternExclude string `json:"x,omitempty"`
+	// for the listings sorted by mtime or size, the sort key of the last listed file
+	SortBy     SortBy `json:"s,omitempty"`
+	Descending bool   `json:"r,omitempty"`
+	SortKey    uint64 `json:"k,omitempty"`
+}
+
+func NewListCursor(dir util.FullPath, lastFileName, prefix, namePattern, namePatternExclude string) *ListCursor {
+	return &ListCursor{
+		Version:            listCursorVersion,
+		Directory:          string(dir),
+		LastFileName:       lastFileName,
+		Prefix:             prefix,
+		NamePattern:        namePattern,
+		NamePatternExclude: namePatternExclude,
+	}
+}
+
+// Encode returns the opaque string form of the cursor.
+func (c *ListCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// After returns the cursor for continuing the listing after the file name.
+func (c *ListCursor) After(lastFileName string) *ListCursor {
+	next := *c
+	next.LastFileName = lastFileName
+	return &next
+}
+
+// AfterSorted returns the cursor for continuing a sorted listing after the file name and its sort key.
+func (c *ListCursor) AfterSorted(lastFileName string, sortKey uint64) *ListCursor {
+	next := c.After(lastFileName)
+	next.SortKey = sortKey
+	return next
+}
+
+func DecodeListCursor(cursor string) (*ListCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid list cursor: %v", err)
+	}
+	c := &ListCursor{}
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("invalid list cursor: %v", err)
+	}
+	if c.Version != listCursorVersion {
+		return nil, fmt.Errorf("unsupported list cursor version %d", c.Version)
+	}
+	if c.Directory == "" {
+		return nil, fmt.Errorf("invalid list cursor: missing directory")
+	}
+	return c, nil
+}
@@ -0,0 +1,22 @@
+package filer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListCursor(t *testing.T) {
+	cursor := NewListCursor("/some/dir", "", "abc", "*.jpg", "")
+	next := cursor.After("abc123.jpg")
+	assert.Equal(t, "", cursor.LastFileName)
+
+	decoded, err := DecodeListCursor(next.Encode())
+	assert.Nil(t, err)
+	assert.Equal(t, next, decoded)
+
+	_, err = DecodeListCursor("not a cursor")
+	assert.NotNil(t, err)
+	_, err = DecodeListCursor(NewListCursor("", "x", "", "", "").Encode())
+	assert.NotNil(t, err)
+}
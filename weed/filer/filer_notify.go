@@ -18,6 +18,9 @@ import (
 )
 
 func (f *Filer) NotifyUpdateEvent(ctx context.Context, oldEntry, newEntry *Entry, deleteChunks, isFromOtherCluster bool, signatures []int32) {
+	if deferUntilCommit(ctx, func() { f.NotifyUpdateEvent(ctx, oldEntry, newEntry, deleteChunks, isFromOtherCluster, signatures) }) {
+		return
+	}
 	var fullpath string
 	if oldEntry != nil {
 		fullpath = string(oldEntry.FullPath)
@@ -65,6 +68,19 @@ func (f *Filer) NotifyUpdateEvent(ctx context.Context, oldEntry, newEntry *Entry
 
 	f.logMetaEvent(ctx, fullpath, eventNotification)
 
+	if f.DirChecksums != nil {
+		f.DirChecksums.onEvent(oldEntry, newEntry)
+	}
+	if f.Usage != nil {
+		f.Usage.onEvent(oldEntry, newEntry)
+	}
+	if f.XattrIndex != nil {
+		f.XattrIndex.onEvent(oldEntry, newEntry)
+	}
+	if f.ChunkAuditor != nil {
+		f.ChunkAuditor.onEvent(newEntry)
+	}
+
 }
 
 func (f *Filer) logMetaEvent(ctx context.Context, fullpath string, eventNotification *filer_pb.EventNotification) {
@@ -83,6 +99,7 @@ func (f *Filer) logMetaEvent(ctx context.Context, fullpath string, eventNotifica
 	}
 
 	f.LocalMetaLogBuffer.AddToBuffer([]byte(dir), data, event.TsNs)
+	recordWrite(ctx, event.TsNs)
 
 }
 
@@ -0,0 +1,126 @@
+package filer
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// MetadataEventFilter evaluates a CEL expression against metadata change events.
+//
+// The expression can refer to these variables:
+//
+//	path         full path of the entry, the new path for renames
+//	directory    parent directory of the entry
+//	name         entry name
+//	event        one of "create", "update", "delete", "rename"
+//	is_directory whether the entry is a directory
+//	size         file size in bytes
+//	mtime        modification time in unix seconds
+//	mime         mime type of the entry
+//	xattrs       extended attributes, as a map of string to string
+//
+// For example: `path.startsWith("/logs/") && size > 1024 && xattrs["user.team"] == "infra"`
+type MetadataEventFilter struct {
+	program cel.Program
+}
+
+var metadataEventFilterEnv *cel.Env
+
+func init() {
+	var err error
+	metadataEventFilterEnv, err = cel.NewEnv(
+		cel.Variable("path", cel.StringType),
+		cel.Variable("directory", cel.StringType),
+		cel.Variable("name", cel.StringType),
+		cel.Variable("event", cel.StringType),
+		cel.Variable("is_directory", cel.BoolType),
+		cel.Variable("size", cel.IntType),
+		cel.Variable("mtime", cel.IntType),
+		cel.Variable("mime", cel.StringType),
+		cel.Variable("xattrs", cel.MapType(cel.StringType, cel.StringType)),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("create metadata filter environment: %v", err))
+	}
+}
+
+func NewMetadataEventFilter(expression string) (*MetadataEventFilter, error) {
+	ast, issues := metadataEventFilterEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("compile filter %q: %v", expression, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("filter %q should return bool, but returns %v", expression, ast.OutputType())
+	}
+	program, err := metadataEventFilterEnv.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("build filter %q: %v", expression, err)
+	}
+	return &MetadataEventFilter{program: program}, nil
+}
+
+// Matches returns true if the event satisfies the filter expression.
+// Evaluation errors, e.g. a missing map key, are treated as not matching.
+func (f *MetadataEventFilter) Matches(dirPath string, eventNotification *filer_pb.EventNotification) bool {
+	out, _, err := f.program.Eval(metadataEventFilterVariables(dirPath, eventNotification))
+	if err != nil {
+		return false
+	}
+	matched, ok := out.Value().(bool)
+	return ok && matched
+}
+
+func metadataEventFilterVariables(dirPath string, eventNotification *filer_pb.EventNotification) map[string]interface{} {
+	entry := eventNotification.NewEntry
+	if entry == nil {
+		entry = eventNotification.OldEntry
+	}
+
+	directory := dirPath
+	event := "update"
+	switch {
+	case eventNotification.OldEntry == nil:
+		event = "create"
+	case eventNotification.NewEntry == nil:
+		event = "delete"
+	case eventNotification.NewParentPath != "" && (eventNotification.NewParentPath != dirPath || eventNotification.NewEntry.Name != eventNotification.OldEntry.Name):
+		event = "rename"
+	}
+	if eventNotification.NewEntry != nil && eventNotification.NewParentPath != "" {
+		directory = eventNotification.NewParentPath
+	}
+
+	vars := map[string]interface{}{
+		"path":         "",
+		"directory":    directory,
+		"name":         "",
+		"event":        event,
+		"is_directory": false,
+		"size":         int64(0),
+		"mtime":        int64(0),
+		"mime":         "",
+		"xattrs":       map[string]string{},
+	}
+	if entry == nil {
+		return vars
+	}
+
+	xattrs := make(map[string]string, len(entry.Extended))
+	for k, v := range entry.Extended {
+		xattrs[k] = string(v)
+	}
+	vars["path"] = string(util.NewFullPath(directory, entry.Name))
+	vars["name"] = entry.Name
+	vars["is_directory"] = entry.IsDirectory
+	vars["size"] = int64(FileSize(entry))
+	vars["xattrs"] = xattrs
+	if entry.Attributes != nil {
+		vars["mtime"] = entry.Attributes.Mtime
+		vars["mime"] = entry.Attributes.Mime
+	}
+	return vars
+}
@@ -0,0 +1,64 @@
+package filer
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestMetadataEventFilter(t *testing.T) {
+
+	logFile := &filer_pb.Entry{
+		Name: "app.log",
+		Attributes: &filer_pb.FuseAttributes{
+			FileSize: 4096,
+			Mtime:    1700000000,
+			Mime:     "text/plain",
+		},
+		Extended: map[string][]byte{
+			"user.team": []byte("infra"),
+		},
+	}
+
+	created := &filer_pb.EventNotification{NewEntry: logFile, NewParentPath: "/logs"}
+	deleted := &filer_pb.EventNotification{OldEntry: logFile}
+	renamed := &filer_pb.EventNotification{OldEntry: logFile, NewEntry: logFile, NewParentPath: "/archive"}
+
+	tests := []struct {
+		expression string
+		event      *filer_pb.EventNotification
+		expected   bool
+	}{
+		{`path == "/logs/app.log"`, created, true},
+		{`path.startsWith("/logs/") && size > 1024`, created, true},
+		{`size > 8192`, created, false},
+		{`xattrs["user.team"] == "infra"`, created, true},
+		{`xattrs["user.missing"] == "infra"`, created, false},
+		{`"user.team" in xattrs && mtime >= 1700000000`, created, true},
+		{`event == "create"`, created, true},
+		{`event == "delete" && name.endsWith(".log")`, deleted, true},
+		{`event == "rename" && directory == "/archive"`, renamed, true},
+		{`!is_directory && mime.startsWith("text/")`, created, true},
+	}
+
+	for _, test := range tests {
+		filter, err := NewMetadataEventFilter(test.expression)
+		if err != nil {
+			t.Fatalf("compile %s: %v", test.expression, err)
+		}
+		if matched := filter.Matches("/logs", test.event); matched != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.expression, test.expected, matched)
+		}
+	}
+
+}
+
+func TestMetadataEventFilterInvalid(t *testing.T) {
+
+	for _, expression := range []string{`size +`, `size + 1`, `unknown_field == 1`} {
+		if _, err := NewMetadataEventFilter(expression); err == nil {
+			t.Errorf("expected error compiling %s", expression)
+		}
+	}
+
+}
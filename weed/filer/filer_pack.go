@@ -0,0 +1,151 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"google.golang.org/protobuf/proto"
+)
+
+// Many small files can be packed into one shared chunk. Each of these files refers
+// to its own range of the shared chunk, starting at FileChunk.PackOffset.
+// A copied file shares the chunks of the original file in the same way, with a
+// PackOffset of 0 and a PackSize of the chunk size. The original file is not changed,
+// and its chunks are released the same way once they have references.
+// The number of files still referring to a shared chunk is kept in the filer store,
+// and the shared chunk is deleted when the last file releases it.
+// The filers sharing the store update the references under the cluster lock of the chunk.
+// The references are kept as an 8-byte count, followed by the dedupe key of a deduplicated chunk.
+
+var packReferenceLock sync.Mutex
+
+// lockPackReferences locks the references to the shared chunk across the filers.
+// Without the address of the filer, e.g. in the tools, the lock is only within the process.
+func (f *Filer) lockPackReferences(fileId string) (unlock func()) {
+	if f.Dlm == nil || f.Dlm.Host == "" {
+		packReferenceLock.Lock()
+		return packReferenceLock.Unlock
+	}
+	lock := cluster.NewLockClient(f.GrpcDialOption, f.Dlm.Host).NewLock(string(packReferenceKey(fileId)), string(f.Dlm.Host))
+	return func() {
+		if err := lock.StopLock(); err != nil {
+			glog.V(1).Infof("unlock pack %s references: %v", fileId, err)
+		}
+	}
+}
+
+func packReferenceKey(fileId string) []byte {
+	return []byte("pack." + fileId)
+}
+
+func IsPackedChunk(chunk *filer_pb.FileChunk) bool {
+	return chunk.PackSize > 0
+}
+
+// SetPackReferences records how many files refer to the shared chunk.
+func (f *Filer) SetPackReferences(ctx context.Context, fileId string, count int) error {
+	return f.setPackReferences(ctx, fileId, count, nil)
+}
+
+func (f *Filer) setPackReferences(ctx context.Context, fileId string, count int, dedupeKey []byte) error {
+	defer f.lockPackReferences(fileId)()
+	value := make([]byte, 8+len(dedupeKey))
+	util.Uint64toBytes(value, uint64(count))
+	copy(value[8:], dedupeKey)
+	if err := f.Store.KvPut(ctx, packReferenceKey(fileId), value); err != nil {
+		return fmt.Errorf("set pack %s references: %v", fileId, err)
+	}
+	return nil
+}
+
+// AddPackReferences adds more files referring to the shared chunk.
+func (f *Filer) AddPackReferences(ctx context.Context, fileId string, delta int) error {
+	defer f.lockPackReferences(fileId)()
+	key := packReferenceKey(fileId)
+	value, err := f.Store.KvGet(ctx, key)
+	if err != nil {
+		return fmt.Errorf("get pack %s references: %v", fileId, err)
+	}
+	util.Uint64toBytes(value, util.BytesToUint64(value[:8])+uint64(delta))
+	if err = f.Store.KvPut(ctx, key, value); err != nil {
+		return fmt.Errorf("add pack %s references: %v", fileId, err)
+	}
+	return nil
+}
+
+// ShareChunk adds a reference to the chunk for one more file, and returns the chunk to share.
+// A chunk shared the first time also gets the reference of the file it belongs to.
+func (f *Filer) ShareChunk(ctx context.Context, chunk *filer_pb.FileChunk) (*filer_pb.FileChunk, error) {
+	fileId := chunk.GetFileIdString()
+	defer f.lockPackReferences(fileId)()
+	key := packReferenceKey(fileId)
+	value, err := f.Store.KvGet(ctx, key)
+	if err == ErrKvNotFound && !IsPackedChunk(chunk) {
+		value, err = make([]byte, 8), nil
+		util.Uint64toBytes(value, 1)
+	}
+	if err != nil {
+		// a packed chunk without references is released by all its files
+		return nil, fmt.Errorf("get pack %s references: %v", fileId, err)
+	}
+	util.Uint64toBytes(value, util.BytesToUint64(value[:8])+1)
+	if err = f.Store.KvPut(ctx, key, value); err != nil {
+		return nil, fmt.Errorf("share pack %s: %v", fileId, err)
+	}
+	return AsSharedChunk(chunk), nil
+}
+
+// AsSharedChunk returns a copy of the whole chunk, to be shared by more files.
+func AsSharedChunk(chunk *filer_pb.FileChunk) *filer_pb.FileChunk {
+	shared := proto.Clone(chunk).(*filer_pb.FileChunk)
+	if !IsPackedChunk(shared) {
+		shared.PackOffset = 0
+		shared.PackSize = shared.Size
+	}
+	return shared
+}
+
+// ReleasePackedChunk drops one reference to the shared chunk,
+// and returns true if it was the last one.
+func (f *Filer) ReleasePackedChunk(ctx context.Context, fileId string) (isLast bool, err error) {
+	defer f.lockPackReferences(fileId)()
+	key := packReferenceKey(fileId)
+	value, err := f.Store.KvGet(ctx, key)
+	if err != nil {
+		// without the references, keep the shared chunk
+		return false, fmt.Errorf("get pack %s references: %v", fileId, err)
+	}
+	count := util.BytesToUint64(value[:8])
+	if count <= 1 {
+		if len(value) > 8 {
+			f.deleteDedupeKey(ctx, value[8:], fileId)
+		}
+		return true, f.Store.KvDelete(ctx, key)
+	}
+	util.Uint64toBytes(value, count-1)
+	return false, f.Store.KvPut(ctx, key, value)
+}
+
+// deleteChunk queues the chunk for deletion, or releases it if shared by packed files.
+func (f *Filer) deleteChunk(chunk *filer_pb.FileChunk) {
+	if !IsPackedChunk(chunk) {
+		// unless shared by the copies of the file
+		if _, err := f.Store.KvGet(context.Background(), packReferenceKey(chunk.GetFileIdString())); err == ErrKvNotFound {
+			f.fileIdDeletionQueue.EnQueue(chunk.GetFileIdString())
+			return
+		}
+	}
+	isLast, err := f.ReleasePackedChunk(context.Background(), chunk.GetFileIdString())
+	if err != nil {
+		glog.V(0).Infof("release packed chunk %s: %v", chunk.GetFileIdString(), err)
+		return
+	}
+	if isLast {
+		f.fileIdDeletionQueue.EnQueue(chunk.GetFileIdString())
+	}
+}
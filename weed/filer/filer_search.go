@@ -2,6 +2,7 @@ package filer
 
 import (
 	"context"
+	"fmt"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 	"math"
 	"path/filepath"
@@ -101,3 +102,37 @@ func (f *Filer) doListValidEntries(ctx context.Context, p util.FullPath, startFi
 	}
 	return
 }
+
+// walkEntries visits all entries under the directory, each directory before its entries.
+func (f *Filer) walkEntries(ctx context.Context, dir util.FullPath, fn func(entry *Entry) error) error {
+	dirs := []util.FullPath{dir}
+	for len(dirs) > 0 {
+		dir, dirs = dirs[len(dirs)-1], dirs[:len(dirs)-1]
+		lastFileName := ""
+		for {
+			var count int
+			var fnErr error
+			_, err := f.StreamListDirectoryEntries(ctx, dir, lastFileName, false, PaginationSize, "", "", "", func(entry *Entry) bool {
+				count++
+				lastFileName = entry.Name()
+				if fnErr = fn(entry); fnErr != nil {
+					return false
+				}
+				if entry.IsDirectory() {
+					dirs = append(dirs, entry.FullPath)
+				}
+				return true
+			})
+			if err != nil {
+				return fmt.Errorf("list %s: %v", dir, err)
+			}
+			if fnErr != nil {
+				return fnErr
+			}
+			if count < PaginationSize {
+				break
+			}
+		}
+	}
+	return nil
+}
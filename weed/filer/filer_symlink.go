@@ -0,0 +1,84 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// MaxSymlinkHops limits how many symlinks are followed to resolve a path, same as linux.
+const MaxSymlinkHops = 40
+
+var ErrSymlinkLoop = errors.New("too many levels of symbolic links")
+
+// IsSymlink tells whether the entry is a symlink.
+func (entry *Entry) IsSymlink() bool {
+	return entry.Attr.SymlinkTarget != ""
+}
+
+// ResolveSymlinkTarget returns the path of the symlink target. A relative target is relative
+// to the directory of the symlink, and an absolute target is a path on this filer.
+func ResolveSymlinkTarget(link util.FullPath, target string) util.FullPath {
+	if !strings.HasPrefix(target, "/") {
+		dir, _ := link.DirAndName()
+		target = dir + "/" + target
+	}
+	return util.FullPath(path.Clean(target))
+}
+
+// FindEntryResolvingSymlinks finds the entry of the path, following the symlinks of the path
+// itself and of its parent directories, and returns the entry of the final target.
+// The returned entry keeps the full path of the target.
+func (f *Filer) FindEntryResolvingSymlinks(ctx context.Context, p util.FullPath) (entry *Entry, err error) {
+
+	for hops := 0; hops <= MaxSymlinkHops; hops++ {
+		entry, err = f.FindEntry(ctx, p)
+		if err == filer_pb.ErrNotFound {
+			// the path may go through a symlinked directory
+			var resolved util.FullPath
+			if resolved, err = f.resolveSymlinkedParent(ctx, p); err != nil {
+				return nil, err
+			}
+			p = resolved
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !entry.IsSymlink() {
+			return entry, nil
+		}
+		p = ResolveSymlinkTarget(entry.FullPath, entry.Attr.SymlinkTarget)
+	}
+
+	return nil, ErrSymlinkLoop
+}
+
+// resolveSymlinkedParent replaces the first symlinked parent directory of the path with its target,
+// or returns ErrNotFound if no parent is a symlink.
+func (f *Filer) resolveSymlinkedParent(ctx context.Context, p util.FullPath) (util.FullPath, error) {
+	parts := p.Split()
+	if len(parts) <= 1 {
+		return "", filer_pb.ErrNotFound
+	}
+	parent := util.FullPath("/")
+	for i, part := range parts[:len(parts)-1] {
+		parent = parent.Child(part)
+		entry, err := f.FindEntry(ctx, parent)
+		if err != nil {
+			return "", err
+		}
+		if entry.IsSymlink() {
+			resolved := ResolveSymlinkTarget(parent, entry.Attr.SymlinkTarget)
+			return util.JoinPath(append([]string{string(resolved)}, parts[i+1:]...)...), nil
+		}
+		if !entry.IsDirectory() {
+			return "", filer_pb.ErrNotFound
+		}
+	}
+	return "", filer_pb.ErrNotFound
+}
@@ -0,0 +1,25 @@
+package filer
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestResolveSymlinkTarget(t *testing.T) {
+	for _, c := range []struct {
+		link     util.FullPath
+		target   string
+		expected util.FullPath
+	}{
+		{"/a/b/link", "c.txt", "/a/b/c.txt"},
+		{"/a/b/link", "../c.txt", "/a/c.txt"},
+		{"/a/b/link", "/x/y", "/x/y"},
+		{"/link", "../../c.txt", "/c.txt"},
+		{"/link", "./d/", "/d"},
+	} {
+		if resolved := ResolveSymlinkTarget(c.link, c.target); resolved != c.expected {
+			t.Errorf("%s -> %s: resolved %s, expected %s", c.link, c.target, resolved, c.expected)
+		}
+	}
+}
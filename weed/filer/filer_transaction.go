@@ -0,0 +1,62 @@
+package filer
+
+import (
+	"context"
+	"sync"
+)
+
+// In a transaction of the filer store, the changes may still be rolled back, so the side effects
+// of the changes, i.e. the metadata notifications, the after operation hooks, and the deletion of
+// the replaced or deleted chunks, wait until the transaction is committed.
+
+type afterCommitKey struct{}
+
+type afterCommitActions struct {
+	lock      sync.Mutex
+	actions   []func()
+	committed bool
+}
+
+// DeferUntilCommit holds the side effects of the changes with the returned context, until
+// RunAfterCommit. If the transaction is rolled back, they are dropped with the changes.
+func DeferUntilCommit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, afterCommitKey{}, &afterCommitActions{})
+}
+
+// RunAfterCommit runs the side effects held by DeferUntilCommit, in the order of the changes.
+// The later side effects with the context run right away.
+func RunAfterCommit(ctx context.Context) {
+	pending, ok := ctx.Value(afterCommitKey{}).(*afterCommitActions)
+	if !ok {
+		return
+	}
+	pending.lock.Lock()
+	actions := pending.actions
+	pending.actions, pending.committed = nil, true
+	pending.lock.Unlock()
+
+	for _, action := range actions {
+		action()
+	}
+}
+
+// AfterCommit runs the action, or holds it until RunAfterCommit if the context defers the side effects.
+func AfterCommit(ctx context.Context, action func()) {
+	if !deferUntilCommit(ctx, action) {
+		action()
+	}
+}
+
+func deferUntilCommit(ctx context.Context, action func()) bool {
+	pending, ok := ctx.Value(afterCommitKey{}).(*afterCommitActions)
+	if !ok {
+		return false
+	}
+	pending.lock.Lock()
+	defer pending.lock.Unlock()
+	if pending.committed {
+		return false
+	}
+	pending.actions = append(pending.actions, action)
+	return true
+}
@@ -0,0 +1,32 @@
+package filer
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAfterCommit(t *testing.T) {
+	var ran []int
+
+	AfterCommit(context.Background(), func() { ran = append(ran, 0) })
+	if len(ran) != 1 {
+		t.Fatalf("not deferred action ran %d times", len(ran))
+	}
+
+	ctx := DeferUntilCommit(context.Background())
+	AfterCommit(ctx, func() { ran = append(ran, 1) })
+	AfterCommit(ctx, func() { ran = append(ran, 2) })
+	if len(ran) != 1 {
+		t.Fatalf("deferred actions ran before commit: %v", ran)
+	}
+
+	RunAfterCommit(ctx)
+	AfterCommit(ctx, func() { ran = append(ran, 3) })
+	if len(ran) != 4 || ran[1] != 1 || ran[2] != 2 || ran[3] != 3 {
+		t.Errorf("ran %v, expected [0 1 2 3]", ran)
+	}
+
+	// the actions of a rolled back transaction are dropped
+	rolledBack := DeferUntilCommit(context.Background())
+	AfterCommit(rolledBack, func() { t.Errorf("ran the action of a rolled back transaction") })
+}
@@ -0,0 +1,377 @@
+package filer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The usage of each collection and of each top level directory, i.e. the total file size
+// and the file count, is kept in the filer store and follows each metadata change of the store,
+// made by this filer or replayed from a peer filer with a separate store.
+// The changes are summed up in memory, and added to the stored usage every UsageFlushInterval,
+// under a cluster lock since the store can be shared by multiple filers.
+// The changes not flushed yet are lost if the filer is killed, and a rebuild recounts everything.
+// The metadata logs and indexes under SystemDir are not counted.
+
+const (
+	usageKey           = "usage.stats"
+	usageLockKey       = "usage.stats.lock"
+	UsageFlushInterval = 10 * time.Second
+)
+
+type Usage struct {
+	Bytes     int64 `json:"bytes"`
+	FileCount int64 `json:"files"`
+}
+
+type UsageStats struct {
+	Collections map[string]*Usage `json:"collections"`
+	Directories map[string]*Usage `json:"directories"`
+}
+
+func newUsageStats() *UsageStats {
+	return &UsageStats{
+		Collections: make(map[string]*Usage),
+		Directories: make(map[string]*Usage),
+	}
+}
+
+func (s *UsageStats) clone() *UsageStats {
+	c := newUsageStats()
+	for name, u := range s.Collections {
+		usage := *u
+		c.Collections[name] = &usage
+	}
+	for name, u := range s.Directories {
+		usage := *u
+		c.Directories[name] = &usage
+	}
+	return c
+}
+
+// usageDelta is the change since the last flush. With reset, the stored usage is replaced with the change.
+type usageDelta struct {
+	Usage
+	reset bool
+}
+
+type usageDeltas map[string]*usageDelta
+
+func (d usageDeltas) add(name string, bytes, fileCount int64) {
+	delta, found := d[name]
+	if !found {
+		delta = &usageDelta{}
+		d[name] = delta
+	}
+	delta.Bytes += bytes
+	delta.FileCount += fileCount
+}
+
+func (d usageDeltas) reset(name string) {
+	d[name] = &usageDelta{reset: true}
+}
+
+func (d usageDeltas) applyTo(usages map[string]*Usage) {
+	for name, delta := range d {
+		usage, found := usages[name]
+		if !found || delta.reset {
+			usage = &Usage{}
+			usages[name] = usage
+		}
+		usage.Bytes += delta.Bytes
+		usage.FileCount += delta.FileCount
+		if usage.Bytes == 0 && usage.FileCount == 0 {
+			delete(usages, name)
+		}
+	}
+}
+
+type UsageTracker struct {
+	filer             *Filer
+	self              pb.ServerAddress
+	defaultCollection string
+	lock              sync.Mutex
+	collections       usageDeltas
+	directories       usageDeltas
+	// the usage as last read from or written to the store
+	stored *UsageStats
+}
+
+func NewUsageTracker(f *Filer, self pb.ServerAddress, defaultCollection string) *UsageTracker {
+	t := &UsageTracker{
+		filer:             f,
+		self:              self,
+		defaultCollection: defaultCollection,
+		collections:       make(usageDeltas),
+		directories:       make(usageDeltas),
+	}
+	go t.loopFlushing()
+	return t
+}
+
+// usageDirectory returns the top level directory of the path, or "/" for the files in the root.
+func usageDirectory(p util.FullPath) string {
+	parts := p.Split()
+	if len(parts) <= 1 {
+		return "/"
+	}
+	return "/" + parts[0]
+}
+
+func (t *UsageTracker) collectionOf(p util.FullPath) string {
+	return t.filer.collectionOf(p, t.defaultCollection)
+}
+
+// collectionOf resolves the collection of the path, same as the filer does when writing.
+func (f *Filer) collectionOf(p util.FullPath, defaultCollection string) string {
+	if f.FilerConf != nil {
+		if rule := f.FilerConf.MatchStorageRule(string(p)); rule.Collection != "" {
+			return rule.Collection
+		}
+	}
+	if bucket := f.DetectBucket(p); bucket != "" {
+		return bucket
+	}
+	return defaultCollection
+}
+
+func (t *UsageTracker) onEvent(oldEntry, newEntry *Entry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if oldEntry != nil {
+		if !oldEntry.IsDirectory() {
+			t.add(oldEntry.FullPath, -int64(oldEntry.Size()), -1)
+		} else if newEntry == nil {
+			t.onDirectoryDeleted(oldEntry)
+		}
+	}
+	if newEntry != nil && !newEntry.IsDirectory() {
+		t.add(newEntry.FullPath, int64(newEntry.Size()), 1)
+	}
+}
+
+// onReplayedEvent counts a change of a peer filer, replayed into this filer store.
+func (t *UsageTracker) onReplayedEvent(event *filer_pb.SubscribeMetadataResponse) {
+	t.onEvent(entriesOfEvent(event))
+}
+
+func (t *UsageTracker) add(p util.FullPath, bytes, fileCount int64) {
+	t.collections.add(t.collectionOf(p), bytes, fileCount)
+	t.directories.add(usageDirectory(p), bytes, fileCount)
+}
+
+// onDirectoryDeleted resets the usage of a deleted top level directory or bucket,
+// since the files of a dropped bucket are deleted without their own events.
+func (t *UsageTracker) onDirectoryDeleted(entry *Entry) {
+	parent, _ := entry.FullPath.DirAndName()
+	if parent == "/" {
+		t.directories.reset(string(entry.FullPath))
+	}
+	if t.filer.isBucket(entry) {
+		t.collections.reset(entry.Name())
+	}
+}
+
+func (t *UsageTracker) loopFlushing() {
+	for {
+		time.Sleep(UsageFlushInterval)
+		if err := t.flush(); err != nil {
+			glog.Warningf("flush usage stats: %v", err)
+		}
+	}
+}
+
+func (t *UsageTracker) takeDeltas() (collections, directories usageDeltas) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	collections, directories = t.collections, t.directories
+	t.collections, t.directories = make(usageDeltas), make(usageDeltas)
+	return
+}
+
+// putBack keeps the deltas failed to flush, for the next flush.
+func (t *UsageTracker) putBack(collections, directories usageDeltas) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for name, delta := range t.collections {
+		collections.add(name, delta.Bytes, delta.FileCount)
+		if delta.reset {
+			collections[name] = delta
+		}
+	}
+	for name, delta := range t.directories {
+		directories.add(name, delta.Bytes, delta.FileCount)
+		if delta.reset {
+			directories[name] = delta
+		}
+	}
+	t.collections, t.directories = collections, directories
+}
+
+func (t *UsageTracker) flush() error {
+	collections, directories := t.takeDeltas()
+	if len(collections) == 0 && len(directories) == 0 {
+		return nil
+	}
+	err := t.update(func(usageStats *UsageStats) {
+		collections.applyTo(usageStats.Collections)
+		directories.applyTo(usageStats.Directories)
+	})
+	if err != nil {
+		t.putBack(collections, directories)
+	}
+	return err
+}
+
+// update changes the stored usage under the cluster lock.
+func (t *UsageTracker) update(fn func(usageStats *UsageStats)) error {
+	lock := cluster.NewLockClient(t.filer.GrpcDialOption, t.self).NewLock(usageLockKey, string(t.self))
+	defer lock.StopLock()
+
+	ctx := context.Background()
+	usageStats, _, err := t.read(ctx)
+	if err != nil {
+		return err
+	}
+	fn(usageStats)
+	data, err := json.Marshal(usageStats)
+	if err != nil {
+		return fmt.Errorf("marshal: %v", err)
+	}
+	if err = t.filer.Store.KvPut(ctx, []byte(usageKey), data); err != nil {
+		return fmt.Errorf("persist: %v", err)
+	}
+	t.setStored(usageStats)
+	return nil
+}
+
+func (t *UsageTracker) read(ctx context.Context) (usageStats *UsageStats, found bool, err error) {
+	data, err := t.filer.Store.KvGet(ctx, []byte(usageKey))
+	if err == ErrKvNotFound {
+		return newUsageStats(), false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("read: %v", err)
+	}
+	usageStats = newUsageStats()
+	if err = json.Unmarshal(data, usageStats); err != nil {
+		return nil, false, fmt.Errorf("unmarshal: %v", err)
+	}
+	return usageStats, true, nil
+}
+
+func (t *UsageTracker) setStored(usageStats *UsageStats) {
+	t.lock.Lock()
+	previous := t.stored
+	t.stored = usageStats.clone()
+	t.lock.Unlock()
+
+	if previous != nil {
+		for name := range previous.Collections {
+			if _, found := usageStats.Collections[name]; !found {
+				stats.FilerUsageBytesGauge.DeleteLabelValues("collection", name)
+				stats.FilerUsageFilesGauge.DeleteLabelValues("collection", name)
+			}
+		}
+		for name := range previous.Directories {
+			if _, found := usageStats.Directories[name]; !found {
+				stats.FilerUsageBytesGauge.DeleteLabelValues("directory", name)
+				stats.FilerUsageFilesGauge.DeleteLabelValues("directory", name)
+			}
+		}
+	}
+	for name, usage := range usageStats.Collections {
+		stats.FilerUsageBytesGauge.WithLabelValues("collection", name).Set(float64(usage.Bytes))
+		stats.FilerUsageFilesGauge.WithLabelValues("collection", name).Set(float64(usage.FileCount))
+	}
+	for name, usage := range usageStats.Directories {
+		stats.FilerUsageBytesGauge.WithLabelValues("directory", name).Set(float64(usage.Bytes))
+		stats.FilerUsageFilesGauge.WithLabelValues("directory", name).Set(float64(usage.FileCount))
+	}
+}
+
+// Get returns the stored usage with the changes not flushed yet, and whether the usage was ever counted.
+func (t *UsageTracker) Get(ctx context.Context) (usageStats *UsageStats, isCounted bool, err error) {
+	if usageStats, isCounted, err = t.read(ctx); err != nil {
+		return nil, false, err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.collections.applyTo(usageStats.Collections)
+	t.directories.applyTo(usageStats.Directories)
+	return usageStats, isCounted, nil
+}
+
+// Rebuild recounts the usage by walking the whole namespace.
+// The changes during the walk may be counted twice or missed, so it is best run when the filer is quiet.
+func (t *UsageTracker) Rebuild(ctx context.Context) (*UsageStats, error) {
+	t.takeDeltas()
+	counted := newUsageStats()
+	collections, directories := make(usageDeltas), make(usageDeltas)
+	if err := t.countTree(ctx, "/", collections, directories); err != nil {
+		return nil, err
+	}
+	collections.applyTo(counted.Collections)
+	directories.applyTo(counted.Directories)
+	err := t.update(func(usageStats *UsageStats) {
+		*usageStats = *counted
+	})
+	glog.V(0).Infof("recounted usage of %d collections and %d directories", len(counted.Collections), len(counted.Directories))
+	return counted, err
+}
+
+func (t *UsageTracker) countTree(ctx context.Context, dir util.FullPath, collections, directories usageDeltas) (err error) {
+	lastFileName := ""
+	for {
+		var subDirs []util.FullPath
+		var count int
+		lastFileName, err = t.filer.StreamListDirectoryEntries(ctx, dir, lastFileName, false, PaginationSize, "", "", "", func(entry *Entry) bool {
+			count++
+			if entry.IsDirectory() {
+				subDirs = append(subDirs, entry.FullPath)
+				return true
+			}
+			size := int64(entry.Size())
+			collections.add(t.collectionOf(entry.FullPath), size, 1)
+			directories.add(usageDirectory(entry.FullPath), size, 1)
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("list %s: %v", dir, err)
+		}
+		for _, subDir := range subDirs {
+			if subDir == SystemDir {
+				continue
+			}
+			if err = t.countTree(ctx, subDir, collections, directories); err != nil {
+				return err
+			}
+		}
+		if count < PaginationSize {
+			break
+		}
+	}
+	return nil
+}
+
+// SortedUsageNames lists the names of the usages, in order.
+func SortedUsageNames(usages map[string]*Usage) (names []string) {
+	for name := range usages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
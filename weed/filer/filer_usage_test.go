@@ -0,0 +1,50 @@
+package filer
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestUsageDirectory(t *testing.T) {
+	for p, expected := range map[util.FullPath]string{
+		"/a.txt":        "/",
+		"/buckets/b1/f": "/buckets",
+		"/data/x/y/z":   "/data",
+		"/data":         "/",
+	} {
+		if got := usageDirectory(p); got != expected {
+			t.Errorf("usageDirectory(%s) = %s, expected %s", p, got, expected)
+		}
+	}
+}
+
+func TestUsageDeltasApplyTo(t *testing.T) {
+	usages := map[string]*Usage{
+		"c1": {Bytes: 100, FileCount: 2},
+		"c2": {Bytes: 50, FileCount: 1},
+		"c3": {Bytes: 10, FileCount: 1},
+	}
+
+	deltas := make(usageDeltas)
+	deltas.add("c1", 20, 1)
+	deltas.add("c1", -5, 0)
+	deltas.add("c2", -50, -1)
+	deltas.reset("c3")
+	deltas.add("c3", 7, 1)
+	deltas.add("c4", 1, 1)
+	deltas.applyTo(usages)
+
+	if u := usages["c1"]; u.Bytes != 115 || u.FileCount != 3 {
+		t.Errorf("c1: %+v", u)
+	}
+	if _, found := usages["c2"]; found {
+		t.Errorf("empty c2 should be removed")
+	}
+	if u := usages["c3"]; u.Bytes != 7 || u.FileCount != 1 {
+		t.Errorf("reset c3: %+v", u)
+	}
+	if u := usages["c4"]; u.Bytes != 1 || u.FileCount != 1 {
+		t.Errorf("c4: %+v", u)
+	}
+}
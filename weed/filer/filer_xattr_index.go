@@ -0,0 +1,215 @@
+package filer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The xattr index keeps one store entry for each indexed file, as
+// <XattrIndexDir>/<key>/<value>/<escaped full path>, written directly to the filer store
+// without any metadata events. So the entries with a value are listed by that directory,
+// and the entries under a directory by the prefix of the escaped full path.
+// The index is updated with the metadata changes of this filer store. A query checks each
+// found entry again, and removes the index entries gone stale.
+
+const (
+	xattrIndexListBatchSize = 1024
+	// longer values are indexed by their hash, to keep the directory names short
+	xattrIndexMaxValueLength = 128
+	// the mount keeps the extended attributes with this prefix
+	xattrPrefix = "xattr-"
+)
+
+type XattrIndex struct {
+	filer *Filer
+	keys  map[string]bool
+}
+
+func NewXattrIndex(f *Filer, keys []string) *XattrIndex {
+	x := &XattrIndex{
+		filer: f,
+		keys:  make(map[string]bool),
+	}
+	for _, key := range keys {
+		x.keys[key] = true
+	}
+	return x
+}
+
+func (x *XattrIndex) IsIndexed(key string) bool {
+	return x.keys[key]
+}
+
+// xattrValueOf reads the extended attribute, as set over http or s3, or by the mount.
+func xattrValueOf(entry *Entry, key string) (string, bool) {
+	if entry == nil {
+		return "", false
+	}
+	if value, found := entry.Extended[key]; found {
+		return string(value), true
+	}
+	if value, found := entry.Extended[xattrPrefix+key]; found {
+		return string(value), true
+	}
+	return "", false
+}
+
+func xattrIndexValueDir(key, value string) util.FullPath {
+	valueName := url.PathEscape(value)
+	if len(valueName) > xattrIndexMaxValueLength {
+		digest := sha256.Sum256([]byte(value))
+		valueName = "~" + hex.EncodeToString(digest[:])
+	}
+	return util.NewFullPath(XattrIndexDir, url.PathEscape(key)).Child(valueName)
+}
+
+// xattrIndexPrefix is the name prefix of the index entries under the directory.
+func xattrIndexPrefix(dir util.FullPath) string {
+	if dir == "" || dir == "/" {
+		return ""
+	}
+	return url.PathEscape(strings.TrimSuffix(string(dir), "/") + "/")
+}
+
+func (x *XattrIndex) onEvent(oldEntry, newEntry *Entry) {
+	for key := range x.keys {
+		oldValue, oldFound := xattrValueOf(oldEntry, key)
+		newValue, newFound := xattrValueOf(newEntry, key)
+		isSame := oldFound && newFound && oldValue == newValue && oldEntry.FullPath == newEntry.FullPath
+		if oldFound && !isSame {
+			if err := x.remove(context.Background(), key, oldValue, oldEntry.FullPath); err != nil {
+				glog.Warningf("remove xattr index %s of %s: %v", key, oldEntry.FullPath, err)
+			}
+		}
+		if newFound && !isSame {
+			if err := x.add(context.Background(), key, newValue, newEntry.FullPath); err != nil {
+				glog.Warningf("add xattr index %s of %s: %v", key, newEntry.FullPath, err)
+			}
+		}
+	}
+}
+
+// onReplayedEvent indexes a change of a peer filer, replayed into this filer store.
+func (x *XattrIndex) onReplayedEvent(event *filer_pb.SubscribeMetadataResponse) {
+	x.onEvent(entriesOfEvent(event))
+}
+
+func (x *XattrIndex) add(ctx context.Context, key, value string, p util.FullPath) error {
+	return x.filer.Store.InsertEntry(ctx, &Entry{
+		FullPath: xattrIndexValueDir(key, value).Child(url.PathEscape(string(p))),
+		Attr: Attr{
+			Mtime: time.Now(),
+			Mode:  0644,
+		},
+	})
+}
+
+func (x *XattrIndex) remove(ctx context.Context, key, value string, p util.FullPath) error {
+	return x.filer.Store.DeleteEntry(ctx, xattrIndexValueDir(key, value).Child(url.PathEscape(string(p))))
+}
+
+// Find visits the entries under the directory with the extended attribute value, ordered by
+// their full path and after startFromPath, until fn returns false.
+func (x *XattrIndex) Find(ctx context.Context, key, value string, dir util.FullPath, startFromPath util.FullPath, fn func(entry *Entry) bool) error {
+
+	if !x.IsIndexed(key) {
+		return fmt.Errorf("extended attribute %s is not indexed", key)
+	}
+
+	valueDir := xattrIndexValueDir(key, value)
+	prefix := xattrIndexPrefix(dir)
+	lastFileName := ""
+	if startFromPath != "" {
+		lastFileName = url.PathEscape(string(startFromPath))
+	}
+
+	for {
+		var indexEntries []*Entry
+		_, err := x.filer.Store.ListDirectoryPrefixedEntries(ctx, valueDir, lastFileName, false, xattrIndexListBatchSize, prefix, func(entry *Entry) bool {
+			indexEntries = append(indexEntries, entry)
+			return true
+		})
+		if err != nil && err != filer_pb.ErrNotFound {
+			return fmt.Errorf("list xattr index %s: %v", valueDir, err)
+		}
+
+		for _, indexEntry := range indexEntries {
+			lastFileName = indexEntry.Name()
+			unescaped, err := url.PathUnescape(lastFileName)
+			if err != nil {
+				continue
+			}
+			p := util.FullPath(unescaped)
+			entry, err := x.filer.FindEntry(ctx, p)
+			if err != nil && err != filer_pb.ErrNotFound {
+				return err
+			}
+			if foundValue, hasValue := xattrValueOf(entry, key); !hasValue || foundValue != value {
+				glog.V(1).Infof("remove stale xattr index %s=%s of %s", key, value, p)
+				if err := x.filer.Store.DeleteEntry(ctx, indexEntry.FullPath); err != nil {
+					glog.Warningf("remove stale xattr index %s: %v", indexEntry.FullPath, err)
+				}
+				continue
+			}
+			if !fn(entry) {
+				return nil
+			}
+		}
+
+		if len(indexEntries) < xattrIndexListBatchSize {
+			return nil
+		}
+	}
+}
+
+// Rebuild indexes the extended attribute of all existing entries, e.g. of a newly indexed key.
+func (x *XattrIndex) Rebuild(ctx context.Context, key string) (indexedCount int64, err error) {
+	if !x.IsIndexed(key) {
+		return 0, fmt.Errorf("extended attribute %s is not indexed", key)
+	}
+	err = x.indexTree(ctx, "/", key, &indexedCount)
+	return
+}
+
+func (x *XattrIndex) indexTree(ctx context.Context, dir util.FullPath, key string, indexedCount *int64) error {
+
+	lastFileName := ""
+	for {
+		var entries []*Entry
+		_, err := x.filer.StreamListDirectoryEntries(ctx, dir, lastFileName, false, xattrIndexListBatchSize, "", "", "", func(entry *Entry) bool {
+			entries = append(entries, entry)
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("list %s: %v", dir, err)
+		}
+
+		for _, entry := range entries {
+			if value, found := xattrValueOf(entry, key); found {
+				if err = x.add(ctx, key, value, entry.FullPath); err != nil {
+					return fmt.Errorf("index %s: %v", entry.FullPath, err)
+				}
+				*indexedCount++
+			}
+			if entry.IsDirectory() && entry.FullPath != SystemDir {
+				if err = x.indexTree(ctx, entry.FullPath, key, indexedCount); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(entries) < xattrIndexListBatchSize {
+			return nil
+		}
+		lastFileName = entries[len(entries)-1].Name()
+	}
+}
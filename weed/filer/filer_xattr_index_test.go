@@ -0,0 +1,51 @@
+package filer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestXattrIndexNames(t *testing.T) {
+	project := xattrIndexValueDir("user.project", "alpha/beta")
+	if project != util.FullPath(XattrIndexDir+"/user.project/alpha%2Fbeta") {
+		t.Errorf("value dir %s", project)
+	}
+	long := xattrIndexValueDir("user.project", strings.Repeat("x", 200))
+	if name := long.Name(); !strings.HasPrefix(name, "~") || len(name) != 65 {
+		t.Errorf("long value dir %s", long)
+	}
+
+	prefix := xattrIndexPrefix("/data")
+	for p, expected := range map[string]bool{
+		"/data/a.txt":   true,
+		"/data/x/y.txt": true,
+		"/database/a":   false,
+		"/data":         false,
+	} {
+		name := project.Child(strings.ReplaceAll(strings.ReplaceAll(p, "/", "%2F"), " ", "%20")).Name()
+		if strings.HasPrefix(name, prefix) != expected {
+			t.Errorf("%s under /data: %v", p, !expected)
+		}
+	}
+	if xattrIndexPrefix("/") != "" {
+		t.Errorf("root prefix")
+	}
+}
+
+func TestXattrValueOf(t *testing.T) {
+	entry := &Entry{Extended: map[string][]byte{
+		"Seaweed-Team":       []byte("infra"),
+		"xattr-user.project": []byte("alpha"),
+	}}
+	if value, found := xattrValueOf(entry, "Seaweed-Team"); !found || value != "infra" {
+		t.Errorf("Seaweed-Team: %s %v", value, found)
+	}
+	if value, found := xattrValueOf(entry, "user.project"); !found || value != "alpha" {
+		t.Errorf("user.project set by mount: %s %v", value, found)
+	}
+	if _, found := xattrValueOf(nil, "user.project"); found {
+		t.Errorf("nil entry")
+	}
+}
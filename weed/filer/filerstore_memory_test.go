@@ -0,0 +1,147 @@
+package filer
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// memoryStore is a filer store in memory, for the tests needing a filer.
+type memoryStore struct {
+	lock    sync.Mutex
+	entries map[util.FullPath]*Entry
+	kv      map[string][]byte
+}
+
+func newTestFiler() *Filer {
+	return &Filer{
+		Store: NewFilerStoreWrapper(&memoryStore{
+			entries: make(map[util.FullPath]*Entry),
+			kv:      make(map[string][]byte),
+		}),
+	}
+}
+
+func (store *memoryStore) GetName() string {
+	return "memory"
+}
+
+func (store *memoryStore) Initialize(configuration util.Configuration, prefix string) error {
+	return nil
+}
+
+func (store *memoryStore) InsertEntry(ctx context.Context, entry *Entry) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	copied := *entry
+	store.entries[entry.FullPath] = &copied
+	return nil
+}
+
+func (store *memoryStore) UpdateEntry(ctx context.Context, entry *Entry) error {
+	return store.InsertEntry(ctx, entry)
+}
+
+func (store *memoryStore) FindEntry(ctx context.Context, p util.FullPath) (*Entry, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	entry, found := store.entries[p]
+	if !found {
+		return nil, filer_pb.ErrNotFound
+	}
+	copied := *entry
+	return &copied, nil
+}
+
+func (store *memoryStore) DeleteEntry(ctx context.Context, p util.FullPath) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	delete(store.entries, p)
+	return nil
+}
+
+func (store *memoryStore) DeleteFolderChildren(ctx context.Context, p util.FullPath) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for fullPath := range store.entries {
+		if strings.HasPrefix(string(fullPath), strings.TrimSuffix(string(p), "/")+"/") {
+			delete(store.entries, fullPath)
+		}
+	}
+	return nil
+}
+
+func (store *memoryStore) ListDirectoryEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc ListEachEntryFunc) (string, error) {
+	return store.ListDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, "", eachEntryFunc)
+}
+
+func (store *memoryStore) ListDirectoryPrefixedEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc) (lastFileName string, err error) {
+	store.lock.Lock()
+	var children []*Entry
+	for fullPath, entry := range store.entries {
+		dir, name := fullPath.DirAndName()
+		if dir != string(dirPath) || !strings.HasPrefix(name, prefix) || name < startFileName || (name == startFileName && !includeStartFile) {
+			continue
+		}
+		copied := *entry
+		children = append(children, &copied)
+	}
+	store.lock.Unlock()
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name() < children[j].Name()
+	})
+	for i, entry := range children {
+		if int64(i) >= limit {
+			break
+		}
+		lastFileName = entry.Name()
+		if !eachEntryFunc(entry) {
+			break
+		}
+	}
+	return lastFileName, nil
+}
+
+func (store *memoryStore) BeginTransaction(ctx context.Context) (context.Context, error) {
+	return ctx, nil
+}
+
+func (store *memoryStore) CommitTransaction(ctx context.Context) error {
+	return nil
+}
+
+func (store *memoryStore) RollbackTransaction(ctx context.Context) error {
+	return nil
+}
+
+func (store *memoryStore) KvPut(ctx context.Context, key []byte, value []byte) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.kv[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+func (store *memoryStore) KvGet(ctx context.Context, key []byte) ([]byte, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	value, found := store.kv[string(key)]
+	if !found {
+		return nil, ErrKvNotFound
+	}
+	return append([]byte(nil), value...), nil
+}
+
+func (store *memoryStore) KvDelete(ctx context.Context, key []byte) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	delete(store.kv, string(key))
+	return nil
+}
+
+func (store *memoryStore) Shutdown() {
+}
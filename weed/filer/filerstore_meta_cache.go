@@ -0,0 +1,268 @@
+package filer
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/karlseguin/ccache/v2"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The meta cache keeps the recently found entries, including the not found ones, and the
+// recently listed directory pages, in front of a slow filer store.
+// The entries and pages of a directory are kept under the directory, so a change to one entry
+// drops the entry itself and the pages of its directory. Deleting the children of a folder, or
+// deleting or renaming a folder on a peer filer, drops the whole cache.
+//
+// The changes through this filer store, including the replayed changes of the peers, and the
+// metadata changes from the peers sharing the filer store, invalidate the cache.
+// The hard linked entries are not cached, since a change to one link changes all of them.
+//
+// A read racing with a change of the same directory is not cached, by comparing the change
+// generation of the directory before and after the read.
+
+const (
+	metaCacheGenerations    = 256
+	metaCacheMaxListingSize = 4096
+	metaCacheEntryPrefix    = "e/"
+	metaCacheListingPrefix  = "l/"
+)
+
+type metaCacheNotFound struct{}
+
+type metaCacheListing struct {
+	entries      [][]byte
+	lastFileName string
+}
+
+func (l *metaCacheListing) Size() int64 {
+	return int64(len(l.entries)) + 1
+}
+
+type MetaCache struct {
+	cache       *ccache.LayeredCache
+	ttl         time.Duration
+	generations [metaCacheGenerations]int64
+}
+
+func NewMetaCache(maxEntries int64, ttl time.Duration) *MetaCache {
+	return &MetaCache{
+		cache: ccache.Layered(ccache.Configure().MaxSize(maxEntries).ItemsToPrune(uint32(maxEntries/100 + 1))),
+		ttl:   ttl,
+	}
+}
+
+// EnableMetaCache caches up to maxEntries entries in front of the filer store, each for up to ttl.
+func (f *Filer) EnableMetaCache(maxEntries int64, ttl time.Duration) {
+	if fsw, ok := f.Store.(*FilerStoreWrapper); ok {
+		fsw.metaCache = NewMetaCache(maxEntries, ttl)
+		glog.V(0).Infof("cache %d entries of filer store %s for %v", maxEntries, fsw.GetName(), ttl)
+	}
+}
+
+func (c *MetaCache) generation(dir util.FullPath) *int64 {
+	h := fnv.New32a()
+	h.Write([]byte(dir))
+	return &c.generations[h.Sum32()%metaCacheGenerations]
+}
+
+func encodeCachedEntry(entry *Entry) ([]byte, error) {
+	return proto.Marshal(entry.ToProtoEntry())
+}
+
+func decodeCachedEntry(dir util.FullPath, data []byte) (*Entry, error) {
+	message := &filer_pb.Entry{}
+	if err := proto.Unmarshal(data, message); err != nil {
+		return nil, err
+	}
+	return FromPbEntry(string(dir), message), nil
+}
+
+// findEntry returns the cached entry, or ErrNotFound if cached as not found.
+func (c *MetaCache) findEntry(p util.FullPath) (entry *Entry, err error, found bool) {
+	dir, name := p.DirAndName()
+	item := c.cache.Get(dir, metaCacheEntryPrefix+name)
+	if item == nil || item.Expired() {
+		stats.FilerMetaCacheCounter.WithLabelValues("find", "miss").Inc()
+		return nil, nil, false
+	}
+	stats.FilerMetaCacheCounter.WithLabelValues("find", "hit").Inc()
+	if _, isNotFound := item.Value().(metaCacheNotFound); isNotFound {
+		return nil, filer_pb.ErrNotFound, true
+	}
+	entry, err = decodeCachedEntry(util.FullPath(dir), item.Value().([]byte))
+	if err != nil {
+		glog.Errorf("decode cached %s: %v", p, err)
+		return nil, nil, false
+	}
+	return entry, nil, true
+}
+
+func (c *MetaCache) setEntry(p util.FullPath, generation int64, entry *Entry, err error) {
+	dir, name := p.DirAndName()
+	if atomic.LoadInt64(c.generation(util.FullPath(dir))) != generation {
+		return
+	}
+	switch {
+	case err == filer_pb.ErrNotFound:
+		c.cache.Set(dir, metaCacheEntryPrefix+name, metaCacheNotFound{}, c.ttl)
+	case err == nil && len(entry.HardLinkId) == 0:
+		if data, encodeErr := encodeCachedEntry(entry); encodeErr == nil {
+			c.cache.Set(dir, metaCacheEntryPrefix+name, data, c.ttl)
+		}
+	}
+}
+
+func metaCacheListingKey(startFileName string, includeStartFile bool, limit int64, prefix string) string {
+	include := "0"
+	if includeStartFile {
+		include = "1"
+	}
+	return metaCacheListingPrefix + include + "/" + strconv.FormatInt(limit, 10) + "/" + prefix + "/" + startFileName
+}
+
+// list replays the cached page of the directory, or lists it with listFn and caches it.
+func (c *MetaCache) list(dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc, listFn func(eachEntryFunc ListEachEntryFunc) (string, error)) (string, error) {
+
+	if limit > metaCacheMaxListingSize {
+		return listFn(eachEntryFunc)
+	}
+
+	dir := string(dirPath)
+	key := metaCacheListingKey(startFileName, includeStartFile, limit, prefix)
+	if item := c.cache.Get(dir, key); item != nil && !item.Expired() {
+		stats.FilerMetaCacheCounter.WithLabelValues("list", "hit").Inc()
+		listing := item.Value().(*metaCacheListing)
+		var entries []*Entry
+		for _, data := range listing.entries {
+			entry, err := decodeCachedEntry(dirPath, data)
+			if err != nil {
+				glog.Errorf("decode cached listing of %s: %v", dirPath, err)
+				c.cache.Delete(dir, key)
+				return listFn(eachEntryFunc)
+			}
+			entries = append(entries, entry)
+		}
+		return replayListing(entries, listing.lastFileName, eachEntryFunc), nil
+	}
+	stats.FilerMetaCacheCounter.WithLabelValues("list", "miss").Inc()
+
+	generation := atomic.LoadInt64(c.generation(dirPath))
+	var entries []*Entry
+	lastFileName, err := listFn(func(entry *Entry) bool {
+		entries = append(entries, entry)
+		return true
+	})
+	if err != nil {
+		return lastFileName, err
+	}
+
+	listing := &metaCacheListing{lastFileName: lastFileName}
+	for _, entry := range entries {
+		if len(entry.HardLinkId) > 0 {
+			listing = nil
+			break
+		}
+		data, encodeErr := encodeCachedEntry(entry)
+		if encodeErr != nil {
+			listing = nil
+			break
+		}
+		listing.entries = append(listing.entries, data)
+	}
+	if listing != nil && atomic.LoadInt64(c.generation(dirPath)) == generation {
+		c.cache.Set(dir, key, listing, c.ttl)
+	}
+
+	return replayListing(entries, lastFileName, eachEntryFunc), nil
+}
+
+func replayListing(entries []*Entry, lastFileName string, eachEntryFunc ListEachEntryFunc) string {
+	for _, entry := range entries {
+		if !eachEntryFunc(entry) {
+			return entry.Name()
+		}
+	}
+	return lastFileName
+}
+
+// invalidate drops the entry and the listed pages of its directory.
+func (c *MetaCache) invalidate(p util.FullPath) {
+	dir, name := p.DirAndName()
+	atomic.AddInt64(c.generation(util.FullPath(dir)), 1)
+	c.cache.Delete(dir, metaCacheEntryPrefix+name)
+	c.cache.DeletePrefix(dir, metaCacheListingPrefix)
+}
+
+func (c *MetaCache) clear() {
+	for i := range c.generations {
+		atomic.AddInt64(&c.generations[i], 1)
+	}
+	c.cache.Clear()
+}
+
+// onPeerEvent invalidates the cache with a metadata change of a peer filer.
+func (c *MetaCache) onPeerEvent(event *filer_pb.SubscribeMetadataResponse) {
+	oldEntry, newEntry := entriesOfEvent(event)
+	if oldEntry != nil {
+		if event.EventNotification.OldEntry.IsDirectory && (newEntry == nil || newEntry.FullPath != oldEntry.FullPath) {
+			c.clear()
+			return
+		}
+		c.invalidate(oldEntry.FullPath)
+	}
+	if newEntry != nil {
+		c.invalidate(newEntry.FullPath)
+	}
+}
+
+func (f *Filer) invalidateMetaCache(event *filer_pb.SubscribeMetadataResponse) {
+	if fsw, ok := f.Store.(*FilerStoreWrapper); ok && fsw.metaCache != nil {
+		fsw.metaCache.onPeerEvent(event)
+	}
+}
+
+func (fsw *FilerStoreWrapper) findCachedEntry(fp util.FullPath, findFn func() (*Entry, error)) (*Entry, error) {
+	if fsw.metaCache == nil {
+		return findFn()
+	}
+	if entry, err, found := fsw.metaCache.findEntry(fp); found {
+		return entry, err
+	}
+	dir, _ := fp.DirAndName()
+	generation := atomic.LoadInt64(fsw.metaCache.generation(util.FullPath(dir)))
+	entry, err := findFn()
+	fsw.metaCache.setEntry(fp, generation, entry, err)
+	return entry, err
+}
+
+func (fsw *FilerStoreWrapper) listCachedEntries(dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc, listFn func(eachEntryFunc ListEachEntryFunc) (string, error)) (string, error) {
+	if fsw.metaCache == nil {
+		return listFn(eachEntryFunc)
+	}
+	return fsw.metaCache.list(dirPath, startFileName, includeStartFile, limit, prefix, eachEntryFunc, listFn)
+}
+
+// invalidateCachedEntry drops the entry, and again after the transaction is committed, since
+// the entry may be cached as before the change until then.
+func (fsw *FilerStoreWrapper) invalidateCachedEntry(ctx context.Context, fp util.FullPath) {
+	if fsw.metaCache != nil {
+		fsw.metaCache.invalidate(fp)
+		deferUntilCommit(ctx, func() { fsw.metaCache.invalidate(fp) })
+	}
+}
+
+func (fsw *FilerStoreWrapper) invalidateCachedEntries(ctx context.Context) {
+	if fsw.metaCache != nil {
+		fsw.metaCache.clear()
+		deferUntilCommit(ctx, fsw.metaCache.clear)
+	}
+}
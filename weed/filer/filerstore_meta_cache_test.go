@@ -0,0 +1,109 @@
+package filer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestMetaCacheFindEntry(t *testing.T) {
+	fsw := &FilerStoreWrapper{metaCache: NewMetaCache(100, time.Minute)}
+
+	finds := 0
+	findFn := func() (*Entry, error) {
+		finds++
+		return &Entry{FullPath: "/dir/a.txt", Attr: Attr{FileSize: 3}}, nil
+	}
+	for i := 0; i < 3; i++ {
+		entry, err := fsw.findCachedEntry("/dir/a.txt", findFn)
+		if err != nil || entry.FullPath != "/dir/a.txt" || entry.FileSize != 3 {
+			t.Fatalf("find %d: %+v %v", i, entry, err)
+		}
+	}
+	if finds != 1 {
+		t.Errorf("found %d times in the store, expected 1", finds)
+	}
+
+	fsw.invalidateCachedEntry(context.Background(), "/dir/a.txt")
+	fsw.findCachedEntry("/dir/a.txt", findFn)
+	if finds != 2 {
+		t.Errorf("found %d times in the store after invalidation, expected 2", finds)
+	}
+
+	notFoundFn := func() (*Entry, error) {
+		finds++
+		return nil, filer_pb.ErrNotFound
+	}
+	fsw.findCachedEntry("/dir/b.txt", notFoundFn)
+	if _, err := fsw.findCachedEntry("/dir/b.txt", notFoundFn); err != filer_pb.ErrNotFound {
+		t.Errorf("cached not found: %v", err)
+	}
+	if finds != 3 {
+		t.Errorf("found %d times in the store, expected 3", finds)
+	}
+}
+
+func TestMetaCacheListing(t *testing.T) {
+	fsw := &FilerStoreWrapper{metaCache: NewMetaCache(100, time.Minute)}
+
+	lists := 0
+	listFn := func(eachEntryFunc ListEachEntryFunc) (string, error) {
+		lists++
+		for _, name := range []string{"a", "b", "c"} {
+			if !eachEntryFunc(&Entry{FullPath: util.NewFullPath("/dir", name)}) {
+				return name, nil
+			}
+		}
+		return "c", nil
+	}
+	list := func(limit int64, stopAt string) (names []string, lastFileName string) {
+		lastFileName, err := fsw.listCachedEntries("/dir", "", false, limit, "", func(entry *Entry) bool {
+			names = append(names, entry.Name())
+			return entry.Name() != stopAt
+		}, listFn)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		return
+	}
+
+	if names, lastFileName := list(10, ""); len(names) != 3 || lastFileName != "c" {
+		t.Fatalf("listed %v, last %s", names, lastFileName)
+	}
+	if names, lastFileName := list(10, "b"); len(names) != 2 || lastFileName != "b" {
+		t.Fatalf("listed cached %v, last %s", names, lastFileName)
+	}
+	if lists != 1 {
+		t.Errorf("listed %d times in the store, expected 1", lists)
+	}
+
+	// a different page is listed from the store
+	list(20, "")
+	if lists != 2 {
+		t.Errorf("listed %d times in the store, expected 2", lists)
+	}
+
+	// a change in the directory drops its pages
+	fsw.invalidateCachedEntry(context.Background(), "/dir/d")
+	list(10, "")
+	if lists != 3 {
+		t.Errorf("listed %d times in the store after invalidation, expected 3", lists)
+	}
+
+	// a peer renaming the directory drops the whole cache
+	fsw.metaCache.onPeerEvent(&filer_pb.SubscribeMetadataResponse{
+		Directory: "/",
+		EventNotification: &filer_pb.EventNotification{
+			OldEntry:      &filer_pb.Entry{Name: "dir", IsDirectory: true},
+			NewEntry:      &filer_pb.Entry{Name: "dir2", IsDirectory: true},
+			NewParentPath: "/",
+		},
+	})
+	list(10, "")
+	if lists != 4 {
+		t.Errorf("listed %d times in the store after the peer rename, expected 4", lists)
+	}
+}
@@ -0,0 +1,552 @@
+package filer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The default filer store can be migrated online to another filer store, configured in filer.toml:
+//
+//	[redis3.next]
+//	enabled = true
+//	migrate = true
+//	migrateEntriesPerSecond = 1000
+//	migrateAutoCutOver = false
+//	# the options of the new store
+//
+// During the migration, the changes are written to both stores, while the existing entries are
+// copied to the new store in the background. Then both stores are compared entry by entry, and the
+// differences are repaired, until one pass finds none. After the cut over, the new store serves the
+// reads, and the old store is still written, until the filer restarts with the new store in filer.toml.
+//
+// The kv entries of the hard links are copied with the linked entries, and the directory shards and
+// the usage stats are copied with the entries. The other kv entries are copied when written, or read
+// through from the old store after the cut over until restart.
+// The path-specific filer stores are not migrated.
+
+type StoreMigrationPhase string
+
+const (
+	MigrationBackfilling StoreMigrationPhase = "backfilling"
+	MigrationVerifying   StoreMigrationPhase = "verifying"
+	MigrationVerified    StoreMigrationPhase = "verified"
+	MigrationCutOver     StoreMigrationPhase = "cut over"
+)
+
+const (
+	migrationStateKey        = "__store_migration__"
+	migrationLockStripes     = 1024
+	migrationRetryInterval   = time.Minute
+	migrationRecheckInterval = 10 * time.Second
+)
+
+// the kv entries not found by walking the entries
+var migrationKvKeys = []string{DirShardsKey, usageKey}
+
+type StoreMigrationStatus struct {
+	From            string
+	To              string
+	Phase           StoreMigrationPhase
+	CopiedEntries   int64
+	VerifiedEntries int64
+	RepairedEntries int64
+	FailedWrites    int64
+	StartedAt       time.Time
+	LastError       string
+}
+
+type storeMigrationState struct {
+	From        string `json:"from"`
+	CutOverAtNs int64  `json:"cutOverAtNs"`
+}
+
+// StoreMigration is the default filer store during the migration, writing to both stores.
+type StoreMigration struct {
+	source      FilerStore
+	target      FilerStore
+	limiter     *rate.Limiter
+	autoCutOver bool
+
+	// guards the switch of the reads at the cut over
+	lock      sync.RWMutex
+	primary   FilerStore
+	secondary FilerStore
+
+	// the copies by the migration and the changes of the same path or kv key are serialized
+	entryStripes [migrationLockStripes]sync.Mutex
+	kvStripes    [migrationLockStripes]sync.Mutex
+	// set when the stores may differ, e.g. a write to the secondary store failed
+	diverged     int32
+	failedWrites int64
+
+	statusLock sync.Mutex
+	status     StoreMigrationStatus
+}
+
+// MigrateDefaultStore starts to migrate the default filer store to the target store.
+// If the migration was cut over before, the target store is used as the default store right away.
+func (fsw *FilerStoreWrapper) MigrateDefaultStore(target FilerStore, entriesPerSecond int, autoCutOver bool) *StoreMigration {
+	source := fsw.defaultStore
+
+	if data, err := target.KvGet(context.Background(), []byte(migrationStateKey)); err == nil {
+		state := &storeMigrationState{}
+		if err = json.Unmarshal(data, state); err == nil && state.CutOverAtNs > 0 {
+			glog.Warningf("filer store %s was migrated to %s at %v, please enable only %s in filer.toml", source.GetName(), target.GetName(), time.Unix(0, state.CutOverAtNs), target.GetName())
+			source.Shutdown()
+			fsw.defaultStore = target
+			return nil
+		}
+	}
+
+	limit := rate.Inf
+	if entriesPerSecond > 0 {
+		limit = rate.Limit(entriesPerSecond)
+	}
+	m := &StoreMigration{
+		source:      source,
+		target:      target,
+		limiter:     rate.NewLimiter(limit, 1),
+		autoCutOver: autoCutOver,
+		primary:     source,
+		secondary:   target,
+		status: StoreMigrationStatus{
+			From:      source.GetName(),
+			To:        target.GetName(),
+			Phase:     MigrationBackfilling,
+			StartedAt: time.Now(),
+		},
+	}
+	fsw.defaultStore = m
+	glog.V(0).Infof("migrate filer store %s to %s", source.GetName(), target.GetName())
+
+	go m.run(context.Background())
+	return m
+}
+
+// StoreMigration returns the running migration of the default filer store, or nil.
+func (f *Filer) StoreMigration() *StoreMigration {
+	if fsw, ok := f.Store.(*FilerStoreWrapper); ok {
+		if m, ok := fsw.defaultStore.(*StoreMigration); ok {
+			return m
+		}
+	}
+	return nil
+}
+
+func (m *StoreMigration) Status() StoreMigrationStatus {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	status := m.status
+	status.FailedWrites = atomic.LoadInt64(&m.failedWrites)
+	return status
+}
+
+func (m *StoreMigration) updateStatus(fn func(status *StoreMigrationStatus)) {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	fn(&m.status)
+}
+
+func (m *StoreMigration) run(ctx context.Context) {
+
+	for {
+		err := m.copyKvs(ctx)
+		if err == nil {
+			err = m.walk(ctx, m.source, "/", func(entry *Entry) (bool, error) {
+				if err := m.limiter.Wait(ctx); err != nil {
+					return false, err
+				}
+				_, exists, err := m.copyEntry(ctx, entry.FullPath)
+				if err == nil {
+					m.updateStatus(func(status *StoreMigrationStatus) { status.CopiedEntries++ })
+				}
+				return exists, err
+			})
+		}
+		if err == nil {
+			break
+		}
+		glog.Errorf("copy filer store %s to %s: %v", m.source.GetName(), m.target.GetName(), err)
+		m.updateStatus(func(status *StoreMigrationStatus) { status.LastError = err.Error() })
+		time.Sleep(migrationRetryInterval)
+	}
+
+	for {
+		m.updateStatus(func(status *StoreMigrationStatus) {
+			status.Phase = MigrationVerifying
+			status.VerifiedEntries = 0
+		})
+		atomic.StoreInt32(&m.diverged, 0)
+		repaired, err := m.verify(ctx)
+		if err != nil {
+			glog.Errorf("verify filer store %s with %s: %v", m.target.GetName(), m.source.GetName(), err)
+			m.updateStatus(func(status *StoreMigrationStatus) { status.LastError = err.Error() })
+			time.Sleep(migrationRetryInterval)
+			continue
+		}
+		if repaired > 0 || atomic.LoadInt32(&m.diverged) != 0 {
+			continue
+		}
+
+		m.updateStatus(func(status *StoreMigrationStatus) { status.Phase = MigrationVerified })
+		glog.V(0).Infof("verified filer store %s with %s", m.target.GetName(), m.source.GetName())
+		if m.autoCutOver {
+			if err = m.CutOver(ctx); err != nil {
+				glog.Errorf("cut over filer store %s to %s: %v", m.source.GetName(), m.target.GetName(), err)
+			}
+		}
+		for atomic.LoadInt32(&m.diverged) == 0 {
+			time.Sleep(migrationRecheckInterval)
+			if m.Status().Phase == MigrationCutOver {
+				// the old store is not verified any more
+				return
+			}
+		}
+		glog.V(0).Infof("filer store %s differs from %s, verify again", m.target.GetName(), m.source.GetName())
+	}
+}
+
+// verify compares the entries of both stores, and repairs the target store.
+func (m *StoreMigration) verify(ctx context.Context) (repaired int64, err error) {
+	if err = m.copyKvs(ctx); err != nil {
+		return
+	}
+	compareFn := func(entry *Entry) (bool, error) {
+		if err := m.limiter.Wait(ctx); err != nil {
+			return false, err
+		}
+		changed, exists, err := m.copyEntry(ctx, entry.FullPath)
+		if err != nil {
+			return false, err
+		}
+		if changed {
+			glog.V(1).Infof("repaired %s in filer store %s", entry.FullPath, m.target.GetName())
+			repaired++
+		}
+		m.updateStatus(func(status *StoreMigrationStatus) {
+			status.VerifiedEntries++
+			if changed {
+				status.RepairedEntries++
+			}
+		})
+		return exists, nil
+	}
+	if err = m.walk(ctx, m.source, "/", compareFn); err != nil {
+		return
+	}
+	// the entries only in the target store
+	err = m.walk(ctx, m.target, "/", compareFn)
+	return
+}
+
+// CutOver switches the reads to the target store, if both stores are verified to be the same.
+func (m *StoreMigration) CutOver(ctx context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	status := m.Status()
+	if status.Phase == MigrationCutOver {
+		return nil
+	}
+	if status.Phase != MigrationVerified || atomic.LoadInt32(&m.diverged) != 0 {
+		return fmt.Errorf("filer store %s is %s, not verified yet", m.target.GetName(), status.Phase)
+	}
+
+	data, _ := json.Marshal(&storeMigrationState{From: m.source.GetName(), CutOverAtNs: time.Now().UnixNano()})
+	if err := m.target.KvPut(ctx, []byte(migrationStateKey), data); err != nil {
+		return fmt.Errorf("save migration state: %v", err)
+	}
+	m.primary, m.secondary = m.target, m.source
+	m.updateStatus(func(status *StoreMigrationStatus) { status.Phase = MigrationCutOver })
+	glog.V(0).Infof("cut over filer store %s to %s, please enable only %s in filer.toml", m.source.GetName(), m.target.GetName(), m.target.GetName())
+	return nil
+}
+
+// walk visits the stored entries of the tree, and descends into the directories if eachEntryFn returns true.
+func (m *StoreMigration) walk(ctx context.Context, store FilerStore, dir util.FullPath, eachEntryFn func(entry *Entry) (bool, error)) error {
+
+	lastFileName := ""
+	for {
+		var entries []*Entry
+		_, err := store.ListDirectoryEntries(ctx, dir, lastFileName, false, PaginationSize, func(entry *Entry) bool {
+			entries = append(entries, entry)
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("list %s in %s: %v", dir, store.GetName(), err)
+		}
+
+		for _, entry := range entries {
+			descend, err := eachEntryFn(entry)
+			if err != nil {
+				return err
+			}
+			if descend && entry.IsDirectory() {
+				if err = m.walk(ctx, store, entry.FullPath, eachEntryFn); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(entries) < PaginationSize {
+			return nil
+		}
+		lastFileName = entries[len(entries)-1].Name()
+	}
+}
+
+// copyEntry makes the entry in the target store the same as in the source store.
+func (m *StoreMigration) copyEntry(ctx context.Context, p util.FullPath) (changed, exists bool, err error) {
+	defer lockStripe(&m.entryStripes, string(p))()
+
+	entry, err := m.source.FindEntry(ctx, p)
+	if err != nil && err != filer_pb.ErrNotFound {
+		return false, false, fmt.Errorf("find %s in %s: %v", p, m.source.GetName(), err)
+	}
+	existing, findErr := m.target.FindEntry(ctx, p)
+	if findErr != nil && findErr != filer_pb.ErrNotFound {
+		return false, false, fmt.Errorf("find %s in %s: %v", p, m.target.GetName(), findErr)
+	}
+
+	if entry == nil {
+		if existing == nil {
+			return false, false, nil
+		}
+		if existing.IsDirectory() {
+			if err = m.target.DeleteFolderChildren(ctx, p); err != nil {
+				return false, false, fmt.Errorf("delete %s children in %s: %v", p, m.target.GetName(), err)
+			}
+		}
+		if err = m.target.DeleteEntry(ctx, p); err != nil {
+			return false, false, fmt.Errorf("delete %s in %s: %v", p, m.target.GetName(), err)
+		}
+		return true, false, nil
+	}
+
+	if len(entry.HardLinkId) > 0 {
+		hardLinkChanged, err := m.copyKv(ctx, entry.HardLinkId)
+		if err != nil {
+			return false, true, err
+		}
+		changed = hardLinkChanged
+	}
+	if existing != nil && proto.Equal(existing.ToProtoEntry(), entry.ToProtoEntry()) {
+		return changed, true, nil
+	}
+	if err = m.target.InsertEntry(ctx, entry); err != nil {
+		return false, true, fmt.Errorf("insert %s in %s: %v", p, m.target.GetName(), err)
+	}
+	return true, true, nil
+}
+
+func (m *StoreMigration) copyKvs(ctx context.Context) error {
+	for _, key := range migrationKvKeys {
+		if _, err := m.copyKv(ctx, []byte(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *StoreMigration) copyKv(ctx context.Context, key []byte) (changed bool, err error) {
+	defer lockStripe(&m.kvStripes, string(key))()
+
+	value, err := m.source.KvGet(ctx, key)
+	if err == ErrKvNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("kv get %x in %s: %v", key, m.source.GetName(), err)
+	}
+	if existing, getErr := m.target.KvGet(ctx, key); getErr == nil && string(existing) == string(value) {
+		return false, nil
+	}
+	if err = m.target.KvPut(ctx, key, value); err != nil {
+		return false, fmt.Errorf("kv put %x in %s: %v", key, m.target.GetName(), err)
+	}
+	return true, nil
+}
+
+func lockStripe(stripes *[migrationLockStripes]sync.Mutex, key string) (unlock func()) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	stripe := &stripes[h.Sum32()%migrationLockStripes]
+	stripe.Lock()
+	return stripe.Unlock
+}
+
+func (m *StoreMigration) stores() (primary, secondary FilerStore) {
+	return m.primary, m.secondary
+}
+
+// write changes both stores. Only the error of the primary store fails the write, and
+// the stores differing after a failed write to the secondary store are verified again.
+func (m *StoreMigration) write(ctx context.Context, stripes *[migrationLockStripes]sync.Mutex, key string, fn func(ctx context.Context, store FilerStore) error) error {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	defer lockStripe(stripes, key)()
+
+	primary, secondary := m.stores()
+	if err := fn(ctx, primary); err != nil {
+		return err
+	}
+	if err := fn(withoutTransaction(ctx), secondary); err != nil {
+		glog.Errorf("write %s to filer store %s: %v", key, secondary.GetName(), err)
+		atomic.AddInt64(&m.failedWrites, 1)
+		atomic.StoreInt32(&m.diverged, 1)
+	}
+	return nil
+}
+
+func (m *StoreMigration) reader() FilerStore {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.primary
+}
+
+// withoutTransaction drops the transaction of the primary store, which the stores
+// look up with the "tx" key, so the secondary store does not write into it.
+func withoutTransaction(ctx context.Context) context.Context {
+	return context.WithValue(ctx, "tx", nil)
+}
+
+func (m *StoreMigration) GetName() string {
+	return m.reader().GetName()
+}
+
+func (m *StoreMigration) Initialize(configuration util.Configuration, prefix string) error {
+	return nil
+}
+
+func (m *StoreMigration) InsertEntry(ctx context.Context, entry *Entry) error {
+	return m.write(ctx, &m.entryStripes, string(entry.FullPath), func(ctx context.Context, store FilerStore) error {
+		return store.InsertEntry(ctx, entry)
+	})
+}
+
+func (m *StoreMigration) UpdateEntry(ctx context.Context, entry *Entry) error {
+	return m.write(ctx, &m.entryStripes, string(entry.FullPath), func(ctx context.Context, store FilerStore) error {
+		return store.UpdateEntry(ctx, entry)
+	})
+}
+
+func (m *StoreMigration) FindEntry(ctx context.Context, p util.FullPath) (*Entry, error) {
+	return m.reader().FindEntry(ctx, p)
+}
+
+func (m *StoreMigration) DeleteEntry(ctx context.Context, p util.FullPath) error {
+	return m.write(ctx, &m.entryStripes, string(p), func(ctx context.Context, store FilerStore) error {
+		return store.DeleteEntry(ctx, p)
+	})
+}
+
+func (m *StoreMigration) DeleteFolderChildren(ctx context.Context, p util.FullPath) error {
+	return m.write(ctx, &m.entryStripes, string(p), func(ctx context.Context, store FilerStore) error {
+		return store.DeleteFolderChildren(ctx, p)
+	})
+}
+
+func (m *StoreMigration) ListDirectoryEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc ListEachEntryFunc) (string, error) {
+	return m.reader().ListDirectoryEntries(ctx, dirPath, startFileName, includeStartFile, limit, eachEntryFunc)
+}
+
+func (m *StoreMigration) ListDirectoryPrefixedEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc) (string, error) {
+	return m.reader().ListDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, prefix, eachEntryFunc)
+}
+
+func (m *StoreMigration) ListDirectorySortedEntries(ctx context.Context, dirPath util.FullPath, sortBy SortBy, descending bool, startSortKey uint64, startFileName string, limit int64, eachEntryFunc ListEachEntryFunc) error {
+	if sortedStore, ok := m.reader().(SortedListingStore); ok {
+		return sortedStore.ListDirectorySortedEntries(ctx, dirPath, sortBy, descending, startSortKey, startFileName, limit, eachEntryFunc)
+	}
+	return ErrUnsupportedSortedListing
+}
+
+func (m *StoreMigration) ListHardLinks(ctx context.Context, eachHardLinkFn func(hardLinkId HardLinkId, entry *Entry) bool) error {
+	if listingStore, ok := m.reader().(HardLinkListingStore); ok {
+		return listingStore.ListHardLinks(ctx, eachHardLinkFn)
+	}
+	return ErrUnsupportedHardLinkListing
+}
+
+func (m *StoreMigration) BeginTransaction(ctx context.Context) (context.Context, error) {
+	return m.reader().BeginTransaction(ctx)
+}
+
+func (m *StoreMigration) CommitTransaction(ctx context.Context) error {
+	return m.reader().CommitTransaction(ctx)
+}
+
+func (m *StoreMigration) RollbackTransaction(ctx context.Context) error {
+	// the secondary store has the rolled back changes
+	atomic.StoreInt32(&m.diverged, 1)
+	return m.reader().RollbackTransaction(ctx)
+}
+
+func (m *StoreMigration) KvPut(ctx context.Context, key []byte, value []byte) error {
+	return m.write(ctx, &m.kvStripes, string(key), func(ctx context.Context, store FilerStore) error {
+		return store.KvPut(ctx, key, value)
+	})
+}
+
+func (m *StoreMigration) KvGet(ctx context.Context, key []byte) ([]byte, error) {
+	m.lock.RLock()
+	primary, secondary := m.stores()
+	m.lock.RUnlock()
+
+	value, err := primary.KvGet(ctx, key)
+	if err != ErrKvNotFound || primary != m.target {
+		return value, err
+	}
+	// after the cut over, read through the kv entries not copied yet
+	if value, err = secondary.KvGet(ctx, key); err == nil {
+		if _, copyErr := m.copyKv(ctx, key); copyErr != nil {
+			glog.Warningf("copy kv %x: %v", key, copyErr)
+		}
+	}
+	return value, err
+}
+
+func (m *StoreMigration) KvDelete(ctx context.Context, key []byte) error {
+	return m.write(ctx, &m.kvStripes, string(key), func(ctx context.Context, store FilerStore) error {
+		return store.KvDelete(ctx, key)
+	})
+}
+
+func (m *StoreMigration) CanDropWholeBucket() bool {
+	sourceBucketAware, sourceOk := m.source.(BucketAware)
+	targetBucketAware, targetOk := m.target.(BucketAware)
+	return sourceOk && targetOk && sourceBucketAware.CanDropWholeBucket() && targetBucketAware.CanDropWholeBucket()
+}
+
+func (m *StoreMigration) OnBucketCreation(bucket string) {
+	for _, store := range []FilerStore{m.source, m.target} {
+		if ba, ok := store.(BucketAware); ok {
+			ba.OnBucketCreation(bucket)
+		}
+	}
+}
+
+func (m *StoreMigration) OnBucketDeletion(bucket string) {
+	for _, store := range []FilerStore{m.source, m.target} {
+		if ba, ok := store.(BucketAware); ok {
+			ba.OnBucketDeletion(bucket)
+		}
+	}
+}
+
+func (m *StoreMigration) Shutdown() {
+	m.source.Shutdown()
+	m.target.Shutdown()
+}
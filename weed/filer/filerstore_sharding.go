@@ -0,0 +1,359 @@
+package filer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// A sharded directory keeps its children under hash-partitioned shard folders,
+// so no single folder in the underlying store holds tens of millions of entries.
+// The shard folders exist only as parent paths of the children in the store,
+// and are neither entries themselves nor visible to clients.
+//
+// While a directory is being converted, children may still be found at the
+// unsharded location, so lookups fall back to it and listings merge both.
+
+const (
+	DirShardsKey         = "dirShards"
+	dirShardNamePrefix   = ".seaweedfs.shard."
+	MaxDirectoryShards   = 4096
+	dirShardsReloadDelay = 10 * time.Second
+	shardListingPageSize = 1024
+	// DirShardsPropagationDelay is how long all filers take to pick up a conversion.
+	DirShardsPropagationDelay = 2 * dirShardsReloadDelay
+)
+
+type DirectoryShards struct {
+	Shards     int  `json:"shards"`
+	Converting bool `json:"converting,omitempty"`
+}
+
+type directoryShardsCache struct {
+	sync.RWMutex
+	dirs      map[util.FullPath]*DirectoryShards
+	refresher sync.Once
+}
+
+func (s *DirectoryShards) shardDir(dir util.FullPath, name string) util.FullPath {
+	shard := crc32.ChecksumIEEE([]byte(name)) % uint32(s.Shards)
+	return dir.Child(fmt.Sprintf("%s%04d", dirShardNamePrefix, shard))
+}
+
+func (s *DirectoryShards) shardDirs(dir util.FullPath) (dirs []util.FullPath) {
+	for shard := 0; shard < s.Shards; shard++ {
+		dirs = append(dirs, dir.Child(fmt.Sprintf("%s%04d", dirShardNamePrefix, shard)))
+	}
+	return
+}
+
+func (fsw *FilerStoreWrapper) loadDirectoryShards(ctx context.Context) (map[util.FullPath]*DirectoryShards, error) {
+	dirs := make(map[util.FullPath]*DirectoryShards)
+	value, err := fsw.getDefaultStore().KvGet(ctx, []byte(DirShardsKey))
+	if err == ErrKvNotFound {
+		return dirs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(value, &dirs); err != nil {
+		return nil, fmt.Errorf("unmarshal %s: %v", DirShardsKey, err)
+	}
+	return dirs, nil
+}
+
+func (fsw *FilerStoreWrapper) saveDirectoryShards(ctx context.Context, dirs map[util.FullPath]*DirectoryShards) error {
+	value, err := json.Marshal(dirs)
+	if err != nil {
+		return err
+	}
+	if err = fsw.getDefaultStore().KvPut(ctx, []byte(DirShardsKey), value); err != nil {
+		return fmt.Errorf("save %s: %v", DirShardsKey, err)
+	}
+	fsw.dirShards.Lock()
+	fsw.dirShards.dirs = dirs
+	fsw.dirShards.Unlock()
+	return nil
+}
+
+func (fsw *FilerStoreWrapper) refreshDirectoryShards() {
+	dirs, err := fsw.loadDirectoryShards(context.Background())
+	if err != nil {
+		glog.V(0).Infof("load directory shards: %v", err)
+		return
+	}
+	fsw.dirShards.Lock()
+	fsw.dirShards.dirs = dirs
+	fsw.dirShards.Unlock()
+}
+
+func (fsw *FilerStoreWrapper) loopRefreshingDirectoryShards() {
+	for {
+		time.Sleep(dirShardsReloadDelay)
+		fsw.refreshDirectoryShards()
+	}
+}
+
+// directoryShards returns the sharding of the directory, nil if not sharded.
+// The sharding is loaded on first use, and then reloaded in the background to pick up conversions by other filers.
+func (fsw *FilerStoreWrapper) directoryShards(ctx context.Context, dir util.FullPath) *DirectoryShards {
+	fsw.dirShards.refresher.Do(func() {
+		fsw.refreshDirectoryShards()
+		go fsw.loopRefreshingDirectoryShards()
+	})
+
+	fsw.dirShards.RLock()
+	defer fsw.dirShards.RUnlock()
+	return fsw.dirShards.dirs[dir]
+}
+
+// shardedPath returns where the entry is kept in the store, and the sharding of its parent directory.
+func (fsw *FilerStoreWrapper) shardedPath(ctx context.Context, fp util.FullPath) (util.FullPath, *DirectoryShards) {
+	if fp == "/" {
+		return fp, nil
+	}
+	dir, name := fp.DirAndName()
+	shards := fsw.directoryShards(ctx, util.FullPath(dir))
+	if shards == nil {
+		return fp, nil
+	}
+	return shards.shardDir(util.FullPath(dir), name).Child(name), shards
+}
+
+func isDirShardName(name string) bool {
+	return strings.HasPrefix(name, dirShardNamePrefix)
+}
+
+// listShardedEntries merges the sorted listings of all shards of the directory.
+func (fsw *FilerStoreWrapper) listShardedEntries(ctx context.Context, store FilerStore, dirPath util.FullPath, shards *DirectoryShards, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc) (lastFileName string, err error) {
+	var listings []*shardListing
+	for _, shardDir := range shards.shardDirs(dirPath) {
+		listings = append(listings, &shardListing{dir: shardDir})
+	}
+	if shards.Converting {
+		// listed last so that the shard version wins when an entry is in both
+		listings = append(listings, &shardListing{dir: dirPath})
+	}
+	for _, listing := range listings {
+		listing.lastName, listing.inclusive = startFileName, includeStartFile
+	}
+
+	for count := int64(0); count < limit; count++ {
+		var next *shardListing
+		for _, listing := range listings {
+			entry, peekErr := listing.peek(ctx, fsw, store, prefix)
+			if peekErr != nil {
+				return lastFileName, peekErr
+			}
+			if entry != nil && (next == nil || entry.Name() < next.buffer[0].Name()) {
+				next = listing
+			}
+		}
+		if next == nil {
+			break
+		}
+		entry := next.pop()
+		for _, listing := range listings {
+			// skip the stale copy of an entry being moved into its shard
+			if listing != next && len(listing.buffer) > 0 && listing.buffer[0].Name() == entry.Name() {
+				listing.pop()
+			}
+		}
+		entry.FullPath = dirPath.Child(entry.Name())
+		lastFileName = entry.Name()
+		if !eachEntryFunc(entry) {
+			break
+		}
+	}
+	return lastFileName, nil
+}
+
+type shardListing struct {
+	dir       util.FullPath
+	buffer    []*Entry
+	lastName  string
+	inclusive bool
+	exhausted bool
+}
+
+func (l *shardListing) peek(ctx context.Context, fsw *FilerStoreWrapper, store FilerStore, prefix string) (*Entry, error) {
+	if len(l.buffer) == 0 && !l.exhausted {
+		var count int
+		collect := func(entry *Entry) bool {
+			count++
+			l.lastName = entry.Name()
+			if prefix == "" || strings.HasPrefix(entry.Name(), prefix) {
+				l.buffer = append(l.buffer, entry)
+			}
+			return true
+		}
+		var err error
+		if prefix == "" {
+			_, err = store.ListDirectoryEntries(ctx, l.dir, l.lastName, l.inclusive, shardListingPageSize, collect)
+		} else {
+			_, err = store.ListDirectoryPrefixedEntries(ctx, l.dir, l.lastName, l.inclusive, shardListingPageSize, prefix, collect)
+			if err == ErrUnsupportedListDirectoryPrefixed {
+				_, err = fsw.prefixFilterEntries(ctx, l.dir, l.lastName, l.inclusive, shardListingPageSize, prefix, collect)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %v", l.dir, err)
+		}
+		l.inclusive = false
+		if count < shardListingPageSize {
+			l.exhausted = true
+		}
+	}
+	if len(l.buffer) == 0 {
+		return nil, nil
+	}
+	return l.buffer[0], nil
+}
+
+func (l *shardListing) pop() *Entry {
+	entry := l.buffer[0]
+	l.buffer = l.buffer[1:]
+	return entry
+}
+
+// StartShardingDirectory marks the directory as being converted into hash-partitioned shards,
+// so new children go into the shards. The children are moved by MoveIntoShards, which should
+// wait DirShardsPropagationDelay for the other filers to pick up the conversion.
+// Starting an interrupted conversion again is allowed.
+func (fsw *FilerStoreWrapper) StartShardingDirectory(ctx context.Context, dirPath util.FullPath, shardCount int) error {
+	if shardCount < 2 || shardCount > MaxDirectoryShards {
+		return fmt.Errorf("shard count %d should be between 2 and %d", shardCount, MaxDirectoryShards)
+	}
+	dirs, err := fsw.loadDirectoryShards(ctx)
+	if err != nil {
+		return err
+	}
+	if existing, found := dirs[dirPath]; found && !existing.Converting {
+		return fmt.Errorf("%s is already sharded into %d shards", dirPath, existing.Shards)
+	} else if found && existing.Shards != shardCount {
+		return fmt.Errorf("%s is being converted into %d shards", dirPath, existing.Shards)
+	} else if found {
+		return nil
+	}
+	dirs[dirPath] = &DirectoryShards{Shards: shardCount, Converting: true}
+	return fsw.saveDirectoryShards(ctx, dirs)
+}
+
+// MoveIntoShards moves the children of a directory being converted into their shards, and completes the conversion.
+// progressFn is called with the number of children moved so far.
+func (fsw *FilerStoreWrapper) MoveIntoShards(ctx context.Context, dirPath util.FullPath, progressFn func(moved int64) error) error {
+	dirs, err := fsw.loadDirectoryShards(ctx)
+	if err != nil {
+		return err
+	}
+	shards, found := dirs[dirPath]
+	if !found || !shards.Converting {
+		return fmt.Errorf("%s is not being converted into shards", dirPath)
+	}
+
+	actualStore := fsw.getActualStore(dirPath + "/")
+	var moved int64
+	for {
+		var children []*Entry
+		if _, err = actualStore.ListDirectoryEntries(ctx, dirPath, "", false, shardListingPageSize, func(entry *Entry) bool {
+			children = append(children, entry)
+			return true
+		}); err != nil {
+			return fmt.Errorf("list %s: %v", dirPath, err)
+		}
+		if len(children) == 0 {
+			break
+		}
+		for _, child := range children {
+			name := child.Name()
+			shardedPath := shards.shardDir(dirPath, name).Child(name)
+			if _, findErr := actualStore.FindEntry(ctx, shardedPath); findErr == filer_pb.ErrNotFound {
+				child.FullPath = shardedPath
+				if err = actualStore.InsertEntry(ctx, child); err != nil {
+					return fmt.Errorf("move %s into shard: %v", dirPath.Child(name), err)
+				}
+			}
+			if err = actualStore.DeleteEntry(ctx, dirPath.Child(name)); err != nil {
+				return fmt.Errorf("delete unsharded %s: %v", dirPath.Child(name), err)
+			}
+			moved++
+		}
+		if progressFn != nil {
+			if err = progressFn(moved); err != nil {
+				return err
+			}
+		}
+	}
+
+	dirs, err = fsw.loadDirectoryShards(ctx)
+	if err != nil {
+		return err
+	}
+	dirs[dirPath] = &DirectoryShards{Shards: shards.Shards}
+	glog.V(0).Infof("sharded %s into %d shards, moved %d entries", dirPath, shards.Shards, moved)
+	return fsw.saveDirectoryShards(ctx, dirs)
+}
+
+// withShardedPath runs fn with the entry moved to its path in the shard.
+func withShardedPath(entry *Entry, shardedPath util.FullPath, fn func() error) error {
+	if isDirShardName(entry.Name()) {
+		return fmt.Errorf("names starting with %s are reserved in sharded directories", dirShardNamePrefix)
+	}
+	logicalPath := entry.FullPath
+	entry.FullPath = shardedPath
+	defer func() {
+		entry.FullPath = logicalPath
+	}()
+	return fn()
+}
+
+func (fsw *FilerStoreWrapper) updateShardedEntry(ctx context.Context, store FilerStore, entry *Entry, shardedPath util.FullPath, shards *DirectoryShards) error {
+	return withShardedPath(entry, shardedPath, func() error {
+		if shards.Converting {
+			// the entry may not have been moved into its shard yet
+			if _, err := store.FindEntry(ctx, shardedPath); err == filer_pb.ErrNotFound {
+				return store.InsertEntry(ctx, entry)
+			}
+		}
+		return store.UpdateEntry(ctx, entry)
+	})
+}
+
+func (fsw *FilerStoreWrapper) findShardedEntry(ctx context.Context, store FilerStore, fp util.FullPath, shardedPath util.FullPath, shards *DirectoryShards) (entry *Entry, err error) {
+	entry, err = store.FindEntry(ctx, shardedPath)
+	if err == filer_pb.ErrNotFound && shards.Converting {
+		entry, err = store.FindEntry(ctx, fp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry.FullPath = fp
+	return entry, nil
+}
+
+func (fsw *FilerStoreWrapper) deleteShardedEntry(ctx context.Context, store FilerStore, fp util.FullPath, shardedPath util.FullPath, shards *DirectoryShards) error {
+	if err := store.DeleteEntry(ctx, shardedPath); err != nil {
+		return err
+	}
+	if shards.Converting {
+		return store.DeleteEntry(ctx, fp)
+	}
+	return nil
+}
+
+func (fsw *FilerStoreWrapper) deleteShardedFolderChildren(ctx context.Context, store FilerStore, fp util.FullPath, shards *DirectoryShards) error {
+	for _, shardDir := range shards.shardDirs(fp) {
+		if err := store.DeleteFolderChildren(ctx, shardDir); err != nil {
+			return err
+		}
+	}
+	return store.DeleteFolderChildren(ctx, fp)
+}
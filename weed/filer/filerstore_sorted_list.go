@@ -0,0 +1,111 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+type SortBy int
+
+const (
+	SortByName SortBy = iota
+	SortByMtime
+	SortBySize
+)
+
+var ErrUnsupportedSortedListing = errors.New("unsupported sorted directory listing")
+
+// SortedListingStore is implemented by the stores keeping secondary indexes
+// of the directory entries by mtime and size.
+type SortedListingStore interface {
+	// ListDirectorySortedEntries lists the entries ordered by the sort key, and then by the name.
+	// If startFileName is not empty, the listing starts after the entry of startSortKey and startFileName.
+	ListDirectorySortedEntries(ctx context.Context, dirPath util.FullPath, sortBy SortBy, descending bool, startSortKey uint64, startFileName string, limit int64, eachEntryFunc ListEachEntryFunc) error
+}
+
+// SortKey returns the value of the entry to sort by. Directories have the size 0.
+func (sortBy SortBy) SortKey(entry *Entry) uint64 {
+	switch sortBy {
+	case SortByMtime:
+		if mtime := entry.Attr.Mtime.Unix(); mtime > 0 {
+			return uint64(mtime)
+		}
+	case SortBySize:
+		if !entry.IsDirectory() {
+			return entry.Size()
+		}
+	}
+	return 0
+}
+
+func (fsw *FilerStoreWrapper) ListDirectorySortedEntries(ctx context.Context, dirPath util.FullPath, sortBy SortBy, descending bool, startSortKey uint64, startFileName string, limit int64, eachEntryFunc ListEachEntryFunc) error {
+	actualStore := fsw.getActualStore(dirPath + "/")
+	sortedStore, ok := actualStore.(SortedListingStore)
+	if !ok || sortBy == SortByName {
+		return ErrUnsupportedSortedListing
+	}
+	if fsw.directoryShards(ctx, dirPath) != nil {
+		// the entries are spread over the shard directories
+		return ErrUnsupportedSortedListing
+	}
+	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "sortedList").Inc()
+	start := time.Now()
+	defer func() {
+		stats.FilerStoreHistogram.WithLabelValues(actualStore.GetName(), "sortedList").Observe(time.Since(start).Seconds())
+	}()
+	if limit > math.MaxInt32-1 {
+		limit = math.MaxInt32 - 1
+	}
+	return sortedStore.ListDirectorySortedEntries(ctx, dirPath, sortBy, descending, startSortKey, startFileName, limit, func(entry *Entry) bool {
+		fsw.maybeReadHardLink(ctx, entry)
+		fsw.maybeUnwrapCipherKeys(ctx, entry)
+		filer_pb.AfterEntryDeserialization(entry.GetChunks())
+		return eachEntryFunc(entry)
+	})
+}
+
+func (t *FilerStorePathTranslator) ListDirectorySortedEntries(ctx context.Context, dirPath util.FullPath, sortBy SortBy, descending bool, startSortKey uint64, startFileName string, limit int64, eachEntryFunc ListEachEntryFunc) error {
+	sortedStore, ok := t.actualStore.(SortedListingStore)
+	if !ok {
+		return ErrUnsupportedSortedListing
+	}
+	return sortedStore.ListDirectorySortedEntries(ctx, t.translatePath(dirPath), sortBy, descending, startSortKey, startFileName, limit, func(entry *Entry) bool {
+		entry.FullPath = dirPath[:len(t.storeRoot)-1] + entry.FullPath
+		return eachEntryFunc(entry)
+	})
+}
+
+// StreamListSortedDirectoryEntries lists the entries ordered by mtime or size, and then by name,
+// starting after the entry of startSortKey and startFileName if startFileName is not empty.
+// It returns ErrUnsupportedSortedListing if the store does not index the directory entries.
+func (f *Filer) StreamListSortedDirectoryEntries(ctx context.Context, p util.FullPath, sortBy SortBy, descending bool, startSortKey uint64, startFileName string, limit int64, eachEntryFunc ListEachEntryFunc) error {
+	for limit > 0 {
+		var count, expiredCount int64
+		var stopped bool
+		err := f.Store.ListDirectorySortedEntries(ctx, p, sortBy, descending, startSortKey, startFileName, limit, func(entry *Entry) bool {
+			count++
+			startSortKey, startFileName = sortBy.SortKey(entry), entry.Name()
+			if entry.TtlSec > 0 && entry.Crtime.Add(time.Duration(entry.TtlSec)*time.Second).Before(time.Now()) {
+				f.Store.DeleteOneEntry(ctx, entry)
+				expiredCount++
+				return true
+			}
+			if !eachEntryFunc(entry) {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if err != nil || stopped || count < limit {
+			return err
+		}
+		limit = expiredCount
+	}
+	return nil
+}
@@ -28,12 +28,19 @@ type VirtualFilerStore interface {
 	OnBucketCreation(bucket string)
 	OnBucketDeletion(bucket string)
 	CanDropWholeBucket() bool
+	StartShardingDirectory(ctx context.Context, dirPath util.FullPath, shardCount int) error
+	MoveIntoShards(ctx context.Context, dirPath util.FullPath, progressFn func(moved int64) error) error
+	ListDirectorySortedEntries(ctx context.Context, dirPath util.FullPath, sortBy SortBy, descending bool, startSortKey uint64, startFileName string, limit int64, eachEntryFunc ListEachEntryFunc) error
+	ListHardLinks(ctx context.Context, eachHardLinkFn func(hardLinkId HardLinkId, entry *Entry) bool) error
 }
 
 type FilerStoreWrapper struct {
 	defaultStore   FilerStore
 	pathToStore    ptrie.Trie
 	storeIdToStore map[string]FilerStore
+	dirShards      directoryShardsCache
+	envelope       *EnvelopeEncryption
+	metaCache      *MetaCache
 }
 
 func NewFilerStoreWrapper(store FilerStore) *FilerStoreWrapper {
@@ -124,9 +131,21 @@ func (fsw *FilerStoreWrapper) InsertEntry(ctx context.Context, entry *Entry) err
 		entry.Mime = ""
 	}
 
+	entry, err := fsw.maybeWrapCipherKeys(ctx, entry)
+	if err != nil {
+		return err
+	}
+
 	if err := fsw.handleUpdateToHardLinks(ctx, entry); err != nil {
 		return err
 	}
+	defer fsw.invalidateCachedEntry(ctx, entry.FullPath)
+
+	if shardedPath, shards := fsw.shardedPath(ctx, entry.FullPath); shards != nil {
+		return withShardedPath(entry, shardedPath, func() error {
+			return actualStore.InsertEntry(ctx, entry)
+		})
+	}
 
 	// glog.V(4).Infof("InsertEntry %s", entry.FullPath)
 	return actualStore.InsertEntry(ctx, entry)
@@ -145,9 +164,19 @@ func (fsw *FilerStoreWrapper) UpdateEntry(ctx context.Context, entry *Entry) err
 		entry.Mime = ""
 	}
 
+	entry, err := fsw.maybeWrapCipherKeys(ctx, entry)
+	if err != nil {
+		return err
+	}
+
 	if err := fsw.handleUpdateToHardLinks(ctx, entry); err != nil {
 		return err
 	}
+	defer fsw.invalidateCachedEntry(ctx, entry.FullPath)
+
+	if shardedPath, shards := fsw.shardedPath(ctx, entry.FullPath); shards != nil {
+		return fsw.updateShardedEntry(ctx, actualStore, entry, shardedPath, shards)
+	}
 
 	// glog.V(4).Infof("UpdateEntry %s", entry.FullPath)
 	return actualStore.UpdateEntry(ctx, entry)
@@ -161,16 +190,23 @@ func (fsw *FilerStoreWrapper) FindEntry(ctx context.Context, fp util.FullPath) (
 		stats.FilerStoreHistogram.WithLabelValues(actualStore.GetName(), "find").Observe(time.Since(start).Seconds())
 	}()
 
-	entry, err = actualStore.FindEntry(ctx, fp)
-	// glog.V(4).Infof("FindEntry %s: %v", fp, err)
-	if err != nil {
-		return nil, err
-	}
+	return fsw.findCachedEntry(fp, func() (entry *Entry, err error) {
+		if shardedPath, shards := fsw.shardedPath(ctx, fp); shards != nil {
+			entry, err = fsw.findShardedEntry(ctx, actualStore, fp, shardedPath, shards)
+		} else {
+			entry, err = actualStore.FindEntry(ctx, fp)
+		}
+		// glog.V(4).Infof("FindEntry %s: %v", fp, err)
+		if err != nil {
+			return nil, err
+		}
 
-	fsw.maybeReadHardLink(ctx, entry)
+		fsw.maybeReadHardLink(ctx, entry)
+		fsw.maybeUnwrapCipherKeys(ctx, entry)
 
-	filer_pb.AfterEntryDeserialization(entry.GetChunks())
-	return
+		filer_pb.AfterEntryDeserialization(entry.GetChunks())
+		return
+	})
 }
 
 func (fsw *FilerStoreWrapper) DeleteEntry(ctx context.Context, fp util.FullPath) (err error) {
@@ -185,6 +221,7 @@ func (fsw *FilerStoreWrapper) DeleteEntry(ctx context.Context, fp util.FullPath)
 	if findErr == filer_pb.ErrNotFound {
 		return nil
 	}
+	defer fsw.invalidateCachedEntry(ctx, fp)
 	if len(existingEntry.HardLinkId) != 0 {
 		// remove hard link
 		op := ctx.Value("OP")
@@ -196,6 +233,10 @@ func (fsw *FilerStoreWrapper) DeleteEntry(ctx context.Context, fp util.FullPath)
 		}
 	}
 
+	if shardedPath, shards := fsw.shardedPath(ctx, fp); shards != nil {
+		return fsw.deleteShardedEntry(ctx, actualStore, fp, shardedPath, shards)
+	}
+
 	// glog.V(4).Infof("DeleteEntry %s", fp)
 	return actualStore.DeleteEntry(ctx, fp)
 }
@@ -207,6 +248,7 @@ func (fsw *FilerStoreWrapper) DeleteOneEntry(ctx context.Context, existingEntry
 	defer func() {
 		stats.FilerStoreHistogram.WithLabelValues(actualStore.GetName(), "delete").Observe(time.Since(start).Seconds())
 	}()
+	defer fsw.invalidateCachedEntry(ctx, existingEntry.FullPath)
 
 	if len(existingEntry.HardLinkId) != 0 {
 		// remove hard link
@@ -219,6 +261,10 @@ func (fsw *FilerStoreWrapper) DeleteOneEntry(ctx context.Context, existingEntry
 		}
 	}
 
+	if shardedPath, shards := fsw.shardedPath(ctx, existingEntry.FullPath); shards != nil {
+		return fsw.deleteShardedEntry(ctx, actualStore, existingEntry.FullPath, shardedPath, shards)
+	}
+
 	// glog.V(4).Infof("DeleteOneEntry %s", existingEntry.FullPath)
 	return actualStore.DeleteEntry(ctx, existingEntry.FullPath)
 }
@@ -230,6 +276,11 @@ func (fsw *FilerStoreWrapper) DeleteFolderChildren(ctx context.Context, fp util.
 	defer func() {
 		stats.FilerStoreHistogram.WithLabelValues(actualStore.GetName(), "deleteFolderChildren").Observe(time.Since(start).Seconds())
 	}()
+	defer fsw.invalidateCachedEntries(ctx)
+
+	if shards := fsw.directoryShards(ctx, fp); shards != nil {
+		return fsw.deleteShardedFolderChildren(ctx, actualStore, fp, shards)
+	}
 
 	// glog.V(4).Infof("DeleteFolderChildren %s", fp)
 	return actualStore.DeleteFolderChildren(ctx, fp)
@@ -243,11 +294,21 @@ func (fsw *FilerStoreWrapper) ListDirectoryEntries(ctx context.Context, dirPath
 		stats.FilerStoreHistogram.WithLabelValues(actualStore.GetName(), "list").Observe(time.Since(start).Seconds())
 	}()
 
+	adjustedEntryFunc := func(eachEntryFunc ListEachEntryFunc) ListEachEntryFunc {
+		return func(entry *Entry) bool {
+			fsw.maybeReadHardLink(ctx, entry)
+			fsw.maybeUnwrapCipherKeys(ctx, entry)
+			filer_pb.AfterEntryDeserialization(entry.GetChunks())
+			return eachEntryFunc(entry)
+		}
+	}
+	if shards := fsw.directoryShards(ctx, dirPath); shards != nil {
+		return fsw.listShardedEntries(ctx, actualStore, dirPath, shards, startFileName, includeStartFile, limit, "", adjustedEntryFunc(eachEntryFunc))
+	}
+
 	// glog.V(4).Infof("ListDirectoryEntries %s from %s limit %d", dirPath, startFileName, limit)
-	return actualStore.ListDirectoryEntries(ctx, dirPath, startFileName, includeStartFile, limit, func(entry *Entry) bool {
-		fsw.maybeReadHardLink(ctx, entry)
-		filer_pb.AfterEntryDeserialization(entry.GetChunks())
-		return eachEntryFunc(entry)
+	return fsw.listCachedEntries(dirPath, startFileName, includeStartFile, limit, "", eachEntryFunc, func(eachEntryFunc ListEachEntryFunc) (string, error) {
+		return actualStore.ListDirectoryEntries(ctx, dirPath, startFileName, includeStartFile, limit, adjustedEntryFunc(eachEntryFunc))
 	})
 }
 
@@ -262,16 +323,24 @@ func (fsw *FilerStoreWrapper) ListDirectoryPrefixedEntries(ctx context.Context,
 		limit = math.MaxInt32 - 1
 	}
 	// glog.V(4).Infof("ListDirectoryPrefixedEntries %s from %s prefix %s limit %d", dirPath, startFileName, prefix, limit)
-	adjustedEntryFunc := func(entry *Entry) bool {
-		fsw.maybeReadHardLink(ctx, entry)
-		filer_pb.AfterEntryDeserialization(entry.GetChunks())
-		return eachEntryFunc(entry)
+	adjustedEntryFunc := func(eachEntryFunc ListEachEntryFunc) ListEachEntryFunc {
+		return func(entry *Entry) bool {
+			fsw.maybeReadHardLink(ctx, entry)
+			fsw.maybeUnwrapCipherKeys(ctx, entry)
+			filer_pb.AfterEntryDeserialization(entry.GetChunks())
+			return eachEntryFunc(entry)
+		}
 	}
-	lastFileName, err = actualStore.ListDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, prefix, adjustedEntryFunc)
-	if err == ErrUnsupportedListDirectoryPrefixed {
-		lastFileName, err = fsw.prefixFilterEntries(ctx, dirPath, startFileName, includeStartFile, limit, prefix, adjustedEntryFunc)
+	if shards := fsw.directoryShards(ctx, dirPath); shards != nil {
+		return fsw.listShardedEntries(ctx, actualStore, dirPath, shards, startFileName, includeStartFile, limit, prefix, adjustedEntryFunc(eachEntryFunc))
 	}
-	return lastFileName, err
+	return fsw.listCachedEntries(dirPath, startFileName, includeStartFile, limit, prefix, eachEntryFunc, func(eachEntryFunc ListEachEntryFunc) (lastFileName string, err error) {
+		lastFileName, err = actualStore.ListDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, prefix, adjustedEntryFunc(eachEntryFunc))
+		if err == ErrUnsupportedListDirectoryPrefixed {
+			lastFileName, err = fsw.prefixFilterEntries(ctx, dirPath, startFileName, includeStartFile, limit, prefix, adjustedEntryFunc(eachEntryFunc))
+		}
+		return lastFileName, err
+	})
 }
 
 func (fsw *FilerStoreWrapper) prefixFilterEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc) (lastFileName string, err error) {
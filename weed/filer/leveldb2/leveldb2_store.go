@@ -25,8 +25,10 @@ func init() {
 }
 
 type LevelDB2Store struct {
-	dbs     []*leveldb.DB
-	dbCount int
+	dbs                 []*leveldb.DB
+	dbCount             int
+	sortIndex           bool
+	sortIndexGeneration []byte
 }
 
 func (store *LevelDB2Store) GetName() string {
@@ -35,6 +37,7 @@ func (store *LevelDB2Store) GetName() string {
 
 func (store *LevelDB2Store) Initialize(configuration weed_util.Configuration, prefix string) (err error) {
 	dir := configuration.GetString(prefix + "dir")
+	store.sortIndex = configuration.GetBool(prefix + "sortIndex")
 	return store.initialize(dir, 8)
 }
 
@@ -66,7 +69,7 @@ func (store *LevelDB2Store) initialize(dir string, dbCount int) (err error) {
 	}
 	store.dbCount = dbCount
 
-	return
+	return store.initializeSortIndex()
 }
 
 func (store *LevelDB2Store) BeginTransaction(ctx context.Context) (context.Context, error) {
@@ -92,7 +95,16 @@ func (store *LevelDB2Store) InsertEntry(ctx context.Context, entry *filer.Entry)
 		value = weed_util.MaybeGzipData(value)
 	}
 
-	err = store.dbs[partitionId].Put(key, value, nil)
+	if store.sortIndex {
+		batch := new(leveldb.Batch)
+		if err = store.updateSortIndexes(batch, store.dbs[partitionId], key, name, entry); err != nil {
+			return fmt.Errorf("index %s : %v", entry.FullPath, err)
+		}
+		batch.Put(key, value)
+		err = store.dbs[partitionId].Write(batch, nil)
+	} else {
+		err = store.dbs[partitionId].Put(key, value, nil)
+	}
 
 	if err != nil {
 		return fmt.Errorf("persisting %s : %v", entry.FullPath, err)
@@ -138,7 +150,16 @@ func (store *LevelDB2Store) DeleteEntry(ctx context.Context, fullpath weed_util.
 	dir, name := fullpath.DirAndName()
 	key, partitionId := genKey(dir, name, store.dbCount)
 
-	err = store.dbs[partitionId].Delete(key, nil)
+	if store.sortIndex {
+		batch := new(leveldb.Batch)
+		if err = store.updateSortIndexes(batch, store.dbs[partitionId], key, name, nil); err != nil {
+			return fmt.Errorf("unindex %s : %v", fullpath, err)
+		}
+		batch.Delete(key)
+		err = store.dbs[partitionId].Write(batch, nil)
+	} else {
+		err = store.dbs[partitionId].Delete(key, nil)
+	}
 	if err != nil {
 		return fmt.Errorf("delete %s : %v", fullpath, err)
 	}
@@ -165,6 +186,10 @@ func (store *LevelDB2Store) DeleteFolderChildren(ctx context.Context, fullpath w
 	}
 	iter.Release()
 
+	if store.sortIndex {
+		store.deleteSortIndexes(batch, store.dbs[partitionId], directoryPrefix)
+	}
+
 	err = store.dbs[partitionId].Write(batch, nil)
 
 	if err != nil {
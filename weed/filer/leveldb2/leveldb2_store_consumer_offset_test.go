@@ -0,0 +1,45 @@
+package leveldb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+)
+
+func TestConsumerOffset(t *testing.T) {
+	testFiler := filer.NewFiler(pb.ServerDiscovery{}, nil, "", "", "", "", "", nil)
+	store := &LevelDB2Store{}
+	store.initialize(t.TempDir(), 2)
+	testFiler.SetStore(store)
+
+	ctx := context.Background()
+
+	if offset, err := testFiler.GetConsumerOffset(ctx, "backup"); err != nil || offset != nil {
+		t.Fatalf("offset before commit: %+v %v", offset, err)
+	}
+
+	testFiler.CommitConsumerOffset(ctx, "backup", 200, false)
+	testFiler.CommitConsumerOffset(ctx, "index", 100, false)
+
+	// an older offset is ignored, unless rewinding
+	if offset, _ := testFiler.CommitConsumerOffset(ctx, "backup", 150, false); offset.TsNs != 200 {
+		t.Errorf("committed older offset: %d", offset.TsNs)
+	}
+	if offset, _ := testFiler.CommitConsumerOffset(ctx, "index", 50, true); offset.TsNs != 50 {
+		t.Errorf("rewound offset: %d", offset.TsNs)
+	}
+
+	offsets, err := testFiler.ListConsumerOffsets(ctx)
+	if err != nil || len(offsets) != 2 || offsets[0].Name != "backup" || offsets[1].Name != "index" {
+		t.Fatalf("list offsets: %+v %v", offsets, err)
+	}
+
+	if err = testFiler.DeleteConsumerOffset(ctx, "backup"); err != nil {
+		t.Fatalf("delete offset: %v", err)
+	}
+	if offsets, _ = testFiler.ListConsumerOffsets(ctx); len(offsets) != 1 || offsets[0].Name != "index" {
+		t.Errorf("list offsets after deletion: %+v", offsets)
+	}
+}
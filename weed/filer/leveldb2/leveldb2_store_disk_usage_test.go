@@ -0,0 +1,103 @@
+package leveldb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestDiskUsage(t *testing.T) {
+	testFiler := filer.NewFiler(pb.ServerDiscovery{}, nil, "", "", "", "", "", nil)
+	store := &LevelDB2Store{}
+	store.initialize(t.TempDir(), 2)
+	testFiler.SetStore(store)
+
+	ctx := context.Background()
+
+	create := func(p util.FullPath, hardLinkId filer.HardLinkId, chunks ...*filer_pb.FileChunk) {
+		entry := &filer.Entry{
+			FullPath:   p,
+			Attr:       filer.Attr{Mode: 0644, Uid: 1000},
+			Chunks:     chunks,
+			HardLinkId: hardLinkId,
+		}
+		if len(hardLinkId) > 0 {
+			entry.HardLinkCounter = 2
+		}
+		entry.FileSize = filer.FileSize(entry.ToProtoEntry())
+		if err := testFiler.CreateEntry(ctx, entry, false, false, nil, false); err != nil {
+			t.Fatalf("create %s: %v", p, err)
+		}
+	}
+
+	// a deduplicated chunk shared by two files
+	create("/home/a/one", nil, &filer_pb.FileChunk{FileId: "1,0101", Size: 100}, &filer_pb.FileChunk{FileId: "1,0303", Offset: 100, Size: 50})
+	create("/home/b/two", nil, &filer_pb.FileChunk{FileId: "1,0202", Size: 20}, &filer_pb.FileChunk{FileId: "1,0303", Offset: 20, Size: 50})
+	hardLinkId := filer.NewHardLinkId()
+	create("/home/a/link1", hardLinkId, &filer_pb.FileChunk{FileId: "1,0404", Size: 10})
+	create("/home/b/link2", hardLinkId, &filer_pb.FileChunk{FileId: "1,0404", Size: 10})
+	// a small file packed into a shared chunk counts the whole shared chunk
+	create("/home/b/packed", nil, &filer_pb.FileChunk{FileId: "1,0505", Size: 5, PackOffset: 20, PackSize: 40})
+
+	report, err := testFiler.DiskUsage(ctx, "/home", filer.DiskUsageOptions{Depth: 1})
+	if err != nil {
+		t.Fatalf("disk usage: %v", err)
+	}
+	total, children := report.Total, report.Children
+	if total.FileCount != 4 || total.HardLinkFileCount != 1 || total.DirectoryCount != 2 {
+		t.Errorf("total counts: %+v", total)
+	}
+	if total.LogicalSize != 150+70+10+5 || total.PhysicalSize != 100+50+20+10+40 || total.SharedChunkCount != 1 {
+		t.Errorf("total sizes: %+v", total)
+	}
+
+	if len(children) != 2 || children[0].Path != "/home/a" || children[1].Path != "/home/b" {
+		t.Fatalf("children: %+v", children)
+	}
+	if a := children[0]; a.FileCount != 2 || a.PhysicalSize != 100+50+10 {
+		t.Errorf("child a: %+v", a)
+	}
+	// the chunk shared with a, and the hard link, are counted in b, too
+	if b := children[1]; b.FileCount != 3 || b.PhysicalSize != 20+50+10+40 || b.HardLinkFileCount != 0 {
+		t.Errorf("child b: %+v", b)
+	}
+
+	// a file owned by alice with a TTL, two levels down
+	tmp := &filer.Entry{
+		FullPath: "/home/b/tmp/scratch",
+		Attr:     filer.Attr{Mode: 0644, UserName: "alice", TtlSec: 7 * 24 * 3600, Crtime: time.Now()},
+		Chunks:   []*filer_pb.FileChunk{{FileId: "2,0606", Size: 30}},
+	}
+	tmp.FileSize = 30
+	if err = testFiler.CreateEntry(ctx, tmp, false, false, nil, false); err != nil {
+		t.Fatalf("create %s: %v", tmp.FullPath, err)
+	}
+	report, err = testFiler.DiskUsage(ctx, "/home", filer.DiskUsageOptions{Depth: 2, Breakdown: true})
+	if err != nil {
+		t.Fatalf("disk usage: %v", err)
+	}
+	var paths []string
+	for _, child := range report.Children {
+		paths = append(paths, string(child.Path))
+	}
+	if len(paths) != 8 || paths[1] != "/home/a/link1" || paths[6] != "/home/b/tmp" {
+		t.Errorf("children down to depth 2: %v", paths)
+	}
+	if b := report.Children[3]; b.Path != "/home/b" || b.FileCount != 4 || b.DirectoryCount != 1 {
+		t.Errorf("child b: %+v", b)
+	}
+	if len(report.Owners) != 2 || report.Owners[0].Key != "1000" || report.Owners[0].FileCount != 4 || report.Owners[1].Key != "alice" || report.Owners[1].LogicalSize != 30 {
+		t.Errorf("owners: %+v", report.Owners)
+	}
+	if len(report.TtlClasses) != 2 || report.TtlClasses[0].Key != "" || report.TtlClasses[1].Key != "1w" || report.TtlClasses[1].FileCount != 1 {
+		t.Errorf("ttl classes: %+v", report.TtlClasses)
+	}
+	if len(report.Collections) != 1 || report.Collections[0].FileCount != 5 {
+		t.Errorf("collections: %+v", report.Collections)
+	}
+}
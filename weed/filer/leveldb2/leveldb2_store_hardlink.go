@@ -0,0 +1,41 @@
+package leveldb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+)
+
+// hard link ids are 16 random bytes and the marker byte, so all of them are kept in the same kv partition
+const hardLinkIdLength = 17
+
+func (store *LevelDB2Store) ListHardLinks(ctx context.Context, eachHardLinkFn func(hardLinkId filer.HardLinkId, entry *filer.Entry) bool) error {
+
+	partitionId := bucketKvKey([]byte{filer.HARD_LINK_MARKER}, store.dbCount)
+
+	iter := store.dbs[partitionId].NewIterator(nil, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != hardLinkIdLength || key[len(key)-1] != filer.HARD_LINK_MARKER {
+			continue
+		}
+		entry := &filer.Entry{}
+		if decodeErr := entry.DecodeAttributesAndChunks(iter.Value()); decodeErr != nil {
+			// not a hard link
+			continue
+		}
+		hardLinkId := make(filer.HardLinkId, len(key))
+		copy(hardLinkId, key)
+		if !eachHardLinkFn(hardLinkId, entry) {
+			break
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("kv bucket %d list hard links: %v", partitionId, err)
+	}
+	return nil
+}
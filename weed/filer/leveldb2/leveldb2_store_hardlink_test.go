@@ -0,0 +1,87 @@
+package leveldb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestCheckHardLinks(t *testing.T) {
+	testFiler := filer.NewFiler(pb.ServerDiscovery{}, nil, "", "", "", "", "", nil)
+	store := &LevelDB2Store{}
+	store.initialize(t.TempDir(), 2)
+	testFiler.SetStore(store)
+
+	ctx := context.Background()
+
+	createLinks := func(hardLinkId filer.HardLinkId, counter int32, paths ...util.FullPath) {
+		for _, p := range paths {
+			entry := &filer.Entry{
+				FullPath:        p,
+				Attr:            filer.Attr{Mode: 0644},
+				HardLinkId:      hardLinkId,
+				HardLinkCounter: counter,
+			}
+			if err := testFiler.CreateEntry(ctx, entry, false, false, nil, false); err != nil {
+				t.Fatalf("create %s: %v", p, err)
+			}
+		}
+	}
+
+	healthy := filer.NewHardLinkId()
+	createLinks(healthy, 2, "/a/healthy1", "/b/healthy2")
+	miscounted := filer.NewHardLinkId()
+	createLinks(miscounted, 3, "/a/miscounted1", "/a/b/miscounted2")
+	dangling := filer.NewHardLinkId()
+	createLinks(dangling, 1, "/dangling")
+	if err := store.KvDelete(ctx, dangling); err != nil {
+		t.Fatalf("delete dangling: %v", err)
+	}
+	orphaned := filer.NewHardLinkId()
+	putOrphan := func(chunks []*filer_pb.FileChunk) {
+		blob, _ := (&filer.Entry{HardLinkId: orphaned, HardLinkCounter: 1, Chunks: chunks}).EncodeAttributesAndChunks()
+		if err := store.KvPut(ctx, orphaned, blob); err != nil {
+			t.Fatalf("put orphan: %v", err)
+		}
+	}
+	putOrphan([]*filer_pb.FileChunk{{FileId: "1,0101", Size: 100}, {FileId: "1,0202", Size: 23}})
+
+	problems := make(map[filer.HardLinkProblemKind]*filer.HardLinkProblem)
+	report, err := testFiler.CheckHardLinks(ctx, false, func(problem *filer.HardLinkProblem) error {
+		problems[problem.Kind] = problem
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if report.HardLinks != 3 || report.Dangling != 1 || report.Miscounted != 1 || report.Orphaned != 1 || !report.OrphansChecked {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.ReclaimableBytes != 123 || report.Repaired != 0 {
+		t.Errorf("unexpected reclaimable %d repaired %d", report.ReclaimableBytes, report.Repaired)
+	}
+	if p := problems[filer.HardLinkMiscounted]; p.Counter != 3 || p.References != 2 {
+		t.Errorf("unexpected miscounted %+v", p)
+	}
+
+	// avoid deleting chunks on volume servers
+	putOrphan(nil)
+	if report, err = testFiler.CheckHardLinks(ctx, true, func(problem *filer.HardLinkProblem) error { return nil }); err != nil || report.Repaired != 3 {
+		t.Fatalf("repair: %+v, %v", report, err)
+	}
+
+	report, err = testFiler.CheckHardLinks(ctx, false, func(problem *filer.HardLinkProblem) error {
+		t.Errorf("unexpected problem after repair %+v", problem)
+		return nil
+	})
+	if err != nil || report.HardLinks != 3 {
+		t.Fatalf("check after repair: %+v, %v", report, err)
+	}
+	if _, err = store.KvGet(ctx, orphaned); err != filer.ErrKvNotFound {
+		t.Errorf("orphan not deleted: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package leveldb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestStoreMigration(t *testing.T) {
+	testFiler := filer.NewFiler(pb.ServerDiscovery{}, nil, "", "", "", "", "", nil)
+	source := &LevelDB2Store{}
+	source.initialize(t.TempDir(), 2)
+	testFiler.SetStore(source)
+	target := &LevelDB2Store{}
+	target.initialize(t.TempDir(), 2)
+
+	ctx := context.Background()
+	createFile := func(p util.FullPath) {
+		entry := &filer.Entry{FullPath: p, Attr: filer.Attr{Mode: 0644, Mtime: time.Now()}}
+		if err := testFiler.CreateEntry(ctx, entry, false, false, nil, false); err != nil {
+			t.Fatalf("create %s: %v", p, err)
+		}
+	}
+	for i := 0; i < 50; i++ {
+		createFile(util.FullPath(fmt.Sprintf("/dir%d/file%d", i%5, i)))
+	}
+	// only in the target store, to be removed by the verification
+	target.InsertEntry(ctx, &filer.Entry{FullPath: "/stale", Attr: filer.Attr{Mode: 0644}})
+
+	migration := testFiler.Store.(*filer.FilerStoreWrapper).MigrateDefaultStore(target, 0, true)
+	if migration == nil {
+		t.Fatalf("migration not started")
+	}
+	// changes during the migration
+	createFile("/dir0/new")
+	if err := testFiler.DeleteEntryMetaAndData(ctx, "/dir1/file1", false, false, false, false, nil); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	for deadline := time.Now().Add(10 * time.Second); migration.Status().Phase != filer.MigrationCutOver; {
+		if time.Now().After(deadline) {
+			t.Fatalf("not cut over: %+v", migration.Status())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for i := 0; i < 50; i++ {
+		p := util.FullPath(fmt.Sprintf("/dir%d/file%d", i%5, i))
+		_, err := target.FindEntry(ctx, p)
+		if p == "/dir1/file1" {
+			if err != filer_pb.ErrNotFound {
+				t.Errorf("deleted %s in the target store: %v", p, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("find %s in the target store: %v", p, err)
+		}
+	}
+	if _, err := target.FindEntry(ctx, "/dir0/new"); err != nil {
+		t.Errorf("find the new file in the target store: %v", err)
+	}
+	if _, err := target.FindEntry(ctx, "/stale"); err != filer_pb.ErrNotFound {
+		t.Errorf("stale entry in the target store: %v", err)
+	}
+
+	// after the cut over, both stores are still written
+	createFile("/after")
+	for _, store := range []filer.FilerStore{source, target} {
+		if _, err := store.FindEntry(ctx, "/after"); err != nil {
+			t.Errorf("find the file created after the cut over: %v", err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package leveldb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func listNames(t *testing.T, fsw *filer.FilerStoreWrapper, startFileName string, includeStartFile bool, limit int64, prefix string) (names []string) {
+	_, err := fsw.ListDirectoryPrefixedEntries(context.Background(), "/dir", startFileName, includeStartFile, limit, prefix, func(entry *filer.Entry) bool {
+		assert.Equal(t, util.NewFullPath("/dir", entry.Name()), entry.FullPath)
+		names = append(names, entry.Name())
+		return true
+	})
+	assert.Nil(t, err)
+	return
+}
+
+func expectedNames(from, to int) (names []string) {
+	for i := from; i <= to; i++ {
+		names = append(names, fmt.Sprintf("f%04d", i))
+	}
+	return
+}
+
+func TestShardDirectory(t *testing.T) {
+	store := &LevelDB2Store{}
+	assert.Nil(t, store.initialize(t.TempDir(), 2))
+	fsw := filer.NewFilerStoreWrapper(store)
+	ctx := context.Background()
+
+	// more children than one page of the conversion
+	const childCount = 1100
+	for i := 0; i < childCount; i++ {
+		assert.Nil(t, fsw.InsertEntry(ctx, &filer.Entry{FullPath: util.FullPath(fmt.Sprintf("/dir/f%04d", i)), Attr: filer.Attr{Mode: 0644, FileSize: uint64(i)}}))
+	}
+
+	assert.NotNil(t, fsw.StartShardingDirectory(ctx, "/dir", 1))
+	assert.Nil(t, fsw.StartShardingDirectory(ctx, "/dir", 4))
+	assert.Nil(t, fsw.StartShardingDirectory(ctx, "/dir", 4), "start the interrupted conversion again")
+	assert.NotNil(t, fsw.StartShardingDirectory(ctx, "/dir", 8))
+
+	// written into the shards before being moved
+	assert.Nil(t, fsw.UpdateEntry(ctx, &filer.Entry{FullPath: "/dir/f1050", Attr: filer.Attr{Mode: 0644, FileSize: 1}}))
+	assert.Nil(t, fsw.InsertEntry(ctx, &filer.Entry{FullPath: "/dir/f1100", Attr: filer.Attr{Mode: 0644, FileSize: 1100}}))
+	_, err := store.FindEntry(ctx, "/dir/f1100")
+	assert.Equal(t, filer_pb.ErrNotFound, err, "new children go into the shards")
+
+	checkListings := func() {
+		assert.Equal(t, expectedNames(0, childCount), listNames(t, fsw, "", false, 2000, ""))
+		assert.Equal(t, expectedNames(1006, 1100), listNames(t, fsw, "f1005", false, 2000, "f1"))
+		assert.Equal(t, expectedNames(1005, 1009), listNames(t, fsw, "f1005", true, 5, "f10"))
+		assert.Equal(t, expectedNames(100, 199), listNames(t, fsw, "", false, 2000, "f01"))
+		assert.Equal(t, expectedNames(1098, 1100), listNames(t, fsw, "f1097", false, 2000, ""))
+		for _, i := range []int{0, 500, 1023, 1050, 1099, 1100} {
+			entry, err := fsw.FindEntry(ctx, util.FullPath(fmt.Sprintf("/dir/f%04d", i)))
+			if assert.Nil(t, err, "find f%04d", i) {
+				assert.Equal(t, util.FullPath(fmt.Sprintf("/dir/f%04d", i)), entry.FullPath)
+				if i == 1050 {
+					assert.Equal(t, uint64(1), entry.FileSize, "the updated copy wins")
+				}
+			}
+		}
+		_, err := fsw.FindEntry(ctx, "/dir/f2000")
+		assert.Equal(t, filer_pb.ErrNotFound, err)
+	}
+	checkListings()
+
+	var progress []int64
+	assert.Nil(t, fsw.MoveIntoShards(ctx, "/dir", func(moved int64) error {
+		progress = append(progress, moved)
+		// part of the children are moved
+		checkListings()
+		return nil
+	}))
+	assert.Equal(t, []int64{1024, childCount}, progress)
+	checkListings()
+
+	_, err = store.ListDirectoryEntries(ctx, "/dir", "", false, 10, func(entry *filer.Entry) bool {
+		t.Errorf("%s is not moved into its shard", entry.FullPath)
+		return true
+	})
+	assert.Nil(t, err)
+	assert.NotNil(t, fsw.StartShardingDirectory(ctx, "/dir", 4), "already sharded")
+	assert.NotNil(t, fsw.MoveIntoShards(ctx, "/dir", nil), "not being converted")
+
+	assert.Nil(t, fsw.DeleteEntry(ctx, "/dir/f0500"))
+	_, err = fsw.FindEntry(ctx, "/dir/f0500")
+	assert.Equal(t, filer_pb.ErrNotFound, err)
+	assert.Equal(t, childCount, len(listNames(t, fsw, "", false, 2000, "")))
+}
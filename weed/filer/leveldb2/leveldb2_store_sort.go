@@ -0,0 +1,209 @@
+package leveldb
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	leveldb_util "github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	weed_util "github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The sort indexes are kept in the partition of the directory, with the keys
+//   "sortidx" + sort by + directory hash + big endian sort key + file name
+// A directory is fully indexed once its marker key "sortidx" + 0 + directory hash
+// has the current index generation. The directories written before the indexes were
+// enabled are indexed on their first sorted listing. A new generation starts whenever
+// the indexes are enabled again, since the writes in between were not indexed.
+
+const sortIndexPrefix = "sortidx"
+
+var (
+	sortIndexGenerationKey = []byte(sortIndexPrefix + ".generation")
+	sortIndexes            = []filer.SortBy{filer.SortByMtime, filer.SortBySize}
+)
+
+func genSortIndexPrefix(sortBy filer.SortBy, dirHash []byte) []byte {
+	prefix := make([]byte, 0, len(sortIndexPrefix)+1+md5.Size+8)
+	prefix = append(prefix, sortIndexPrefix...)
+	prefix = append(prefix, byte(sortBy))
+	return append(prefix, dirHash[:md5.Size]...)
+}
+
+func genSortIndexKey(sortBy filer.SortBy, dirHash []byte, sortKey uint64, fileName string) []byte {
+	key := genSortIndexPrefix(sortBy, dirHash)
+	key = binary.BigEndian.AppendUint64(key, sortKey)
+	return append(key, fileName...)
+}
+
+func genSortIndexMarkerKey(dirHash []byte) []byte {
+	return genSortIndexPrefix(filer.SortByName, dirHash)
+}
+
+func (store *LevelDB2Store) initializeSortIndex() error {
+	db := store.dbs[0]
+	if !store.sortIndex {
+		return db.Delete(sortIndexGenerationKey, nil)
+	}
+	value, err := db.Get(sortIndexGenerationKey, nil)
+	if err == nil {
+		store.sortIndexGeneration = value
+		return nil
+	}
+	if err != leveldb.ErrNotFound {
+		return fmt.Errorf("read sort index generation: %v", err)
+	}
+	store.sortIndexGeneration = binary.BigEndian.AppendUint64(nil, uint64(time.Now().UnixNano()))
+	return db.Put(sortIndexGenerationKey, store.sortIndexGeneration, nil)
+}
+
+// updateSortIndexes replaces the index keys of the stored entry with those of the new entry,
+// or just removes them if the new entry is nil.
+func (store *LevelDB2Store) updateSortIndexes(batch *leveldb.Batch, db *leveldb.DB, key []byte, fileName string, newEntry *filer.Entry) error {
+	dirHash := key[:md5.Size]
+	data, err := db.Get(key, nil)
+	if err != nil && err != leveldb.ErrNotFound {
+		return err
+	}
+	if err == nil {
+		oldEntry := &filer.Entry{}
+		if decodeErr := oldEntry.DecodeAttributesAndChunks(weed_util.MaybeDecompressData(data)); decodeErr == nil {
+			for _, sortBy := range sortIndexes {
+				batch.Delete(genSortIndexKey(sortBy, dirHash, sortBy.SortKey(oldEntry), fileName))
+			}
+		}
+	}
+	if newEntry != nil {
+		for _, sortBy := range sortIndexes {
+			batch.Put(genSortIndexKey(sortBy, dirHash, sortBy.SortKey(newEntry), fileName), nil)
+		}
+	}
+	return nil
+}
+
+func (store *LevelDB2Store) deleteSortIndexes(batch *leveldb.Batch, db *leveldb.DB, dirHash []byte) {
+	for _, sortBy := range sortIndexes {
+		iter := db.NewIterator(leveldb_util.BytesPrefix(genSortIndexPrefix(sortBy, dirHash)), nil)
+		for iter.Next() {
+			batch.Delete(append([]byte(nil), iter.Key()...))
+		}
+		iter.Release()
+	}
+	batch.Delete(genSortIndexMarkerKey(dirHash))
+}
+
+// maybeBuildSortIndexes indexes the directory entries written before the indexes were enabled.
+func (store *LevelDB2Store) maybeBuildSortIndexes(db *leveldb.DB, dirPath weed_util.FullPath, dirHash []byte) error {
+	markerKey := genSortIndexMarkerKey(dirHash)
+	if generation, err := db.Get(markerKey, nil); err == nil && bytes.Equal(generation, store.sortIndexGeneration) {
+		return nil
+	}
+
+	glog.V(1).Infof("build sort indexes of %s", dirPath)
+	batch := new(leveldb.Batch)
+	iter := db.NewIterator(leveldb_util.BytesPrefix(dirHash), nil)
+	for iter.Next() {
+		fileName := getNameFromKey(iter.Key())
+		if fileName == "" {
+			continue
+		}
+		entry := &filer.Entry{}
+		if err := entry.DecodeAttributesAndChunks(weed_util.MaybeDecompressData(iter.Value())); err != nil {
+			glog.V(0).Infof("build sort indexes of %s: decode %s: %v", dirPath, fileName, err)
+			continue
+		}
+		for _, sortBy := range sortIndexes {
+			batch.Put(genSortIndexKey(sortBy, dirHash, sortBy.SortKey(entry), fileName), nil)
+		}
+		if batch.Len() >= 1024 {
+			if err := db.Write(batch, nil); err != nil {
+				iter.Release()
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	batch.Put(markerKey, store.sortIndexGeneration)
+	return db.Write(batch, nil)
+}
+
+func (store *LevelDB2Store) ListDirectorySortedEntries(ctx context.Context, dirPath weed_util.FullPath, sortBy filer.SortBy, descending bool, startSortKey uint64, startFileName string, limit int64, eachEntryFunc filer.ListEachEntryFunc) error {
+	if !store.sortIndex || (sortBy != filer.SortByMtime && sortBy != filer.SortBySize) {
+		return filer.ErrUnsupportedSortedListing
+	}
+
+	dirHash, partitionId := hashToBytes(string(dirPath), store.dbCount)
+	db := store.dbs[partitionId]
+	if err := store.maybeBuildSortIndexes(db, dirPath, dirHash); err != nil {
+		return fmt.Errorf("build sort indexes of %s: %v", dirPath, err)
+	}
+
+	indexPrefix := genSortIndexPrefix(sortBy, dirHash)
+	indexRange := leveldb_util.BytesPrefix(indexPrefix)
+	var startKey []byte
+	if startFileName != "" {
+		startKey = genSortIndexKey(sortBy, dirHash, startSortKey, startFileName)
+		if descending {
+			indexRange.Limit = startKey
+		} else {
+			indexRange.Start = startKey
+		}
+	}
+
+	iter := db.NewIterator(indexRange, nil)
+	defer iter.Release()
+	advance := iter.Next
+	ok := iter.First()
+	if descending {
+		advance = iter.Prev
+		ok = iter.Last()
+	}
+	for ; ok && limit > 0; ok = advance() {
+		indexKey := iter.Key()
+		if startKey != nil && bytes.Equal(indexKey, startKey) {
+			continue
+		}
+		sortKey := binary.BigEndian.Uint64(indexKey[len(indexPrefix):])
+		fileName := string(indexKey[len(indexPrefix)+8:])
+
+		data, err := db.Get(genKeyFromHash(dirHash, fileName), nil)
+		if err == leveldb.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("get %s/%s: %v", dirPath, fileName, err)
+		}
+		entry := &filer.Entry{
+			FullPath: weed_util.NewFullPath(string(dirPath), fileName),
+		}
+		if err = entry.DecodeAttributesAndChunks(weed_util.MaybeDecompressData(data)); err != nil {
+			return fmt.Errorf("decode %s: %v", entry.FullPath, err)
+		}
+		if sortBy.SortKey(entry) != sortKey {
+			// left over by a concurrent write while building the indexes
+			continue
+		}
+		limit--
+		if !eachEntryFunc(entry) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
+func genKeyFromHash(dirHash []byte, fileName string) []byte {
+	key := make([]byte, 0, md5.Size+len(fileName))
+	key = append(key, dirHash[:md5.Size]...)
+	return append(key, fileName...)
+}
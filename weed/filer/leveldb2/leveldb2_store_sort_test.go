@@ -0,0 +1,73 @@
+package leveldb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func listSorted(t *testing.T, store *LevelDB2Store, sortBy filer.SortBy, descending bool, startSortKey uint64, startFileName string, limit int64) (names []string) {
+	err := store.ListDirectorySortedEntries(context.Background(), "/dir", sortBy, descending, startSortKey, startFileName, limit, func(entry *filer.Entry) bool {
+		names = append(names, entry.Name())
+		return true
+	})
+	assert.Nil(t, err)
+	return
+}
+
+func TestSortedListing(t *testing.T) {
+	dir := t.TempDir()
+	store := &LevelDB2Store{}
+	assert.Nil(t, store.initialize(dir, 2))
+	ctx := context.Background()
+
+	now := time.Now()
+	for i, size := range []uint64{30, 10, 20} {
+		assert.Nil(t, store.InsertEntry(ctx, &filer.Entry{
+			FullPath: util.FullPath(fmt.Sprintf("/dir/file%d", i)),
+			Attr: filer.Attr{
+				Mtime:    now.Add(time.Duration(i) * time.Second),
+				Mode:     0644,
+				FileSize: size,
+			},
+		}))
+	}
+	assert.Equal(t, filer.ErrUnsupportedSortedListing, store.ListDirectorySortedEntries(ctx, "/dir", filer.SortBySize, false, 0, "", 10, nil))
+	store.Shutdown()
+
+	// the existing entries are indexed on the first sorted listing
+	store = &LevelDB2Store{sortIndex: true}
+	assert.Nil(t, store.initialize(dir, 2))
+	assert.Equal(t, []string{"file1", "file2", "file0"}, listSorted(t, store, filer.SortBySize, false, 0, "", 10))
+	assert.Equal(t, []string{"file2", "file1", "file0"}, listSorted(t, store, filer.SortByMtime, true, 0, "", 10))
+
+	// the writes update the indexes
+	assert.Nil(t, store.UpdateEntry(ctx, &filer.Entry{
+		FullPath: "/dir/file1",
+		Attr: filer.Attr{
+			Mtime:    now.Add(time.Hour),
+			Mode:     0644,
+			FileSize: 40,
+		},
+	}))
+	assert.Nil(t, store.DeleteEntry(ctx, "/dir/file0"))
+	assert.Equal(t, []string{"file2", "file1"}, listSorted(t, store, filer.SortBySize, false, 0, "", 10))
+	assert.Equal(t, []string{"file1", "file2"}, listSorted(t, store, filer.SortByMtime, true, 0, "", 10))
+
+	// paginate
+	assert.Equal(t, []string{"file2"}, listSorted(t, store, filer.SortBySize, false, 0, "", 1))
+	assert.Equal(t, []string{"file1"}, listSorted(t, store, filer.SortBySize, false, 20, "file2", 1))
+	assert.Equal(t, []string{"file2"}, listSorted(t, store, filer.SortBySize, true, 40, "file1", 10))
+
+	assert.Nil(t, store.DeleteFolderChildren(ctx, "/dir"))
+	assert.Empty(t, listSorted(t, store, filer.SortBySize, false, 0, "", 10))
+	_, err := store.FindEntry(ctx, "/dir/file1")
+	assert.Equal(t, filer_pb.ErrNotFound, err)
+}
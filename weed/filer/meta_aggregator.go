@@ -28,6 +28,7 @@ type MetaAggregator struct {
 	MetaLogBuffer   *log_buffer.LogBuffer
 	peerStatues     map[pb.ServerAddress]int
 	peerStatuesLock sync.Mutex
+	progress        *peerProgress
 	// notifying clients
 	ListenersLock sync.Mutex
 	ListenersCond *sync.Cond
@@ -41,6 +42,7 @@ func NewMetaAggregator(filer *Filer, self pb.ServerAddress, grpcDialOption grpc.
 		self:           self,
 		grpcDialOption: grpcDialOption,
 		peerStatues:    make(map[pb.ServerAddress]int),
+		progress:       newPeerProgress(),
 	}
 	t.ListenersCond = sync.NewCond(&t.ListenersLock)
 	t.MetaLogBuffer = log_buffer.NewLogBuffer("aggr", LogFlushInterval, nil, func() {
@@ -153,6 +155,12 @@ func (ma *MetaAggregator) doSubscribeToOneFiler(f *Filer, self pb.ServerAddress,
 				glog.Errorf("failed to reply metadata change from %v: %v", peer, err)
 				return
 			}
+			if f.Usage != nil {
+				f.Usage.onReplayedEvent(event)
+			}
+			if f.XattrIndex != nil {
+				f.XattrIndex.onReplayedEvent(event)
+			}
 			counter++
 			if lastPersistTime.Add(time.Minute).Before(time.Now()) {
 				if err := ma.updateOffset(f, peer, peerSignature, event.TsNs); err == nil {
@@ -180,9 +188,17 @@ func (ma *MetaAggregator) doSubscribeToOneFiler(f *Filer, self pb.ServerAddress,
 		dir := event.Directory
 		// println("received meta change", dir, "size", len(data))
 		ma.MetaLogBuffer.AddToBuffer([]byte(dir), data, event.TsNs)
+		// the peer may share the filer store, and change it without this filer store wrapper
+		ma.filer.invalidateMetaCache(event)
+		if ma.filer.ChunkAuditor != nil {
+			ma.filer.ChunkAuditor.onPeerEvent(event)
+		}
 		if maybeReplicateMetadataChange != nil {
 			maybeReplicateMetadataChange(event)
 		}
+		if ma.filer.DirChecksums != nil && peer != self {
+			ma.filer.DirChecksums.onPeerEvent(event)
+		}
 		return nil
 	}
 
@@ -217,11 +233,20 @@ func (ma *MetaAggregator) doSubscribeToOneFiler(f *Filer, self pb.ServerAddress,
 
 			f.onMetadataChangeEvent(resp)
 			lastTsNs = resp.TsNs
+			ma.progress.update(peer, lastTsNs)
 		}
 	})
 	return lastTsNs, err
 }
 
+// waitForPeer waits until the events of the peer up to tsNs are received and applied.
+func (ma *MetaAggregator) waitForPeer(ctx context.Context, peer pb.ServerAddress, tsNs int64) error {
+	if !ma.isActive(peer) {
+		return fmt.Errorf("not following %s", peer)
+	}
+	return ma.progress.wait(ctx, peer, tsNs)
+}
+
 func (ma *MetaAggregator) readFilerStoreSignature(peer pb.ServerAddress) (sig int32, err error) {
 	err = pb.WithFilerClient(false, 0, peer, ma.grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
 		resp, err := client.GetFilerConfiguration(context.Background(), &filer_pb.GetFilerConfigurationRequest{})
@@ -35,3 +35,22 @@ func Replay(filerStore FilerStore, resp *filer_pb.SubscribeMetadataResponse) err
 
 	return nil
 }
+
+// entriesOfEvent returns the entries before and after the change.
+func entriesOfEvent(resp *filer_pb.SubscribeMetadataResponse) (oldEntry, newEntry *Entry) {
+	message := resp.EventNotification
+	if message == nil {
+		return
+	}
+	if message.OldEntry != nil {
+		oldEntry = FromPbEntry(resp.Directory, message.OldEntry)
+	}
+	if message.NewEntry != nil {
+		dir := resp.Directory
+		if message.NewParentPath != "" {
+			dir = message.NewParentPath
+		}
+		newEntry = FromPbEntry(dir, message.NewEntry)
+	}
+	return
+}
@@ -0,0 +1,179 @@
+package raftleveldb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/raft"
+	"github.com/syndtr/goleveldb/leveldb"
+	leveldb_util "github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+const (
+	opPut = iota + 1
+	opDelete
+	opDeleteChildren
+)
+
+// command is one replicated mutation of the key space.
+// Entries are encoded by the filer that accepted the request,
+// so every member applies exactly the same bytes.
+type command struct {
+	Op    byte
+	Key   []byte
+	Value []byte
+}
+
+func (cmd *command) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cmd); err != nil {
+		return nil, fmt.Errorf("encode raft command: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeCommand(data []byte) (*command, error) {
+	cmd := &command{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(cmd); err != nil {
+		return nil, fmt.Errorf("decode raft command: %v", err)
+	}
+	return cmd, nil
+}
+
+type fsm struct {
+	db *leveldb.DB
+}
+
+var _ raft.FSM = &fsm{}
+
+func (f *fsm) Apply(l *raft.Log) interface{} {
+	cmd, err := decodeCommand(l.Data)
+	if err != nil {
+		return err
+	}
+	switch cmd.Op {
+	case opPut:
+		return f.db.Put(cmd.Key, cmd.Value, nil)
+	case opDelete:
+		return f.db.Delete(cmd.Key, nil)
+	case opDeleteChildren:
+		batch := new(leveldb.Batch)
+		iter := f.db.NewIterator(leveldb_util.BytesPrefix(cmd.Key), nil)
+		for iter.Next() {
+			if getNameFromKey(iter.Key()) == "" {
+				continue
+			}
+			batch.Delete(append([]byte(nil), iter.Key()...))
+		}
+		iter.Release()
+		return f.db.Write(batch, nil)
+	}
+	return fmt.Errorf("unknown raft command op %d", cmd.Op)
+}
+
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	snapshot, err := f.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &fsmSnapshot{snapshot: snapshot}, nil
+}
+
+// Restore replaces the whole local key space with the snapshot content.
+func (f *fsm) Restore(reader io.ReadCloser) error {
+	defer reader.Close()
+
+	batch := new(leveldb.Batch)
+	iter := f.db.NewIterator(nil, nil)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+	}
+	iter.Release()
+	if err := f.db.Write(batch, nil); err != nil {
+		return err
+	}
+
+	count := 0
+	batch.Reset()
+	r := bufio.NewReader(reader)
+	for {
+		key, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		value, err := readRecord(r)
+		if err != nil {
+			return err
+		}
+		batch.Put(key, value)
+		count++
+		if batch.Len() >= 1024 {
+			if err := f.db.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	glog.V(0).Infof("restored %d keys from raft snapshot", count)
+	return f.db.Write(batch, nil)
+}
+
+type fsmSnapshot struct {
+	snapshot *leveldb.Snapshot
+}
+
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	w := bufio.NewWriter(sink)
+	iter := s.snapshot.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := writeRecord(w, iter.Key()); err != nil {
+			sink.Cancel()
+			return err
+		}
+		if err := writeRecord(w, iter.Value()); err != nil {
+			sink.Cancel()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *fsmSnapshot) Release() {
+	s.snapshot.Release()
+}
+
+func writeRecord(w io.Writer, data []byte) error {
+	var header [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(header[:], uint64(len(data)))
+	if _, err := w.Write(header[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
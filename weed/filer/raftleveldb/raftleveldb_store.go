@@ -0,0 +1,349 @@
+package raftleveldb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/raft"
+	boltdb "github.com/hashicorp/raft-boltdb/v2"
+	"github.com/syndtr/goleveldb/leveldb"
+	leveldb_errors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	leveldb_util "github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	weed_util "github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const (
+	DIR_FILE_SEPARATOR = byte(0x00)
+	applyTimeout       = 10 * time.Second
+)
+
+var (
+	_ = filer.Debuggable(&RaftLevelDBStore{})
+)
+
+func init() {
+	filer.Stores = append(filer.Stores, &RaftLevelDBStore{})
+}
+
+// RaftLevelDBStore keeps a local leveldb on every filer of a raft group.
+// Writes are applied through the raft log, and forwarded to the leader when
+// received by a follower. Reads are served from the local copy.
+type RaftLevelDBStore struct {
+	db        *leveldb.DB
+	raft      *raft.Raft
+	transport *muxTransport
+	logStore  *boltdb.BoltStore
+}
+
+func (store *RaftLevelDBStore) GetName() string {
+	return "raftleveldb"
+}
+
+func (store *RaftLevelDBStore) Initialize(configuration weed_util.Configuration, prefix string) (err error) {
+	dir := configuration.GetString(prefix + "dir")
+	address := configuration.GetString(prefix + "address")
+	var peers []string
+	for _, peer := range strings.Split(configuration.GetString(prefix+"peers"), ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			peers = append(peers, peer)
+		}
+	}
+	return store.initialize(dir, address, peers)
+}
+
+func (store *RaftLevelDBStore) initialize(dir string, address string, peers []string) (err error) {
+	glog.Infof("filer store dir: %s, raft address: %s, peers: %v", dir, address, peers)
+	if address == "" {
+		return fmt.Errorf("raftleveldb: missing raft address")
+	}
+	dataDir, raftDir := filepath.Join(dir, "data"), filepath.Join(dir, "raft")
+	for _, d := range []string{dataDir, raftDir} {
+		os.MkdirAll(d, 0755)
+		if err := weed_util.TestFolderWritable(d); err != nil {
+			return fmt.Errorf("Check Level Folder %s Writable: %s", d, err)
+		}
+	}
+
+	opts := &opt.Options{
+		BlockCacheCapacity: 32 * 1024 * 1024,         // default value is 8MiB
+		WriteBuffer:        16 * 1024 * 1024,         // default value is 4MiB
+		Filter:             filter.NewBloomFilter(8), // false positive rate 0.02
+	}
+	if store.db, err = leveldb.OpenFile(dataDir, opts); err != nil {
+		if leveldb_errors.IsCorrupted(err) {
+			store.db, err = leveldb.RecoverFile(dataDir, opts)
+		}
+		if err != nil {
+			return fmt.Errorf("filer store open dir %s: %v", dataDir, err)
+		}
+	}
+
+	c := raft.DefaultConfig()
+	c.LocalID = raft.ServerID(address)
+	c.LogLevel = "Error"
+	if glog.V(4) {
+		c.LogLevel = "Debug"
+	} else if glog.V(2) {
+		c.LogLevel = "Info"
+	}
+
+	if store.logStore, err = boltdb.NewBoltStore(filepath.Join(raftDir, "logs.dat")); err != nil {
+		return fmt.Errorf("boltdb.NewBoltStore: %v", err)
+	}
+	snapshots, err := raft.NewFileSnapshotStore(raftDir, 3, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("raft.NewFileSnapshotStore(%q): %v", raftDir, err)
+	}
+	if store.transport, err = newMuxTransport(address, store.applyForwarded); err != nil {
+		return err
+	}
+
+	store.raft, err = raft.NewRaft(c, &fsm{db: store.db}, store.logStore, store.logStore, snapshots, store.transport.raftTransport)
+	if err != nil {
+		return fmt.Errorf("raft.NewRaft: %v", err)
+	}
+
+	hasState, err := raft.HasExistingState(store.logStore, store.logStore, snapshots)
+	if err != nil {
+		return err
+	}
+	if !hasState {
+		cfg := raft.Configuration{}
+		if len(peers) == 0 {
+			peers = []string{address}
+		}
+		for _, peer := range peers {
+			cfg.Servers = append(cfg.Servers, raft.Server{
+				Suffrage: raft.Voter,
+				ID:       raft.ServerID(peer),
+				Address:  raft.ServerAddress(peer),
+			})
+		}
+		glog.V(0).Infof("bootstrapping filer raft group %+v", cfg)
+		if err := store.raft.BootstrapCluster(cfg).Error(); err != nil && err != raft.ErrCantBootstrap {
+			return fmt.Errorf("raft.BootstrapCluster: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (store *RaftLevelDBStore) BeginTransaction(ctx context.Context) (context.Context, error) {
+	return ctx, nil
+}
+func (store *RaftLevelDBStore) CommitTransaction(ctx context.Context) error {
+	return nil
+}
+func (store *RaftLevelDBStore) RollbackTransaction(ctx context.Context) error {
+	return nil
+}
+
+// apply runs the command through the raft log on the leader,
+// or forwards it to the current leader.
+func (store *RaftLevelDBStore) apply(cmd *command) error {
+	if store.raft.State() == raft.Leader {
+		return store.applyForwarded(cmd)
+	}
+	leader, _ := store.raft.LeaderWithID()
+	if leader == "" {
+		// an election may be in progress
+		time.Sleep(time.Duration(100+rand.Intn(200)) * time.Millisecond)
+		if leader, _ = store.raft.LeaderWithID(); leader == "" {
+			return raft.ErrNotLeader
+		}
+	}
+	return store.transport.forward(string(leader), cmd)
+}
+
+func (store *RaftLevelDBStore) applyForwarded(cmd *command) error {
+	data, err := cmd.encode()
+	if err != nil {
+		return err
+	}
+	future := store.raft.Apply(data, applyTimeout)
+	if err := future.Error(); err != nil {
+		return err
+	}
+	if resp, ok := future.Response().(error); ok && resp != nil {
+		return resp
+	}
+	return nil
+}
+
+func (store *RaftLevelDBStore) InsertEntry(ctx context.Context, entry *filer.Entry) (err error) {
+	key := genKey(entry.DirAndName())
+
+	value, err := entry.EncodeAttributesAndChunks()
+	if err != nil {
+		return fmt.Errorf("encoding %s %+v: %v", entry.FullPath, entry.Attr, err)
+	}
+
+	if len(entry.GetChunks()) > filer.CountEntryChunksForGzip {
+		value = weed_util.MaybeGzipData(value)
+	}
+
+	if err = store.apply(&command{Op: opPut, Key: key, Value: value}); err != nil {
+		return fmt.Errorf("persisting %s : %v", entry.FullPath, err)
+	}
+
+	return nil
+}
+
+func (store *RaftLevelDBStore) UpdateEntry(ctx context.Context, entry *filer.Entry) (err error) {
+
+	return store.InsertEntry(ctx, entry)
+}
+
+func (store *RaftLevelDBStore) FindEntry(ctx context.Context, fullpath weed_util.FullPath) (entry *filer.Entry, err error) {
+	key := genKey(fullpath.DirAndName())
+
+	data, err := store.db.Get(key, nil)
+
+	if err == leveldb.ErrNotFound {
+		return nil, filer_pb.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get %s : %v", fullpath, err)
+	}
+
+	entry = &filer.Entry{
+		FullPath: fullpath,
+	}
+	err = entry.DecodeAttributesAndChunks(weed_util.MaybeDecompressData(data))
+	if err != nil {
+		return entry, fmt.Errorf("decode %s : %v", entry.FullPath, err)
+	}
+
+	return entry, nil
+}
+
+func (store *RaftLevelDBStore) DeleteEntry(ctx context.Context, fullpath weed_util.FullPath) (err error) {
+	key := genKey(fullpath.DirAndName())
+
+	if err = store.apply(&command{Op: opDelete, Key: key}); err != nil {
+		return fmt.Errorf("delete %s : %v", fullpath, err)
+	}
+
+	return nil
+}
+
+func (store *RaftLevelDBStore) DeleteFolderChildren(ctx context.Context, fullpath weed_util.FullPath) (err error) {
+
+	directoryPrefix := genDirectoryKeyPrefix(fullpath, "")
+	if err = store.apply(&command{Op: opDeleteChildren, Key: directoryPrefix}); err != nil {
+		return fmt.Errorf("delete %s : %v", fullpath, err)
+	}
+
+	return nil
+}
+
+func (store *RaftLevelDBStore) ListDirectoryEntries(ctx context.Context, dirPath weed_util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
+	return store.ListDirectoryPrefixedEntries(ctx, dirPath, startFileName, includeStartFile, limit, "", eachEntryFunc)
+}
+
+func (store *RaftLevelDBStore) ListDirectoryPrefixedEntries(ctx context.Context, dirPath weed_util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
+
+	directoryPrefix := genDirectoryKeyPrefix(dirPath, prefix)
+	lastFileStart := directoryPrefix
+	if startFileName != "" {
+		lastFileStart = genDirectoryKeyPrefix(dirPath, startFileName)
+	}
+
+	iter := store.db.NewIterator(&leveldb_util.Range{Start: lastFileStart}, nil)
+	for iter.Next() {
+		key := iter.Key()
+		if !bytes.HasPrefix(key, directoryPrefix) {
+			break
+		}
+		fileName := getNameFromKey(key)
+		if fileName == "" {
+			continue
+		}
+		if fileName == startFileName && !includeStartFile {
+			continue
+		}
+		limit--
+		if limit < 0 {
+			break
+		}
+		lastFileName = fileName
+		entry := &filer.Entry{
+			FullPath: weed_util.NewFullPath(string(dirPath), fileName),
+		}
+		if decodeErr := entry.DecodeAttributesAndChunks(weed_util.MaybeDecompressData(iter.Value())); decodeErr != nil {
+			err = decodeErr
+			glog.V(0).Infof("list %s : %v", entry.FullPath, err)
+			break
+		}
+		if !eachEntryFunc(entry) {
+			break
+		}
+	}
+	iter.Release()
+
+	return lastFileName, err
+}
+
+func genKey(dirPath, fileName string) (key []byte) {
+	key = []byte(dirPath)
+	key = append(key, DIR_FILE_SEPARATOR)
+	key = append(key, []byte(fileName)...)
+	return key
+}
+
+func genDirectoryKeyPrefix(fullpath weed_util.FullPath, startFileName string) (keyPrefix []byte) {
+	keyPrefix = []byte(string(fullpath))
+	keyPrefix = append(keyPrefix, DIR_FILE_SEPARATOR)
+	if len(startFileName) > 0 {
+		keyPrefix = append(keyPrefix, []byte(startFileName)...)
+	}
+	return keyPrefix
+}
+
+func getNameFromKey(key []byte) string {
+
+	sepIndex := len(key) - 1
+	for sepIndex >= 0 && key[sepIndex] != DIR_FILE_SEPARATOR {
+		sepIndex--
+	}
+
+	return string(key[sepIndex+1:])
+
+}
+
+func (store *RaftLevelDBStore) Shutdown() {
+	if store.raft != nil {
+		store.raft.Shutdown().Error()
+	}
+	if store.transport != nil {
+		store.transport.Close()
+	}
+	if store.logStore != nil {
+		store.logStore.Close()
+	}
+	store.db.Close()
+}
+
+func (store *RaftLevelDBStore) Debug(writer io.Writer) {
+	fmt.Fprintf(writer, "raft state: %v leader: %v\n", store.raft.State(), store.raft.Leader())
+	iter := store.db.NewIterator(&leveldb_util.Range{}, nil)
+	for iter.Next() {
+		key := iter.Key()
+		fullName := bytes.Replace(key, []byte{DIR_FILE_SEPARATOR}, []byte{' '}, 1)
+		fmt.Fprintf(writer, "%v\n", string(fullName))
+	}
+	iter.Release()
+}
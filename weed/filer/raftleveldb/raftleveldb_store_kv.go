@@ -0,0 +1,46 @@
+package raftleveldb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func (store *RaftLevelDBStore) KvPut(ctx context.Context, key []byte, value []byte) (err error) {
+
+	err = store.apply(&command{Op: opPut, Key: key, Value: value})
+
+	if err != nil {
+		return fmt.Errorf("kv put: %v", err)
+	}
+
+	return nil
+}
+
+func (store *RaftLevelDBStore) KvGet(ctx context.Context, key []byte) (value []byte, err error) {
+
+	value, err = store.db.Get(key, nil)
+
+	if err == leveldb.ErrNotFound {
+		return nil, filer.ErrKvNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("kv get: %v", err)
+	}
+
+	return
+}
+
+func (store *RaftLevelDBStore) KvDelete(ctx context.Context, key []byte) (err error) {
+
+	err = store.apply(&command{Op: opDelete, Key: key})
+
+	if err != nil {
+		return fmt.Errorf("kv delete: %v", err)
+	}
+
+	return nil
+}
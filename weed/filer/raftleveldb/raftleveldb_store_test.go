@@ -0,0 +1,198 @@
+package raftleveldb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func waitForLeader(t *testing.T, stores ...*RaftLevelDBStore) {
+	for i := 0; i < 100; i++ {
+		for _, store := range stores {
+			if store.raft.State() == raft.Leader {
+				return
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("no raft leader elected")
+}
+
+func TestCreateAndFind(t *testing.T) {
+	testFiler := filer.NewFiler(pb.ServerDiscovery{}, nil, "", "", "", "", "", nil)
+	store := &RaftLevelDBStore{}
+	if err := store.initialize(t.TempDir(), freeAddress(t), nil); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	defer store.Shutdown()
+	waitForLeader(t, store)
+	testFiler.SetStore(store)
+
+	fullpath := util.FullPath("/home/chris/this/is/one/file1.jpg")
+
+	ctx := context.Background()
+
+	entry1 := &filer.Entry{
+		FullPath: fullpath,
+		Attr: filer.Attr{
+			Mode: 0440,
+			Uid:  1234,
+			Gid:  5678,
+		},
+	}
+
+	if err := testFiler.CreateEntry(ctx, entry1, false, false, nil, false); err != nil {
+		t.Errorf("create entry %v: %v", entry1.FullPath, err)
+		return
+	}
+
+	entry, err := testFiler.FindEntry(ctx, fullpath)
+	if err != nil {
+		t.Errorf("find entry: %v", err)
+		return
+	}
+	if entry.FullPath != entry1.FullPath {
+		t.Errorf("find wrong entry: %v", entry.FullPath)
+		return
+	}
+
+	entries, _, _ := testFiler.ListDirectoryEntries(ctx, util.FullPath("/home/chris/this/is"), "", false, 100, "", "", "")
+	if len(entries) != 1 {
+		t.Errorf("list entries count: %v", len(entries))
+		return
+	}
+
+	if err := store.DeleteFolderChildren(ctx, util.FullPath("/home/chris/this/is/one")); err != nil {
+		t.Errorf("delete folder children: %v", err)
+		return
+	}
+	if _, err := store.FindEntry(ctx, fullpath); err != filer_pb.ErrNotFound {
+		t.Errorf("entry should be deleted: %v", err)
+	}
+
+}
+
+func TestReplicationAndForwarding(t *testing.T) {
+	dir := t.TempDir()
+	var peers []string
+	for i := 0; i < 3; i++ {
+		peers = append(peers, freeAddress(t))
+	}
+	var stores []*RaftLevelDBStore
+	for i, peer := range peers {
+		store := &RaftLevelDBStore{}
+		if err := store.initialize(filepath.Join(dir, fmt.Sprintf("filer%d", i)), peer, peers); err != nil {
+			t.Fatalf("initialize %s: %v", peer, err)
+		}
+		defer store.Shutdown()
+		stores = append(stores, store)
+	}
+	waitForLeader(t, stores...)
+
+	var follower *RaftLevelDBStore
+	for _, store := range stores {
+		if store.raft.State() != raft.Leader {
+			follower = store
+			break
+		}
+	}
+
+	ctx := context.Background()
+	entry := &filer.Entry{
+		FullPath: util.FullPath("/home/chris/file1.jpg"),
+		Attr: filer.Attr{
+			Mode: 0440,
+			Uid:  1234,
+		},
+	}
+	if err := follower.InsertEntry(ctx, entry); err != nil {
+		t.Fatalf("insert through follower: %v", err)
+	}
+
+	for _, store := range stores {
+		var found *filer.Entry
+		for i := 0; i < 50 && found == nil; i++ {
+			found, _ = store.FindEntry(ctx, entry.FullPath)
+			time.Sleep(20 * time.Millisecond)
+		}
+		if found == nil || found.Attr.Uid != 1234 {
+			t.Errorf("entry not replicated to %s: %+v", store.raft.String(), found)
+		}
+	}
+
+	if err := follower.DeleteEntry(ctx, entry.FullPath); err != nil {
+		t.Fatalf("delete through follower: %v", err)
+	}
+	if err := stores[0].raft.Barrier(time.Second).Error(); err != nil && err != raft.ErrNotLeader {
+		t.Fatalf("barrier: %v", err)
+	}
+}
+
+type bufferSink struct {
+	bytes.Buffer
+}
+
+func (s *bufferSink) ID() string    { return "test" }
+func (s *bufferSink) Cancel() error { return nil }
+func (s *bufferSink) Close() error  { return nil }
+
+func TestSnapshotRestore(t *testing.T) {
+	source, err := leveldb.OpenFile(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer source.Close()
+	target, err := leveldb.OpenFile(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer target.Close()
+
+	for i := 0; i < 3000; i++ {
+		source.Put(genKey("/dir", fmt.Sprintf("f%05d", i)), []byte(fmt.Sprintf("v%d", i)), nil)
+	}
+	target.Put([]byte("stale"), []byte("value"), nil)
+
+	snapshot, err := (&fsm{db: source}).Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	sink := &bufferSink{}
+	if err := snapshot.Persist(sink); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	snapshot.Release()
+
+	if err := (&fsm{db: target}).Restore(io.NopCloser(&sink.Buffer)); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, err := target.Get([]byte("stale"), nil); err != leveldb.ErrNotFound {
+		t.Errorf("stale key should be removed: %v", err)
+	}
+	value, err := target.Get(genKey("/dir", "f02999"), nil)
+	if err != nil || string(value) != "v2999" {
+		t.Errorf("restored value: %s %v", value, err)
+	}
+}
@@ -0,0 +1,182 @@
+package raftleveldb
+
+import (
+	"encoding/gob"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/raft"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+const (
+	connTypeRaft    = byte(1)
+	connTypeForward = byte(2)
+
+	forwardTimeout = 15 * time.Second
+)
+
+type forwardResponse struct {
+	Error string
+}
+
+// muxTransport shares one listening address between raft traffic and
+// writes forwarded from followers to the leader.
+// The first byte of every connection tells which one it is.
+type muxTransport struct {
+	listener      net.Listener
+	raftConns     chan net.Conn
+	applyFn       func(cmd *command) error
+	raftTransport *raft.NetworkTransport
+	closeOnce     sync.Once
+	closed        chan struct{}
+}
+
+func newMuxTransport(address string, applyFn func(cmd *command) error) (*muxTransport, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("listen on raft address %s: %v", address, err)
+	}
+	t := &muxTransport{
+		listener:  listener,
+		raftConns: make(chan net.Conn, 16),
+		applyFn:   applyFn,
+		closed:    make(chan struct{}),
+	}
+	t.raftTransport = raft.NewNetworkTransport(&raftStreamLayer{t: t, advertise: address}, 3, 10*time.Second, os.Stderr)
+	go t.serve()
+	return t, nil
+}
+
+func (t *muxTransport) serve() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			select {
+			case <-t.closed:
+				return
+			default:
+			}
+			glog.Errorf("raft listener accept: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go t.dispatch(conn)
+	}
+}
+
+func (t *muxTransport) dispatch(conn net.Conn) {
+	var connType [1]byte
+	conn.SetReadDeadline(time.Now().Add(forwardTimeout))
+	if _, err := conn.Read(connType[:]); err != nil {
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	switch connType[0] {
+	case connTypeRaft:
+		select {
+		case t.raftConns <- conn:
+		case <-t.closed:
+			conn.Close()
+		}
+	case connTypeForward:
+		t.handleForward(conn)
+	default:
+		glog.V(1).Infof("unknown connection type %d from %s", connType[0], conn.RemoteAddr())
+		conn.Close()
+	}
+}
+
+func (t *muxTransport) handleForward(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(forwardTimeout))
+	cmd := &command{}
+	if err := gob.NewDecoder(conn).Decode(cmd); err != nil {
+		glog.V(1).Infof("decode forwarded command from %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	resp := forwardResponse{}
+	if err := t.applyFn(cmd); err != nil {
+		resp.Error = err.Error()
+	}
+	if err := gob.NewEncoder(conn).Encode(&resp); err != nil {
+		glog.V(1).Infof("reply forwarded command to %s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+// forward sends the command to the leader and waits until it is applied.
+func (t *muxTransport) forward(leader string, cmd *command) error {
+	conn, err := net.DialTimeout("tcp", leader, forwardTimeout)
+	if err != nil {
+		return fmt.Errorf("connect to raft leader %s: %v", leader, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(forwardTimeout))
+	if _, err = conn.Write([]byte{connTypeForward}); err != nil {
+		return fmt.Errorf("forward to raft leader %s: %v", leader, err)
+	}
+	if err = gob.NewEncoder(conn).Encode(cmd); err != nil {
+		return fmt.Errorf("forward to raft leader %s: %v", leader, err)
+	}
+	resp := forwardResponse{}
+	if err = gob.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("read reply from raft leader %s: %v", leader, err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("raft leader %s: %s", leader, resp.Error)
+	}
+	return nil
+}
+
+func (t *muxTransport) Close() {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		t.raftTransport.Close()
+		t.listener.Close()
+	})
+}
+
+// raftStreamLayer exposes the raft side of muxTransport to raft.NetworkTransport.
+type raftStreamLayer struct {
+	t         *muxTransport
+	advertise string
+}
+
+var _ raft.StreamLayer = &raftStreamLayer{}
+
+func (s *raftStreamLayer) Accept() (net.Conn, error) {
+	select {
+	case conn := <-s.t.raftConns:
+		return conn, nil
+	case <-s.t.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (s *raftStreamLayer) Close() error {
+	return nil
+}
+
+func (s *raftStreamLayer) Addr() net.Addr {
+	if addr, err := net.ResolveTCPAddr("tcp", s.advertise); err == nil {
+		return addr
+	}
+	return s.t.listener.Addr()
+}
+
+func (s *raftStreamLayer) Dial(address raft.ServerAddress, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", string(address), timeout)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = conn.Write([]byte{connTypeRaft}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"math/rand"
 	"sync"
 
 	"github.com/seaweedfs/seaweedfs/weed/glog"
@@ -29,6 +28,10 @@ func LookupFn(filerClient filer_pb.FilerClient) wdclient.LookupFileIdFunctionTyp
 
 	vidCache := make(map[string]*filer_pb.Locations)
 	var vicCacheLock sync.RWMutex
+	locality := wdclient.Locality{DataCenter: filerClient.GetDataCenter()}
+	if c, ok := filerClient.(wdclient.HasReadLocality); ok {
+		locality = c.GetReadLocality()
+	}
 	return func(fileId string) (targetUrls []string, err error) {
 		vid := VolumeId(fileId)
 		vicCacheLock.RLock()
@@ -39,7 +42,10 @@ func LookupFn(filerClient filer_pb.FilerClient) wdclient.LookupFileIdFunctionTyp
 			util.Retry("lookup volume "+vid, func() error {
 				err = filerClient.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
 					resp, err := client.LookupVolume(context.Background(), &filer_pb.LookupVolumeRequest{
-						VolumeIds: []string{vid},
+						VolumeIds:      []string{vid},
+						DataCenter:     locality.DataCenter,
+						Rack:           locality.Rack,
+						ReadPreference: string(locality.Preference),
 					})
 					if err != nil {
 						return err
@@ -64,25 +70,13 @@ func LookupFn(filerClient filer_pb.FilerClient) wdclient.LookupFileIdFunctionTyp
 			return nil, err
 		}
 
-		fcDataCenter := filerClient.GetDataCenter()
-		var sameDcTargetUrls, otherTargetUrls []string
-		for _, loc := range locations.Locations {
+		// Prefer the same rack, then the same data center
+		for _, loc := range wdclient.OrderByProximity(locality, locations.Locations, func(loc *filer_pb.Location) (string, string) {
+			return loc.DataCenter, loc.Rack
+		}) {
 			volumeServerAddress := filerClient.AdjustedUrl(loc)
-			targetUrl := fmt.Sprintf("http://%s/%s", volumeServerAddress, fileId)
-			if fcDataCenter == "" || fcDataCenter != loc.DataCenter {
-				otherTargetUrls = append(otherTargetUrls, targetUrl)
-			} else {
-				sameDcTargetUrls = append(sameDcTargetUrls, targetUrl)
-			}
+			targetUrls = append(targetUrls, fmt.Sprintf("http://%s/%s", volumeServerAddress, fileId))
 		}
-		rand.Shuffle(len(sameDcTargetUrls), func(i, j int) {
-			sameDcTargetUrls[i], sameDcTargetUrls[j] = sameDcTargetUrls[j], sameDcTargetUrls[i]
-		})
-		rand.Shuffle(len(otherTargetUrls), func(i, j int) {
-			otherTargetUrls[i], otherTargetUrls[j] = otherTargetUrls[j], otherTargetUrls[i]
-		})
-		// Prefer same data center
-		targetUrls = append(sameDcTargetUrls, otherTargetUrls...)
 		return
 	}
 }
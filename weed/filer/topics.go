@@ -2,5 +2,8 @@ package filer
 
 const (
 	TopicsDir    = "/topics"
-	SystemLogDir = TopicsDir + "/.system/log"
+	SystemDir    = TopicsDir + "/.system"
+	SystemLogDir = SystemDir + "/log"
+	// XattrIndexDir keeps the index of the extended attributes
+	XattrIndexDir = SystemDir + "/xattr_index"
 )
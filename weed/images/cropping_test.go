@@ -19,4 +19,6 @@ func TestCropping(t *testing.T) {
 
 	util.WriteFile("cropped1.jpg", buf.Bytes(), 0644)
 
+	os.Remove("cropped1.jpg")
+
 }
@@ -0,0 +1,59 @@
+package kms
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// AwsKeyManager keeps the master keys in AWS KMS, or a compatible service.
+// The key id is the id, arn or alias of the KMS key.
+type AwsKeyManager struct {
+	svc kmsiface.KMSAPI
+}
+
+func NewAwsKeyManager(region, endpoint, accessKeyId, secretAccessKey string) (*AwsKeyManager, error) {
+	config := &aws.Config{
+		Region:   aws.String(region),
+		Endpoint: aws.String(endpoint),
+	}
+	if accessKeyId != "" && secretAccessKey != "" {
+		config.Credentials = credentials.NewStaticCredentials(accessKeyId, secretAccessKey, "")
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, fmt.Errorf("create aws session: %v", err)
+	}
+	return &AwsKeyManager{svc: kms.New(sess)}, nil
+}
+
+func (m *AwsKeyManager) GetName() string {
+	return "aws"
+}
+
+func (m *AwsKeyManager) WrapKey(ctx context.Context, keyId string, dataKey []byte) ([]byte, error) {
+	resp, err := m.svc.EncryptWithContext(ctx, &kms.EncryptInput{
+		KeyId:     aws.String(keyId),
+		Plaintext: dataKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kms encrypt with %s: %v", keyId, err)
+	}
+	return resp.CiphertextBlob, nil
+}
+
+func (m *AwsKeyManager) UnwrapKey(ctx context.Context, keyId string, wrapped []byte) ([]byte, error) {
+	resp, err := m.svc.DecryptWithContext(ctx, &kms.DecryptInput{
+		KeyId:          aws.String(keyId),
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kms decrypt with %s: %v", keyId, err)
+	}
+	return resp.Plaintext, nil
+}
@@ -0,0 +1,51 @@
+package kms
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// KeyManager keeps the master keys, and wraps the data keys with them,
+// so the data keys can be stored next to the data they encrypt.
+type KeyManager interface {
+	GetName() string
+	WrapKey(ctx context.Context, keyId string, dataKey []byte) (wrapped []byte, err error)
+	UnwrapKey(ctx context.Context, keyId string, wrapped []byte) (dataKey []byte, err error)
+}
+
+// LoadKeyManager loads the configured key manager under "filer.kms", or returns nil if none is enabled.
+func LoadKeyManager(config util.Configuration, prefix string) (KeyManager, error) {
+	var keyManagers []KeyManager
+
+	if config.GetBool(prefix + "local.enabled") {
+		keyManager, err := NewLocalKeyManager(config.GetStringSlice(prefix + "local.keys"))
+		if err != nil {
+			return nil, err
+		}
+		keyManagers = append(keyManagers, keyManager)
+	}
+	if config.GetBool(prefix + "aws.enabled") {
+		keyManager, err := NewAwsKeyManager(
+			config.GetString(prefix+"aws.region"),
+			config.GetString(prefix+"aws.endpoint"),
+			config.GetString(prefix+"aws.aws_access_key_id"),
+			config.GetString(prefix+"aws.aws_secret_access_key"),
+		)
+		if err != nil {
+			return nil, err
+		}
+		keyManagers = append(keyManagers, keyManager)
+	}
+
+	if len(keyManagers) > 1 {
+		return nil, fmt.Errorf("only one key manager can be enabled")
+	}
+	if len(keyManagers) == 0 {
+		return nil, nil
+	}
+	glog.V(0).Infof("configured key manager %s", keyManagers[0].GetName())
+	return keyManagers[0], nil
+}
@@ -0,0 +1,58 @@
+package kms
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// LocalKeyManager keeps the master keys in the configuration, for a cluster without a KMS.
+type LocalKeyManager struct {
+	keys map[string]util.CipherKey
+}
+
+// NewLocalKeyManager reads the keys as "<key id>:<base64 encoded 32 byte key>".
+func NewLocalKeyManager(keys []string) (*LocalKeyManager, error) {
+	m := &LocalKeyManager{
+		keys: make(map[string]util.CipherKey),
+	}
+	for _, key := range keys {
+		sepIndex := strings.LastIndex(key, ":")
+		if sepIndex <= 0 {
+			return nil, fmt.Errorf("invalid key %q, expecting <key id>:<base64 encoded key>", key)
+		}
+		keyId := key[:sepIndex]
+		secret, err := base64.StdEncoding.DecodeString(key[sepIndex+1:])
+		if err != nil {
+			return nil, fmt.Errorf("decode key %s: %v", keyId, err)
+		}
+		if len(secret) != 32 {
+			return nil, fmt.Errorf("key %s has %d bytes, expecting 32 bytes", keyId, len(secret))
+		}
+		m.keys[keyId] = secret
+	}
+	return m, nil
+}
+
+func (m *LocalKeyManager) GetName() string {
+	return "local"
+}
+
+func (m *LocalKeyManager) WrapKey(ctx context.Context, keyId string, dataKey []byte) ([]byte, error) {
+	key, found := m.keys[keyId]
+	if !found {
+		return nil, fmt.Errorf("key %s not found", keyId)
+	}
+	return util.Encrypt(dataKey, key)
+}
+
+func (m *LocalKeyManager) UnwrapKey(ctx context.Context, keyId string, wrapped []byte) ([]byte, error) {
+	key, found := m.keys[keyId]
+	if !found {
+		return nil, fmt.Errorf("key %s not found", keyId)
+	}
+	return util.Decrypt(wrapped, key)
+}
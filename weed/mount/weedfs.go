@@ -42,6 +42,8 @@ type Option struct {
 	CacheSizeMBForRead int64
 	CacheDirForWrite   string
 	DataCenter         string
+	Rack               string
+	ReadPreference     wdclient.ReadPreference
 	Umask              os.FileMode
 	Quota              int64
 	DisableXAttr       bool
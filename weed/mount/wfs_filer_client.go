@@ -9,6 +9,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/pb"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
 	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/wdclient"
 )
 
 var _ = filer_pb.FilerClient(&WFS{})
@@ -55,3 +56,11 @@ func (wfs *WFS) AdjustedUrl(location *filer_pb.Location) string {
 func (wfs *WFS) GetDataCenter() string {
 	return wfs.option.DataCenter
 }
+
+func (wfs *WFS) GetReadLocality() wdclient.Locality {
+	return wdclient.Locality{
+		DataCenter: wfs.option.DataCenter,
+		Rack:       wfs.option.Rack,
+		Preference: wfs.option.ReadPreference,
+	}
+}
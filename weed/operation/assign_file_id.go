@@ -21,6 +21,7 @@ type VolumeAssignRequest struct {
 	Rack                string
 	DataNode            string
 	WritableVolumeCount uint32
+	Placement           string // the constraint over the tags of the volume servers, e.g. "zone=a,hw=nvme"
 }
 
 type AssignResult struct {
@@ -102,6 +103,7 @@ func (ap *singleThreadAssignProxy) doAssign(grpcConnection *grpc.ClientConn, pri
 			Rack:                request.Rack,
 			DataNode:            request.DataNode,
 			WritableVolumeCount: request.WritableVolumeCount,
+			Placement:           request.Placement,
 		}
 		if err = ap.assignClient.Send(req); err != nil {
 			return nil, fmt.Errorf("StreamAssignSend: %v", err)
@@ -163,6 +165,7 @@ func Assign(masterFn GetMasterFn, grpcDialOption grpc.DialOption, primaryRequest
 				Rack:                request.Rack,
 				DataNode:            request.DataNode,
 				WritableVolumeCount: request.WritableVolumeCount,
+				Placement:           request.Placement,
 			}
 			resp, grpcErr := masterClient.Assign(context.Background(), req)
 			if grpcErr != nil {
@@ -242,6 +245,9 @@ type StorageOption struct {
 	Fsync             bool
 	VolumeGrowthCount uint32
 	SaveInside        bool
+	Compression       string
+	Dedupe            bool
+	Cipher            bool
 }
 
 func (so *StorageOption) TtlString() string {
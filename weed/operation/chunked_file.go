@@ -1,6 +1,7 @@
 package operation
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -36,6 +37,8 @@ type ChunkManifest struct {
 	Mime   string    `json:"mime,omitempty"`
 	Size   int64     `json:"size,omitempty"`
 	Chunks ChunkList `json:"chunks,omitempty"`
+	// the chunks are striped round-robin across this many volumes, see chunked_file_stripe.go
+	StripeWidth int `json:"stripeWidth,omitempty"`
 }
 
 // seekable chunked file reader
@@ -48,6 +51,8 @@ type ChunkedFileReader struct {
 	pw             *io.PipeWriter
 	mutex          sync.Mutex
 	grpcDialOption grpc.DialOption
+	parallelReads  int
+	lookupFileId   func(fileId string) (fullUrl string, jwt string, err error)
 }
 
 func (s ChunkList) Len() int           { return len(s) }
@@ -130,12 +135,23 @@ func NewChunkedFileReader(chunkList []*ChunkInfo, master pb.ServerAddress, grpcD
 		totalSize += chunk.Size
 	}
 	sort.Sort(ChunkList(chunkList))
-	return &ChunkedFileReader{
+	cf := &ChunkedFileReader{
 		totalSize:      totalSize,
 		chunkList:      chunkList,
 		master:         master,
 		grpcDialOption: grpcDialOption,
 	}
+	cf.lookupFileId = func(fileId string) (string, string, error) {
+		return LookupFileId(func() pb.ServerAddress {
+			return cf.master
+		}, cf.grpcDialOption, fileId)
+	}
+	return cf
+}
+
+// SetParallelReads reads up to this many chunks ahead concurrently, e.g. the stripe width of a striped file
+func (cf *ChunkedFileReader) SetParallelReads(parallel int) {
+	cf.parallelReads = parallel
 }
 
 func (cf *ChunkedFileReader) Seek(offset int64, whence int) (int64, error) {
@@ -170,16 +186,11 @@ func (cf *ChunkedFileReader) WriteTo(w io.Writer) (n int64, err error) {
 	if chunkIndex < 0 {
 		return n, ErrInvalidRange
 	}
+	if cf.parallelReads > 1 {
+		return cf.writeToInParallel(w, chunkIndex, chunkStartOffset)
+	}
 	for ; chunkIndex < len(cf.chunkList); chunkIndex++ {
-		ci := cf.chunkList[chunkIndex]
-		// if we need read date from local volume server first?
-		fileUrl, jwt, lookupError := LookupFileId(func() pb.ServerAddress {
-			return cf.master
-		}, cf.grpcDialOption, ci.Fid)
-		if lookupError != nil {
-			return n, lookupError
-		}
-		if wn, e := readChunkNeedle(fileUrl, w, chunkStartOffset, jwt); e != nil {
+		if wn, e := cf.readChunk(cf.chunkList[chunkIndex], w, chunkStartOffset); e != nil {
 			return n, e
 		} else {
 			n += wn
@@ -191,6 +202,56 @@ func (cf *ChunkedFileReader) WriteTo(w io.Writer) (n int64, err error) {
 	return n, nil
 }
 
+// writeToInParallel fetches the next chunks concurrently into memory, and writes them in order
+func (cf *ChunkedFileReader) writeToInParallel(w io.Writer, chunkIndex int, chunkStartOffset int64) (n int64, err error) {
+	type fetchedChunk struct {
+		data []byte
+		err  error
+	}
+	fetch := func(ci *ChunkInfo, offset int64) chan fetchedChunk {
+		fetched := make(chan fetchedChunk, 1)
+		go func() {
+			var buf bytes.Buffer
+			_, e := cf.readChunk(ci, &buf, offset)
+			fetched <- fetchedChunk{data: buf.Bytes(), err: e}
+		}()
+		return fetched
+	}
+
+	var pending []chan fetchedChunk
+	for ; chunkIndex < len(cf.chunkList) && len(pending) < cf.parallelReads; chunkIndex++ {
+		pending = append(pending, fetch(cf.chunkList[chunkIndex], chunkStartOffset))
+		chunkStartOffset = 0
+	}
+	for len(pending) > 0 {
+		fetched := <-pending[0]
+		pending = pending[1:]
+		if fetched.err != nil {
+			return n, fetched.err
+		}
+		wn, e := w.Write(fetched.data)
+		n += int64(wn)
+		cf.pos += int64(wn)
+		if e != nil {
+			return n, e
+		}
+		if chunkIndex < len(cf.chunkList) {
+			pending = append(pending, fetch(cf.chunkList[chunkIndex], 0))
+			chunkIndex++
+		}
+	}
+	return n, nil
+}
+
+func (cf *ChunkedFileReader) readChunk(ci *ChunkInfo, w io.Writer, offset int64) (written int64, err error) {
+	// if we need read date from local volume server first?
+	fileUrl, jwt, lookupError := cf.lookupFileId(ci.Fid)
+	if lookupError != nil {
+		return 0, lookupError
+	}
+	return readChunkNeedle(fileUrl, w, offset, jwt)
+}
+
 func (cf *ChunkedFileReader) ReadAt(p []byte, off int64) (n int, err error) {
 	cf.Seek(off, 0)
 	return cf.Read(p)
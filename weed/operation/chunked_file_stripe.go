@@ -0,0 +1,158 @@
+package operation
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/security"
+)
+
+// A striped file is split into stripe units of the chunk size, written round-robin to the stripe width of volumes,
+// so a file is not bounded by the size of one volume, and the stripe units can be read from the volumes in parallel.
+// The chunk manifest is the stripe map: the stripe unit i is the chunk at offset i*chunkSize,
+// written to the (i % width)-th volume with the file id of the (i / width)-th key assigned in that volume.
+
+const stripeAssignAttemptsPerVolume = 4
+
+// stripeUnitFid is the file id of the stripe unit, in the file keys assigned to its volume
+func stripeUnitFid(memberFids []string, unit int) string {
+	fid := memberFids[unit%len(memberFids)]
+	if k := unit / len(memberFids); k > 0 {
+		fid += "_" + strconv.Itoa(k)
+	}
+	return fid
+}
+
+// assignStripeMembers assigns the file keys for the stripe units in up to width distinct volumes
+func (fi FilePart) assignStripeMembers(width int, units int64, masterFn GetMasterFn, grpcDialOption grpc.DialOption) (members []*AssignResult, err error) {
+	unitsPerMember := (units + int64(width) - 1) / int64(width)
+	volumes := make(map[string]bool)
+	for attempt := 0; attempt < width*stripeAssignAttemptsPerVolume && len(members) < width; attempt++ {
+		ret, assignErr := Assign(masterFn, grpcDialOption, &VolumeAssignRequest{
+			Count:       uint64(unitsPerMember),
+			Replication: fi.Replication,
+			Collection:  fi.Collection,
+			DataCenter:  fi.DataCenter,
+			Ttl:         fi.Ttl,
+			DiskType:    fi.DiskType,
+		})
+		if assignErr != nil {
+			return nil, assignErr
+		}
+		volumeId := strings.Split(ret.Fid, ",")[0]
+		if volumes[volumeId] {
+			continue
+		}
+		volumes[volumeId] = true
+		members = append(members, ret)
+	}
+	if len(members) < width {
+		glog.V(0).Infof("stripe %s across %d volumes instead of %d", fi.FileName, len(members), width)
+	}
+	return members, nil
+}
+
+// uploadStriped uploads the stripe units, as many at a time as the stripe width, and then the chunk manifest as the file
+func (fi FilePart) uploadStriped(fileUrl string, baseName string, chunkSize int64, masterFn GetMasterFn, usePublicUrl bool, jwt security.EncodedJwt, grpcDialOption grpc.DialOption) (retSize uint32, err error) {
+	units := (fi.FileSize + chunkSize - 1) / chunkSize
+	width := fi.StripeWidth
+	if int64(width) > units {
+		width = int(units)
+	}
+	members, err := fi.assignStripeMembers(width, units, masterFn, grpcDialOption)
+	if err != nil {
+		return 0, err
+	}
+	memberFids := make([]string, len(members))
+	for i, m := range members {
+		memberFids[i] = m.Fid
+	}
+	cm := ChunkManifest{
+		Name:        baseName,
+		Size:        fi.FileSize,
+		Mime:        fi.MimeType,
+		Chunks:      make([]*ChunkInfo, 0, units),
+		StripeWidth: len(members),
+	}
+
+	type stripeUnit struct {
+		index int
+		data  []byte
+	}
+	var lock sync.Mutex // guards the chunk list, the size, and the first error
+	unitChan := make(chan stripeUnit)
+	var wg sync.WaitGroup
+	for i := 0; i < len(members); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for unit := range unitChan {
+				m := members[unit.index%len(members)]
+				fid := stripeUnitFid(memberFids, unit.index)
+				unitUrl := "http://" + m.Url + "/" + fid
+				if usePublicUrl {
+					unitUrl = "http://" + m.PublicUrl + "/" + fid
+				}
+				count, e := upload_one_chunk(
+					baseName+"-"+strconv.Itoa(unit.index+1),
+					bytes.NewReader(unit.data),
+					masterFn, unitUrl,
+					m.Auth)
+				lock.Lock()
+				if e != nil {
+					if err == nil {
+						err = e
+					}
+				} else {
+					cm.Chunks = append(cm.Chunks, &ChunkInfo{
+						Offset: int64(unit.index) * chunkSize,
+						Size:   int64(count),
+						Fid:    fid,
+					})
+					retSize += count
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	var readErr error
+	for i := 0; i < int(units); i++ {
+		lock.Lock()
+		failed := err != nil
+		lock.Unlock()
+		if failed {
+			break
+		}
+		data := make([]byte, chunkSize)
+		n, e := io.ReadFull(fi.Reader, data)
+		if e != nil && e != io.ErrUnexpectedEOF {
+			readErr = fmt.Errorf("read stripe unit %d of %s: %v", i, fi.FileName, e)
+			break
+		}
+		unitChan <- stripeUnit{index: i, data: data[:n]}
+	}
+	close(unitChan)
+	wg.Wait()
+	if err == nil {
+		err = readErr
+	}
+
+	if err == nil {
+		sort.Sort(cm.Chunks)
+		err = upload_chunked_file_manifest(fileUrl, &cm, jwt)
+	}
+	if err != nil {
+		// delete all uploaded chunks
+		cm.DeleteChunks(masterFn, usePublicUrl, grpcDialOption)
+		return 0, err
+	}
+	return retSize, nil
+}
@@ -0,0 +1,66 @@
+package operation
+
+import (
+	"bytes"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStripeUnitFid(t *testing.T) {
+	memberFids := []string{"3,01637037d6", "5,02a1b2c3d4", "7,0b5f8e1a2c"}
+	expected := []string{"3,01637037d6", "5,02a1b2c3d4", "7,0b5f8e1a2c", "3,01637037d6_1", "5,02a1b2c3d4_1", "7,0b5f8e1a2c_1", "3,01637037d6_2"}
+	for unit, fid := range expected {
+		if got := stripeUnitFid(memberFids, unit); got != fid {
+			t.Errorf("stripe unit %d: %s, expected %s", unit, got, fid)
+		}
+	}
+}
+
+func TestChunkedFileReaderParallelReads(t *testing.T) {
+	content := make([]byte, 10*1000+123)
+	rand.Read(content)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		unit, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		data := content[unit*1000:]
+		if len(data) > 1000 {
+			data = data[:1000]
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	var chunks []*ChunkInfo
+	for offset := 0; offset < len(content); offset += 1000 {
+		size := len(content) - offset
+		if size > 1000 {
+			size = 1000
+		}
+		chunks = append(chunks, &ChunkInfo{Fid: strconv.Itoa(offset / 1000), Offset: int64(offset), Size: int64(size)})
+	}
+	rand.Shuffle(len(chunks), func(i, j int) { chunks[i], chunks[j] = chunks[j], chunks[i] })
+
+	cf := NewChunkedFileReader(chunks, "", nil)
+	cf.lookupFileId = func(fileId string) (string, string, error) {
+		return server.URL + "/" + fileId, "", nil
+	}
+	cf.SetParallelReads(3)
+	defer cf.Close()
+
+	for _, offset := range []int64{0, 2500, 10000, 10122} {
+		if _, err := cf.Seek(offset, 0); err != nil {
+			t.Fatalf("seek %d: %v", offset, err)
+		}
+		var buf bytes.Buffer
+		if _, err := cf.WriteTo(&buf); err != nil {
+			t.Fatalf("read from %d: %v", offset, err)
+		}
+		if !bytes.Equal(buf.Bytes(), content[offset:]) {
+			t.Errorf("read %d bytes from %d, expected %d", buf.Len(), offset, len(content)-int(offset))
+		}
+	}
+}
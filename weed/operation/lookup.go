@@ -17,6 +17,7 @@ type Location struct {
 	Url        string `json:"url,omitempty"`
 	PublicUrl  string `json:"publicUrl,omitempty"`
 	DataCenter string `json:"dataCenter,omitempty"`
+	Rack       string `json:"rack,omitempty"`
 	GrpcPort   int    `json:"grpcPort,omitempty"`
 }
 
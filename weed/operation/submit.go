@@ -30,6 +30,7 @@ type FilePart struct {
 	Server      string //this comes from assign result
 	Fid         string //this comes from assign result, but customizable
 	Fsync       bool
+	StripeWidth int //stripe the chunks across this many volumes
 }
 
 type SubmitResult struct {
@@ -131,6 +132,9 @@ func (fi FilePart) Upload(maxMB int, masterFn GetMasterFn, usePublicUrl bool, jw
 		defer closer.Close()
 	}
 	baseName := path.Base(fi.FileName)
+	if maxMB > 0 && fi.FileSize > int64(maxMB*1024*1024) && fi.StripeWidth > 1 {
+		return fi.uploadStriped(fileUrl, baseName, int64(maxMB*1024*1024), masterFn, usePublicUrl, jwt, grpcDialOption)
+	}
 	if maxMB > 0 && fi.FileSize > int64(maxMB*1024*1024) {
 		chunkSize := int64(maxMB * 1024 * 1024)
 		chunks := fi.FileSize/chunkSize + 1
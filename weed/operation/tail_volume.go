@@ -28,8 +28,13 @@ func TailVolume(masterFn GetMasterFn, grpcDialOption grpc.DialOption, vid needle
 }
 
 func TailVolumeFromSource(volumeServer pb.ServerAddress, grpcDialOption grpc.DialOption, vid needle.VolumeId, sinceNs uint64, idleTimeoutSeconds int, fn func(n *needle.Needle) error) error {
+	return TailVolumeFromSourceWithContext(context.Background(), volumeServer, grpcDialOption, vid, sinceNs, idleTimeoutSeconds, fn)
+}
+
+// TailVolumeFromSourceWithContext tails the volume until the context is cancelled, or idle for the timeout if not 0
+func TailVolumeFromSourceWithContext(parentCtx context.Context, volumeServer pb.ServerAddress, grpcDialOption grpc.DialOption, vid needle.VolumeId, sinceNs uint64, idleTimeoutSeconds int, fn func(n *needle.Needle) error) error {
 	return WithVolumeServerClient(true, volumeServer, grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := context.WithCancel(parentCtx)
 		defer cancel()
 
 		stream, err := client.VolumeTailSender(ctx, &volume_server_pb.VolumeTailSenderRequest{
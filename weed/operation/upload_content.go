@@ -32,6 +32,9 @@ type UploadOption struct {
 	Jwt               security.EncodedJwt
 	RetryForever      bool
 	Md5               string
+	// Compression is "gzip" or "zstd" to compress the compressible content, "none" to keep it as is,
+	// or empty to gzip the content known to be compressible.
+	Compression string
 }
 
 type UploadResult struct {
@@ -100,6 +103,12 @@ func UploadWithRetry(filerClient filer_pb.FilerClient, assignRequest *filer_pb.A
 			}
 
 			fileId, auth = resp.FileId, security.EncodedJwt(resp.Auth)
+			if uploadOption.Compression == "" {
+				uploadOption.Compression = resp.Compression
+			}
+			if resp.Cipher {
+				uploadOption.Cipher = true
+			}
 			loc := resp.Location
 			host = filerClient.AdjustedUrl(loc)
 
@@ -175,7 +184,7 @@ func retriedUploadData(data []byte, option *UploadOption) (uploadResult *UploadR
 func doUploadData(data []byte, option *UploadOption) (uploadResult *UploadResult, err error) {
 	contentIsGzipped := option.IsInputCompressed
 	shouldGzipNow := false
-	if !option.IsInputCompressed {
+	if !option.IsInputCompressed && option.Compression != "none" {
 		if option.MimeType == "" {
 			option.MimeType = http.DetectContentType(data)
 			// println("detect1 mimetype to", MimeType)
@@ -189,6 +198,11 @@ func doUploadData(data []byte, option *UploadOption) (uploadResult *UploadResult
 			var compressed []byte
 			compressed, err = util.GzipData(data[0:128])
 			shouldGzipNow = len(compressed)*10 < 128*9 // can not compress to less than 90%
+		} else if !iAmSure && option.Compression != "" && len(data) > 128 {
+			// asked to compress, so try the content of any unknown type
+			var compressed []byte
+			compressed, err = util.GzipData(data[0:128])
+			shouldGzipNow = len(compressed)*10 < 128*9
 		}
 	}
 
@@ -199,9 +213,15 @@ func doUploadData(data []byte, option *UploadOption) (uploadResult *UploadResult
 	clearDataLen = len(data)
 	clearData := data
 	if shouldGzipNow && !option.Cipher {
-		compressed, compressErr := util.GzipData(data)
+		var compressed []byte
+		var compressErr error
+		if option.Compression == "zstd" {
+			compressed, compressErr = util.ZstdData(data)
+		} else {
+			compressed, compressErr = util.GzipData(data)
+		}
 		// fmt.Printf("data is compressed from %d ==> %d\n", len(data), len(compressed))
-		if compressErr == nil {
+		if compressErr == nil && (option.Compression == "" || len(compressed)*10 < len(data)*9) {
 			data = compressed
 			contentIsGzipped = true
 		}
@@ -258,6 +278,7 @@ func doUploadData(data []byte, option *UploadOption) (uploadResult *UploadResult
 			PairMap:           option.PairMap,
 			Jwt:               option.Jwt,
 			Md5:               option.Md5,
+			Compression:       compressionOf(data),
 		})
 		if uploadResult == nil {
 			return
@@ -271,6 +292,21 @@ func doUploadData(data []byte, option *UploadOption) (uploadResult *UploadResult
 	return uploadResult, err
 }
 
+// compressionOf detects the compression of the compressed content.
+func compressionOf(data []byte) string {
+	if util.IsZstdContent(data) {
+		return "zstd"
+	}
+	return "gzip"
+}
+
+func (option *UploadOption) contentEncoding() string {
+	if option.Compression == "zstd" {
+		return "zstd"
+	}
+	return "gzip"
+}
+
 func upload_content(fillBufferFunction func(w io.Writer) error, originalDataSize int, option *UploadOption) (*UploadResult, error) {
 	buf := GetBuffer()
 	defer PutBuffer(buf)
@@ -286,7 +322,7 @@ func upload_content(fillBufferFunction func(w io.Writer) error, originalDataSize
 		h.Set("Content-Type", option.MimeType)
 	}
 	if option.IsInputCompressed {
-		h.Set("Content-Encoding", "gzip")
+		h.Set("Content-Encoding", option.contentEncoding())
 	}
 	if option.Md5 != "" {
 		h.Set("Content-MD5", option.Md5)
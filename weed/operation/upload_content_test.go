@@ -0,0 +1,104 @@
+package operation
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// uploadedPart is the file part received by the test volume server
+type uploadedPart struct {
+	contentEncoding string
+	data            []byte
+}
+
+func newTestVolumeServer(t *testing.T) (*httptest.Server, *uploadedPart) {
+	received := &uploadedPart{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			t.Errorf("read multipart: %v", err)
+			return
+		}
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Errorf("read part: %v", err)
+			return
+		}
+		received.contentEncoding = part.Header.Get("Content-Encoding")
+		received.data, _ = io.ReadAll(part)
+		json.NewEncoder(w).Encode(&UploadResult{Size: uint32(len(received.data))})
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func TestUploadCompression(t *testing.T) {
+	server, received := newTestVolumeServer(t)
+
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog\n"), 100)
+	zeros := make([]byte, 4096)
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+	// compressible in the sampled head only
+	mostlyRandom := append(make([]byte, 128), random...)
+
+	for _, tc := range []struct {
+		name             string
+		data             []byte
+		compression      string
+		expectedEncoding string
+	}{
+		{"text by default", text, "", "gzip"},
+		{"text with gzip", text, "gzip", "gzip"},
+		{"text with zstd", text, "zstd", "zstd"},
+		{"text with none", text, "none", ""},
+		{"unknown type by default", zeros, "", ""},
+		{"unknown type with zstd", zeros, "zstd", "zstd"},
+		{"incompressible head", random, "gzip", ""},
+		{"compressed less than 10%", mostlyRandom, "zstd", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := UploadData(tc.data, &UploadOption{
+				UploadUrl:   server.URL,
+				Filename:    "file",
+				Compression: tc.compression,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if received.contentEncoding != tc.expectedEncoding {
+				t.Errorf("uploaded with Content-Encoding %q, expected %q", received.contentEncoding, tc.expectedEncoding)
+			}
+			if result.Size != uint32(len(tc.data)) || (result.Gzip == 1) != (tc.expectedEncoding != "") {
+				t.Errorf("upload result %+v", result)
+			}
+			switch tc.expectedEncoding {
+			case "gzip":
+				if !util.IsGzippedContent(received.data) {
+					t.Errorf("uploaded data is not gzipped")
+				}
+			case "zstd":
+				if !util.IsZstdContent(received.data) {
+					t.Errorf("uploaded data is not zstd compressed")
+				}
+			}
+			if tc.expectedEncoding != "" {
+				if len(received.data)*10 >= len(tc.data)*9 {
+					t.Errorf("compressed %d bytes into %d", len(tc.data), len(received.data))
+				}
+				if decompressed, err := util.DecompressData(received.data); err != nil || !bytes.Equal(decompressed, tc.data) {
+					t.Errorf("decompress: %v", err)
+				}
+			} else if !bytes.Equal(received.data, tc.data) {
+				t.Errorf("uploaded data is changed")
+			}
+		})
+	}
+}
@@ -28,8 +28,22 @@ type SeaweedFilerClient interface {
 	UpdateEntry(ctx context.Context, in *UpdateEntryRequest, opts ...grpc.CallOption) (*UpdateEntryResponse, error)
 	AppendToEntry(ctx context.Context, in *AppendToEntryRequest, opts ...grpc.CallOption) (*AppendToEntryResponse, error)
 	DeleteEntry(ctx context.Context, in *DeleteEntryRequest, opts ...grpc.CallOption) (*DeleteEntryResponse, error)
+	BatchMutateEntries(ctx context.Context, in *BatchMutateEntriesRequest, opts ...grpc.CallOption) (*BatchMutateEntriesResponse, error)
 	AtomicRenameEntry(ctx context.Context, in *AtomicRenameEntryRequest, opts ...grpc.CallOption) (*AtomicRenameEntryResponse, error)
 	StreamRenameEntry(ctx context.Context, in *StreamRenameEntryRequest, opts ...grpc.CallOption) (SeaweedFiler_StreamRenameEntryClient, error)
+	GetRenameProgress(ctx context.Context, in *GetRenameProgressRequest, opts ...grpc.CallOption) (*GetRenameProgressResponse, error)
+	CopyEntries(ctx context.Context, in *CopyEntriesRequest, opts ...grpc.CallOption) (SeaweedFiler_CopyEntriesClient, error)
+	GetDirectoryChecksum(ctx context.Context, in *GetDirectoryChecksumRequest, opts ...grpc.CallOption) (*GetDirectoryChecksumResponse, error)
+	RebuildDirectoryChecksum(ctx context.Context, in *RebuildDirectoryChecksumRequest, opts ...grpc.CallOption) (*RebuildDirectoryChecksumResponse, error)
+	GetUsageStats(ctx context.Context, in *GetUsageStatsRequest, opts ...grpc.CallOption) (*GetUsageStatsResponse, error)
+	FindEntriesByXattr(ctx context.Context, in *FindEntriesByXattrRequest, opts ...grpc.CallOption) (SeaweedFiler_FindEntriesByXattrClient, error)
+	RebuildXattrIndex(ctx context.Context, in *RebuildXattrIndexRequest, opts ...grpc.CallOption) (*RebuildXattrIndexResponse, error)
+	CheckHardLinks(ctx context.Context, in *CheckHardLinksRequest, opts ...grpc.CallOption) (SeaweedFiler_CheckHardLinksClient, error)
+	RewrapEncryptionKeys(ctx context.Context, in *RewrapEncryptionKeysRequest, opts ...grpc.CallOption) (*RewrapEncryptionKeysResponse, error)
+	ShardDirectory(ctx context.Context, in *ShardDirectoryRequest, opts ...grpc.CallOption) (SeaweedFiler_ShardDirectoryClient, error)
+	StoreMigration(ctx context.Context, in *StoreMigrationRequest, opts ...grpc.CallOption) (*StoreMigrationResponse, error)
+	ChunkAudit(ctx context.Context, in *ChunkAuditRequest, opts ...grpc.CallOption) (*ChunkAuditResponse, error)
+	DiskUsage(ctx context.Context, in *DiskUsageRequest, opts ...grpc.CallOption) (*DiskUsageResponse, error)
 	AssignVolume(ctx context.Context, in *AssignVolumeRequest, opts ...grpc.CallOption) (*AssignVolumeResponse, error)
 	LookupVolume(ctx context.Context, in *LookupVolumeRequest, opts ...grpc.CallOption) (*LookupVolumeResponse, error)
 	CollectionList(ctx context.Context, in *CollectionListRequest, opts ...grpc.CallOption) (*CollectionListResponse, error)
@@ -39,6 +53,9 @@ type SeaweedFilerClient interface {
 	GetFilerConfiguration(ctx context.Context, in *GetFilerConfigurationRequest, opts ...grpc.CallOption) (*GetFilerConfigurationResponse, error)
 	SubscribeMetadata(ctx context.Context, in *SubscribeMetadataRequest, opts ...grpc.CallOption) (SeaweedFiler_SubscribeMetadataClient, error)
 	SubscribeLocalMetadata(ctx context.Context, in *SubscribeMetadataRequest, opts ...grpc.CallOption) (SeaweedFiler_SubscribeLocalMetadataClient, error)
+	CommitConsumerOffset(ctx context.Context, in *CommitConsumerOffsetRequest, opts ...grpc.CallOption) (*CommitConsumerOffsetResponse, error)
+	ListConsumerOffsets(ctx context.Context, in *ListConsumerOffsetsRequest, opts ...grpc.CallOption) (*ListConsumerOffsetsResponse, error)
+	DeleteConsumerOffset(ctx context.Context, in *DeleteConsumerOffsetRequest, opts ...grpc.CallOption) (*DeleteConsumerOffsetResponse, error)
 	KvGet(ctx context.Context, in *KvGetRequest, opts ...grpc.CallOption) (*KvGetResponse, error)
 	KvPut(ctx context.Context, in *KvPutRequest, opts ...grpc.CallOption) (*KvPutResponse, error)
 	CacheRemoteObjectToLocalCluster(ctx context.Context, in *CacheRemoteObjectToLocalClusterRequest, opts ...grpc.CallOption) (*CacheRemoteObjectToLocalClusterResponse, error)
@@ -134,6 +151,15 @@ func (c *seaweedFilerClient) DeleteEntry(ctx context.Context, in *DeleteEntryReq
 	return out, nil
 }
 
+func (c *seaweedFilerClient) BatchMutateEntries(ctx context.Context, in *BatchMutateEntriesRequest, opts ...grpc.CallOption) (*BatchMutateEntriesResponse, error) {
+	out := new(BatchMutateEntriesResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/BatchMutateEntries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *seaweedFilerClient) AtomicRenameEntry(ctx context.Context, in *AtomicRenameEntryRequest, opts ...grpc.CallOption) (*AtomicRenameEntryResponse, error) {
 	out := new(AtomicRenameEntryResponse)
 	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/AtomicRenameEntry", in, out, opts...)
@@ -175,6 +201,215 @@ func (x *seaweedFilerStreamRenameEntryClient) Recv() (*StreamRenameEntryResponse
 	return m, nil
 }
 
+func (c *seaweedFilerClient) GetRenameProgress(ctx context.Context, in *GetRenameProgressRequest, opts ...grpc.CallOption) (*GetRenameProgressResponse, error) {
+	out := new(GetRenameProgressResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/GetRenameProgress", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerClient) CopyEntries(ctx context.Context, in *CopyEntriesRequest, opts ...grpc.CallOption) (SeaweedFiler_CopyEntriesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SeaweedFiler_ServiceDesc.Streams[2], "/filer_pb.SeaweedFiler/CopyEntries", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &seaweedFilerCopyEntriesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SeaweedFiler_CopyEntriesClient interface {
+	Recv() (*CopyEntriesResponse, error)
+	grpc.ClientStream
+}
+
+type seaweedFilerCopyEntriesClient struct {
+	grpc.ClientStream
+}
+
+func (x *seaweedFilerCopyEntriesClient) Recv() (*CopyEntriesResponse, error) {
+	m := new(CopyEntriesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *seaweedFilerClient) GetDirectoryChecksum(ctx context.Context, in *GetDirectoryChecksumRequest, opts ...grpc.CallOption) (*GetDirectoryChecksumResponse, error) {
+	out := new(GetDirectoryChecksumResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/GetDirectoryChecksum", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerClient) RebuildDirectoryChecksum(ctx context.Context, in *RebuildDirectoryChecksumRequest, opts ...grpc.CallOption) (*RebuildDirectoryChecksumResponse, error) {
+	out := new(RebuildDirectoryChecksumResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/RebuildDirectoryChecksum", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerClient) GetUsageStats(ctx context.Context, in *GetUsageStatsRequest, opts ...grpc.CallOption) (*GetUsageStatsResponse, error) {
+	out := new(GetUsageStatsResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/GetUsageStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerClient) FindEntriesByXattr(ctx context.Context, in *FindEntriesByXattrRequest, opts ...grpc.CallOption) (SeaweedFiler_FindEntriesByXattrClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SeaweedFiler_ServiceDesc.Streams[3], "/filer_pb.SeaweedFiler/FindEntriesByXattr", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &seaweedFilerFindEntriesByXattrClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SeaweedFiler_FindEntriesByXattrClient interface {
+	Recv() (*FindEntriesByXattrResponse, error)
+	grpc.ClientStream
+}
+
+type seaweedFilerFindEntriesByXattrClient struct {
+	grpc.ClientStream
+}
+
+func (x *seaweedFilerFindEntriesByXattrClient) Recv() (*FindEntriesByXattrResponse, error) {
+	m := new(FindEntriesByXattrResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *seaweedFilerClient) RebuildXattrIndex(ctx context.Context, in *RebuildXattrIndexRequest, opts ...grpc.CallOption) (*RebuildXattrIndexResponse, error) {
+	out := new(RebuildXattrIndexResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/RebuildXattrIndex", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerClient) CheckHardLinks(ctx context.Context, in *CheckHardLinksRequest, opts ...grpc.CallOption) (SeaweedFiler_CheckHardLinksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SeaweedFiler_ServiceDesc.Streams[4], "/filer_pb.SeaweedFiler/CheckHardLinks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &seaweedFilerCheckHardLinksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SeaweedFiler_CheckHardLinksClient interface {
+	Recv() (*CheckHardLinksResponse, error)
+	grpc.ClientStream
+}
+
+type seaweedFilerCheckHardLinksClient struct {
+	grpc.ClientStream
+}
+
+func (x *seaweedFilerCheckHardLinksClient) Recv() (*CheckHardLinksResponse, error) {
+	m := new(CheckHardLinksResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *seaweedFilerClient) RewrapEncryptionKeys(ctx context.Context, in *RewrapEncryptionKeysRequest, opts ...grpc.CallOption) (*RewrapEncryptionKeysResponse, error) {
+	out := new(RewrapEncryptionKeysResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/RewrapEncryptionKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerClient) ShardDirectory(ctx context.Context, in *ShardDirectoryRequest, opts ...grpc.CallOption) (SeaweedFiler_ShardDirectoryClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SeaweedFiler_ServiceDesc.Streams[5], "/filer_pb.SeaweedFiler/ShardDirectory", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &seaweedFilerShardDirectoryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SeaweedFiler_ShardDirectoryClient interface {
+	Recv() (*ShardDirectoryResponse, error)
+	grpc.ClientStream
+}
+
+type seaweedFilerShardDirectoryClient struct {
+	grpc.ClientStream
+}
+
+func (x *seaweedFilerShardDirectoryClient) Recv() (*ShardDirectoryResponse, error) {
+	m := new(ShardDirectoryResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *seaweedFilerClient) StoreMigration(ctx context.Context, in *StoreMigrationRequest, opts ...grpc.CallOption) (*StoreMigrationResponse, error) {
+	out := new(StoreMigrationResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/StoreMigration", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerClient) ChunkAudit(ctx context.Context, in *ChunkAuditRequest, opts ...grpc.CallOption) (*ChunkAuditResponse, error) {
+	out := new(ChunkAuditResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/ChunkAudit", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerClient) DiskUsage(ctx context.Context, in *DiskUsageRequest, opts ...grpc.CallOption) (*DiskUsageResponse, error) {
+	out := new(DiskUsageResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/DiskUsage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *seaweedFilerClient) AssignVolume(ctx context.Context, in *AssignVolumeRequest, opts ...grpc.CallOption) (*AssignVolumeResponse, error) {
 	out := new(AssignVolumeResponse)
 	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/AssignVolume", in, out, opts...)
@@ -239,7 +474,7 @@ func (c *seaweedFilerClient) GetFilerConfiguration(ctx context.Context, in *GetF
 }
 
 func (c *seaweedFilerClient) SubscribeMetadata(ctx context.Context, in *SubscribeMetadataRequest, opts ...grpc.CallOption) (SeaweedFiler_SubscribeMetadataClient, error) {
-	stream, err := c.cc.NewStream(ctx, &SeaweedFiler_ServiceDesc.Streams[2], "/filer_pb.SeaweedFiler/SubscribeMetadata", opts...)
+	stream, err := c.cc.NewStream(ctx, &SeaweedFiler_ServiceDesc.Streams[6], "/filer_pb.SeaweedFiler/SubscribeMetadata", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -271,7 +506,7 @@ func (x *seaweedFilerSubscribeMetadataClient) Recv() (*SubscribeMetadataResponse
 }
 
 func (c *seaweedFilerClient) SubscribeLocalMetadata(ctx context.Context, in *SubscribeMetadataRequest, opts ...grpc.CallOption) (SeaweedFiler_SubscribeLocalMetadataClient, error) {
-	stream, err := c.cc.NewStream(ctx, &SeaweedFiler_ServiceDesc.Streams[3], "/filer_pb.SeaweedFiler/SubscribeLocalMetadata", opts...)
+	stream, err := c.cc.NewStream(ctx, &SeaweedFiler_ServiceDesc.Streams[7], "/filer_pb.SeaweedFiler/SubscribeLocalMetadata", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -302,6 +537,33 @@ func (x *seaweedFilerSubscribeLocalMetadataClient) Recv() (*SubscribeMetadataRes
 	return m, nil
 }
 
+func (c *seaweedFilerClient) CommitConsumerOffset(ctx context.Context, in *CommitConsumerOffsetRequest, opts ...grpc.CallOption) (*CommitConsumerOffsetResponse, error) {
+	out := new(CommitConsumerOffsetResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/CommitConsumerOffset", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerClient) ListConsumerOffsets(ctx context.Context, in *ListConsumerOffsetsRequest, opts ...grpc.CallOption) (*ListConsumerOffsetsResponse, error) {
+	out := new(ListConsumerOffsetsResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/ListConsumerOffsets", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerClient) DeleteConsumerOffset(ctx context.Context, in *DeleteConsumerOffsetRequest, opts ...grpc.CallOption) (*DeleteConsumerOffsetResponse, error) {
+	out := new(DeleteConsumerOffsetResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/DeleteConsumerOffset", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *seaweedFilerClient) KvGet(ctx context.Context, in *KvGetRequest, opts ...grpc.CallOption) (*KvGetResponse, error) {
 	out := new(KvGetResponse)
 	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFiler/KvGet", in, out, opts...)
@@ -375,8 +637,22 @@ type SeaweedFilerServer interface {
 	UpdateEntry(context.Context, *UpdateEntryRequest) (*UpdateEntryResponse, error)
 	AppendToEntry(context.Context, *AppendToEntryRequest) (*AppendToEntryResponse, error)
 	DeleteEntry(context.Context, *DeleteEntryRequest) (*DeleteEntryResponse, error)
+	BatchMutateEntries(context.Context, *BatchMutateEntriesRequest) (*BatchMutateEntriesResponse, error)
 	AtomicRenameEntry(context.Context, *AtomicRenameEntryRequest) (*AtomicRenameEntryResponse, error)
 	StreamRenameEntry(*StreamRenameEntryRequest, SeaweedFiler_StreamRenameEntryServer) error
+	GetRenameProgress(context.Context, *GetRenameProgressRequest) (*GetRenameProgressResponse, error)
+	CopyEntries(*CopyEntriesRequest, SeaweedFiler_CopyEntriesServer) error
+	GetDirectoryChecksum(context.Context, *GetDirectoryChecksumRequest) (*GetDirectoryChecksumResponse, error)
+	RebuildDirectoryChecksum(context.Context, *RebuildDirectoryChecksumRequest) (*RebuildDirectoryChecksumResponse, error)
+	GetUsageStats(context.Context, *GetUsageStatsRequest) (*GetUsageStatsResponse, error)
+	FindEntriesByXattr(*FindEntriesByXattrRequest, SeaweedFiler_FindEntriesByXattrServer) error
+	RebuildXattrIndex(context.Context, *RebuildXattrIndexRequest) (*RebuildXattrIndexResponse, error)
+	CheckHardLinks(*CheckHardLinksRequest, SeaweedFiler_CheckHardLinksServer) error
+	RewrapEncryptionKeys(context.Context, *RewrapEncryptionKeysRequest) (*RewrapEncryptionKeysResponse, error)
+	ShardDirectory(*ShardDirectoryRequest, SeaweedFiler_ShardDirectoryServer) error
+	StoreMigration(context.Context, *StoreMigrationRequest) (*StoreMigrationResponse, error)
+	ChunkAudit(context.Context, *ChunkAuditRequest) (*ChunkAuditResponse, error)
+	DiskUsage(context.Context, *DiskUsageRequest) (*DiskUsageResponse, error)
 	AssignVolume(context.Context, *AssignVolumeRequest) (*AssignVolumeResponse, error)
 	LookupVolume(context.Context, *LookupVolumeRequest) (*LookupVolumeResponse, error)
 	CollectionList(context.Context, *CollectionListRequest) (*CollectionListResponse, error)
@@ -386,6 +662,9 @@ type SeaweedFilerServer interface {
 	GetFilerConfiguration(context.Context, *GetFilerConfigurationRequest) (*GetFilerConfigurationResponse, error)
 	SubscribeMetadata(*SubscribeMetadataRequest, SeaweedFiler_SubscribeMetadataServer) error
 	SubscribeLocalMetadata(*SubscribeMetadataRequest, SeaweedFiler_SubscribeLocalMetadataServer) error
+	CommitConsumerOffset(context.Context, *CommitConsumerOffsetRequest) (*CommitConsumerOffsetResponse, error)
+	ListConsumerOffsets(context.Context, *ListConsumerOffsetsRequest) (*ListConsumerOffsetsResponse, error)
+	DeleteConsumerOffset(context.Context, *DeleteConsumerOffsetRequest) (*DeleteConsumerOffsetResponse, error)
 	KvGet(context.Context, *KvGetRequest) (*KvGetResponse, error)
 	KvPut(context.Context, *KvPutRequest) (*KvPutResponse, error)
 	CacheRemoteObjectToLocalCluster(context.Context, *CacheRemoteObjectToLocalClusterRequest) (*CacheRemoteObjectToLocalClusterResponse, error)
@@ -419,12 +698,54 @@ func (UnimplementedSeaweedFilerServer) AppendToEntry(context.Context, *AppendToE
 func (UnimplementedSeaweedFilerServer) DeleteEntry(context.Context, *DeleteEntryRequest) (*DeleteEntryResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteEntry not implemented")
 }
+func (UnimplementedSeaweedFilerServer) BatchMutateEntries(context.Context, *BatchMutateEntriesRequest) (*BatchMutateEntriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchMutateEntries not implemented")
+}
 func (UnimplementedSeaweedFilerServer) AtomicRenameEntry(context.Context, *AtomicRenameEntryRequest) (*AtomicRenameEntryResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AtomicRenameEntry not implemented")
 }
 func (UnimplementedSeaweedFilerServer) StreamRenameEntry(*StreamRenameEntryRequest, SeaweedFiler_StreamRenameEntryServer) error {
 	return status.Errorf(codes.Unimplemented, "method StreamRenameEntry not implemented")
 }
+func (UnimplementedSeaweedFilerServer) GetRenameProgress(context.Context, *GetRenameProgressRequest) (*GetRenameProgressResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRenameProgress not implemented")
+}
+func (UnimplementedSeaweedFilerServer) CopyEntries(*CopyEntriesRequest, SeaweedFiler_CopyEntriesServer) error {
+	return status.Errorf(codes.Unimplemented, "method CopyEntries not implemented")
+}
+func (UnimplementedSeaweedFilerServer) GetDirectoryChecksum(context.Context, *GetDirectoryChecksumRequest) (*GetDirectoryChecksumResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDirectoryChecksum not implemented")
+}
+func (UnimplementedSeaweedFilerServer) RebuildDirectoryChecksum(context.Context, *RebuildDirectoryChecksumRequest) (*RebuildDirectoryChecksumResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RebuildDirectoryChecksum not implemented")
+}
+func (UnimplementedSeaweedFilerServer) GetUsageStats(context.Context, *GetUsageStatsRequest) (*GetUsageStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUsageStats not implemented")
+}
+func (UnimplementedSeaweedFilerServer) FindEntriesByXattr(*FindEntriesByXattrRequest, SeaweedFiler_FindEntriesByXattrServer) error {
+	return status.Errorf(codes.Unimplemented, "method FindEntriesByXattr not implemented")
+}
+func (UnimplementedSeaweedFilerServer) RebuildXattrIndex(context.Context, *RebuildXattrIndexRequest) (*RebuildXattrIndexResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RebuildXattrIndex not implemented")
+}
+func (UnimplementedSeaweedFilerServer) CheckHardLinks(*CheckHardLinksRequest, SeaweedFiler_CheckHardLinksServer) error {
+	return status.Errorf(codes.Unimplemented, "method CheckHardLinks not implemented")
+}
+func (UnimplementedSeaweedFilerServer) RewrapEncryptionKeys(context.Context, *RewrapEncryptionKeysRequest) (*RewrapEncryptionKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RewrapEncryptionKeys not implemented")
+}
+func (UnimplementedSeaweedFilerServer) ShardDirectory(*ShardDirectoryRequest, SeaweedFiler_ShardDirectoryServer) error {
+	return status.Errorf(codes.Unimplemented, "method ShardDirectory not implemented")
+}
+func (UnimplementedSeaweedFilerServer) StoreMigration(context.Context, *StoreMigrationRequest) (*StoreMigrationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StoreMigration not implemented")
+}
+func (UnimplementedSeaweedFilerServer) ChunkAudit(context.Context, *ChunkAuditRequest) (*ChunkAuditResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChunkAudit not implemented")
+}
+func (UnimplementedSeaweedFilerServer) DiskUsage(context.Context, *DiskUsageRequest) (*DiskUsageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DiskUsage not implemented")
+}
 func (UnimplementedSeaweedFilerServer) AssignVolume(context.Context, *AssignVolumeRequest) (*AssignVolumeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AssignVolume not implemented")
 }
@@ -452,6 +773,15 @@ func (UnimplementedSeaweedFilerServer) SubscribeMetadata(*SubscribeMetadataReque
 func (UnimplementedSeaweedFilerServer) SubscribeLocalMetadata(*SubscribeMetadataRequest, SeaweedFiler_SubscribeLocalMetadataServer) error {
 	return status.Errorf(codes.Unimplemented, "method SubscribeLocalMetadata not implemented")
 }
+func (UnimplementedSeaweedFilerServer) CommitConsumerOffset(context.Context, *CommitConsumerOffsetRequest) (*CommitConsumerOffsetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitConsumerOffset not implemented")
+}
+func (UnimplementedSeaweedFilerServer) ListConsumerOffsets(context.Context, *ListConsumerOffsetsRequest) (*ListConsumerOffsetsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListConsumerOffsets not implemented")
+}
+func (UnimplementedSeaweedFilerServer) DeleteConsumerOffset(context.Context, *DeleteConsumerOffsetRequest) (*DeleteConsumerOffsetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteConsumerOffset not implemented")
+}
 func (UnimplementedSeaweedFilerServer) KvGet(context.Context, *KvGetRequest) (*KvGetResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method KvGet not implemented")
 }
@@ -597,6 +927,24 @@ func _SeaweedFiler_DeleteEntry_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SeaweedFiler_BatchMutateEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchMutateEntriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).BatchMutateEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/BatchMutateEntries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).BatchMutateEntries(ctx, req.(*BatchMutateEntriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SeaweedFiler_AtomicRenameEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(AtomicRenameEntryRequest)
 	if err := dec(in); err != nil {
@@ -636,89 +984,335 @@ func (x *seaweedFilerStreamRenameEntryServer) Send(m *StreamRenameEntryResponse)
 	return x.ServerStream.SendMsg(m)
 }
 
-func _SeaweedFiler_AssignVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(AssignVolumeRequest)
+func _SeaweedFiler_GetRenameProgress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRenameProgressRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SeaweedFilerServer).AssignVolume(ctx, in)
+		return srv.(SeaweedFilerServer).GetRenameProgress(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/filer_pb.SeaweedFiler/AssignVolume",
+		FullMethod: "/filer_pb.SeaweedFiler/GetRenameProgress",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SeaweedFilerServer).AssignVolume(ctx, req.(*AssignVolumeRequest))
+		return srv.(SeaweedFilerServer).GetRenameProgress(ctx, req.(*GetRenameProgressRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SeaweedFiler_LookupVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(LookupVolumeRequest)
+func _SeaweedFiler_CopyEntries_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CopyEntriesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SeaweedFilerServer).CopyEntries(m, &seaweedFilerCopyEntriesServer{stream})
+}
+
+type SeaweedFiler_CopyEntriesServer interface {
+	Send(*CopyEntriesResponse) error
+	grpc.ServerStream
+}
+
+type seaweedFilerCopyEntriesServer struct {
+	grpc.ServerStream
+}
+
+func (x *seaweedFilerCopyEntriesServer) Send(m *CopyEntriesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SeaweedFiler_GetDirectoryChecksum_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDirectoryChecksumRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SeaweedFilerServer).LookupVolume(ctx, in)
+		return srv.(SeaweedFilerServer).GetDirectoryChecksum(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/filer_pb.SeaweedFiler/LookupVolume",
+		FullMethod: "/filer_pb.SeaweedFiler/GetDirectoryChecksum",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SeaweedFilerServer).LookupVolume(ctx, req.(*LookupVolumeRequest))
+		return srv.(SeaweedFilerServer).GetDirectoryChecksum(ctx, req.(*GetDirectoryChecksumRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SeaweedFiler_CollectionList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(CollectionListRequest)
+func _SeaweedFiler_RebuildDirectoryChecksum_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RebuildDirectoryChecksumRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SeaweedFilerServer).CollectionList(ctx, in)
+		return srv.(SeaweedFilerServer).RebuildDirectoryChecksum(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/filer_pb.SeaweedFiler/CollectionList",
+		FullMethod: "/filer_pb.SeaweedFiler/RebuildDirectoryChecksum",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SeaweedFilerServer).CollectionList(ctx, req.(*CollectionListRequest))
+		return srv.(SeaweedFilerServer).RebuildDirectoryChecksum(ctx, req.(*RebuildDirectoryChecksumRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SeaweedFiler_DeleteCollection_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteCollectionRequest)
+func _SeaweedFiler_GetUsageStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUsageStatsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SeaweedFilerServer).DeleteCollection(ctx, in)
+		return srv.(SeaweedFilerServer).GetUsageStats(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/filer_pb.SeaweedFiler/DeleteCollection",
+		FullMethod: "/filer_pb.SeaweedFiler/GetUsageStats",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SeaweedFilerServer).DeleteCollection(ctx, req.(*DeleteCollectionRequest))
+		return srv.(SeaweedFilerServer).GetUsageStats(ctx, req.(*GetUsageStatsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SeaweedFiler_Statistics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(StatisticsRequest)
+func _SeaweedFiler_FindEntriesByXattr_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FindEntriesByXattrRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SeaweedFilerServer).FindEntriesByXattr(m, &seaweedFilerFindEntriesByXattrServer{stream})
+}
+
+type SeaweedFiler_FindEntriesByXattrServer interface {
+	Send(*FindEntriesByXattrResponse) error
+	grpc.ServerStream
+}
+
+type seaweedFilerFindEntriesByXattrServer struct {
+	grpc.ServerStream
+}
+
+func (x *seaweedFilerFindEntriesByXattrServer) Send(m *FindEntriesByXattrResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SeaweedFiler_RebuildXattrIndex_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RebuildXattrIndexRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SeaweedFilerServer).Statistics(ctx, in)
+		return srv.(SeaweedFilerServer).RebuildXattrIndex(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/filer_pb.SeaweedFiler/Statistics",
+		FullMethod: "/filer_pb.SeaweedFiler/RebuildXattrIndex",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).RebuildXattrIndex(ctx, req.(*RebuildXattrIndexRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_CheckHardLinks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CheckHardLinksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SeaweedFilerServer).CheckHardLinks(m, &seaweedFilerCheckHardLinksServer{stream})
+}
+
+type SeaweedFiler_CheckHardLinksServer interface {
+	Send(*CheckHardLinksResponse) error
+	grpc.ServerStream
+}
+
+type seaweedFilerCheckHardLinksServer struct {
+	grpc.ServerStream
+}
+
+func (x *seaweedFilerCheckHardLinksServer) Send(m *CheckHardLinksResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SeaweedFiler_RewrapEncryptionKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RewrapEncryptionKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).RewrapEncryptionKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/RewrapEncryptionKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).RewrapEncryptionKeys(ctx, req.(*RewrapEncryptionKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_ShardDirectory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ShardDirectoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SeaweedFilerServer).ShardDirectory(m, &seaweedFilerShardDirectoryServer{stream})
+}
+
+type SeaweedFiler_ShardDirectoryServer interface {
+	Send(*ShardDirectoryResponse) error
+	grpc.ServerStream
+}
+
+type seaweedFilerShardDirectoryServer struct {
+	grpc.ServerStream
+}
+
+func (x *seaweedFilerShardDirectoryServer) Send(m *ShardDirectoryResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SeaweedFiler_StoreMigration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StoreMigrationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).StoreMigration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/StoreMigration",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).StoreMigration(ctx, req.(*StoreMigrationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_ChunkAudit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChunkAuditRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).ChunkAudit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/ChunkAudit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).ChunkAudit(ctx, req.(*ChunkAuditRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_DiskUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiskUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).DiskUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/DiskUsage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).DiskUsage(ctx, req.(*DiskUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_AssignVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AssignVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).AssignVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/AssignVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).AssignVolume(ctx, req.(*AssignVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_LookupVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).LookupVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/LookupVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).LookupVolume(ctx, req.(*LookupVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_CollectionList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CollectionListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).CollectionList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/CollectionList",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).CollectionList(ctx, req.(*CollectionListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_DeleteCollection_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCollectionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).DeleteCollection(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/DeleteCollection",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).DeleteCollection(ctx, req.(*DeleteCollectionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_Statistics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatisticsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).Statistics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/Statistics",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
 		return srv.(SeaweedFilerServer).Statistics(ctx, req.(*StatisticsRequest))
@@ -804,6 +1398,60 @@ func (x *seaweedFilerSubscribeLocalMetadataServer) Send(m *SubscribeMetadataResp
 	return x.ServerStream.SendMsg(m)
 }
 
+func _SeaweedFiler_CommitConsumerOffset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitConsumerOffsetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).CommitConsumerOffset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/CommitConsumerOffset",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).CommitConsumerOffset(ctx, req.(*CommitConsumerOffsetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_ListConsumerOffsets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListConsumerOffsetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).ListConsumerOffsets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/ListConsumerOffsets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).ListConsumerOffsets(ctx, req.(*ListConsumerOffsetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_DeleteConsumerOffset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteConsumerOffsetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).DeleteConsumerOffset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFiler/DeleteConsumerOffset",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).DeleteConsumerOffset(ctx, req.(*DeleteConsumerOffsetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SeaweedFiler_KvGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(KvGetRequest)
 	if err := dec(in); err != nil {
@@ -957,10 +1605,50 @@ var SeaweedFiler_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteEntry",
 			Handler:    _SeaweedFiler_DeleteEntry_Handler,
 		},
+		{
+			MethodName: "BatchMutateEntries",
+			Handler:    _SeaweedFiler_BatchMutateEntries_Handler,
+		},
 		{
 			MethodName: "AtomicRenameEntry",
 			Handler:    _SeaweedFiler_AtomicRenameEntry_Handler,
 		},
+		{
+			MethodName: "GetRenameProgress",
+			Handler:    _SeaweedFiler_GetRenameProgress_Handler,
+		},
+		{
+			MethodName: "GetDirectoryChecksum",
+			Handler:    _SeaweedFiler_GetDirectoryChecksum_Handler,
+		},
+		{
+			MethodName: "RebuildDirectoryChecksum",
+			Handler:    _SeaweedFiler_RebuildDirectoryChecksum_Handler,
+		},
+		{
+			MethodName: "GetUsageStats",
+			Handler:    _SeaweedFiler_GetUsageStats_Handler,
+		},
+		{
+			MethodName: "RebuildXattrIndex",
+			Handler:    _SeaweedFiler_RebuildXattrIndex_Handler,
+		},
+		{
+			MethodName: "RewrapEncryptionKeys",
+			Handler:    _SeaweedFiler_RewrapEncryptionKeys_Handler,
+		},
+		{
+			MethodName: "StoreMigration",
+			Handler:    _SeaweedFiler_StoreMigration_Handler,
+		},
+		{
+			MethodName: "ChunkAudit",
+			Handler:    _SeaweedFiler_ChunkAudit_Handler,
+		},
+		{
+			MethodName: "DiskUsage",
+			Handler:    _SeaweedFiler_DiskUsage_Handler,
+		},
 		{
 			MethodName: "AssignVolume",
 			Handler:    _SeaweedFiler_AssignVolume_Handler,
@@ -989,6 +1677,18 @@ var SeaweedFiler_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetFilerConfiguration",
 			Handler:    _SeaweedFiler_GetFilerConfiguration_Handler,
 		},
+		{
+			MethodName: "CommitConsumerOffset",
+			Handler:    _SeaweedFiler_CommitConsumerOffset_Handler,
+		},
+		{
+			MethodName: "ListConsumerOffsets",
+			Handler:    _SeaweedFiler_ListConsumerOffsets_Handler,
+		},
+		{
+			MethodName: "DeleteConsumerOffset",
+			Handler:    _SeaweedFiler_DeleteConsumerOffset_Handler,
+		},
 		{
 			MethodName: "KvGet",
 			Handler:    _SeaweedFiler_KvGet_Handler,
@@ -1029,6 +1729,26 @@ var SeaweedFiler_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _SeaweedFiler_StreamRenameEntry_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "CopyEntries",
+			Handler:       _SeaweedFiler_CopyEntries_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "FindEntriesByXattr",
+			Handler:       _SeaweedFiler_FindEntriesByXattr_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "CheckHardLinks",
+			Handler:       _SeaweedFiler_CheckHardLinks_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ShardDirectory",
+			Handler:       _SeaweedFiler_ShardDirectory_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "SubscribeMetadata",
 			Handler:       _SeaweedFiler_SubscribeMetadata_Handler,
@@ -1042,3 +1762,125 @@ var SeaweedFiler_ServiceDesc = grpc.ServiceDesc{
 	},
 	Metadata: "filer.proto",
 }
+
+// SeaweedFilerHookClient is the client API for SeaweedFilerHook service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SeaweedFilerHookClient interface {
+	BeforeOperation(ctx context.Context, in *FilerHookRequest, opts ...grpc.CallOption) (*FilerHookResponse, error)
+	AfterOperation(ctx context.Context, in *FilerHookRequest, opts ...grpc.CallOption) (*FilerHookResponse, error)
+}
+
+type seaweedFilerHookClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSeaweedFilerHookClient(cc grpc.ClientConnInterface) SeaweedFilerHookClient {
+	return &seaweedFilerHookClient{cc}
+}
+
+func (c *seaweedFilerHookClient) BeforeOperation(ctx context.Context, in *FilerHookRequest, opts ...grpc.CallOption) (*FilerHookResponse, error) {
+	out := new(FilerHookResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFilerHook/BeforeOperation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerHookClient) AfterOperation(ctx context.Context, in *FilerHookRequest, opts ...grpc.CallOption) (*FilerHookResponse, error) {
+	out := new(FilerHookResponse)
+	err := c.cc.Invoke(ctx, "/filer_pb.SeaweedFilerHook/AfterOperation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SeaweedFilerHookServer is the server API for SeaweedFilerHook service.
+// All implementations must embed UnimplementedSeaweedFilerHookServer
+// for forward compatibility
+type SeaweedFilerHookServer interface {
+	BeforeOperation(context.Context, *FilerHookRequest) (*FilerHookResponse, error)
+	AfterOperation(context.Context, *FilerHookRequest) (*FilerHookResponse, error)
+	mustEmbedUnimplementedSeaweedFilerHookServer()
+}
+
+// UnimplementedSeaweedFilerHookServer must be embedded to have forward compatible implementations.
+type UnimplementedSeaweedFilerHookServer struct {
+}
+
+func (UnimplementedSeaweedFilerHookServer) BeforeOperation(context.Context, *FilerHookRequest) (*FilerHookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BeforeOperation not implemented")
+}
+func (UnimplementedSeaweedFilerHookServer) AfterOperation(context.Context, *FilerHookRequest) (*FilerHookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AfterOperation not implemented")
+}
+func (UnimplementedSeaweedFilerHookServer) mustEmbedUnimplementedSeaweedFilerHookServer() {}
+
+// UnsafeSeaweedFilerHookServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SeaweedFilerHookServer will
+// result in compilation errors.
+type UnsafeSeaweedFilerHookServer interface {
+	mustEmbedUnimplementedSeaweedFilerHookServer()
+}
+
+func RegisterSeaweedFilerHookServer(s grpc.ServiceRegistrar, srv SeaweedFilerHookServer) {
+	s.RegisterService(&SeaweedFilerHook_ServiceDesc, srv)
+}
+
+func _SeaweedFilerHook_BeforeOperation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FilerHookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerHookServer).BeforeOperation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFilerHook/BeforeOperation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerHookServer).BeforeOperation(ctx, req.(*FilerHookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFilerHook_AfterOperation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FilerHookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerHookServer).AfterOperation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/filer_pb.SeaweedFilerHook/AfterOperation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerHookServer).AfterOperation(ctx, req.(*FilerHookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SeaweedFilerHook_ServiceDesc is the grpc.ServiceDesc for SeaweedFilerHook service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SeaweedFilerHook_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "filer_pb.SeaweedFilerHook",
+	HandlerType: (*SeaweedFilerHookServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "BeforeOperation",
+			Handler:    _SeaweedFilerHook_BeforeOperation_Handler,
+		},
+		{
+			MethodName: "AfterOperation",
+			Handler:    _SeaweedFilerHook_AfterOperation_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "filer.proto",
+}
@@ -29,9 +29,14 @@ type MetadataFollowOption struct {
 	PathPrefix             string
 	AdditionalPathPrefixes []string
 	DirectoriesToWatch     []string
+	FilterExpression       string
 	StartTsNs              int64
 	StopTsNs               int64
 	EventErrorType         EventErrorType
+	// ConsumerName resumes from the offset committed with the name, if StartTsNs is not set,
+	// and commits the offset of the processed events every CommitInterval.
+	ConsumerName   string
+	CommitInterval time.Duration
 }
 
 type ProcessMetadataFunc func(resp *filer_pb.SubscribeMetadataResponse) error
@@ -60,20 +65,39 @@ func makeSubscribeMetadataFunc(option *MetadataFollowOption, processEventFn Proc
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 		stream, err := client.SubscribeMetadata(ctx, &filer_pb.SubscribeMetadataRequest{
-			ClientName:   option.ClientName,
-			PathPrefix:   option.PathPrefix,
-			PathPrefixes: option.AdditionalPathPrefixes,
-			Directories:  option.DirectoriesToWatch,
-			SinceNs:      option.StartTsNs,
-			Signature:    option.SelfSignature,
-			ClientId:     option.ClientId,
-			ClientEpoch:  option.ClientEpoch,
-			UntilNs:      option.StopTsNs,
+			ClientName:       option.ClientName,
+			PathPrefix:       option.PathPrefix,
+			PathPrefixes:     option.AdditionalPathPrefixes,
+			Directories:      option.DirectoriesToWatch,
+			SinceNs:          option.StartTsNs,
+			Signature:        option.SelfSignature,
+			ClientId:         option.ClientId,
+			ClientEpoch:      option.ClientEpoch,
+			UntilNs:          option.StopTsNs,
+			FilterExpression: option.FilterExpression,
+			ConsumerName:     option.ConsumerName,
 		})
 		if err != nil {
 			return fmt.Errorf("subscribe: %v", err)
 		}
 
+		var committedTsNs int64
+		var lastCommitTime time.Time
+		commitOffset := func() {
+			if option.ConsumerName == "" || option.StartTsNs <= committedTsNs {
+				return
+			}
+			if _, err := client.CommitConsumerOffset(context.Background(), &filer_pb.CommitConsumerOffsetRequest{
+				ConsumerName: option.ConsumerName,
+				TsNs:         option.StartTsNs,
+			}); err != nil {
+				glog.Errorf("commit offset of consumer %s: %v", option.ConsumerName, err)
+				return
+			}
+			committedTsNs, lastCommitTime = option.StartTsNs, time.Now()
+		}
+		defer commitOffset()
+
 		for {
 			resp, listenErr := stream.Recv()
 			if listenErr == io.EOF {
@@ -101,6 +125,9 @@ func makeSubscribeMetadataFunc(option *MetadataFollowOption, processEventFn Proc
 				}
 			}
 			option.StartTsNs = resp.TsNs
+			if lastCommitTime.Add(option.CommitInterval).Before(time.Now()) {
+				commitOffset()
+			}
 		}
 	}
 }
@@ -44,6 +44,21 @@ type SeaweedClient interface {
 	RaftListClusterServers(ctx context.Context, in *RaftListClusterServersRequest, opts ...grpc.CallOption) (*RaftListClusterServersResponse, error)
 	RaftAddServer(ctx context.Context, in *RaftAddServerRequest, opts ...grpc.CallOption) (*RaftAddServerResponse, error)
 	RaftRemoveServer(ctx context.Context, in *RaftRemoveServerRequest, opts ...grpc.CallOption) (*RaftRemoveServerResponse, error)
+	RaftPromoteServer(ctx context.Context, in *RaftPromoteServerRequest, opts ...grpc.CallOption) (*RaftPromoteServerResponse, error)
+	RaftDemoteServer(ctx context.Context, in *RaftDemoteServerRequest, opts ...grpc.CallOption) (*RaftDemoteServerResponse, error)
+	RaftTransferLeadership(ctx context.Context, in *RaftTransferLeadershipRequest, opts ...grpc.CallOption) (*RaftTransferLeadershipResponse, error)
+	DrainVolumeServer(ctx context.Context, in *DrainVolumeServerRequest, opts ...grpc.CallOption) (*DrainVolumeServerResponse, error)
+	ListVolumeServerDrains(ctx context.Context, in *ListVolumeServerDrainsRequest, opts ...grpc.CallOption) (*ListVolumeServerDrainsResponse, error)
+	SetMaintenance(ctx context.Context, in *SetMaintenanceRequest, opts ...grpc.CallOption) (*SetMaintenanceResponse, error)
+	ListMaintenances(ctx context.Context, in *ListMaintenancesRequest, opts ...grpc.CallOption) (*ListMaintenancesResponse, error)
+	WatchTopology(ctx context.Context, in *WatchTopologyRequest, opts ...grpc.CallOption) (Seaweed_WatchTopologyClient, error)
+	ListTopologyHistory(ctx context.Context, in *ListTopologyHistoryRequest, opts ...grpc.CallOption) (*ListTopologyHistoryResponse, error)
+	SetCollectionQuota(ctx context.Context, in *SetCollectionQuotaRequest, opts ...grpc.CallOption) (*SetCollectionQuotaResponse, error)
+	ListCollectionQuotas(ctx context.Context, in *ListCollectionQuotasRequest, opts ...grpc.CallOption) (*ListCollectionQuotasResponse, error)
+	ListVolumeRepairs(ctx context.Context, in *ListVolumeRepairsRequest, opts ...grpc.CallOption) (*ListVolumeRepairsResponse, error)
+	ListLifecycleActions(ctx context.Context, in *ListLifecycleActionsRequest, opts ...grpc.CallOption) (*ListLifecycleActionsResponse, error)
+	GetClusterLoad(ctx context.Context, in *GetClusterLoadRequest, opts ...grpc.CallOption) (*GetClusterLoadResponse, error)
+	SimulatePlacement(ctx context.Context, in *SimulatePlacementRequest, opts ...grpc.CallOption) (*SimulatePlacementResponse, error)
 }
 
 type seaweedClient struct {
@@ -318,6 +333,164 @@ func (c *seaweedClient) RaftRemoveServer(ctx context.Context, in *RaftRemoveServ
 	return out, nil
 }
 
+func (c *seaweedClient) RaftPromoteServer(ctx context.Context, in *RaftPromoteServerRequest, opts ...grpc.CallOption) (*RaftPromoteServerResponse, error) {
+	out := new(RaftPromoteServerResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/RaftPromoteServer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) RaftDemoteServer(ctx context.Context, in *RaftDemoteServerRequest, opts ...grpc.CallOption) (*RaftDemoteServerResponse, error) {
+	out := new(RaftDemoteServerResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/RaftDemoteServer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) RaftTransferLeadership(ctx context.Context, in *RaftTransferLeadershipRequest, opts ...grpc.CallOption) (*RaftTransferLeadershipResponse, error) {
+	out := new(RaftTransferLeadershipResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/RaftTransferLeadership", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) DrainVolumeServer(ctx context.Context, in *DrainVolumeServerRequest, opts ...grpc.CallOption) (*DrainVolumeServerResponse, error) {
+	out := new(DrainVolumeServerResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/DrainVolumeServer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) ListVolumeServerDrains(ctx context.Context, in *ListVolumeServerDrainsRequest, opts ...grpc.CallOption) (*ListVolumeServerDrainsResponse, error) {
+	out := new(ListVolumeServerDrainsResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/ListVolumeServerDrains", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) SetMaintenance(ctx context.Context, in *SetMaintenanceRequest, opts ...grpc.CallOption) (*SetMaintenanceResponse, error) {
+	out := new(SetMaintenanceResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/SetMaintenance", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) ListMaintenances(ctx context.Context, in *ListMaintenancesRequest, opts ...grpc.CallOption) (*ListMaintenancesResponse, error) {
+	out := new(ListMaintenancesResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/ListMaintenances", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) WatchTopology(ctx context.Context, in *WatchTopologyRequest, opts ...grpc.CallOption) (Seaweed_WatchTopologyClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Seaweed_ServiceDesc.Streams[3], "/master_pb.Seaweed/WatchTopology", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &seaweedWatchTopologyClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Seaweed_WatchTopologyClient interface {
+	Recv() (*TopologyEvent, error)
+	grpc.ClientStream
+}
+
+type seaweedWatchTopologyClient struct {
+	grpc.ClientStream
+}
+
+func (x *seaweedWatchTopologyClient) Recv() (*TopologyEvent, error) {
+	m := new(TopologyEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *seaweedClient) ListTopologyHistory(ctx context.Context, in *ListTopologyHistoryRequest, opts ...grpc.CallOption) (*ListTopologyHistoryResponse, error) {
+	out := new(ListTopologyHistoryResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/ListTopologyHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) SetCollectionQuota(ctx context.Context, in *SetCollectionQuotaRequest, opts ...grpc.CallOption) (*SetCollectionQuotaResponse, error) {
+	out := new(SetCollectionQuotaResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/SetCollectionQuota", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) ListCollectionQuotas(ctx context.Context, in *ListCollectionQuotasRequest, opts ...grpc.CallOption) (*ListCollectionQuotasResponse, error) {
+	out := new(ListCollectionQuotasResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/ListCollectionQuotas", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) ListVolumeRepairs(ctx context.Context, in *ListVolumeRepairsRequest, opts ...grpc.CallOption) (*ListVolumeRepairsResponse, error) {
+	out := new(ListVolumeRepairsResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/ListVolumeRepairs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) ListLifecycleActions(ctx context.Context, in *ListLifecycleActionsRequest, opts ...grpc.CallOption) (*ListLifecycleActionsResponse, error) {
+	out := new(ListLifecycleActionsResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/ListLifecycleActions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) GetClusterLoad(ctx context.Context, in *GetClusterLoadRequest, opts ...grpc.CallOption) (*GetClusterLoadResponse, error) {
+	out := new(GetClusterLoadResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/GetClusterLoad", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedClient) SimulatePlacement(ctx context.Context, in *SimulatePlacementRequest, opts ...grpc.CallOption) (*SimulatePlacementResponse, error) {
+	out := new(SimulatePlacementResponse)
+	err := c.cc.Invoke(ctx, "/master_pb.Seaweed/SimulatePlacement", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SeaweedServer is the server API for Seaweed service.
 // All implementations must embed UnimplementedSeaweedServer
 // for forward compatibility
@@ -344,6 +517,21 @@ type SeaweedServer interface {
 	RaftListClusterServers(context.Context, *RaftListClusterServersRequest) (*RaftListClusterServersResponse, error)
 	RaftAddServer(context.Context, *RaftAddServerRequest) (*RaftAddServerResponse, error)
 	RaftRemoveServer(context.Context, *RaftRemoveServerRequest) (*RaftRemoveServerResponse, error)
+	RaftPromoteServer(context.Context, *RaftPromoteServerRequest) (*RaftPromoteServerResponse, error)
+	RaftDemoteServer(context.Context, *RaftDemoteServerRequest) (*RaftDemoteServerResponse, error)
+	RaftTransferLeadership(context.Context, *RaftTransferLeadershipRequest) (*RaftTransferLeadershipResponse, error)
+	DrainVolumeServer(context.Context, *DrainVolumeServerRequest) (*DrainVolumeServerResponse, error)
+	ListVolumeServerDrains(context.Context, *ListVolumeServerDrainsRequest) (*ListVolumeServerDrainsResponse, error)
+	SetMaintenance(context.Context, *SetMaintenanceRequest) (*SetMaintenanceResponse, error)
+	ListMaintenances(context.Context, *ListMaintenancesRequest) (*ListMaintenancesResponse, error)
+	WatchTopology(*WatchTopologyRequest, Seaweed_WatchTopologyServer) error
+	ListTopologyHistory(context.Context, *ListTopologyHistoryRequest) (*ListTopologyHistoryResponse, error)
+	SetCollectionQuota(context.Context, *SetCollectionQuotaRequest) (*SetCollectionQuotaResponse, error)
+	ListCollectionQuotas(context.Context, *ListCollectionQuotasRequest) (*ListCollectionQuotasResponse, error)
+	ListVolumeRepairs(context.Context, *ListVolumeRepairsRequest) (*ListVolumeRepairsResponse, error)
+	ListLifecycleActions(context.Context, *ListLifecycleActionsRequest) (*ListLifecycleActionsResponse, error)
+	GetClusterLoad(context.Context, *GetClusterLoadRequest) (*GetClusterLoadResponse, error)
+	SimulatePlacement(context.Context, *SimulatePlacementRequest) (*SimulatePlacementResponse, error)
 	mustEmbedUnimplementedSeaweedServer()
 }
 
@@ -417,6 +605,51 @@ func (UnimplementedSeaweedServer) RaftAddServer(context.Context, *RaftAddServerR
 func (UnimplementedSeaweedServer) RaftRemoveServer(context.Context, *RaftRemoveServerRequest) (*RaftRemoveServerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RaftRemoveServer not implemented")
 }
+func (UnimplementedSeaweedServer) RaftPromoteServer(context.Context, *RaftPromoteServerRequest) (*RaftPromoteServerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RaftPromoteServer not implemented")
+}
+func (UnimplementedSeaweedServer) RaftDemoteServer(context.Context, *RaftDemoteServerRequest) (*RaftDemoteServerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RaftDemoteServer not implemented")
+}
+func (UnimplementedSeaweedServer) RaftTransferLeadership(context.Context, *RaftTransferLeadershipRequest) (*RaftTransferLeadershipResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RaftTransferLeadership not implemented")
+}
+func (UnimplementedSeaweedServer) DrainVolumeServer(context.Context, *DrainVolumeServerRequest) (*DrainVolumeServerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DrainVolumeServer not implemented")
+}
+func (UnimplementedSeaweedServer) ListVolumeServerDrains(context.Context, *ListVolumeServerDrainsRequest) (*ListVolumeServerDrainsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVolumeServerDrains not implemented")
+}
+func (UnimplementedSeaweedServer) SetMaintenance(context.Context, *SetMaintenanceRequest) (*SetMaintenanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetMaintenance not implemented")
+}
+func (UnimplementedSeaweedServer) ListMaintenances(context.Context, *ListMaintenancesRequest) (*ListMaintenancesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMaintenances not implemented")
+}
+func (UnimplementedSeaweedServer) WatchTopology(*WatchTopologyRequest, Seaweed_WatchTopologyServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTopology not implemented")
+}
+func (UnimplementedSeaweedServer) ListTopologyHistory(context.Context, *ListTopologyHistoryRequest) (*ListTopologyHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTopologyHistory not implemented")
+}
+func (UnimplementedSeaweedServer) SetCollectionQuota(context.Context, *SetCollectionQuotaRequest) (*SetCollectionQuotaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetCollectionQuota not implemented")
+}
+func (UnimplementedSeaweedServer) ListCollectionQuotas(context.Context, *ListCollectionQuotasRequest) (*ListCollectionQuotasResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCollectionQuotas not implemented")
+}
+func (UnimplementedSeaweedServer) ListVolumeRepairs(context.Context, *ListVolumeRepairsRequest) (*ListVolumeRepairsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVolumeRepairs not implemented")
+}
+func (UnimplementedSeaweedServer) ListLifecycleActions(context.Context, *ListLifecycleActionsRequest) (*ListLifecycleActionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLifecycleActions not implemented")
+}
+func (UnimplementedSeaweedServer) GetClusterLoad(context.Context, *GetClusterLoadRequest) (*GetClusterLoadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetClusterLoad not implemented")
+}
+func (UnimplementedSeaweedServer) SimulatePlacement(context.Context, *SimulatePlacementRequest) (*SimulatePlacementResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SimulatePlacement not implemented")
+}
 func (UnimplementedSeaweedServer) mustEmbedUnimplementedSeaweedServer() {}
 
 // UnsafeSeaweedServer may be embedded to opt out of forward compatibility for this service.
@@ -850,6 +1083,279 @@ func _Seaweed_RaftRemoveServer_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Seaweed_RaftPromoteServer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RaftPromoteServerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).RaftPromoteServer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/RaftPromoteServer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).RaftPromoteServer(ctx, req.(*RaftPromoteServerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_RaftDemoteServer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RaftDemoteServerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).RaftDemoteServer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/RaftDemoteServer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).RaftDemoteServer(ctx, req.(*RaftDemoteServerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_RaftTransferLeadership_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RaftTransferLeadershipRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).RaftTransferLeadership(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/RaftTransferLeadership",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).RaftTransferLeadership(ctx, req.(*RaftTransferLeadershipRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_DrainVolumeServer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DrainVolumeServerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).DrainVolumeServer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/DrainVolumeServer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).DrainVolumeServer(ctx, req.(*DrainVolumeServerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_ListVolumeServerDrains_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVolumeServerDrainsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).ListVolumeServerDrains(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/ListVolumeServerDrains",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).ListVolumeServerDrains(ctx, req.(*ListVolumeServerDrainsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_SetMaintenance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetMaintenanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).SetMaintenance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/SetMaintenance",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).SetMaintenance(ctx, req.(*SetMaintenanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_ListMaintenances_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMaintenancesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).ListMaintenances(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/ListMaintenances",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).ListMaintenances(ctx, req.(*ListMaintenancesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_WatchTopology_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTopologyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SeaweedServer).WatchTopology(m, &seaweedWatchTopologyServer{stream})
+}
+
+type Seaweed_WatchTopologyServer interface {
+	Send(*TopologyEvent) error
+	grpc.ServerStream
+}
+
+type seaweedWatchTopologyServer struct {
+	grpc.ServerStream
+}
+
+func (x *seaweedWatchTopologyServer) Send(m *TopologyEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Seaweed_ListTopologyHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTopologyHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).ListTopologyHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/ListTopologyHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).ListTopologyHistory(ctx, req.(*ListTopologyHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_SetCollectionQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetCollectionQuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).SetCollectionQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/SetCollectionQuota",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).SetCollectionQuota(ctx, req.(*SetCollectionQuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_ListCollectionQuotas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCollectionQuotasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).ListCollectionQuotas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/ListCollectionQuotas",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).ListCollectionQuotas(ctx, req.(*ListCollectionQuotasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_ListVolumeRepairs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVolumeRepairsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).ListVolumeRepairs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/ListVolumeRepairs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).ListVolumeRepairs(ctx, req.(*ListVolumeRepairsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_ListLifecycleActions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLifecycleActionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).ListLifecycleActions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/ListLifecycleActions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).ListLifecycleActions(ctx, req.(*ListLifecycleActionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_GetClusterLoad_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetClusterLoadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).GetClusterLoad(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/GetClusterLoad",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).GetClusterLoad(ctx, req.(*GetClusterLoadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Seaweed_SimulatePlacement_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SimulatePlacementRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).SimulatePlacement(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/master_pb.Seaweed/SimulatePlacement",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).SimulatePlacement(ctx, req.(*SimulatePlacementRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Seaweed_ServiceDesc is the grpc.ServiceDesc for Seaweed service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -933,6 +1439,62 @@ var Seaweed_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RaftRemoveServer",
 			Handler:    _Seaweed_RaftRemoveServer_Handler,
 		},
+		{
+			MethodName: "RaftPromoteServer",
+			Handler:    _Seaweed_RaftPromoteServer_Handler,
+		},
+		{
+			MethodName: "RaftDemoteServer",
+			Handler:    _Seaweed_RaftDemoteServer_Handler,
+		},
+		{
+			MethodName: "RaftTransferLeadership",
+			Handler:    _Seaweed_RaftTransferLeadership_Handler,
+		},
+		{
+			MethodName: "DrainVolumeServer",
+			Handler:    _Seaweed_DrainVolumeServer_Handler,
+		},
+		{
+			MethodName: "ListVolumeServerDrains",
+			Handler:    _Seaweed_ListVolumeServerDrains_Handler,
+		},
+		{
+			MethodName: "SetMaintenance",
+			Handler:    _Seaweed_SetMaintenance_Handler,
+		},
+		{
+			MethodName: "ListMaintenances",
+			Handler:    _Seaweed_ListMaintenances_Handler,
+		},
+		{
+			MethodName: "ListTopologyHistory",
+			Handler:    _Seaweed_ListTopologyHistory_Handler,
+		},
+		{
+			MethodName: "SetCollectionQuota",
+			Handler:    _Seaweed_SetCollectionQuota_Handler,
+		},
+		{
+			MethodName: "ListCollectionQuotas",
+			Handler:    _Seaweed_ListCollectionQuotas_Handler,
+		},
+		{
+			MethodName: "ListVolumeRepairs",
+			Handler:    _Seaweed_ListVolumeRepairs_Handler,
+		},
+		{
+			MethodName: "ListLifecycleActions",
+			Handler:    _Seaweed_ListLifecycleActions_Handler,
+		},
+		{
+			MethodName: "GetClusterLoad",
+			Handler:    _Seaweed_GetClusterLoad_Handler,
+		},
+		{
+			MethodName: "SimulatePlacement",
+			Handler:    _Seaweed_SimulatePlacement_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -953,6 +1515,11 @@ var Seaweed_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "WatchTopology",
+			Handler:       _Seaweed_WatchTopology_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "master.proto",
 }
@@ -39,14 +39,21 @@ type VolumeServerClient interface {
 	VolumeMarkWritable(ctx context.Context, in *VolumeMarkWritableRequest, opts ...grpc.CallOption) (*VolumeMarkWritableResponse, error)
 	VolumeConfigure(ctx context.Context, in *VolumeConfigureRequest, opts ...grpc.CallOption) (*VolumeConfigureResponse, error)
 	VolumeStatus(ctx context.Context, in *VolumeStatusRequest, opts ...grpc.CallOption) (*VolumeStatusResponse, error)
+	VolumeEncryptionRotate(ctx context.Context, in *VolumeEncryptionRotateRequest, opts ...grpc.CallOption) (*VolumeEncryptionRotateResponse, error)
 	// copy the .idx .dat files, and mount this volume
 	VolumeCopy(ctx context.Context, in *VolumeCopyRequest, opts ...grpc.CallOption) (VolumeServer_VolumeCopyClient, error)
 	ReadVolumeFileStatus(ctx context.Context, in *ReadVolumeFileStatusRequest, opts ...grpc.CallOption) (*ReadVolumeFileStatusResponse, error)
 	CopyFile(ctx context.Context, in *CopyFileRequest, opts ...grpc.CallOption) (VolumeServer_CopyFileClient, error)
+	// snapshot the .dat .idx files in place, and clone a snapshot to a new volume
+	VolumeSnapshot(ctx context.Context, in *VolumeSnapshotRequest, opts ...grpc.CallOption) (*VolumeSnapshotResponse, error)
+	VolumeSnapshotDelete(ctx context.Context, in *VolumeSnapshotDeleteRequest, opts ...grpc.CallOption) (*VolumeSnapshotDeleteResponse, error)
+	VolumeClone(ctx context.Context, in *VolumeCloneRequest, opts ...grpc.CallOption) (*VolumeCloneResponse, error)
 	ReadNeedleBlob(ctx context.Context, in *ReadNeedleBlobRequest, opts ...grpc.CallOption) (*ReadNeedleBlobResponse, error)
 	ReadNeedleMeta(ctx context.Context, in *ReadNeedleMetaRequest, opts ...grpc.CallOption) (*ReadNeedleMetaResponse, error)
 	WriteNeedleBlob(ctx context.Context, in *WriteNeedleBlobRequest, opts ...grpc.CallOption) (*WriteNeedleBlobResponse, error)
 	ReadAllNeedles(ctx context.Context, in *ReadAllNeedlesRequest, opts ...grpc.CallOption) (VolumeServer_ReadAllNeedlesClient, error)
+	VolumeManifest(ctx context.Context, in *VolumeManifestRequest, opts ...grpc.CallOption) (VolumeServer_VolumeManifestClient, error)
+	VolumeFsckDeep(ctx context.Context, in *VolumeFsckDeepRequest, opts ...grpc.CallOption) (VolumeServer_VolumeFsckDeepClient, error)
 	VolumeTailSender(ctx context.Context, in *VolumeTailSenderRequest, opts ...grpc.CallOption) (VolumeServer_VolumeTailSenderClient, error)
 	VolumeTailReceiver(ctx context.Context, in *VolumeTailReceiverRequest, opts ...grpc.CallOption) (*VolumeTailReceiverResponse, error)
 	// erasure coding
@@ -64,6 +71,7 @@ type VolumeServerClient interface {
 	VolumeTierMoveDatFromRemote(ctx context.Context, in *VolumeTierMoveDatFromRemoteRequest, opts ...grpc.CallOption) (VolumeServer_VolumeTierMoveDatFromRemoteClient, error)
 	VolumeServerStatus(ctx context.Context, in *VolumeServerStatusRequest, opts ...grpc.CallOption) (*VolumeServerStatusResponse, error)
 	VolumeServerLeave(ctx context.Context, in *VolumeServerLeaveRequest, opts ...grpc.CallOption) (*VolumeServerLeaveResponse, error)
+	VolumeServerDrain(ctx context.Context, in *VolumeServerDrainRequest, opts ...grpc.CallOption) (*VolumeServerDrainResponse, error)
 	// remote storage
 	FetchAndWriteNeedle(ctx context.Context, in *FetchAndWriteNeedleRequest, opts ...grpc.CallOption) (*FetchAndWriteNeedleResponse, error)
 	// <experimental> query
@@ -270,6 +278,15 @@ func (c *volumeServerClient) VolumeStatus(ctx context.Context, in *VolumeStatusR
 	return out, nil
 }
 
+func (c *volumeServerClient) VolumeEncryptionRotate(ctx context.Context, in *VolumeEncryptionRotateRequest, opts ...grpc.CallOption) (*VolumeEncryptionRotateResponse, error) {
+	out := new(VolumeEncryptionRotateResponse)
+	err := c.cc.Invoke(ctx, "/volume_server_pb.VolumeServer/VolumeEncryptionRotate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *volumeServerClient) VolumeCopy(ctx context.Context, in *VolumeCopyRequest, opts ...grpc.CallOption) (VolumeServer_VolumeCopyClient, error) {
 	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[2], "/volume_server_pb.VolumeServer/VolumeCopy", opts...)
 	if err != nil {
@@ -343,6 +360,33 @@ func (x *volumeServerCopyFileClient) Recv() (*CopyFileResponse, error) {
 	return m, nil
 }
 
+func (c *volumeServerClient) VolumeSnapshot(ctx context.Context, in *VolumeSnapshotRequest, opts ...grpc.CallOption) (*VolumeSnapshotResponse, error) {
+	out := new(VolumeSnapshotResponse)
+	err := c.cc.Invoke(ctx, "/volume_server_pb.VolumeServer/VolumeSnapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *volumeServerClient) VolumeSnapshotDelete(ctx context.Context, in *VolumeSnapshotDeleteRequest, opts ...grpc.CallOption) (*VolumeSnapshotDeleteResponse, error) {
+	out := new(VolumeSnapshotDeleteResponse)
+	err := c.cc.Invoke(ctx, "/volume_server_pb.VolumeServer/VolumeSnapshotDelete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *volumeServerClient) VolumeClone(ctx context.Context, in *VolumeCloneRequest, opts ...grpc.CallOption) (*VolumeCloneResponse, error) {
+	out := new(VolumeCloneResponse)
+	err := c.cc.Invoke(ctx, "/volume_server_pb.VolumeServer/VolumeClone", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *volumeServerClient) ReadNeedleBlob(ctx context.Context, in *ReadNeedleBlobRequest, opts ...grpc.CallOption) (*ReadNeedleBlobResponse, error) {
 	out := new(ReadNeedleBlobResponse)
 	err := c.cc.Invoke(ctx, "/volume_server_pb.VolumeServer/ReadNeedleBlob", in, out, opts...)
@@ -402,8 +446,72 @@ func (x *volumeServerReadAllNeedlesClient) Recv() (*ReadAllNeedlesResponse, erro
 	return m, nil
 }
 
+func (c *volumeServerClient) VolumeManifest(ctx context.Context, in *VolumeManifestRequest, opts ...grpc.CallOption) (VolumeServer_VolumeManifestClient, error) {
+	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[5], "/volume_server_pb.VolumeServer/VolumeManifest", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &volumeServerVolumeManifestClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type VolumeServer_VolumeManifestClient interface {
+	Recv() (*VolumeManifestResponse, error)
+	grpc.ClientStream
+}
+
+type volumeServerVolumeManifestClient struct {
+	grpc.ClientStream
+}
+
+func (x *volumeServerVolumeManifestClient) Recv() (*VolumeManifestResponse, error) {
+	m := new(VolumeManifestResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *volumeServerClient) VolumeFsckDeep(ctx context.Context, in *VolumeFsckDeepRequest, opts ...grpc.CallOption) (VolumeServer_VolumeFsckDeepClient, error) {
+	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[6], "/volume_server_pb.VolumeServer/VolumeFsckDeep", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &volumeServerVolumeFsckDeepClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type VolumeServer_VolumeFsckDeepClient interface {
+	Recv() (*VolumeFsckDeepResponse, error)
+	grpc.ClientStream
+}
+
+type volumeServerVolumeFsckDeepClient struct {
+	grpc.ClientStream
+}
+
+func (x *volumeServerVolumeFsckDeepClient) Recv() (*VolumeFsckDeepResponse, error) {
+	m := new(VolumeFsckDeepResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *volumeServerClient) VolumeTailSender(ctx context.Context, in *VolumeTailSenderRequest, opts ...grpc.CallOption) (VolumeServer_VolumeTailSenderClient, error) {
-	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[5], "/volume_server_pb.VolumeServer/VolumeTailSender", opts...)
+	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[7], "/volume_server_pb.VolumeServer/VolumeTailSender", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -498,7 +606,7 @@ func (c *volumeServerClient) VolumeEcShardsUnmount(ctx context.Context, in *Volu
 }
 
 func (c *volumeServerClient) VolumeEcShardRead(ctx context.Context, in *VolumeEcShardReadRequest, opts ...grpc.CallOption) (VolumeServer_VolumeEcShardReadClient, error) {
-	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[6], "/volume_server_pb.VolumeServer/VolumeEcShardRead", opts...)
+	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[8], "/volume_server_pb.VolumeServer/VolumeEcShardRead", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -548,7 +656,7 @@ func (c *volumeServerClient) VolumeEcShardsToVolume(ctx context.Context, in *Vol
 }
 
 func (c *volumeServerClient) VolumeTierMoveDatToRemote(ctx context.Context, in *VolumeTierMoveDatToRemoteRequest, opts ...grpc.CallOption) (VolumeServer_VolumeTierMoveDatToRemoteClient, error) {
-	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[7], "/volume_server_pb.VolumeServer/VolumeTierMoveDatToRemote", opts...)
+	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[9], "/volume_server_pb.VolumeServer/VolumeTierMoveDatToRemote", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -580,7 +688,7 @@ func (x *volumeServerVolumeTierMoveDatToRemoteClient) Recv() (*VolumeTierMoveDat
 }
 
 func (c *volumeServerClient) VolumeTierMoveDatFromRemote(ctx context.Context, in *VolumeTierMoveDatFromRemoteRequest, opts ...grpc.CallOption) (VolumeServer_VolumeTierMoveDatFromRemoteClient, error) {
-	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[8], "/volume_server_pb.VolumeServer/VolumeTierMoveDatFromRemote", opts...)
+	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[10], "/volume_server_pb.VolumeServer/VolumeTierMoveDatFromRemote", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -629,6 +737,15 @@ func (c *volumeServerClient) VolumeServerLeave(ctx context.Context, in *VolumeSe
 	return out, nil
 }
 
+func (c *volumeServerClient) VolumeServerDrain(ctx context.Context, in *VolumeServerDrainRequest, opts ...grpc.CallOption) (*VolumeServerDrainResponse, error) {
+	out := new(VolumeServerDrainResponse)
+	err := c.cc.Invoke(ctx, "/volume_server_pb.VolumeServer/VolumeServerDrain", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *volumeServerClient) FetchAndWriteNeedle(ctx context.Context, in *FetchAndWriteNeedleRequest, opts ...grpc.CallOption) (*FetchAndWriteNeedleResponse, error) {
 	out := new(FetchAndWriteNeedleResponse)
 	err := c.cc.Invoke(ctx, "/volume_server_pb.VolumeServer/FetchAndWriteNeedle", in, out, opts...)
@@ -639,7 +756,7 @@ func (c *volumeServerClient) FetchAndWriteNeedle(ctx context.Context, in *FetchA
 }
 
 func (c *volumeServerClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (VolumeServer_QueryClient, error) {
-	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[9], "/volume_server_pb.VolumeServer/Query", opts...)
+	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[11], "/volume_server_pb.VolumeServer/Query", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -709,14 +826,21 @@ type VolumeServerServer interface {
 	VolumeMarkWritable(context.Context, *VolumeMarkWritableRequest) (*VolumeMarkWritableResponse, error)
 	VolumeConfigure(context.Context, *VolumeConfigureRequest) (*VolumeConfigureResponse, error)
 	VolumeStatus(context.Context, *VolumeStatusRequest) (*VolumeStatusResponse, error)
+	VolumeEncryptionRotate(context.Context, *VolumeEncryptionRotateRequest) (*VolumeEncryptionRotateResponse, error)
 	// copy the .idx .dat files, and mount this volume
 	VolumeCopy(*VolumeCopyRequest, VolumeServer_VolumeCopyServer) error
 	ReadVolumeFileStatus(context.Context, *ReadVolumeFileStatusRequest) (*ReadVolumeFileStatusResponse, error)
 	CopyFile(*CopyFileRequest, VolumeServer_CopyFileServer) error
+	// snapshot the .dat .idx files in place, and clone a snapshot to a new volume
+	VolumeSnapshot(context.Context, *VolumeSnapshotRequest) (*VolumeSnapshotResponse, error)
+	VolumeSnapshotDelete(context.Context, *VolumeSnapshotDeleteRequest) (*VolumeSnapshotDeleteResponse, error)
+	VolumeClone(context.Context, *VolumeCloneRequest) (*VolumeCloneResponse, error)
 	ReadNeedleBlob(context.Context, *ReadNeedleBlobRequest) (*ReadNeedleBlobResponse, error)
 	ReadNeedleMeta(context.Context, *ReadNeedleMetaRequest) (*ReadNeedleMetaResponse, error)
 	WriteNeedleBlob(context.Context, *WriteNeedleBlobRequest) (*WriteNeedleBlobResponse, error)
 	ReadAllNeedles(*ReadAllNeedlesRequest, VolumeServer_ReadAllNeedlesServer) error
+	VolumeManifest(*VolumeManifestRequest, VolumeServer_VolumeManifestServer) error
+	VolumeFsckDeep(*VolumeFsckDeepRequest, VolumeServer_VolumeFsckDeepServer) error
 	VolumeTailSender(*VolumeTailSenderRequest, VolumeServer_VolumeTailSenderServer) error
 	VolumeTailReceiver(context.Context, *VolumeTailReceiverRequest) (*VolumeTailReceiverResponse, error)
 	// erasure coding
@@ -734,6 +858,7 @@ type VolumeServerServer interface {
 	VolumeTierMoveDatFromRemote(*VolumeTierMoveDatFromRemoteRequest, VolumeServer_VolumeTierMoveDatFromRemoteServer) error
 	VolumeServerStatus(context.Context, *VolumeServerStatusRequest) (*VolumeServerStatusResponse, error)
 	VolumeServerLeave(context.Context, *VolumeServerLeaveRequest) (*VolumeServerLeaveResponse, error)
+	VolumeServerDrain(context.Context, *VolumeServerDrainRequest) (*VolumeServerDrainResponse, error)
 	// remote storage
 	FetchAndWriteNeedle(context.Context, *FetchAndWriteNeedleRequest) (*FetchAndWriteNeedleResponse, error)
 	// <experimental> query
@@ -795,6 +920,9 @@ func (UnimplementedVolumeServerServer) VolumeConfigure(context.Context, *VolumeC
 func (UnimplementedVolumeServerServer) VolumeStatus(context.Context, *VolumeStatusRequest) (*VolumeStatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method VolumeStatus not implemented")
 }
+func (UnimplementedVolumeServerServer) VolumeEncryptionRotate(context.Context, *VolumeEncryptionRotateRequest) (*VolumeEncryptionRotateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeEncryptionRotate not implemented")
+}
 func (UnimplementedVolumeServerServer) VolumeCopy(*VolumeCopyRequest, VolumeServer_VolumeCopyServer) error {
 	return status.Errorf(codes.Unimplemented, "method VolumeCopy not implemented")
 }
@@ -804,6 +932,15 @@ func (UnimplementedVolumeServerServer) ReadVolumeFileStatus(context.Context, *Re
 func (UnimplementedVolumeServerServer) CopyFile(*CopyFileRequest, VolumeServer_CopyFileServer) error {
 	return status.Errorf(codes.Unimplemented, "method CopyFile not implemented")
 }
+func (UnimplementedVolumeServerServer) VolumeSnapshot(context.Context, *VolumeSnapshotRequest) (*VolumeSnapshotResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeSnapshot not implemented")
+}
+func (UnimplementedVolumeServerServer) VolumeSnapshotDelete(context.Context, *VolumeSnapshotDeleteRequest) (*VolumeSnapshotDeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeSnapshotDelete not implemented")
+}
+func (UnimplementedVolumeServerServer) VolumeClone(context.Context, *VolumeCloneRequest) (*VolumeCloneResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeClone not implemented")
+}
 func (UnimplementedVolumeServerServer) ReadNeedleBlob(context.Context, *ReadNeedleBlobRequest) (*ReadNeedleBlobResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ReadNeedleBlob not implemented")
 }
@@ -816,6 +953,12 @@ func (UnimplementedVolumeServerServer) WriteNeedleBlob(context.Context, *WriteNe
 func (UnimplementedVolumeServerServer) ReadAllNeedles(*ReadAllNeedlesRequest, VolumeServer_ReadAllNeedlesServer) error {
 	return status.Errorf(codes.Unimplemented, "method ReadAllNeedles not implemented")
 }
+func (UnimplementedVolumeServerServer) VolumeManifest(*VolumeManifestRequest, VolumeServer_VolumeManifestServer) error {
+	return status.Errorf(codes.Unimplemented, "method VolumeManifest not implemented")
+}
+func (UnimplementedVolumeServerServer) VolumeFsckDeep(*VolumeFsckDeepRequest, VolumeServer_VolumeFsckDeepServer) error {
+	return status.Errorf(codes.Unimplemented, "method VolumeFsckDeep not implemented")
+}
 func (UnimplementedVolumeServerServer) VolumeTailSender(*VolumeTailSenderRequest, VolumeServer_VolumeTailSenderServer) error {
 	return status.Errorf(codes.Unimplemented, "method VolumeTailSender not implemented")
 }
@@ -861,6 +1004,9 @@ func (UnimplementedVolumeServerServer) VolumeServerStatus(context.Context, *Volu
 func (UnimplementedVolumeServerServer) VolumeServerLeave(context.Context, *VolumeServerLeaveRequest) (*VolumeServerLeaveResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method VolumeServerLeave not implemented")
 }
+func (UnimplementedVolumeServerServer) VolumeServerDrain(context.Context, *VolumeServerDrainRequest) (*VolumeServerDrainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeServerDrain not implemented")
+}
 func (UnimplementedVolumeServerServer) FetchAndWriteNeedle(context.Context, *FetchAndWriteNeedleRequest) (*FetchAndWriteNeedleResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FetchAndWriteNeedle not implemented")
 }
@@ -1180,6 +1326,24 @@ func _VolumeServer_VolumeStatus_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VolumeServer_VolumeEncryptionRotate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeEncryptionRotateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VolumeServerServer).VolumeEncryptionRotate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/volume_server_pb.VolumeServer/VolumeEncryptionRotate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VolumeServerServer).VolumeEncryptionRotate(ctx, req.(*VolumeEncryptionRotateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _VolumeServer_VolumeCopy_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(VolumeCopyRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -1240,6 +1404,60 @@ func (x *volumeServerCopyFileServer) Send(m *CopyFileResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _VolumeServer_VolumeSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VolumeServerServer).VolumeSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/volume_server_pb.VolumeServer/VolumeSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VolumeServerServer).VolumeSnapshot(ctx, req.(*VolumeSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VolumeServer_VolumeSnapshotDelete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeSnapshotDeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VolumeServerServer).VolumeSnapshotDelete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/volume_server_pb.VolumeServer/VolumeSnapshotDelete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VolumeServerServer).VolumeSnapshotDelete(ctx, req.(*VolumeSnapshotDeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VolumeServer_VolumeClone_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeCloneRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VolumeServerServer).VolumeClone(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/volume_server_pb.VolumeServer/VolumeClone",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VolumeServerServer).VolumeClone(ctx, req.(*VolumeCloneRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _VolumeServer_ReadNeedleBlob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ReadNeedleBlobRequest)
 	if err := dec(in); err != nil {
@@ -1315,6 +1533,48 @@ func (x *volumeServerReadAllNeedlesServer) Send(m *ReadAllNeedlesResponse) error
 	return x.ServerStream.SendMsg(m)
 }
 
+func _VolumeServer_VolumeManifest_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(VolumeManifestRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VolumeServerServer).VolumeManifest(m, &volumeServerVolumeManifestServer{stream})
+}
+
+type VolumeServer_VolumeManifestServer interface {
+	Send(*VolumeManifestResponse) error
+	grpc.ServerStream
+}
+
+type volumeServerVolumeManifestServer struct {
+	grpc.ServerStream
+}
+
+func (x *volumeServerVolumeManifestServer) Send(m *VolumeManifestResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _VolumeServer_VolumeFsckDeep_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(VolumeFsckDeepRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VolumeServerServer).VolumeFsckDeep(m, &volumeServerVolumeFsckDeepServer{stream})
+}
+
+type VolumeServer_VolumeFsckDeepServer interface {
+	Send(*VolumeFsckDeepResponse) error
+	grpc.ServerStream
+}
+
+type volumeServerVolumeFsckDeepServer struct {
+	grpc.ServerStream
+}
+
+func (x *volumeServerVolumeFsckDeepServer) Send(m *VolumeFsckDeepResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _VolumeServer_VolumeTailSender_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(VolumeTailSenderRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -1597,6 +1857,24 @@ func _VolumeServer_VolumeServerLeave_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VolumeServer_VolumeServerDrain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeServerDrainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VolumeServerServer).VolumeServerDrain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/volume_server_pb.VolumeServer/VolumeServerDrain",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VolumeServerServer).VolumeServerDrain(ctx, req.(*VolumeServerDrainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _VolumeServer_FetchAndWriteNeedle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(FetchAndWriteNeedleRequest)
 	if err := dec(in); err != nil {
@@ -1735,10 +2013,26 @@ var VolumeServer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "VolumeStatus",
 			Handler:    _VolumeServer_VolumeStatus_Handler,
 		},
+		{
+			MethodName: "VolumeEncryptionRotate",
+			Handler:    _VolumeServer_VolumeEncryptionRotate_Handler,
+		},
 		{
 			MethodName: "ReadVolumeFileStatus",
 			Handler:    _VolumeServer_ReadVolumeFileStatus_Handler,
 		},
+		{
+			MethodName: "VolumeSnapshot",
+			Handler:    _VolumeServer_VolumeSnapshot_Handler,
+		},
+		{
+			MethodName: "VolumeSnapshotDelete",
+			Handler:    _VolumeServer_VolumeSnapshotDelete_Handler,
+		},
+		{
+			MethodName: "VolumeClone",
+			Handler:    _VolumeServer_VolumeClone_Handler,
+		},
 		{
 			MethodName: "ReadNeedleBlob",
 			Handler:    _VolumeServer_ReadNeedleBlob_Handler,
@@ -1795,6 +2089,10 @@ var VolumeServer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "VolumeServerLeave",
 			Handler:    _VolumeServer_VolumeServerLeave_Handler,
 		},
+		{
+			MethodName: "VolumeServerDrain",
+			Handler:    _VolumeServer_VolumeServerDrain_Handler,
+		},
 		{
 			MethodName: "FetchAndWriteNeedle",
 			Handler:    _VolumeServer_FetchAndWriteNeedle_Handler,
@@ -1834,6 +2132,16 @@ var VolumeServer_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _VolumeServer_ReadAllNeedles_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "VolumeManifest",
+			Handler:       _VolumeServer_VolumeManifest_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "VolumeFsckDeep",
+			Handler:       _VolumeServer_VolumeFsckDeep_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "VolumeTailSender",
 			Handler:       _VolumeServer_VolumeTailSender_Handler,
@@ -0,0 +1,133 @@
+package replication
+
+import (
+	"crypto/md5"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+// ConflictStrategy decides what happens when a replicated change meets
+// a target entry that was modified independently on the target side.
+type ConflictStrategy string
+
+const (
+	// ConflictOverwrite applies the incoming change without checking the target.
+	ConflictOverwrite ConflictStrategy = "overwrite"
+	// ConflictLastWriterWins keeps whichever version has the later mtime.
+	ConflictLastWriterWins ConflictStrategy = "lww"
+	// ConflictRename keeps the target version and writes the incoming one as a conflicted copy.
+	ConflictRename ConflictStrategy = "rename"
+	// ConflictQueue keeps the target version and records the incoming change for manual resolution.
+	ConflictQueue ConflictStrategy = "queue"
+)
+
+func ParseConflictStrategy(s string) (ConflictStrategy, error) {
+	switch strategy := ConflictStrategy(strings.ToLower(strings.TrimSpace(s))); strategy {
+	case "", ConflictOverwrite:
+		return ConflictOverwrite, nil
+	case ConflictLastWriterWins, ConflictRename, ConflictQueue:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown conflict strategy %q, expecting one of overwrite, lww, rename, queue", s)
+}
+
+// ConflictResolution is the outcome of resolving one replicated change.
+type ConflictResolution int
+
+const (
+	// ApplyIncoming replicates the change as usual.
+	ApplyIncoming ConflictResolution = iota
+	// KeepExisting drops the incoming change.
+	KeepExisting
+	// WriteConflictedCopy writes the incoming version next to the existing one.
+	WriteConflictedCopy
+	// QueueForManualResolution drops the incoming change after recording it.
+	QueueForManualResolution
+)
+
+func (r ConflictResolution) String() string {
+	switch r {
+	case KeepExisting:
+		return "keep_existing"
+	case WriteConflictedCopy:
+		return "conflicted_copy"
+	case QueueForManualResolution:
+		return "queued"
+	}
+	return "apply_incoming"
+}
+
+// IsConflict reports whether the existing target entry was changed independently
+// of the incoming change. base is the source version the change was made against,
+// nil for creations. incoming is the new source version, nil for deletions.
+//
+// Entries are compared by content signature, i.e. etag, size and inline content,
+// since attributes like mtime are copied over by replication.
+func IsConflict(existing, base, incoming *filer_pb.Entry) bool {
+	if existing == nil {
+		return false
+	}
+	if existing.IsDirectory {
+		// directories merge naturally by their children
+		return false
+	}
+	existingSignature := ContentSignature(existing)
+	if incoming != nil && existingSignature == ContentSignature(incoming) {
+		return false
+	}
+	if base != nil && existingSignature == ContentSignature(base) {
+		return false
+	}
+	return true
+}
+
+// ContentSignature summarizes the file content of an entry.
+func ContentSignature(entry *filer_pb.Entry) string {
+	if entry == nil {
+		return ""
+	}
+	if len(entry.Content) > 0 {
+		return fmt.Sprintf("content:%x:%d", md5.Sum(entry.Content), len(entry.Content))
+	}
+	return fmt.Sprintf("%s:%d", filer.ETag(entry), filer.FileSize(entry))
+}
+
+// ResolveConflict applies the strategy to a detected conflict.
+// eventTsNs is used as the modification time of deletions.
+func ResolveConflict(strategy ConflictStrategy, existing, incoming *filer_pb.Entry, eventTsNs int64) ConflictResolution {
+	switch strategy {
+	case ConflictLastWriterWins:
+		incomingMtime := eventTsNs / 1e9
+		if incoming != nil && incoming.Attributes != nil {
+			incomingMtime = incoming.Attributes.Mtime
+		}
+		var existingMtime int64
+		if existing.Attributes != nil {
+			existingMtime = existing.Attributes.Mtime
+		}
+		if incomingMtime >= existingMtime {
+			return ApplyIncoming
+		}
+		return KeepExisting
+	case ConflictRename:
+		if incoming == nil {
+			// the modified target version survives a concurrent deletion
+			return KeepExisting
+		}
+		return WriteConflictedCopy
+	case ConflictQueue:
+		return QueueForManualResolution
+	}
+	return ApplyIncoming
+}
+
+// ConflictedCopyName names the copy of an incoming version, keeping the file extension,
+// e.g. "report.conflict-1234-1697290000.txt".
+func ConflictedCopyName(name string, sourceSignature int32, eventTsNs int64) string {
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s.conflict-%d-%d%s", strings.TrimSuffix(name, ext), sourceSignature, eventTsNs/1e9, ext)
+}
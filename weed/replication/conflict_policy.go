@@ -0,0 +1,85 @@
+package replication
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+type conflictPolicyRule struct {
+	pathPrefix string
+	strategy   ConflictStrategy
+}
+
+// ConflictPolicy keeps per-path conflict strategies. Paths are relative to the
+// synchronized directories, same as SyncTopology. The rule with the longest matching
+// path prefix wins, and paths without any rule use the default strategy.
+type ConflictPolicy struct {
+	defaultStrategy ConflictStrategy
+	rules           []conflictPolicyRule
+}
+
+// ParseConflictPolicy parses comma separated "<path>:<strategy>" rules,
+// e.g. "/shared:rename,/logs:lww,/finance:queue".
+func ParseConflictPolicy(defaultStrategy ConflictStrategy, spec string) (*ConflictPolicy, error) {
+	p := &ConflictPolicy{
+		defaultStrategy: defaultStrategy,
+	}
+	for _, item := range util.StringSplit(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		sepIndex := strings.LastIndex(item, ":")
+		if sepIndex <= 0 {
+			return nil, fmt.Errorf("invalid conflict rule %q, expecting <path>:<strategy>", item)
+		}
+		strategy, err := ParseConflictStrategy(item[sepIndex+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid conflict rule %q: %v", item, err)
+		}
+		pathPrefix := strings.TrimSuffix(item[:sepIndex], "/")
+		if pathPrefix == "" {
+			pathPrefix = "/"
+		}
+		p.rules = append(p.rules, conflictPolicyRule{
+			pathPrefix: pathPrefix,
+			strategy:   strategy,
+		})
+	}
+	return p, nil
+}
+
+// StrategyOf returns the conflict strategy configured for the path.
+func (p *ConflictPolicy) StrategyOf(path string) ConflictStrategy {
+	if p == nil {
+		return ConflictOverwrite
+	}
+	strategy, matchedLength := p.defaultStrategy, -1
+	for _, rule := range p.rules {
+		if path != rule.pathPrefix && !util.FullPath(path).IsUnder(util.FullPath(rule.pathPrefix)) {
+			continue
+		}
+		if len(rule.pathPrefix) > matchedLength {
+			strategy, matchedLength = rule.strategy, len(rule.pathPrefix)
+		}
+	}
+	return strategy
+}
+
+// IsOverwriteOnly tells whether no path needs any conflict checking.
+func (p *ConflictPolicy) IsOverwriteOnly() bool {
+	if p == nil {
+		return true
+	}
+	if p.defaultStrategy != ConflictOverwrite {
+		return false
+	}
+	for _, rule := range p.rules {
+		if rule.strategy != ConflictOverwrite {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package replication
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// ConflictQueueDir keeps one record per conflict on the target filer,
+// for manual inspection, e.g. with "fs.ls" and "fs.cat" in weed shell.
+const ConflictQueueDir = filer.DirectoryEtcSeaweedFS + "/sync/conflicts"
+
+// ConflictRecord describes one conflict met by filer.sync.
+type ConflictRecord struct {
+	Path          string `json:"path"`
+	SourceFiler   string `json:"sourceFiler"`
+	TargetFiler   string `json:"targetFiler"`
+	EventTsNs     int64  `json:"eventTsNs"`
+	Strategy      string `json:"strategy"`
+	Resolution    string `json:"resolution"`
+	ExistingMtime int64  `json:"existingMtime"`
+	IncomingMtime int64  `json:"incomingMtime"`
+	ExistingETag  string `json:"existingETag"`
+	IncomingETag  string `json:"incomingETag,omitempty"`
+	// Event is the serialized filer_pb.SubscribeMetadataResponse, which can be replayed manually.
+	Event []byte `json:"event,omitempty"`
+}
+
+func NewConflictRecord(path, sourceFiler, targetFiler string, strategy ConflictStrategy, resolution ConflictResolution, existing *filer_pb.Entry, resp *filer_pb.SubscribeMetadataResponse) *ConflictRecord {
+	record := &ConflictRecord{
+		Path:        path,
+		SourceFiler: sourceFiler,
+		TargetFiler: targetFiler,
+		EventTsNs:   resp.TsNs,
+		Strategy:    string(strategy),
+		Resolution:  resolution.String(),
+	}
+	if existing != nil {
+		record.ExistingETag = filer.ETag(existing)
+		if existing.Attributes != nil {
+			record.ExistingMtime = existing.Attributes.Mtime
+		}
+	}
+	if incoming := resp.EventNotification.NewEntry; incoming != nil {
+		record.IncomingETag = filer.ETag(incoming)
+		if incoming.Attributes != nil {
+			record.IncomingMtime = incoming.Attributes.Mtime
+		}
+	}
+	if resolution == QueueForManualResolution {
+		record.Event, _ = proto.Marshal(resp)
+	}
+	return record
+}
+
+// SaveConflictRecord stores the record under ConflictQueueDir on the target filer.
+// The signatures prevent the record itself from being synchronized back.
+func SaveConflictRecord(client filer_pb.SeaweedFilerClient, record *ConflictRecord, signatures []int32) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal conflict record %s: %v", record.Path, err)
+	}
+	now := time.Now()
+	return filer_pb.CreateEntry(client, &filer_pb.CreateEntryRequest{
+		Directory: ConflictQueueDir,
+		Entry: &filer_pb.Entry{
+			Name: fmt.Sprintf("%d-%s.json", record.EventTsNs, util.Md5String([]byte(record.Path))),
+			Attributes: &filer_pb.FuseAttributes{
+				Mtime:    now.Unix(),
+				Crtime:   now.Unix(),
+				FileMode: uint32(0644),
+				FileSize: uint64(len(data)),
+				Mime:     "application/json",
+			},
+			Content: data,
+		},
+		Signatures: signatures,
+	})
+}
+
+// ReadConflictRecords reads the records under ConflictQueueDir, the earliest conflicts first.
+func ReadConflictRecords(filerClient filer_pb.FilerClient, fn func(name string, record *ConflictRecord) error) error {
+	return filer_pb.ReadDirAllEntries(filerClient, util.FullPath(ConflictQueueDir), "", func(entry *filer_pb.Entry, isLast bool) error {
+		if entry.IsDirectory {
+			return nil
+		}
+		record := &ConflictRecord{}
+		if err := json.Unmarshal(entry.Content, record); err != nil {
+			return fmt.Errorf("unmarshal conflict record %s: %v", entry.Name, err)
+		}
+		return fn(entry.Name, record)
+	})
+}
+
+// DeleteConflictRecord removes a record, e.g. after the conflict is resolved manually.
+func DeleteConflictRecord(client filer_pb.SeaweedFilerClient, name string) error {
+	return filer_pb.DoRemove(client, ConflictQueueDir, name, true, false, false, false, nil)
+}
@@ -0,0 +1,135 @@
+package replication
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func newTestEntry(content string, mtime int64) *filer_pb.Entry {
+	return &filer_pb.Entry{
+		Name:       "report.txt",
+		Attributes: &filer_pb.FuseAttributes{Mtime: mtime, FileSize: uint64(len(content))},
+		Content:    []byte(content),
+	}
+}
+
+func TestIsConflict(t *testing.T) {
+	base := newTestEntry("v1", 100)
+	incoming := newTestEntry("v2", 200)
+
+	if IsConflict(nil, base, incoming) {
+		t.Errorf("missing target entry is not a conflict")
+	}
+	if IsConflict(newTestEntry("v1", 150), base, incoming) {
+		t.Errorf("unchanged target entry is not a conflict")
+	}
+	if IsConflict(newTestEntry("v2", 200), base, incoming) {
+		t.Errorf("already replicated entry is not a conflict")
+	}
+	if !IsConflict(newTestEntry("v3", 300), base, incoming) {
+		t.Errorf("independently changed target entry is a conflict")
+	}
+	if !IsConflict(newTestEntry("v3", 300), nil, incoming) {
+		t.Errorf("concurrently created entries are a conflict")
+	}
+	if !IsConflict(newTestEntry("v3", 300), base, nil) {
+		t.Errorf("deleting an independently changed entry is a conflict")
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	existing := newTestEntry("v3", 300)
+
+	tests := []struct {
+		strategy ConflictStrategy
+		incoming *filer_pb.Entry
+		expected ConflictResolution
+	}{
+		{ConflictOverwrite, newTestEntry("v2", 200), ApplyIncoming},
+		{ConflictLastWriterWins, newTestEntry("v2", 200), KeepExisting},
+		{ConflictLastWriterWins, newTestEntry("v4", 400), ApplyIncoming},
+		{ConflictRename, newTestEntry("v2", 200), WriteConflictedCopy},
+		{ConflictRename, nil, KeepExisting},
+		{ConflictQueue, newTestEntry("v2", 200), QueueForManualResolution},
+	}
+	for _, test := range tests {
+		if resolution := ResolveConflict(test.strategy, existing, test.incoming, 250*1e9); resolution != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.strategy, test.expected, resolution)
+		}
+	}
+
+	// deletion time is the event time
+	if resolution := ResolveConflict(ConflictLastWriterWins, existing, nil, 350*1e9); resolution != ApplyIncoming {
+		t.Errorf("later deletion should win, got %s", resolution)
+	}
+
+	if name := ConflictedCopyName("report.txt", 7, 1697290000*1e9); name != "report.conflict-7-1697290000.txt" {
+		t.Errorf("unexpected conflicted copy name %s", name)
+	}
+}
+
+func TestSyncTopology(t *testing.T) {
+	topology, err := ParseSyncTopology("/shared:both,/ingest:a2b,/ingest/feedback:b2a,/scratch/:none")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		fromA    bool
+		expected bool
+	}{
+		{"/other/file", true, true},
+		{"/other/file", false, true},
+		{"/ingest/file", true, true},
+		{"/ingest/file", false, false},
+		{"/ingest/feedback/file", true, false},
+		{"/ingest/feedback/file", false, true},
+		{"/ingestion/file", false, true},
+		{"/scratch", true, false},
+		{"/scratch/file", false, false},
+	}
+	for _, test := range tests {
+		if allowed := topology.Allows(test.path, test.fromA); allowed != test.expected {
+			t.Errorf("%s fromA:%v expected %v", test.path, test.fromA, test.expected)
+		}
+	}
+
+	if _, err := ParseSyncTopology("/shared:sideways"); err == nil {
+		t.Errorf("expected error for unknown direction")
+	}
+}
+
+func TestConflictPolicy(t *testing.T) {
+	policy, err := ParseConflictPolicy(ConflictLastWriterWins, "/shared:rename,/shared/finance:queue,/scratch/:overwrite")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected ConflictStrategy
+	}{
+		{"/other/file", ConflictLastWriterWins},
+		{"/shared/file", ConflictRename},
+		{"/shared/finance/q3.xls", ConflictQueue},
+		{"/sharedx/file", ConflictLastWriterWins},
+		{"/scratch/file", ConflictOverwrite},
+	}
+	for _, test := range tests {
+		if strategy := policy.StrategyOf(test.path); strategy != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.path, test.expected, strategy)
+		}
+	}
+	if policy.IsOverwriteOnly() {
+		t.Errorf("expected conflict checking")
+	}
+
+	if policy, _ = ParseConflictPolicy(ConflictOverwrite, ""); !policy.IsOverwriteOnly() {
+		t.Errorf("expected no conflict checking")
+	}
+	if _, err = ParseConflictPolicy(ConflictOverwrite, "/shared:merge"); err == nil {
+		t.Errorf("expected error for unknown strategy")
+	}
+}
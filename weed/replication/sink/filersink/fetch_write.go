@@ -1,7 +1,9 @@
 package filersink
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"github.com/schollz/progressbar/v3"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 	"os"
@@ -10,6 +12,7 @@ import (
 
 	"google.golang.org/grpc"
 
+	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/operation"
 	"github.com/seaweedfs/seaweedfs/weed/pb"
@@ -78,7 +81,7 @@ func (fs *FilerSink) replicateOneChunk(sourceChunk *filer_pb.FileChunk, path str
 		ETag:         sourceChunk.ETag,
 		SourceFileId: sourceChunk.GetFileIdString(),
 		CipherKey:    sourceChunk.CipherKey,
-		IsCompressed: sourceChunk.IsCompressed,
+		IsCompressed: sourceChunk.IsCompressed && !filer.IsPackedChunk(sourceChunk),
 	}, nil
 }
 
@@ -90,6 +93,24 @@ func (fs *FilerSink) fetchAndWrite(sourceChunk *filer_pb.FileChunk, path string)
 	}
 	defer util.CloseResponse(resp)
 
+	isInputCompressed := "gzip" == header.Get("Content-Encoding")
+	var body io.Reader = resp.Body
+	if filer.IsPackedChunk(sourceChunk) {
+		// only copy this file's range of the shared chunk
+		if isInputCompressed {
+			gzipReader, gzipErr := gzip.NewReader(body)
+			if gzipErr != nil {
+				return "", fmt.Errorf("read packed part %s: %v", sourceChunk.GetFileIdString(), gzipErr)
+			}
+			defer gzipReader.Close()
+			body, isInputCompressed = gzipReader, false
+		}
+		if _, err = io.CopyN(io.Discard, body, sourceChunk.PackOffset); err != nil {
+			return "", fmt.Errorf("read packed part %s: %v", sourceChunk.GetFileIdString(), err)
+		}
+		body = io.LimitReader(body, int64(sourceChunk.Size))
+	}
+
 	fileId, uploadResult, err, _ := operation.UploadWithRetry(
 		fs,
 		&filer_pb.AssignVolumeRequest{
@@ -104,7 +125,7 @@ func (fs *FilerSink) fetchAndWrite(sourceChunk *filer_pb.FileChunk, path string)
 		&operation.UploadOption{
 			Filename:          filename,
 			Cipher:            false,
-			IsInputCompressed: "gzip" == header.Get("Content-Encoding"),
+			IsInputCompressed: isInputCompressed,
 			MimeType:          header.Get("Content-Type"),
 			PairMap:           nil,
 		},
@@ -116,7 +137,7 @@ func (fs *FilerSink) fetchAndWrite(sourceChunk *filer_pb.FileChunk, path string)
 			glog.V(4).Infof("replicating %s to %s header:%+v", filename, fileUrl, header)
 			return fileUrl
 		},
-		resp.Body,
+		body,
 	)
 
 	if err != nil {
@@ -0,0 +1,91 @@
+package replication
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// SyncDirection tells which way changes under a path flow between filer A and filer B.
+type SyncDirection string
+
+const (
+	SyncBoth SyncDirection = "both"
+	SyncAToB SyncDirection = "a2b"
+	SyncBToA SyncDirection = "b2a"
+	SyncNone SyncDirection = "none"
+)
+
+type syncTopologyRule struct {
+	pathPrefix string
+	direction  SyncDirection
+}
+
+// SyncTopology keeps per-path direction rules. Paths are relative to the
+// synchronized directories, i.e. "a.path" and "b.path" in filer.sync.
+// The rule with the longest matching path prefix wins, and paths without any rule flow both ways.
+type SyncTopology struct {
+	rules []syncTopologyRule
+}
+
+// ParseSyncTopology parses comma separated "<path>:<direction>" rules,
+// e.g. "/shared:both,/ingest:a2b,/reports:b2a,/scratch:none".
+func ParseSyncTopology(spec string) (*SyncTopology, error) {
+	t := &SyncTopology{}
+	for _, item := range util.StringSplit(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		sepIndex := strings.LastIndex(item, ":")
+		if sepIndex <= 0 {
+			return nil, fmt.Errorf("invalid sync topology rule %q, expecting <path>:<direction>", item)
+		}
+		direction := SyncDirection(strings.ToLower(item[sepIndex+1:]))
+		switch direction {
+		case SyncBoth, SyncAToB, SyncBToA, SyncNone:
+		default:
+			return nil, fmt.Errorf("invalid direction in sync topology rule %q, expecting one of both, a2b, b2a, none", item)
+		}
+		pathPrefix := strings.TrimSuffix(item[:sepIndex], "/")
+		if pathPrefix == "" {
+			pathPrefix = "/"
+		}
+		t.rules = append(t.rules, syncTopologyRule{
+			pathPrefix: pathPrefix,
+			direction:  direction,
+		})
+	}
+	return t, nil
+}
+
+// DirectionOf returns the direction configured for the path.
+func (t *SyncTopology) DirectionOf(path string) SyncDirection {
+	direction, matchedLength := SyncBoth, -1
+	if t == nil {
+		return direction
+	}
+	for _, rule := range t.rules {
+		if path != rule.pathPrefix && !util.FullPath(path).IsUnder(util.FullPath(rule.pathPrefix)) {
+			continue
+		}
+		if len(rule.pathPrefix) > matchedLength {
+			direction, matchedLength = rule.direction, len(rule.pathPrefix)
+		}
+	}
+	return direction
+}
+
+// Allows tells whether a change to the path should be synchronized from A to B, or from B to A.
+func (t *SyncTopology) Allows(path string, fromA bool) bool {
+	switch t.DirectionOf(path) {
+	case SyncNone:
+		return false
+	case SyncAToB:
+		return fromA
+	case SyncBToA:
+		return !fromA
+	}
+	return true
+}
@@ -17,12 +17,19 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/wdclient"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func (fs *FilerServer) LookupDirectoryEntry(ctx context.Context, req *filer_pb.LookupDirectoryEntryRequest) (*filer_pb.LookupDirectoryEntryResponse, error) {
 
 	glog.V(4).Infof("LookupDirectoryEntry %s", filepath.Join(req.Directory, req.Name))
 
+	if err := fs.filer.WaitForConsistency(ctx, req.ConsistencyToken); err != nil {
+		return nil, err
+	}
+
 	entry, err := fs.filer.FindEntry(ctx, util.JoinPath(req.Directory, req.Name))
 	if err == filer_pb.ErrNotFound {
 		return &filer_pb.LookupDirectoryEntryResponse{}, err
@@ -41,6 +48,10 @@ func (fs *FilerServer) ListEntries(req *filer_pb.ListEntriesRequest, stream file
 
 	glog.V(4).Infof("ListEntries %v", req)
 
+	if err = fs.filer.WaitForConsistency(stream.Context(), req.ConsistencyToken); err != nil {
+		return err
+	}
+
 	limit := int(req.Limit)
 	if limit == 0 {
 		limit = fs.option.DirListingLimit
@@ -51,15 +62,39 @@ func (fs *FilerServer) ListEntries(req *filer_pb.ListEntriesRequest, stream file
 		paginationLimit = limit
 	}
 
-	lastFileName := req.StartFromFileName
+	cursor := filer.NewListCursor(util.FullPath(req.Directory), req.StartFromFileName, req.Prefix, "", "")
+	cursor.SortBy, cursor.Descending = filer.SortBy(req.SortBy), req.SortDescending
 	includeLastFile := req.InclusiveStartFrom
+	if req.Cursor != "" {
+		if cursor, err = filer.DecodeListCursor(req.Cursor); err != nil {
+			return err
+		}
+		includeLastFile = false
+	} else if cursor.SortBy != filer.SortByName && (req.StartFromFileName != "" || req.Prefix != "") {
+		return fmt.Errorf("sorted listing only continues with the cursor, and does not filter by prefix")
+	}
+
+	if cursor.SortBy != filer.SortByName {
+		return fs.filer.StreamListSortedDirectoryEntries(stream.Context(), util.FullPath(cursor.Directory), cursor.SortBy, cursor.Descending, cursor.SortKey, cursor.LastFileName, int64(limit), func(entry *filer.Entry) bool {
+			if err = stream.Send(&filer_pb.ListEntriesResponse{
+				Entry:  entry.ToProtoEntry(),
+				Cursor: cursor.AfterSorted(entry.Name(), cursor.SortBy.SortKey(entry)).Encode(),
+			}); err != nil {
+				return false
+			}
+			return true
+		})
+	}
+
+	lastFileName := cursor.LastFileName
 	var listErr error
 	for limit > 0 {
 		var hasEntries bool
-		lastFileName, listErr = fs.filer.StreamListDirectoryEntries(stream.Context(), util.FullPath(req.Directory), lastFileName, includeLastFile, int64(paginationLimit), req.Prefix, "", "", func(entry *filer.Entry) bool {
+		lastFileName, listErr = fs.filer.StreamListDirectoryEntries(stream.Context(), util.FullPath(cursor.Directory), lastFileName, includeLastFile, int64(paginationLimit), cursor.Prefix, "", "", func(entry *filer.Entry) bool {
 			hasEntries = true
 			if err = stream.Send(&filer_pb.ListEntriesResponse{
-				Entry: entry.ToProtoEntry(),
+				Entry:  entry.ToProtoEntry(),
+				Cursor: cursor.After(entry.Name()).Encode(),
 			}); err != nil {
 				return false
 			}
@@ -93,6 +128,7 @@ func (fs *FilerServer) LookupVolume(ctx context.Context, req *filer_pb.LookupVol
 	resp := &filer_pb.LookupVolumeResponse{
 		LocationsMap: make(map[string]*filer_pb.Locations),
 	}
+	locality := wdclient.Locality{DataCenter: req.DataCenter, Rack: req.Rack, Preference: wdclient.ReadPreference(req.ReadPreference)}
 
 	for _, vidString := range req.VolumeIds {
 		vid, err := strconv.Atoi(vidString)
@@ -111,6 +147,12 @@ func (fs *FilerServer) LookupVolume(ctx context.Context, req *filer_pb.LookupVol
 				PublicUrl:  loc.PublicUrl,
 				GrpcPort:   uint32(loc.GrpcPort),
 				DataCenter: loc.DataCenter,
+				Rack:       loc.Rack,
+			})
+		}
+		if locality.DataCenter != "" {
+			locs = wdclient.OrderByProximity(locality, locs, func(loc *filer_pb.Location) (string, string) {
+				return loc.DataCenter, loc.Rack
 			})
 		}
 		resp.LocationsMap[vidString] = &filer_pb.Locations{
@@ -141,6 +183,7 @@ func (fs *FilerServer) CreateEntry(ctx context.Context, req *filer_pb.CreateEntr
 	glog.V(4).Infof("CreateEntry %v/%v", req.Directory, req.Entry.Name)
 
 	resp = &filer_pb.CreateEntryResponse{}
+	ctx = filer.RecordWrites(ctx)
 
 	chunks, garbage, err2 := fs.cleanupChunks(util.Join(req.Directory, req.Entry.Name), nil, req.Entry)
 	if err2 != nil {
@@ -158,7 +201,8 @@ func (fs *FilerServer) CreateEntry(ctx context.Context, req *filer_pb.CreateEntr
 	createErr := fs.filer.CreateEntry(ctx, newEntry, req.OExcl, req.IsFromOtherCluster, req.Signatures, req.SkipCheckParentDirectory)
 
 	if createErr == nil {
-		fs.filer.DeleteChunksNotRecursive(garbage)
+		filer.AfterCommit(ctx, func() { fs.filer.DeleteChunksNotRecursive(garbage) })
+		resp.ConsistencyToken = fs.filer.ConsistencyToken(ctx)
 	} else {
 		glog.V(3).Infof("CreateEntry %s: %v", filepath.Join(req.Directory, req.Entry.Name), createErr)
 		resp.Error = createErr.Error()
@@ -170,6 +214,7 @@ func (fs *FilerServer) CreateEntry(ctx context.Context, req *filer_pb.CreateEntr
 func (fs *FilerServer) UpdateEntry(ctx context.Context, req *filer_pb.UpdateEntryRequest) (*filer_pb.UpdateEntryResponse, error) {
 
 	glog.V(4).Infof("UpdateEntry %v", req)
+	ctx = filer.RecordWrites(ctx)
 
 	fullpath := util.Join(req.Directory, req.Entry.Name)
 	entry, err := fs.filer.FindEntry(ctx, util.FullPath(fullpath))
@@ -189,16 +234,24 @@ func (fs *FilerServer) UpdateEntry(ctx context.Context, req *filer_pb.UpdateEntr
 		return &filer_pb.UpdateEntryResponse{}, err
 	}
 
+	if newEntry, err = fs.filer.BeforeOperation(ctx, filer.HookUpdate, entry, newEntry); err != nil {
+		return &filer_pb.UpdateEntryResponse{}, err
+	}
+
 	if err = fs.filer.UpdateEntry(ctx, entry, newEntry); err == nil {
-		fs.filer.DeleteChunksNotRecursive(garbage)
+		filer.AfterCommit(ctx, func() { fs.filer.DeleteChunksNotRecursive(garbage) })
 
 		fs.filer.NotifyUpdateEvent(ctx, entry, newEntry, true, req.IsFromOtherCluster, req.Signatures)
 
+		fs.filer.AfterOperation(ctx, filer.HookUpdate, entry, newEntry)
+
 	} else {
 		glog.V(3).Infof("UpdateEntry %s: %v", filepath.Join(req.Directory, req.Entry.Name), err)
 	}
 
-	return &filer_pb.UpdateEntryResponse{}, err
+	return &filer_pb.UpdateEntryResponse{
+		ConsistencyToken: fs.filer.ConsistencyToken(ctx),
+	}, err
 }
 
 func (fs *FilerServer) cleanupChunks(fullpath string, existingEntry *filer.Entry, newEntry *filer_pb.Entry) (chunks, garbage []*filer_pb.FileChunk, err error) {
@@ -262,7 +315,12 @@ func (fs *FilerServer) AppendToEntry(ctx context.Context, req *filer_pb.AppendTo
 			},
 		}
 	} else {
-		offset = int64(filer.TotalSize(entry.GetChunks()))
+		// same as the appends over http
+		offset = int64(entry.FileSize)
+	}
+
+	if req.CheckSize && uint64(offset) != req.ExpectedSize {
+		return nil, status.Errorf(codes.FailedPrecondition, "%v: %s is %d bytes, expecting %d", filer.ErrUnexpectedFileSize, fullpath, offset, req.ExpectedSize)
 	}
 
 	for _, chunk := range req.Chunks {
@@ -271,6 +329,8 @@ func (fs *FilerServer) AppendToEntry(ctx context.Context, req *filer_pb.AppendTo
 	}
 
 	entry.Chunks = append(entry.GetChunks(), req.Chunks...)
+	entry.FileSize = uint64(offset)
+	entry.Mtime = time.Now()
 	so, err := fs.detectStorageOption(string(fullpath), "", "", entry.TtlSec, "", "", "", "")
 	if err != nil {
 		glog.Warningf("detectStorageOption: %v", err)
@@ -282,17 +342,24 @@ func (fs *FilerServer) AppendToEntry(ctx context.Context, req *filer_pb.AppendTo
 		glog.V(0).Infof("MaybeManifestize: %v", err)
 	}
 
-	err = fs.filer.CreateEntry(context.Background(), entry, false, false, nil, false)
+	writeCtx := filer.RecordWrites(context.Background())
+	err = fs.filer.CreateEntry(writeCtx, entry, false, false, nil, false)
 
-	return &filer_pb.AppendToEntryResponse{}, err
+	return &filer_pb.AppendToEntryResponse{
+		ConsistencyToken: fs.filer.ConsistencyToken(writeCtx),
+		Size:             uint64(offset),
+	}, err
 }
 
 func (fs *FilerServer) DeleteEntry(ctx context.Context, req *filer_pb.DeleteEntryRequest) (resp *filer_pb.DeleteEntryResponse, err error) {
 
 	glog.V(4).Infof("DeleteEntry %v", req)
 
+	ctx = filer.RecordWrites(ctx)
 	err = fs.filer.DeleteEntryMetaAndData(ctx, util.JoinPath(req.Directory, req.Name), req.IsRecursive, req.IgnoreRecursiveError, req.IsDeleteData, req.IsFromOtherCluster, req.Signatures)
-	resp = &filer_pb.DeleteEntryResponse{}
+	resp = &filer_pb.DeleteEntryResponse{
+		ConsistencyToken: fs.filer.ConsistencyToken(ctx),
+	}
 	if err != nil && err != filer_pb.ErrNotFound {
 		resp.Error = err.Error()
 	}
@@ -334,6 +401,8 @@ func (fs *FilerServer) AssignVolume(ctx context.Context, req *filer_pb.AssignVol
 		Auth:        string(assignResult.Auth),
 		Collection:  so.Collection,
 		Replication: so.Replication,
+		Compression: so.Compression,
+		Cipher:      so.Cipher,
 	}, nil
 }
 
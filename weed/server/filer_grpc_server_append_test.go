@@ -0,0 +1,105 @@
+package weed_server
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+// serveTestLocks runs the filer grpc server, for the filer to be its own lock server
+func serveTestLocks(t *testing.T, fs *FilerServer) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	filer_pb.RegisterSeaweedFilerServer(grpcServer, fs)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	grpcPort := listener.Addr().(*net.TCPAddr).Port
+	host := pb.NewServerAddress("127.0.0.1", grpcPort-10000, grpcPort)
+	fs.option.Host = host
+	fs.grpcDialOption = grpc.WithTransportCredentials(insecure.NewCredentials())
+	fs.filer.Dlm.Host = host
+	fs.filer.Dlm.LockRing.SetSnapshot([]pb.ServerAddress{host})
+}
+
+func appendChunk(fs *FilerServer, fileId string, size uint64, expectedSize int64) (*filer_pb.AppendToEntryResponse, error) {
+	return fs.AppendToEntry(context.Background(), &filer_pb.AppendToEntryRequest{
+		Directory:    "/dir",
+		EntryName:    "log",
+		Chunks:       []*filer_pb.FileChunk{{FileId: fileId, Size: size, ModifiedTsNs: time.Now().UnixNano()}},
+		CheckSize:    expectedSize >= 0,
+		ExpectedSize: uint64(expectedSize),
+	})
+}
+
+func TestAppendToEntryExpectedSize(t *testing.T) {
+	fs := newTestFilerServer(t)
+	serveTestLocks(t, fs)
+	ctx := context.Background()
+
+	if resp, err := appendChunk(fs, "1,0101", 10, 0); err != nil || resp.Size != 10 {
+		t.Fatalf("append to a new file: %+v %v", resp, err)
+	}
+	_, err := appendChunk(fs, "1,0202", 10, 5)
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("append with an unexpected size: %v", err)
+	}
+	if resp, err := appendChunk(fs, "1,0303", 10, -1); err != nil || resp.Size != 20 {
+		t.Errorf("append without checking the size: %+v %v", resp, err)
+	}
+
+	// the size is the file size, same as the appends over http, e.g. of a file extended by truncate
+	entry, _ := fs.filer.FindEntry(ctx, "/dir/log")
+	entry.FileSize = 35
+	if err = fs.filer.CreateEntry(ctx, entry, false, false, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = appendChunk(fs, "1,0404", 10, 20); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("append to the extended file by its chunks: %v", err)
+	}
+	if resp, err := appendChunk(fs, "1,0505", 10, 35); err != nil || resp.Size != 45 {
+		t.Errorf("append to the extended file: %+v %v", resp, err)
+	}
+	entry, _ = fs.filer.FindEntry(ctx, "/dir/log")
+	if chunks := entry.GetChunks(); len(chunks) != 3 || chunks[2].Offset != 35 || entry.FileSize != 45 {
+		t.Errorf("appended chunks %+v, size %d", chunks, entry.FileSize)
+	}
+
+	// concurrent appends at the same size, only one of them is appended
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var appended, rejected int
+	for _, fileId := range []string{"1,0606", "1,0707", "1,0808"} {
+		wg.Add(1)
+		go func(fileId string) {
+			defer wg.Done()
+			_, err := appendChunk(fs, fileId, 10, 45)
+			lock.Lock()
+			defer lock.Unlock()
+			if err == nil {
+				appended++
+			} else if status.Code(err) == codes.FailedPrecondition {
+				rejected++
+			} else {
+				t.Errorf("append %s: %v", fileId, err)
+			}
+		}(fileId)
+	}
+	wg.Wait()
+	if appended != 1 || rejected != 2 {
+		t.Errorf("appended %d, rejected %d", appended, rejected)
+	}
+}
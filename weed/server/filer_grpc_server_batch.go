@@ -0,0 +1,95 @@
+package weed_server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func (fs *FilerServer) BatchMutateEntries(ctx context.Context, req *filer_pb.BatchMutateEntriesRequest) (*filer_pb.BatchMutateEntriesResponse, error) {
+
+	glog.V(4).Infof("BatchMutateEntries %d mutations, continue on error %v", len(req.Mutations), req.ContinueOnError)
+
+	ctx = filer.RecordWrites(ctx)
+	resp := &filer_pb.BatchMutateEntriesResponse{
+		Errors: make([]string, len(req.Mutations)),
+	}
+
+	if req.ContinueOnError {
+		for i, mutation := range req.Mutations {
+			if err := fs.applyEntryMutation(ctx, mutation); err != nil {
+				resp.Errors[i] = err.Error()
+				continue
+			}
+			resp.AppliedCount++
+		}
+		resp.ConsistencyToken = fs.filer.ConsistencyToken(ctx)
+		return resp, nil
+	}
+
+	deferCtx := filer.DeferUntilCommit(ctx)
+	txCtx, err := fs.filer.BeginTransaction(deferCtx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %v", err)
+	}
+	// the filer stores without transactions return the context as is
+	isTransactional := txCtx != deferCtx
+
+	for i, mutation := range req.Mutations {
+		if err = fs.applyEntryMutation(txCtx, mutation); err != nil {
+			resp.Errors[i] = err.Error()
+			resp.Error = fmt.Sprintf("mutation %d: %v", i, err)
+			break
+		}
+		resp.AppliedCount++
+	}
+	if err == nil {
+		if err = fs.filer.CommitTransaction(txCtx); err != nil {
+			resp.Error = fmt.Sprintf("commit: %v", err)
+		}
+	}
+
+	if err != nil && isTransactional {
+		if rollbackErr := fs.filer.RollbackTransaction(txCtx); rollbackErr != nil {
+			glog.Errorf("rollback batch of %d mutations: %v", len(req.Mutations), rollbackErr)
+		}
+		resp.AppliedCount = 0
+		return resp, nil
+	}
+
+	// without transactions, the mutations before the failed one are kept
+	filer.RunAfterCommit(txCtx)
+	resp.ConsistencyToken = fs.filer.ConsistencyToken(ctx)
+	return resp, nil
+}
+
+func (fs *FilerServer) applyEntryMutation(ctx context.Context, mutation *filer_pb.EntryMutation) error {
+	switch m := mutation.Mutation.(type) {
+	case *filer_pb.EntryMutation_Create:
+		if m.Create.Entry == nil {
+			return fmt.Errorf("create %s: missing entry", m.Create.Directory)
+		}
+		resp, err := fs.CreateEntry(ctx, m.Create)
+		if err == nil && resp.Error != "" {
+			err = errors.New(resp.Error)
+		}
+		return err
+	case *filer_pb.EntryMutation_Update:
+		if m.Update.Entry == nil {
+			return fmt.Errorf("update %s: missing entry", m.Update.Directory)
+		}
+		_, err := fs.UpdateEntry(ctx, m.Update)
+		return err
+	case *filer_pb.EntryMutation_Delete:
+		resp, err := fs.DeleteEntry(ctx, m.Delete)
+		if err == nil && resp.Error != "" {
+			err = errors.New(resp.Error)
+		}
+		return err
+	}
+	return fmt.Errorf("empty mutation")
+}
@@ -0,0 +1,94 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func (fs *FilerServer) ChunkAudit(ctx context.Context, req *filer_pb.ChunkAuditRequest) (*filer_pb.ChunkAuditResponse, error) {
+
+	var report *filer.ChunkAuditReport
+	var err error
+	switch {
+	case req.Start:
+		glog.V(0).Infof("ChunkAudit start collection:%s volumes:%v", req.Collection, req.VolumeIds)
+		options := filer.ChunkAuditOptions{
+			Collection:    req.Collection,
+			VolumeIds:     make(map[uint32]bool),
+			CutoffTimeAgo: time.Duration(req.CutoffSecondsAgo) * time.Second,
+		}
+		for _, vid := range req.VolumeIds {
+			options.VolumeIds[vid] = true
+		}
+		report, err = fs.filer.ChunkAuditor.Start(options)
+	case req.PurgeAuditId != "":
+		glog.V(0).Infof("ChunkAudit purge %s force:%v", req.PurgeAuditId, req.ForcePurging)
+		report, err = fs.filer.ChunkAuditor.Purge(req.PurgeAuditId, req.ForcePurging)
+	default:
+		report = fs.filer.ChunkAuditor.Report()
+		if report == nil {
+			err = fmt.Errorf("no chunk audit yet")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toPbChunkAudit(report, int(req.Limit)), nil
+}
+
+func toPbChunkAudit(report *filer.ChunkAuditReport, limit int) *filer_pb.ChunkAuditResponse {
+	resp := &filer_pb.ChunkAuditResponse{
+		AuditId:          report.Id,
+		IsRunning:        report.IsRunning(),
+		StartedAtNs:      report.StartedAt.UnixNano(),
+		Error:            report.Error,
+		ScannedEntries:   report.ScannedEntries,
+		ReferencedChunks: report.ReferencedChunks,
+		DanglingCount:    report.DanglingCount,
+		PurgeErrors:      report.PurgeErrors,
+	}
+	if !report.IsRunning() {
+		resp.FinishedAtNs = report.FinishedAt.UnixNano()
+	}
+	if !report.PurgedAt.IsZero() {
+		resp.PurgedAtNs = report.PurgedAt.UnixNano()
+	}
+	for _, v := range report.Volumes {
+		volume := &filer_pb.ChunkAuditResponse_Volume{
+			VolumeId:    v.VolumeId,
+			Collection:  v.Collection,
+			IsEcVolume:  v.IsEcVolume,
+			InUseCount:  v.InUseCount,
+			OrphanCount: uint64(len(v.Orphans)),
+			OrphanSize:  v.OrphanSize,
+			PurgedCount: v.PurgedCount,
+		}
+		for _, server := range v.Servers {
+			volume.Servers = append(volume.Servers, string(server))
+		}
+		for i, orphan := range v.Orphans {
+			if limit > 0 && i >= limit {
+				break
+			}
+			volume.OrphanFileIds = append(volume.OrphanFileIds, orphan.FileId)
+		}
+		resp.Volumes = append(resp.Volumes, volume)
+	}
+	for i, d := range report.Dangling {
+		if limit > 0 && i >= limit {
+			break
+		}
+		resp.Dangling = append(resp.Dangling, &filer_pb.ChunkAuditResponse_Dangling{
+			FileId: d.FileId,
+			Path:   string(d.Path),
+			Reason: d.Reason,
+		})
+	}
+	return resp
+}
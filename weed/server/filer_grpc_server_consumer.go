@@ -0,0 +1,53 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func (fs *FilerServer) CommitConsumerOffset(ctx context.Context, req *filer_pb.CommitConsumerOffsetRequest) (*filer_pb.CommitConsumerOffsetResponse, error) {
+
+	glog.V(4).Infof("CommitConsumerOffset %s %d rewind:%v", req.ConsumerName, req.TsNs, req.Rewind)
+
+	offset, err := fs.filer.CommitConsumerOffset(ctx, req.ConsumerName, req.TsNs, req.Rewind)
+	if err != nil {
+		return nil, err
+	}
+	return &filer_pb.CommitConsumerOffsetResponse{
+		Offset: toPbConsumerOffset(offset),
+	}, nil
+}
+
+func (fs *FilerServer) ListConsumerOffsets(ctx context.Context, req *filer_pb.ListConsumerOffsetsRequest) (*filer_pb.ListConsumerOffsetsResponse, error) {
+
+	offsets, err := fs.filer.ListConsumerOffsets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &filer_pb.ListConsumerOffsetsResponse{}
+	for _, offset := range offsets {
+		resp.Offsets = append(resp.Offsets, toPbConsumerOffset(offset))
+	}
+	return resp, nil
+}
+
+func (fs *FilerServer) DeleteConsumerOffset(ctx context.Context, req *filer_pb.DeleteConsumerOffsetRequest) (*filer_pb.DeleteConsumerOffsetResponse, error) {
+
+	glog.V(0).Infof("DeleteConsumerOffset %s", req.ConsumerName)
+
+	if err := fs.filer.DeleteConsumerOffset(ctx, req.ConsumerName); err != nil {
+		return nil, err
+	}
+	return &filer_pb.DeleteConsumerOffsetResponse{}, nil
+}
+
+func toPbConsumerOffset(offset *filer.ConsumerOffset) *filer_pb.ConsumerOffset {
+	return &filer_pb.ConsumerOffset{
+		ConsumerName:  offset.Name,
+		TsNs:          offset.TsNs,
+		CommittedAtNs: offset.CommittedAt,
+	}
+}
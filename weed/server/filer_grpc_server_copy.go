@@ -0,0 +1,267 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	copyListingPageSize  = 1024
+	copyProgressInterval = time.Second
+)
+
+type entryCopier struct {
+	fs          *FilerServer
+	req         *filer_pb.CopyEntriesRequest
+	stream      filer_pb.SeaweedFiler_CopyEntriesServer
+	copiedCount int64
+	copiedBytes int64
+	lastPath    string
+	lastReport  time.Time
+}
+
+// CopyEntries copies a file or a directory tree without moving the file content out of the cluster.
+// By default the copied files share the chunks of the source files.
+// The entries are copied in the listing order, so an interrupted copy can be resumed
+// after the last reported path.
+func (fs *FilerServer) CopyEntries(req *filer_pb.CopyEntriesRequest, stream filer_pb.SeaweedFiler_CopyEntriesServer) error {
+
+	glog.V(1).Infof("CopyEntries %v", req)
+
+	sourcePath := util.FullPath(req.SourcePath)
+	targetPath := util.FullPath(req.TargetPath)
+	if targetPath == sourcePath || targetPath.IsUnder(sourcePath) {
+		return fmt.Errorf("can not copy %s into itself", sourcePath)
+	}
+
+	ctx := stream.Context()
+	entry, err := fs.filer.FindEntry(ctx, sourcePath)
+	if err != nil {
+		return fmt.Errorf("%s not found: %v", sourcePath, err)
+	}
+
+	var resumeFrom []string
+	if req.ResumeFrom != "" {
+		resumeFrom = strings.Split(strings.Trim(req.ResumeFrom, "/"), "/")
+	}
+
+	c := &entryCopier{
+		fs:         fs,
+		req:        req,
+		stream:     stream,
+		lastPath:   req.ResumeFrom,
+		lastReport: time.Now(),
+	}
+	if err = c.copyEntry(ctx, entry, targetPath, "", resumeFrom); err != nil {
+		return err
+	}
+
+	return stream.Send(&filer_pb.CopyEntriesResponse{
+		CopiedCount: c.copiedCount,
+		CopiedBytes: c.copiedBytes,
+		ResumeFrom:  c.lastPath,
+		IsCompleted: true,
+	})
+}
+
+// copyEntry copies the entry, and the children after resumeFrom if it is a directory.
+func (c *entryCopier) copyEntry(ctx context.Context, entry *filer.Entry, targetPath util.FullPath, relativePath string, resumeFrom []string) error {
+	if entry.IsDirectory() {
+		if err := c.fs.filer.CreateEntry(ctx, c.copyAttributes(entry, targetPath), false, false, c.req.Signatures, false); err != nil {
+			return fmt.Errorf("create %s: %v", targetPath, err)
+		}
+		if err := c.copyChildren(ctx, entry.FullPath, targetPath, relativePath, resumeFrom); err != nil {
+			return err
+		}
+	} else if err := c.copyFile(ctx, entry, targetPath); err != nil {
+		return fmt.Errorf("copy %s => %s: %v", entry.FullPath, targetPath, err)
+	}
+
+	c.copiedCount++
+	if relativePath != "" {
+		c.lastPath = relativePath
+	}
+	if time.Since(c.lastReport) < copyProgressInterval {
+		return nil
+	}
+	c.lastReport = time.Now()
+	return c.stream.Send(&filer_pb.CopyEntriesResponse{
+		CopiedCount: c.copiedCount,
+		CopiedBytes: c.copiedBytes,
+		ResumeFrom:  c.lastPath,
+	})
+}
+
+func (c *entryCopier) copyChildren(ctx context.Context, dir, targetDir util.FullPath, relativePath string, resumeFrom []string) error {
+	startFileName, inclusive := "", false
+	if len(resumeFrom) > 0 {
+		startFileName, inclusive = resumeFrom[0], true
+	}
+	for {
+		// collect one page first, to not copy while listing the store
+		var children []*filer.Entry
+		lastFileName, err := c.fs.filer.StreamListDirectoryEntries(ctx, dir, startFileName, inclusive, copyListingPageSize, "", "", "", func(entry *filer.Entry) bool {
+			children = append(children, entry)
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("list %s: %v", dir, err)
+		}
+		for _, child := range children {
+			var childResumeFrom []string
+			if len(resumeFrom) > 0 && child.Name() == resumeFrom[0] {
+				if len(resumeFrom) == 1 {
+					// copied before the interruption
+					continue
+				}
+				childResumeFrom = resumeFrom[1:]
+			}
+			if err := c.copyEntry(ctx, child, targetDir.Child(child.Name()), path.Join(relativePath, child.Name()), childResumeFrom); err != nil {
+				return err
+			}
+		}
+		resumeFrom = nil
+		if len(children) < copyListingPageSize {
+			return nil
+		}
+		startFileName, inclusive = lastFileName, false
+	}
+}
+
+func (c *entryCopier) copyFile(ctx context.Context, entry *filer.Entry, targetPath util.FullPath) error {
+	if existing, err := c.fs.filer.FindEntry(ctx, targetPath); err == nil && sameChunks(existing.GetChunks(), entry.GetChunks()) {
+		// copied before the interruption
+		return nil
+	}
+
+	var chunks []*filer_pb.FileChunk
+	var err error
+	if c.req.DeepCopy {
+		chunks, err = c.fs.deepCopyChunks(entry, targetPath)
+	} else {
+		chunks, err = c.fs.shareChunks(ctx, entry)
+	}
+	if err != nil {
+		return err
+	}
+
+	newEntry := c.copyAttributes(entry, targetPath)
+	newEntry.Chunks = chunks
+	// the shared or copied chunks hold their own references
+	if err = c.fs.filer.CreateEntry(filer.WithReferencedChunks(ctx), newEntry, false, false, c.req.Signatures, false); err != nil {
+		c.fs.filer.DeleteChunks(chunks)
+		return err
+	}
+	c.copiedBytes += int64(entry.FileSize)
+	return nil
+}
+
+func (c *entryCopier) copyAttributes(entry *filer.Entry, targetPath util.FullPath) *filer.Entry {
+	newEntry := &filer.Entry{
+		FullPath: targetPath,
+		Attr:     entry.Attr,
+		Content:  entry.Content,
+		Remote:   entry.Remote,
+		Quota:    entry.Quota,
+	}
+	if c.req.PreserveAttributes {
+		newEntry.Extended = entry.Extended
+	} else {
+		now := time.Now()
+		newEntry.Attr.Mtime = now
+		newEntry.Attr.Crtime = now
+	}
+	return newEntry
+}
+
+// shareChunks adds a reference to each data chunk of the source file for the copy,
+// without changing the source file. Chunk manifests are resolved, so that each data chunk can be shared.
+func (fs *FilerServer) shareChunks(ctx context.Context, entry *filer.Entry) ([]*filer_pb.FileChunk, error) {
+	if len(entry.GetChunks()) == 0 {
+		return nil, nil
+	}
+	dataChunks, _, err := filer.ResolveChunkManifest(fs.filer.MasterClient.GetLookupFileIdFunction(), entry.GetChunks(), 0, math.MaxInt64)
+	if err != nil {
+		return nil, fmt.Errorf("resolve chunks: %v", err)
+	}
+
+	copiedChunks := make([]*filer_pb.FileChunk, 0, len(dataChunks))
+	for _, chunk := range dataChunks {
+		if chunk.Size == 0 {
+			continue
+		}
+		shared, err := fs.filer.ShareChunk(ctx, chunk)
+		if err != nil {
+			fs.filer.DeleteChunks(copiedChunks)
+			return nil, err
+		}
+		copiedChunks = append(copiedChunks, shared)
+	}
+
+	// the source chunks released before shared are deleted
+	current, err := fs.filer.FindEntry(ctx, entry.FullPath)
+	if err == nil && !sameChunks(current.GetChunks(), entry.GetChunks()) {
+		err = fmt.Errorf("changed while copying")
+	}
+	if err != nil {
+		fs.filer.DeleteChunks(copiedChunks)
+		return nil, err
+	}
+	return copiedChunks, nil
+}
+
+// deepCopyChunks writes the content of each data chunk of the file into a new chunk.
+func (fs *FilerServer) deepCopyChunks(entry *filer.Entry, targetPath util.FullPath) ([]*filer_pb.FileChunk, error) {
+	dataChunks, _, err := filer.ResolveChunkManifest(fs.filer.MasterClient.GetLookupFileIdFunction(), entry.GetChunks(), 0, math.MaxInt64)
+	if err != nil {
+		return nil, fmt.Errorf("resolve chunks: %v", err)
+	}
+	so, err := fs.detectStorageOption(string(targetPath), "", "", entry.TtlSec, "", "", "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []*filer_pb.FileChunk
+	for _, chunk := range dataChunks {
+		source := proto.Clone(chunk).(*filer_pb.FileChunk)
+		source.Offset = 0
+		data := make([]byte, chunk.Size)
+		if err = filer.ReadAll(data, fs.filer.MasterClient, []*filer_pb.FileChunk{source}); err != nil {
+			fs.filer.DeleteChunks(chunks)
+			return nil, fmt.Errorf("read chunk %s: %v", chunk.GetFileIdString(), err)
+		}
+		copied, err := fs.dataToChunk(entry.Name(), entry.Mime, data, chunk.Offset, so)
+		if err != nil {
+			fs.filer.DeleteChunks(append(chunks, copied...))
+			return nil, fmt.Errorf("write copy of chunk %s: %v", chunk.GetFileIdString(), err)
+		}
+		for _, c := range copied {
+			// keep the order of overlapping chunks
+			c.ModifiedTsNs = chunk.ModifiedTsNs
+		}
+		chunks = append(chunks, copied...)
+	}
+	return chunks, nil
+}
+
+func sameChunks(a, b []*filer_pb.FileChunk) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].GetFileIdString() != b[i].GetFileIdString() || a[i].Offset != b[i].Offset || a[i].Size != b[i].Size {
+			return false
+		}
+	}
+	return true
+}
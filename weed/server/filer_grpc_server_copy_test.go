@@ -0,0 +1,176 @@
+package weed_server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+type copyEntriesStream struct {
+	grpc.ServerStream
+	responses []*filer_pb.CopyEntriesResponse
+}
+
+func (s *copyEntriesStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *copyEntriesStream) Send(resp *filer_pb.CopyEntriesResponse) error {
+	s.responses = append(s.responses, resp)
+	return nil
+}
+
+func copyEntries(t *testing.T, fs *FilerServer, req *filer_pb.CopyEntriesRequest) (*filer_pb.CopyEntriesResponse, error) {
+	stream := &copyEntriesStream{}
+	if err := fs.CopyEntries(req, stream); err != nil {
+		return nil, err
+	}
+	last := stream.responses[len(stream.responses)-1]
+	if !last.IsCompleted {
+		t.Errorf("copy %s => %s is not completed", req.SourcePath, req.TargetPath)
+	}
+	return last, nil
+}
+
+func createTestDirectory(t *testing.T, fs *FilerServer, p util.FullPath) {
+	entry := &filer.Entry{FullPath: p, Attr: filer.Attr{Mode: 0755 | 1<<31, Mtime: time.Now(), Crtime: time.Now()}}
+	if err := fs.filer.CreateEntry(context.Background(), entry, false, false, nil, false); err != nil {
+		t.Fatalf("create %s: %v", p, err)
+	}
+}
+
+func TestCopyEntriesSharingChunks(t *testing.T) {
+	fs := newTestFilerServer(t)
+	ctx := context.Background()
+	old := time.Now().Add(-time.Hour)
+	createTestDirectory(t, fs, "/src")
+	createTestDirectory(t, fs, "/src/sub")
+	createTestFile(t, fs, "/src/a", "1,0101", 10, old)
+	createTestFile(t, fs, "/src/b", "1,0202", 20, old)
+	createTestFile(t, fs, "/src/sub/c", "1,0303", 30, old)
+
+	if _, err := copyEntries(t, fs, &filer_pb.CopyEntriesRequest{SourcePath: "/src", TargetPath: "/src/sub/dst"}); err == nil {
+		t.Errorf("copied a directory into itself")
+	}
+
+	resp, err := copyEntries(t, fs, &filer_pb.CopyEntriesRequest{SourcePath: "/src", TargetPath: "/dst", PreserveAttributes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.CopiedCount != 5 || resp.CopiedBytes != 60 {
+		t.Errorf("copied %d entries with %d bytes", resp.CopiedCount, resp.CopiedBytes)
+	}
+
+	for name, fileId := range map[string]string{"a": "1,0101", "b": "1,0202", "sub/c": "1,0303"} {
+		source, err := fs.filer.FindEntry(ctx, util.FullPath("/src/"+name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the source is not changed
+		if chunk := source.GetChunks()[0]; chunk.GetFileIdString() != fileId || filer.IsPackedChunk(chunk) {
+			t.Errorf("source %s chunk %+v", name, chunk)
+		}
+		copied, err := fs.filer.FindEntry(ctx, util.FullPath("/dst/"+name))
+		if err != nil {
+			t.Fatalf("copied %s: %v", name, err)
+		}
+		if chunk := copied.GetChunks()[0]; chunk.GetFileIdString() != fileId || !filer.IsPackedChunk(chunk) || !copied.Mtime.Equal(source.Mtime) {
+			t.Errorf("copied %s chunk %+v mtime %v", name, chunk, copied.Mtime)
+		}
+		// the source and the copy
+		if count := packReferences(t, fs, fileId); count != 2 {
+			t.Errorf("%s references %d, expected 2", name, count)
+		}
+	}
+
+	// the shared chunk is kept until both files release it
+	a, _ := fs.filer.FindEntry(ctx, "/src/a")
+	fs.filer.DeleteChunks(a.GetChunks())
+	if count := packReferences(t, fs, "1,0101"); count != 1 {
+		t.Errorf("references %d after deleting the source, expected 1", count)
+	}
+	copiedA, _ := fs.filer.FindEntry(ctx, "/dst/a")
+	if isLast, err := fs.filer.ReleasePackedChunk(ctx, copiedA.GetChunks()[0].GetFileIdString()); err != nil || !isLast {
+		t.Errorf("release the copy: %v %v", isLast, err)
+	}
+}
+
+func TestCopyEntriesResume(t *testing.T) {
+	fs := newTestFilerServer(t)
+	ctx := context.Background()
+	old := time.Now().Add(-time.Hour)
+	createTestDirectory(t, fs, "/src")
+	createTestDirectory(t, fs, "/src/sub")
+	createTestFile(t, fs, "/src/a", "1,0101", 10, old)
+	createTestFile(t, fs, "/src/sub/b", "1,0202", 20, old)
+	createTestFile(t, fs, "/src/sub/c", "1,0303", 30, old)
+	createTestFile(t, fs, "/src/z", "1,0404", 40, old)
+
+	// interrupted after sub/b
+	createTestDirectory(t, fs, "/dst")
+	resp, err := copyEntries(t, fs, &filer_pb.CopyEntriesRequest{SourcePath: "/src", TargetPath: "/dst", ResumeFrom: "sub/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ResumeFrom != "z" {
+		t.Errorf("resume from %s, expected z", resp.ResumeFrom)
+	}
+	for name, expected := range map[string]bool{"a": false, "sub/b": false, "sub/c": true, "z": true} {
+		if _, err := fs.filer.FindEntry(ctx, util.FullPath("/dst/"+name)); (err == nil) != expected {
+			t.Errorf("copied %s: %v, expected %v", name, err == nil, expected)
+		}
+	}
+
+	// copying again skips the copied files, without adding references
+	if _, err = copyEntries(t, fs, &filer_pb.CopyEntriesRequest{SourcePath: "/src", TargetPath: "/dst"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, fileId := range map[string]string{"a": "1,0101", "sub/b": "1,0202", "sub/c": "1,0303", "z": "1,0404"} {
+		if _, err := fs.filer.FindEntry(ctx, util.FullPath("/dst/"+name)); err != nil {
+			t.Errorf("copied %s: %v", name, err)
+		}
+		if count := packReferences(t, fs, fileId); count != 2 {
+			t.Errorf("%s references %d, expected 2", name, count)
+		}
+	}
+}
+
+func TestCopyEntriesDeepCopy(t *testing.T) {
+	fs := newTestFilerServer(t)
+	ctx := context.Background()
+	createTestDirectory(t, fs, "/src")
+	small := &filer.Entry{FullPath: "/src/small", Attr: filer.Attr{Mode: 0644, FileSize: 5}, Content: []byte("hello")}
+	if err := fs.filer.CreateEntry(ctx, small, false, false, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := copyEntries(t, fs, &filer_pb.CopyEntriesRequest{SourcePath: "/src", TargetPath: "/dst", DeepCopy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.CopiedCount != 2 || resp.CopiedBytes != 5 {
+		t.Errorf("copied %d entries with %d bytes", resp.CopiedCount, resp.CopiedBytes)
+	}
+	copied, err := fs.filer.FindEntry(ctx, "/dst/small")
+	if err != nil || string(copied.Content) != "hello" {
+		t.Errorf("copied small file %+v: %v", copied, err)
+	}
+
+	// the content of the chunks can not be read without the volume servers
+	createTestFile(t, fs, "/src/large", "1,0101", 10, time.Now())
+	if _, err = copyEntries(t, fs, &filer_pb.CopyEntriesRequest{SourcePath: "/src/large", TargetPath: "/dst/large", DeepCopy: true}); err == nil {
+		t.Errorf("deep copied the unreadable chunks")
+	}
+	if _, err = fs.filer.FindEntry(ctx, "/dst/large"); err == nil {
+		t.Errorf("created the copy without its content")
+	}
+	if count := packReferences(t, fs, "1,0101"); count != 0 {
+		t.Errorf("deep copy added %d references", count)
+	}
+}
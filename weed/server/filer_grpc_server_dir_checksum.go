@@ -0,0 +1,84 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func (fs *FilerServer) GetDirectoryChecksum(ctx context.Context, req *filer_pb.GetDirectoryChecksumRequest) (*filer_pb.GetDirectoryChecksumResponse, error) {
+
+	if fs.filer.DirChecksums == nil {
+		return nil, fmt.Errorf("directory checksums are not enabled on filer %s", fs.option.Host)
+	}
+
+	dir := util.FullPath(req.Directory)
+	sum, isTracked, err := fs.filer.DirChecksums.Get(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	resp := &filer_pb.GetDirectoryChecksumResponse{
+		Checksum:  sum.String(),
+		IsTracked: isTracked,
+	}
+	if !req.IncludeChildren {
+		return resp, nil
+	}
+
+	limit := int64(req.Limit)
+	if limit == 0 {
+		limit = int64(fs.option.DirListingLimit)
+	}
+	var listErr error
+	_, err = fs.filer.StreamListDirectoryEntries(ctx, dir, req.StartFromFileName, false, limit, "", "", "", func(entry *filer.Entry) bool {
+		child := &filer_pb.GetDirectoryChecksumResponse_Child{
+			Name:        entry.Name(),
+			IsDirectory: entry.IsDirectory(),
+		}
+		if entry.IsDirectory() {
+			var childSum filer.DirChecksum
+			childSum, _, listErr = fs.filer.DirChecksums.Get(ctx, entry.FullPath)
+			child.Checksum = childSum.String()
+		} else {
+			var childSum filer.DirChecksum
+			childSum, listErr = fs.filer.DirChecksums.ChildChecksum(ctx, entry)
+			child.Checksum = childSum.String()
+		}
+		resp.Children = append(resp.Children, child)
+		return listErr == nil
+	})
+	if err == nil {
+		err = listErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %v", dir, err)
+	}
+	return resp, nil
+}
+
+func (fs *FilerServer) RebuildDirectoryChecksum(ctx context.Context, req *filer_pb.RebuildDirectoryChecksumRequest) (*filer_pb.RebuildDirectoryChecksumResponse, error) {
+
+	if fs.filer.DirChecksums == nil {
+		return nil, fmt.Errorf("directory checksums are not enabled on filer %s", fs.option.Host)
+	}
+
+	dir := util.FullPath(req.Directory)
+	if err := fs.filer.DirChecksums.Rebuild(dir); err != nil {
+		return nil, fmt.Errorf("rebuild checksum of %s: %v", dir, err)
+	}
+	sum, _, err := fs.filer.DirChecksums.Get(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &filer_pb.RebuildDirectoryChecksumResponse{
+		Checksum: sum.String(),
+	}, nil
+}
+
+// startDirChecksums tracks the whole namespace. The filer maintaining the checksums rebuilds them first.
+func (fs *FilerServer) startDirChecksums() {
+	fs.filer.DirChecksums = filer.NewDirChecksums(fs.filer)
+}
@@ -0,0 +1,56 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func (fs *FilerServer) DiskUsage(ctx context.Context, req *filer_pb.DiskUsageRequest) (*filer_pb.DiskUsageResponse, error) {
+
+	glog.V(3).Infof("DiskUsage %s by child %v depth %d breakdown %v", req.Directory, req.ByChild, req.Depth, req.Breakdown)
+
+	options := filer.DiskUsageOptions{
+		Depth:     int(req.Depth),
+		Breakdown: req.Breakdown,
+	}
+	if req.ByChild && options.Depth < 1 {
+		options.Depth = 1
+	}
+	report, err := fs.filer.DiskUsage(ctx, util.FullPath(req.Directory), options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filer_pb.DiskUsageResponse{
+		Total:       toPbDiskUsage(report.Total),
+		Children:    toPbDiskUsages(report.Children),
+		Collections: toPbDiskUsages(report.Collections),
+		Owners:      toPbDiskUsages(report.Owners),
+		Ttls:        toPbDiskUsages(report.TtlClasses),
+	}, nil
+}
+
+func toPbDiskUsages(usages []*filer.DiskUsage) (pbUsages []*filer_pb.DiskUsageResponse_Usage) {
+	for _, usage := range usages {
+		pbUsages = append(pbUsages, toPbDiskUsage(usage))
+	}
+	return
+}
+
+func toPbDiskUsage(usage *filer.DiskUsage) *filer_pb.DiskUsageResponse_Usage {
+	return &filer_pb.DiskUsageResponse_Usage{
+		Path:              string(usage.Path),
+		Key:               usage.Key,
+		LogicalSize:       usage.LogicalSize,
+		PhysicalSize:      usage.PhysicalSize,
+		FileCount:         usage.FileCount,
+		DirectoryCount:    usage.DirectoryCount,
+		ChunkCount:        usage.ChunkCount,
+		SharedChunkCount:  usage.SharedChunkCount,
+		HardLinkFileCount: usage.HardLinkFileCount,
+	}
+}
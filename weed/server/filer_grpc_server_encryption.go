@@ -0,0 +1,29 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func (fs *FilerServer) RewrapEncryptionKeys(ctx context.Context, req *filer_pb.RewrapEncryptionKeysRequest) (*filer_pb.RewrapEncryptionKeysResponse, error) {
+
+	directory := req.Directory
+	if directory == "" {
+		directory = "/"
+	}
+
+	glog.V(0).Infof("RewrapEncryptionKeys %s", directory)
+
+	rewrappedCount, err := fs.filer.RewrapEncryptionKeys(ctx, util.FullPath(directory))
+	if err != nil {
+		return nil, fmt.Errorf("rewrap encryption keys under %s: %v", directory, err)
+	}
+
+	return &filer_pb.RewrapEncryptionKeysResponse{
+		RewrappedCount: rewrappedCount,
+	}, nil
+}
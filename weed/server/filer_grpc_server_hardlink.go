@@ -0,0 +1,46 @@
+package weed_server
+
+import (
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func (fs *FilerServer) CheckHardLinks(req *filer_pb.CheckHardLinksRequest, stream filer_pb.SeaweedFiler_CheckHardLinksServer) error {
+
+	glog.V(0).Infof("CheckHardLinks repair:%v", req.Repair)
+
+	report, err := fs.filer.CheckHardLinks(stream.Context(), req.Repair, func(problem *filer.HardLinkProblem) error {
+		var paths []string
+		for _, p := range problem.Paths {
+			paths = append(paths, string(p))
+		}
+		return stream.Send(&filer_pb.CheckHardLinksResponse{
+			Problem: &filer_pb.CheckHardLinksResponse_Problem{
+				Kind:             string(problem.Kind),
+				HardLinkId:       problem.HardLinkId,
+				Paths:            paths,
+				Counter:          problem.Counter,
+				References:       problem.References,
+				ReclaimableBytes: problem.ReclaimableBytes,
+				Repaired:         problem.Repaired,
+			},
+		})
+	})
+	if err != nil {
+		glog.Errorf("check hard links: %v", err)
+		return err
+	}
+
+	return stream.Send(&filer_pb.CheckHardLinksResponse{
+		Summary: &filer_pb.CheckHardLinksResponse_Summary{
+			HardLinks:        report.HardLinks,
+			Dangling:         report.Dangling,
+			Miscounted:       report.Miscounted,
+			Orphaned:         report.Orphaned,
+			ReclaimableBytes: report.ReclaimableBytes,
+			Repaired:         report.Repaired,
+			OrphansChecked:   report.OrphansChecked,
+		},
+	})
+}
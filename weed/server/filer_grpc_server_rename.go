@@ -15,6 +15,7 @@ import (
 func (fs *FilerServer) AtomicRenameEntry(ctx context.Context, req *filer_pb.AtomicRenameEntryRequest) (*filer_pb.AtomicRenameEntryResponse, error) {
 
 	glog.V(1).Infof("AtomicRenameEntry %v", req)
+	ctx = filer.RecordWrites(ctx)
 
 	oldParent := util.FullPath(filepath.ToSlash(req.OldDirectory))
 	newParent := util.FullPath(filepath.ToSlash(req.NewDirectory))
@@ -23,29 +24,29 @@ func (fs *FilerServer) AtomicRenameEntry(ctx context.Context, req *filer_pb.Atom
 		return nil, err
 	}
 
-	ctx, err := fs.filer.BeginTransaction(ctx)
+	oldEntry, err := fs.filer.FindEntry(ctx, oldParent.Child(req.OldName))
 	if err != nil {
+		return nil, fmt.Errorf("%s/%s not found: %v", req.OldDirectory, req.OldName, err)
+	}
+	if err = fs.beforeRename(ctx, oldEntry, newParent.Child(req.NewName)); err != nil {
 		return nil, err
 	}
 
-	oldEntry, err := fs.filer.FindEntry(ctx, oldParent.Child(req.OldName))
+	op, err := fs.startRenameOperation(req.OperationId, oldEntry.IsDirectory(), oldParent, req.OldName, newParent, req.NewName, req.Signatures)
 	if err != nil {
-		fs.filer.RollbackTransaction(ctx)
-		return nil, fmt.Errorf("%s/%s not found: %v", req.OldDirectory, req.OldName, err)
+		return nil, err
 	}
-
-	moveErr := fs.moveEntry(ctx, nil, oldParent, oldEntry, newParent, req.NewName, req.Signatures)
-	if moveErr != nil {
-		fs.filer.RollbackTransaction(ctx)
-		return nil, fmt.Errorf("%s/%s move error: %v", req.OldDirectory, req.OldName, moveErr)
-	} else {
-		if commitError := fs.filer.CommitTransaction(ctx); commitError != nil {
-			fs.filer.RollbackTransaction(ctx)
-			return nil, fmt.Errorf("%s/%s move commit error: %v", req.OldDirectory, req.OldName, commitError)
-		}
+	err = fs.applyRename(ctx, op, nil)
+	fs.finishRenameOperation(op, err)
+	if err != nil {
+		return nil, err
 	}
+	fs.afterRename(ctx, oldEntry, newParent.Child(req.NewName))
 
-	return &filer_pb.AtomicRenameEntryResponse{}, nil
+	return &filer_pb.AtomicRenameEntryResponse{
+		OperationId:      op.journal.OperationId,
+		ConsistencyToken: fs.filer.ConsistencyToken(ctx),
+	}, nil
 }
 
 func (fs *FilerServer) StreamRenameEntry(req *filer_pb.StreamRenameEntryRequest, stream filer_pb.SeaweedFiler_StreamRenameEntryServer) (err error) {
@@ -61,14 +62,8 @@ func (fs *FilerServer) StreamRenameEntry(req *filer_pb.StreamRenameEntryRequest,
 
 	ctx := context.Background()
 
-	ctx, err = fs.filer.BeginTransaction(ctx)
-	if err != nil {
-		return err
-	}
-
 	oldEntry, err := fs.filer.FindEntry(ctx, oldParent.Child(req.OldName))
 	if err != nil {
-		fs.filer.RollbackTransaction(ctx)
 		return fmt.Errorf("%s/%s not found: %v", req.OldDirectory, req.OldName, err)
 	}
 
@@ -78,7 +73,6 @@ func (fs *FilerServer) StreamRenameEntry(req *filer_pb.StreamRenameEntryRequest,
 		newEntry, err := fs.filer.FindEntry(ctx, targetDir)
 		if err == nil {
 			if !newEntry.IsDirectory() {
-				fs.filer.RollbackTransaction(ctx)
 				return fmt.Errorf("%s is not directory", targetDir)
 			}
 			if entries, _, _ := fs.filer.ListDirectoryEntries(context.Background(), targetDir, "", false, 1, "", "", ""); len(entries) > 0 {
@@ -87,18 +81,34 @@ func (fs *FilerServer) StreamRenameEntry(req *filer_pb.StreamRenameEntryRequest,
 		}
 	}
 
-	moveErr := fs.moveEntry(ctx, stream, oldParent, oldEntry, newParent, req.NewName, req.Signatures)
-	if moveErr != nil {
-		fs.filer.RollbackTransaction(ctx)
-		return fmt.Errorf("%s/%s move error: %v", req.OldDirectory, req.OldName, moveErr)
-	} else {
-		if commitError := fs.filer.CommitTransaction(ctx); commitError != nil {
-			fs.filer.RollbackTransaction(ctx)
-			return fmt.Errorf("%s/%s move commit error: %v", req.OldDirectory, req.OldName, commitError)
-		}
+	if err = fs.beforeRename(ctx, oldEntry, newParent.Child(req.NewName)); err != nil {
+		return err
 	}
 
-	return nil
+	op, err := fs.startRenameOperation(req.OperationId, oldEntry.IsDirectory(), oldParent, req.OldName, newParent, req.NewName, req.Signatures)
+	if err != nil {
+		return err
+	}
+	err = fs.applyRename(ctx, op, stream)
+	fs.finishRenameOperation(op, err)
+	if err == nil {
+		fs.afterRename(ctx, oldEntry, newParent.Child(req.NewName))
+	}
+
+	return err
+}
+
+func (fs *FilerServer) beforeRename(ctx context.Context, oldEntry *filer.Entry, newPath util.FullPath) error {
+	newEntry := oldEntry.ShallowClone()
+	newEntry.FullPath = newPath
+	_, err := fs.filer.BeforeOperation(ctx, filer.HookRename, oldEntry, newEntry)
+	return err
+}
+
+func (fs *FilerServer) afterRename(ctx context.Context, oldEntry *filer.Entry, newPath util.FullPath) {
+	newEntry := oldEntry.ShallowClone()
+	newEntry.FullPath = newPath
+	fs.filer.AfterOperation(ctx, filer.HookRename, oldEntry, newEntry)
 }
 
 func (fs *FilerServer) moveEntry(ctx context.Context, stream filer_pb.SeaweedFiler_StreamRenameEntryServer, oldParent util.FullPath, entry *filer.Entry, newParent util.FullPath, newName string, signatures []int32) error {
@@ -207,6 +217,7 @@ func (fs *FilerServer) moveSelfEntry(ctx context.Context, stream filer_pb.Seawee
 	if deleteErr != nil {
 		return deleteErr
 	}
+	countMovedEntry(ctx)
 	if stream != nil {
 		if err := stream.Send(&filer_pb.StreamRenameEntryResponse{
 			Directory: string(oldParent),
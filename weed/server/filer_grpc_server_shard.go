@@ -0,0 +1,54 @@
+package weed_server
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func (fs *FilerServer) ShardDirectory(req *filer_pb.ShardDirectoryRequest, stream filer_pb.SeaweedFiler_ShardDirectoryServer) error {
+
+	glog.V(0).Infof("ShardDirectory %v", req)
+
+	dirPath := util.FullPath(filepath.ToSlash(req.Directory))
+	ctx := stream.Context()
+
+	entry, err := fs.filer.FindEntry(ctx, dirPath)
+	if err != nil {
+		return fmt.Errorf("find %s: %v", dirPath, err)
+	}
+	if !entry.IsDirectory() {
+		return fmt.Errorf("%s is not a directory", dirPath)
+	}
+
+	if err = fs.filer.Store.StartShardingDirectory(ctx, dirPath, int(req.ShardCount)); err != nil {
+		return err
+	}
+
+	// let the other filers pick up the conversion before moving any child
+	propagated := time.NewTimer(filer.DirShardsPropagationDelay)
+	defer propagated.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-propagated.C:
+	}
+
+	err = fs.filer.Store.MoveIntoShards(ctx, dirPath, func(moved int64) error {
+		return stream.Send(&filer_pb.ShardDirectoryResponse{
+			MovedCount: moved,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return stream.Send(&filer_pb.ShardDirectoryResponse{
+		IsCompleted: true,
+	})
+}
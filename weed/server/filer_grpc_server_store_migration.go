@@ -0,0 +1,37 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func (fs *FilerServer) StoreMigration(ctx context.Context, req *filer_pb.StoreMigrationRequest) (*filer_pb.StoreMigrationResponse, error) {
+
+	migration := fs.filer.StoreMigration()
+	if migration == nil {
+		return nil, fmt.Errorf("filer store %s is not being migrated", fs.filer.Store.GetName())
+	}
+
+	if req.CutOver {
+		glog.V(0).Infof("StoreMigration cut over")
+		if err := migration.CutOver(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	status := migration.Status()
+	return &filer_pb.StoreMigrationResponse{
+		FromStore:       status.From,
+		ToStore:         status.To,
+		Phase:           string(status.Phase),
+		CopiedEntries:   status.CopiedEntries,
+		VerifiedEntries: status.VerifiedEntries,
+		RepairedEntries: status.RepairedEntries,
+		FailedWrites:    status.FailedWrites,
+		StartedAtNs:     status.StartedAt.UnixNano(),
+		LastError:       status.LastError,
+	}, nil
+}
@@ -1,6 +1,7 @@
 package weed_server
 
 import (
+	"context"
 	"fmt"
 	"github.com/seaweedfs/seaweedfs/weed/stats"
 	"strings"
@@ -30,10 +31,17 @@ func (fs *FilerServer) SubscribeMetadata(req *filer_pb.SubscribeMetadataRequest,
 	}
 	defer fs.deleteClient(clientName, req.ClientId, req.ClientEpoch)
 
+	if err := fs.resumeFromConsumerOffset(stream.Context(), req); err != nil {
+		return err
+	}
+
 	lastReadTime := time.Unix(0, req.SinceNs)
 	glog.V(0).Infof(" %v starts to subscribe %s from %+v", clientName, req.PathPrefix, lastReadTime)
 
-	eachEventNotificationFn := fs.eachEventNotificationFn(req, stream, clientName)
+	eachEventNotificationFn, err := fs.eachEventNotificationFn(req, stream, clientName)
+	if err != nil {
+		return err
+	}
 
 	eachLogEntryFn := eachLogEntryFn(eachEventNotificationFn)
 
@@ -102,10 +110,17 @@ func (fs *FilerServer) SubscribeLocalMetadata(req *filer_pb.SubscribeMetadataReq
 		fs.deleteClient(clientName, req.ClientId, req.ClientEpoch)
 	}()
 
+	if err := fs.resumeFromConsumerOffset(stream.Context(), req); err != nil {
+		return err
+	}
+
 	lastReadTime := time.Unix(0, req.SinceNs)
 	glog.V(0).Infof(" + %v local subscribe %s from %+v clientId:%d", clientName, req.PathPrefix, lastReadTime, req.ClientId)
 
-	eachEventNotificationFn := fs.eachEventNotificationFn(req, stream, clientName)
+	eachEventNotificationFn, err := fs.eachEventNotificationFn(req, stream, clientName)
+	if err != nil {
+		return err
+	}
 
 	eachLogEntryFn := eachLogEntryFn(eachEventNotificationFn)
 
@@ -177,9 +192,17 @@ func eachLogEntryFn(eachEventNotificationFn func(dirPath string, eventNotificati
 	}
 }
 
-func (fs *FilerServer) eachEventNotificationFn(req *filer_pb.SubscribeMetadataRequest, stream filer_pb.SeaweedFiler_SubscribeMetadataServer, clientName string) func(dirPath string, eventNotification *filer_pb.EventNotification, tsNs int64) error {
+func (fs *FilerServer) eachEventNotificationFn(req *filer_pb.SubscribeMetadataRequest, stream filer_pb.SeaweedFiler_SubscribeMetadataServer, clientName string) (func(dirPath string, eventNotification *filer_pb.EventNotification, tsNs int64) error, error) {
 	filtered := 0
 
+	var eventFilter *filer.MetadataEventFilter
+	if req.FilterExpression != "" {
+		var err error
+		if eventFilter, err = filer.NewMetadataEventFilter(req.FilterExpression); err != nil {
+			return nil, err
+		}
+	}
+
 	return func(dirPath string, eventNotification *filer_pb.EventNotification, tsNs int64) error {
 		defer func() {
 			if filtered > MaxUnsyncedEvents {
@@ -238,6 +261,10 @@ func (fs *FilerServer) eachEventNotificationFn(req *filer_pb.SubscribeMetadataRe
 			}
 		}
 
+		if eventFilter != nil && !eventFilter.Matches(dirPath, eventNotification) {
+			return nil
+		}
+
 		// collect timestamps for path
 		stats.FilerServerLastSendTsOfSubscribeGauge.WithLabelValues(fs.option.Host.String(), req.ClientName, req.PathPrefix).Set(float64(tsNs))
 
@@ -253,7 +280,7 @@ func (fs *FilerServer) eachEventNotificationFn(req *filer_pb.SubscribeMetadataRe
 		}
 		filtered = 0
 		return nil
-	}
+	}, nil
 }
 
 func hasPrefixIn(text string, prefixes []string) bool {
@@ -301,3 +328,18 @@ func (fs *FilerServer) deleteClient(clientName string, clientId int32, clientEpo
 		}
 	}
 }
+
+// resumeFromConsumerOffset starts a named consumer after its committed offset, if not told where to start.
+func (fs *FilerServer) resumeFromConsumerOffset(ctx context.Context, req *filer_pb.SubscribeMetadataRequest) error {
+	if req.ConsumerName == "" || req.SinceNs != 0 {
+		return nil
+	}
+	offset, err := fs.filer.GetConsumerOffset(ctx, req.ConsumerName)
+	if err != nil {
+		return err
+	}
+	if offset != nil {
+		req.SinceNs = offset.TsNs
+	}
+	return nil
+}
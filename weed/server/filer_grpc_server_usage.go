@@ -0,0 +1,64 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func (fs *FilerServer) GetUsageStats(ctx context.Context, req *filer_pb.GetUsageStatsRequest) (*filer_pb.GetUsageStatsResponse, error) {
+
+	if fs.filer.Usage == nil {
+		return nil, fmt.Errorf("usage stats are not enabled on filer %s", fs.option.Host)
+	}
+
+	var usageStats *filer.UsageStats
+	var isCounted bool
+	var err error
+	if req.Rebuild {
+		usageStats, err = fs.filer.Usage.Rebuild(ctx)
+		isCounted = err == nil
+	} else {
+		usageStats, isCounted, err = fs.filer.Usage.Get(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("usage stats: %v", err)
+	}
+
+	return &filer_pb.GetUsageStatsResponse{
+		Collections: toPbUsages(usageStats.Collections),
+		Directories: toPbUsages(usageStats.Directories),
+		IsCounted:   isCounted,
+	}, nil
+}
+
+func toPbUsages(usages map[string]*filer.Usage) (pbUsages []*filer_pb.GetUsageStatsResponse_Usage) {
+	for _, name := range filer.SortedUsageNames(usages) {
+		pbUsages = append(pbUsages, &filer_pb.GetUsageStatsResponse_Usage{
+			Name:      name,
+			Bytes:     usages[name].Bytes,
+			FileCount: usages[name].FileCount,
+		})
+	}
+	return
+}
+
+// startUsageStats counts the usage of the whole namespace once if never done.
+func (fs *FilerServer) startUsageStats() {
+	fs.filer.Usage = filer.NewUsageTracker(fs.filer, fs.option.Host, fs.option.Collection)
+	go func() {
+		if _, isCounted, err := fs.filer.Usage.Get(context.Background()); err != nil {
+			glog.Errorf("read usage stats: %v", err)
+			return
+		} else if isCounted {
+			return
+		}
+		glog.V(0).Infof("counting usage stats for the first time")
+		if _, err := fs.filer.Usage.Rebuild(context.Background()); err != nil {
+			glog.Errorf("count usage stats: %v", err)
+		}
+	}()
+}
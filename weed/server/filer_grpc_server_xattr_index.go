@@ -0,0 +1,63 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func (fs *FilerServer) FindEntriesByXattr(req *filer_pb.FindEntriesByXattrRequest, stream filer_pb.SeaweedFiler_FindEntriesByXattrServer) (err error) {
+
+	glog.V(4).Infof("FindEntriesByXattr %v", req)
+
+	if fs.filer.XattrIndex == nil {
+		return fmt.Errorf("no extended attribute is indexed on filer %s", fs.option.Host)
+	}
+
+	limit := int64(req.Limit)
+	if limit == 0 {
+		limit = int64(fs.option.DirListingLimit)
+	}
+
+	directory := req.Directory
+	if directory == "" {
+		directory = "/"
+	}
+
+	var sendErr error
+	err = fs.filer.XattrIndex.Find(stream.Context(), req.Key, req.Value, util.FullPath(directory), util.FullPath(req.StartFromPath), func(entry *filer.Entry) bool {
+		dir, _ := entry.FullPath.DirAndName()
+		if sendErr = stream.Send(&filer_pb.FindEntriesByXattrResponse{
+			Directory: dir,
+			Entry:     entry.ToProtoEntry(),
+		}); sendErr != nil {
+			return false
+		}
+		limit--
+		return limit > 0
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	return err
+}
+
+func (fs *FilerServer) RebuildXattrIndex(ctx context.Context, req *filer_pb.RebuildXattrIndexRequest) (*filer_pb.RebuildXattrIndexResponse, error) {
+
+	if fs.filer.XattrIndex == nil {
+		return nil, fmt.Errorf("no extended attribute is indexed on filer %s", fs.option.Host)
+	}
+
+	indexedCount, err := fs.filer.XattrIndex.Rebuild(ctx, req.Key)
+	if err != nil {
+		return nil, fmt.Errorf("rebuild xattr index %s: %v", req.Key, err)
+	}
+
+	return &filer_pb.RebuildXattrIndexResponse{
+		IndexedCount: indexedCount,
+	}, nil
+}
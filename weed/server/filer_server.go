@@ -19,6 +19,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
 	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/wdclient"
 
 	"github.com/seaweedfs/seaweedfs/weed/filer"
 	_ "github.com/seaweedfs/seaweedfs/weed/filer/arangodb"
@@ -34,6 +35,7 @@ import (
 	_ "github.com/seaweedfs/seaweedfs/weed/filer/mysql2"
 	_ "github.com/seaweedfs/seaweedfs/weed/filer/postgres"
 	_ "github.com/seaweedfs/seaweedfs/weed/filer/postgres2"
+	_ "github.com/seaweedfs/seaweedfs/weed/filer/raftleveldb"
 	_ "github.com/seaweedfs/seaweedfs/weed/filer/redis"
 	_ "github.com/seaweedfs/seaweedfs/weed/filer/redis2"
 	_ "github.com/seaweedfs/seaweedfs/weed/filer/redis3"
@@ -70,6 +72,19 @@ type FilerOption struct {
 	ShowUIDirectoryDelete bool
 	DownloadMaxBytesPs    int64
 	DiskType              string
+	PackInterval          time.Duration
+	PackMaxFileSize       int64
+	PackMinFiles          int
+	DirChecksums          bool
+	UsageStats            bool
+	XattrIndexKeys        []string
+	ResolveSymlinks       bool
+	MetaCacheEntries      int64
+	MetaCacheTtl          time.Duration
+	AsOfMaxAge            time.Duration
+	ClientLimits          ClientLimits
+	ClientLimitOverrides  map[string]ClientLimits
+	ReadPreference        wdclient.ReadPreference
 }
 
 type FilerServer struct {
@@ -81,6 +96,7 @@ type FilerServer struct {
 	secret         security.SigningKey
 	filer          *filer.Filer
 	filerGuard     *security.Guard
+	clientLimiter  *clientLimiter
 	grpcDialOption grpc.DialOption
 
 	// metrics read from the master
@@ -94,6 +110,9 @@ type FilerServer struct {
 	// track known metadata listeners
 	knownListenersLock sync.Mutex
 	knownListeners     map[int32]int32
+
+	// journaled renames, by operation id
+	renameOperations sync.Map
 }
 
 func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption) (fs *FilerServer, err error) {
@@ -124,9 +143,15 @@ func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption)
 		fs.listenersCond.Broadcast()
 	})
 	fs.filer.Cipher = option.Cipher
+	fs.filer.MasterClient.Rack = option.Rack
+	fs.filer.MasterClient.SetReadPreference(option.ReadPreference)
 	// we do not support IP whitelist right now
 	fs.filerGuard = security.NewGuard([]string{}, signingKey, expiresAfterSec, readSigningKey, readExpiresAfterSec)
 
+	if !option.ClientLimits.IsUnlimited() || len(option.ClientLimitOverrides) > 0 {
+		fs.clientLimiter = newClientLimiter(option.ClientLimits, option.ClientLimitOverrides)
+	}
+
 	fs.checkWithMaster()
 
 	go stats.LoopPushingMetric("filer", string(fs.option.Host), fs.metricsAddress, fs.metricsIntervalSec)
@@ -148,10 +173,15 @@ func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption)
 	fs.option.recursiveDelete = v.GetBool("filer.options.recursive_delete")
 	v.SetDefault("filer.options.buckets_folder", "/buckets")
 	fs.filer.DirBucketsPath = v.GetString("filer.options.buckets_folder")
+	fs.filer.LoadOperationHooks(v)
 	// TODO deprecated, will be be removed after 2020-12-31
 	// replaced by https://github.com/seaweedfs/seaweedfs/wiki/Path-Specific-Configuration
 	// fs.filer.FsyncBuckets = v.GetStringSlice("filer.options.buckets_fsync")
 	isFresh := fs.filer.LoadConfiguration(v)
+	fs.filer.LoadEnvelopeEncryption(v)
+	if option.MetaCacheEntries > 0 {
+		fs.filer.EnableMetaCache(option.MetaCacheEntries, option.MetaCacheTtl)
+	}
 
 	notification.LoadConfiguration(v, "notification.")
 
@@ -178,6 +208,24 @@ func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption)
 
 	fs.filer.LoadRemoteStorageConfAndMapping()
 
+	go fs.loopRecoveringRenameJournals()
+
+	if option.DirChecksums {
+		fs.startDirChecksums()
+	}
+
+	if option.UsageStats {
+		fs.startUsageStats()
+	}
+
+	if len(option.XattrIndexKeys) > 0 {
+		fs.filer.XattrIndex = filer.NewXattrIndex(fs.filer, option.XattrIndexKeys)
+	}
+
+	if option.PackInterval > 0 {
+		go fs.loopPackingSmallFiles()
+	}
+
 	grace.OnInterrupt(func() {
 		fs.filer.Shutdown()
 	})
@@ -0,0 +1,289 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/security"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// A client is the subject of its jwt, if the filer verifies jwt, or else its ip address.
+// Each client has its own limits, so one misbehaving client can not starve the others.
+// A request over the rate or concurrency limit is rejected with 429 or ResourceExhausted,
+// while the bandwidth limit slows down reading the request and writing the response.
+
+const clientLimitIdleTimeout = 10 * time.Minute
+
+// ClientLimits are the limits of each client. A zero value means no limit.
+type ClientLimits struct {
+	RequestsPerSecond  float64
+	ConcurrentRequests int64
+	BytesPerSecond     int64
+}
+
+func (l ClientLimits) IsUnlimited() bool {
+	return l.RequestsPerSecond == 0 && l.ConcurrentRequests == 0 && l.BytesPerSecond == 0
+}
+
+// ParseClientLimitOverrides parses comma separated "<client>=<requestsPerSecond>/<concurrentRequests>/<MBps>",
+// e.g. "backup=0/4/50,10.0.0.8=500/0/0", where the client is a jwt subject or an ip address.
+func ParseClientLimitOverrides(spec string) (map[string]ClientLimits, error) {
+	overrides := make(map[string]ClientLimits)
+	for _, item := range util.StringSplit(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		sepIndex := strings.LastIndex(item, "=")
+		parts := strings.Split(item[sepIndex+1:], "/")
+		if sepIndex <= 0 || len(parts) != 3 {
+			return nil, fmt.Errorf("invalid client limit %q, expecting <client>=<requestsPerSecond>/<concurrentRequests>/<MBps>", item)
+		}
+		requestsPerSecond, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid requests per second in %q: %v", item, err)
+		}
+		concurrentRequests, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid concurrent requests in %q: %v", item, err)
+		}
+		mbps, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MBps in %q: %v", item, err)
+		}
+		overrides[item[:sepIndex]] = ClientLimits{
+			RequestsPerSecond:  requestsPerSecond,
+			ConcurrentRequests: concurrentRequests,
+			BytesPerSecond:     mbps * 1024 * 1024,
+		}
+	}
+	return overrides, nil
+}
+
+type clientState struct {
+	limits    ClientLimits
+	requests  *rate.Limiter
+	bandwidth *rate.Limiter
+	inFlight  int64
+	lastSeen  time.Time
+}
+
+type clientLimiter struct {
+	defaultLimits ClientLimits
+	overrides     map[string]ClientLimits
+	lock          sync.Mutex
+	clients       map[string]*clientState
+}
+
+func newClientLimiter(defaultLimits ClientLimits, overrides map[string]ClientLimits) *clientLimiter {
+	l := &clientLimiter{
+		defaultLimits: defaultLimits,
+		overrides:     overrides,
+		clients:       make(map[string]*clientState),
+	}
+	go l.loopEvictingIdleClients()
+	return l
+}
+
+func (l *clientLimiter) stateOf(client string) *clientState {
+	state, found := l.clients[client]
+	if found {
+		return state
+	}
+	limits, found := l.overrides[client]
+	if !found {
+		limits = l.defaultLimits
+	}
+	state = &clientState{limits: limits}
+	if limits.RequestsPerSecond > 0 {
+		state.requests = rate.NewLimiter(rate.Limit(limits.RequestsPerSecond), int(math.Max(1, math.Ceil(limits.RequestsPerSecond))))
+	}
+	if limits.BytesPerSecond > 0 {
+		state.bandwidth = rate.NewLimiter(rate.Limit(limits.BytesPerSecond), int(limits.BytesPerSecond))
+	}
+	l.clients[client] = state
+	return state
+}
+
+// acquire admits one request of the client. With countConcurrency, the caller must call release when done.
+func (l *clientLimiter) acquire(client string, countConcurrency bool) (state *clientState, retryAfter time.Duration, err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	state = l.stateOf(client)
+	state.lastSeen = time.Now()
+
+	if countConcurrency && state.limits.ConcurrentRequests > 0 && state.inFlight >= state.limits.ConcurrentRequests {
+		stats.FilerRequestCounter.WithLabelValues(stats.ErrorTooManyConcurrentRequests).Inc()
+		return nil, time.Second, fmt.Errorf("client %s has more than %d concurrent requests", client, state.limits.ConcurrentRequests)
+	}
+	if state.requests != nil {
+		reservation := state.requests.Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			stats.FilerRequestCounter.WithLabelValues(stats.ErrorTooManyRequests).Inc()
+			return nil, delay, fmt.Errorf("client %s has more than %v requests per second", client, state.limits.RequestsPerSecond)
+		}
+	}
+	if countConcurrency {
+		state.inFlight++
+	}
+	return state, 0, nil
+}
+
+func (l *clientLimiter) release(state *clientState) {
+	l.lock.Lock()
+	state.inFlight--
+	l.lock.Unlock()
+}
+
+func (l *clientLimiter) loopEvictingIdleClients() {
+	for {
+		time.Sleep(clientLimitIdleTimeout)
+		l.lock.Lock()
+		for client, state := range l.clients {
+			if state.inFlight == 0 && time.Since(state.lastSeen) > clientLimitIdleTimeout {
+				delete(l.clients, client)
+			}
+		}
+		l.lock.Unlock()
+	}
+}
+
+// clientOfHttpRequest identifies the client by its verified jwt, or else by its ip address.
+func (fs *FilerServer) clientOfHttpRequest(r *http.Request, isWrite bool) string {
+	signingKey := fs.filerGuard.ReadSigningKey
+	if isWrite {
+		signingKey = fs.filerGuard.SigningKey
+	}
+	if len(signingKey) > 0 {
+		if tokenStr := security.GetJwt(r); tokenStr != "" {
+			if token, err := security.DecodeJwt(signingKey, tokenStr, &security.SeaweedFilerClaims{}); err == nil && token.Valid {
+				if subject, err := token.Claims.GetSubject(); err == nil && subject != "" {
+					return subject
+				}
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitHttpRequest admits the request under the limits of its client, or writes 429.
+// The returned release function must be called once the request is done.
+func (fs *FilerServer) limitHttpRequest(w http.ResponseWriter, r *http.Request, isWrite bool) (http.ResponseWriter, func(), bool) {
+	if fs.clientLimiter == nil {
+		return w, func() {}, true
+	}
+
+	client := fs.clientOfHttpRequest(r, isWrite)
+	state, retryAfter, err := fs.clientLimiter.acquire(client, true)
+	if err != nil {
+		glog.V(1).Infof("limit %s %s: %v", r.Method, r.URL.Path, err)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		writeJsonError(w, r, http.StatusTooManyRequests, err)
+		return w, nil, false
+	}
+
+	if state.bandwidth != nil {
+		r.Body = &throttledReadCloser{ReadCloser: r.Body, ctx: r.Context(), limiter: state.bandwidth}
+		w = &throttledResponseWriter{ResponseWriter: w, ctx: r.Context(), limiter: state.bandwidth}
+	}
+	return w, func() { fs.clientLimiter.release(state) }, true
+}
+
+func waitForBandwidth(ctx context.Context, limiter *rate.Limiter, n int) error {
+	for n > 0 {
+		size := n
+		if size > limiter.Burst() {
+			size = limiter.Burst()
+		}
+		if err := limiter.WaitN(ctx, size); err != nil {
+			return err
+		}
+		n -= size
+	}
+	return nil
+}
+
+type throttledReadCloser struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+func (t *throttledReadCloser) Read(p []byte) (n int, err error) {
+	n, err = t.ReadCloser.Read(p)
+	if waitErr := waitForBandwidth(t.ctx, t.limiter, n); waitErr != nil && err == nil {
+		err = waitErr
+	}
+	return
+}
+
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+func (t *throttledResponseWriter) Write(p []byte) (int, error) {
+	if err := waitForBandwidth(t.ctx, t.limiter, len(p)); err != nil {
+		return 0, err
+	}
+	return t.ResponseWriter.Write(p)
+}
+
+func clientOfGrpcContext(ctx context.Context) string {
+	pr, ok := peer.FromContext(ctx)
+	if !ok || pr.Addr == net.Addr(nil) {
+		return ""
+	}
+	if tcpAddr, ok := pr.Addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+	return pr.Addr.String()
+}
+
+// GrpcServerOptions limits the grpc requests of each client, by its ip address.
+// The streams, e.g. metadata subscriptions, only count against the request rate.
+func (fs *FilerServer) GrpcServerOptions() []grpc.ServerOption {
+	if fs.clientLimiter == nil {
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			state, _, err := fs.clientLimiter.acquire(clientOfGrpcContext(ctx), true)
+			if err != nil {
+				return nil, status.Error(codes.ResourceExhausted, err.Error())
+			}
+			defer fs.clientLimiter.release(state)
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if _, _, err := fs.clientLimiter.acquire(clientOfGrpcContext(ss.Context()), false); err != nil {
+				return status.Error(codes.ResourceExhausted, err.Error())
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
@@ -0,0 +1,58 @@
+package weed_server
+
+import (
+	"testing"
+)
+
+func TestParseClientLimitOverrides(t *testing.T) {
+	overrides, err := ParseClientLimitOverrides("backup=0/4/50, 10.0.0.8=500/0/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits := overrides["backup"]; limits.ConcurrentRequests != 4 || limits.BytesPerSecond != 50*1024*1024 {
+		t.Errorf("backup: %+v", limits)
+	}
+	if limits := overrides["10.0.0.8"]; limits.RequestsPerSecond != 500 || !(ClientLimits{}).IsUnlimited() {
+		t.Errorf("10.0.0.8: %+v", limits)
+	}
+	for _, spec := range []string{"backup", "backup=1/2", "=1/2/3", "backup=a/2/3"} {
+		if _, err := ParseClientLimitOverrides(spec); err == nil {
+			t.Errorf("expecting an error for %q", spec)
+		}
+	}
+}
+
+func TestClientLimiterAcquire(t *testing.T) {
+	l := &clientLimiter{
+		defaultLimits: ClientLimits{ConcurrentRequests: 2, RequestsPerSecond: 3},
+		overrides:     map[string]ClientLimits{"admin": {}},
+		clients:       make(map[string]*clientState),
+	}
+
+	first, _, err := l.acquire("a", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = l.acquire("a", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = l.acquire("a", true); err == nil {
+		t.Errorf("expecting the concurrency limit")
+	}
+	l.release(first)
+	if _, _, err = l.acquire("a", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, retryAfter, err := l.acquire("a", false); err == nil || retryAfter <= 0 {
+		t.Errorf("expecting the rate limit, got %v retry after %v", err, retryAfter)
+	}
+
+	if _, _, err = l.acquire("b", true); err != nil {
+		t.Errorf("another client has its own limits: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, _, err = l.acquire("admin", true); err != nil {
+			t.Errorf("admin is not limited: %v", err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func createDedupedFile(t *testing.T, fs *FilerServer, p util.FullPath, chunk *filer_pb.FileChunk) {
+	entry := &filer.Entry{
+		FullPath: p,
+		Attr:     filer.Attr{Mode: 0644, Mtime: time.Now(), Crtime: time.Now(), FileSize: chunk.Size},
+		Chunks:   []*filer_pb.FileChunk{chunk},
+	}
+	if err := fs.filer.CreateEntry(filer.WithReferencedChunks(context.Background()), entry, false, false, nil, false); err != nil {
+		t.Fatalf("create %s: %v", p, err)
+	}
+}
+
+func TestDedupeChunks(t *testing.T) {
+	fs := newTestFilerServer(t)
+	ctx := context.Background()
+	digest := filer.ContentDigest([]byte("hello"))
+
+	if chunk, err := fs.filer.ReuseDedupedChunk(ctx, "c1", "", "", digest); chunk != nil || err != nil {
+		t.Fatalf("reused %+v before recorded: %v", chunk, err)
+	}
+	uploaded := &filer_pb.FileChunk{FileId: "1,0101", Size: 5}
+	recorded, err := fs.filer.RecordDedupedChunk(ctx, "c1", "", "", digest, uploaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filer.IsPackedChunk(recorded) || recorded.GetFileIdString() != "1,0101" {
+		t.Errorf("recorded chunk %+v", recorded)
+	}
+	createDedupedFile(t, fs, "/dir/a", recorded)
+
+	// reused only with the same collection, ttl and disk type
+	for _, other := range [][3]string{{"c2", "", ""}, {"c1", "1d", ""}, {"c1", "", "ssd"}} {
+		if chunk, err := fs.filer.ReuseDedupedChunk(ctx, other[0], other[1], other[2], digest); chunk != nil || err != nil {
+			t.Errorf("reused %+v for %v: %v", chunk, other, err)
+		}
+	}
+	reused, err := fs.filer.ReuseDedupedChunk(ctx, "c1", "", "", digest)
+	if err != nil || reused == nil || reused.GetFileIdString() != "1,0101" {
+		t.Fatalf("reused %+v: %v", reused, err)
+	}
+	createDedupedFile(t, fs, "/dir/b", reused)
+	if count := packReferences(t, fs, "1,0101"); count != 2 {
+		t.Errorf("references %d, expected 2", count)
+	}
+
+	// overwriting a with the same content releases its old reference
+	reused, _ = fs.filer.ReuseDedupedChunk(ctx, "c1", "", "", digest)
+	createDedupedFile(t, fs, "/dir/a", reused)
+	if count := packReferences(t, fs, "1,0101"); count != 2 {
+		t.Errorf("references %d after overwriting, expected 2", count)
+	}
+
+	// the last release deletes the digest mapping
+	for _, name := range []string{"a", "b"} {
+		if err = fs.filer.DeleteEntryMetaAndData(ctx, util.NewFullPath("/dir", name), false, false, true, false, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count := packReferences(t, fs, "1,0101"); count != 0 {
+		t.Errorf("references %d after deleting all, expected none", count)
+	}
+	if chunk, err := fs.filer.ReuseDedupedChunk(ctx, "c1", "", "", digest); chunk != nil || err != nil {
+		t.Errorf("reused the released chunk %+v: %v", chunk, err)
+	}
+	if _, err = fs.filer.Store.KvGet(ctx, []byte(fmt.Sprintf("dedupe.c1...%x", digest))); err != filer.ErrKvNotFound {
+		t.Errorf("kept the digest mapping of the released chunk: %v", err)
+	}
+
+	// the mapping to a newer chunk of the same content is kept
+	first, _ := fs.filer.RecordDedupedChunk(ctx, "c1", "", "", digest, &filer_pb.FileChunk{FileId: "1,0202", Size: 5})
+	second, _ := fs.filer.RecordDedupedChunk(ctx, "c1", "", "", digest, &filer_pb.FileChunk{FileId: "1,0303", Size: 5})
+	if isLast, err := fs.filer.ReleasePackedChunk(ctx, first.GetFileIdString()); !isLast || err != nil {
+		t.Fatalf("release %s: %v %v", first.GetFileIdString(), isLast, err)
+	}
+	if reused, err = fs.filer.ReuseDedupedChunk(ctx, "c1", "", "", digest); reused == nil || reused.GetFileIdString() != second.GetFileIdString() {
+		t.Errorf("reused %+v after releasing the older chunk: %v", reused, err)
+	}
+}
@@ -48,6 +48,12 @@ func (fs *FilerServer) filerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w, release, ok := fs.limitHttpRequest(w, r, !isReadHttpCall)
+	if !ok {
+		return
+	}
+	defer release()
+
 	stats.FilerRequestCounter.WithLabelValues(r.Method).Inc()
 	defer func() {
 		stats.FilerRequestHistogram.WithLabelValues(r.Method).Observe(time.Since(start).Seconds())
@@ -120,6 +126,12 @@ func (fs *FilerServer) readonlyFilerHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	w, release, ok := fs.limitHttpRequest(w, r, false)
+	if !ok {
+		return
+	}
+	defer release()
+
 	w.Header().Set("Server", "SeaweedFS Filer "+util.VERSION)
 
 	switch r.Method {
@@ -8,6 +8,7 @@ import (
 	"math"
 	"mime"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -82,6 +83,27 @@ func checkPreconditions(w http.ResponseWriter, r *http.Request, entry *filer.Ent
 	return false
 }
 
+// shouldResolveSymlinks tells whether to read the symlink targets instead of the symlinks,
+// as set by "-resolveSymlinks" and overridden by "?resolveSymlinks=true|false".
+func (fs *FilerServer) shouldResolveSymlinks(r *http.Request) bool {
+	switch r.URL.Query().Get("resolveSymlinks") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return fs.option.ResolveSymlinks
+}
+
+// rootDirectoryHandler lists or archives the root directory, when it can not be looked up.
+func (fs *FilerServer) rootDirectoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("archive") != "" && !fs.option.DisableDirListing {
+		fs.archiveDirectoryHandler(w, r, &filer.Entry{FullPath: "/", Attr: filer.Attr{Mode: os.ModeDir | 0755}})
+		return
+	}
+	fs.listDirectoryHandler(w, r)
+}
+
 func (fs *FilerServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request) {
 
 	path := r.URL.Path
@@ -90,16 +112,46 @@ func (fs *FilerServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request)
 		path = path[:len(path)-1]
 	}
 
-	entry, err := fs.filer.FindEntry(context.Background(), util.FullPath(path))
+	consistencyToken := r.Header.Get(filer.ConsistencyTokenHeader)
+	if consistencyToken == "" {
+		consistencyToken = r.URL.Query().Get("consistencyToken")
+	}
+	if err := fs.filer.WaitForConsistency(context.Background(), consistencyToken); err != nil {
+		glog.V(1).Infof("read %s: %v", path, err)
+		writeJsonError(w, r, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	var entry *filer.Entry
+	var err error
+	if asOfHeader := r.Header.Get(filer.AsOfHeader); asOfHeader != "" {
+		asOf, asOfErr := filer.ParseAsOf(asOfHeader, fs.option.AsOfMaxAge)
+		if asOfErr != nil {
+			writeJsonError(w, r, http.StatusBadRequest, asOfErr)
+			return
+		}
+		entry, err = fs.filer.FindEntryAsOf(context.Background(), util.FullPath(path), asOf)
+	} else if fs.shouldResolveSymlinks(r) {
+		entry, err = fs.filer.FindEntryResolvingSymlinks(context.Background(), util.FullPath(path))
+		if err == nil && entry.IsDirectory() && string(entry.FullPath) != path {
+			// list the target directory
+			r.URL.Path = string(entry.FullPath)
+		}
+	} else {
+		entry, err = fs.filer.FindEntry(context.Background(), util.FullPath(path))
+	}
 	if err != nil {
 		if path == "/" {
-			fs.listDirectoryHandler(w, r)
+			fs.rootDirectoryHandler(w, r)
 			return
 		}
 		if err == filer_pb.ErrNotFound {
 			glog.V(2).Infof("Not found %s: %v", path, err)
 			stats.FilerRequestCounter.WithLabelValues(stats.ErrorReadNotFound).Inc()
 			w.WriteHeader(http.StatusNotFound)
+		} else if err == filer.ErrSymlinkLoop {
+			glog.V(1).Infof("resolve %s: %v", path, err)
+			writeJsonError(w, r, http.StatusLoopDetected, err)
 		} else {
 			glog.Errorf("Internal %s: %v", path, err)
 			stats.FilerRequestCounter.WithLabelValues(stats.ErrorReadInternal).Inc()
@@ -119,6 +171,10 @@ func (fs *FilerServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request)
 			writeJsonQuiet(w, r, http.StatusOK, entry)
 			return
 		}
+		if query.Get("archive") != "" {
+			fs.archiveDirectoryHandler(w, r, entry)
+			return
+		}
 		if entry.Attr.Mime == "" || (entry.Attr.Mime == s3_constants.FolderMimeType && r.Header.Get(s3_constants.AmzIdentityId) == "") {
 			// return index of directory for non s3 gateway
 			fs.listDirectoryHandler(w, r)
@@ -0,0 +1,262 @@
+package weed_server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const archiveListBatchSize = 1024
+
+// archiveWriter adds the entries of a directory tree to an archive.
+type archiveWriter interface {
+	addDirectory(name string, entry *filer.Entry) error
+	addFile(name string, entry *filer.Entry) (io.Writer, error)
+	addSymlink(name string, entry *filer.Entry) error
+	io.Closer
+}
+
+// archiveDirectoryHandler streams the directory tree as an archive, for "?archive=zip",
+// "?archive=tar", "?archive=tar.gz" or "?archive=tar.zst".
+// The file content is streamed chunk by chunk, and nothing is buffered on the filer.
+// Since the response has started, a read failure can only abort the archive halfway.
+func (fs *FilerServer) archiveDirectoryHandler(w http.ResponseWriter, r *http.Request, entry *filer.Entry) {
+
+	stats.FilerRequestCounter.WithLabelValues(stats.DirArchive).Inc()
+
+	format := r.URL.Query().Get("archive")
+
+	rootName := entry.Name()
+	if rootName == "" {
+		rootName = "root"
+	}
+
+	var ext, contentType string
+	var newArchiveWriter func(io.Writer) (archiveWriter, error)
+	switch format {
+	case "zip":
+		ext, contentType, newArchiveWriter = ".zip", "application/zip", newZipArchiveWriter
+	case "tar":
+		ext, contentType, newArchiveWriter = ".tar", "application/x-tar", newTarArchiveWriter
+	case "tar.gz", "tgz":
+		ext, contentType = ".tar.gz", "application/gzip"
+		newArchiveWriter = func(writer io.Writer) (archiveWriter, error) {
+			return newCompressedTarArchiveWriter(gzip.NewWriter(writer)), nil
+		}
+	case "tar.zst":
+		ext, contentType = ".tar.zst", "application/zstd"
+		newArchiveWriter = func(writer io.Writer) (archiveWriter, error) {
+			encoder, err := zstd.NewWriter(writer)
+			if err != nil {
+				return nil, err
+			}
+			return newCompressedTarArchiveWriter(encoder), nil
+		}
+	default:
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("unknown archive format %q, expecting zip, tar, tar.gz or tar.zst", format))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", rootName+ext))
+	if r.Method == "HEAD" {
+		return
+	}
+
+	archive, err := newArchiveWriter(w)
+	if err != nil {
+		glog.Errorf("archive %s: %v", entry.FullPath, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err = fs.archiveDirectory(r.Context(), archive, entry.FullPath, rootName); err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		stats.FilerRequestCounter.WithLabelValues(stats.ErrorReadStream).Inc()
+		glog.Errorf("archive %s: %v", entry.FullPath, err)
+		// break the response, so the client does not take the truncated archive as complete
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func (fs *FilerServer) archiveDirectory(ctx context.Context, archive archiveWriter, dir util.FullPath, dirName string) error {
+
+	lastFileName := ""
+	for {
+		var entries []*filer.Entry
+		_, err := fs.filer.StreamListDirectoryEntries(ctx, dir, lastFileName, false, archiveListBatchSize, "", "", "", func(entry *filer.Entry) bool {
+			entries = append(entries, entry)
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("list %s: %v", dir, err)
+		}
+
+		for _, entry := range entries {
+			name := dirName + "/" + entry.Name()
+			switch {
+			case entry.IsDirectory():
+				if entry.FullPath == filer.SystemDir {
+					continue
+				}
+				if err = archive.addDirectory(name, entry); err != nil {
+					return err
+				}
+				if err = fs.archiveDirectory(ctx, archive, entry.FullPath, name); err != nil {
+					return err
+				}
+			case entry.Attr.SymlinkTarget != "":
+				if err = archive.addSymlink(name, entry); err != nil {
+					return err
+				}
+			default:
+				if err = fs.archiveFile(ctx, archive, name, entry); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(entries) < archiveListBatchSize {
+			return nil
+		}
+		lastFileName = entries[len(entries)-1].Name()
+	}
+}
+
+func (fs *FilerServer) archiveFile(ctx context.Context, archive archiveWriter, name string, entry *filer.Entry) error {
+
+	writer, err := archive.addFile(name, entry)
+	if err != nil {
+		return err
+	}
+
+	totalSize := int64(entry.Size())
+	if totalSize <= int64(len(entry.Content)) {
+		_, err = writer.Write(entry.Content[:totalSize])
+		return err
+	}
+
+	chunks := entry.GetChunks()
+	if entry.IsInRemoteOnly() {
+		dir, name := entry.FullPath.DirAndName()
+		resp, err := fs.CacheRemoteObjectToLocalCluster(ctx, &filer_pb.CacheRemoteObjectToLocalClusterRequest{
+			Directory: dir,
+			Name:      name,
+		})
+		if err != nil {
+			return fmt.Errorf("cache %s: %v", entry.FullPath, err)
+		}
+		chunks = resp.Entry.GetChunks()
+	}
+
+	if err = filer.StreamContentWithThrottler(fs.filer.MasterClient, writer, chunks, 0, totalSize, fs.option.DownloadMaxBytesPs); err != nil {
+		return fmt.Errorf("read %s: %v", entry.FullPath, err)
+	}
+	return nil
+}
+
+type tarArchiveWriter struct {
+	*tar.Writer
+	compressor io.WriteCloser
+}
+
+func newTarArchiveWriter(writer io.Writer) (archiveWriter, error) {
+	return &tarArchiveWriter{Writer: tar.NewWriter(writer)}, nil
+}
+
+func newCompressedTarArchiveWriter(compressor io.WriteCloser) archiveWriter {
+	return &tarArchiveWriter{Writer: tar.NewWriter(compressor), compressor: compressor}
+}
+
+func (t *tarArchiveWriter) header(name string, entry *filer.Entry, typeFlag byte) *tar.Header {
+	return &tar.Header{
+		Typeflag: typeFlag,
+		Name:     name,
+		Mode:     int64(entry.Attr.Mode.Perm()),
+		Uid:      int(entry.Attr.Uid),
+		Gid:      int(entry.Attr.Gid),
+		Uname:    entry.Attr.UserName,
+		ModTime:  entry.Attr.Mtime,
+	}
+}
+
+func (t *tarArchiveWriter) addDirectory(name string, entry *filer.Entry) error {
+	return t.WriteHeader(t.header(name+"/", entry, tar.TypeDir))
+}
+
+func (t *tarArchiveWriter) addFile(name string, entry *filer.Entry) (io.Writer, error) {
+	header := t.header(name, entry, tar.TypeReg)
+	header.Size = int64(entry.Size())
+	return t.Writer, t.WriteHeader(header)
+}
+
+func (t *tarArchiveWriter) addSymlink(name string, entry *filer.Entry) error {
+	header := t.header(name, entry, tar.TypeSymlink)
+	header.Linkname = entry.Attr.SymlinkTarget
+	return t.WriteHeader(header)
+}
+
+func (t *tarArchiveWriter) Close() error {
+	if err := t.Writer.Close(); err != nil {
+		return err
+	}
+	if t.compressor != nil {
+		return t.compressor.Close()
+	}
+	return nil
+}
+
+type zipArchiveWriter struct {
+	*zip.Writer
+}
+
+func newZipArchiveWriter(writer io.Writer) (archiveWriter, error) {
+	return &zipArchiveWriter{Writer: zip.NewWriter(writer)}, nil
+}
+
+func (z *zipArchiveWriter) header(name string, entry *filer.Entry, mode os.FileMode) *zip.FileHeader {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: entry.Attr.Mtime,
+	}
+	header.SetMode(mode | entry.Attr.Mode.Perm())
+	return header
+}
+
+func (z *zipArchiveWriter) addDirectory(name string, entry *filer.Entry) error {
+	header := z.header(name+"/", entry, os.ModeDir)
+	header.Method = zip.Store
+	_, err := z.CreateHeader(header)
+	return err
+}
+
+func (z *zipArchiveWriter) addFile(name string, entry *filer.Entry) (io.Writer, error) {
+	return z.CreateHeader(z.header(name, entry, 0))
+}
+
+func (z *zipArchiveWriter) addSymlink(name string, entry *filer.Entry) error {
+	header := z.header(name, entry, os.ModeSymlink)
+	header.Method = zip.Store
+	writer, err := z.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write([]byte(entry.Attr.SymlinkTarget))
+	return err
+}
@@ -0,0 +1,182 @@
+package weed_server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+)
+
+func createTestTree(t *testing.T, fs *FilerServer) {
+	now := time.Now()
+	for _, entry := range []*filer.Entry{
+		{FullPath: "/docs/a.txt", Attr: filer.Attr{Mode: 0644, Mtime: now, FileSize: 5}, Content: []byte("hello")},
+		{FullPath: "/docs/empty", Attr: filer.Attr{Mode: 0600, Mtime: now}},
+		{FullPath: "/docs/link", Attr: filer.Attr{Mode: os.ModeSymlink | 0777, Mtime: now, SymlinkTarget: "a.txt"}},
+		{FullPath: "/docs/sub/b.txt", Attr: filer.Attr{Mode: 0644, Mtime: now, FileSize: 6}, Content: []byte("world!")},
+	} {
+		if err := fs.filer.CreateEntry(context.Background(), entry, false, false, nil, false); err != nil {
+			t.Fatalf("create %s: %v", entry.FullPath, err)
+		}
+	}
+}
+
+func getArchive(t *testing.T, fs *FilerServer, url string) (*httptest.ResponseRecorder, []byte) {
+	w := httptest.NewRecorder()
+	fs.GetOrHeadHandler(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("get %s: %d %s", url, w.Code, w.Body.String())
+	}
+	return w, w.Body.Bytes()
+}
+
+// readTar returns the content of the files, the "/" suffixed directories, and the "->" symlinks
+func readTar(t *testing.T, reader io.Reader) map[string]string {
+	files := make(map[string]string)
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("read tar: %v", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			files[header.Name] = ""
+		case tar.TypeSymlink:
+			files[header.Name] = "->" + header.Linkname
+		default:
+			data, _ := io.ReadAll(tarReader)
+			if int64(len(data)) != header.Size {
+				t.Errorf("%s has %d bytes, header size %d", header.Name, len(data), header.Size)
+			}
+			files[header.Name] = string(data)
+		}
+	}
+}
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	files := make(map[string]string)
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("read zip: %v", err)
+	}
+	for _, file := range zipReader.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+		switch {
+		case file.Mode().IsDir():
+			files[file.Name] = ""
+		case file.Mode()&os.ModeSymlink != 0:
+			files[file.Name] = "->" + string(content)
+		default:
+			files[file.Name] = string(content)
+		}
+	}
+	return files
+}
+
+func TestArchiveDirectory(t *testing.T) {
+	fs := newTestFilerServer(t)
+	createTestTree(t, fs)
+
+	expected := map[string]string{
+		"docs/a.txt":     "hello",
+		"docs/empty":     "",
+		"docs/link":      "->a.txt",
+		"docs/sub/":      "",
+		"docs/sub/b.txt": "world!",
+	}
+
+	w, data := getArchive(t, fs, "/docs?archive=tar")
+	if w.Header().Get("Content-Disposition") != `attachment; filename="docs.tar"` {
+		t.Errorf("Content-Disposition %s", w.Header().Get("Content-Disposition"))
+	}
+	if files := readTar(t, bytes.NewReader(data)); !reflect.DeepEqual(files, expected) {
+		t.Errorf("tar %v", files)
+	}
+
+	_, data = getArchive(t, fs, "/docs/?archive=tar.gz")
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := readTar(t, gzipReader); !reflect.DeepEqual(files, expected) {
+		t.Errorf("tar.gz %v", files)
+	}
+
+	_, data = getArchive(t, fs, "/docs?archive=tar.zst")
+	zstdReader, err := zstd.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zstdReader.Close()
+	if files := readTar(t, zstdReader); !reflect.DeepEqual(files, expected) {
+		t.Errorf("tar.zst %v", files)
+	}
+
+	w, data = getArchive(t, fs, "/docs?archive=zip")
+	if w.Header().Get("Content-Type") != "application/zip" {
+		t.Errorf("Content-Type %s", w.Header().Get("Content-Type"))
+	}
+	if files := readZip(t, data); !reflect.DeepEqual(files, expected) {
+		t.Errorf("zip %v", files)
+	}
+
+	w = httptest.NewRecorder()
+	fs.GetOrHeadHandler(w, httptest.NewRequest(http.MethodGet, "/docs?archive=rar", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: %d", w.Code)
+	}
+}
+
+func TestArchiveRootDirectory(t *testing.T) {
+	fs := newTestFilerServer(t)
+	createTestTree(t, fs)
+
+	expected := map[string]string{
+		"root/docs/":          "",
+		"root/docs/a.txt":     "hello",
+		"root/docs/empty":     "",
+		"root/docs/link":      "->a.txt",
+		"root/docs/sub/":      "",
+		"root/docs/sub/b.txt": "world!",
+	}
+	for _, url := range []string{"/?archive=tar", "/?archive=tar&resolveSymlinks=true"} {
+		w, data := getArchive(t, fs, url)
+		if w.Header().Get("Content-Disposition") != `attachment; filename="root.tar"` {
+			t.Errorf("%s Content-Disposition %s", url, w.Header().Get("Content-Disposition"))
+		}
+		if files := readTar(t, bytes.NewReader(data)); !reflect.DeepEqual(files, expected) {
+			t.Errorf("%s tar %v", url, files)
+		}
+	}
+
+	// archived the same when the root can not be looked up
+	w := httptest.NewRecorder()
+	fs.rootDirectoryHandler(w, httptest.NewRequest(http.MethodGet, "/?archive=zip", nil))
+	if w.Header().Get("Content-Disposition") != `attachment; filename="root.zip"` {
+		t.Errorf("Content-Disposition %s", w.Header().Get("Content-Disposition"))
+	}
+	if files := readZip(t, w.Body.Bytes()); !reflect.DeepEqual(files, expected) {
+		t.Errorf("zip %v", files)
+	}
+}
@@ -2,10 +2,13 @@ package weed_server
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	ui "github.com/seaweedfs/seaweedfs/weed/server/filer_ui"
 	"github.com/seaweedfs/seaweedfs/weed/stats"
@@ -16,6 +19,12 @@ import (
 // files are sorted by name and paginated via "lastFileName" and "limit".
 // sub directories are listed on the first page, when "lastFileName"
 // is empty.
+// Alternatively, pass back the opaque "cursor" of the previous page to continue
+// the listing, on this or any other filer.
+// With "sortBy=mtime" or "sortBy=size", and optionally "desc=true", the entries are
+// sorted by the filer store, if it keeps the indexes, and paginated via "cursor".
+// With the "X-Seaweed-As-Of" header, the directory is listed as it was at that time,
+// sorted by name.
 func (fs *FilerServer) listDirectoryHandler(w http.ResponseWriter, r *http.Request) {
 
 	stats.FilerRequestCounter.WithLabelValues(stats.DirList).Inc()
@@ -33,8 +42,62 @@ func (fs *FilerServer) listDirectoryHandler(w http.ResponseWriter, r *http.Reque
 	lastFileName := r.FormValue("lastFileName")
 	namePattern := r.FormValue("namePattern")
 	namePatternExclude := r.FormValue("namePatternExclude")
+	sortBy, err := parseSortBy(r.FormValue("sortBy"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	descending := r.FormValue("desc") == "true"
+	var sortKey uint64
+	if cursorString := r.FormValue("cursor"); cursorString != "" {
+		cursor, err := filer.DecodeListCursor(cursorString)
+		if err != nil {
+			writeJsonError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if cursor.Directory != path {
+			writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("list cursor is for %s", cursor.Directory))
+			return
+		}
+		lastFileName, namePattern, namePatternExclude = cursor.LastFileName, cursor.NamePattern, cursor.NamePatternExclude
+		sortBy, descending, sortKey = cursor.SortBy, cursor.Descending, cursor.SortKey
+	}
 
-	entries, shouldDisplayLoadMore, err := fs.filer.ListDirectoryEntries(context.Background(), util.FullPath(path), lastFileName, false, int64(limit), "", namePattern, namePatternExclude)
+	var asOf time.Time
+	if asOfHeader := r.Header.Get(filer.AsOfHeader); asOfHeader != "" {
+		if asOf, err = filer.ParseAsOf(asOfHeader, fs.option.AsOfMaxAge); err != nil {
+			writeJsonError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if sortBy != filer.SortByName {
+			writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("listing as of a past time is only sorted by name"))
+			return
+		}
+	}
+
+	var entries []*filer.Entry
+	var shouldDisplayLoadMore bool
+	if !asOf.IsZero() {
+		entries, shouldDisplayLoadMore, err = fs.filer.ListDirectoryEntriesAsOf(context.Background(), util.FullPath(path), asOf, lastFileName, false, int64(limit), namePattern, namePatternExclude)
+	} else if sortBy == filer.SortByName {
+		entries, shouldDisplayLoadMore, err = fs.filer.ListDirectoryEntries(context.Background(), util.FullPath(path), lastFileName, false, int64(limit), "", namePattern, namePatternExclude)
+	} else {
+		if namePattern != "" || namePatternExclude != "" {
+			writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("sorted listing does not filter by name pattern"))
+			return
+		}
+		err = fs.filer.StreamListSortedDirectoryEntries(context.Background(), util.FullPath(path), sortBy, descending, sortKey, lastFileName, int64(limit)+1, func(entry *filer.Entry) bool {
+			entries = append(entries, entry)
+			return true
+		})
+		if shouldDisplayLoadMore = len(entries) > limit; shouldDisplayLoadMore {
+			entries = entries[:limit]
+		}
+		if err == filer.ErrUnsupportedSortedListing {
+			writeJsonError(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
 
 	if err != nil {
 		glog.V(0).Infof("listDirectory %s %s %d: %s", path, lastFileName, limit, err)
@@ -42,6 +105,7 @@ func (fs *FilerServer) listDirectoryHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	dirPath := util.FullPath(path)
 	if path == "/" {
 		path = ""
 	}
@@ -55,6 +119,12 @@ func (fs *FilerServer) listDirectoryHandler(w http.ResponseWriter, r *http.Reque
 	glog.V(4).Infof("listDirectory %s, last file %s, limit %d: %d items", path, lastFileName, limit, len(entries))
 
 	if r.Header.Get("Accept") == "application/json" {
+		var cursor string
+		if shouldDisplayLoadMore {
+			c := filer.NewListCursor(dirPath, lastFileName, "", namePattern, namePatternExclude)
+			c.SortBy, c.Descending = sortBy, descending
+			cursor = c.AfterSorted(lastFileName, sortBy.SortKey(entries[len(entries)-1])).Encode()
+		}
 		writeJsonQuiet(w, r, http.StatusOK, struct {
 			Path                  string
 			Entries               interface{}
@@ -62,6 +132,7 @@ func (fs *FilerServer) listDirectoryHandler(w http.ResponseWriter, r *http.Reque
 			LastFileName          string
 			ShouldDisplayLoadMore bool
 			EmptyFolder           bool
+			Cursor                string `json:",omitempty"`
 		}{
 			path,
 			entries,
@@ -69,6 +140,7 @@ func (fs *FilerServer) listDirectoryHandler(w http.ResponseWriter, r *http.Reque
 			lastFileName,
 			shouldDisplayLoadMore,
 			emptyFolder,
+			cursor,
 		})
 		return
 	}
@@ -96,3 +168,15 @@ func (fs *FilerServer) listDirectoryHandler(w http.ResponseWriter, r *http.Reque
 		glog.V(0).Infof("Template Execute Error: %v", err)
 	}
 }
+
+func parseSortBy(sortBy string) (filer.SortBy, error) {
+	switch sortBy {
+	case "", "name":
+		return filer.SortByName, nil
+	case "mtime":
+		return filer.SortByMtime, nil
+	case "size":
+		return filer.SortBySize, nil
+	}
+	return filer.SortByName, fmt.Errorf("unknown sortBy %q", sortBy)
+}
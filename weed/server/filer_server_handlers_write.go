@@ -11,6 +11,7 @@ import (
 
 	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
 
+	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/operation"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
@@ -70,7 +71,7 @@ func (fs *FilerServer) assignNewFileInfo(so *operation.StorageOption) (fileId, u
 
 func (fs *FilerServer) PostHandler(w http.ResponseWriter, r *http.Request, contentLength int64) {
 
-	ctx := context.Background()
+	ctx := filer.RecordWrites(context.Background())
 
 	destination := r.RequestURI
 	if finalDestination := r.Header.Get(s3_constants.SeaweedStorageDestinationHeader); finalDestination != "" {
@@ -177,6 +178,7 @@ func (fs *FilerServer) move(ctx context.Context, w http.ResponseWriter, r *http.
 		return
 	}
 
+	fs.setConsistencyToken(ctx, w)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -200,7 +202,8 @@ func (fs *FilerServer) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 		objectPath = objectPath[0 : len(objectPath)-1]
 	}
 
-	err := fs.filer.DeleteEntryMetaAndData(context.Background(), util.FullPath(objectPath), isRecursive, ignoreRecursiveError, !skipChunkDeletion, false, nil)
+	ctx := filer.RecordWrites(context.Background())
+	err := fs.filer.DeleteEntryMetaAndData(ctx, util.FullPath(objectPath), isRecursive, ignoreRecursiveError, !skipChunkDeletion, false, nil)
 	if err != nil {
 		glog.V(1).Infoln("deleting", objectPath, ":", err.Error())
 		httpStatus := http.StatusInternalServerError
@@ -213,9 +216,17 @@ func (fs *FilerServer) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	fs.setConsistencyToken(ctx, w)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// setConsistencyToken returns the token of the writes, for later reads from any filer.
+func (fs *FilerServer) setConsistencyToken(ctx context.Context, w http.ResponseWriter) {
+	if token := fs.filer.ConsistencyToken(ctx); token != "" {
+		w.Header().Set(filer.ConsistencyTokenHeader, token)
+	}
+}
+
 func (fs *FilerServer) detectStorageOption(requestURI, qCollection, qReplication string, ttlSeconds int32, diskType, dataCenter, rack, dataNode string) (*operation.StorageOption, error) {
 
 	rule := fs.filer.FilerConf.MatchStorageRule(requestURI)
@@ -248,6 +259,9 @@ func (fs *FilerServer) detectStorageOption(requestURI, qCollection, qReplication
 		DiskType:          util.Nvl(diskType, rule.DiskType),
 		Fsync:             rule.Fsync,
 		VolumeGrowthCount: rule.VolumeGrowthCount,
+		Compression:       rule.Compression,
+		Dedupe:            rule.Dedupe && ttlSeconds == 0,
+		Cipher:            rule.EncryptionKeyId != "",
 	}, nil
 }
 
@@ -3,6 +3,7 @@ package weed_server
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	//"github.com/seaweedfs/seaweedfs/weed/s3api"
 	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
@@ -14,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
 	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/operation"
@@ -35,6 +37,11 @@ func (fs *FilerServer) autoChunk(ctx context.Context, w http.ResponseWriter, r *
 
 	chunkSize := 1024 * 1024 * maxMB
 
+	if _, _, err := appendExpectedSize(r); err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
 	var reply *FilerPostResult
 	var err error
 	var md5bytes []byte
@@ -52,10 +59,13 @@ func (fs *FilerServer) autoChunk(ctx context.Context, w http.ResponseWriter, r *
 			writeJsonError(w, r, 499, err)
 		} else if strings.HasSuffix(err.Error(), "is a file") || strings.HasSuffix(err.Error(), "already exists") {
 			writeJsonError(w, r, http.StatusConflict, err)
+		} else if errors.Is(err, filer.ErrUnexpectedFileSize) {
+			writeJsonError(w, r, http.StatusPreconditionFailed, err)
 		} else {
 			writeJsonError(w, r, http.StatusInternalServerError, err)
 		}
 	} else if reply != nil {
+		fs.setConsistencyToken(ctx, w)
 		if len(md5bytes) > 0 {
 			md5InBase64 := util.Base64Encode(md5bytes)
 			w.Header().Set("Content-MD5", md5InBase64)
@@ -133,6 +143,23 @@ func isAppend(r *http.Request) bool {
 	return r.URL.Query().Get("op") == "append"
 }
 
+// appendExpectedSize parses the optional "expectedSize" of an append,
+// to append only if no other client has appended to the file since it was last checked.
+func appendExpectedSize(r *http.Request) (expectedSize uint64, hasExpectedSize bool, err error) {
+	query := r.URL.Query()
+	if !query.Has("expectedSize") {
+		return 0, false, nil
+	}
+	if !isAppend(r) {
+		return 0, false, fmt.Errorf("expectedSize is only for op=append")
+	}
+	expectedSize, err = strconv.ParseUint(query.Get("expectedSize"), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid expectedSize '%s'", query.Get("expectedSize"))
+	}
+	return expectedSize, true, nil
+}
+
 func skipCheckParentDirEntry(r *http.Request) bool {
 	return r.URL.Query().Get("skipCheckParentDir") == "true"
 }
@@ -174,12 +201,26 @@ func (fs *FilerServer) saveMetaData(ctx context.Context, r *http.Request, fileNa
 	isOffsetWrite := len(fileChunks) > 0 && fileChunks[0].Offset > 0
 	// when it is an append
 	if isAppend || isOffsetWrite {
+		if isAppend {
+			// appends via any filer are serialized on the file, same as AppendToEntry
+			lock := cluster.NewLockClient(fs.grpcDialOption, fs.option.Host).NewLock(path, string(fs.option.Host))
+			defer lock.StopLock()
+		}
 		existingEntry, findErr := fs.filer.FindEntry(ctx, util.FullPath(path))
 		if findErr != nil && findErr != filer_pb.ErrNotFound {
 			glog.V(0).Infof("failing to find %s: %v", path, findErr)
 		}
 		entry = existingEntry
 	}
+	if expectedSize, hasExpectedSize, _ := appendExpectedSize(r); hasExpectedSize {
+		var currentSize uint64
+		if entry != nil {
+			currentSize = entry.FileSize
+		}
+		if currentSize != expectedSize {
+			return nil, fmt.Errorf("%w: %s is %d bytes, expecting %d", filer.ErrUnexpectedFileSize, path, currentSize, expectedSize)
+		}
+	}
 	if entry != nil {
 		entry.Mtime = time.Now()
 		entry.Md5 = nil
@@ -255,6 +296,10 @@ func (fs *FilerServer) saveMetaData(ctx context.Context, r *http.Request, fileNa
 		}
 	}
 
+	if so.Dedupe && !isAppend && !isOffsetWrite {
+		// each chunk, deduplicated or not, is new to the overwritten file
+		ctx = filer.WithReferencedChunks(ctx)
+	}
 	if dbErr := fs.filer.CreateEntry(ctx, entry, false, false, nil, skipCheckParentDirEntry(r)); dbErr != nil {
 		replyerr = dbErr
 		filerResult.Error = dbErr.Error()
@@ -282,7 +327,7 @@ func (fs *FilerServer) saveAsChunk(so *operation.StorageOption) filer.SaveDataAs
 			uploadOption := &operation.UploadOption{
 				UploadUrl:         urlLocation,
 				Filename:          name,
-				Cipher:            fs.option.Cipher,
+				Cipher:            fs.option.Cipher || so.Cipher,
 				IsInputCompressed: false,
 				MimeType:          "",
 				PairMap:           nil,
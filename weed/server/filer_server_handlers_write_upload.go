@@ -2,6 +2,7 @@ package weed_server
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"fmt"
 	"hash"
@@ -13,6 +14,7 @@ import (
 
 	"golang.org/x/exp/slices"
 
+	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/operation"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
@@ -80,7 +82,7 @@ func (fs *FilerServer) uploadReaderToChunks(w http.ResponseWriter, r *http.Reque
 			break
 		}
 		if chunkOffset == 0 && !isAppend {
-			if dataSize < fs.option.SaveToFilerLimit {
+			if dataSize < fs.option.SaveToFilerLimit && !so.Cipher {
 				chunkOffset += dataSize
 				smallContent = make([]byte, dataSize)
 				bytesBuffer.Read(smallContent)
@@ -141,7 +143,7 @@ func (fs *FilerServer) uploadReaderToChunks(w http.ResponseWriter, r *http.Reque
 	return fileChunks, md5Hash, chunkOffset, nil, smallContent
 }
 
-func (fs *FilerServer) doUpload(urlLocation string, limitedReader io.Reader, fileName string, contentType string, pairMap map[string]string, auth security.EncodedJwt) (*operation.UploadResult, error, []byte) {
+func (fs *FilerServer) doUpload(urlLocation string, limitedReader io.Reader, fileName string, contentType string, pairMap map[string]string, auth security.EncodedJwt, compression string, cipher bool) (*operation.UploadResult, error, []byte) {
 
 	stats.FilerRequestCounter.WithLabelValues(stats.ChunkUpload).Inc()
 	start := time.Now()
@@ -152,11 +154,12 @@ func (fs *FilerServer) doUpload(urlLocation string, limitedReader io.Reader, fil
 	uploadOption := &operation.UploadOption{
 		UploadUrl:         urlLocation,
 		Filename:          fileName,
-		Cipher:            fs.option.Cipher,
+		Cipher:            fs.option.Cipher || cipher,
 		IsInputCompressed: false,
 		MimeType:          contentType,
 		PairMap:           pairMap,
 		Jwt:               auth,
+		Compression:       compression,
 	}
 	uploadResult, err, data := operation.Upload(limitedReader, uploadOption)
 	if uploadResult != nil && uploadResult.RetryCount > 0 {
@@ -166,6 +169,21 @@ func (fs *FilerServer) doUpload(urlLocation string, limitedReader io.Reader, fil
 }
 
 func (fs *FilerServer) dataToChunk(fileName, contentType string, data []byte, chunkOffset int64, so *operation.StorageOption) ([]*filer_pb.FileChunk, error) {
+	var digest []byte
+	if so.Dedupe && len(data) > 0 {
+		digest = filer.ContentDigest(data)
+		deduped, err := fs.filer.ReuseDedupedChunk(context.Background(), so.Collection, so.TtlString(), so.DiskType, digest)
+		if err != nil {
+			glog.V(0).Infof("dedupe %s: %v", fileName, err)
+		}
+		if deduped != nil {
+			stats.FilerRequestCounter.WithLabelValues(stats.ChunkDedupe).Inc()
+			deduped.Offset = chunkOffset
+			deduped.ModifiedTsNs = time.Now().UnixNano()
+			return []*filer_pb.FileChunk{deduped}, nil
+		}
+	}
+
 	dataReader := util.NewBytesReader(data)
 
 	// retry to assign a different file id
@@ -184,7 +202,7 @@ func (fs *FilerServer) dataToChunk(fileName, contentType string, data []byte, ch
 			return uploadErr
 		}
 		// upload the chunk to the volume server
-		uploadResult, uploadErr, _ = fs.doUpload(urlLocation, dataReader, fileName, contentType, nil, auth)
+		uploadResult, uploadErr, _ = fs.doUpload(urlLocation, dataReader, fileName, contentType, nil, auth, so.Compression, so.Cipher)
 		if uploadErr != nil {
 			glog.V(4).Infof("retry later due to upload error: %v", uploadErr)
 			stats.FilerRequestCounter.WithLabelValues(stats.ChunkDoUploadRetry).Inc()
@@ -208,5 +226,11 @@ func (fs *FilerServer) dataToChunk(fileName, contentType string, data []byte, ch
 	if uploadResult.Size == 0 {
 		return nil, nil
 	}
-	return []*filer_pb.FileChunk{uploadResult.ToPbFileChunk(fileId, chunkOffset, time.Now().UnixNano())}, nil
+	chunk := uploadResult.ToPbFileChunk(fileId, chunkOffset, time.Now().UnixNano())
+	if digest != nil {
+		if chunk, err = fs.filer.RecordDedupedChunk(context.Background(), so.Collection, so.TtlString(), so.DiskType, digest, chunk); err != nil {
+			glog.V(0).Infof("dedupe %s: %v", fileName, err)
+		}
+	}
+	return []*filer_pb.FileChunk{chunk}, nil
 }
@@ -0,0 +1,232 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const (
+	packListingPageSize = 1024
+	packLockKey         = "filer.pack"
+)
+
+type packCandidate struct {
+	entry *filer.Entry
+	chunk *filer_pb.FileChunk
+}
+
+// loopPackingSmallFiles periodically packs the small files in the folders
+// configured with "fs.configure -packSmallFiles" into shared chunks,
+// so that billions of tiny files do not need billions of needles.
+// Only the filer holding the cluster lock packs, the others stand by.
+func (fs *FilerServer) loopPackingSmallFiles() {
+	if fs.option.Cipher {
+		glog.V(0).Infof("small file packing is disabled with encrypted volume data")
+		return
+	}
+	lockClient := cluster.NewLockClient(fs.grpcDialOption, fs.option.Host)
+	lock := lockClient.StartLock(packLockKey, string(fs.option.Host))
+	var wasLocked bool
+	for {
+		time.Sleep(fs.option.PackInterval)
+		if !lock.IsLocked() {
+			if wasLocked {
+				// lost the lock, and stopped renewing it
+				glog.V(0).Infof("stop packing small files, and wait for the lock again")
+				lock.StopLock()
+				lock, wasLocked = lockClient.StartLock(packLockKey, string(fs.option.Host)), false
+			}
+			continue
+		}
+		wasLocked = true
+		for _, location := range fs.filer.FilerConf.ToProto().Locations {
+			if !location.PackSmallFiles {
+				continue
+			}
+			dir := location.LocationPrefix
+			if !strings.HasSuffix(dir, "/") {
+				dir, _ = util.FullPath(dir).DirAndName()
+			}
+			if err := fs.packSmallFilesUnder(util.FullPath(dir), location.LocationPrefix); err != nil {
+				glog.Errorf("pack small files under %s: %v", location.LocationPrefix, err)
+			}
+		}
+	}
+}
+
+func (fs *FilerServer) packSmallFilesUnder(dir util.FullPath, locationPrefix string) error {
+	ctx := context.Background()
+	olderThan := time.Now().Add(-fs.option.PackInterval)
+	maxPackSize := int64(fs.option.MaxMB) * 1024 * 1024
+
+	var subDirs []util.FullPath
+	var candidates []*packCandidate
+	var candidatesSize int64
+	lastFileName := ""
+	for {
+		var count int
+		var packErr error
+		_, err := fs.filer.StreamListDirectoryEntries(ctx, dir, lastFileName, false, packListingPageSize, "", "", "", func(entry *filer.Entry) bool {
+			count++
+			lastFileName = entry.Name()
+			if entry.IsDirectory() {
+				subDirs = append(subDirs, entry.FullPath)
+				return true
+			}
+			if !strings.HasPrefix(string(entry.FullPath), locationPrefix) {
+				return true
+			}
+			chunk := fs.packableChunk(entry, olderThan)
+			if chunk == nil {
+				return true
+			}
+			candidates = append(candidates, &packCandidate{entry: entry, chunk: chunk})
+			candidatesSize += int64(chunk.Size)
+			if candidatesSize >= maxPackSize {
+				packErr = fs.packSmallFiles(ctx, dir, candidates)
+				candidates, candidatesSize = nil, 0
+			}
+			return packErr == nil
+		})
+		if err != nil {
+			return fmt.Errorf("list %s: %v", dir, err)
+		}
+		if packErr != nil {
+			return packErr
+		}
+		if count < packListingPageSize {
+			break
+		}
+	}
+	if len(candidates) >= fs.option.PackMinFiles {
+		if err := fs.packSmallFiles(ctx, dir, candidates); err != nil {
+			return err
+		}
+	}
+
+	for _, subDir := range subDirs {
+		if err := fs.packSmallFilesUnder(subDir, locationPrefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packableChunk returns the only chunk of a small file which has not changed recently.
+func (fs *FilerServer) packableChunk(entry *filer.Entry, olderThan time.Time) *filer_pb.FileChunk {
+	if len(entry.GetChunks()) != 1 || entry.HardLinkId != nil || entry.TtlSec > 0 || entry.IsInRemoteOnly() {
+		return nil
+	}
+	if entry.Mtime.After(olderThan) {
+		return nil
+	}
+	chunk := entry.GetChunks()[0]
+	if chunk.IsChunkManifest || filer.IsPackedChunk(chunk) || chunk.CipherKey != nil || chunk.Offset != 0 {
+		return nil
+	}
+	if chunk.Size == 0 || int64(chunk.Size) > fs.option.PackMaxFileSize || chunk.Size != entry.FileSize {
+		return nil
+	}
+	return chunk
+}
+
+// packSmallFiles writes the content of the files into one shared chunk,
+// and points each file to its range of the shared chunk.
+func (fs *FilerServer) packSmallFiles(ctx context.Context, dir util.FullPath, candidates []*packCandidate) error {
+	if len(candidates) < fs.option.PackMinFiles {
+		return nil
+	}
+
+	var packSize int64
+	for _, candidate := range candidates {
+		packSize += int64(candidate.chunk.Size)
+	}
+	data := make([]byte, packSize)
+	offsets := make([]int64, len(candidates))
+	var offset int64
+	for i, candidate := range candidates {
+		size := int64(candidate.chunk.Size)
+		if err := filer.ReadAll(data[offset:offset+size], fs.filer.MasterClient, candidate.entry.GetChunks()); err != nil {
+			return fmt.Errorf("read %s: %v", candidate.entry.FullPath, err)
+		}
+		offsets[i] = offset
+		offset += size
+	}
+
+	so, err := fs.detectStorageOption(string(dir)+"/", "", "", 0, "", "", "", "")
+	if err != nil {
+		return err
+	}
+	// the pack chunk is referenced by the packed files only
+	so.Dedupe = false
+	chunks, err := fs.dataToChunk("", "", data, 0, so)
+	if err != nil {
+		fs.filer.DeleteChunks(chunks)
+		return fmt.Errorf("upload pack for %s: %v", dir, err)
+	}
+	if len(chunks) != 1 {
+		fs.filer.DeleteChunks(chunks)
+		return fmt.Errorf("upload pack for %s: unexpected %d chunks", dir, len(chunks))
+	}
+	pack := chunks[0]
+	packedCount, err := fs.pointToPack(ctx, pack, uint64(packSize), candidates, offsets)
+	if err != nil {
+		fs.filer.DeleteChunks(chunks)
+		return err
+	}
+
+	glog.V(0).Infof("packed %d small files in %s into %s", packedCount, dir, pack.GetFileIdString())
+	return nil
+}
+
+// pointToPack points each file to its range of the uploaded shared chunk, and returns how many files are packed.
+func (fs *FilerServer) pointToPack(ctx context.Context, pack *filer_pb.FileChunk, packSize uint64, candidates []*packCandidate, offsets []int64) (packedCount int, err error) {
+	if err = fs.filer.SetPackReferences(ctx, pack.GetFileIdString(), len(candidates)); err != nil {
+		return 0, err
+	}
+
+	for i, candidate := range candidates {
+		packedChunk := &filer_pb.FileChunk{
+			FileId:       pack.FileId,
+			Fid:          pack.Fid,
+			Offset:       0,
+			Size:         candidate.chunk.Size,
+			ModifiedTsNs: candidate.chunk.ModifiedTsNs,
+			ETag:         candidate.chunk.ETag,
+			IsCompressed: pack.IsCompressed,
+			PackOffset:   offsets[i],
+			PackSize:     packSize,
+		}
+		if err := fs.repointToPack(ctx, candidate, packedChunk); err != nil {
+			glog.V(1).Infof("pack %s: %v", candidate.entry.FullPath, err)
+			// the file was not packed and does not refer to the shared chunk
+			fs.filer.DeleteChunks([]*filer_pb.FileChunk{packedChunk})
+			continue
+		}
+		packedCount++
+	}
+	return packedCount, nil
+}
+
+// repointToPack updates the file to read from the shared chunk, if the file is unchanged since listed.
+func (fs *FilerServer) repointToPack(ctx context.Context, candidate *packCandidate, packedChunk *filer_pb.FileChunk) error {
+	current, err := fs.filer.FindEntry(ctx, candidate.entry.FullPath)
+	if err != nil {
+		return err
+	}
+	if len(current.GetChunks()) != 1 || current.GetChunks()[0].GetFileIdString() != candidate.chunk.GetFileIdString() || current.FileSize != candidate.entry.FileSize {
+		return fmt.Errorf("changed while packing")
+	}
+	packed := current.ShallowClone()
+	packed.Chunks = []*filer_pb.FileChunk{packedChunk}
+	// the file content is unchanged
+	return fs.filer.CreateEntry(filer.WithoutOperationHooks(ctx), packed, false, false, nil, true)
+}
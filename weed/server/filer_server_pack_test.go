@@ -0,0 +1,138 @@
+package weed_server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/filer/leveldb2"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// newTestFilerServer runs the filer on a local store, without the masters and the volume servers
+func newTestFilerServer(t *testing.T) *FilerServer {
+	f := filer.NewFiler(pb.ServerDiscovery{}, nil, "", "", "", "", "", nil)
+	store := &leveldb.LevelDB2Store{}
+	config := viper.New()
+	config.Set("leveldb2.dir", t.TempDir())
+	if err := store.Initialize(config, "leveldb2."); err != nil {
+		t.Fatalf("initialize the store: %v", err)
+	}
+	f.SetStore(store)
+	t.Cleanup(store.Shutdown)
+	return &FilerServer{
+		filer: f,
+		option: &FilerOption{
+			PackInterval:    time.Minute,
+			PackMaxFileSize: 1024,
+			PackMinFiles:    2,
+		},
+	}
+}
+
+func createTestFile(t *testing.T, fs *FilerServer, p util.FullPath, fileId string, size uint64, mtime time.Time) *filer.Entry {
+	entry := &filer.Entry{
+		FullPath: p,
+		Attr:     filer.Attr{Mode: 0644, Mtime: mtime, Crtime: mtime, FileSize: size},
+		Chunks:   []*filer_pb.FileChunk{{FileId: fileId, Size: size, ModifiedTsNs: mtime.UnixNano()}},
+	}
+	if err := fs.filer.CreateEntry(context.Background(), entry, false, false, nil, false); err != nil {
+		t.Fatalf("create %s: %v", p, err)
+	}
+	return entry
+}
+
+func packReferences(t *testing.T, fs *FilerServer, fileId string) uint64 {
+	value, err := fs.filer.Store.KvGet(context.Background(), []byte("pack."+fileId))
+	if err == filer.ErrKvNotFound {
+		return 0
+	}
+	if err != nil {
+		t.Fatalf("get pack %s references: %v", fileId, err)
+	}
+	return util.BytesToUint64(value[:8])
+}
+
+func TestPackSmallFiles(t *testing.T) {
+	fs := newTestFilerServer(t)
+	ctx := context.Background()
+	old := time.Now().Add(-time.Hour)
+
+	createTestFile(t, fs, "/dir/a", "1,0101", 10, old)
+	createTestFile(t, fs, "/dir/b", "1,0202", 20, old)
+	createTestFile(t, fs, "/dir/c", "1,0303", 30, old)
+	createTestFile(t, fs, "/dir/large", "1,0404", 2048, old)
+	createTestFile(t, fs, "/dir/recent", "1,0505", 10, time.Now())
+
+	olderThan := time.Now().Add(-fs.option.PackInterval)
+	var candidates []*packCandidate
+	var offsets []int64
+	var packSize int64
+	for _, name := range []string{"a", "b", "c", "large", "recent"} {
+		entry, err := fs.filer.FindEntry(ctx, util.FullPath("/dir").Child(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunk := fs.packableChunk(entry, olderThan)
+		if (chunk != nil) != (name != "large" && name != "recent") {
+			t.Errorf("%s packable: %v", name, chunk != nil)
+		}
+		if chunk != nil {
+			candidates = append(candidates, &packCandidate{entry: entry, chunk: chunk})
+			offsets = append(offsets, packSize)
+			packSize += int64(chunk.Size)
+		}
+	}
+
+	// b changes after listed
+	createTestFile(t, fs, "/dir/b", "1,0606", 20, old)
+
+	pack := &filer_pb.FileChunk{FileId: "2,0707", Size: uint64(packSize)}
+	packedCount, err := fs.pointToPack(ctx, pack, uint64(packSize), candidates, offsets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packedCount != 2 {
+		t.Errorf("packed %d files, expected 2", packedCount)
+	}
+	// the reference of b is released
+	if count := packReferences(t, fs, "2,0707"); count != 2 {
+		t.Errorf("pack references %d, expected 2", count)
+	}
+
+	for name, expected := range map[string]struct {
+		fileId     string
+		packOffset int64
+	}{"a": {"2,0707", 0}, "b": {"1,0606", 0}, "c": {"2,0707", 30}} {
+		entry, err := fs.filer.FindEntry(ctx, util.FullPath("/dir").Child(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunk := entry.GetChunks()[0]
+		if chunk.GetFileIdString() != expected.fileId || chunk.PackOffset != expected.packOffset {
+			t.Errorf("%s chunk %s at %d, expected %s at %d", name, chunk.GetFileIdString(), chunk.PackOffset, expected.fileId, expected.packOffset)
+		}
+	}
+
+	// each packed file releases its reference, and the last one removes the references
+	a, _ := fs.filer.FindEntry(ctx, "/dir/a")
+	fs.filer.DeleteChunks(a.GetChunks())
+	if count := packReferences(t, fs, "2,0707"); count != 1 {
+		t.Errorf("pack references %d after releasing a, expected 1", count)
+	}
+	c, _ := fs.filer.FindEntry(ctx, "/dir/c")
+	if isLast, err := fs.filer.ReleasePackedChunk(ctx, c.GetChunks()[0].GetFileIdString()); err != nil || !isLast {
+		t.Errorf("release the last reference: %v %v", isLast, err)
+	}
+	if count := packReferences(t, fs, "2,0707"); count != 0 {
+		t.Errorf("pack references %d after releasing all, expected none", count)
+	}
+	if _, err := fs.filer.ReleasePackedChunk(ctx, "2,0707"); err == nil {
+		t.Errorf("released a chunk without references")
+	}
+}
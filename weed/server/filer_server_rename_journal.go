@@ -0,0 +1,294 @@
+package weed_server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// A directory rename is journaled under renameJournalDir before it starts, and the journal is
+// removed when it completes. Moving an entry creates the new entry before deleting the old one,
+// and a folder is deleted only after all its children are moved, so re-running an interrupted
+// rename moves what is left and completes it. Each filer checks the journals every
+// renameJournalRecoverInterval, re-runs its own interrupted renames, and takes over the journals
+// of a filer which has not updated them for renameJournalStaleAfter.
+// Renaming a single file is one entry and is not journaled.
+const (
+	renameJournalDir             = filer.DirectoryEtcSeaweedFS + "/rename"
+	renameJournalHeartbeat       = time.Minute
+	renameJournalStaleAfter      = 10 * time.Minute
+	renameJournalRecoverInterval = 5 * time.Minute
+	renameProgressRetention      = 10 * time.Minute
+)
+
+type renameJournal struct {
+	OperationId  string  `json:"operationId"`
+	Owner        string  `json:"owner"`
+	OldDirectory string  `json:"oldDirectory"`
+	OldName      string  `json:"oldName"`
+	NewDirectory string  `json:"newDirectory"`
+	NewName      string  `json:"newName"`
+	Signatures   []int32 `json:"signatures,omitempty"`
+	StartTsNs    int64   `json:"startTsNs"`
+	MovedCount   int64   `json:"movedCount"`
+}
+
+type renameOperation struct {
+	journal     *renameJournal
+	isJournaled bool
+	movedCount  int64
+	lock        sync.Mutex
+	isCompleted bool
+	err         error
+	done        chan struct{}
+}
+
+type renameOperationKey struct{}
+
+func (j *renameJournal) oldPath() util.FullPath {
+	return util.FullPath(j.OldDirectory).Child(j.OldName)
+}
+
+func (j *renameJournal) newPath() util.FullPath {
+	return util.FullPath(j.NewDirectory).Child(j.NewName)
+}
+
+// startRenameOperation journals a directory rename before any entry is moved.
+// A file rename is only tracked when the client asks for its progress with an operation id.
+func (fs *FilerServer) startRenameOperation(operationId string, isDirectory bool, oldParent util.FullPath, oldName string, newParent util.FullPath, newName string, signatures []int32) (*renameOperation, error) {
+	isTracked := isDirectory || operationId != ""
+	if operationId == "" {
+		operationId = uuid.New().String()
+	}
+	op := &renameOperation{
+		isJournaled: isDirectory,
+		journal: &renameJournal{
+			OperationId:  operationId,
+			Owner:        string(fs.option.Host),
+			OldDirectory: string(oldParent),
+			OldName:      oldName,
+			NewDirectory: string(newParent),
+			NewName:      newName,
+			Signatures:   signatures,
+			StartTsNs:    time.Now().UnixNano(),
+		},
+		done: make(chan struct{}),
+	}
+	if !isTracked {
+		return op, nil
+	}
+	if _, loaded := fs.renameOperations.LoadOrStore(operationId, op); loaded {
+		return nil, fmt.Errorf("rename operation %s already exists", operationId)
+	}
+	if !isDirectory {
+		return op, nil
+	}
+	if err := fs.saveRenameJournal(op.journal); err != nil {
+		fs.renameOperations.Delete(operationId)
+		return nil, fmt.Errorf("journal rename %s: %v", operationId, err)
+	}
+	go fs.keepRenameJournalAlive(op)
+	return op, nil
+}
+
+// finishRenameOperation removes the journal, unless the rename failed half way and has to be resumed.
+func (fs *FilerServer) finishRenameOperation(op *renameOperation, err error) {
+	ctx := context.Background()
+	op.lock.Lock()
+	op.isCompleted, op.err = err == nil, err
+	op.lock.Unlock()
+	close(op.done)
+	if !op.isJournaled {
+		time.AfterFunc(renameProgressRetention, func() {
+			fs.renameOperations.Delete(op.journal.OperationId)
+		})
+		return
+	}
+
+	keepJournal := false
+	if err != nil {
+		_, oldErr := fs.filer.FindEntry(ctx, op.journal.oldPath())
+		_, newErr := fs.filer.FindEntry(ctx, op.journal.newPath())
+		keepJournal = oldErr == nil && newErr == nil
+	}
+	if keepJournal {
+		glog.Errorf("rename %s => %s is interrupted, and will be resumed from journal %s: %v", op.journal.oldPath(), op.journal.newPath(), op.journal.OperationId, err)
+	} else if deleteErr := fs.filer.DeleteEntryMetaAndData(ctx, util.NewFullPath(renameJournalDir, op.journal.OperationId), false, false, false, false, nil); deleteErr != nil {
+		glog.Errorf("delete rename journal %s: %v", op.journal.OperationId, deleteErr)
+	}
+
+	time.AfterFunc(renameProgressRetention, func() {
+		fs.renameOperations.Delete(op.journal.OperationId)
+	})
+}
+
+func (fs *FilerServer) keepRenameJournalAlive(op *renameOperation) {
+	ticker := time.NewTicker(renameJournalHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-op.done:
+			return
+		case <-ticker.C:
+			op.journal.MovedCount = atomic.LoadInt64(&op.movedCount)
+			if err := fs.saveRenameJournal(op.journal); err != nil {
+				glog.Warningf("update rename journal %s: %v", op.journal.OperationId, err)
+			}
+		}
+	}
+}
+
+func (fs *FilerServer) saveRenameJournal(journal *renameJournal) error {
+	data, err := json.Marshal(journal)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return fs.filer.CreateEntry(context.Background(), &filer.Entry{
+		FullPath: util.NewFullPath(renameJournalDir, journal.OperationId),
+		Attr: filer.Attr{
+			Mtime:    now,
+			Crtime:   now,
+			Mode:     0644,
+			Mime:     "application/json",
+			FileSize: uint64(len(data)),
+		},
+		Content: data,
+	}, false, false, nil, false)
+}
+
+// applyRename moves the journaled entry, and counts the moved entries as the progress.
+func (fs *FilerServer) applyRename(ctx context.Context, op *renameOperation, stream filer_pb.SeaweedFiler_StreamRenameEntryServer) error {
+	journal := op.journal
+	oldParent, newParent := util.FullPath(journal.OldDirectory), util.FullPath(journal.NewDirectory)
+
+	// the hooks are called for the rename, not for each moved entry
+	ctx = filer.WithoutOperationHooks(context.WithValue(ctx, renameOperationKey{}, op))
+	ctx, err := fs.filer.BeginTransaction(ctx)
+	if err != nil {
+		return err
+	}
+
+	oldEntry, err := fs.filer.FindEntry(ctx, oldParent.Child(journal.OldName))
+	if err != nil {
+		fs.filer.RollbackTransaction(ctx)
+		return fmt.Errorf("%s/%s not found: %v", journal.OldDirectory, journal.OldName, err)
+	}
+
+	moveErr := fs.moveEntry(ctx, stream, oldParent, oldEntry, newParent, journal.NewName, journal.Signatures)
+	if moveErr != nil {
+		fs.filer.RollbackTransaction(ctx)
+		return fmt.Errorf("%s/%s move error: %v", journal.OldDirectory, journal.OldName, moveErr)
+	}
+	if commitError := fs.filer.CommitTransaction(ctx); commitError != nil {
+		fs.filer.RollbackTransaction(ctx)
+		return fmt.Errorf("%s/%s move commit error: %v", journal.OldDirectory, journal.OldName, commitError)
+	}
+	return nil
+}
+
+func countMovedEntry(ctx context.Context) {
+	if op, ok := ctx.Value(renameOperationKey{}).(*renameOperation); ok {
+		atomic.AddInt64(&op.movedCount, 1)
+	}
+}
+
+func (fs *FilerServer) loopRecoveringRenameJournals() {
+	for {
+		fs.recoverRenameJournals()
+		time.Sleep(renameJournalRecoverInterval)
+	}
+}
+
+// recoverRenameJournals resumes the renames interrupted by a filer restart or a failure,
+// and the renames of the filers which stopped updating their journals.
+func (fs *FilerServer) recoverRenameJournals() {
+	ctx := context.Background()
+	var journals []*renameJournal
+	_, err := fs.filer.StreamListDirectoryEntries(ctx, renameJournalDir, "", false, 1024, "", "", "", func(entry *filer.Entry) bool {
+		journal := &renameJournal{}
+		if err := json.Unmarshal(entry.Content, journal); err != nil {
+			glog.Errorf("parse rename journal %s: %v", entry.FullPath, err)
+			return true
+		}
+		if journal.Owner == string(fs.option.Host) || time.Since(entry.Mtime) > renameJournalStaleAfter {
+			journals = append(journals, journal)
+		}
+		return true
+	})
+	if err != nil && err != filer_pb.ErrNotFound {
+		glog.Errorf("list rename journals: %v", err)
+		return
+	}
+
+	for _, journal := range journals {
+		glog.V(0).Infof("resume rename %s => %s from journal %s", journal.oldPath(), journal.newPath(), journal.OperationId)
+		journal.Owner = string(fs.option.Host)
+		op := &renameOperation{
+			journal:     journal,
+			isJournaled: true,
+			movedCount:  journal.MovedCount,
+			done:        make(chan struct{}),
+		}
+		if _, loaded := fs.renameOperations.LoadOrStore(journal.OperationId, op); loaded {
+			continue
+		}
+		if err := fs.saveRenameJournal(journal); err != nil {
+			glog.Errorf("take over rename journal %s: %v", journal.OperationId, err)
+			fs.renameOperations.Delete(journal.OperationId)
+			continue
+		}
+		go fs.keepRenameJournalAlive(op)
+		if _, findErr := fs.filer.FindEntry(ctx, journal.oldPath()); findErr == filer_pb.ErrNotFound {
+			// the old entry is already moved
+			fs.finishRenameOperation(op, nil)
+			continue
+		}
+		fs.finishRenameOperation(op, fs.applyRename(ctx, op, nil))
+	}
+}
+
+func (fs *FilerServer) GetRenameProgress(ctx context.Context, req *filer_pb.GetRenameProgressRequest) (*filer_pb.GetRenameProgressResponse, error) {
+
+	if value, found := fs.renameOperations.Load(req.OperationId); found {
+		op := value.(*renameOperation)
+		resp := &filer_pb.GetRenameProgressResponse{
+			OldPath:    string(op.journal.oldPath()),
+			NewPath:    string(op.journal.newPath()),
+			MovedCount: atomic.LoadInt64(&op.movedCount),
+			StartTsNs:  op.journal.StartTsNs,
+		}
+		op.lock.Lock()
+		resp.IsCompleted = op.isCompleted
+		if op.err != nil {
+			resp.Error = op.err.Error()
+		}
+		op.lock.Unlock()
+		return resp, nil
+	}
+
+	// the rename may be running on another filer, or waiting to be resumed
+	entry, err := fs.filer.FindEntry(ctx, util.NewFullPath(renameJournalDir, req.OperationId))
+	if err != nil {
+		return nil, fmt.Errorf("rename operation %s not found", req.OperationId)
+	}
+	journal := &renameJournal{}
+	if err = json.Unmarshal(entry.Content, journal); err != nil {
+		return nil, fmt.Errorf("parse rename journal %s: %v", req.OperationId, err)
+	}
+	return &filer_pb.GetRenameProgressResponse{
+		OldPath:    string(journal.oldPath()),
+		NewPath:    string(journal.newPath()),
+		MovedCount: journal.MovedCount,
+		StartTsNs:  journal.StartTsNs,
+	}, nil
+}
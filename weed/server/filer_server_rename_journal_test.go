@@ -0,0 +1,108 @@
+package weed_server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func createRenameJournal(t *testing.T, fs *FilerServer, journal *renameJournal, mtime time.Time) {
+	data, _ := json.Marshal(journal)
+	if err := fs.filer.CreateEntry(context.Background(), &filer.Entry{
+		FullPath: util.NewFullPath(renameJournalDir, journal.OperationId),
+		Attr:     filer.Attr{Mtime: mtime, Crtime: mtime, Mode: 0644, FileSize: uint64(len(data))},
+		Content:  data,
+	}, false, false, nil, false); err != nil {
+		t.Fatalf("create rename journal %s: %v", journal.OperationId, err)
+	}
+}
+
+func expectEntries(t *testing.T, fs *FilerServer, expected map[util.FullPath]bool) {
+	for p, exists := range expected {
+		if _, err := fs.filer.FindEntry(context.Background(), p); (err == nil) != exists {
+			t.Errorf("%s exists: %v, expected %v", p, err == nil, exists)
+		}
+	}
+}
+
+func TestRenameFileWithoutJournal(t *testing.T) {
+	fs := newTestFilerServer(t)
+	fs.option.Host = "localhost:8888"
+	createTestFile(t, fs, "/dir/a", "1,0101", 10, time.Now())
+
+	resp, err := fs.AtomicRenameEntry(context.Background(), &filer_pb.AtomicRenameEntryRequest{OldDirectory: "/dir", OldName: "a", NewDirectory: "/dir", NewName: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEntries(t, fs, map[util.FullPath]bool{"/dir/a": false, "/dir/b": true, renameJournalDir: false})
+	if _, found := fs.renameOperations.Load(resp.OperationId); found {
+		t.Errorf("tracked the file rename %s", resp.OperationId)
+	}
+
+	// the client asks for the progress
+	createTestDirectory(t, fs, "/src")
+	createTestFile(t, fs, "/src/c", "1,0202", 10, time.Now())
+	if _, err = fs.AtomicRenameEntry(context.Background(), &filer_pb.AtomicRenameEntryRequest{OldDirectory: "/", OldName: "src", NewDirectory: "/", NewName: "dst", OperationId: "op1"}); err != nil {
+		t.Fatal(err)
+	}
+	progress, err := fs.GetRenameProgress(context.Background(), &filer_pb.GetRenameProgressRequest{OperationId: "op1"})
+	if err != nil || !progress.IsCompleted || progress.MovedCount != 2 {
+		t.Errorf("progress %+v: %v", progress, err)
+	}
+	expectEntries(t, fs, map[util.FullPath]bool{"/src": false, "/dst/c": true, renameJournalDir + "/op1": false})
+}
+
+func TestRecoverRenameJournals(t *testing.T) {
+	fs := newTestFilerServer(t)
+	fs.option.Host = "localhost:8888"
+	old := time.Now().Add(-time.Hour)
+
+	// the rename of /src to /dst was interrupted after moving a and sub/b
+	createTestFile(t, fs, "/dst/a", "1,0101", 10, old)
+	createTestFile(t, fs, "/dst/sub/b", "1,0202", 10, old)
+	createTestFile(t, fs, "/src/sub/c", "1,0303", 10, old)
+	createTestFile(t, fs, "/src/z", "1,0404", 10, old)
+	createRenameJournal(t, fs, &renameJournal{OperationId: "stale", Owner: "localhost:8889", OldDirectory: "/", OldName: "src", NewDirectory: "/", NewName: "dst", MovedCount: 2}, old)
+
+	// another filer is still renaming /busy
+	createTestFile(t, fs, "/busy/d", "1,0505", 10, old)
+	createRenameJournal(t, fs, &renameJournal{OperationId: "running", Owner: "localhost:8889", OldDirectory: "/", OldName: "busy", NewDirectory: "/", NewName: "moved"}, time.Now())
+
+	// the old entry of this rename is already moved
+	createTestFile(t, fs, "/done/e", "1,0606", 10, old)
+	createRenameJournal(t, fs, &renameJournal{OperationId: "moved", Owner: "localhost:8888", OldDirectory: "/", OldName: "gone", NewDirectory: "/", NewName: "done"}, time.Now())
+
+	fs.recoverRenameJournals()
+
+	expectEntries(t, fs, map[util.FullPath]bool{
+		"/src":                        false,
+		"/dst/a":                      true,
+		"/dst/sub/b":                  true,
+		"/dst/sub/c":                  true,
+		"/dst/z":                      true,
+		"/busy/d":                     true,
+		"/moved":                      false,
+		"/done/e":                     true,
+		renameJournalDir + "/stale":   false,
+		renameJournalDir + "/running": true,
+		renameJournalDir + "/moved":   false,
+	})
+	// sub/c, sub, z and src are moved after the 2 journaled ones
+	progress, err := fs.GetRenameProgress(context.Background(), &filer_pb.GetRenameProgressRequest{OperationId: "stale"})
+	if err != nil || !progress.IsCompleted || progress.MovedCount != 6 {
+		t.Errorf("resumed progress %+v: %v", progress, err)
+	}
+
+	// recovering again changes nothing
+	fs.renameOperations.Delete("stale")
+	fs.recoverRenameJournals()
+	expectEntries(t, fs, map[util.FullPath]bool{"/dst/sub/c": true, "/dst/z": true, "/busy/d": true, renameJournalDir + "/running": true})
+	if _, found := fs.renameOperations.Load("stale"); found {
+		t.Errorf("resumed the completed rename again")
+	}
+}
@@ -71,6 +71,7 @@ func (ms *MasterServer) SendHeartbeat(stream master_pb.Seaweed_SendHeartbeatServ
 
 			message := &master_pb.VolumeLocation{
 				DataCenter: dn.GetDataCenterId(),
+				Rack:       dn.GetRackId(),
 				Url:        dn.Url(),
 				PublicUrl:  dn.PublicUrl,
 			}
@@ -81,6 +82,11 @@ func (ms *MasterServer) SendHeartbeat(stream master_pb.Seaweed_SendHeartbeatServ
 				message.DeletedVids = append(message.DeletedVids, uint32(s.VolumeId))
 			}
 
+			ms.rememberOfflineMaintenanceVolumes(dn)
+			if ms.isTopologyObserved() {
+				ms.publishTopologyEvent(newNodeEvent(master_pb.TopologyEvent_NODE_DOWN, dn))
+			}
+
 			// if the volume server disconnects and reconnects quickly
 			//  the unregister and register can race with each other
 			ms.Topo.UnRegisterDataNode(dn)
@@ -155,8 +161,12 @@ func (ms *MasterServer) SendHeartbeat(stream master_pb.Seaweed_SendHeartbeatServ
 			}
 			stats.MasterReceivedHeartbeatCounter.WithLabelValues("dataNode").Inc()
 			dn.Counter++
+			if dn.Counter == 1 && ms.isTopologyObserved() {
+				ms.publishTopologyEvent(newNodeEvent(master_pb.TopologyEvent_NODE_UP, dn))
+			}
 		}
 
+		watch := ms.startTopologyWatch(dn)
 		dn.AdjustMaxVolumeCounts(heartbeat.MaxVolumeCounts)
 
 		glog.V(4).Infof("master received heartbeat %s", heartbeat.String())
@@ -166,6 +176,7 @@ func (ms *MasterServer) SendHeartbeat(stream master_pb.Seaweed_SendHeartbeatServ
 			Url:        dn.Url(),
 			PublicUrl:  dn.PublicUrl,
 			DataCenter: dn.GetDataCenterId(),
+			Rack:       dn.GetRackId(),
 		}
 		if len(heartbeat.NewVolumes) > 0 {
 			stats.FilerRequestCounter.WithLabelValues("newVolumes").Inc()
@@ -183,17 +194,29 @@ func (ms *MasterServer) SendHeartbeat(stream master_pb.Seaweed_SendHeartbeatServ
 			}
 			// update master internal volume layouts
 			ms.Topo.IncrementalSyncDataNodeRegistration(heartbeat.NewVolumes, heartbeat.DeletedVolumes, dn)
+			watch.addShortVolumes(heartbeat.NewVolumes, false)
+			watch.addShortVolumes(heartbeat.DeletedVolumes, true)
 		}
 
 		if len(heartbeat.Volumes) > 0 || heartbeat.HasNoVolumes {
+			// only the full heartbeats tell whether the volume server is draining, and its tags
+			dn.SetDraining(heartbeat.IsDraining)
+			dn.SetTags(heartbeat.Tags)
+			dn.WriteLatency = time.Duration(heartbeat.WriteLatencyUs) * time.Microsecond
+			dn.InFlightWrites = int64(heartbeat.InFlightWrites)
+			dn.RequestRates = topology.ToRequestRates(heartbeat.RequestLoad)
+			dn.Weight = heartbeat.Weight
 			if heartbeat.Ip != "" {
 				dcName, rackName := ms.Topo.Configuration.Locate(heartbeat.Ip, heartbeat.DataCenter, heartbeat.Rack)
 				ms.Topo.DataNodeRegistration(dcName, rackName, dn)
 			}
+			ms.applyMaintenance(dn)
 
 			// process heartbeat.Volumes
 			stats.MasterReceivedHeartbeatCounter.WithLabelValues("Volumes").Inc()
 			newVolumes, deletedVolumes := ms.Topo.SyncDataNodeRegistration(heartbeat.Volumes, dn)
+			watch.addVolumes(newVolumes)
+			watch.deleteVolumes(deletedVolumes)
 
 			for _, v := range newVolumes {
 				glog.V(0).Infof("master see new volume %d from %s", uint32(v.Id), dn.Url())
@@ -212,8 +235,10 @@ func (ms *MasterServer) SendHeartbeat(stream master_pb.Seaweed_SendHeartbeatServ
 
 			for _, s := range heartbeat.NewEcShards {
 				message.NewEcVids = append(message.NewEcVids, s.Id)
+				watch.changeEcShards(needle.VolumeId(s.Id))
 			}
 			for _, s := range heartbeat.DeletedEcShards {
+				watch.changeEcShards(needle.VolumeId(s.Id))
 				if dn.HasEcShards(needle.VolumeId(s.Id)) {
 					continue
 				}
@@ -230,8 +255,10 @@ func (ms *MasterServer) SendHeartbeat(stream master_pb.Seaweed_SendHeartbeatServ
 			// broadcast the ec vid changes to master clients
 			for _, s := range newShards {
 				message.NewEcVids = append(message.NewEcVids, uint32(s.VolumeId))
+				watch.changeEcShards(s.VolumeId)
 			}
 			for _, s := range deletedShards {
+				watch.changeEcShards(s.VolumeId)
 				if dn.HasVolumesById(s.VolumeId) {
 					continue
 				}
@@ -242,6 +269,7 @@ func (ms *MasterServer) SendHeartbeat(stream master_pb.Seaweed_SendHeartbeatServ
 		if len(message.NewVids) > 0 || len(message.DeletedVids) > 0 || len(message.NewEcVids) > 0 || len(message.DeletedEcVids) > 0 {
 			ms.broadcastToClients(&master_pb.KeepConnectedResponse{VolumeLocation: message})
 		}
+		watch.publish()
 	}
 }
 
@@ -403,6 +431,11 @@ func (ms *MasterServer) GetMasterConfiguration(ctx context.Context, req *master_
 		VolumeSizeLimitMB:      uint32(ms.option.VolumeSizeLimitMB),
 		VolumePreallocate:      ms.option.VolumePreallocate,
 		Leader:                 string(leader),
+		Version:                util.Version(),
+	}
+	if r := ms.option.VolumeIdRange; r != nil {
+		resp.VolumeIdRangeStart = uint32(r.Start)
+		resp.VolumeIdRangeEnd = uint32(r.End)
 	}
 
 	return resp, nil
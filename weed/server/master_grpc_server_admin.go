@@ -143,7 +143,10 @@ func (ms *MasterServer) LeaseAdminToken(ctx context.Context, req *master_pb.Leas
 		return resp, fmt.Errorf("already locked by %v: %v", lastClient, lastMessage)
 	}
 	// for fresh lease request
-	ts, token := ms.adminLocks.generateToken(req.LockName, req.ClientName)
+	ts, token, err := ms.leaseFreshAdminToken(req.LockName, req.ClientName)
+	if err != nil {
+		return resp, err
+	}
 	resp.Token, resp.LockTsNs = token, ts.UnixNano()
 	return resp, nil
 }
@@ -12,7 +12,6 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/security"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
-	"github.com/seaweedfs/seaweedfs/weed/storage/types"
 	"github.com/seaweedfs/seaweedfs/weed/topology"
 )
 
@@ -23,7 +22,12 @@ func (ms *MasterServer) StreamAssign(server master_pb.Seaweed_StreamAssignServer
 			glog.Errorf("StreamAssign failed to receive: %v", err)
 			return err
 		}
+		release, err := ms.admit(server.Context(), clientOfGrpcContext(server.Context()))
+		if err != nil {
+			return toAdmissionGrpcError(server.Context(), err)
+		}
 		resp, err := ms.Assign(context.Background(), req)
+		release()
 		if err != nil {
 			glog.Errorf("StreamAssign failed to assign: %v", err)
 			return err
@@ -44,9 +48,7 @@ func (ms *MasterServer) Assign(ctx context.Context, req *master_pb.AssignRequest
 		req.Count = 1
 	}
 
-	if req.Replication == "" {
-		req.Replication = ms.option.DefaultReplicaPlacement
-	}
+	req.Replication = ms.replicationOf(req.Collection, req.Replication)
 	replicaPlacement, err := super_block.NewReplicaPlacementFromString(req.Replication)
 	if err != nil {
 		return nil, err
@@ -55,7 +57,14 @@ func (ms *MasterServer) Assign(ctx context.Context, req *master_pb.AssignRequest
 	if err != nil {
 		return nil, err
 	}
-	diskType := types.ToDiskType(req.DiskType)
+	if err := ms.Topo.CheckCollectionQuotaForWrite(req.Collection); err != nil {
+		return nil, err
+	}
+	diskType := ms.Topo.GrowDiskType(req.Collection, req.DiskType)
+	placement, err := ms.placementOf(req.Collection, req.Placement)
+	if err != nil {
+		return nil, err
+	}
 
 	option := &topology.VolumeGrowOption{
 		Collection:         req.Collection,
@@ -67,6 +76,7 @@ func (ms *MasterServer) Assign(ctx context.Context, req *master_pb.AssignRequest
 		Rack:               req.Rack,
 		DataNode:           req.DataNode,
 		MemoryMapMaxSizeMb: req.MemoryMapMaxSizeMb,
+		Placement:          placement,
 	}
 
 	vl := ms.Topo.GetVolumeLayout(option.Collection, option.ReplicaPlacement, option.Ttl, option.DiskType)
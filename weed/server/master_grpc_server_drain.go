@@ -0,0 +1,100 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/seaweedfs/raft"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// DrainVolumeServer asks the volume server to stop accepting writes, and starts moving its volumes
+// and ec shards to the other volume servers. The volume server exits once it is empty.
+func (ms *MasterServer) DrainVolumeServer(ctx context.Context, req *master_pb.DrainVolumeServerRequest) (*master_pb.DrainVolumeServerResponse, error) {
+
+	if !ms.Topo.IsLeader() {
+		return nil, raft.NotLeaderError
+	}
+
+	dn := findDataNode(ms.Topo.ListDataNodes(), req.Node)
+	if dn == nil {
+		return nil, fmt.Errorf("volume server %s not found", req.Node)
+	}
+
+	if err := ms.askVolumeServerToDrain(dn, req.Cancel); err != nil {
+		return nil, err
+	}
+
+	ms.drainsLock.Lock()
+	defer ms.drainsLock.Unlock()
+	if req.Cancel {
+		glog.V(0).Infof("cancel draining volume server %s", dn.Url())
+		dn.SetDraining(false)
+		delete(ms.drains, dn.Url())
+	} else if d, found := ms.drains[dn.Url()]; !found || d.isDone {
+		glog.V(0).Infof("start draining volume server %s", dn.Url())
+		dn.SetDraining(true)
+		ms.drains[dn.Url()] = newVolumeServerDrain(dn.Url())
+	}
+
+	return &master_pb.DrainVolumeServerResponse{}, nil
+}
+
+// ListVolumeServerDrains reports the progress of the draining volume servers
+func (ms *MasterServer) ListVolumeServerDrains(ctx context.Context, req *master_pb.ListVolumeServerDrainsRequest) (*master_pb.ListVolumeServerDrainsResponse, error) {
+
+	if !ms.Topo.IsLeader() {
+		return nil, raft.NotLeaderError
+	}
+
+	resp := &master_pb.ListVolumeServerDrainsResponse{}
+
+	ms.drainsLock.Lock()
+	for _, d := range ms.drains {
+		resp.Drains = append(resp.Drains, &master_pb.ListVolumeServerDrainsResponse_Drain{
+			Node:                  d.node,
+			StartedAtNs:           d.startedAt.UnixNano(),
+			MovedVolumeCount:      uint32(len(d.movedVolumes)),
+			MovedEcShardCount:     uint32(len(d.movedEcShards)),
+			RemainingVolumeCount:  uint32(d.remainingVolumes),
+			RemainingEcShardCount: uint32(d.remainingEcShards),
+			CurrentMove:           d.currentMove,
+			LastError:             d.lastError,
+			IsDone:                d.isDone,
+		})
+	}
+	ms.drainsLock.Unlock()
+
+	sort.Slice(resp.Drains, func(i, j int) bool {
+		return resp.Drains[i].Node < resp.Drains[j].Node
+	})
+
+	return resp, nil
+}
+
+func (ms *MasterServer) askVolumeServerToDrain(dn *topology.DataNode, cancel bool) error {
+	return operation.WithVolumeServerClient(false, dn.ServerAddress(), ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		_, err := client.VolumeServerDrain(context.Background(), &volume_server_pb.VolumeServerDrainRequest{
+			Cancel: cancel,
+		})
+		if err != nil {
+			return fmt.Errorf("ask volume server %s to drain: %v", dn.Url(), err)
+		}
+		return nil
+	})
+}
+
+func findDataNode(dataNodes []*topology.DataNode, node string) *topology.DataNode {
+	for _, dn := range dataNodes {
+		if dn.Url() == node || string(dn.Id()) == node {
+			return dn
+		}
+	}
+	return nil
+}
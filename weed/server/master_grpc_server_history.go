@@ -0,0 +1,52 @@
+package weed_server
+
+import (
+	"context"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+// ListTopologyHistory lists the recorded topology changes, merged across the masters unless local
+func (ms *MasterServer) ListTopologyHistory(ctx context.Context, req *master_pb.ListTopologyHistoryRequest) (*master_pb.ListTopologyHistoryResponse, error) {
+	resp := &master_pb.ListTopologyHistoryResponse{}
+	if ms.history != nil {
+		changes, err := ms.history.list(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Changes = changes
+	}
+	if req.Local {
+		return resp, nil
+	}
+
+	peerReq := proto.Clone(req).(*master_pb.ListTopologyHistoryRequest)
+	peerReq.Local = true
+	for _, master := range ms.MasterClient.GetMasters() {
+		if master == ms.option.Master {
+			continue
+		}
+		err := pb.WithMasterClient(false, master, ms.grpcDialOption, false, func(client master_pb.SeaweedClient) error {
+			peerResp, err := client.ListTopologyHistory(ctx, peerReq)
+			if err != nil {
+				return err
+			}
+			resp.Changes = append(resp.Changes, peerResp.Changes...)
+			return nil
+		})
+		if err != nil {
+			glog.V(0).Infof("list the topology history on master %s: %v", master, err)
+			resp.UnreachableMasters = append(resp.UnreachableMasters, string(master))
+		}
+	}
+	sort.SliceStable(resp.Changes, func(i, j int) bool {
+		return resp.Changes[i].TsNs < resp.Changes[j].TsNs
+	})
+	resp.Changes = limitTopologyChanges(resp.Changes, req.Limit)
+	return resp, nil
+}
@@ -0,0 +1,37 @@
+package weed_server
+
+import (
+	"context"
+	"time"
+
+	"github.com/seaweedfs/raft"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+// ListLifecycleActions reports the lifecycle actions due now, as a dry run, and the recently applied ones
+func (ms *MasterServer) ListLifecycleActions(ctx context.Context, req *master_pb.ListLifecycleActionsRequest) (*master_pb.ListLifecycleActionsResponse, error) {
+
+	if !ms.Topo.IsLeader() {
+		return nil, raft.NotLeaderError
+	}
+
+	resp := &master_pb.ListLifecycleActionsResponse{
+		DryRun: ms.option.LifecycleDryRun,
+	}
+	for _, a := range planLifecycleActions(ms.option.LifecycleCollections, ms.Topo.ListDataNodes(), time.Now()) {
+		if req.Collection == "" || a.volume.Collection == req.Collection {
+			resp.Planned = append(resp.Planned, a.toMessage())
+		}
+	}
+
+	ms.lifecycleLock.Lock()
+	for _, applied := range ms.lifecycleApplied {
+		if req.Collection == "" || applied.Collection == req.Collection {
+			resp.Applied = append(resp.Applied, applied)
+		}
+	}
+	ms.lifecycleLock.Unlock()
+
+	return resp, nil
+}
@@ -0,0 +1,19 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/seaweedfs/raft"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+// GetClusterLoad reports the capacity and the request rates by data center, rack, and volume server
+func (ms *MasterServer) GetClusterLoad(ctx context.Context, req *master_pb.GetClusterLoadRequest) (*master_pb.GetClusterLoadResponse, error) {
+
+	if !ms.Topo.IsLeader() {
+		return nil, raft.NotLeaderError
+	}
+
+	return toClusterLoad(ms.Topo.ListDataNodes(), uint64(ms.option.VolumeSizeLimitMB)*1024*1024), nil
+}
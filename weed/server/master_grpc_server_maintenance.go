@@ -0,0 +1,117 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/seaweedfs/raft"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+// SetMaintenance puts a volume server or a whole rack in or out of the maintenance
+func (ms *MasterServer) SetMaintenance(ctx context.Context, req *master_pb.SetMaintenanceRequest) (*master_pb.SetMaintenanceResponse, error) {
+
+	if !ms.Topo.IsLeader() {
+		return nil, raft.NotLeaderError
+	}
+
+	if req.Node == "" && req.Rack == "" {
+		return nil, fmt.Errorf("neither the volume server nor the rack is set")
+	}
+	dataNodes := ms.Topo.ListDataNodes()
+	key := maintenanceKey(req.Node, req.DataCenter, req.Rack)
+
+	ms.maintenancesLock.Lock()
+	m, found := ms.maintenances[key]
+	if req.Cancel {
+		if !found {
+			ms.maintenancesLock.Unlock()
+			return nil, fmt.Errorf("%s is not in maintenance", key)
+		}
+		glog.V(0).Infof("end the maintenance of %s", key)
+		delete(ms.maintenances, key)
+	} else {
+		if !found {
+			m = &maintenance{
+				node:           req.Node,
+				dataCenter:     req.DataCenter,
+				rack:           req.Rack,
+				startedAt:      time.Now(),
+				offlineVolumes: make(map[string][]needle.VolumeId),
+			}
+			if !m.coversAny(dataNodes) {
+				ms.maintenancesLock.Unlock()
+				return nil, fmt.Errorf("%s not found", key)
+			}
+			ms.maintenances[key] = m
+		}
+		// set again to extend the grace period
+		m.reason = req.Reason
+		m.graceUntil = time.Now().Add(time.Duration(req.GracePeriodSeconds) * time.Second)
+		glog.V(0).Infof("start the maintenance of %s until %v: %s", key, m.graceUntil, m.reason)
+	}
+	ms.maintenancesLock.Unlock()
+
+	for _, dn := range dataNodes {
+		ms.applyMaintenance(dn)
+	}
+
+	return &master_pb.SetMaintenanceResponse{}, nil
+}
+
+// ListMaintenances reports the volume servers and racks in maintenance, with the volumes whose re-replication is deferred
+func (ms *MasterServer) ListMaintenances(ctx context.Context, req *master_pb.ListMaintenancesRequest) (*master_pb.ListMaintenancesResponse, error) {
+
+	if !ms.Topo.IsLeader() {
+		return nil, raft.NotLeaderError
+	}
+
+	resp := &master_pb.ListMaintenancesResponse{}
+	dataNodes := ms.Topo.ListDataNodes()
+	now := time.Now()
+
+	ms.maintenancesLock.Lock()
+	for _, m := range ms.maintenances {
+		r := &master_pb.ListMaintenancesResponse_Maintenance{
+			Node:         m.node,
+			DataCenter:   m.dataCenter,
+			Rack:         m.rack,
+			Reason:       m.reason,
+			StartedAtNs:  m.startedAt.UnixNano(),
+			GraceUntilNs: m.graceUntil.UnixNano(),
+		}
+		for _, dn := range dataNodes {
+			if m.covers(dn) {
+				r.OnlineNodes = append(r.OnlineNodes, dn.Url())
+			}
+		}
+		for node, vids := range m.offlineVolumes {
+			r.OfflineNodes = append(r.OfflineNodes, node)
+			if !m.isInGracePeriod(now) {
+				continue
+			}
+			for _, vid := range vids {
+				r.DeferredVolumeIds = append(r.DeferredVolumeIds, uint32(vid))
+			}
+		}
+		sort.Strings(r.OnlineNodes)
+		sort.Strings(r.OfflineNodes)
+		sort.Slice(r.DeferredVolumeIds, func(i, j int) bool {
+			return r.DeferredVolumeIds[i] < r.DeferredVolumeIds[j]
+		})
+		resp.Maintenances = append(resp.Maintenances, r)
+	}
+	ms.maintenancesLock.Unlock()
+
+	sort.Slice(resp.Maintenances, func(i, j int) bool {
+		a, b := resp.Maintenances[i], resp.Maintenances[j]
+		return maintenanceKey(a.Node, a.DataCenter, a.Rack) < maintenanceKey(b.Node, b.DataCenter, b.Rack)
+	})
+
+	return resp, nil
+}
@@ -0,0 +1,49 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seaweedfs/raft"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+func (ms *MasterServer) SetCollectionQuota(ctx context.Context, req *master_pb.SetCollectionQuotaRequest) (*master_pb.SetCollectionQuotaResponse, error) {
+	if !ms.Topo.IsLeader() {
+		return nil, raft.NotLeaderError
+	}
+	if req.Quota == nil || req.Quota.Collection == "" {
+		return nil, fmt.Errorf("missing the collection")
+	}
+	if req.Delete {
+		glog.V(0).Infof("delete the quota of collection %s", req.Quota.Collection)
+		ms.Topo.SetCollectionQuota(req.Quota.Collection, nil)
+		return &master_pb.SetCollectionQuotaResponse{}, nil
+	}
+	if req.Quota.WarnPercent < 0 || req.Quota.WarnPercent > 100 {
+		return nil, fmt.Errorf("warn percent %d not within [0, 100]", req.Quota.WarnPercent)
+	}
+	quota := &topology.CollectionQuota{
+		MaxVolumeCount:  int(req.Quota.MaxVolumeCount),
+		MaxLogicalBytes: req.Quota.MaxLogicalBytes,
+		WarnPercent:     int(req.Quota.WarnPercent),
+	}
+	glog.V(0).Infof("set the quota of collection %s: %+v", req.Quota.Collection, *quota)
+	ms.Topo.SetCollectionQuota(req.Quota.Collection, quota)
+	ms.refreshCollectionQuotas()
+	return &master_pb.SetCollectionQuotaResponse{}, nil
+}
+
+func (ms *MasterServer) ListCollectionQuotas(ctx context.Context, req *master_pb.ListCollectionQuotasRequest) (*master_pb.ListCollectionQuotasResponse, error) {
+	if !ms.Topo.IsLeader() {
+		return nil, raft.NotLeaderError
+	}
+	resp := &master_pb.ListCollectionQuotasResponse{}
+	for _, usage := range ms.Topo.ListCollectionQuotas() {
+		resp.Quotas = append(resp.Quotas, toCollectionQuotaMessage(usage))
+	}
+	return resp, nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/hashicorp/raft"
 
 	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
 )
 
@@ -90,3 +91,173 @@ func (ms *MasterServer) RaftRemoveServer(ctx context.Context, req *master_pb.Raf
 	}
 	return resp, nil
 }
+
+// RaftPromoteServer turns a non-voting learner into a voter
+func (ms *MasterServer) RaftPromoteServer(ctx context.Context, req *master_pb.RaftPromoteServerRequest) (*master_pb.RaftPromoteServerResponse, error) {
+	voterCount, err := ms.changeRaftSuffrage(req.Id, raft.Voter, req.Force)
+	if err != nil {
+		return nil, err
+	}
+	return &master_pb.RaftPromoteServerResponse{VoterCount: int32(voterCount)}, nil
+}
+
+// RaftDemoteServer turns a voter into a non-voting learner, still replicating the state
+func (ms *MasterServer) RaftDemoteServer(ctx context.Context, req *master_pb.RaftDemoteServerRequest) (*master_pb.RaftDemoteServerResponse, error) {
+	voterCount, err := ms.changeRaftSuffrage(req.Id, raft.Nonvoter, req.Force)
+	if err != nil {
+		return nil, err
+	}
+	return &master_pb.RaftDemoteServerResponse{VoterCount: int32(voterCount)}, nil
+}
+
+// RaftTransferLeadership hands the leadership over to the chosen voter, or to any up-to-date voter,
+// e.g. before restarting the leader in a rolling upgrade
+func (ms *MasterServer) RaftTransferLeadership(ctx context.Context, req *master_pb.RaftTransferLeadershipRequest) (*master_pb.RaftTransferLeadershipResponse, error) {
+	ms.Topo.RaftServerAccessLock.RLock()
+	defer ms.Topo.RaftServerAccessLock.RUnlock()
+
+	if ms.Topo.HashicorpRaft == nil {
+		return nil, fmt.Errorf("raft transfer leadership: only supported with -raftHashicorp")
+	}
+	if ms.Topo.HashicorpRaft.State() != raft.Leader {
+		return nil, fmt.Errorf("raft transfer leadership failed: %s is no current leader", ms.Topo.HashicorpRaft.String())
+	}
+
+	var future raft.Future
+	if req.Id == "" {
+		future = ms.Topo.HashicorpRaft.LeadershipTransfer()
+	} else {
+		configuration := ms.Topo.HashicorpRaft.GetConfiguration()
+		if err := configuration.Error(); err != nil {
+			return nil, err
+		}
+		_, leaderId := ms.Topo.HashicorpRaft.LeaderWithID()
+		target, err := checkLeadershipTransfer(configuration.Configuration().Servers, leaderId, raft.ServerID(req.Id), ms.isRaftServerConnected(leaderId))
+		if err != nil {
+			return nil, fmt.Errorf("raft transfer leadership to %s: %v", req.Id, err)
+		}
+		future = ms.Topo.HashicorpRaft.LeadershipTransferToServer(target.ID, target.Address)
+	}
+	if err := future.Error(); err != nil {
+		return nil, fmt.Errorf("raft transfer leadership: %v", err)
+	}
+	_, leaderId := ms.Topo.HashicorpRaft.LeaderWithID()
+	glog.V(0).Infof("raft transferred the leadership to %s", leaderId)
+	return &master_pb.RaftTransferLeadershipResponse{Leader: string(leaderId)}, nil
+}
+
+func (ms *MasterServer) changeRaftSuffrage(id string, suffrage raft.ServerSuffrage, force bool) (voterCount int, err error) {
+	ms.Topo.RaftServerAccessLock.RLock()
+	defer ms.Topo.RaftServerAccessLock.RUnlock()
+
+	if ms.Topo.HashicorpRaft == nil {
+		return 0, fmt.Errorf("raft change %s to %s: only supported with -raftHashicorp", id, suffrage)
+	}
+	if ms.Topo.HashicorpRaft.State() != raft.Leader {
+		return 0, fmt.Errorf("raft change %s to %s failed: %s is no current leader", id, suffrage, ms.Topo.HashicorpRaft.String())
+	}
+
+	configuration := ms.Topo.HashicorpRaft.GetConfiguration()
+	if err = configuration.Error(); err != nil {
+		return 0, err
+	}
+	_, leaderId := ms.Topo.HashicorpRaft.LeaderWithID()
+	servers := configuration.Configuration().Servers
+	voterCount, err = checkSuffrageChange(servers, leaderId, raft.ServerID(id), suffrage, ms.isRaftServerConnected(leaderId))
+	if err != nil && !force {
+		return 0, fmt.Errorf("raft change %s to %s: %v", id, suffrage, err)
+	}
+
+	var idxFuture raft.IndexFuture
+	if suffrage == raft.Voter {
+		for _, server := range servers {
+			if string(server.ID) == id {
+				idxFuture = ms.Topo.HashicorpRaft.AddVoter(server.ID, server.Address, 0, 0)
+			}
+		}
+	} else {
+		idxFuture = ms.Topo.HashicorpRaft.DemoteVoter(raft.ServerID(id), 0, 0)
+	}
+	if idxFuture == nil {
+		return 0, fmt.Errorf("raft change %s to %s: unknown server", id, suffrage)
+	}
+	if err = idxFuture.Error(); err != nil {
+		return 0, err
+	}
+	glog.V(0).Infof("raft changed %s to %s, with %d voters", id, suffrage, voterCount)
+	return voterCount, nil
+}
+
+// isRaftServerConnected checks whether the master keeps a connection to the leader
+func (ms *MasterServer) isRaftServerConnected(leaderId raft.ServerID) func(raft.ServerID) bool {
+	return func(serverId raft.ServerID) bool {
+		if serverId == leaderId {
+			return true
+		}
+		ms.clientChansLock.RLock()
+		defer ms.clientChansLock.RUnlock()
+		_, found := ms.clientChans[fmt.Sprintf("%s@%s", cluster.MasterType, serverId)]
+		return found
+	}
+}
+
+// checkSuffrageChange counts the voters after the change, and checks the connected voters still make a quorum.
+// The leader is not demoted, to keep the leadership while changing; transfer the leadership first.
+func checkSuffrageChange(servers []raft.Server, leaderId, id raft.ServerID, suffrage raft.ServerSuffrage, isConnected func(raft.ServerID) bool) (voterCount int, err error) {
+	var target *raft.Server
+	for i := range servers {
+		if servers[i].ID == id {
+			target = &servers[i]
+		}
+	}
+	if target == nil {
+		return 0, fmt.Errorf("unknown server")
+	}
+	if target.Suffrage == suffrage {
+		return 0, fmt.Errorf("already a %s", suffrage)
+	}
+	if suffrage == raft.Voter && !isConnected(id) {
+		return 0, fmt.Errorf("not connected to the leader")
+	}
+	if suffrage != raft.Voter && id == leaderId {
+		return 0, fmt.Errorf("is the leader, transfer the leadership first")
+	}
+
+	connectedVoterCount := 0
+	for _, server := range servers {
+		isVoter := server.Suffrage == raft.Voter
+		if server.ID == id {
+			isVoter = suffrage == raft.Voter
+		}
+		if !isVoter {
+			continue
+		}
+		voterCount++
+		if isConnected(server.ID) {
+			connectedVoterCount++
+		}
+	}
+	if connectedVoterCount <= voterCount/2 {
+		return voterCount, fmt.Errorf("only %d of the %d voters would be connected, not a quorum", connectedVoterCount, voterCount)
+	}
+	return voterCount, nil
+}
+
+// checkLeadershipTransfer checks the leadership can go to the server: a connected voter other than the leader
+func checkLeadershipTransfer(servers []raft.Server, leaderId, id raft.ServerID, isConnected func(raft.ServerID) bool) (*raft.Server, error) {
+	for i := range servers {
+		if servers[i].ID != id {
+			continue
+		}
+		switch {
+		case id == leaderId:
+			return nil, fmt.Errorf("already the leader")
+		case servers[i].Suffrage != raft.Voter:
+			return nil, fmt.Errorf("not a voter, promote it first")
+		case !isConnected(id):
+			return nil, fmt.Errorf("not connected to the leader")
+		}
+		return &servers[i], nil
+	}
+	return nil, fmt.Errorf("unknown server")
+}
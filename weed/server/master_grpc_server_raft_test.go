@@ -0,0 +1,68 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/hashicorp/raft"
+)
+
+func TestCheckSuffrageChange(t *testing.T) {
+	servers := []raft.Server{
+		{ID: "m1", Suffrage: raft.Voter},
+		{ID: "m2", Suffrage: raft.Voter},
+		{ID: "m3", Suffrage: raft.Voter},
+		{ID: "m4", Suffrage: raft.Nonvoter},
+		{ID: "m5", Suffrage: raft.Nonvoter},
+	}
+	connected := map[raft.ServerID]bool{"m1": true, "m2": true, "m3": true, "m4": true}
+	isConnected := func(id raft.ServerID) bool {
+		return connected[id]
+	}
+
+	if voterCount, err := checkSuffrageChange(servers, "m1", "m4", raft.Voter, isConnected); err != nil || voterCount != 4 {
+		t.Errorf("promote m4: %d voters, %v", voterCount, err)
+	}
+	if _, err := checkSuffrageChange(servers, "m1", "m5", raft.Voter, isConnected); err == nil {
+		t.Errorf("promoted the disconnected m5")
+	}
+	if _, err := checkSuffrageChange(servers, "m1", "m1", raft.Nonvoter, isConnected); err == nil {
+		t.Errorf("demoted the leader")
+	}
+	if _, err := checkSuffrageChange(servers, "m1", "m4", raft.Nonvoter, isConnected); err == nil {
+		t.Errorf("demoted the learner")
+	}
+	if voterCount, err := checkSuffrageChange(servers, "m1", "m3", raft.Nonvoter, isConnected); err != nil || voterCount != 2 {
+		t.Errorf("demote m3: %d voters, %v", voterCount, err)
+	}
+
+	// with m2 down, demoting m3 leaves only the leader connected out of 2 voters
+	connected["m2"] = false
+	if _, err := checkSuffrageChange(servers, "m1", "m3", raft.Nonvoter, isConnected); err == nil {
+		t.Errorf("demoted m3 without a quorum")
+	}
+	if _, err := checkSuffrageChange(servers, "m1", "m9", raft.Voter, isConnected); err == nil {
+		t.Errorf("promoted an unknown server")
+	}
+}
+
+func TestCheckLeadershipTransfer(t *testing.T) {
+	servers := []raft.Server{
+		{ID: "m1", Suffrage: raft.Voter},
+		{ID: "m2", Suffrage: raft.Voter, Address: "m2:19333"},
+		{ID: "m3", Suffrage: raft.Voter},
+		{ID: "m4", Suffrage: raft.Nonvoter},
+	}
+	connected := map[raft.ServerID]bool{"m1": true, "m2": true, "m4": true}
+	isConnected := func(id raft.ServerID) bool {
+		return connected[id]
+	}
+
+	if target, err := checkLeadershipTransfer(servers, "m1", "m2", isConnected); err != nil || target.Address != "m2:19333" {
+		t.Errorf("transfer to m2: %+v, %v", target, err)
+	}
+	for _, id := range []raft.ServerID{"m1", "m3", "m4", "m9"} {
+		if _, err := checkLeadershipTransfer(servers, "m1", id, isConnected); err == nil {
+			t.Errorf("transferred the leadership to %s", id)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/seaweedfs/raft"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+// ListVolumeRepairs reports the volumes missing replicas in the repair order, with the repair policy and progress
+func (ms *MasterServer) ListVolumeRepairs(ctx context.Context, req *master_pb.ListVolumeRepairsRequest) (*master_pb.ListVolumeRepairsResponse, error) {
+
+	if !ms.Topo.IsLeader() {
+		return nil, raft.NotLeaderError
+	}
+
+	policy := ms.option.RepairPolicy
+	resp := &master_pb.ListVolumeRepairsResponse{
+		MaxConcurrent: uint32(policy.MaxConcurrent),
+		DelaySeconds:  int64(policy.Delay.Seconds()),
+		BytePerSecond: policy.BytePerSecond,
+	}
+
+	ms.repairsLock.Lock()
+	var repairs []*volumeRepair
+	for _, r := range ms.repairs {
+		repairs = append(repairs, r)
+	}
+	sortRepairs(repairs)
+	for _, r := range repairs {
+		resp.Repairs = append(resp.Repairs, &master_pb.ListVolumeRepairsResponse_VolumeRepair{
+			VolumeId:     uint32(r.volume.Id),
+			Collection:   r.volume.Collection,
+			Replication:  r.volume.ReplicaPlacement.String(),
+			ReplicaCount: uint32(len(r.replicas)),
+			FirstSeenNs:  r.firstSeen.UnixNano(),
+			IsDeferred:   r.deferred,
+			Target:       r.target,
+			LastError:    r.lastError,
+		})
+	}
+	resp.RepairedCount = ms.repairedCount
+	resp.FailedCount = ms.repairFailedCount
+	ms.repairsLock.Unlock()
+
+	return resp, nil
+}
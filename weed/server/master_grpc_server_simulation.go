@@ -0,0 +1,19 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/seaweedfs/raft"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+// SimulatePlacement reports the placement and the data movement after a hypothetical change, without changing the cluster
+func (ms *MasterServer) SimulatePlacement(ctx context.Context, req *master_pb.SimulatePlacementRequest) (*master_pb.SimulatePlacementResponse, error) {
+
+	if !ms.Topo.IsLeader() {
+		return nil, raft.NotLeaderError
+	}
+
+	return simulatePlacement(ms.Topo.ListDataNodes(), req, ms.option.PlacementCollections)
+}
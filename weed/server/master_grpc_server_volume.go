@@ -16,6 +16,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/wdclient"
 )
 
 func (ms *MasterServer) ProcessGrowRequest() {
@@ -52,7 +53,9 @@ func (ms *MasterServer) ProcessGrowRequest() {
 				go func() {
 					glog.V(1).Infoln("starting automatic volume grow")
 					start := time.Now()
+					done := ms.expectVolumeGrowth(req.Option.Collection, "automatic volume growth")
 					newVidLocations, err := ms.vg.AutomaticGrowByType(req.Option, ms.grpcDialOption, ms.Topo, req.Count)
+					done()
 					glog.V(1).Infoln("finished automatic volume grow, cost ", time.Now().Sub(start))
 					if err == nil {
 						for _, newVidLocation := range newVidLocations {
@@ -80,6 +83,7 @@ func (ms *MasterServer) LookupVolume(ctx context.Context, req *master_pb.LookupV
 
 	resp := &master_pb.LookupVolumeResponse{}
 	volumeLocations := ms.lookupVolumeId(req.VolumeOrFileIds, req.Collection)
+	locality := wdclient.Locality{DataCenter: req.DataCenter, Rack: req.Rack, Preference: wdclient.ReadPreference(req.ReadPreference)}
 
 	for _, volumeOrFileId := range req.VolumeOrFileIds {
 		vid := volumeOrFileId
@@ -94,6 +98,12 @@ func (ms *MasterServer) LookupVolume(ctx context.Context, req *master_pb.LookupV
 					Url:        loc.Url,
 					PublicUrl:  loc.PublicUrl,
 					DataCenter: loc.DataCenter,
+					Rack:       loc.Rack,
+				})
+			}
+			if locality.DataCenter != "" {
+				locations = wdclient.OrderByProximity(locality, locations, func(loc *master_pb.Location) (string, string) {
+					return loc.DataCenter, loc.Rack
 				})
 			}
 			var auth string
@@ -172,13 +182,14 @@ func (ms *MasterServer) LookupEcVolume(ctx context.Context, req *master_pb.Looku
 
 	resp.VolumeId = req.VolumeId
 
-	for shardId, shardLocations := range ecLocations.Locations {
+	for shardId, shardLocations := range ecLocations.Locations[:ecLocations.Scheme.TotalShards()] {
 		var locations []*master_pb.Location
 		for _, dn := range shardLocations {
 			locations = append(locations, &master_pb.Location{
 				Url:        string(dn.Id()),
 				PublicUrl:  dn.PublicUrl,
 				DataCenter: dn.GetDataCenterId(),
+				Rack:       dn.GetRackId(),
 			})
 		}
 		resp.ShardIdLocations = append(resp.ShardIdLocations, &master_pb.LookupEcVolumeResponse_EcShardIdLocation{
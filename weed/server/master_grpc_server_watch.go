@@ -0,0 +1,266 @@
+package weed_server
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// The leader pushes the topology changes seen in the heartbeats to the watchers, and to the topology history.
+// A watcher not keeping up is dropped, and is expected to watch again from the full topology.
+
+const topologyWatcherBufferSize = 1024
+
+// WatchTopology streams the topology changes, optionally after the full topology
+func (ms *MasterServer) WatchTopology(req *master_pb.WatchTopologyRequest, stream master_pb.Seaweed_WatchTopologyServer) error {
+	if !ms.Topo.IsLeader() {
+		leader, _ := ms.Topo.Leader()
+		return fmt.Errorf("not the leader, watch the topology on the leader %s", leader)
+	}
+
+	events := ms.addTopologyWatcher()
+	defer ms.deleteTopologyWatcher(events)
+
+	if req.SendInitialTopology {
+		if err := stream.Send(&master_pb.TopologyEvent{
+			Type:         master_pb.TopologyEvent_INITIAL_TOPOLOGY,
+			TsNs:         time.Now().UnixNano(),
+			TopologyInfo: ms.Topo.ToTopologyInfo(),
+		}); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return fmt.Errorf("dropped the watcher not keeping up with the topology changes")
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if !ms.Topo.IsLeader() {
+				return fmt.Errorf("no longer the leader")
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (ms *MasterServer) addTopologyWatcher() chan *master_pb.TopologyEvent {
+	events := make(chan *master_pb.TopologyEvent, topologyWatcherBufferSize)
+	ms.topologyWatchersLock.Lock()
+	ms.topologyWatchers[events] = true
+	ms.topologyWatchersLock.Unlock()
+	return events
+}
+
+func (ms *MasterServer) deleteTopologyWatcher(events chan *master_pb.TopologyEvent) {
+	ms.topologyWatchersLock.Lock()
+	defer ms.topologyWatchersLock.Unlock()
+	if ms.topologyWatchers[events] {
+		delete(ms.topologyWatchers, events)
+		close(events)
+	}
+}
+
+func (ms *MasterServer) hasTopologyWatchers() bool {
+	ms.topologyWatchersLock.RLock()
+	defer ms.topologyWatchersLock.RUnlock()
+	return len(ms.topologyWatchers) > 0
+}
+
+// isTopologyObserved checks whether the topology changes are watched or recorded
+func (ms *MasterServer) isTopologyObserved() bool {
+	return ms.history != nil || ms.hasTopologyWatchers()
+}
+
+func (ms *MasterServer) publishTopologyEvent(event *master_pb.TopologyEvent) {
+	event.TsNs = time.Now().UnixNano()
+	if ms.history != nil {
+		ms.recordTopologyEvent(event)
+	}
+	var slowWatchers []chan *master_pb.TopologyEvent
+	ms.topologyWatchersLock.RLock()
+	for events := range ms.topologyWatchers {
+		select {
+		case events <- event:
+		default:
+			slowWatchers = append(slowWatchers, events)
+		}
+	}
+	ms.topologyWatchersLock.RUnlock()
+	for _, events := range slowWatchers {
+		glog.V(0).Infof("drop the topology watcher with %d pending changes", len(events))
+		ms.deleteTopologyWatcher(events)
+	}
+}
+
+// newNodeEvent locates the volume server, with its disk usages but not its volumes
+func newNodeEvent(eventType master_pb.TopologyEvent_Type, dn *topology.DataNode) *master_pb.TopologyEvent {
+	event := &master_pb.TopologyEvent{
+		Type:     eventType,
+		DataNode: dataNodeSummary(dn),
+	}
+	if rack := dn.GetRack(); rack != nil {
+		event.Rack = string(rack.Id())
+		if dc := dn.GetDataCenter(); dc != nil {
+			event.DataCenter = string(dc.Id())
+		}
+	}
+	return event
+}
+
+func dataNodeSummary(dn *topology.DataNode) *master_pb.DataNodeInfo {
+	diskInfos := dn.GetDiskUsages().ToDiskInfo()
+	for diskType, diskInfo := range diskInfos {
+		diskInfo.Type = diskType
+	}
+	return &master_pb.DataNodeInfo{
+		Id:              string(dn.Id()),
+		DiskInfos:       diskInfos,
+		GrpcPort:        uint32(dn.GrpcPort),
+		IsDraining:      dn.IsDraining(),
+		Tags:            dn.GetTags(),
+		IsInMaintenance: dn.IsInMaintenance,
+	}
+}
+
+// topologyWatch collects the changes of one volume server during one heartbeat, only with any watchers or the history
+type topologyWatch struct {
+	ms          *MasterServer
+	dn          *topology.DataNode
+	summary     *master_pb.DataNodeInfo
+	volumes     map[needle.VolumeId]storage.VolumeInfo
+	added       []*master_pb.VolumeInformationMessage
+	deleted     []*master_pb.VolumeInformationMessage
+	ecVolumeIds map[needle.VolumeId]bool
+}
+
+func (ms *MasterServer) startTopologyWatch(dn *topology.DataNode) *topologyWatch {
+	if !ms.isTopologyObserved() {
+		return nil
+	}
+	w := &topologyWatch{
+		ms:          ms,
+		dn:          dn,
+		summary:     dataNodeSummary(dn),
+		volumes:     make(map[needle.VolumeId]storage.VolumeInfo),
+		ecVolumeIds: make(map[needle.VolumeId]bool),
+	}
+	for _, v := range dn.GetVolumes() {
+		w.volumes[v.Id] = v
+	}
+	return w
+}
+
+func (w *topologyWatch) addVolumes(volumes []storage.VolumeInfo) {
+	if w == nil {
+		return
+	}
+	for _, v := range volumes {
+		w.added = append(w.added, v.ToVolumeInformationMessage())
+	}
+}
+
+func (w *topologyWatch) deleteVolumes(volumes []storage.VolumeInfo) {
+	if w == nil {
+		return
+	}
+	for _, v := range volumes {
+		w.deleted = append(w.deleted, v.ToVolumeInformationMessage())
+	}
+}
+
+func (w *topologyWatch) addShortVolumes(volumes []*master_pb.VolumeShortInformationMessage, isDeleted bool) {
+	if w == nil {
+		return
+	}
+	for _, m := range volumes {
+		v, err := storage.NewVolumeInfoFromShort(m)
+		if err != nil {
+			continue
+		}
+		if isDeleted {
+			w.deleted = append(w.deleted, v.ToVolumeInformationMessage())
+		} else if current, err := w.dn.GetVolumesById(v.Id); err == nil {
+			w.added = append(w.added, current.ToVolumeInformationMessage())
+		}
+	}
+}
+
+func (w *topologyWatch) changeEcShards(volumeIds ...needle.VolumeId) {
+	if w == nil {
+		return
+	}
+	for _, vid := range volumeIds {
+		w.ecVolumeIds[vid] = true
+	}
+}
+
+// publish sends the changes in the order of the volumes, the ec shards, and the disk usages
+func (w *topologyWatch) publish() {
+	if w == nil {
+		return
+	}
+	newEvent := func(eventType master_pb.TopologyEvent_Type) *master_pb.TopologyEvent {
+		event := newNodeEvent(eventType, w.dn)
+		event.DataNode.DiskInfos = nil
+		return event
+	}
+	if len(w.added) > 0 {
+		event := newEvent(master_pb.TopologyEvent_VOLUMES_ADDED)
+		event.Volumes = w.added
+		w.ms.publishTopologyEvent(event)
+	}
+	if len(w.deleted) > 0 {
+		event := newEvent(master_pb.TopologyEvent_VOLUMES_DELETED)
+		event.Volumes = w.deleted
+		w.ms.publishTopologyEvent(event)
+	}
+
+	var changed []*master_pb.VolumeInformationMessage
+	for _, v := range w.dn.GetVolumes() {
+		if old, found := w.volumes[v.Id]; found && (old.ReadOnly != v.ReadOnly || old.ReplicaPlacement.Byte() != v.ReplicaPlacement.Byte()) {
+			changed = append(changed, v.ToVolumeInformationMessage())
+		}
+	}
+	if len(changed) > 0 {
+		event := newEvent(master_pb.TopologyEvent_VOLUMES_CHANGED)
+		event.Volumes = changed
+		w.ms.publishTopologyEvent(event)
+	}
+
+	if len(w.ecVolumeIds) > 0 {
+		event := newEvent(master_pb.TopologyEvent_EC_SHARDS_CHANGED)
+		for _, ecInfo := range w.dn.GetEcShards() {
+			if w.ecVolumeIds[ecInfo.VolumeId] {
+				event.EcShards = append(event.EcShards, ecInfo.ToVolumeEcShardInformationMessage())
+				delete(w.ecVolumeIds, ecInfo.VolumeId)
+			}
+		}
+		// no shards left on the volume server
+		for vid := range w.ecVolumeIds {
+			event.EcShards = append(event.EcShards, &master_pb.VolumeEcShardInformationMessage{Id: uint32(vid)})
+		}
+		w.ms.publishTopologyEvent(event)
+	}
+
+	if summary := dataNodeSummary(w.dn); !proto.Equal(summary, w.summary) {
+		event := newNodeEvent(master_pb.TopologyEvent_NODE_UPDATED, w.dn)
+		event.DataNode = summary
+		w.ms.publishTopologyEvent(event)
+	}
+}
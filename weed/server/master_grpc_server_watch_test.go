@@ -0,0 +1,72 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+func TestTopologyWatch(t *testing.T) {
+	topo := topology.NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+	dn := topo.GetOrCreateDataCenter("dc1").GetOrCreateRack("rack1").GetOrCreateDataNode("127.0.0.1", 8080, 0, "a", map[string]uint32{"": 10})
+	ms := &MasterServer{Topo: topo, topologyWatchers: make(map[chan *master_pb.TopologyEvent]bool)}
+
+	if ms.startTopologyWatch(dn) != nil {
+		t.Errorf("watching the heartbeat without watchers")
+	}
+	events := ms.addTopologyWatcher()
+	receive := func() (eventTypes []master_pb.TopologyEvent_Type, received []*master_pb.TopologyEvent) {
+		for len(events) > 0 {
+			event := <-events
+			eventTypes = append(eventTypes, event.Type)
+			received = append(received, event)
+		}
+		return
+	}
+	volume := func(id uint32, readOnly bool) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{Id: id, ReadOnly: readOnly, Version: uint32(needle.CurrentVersion)}
+	}
+
+	watch := ms.startTopologyWatch(dn)
+	newVolumes, deletedVolumes := topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{volume(1, false), volume(2, false)}, dn)
+	watch.addVolumes(newVolumes)
+	watch.deleteVolumes(deletedVolumes)
+	watch.publish()
+	eventTypes, received := receive()
+	if len(eventTypes) != 2 || eventTypes[0] != master_pb.TopologyEvent_VOLUMES_ADDED || eventTypes[1] != master_pb.TopologyEvent_NODE_UPDATED {
+		t.Fatalf("events %v, expected the added volumes and the node update", eventTypes)
+	}
+	if len(received[0].Volumes) != 2 || received[0].DataCenter != "dc1" || received[0].Rack != "rack1" {
+		t.Errorf("added volumes event %+v", received[0])
+	}
+	if diskInfo := received[1].DataNode.DiskInfos[""]; diskInfo == nil || diskInfo.VolumeCount != 2 || diskInfo.MaxVolumeCount != 10 {
+		t.Errorf("node update %+v", received[1].DataNode)
+	}
+
+	watch = ms.startTopologyWatch(dn)
+	newVolumes, deletedVolumes = topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{volume(1, true)}, dn)
+	watch.addVolumes(newVolumes)
+	watch.deleteVolumes(deletedVolumes)
+	watch.publish()
+	eventTypes, received = receive()
+	if len(eventTypes) < 2 || eventTypes[0] != master_pb.TopologyEvent_VOLUMES_DELETED || eventTypes[1] != master_pb.TopologyEvent_VOLUMES_CHANGED {
+		t.Fatalf("events %v, expected the deleted and the read only volumes", eventTypes)
+	}
+	if received[0].Volumes[0].Id != 2 || received[1].Volumes[0].Id != 1 || !received[1].Volumes[0].ReadOnly {
+		t.Errorf("deleted %+v, changed %+v", received[0].Volumes, received[1].Volumes)
+	}
+
+	// the watcher not keeping up is dropped
+	for i := 0; i <= topologyWatcherBufferSize; i++ {
+		ms.publishTopologyEvent(newNodeEvent(master_pb.TopologyEvent_NODE_UPDATED, dn))
+	}
+	if ms.hasTopologyWatchers() {
+		t.Errorf("kept the slow watcher")
+	}
+	for range events {
+	}
+	ms.deleteTopologyWatcher(events)
+}
@@ -3,10 +3,12 @@ package weed_server
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
@@ -26,7 +28,10 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
 	"github.com/seaweedfs/seaweedfs/weed/security"
 	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	ui "github.com/seaweedfs/seaweedfs/weed/server/master_ui"
 	"github.com/seaweedfs/seaweedfs/weed/shell"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/topology"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 	"github.com/seaweedfs/seaweedfs/weed/wdclient"
@@ -50,6 +55,22 @@ type MasterOption struct {
 	MetricsAddress          string
 	MetricsIntervalSec      int
 	IsFollower              bool
+	DrainInterval           time.Duration
+	DrainBytePerSecond      int64
+	TierPolicy              TierPolicy
+	ArchiveCollections      map[string]erasure_coding.Scheme // erasure coded once full, without replicas before
+	PlacementCollections    map[string]*topology.PlacementConstraint
+	RebalancePolicy         RebalancePolicy
+	EcPlacementPolicy       EcPlacementPolicy
+	AdmissionPolicy         AdmissionPolicy
+	RepairPolicy            RepairPolicy
+	VolumeGrowthCollections map[string]*topology.VolumeGrowthPolicy
+	QuotaCollections        map[string]*topology.CollectionQuota
+	VolumeIdRange           *topology.VolumeIdRange // the volume ids allocated by this cluster, nil for all
+	LifecycleCollections    map[string]*CollectionLifecycle
+	LifecycleDryRun         bool
+	ShutdownDrainTimeout    time.Duration // how long the shutdown waits for the assign and lookup requests in flight
+	HistoryRetention        time.Duration // how long the topology changes are kept, 0 to not record them
 }
 
 type MasterServer struct {
@@ -76,6 +97,33 @@ type MasterServer struct {
 	adminLocks *AdminLocks
 
 	Cluster *cluster.Cluster
+
+	drainsLock sync.Mutex
+	drains     map[string]*volumeServerDrain
+
+	volumeMovesLock sync.Mutex
+	volumeMoves     map[needle.VolumeId]string // the mover of each volume being moved
+
+	maintenancesLock sync.Mutex
+	maintenances     map[string]*maintenance
+
+	topologyWatchersLock sync.RWMutex
+	topologyWatchers     map[chan *master_pb.TopologyEvent]bool
+	history              *topologyHistory
+
+	admission *admissionController
+	requests  requestDrainer
+
+	repairsLock       sync.Mutex
+	repairs           map[needle.VolumeId]*volumeRepair
+	repairedCount     uint64
+	repairFailedCount uint64
+
+	operationsLock sync.Mutex
+	operations     map[*masterOperation]bool
+
+	lifecycleLock    sync.Mutex
+	lifecycleApplied []*master_pb.LifecycleAction // the latest first
 }
 
 func NewMasterServer(r *mux.Router, option *MasterOption, peers map[string]pb.ServerAddress) *MasterServer {
@@ -105,16 +153,34 @@ func NewMasterServer(r *mux.Router, option *MasterOption, peers map[string]pb.Se
 
 	grpcDialOption := security.LoadClientTLS(v, "grpc.master")
 	ms := &MasterServer{
-		option:          option,
-		preallocateSize: preallocateSize,
-		vgCh:            make(chan *topology.VolumeGrowRequest, 1<<6),
-		clientChans:     make(map[string]chan *master_pb.KeepConnectedResponse),
-		grpcDialOption:  grpcDialOption,
-		MasterClient:    wdclient.NewMasterClient(grpcDialOption, "", cluster.MasterType, option.Master, "", "", *pb.NewServiceDiscoveryFromMap(peers)),
-		adminLocks:      NewAdminLocks(),
-		Cluster:         cluster.NewCluster(),
+		option:           option,
+		preallocateSize:  preallocateSize,
+		vgCh:             make(chan *topology.VolumeGrowRequest, 1<<6),
+		clientChans:      make(map[string]chan *master_pb.KeepConnectedResponse),
+		grpcDialOption:   grpcDialOption,
+		MasterClient:     wdclient.NewMasterClient(grpcDialOption, "", cluster.MasterType, option.Master, "", "", *pb.NewServiceDiscoveryFromMap(peers)),
+		adminLocks:       NewAdminLocks(),
+		Cluster:          cluster.NewCluster(),
+		drains:           make(map[string]*volumeServerDrain),
+		volumeMoves:      make(map[needle.VolumeId]string),
+		maintenances:     make(map[string]*maintenance),
+		repairs:          make(map[needle.VolumeId]*volumeRepair),
+		operations:       make(map[*masterOperation]bool),
+		topologyWatchers: make(map[chan *master_pb.TopologyEvent]bool),
 	}
 	ms.boundedLeaderChan = make(chan int, 16)
+	if option.AdmissionPolicy.isEnabled() {
+		ms.admission = newAdmissionController(option.AdmissionPolicy)
+	}
+	if option.HistoryRetention > 0 {
+		_, port, _ := net.SplitHostPort(option.Master.ToHttpAddress())
+		historyDir := filepath.Join(option.MetaFolder, fmt.Sprintf("m%s_topology_history", port))
+		if history, err := newTopologyHistory(historyDir, option.HistoryRetention); err != nil {
+			glog.Warningf("not recording the topology history in %s: %v", historyDir, err)
+		} else {
+			ms.history = history
+		}
+	}
 
 	ms.MasterClient.SetOnPeerUpdateFn(ms.OnPeerUpdate)
 
@@ -123,6 +189,11 @@ func NewMasterServer(r *mux.Router, option *MasterOption, peers map[string]pb.Se
 		glog.Fatalf("create sequencer failed.")
 	}
 	ms.Topo = topology.NewTopology("topo", seq, uint64(ms.option.VolumeSizeLimitMB)*1024*1024, 5, replicationAsMin)
+	ms.Topo.SetVolumeGrowthPolicies(option.VolumeGrowthCollections)
+	ms.Topo.SetVolumeIdRange(option.VolumeIdRange)
+	for collection, quota := range option.QuotaCollections {
+		ms.Topo.SetCollectionQuota(collection, quota)
+	}
 	ms.vg = topology.NewDefaultVolumeGrowth()
 	glog.V(0).Infoln("Volume Size Limit is", ms.option.VolumeSizeLimitMB, "MB")
 
@@ -131,14 +202,17 @@ func NewMasterServer(r *mux.Router, option *MasterOption, peers map[string]pb.Se
 	handleStaticResources2(r)
 	r.HandleFunc("/", ms.proxyToLeader(ms.uiStatusHandler))
 	r.HandleFunc("/ui/index.html", ms.uiStatusHandler)
+	r.HandleFunc("/ui/topology", ms.proxyToLeader(ms.uiTopologyHandler))
+	r.PathPrefix("/ui/static/").Handler(http.StripPrefix("/ui/static/", http.FileServer(http.FS(ui.StaticFS))))
 	if !ms.option.DisableHttp {
-		r.HandleFunc("/dir/assign", ms.proxyToLeader(ms.guard.WhiteList(ms.dirAssignHandler)))
-		r.HandleFunc("/dir/lookup", ms.guard.WhiteList(ms.dirLookupHandler))
+		r.HandleFunc("/dir/assign", ms.proxyToLeader(ms.guard.WhiteList(ms.admitHttp(ms.dirAssignHandler))))
+		r.HandleFunc("/dir/lookup", ms.guard.WhiteList(ms.admitHttp(ms.dirLookupHandler)))
 		r.HandleFunc("/dir/status", ms.proxyToLeader(ms.guard.WhiteList(ms.dirStatusHandler)))
 		r.HandleFunc("/col/delete", ms.proxyToLeader(ms.guard.WhiteList(ms.collectionDeleteHandler)))
 		r.HandleFunc("/vol/grow", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeGrowHandler)))
 		r.HandleFunc("/vol/status", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeStatusHandler)))
 		r.HandleFunc("/vol/vacuum", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeVacuumHandler)))
+		r.HandleFunc("/cluster/load", ms.proxyToLeader(ms.guard.WhiteList(ms.clusterLoadHandler)))
 		r.HandleFunc("/submit", ms.guard.WhiteList(ms.submitFromMasterServerHandler))
 		/*
 			r.HandleFunc("/stats/health", ms.guard.WhiteList(statsHealthHandler))
@@ -159,6 +233,15 @@ func NewMasterServer(r *mux.Router, option *MasterOption, peers map[string]pb.Se
 
 	if !option.IsFollower {
 		ms.startAdminScripts()
+		go ms.loopDrainingVolumeServers()
+		go ms.loopFailingDisks()
+		go ms.loopTierMigration()
+		go ms.loopArchiveEncoding()
+		go ms.loopRebalance()
+		go ms.loopCollectionQuotas()
+		go ms.loopEcPlacement()
+		go ms.loopRepair()
+		go ms.loopLifecycle()
 	}
 
 	return ms
@@ -398,13 +481,3 @@ func (ms *MasterServer) OnPeerUpdate(update *master_pb.ClusterNodeUpdate, startF
 		})
 	}
 }
-
-func (ms *MasterServer) Shutdown() {
-	if ms.Topo == nil || ms.Topo.HashicorpRaft == nil {
-		return
-	}
-	if ms.Topo.HashicorpRaft.State() == hashicorpRaft.Leader {
-		ms.Topo.HashicorpRaft.LeadershipTransfer()
-	}
-	ms.Topo.HashicorpRaft.Shutdown()
-}
@@ -0,0 +1,253 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+)
+
+// The master admits a bounded number of assign and lookup requests at a time, so a storm of them
+// can not starve the heartbeats and the raft traffic of the leader. The requests beyond the limit wait
+// in a bounded queue, served round robin across the clients, so a runaway client only delays itself.
+// A request not admitted is rejected with a hint of when to retry: 429 or ResourceExhausted over the
+// limit of its client, and 503 or Unavailable when the queue is full or the wait times out.
+
+// AdmissionPolicy limits the concurrent assign and lookup requests. A zero MaxConcurrent means no limit.
+type AdmissionPolicy struct {
+	MaxConcurrent int
+	MaxQueued     int
+	QueueTimeout  time.Duration
+	MaxPerClient  int // the requests of one client handled or waiting at a time, 0 for no limit
+}
+
+func (p AdmissionPolicy) isEnabled() bool {
+	return p.MaxConcurrent > 0
+}
+
+var admittedGrpcMethods = map[string]bool{
+	"/master_pb.Seaweed/Assign":         true,
+	"/master_pb.Seaweed/LookupVolume":   true,
+	"/master_pb.Seaweed/LookupEcVolume": true,
+}
+
+type admissionError struct {
+	reason     string
+	retryAfter time.Duration
+	overloaded bool // the master is overloaded, or else the client is over its own limit
+}
+
+func (e *admissionError) Error() string {
+	return fmt.Sprintf("%s, retry after %v", e.reason, e.retryAfter)
+}
+
+type admissionClient struct {
+	active  int // handled or waiting
+	waiters []chan struct{}
+}
+
+type admissionController struct {
+	policy   AdmissionPolicy
+	lock     sync.Mutex
+	inFlight int
+	queued   int
+	clients  map[string]*admissionClient
+	ready    []string // the clients with waiters, in the round robin order
+}
+
+func newAdmissionController(policy AdmissionPolicy) *admissionController {
+	return &admissionController{
+		policy:  policy,
+		clients: make(map[string]*admissionClient),
+	}
+}
+
+// acquire admits one request of the client, waiting in the queue if needed. The caller must call release when done.
+func (a *admissionController) acquire(ctx context.Context, client string) error {
+	a.lock.Lock()
+	c, found := a.clients[client]
+	if !found {
+		c = &admissionClient{}
+		a.clients[client] = c
+	}
+	if a.policy.MaxPerClient > 0 && c.active >= a.policy.MaxPerClient {
+		a.lock.Unlock()
+		stats.MasterAdmissionCounter.WithLabelValues(stats.AdmissionClientLimited).Inc()
+		return &admissionError{reason: fmt.Sprintf("client %s has %d pending requests", client, a.policy.MaxPerClient), retryAfter: time.Second}
+	}
+	if a.inFlight < a.policy.MaxConcurrent && a.queued == 0 {
+		a.inFlight++
+		c.active++
+		a.lock.Unlock()
+		return nil
+	}
+	if a.queued >= a.policy.MaxQueued {
+		a.forget(client, c)
+		a.lock.Unlock()
+		stats.MasterAdmissionCounter.WithLabelValues(stats.AdmissionQueueFull).Inc()
+		return &admissionError{reason: fmt.Sprintf("master has %d queued requests", a.policy.MaxQueued), retryAfter: a.retryAfter(), overloaded: true}
+	}
+	admitted := make(chan struct{}, 1)
+	c.waiters = append(c.waiters, admitted)
+	if len(c.waiters) == 1 {
+		a.ready = append(a.ready, client)
+	}
+	c.active++
+	a.queued++
+	a.lock.Unlock()
+
+	timer := time.NewTimer(a.policy.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case <-admitted:
+		return nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for i, w := range c.waiters {
+		if w == admitted {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			if len(c.waiters) == 0 {
+				a.removeReady(client)
+			}
+			c.active--
+			a.queued--
+			a.forget(client, c)
+			stats.MasterAdmissionCounter.WithLabelValues(stats.AdmissionQueueTimeout).Inc()
+			return &admissionError{reason: fmt.Sprintf("waited %v in the queue", a.policy.QueueTimeout), retryAfter: a.retryAfter(), overloaded: true}
+		}
+	}
+	// admitted while timing out
+	return nil
+}
+
+// release finishes one request of the client, and admits the next waiting one of the next client
+func (a *admissionController) release(client string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.inFlight--
+	if c, found := a.clients[client]; found {
+		c.active--
+		a.forget(client, c)
+	}
+	for a.inFlight < a.policy.MaxConcurrent && len(a.ready) > 0 {
+		next := a.ready[0]
+		a.ready = a.ready[1:]
+		c := a.clients[next]
+		c.waiters[0] <- struct{}{}
+		c.waiters = c.waiters[1:]
+		if len(c.waiters) > 0 {
+			a.ready = append(a.ready, next)
+		}
+		a.queued--
+		a.inFlight++
+	}
+}
+
+func (a *admissionController) removeReady(client string) {
+	for i, name := range a.ready {
+		if name == client {
+			a.ready = append(a.ready[:i], a.ready[i+1:]...)
+			return
+		}
+	}
+}
+
+func (a *admissionController) forget(client string, c *admissionClient) {
+	if c.active == 0 && len(c.waiters) == 0 {
+		delete(a.clients, client)
+	}
+}
+
+// retryAfter hints the queue timeout, long enough for the queue to drain
+func (a *admissionController) retryAfter() time.Duration {
+	if a.policy.QueueTimeout < time.Second {
+		return time.Second
+	}
+	return a.policy.QueueTimeout
+}
+
+func (ms *MasterServer) admit(ctx context.Context, client string) (release func(), err error) {
+	if !ms.requests.begin() {
+		return nil, &admissionError{reason: "the master is shutting down", retryAfter: time.Second, overloaded: true}
+	}
+	if ms.admission == nil {
+		return ms.requests.end, nil
+	}
+	if err = ms.admission.acquire(ctx, client); err != nil {
+		ms.requests.end()
+		glog.V(1).Infof("reject the request of %s: %v", client, err)
+		return nil, err
+	}
+	return func() {
+		ms.admission.release(client)
+		ms.requests.end()
+	}, nil
+}
+
+func toAdmissionGrpcError(ctx context.Context, err error) error {
+	e, ok := err.(*admissionError)
+	if !ok {
+		return err
+	}
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(e.retryAfter.Seconds())))))
+	if e.overloaded {
+		return status.Error(codes.Unavailable, e.Error())
+	}
+	return status.Error(codes.ResourceExhausted, e.Error())
+}
+
+// admitHttp admits the request, or writes 429 or 503 with the Retry-After header
+func (ms *MasterServer) admitHttp(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		release, err := ms.admit(r.Context(), client)
+		if err != nil {
+			e := err.(*admissionError)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(e.retryAfter.Seconds()))))
+			httpStatus := http.StatusTooManyRequests
+			if e.overloaded {
+				httpStatus = http.StatusServiceUnavailable
+			}
+			writeJsonError(w, r, httpStatus, err)
+			return
+		}
+		defer release()
+		f(w, r)
+	}
+}
+
+// GrpcServerOptions admits the assign and lookup requests, by the ip address of the client
+func (ms *MasterServer) GrpcServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if !admittedGrpcMethods[info.FullMethod] {
+				return handler(ctx, req)
+			}
+			release, err := ms.admit(ctx, clientOfGrpcContext(ctx))
+			if err != nil {
+				return nil, toAdmissionGrpcError(ctx, err)
+			}
+			defer release()
+			return handler(ctx, req)
+		}),
+	}
+}
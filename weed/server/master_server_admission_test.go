@@ -0,0 +1,87 @@
+package weed_server
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAdmissionController(t *testing.T) {
+	a := newAdmissionController(AdmissionPolicy{MaxConcurrent: 1, MaxQueued: 3, QueueTimeout: time.Minute, MaxPerClient: 3})
+	ctx := context.Background()
+
+	admitted := make(chan string, 10)
+	enqueue := func(client string) {
+		a.lock.Lock()
+		queued := a.queued
+		a.lock.Unlock()
+		go func() {
+			if err := a.acquire(ctx, client); err != nil {
+				t.Errorf("acquire %s: %v", client, err)
+				return
+			}
+			admitted <- client
+		}()
+		for {
+			a.lock.Lock()
+			done := a.queued > queued
+			a.lock.Unlock()
+			if done {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	expectAdmitted := func(expected string) {
+		select {
+		case client := <-admitted:
+			if client != expected {
+				t.Errorf("admitted %s, expected %s", client, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s not admitted", expected)
+		}
+	}
+
+	if err := a.acquire(ctx, "a"); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	enqueue("a")
+	enqueue("a")
+	enqueue("b")
+
+	err := a.acquire(ctx, "a")
+	if e, ok := err.(*admissionError); !ok || e.overloaded {
+		t.Errorf("the 4th request of a: %v, expected over the client limit", err)
+	}
+	err = a.acquire(ctx, "c")
+	if e, ok := err.(*admissionError); !ok || !e.overloaded || e.retryAfter != time.Minute {
+		t.Errorf("the request of c: %v, expected the queue full", err)
+	}
+
+	// b is admitted before the last request of a
+	a.release("a")
+	expectAdmitted("a")
+	a.release("a")
+	expectAdmitted("b")
+	a.release("b")
+	expectAdmitted("a")
+	a.release("a")
+
+	if a.inFlight != 0 || a.queued != 0 || len(a.clients) != 0 || len(a.ready) != 0 {
+		t.Errorf("left %d in flight, %d queued, %d clients, %d ready", a.inFlight, a.queued, len(a.clients), len(a.ready))
+	}
+
+	a.policy.QueueTimeout = 10 * time.Millisecond
+	if err = a.acquire(ctx, "a"); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	err = a.acquire(ctx, "b")
+	if e, ok := err.(*admissionError); !ok || !e.overloaded || e.retryAfter != time.Second {
+		t.Errorf("the request of b: %v, expected timed out in the queue", err)
+	}
+	a.release("a")
+	if a.inFlight != 0 || a.queued != 0 || len(a.clients) != 0 {
+		t.Errorf("left %d in flight, %d queued, %d clients after the timeout", a.inFlight, a.queued, len(a.clients))
+	}
+}
@@ -0,0 +1,283 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// The archive collections skip the replicated copies before erasure coding: their volumes are written
+// without replicas, and the leader erasure codes each volume as soon as it is full, spreading the ec shards
+// across the racks and volume servers, and then deletes the volume.
+// So the archived data is written once to the volume and once to the ec shards.
+
+const (
+	archiveEncodingInterval = time.Minute
+	archiveFullPercent      = 95
+)
+
+type archiveVolume struct {
+	volume  storage.VolumeInfo
+	sources []*topology.DataNode
+	scheme  erasure_coding.Scheme
+}
+
+// ParseArchiveCollections parses the comma separated collections, each with an optional ec scheme, e.g. "logs,backup:8+3"
+func ParseArchiveCollections(s string) (map[string]erasure_coding.Scheme, error) {
+	collections := make(map[string]erasure_coding.Scheme)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		collection, schemeString, hasScheme := strings.Cut(item, ":")
+		scheme := erasure_coding.DefaultScheme
+		if hasScheme {
+			var err error
+			if scheme, err = erasure_coding.ParseScheme(schemeString); err != nil {
+				return nil, fmt.Errorf("archive collection %s: %v", collection, err)
+			}
+		}
+		collections[collection] = scheme
+	}
+	return collections, nil
+}
+
+// replicationOf is the replication to write the collection with, no replicas for the archive collections
+func (ms *MasterServer) replicationOf(collection, replication string) string {
+	if _, isArchive := ms.option.ArchiveCollections[collection]; isArchive {
+		return "000"
+	}
+	if replication == "" {
+		return ms.option.DefaultReplicaPlacement
+	}
+	return replication
+}
+
+func (ms *MasterServer) loopArchiveEncoding() {
+	if len(ms.option.ArchiveCollections) == 0 {
+		return
+	}
+	for {
+		time.Sleep(archiveEncodingInterval)
+		if !ms.Topo.IsLeader() {
+			continue
+		}
+		dataNodes := ms.Topo.ListDataNodes()
+		volumeSizeLimit := uint64(ms.option.VolumeSizeLimitMB) * 1024 * 1024
+		for _, av := range pickFullArchiveVolumes(ms.option.ArchiveCollections, dataNodes, volumeSizeLimit) {
+			if err := ms.encodeArchiveVolume(av, dataNodes); err != nil {
+				glog.Warningf("erasure code archive volume %d: %v", av.volume.Id, err)
+			}
+		}
+	}
+}
+
+// pickFullArchiveVolumes lists the full or read only volumes of the archive collections, with all their replicas
+func pickFullArchiveVolumes(collections map[string]erasure_coding.Scheme, dataNodes []*topology.DataNode, volumeSizeLimit uint64) (volumes []*archiveVolume) {
+	found := make(map[needle.VolumeId]*archiveVolume)
+	for _, dn := range dataNodes {
+		for _, v := range dn.GetVolumes() {
+			scheme, isArchive := collections[v.Collection]
+			if !isArchive || v.IsRemote() {
+				continue
+			}
+			if av, ok := found[v.Id]; ok {
+				av.sources = append(av.sources, dn)
+				continue
+			}
+			if !v.ReadOnly && v.Size*100 < volumeSizeLimit*archiveFullPercent {
+				continue
+			}
+			av := &archiveVolume{volume: v, sources: []*topology.DataNode{dn}, scheme: scheme}
+			found[v.Id] = av
+			volumes = append(volumes, av)
+		}
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].volume.Id < volumes[j].volume.Id
+	})
+	return
+}
+
+// allocateEcShards spreads the ec shards to the racks with fewer shards of the volume,
+// then to the volume servers with fewer shards of the volume, then to the ones with more free slots,
+// never over the caps of the ec placement policy.
+func allocateEcShards(dataNodes []*topology.DataNode, totalShards int, diskType string, placement *topology.PlacementConstraint, policy EcPlacementPolicy) (map[*topology.DataNode][]uint32, error) {
+	option := &topology.VolumeGrowOption{DiskType: types.ToDiskType(diskType), Placement: placement}
+	freeSlots := make(map[*topology.DataNode]int64)
+	var candidates []*topology.DataNode
+	for _, dn := range dataNodes {
+		if dn.IsDraining() || dn.IsTerminating {
+			continue
+		}
+		if free := dn.AvailableSpaceFor(option) * erasure_coding.DataShardsCount; free > 0 {
+			freeSlots[dn] = free
+			candidates = append(candidates, dn)
+		}
+	}
+
+	allocated := make(map[*topology.DataNode][]uint32)
+	counts := newEcShardCounts()
+	for shardId := 0; shardId < totalShards; shardId++ {
+		var target *topology.DataNode
+		var best [3]int64
+		for _, dn := range candidates {
+			if freeSlots[dn] <= 0 || !policy.allows(counts, dn) {
+				continue
+			}
+			score := [3]int64{int64(counts.racks[dn.GetRack()]), int64(len(allocated[dn])), -freeSlots[dn]}
+			if target == nil || lessScore(score, best) {
+				target, best = dn, score
+			}
+		}
+		if target == nil {
+			return nil, fmt.Errorf("not enough free slots for %d ec shards within %d shards per rack and %d shards per data center",
+				totalShards, policy.MaxShardsPerRack, policy.MaxShardsPerDataCenter)
+		}
+		allocated[target] = append(allocated[target], uint32(shardId))
+		counts.add(target, 1)
+		freeSlots[target]--
+	}
+	return allocated, nil
+}
+
+// encodeArchiveVolume generates the ec shards on the first replica, spreads them, and deletes the volume
+func (ms *MasterServer) encodeArchiveVolume(av *archiveVolume, dataNodes []*topology.DataNode) error {
+	v, source := av.volume, av.sources[0]
+	sourceAddress := source.ServerAddress()
+	doneMoving, err := ms.startVolumeMove(v.Id, "archive")
+	if err != nil {
+		return err
+	}
+	defer doneMoving()
+	glog.V(0).Infof("erasure code archive volume %d on %s with %s", v.Id, source.Url(), av.scheme)
+	defer ms.startOperation(fmt.Sprintf("erasure code volume %d on %s with %s", v.Id, source.Url(), av.scheme))()
+	defer ms.expectTopologyChange(v.Id, fmt.Sprintf("archive with %s", av.scheme))()
+
+	allocated, err := allocateEcShards(dataNodes, av.scheme.TotalShards(), v.DiskType, ms.option.PlacementCollections[v.Collection], ms.option.EcPlacementPolicy)
+	if err != nil {
+		return err
+	}
+
+	for _, dn := range av.sources {
+		err = operation.WithVolumeServerClient(false, dn.ServerAddress(), ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+			_, err := client.VolumeMarkReadonly(context.Background(), &volume_server_pb.VolumeMarkReadonlyRequest{
+				VolumeId: uint32(v.Id),
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("mark volume %d readonly on %s: %v", v.Id, dn.Url(), err)
+		}
+	}
+
+	err = operation.WithVolumeServerClient(false, sourceAddress, ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		_, err := client.VolumeEcShardsGenerate(context.Background(), &volume_server_pb.VolumeEcShardsGenerateRequest{
+			VolumeId:     uint32(v.Id),
+			Collection:   v.Collection,
+			DataShards:   uint32(av.scheme.DataShards),
+			ParityShards: uint32(av.scheme.ParityShards),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("generate ec shards on %s: %v", source.Url(), err)
+	}
+
+	var copiedShardIds []uint32
+	var mounted []*topology.DataNode
+	for target, shardIds := range allocated {
+		err = operation.WithVolumeServerClient(false, target.ServerAddress(), ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+			if target != source {
+				if _, err := client.VolumeEcShardsCopy(context.Background(), &volume_server_pb.VolumeEcShardsCopyRequest{
+					VolumeId:       uint32(v.Id),
+					Collection:     v.Collection,
+					ShardIds:       shardIds,
+					CopyEcxFile:    true,
+					CopyEcjFile:    true,
+					CopyVifFile:    true,
+					SourceDataNode: string(sourceAddress),
+				}); err != nil {
+					return fmt.Errorf("copy: %v", err)
+				}
+			}
+			if _, err := client.VolumeEcShardsMount(context.Background(), &volume_server_pb.VolumeEcShardsMountRequest{
+				VolumeId:   uint32(v.Id),
+				Collection: v.Collection,
+				ShardIds:   shardIds,
+			}); err != nil {
+				return fmt.Errorf("mount: %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			ms.removeEcShards(v, allocated, mounted)
+			return fmt.Errorf("ec shards %d.%v on %s: %v", v.Id, shardIds, target.Url(), err)
+		}
+		mounted = append(mounted, target)
+		if target != source {
+			copiedShardIds = append(copiedShardIds, shardIds...)
+		}
+	}
+
+	err = operation.WithVolumeServerClient(false, sourceAddress, ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		_, err := client.VolumeEcShardsDelete(context.Background(), &volume_server_pb.VolumeEcShardsDeleteRequest{
+			VolumeId:   uint32(v.Id),
+			Collection: v.Collection,
+			ShardIds:   copiedShardIds,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("delete copied ec shards %d.%v on %s: %v", v.Id, copiedShardIds, source.Url(), err)
+	}
+
+	for _, dn := range av.sources {
+		err = operation.WithVolumeServerClient(false, dn.ServerAddress(), ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+			_, err := client.VolumeDelete(context.Background(), &volume_server_pb.VolumeDeleteRequest{
+				VolumeId: uint32(v.Id),
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("delete volume %d from %s: %v", v.Id, dn.Url(), err)
+		}
+	}
+	return nil
+}
+
+// removeEcShards cleans up the ec shards mounted before the encoding failed, keeping the volume to retry later
+func (ms *MasterServer) removeEcShards(v storage.VolumeInfo, allocated map[*topology.DataNode][]uint32, mounted []*topology.DataNode) {
+	for _, dn := range mounted {
+		shardIds := allocated[dn]
+		err := operation.WithVolumeServerClient(false, dn.ServerAddress(), ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+			if _, err := client.VolumeEcShardsUnmount(context.Background(), &volume_server_pb.VolumeEcShardsUnmountRequest{
+				VolumeId: uint32(v.Id),
+				ShardIds: shardIds,
+			}); err != nil {
+				return err
+			}
+			_, err := client.VolumeEcShardsDelete(context.Background(), &volume_server_pb.VolumeEcShardsDeleteRequest{
+				VolumeId:   uint32(v.Id),
+				Collection: v.Collection,
+				ShardIds:   shardIds,
+			})
+			return err
+		})
+		if err != nil {
+			glog.Warningf("remove ec shards %d.%v on %s: %v", v.Id, shardIds, dn.Url(), err)
+		}
+	}
+}
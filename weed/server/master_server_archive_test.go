@@ -0,0 +1,64 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func TestParseArchiveCollections(t *testing.T) {
+	collections, err := ParseArchiveCollections("logs, backup:8+3,")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(collections) != 2 || collections["logs"] != erasure_coding.DefaultScheme ||
+		collections["backup"] != (erasure_coding.Scheme{DataShards: 8, ParityShards: 3}) {
+		t.Errorf("archive collections %+v", collections)
+	}
+	if _, err = ParseArchiveCollections("logs:8"); err == nil {
+		t.Errorf("parsed the invalid scheme")
+	}
+}
+
+func TestPickAndSpreadArchiveVolumes(t *testing.T) {
+	topo, nodes := newTestTopology(32*1024, []testNodeLayout{
+		{"rack1", "a"}, {"rack1", "b"}, {"rack2", "c"},
+	})
+	volumeSizeLimit := uint64(1024 * 1024)
+	volume := func(id uint32, collection string, size uint64, readOnly bool) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{Id: id, Collection: collection, Size: size, ReadOnly: readOnly, Version: uint32(needle.CurrentVersion)}
+	}
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{
+		volume(1, "logs", volumeSizeLimit, false),   // full
+		volume(2, "logs", volumeSizeLimit/2, false), // still written
+		volume(3, "logs", volumeSizeLimit/2, true),  // read only
+		volume(4, "other", volumeSizeLimit, false),  // not archived
+	}, nodes["a"])
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{volume(1, "logs", volumeSizeLimit, false)}, nodes["c"])
+
+	collections := map[string]erasure_coding.Scheme{"logs": {DataShards: 4, ParityShards: 2}}
+	volumes := pickFullArchiveVolumes(collections, topo.ListDataNodes(), volumeSizeLimit)
+	if len(volumes) != 2 || volumes[0].volume.Id != 1 || volumes[1].volume.Id != 3 {
+		t.Fatalf("archive volumes %+v, expected volume 1 and 3", volumes)
+	}
+	if len(volumes[0].sources) != 2 || volumes[0].scheme != collections["logs"] {
+		t.Errorf("archive volume 1 on %d servers with %s", len(volumes[0].sources), volumes[0].scheme)
+	}
+
+	allocated, err := allocateEcShards(topo.ListDataNodes(), 6, "", nil, EcPlacementPolicy{})
+	if err != nil {
+		t.Fatalf("allocate: %v", err)
+	}
+	if len(allocated[nodes["c"]]) != 3 || len(allocated[nodes["a"]])+len(allocated[nodes["b"]]) != 3 {
+		t.Errorf("shards on a %v, b %v, c %v, expected spread evenly across the racks",
+			allocated[nodes["a"]], allocated[nodes["b"]], allocated[nodes["c"]])
+	}
+
+	nodes["c"].SetDraining(true)
+	allocated, _ = allocateEcShards(topo.ListDataNodes(), 6, "", nil, EcPlacementPolicy{})
+	if len(allocated[nodes["c"]]) != 0 || len(allocated[nodes["a"]]) != 3 || len(allocated[nodes["b"]]) != 3 {
+		t.Errorf("shards on a %v, b %v, c %v after draining c", allocated[nodes["a"]], allocated[nodes["b"]], allocated[nodes["c"]])
+	}
+}
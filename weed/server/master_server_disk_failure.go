@@ -0,0 +1,105 @@
+package weed_server
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// The volume servers report the volumes on their failing disks as read only and disk failing.
+// The leader re-replicates them one at a time, copying from a healthy replica if there is one,
+// and deletes them from the failing disk once the healthy replicas are enough.
+
+func (ms *MasterServer) loopFailingDisks() {
+	interval := ms.option.DrainInterval
+	if interval <= 0 {
+		interval = defaultDrainInterval
+	}
+	for {
+		time.Sleep(interval)
+		if ms.Topo.IsLeader() {
+			ms.replicateFromFailingDisks()
+		}
+	}
+}
+
+type failingDiskVolume struct {
+	volume  storage.VolumeInfo
+	failing *topology.DataNode
+	// a healthy replica to copy from, or the failing one if there is none
+	source *topology.DataNode
+	// the healthy replicas are enough, so the volume is just deleted from the failing disk
+	isReplicated bool
+}
+
+// pickFailingDiskVolume finds the volume with the lowest id on a failing disk.
+// The volumes of the draining volume servers are moved by the drain.
+func pickFailingDiskVolume(dataNodes []*topology.DataNode) (f *failingDiskVolume) {
+	for _, dn := range dataNodes {
+		if dn.IsDraining() {
+			continue
+		}
+		for _, v := range dn.GetVolumes() {
+			if v.DiskFailing && (f == nil || v.Id < f.volume.Id) {
+				f = &failingDiskVolume{volume: v, failing: dn}
+			}
+		}
+	}
+	if f == nil {
+		return nil
+	}
+	f.source = f.failing
+	healthyCount := 0
+	sort.Slice(dataNodes, func(i, j int) bool {
+		return dataNodes[i].Url() < dataNodes[j].Url()
+	})
+	for _, dn := range dataNodes {
+		if dn == f.failing {
+			continue
+		}
+		if v, err := dn.GetVolumesById(f.volume.Id); err == nil && !v.DiskFailing {
+			if healthyCount == 0 {
+				f.source = dn
+			}
+			healthyCount++
+		}
+	}
+	f.isReplicated = healthyCount >= f.volume.ReplicaPlacement.GetCopyCount()
+	return f
+}
+
+func (ms *MasterServer) replicateFromFailingDisks() {
+	dataNodes := ms.Topo.ListDataNodes()
+	f := pickFailingDiskVolume(dataNodes)
+	if f == nil {
+		return
+	}
+	v := f.volume
+	doneMoving, err := ms.startVolumeMove(v.Id, "failing disk")
+	if err != nil {
+		glog.V(1).Infof("skip replicating volume %d from the failing disk of %s: %v", v.Id, f.failing.Url(), err)
+		return
+	}
+	defer doneMoving()
+	defer ms.expectTopologyChange(v.Id, fmt.Sprintf("failing disk on %s", f.failing.Url()))()
+	if !f.isReplicated {
+		target := pickMoveTarget(f.failing, dataNodes, v.Id, v.DiskType, ms.option.PlacementCollections[v.Collection], false)
+		if target == nil {
+			glog.Warningf("no volume server to re-replicate volume %d on the failing disk of %s", v.Id, f.failing.Url())
+			return
+		}
+		glog.V(0).Infof("re-replicate volume %d on the failing disk of %s from %s to %s", v.Id, f.failing.Url(), f.source.Url(), target.Url())
+		if err := ms.copyVolume(v, f.source, target, v.DiskType, ms.option.DrainBytePerSecond); err != nil {
+			glog.Warningf("re-replicate volume %d on the failing disk of %s: %v", v.Id, f.failing.Url(), err)
+			return
+		}
+	}
+	glog.V(0).Infof("delete volume %d from the failing disk of %s", v.Id, f.failing.Url())
+	if err := ms.deleteVolume(v, f.failing); err != nil {
+		glog.Warningf("delete volume %d from the failing disk: %v", v.Id, err)
+	}
+}
@@ -0,0 +1,39 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func TestPickFailingDiskVolume(t *testing.T) {
+	topo, nodes := newTestTopology(32*1024, []testNodeLayout{{"rack1", "a"}, {"rack1", "b"}, {"rack1", "c"}})
+	replica := func(id uint32, diskFailing bool) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{Id: id, ReplicaPlacement: 1, Version: uint32(needle.CurrentVersion), ReadOnly: diskFailing, DiskFailing: diskFailing}
+	}
+	// volume 1 has a healthy replica on b, volume 2 has no other replica
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{replica(1, true), replica(2, true)}, nodes["a"])
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{replica(1, false)}, nodes["b"])
+
+	f := pickFailingDiskVolume(topo.ListDataNodes())
+	if f == nil || f.volume.Id != 1 || f.failing != nodes["a"] || f.source != nodes["b"] || f.isReplicated {
+		t.Fatalf("picked %+v, expected volume 1 copied from b", f)
+	}
+
+	// re-replicated to c
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{replica(1, false)}, nodes["c"])
+	if f = pickFailingDiskVolume(topo.ListDataNodes()); f == nil || f.volume.Id != 1 || !f.isReplicated {
+		t.Fatalf("picked %+v, expected volume 1 to be deleted", f)
+	}
+
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{replica(2, true)}, nodes["a"])
+	if f = pickFailingDiskVolume(topo.ListDataNodes()); f == nil || f.volume.Id != 2 || f.source != nodes["a"] || f.isReplicated {
+		t.Fatalf("picked %+v, expected volume 2 copied from the failing disk", f)
+	}
+
+	nodes["a"].SetDraining(true)
+	if f = pickFailingDiskVolume(topo.ListDataNodes()); f != nil {
+		t.Fatalf("picked %+v on the draining volume server", f)
+	}
+}
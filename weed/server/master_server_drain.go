@@ -0,0 +1,389 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// The leader moves the volumes and ec shards of each draining volume server one at a time,
+// pausing between the moves. The draining state is kept on the volume server and reported
+// in its heartbeat, so a new leader resumes the drains.
+
+const (
+	defaultDrainInterval = 10 * time.Second
+	// the moved volume is cold, or the draining volume server rejects the writes, so tailing mostly catches up the deletes
+	moveTailIdleTimeout = 5 * time.Second
+)
+
+type volumeServerDrain struct {
+	node              string
+	startedAt         time.Time
+	movedVolumes      map[needle.VolumeId]bool
+	movedEcShards     map[string]bool // <volume id>.<shard id>
+	remainingVolumes  int
+	remainingEcShards int
+	currentMove       string
+	lastError         string
+	isDone            bool
+}
+
+func newVolumeServerDrain(node string) *volumeServerDrain {
+	return &volumeServerDrain{
+		node:          node,
+		startedAt:     time.Now(),
+		movedVolumes:  make(map[needle.VolumeId]bool),
+		movedEcShards: make(map[string]bool),
+	}
+}
+
+func (ms *MasterServer) loopDrainingVolumeServers() {
+	interval := ms.option.DrainInterval
+	if interval <= 0 {
+		interval = defaultDrainInterval
+	}
+	for {
+		time.Sleep(interval)
+		if ms.Topo.IsLeader() {
+			ms.drainVolumeServers()
+		}
+	}
+}
+
+func (ms *MasterServer) drainVolumeServers() {
+	dataNodes := ms.Topo.ListDataNodes()
+
+	ms.drainsLock.Lock()
+	// resume the drains started before the leader change
+	for _, dn := range dataNodes {
+		if _, found := ms.drains[dn.Url()]; dn.IsDraining() && !found {
+			ms.drains[dn.Url()] = newVolumeServerDrain(dn.Url())
+		}
+	}
+	var drains []*volumeServerDrain
+	for _, d := range ms.drains {
+		if !d.isDone {
+			drains = append(drains, d)
+		}
+	}
+	ms.drainsLock.Unlock()
+
+	for _, d := range drains {
+		ms.drainOneStep(d, dataNodes)
+	}
+}
+
+// drainOneStep moves one volume, or one ec shard if no volumes are left
+func (ms *MasterServer) drainOneStep(d *volumeServerDrain, dataNodes []*topology.DataNode) {
+	dn := findDataNode(dataNodes, d.node)
+	if dn == nil {
+		ms.drainsLock.Lock()
+		if d.remainingVolumes == 0 && d.remainingEcShards == 0 && len(d.movedVolumes)+len(d.movedEcShards) > 0 {
+			// the drained volume server has exited
+			d.isDone = true
+		} else {
+			d.lastError = fmt.Sprintf("volume server %s is not connected", d.node)
+		}
+		ms.drainsLock.Unlock()
+		return
+	}
+
+	if !dn.IsDraining() {
+		// restarted, or the heartbeat has not caught up yet
+		if err := ms.askVolumeServerToDrain(dn, false); err != nil {
+			ms.updateDrain(d, "", err)
+			return
+		}
+		dn.SetDraining(true)
+	}
+
+	var volumes []storage.VolumeInfo
+	for _, v := range dn.GetVolumes() {
+		if !d.movedVolumes[v.Id] {
+			volumes = append(volumes, v)
+		}
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].Id < volumes[j].Id
+	})
+	type ecShard struct {
+		info    *erasure_coding.EcVolumeInfo
+		shardId erasure_coding.ShardId
+	}
+	var ecShards []ecShard
+	ecVolumes := dn.GetEcShards()
+	for _, ecInfo := range ecVolumes {
+		for _, shardId := range ecInfo.ShardBits.ShardIds() {
+			if !d.movedEcShards[ecShardKey(ecInfo.VolumeId, shardId)] {
+				ecShards = append(ecShards, ecShard{info: ecInfo, shardId: shardId})
+			}
+		}
+	}
+
+	ms.drainsLock.Lock()
+	d.remainingVolumes, d.remainingEcShards = len(volumes), len(ecShards)
+	if len(dn.GetVolumes()) == 0 && len(ecVolumes) == 0 {
+		glog.V(0).Infof("volume server %s is drained", d.node)
+		d.isDone = true
+	}
+	ms.drainsLock.Unlock()
+
+	switch {
+	case len(volumes) > 0:
+		v := volumes[0]
+		target := pickMoveTarget(dn, dataNodes, v.Id, v.DiskType, ms.option.PlacementCollections[v.Collection], false)
+		if target == nil {
+			ms.updateDrain(d, "", fmt.Errorf("no volume server to move volume %d to", v.Id))
+			return
+		}
+		doneMoving, err := ms.startVolumeMove(v.Id, fmt.Sprintf("drain %s", dn.Url()))
+		if err != nil {
+			ms.updateDrain(d, "", err)
+			return
+		}
+		move := fmt.Sprintf("volume %d => %s", v.Id, target.Url())
+		ms.updateDrain(d, move, nil)
+		done := ms.expectTopologyChange(v.Id, fmt.Sprintf("drain %s", dn.Url()))
+		err = ms.moveVolume(v, dn, target, v.DiskType, ms.option.DrainBytePerSecond)
+		done()
+		doneMoving()
+		ms.updateDrain(d, "", err)
+		if err == nil {
+			ms.drainsLock.Lock()
+			d.movedVolumes[v.Id] = true
+			d.remainingVolumes--
+			ms.drainsLock.Unlock()
+		}
+	case len(ecShards) > 0:
+		s := ecShards[0]
+		targets := ms.option.EcPlacementPolicy.ecShardTargets(dataNodes, dn, countEcShards(dataNodes, s.info.VolumeId))
+		target := pickMoveTarget(dn, targets, s.info.VolumeId, s.info.DiskType, ms.option.PlacementCollections[s.info.Collection], true)
+		if target == nil {
+			ms.updateDrain(d, "", fmt.Errorf("no volume server to move ec shard %d.%d to", s.info.VolumeId, s.shardId))
+			return
+		}
+		doneMoving, err := ms.startVolumeMove(s.info.VolumeId, fmt.Sprintf("drain %s", dn.Url()))
+		if err != nil {
+			ms.updateDrain(d, "", err)
+			return
+		}
+		move := fmt.Sprintf("ec shard %d.%d => %s", s.info.VolumeId, s.shardId, target.Url())
+		ms.updateDrain(d, move, nil)
+		done := ms.expectTopologyChange(s.info.VolumeId, fmt.Sprintf("drain %s", dn.Url()))
+		err = ms.moveEcShard(s.info, s.shardId, dn, target)
+		done()
+		doneMoving()
+		ms.updateDrain(d, "", err)
+		if err == nil {
+			ms.drainsLock.Lock()
+			d.movedEcShards[ecShardKey(s.info.VolumeId, s.shardId)] = true
+			d.remainingEcShards--
+			ms.drainsLock.Unlock()
+		}
+	}
+}
+
+func (ms *MasterServer) updateDrain(d *volumeServerDrain, currentMove string, err error) {
+	ms.drainsLock.Lock()
+	defer ms.drainsLock.Unlock()
+	d.currentMove = currentMove
+	if err != nil {
+		glog.Warningf("drain volume server %s: %v", d.node, err)
+		d.lastError = err.Error()
+	}
+}
+
+func ecShardKey(vid needle.VolumeId, shardId erasure_coding.ShardId) string {
+	return fmt.Sprintf("%d.%d", vid, shardId)
+}
+
+// pickMoveTarget prefers the volume servers with fewer copies or ec shards of the volume,
+// then the ones closer to the source to keep the replica placement, then the ones with more free slots.
+// Only the volume servers matching the placement constraint of the collection are picked.
+func pickMoveTarget(source *topology.DataNode, dataNodes []*topology.DataNode, vid needle.VolumeId, diskType string, placement *topology.PlacementConstraint, isEcShard bool) (target *topology.DataNode) {
+	option := &topology.VolumeGrowOption{DiskType: types.ToDiskType(diskType), Placement: placement}
+	var best [3]int64
+	for _, dn := range dataNodes {
+		if dn == source || dn.IsDraining() || dn.IsTerminating {
+			continue
+		}
+		free := dn.AvailableSpaceFor(option)
+		if free <= 0 {
+			continue
+		}
+		var distance int64
+		switch {
+		case dn.GetRack() == source.GetRack():
+			distance = 0
+		case dn.GetDataCenter() == source.GetDataCenter():
+			distance = 1
+		default:
+			distance = 2
+		}
+		var sameVolumeCount int64
+		if isEcShard {
+			for _, ecInfo := range dn.GetEcShards() {
+				if ecInfo.VolumeId == vid {
+					sameVolumeCount += int64(ecInfo.ShardIdCount())
+				}
+			}
+		} else if dn.HasVolumesById(vid) {
+			continue
+		} else if distance > 0 {
+			// avoid the racks already with the other replicas
+			for _, n := range dn.GetRack().Children() {
+				if n.(*topology.DataNode).HasVolumesById(vid) {
+					sameVolumeCount++
+				}
+			}
+		}
+		score := [3]int64{sameVolumeCount, distance, -free}
+		if target == nil || lessScore(score, best) {
+			target, best = dn, score
+		}
+	}
+	return
+}
+
+func lessScore(a, b [3]int64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// moveVolume copies the volume to the disk type of the target, catches up the latest changes, and deletes it from the source
+func (ms *MasterServer) moveVolume(v storage.VolumeInfo, source, target *topology.DataNode, diskType string, bytePerSecond int64) error {
+	glog.V(0).Infof("move volume %d from %s to %s disk type %q", v.Id, source.Url(), target.Url(), diskType)
+	if err := ms.copyVolume(v, source, target, diskType, bytePerSecond); err != nil {
+		return err
+	}
+	return ms.deleteVolume(v, source)
+}
+
+// copyVolume copies the volume to the disk type of the target, and catches up the latest changes
+func (ms *MasterServer) copyVolume(v storage.VolumeInfo, source, target *topology.DataNode, diskType string, bytePerSecond int64) error {
+	defer ms.startOperation(fmt.Sprintf("copy volume %d from %s to %s", v.Id, source.Url(), target.Url()))()
+	sourceAddress, targetAddress := source.ServerAddress(), target.ServerAddress()
+
+	var lastAppendAtNs uint64
+	err := operation.WithVolumeServerClient(true, targetAddress, ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		stream, err := client.VolumeCopy(context.Background(), &volume_server_pb.VolumeCopyRequest{
+			VolumeId:        uint32(v.Id),
+			SourceDataNode:  string(sourceAddress),
+			DiskType:        diskType,
+			IoBytePerSecond: bytePerSecond,
+		})
+		if err != nil {
+			return err
+		}
+		for {
+			resp, recvErr := stream.Recv()
+			if recvErr == io.EOF {
+				return nil
+			}
+			if recvErr != nil {
+				return recvErr
+			}
+			if resp.LastAppendAtNs != 0 {
+				lastAppendAtNs = resp.LastAppendAtNs
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("copy volume %d from %s to %s: %v", v.Id, source.Url(), target.Url(), err)
+	}
+
+	err = operation.WithVolumeServerClient(true, targetAddress, ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		_, err := client.VolumeTailReceiver(context.Background(), &volume_server_pb.VolumeTailReceiverRequest{
+			VolumeId:           uint32(v.Id),
+			SinceNs:            lastAppendAtNs,
+			IdleTimeoutSeconds: uint32(moveTailIdleTimeout.Seconds()),
+			SourceVolumeServer: string(sourceAddress),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("tail volume %d from %s to %s: %v", v.Id, source.Url(), target.Url(), err)
+	}
+	return nil
+}
+
+func (ms *MasterServer) deleteVolume(v storage.VolumeInfo, dn *topology.DataNode) error {
+	err := operation.WithVolumeServerClient(false, dn.ServerAddress(), ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		_, err := client.VolumeDelete(context.Background(), &volume_server_pb.VolumeDeleteRequest{
+			VolumeId: uint32(v.Id),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("delete volume %d from %s: %v", v.Id, dn.Url(), err)
+	}
+	return nil
+}
+
+func (ms *MasterServer) moveEcShard(ecInfo *erasure_coding.EcVolumeInfo, shardId erasure_coding.ShardId, source, target *topology.DataNode) error {
+	sourceAddress, targetAddress := source.ServerAddress(), target.ServerAddress()
+	shardIds := []uint32{uint32(shardId)}
+	glog.V(0).Infof("move ec shard %d.%d from %s to %s", ecInfo.VolumeId, shardId, source.Url(), target.Url())
+	defer ms.startOperation(fmt.Sprintf("move ec shard %d.%d from %s to %s", ecInfo.VolumeId, shardId, source.Url(), target.Url()))()
+
+	err := operation.WithVolumeServerClient(false, targetAddress, ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		if _, err := client.VolumeEcShardsCopy(context.Background(), &volume_server_pb.VolumeEcShardsCopyRequest{
+			VolumeId:       uint32(ecInfo.VolumeId),
+			Collection:     ecInfo.Collection,
+			ShardIds:       shardIds,
+			CopyEcxFile:    true,
+			CopyEcjFile:    true,
+			CopyVifFile:    true,
+			SourceDataNode: string(sourceAddress),
+		}); err != nil {
+			return fmt.Errorf("copy: %v", err)
+		}
+		if _, err := client.VolumeEcShardsMount(context.Background(), &volume_server_pb.VolumeEcShardsMountRequest{
+			VolumeId:   uint32(ecInfo.VolumeId),
+			Collection: ecInfo.Collection,
+			ShardIds:   shardIds,
+		}); err != nil {
+			return fmt.Errorf("mount: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("move ec shard %d.%d from %s to %s: %v", ecInfo.VolumeId, shardId, source.Url(), target.Url(), err)
+	}
+
+	err = operation.WithVolumeServerClient(false, sourceAddress, ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		if _, err := client.VolumeEcShardsUnmount(context.Background(), &volume_server_pb.VolumeEcShardsUnmountRequest{
+			VolumeId: uint32(ecInfo.VolumeId),
+			ShardIds: shardIds,
+		}); err != nil {
+			return fmt.Errorf("unmount: %v", err)
+		}
+		if _, err := client.VolumeEcShardsDelete(context.Background(), &volume_server_pb.VolumeEcShardsDeleteRequest{
+			VolumeId:   uint32(ecInfo.VolumeId),
+			Collection: ecInfo.Collection,
+			ShardIds:   shardIds,
+		}); err != nil {
+			return fmt.Errorf("delete: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("remove ec shard %d.%d from %s: %v", ecInfo.VolumeId, shardId, source.Url(), err)
+	}
+	return nil
+}
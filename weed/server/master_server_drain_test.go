@@ -0,0 +1,48 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+func TestPickDrainTarget(t *testing.T) {
+	topo, nodes := newTestTopology(32*1024, []testNodeLayout{
+		{"rack1", "a"}, {"rack1", "b"}, {"rack2", "c"}, {"rack2", "d"}, {"rack3", "e"},
+	})
+	replica := &master_pb.VolumeInformationMessage{Id: 1, ReplicaPlacement: 10, Version: uint32(needle.CurrentVersion)}
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{replica}, nodes["a"])
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{replica}, nodes["c"])
+	topo.SyncDataNodeEcShards([]*master_pb.VolumeEcShardInformationMessage{{Id: 2, EcIndexBits: 0x7}}, nodes["a"])
+	topo.SyncDataNodeEcShards([]*master_pb.VolumeEcShardInformationMessage{{Id: 2, EcIndexBits: 0x38}}, nodes["b"])
+
+	source := nodes["a"]
+	source.SetDraining(true)
+	if free := source.AvailableSpaceFor(&topology.VolumeGrowOption{}); free != 0 {
+		t.Errorf("draining node has %d free slots", free)
+	}
+
+	dataNodes := topo.ListDataNodes()
+	if target := pickMoveTarget(source, dataNodes, 1, "", nil, false); target != nodes["b"] {
+		t.Errorf("volume moved to %v, expected the same rack", target)
+	}
+	// b already has ec shards of volume 2
+	if target := pickMoveTarget(source, dataNodes, 2, "", nil, true); target == nil || target == nodes["b"] {
+		t.Errorf("ec shard moved to %v", target)
+	}
+
+	nodes["b"].SetDraining(true)
+	// rack2 already has the other replica
+	if target := pickMoveTarget(source, dataNodes, 1, "", nil, false); target != nodes["e"] {
+		t.Errorf("volume moved to %v, expected the rack without replicas", target)
+	}
+
+	for _, n := range []string{"c", "d", "e"} {
+		nodes[n].SetDraining(true)
+	}
+	if target := pickMoveTarget(source, dataNodes, 1, "", nil, false); target != nil {
+		t.Errorf("volume moved to draining node %v", target)
+	}
+}
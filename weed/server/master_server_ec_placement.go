@@ -0,0 +1,175 @@
+package weed_server
+
+import (
+	"sort"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// The ec shards of each volume are capped per rack and per data center, e.g. to at most the parity shards,
+// so losing any one rack or data center keeps the volume readable. The leader places the new ec shards
+// within the caps, and periodically moves the shards away from the racks and data centers over the caps.
+
+const ecPlacementCheckInterval = 5 * time.Minute
+
+type EcPlacementPolicy struct {
+	MaxShardsPerRack       int // 0 for no limit
+	MaxShardsPerDataCenter int // 0 for no limit
+}
+
+func (p EcPlacementPolicy) isEnabled() bool {
+	return p.MaxShardsPerRack > 0 || p.MaxShardsPerDataCenter > 0
+}
+
+// ecShardCounts counts the ec shards of one volume on each rack and data center
+type ecShardCounts struct {
+	racks       map[*topology.Rack]int
+	dataCenters map[*topology.DataCenter]int
+}
+
+func newEcShardCounts() *ecShardCounts {
+	return &ecShardCounts{
+		racks:       make(map[*topology.Rack]int),
+		dataCenters: make(map[*topology.DataCenter]int),
+	}
+}
+
+func (c *ecShardCounts) add(dn *topology.DataNode, delta int) {
+	c.racks[dn.GetRack()] += delta
+	c.dataCenters[dn.GetDataCenter()] += delta
+}
+
+// allows checks one more shard on the volume server stays within the caps
+func (p EcPlacementPolicy) allows(c *ecShardCounts, dn *topology.DataNode) bool {
+	if p.MaxShardsPerRack > 0 && c.racks[dn.GetRack()] >= p.MaxShardsPerRack {
+		return false
+	}
+	if p.MaxShardsPerDataCenter > 0 && c.dataCenters[dn.GetDataCenter()] >= p.MaxShardsPerDataCenter {
+		return false
+	}
+	return true
+}
+
+// isViolatedBy checks whether the rack or the data center of the volume server is over the caps
+func (p EcPlacementPolicy) isViolatedBy(c *ecShardCounts, dn *topology.DataNode) bool {
+	return (p.MaxShardsPerRack > 0 && c.racks[dn.GetRack()] > p.MaxShardsPerRack) ||
+		(p.MaxShardsPerDataCenter > 0 && c.dataCenters[dn.GetDataCenter()] > p.MaxShardsPerDataCenter)
+}
+
+// countEcShards counts the shards of the ec volume on each rack and data center
+func countEcShards(dataNodes []*topology.DataNode, vid needle.VolumeId) *ecShardCounts {
+	counts := newEcShardCounts()
+	for _, dn := range dataNodes {
+		for _, ecInfo := range dn.GetEcShards() {
+			if ecInfo.VolumeId == vid {
+				counts.add(dn, ecInfo.ShardIdCount())
+			}
+		}
+	}
+	return counts
+}
+
+// ecShardTargets lists the volume servers to move one ec shard of the volume to, within the caps
+func (p EcPlacementPolicy) ecShardTargets(dataNodes []*topology.DataNode, source *topology.DataNode, counts *ecShardCounts) (targets []*topology.DataNode) {
+	if !p.isEnabled() {
+		return dataNodes
+	}
+	counts.add(source, -1)
+	defer counts.add(source, 1)
+	for _, dn := range dataNodes {
+		if p.allows(counts, dn) {
+			targets = append(targets, dn)
+		}
+	}
+	return
+}
+
+type ecShardMove struct {
+	info    *erasure_coding.EcVolumeInfo
+	shardId erasure_coding.ShardId
+	source  *topology.DataNode
+	target  *topology.DataNode
+}
+
+func (ms *MasterServer) loopEcPlacement() {
+	policy := ms.option.EcPlacementPolicy
+	if !policy.isEnabled() {
+		return
+	}
+	for {
+		time.Sleep(ecPlacementCheckInterval)
+		if !ms.Topo.IsLeader() {
+			continue
+		}
+		for _, m := range pickEcPlacementMoves(policy, ms.Topo.ListDataNodes(), ms.option.PlacementCollections) {
+			doneMoving, err := ms.startVolumeMove(m.info.VolumeId, "ec shard placement")
+			if err != nil {
+				glog.V(1).Infof("skip fixing the ec shard placement: %v", err)
+				continue
+			}
+			done := ms.expectTopologyChange(m.info.VolumeId, "ec shard placement")
+			if err := ms.moveEcShard(m.info, m.shardId, m.source, m.target); err != nil {
+				glog.Warningf("fix ec shard placement: %v", err)
+			}
+			done()
+			doneMoving()
+		}
+	}
+}
+
+// pickEcPlacementMoves plans the moves of the ec shards out of the racks and data centers over the caps,
+// each from the volume server with the most shards of the volume there.
+func pickEcPlacementMoves(policy EcPlacementPolicy, dataNodes []*topology.DataNode, placements map[string]*topology.PlacementConstraint) (moves []*ecShardMove) {
+	type shardHolder struct {
+		dn     *topology.DataNode
+		info   *erasure_coding.EcVolumeInfo
+		shards []erasure_coding.ShardId
+	}
+	holders := make(map[needle.VolumeId][]*shardHolder)
+	var vids []needle.VolumeId
+	for _, dn := range dataNodes {
+		for _, ecInfo := range dn.GetEcShards() {
+			if _, found := holders[ecInfo.VolumeId]; !found {
+				vids = append(vids, ecInfo.VolumeId)
+			}
+			holders[ecInfo.VolumeId] = append(holders[ecInfo.VolumeId], &shardHolder{dn: dn, info: ecInfo, shards: ecInfo.ShardIds()})
+		}
+	}
+	sort.Slice(vids, func(i, j int) bool {
+		return vids[i] < vids[j]
+	})
+
+	for _, vid := range vids {
+		counts := newEcShardCounts()
+		for _, h := range holders[vid] {
+			counts.add(h.dn, len(h.shards))
+		}
+		for {
+			var source *shardHolder
+			for _, h := range holders[vid] {
+				if len(h.shards) > 0 && policy.isViolatedBy(counts, h.dn) && (source == nil || len(h.shards) > len(source.shards)) {
+					source = h
+				}
+			}
+			if source == nil {
+				break
+			}
+			targets := policy.ecShardTargets(dataNodes, source.dn, counts)
+			target := pickMoveTarget(source.dn, targets, vid, source.info.DiskType, placements[source.info.Collection], true)
+			if target == nil {
+				glog.Warningf("ec volume %d has too many shards on the rack or data center of %s, and no volume server to move them to", vid, source.dn.Url())
+				break
+			}
+			shardId := source.shards[len(source.shards)-1]
+			source.shards = source.shards[:len(source.shards)-1]
+			counts.add(source.dn, -1)
+			counts.add(target, 1)
+			moves = append(moves, &ecShardMove{info: source.info, shardId: shardId, source: source.dn, target: target})
+		}
+	}
+	return
+}
@@ -0,0 +1,64 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+func TestEcPlacementPolicy(t *testing.T) {
+	topo, nodes := newTestTopology(32*1024, []testNodeLayout{
+		{"rack1", "a"}, {"rack1", "b"}, {"rack2", "c"}, {"rack3", "d"},
+	})
+
+	policy := EcPlacementPolicy{MaxShardsPerRack: 2}
+	allocated, err := allocateEcShards(topo.ListDataNodes(), 6, "", nil, policy)
+	if err != nil {
+		t.Fatalf("allocate: %v", err)
+	}
+	if len(allocated[nodes["a"]])+len(allocated[nodes["b"]]) != 2 || len(allocated[nodes["c"]]) != 2 || len(allocated[nodes["d"]]) != 2 {
+		t.Errorf("shards on a %v, b %v, c %v, d %v, expected 2 on each rack",
+			allocated[nodes["a"]], allocated[nodes["b"]], allocated[nodes["c"]], allocated[nodes["d"]])
+	}
+	if _, err = allocateEcShards(topo.ListDataNodes(), 6, "", nil, EcPlacementPolicy{MaxShardsPerRack: 1}); err == nil {
+		t.Errorf("allocated 6 shards on 3 racks with 1 shard per rack")
+	}
+	if _, err = allocateEcShards(topo.ListDataNodes(), 6, "", nil, EcPlacementPolicy{MaxShardsPerDataCenter: 5}); err == nil {
+		t.Errorf("allocated 6 shards on 1 data center with 5 shards per data center")
+	}
+
+	// all 6 shards of volume 7 on rack1
+	topo.SyncDataNodeEcShards([]*master_pb.VolumeEcShardInformationMessage{{Id: 7, EcIndexBits: 0x7}}, nodes["a"])
+	topo.SyncDataNodeEcShards([]*master_pb.VolumeEcShardInformationMessage{{Id: 7, EcIndexBits: 0x38}}, nodes["b"])
+	moves := pickEcPlacementMoves(policy, topo.ListDataNodes(), nil)
+	if len(moves) != 4 {
+		t.Fatalf("%d moves, expected 4", len(moves))
+	}
+	targets := make(map[*topology.DataNode]int)
+	for _, m := range moves {
+		if m.source.GetRack() != nodes["a"].GetRack() || m.info.VolumeId != 7 {
+			t.Errorf("moved ec shard %d.%d from %s", m.info.VolumeId, m.shardId, m.source.Id())
+		}
+		targets[m.target]++
+	}
+	if targets[nodes["c"]] != 2 || targets[nodes["d"]] != 2 {
+		t.Errorf("moved %d shards to c and %d shards to d, expected 2 each", targets[nodes["c"]], targets[nodes["d"]])
+	}
+
+	// no room left on the other racks
+	nodes["c"].SetDraining(true)
+	nodes["d"].SetDraining(true)
+	if moves = pickEcPlacementMoves(policy, topo.ListDataNodes(), nil); len(moves) != 0 {
+		t.Errorf("%d moves to the draining volume servers", len(moves))
+	}
+
+	// a draining volume server moves its ec shards only within the caps
+	counts := countEcShards(topo.ListDataNodes(), 7)
+	if targets := policy.ecShardTargets(topo.ListDataNodes(), nodes["a"], counts); len(targets) != 2 {
+		t.Errorf("%d targets out of rack1, expected c and d", len(targets))
+	}
+	if counts.racks[nodes["a"].GetRack()] != 6 {
+		t.Errorf("counted %d shards on rack1 after listing the targets", counts.racks[nodes["a"].GetRack()])
+	}
+}
@@ -0,0 +1,57 @@
+package weed_server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// ParseVolumeGrowthCollections parses the semicolon separated collections, each with its growth policy,
+// e.g. "logs:threshold=0.8,count=2,disk=hdd,maxVolumes=100;images:count=4,maxSize=10TiB"
+func ParseVolumeGrowthCollections(s string) (map[string]*topology.VolumeGrowthPolicy, error) {
+	policies := make(map[string]*topology.VolumeGrowthPolicy)
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		collection, settings, hasSettings := strings.Cut(item, ":")
+		collection = strings.TrimSpace(collection)
+		if !hasSettings {
+			return nil, fmt.Errorf("volume growth collection %s: missing the policy", collection)
+		}
+		policy := &topology.VolumeGrowthPolicy{}
+		for _, setting := range strings.Split(settings, ",") {
+			key, value, hasValue := strings.Cut(strings.TrimSpace(setting), "=")
+			if !hasValue {
+				return nil, fmt.Errorf("volume growth collection %s: expect key=value in %q", collection, setting)
+			}
+			var err error
+			switch value = strings.TrimSpace(value); key {
+			case "threshold":
+				policy.Threshold, err = strconv.ParseFloat(value, 64)
+				if err == nil && (policy.Threshold <= 0 || policy.Threshold > 1) {
+					err = fmt.Errorf("not within (0, 1]")
+				}
+			case "count":
+				policy.Count, err = strconv.Atoi(value)
+			case "disk":
+				policy.DiskType = value
+			case "maxVolumes":
+				policy.MaxVolumeCount, err = strconv.Atoi(value)
+			case "maxSize":
+				policy.MaxSize, err = util.ParseBytes(value)
+			default:
+				err = fmt.Errorf("unknown setting")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("volume growth collection %s: %s=%s: %v", collection, key, value, err)
+			}
+		}
+		policies[collection] = policy
+	}
+	return policies, nil
+}
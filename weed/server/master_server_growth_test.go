@@ -0,0 +1,24 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+func TestParseVolumeGrowthCollections(t *testing.T) {
+	policies, err := ParseVolumeGrowthCollections("logs:threshold=0.8,count=2,disk=ssd,maxVolumes=100; images:maxSize=1KiB;")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(policies) != 2 ||
+		*policies["logs"] != (topology.VolumeGrowthPolicy{Threshold: 0.8, Count: 2, DiskType: "ssd", MaxVolumeCount: 100}) ||
+		*policies["images"] != (topology.VolumeGrowthPolicy{MaxSize: 1024}) {
+		t.Errorf("policies %+v", policies)
+	}
+	for _, s := range []string{"logs", "logs:count", "logs:threshold=2", "logs:size=1"} {
+		if _, err = ParseVolumeGrowthCollections(s); err == nil {
+			t.Errorf("parsed the invalid policy %q", s)
+		}
+	}
+}
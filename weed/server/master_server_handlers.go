@@ -73,7 +73,7 @@ func (ms *MasterServer) findVolumeLocation(collection, vid string) operation.Loo
 			machines := ms.Topo.Lookup(collection, volumeId)
 			for _, loc := range machines {
 				locations = append(locations, operation.Location{
-					Url: loc.Url(), PublicUrl: loc.PublicUrl, DataCenter: loc.GetDataCenterId(),
+					Url: loc.Url(), PublicUrl: loc.PublicUrl, DataCenter: loc.GetDataCenterId(), Rack: loc.GetRackId(),
 				})
 			}
 		}
@@ -81,7 +81,7 @@ func (ms *MasterServer) findVolumeLocation(collection, vid string) operation.Loo
 		machines, getVidLocationsErr := ms.MasterClient.GetVidLocations(vid)
 		for _, loc := range machines {
 			locations = append(locations, operation.Location{
-				Url: loc.Url, PublicUrl: loc.PublicUrl, DataCenter: loc.DataCenter,
+				Url: loc.Url, PublicUrl: loc.PublicUrl, DataCenter: loc.DataCenter, Rack: loc.Rack,
 			})
 		}
 		err = getVidLocationsErr
@@ -116,6 +116,10 @@ func (ms *MasterServer) dirAssignHandler(w http.ResponseWriter, r *http.Request)
 		writeJsonQuiet(w, r, http.StatusNotAcceptable, operation.AssignResult{Error: err.Error()})
 		return
 	}
+	if err = ms.Topo.CheckCollectionQuotaForWrite(option.Collection); err != nil {
+		writeJsonQuiet(w, r, http.StatusForbidden, operation.AssignResult{Error: err.Error()})
+		return
+	}
 
 	vl := ms.Topo.GetVolumeLayout(option.Collection, option.ReplicaPlacement, option.Ttl, option.DiskType)
 
@@ -15,7 +15,6 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/storage/backend/memory_map"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
-	"github.com/seaweedfs/seaweedfs/weed/storage/types"
 	"github.com/seaweedfs/seaweedfs/weed/topology"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 )
@@ -83,7 +82,9 @@ func (ms *MasterServer) volumeGrowHandler(w http.ResponseWriter, r *http.Request
 			err = fmt.Errorf("only %d volumes left, not enough for %d", ms.Topo.AvailableSpaceFor(option), count*option.ReplicaPlacement.GetCopyCount())
 		} else {
 			var newVidLocations []*master_pb.VolumeLocation
+			done := ms.expectVolumeGrowth(option.Collection, fmt.Sprintf("volume growth requested by %s", r.RemoteAddr))
 			newVidLocations, err = ms.vg.GrowByCountAndType(ms.grpcDialOption, count, option, ms.Topo)
+			done()
 			count = len(newVidLocations)
 		}
 	} else {
@@ -136,10 +137,7 @@ func (ms *MasterServer) submitFromMasterServerHandler(w http.ResponseWriter, r *
 }
 
 func (ms *MasterServer) getVolumeGrowOption(r *http.Request) (*topology.VolumeGrowOption, error) {
-	replicationString := r.FormValue("replication")
-	if replicationString == "" {
-		replicationString = ms.option.DefaultReplicaPlacement
-	}
+	replicationString := ms.replicationOf(r.FormValue("collection"), r.FormValue("replication"))
 	replicaPlacement, err := super_block.NewReplicaPlacementFromString(replicationString)
 	if err != nil {
 		return nil, err
@@ -152,7 +150,11 @@ func (ms *MasterServer) getVolumeGrowOption(r *http.Request) (*topology.VolumeGr
 	if err != nil {
 		return nil, err
 	}
-	diskType := types.ToDiskType(r.FormValue("disk"))
+	diskType := ms.Topo.GrowDiskType(r.FormValue("collection"), r.FormValue("disk"))
+	placement, err := ms.placementOf(r.FormValue("collection"), r.FormValue("placement"))
+	if err != nil {
+		return nil, err
+	}
 
 	preallocate := ms.preallocateSize
 	if r.FormValue("preallocate") != "" {
@@ -171,6 +173,7 @@ func (ms *MasterServer) getVolumeGrowOption(r *http.Request) (*topology.VolumeGr
 		Rack:               r.FormValue("rack"),
 		DataNode:           r.FormValue("dataNode"),
 		MemoryMapMaxSizeMb: memoryMapMaxSizeMb,
+		Placement:          placement,
 	}
 	return volumeGrowOption, nil
 }
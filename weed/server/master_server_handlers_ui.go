@@ -1,14 +1,21 @@
 package weed_server
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	hashicorpRaft "github.com/hashicorp/raft"
 	"github.com/seaweedfs/raft"
 
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
 	ui "github.com/seaweedfs/seaweedfs/weed/server/master_ui"
 	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
@@ -56,3 +63,192 @@ func (ms *MasterServer) uiStatusHandler(w http.ResponseWriter, r *http.Request)
 		ui.StatusNewRaftTpl.Execute(w, args)
 	}
 }
+
+// masterOperation is a long running operation of the leader, e.g. a volume copy, shown on the status page
+type masterOperation struct {
+	description string
+	startedAt   time.Time
+}
+
+// startOperation shows the operation on the status page until the returned function is called
+func (ms *MasterServer) startOperation(description string) (done func()) {
+	op := &masterOperation{description: description, startedAt: time.Now()}
+	ms.operationsLock.Lock()
+	ms.operations[op] = true
+	ms.operationsLock.Unlock()
+	return func() {
+		ms.operationsLock.Lock()
+		delete(ms.operations, op)
+		ms.operationsLock.Unlock()
+	}
+}
+
+type uiTopology struct {
+	VolumeSizeLimit uint64         `json:"volumeSizeLimit"`
+	DataCenters     []uiDataCenter `json:"dataCenters"`
+	EcVolumes       []*uiEcVolume  `json:"ecVolumes"`
+	Operations      []uiOperation  `json:"operations"`
+}
+
+type uiDataCenter struct {
+	Id    string   `json:"id"`
+	Racks []uiRack `json:"racks"`
+}
+
+type uiRack struct {
+	Id    string   `json:"id"`
+	Nodes []uiNode `json:"nodes"`
+}
+
+type uiNode struct {
+	Url       string   `json:"url"`
+	PublicUrl string   `json:"publicUrl"`
+	State     string   `json:"state"` // draining, terminating, maintenance, or empty
+	Disks     []uiDisk `json:"disks"`
+}
+
+type uiDisk struct {
+	Type        string     `json:"type"`
+	MaxVolumes  int64      `json:"maxVolumes"`
+	EcShards    int        `json:"ecShards"`
+	Utilization float64    `json:"utilization"` // of the volume slots, the ec shards counted by the data shards per slot
+	Volumes     []uiVolume `json:"volumes"`
+}
+
+type uiVolume struct {
+	Id         uint32 `json:"id"`
+	Collection string `json:"collection"`
+	Size       uint64 `json:"size"`
+	State      string `json:"state"` // writable, full, readonly, remote, or failing
+}
+
+type uiEcVolume struct {
+	Id          uint32          `json:"id"`
+	Collection  string          `json:"collection"`
+	TotalShards int             `json:"totalShards"`
+	Locations   []uiEcLocations `json:"locations"`
+}
+
+type uiEcLocations struct {
+	Url      string   `json:"url"`
+	Rack     string   `json:"rack"`
+	ShardIds []uint32 `json:"shardIds"`
+}
+
+type uiOperation struct {
+	Description string `json:"description"`
+	StartedAtNs int64  `json:"startedAtNs"`
+}
+
+// uiTopologyHandler serves the topology for the status page to refresh itself
+func (ms *MasterServer) uiTopologyHandler(w http.ResponseWriter, r *http.Request) {
+	t := toUiTopology(ms.Topo.ListDataNodes(), uint64(ms.option.VolumeSizeLimitMB)*1024*1024)
+
+	if vid, found := ms.Topo.VacuumingVolumeId(); found {
+		t.Operations = append(t.Operations, uiOperation{Description: fmt.Sprintf("vacuum volume %d", vid)})
+	}
+	ms.operationsLock.Lock()
+	for op := range ms.operations {
+		t.Operations = append(t.Operations, uiOperation{Description: op.description, StartedAtNs: op.startedAt.UnixNano()})
+	}
+	ms.operationsLock.Unlock()
+	sort.Slice(t.Operations, func(i, j int) bool {
+		return t.Operations[i].StartedAtNs < t.Operations[j].StartedAtNs
+	})
+
+	writeJsonQuiet(w, r, http.StatusOK, t)
+}
+
+func toUiTopology(dataNodes []*topology.DataNode, volumeSizeLimit uint64) *uiTopology {
+	t := &uiTopology{VolumeSizeLimit: volumeSizeLimit}
+	ecVolumes := make(map[needle.VolumeId]*uiEcVolume)
+	for _, dn := range dataNodes {
+		dcId, rackId := dn.GetDataCenterId(), dn.GetRackId()
+		dcIndex := sort.Search(len(t.DataCenters), func(i int) bool { return t.DataCenters[i].Id >= dcId })
+		if dcIndex == len(t.DataCenters) || t.DataCenters[dcIndex].Id != dcId {
+			t.DataCenters = append(t.DataCenters[:dcIndex], append([]uiDataCenter{{Id: dcId}}, t.DataCenters[dcIndex:]...)...)
+		}
+		dc := &t.DataCenters[dcIndex]
+		rackIndex := sort.Search(len(dc.Racks), func(i int) bool { return dc.Racks[i].Id >= rackId })
+		if rackIndex == len(dc.Racks) || dc.Racks[rackIndex].Id != rackId {
+			dc.Racks = append(dc.Racks[:rackIndex], append([]uiRack{{Id: rackId}}, dc.Racks[rackIndex:]...)...)
+		}
+		rack := &dc.Racks[rackIndex]
+		rack.Nodes = append(rack.Nodes, toUiNode(dn, volumeSizeLimit))
+
+		for _, ecInfo := range dn.GetEcShards() {
+			ev, found := ecVolumes[ecInfo.VolumeId]
+			if !found {
+				ev = &uiEcVolume{Id: uint32(ecInfo.VolumeId), Collection: ecInfo.Collection, TotalShards: ecInfo.Scheme.TotalShards()}
+				ecVolumes[ecInfo.VolumeId] = ev
+				t.EcVolumes = append(t.EcVolumes, ev)
+			}
+			location := uiEcLocations{Url: dn.Url(), Rack: rackId}
+			for _, shardId := range ecInfo.ShardBits.ShardIds() {
+				location.ShardIds = append(location.ShardIds, uint32(shardId))
+			}
+			ev.Locations = append(ev.Locations, location)
+		}
+	}
+	for _, dc := range t.DataCenters {
+		for _, rack := range dc.Racks {
+			sort.Slice(rack.Nodes, func(i, j int) bool {
+				return rack.Nodes[i].Url < rack.Nodes[j].Url
+			})
+		}
+	}
+	sort.Slice(t.EcVolumes, func(i, j int) bool {
+		return t.EcVolumes[i].Id < t.EcVolumes[j].Id
+	})
+	return t
+}
+
+func toUiNode(dn *topology.DataNode, volumeSizeLimit uint64) uiNode {
+	n := uiNode{Url: dn.Url(), PublicUrl: dn.PublicUrl}
+	switch {
+	case dn.IsTerminating:
+		n.State = "terminating"
+	case dn.IsDraining():
+		n.State = "draining"
+	case dn.IsInMaintenance:
+		n.State = "maintenance"
+	}
+	for diskType, diskInfo := range dn.ToDataNodeInfo().DiskInfos {
+		d := uiDisk{Type: diskType, MaxVolumes: diskInfo.MaxVolumeCount}
+		if d.Type == "" {
+			d.Type = types.HardDriveType.ReadableString()
+		}
+		for _, ecShardInfo := range diskInfo.EcShardInfos {
+			d.EcShards += erasure_coding.ShardBits(ecShardInfo.EcIndexBits).ShardIdCount()
+		}
+		for _, v := range diskInfo.VolumeInfos {
+			d.Volumes = append(d.Volumes, uiVolume{Id: v.Id, Collection: v.Collection, Size: v.Size, State: uiVolumeState(v, volumeSizeLimit)})
+		}
+		sort.Slice(d.Volumes, func(i, j int) bool {
+			return d.Volumes[i].Id < d.Volumes[j].Id
+		})
+		if d.MaxVolumes > 0 {
+			usedSlots := len(d.Volumes) + (d.EcShards+erasure_coding.DataShardsCount-1)/erasure_coding.DataShardsCount
+			d.Utilization = float64(usedSlots) / float64(d.MaxVolumes)
+		}
+		n.Disks = append(n.Disks, d)
+	}
+	sort.Slice(n.Disks, func(i, j int) bool {
+		return n.Disks[i].Type < n.Disks[j].Type
+	})
+	return n
+}
+
+func uiVolumeState(v *master_pb.VolumeInformationMessage, volumeSizeLimit uint64) string {
+	switch {
+	case v.DiskFailing:
+		return "failing"
+	case v.RemoteStorageName != "":
+		return "remote"
+	case v.ReadOnly:
+		return "readonly"
+	case volumeSizeLimit > 0 && v.Size >= volumeSizeLimit:
+		return "full"
+	}
+	return "writable"
+}
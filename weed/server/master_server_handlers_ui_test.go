@@ -0,0 +1,57 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+func TestToUiTopology(t *testing.T) {
+	topo := topology.NewTopology("weedfs", sequence.NewMemorySequencer(), 1024, 5, false)
+	nodes := make(map[string]*topology.DataNode)
+	for i, layout := range []struct{ dc, rack, node string }{
+		{"dc2", "rack1", "c"}, {"dc1", "rack2", "b"}, {"dc1", "rack1", "a"},
+	} {
+		rack := topo.GetOrCreateDataCenter(layout.dc).GetOrCreateRack(layout.rack)
+		nodes[layout.node] = rack.GetOrCreateDataNode("127.0.0.1", 8080+i, 0, layout.node, map[string]uint32{"": 4})
+	}
+	version := uint32(needle.CurrentVersion)
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{
+		{Id: 2, Size: 2048, Version: version},
+		{Id: 1, ReadOnly: true, Version: version},
+	}, nodes["a"])
+	topo.SyncDataNodeEcShards([]*master_pb.VolumeEcShardInformationMessage{{Id: 7, EcIndexBits: 0x7}}, nodes["a"])
+	topo.SyncDataNodeEcShards([]*master_pb.VolumeEcShardInformationMessage{{Id: 7, EcIndexBits: 0x38}}, nodes["c"])
+	nodes["b"].SetDraining(true)
+
+	ui := toUiTopology(topo.ListDataNodes(), 1024)
+	if len(ui.DataCenters) != 2 || ui.DataCenters[0].Id != "dc1" || len(ui.DataCenters[0].Racks) != 2 || ui.DataCenters[0].Racks[0].Id != "rack1" {
+		t.Fatalf("data centers %+v", ui.DataCenters)
+	}
+
+	a := ui.DataCenters[0].Racks[0].Nodes[0]
+	if a.Url != nodes["a"].Url() || len(a.Disks) != 1 {
+		t.Fatalf("node a %+v", a)
+	}
+	disk := a.Disks[0]
+	if disk.Type != "hdd" || disk.EcShards != 3 || len(disk.Volumes) != 2 {
+		t.Fatalf("disk of a %+v", disk)
+	}
+	if disk.Volumes[0].State != "readonly" || disk.Volumes[1].State != "full" {
+		t.Errorf("volume states %s and %s", disk.Volumes[0].State, disk.Volumes[1].State)
+	}
+	// 2 volumes and 1 slot for the ec shards out of 4 slots
+	if disk.Utilization != 0.75 {
+		t.Errorf("utilization %v", disk.Utilization)
+	}
+	if b := ui.DataCenters[0].Racks[1].Nodes[0]; b.State != "draining" {
+		t.Errorf("node b state %q", b.State)
+	}
+
+	if len(ui.EcVolumes) != 1 || len(ui.EcVolumes[0].Locations) != 2 || ui.EcVolumes[0].TotalShards != 14 {
+		t.Fatalf("ec volumes %+v", ui.EcVolumes)
+	}
+}
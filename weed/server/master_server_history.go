@@ -0,0 +1,317 @@
+package weed_server
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+)
+
+// The leader records the topology changes seen in the heartbeats in daily files of JSON lines under
+// the meta folder, so the history survives the restarts, and an incident can be looked into without
+// the logs of all the servers. The changes made by the leader itself, e.g. the moves of a drain, carry
+// the cause. Each master records while it is the leader, and a query merges the histories of all masters.
+
+const (
+	topologyHistoryDayLayout = "2006-01-02"
+	topologyHistoryFileExt   = ".jsonl"
+	topologyCauseGrace       = time.Minute // how long a cause still applies after the change, until reported in the heartbeats
+)
+
+// topologyCause attributes the changes to a reason of the leader
+type topologyCause struct {
+	reason string
+	until  time.Time // zero while the change is in progress
+}
+
+func (c *topologyCause) isActive(now time.Time) bool {
+	return c.until.IsZero() || now.Before(c.until)
+}
+
+type topologyHistory struct {
+	dir       string
+	retention time.Duration
+	lock      sync.Mutex
+	file      *os.File
+	day       string
+	causes    map[needle.VolumeId]*topologyCause
+	growths   map[string]*topologyCause // of the new volumes, by the collection
+}
+
+func newTopologyHistory(dir string, retention time.Duration) (*topologyHistory, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &topologyHistory{
+		dir:       dir,
+		retention: retention,
+		causes:    make(map[needle.VolumeId]*topologyCause),
+		growths:   make(map[string]*topologyCause),
+	}, nil
+}
+
+// expect attributes the changes of the volume to the reason, until a while after done is called
+func (h *topologyHistory) expect(vid needle.VolumeId, reason string) (done func()) {
+	cause := &topologyCause{reason: reason}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	now := time.Now()
+	for id, c := range h.causes {
+		if !c.isActive(now) {
+			delete(h.causes, id)
+		}
+	}
+	h.causes[vid] = cause
+	return h.doneFunc(cause)
+}
+
+// expectGrowth attributes the new volumes of the collection to the reason, until a while after done is called
+func (h *topologyHistory) expectGrowth(collection, reason string) (done func()) {
+	cause := &topologyCause{reason: reason}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.growths[collection] = cause
+	return h.doneFunc(cause)
+}
+
+func (h *topologyHistory) doneFunc(cause *topologyCause) func() {
+	return func() {
+		h.lock.Lock()
+		cause.until = time.Now().Add(topologyCauseGrace)
+		h.lock.Unlock()
+	}
+}
+
+// record attributes the changes to the expected causes, and appends them to the file of their day
+func (h *topologyHistory) record(changes []*master_pb.TopologyChange) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, c := range changes {
+		ts := time.Unix(0, c.TsNs)
+		if cause, found := h.causes[needle.VolumeId(c.VolumeId)]; found && c.VolumeId != 0 && cause.isActive(ts) {
+			c.Cause = cause.reason
+		} else if cause, found := h.growths[c.Collection]; found && c.Type == topologyChangeType(master_pb.TopologyEvent_VOLUMES_ADDED) && cause.isActive(ts) {
+			c.Cause = cause.reason
+		}
+
+		if day := ts.UTC().Format(topologyHistoryDayLayout); day != h.day {
+			if h.file != nil {
+				h.file.Close()
+				h.file = nil
+			}
+			f, err := os.OpenFile(filepath.Join(h.dir, day+topologyHistoryFileExt), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return err
+			}
+			h.file, h.day = f, day
+			h.expire(ts)
+		}
+		line, err := protojson.Marshal(c)
+		if err != nil {
+			return err
+		}
+		if _, err = h.file.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expire deletes the files of the days past the retention
+func (h *topologyHistory) expire(now time.Time) {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		glog.Warningf("list the topology history in %s: %v", h.dir, err)
+		return
+	}
+	for _, entry := range entries {
+		day, found := parseTopologyHistoryDay(entry.Name())
+		if !found || now.Sub(day.Add(24*time.Hour)) <= h.retention {
+			continue
+		}
+		if err = os.Remove(filepath.Join(h.dir, entry.Name())); err != nil {
+			glog.Warningf("delete the expired topology history: %v", err)
+		}
+	}
+}
+
+func parseTopologyHistoryDay(name string) (time.Time, bool) {
+	dayName, isHistory := strings.CutSuffix(name, topologyHistoryFileExt)
+	if !isHistory {
+		return time.Time{}, false
+	}
+	day, err := time.Parse(topologyHistoryDayLayout, dayName)
+	return day, err == nil
+}
+
+// list reads the recorded changes matching the request, the oldest first
+func (h *topologyHistory) list(req *master_pb.ListTopologyHistoryRequest) (changes []*master_pb.TopologyChange, err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	untilNs := req.UntilNs
+	if untilNs == 0 {
+		untilNs = time.Now().UnixNano()
+	}
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		day, found := parseTopologyHistoryDay(entry.Name())
+		if !found || day.Add(24*time.Hour).UnixNano() <= req.SinceNs || day.UnixNano() > untilNs {
+			continue
+		}
+		if changes, err = readTopologyHistory(filepath.Join(h.dir, entry.Name()), req, untilNs, changes); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].TsNs < changes[j].TsNs
+	})
+	return limitTopologyChanges(changes, req.Limit), nil
+}
+
+func readTopologyHistory(fileName string, req *master_pb.ListTopologyHistoryRequest, untilNs int64, changes []*master_pb.TopologyChange) ([]*master_pb.TopologyChange, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		c := &master_pb.TopologyChange{}
+		if err = protojson.Unmarshal(scanner.Bytes(), c); err != nil {
+			// a partial line written before a crash
+			glog.V(1).Infof("skip the topology history line in %s: %v", fileName, err)
+			continue
+		}
+		if matchTopologyChange(req, c, untilNs) {
+			changes = append(changes, c)
+		}
+	}
+	return changes, scanner.Err()
+}
+
+func matchTopologyChange(req *master_pb.ListTopologyHistoryRequest, c *master_pb.TopologyChange, untilNs int64) bool {
+	if c.TsNs < req.SinceNs || c.TsNs > untilNs {
+		return false
+	}
+	if (req.Node != "" && c.Node != req.Node) || (req.VolumeId != 0 && c.VolumeId != req.VolumeId) || (req.Collection != "" && c.Collection != req.Collection) {
+		return false
+	}
+	if len(req.Types) == 0 {
+		return true
+	}
+	for _, t := range req.Types {
+		if t == c.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// limitTopologyChanges keeps the latest changes
+func limitTopologyChanges(changes []*master_pb.TopologyChange, limit int32) []*master_pb.TopologyChange {
+	if limit > 0 && len(changes) > int(limit) {
+		return changes[len(changes)-int(limit):]
+	}
+	return changes
+}
+
+func topologyChangeType(eventType master_pb.TopologyEvent_Type) string {
+	return strings.ToLower(eventType.String())
+}
+
+// toTopologyChanges splits the event into one change per volume, with the default cause.
+// The node updates, mostly the disk usages, are not recorded.
+func toTopologyChanges(event *master_pb.TopologyEvent, master string) (changes []*master_pb.TopologyChange) {
+	newChange := func(cause string) *master_pb.TopologyChange {
+		c := &master_pb.TopologyChange{
+			TsNs:       event.TsNs,
+			Type:       topologyChangeType(event.Type),
+			DataCenter: event.DataCenter,
+			Rack:       event.Rack,
+			Cause:      cause,
+			Master:     master,
+		}
+		if event.DataNode != nil {
+			c.Node = event.DataNode.Id
+		}
+		return c
+	}
+	const reported = "reported by the volume server"
+	switch event.Type {
+	case master_pb.TopologyEvent_NODE_UP:
+		changes = append(changes, newChange("the volume server connected"))
+	case master_pb.TopologyEvent_NODE_DOWN:
+		changes = append(changes, newChange("the volume server disconnected"))
+	case master_pb.TopologyEvent_VOLUMES_ADDED, master_pb.TopologyEvent_VOLUMES_DELETED, master_pb.TopologyEvent_VOLUMES_CHANGED:
+		for _, v := range event.Volumes {
+			c := newChange(reported)
+			c.VolumeId, c.Collection = v.Id, v.Collection
+			c.Detail = volumeChangeDetail(v)
+			changes = append(changes, c)
+		}
+	case master_pb.TopologyEvent_EC_SHARDS_CHANGED:
+		for _, s := range event.EcShards {
+			c := newChange(reported)
+			c.VolumeId, c.Collection = s.Id, s.Collection
+			c.Detail = fmt.Sprintf("shards %v on the node", erasure_coding.ShardBits(s.EcIndexBits).ShardIds())
+			changes = append(changes, c)
+		}
+	case master_pb.TopologyEvent_QUOTA_STATE_CHANGED:
+		if q := event.CollectionQuota; q != nil {
+			c := newChange("the usage of the collection")
+			c.Collection = q.Collection
+			c.Detail = fmt.Sprintf("%s with %d volumes and %d bytes", q.State, q.VolumeCount, q.LogicalBytes)
+			changes = append(changes, c)
+		}
+	}
+	return
+}
+
+func volumeChangeDetail(v *master_pb.VolumeInformationMessage) string {
+	detail := fmt.Sprintf("%d bytes", v.Size)
+	if rp, err := super_block.NewReplicaPlacementFromByte(byte(v.ReplicaPlacement)); err == nil {
+		detail = fmt.Sprintf("replication %s, %s", rp, detail)
+	}
+	if v.ReadOnly {
+		detail += ", read only"
+	}
+	return detail
+}
+
+func (ms *MasterServer) recordTopologyEvent(event *master_pb.TopologyEvent) {
+	if err := ms.history.record(toTopologyChanges(event, string(ms.option.Master))); err != nil {
+		glog.Warningf("record the topology history: %v", err)
+	}
+}
+
+// expectTopologyChange attributes the coming changes of the volume to the reason, until a while after done is called
+func (ms *MasterServer) expectTopologyChange(vid needle.VolumeId, reason string) (done func()) {
+	if ms.history == nil {
+		return func() {}
+	}
+	return ms.history.expect(vid, reason)
+}
+
+// expectVolumeGrowth attributes the new volumes of the collection to the reason, until a while after done is called
+func (ms *MasterServer) expectVolumeGrowth(collection, reason string) (done func()) {
+	if ms.history == nil {
+		return func() {}
+	}
+	return ms.history.expectGrowth(collection, reason)
+}
@@ -0,0 +1,83 @@
+package weed_server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+func TestTopologyHistory(t *testing.T) {
+	dir := t.TempDir()
+	h, err := newTopologyHistory(dir, 48*time.Hour)
+	if err != nil {
+		t.Fatalf("new history: %v", err)
+	}
+	now := time.Now()
+	event := func(eventType master_pb.TopologyEvent_Type, ts time.Time, node string, volumeIds ...uint32) *master_pb.TopologyEvent {
+		e := &master_pb.TopologyEvent{Type: eventType, TsNs: ts.UnixNano(), DataCenter: "dc1", Rack: "rack1", DataNode: &master_pb.DataNodeInfo{Id: node}}
+		for _, vid := range volumeIds {
+			e.Volumes = append(e.Volumes, &master_pb.VolumeInformationMessage{Id: vid, Collection: "logs", ReplicaPlacement: 1, ReadOnly: true})
+		}
+		return e
+	}
+
+	// an expired file, and a file within the retention
+	expiredDay := now.Add(-5 * 24 * time.Hour).UTC().Format(topologyHistoryDayLayout)
+	if err = os.WriteFile(filepath.Join(dir, expiredDay+topologyHistoryFileExt), nil, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err = h.record(toTopologyChanges(event(master_pb.TopologyEvent_NODE_UP, now.Add(-24*time.Hour), "a:8080"), "m1")); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	done := h.expect(2, "drain a:8080")
+	if err = h.record(toTopologyChanges(event(master_pb.TopologyEvent_VOLUMES_ADDED, now, "b:8080", 1, 2), "m1")); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	done()
+	growthDone := h.expectGrowth("logs", "automatic volume growth")
+	if err = h.record(toTopologyChanges(event(master_pb.TopologyEvent_VOLUMES_ADDED, now, "a:8080", 3), "m1")); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	growthDone()
+	if err = h.record(toTopologyChanges(event(master_pb.TopologyEvent_NODE_UPDATED, now, "a:8080"), "m1")); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, expiredDay+topologyHistoryFileExt)); !os.IsNotExist(err) {
+		t.Errorf("kept the expired history file: %v", err)
+	}
+
+	changes, err := h.list(&master_pb.ListTopologyHistoryRequest{SinceNs: now.Add(-48 * time.Hour).UnixNano()})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(changes) != 4 || changes[0].Type != "node_up" || changes[0].Node != "a:8080" || changes[0].Master != "m1" {
+		t.Fatalf("changes %+v", changes)
+	}
+	expectedCauses := []string{"the volume server connected", "reported by the volume server", "drain a:8080", "automatic volume growth"}
+	for i, cause := range expectedCauses {
+		if changes[i].Cause != cause {
+			t.Errorf("change %d cause %q, expected %q", i, changes[i].Cause, cause)
+		}
+	}
+	if changes[2].VolumeId != 2 || changes[2].Collection != "logs" || changes[2].Detail != "replication 001, 0 bytes, read only" {
+		t.Errorf("volume change %+v", changes[2])
+	}
+
+	changes, _ = h.list(&master_pb.ListTopologyHistoryRequest{SinceNs: now.Add(-48 * time.Hour).UnixNano(), Node: "b:8080", VolumeId: 2})
+	if len(changes) != 1 || changes[0].VolumeId != 2 {
+		t.Errorf("changes of volume 2 on b %+v", changes)
+	}
+	changes, _ = h.list(&master_pb.ListTopologyHistoryRequest{SinceNs: now.Add(-48 * time.Hour).UnixNano(), Types: []string{"volumes_added"}, Limit: 1})
+	if len(changes) != 1 || changes[0].VolumeId != 3 {
+		t.Errorf("the latest added volume %+v", changes)
+	}
+	changes, _ = h.list(&master_pb.ListTopologyHistoryRequest{SinceNs: now.Add(-time.Hour).UnixNano()})
+	if len(changes) != 3 {
+		t.Errorf("%d changes in the last hour, expected 3", len(changes))
+	}
+}
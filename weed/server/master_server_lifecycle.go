@@ -0,0 +1,281 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// The leader ages the volumes of the collections with a lifecycle, each volume as a unit, so the logs and
+// the backups age out without the maintenance scripts: a volume not written for a while is sealed as read only,
+// then moved to the remote tier, and finally deleted with all its replicas. In the dry run, the actions
+// due are only reported.
+
+const (
+	lifecycleCheckInterval  = 10 * time.Minute
+	lifecycleAppliedHistory = 100
+)
+
+const (
+	lifecycleActionSeal   = "seal"
+	lifecycleActionTier   = "tier"
+	lifecycleActionDelete = "delete"
+)
+
+// CollectionLifecycle ages the volumes by the time since their last write. A zero period skips the step.
+type CollectionLifecycle struct {
+	SealAfter   time.Duration
+	TierAfter   time.Duration
+	TierBackend string // e.g. "s3.default" in [storage.backend.s3.default] of master.toml
+	DeleteAfter time.Duration
+}
+
+// ParseLifecycleCollections parses the semicolon separated collections, each with its lifecycle,
+// e.g. "logs:sealAfter=1d,tierAfter=7d,tierBackend=s3.default,deleteAfter=30d;backup:deleteAfter=90d"
+func ParseLifecycleCollections(s string) (map[string]*CollectionLifecycle, error) {
+	lifecycles := make(map[string]*CollectionLifecycle)
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		collection, settings, hasSettings := strings.Cut(item, ":")
+		collection = strings.TrimSpace(collection)
+		if !hasSettings {
+			return nil, fmt.Errorf("lifecycle collection %s: missing the lifecycle", collection)
+		}
+		l := &CollectionLifecycle{}
+		for _, setting := range strings.Split(settings, ",") {
+			key, value, hasValue := strings.Cut(strings.TrimSpace(setting), "=")
+			if !hasValue {
+				return nil, fmt.Errorf("lifecycle collection %s: expect key=value in %q", collection, setting)
+			}
+			var err error
+			switch value = strings.TrimSpace(value); key {
+			case "sealAfter":
+				l.SealAfter, err = parseLifecycleDuration(value)
+			case "tierAfter":
+				l.TierAfter, err = parseLifecycleDuration(value)
+			case "tierBackend":
+				l.TierBackend = value
+			case "deleteAfter":
+				l.DeleteAfter, err = parseLifecycleDuration(value)
+			default:
+				err = fmt.Errorf("unknown setting")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("lifecycle collection %s: %s=%s: %v", collection, key, value, err)
+			}
+		}
+		if l.TierAfter > 0 && l.TierBackend == "" {
+			return nil, fmt.Errorf("lifecycle collection %s: the tierBackend of tierAfter is not set", collection)
+		}
+		if l.DeleteAfter > 0 && l.TierAfter >= l.DeleteAfter {
+			return nil, fmt.Errorf("lifecycle collection %s: tierAfter %v is not before deleteAfter %v", collection, l.TierAfter, l.DeleteAfter)
+		}
+		lifecycles[collection] = l
+	}
+	return lifecycles, nil
+}
+
+// parseLifecycleDuration parses a duration, also in days, e.g. "30d"
+func parseLifecycleDuration(s string) (time.Duration, error) {
+	if days, isDays := strings.CutSuffix(s, "d"); isDays {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// lifecycleAction is an action due on a volume, with all its replicas
+type lifecycleAction struct {
+	volume   storage.VolumeInfo
+	replicas []*topology.DataNode
+	action   string
+	reason   string
+}
+
+func (a *lifecycleAction) toMessage() *master_pb.LifecycleAction {
+	return &master_pb.LifecycleAction{
+		VolumeId:   uint32(a.volume.Id),
+		Collection: a.volume.Collection,
+		Action:     a.action,
+		Reason:     a.reason,
+	}
+}
+
+// actionOf takes the last step due, by the time since the newest write of the replicas,
+// or since the creation for the volumes never written
+func (l *CollectionLifecycle) actionOf(replicas []storage.VolumeInfo, now time.Time) (action, reason string) {
+	var lastWrittenAtSecond int64
+	isReadOnly, isRemote := true, false
+	for _, v := range replicas {
+		if v.ModifiedAtSecond > lastWrittenAtSecond {
+			lastWrittenAtSecond = v.ModifiedAtSecond
+		}
+		if v.CreatedAtSecond > lastWrittenAtSecond {
+			lastWrittenAtSecond = v.CreatedAtSecond
+		}
+		isReadOnly = isReadOnly && v.ReadOnly
+		isRemote = isRemote || v.RemoteStorageName != ""
+	}
+	if lastWrittenAtSecond == 0 {
+		return "", ""
+	}
+	quietFor := now.Sub(time.Unix(lastWrittenAtSecond, 0)).Truncate(time.Minute)
+	switch {
+	case l.DeleteAfter > 0 && quietFor > l.DeleteAfter:
+		return lifecycleActionDelete, fmt.Sprintf("not written for %v, over %v", quietFor, l.DeleteAfter)
+	case l.TierAfter > 0 && quietFor > l.TierAfter && !isRemote:
+		return lifecycleActionTier, fmt.Sprintf("not written for %v, over %v, to %s", quietFor, l.TierAfter, l.TierBackend)
+	case l.SealAfter > 0 && quietFor > l.SealAfter && !isReadOnly:
+		return lifecycleActionSeal, fmt.Sprintf("not written for %v, over %v", quietFor, l.SealAfter)
+	}
+	return "", ""
+}
+
+// planLifecycleActions lists the actions due on the volumes of the collections with a lifecycle, by the volume id
+func planLifecycleActions(lifecycles map[string]*CollectionLifecycle, dataNodes []*topology.DataNode, now time.Time) (actions []*lifecycleAction) {
+	replicas := make(map[needle.VolumeId][]storage.VolumeInfo)
+	locations := make(map[needle.VolumeId][]*topology.DataNode)
+	for _, dn := range dataNodes {
+		for _, v := range dn.GetVolumes() {
+			if _, found := lifecycles[v.Collection]; found {
+				replicas[v.Id] = append(replicas[v.Id], v)
+				locations[v.Id] = append(locations[v.Id], dn)
+			}
+		}
+	}
+	for vid, vs := range replicas {
+		action, reason := lifecycles[vs[0].Collection].actionOf(vs, now)
+		if action == "" {
+			continue
+		}
+		actions = append(actions, &lifecycleAction{volume: vs[0], replicas: locations[vid], action: action, reason: reason})
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].volume.Id < actions[j].volume.Id
+	})
+	return
+}
+
+func (ms *MasterServer) loopLifecycle() {
+	if len(ms.option.LifecycleCollections) == 0 {
+		return
+	}
+	for {
+		time.Sleep(lifecycleCheckInterval)
+		if !ms.Topo.IsLeader() {
+			continue
+		}
+		for _, a := range planLifecycleActions(ms.option.LifecycleCollections, ms.Topo.ListDataNodes(), time.Now()) {
+			if ms.option.LifecycleDryRun {
+				glog.V(0).Infof("lifecycle dry run: %s volume %d of collection %s, %s", a.action, a.volume.Id, a.volume.Collection, a.reason)
+				continue
+			}
+			ms.applyLifecycleAction(a)
+		}
+	}
+}
+
+func (ms *MasterServer) applyLifecycleAction(a *lifecycleAction) {
+	doneMoving, err := ms.startVolumeMove(a.volume.Id, fmt.Sprintf("lifecycle %s", a.action))
+	if err != nil {
+		glog.V(1).Infof("lifecycle: skip %s volume %d: %v", a.action, a.volume.Id, err)
+		return
+	}
+	defer doneMoving()
+	glog.V(0).Infof("lifecycle: %s volume %d of collection %s, %s", a.action, a.volume.Id, a.volume.Collection, a.reason)
+	done := ms.expectTopologyChange(a.volume.Id, fmt.Sprintf("lifecycle %s, %s", a.action, a.reason))
+	switch a.action {
+	case lifecycleActionSeal:
+		err = ms.sealVolume(a.volume, a.replicas)
+	case lifecycleActionTier:
+		err = ms.tierVolume(a.volume, a.replicas, ms.option.LifecycleCollections[a.volume.Collection].TierBackend)
+	case lifecycleActionDelete:
+		for _, dn := range a.replicas {
+			if err = ms.deleteVolume(a.volume, dn); err != nil {
+				break
+			}
+		}
+	}
+	done()
+
+	applied := a.toMessage()
+	applied.AppliedAtNs = time.Now().UnixNano()
+	if err != nil {
+		glog.Warningf("lifecycle: %s volume %d: %v", a.action, a.volume.Id, err)
+		applied.Error = err.Error()
+	}
+	ms.lifecycleLock.Lock()
+	ms.lifecycleApplied = append([]*master_pb.LifecycleAction{applied}, ms.lifecycleApplied...)
+	if len(ms.lifecycleApplied) > lifecycleAppliedHistory {
+		ms.lifecycleApplied = ms.lifecycleApplied[:lifecycleAppliedHistory]
+	}
+	ms.lifecycleLock.Unlock()
+}
+
+func (ms *MasterServer) sealVolume(v storage.VolumeInfo, replicas []*topology.DataNode) error {
+	for _, dn := range replicas {
+		err := operation.WithVolumeServerClient(false, dn.ServerAddress(), ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+			_, err := client.VolumeMarkReadonly(context.Background(), &volume_server_pb.VolumeMarkReadonlyRequest{
+				VolumeId: uint32(v.Id),
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("mark volume %d readonly on %s: %v", v.Id, dn.Url(), err)
+		}
+	}
+	return nil
+}
+
+// tierVolume seals the volume, moves the .dat file of the first replica to the remote tier, and deletes the other replicas
+func (ms *MasterServer) tierVolume(v storage.VolumeInfo, replicas []*topology.DataNode, backend string) error {
+	if err := ms.sealVolume(v, replicas); err != nil {
+		return err
+	}
+	defer ms.startOperation(fmt.Sprintf("move volume %d on %s to %s", v.Id, replicas[0].Url(), backend))()
+	err := operation.WithVolumeServerClient(true, replicas[0].ServerAddress(), ms.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		stream, err := client.VolumeTierMoveDatToRemote(context.Background(), &volume_server_pb.VolumeTierMoveDatToRemoteRequest{
+			VolumeId:               uint32(v.Id),
+			Collection:             v.Collection,
+			DestinationBackendName: backend,
+		})
+		if err != nil {
+			return err
+		}
+		for {
+			if _, recvErr := stream.Recv(); recvErr != nil {
+				if recvErr == io.EOF {
+					return nil
+				}
+				return recvErr
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("move volume %d on %s to %s: %v", v.Id, replicas[0].Url(), backend, err)
+	}
+	for _, dn := range replicas[1:] {
+		if err = ms.deleteVolume(v, dn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package weed_server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+func TestParseLifecycleCollections(t *testing.T) {
+	lifecycles, err := ParseLifecycleCollections("logs:sealAfter=12h,tierAfter=7d,tierBackend=s3.default,deleteAfter=30d; backup:deleteAfter=90d")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	logs := lifecycles["logs"]
+	if logs == nil || logs.SealAfter != 12*time.Hour || logs.TierAfter != 7*24*time.Hour || logs.TierBackend != "s3.default" || logs.DeleteAfter != 30*24*time.Hour {
+		t.Errorf("logs lifecycle %+v", logs)
+	}
+	if backup := lifecycles["backup"]; backup == nil || backup.DeleteAfter != 90*24*time.Hour {
+		t.Errorf("backup lifecycle %+v", backup)
+	}
+	for _, s := range []string{"logs", "logs:tierAfter=7d", "logs:tierAfter=7d,tierBackend=s3.default,deleteAfter=1d", "logs:keep=1d", "logs:deleteAfter=xd"} {
+		if _, err = ParseLifecycleCollections(s); err == nil {
+			t.Errorf("parsed %q", s)
+		}
+	}
+}
+
+func TestPlanLifecycleActions(t *testing.T) {
+	topo := topology.NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+	rack := topo.GetOrCreateDataCenter("dc1").GetOrCreateRack("rack1")
+	a := rack.GetOrCreateDataNode("127.0.0.1", 8080, 0, "a", map[string]uint32{"": 10})
+	b := rack.GetOrCreateDataNode("127.0.0.1", 8081, 0, "b", map[string]uint32{"": 10})
+
+	now := time.Unix(1000000000, 0)
+	daysAgo := func(days int) int64 {
+		return now.Add(-time.Duration(days) * 24 * time.Hour).Unix()
+	}
+	volume := func(id uint32, collection string, writtenDaysAgo int, readOnly bool, remote string) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{Id: id, Collection: collection, Version: uint32(needle.CurrentVersion),
+			CreatedAtSecond: daysAgo(100), ModifiedAtSecond: daysAgo(writtenDaysAgo), ReadOnly: readOnly, RemoteStorageName: remote}
+	}
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{
+		volume(1, "logs", 0, false, ""),
+		volume(2, "logs", 2, false, ""),
+		volume(3, "logs", 10, true, ""),
+		volume(4, "logs", 10, true, "s3.default"),
+		volume(5, "logs", 40, true, "s3.default"),
+		volume(6, "other", 40, false, ""),
+	}, a)
+	// the replica on b was written lately, so volume 2 is kept writable
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{volume(2, "logs", 0, false, ""), volume(3, "logs", 10, false, "")}, b)
+
+	lifecycles := map[string]*CollectionLifecycle{
+		"logs": {SealAfter: 24 * time.Hour, TierAfter: 7 * 24 * time.Hour, TierBackend: "s3.default", DeleteAfter: 30 * 24 * time.Hour},
+	}
+	actions := planLifecycleActions(lifecycles, topo.ListDataNodes(), now)
+	expected := []struct {
+		vid      needle.VolumeId
+		action   string
+		replicas int
+	}{
+		{3, lifecycleActionTier, 2},
+		{5, lifecycleActionDelete, 1},
+	}
+	if len(actions) != len(expected) {
+		t.Fatalf("%d actions, expected %d", len(actions), len(expected))
+	}
+	for i, e := range expected {
+		if actions[i].volume.Id != e.vid || actions[i].action != e.action || len(actions[i].replicas) != e.replicas {
+			t.Errorf("action %d: %s volume %d on %d replicas, expected %s volume %d on %d replicas",
+				i, actions[i].action, actions[i].volume.Id, len(actions[i].replicas), e.action, e.vid, e.replicas)
+		}
+	}
+
+	// seal the volume written 2 days ago on both replicas
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{volume(2, "logs", 2, false, ""), volume(3, "logs", 10, false, "")}, b)
+	actions = planLifecycleActions(lifecycles, topo.ListDataNodes(), now)
+	if len(actions) != 3 || actions[0].volume.Id != 2 || actions[0].action != lifecycleActionSeal || len(actions[0].replicas) != 2 {
+		t.Errorf("expected sealing volume 2 on 2 replicas first, got %d actions", len(actions))
+	}
+}
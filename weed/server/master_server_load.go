@@ -0,0 +1,91 @@
+package weed_server
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// The cluster load rolls up the capacity and the request rates from the heartbeats by data center, rack, and
+// volume server, so the autoscalers and the dashboards need only one call to the master.
+
+func (ms *MasterServer) clusterLoadHandler(w http.ResponseWriter, r *http.Request) {
+	writeJsonQuiet(w, r, http.StatusOK, toClusterLoad(ms.Topo.ListDataNodes(), uint64(ms.option.VolumeSizeLimitMB)*1024*1024))
+}
+
+func toClusterLoad(dataNodes []*topology.DataNode, volumeSizeLimit uint64) *master_pb.GetClusterLoadResponse {
+	resp := &master_pb.GetClusterLoadResponse{Load: &master_pb.ClusterLoad{}}
+	for _, dn := range dataNodes {
+		dcId, rackId := dn.GetDataCenterId(), dn.GetRackId()
+		dcIndex := sort.Search(len(resp.DataCenters), func(i int) bool { return resp.DataCenters[i].Load.Id >= dcId })
+		if dcIndex == len(resp.DataCenters) || resp.DataCenters[dcIndex].Load.Id != dcId {
+			dc := &master_pb.GetClusterLoadResponse_DataCenterLoad{Load: &master_pb.ClusterLoad{Id: dcId}}
+			resp.DataCenters = append(resp.DataCenters[:dcIndex], append([]*master_pb.GetClusterLoadResponse_DataCenterLoad{dc}, resp.DataCenters[dcIndex:]...)...)
+		}
+		dc := resp.DataCenters[dcIndex]
+		rackIndex := sort.Search(len(dc.Racks), func(i int) bool { return dc.Racks[i].Load.Id >= rackId })
+		if rackIndex == len(dc.Racks) || dc.Racks[rackIndex].Load.Id != rackId {
+			rack := &master_pb.GetClusterLoadResponse_RackLoad{Load: &master_pb.ClusterLoad{Id: rackId}}
+			dc.Racks = append(dc.Racks[:rackIndex], append([]*master_pb.GetClusterLoadResponse_RackLoad{rack}, dc.Racks[rackIndex:]...)...)
+		}
+		rack := dc.Racks[rackIndex]
+
+		node := toNodeLoad(dn, volumeSizeLimit)
+		rack.Nodes = append(rack.Nodes, node)
+		for _, load := range []*master_pb.ClusterLoad{rack.Load, dc.Load, resp.Load} {
+			addClusterLoad(load, node)
+		}
+	}
+	for _, dc := range resp.DataCenters {
+		for _, rack := range dc.Racks {
+			sort.Slice(rack.Nodes, func(i, j int) bool {
+				return rack.Nodes[i].Id < rack.Nodes[j].Id
+			})
+		}
+	}
+	return resp
+}
+
+func toNodeLoad(dn *topology.DataNode, volumeSizeLimit uint64) *master_pb.ClusterLoad {
+	rates := dn.RequestRates
+	load := &master_pb.ClusterLoad{
+		Id:                    dn.Url(),
+		NodeCount:             1,
+		ReadsPerSecond:        rates.Reads,
+		WritesPerSecond:       rates.Writes,
+		DeletesPerSecond:      rates.Deletes,
+		ReadBytesPerSecond:    rates.ReadBytes,
+		WrittenBytesPerSecond: rates.WrittenBytes,
+		ErrorsPerSecond:       rates.Errors,
+	}
+	for _, diskInfo := range dn.ToDataNodeInfo().DiskInfos {
+		load.MaxVolumeCount += uint64(diskInfo.MaxVolumeCount)
+		load.VolumeCount += uint64(len(diskInfo.VolumeInfos))
+		for _, ecShardInfo := range diskInfo.EcShardInfos {
+			load.EcShardCount += uint64(erasure_coding.ShardBits(ecShardInfo.EcIndexBits).ShardIdCount())
+		}
+		for _, v := range diskInfo.VolumeInfos {
+			load.UsedBytes += v.Size
+		}
+	}
+	load.CapacityBytes = load.MaxVolumeCount * volumeSizeLimit
+	return load
+}
+
+func addClusterLoad(sum, load *master_pb.ClusterLoad) {
+	sum.NodeCount += load.NodeCount
+	sum.MaxVolumeCount += load.MaxVolumeCount
+	sum.VolumeCount += load.VolumeCount
+	sum.EcShardCount += load.EcShardCount
+	sum.UsedBytes += load.UsedBytes
+	sum.CapacityBytes += load.CapacityBytes
+	sum.ReadsPerSecond += load.ReadsPerSecond
+	sum.WritesPerSecond += load.WritesPerSecond
+	sum.DeletesPerSecond += load.DeletesPerSecond
+	sum.ReadBytesPerSecond += load.ReadBytesPerSecond
+	sum.WrittenBytesPerSecond += load.WrittenBytesPerSecond
+	sum.ErrorsPerSecond += load.ErrorsPerSecond
+}
@@ -0,0 +1,50 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+func TestToClusterLoad(t *testing.T) {
+	topo := topology.NewTopology("weedfs", sequence.NewMemorySequencer(), 1024, 5, false)
+	nodes := make(map[string]*topology.DataNode)
+	for i, layout := range []struct{ dc, rack, node string }{
+		{"dc1", "rack1", "a"}, {"dc1", "rack1", "b"}, {"dc2", "rack1", "c"},
+	} {
+		rack := topo.GetOrCreateDataCenter(layout.dc).GetOrCreateRack(layout.rack)
+		nodes[layout.node] = rack.GetOrCreateDataNode("127.0.0.1", 8080+i, 0, layout.node, map[string]uint32{"": 4})
+	}
+	version := uint32(needle.CurrentVersion)
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{{Id: 1, Size: 100, Version: version}, {Id: 2, Size: 200, Version: version}}, nodes["a"])
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{{Id: 3, Size: 300, Version: version}}, nodes["c"])
+	topo.SyncDataNodeEcShards([]*master_pb.VolumeEcShardInformationMessage{{Id: 7, EcIndexBits: 0x7}}, nodes["b"])
+	nodes["a"].RequestRates = topology.ToRequestRates(&master_pb.RequestLoad{Reads: 100, ReadBytes: 1000, Errors: 10, IntervalMs: 10000})
+	nodes["b"].RequestRates = topology.ToRequestRates(&master_pb.RequestLoad{Reads: 50, Writes: 20, IntervalMs: 5000})
+	nodes["c"].RequestRates = topology.ToRequestRates(&master_pb.RequestLoad{Reads: 100})
+
+	load := toClusterLoad(topo.ListDataNodes(), 1024)
+	if len(load.DataCenters) != 2 || load.DataCenters[0].Load.Id != "dc1" || len(load.DataCenters[0].Racks) != 1 {
+		t.Fatalf("data centers %+v", load.DataCenters)
+	}
+	rack := load.DataCenters[0].Racks[0]
+	if len(rack.Nodes) != 2 || rack.Nodes[0].Id != nodes["a"].Url() {
+		t.Fatalf("nodes %+v", rack.Nodes)
+	}
+	if a := rack.Nodes[0]; a.VolumeCount != 2 || a.UsedBytes != 300 || a.CapacityBytes != 4*1024 || a.ReadsPerSecond != 10 || a.ErrorsPerSecond != 1 {
+		t.Errorf("node a %+v", a)
+	}
+	if rack.Load.NodeCount != 2 || rack.Load.EcShardCount != 3 || rack.Load.ReadsPerSecond != 20 || rack.Load.WritesPerSecond != 4 {
+		t.Errorf("rack %+v", rack.Load)
+	}
+	// no rates from the first heartbeat of c
+	if c := load.DataCenters[1].Load; c.ReadsPerSecond != 0 || c.UsedBytes != 300 {
+		t.Errorf("dc2 %+v", c)
+	}
+	if load.Load.NodeCount != 3 || load.Load.MaxVolumeCount != 12 || load.Load.UsedBytes != 600 || load.Load.ReadBytesPerSecond != 100 {
+		t.Errorf("cluster %+v", load.Load)
+	}
+}
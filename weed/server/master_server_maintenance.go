@@ -0,0 +1,107 @@
+package weed_server
+
+import (
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// A volume server, or a whole rack, in maintenance, e.g. for the planned reboots and firmware updates,
+// gets no new volumes, and its volumes take no new writes, while they stay readable.
+// Once its volume servers go offline, the volumes they held are not re-replicated until the grace period ends,
+// as the volume servers are expected back. The maintenance is kept on the leader until cancelled.
+
+type maintenance struct {
+	node       string
+	dataCenter string
+	rack       string
+	reason     string
+	startedAt  time.Time
+	graceUntil time.Time
+	// the volumes on the offline volume servers, by the volume server
+	offlineVolumes map[string][]needle.VolumeId
+}
+
+func maintenanceKey(node, dataCenter, rack string) string {
+	if node != "" {
+		return node
+	}
+	return dataCenter + ":" + rack
+}
+
+func (m *maintenance) covers(dn *topology.DataNode) bool {
+	if m.node != "" {
+		return dn.Url() == m.node || string(dn.Id()) == m.node
+	}
+	if dn.Parent() == nil {
+		return false
+	}
+	return string(dn.GetRack().Id()) == m.rack && (m.dataCenter == "" || dn.GetDataCenterId() == m.dataCenter)
+}
+
+func (m *maintenance) coversAny(dataNodes []*topology.DataNode) bool {
+	for _, dn := range dataNodes {
+		if m.covers(dn) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *maintenance) isInGracePeriod(now time.Time) bool {
+	return now.Before(m.graceUntil)
+}
+
+// applyMaintenance puts the registered volume server in or out of the maintenance,
+// and its volumes are no longer offline
+func (ms *MasterServer) applyMaintenance(dn *topology.DataNode) {
+	inMaintenance := false
+	ms.maintenancesLock.Lock()
+	for _, m := range ms.maintenances {
+		if m.covers(dn) {
+			inMaintenance = true
+			delete(m.offlineVolumes, dn.Url())
+		}
+	}
+	ms.maintenancesLock.Unlock()
+	ms.Topo.SetDataNodeMaintenance(dn, inMaintenance)
+}
+
+// rememberOfflineMaintenanceVolumes defers the re-replication of the volumes on the disconnected volume server in maintenance
+func (ms *MasterServer) rememberOfflineMaintenanceVolumes(dn *topology.DataNode) {
+	if !dn.IsInMaintenance {
+		return
+	}
+	var vids []needle.VolumeId
+	for _, v := range dn.GetVolumes() {
+		vids = append(vids, v.Id)
+	}
+	ms.maintenancesLock.Lock()
+	defer ms.maintenancesLock.Unlock()
+	for _, m := range ms.maintenances {
+		if m.covers(dn) {
+			glog.V(0).Infof("volume server %s in maintenance is offline, defer re-replicating its %d volumes until %v", dn.Url(), len(vids), m.graceUntil)
+			m.offlineVolumes[dn.Url()] = vids
+		}
+	}
+}
+
+// deferredVolumeIds lists the volumes on the offline volume servers still in the grace period of their maintenance
+func (ms *MasterServer) deferredVolumeIds(now time.Time) map[needle.VolumeId]bool {
+	deferred := make(map[needle.VolumeId]bool)
+	ms.maintenancesLock.Lock()
+	defer ms.maintenancesLock.Unlock()
+	for _, m := range ms.maintenances {
+		if !m.isInGracePeriod(now) {
+			continue
+		}
+		for _, vids := range m.offlineVolumes {
+			for _, vid := range vids {
+				deferred[vid] = true
+			}
+		}
+	}
+	return deferred
+}
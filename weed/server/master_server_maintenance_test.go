@@ -0,0 +1,66 @@
+package weed_server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+func TestRackMaintenance(t *testing.T) {
+	topo, nodes := newTestTopology(32*1024, []testNodeLayout{
+		{"rack1", "a"}, {"rack1", "b"}, {"rack2", "c"},
+	})
+	replica := &master_pb.VolumeInformationMessage{Id: 1, ReplicaPlacement: 10, Version: uint32(needle.CurrentVersion)}
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{replica}, nodes["a"])
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{replica}, nodes["c"])
+	rp, _ := super_block.NewReplicaPlacementFromString("010")
+	option := &topology.VolumeGrowOption{ReplicaPlacement: rp, Ttl: needle.EMPTY_TTL}
+	if _, _, _, err := topo.PickForWrite(1, option); err != nil {
+		t.Fatalf("volume 1 is not writable: %v", err)
+	}
+
+	ms := &MasterServer{Topo: topo, maintenances: make(map[string]*maintenance)}
+	ms.maintenances[maintenanceKey("", "dc1", "rack1")] = &maintenance{
+		dataCenter:     "dc1",
+		rack:           "rack1",
+		graceUntil:     time.Now().Add(time.Hour),
+		offlineVolumes: make(map[string][]needle.VolumeId),
+	}
+	for _, dn := range topo.ListDataNodes() {
+		ms.applyMaintenance(dn)
+	}
+	if !nodes["a"].IsInMaintenance || !nodes["b"].IsInMaintenance || nodes["c"].IsInMaintenance {
+		t.Fatalf("maintenance a %v b %v c %v, expected rack1 only", nodes["a"].IsInMaintenance, nodes["b"].IsInMaintenance, nodes["c"].IsInMaintenance)
+	}
+	if free := nodes["b"].AvailableSpaceFor(option); free != 0 {
+		t.Errorf("node in maintenance has %d free slots", free)
+	}
+	if _, _, _, err := topo.PickForWrite(1, option); err == nil {
+		t.Errorf("assigned a write to the volume with a replica in maintenance")
+	}
+	if vids := topo.Lookup("", 1); len(vids) != 2 {
+		t.Errorf("volume 1 readable on %d servers", len(vids))
+	}
+
+	ms.rememberOfflineMaintenanceVolumes(nodes["a"])
+	if vids := ms.maintenances["dc1:rack1"].offlineVolumes[nodes["a"].Url()]; len(vids) != 1 || vids[0] != 1 {
+		t.Errorf("deferred volumes %v, expected volume 1", vids)
+	}
+	// back online
+	ms.applyMaintenance(nodes["a"])
+	if len(ms.maintenances["dc1:rack1"].offlineVolumes) != 0 {
+		t.Errorf("volume server a is still offline")
+	}
+
+	delete(ms.maintenances, "dc1:rack1")
+	for _, dn := range topo.ListDataNodes() {
+		ms.applyMaintenance(dn)
+	}
+	if _, _, _, err := topo.PickForWrite(1, option); err != nil {
+		t.Errorf("volume 1 is not writable after the maintenance: %v", err)
+	}
+}
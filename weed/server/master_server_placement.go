@@ -0,0 +1,42 @@
+package weed_server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// ParsePlacementCollections parses the semicolon separated collections, each with its placement constraint,
+// e.g. "logs:hw=hdd;images:zone=a|b,hw=nvme"
+func ParsePlacementCollections(s string) (map[string]*topology.PlacementConstraint, error) {
+	collections := make(map[string]*topology.PlacementConstraint)
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		collection, expression, hasExpression := strings.Cut(item, ":")
+		if !hasExpression {
+			return nil, fmt.Errorf("placement collection %s: missing the constraint", collection)
+		}
+		constraint, err := topology.ParsePlacementConstraint(expression)
+		if err != nil {
+			return nil, fmt.Errorf("placement collection %s: %v", collection, err)
+		}
+		collections[strings.TrimSpace(collection)] = constraint
+	}
+	return collections, nil
+}
+
+// placementOf is the placement constraint to write the collection with,
+// the constraint of the request in addition to the one of the collection
+func (ms *MasterServer) placementOf(collection, expression string) (*topology.PlacementConstraint, error) {
+	if collectionConstraint := ms.option.PlacementCollections[collection]; collectionConstraint != nil {
+		if strings.TrimSpace(expression) == "" {
+			return collectionConstraint, nil
+		}
+		expression = collectionConstraint.String() + "," + expression
+	}
+	return topology.ParsePlacementConstraint(expression)
+}
@@ -0,0 +1,39 @@
+package weed_server
+
+import (
+	"testing"
+)
+
+func TestParsePlacementCollections(t *testing.T) {
+	collections, err := ParsePlacementCollections("logs:hw=hdd; images:zone=a|b,hw=nvme;")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(collections) != 2 || collections["logs"].String() != "hw=hdd" || collections["images"].String() != "zone=a|b,hw=nvme" {
+		t.Errorf("placement collections %+v", collections)
+	}
+	if _, err = ParsePlacementCollections("logs"); err == nil {
+		t.Errorf("parsed the collection without a constraint")
+	}
+	if _, err = ParsePlacementCollections("logs:hw="); err == nil {
+		t.Errorf("parsed the invalid constraint")
+	}
+
+	ms := &MasterServer{option: &MasterOption{PlacementCollections: collections}}
+	for _, tc := range []struct {
+		collection, expression, placement string
+	}{
+		{"", "", ""},
+		{"", "zone=a", "zone=a"},
+		{"logs", "", "hw=hdd"},
+		{"logs", "zone=a", "hw=hdd,zone=a"},
+	} {
+		placement, err := ms.placementOf(tc.collection, tc.expression)
+		if err != nil {
+			t.Fatalf("placement of %s %q: %v", tc.collection, tc.expression, err)
+		}
+		if placement.String() != tc.placement {
+			t.Errorf("placement of %s %q is %q, expected %q", tc.collection, tc.expression, placement, tc.placement)
+		}
+	}
+}
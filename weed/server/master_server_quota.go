@@ -0,0 +1,101 @@
+package weed_server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The leader refreshes the usage of the collections with quotas, and tells the topology watchers
+// when a collection reaches the warning or the limit of its quota. The quotas set at runtime are kept
+// only on the leader, so also set them with -quota.collections to keep them across the leader changes.
+
+const (
+	collectionQuotaRefreshInterval = 10 * time.Second
+	defaultQuotaWarnPercent        = 80
+)
+
+// ParseCollectionQuotas parses the semicolon separated collections, each with its quota,
+// e.g. "tenant1:maxVolumes=100,maxSize=10TiB,warn=90;tenant2:maxSize=1TiB"
+func ParseCollectionQuotas(s string) (map[string]*topology.CollectionQuota, error) {
+	quotas := make(map[string]*topology.CollectionQuota)
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		collection, settings, hasSettings := strings.Cut(item, ":")
+		collection = strings.TrimSpace(collection)
+		if !hasSettings {
+			return nil, fmt.Errorf("quota collection %s: missing the quota", collection)
+		}
+		quota := &topology.CollectionQuota{WarnPercent: defaultQuotaWarnPercent}
+		for _, setting := range strings.Split(settings, ",") {
+			key, value, hasValue := strings.Cut(strings.TrimSpace(setting), "=")
+			if !hasValue {
+				return nil, fmt.Errorf("quota collection %s: expect key=value in %q", collection, setting)
+			}
+			var err error
+			switch value = strings.TrimSpace(value); key {
+			case "maxVolumes":
+				quota.MaxVolumeCount, err = strconv.Atoi(value)
+			case "maxSize":
+				quota.MaxLogicalBytes, err = util.ParseBytes(value)
+			case "warn":
+				quota.WarnPercent, err = strconv.Atoi(value)
+				if err == nil && (quota.WarnPercent < 0 || quota.WarnPercent > 100) {
+					err = fmt.Errorf("not within [0, 100]")
+				}
+			default:
+				err = fmt.Errorf("unknown setting")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("quota collection %s: %s=%s: %v", collection, key, value, err)
+			}
+		}
+		quotas[collection] = quota
+	}
+	return quotas, nil
+}
+
+func (ms *MasterServer) loopCollectionQuotas() {
+	for {
+		time.Sleep(collectionQuotaRefreshInterval)
+		if ms.Topo.IsLeader() {
+			ms.refreshCollectionQuotas()
+		}
+	}
+}
+
+func (ms *MasterServer) refreshCollectionQuotas() {
+	for _, usage := range ms.Topo.RefreshCollectionQuotas() {
+		if usage.State == topology.QuotaOk {
+			glog.V(0).Infof("collection %s is back within its quota", usage.Collection)
+		} else {
+			glog.Warningf("collection %s quota %s: %d of %d volumes, %d of %d bytes", usage.Collection, usage.State,
+				usage.VolumeCount, usage.Quota.MaxVolumeCount, usage.LogicalBytes, usage.Quota.MaxLogicalBytes)
+		}
+		ms.publishTopologyEvent(&master_pb.TopologyEvent{
+			Type:            master_pb.TopologyEvent_QUOTA_STATE_CHANGED,
+			CollectionQuota: toCollectionQuotaMessage(usage),
+		})
+	}
+}
+
+func toCollectionQuotaMessage(usage topology.CollectionQuotaUsage) *master_pb.CollectionQuota {
+	return &master_pb.CollectionQuota{
+		Collection:      usage.Collection,
+		MaxVolumeCount:  int64(usage.Quota.MaxVolumeCount),
+		MaxLogicalBytes: usage.Quota.MaxLogicalBytes,
+		WarnPercent:     int32(usage.Quota.WarnPercent),
+		VolumeCount:     int64(usage.VolumeCount),
+		LogicalBytes:    usage.LogicalBytes,
+		State:           string(usage.State),
+	}
+}
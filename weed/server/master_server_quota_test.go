@@ -0,0 +1,24 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+func TestParseCollectionQuotas(t *testing.T) {
+	quotas, err := ParseCollectionQuotas("tenant1:maxVolumes=100,maxSize=1KiB,warn=90; tenant2:maxSize=2KiB")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(quotas) != 2 ||
+		*quotas["tenant1"] != (topology.CollectionQuota{MaxVolumeCount: 100, MaxLogicalBytes: 1024, WarnPercent: 90}) ||
+		*quotas["tenant2"] != (topology.CollectionQuota{MaxLogicalBytes: 2048, WarnPercent: defaultQuotaWarnPercent}) {
+		t.Errorf("quotas %+v", quotas)
+	}
+	for _, s := range []string{"tenant1", "tenant1:maxVolumes", "tenant1:warn=101", "tenant1:maxFiles=1"} {
+		if _, err = ParseCollectionQuotas(s); err == nil {
+			t.Errorf("parsed the invalid quota %q", s)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package weed_server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// The leader periodically moves the volumes from the most utilized volume servers to the least utilized ones
+// of the same disk type, until the utilization of the volume slots of all volume servers is within the band.
+// The moves keep the replica placement and the placement constraint of the collection.
+
+type RebalancePolicy struct {
+	Interval           time.Duration // 0 to disable
+	Window             *RebalanceWindow
+	MaxConcurrentMoves int
+	BytePerSecond      int64
+	Band               float64 // the allowed utilization difference between the volume servers, 0.1 for 10%
+}
+
+func (p RebalancePolicy) isEnabled() bool {
+	return p.Interval > 0
+}
+
+// RebalanceWindow is the daily time window to rebalance in, by the local time of the master
+type RebalanceWindow struct {
+	start, end time.Duration // since midnight
+}
+
+// ParseRebalanceWindow parses the daily window, e.g. "01:00-05:00", or "22:00-04:00" across midnight
+func ParseRebalanceWindow(s string) (*RebalanceWindow, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	start, end, found := strings.Cut(s, "-")
+	if !found {
+		return nil, fmt.Errorf("rebalance window %q: expect HH:MM-HH:MM", s)
+	}
+	w := &RebalanceWindow{}
+	var err error
+	if w.start, err = parseTimeOfDay(start); err != nil {
+		return nil, fmt.Errorf("rebalance window %q: %v", s, err)
+	}
+	if w.end, err = parseTimeOfDay(end); err != nil {
+		return nil, fmt.Errorf("rebalance window %q: %v", s, err)
+	}
+	if w.start == w.end {
+		return nil, fmt.Errorf("rebalance window %q is empty", s)
+	}
+	return w, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains is true for any time without a window
+func (w *RebalanceWindow) Contains(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.start < w.end {
+		return w.start <= sinceMidnight && sinceMidnight < w.end
+	}
+	return w.start <= sinceMidnight || sinceMidnight < w.end
+}
+
+type rebalanceMove struct {
+	volume storage.VolumeInfo
+	source *topology.DataNode
+	target *topology.DataNode
+}
+
+func (ms *MasterServer) loopRebalance() {
+	policy := ms.option.RebalancePolicy
+	if !policy.isEnabled() {
+		return
+	}
+	for {
+		time.Sleep(policy.Interval)
+		if !ms.Topo.IsLeader() || !policy.Window.Contains(time.Now()) {
+			continue
+		}
+		ms.rebalanceOnce(policy)
+	}
+}
+
+// rebalanceOnce runs up to the max concurrent moves, and waits for them to finish
+func (ms *MasterServer) rebalanceOnce(policy RebalancePolicy) {
+	moves := pickRebalanceMoves(policy, ms.Topo.ListDataNodes(), ms.option.PlacementCollections)
+	var wg sync.WaitGroup
+	for _, m := range moves {
+		wg.Add(1)
+		go func(m *rebalanceMove) {
+			defer wg.Done()
+			doneMoving, err := ms.startVolumeMove(m.volume.Id, "rebalance")
+			if err != nil {
+				glog.V(1).Infof("skip rebalancing volume %d: %v", m.volume.Id, err)
+				return
+			}
+			defer doneMoving()
+			defer ms.expectTopologyChange(m.volume.Id, "rebalance")()
+			glog.V(0).Infof("rebalance volume %d from %s to %s", m.volume.Id, m.source.Url(), m.target.Url())
+			if err := ms.moveVolume(m.volume, m.source, m.target, m.volume.DiskType, policy.BytePerSecond); err != nil {
+				glog.Warningf("rebalance volume %d: %v", m.volume.Id, err)
+			}
+		}(m)
+	}
+	wg.Wait()
+}
+
+// rebalanceNode is the usage of the volume slots of one disk type of a volume server
+type rebalanceNode struct {
+	dn       *topology.DataNode
+	used     float64
+	max      float64
+	volumes  []storage.VolumeInfo
+	isSource bool
+}
+
+func (n *rebalanceNode) utilization() float64 {
+	return n.used / n.max
+}
+
+// pickRebalanceMoves plans up to the max concurrent moves, each from the most utilized volume server
+// to the least utilized one able to take one of its volumes, while their utilization differs more than the band.
+func pickRebalanceMoves(policy RebalancePolicy, dataNodes []*topology.DataNode, placements map[string]*topology.PlacementConstraint) (moves []*rebalanceMove) {
+	maxMoves := policy.MaxConcurrentMoves
+	if maxMoves <= 0 {
+		maxMoves = 1
+	}
+
+	// the volume servers of each disk type, and the replicas of each volume
+	nodesByDiskType := make(map[types.DiskType][]*rebalanceNode)
+	replicas := make(map[needle.VolumeId][]*topology.DataNode)
+	for _, dn := range dataNodes {
+		for _, v := range dn.GetVolumes() {
+			replicas[v.Id] = append(replicas[v.Id], dn)
+		}
+		if dn.IsDraining() || dn.IsInMaintenance || dn.IsTerminating {
+			continue
+		}
+		for diskType, diskInfo := range dn.ToDataNodeInfo().DiskInfos {
+			if diskInfo.MaxVolumeCount <= 0 {
+				continue
+			}
+			n := &rebalanceNode{
+				dn:   dn,
+				used: float64(diskInfo.VolumeCount - diskInfo.RemoteVolumeCount),
+				max:  float64(diskInfo.MaxVolumeCount),
+			}
+			for _, ecShardInfo := range diskInfo.EcShardInfos {
+				n.used += float64(erasure_coding.ShardBits(ecShardInfo.EcIndexBits).ShardIdCount()) / erasure_coding.DataShardsCount
+			}
+			for _, v := range dn.GetVolumes() {
+				if types.ToDiskType(v.DiskType) == types.ToDiskType(diskType) && !v.IsRemote() {
+					n.volumes = append(n.volumes, v)
+				}
+			}
+			// prefer moving the read only volumes, not written any more
+			sort.SliceStable(n.volumes, func(i, j int) bool {
+				if n.volumes[i].ReadOnly != n.volumes[j].ReadOnly {
+					return n.volumes[i].ReadOnly
+				}
+				return n.volumes[i].Id < n.volumes[j].Id
+			})
+			nodesByDiskType[types.ToDiskType(diskType)] = append(nodesByDiskType[types.ToDiskType(diskType)], n)
+		}
+	}
+
+	var diskTypes []types.DiskType
+	for diskType := range nodesByDiskType {
+		diskTypes = append(diskTypes, diskType)
+	}
+	sort.Slice(diskTypes, func(i, j int) bool {
+		return diskTypes[i] < diskTypes[j]
+	})
+
+	moved := make(map[needle.VolumeId]bool)
+	for _, diskType := range diskTypes {
+		nodes := nodesByDiskType[diskType]
+		for len(moves) < maxMoves {
+			m := pickOneRebalanceMove(policy.Band, nodes, replicas, placements, moved)
+			if m == nil {
+				break
+			}
+			moves = append(moves, m)
+		}
+	}
+	return
+}
+
+func pickOneRebalanceMove(band float64, nodes []*rebalanceNode, replicas map[needle.VolumeId][]*topology.DataNode,
+	placements map[string]*topology.PlacementConstraint, moved map[needle.VolumeId]bool) *rebalanceMove {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].utilization() > nodes[j].utilization()
+	})
+	for _, source := range nodes {
+		for t := len(nodes) - 1; t >= 0; t-- {
+			target := nodes[t]
+			if source.utilization()-target.utilization() <= band {
+				break
+			}
+			// not to move back and forth
+			if (target.used+1)/target.max > (source.used-1)/source.max {
+				continue
+			}
+			for i, v := range source.volumes {
+				if moved[v.Id] || target.dn.HasVolumesById(v.Id) ||
+					!placements[v.Collection].Match(target.dn.GetTags()) ||
+					!keepsReplicaPlacement(source.dn, target.dn, replicas[v.Id]) {
+					continue
+				}
+				moved[v.Id] = true
+				source.volumes = append(source.volumes[:i:i], source.volumes[i+1:]...)
+				source.used--
+				target.used++
+				return &rebalanceMove{volume: v, source: source.dn, target: target.dn}
+			}
+		}
+	}
+	return nil
+}
+
+// keepsReplicaPlacement checks the other replicas are in the same relative locations after the move:
+// moving within the rack always keeps the placement, moving to another rack or data center
+// only if neither the source one nor the target one has any other replica.
+func keepsReplicaPlacement(source, target *topology.DataNode, replicas []*topology.DataNode) bool {
+	if source.GetRack() == target.GetRack() {
+		return true
+	}
+	sameDataCenter := source.GetDataCenter() == target.GetDataCenter()
+	for _, replica := range replicas {
+		if replica == source {
+			continue
+		}
+		if sameDataCenter {
+			if replica.GetRack() == source.GetRack() || replica.GetRack() == target.GetRack() {
+				return false
+			}
+		} else if replica.GetDataCenter() == source.GetDataCenter() || replica.GetDataCenter() == target.GetDataCenter() {
+			return false
+		}
+	}
+	return true
+}
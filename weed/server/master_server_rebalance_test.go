@@ -0,0 +1,83 @@
+package weed_server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+func TestRebalanceWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2023, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+	night, err := ParseRebalanceWindow("22:00-04:30")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !night.Contains(at(23, 0)) || !night.Contains(at(4, 29)) || night.Contains(at(4, 30)) || night.Contains(at(12, 0)) {
+		t.Errorf("window across midnight")
+	}
+	day, _ := ParseRebalanceWindow("09:00-17:00")
+	if !day.Contains(at(9, 0)) || day.Contains(at(17, 0)) || day.Contains(at(8, 59)) {
+		t.Errorf("window within the day")
+	}
+	if any, _ := ParseRebalanceWindow(""); !any.Contains(at(3, 0)) {
+		t.Errorf("no window")
+	}
+	for _, s := range []string{"01:00", "1am-2am", "01:00-01:00"} {
+		if _, err = ParseRebalanceWindow(s); err == nil {
+			t.Errorf("parsed the invalid window %q", s)
+		}
+	}
+}
+
+func TestPickRebalanceMoves(t *testing.T) {
+	topo, nodes := newTestTopology(32*1024, []testNodeLayout{
+		{"rack1", "a"}, {"rack1", "b"}, {"rack2", "c"}, {"rack3", "d"},
+	})
+	volume := func(id uint32, replicaPlacement uint32) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{Id: id, ReplicaPlacement: replicaPlacement, Version: uint32(needle.CurrentVersion)}
+	}
+	// volume 1 and 2 with a replica on another rack, volume 3 with a replica on the same rack
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{
+		volume(1, 10), volume(2, 10), volume(3, 1), volume(4, 0), volume(5, 0), volume(6, 0),
+	}, nodes["a"])
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{volume(3, 1)}, nodes["b"])
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{volume(1, 10), volume(2, 10)}, nodes["c"])
+	nodes["d"].SetDraining(true)
+
+	policy := RebalancePolicy{Interval: time.Minute, MaxConcurrentMoves: 3, Band: 0.1}
+	moves := pickRebalanceMoves(policy, topo.ListDataNodes(), nil)
+	if len(moves) != 3 {
+		t.Fatalf("%d moves, expected 3", len(moves))
+	}
+	expected := []struct {
+		vid    needle.VolumeId
+		target string
+	}{
+		// volume 3 stays on the rack of its other replica
+		{1, "b"}, {2, "b"}, {4, "c"},
+	}
+	for i, m := range moves {
+		if m.source != nodes["a"] || m.volume.Id != expected[i].vid || m.target != nodes[expected[i].target] {
+			t.Errorf("move %d: volume %d from %s to %s, expected volume %d to %s", i,
+				m.volume.Id, m.source.Id(), m.target.Id(), expected[i].vid, expected[i].target)
+		}
+	}
+
+	policy.Band = 0.5
+	if moves = pickRebalanceMoves(policy, topo.ListDataNodes(), nil); len(moves) != 0 {
+		t.Errorf("%d moves within the band", len(moves))
+	}
+
+	// moving to another rack keeps the placement only without other replicas on the source or target rack
+	if !keepsReplicaPlacement(nodes["a"], nodes["d"], []*topology.DataNode{nodes["a"], nodes["c"]}) {
+		t.Errorf("volume 1 can move to rack3")
+	}
+	if keepsReplicaPlacement(nodes["a"], nodes["c"], []*topology.DataNode{nodes["a"], nodes["b"]}) {
+		t.Errorf("volume 3 moved away from its replica on the same rack")
+	}
+}
@@ -0,0 +1,270 @@
+package weed_server
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// When a volume server dies, its volumes miss replicas. The leader re-replicates them, paced so the repair
+// does not saturate the cluster: a volume is repaired only after missing replicas for the start delay,
+// since most volume servers come back after a restart, only a few copies run at a time sharing the bandwidth,
+// and the volumes with the fewest replicas left go first. The volumes on the offline volume servers
+// in maintenance wait until the grace period ends.
+
+const repairCheckInterval = 30 * time.Second
+
+// RepairPolicy paces the re-replication. A zero MaxConcurrent leaves the repair to volume.fix.replication.
+type RepairPolicy struct {
+	MaxConcurrent int
+	Delay         time.Duration // how long a volume misses replicas before it is repaired
+	BytePerSecond int64         // shared by the concurrent copies, 0 for no limit
+}
+
+func (p RepairPolicy) isEnabled() bool {
+	return p.MaxConcurrent > 0
+}
+
+// copyBytePerSecond is the share of the bandwidth of each copy
+func (p RepairPolicy) copyBytePerSecond() int64 {
+	if p.BytePerSecond <= 0 {
+		return 0
+	}
+	if share := p.BytePerSecond / int64(p.MaxConcurrent); share > 0 {
+		return share
+	}
+	return 1
+}
+
+// volumeRepair is a volume missing replicas
+type volumeRepair struct {
+	volume    storage.VolumeInfo
+	replicas  []*topology.DataNode
+	firstSeen time.Time
+	deferred  bool   // the missing replicas are on the volume servers in maintenance
+	target    string // the volume server copied to, while repairing
+	copiedAt  time.Time
+	lastError string
+}
+
+func (r *volumeRepair) missing() int {
+	return r.volume.ReplicaPlacement.GetCopyCount() - len(r.replicas)
+}
+
+// repairsBefore orders the volumes with fewer replicas left first, then the ones missing more replicas,
+// then the ones missing replicas for longer
+func repairsBefore(a, b *volumeRepair) bool {
+	if len(a.replicas) != len(b.replicas) {
+		return len(a.replicas) < len(b.replicas)
+	}
+	if a.missing() != b.missing() {
+		return a.missing() > b.missing()
+	}
+	if !a.firstSeen.Equal(b.firstSeen) {
+		return a.firstSeen.Before(b.firstSeen)
+	}
+	return a.volume.Id < b.volume.Id
+}
+
+func sortRepairs(repairs []*volumeRepair) {
+	sort.Slice(repairs, func(i, j int) bool {
+		return repairsBefore(repairs[i], repairs[j])
+	})
+}
+
+// findUnderReplicatedVolumes lists the volumes with fewer replicas than their replica placement, with the replicas left
+func findUnderReplicatedVolumes(dataNodes []*topology.DataNode) map[needle.VolumeId]*volumeRepair {
+	found := make(map[needle.VolumeId]*volumeRepair)
+	for _, dn := range dataNodes {
+		for _, v := range dn.GetVolumes() {
+			if v.ReplicaPlacement == nil {
+				continue
+			}
+			r, ok := found[v.Id]
+			if !ok {
+				r = &volumeRepair{volume: v}
+				found[v.Id] = r
+			}
+			r.replicas = append(r.replicas, dn)
+		}
+	}
+	for vid, r := range found {
+		if r.missing() <= 0 {
+			delete(found, vid)
+		}
+	}
+	return found
+}
+
+// updateRepairs keeps the volumes missing replicas, remembering since when, and the ones being repaired
+func updateRepairs(repairs map[needle.VolumeId]*volumeRepair, found map[needle.VolumeId]*volumeRepair, deferred map[needle.VolumeId]bool, now time.Time) {
+	for vid, r := range repairs {
+		if _, ok := found[vid]; !ok && r.target == "" {
+			delete(repairs, vid)
+		}
+	}
+	for vid, f := range found {
+		r, ok := repairs[vid]
+		if !ok {
+			r = &volumeRepair{firstSeen: now}
+			repairs[vid] = r
+		}
+		r.volume, r.replicas, r.deferred = f.volume, f.replicas, deferred[vid]
+	}
+}
+
+// pickVolumeRepairs picks the most urgent volumes past the start delay, up to the free repair slots
+func pickVolumeRepairs(policy RepairPolicy, repairs map[needle.VolumeId]*volumeRepair, now time.Time) (picked []*volumeRepair) {
+	slots := policy.MaxConcurrent
+	var candidates []*volumeRepair
+	for _, r := range repairs {
+		if r.target != "" {
+			slots--
+			continue
+		}
+		// a volume just copied waits for its new replica to be reported
+		if r.deferred || now.Sub(r.firstSeen) < policy.Delay || now.Sub(r.copiedAt) < repairCheckInterval {
+			continue
+		}
+		candidates = append(candidates, r)
+	}
+	sortRepairs(candidates)
+	for _, r := range candidates {
+		if len(picked) >= slots {
+			break
+		}
+		picked = append(picked, r)
+	}
+	return
+}
+
+// pickRepairTarget picks the volume server with the most free slots among the ones keeping the replica placement,
+// preferring the racks and data centers with fewer replicas of the volume
+func pickRepairTarget(r *volumeRepair, dataNodes []*topology.DataNode, placement *topology.PlacementConstraint) (target *topology.DataNode) {
+	rp := r.volume.ReplicaPlacement
+	dataCenters := make(map[*topology.DataCenter]int)
+	racks := make(map[*topology.Rack]int)
+	racksOfDataCenter := make(map[*topology.DataCenter]map[*topology.Rack]bool)
+	for _, dn := range r.replicas {
+		dc := dn.GetDataCenter()
+		dataCenters[dc]++
+		racks[dn.GetRack()]++
+		if racksOfDataCenter[dc] == nil {
+			racksOfDataCenter[dc] = make(map[*topology.Rack]bool)
+		}
+		racksOfDataCenter[dc][dn.GetRack()] = true
+	}
+
+	option := &topology.VolumeGrowOption{DiskType: types.ToDiskType(r.volume.DiskType), Placement: placement}
+	var best [3]int64
+	for _, dn := range dataNodes {
+		if dn.IsDraining() || dn.IsInMaintenance || dn.IsTerminating || dn.HasVolumesById(r.volume.Id) {
+			continue
+		}
+		free := dn.AvailableSpaceFor(option)
+		if free <= 0 {
+			continue
+		}
+		dc, rack := dn.GetDataCenter(), dn.GetRack()
+		if dataCenters[dc] == 0 && len(dataCenters) > rp.DiffDataCenterCount {
+			continue
+		}
+		if racks[rack] == 0 && len(racksOfDataCenter[dc]) > rp.DiffRackCount {
+			continue
+		}
+		if racks[rack] > rp.SameRackCount {
+			continue
+		}
+		score := [3]int64{int64(racks[rack]), int64(dataCenters[dc]), -free}
+		if target == nil || lessScore(score, best) {
+			target, best = dn, score
+		}
+	}
+	return
+}
+
+// repairSource is a replica not on a failing disk, if any
+func repairSource(r *volumeRepair) *topology.DataNode {
+	for _, dn := range r.replicas {
+		for _, v := range dn.GetVolumes() {
+			if v.Id == r.volume.Id && !v.DiskFailing {
+				return dn
+			}
+		}
+	}
+	return r.replicas[0]
+}
+
+func (ms *MasterServer) loopRepair() {
+	policy := ms.option.RepairPolicy
+	if !policy.isEnabled() {
+		return
+	}
+	for {
+		time.Sleep(repairCheckInterval)
+		if !ms.Topo.IsLeader() {
+			ms.repairsLock.Lock()
+			ms.repairs = make(map[needle.VolumeId]*volumeRepair)
+			ms.repairsLock.Unlock()
+			continue
+		}
+		ms.repairOnce(policy)
+	}
+}
+
+// repairOnce starts repairing the most urgent volumes, without waiting for the copies
+func (ms *MasterServer) repairOnce(policy RepairPolicy) {
+	now := time.Now()
+	dataNodes := ms.Topo.ListDataNodes()
+	found := findUnderReplicatedVolumes(dataNodes)
+	deferred := ms.deferredVolumeIds(now)
+
+	ms.repairsLock.Lock()
+	defer ms.repairsLock.Unlock()
+	updateRepairs(ms.repairs, found, deferred, now)
+	for _, r := range pickVolumeRepairs(policy, ms.repairs, now) {
+		target := pickRepairTarget(r, dataNodes, ms.option.PlacementCollections[r.volume.Collection])
+		if target == nil {
+			r.lastError = "no volume server keeps the replica placement"
+			continue
+		}
+		r.target = target.Url()
+		go ms.repairVolume(policy, r, repairSource(r), target)
+	}
+}
+
+func (ms *MasterServer) repairVolume(policy RepairPolicy, r *volumeRepair, source, target *topology.DataNode) {
+	v := r.volume
+	doneMoving, err := ms.startVolumeMove(v.Id, "repair")
+	if err != nil {
+		ms.repairsLock.Lock()
+		r.target, r.lastError = "", err.Error()
+		ms.repairsLock.Unlock()
+		return
+	}
+	defer doneMoving()
+	glog.V(0).Infof("re-replicate volume %d with %d of %d replicas from %s to %s",
+		v.Id, len(r.replicas), v.ReplicaPlacement.GetCopyCount(), source.Url(), target.Url())
+	done := ms.expectTopologyChange(v.Id, fmt.Sprintf("repair with %d of %d replicas", len(r.replicas), v.ReplicaPlacement.GetCopyCount()))
+	err = ms.copyVolume(v, source, target, v.DiskType, policy.copyBytePerSecond())
+	done()
+
+	ms.repairsLock.Lock()
+	defer ms.repairsLock.Unlock()
+	r.target = ""
+	if err != nil {
+		glog.Warningf("re-replicate volume %d: %v", v.Id, err)
+		r.lastError = err.Error()
+		ms.repairFailedCount++
+		return
+	}
+	r.lastError = ""
+	r.copiedAt = time.Now()
+	ms.repairedCount++
+}
@@ -0,0 +1,70 @@
+package weed_server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func TestVolumeRepairs(t *testing.T) {
+	topo, nodes := newTestTopology(32*1024, []testNodeLayout{
+		{"rack1", "a"}, {"rack1", "b"}, {"rack2", "c"}, {"rack3", "d"},
+	})
+	volume := func(id uint32, replicaPlacement uint32) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{Id: id, ReplicaPlacement: replicaPlacement, Version: uint32(needle.CurrentVersion)}
+	}
+	// volume 1 "020" has 2 of 3 replicas, volume 2 "010" has 1 of 2, volume 3 "001" has 1 of 2, volume 4 "010" has both
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{volume(1, 20), volume(2, 10), volume(3, 1), volume(4, 10)}, nodes["a"])
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{volume(1, 20), volume(4, 10)}, nodes["c"])
+
+	found := findUnderReplicatedVolumes(topo.ListDataNodes())
+	if len(found) != 3 || found[4] != nil {
+		t.Fatalf("found %d under replicated volumes, expected 1, 2 and 3", len(found))
+	}
+
+	start := time.Now()
+	repairs := make(map[needle.VolumeId]*volumeRepair)
+	updateRepairs(repairs, found, map[needle.VolumeId]bool{3: true}, start)
+	policy := RepairPolicy{MaxConcurrent: 2, Delay: time.Minute}
+	if picked := pickVolumeRepairs(policy, repairs, start.Add(time.Second)); len(picked) != 0 {
+		t.Errorf("picked %d repairs before the start delay", len(picked))
+	}
+
+	// volume 2 with the fewest replicas left goes first, volume 3 is deferred
+	now := start.Add(time.Minute)
+	picked := pickVolumeRepairs(policy, repairs, now)
+	if len(picked) != 2 || picked[0].volume.Id != 2 || picked[1].volume.Id != 1 {
+		t.Fatalf("picked %d repairs, expected volumes 2 and 1", len(picked))
+	}
+	picked[0].target = "copying"
+	if picked = pickVolumeRepairs(RepairPolicy{MaxConcurrent: 1}, repairs, now); len(picked) != 0 {
+		t.Errorf("picked %d repairs beyond the max concurrent", len(picked))
+	}
+
+	// the replica placement decides the rack of the new replica
+	if target := pickRepairTarget(repairs[2], topo.ListDataNodes(), nil); target == nil || target.GetRack() == nodes["a"].GetRack() {
+		t.Errorf("volume 2 \"010\" copied to %v, expected another rack", target)
+	}
+	if target := pickRepairTarget(repairs[3], topo.ListDataNodes(), nil); target != nodes["b"] {
+		t.Errorf("volume 3 \"001\" copied to %v, expected b on the same rack", target)
+	}
+	if target := pickRepairTarget(repairs[1], topo.ListDataNodes(), nil); target != nodes["d"] {
+		t.Errorf("volume 1 \"020\" copied to %v, expected d on the third rack", target)
+	}
+
+	// the volumes fully replicated again are dropped, unless being copied
+	updateRepairs(repairs, map[needle.VolumeId]*volumeRepair{}, nil, now)
+	if len(repairs) != 1 || repairs[2] == nil {
+		t.Errorf("kept %d repairs, expected volume 2 being copied", len(repairs))
+	}
+
+	if share := policy.copyBytePerSecond(); share != 0 {
+		t.Errorf("copy rate %d without a bandwidth limit", share)
+	}
+	policy.BytePerSecond = 100
+	if share := policy.copyBytePerSecond(); share != 50 {
+		t.Errorf("copy rate %d, expected half of the bandwidth", share)
+	}
+}
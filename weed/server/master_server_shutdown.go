@@ -0,0 +1,84 @@
+package weed_server
+
+import (
+	"sync"
+	"time"
+
+	hashicorpRaft "github.com/hashicorp/raft"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+// On shutdown, the master stops admitting the assign and lookup requests, with 503 or Unavailable and
+// a hint to retry, so the clients turn to the other masters. It waits for the requests in flight to finish,
+// and then hands the raft leadership over before leaving, so a rolling upgrade of the masters does not
+// fail the requests caught in between.
+
+// requestDrainer tracks the assign and lookup requests in flight, and stops admitting them once draining
+type requestDrainer struct {
+	lock     sync.Mutex
+	inFlight int
+	draining bool
+	drained  chan struct{}
+}
+
+// begin admits one request unless draining. The caller must call end when done.
+func (d *requestDrainer) begin() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.draining {
+		return false
+	}
+	d.inFlight++
+	return true
+}
+
+func (d *requestDrainer) end() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.inFlight--
+	if d.draining && d.inFlight == 0 {
+		close(d.drained)
+	}
+}
+
+// drain stops admitting the requests, and waits up to the timeout for the ones in flight,
+// returning how many are left
+func (d *requestDrainer) drain(timeout time.Duration) int {
+	d.lock.Lock()
+	if !d.draining {
+		d.draining = true
+		d.drained = make(chan struct{})
+		if d.inFlight == 0 {
+			close(d.drained)
+		}
+	}
+	drained := d.drained
+	d.lock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+	case <-timer.C:
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.inFlight
+}
+
+func (ms *MasterServer) Shutdown() {
+	if left := ms.requests.drain(ms.option.ShutdownDrainTimeout); left > 0 {
+		glog.Warningf("shutting down with %d assign and lookup requests in flight after %v", left, ms.option.ShutdownDrainTimeout)
+	}
+	if ms.Topo == nil || ms.Topo.HashicorpRaft == nil {
+		return
+	}
+	if ms.Topo.HashicorpRaft.State() == hashicorpRaft.Leader {
+		if err := ms.Topo.HashicorpRaft.LeadershipTransfer().Error(); err != nil {
+			glog.Warningf("transfer the raft leadership before shutting down: %v", err)
+		}
+	}
+	ms.Topo.HashicorpRaft.Shutdown()
+}
@@ -0,0 +1,32 @@
+package weed_server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRequestDrainer(t *testing.T) {
+	d := &requestDrainer{}
+	if !d.begin() || !d.begin() {
+		t.Fatalf("not admitted before draining")
+	}
+	d.end()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		d.end()
+	}()
+	if left := d.drain(time.Second); left != 0 {
+		t.Errorf("%d requests left after draining", left)
+	}
+	if d.begin() {
+		t.Errorf("admitted while draining")
+	}
+
+	d = &requestDrainer{}
+	d.begin()
+	if left := d.drain(10 * time.Millisecond); left != 1 {
+		t.Errorf("%d requests left after timing out, expected 1", left)
+	}
+	d.end()
+}
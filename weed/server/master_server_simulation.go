@@ -0,0 +1,328 @@
+package weed_server
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// The placement simulation applies a hypothetical change to a copy of the topology, and places the volumes
+// the way the master and volume.balance would: the extra replicas are deleted, the missing replicas are
+// copied again by the re-replication, and the volumes are moved by balancing. Nothing is changed in the cluster,
+// so the operators see the data movement of an expansion, or of a rack removal, before starting it.
+
+type placementSimulation struct {
+	topo       *topology.Topology
+	placements map[string]*topology.PlacementConstraint
+	nodes      map[*topology.DataNode]*master_pb.SimulatePlacementResponse_NodePlacement
+	resp       *master_pb.SimulatePlacementResponse
+}
+
+func simulatePlacement(dataNodes []*topology.DataNode, req *master_pb.SimulatePlacementRequest, placements map[string]*topology.PlacementConstraint) (*master_pb.SimulatePlacementResponse, error) {
+	replications := make(map[string]*super_block.ReplicaPlacement)
+	for _, r := range req.Replications {
+		rp, err := super_block.NewReplicaPlacementFromString(r.Replication)
+		if err != nil {
+			return nil, fmt.Errorf("replication %q of collection %s: %v", r.Replication, r.Collection, err)
+		}
+		replications[r.Collection] = rp
+	}
+	removedRacks, removedNodes := make(map[string]bool), make(map[string]bool)
+	for _, rack := range req.RemoveRacks {
+		removedRacks[rack] = false
+	}
+	for _, node := range req.RemoveNodes {
+		removedNodes[node] = false
+	}
+
+	s := &placementSimulation{
+		topo:       topology.NewTopology("simulation", sequence.NewMemorySequencer(), 0, 0, false),
+		placements: placements,
+		nodes:      make(map[*topology.DataNode]*master_pb.SimulatePlacementResponse_NodePlacement),
+		resp:       &master_pb.SimulatePlacementResponse{},
+	}
+	keptVolumes, removedVolumes := make(map[needle.VolumeId]bool), make(map[needle.VolumeId]bool)
+	for _, dn := range dataNodes {
+		dcId, rackId := dn.GetDataCenterId(), dn.GetRackId()
+		maxVolumeCounts := make(map[string]uint32)
+		node := &master_pb.SimulatePlacementResponse_NodePlacement{Url: dn.Url(), DataCenter: dcId, Rack: rackId}
+		for diskType, diskInfo := range dn.ToDataNodeInfo().DiskInfos {
+			maxVolumeCounts[diskType] = uint32(diskInfo.MaxVolumeCount)
+			node.MaxVolumeCount += uint32(diskInfo.MaxVolumeCount)
+		}
+		volumes := dn.GetVolumes()
+		node.VolumeCountBefore = uint32(len(volumes))
+		s.resp.Nodes = append(s.resp.Nodes, node)
+
+		rackKey := dcId + ":" + rackId
+		_, isRackRemoved := removedRacks[rackKey]
+		_, isNodeRemoved := removedNodes[dn.Url()]
+		if isRackRemoved {
+			removedRacks[rackKey] = true
+		}
+		if isNodeRemoved {
+			removedNodes[dn.Url()] = true
+		}
+		if isRackRemoved || isNodeRemoved {
+			node.IsRemoved = true
+			for _, v := range volumes {
+				removedVolumes[v.Id] = true
+			}
+			continue
+		}
+
+		simulated := s.topo.GetOrCreateDataCenter(dcId).GetOrCreateRack(rackId).GetOrCreateDataNode(dn.Ip, dn.Port, dn.GrpcPort, dn.PublicUrl, maxVolumeCounts)
+		simulated.IsInMaintenance, simulated.Weight = dn.IsInMaintenance, dn.Weight
+		simulated.SetDraining(dn.IsDraining())
+		simulated.SetTags(dn.GetTags())
+		for i, v := range volumes {
+			if rp, found := replications[v.Collection]; found {
+				volumes[i].ReplicaPlacement = rp
+			}
+			keptVolumes[v.Id] = true
+		}
+		simulated.DeltaUpdateVolumes(volumes, nil)
+		s.nodes[simulated] = node
+	}
+	for rack, found := range removedRacks {
+		if !found {
+			return nil, fmt.Errorf("rack %s not found", rack)
+		}
+	}
+	for node, found := range removedNodes {
+		if !found {
+			return nil, fmt.Errorf("volume server %s not found", node)
+		}
+	}
+	for vid := range removedVolumes {
+		if !keptVolumes[vid] {
+			s.resp.LostVolumeIds = append(s.resp.LostVolumeIds, uint32(vid))
+		}
+	}
+	sort.Slice(s.resp.LostVolumeIds, func(i, j int) bool {
+		return s.resp.LostVolumeIds[i] < s.resp.LostVolumeIds[j]
+	})
+
+	if err := s.addNodes(req.AddNodes); err != nil {
+		return nil, err
+	}
+
+	simulatedNodes := s.topo.ListDataNodes()
+	s.deleteExtraReplicas(simulatedNodes)
+	s.copyMissingReplicas(simulatedNodes)
+	for _, diskType := range volumeDiskTypes(simulatedNodes) {
+		s.balance(simulatedNodes, diskType)
+	}
+
+	for dn, node := range s.nodes {
+		node.VolumeCountAfter = uint32(len(dn.GetVolumes()))
+	}
+	sort.Slice(s.resp.Nodes, func(i, j int) bool {
+		a, b := s.resp.Nodes[i], s.resp.Nodes[j]
+		if a.DataCenter != b.DataCenter {
+			return a.DataCenter < b.DataCenter
+		}
+		if a.Rack != b.Rack {
+			return a.Rack < b.Rack
+		}
+		return a.Url < b.Url
+	})
+	return s.resp, nil
+}
+
+func (s *placementSimulation) addNodes(addNodes []*master_pb.SimulatePlacementRequest_NewNodes) error {
+	var added int
+	for _, n := range addNodes {
+		if n.DataCenter == "" || n.Rack == "" || n.Count == 0 || n.MaxVolumeCount == 0 {
+			return fmt.Errorf("the new volume servers need a data center, a rack, a count, and a max volume count")
+		}
+		diskType := string(types.ToDiskType(n.DiskType))
+		rack := s.topo.GetOrCreateDataCenter(n.DataCenter).GetOrCreateRack(n.Rack)
+		for i := uint32(0); i < n.Count; i++ {
+			added++
+			dn := rack.GetOrCreateDataNode(fmt.Sprintf("new-%d", added), 8080, 0, "", map[string]uint32{diskType: n.MaxVolumeCount})
+			dn.Weight = n.Weight
+			node := &master_pb.SimulatePlacementResponse_NodePlacement{
+				Url:            dn.Url(),
+				DataCenter:     n.DataCenter,
+				Rack:           n.Rack,
+				MaxVolumeCount: n.MaxVolumeCount,
+				IsAdded:        true,
+			}
+			s.resp.Nodes = append(s.resp.Nodes, node)
+			s.nodes[dn] = node
+		}
+	}
+	return nil
+}
+
+// deleteExtraReplicas deletes the replicas over a lowered replica placement, from the fullest volume servers
+func (s *placementSimulation) deleteExtraReplicas(dataNodes []*topology.DataNode) {
+	replicas := make(map[needle.VolumeId][]*topology.DataNode)
+	volumes := make(map[needle.VolumeId]storage.VolumeInfo)
+	for _, dn := range dataNodes {
+		for _, v := range dn.GetVolumes() {
+			replicas[v.Id] = append(replicas[v.Id], dn)
+			volumes[v.Id] = v
+		}
+	}
+	for vid, nodes := range replicas {
+		v := volumes[vid]
+		if v.ReplicaPlacement == nil || len(nodes) <= v.ReplicaPlacement.GetCopyCount() {
+			continue
+		}
+		sort.Slice(nodes, func(i, j int) bool {
+			return len(nodes[i].GetVolumes()) > len(nodes[j].GetVolumes())
+		})
+		for _, dn := range nodes[:len(nodes)-v.ReplicaPlacement.GetCopyCount()] {
+			dn.DeltaUpdateVolumes(nil, []storage.VolumeInfo{v})
+			s.resp.DeletedReplicaCount++
+			s.resp.DeletedBytes += v.Size
+		}
+	}
+}
+
+// copyMissingReplicas places the missing replicas as the re-replication would, in the same order
+func (s *placementSimulation) copyMissingReplicas(dataNodes []*topology.DataNode) {
+	var repairs []*volumeRepair
+	for _, r := range findUnderReplicatedVolumes(dataNodes) {
+		repairs = append(repairs, r)
+	}
+	sortRepairs(repairs)
+	for _, r := range repairs {
+		for missing := r.missing(); missing > 0; missing-- {
+			target := pickRepairTarget(r, dataNodes, s.placements[r.volume.Collection])
+			if target == nil {
+				s.resp.UnplacedReplicaCount += uint32(missing)
+				break
+			}
+			target.DeltaUpdateVolumes([]storage.VolumeInfo{r.volume}, nil)
+			r.replicas = append(r.replicas, target)
+			s.resp.CopiedReplicaCount++
+			s.resp.CopiedBytes += r.volume.Size
+		}
+	}
+}
+
+// balance moves the volumes of the disk type from the fullest volume servers to the emptiest ones,
+// by their volumes to their weighted volume slots, as volume.balance would
+func (s *placementSimulation) balance(dataNodes []*topology.DataNode, diskType string) {
+	capacities := make(map[*topology.DataNode]float64)
+	counts := make(map[*topology.DataNode]int)
+	var nodes []*topology.DataNode
+	var totalCapacity float64
+	var totalCount int
+	for _, dn := range dataNodes {
+		diskInfo, found := dn.ToDataNodeInfo().DiskInfos[diskType]
+		if !found || diskInfo.MaxVolumeCount == 0 || dn.IsDraining() || dn.IsInMaintenance {
+			continue
+		}
+		capacities[dn] = float64(diskInfo.MaxVolumeCount) * dn.PlacementWeight()
+		counts[dn] = len(volumesOfDiskType(dn, diskType))
+		nodes = append(nodes, dn)
+		totalCapacity += capacities[dn]
+		totalCount += counts[dn]
+	}
+	if len(nodes) < 2 {
+		return
+	}
+	idealRatio := float64(totalCount) / totalCapacity
+	ratio := func(dn *topology.DataNode, extra int) float64 {
+		return float64(counts[dn]+extra) / capacities[dn]
+	}
+
+	for moves := 0; moves < totalCount; moves++ {
+		sort.Slice(nodes, func(i, j int) bool {
+			return ratio(nodes[i], 0) < ratio(nodes[j], 0)
+		})
+		full := nodes[len(nodes)-1]
+		if ratio(full, 0) <= idealRatio {
+			return
+		}
+		moved := false
+		for _, empty := range nodes[:len(nodes)-1] {
+			if ratio(empty, 1) > idealRatio {
+				break
+			}
+			for _, v := range volumesOfDiskType(full, diskType) {
+				option := &topology.VolumeGrowOption{DiskType: types.ToDiskType(diskType), Placement: s.placements[v.Collection]}
+				if empty.AvailableSpaceFor(option) <= 0 || !canMoveReplica(v.Id, full, empty) {
+					continue
+				}
+				full.DeltaUpdateVolumes(nil, []storage.VolumeInfo{v})
+				empty.DeltaUpdateVolumes([]storage.VolumeInfo{v}, nil)
+				counts[full]--
+				counts[empty]++
+				s.resp.MovedVolumeCount++
+				s.resp.MovedBytes += v.Size
+				moved = true
+				break
+			}
+			if moved {
+				break
+			}
+		}
+		if !moved {
+			return
+		}
+	}
+}
+
+// canMoveReplica keeps the replicas of the volume in as many racks and data centers as before the move
+func canMoveReplica(vid needle.VolumeId, source, target *topology.DataNode) bool {
+	if target.HasVolumesById(vid) {
+		return false
+	}
+	if target.GetRack() == source.GetRack() {
+		return true
+	}
+	var racks []*topology.Rack
+	if target.GetDataCenter() == source.GetDataCenter() {
+		racks = append(racks, target.GetRack())
+	} else {
+		for _, rack := range target.GetDataCenter().Children() {
+			racks = append(racks, rack.(*topology.Rack))
+		}
+	}
+	for _, rack := range racks {
+		for _, n := range rack.Children() {
+			if n.(*topology.DataNode).HasVolumesById(vid) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func volumesOfDiskType(dn *topology.DataNode, diskType string) (volumes []storage.VolumeInfo) {
+	for _, v := range dn.GetVolumes() {
+		if v.DiskType == diskType {
+			volumes = append(volumes, v)
+		}
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].Id < volumes[j].Id
+	})
+	return
+}
+
+func volumeDiskTypes(dataNodes []*topology.DataNode) (diskTypes []string) {
+	found := make(map[string]bool)
+	for _, dn := range dataNodes {
+		for _, v := range dn.GetVolumes() {
+			if !found[v.DiskType] {
+				found[v.DiskType] = true
+				diskTypes = append(diskTypes, v.DiskType)
+			}
+		}
+	}
+	sort.Strings(diskTypes)
+	return
+}
@@ -0,0 +1,77 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func TestSimulatePlacement(t *testing.T) {
+	topo, nodes := newTestTopology(1024, []testNodeLayout{{"rack1", "a"}, {"rack1", "b"}, {"rack2", "c"}})
+	volume := func(id uint32, collection string, replication uint32) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{Id: id, Collection: collection, Size: 100, ReplicaPlacement: replication, Version: uint32(needle.CurrentVersion)}
+	}
+	// the volumes 1 to 4 are replicated on another rack
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{volume(1, "logs", 10), volume(2, "logs", 10)}, nodes["a"])
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{volume(3, "", 10), volume(4, "", 10)}, nodes["b"])
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{
+		volume(1, "logs", 10), volume(2, "logs", 10), volume(3, "", 10), volume(4, "", 10), volume(5, "", 0),
+	}, nodes["c"])
+	dataNodes := topo.ListDataNodes()
+
+	resp, err := simulatePlacement(dataNodes, &master_pb.SimulatePlacementRequest{
+		RemoveRacks: []string{"dc1:rack2"},
+		AddNodes:    []*master_pb.SimulatePlacementRequest_NewNodes{{DataCenter: "dc1", Rack: "rack3", Count: 1, MaxVolumeCount: 10}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("simulate replacing rack2: %v", err)
+	}
+	if resp.CopiedReplicaCount != 4 || resp.CopiedBytes != 400 || resp.UnplacedReplicaCount != 0 || resp.MovedVolumeCount != 0 {
+		t.Errorf("replacing rack2: %+v", resp)
+	}
+	if len(resp.LostVolumeIds) != 1 || resp.LostVolumeIds[0] != 5 {
+		t.Errorf("lost volumes %v", resp.LostVolumeIds)
+	}
+	if len(resp.Nodes) != 4 || !resp.Nodes[2].IsRemoved || resp.Nodes[2].VolumeCountBefore != 5 || !resp.Nodes[3].IsAdded || resp.Nodes[3].VolumeCountAfter != 4 {
+		t.Errorf("nodes %+v", resp.Nodes)
+	}
+
+	resp, err = simulatePlacement(dataNodes, &master_pb.SimulatePlacementRequest{
+		RemoveRacks: []string{"dc1:rack2"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("simulate removing rack2: %v", err)
+	}
+	if resp.CopiedReplicaCount != 0 || resp.UnplacedReplicaCount != 4 {
+		t.Errorf("removing the only other rack: %+v", resp)
+	}
+
+	// only the volume 5 is moved to the new volume servers on rack1, where the replicas of the others are
+	resp, err = simulatePlacement(dataNodes, &master_pb.SimulatePlacementRequest{
+		AddNodes: []*master_pb.SimulatePlacementRequest_NewNodes{{DataCenter: "dc1", Rack: "rack1", Count: 2, MaxVolumeCount: 10}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("simulate adding to rack1: %v", err)
+	}
+	if resp.MovedVolumeCount != 1 || resp.MovedBytes != 100 || resp.CopiedReplicaCount != 0 {
+		t.Errorf("adding to rack1: %+v", resp)
+	}
+
+	resp, err = simulatePlacement(dataNodes, &master_pb.SimulatePlacementRequest{
+		Replications: []*master_pb.SimulatePlacementRequest_CollectionReplication{{Collection: "logs", Replication: "000"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("simulate lowering the replication: %v", err)
+	}
+	if resp.DeletedReplicaCount != 2 || resp.DeletedBytes != 200 {
+		t.Errorf("lowering the replication: %+v", resp)
+	}
+
+	if _, err = simulatePlacement(dataNodes, &master_pb.SimulatePlacementRequest{RemoveRacks: []string{"dc1:rack9"}}, nil); err == nil {
+		t.Errorf("removed an unknown rack")
+	}
+	if len(nodes["c"].GetVolumes()) != 5 {
+		t.Errorf("the simulation changed the topology")
+	}
+}
@@ -0,0 +1,20 @@
+package weed_server
+
+import (
+	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+type testNodeLayout struct{ rack, node string }
+
+// newTestTopology creates the data nodes in data center dc1, each with 10 volume slots, by node name
+func newTestTopology(volumeSizeLimit uint64, layouts []testNodeLayout) (*topology.Topology, map[string]*topology.DataNode) {
+	topo := topology.NewTopology("weedfs", sequence.NewMemorySequencer(), volumeSizeLimit, 5, false)
+	dc := topo.GetOrCreateDataCenter("dc1")
+	nodes := make(map[string]*topology.DataNode)
+	for i, layout := range layouts {
+		rack := dc.GetOrCreateRack(layout.rack)
+		nodes[layout.node] = rack.GetOrCreateDataNode("127.0.0.1", 8080+i, 0, layout.node, map[string]uint32{"": 10})
+	}
+	return topo, nodes
+}
@@ -0,0 +1,161 @@
+package weed_server
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+// The leader migrates the volumes between the disk types by how recently and how often they are read,
+// as reported in the heartbeats, one volume replica at a time: the volumes not read or written for a while
+// to the cold disk type, and the frequently read volumes to the hot disk type.
+
+const (
+	tierMigrationInterval = time.Minute
+	tierReadRateWindow    = time.Hour
+)
+
+type TierPolicy struct {
+	ColdAfter       time.Duration // demote the volumes not read or written for this long, 0 to disable
+	HotReadsPerHour int           // promote the volumes read at least this often, 0 to disable
+	HotDiskType     string
+	ColdDiskType    string
+	BytePerSecond   int64
+}
+
+func (p TierPolicy) isEnabled() bool {
+	return p.ColdAfter > 0 || p.HotReadsPerHour > 0
+}
+
+// volumeReadSample is the read count of a volume replica at the start of the read rate window
+type volumeReadSample struct {
+	readCount    uint64
+	sampledAt    time.Time
+	readsPerHour float64 // of the last full window, negative before the first window ends
+}
+
+type tierMigration struct {
+	volume   storage.VolumeInfo
+	source   *topology.DataNode
+	diskType string
+	reason   string
+}
+
+func (ms *MasterServer) loopTierMigration() {
+	policy := ms.option.TierPolicy
+	if !policy.isEnabled() {
+		return
+	}
+	if types.ToDiskType(policy.HotDiskType) == types.ToDiskType(policy.ColdDiskType) {
+		glog.Warningf("skip migrating volumes between the same hot and cold disk type %q", policy.HotDiskType)
+		return
+	}
+	samples := make(map[string]*volumeReadSample)
+	for {
+		time.Sleep(tierMigrationInterval)
+		if !ms.Topo.IsLeader() {
+			samples = make(map[string]*volumeReadSample)
+			continue
+		}
+		ms.migrateOneVolume(policy, samples)
+	}
+}
+
+// migrateOneVolume moves the first volume replica to migrate with a volume server to move to
+func (ms *MasterServer) migrateOneVolume(policy TierPolicy, samples map[string]*volumeReadSample) {
+	dataNodes := ms.Topo.ListDataNodes()
+	for _, m := range pickTierMigrations(policy, dataNodes, samples, time.Now()) {
+		target := pickMoveTarget(m.source, dataNodes, m.volume.Id, m.diskType, ms.option.PlacementCollections[m.volume.Collection], false)
+		if target == nil {
+			glog.V(1).Infof("no volume server to move volume %d to disk type %q", m.volume.Id, m.diskType)
+			continue
+		}
+		doneMoving, err := ms.startVolumeMove(m.volume.Id, "tier migration")
+		if err != nil {
+			glog.V(1).Infof("skip migrating volume %d: %v", m.volume.Id, err)
+			continue
+		}
+		glog.V(0).Infof("migrate volume %d on %s: %s", m.volume.Id, m.source.Url(), m.reason)
+		done := ms.expectTopologyChange(m.volume.Id, fmt.Sprintf("tier migration: %s", m.reason))
+		if err := ms.moveVolume(m.volume, m.source, target, m.diskType, policy.BytePerSecond); err != nil {
+			glog.Warningf("migrate volume %d: %v", m.volume.Id, err)
+		}
+		done()
+		doneMoving()
+		return
+	}
+}
+
+// pickTierMigrations lists the volume replicas to promote, the most read first,
+// and then the volume replicas to demote, the least recently accessed first.
+func pickTierMigrations(policy TierPolicy, dataNodes []*topology.DataNode, samples map[string]*volumeReadSample, now time.Time) (migrations []*tierMigration) {
+	hotDiskType, coldDiskType := string(types.ToDiskType(policy.HotDiskType)), string(types.ToDiskType(policy.ColdDiskType))
+
+	var promotions, demotions []*tierMigration
+	var readsPerHour = make(map[*tierMigration]float64)
+	var accessedAt = make(map[*tierMigration]int64)
+	seen := make(map[string]bool)
+	for _, dn := range dataNodes {
+		for _, v := range dn.GetVolumes() {
+			key := fmt.Sprintf("%s/%d", dn.Url(), v.Id)
+			seen[key] = true
+			sample := updateReadSample(samples, key, v.ReadCount, now)
+			if dn.IsDraining() || dn.IsTerminating || v.IsRemote() {
+				continue
+			}
+			diskType := string(types.ToDiskType(v.DiskType))
+			switch {
+			case diskType == coldDiskType && policy.HotReadsPerHour > 0 && sample.readsPerHour >= float64(policy.HotReadsPerHour):
+				m := &tierMigration{volume: v, source: dn, diskType: hotDiskType,
+					reason: fmt.Sprintf("read %.0f times per hour", sample.readsPerHour)}
+				promotions = append(promotions, m)
+				readsPerHour[m] = sample.readsPerHour
+			case diskType == hotDiskType && policy.ColdAfter > 0:
+				lastAccessed := v.ReadAtSecond
+				if v.ModifiedAtSecond > lastAccessed {
+					lastAccessed = v.ModifiedAtSecond
+				}
+				if now.Sub(time.Unix(lastAccessed, 0)) < policy.ColdAfter {
+					continue
+				}
+				m := &tierMigration{volume: v, source: dn, diskType: coldDiskType,
+					reason: fmt.Sprintf("not accessed since %s", time.Unix(lastAccessed, 0).Format(time.RFC3339))}
+				demotions = append(demotions, m)
+				accessedAt[m] = lastAccessed
+			}
+		}
+	}
+	for key := range samples {
+		if !seen[key] {
+			delete(samples, key)
+		}
+	}
+
+	sort.SliceStable(promotions, func(i, j int) bool {
+		return readsPerHour[promotions[i]] > readsPerHour[promotions[j]]
+	})
+	sort.SliceStable(demotions, func(i, j int) bool {
+		return accessedAt[demotions[i]] < accessedAt[demotions[j]]
+	})
+	return append(promotions, demotions...)
+}
+
+func updateReadSample(samples map[string]*volumeReadSample, key string, readCount uint64, now time.Time) *volumeReadSample {
+	sample, found := samples[key]
+	if !found || readCount < sample.readCount {
+		// new, or the volume server has restarted
+		sample = &volumeReadSample{readCount: readCount, sampledAt: now, readsPerHour: -1}
+		samples[key] = sample
+		return sample
+	}
+	if elapsed := now.Sub(sample.sampledAt); elapsed >= tierReadRateWindow {
+		sample.readsPerHour = float64(readCount-sample.readCount) / elapsed.Hours()
+		sample.readCount, sample.sampledAt = readCount, now
+	}
+	return sample
+}
@@ -0,0 +1,57 @@
+package weed_server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
+)
+
+func TestPickTierMigrations(t *testing.T) {
+	topo := topology.NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+	rack := topo.GetOrCreateDataCenter("dc1").GetOrCreateRack("rack1")
+	dn := rack.GetOrCreateDataNode("127.0.0.1", 8080, 0, "a", map[string]uint32{"": 10, "ssd": 10})
+
+	now := time.Now()
+	volume := func(id uint32, diskType string, readAt time.Time, readCount uint64) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{Id: id, Version: uint32(needle.CurrentVersion), DiskType: diskType,
+			ModifiedAtSecond: now.Add(-30 * 24 * time.Hour).Unix(), ReadAtSecond: readAt.Unix(), ReadCount: readCount}
+	}
+	register := func(volumes ...*master_pb.VolumeInformationMessage) {
+		topo.SyncDataNodeRegistration(volumes, dn)
+	}
+	policy := TierPolicy{ColdAfter: 24 * time.Hour, HotReadsPerHour: 100, HotDiskType: "ssd", ColdDiskType: "hdd"}
+	samples := make(map[string]*volumeReadSample)
+
+	register(
+		volume(1, "ssd", now.Add(-time.Hour), 0),    // recently read
+		volume(2, "ssd", now.Add(-48*time.Hour), 0), // cold
+		volume(3, "ssd", now.Add(-72*time.Hour), 0), // colder
+		volume(4, "", now, 1000),                    // hot after a full window
+		volume(5, "", now, 1000),                    // read only a few times
+	)
+	migrations := pickTierMigrations(policy, topo.ListDataNodes(), samples, now)
+	if len(migrations) != 2 || migrations[0].volume.Id != 3 || migrations[1].volume.Id != 2 {
+		t.Fatalf("migrations %+v, expected demoting volume 3 and 2", migrations)
+	}
+	if migrations[0].diskType != "" {
+		t.Errorf("demoted to disk type %q", migrations[0].diskType)
+	}
+
+	later := now.Add(tierReadRateWindow)
+	register(
+		volume(1, "ssd", now.Add(-time.Hour), 0),
+		volume(4, "", later, 1000+200),
+		volume(5, "", later, 1000+10),
+	)
+	migrations = pickTierMigrations(policy, topo.ListDataNodes(), samples, later)
+	if len(migrations) != 1 || migrations[0].volume.Id != 4 || migrations[0].diskType != "ssd" {
+		t.Fatalf("migrations %+v, expected promoting volume 4", migrations)
+	}
+	if len(samples) != 3 {
+		t.Errorf("%d read samples kept for 3 volumes", len(samples))
+	}
+}
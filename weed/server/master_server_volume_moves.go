@@ -0,0 +1,47 @@
+package weed_server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+// The background movers of the leader, i.e. the drains, repairs, rebalancing, tier migration, lifecycle,
+// archiving and the ec shard placement, move, copy, delete and convert the volumes and ec shards.
+// Each of them works on a volume only when no other mover does, and no shell command holds the shell lock,
+// since the shell commands like volume.balance or volume.move work on any volume.
+// In turn, the shell lock is not leased while any volume is being moved.
+
+const shellAdminLockName = "shell"
+
+// startVolumeMove reserves the volume for the mover, until done is called
+func (ms *MasterServer) startVolumeMove(vid needle.VolumeId, mover string) (done func(), err error) {
+	ms.volumeMovesLock.Lock()
+	defer ms.volumeMovesLock.Unlock()
+	if other, found := ms.volumeMoves[vid]; found {
+		return nil, fmt.Errorf("volume %d is being moved by %s", vid, other)
+	}
+	if client, _, isLocked := ms.adminLocks.isLocked(shellAdminLockName); isLocked {
+		return nil, fmt.Errorf("the shell is locked by %s", client)
+	}
+	ms.volumeMoves[vid] = mover
+	return func() {
+		ms.volumeMovesLock.Lock()
+		delete(ms.volumeMoves, vid)
+		ms.volumeMovesLock.Unlock()
+	}, nil
+}
+
+// leaseFreshAdminToken leases the admin lock not held by anyone, except the shell lock while any volume is being moved
+func (ms *MasterServer) leaseFreshAdminToken(lockName, clientName string) (ts time.Time, token int64, err error) {
+	if lockName == shellAdminLockName {
+		ms.volumeMovesLock.Lock()
+		defer ms.volumeMovesLock.Unlock()
+		if len(ms.volumeMoves) > 0 {
+			return ts, token, fmt.Errorf("the master is moving %d volumes", len(ms.volumeMoves))
+		}
+	}
+	ts, token = ms.adminLocks.generateToken(lockName, clientName)
+	return ts, token, nil
+}
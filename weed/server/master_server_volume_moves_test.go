@@ -0,0 +1,59 @@
+package weed_server
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func TestVolumeMoves(t *testing.T) {
+	ms := &MasterServer{adminLocks: NewAdminLocks(), volumeMoves: make(map[needle.VolumeId]string)}
+
+	// the rebalancing moves volume 1, while the drain wants to move it too
+	doneRebalancing, err := ms.startVolumeMove(1, "rebalance")
+	if err != nil {
+		t.Fatalf("start rebalancing: %v", err)
+	}
+	if _, err = ms.startVolumeMove(1, "drain"); err == nil {
+		t.Errorf("drained the volume being rebalanced")
+	}
+	doneDraining, err := ms.startVolumeMove(2, "drain")
+	if err != nil {
+		t.Fatalf("start draining another volume: %v", err)
+	}
+
+	// the lifecycle skips the volume, without applying the action
+	ms.applyLifecycleAction(&lifecycleAction{volume: storage.VolumeInfo{Id: 1}, action: lifecycleActionDelete})
+	if len(ms.lifecycleApplied) != 0 {
+		t.Errorf("applied %v to the volume being rebalanced", ms.lifecycleApplied)
+	}
+
+	// the shell waits for the movers
+	if _, _, err = ms.leaseFreshAdminToken(shellAdminLockName, "shell"); err == nil {
+		t.Errorf("leased the shell lock while moving volumes")
+	}
+	doneRebalancing()
+	doneDraining()
+	ts, token, err := ms.leaseFreshAdminToken(shellAdminLockName, "shell")
+	if err != nil {
+		t.Fatalf("lease the shell lock: %v", err)
+	}
+
+	// and the movers wait for the shell
+	if _, err = ms.startVolumeMove(1, "drain"); err == nil {
+		t.Errorf("drained the volume while the shell is locked")
+	}
+	if !ms.adminLocks.isValidToken(shellAdminLockName, ts, token) {
+		t.Fatalf("invalid shell lock token")
+	}
+	ms.adminLocks.deleteLock(shellAdminLockName)
+	doneDraining, err = ms.startVolumeMove(1, "drain")
+	if err != nil {
+		t.Fatalf("start draining after the shell is unlocked: %v", err)
+	}
+	doneDraining()
+	if len(ms.volumeMoves) != 0 {
+		t.Errorf("still moving %v", ms.volumeMoves)
+	}
+}
@@ -0,0 +1,167 @@
+// Renders the live topology of the master status page, refreshed every few seconds from /ui/topology.
+(function () {
+    var refreshInterval = 5000;
+    var volumeStates = ["writable", "full", "readonly", "remote", "failing"];
+
+    function el(tag, className, text) {
+        var e = document.createElement(tag);
+        if (className) {
+            e.className = className;
+        }
+        if (text !== undefined) {
+            e.textContent = text;
+        }
+        return e;
+    }
+
+    function humanBytes(bytes) {
+        var units = ["B", "KiB", "MiB", "GiB", "TiB", "PiB"];
+        var i = 0;
+        while (bytes >= 1024 && i < units.length - 1) {
+            bytes /= 1024;
+            i++;
+        }
+        return bytes.toFixed(i === 0 ? 0 : 1) + units[i];
+    }
+
+    // from green when empty to red when full
+    function heatColor(utilization) {
+        var u = Math.max(0, Math.min(1, utilization));
+        return "hsl(" + Math.round(120 * (1 - u)) + ", 65%, 45%)";
+    }
+
+    function renderDisk(disk) {
+        var d = el("div", "topology-disk");
+        var percent = Math.round(disk.utilization * 100);
+        d.appendChild(el("div", "", disk.type + ": " + (disk.volumes ? disk.volumes.length : 0) + " volumes, " +
+            disk.ecShards + " ec shards, " + disk.maxVolumes + " slots, " + percent + "% used"));
+        var heat = el("div", "topology-heat");
+        var bar = el("div");
+        bar.style.width = Math.min(100, percent) + "%";
+        bar.style.background = heatColor(disk.utilization);
+        heat.appendChild(bar);
+        d.appendChild(heat);
+        var volumes = el("div", "topology-volumes");
+        (disk.volumes || []).forEach(function (v) {
+            var square = el("span", "topology-volume volume-" + v.state);
+            square.title = "volume " + v.id + (v.collection ? " " + v.collection : "") + ", " + humanBytes(v.size) + ", " + v.state;
+            volumes.appendChild(square);
+        });
+        d.appendChild(volumes);
+        return d;
+    }
+
+    function renderNode(node) {
+        var n = el("div", "topology-node");
+        var link = el("a", "", node.url);
+        link.href = "http://" + node.url + "/ui/index.html";
+        n.appendChild(link);
+        if (node.state) {
+            n.appendChild(document.createTextNode(" "));
+            n.appendChild(el("span", "badge", node.state));
+        }
+        (node.disks || []).forEach(function (disk) {
+            n.appendChild(renderDisk(disk));
+        });
+        return n;
+    }
+
+    function renderTopology(container, topology) {
+        container.textContent = "";
+        var legend = el("div", "topology-legend");
+        volumeStates.forEach(function (state) {
+            var item = el("span");
+            item.appendChild(el("span", "topology-volume volume-" + state));
+            item.appendChild(document.createTextNode(" " + state));
+            legend.appendChild(item);
+        });
+        container.appendChild(legend);
+        (topology.dataCenters || []).forEach(function (dc) {
+            var d = el("div", "topology-dc");
+            d.appendChild(el("h4", "", "Data Center " + dc.id));
+            (dc.racks || []).forEach(function (rack) {
+                var r = el("div", "topology-rack");
+                r.appendChild(el("strong", "", "Rack " + rack.id));
+                (rack.nodes || []).forEach(function (node) {
+                    r.appendChild(renderNode(node));
+                });
+                d.appendChild(r);
+            });
+            container.appendChild(d);
+        });
+    }
+
+    function renderEcVolumes(container, ecVolumes) {
+        container.textContent = "";
+        if (!ecVolumes || ecVolumes.length === 0) {
+            container.appendChild(el("p", "", "No erasure coded volumes."));
+            return;
+        }
+        var table = el("table", "table table-condensed table-striped");
+        var head = el("tr");
+        ["Volume", "Collection", "Shards", "Distribution"].forEach(function (title) {
+            head.appendChild(el("th", "", title));
+        });
+        table.appendChild(head);
+        ecVolumes.forEach(function (ev) {
+            var count = 0;
+            var distribution = [];
+            ev.locations.forEach(function (loc) {
+                count += loc.shardIds.length;
+                distribution.push(loc.url + " (" + loc.rack + "): " + loc.shardIds.join(","));
+            });
+            var row = el("tr");
+            row.appendChild(el("td", "", ev.id));
+            row.appendChild(el("td", "", ev.collection));
+            var shards = el("td", "", count + " / " + ev.totalShards);
+            if (count < ev.totalShards) {
+                shards.className = "text-danger";
+            }
+            row.appendChild(shards);
+            row.appendChild(el("td", "", distribution.join("; ")));
+            table.appendChild(row);
+        });
+        container.appendChild(table);
+    }
+
+    function renderOperations(container, operations) {
+        container.textContent = "";
+        if (!operations || operations.length === 0) {
+            container.appendChild(el("p", "", "No ongoing operations."));
+            return;
+        }
+        var list = el("ul", "list-unstyled");
+        operations.forEach(function (op) {
+            var text = op.description;
+            if (op.startedAtNs) {
+                text += ", for " + Math.round((Date.now() - op.startedAtNs / 1e6) / 1000) + "s";
+            }
+            list.appendChild(el("li", "", text));
+        });
+        container.appendChild(list);
+    }
+
+    function refresh() {
+        fetch("/ui/topology")
+            .then(function (resp) {
+                if (!resp.ok) {
+                    throw new Error(resp.status + " " + resp.statusText);
+                }
+                return resp.json();
+            })
+            .then(function (topology) {
+                renderTopology(document.getElementById("topology"), topology);
+                renderEcVolumes(document.getElementById("ec-volumes"), topology.ecVolumes);
+                renderOperations(document.getElementById("operations"), topology.operations);
+                document.getElementById("topology-error").textContent = "";
+            })
+            .catch(function (err) {
+                document.getElementById("topology-error").textContent = "failed to refresh the topology: " + err.message;
+            })
+            .finally(function () {
+                setTimeout(refresh, refreshInterval);
+            });
+    }
+
+    document.addEventListener("DOMContentLoaded", refresh);
+})();
@@ -1,10 +1,15 @@
 package master_ui
 
 import (
-	_ "embed"
+	"embed"
 	"html/template"
 )
 
+// StaticFS has the scripts and styles of the status page, served under /ui/static/
+//
+//go:embed master.js master.css
+var StaticFS embed.FS
+
 //go:embed master.html
 var masterHtml string
 
@@ -47,8 +47,12 @@ func getPeerIdx(self pb.ServerAddress, mapPeers map[string]pb.ServerAddress) int
 
 func (s *RaftServer) AddPeersConfiguration() (cfg raft.Configuration) {
 	for _, peer := range s.peers {
+		suffrage := raft.Voter
+		if s.learners[string(peer)] {
+			suffrage = raft.Nonvoter
+		}
 		cfg.Servers = append(cfg.Servers, raft.Server{
-			Suffrage: raft.Voter,
+			Suffrage: suffrage,
 			ID:       raft.ServerID(peer),
 			Address:  raft.ServerAddress(peer.ToGrpcAddress()),
 		})
@@ -74,6 +78,12 @@ func (s *RaftServer) UpdatePeers() {
 					if peerName == peerLeader || existsPeerName[peerName] {
 						continue
 					}
+					if s.learners[peerName] {
+						glog.V(0).Infof("adding new learner peer: %s", peerName)
+						s.RaftHashicorp.AddNonvoter(
+							raft.ServerID(peerName), raft.ServerAddress(peer.ToGrpcAddress()), 0, 0)
+						continue
+					}
 					glog.V(0).Infof("adding new peer: %s", peerName)
 					s.RaftHashicorp.AddVoter(
 						raft.ServerID(peerName), raft.ServerAddress(peer.ToGrpcAddress()), 0, 0)
@@ -99,6 +109,7 @@ func (s *RaftServer) UpdatePeers() {
 func NewHashicorpRaftServer(option *RaftServerOption) (*RaftServer, error) {
 	s := &RaftServer{
 		peers:      option.Peers,
+		learners:   option.Learners,
 		serverAddr: option.ServerAddr,
 		dataDir:    option.DataDir,
 		topo:       option.Topo,
@@ -30,10 +30,12 @@ type RaftServerOption struct {
 	HeartbeatInterval time.Duration
 	ElectionTimeout   time.Duration
 	RaftBootstrap     bool
+	Learners          map[string]bool // the peers joining as non-voting members, only with hashicorp raft
 }
 
 type RaftServer struct {
 	peers            map[string]pb.ServerAddress // initial peers to join with
+	learners         map[string]bool
 	raftServer       raft.Server
 	RaftHashicorp    *hashicorpRaft.Raft
 	TransportManager *transport.Manager
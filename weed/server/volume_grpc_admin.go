@@ -244,6 +244,21 @@ func (vs *VolumeServer) VolumeStatus(ctx context.Context, req *volume_server_pb.
 	return resp, nil
 }
 
+func (vs *VolumeServer) VolumeEncryptionRotate(ctx context.Context, req *volume_server_pb.VolumeEncryptionRotateRequest) (*volume_server_pb.VolumeEncryptionRotateResponse, error) {
+
+	previousKeyId, keyId, err := vs.store.RotateEncryptionKey(needle.VolumeId(req.VolumeId))
+	if err != nil {
+		glog.Errorf("volume encryption rotate %v: %v", req, err)
+		return nil, err
+	}
+	glog.V(1).Infof("volume encryption rotate %v: %s => %s", req, previousKeyId, keyId)
+
+	return &volume_server_pb.VolumeEncryptionRotateResponse{
+		PreviousKeyId: previousKeyId,
+		KeyId:         keyId,
+	}, nil
+}
+
 func (vs *VolumeServer) VolumeServerStatus(ctx context.Context, req *volume_server_pb.VolumeServerStatusRequest) (*volume_server_pb.VolumeServerStatusResponse, error) {
 
 	resp := &volume_server_pb.VolumeServerStatusResponse{
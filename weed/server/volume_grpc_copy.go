@@ -3,6 +3,7 @@ package weed_server
 import (
 	"context"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"math"
 	"os"
@@ -64,6 +65,10 @@ func (vs *VolumeServer) VolumeCopy(req *volume_server_pb.VolumeCopyRequest, stre
 			diskType = req.DiskType
 		}
 		location := vs.store.FindFreeLocation(types.ToDiskType(diskType))
+		resumeLocation, progress := vs.findResumableCopy(req.VolumeId, volFileInfoResp.Collection, req.SourceDataNode, volFileInfoResp.CompactionRevision)
+		if resumeLocation != nil {
+			location = resumeLocation
+		}
 		if location == nil {
 			return fmt.Errorf("no space left for disk type %s", types.ToDiskType(diskType).ReadableString())
 		}
@@ -76,10 +81,13 @@ func (vs *VolumeServer) VolumeCopy(req *volume_server_pb.VolumeCopyRequest, stre
 
 		defer func() {
 			if err != nil {
-				os.Remove(dataBaseFileName + ".dat")
+				// keep the copied segments of the .dat file to resume
+				if !util.FileExists(dataBaseFileName + ".copy") {
+					os.Remove(dataBaseFileName + ".dat")
+					os.Remove(dataBaseFileName + ".note")
+				}
 				os.Remove(indexBaseFileName + ".idx")
 				os.Remove(dataBaseFileName + ".vif")
-				os.Remove(dataBaseFileName + ".note")
 			}
 		}()
 
@@ -97,7 +105,7 @@ func (vs *VolumeServer) VolumeCopy(req *volume_server_pb.VolumeCopyRequest, stre
 			glog.V(0).Infof("connect to %s: %v", vs.GetMaster(), grpcErr)
 		}
 
-		if preallocateSize > 0 && !hasRemoteDatFile {
+		if preallocateSize > 0 && !hasRemoteDatFile && progress == nil {
 			volumeFile := dataBaseFileName + ".dat"
 			_, err := backend.CreateVolumeFile(volumeFile, preallocateSize, 0)
 			if err != nil {
@@ -120,7 +128,7 @@ func (vs *VolumeServer) VolumeCopy(req *volume_server_pb.VolumeCopyRequest, stre
 		throttler := util.NewWriteThrottler(ioBytePerSecond)
 
 		if !hasRemoteDatFile {
-			if modifiedTsNs, err = vs.doCopyFileWithThrottler(client, false, req.Collection, req.VolumeId, volFileInfoResp.CompactionRevision, volFileInfoResp.DatFileSize, dataBaseFileName, ".dat", false, true, func(processed int64) bool {
+			reportProgress := func(processed int64) bool {
 				if processed > nextReportTarget {
 					copyResponse.ProcessedBytes = processed
 					if sendErr = stream.Send(copyResponse); sendErr != nil {
@@ -129,7 +137,17 @@ func (vs *VolumeServer) VolumeCopy(req *volume_server_pb.VolumeCopyRequest, stre
 					nextReportTarget = processed + reportInterval
 				}
 				return true
-			}, throttler); err != nil {
+			}
+			if progress == nil {
+				progress = newVolumeCopyProgress(req.SourceDataNode, volFileInfoResp.CompactionRevision)
+			}
+			modifiedTsNs, err = vs.copyDatFileInSegments(stream.Context(), client, req, volFileInfoResp, dataBaseFileName, progress, newCopyLimiter(ioBytePerSecond), reportProgress)
+			if err == errSegmentedCopyUnsupported {
+				glog.V(0).Infof("copy volume %d from %s as a whole: %v", req.VolumeId, req.SourceDataNode, err)
+				os.Remove(dataBaseFileName + ".copy")
+				modifiedTsNs, err = vs.doCopyFileWithThrottler(client, false, req.Collection, req.VolumeId, volFileInfoResp.CompactionRevision, volFileInfoResp.DatFileSize, dataBaseFileName, ".dat", false, true, reportProgress, throttler)
+			}
+			if err != nil {
 				return err
 			}
 			if sendErr != nil {
@@ -154,6 +172,7 @@ func (vs *VolumeServer) VolumeCopy(req *volume_server_pb.VolumeCopyRequest, stre
 			os.Chtimes(dataBaseFileName+".vif", time.Unix(0, modifiedTsNs), time.Unix(0, modifiedTsNs))
 		}
 
+		os.Remove(dataBaseFileName + ".copy")
 		os.Remove(dataBaseFileName + ".note")
 
 		return nil
@@ -266,7 +285,8 @@ func writeToFile(client volume_server_pb.VolumeServer_CopyFileClient, fileName s
 	}
 	defer dst.Close()
 
-	var progressedBytes int64
+	// the pages written by the bulk copies are dropped from the page cache, once the copy reaches the stream size
+	var progressedBytes, droppedBytes int64
 	for {
 		resp, receiveErr := client.Recv()
 		if receiveErr == io.EOF {
@@ -280,6 +300,10 @@ func writeToFile(client volume_server_pb.VolumeServer_CopyFileClient, fileName s
 		}
 		dst.Write(resp.FileContent)
 		progressedBytes += int64(len(resp.FileContent))
+		if backend.IsPageCacheStream(progressedBytes) {
+			backend.DropFileWrittenPages(dst, droppedBytes, progressedBytes-droppedBytes)
+			droppedBytes = progressedBytes
+		}
 		if progressFn != nil {
 			if !progressFn(progressedBytes) {
 				return modifiedTsNs, fmt.Errorf("interrupted copy operation")
@@ -326,6 +350,10 @@ func (vs *VolumeServer) CopyFile(req *volume_server_pb.CopyFileRequest, stream v
 		if uint32(v.CompactionRevision) != req.CompactionRevision && req.CompactionRevision != math.MaxUint32 {
 			return fmt.Errorf("volume %d is compacted", req.VolumeId)
 		}
+		// the copied .vif file would share the segments on the object store
+		if v.IsObjectStored() && (req.Ext == ".dat" || req.Ext == ".vif") {
+			return fmt.Errorf("volume %d is on the object store and can not be copied", req.VolumeId)
+		}
 		v.SyncToDisk()
 		fileName = v.FileName(req.Ext)
 	} else {
@@ -364,6 +392,21 @@ func (vs *VolumeServer) CopyFile(req *volume_server_pb.CopyFileRequest, stream v
 		return err
 	}
 	fileModTsNs := fileInfo.ModTime().UnixNano()
+	// the bulk copies do not keep the copied file in the page cache
+	copySize := fileInfo.Size()
+	if req.StopOffset < uint64(copySize) {
+		copySize = int64(req.StopOffset)
+	}
+	isPageCacheStream := backend.IsPageCacheStream(copySize - int64(req.StartOffset))
+	readOffset := int64(req.StartOffset)
+
+	if req.StartOffset > 0 {
+		if _, err = file.Seek(int64(req.StartOffset), io.SeekStart); err != nil {
+			return err
+		}
+		bytesToRead -= int64(req.StartOffset)
+	}
+	checksum := crc32.New(crc32.MakeTable(crc32.Castagnoli))
 
 	buffer := make([]byte, BufferSizeLimit)
 
@@ -380,9 +423,16 @@ func (vs *VolumeServer) CopyFile(req *volume_server_pb.CopyFileRequest, stream v
 			break
 		}
 
+		if isPageCacheStream {
+			backend.DropFileReadPages(file, readOffset, int64(bytesread))
+		}
+		readOffset += int64(bytesread)
 		if int64(bytesread) > bytesToRead {
 			bytesread = int(bytesToRead)
 		}
+		if req.WithChecksum {
+			checksum.Write(buffer[:bytesread])
+		}
 		err = stream.Send(&volume_server_pb.CopyFileResponse{
 			FileContent:  buffer[:bytesread],
 			ModifiedTsNs: fileModTsNs,
@@ -397,5 +447,13 @@ func (vs *VolumeServer) CopyFile(req *volume_server_pb.CopyFileRequest, stream v
 
 	}
 
+	if req.WithChecksum {
+		return stream.Send(&volume_server_pb.CopyFileResponse{
+			ModifiedTsNs: fileModTsNs,
+			Checksum:     checksum.Sum32(),
+			HasChecksum:  true,
+		})
+	}
+
 	return nil
 }
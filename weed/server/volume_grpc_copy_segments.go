@@ -0,0 +1,276 @@
+package weed_server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The .dat file is copied in segments, optionally in parallel, each verified by its crc32c.
+// The copied segments are kept in the .copy progress file next to the partially copied .dat file,
+// so copying the volume again from the same source resumes from the missing segments.
+
+const copySegmentRetries = 3
+
+var copySegmentSize int64 = 64 * 1024 * 1024
+
+var (
+	errSegmentedCopyUnsupported = errors.New("the source volume server can not copy in segments")
+	errCopyInterrupted          = errors.New("interrupted copy operation")
+)
+
+type volumeCopyProgress struct {
+	Source             string          `json:"source"`
+	CompactionRevision uint32          `json:"compactionRevision"`
+	SegmentSize        int64           `json:"segmentSize"`
+	Segments           map[int64]int64 `json:"segments"` // start offset => stop offset of the copied segments
+}
+
+func newVolumeCopyProgress(source string, compactionRevision uint32) *volumeCopyProgress {
+	return &volumeCopyProgress{
+		Source:             source,
+		CompactionRevision: compactionRevision,
+		SegmentSize:        copySegmentSize,
+		Segments:           make(map[int64]int64),
+	}
+}
+
+func loadVolumeCopyProgress(fileName string) (*volumeCopyProgress, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	progress := &volumeCopyProgress{}
+	if err = json.Unmarshal(data, progress); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", fileName, err)
+	}
+	if progress.Segments == nil {
+		progress.Segments = make(map[int64]int64)
+	}
+	return progress, nil
+}
+
+func (p *volumeCopyProgress) save(fileName string) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err = util.WriteFile(fileName+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(fileName+".tmp", fileName)
+}
+
+// findResumableCopy finds the partially copied volume from the same source, and removes the stale ones
+func (vs *VolumeServer) findResumableCopy(volumeId uint32, collection, source string, compactionRevision uint32) (*storage.DiskLocation, *volumeCopyProgress) {
+	for _, location := range vs.store.Locations {
+		dataBaseFileName := storage.VolumeFileName(location.Directory, collection, int(volumeId))
+		progress, err := loadVolumeCopyProgress(dataBaseFileName + ".copy")
+		if err != nil {
+			continue
+		}
+		if progress.Source == source && progress.CompactionRevision == compactionRevision &&
+			progress.SegmentSize == copySegmentSize && util.FileExists(dataBaseFileName+".dat") {
+			return location, progress
+		}
+		glog.V(0).Infof("remove stale partial copy of volume %d from %s", volumeId, progress.Source)
+		os.Remove(dataBaseFileName + ".dat")
+		os.Remove(dataBaseFileName + ".note")
+		os.Remove(dataBaseFileName + ".copy")
+	}
+	return nil, nil
+}
+
+// copyDatFileInSegments copies the segments of the .dat file not copied yet, and records each copied segment
+func (vs *VolumeServer) copyDatFileInSegments(ctx context.Context, client volume_server_pb.VolumeServerClient, req *volume_server_pb.VolumeCopyRequest,
+	status *volume_server_pb.ReadVolumeFileStatusResponse, dataBaseFileName string, progress *volumeCopyProgress,
+	limiter *rate.Limiter, progressFn storage.ProgressFunc) (modifiedTsNs int64, err error) {
+
+	dst, err := os.OpenFile(dataBaseFileName+".dat", os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	datFileSize := int64(status.DatFileSize)
+	var copiedBytes int64
+	var segments []int64
+	for start := int64(0); start < datFileSize; start += copySegmentSize {
+		if progress.Segments[start] == segmentStop(start, datFileSize) {
+			copiedBytes += segmentStop(start, datFileSize) - start
+			continue
+		}
+		segments = append(segments, start)
+	}
+	if copiedBytes > 0 {
+		glog.V(0).Infof("resume copying volume %d from %s, %d of %d bytes copied", req.VolumeId, req.SourceDataNode, copiedBytes, datFileSize)
+	}
+
+	parallel := int(req.ParallelSegments)
+	if parallel < 1 {
+		parallel = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var lock sync.Mutex // guards the progress, the copied bytes, and the first error
+	onReceived := func(n int64) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		copiedBytes += n
+		return progressFn == nil || progressFn(copiedBytes)
+	}
+
+	segmentChan := make(chan int64)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range segmentChan {
+				stop := segmentStop(start, datFileSize)
+				segmentTsNs, segmentErr := copyDatSegmentWithRetry(ctx, client, req, status, dst, start, stop, limiter, onReceived)
+				if segmentErr == nil {
+					segmentErr = dst.Sync()
+				}
+				lock.Lock()
+				if segmentErr == nil {
+					progress.Segments[start] = stop
+					segmentErr = progress.save(dataBaseFileName + ".copy")
+				}
+				if segmentTsNs > modifiedTsNs {
+					modifiedTsNs = segmentTsNs
+				}
+				if segmentErr != nil && err == nil {
+					err = segmentErr
+					cancel()
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+feeding:
+	for _, start := range segments {
+		select {
+		case segmentChan <- start:
+		case <-ctx.Done():
+			break feeding
+		}
+	}
+	close(segmentChan)
+	wg.Wait()
+
+	if err != nil {
+		return modifiedTsNs, err
+	}
+	// the file could be preallocated or left larger by an earlier copy
+	return modifiedTsNs, dst.Truncate(datFileSize)
+}
+
+func segmentStop(start, fileSize int64) int64 {
+	if start+copySegmentSize < fileSize {
+		return start + copySegmentSize
+	}
+	return fileSize
+}
+
+func copyDatSegmentWithRetry(ctx context.Context, client volume_server_pb.VolumeServerClient, req *volume_server_pb.VolumeCopyRequest,
+	status *volume_server_pb.ReadVolumeFileStatusResponse, dst *os.File, start, stop int64,
+	limiter *rate.Limiter, onReceived func(n int64) bool) (modifiedTsNs int64, err error) {
+	for i := 0; i < copySegmentRetries; i++ {
+		if i > 0 {
+			glog.V(0).Infof("retry copying volume %d segment [%d,%d) from %s: %v", req.VolumeId, start, stop, req.SourceDataNode, err)
+			time.Sleep(time.Duration(i) * time.Second)
+		}
+		modifiedTsNs, err = copyDatSegment(ctx, client, req, status, dst, start, stop, limiter, onReceived)
+		if err == nil || err == errSegmentedCopyUnsupported || err == errCopyInterrupted || ctx.Err() != nil {
+			return
+		}
+	}
+	return
+}
+
+func copyDatSegment(ctx context.Context, client volume_server_pb.VolumeServerClient, req *volume_server_pb.VolumeCopyRequest,
+	status *volume_server_pb.ReadVolumeFileStatusResponse, dst *os.File, start, stop int64,
+	limiter *rate.Limiter, onReceived func(n int64) bool) (modifiedTsNs int64, err error) {
+
+	copyFileClient, err := client.CopyFile(ctx, &volume_server_pb.CopyFileRequest{
+		VolumeId:           req.VolumeId,
+		Ext:                ".dat",
+		CompactionRevision: status.CompactionRevision,
+		StartOffset:        uint64(start),
+		StopOffset:         uint64(stop),
+		Collection:         req.Collection,
+		WithChecksum:       true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("start copying volume %d segment [%d,%d): %v", req.VolumeId, start, stop, err)
+	}
+
+	checksum := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	offset := start
+	for {
+		resp, recvErr := copyFileClient.Recv()
+		if recvErr == io.EOF {
+			// an older source ends without the checksum
+			return modifiedTsNs, errSegmentedCopyUnsupported
+		}
+		if recvErr != nil {
+			return modifiedTsNs, fmt.Errorf("receive volume %d segment [%d,%d): %v", req.VolumeId, start, stop, recvErr)
+		}
+		if resp.ModifiedTsNs != 0 {
+			modifiedTsNs = resp.ModifiedTsNs
+		}
+		if resp.HasChecksum {
+			if offset != stop {
+				return modifiedTsNs, fmt.Errorf("volume %d segment [%d,%d) received only %d bytes", req.VolumeId, start, stop, offset-start)
+			}
+			if resp.Checksum != checksum.Sum32() {
+				return modifiedTsNs, fmt.Errorf("volume %d segment [%d,%d) checksum %x, expected %x", req.VolumeId, start, stop, checksum.Sum32(), resp.Checksum)
+			}
+			return modifiedTsNs, nil
+		}
+		n := int64(len(resp.FileContent))
+		if offset+n > stop {
+			// an older source ignores the start offset
+			return modifiedTsNs, errSegmentedCopyUnsupported
+		}
+		if limiter != nil {
+			if err = limiter.WaitN(ctx, len(resp.FileContent)); err != nil {
+				return modifiedTsNs, err
+			}
+		}
+		if _, err = dst.WriteAt(resp.FileContent, offset); err != nil {
+			return modifiedTsNs, fmt.Errorf("write volume %d segment [%d,%d): %v", req.VolumeId, start, stop, err)
+		}
+		checksum.Write(resp.FileContent)
+		offset += n
+		if !onReceived(n) {
+			return modifiedTsNs, errCopyInterrupted
+		}
+	}
+}
+
+func newCopyLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	burst := bytesPerSecond
+	if burst < BufferSizeLimit {
+		burst = BufferSizeLimit
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(burst))
+}
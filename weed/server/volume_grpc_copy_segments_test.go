@@ -0,0 +1,101 @@
+package weed_server
+
+import (
+	"bytes"
+	"context"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+)
+
+type fakeCopySource struct {
+	volume_server_pb.VolumeServerClient
+	data      []byte
+	lock      sync.Mutex
+	requested []uint64
+}
+
+func (f *fakeCopySource) CopyFile(ctx context.Context, in *volume_server_pb.CopyFileRequest, opts ...grpc.CallOption) (volume_server_pb.VolumeServer_CopyFileClient, error) {
+	f.lock.Lock()
+	f.requested = append(f.requested, in.StartOffset)
+	f.lock.Unlock()
+	content := f.data[in.StartOffset:in.StopOffset]
+	stream := &fakeCopyFileClient{}
+	for len(content) > 0 {
+		n := len(content)
+		if n > 4096 {
+			n = 4096
+		}
+		stream.responses = append(stream.responses, &volume_server_pb.CopyFileResponse{FileContent: content[:n]})
+		content = content[n:]
+	}
+	stream.responses = append(stream.responses, &volume_server_pb.CopyFileResponse{
+		Checksum:    crc32.Checksum(f.data[in.StartOffset:in.StopOffset], crc32.MakeTable(crc32.Castagnoli)),
+		HasChecksum: true,
+	})
+	return stream, nil
+}
+
+type fakeCopyFileClient struct {
+	grpc.ClientStream
+	responses []*volume_server_pb.CopyFileResponse
+}
+
+func (c *fakeCopyFileClient) Recv() (*volume_server_pb.CopyFileResponse, error) {
+	if len(c.responses) == 0 {
+		return nil, io.EOF
+	}
+	resp := c.responses[0]
+	c.responses = c.responses[1:]
+	return resp, nil
+}
+
+func TestCopyDatFileInSegmentsResumes(t *testing.T) {
+	defer func(size int64) { copySegmentSize = size }(copySegmentSize)
+	copySegmentSize = 16 * 1024
+
+	source := &fakeCopySource{data: make([]byte, 10*copySegmentSize+100)}
+	rand.Read(source.data)
+	req := &volume_server_pb.VolumeCopyRequest{VolumeId: 1, SourceDataNode: "source:8080", ParallelSegments: 3}
+	status := &volume_server_pb.ReadVolumeFileStatusResponse{DatFileSize: uint64(len(source.data))}
+	dataBaseFileName := filepath.Join(t.TempDir(), "1")
+	vs := &VolumeServer{}
+
+	// interrupted after about half of the file
+	progress := newVolumeCopyProgress(req.SourceDataNode, 0)
+	_, err := vs.copyDatFileInSegments(context.Background(), source, req, status, dataBaseFileName, progress, nil, func(processed int64) bool {
+		return processed < int64(len(source.data))/2
+	})
+	if err != errCopyInterrupted {
+		t.Fatalf("expecting interrupted copy, got %v", err)
+	}
+
+	progress, err = loadVolumeCopyProgress(dataBaseFileName + ".copy")
+	if err != nil {
+		t.Fatalf("load progress: %v", err)
+	}
+	copiedSegments := len(progress.Segments)
+	if copiedSegments == 0 || copiedSegments >= 11 {
+		t.Fatalf("%d segments copied before the interruption", copiedSegments)
+	}
+
+	source.requested = nil
+	if _, err = vs.copyDatFileInSegments(context.Background(), source, req, status, dataBaseFileName, progress, newCopyLimiter(1<<30), nil); err != nil {
+		t.Fatalf("resume copy: %v", err)
+	}
+	if len(source.requested) != 11-copiedSegments {
+		t.Errorf("requested %d segments after resuming, expected %d", len(source.requested), 11-copiedSegments)
+	}
+	copied, _ := os.ReadFile(dataBaseFileName + ".dat")
+	if !bytes.Equal(copied, source.data) {
+		t.Errorf("copied %d bytes differ from the source", len(copied))
+	}
+}
@@ -49,19 +49,29 @@ func (vs *VolumeServer) VolumeEcShardsGenerate(ctx context.Context, req *volume_
 		return nil, fmt.Errorf("existing collection:%v unexpected input: %v", v.Collection, req.Collection)
 	}
 
+	// the ec shards are encoded from the data file, which is not readable without the data key
+	if v.IsEncrypted() {
+		return nil, fmt.Errorf("volume %d is encrypted and can not be erasure coded", req.VolumeId)
+	}
+
+	scheme := erasure_coding.NewScheme(req.DataShards, req.ParityShards)
+	if err := scheme.Validate(); err != nil {
+		return nil, err
+	}
+
 	shouldCleanup := true
 	defer func() {
 		if !shouldCleanup {
 			return
 		}
-		for i := 0; i < erasure_coding.TotalShardsCount; i++ {
-			os.Remove(fmt.Sprintf("%s.ec%2d", baseFileName, i))
+		for i := 0; i < scheme.TotalShards(); i++ {
+			os.Remove(baseFileName + erasure_coding.ToExt(i))
 		}
 		os.Remove(v.IndexFileName() + ".ecx")
 	}()
 
 	// write .ec00 ~ .ec13 files
-	if err := erasure_coding.WriteEcFiles(baseFileName); err != nil {
+	if err := erasure_coding.WriteEcFilesWithThrottler(baseFileName, scheme, util.NewWriteThrottler(req.IoBytePerSecond)); err != nil {
 		return nil, fmt.Errorf("WriteEcFiles %s: %v", baseFileName, err)
 	}
 
@@ -71,7 +81,12 @@ func (vs *VolumeServer) VolumeEcShardsGenerate(ctx context.Context, req *volume_
 	}
 
 	// write .vif files
-	if err := volume_info.SaveVolumeInfo(baseFileName+".vif", &volume_server_pb.VolumeInfo{Version: uint32(v.Version())}); err != nil {
+	if err := volume_info.SaveVolumeInfo(baseFileName+".vif", &volume_server_pb.VolumeInfo{
+		Version:           uint32(v.Version()),
+		EcShardConfig:     scheme.ToEcShardConfig(),
+		Deduplicated:      v.IsDeduplicated(),
+		ChecksumAlgorithm: v.ChecksumAlgorithm().String(),
+	}); err != nil {
 		return nil, fmt.Errorf("SaveVolumeInfo %s: %v", baseFileName, err)
 	}
 
@@ -102,7 +117,11 @@ func (vs *VolumeServer) VolumeEcShardsRebuild(ctx context.Context, req *volume_s
 		if util.FileExists(path.Join(location.IdxDirectory, baseFileName+".ecx")) {
 			// write .ec00 ~ .ec13 files
 			dataBaseFileName := path.Join(location.Directory, baseFileName)
-			if generatedShardIds, err := erasure_coding.RebuildEcFiles(dataBaseFileName); err != nil {
+			scheme, err := erasure_coding.LoadScheme(dataBaseFileName)
+			if err != nil {
+				return nil, fmt.Errorf("LoadScheme %s: %v", dataBaseFileName, err)
+			}
+			if generatedShardIds, err := erasure_coding.RebuildEcFiles(dataBaseFileName, scheme); err != nil {
 				return nil, fmt.Errorf("RebuildEcFiles %s: %v", dataBaseFileName, err)
 			} else {
 				rebuiltShardIds = generatedShardIds
@@ -135,11 +154,17 @@ func (vs *VolumeServer) VolumeEcShardsCopy(ctx context.Context, req *volume_serv
 	dataBaseFileName := storage.VolumeFileName(location.Directory, req.Collection, int(req.VolumeId))
 	indexBaseFileName := storage.VolumeFileName(location.IdxDirectory, req.Collection, int(req.VolumeId))
 
+	ioBytePerSecond := req.IoBytePerSecond
+	if ioBytePerSecond <= 0 {
+		ioBytePerSecond = vs.compactionBytePerSecond
+	}
+
 	err := operation.WithVolumeServerClient(true, pb.ServerAddress(req.SourceDataNode), vs.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
 
 		// copy ec data slices
+		throttler := util.NewWriteThrottler(ioBytePerSecond)
 		for _, shardId := range req.ShardIds {
-			if _, err := vs.doCopyFile(client, true, req.Collection, req.VolumeId, math.MaxUint32, math.MaxInt64, dataBaseFileName, erasure_coding.ToExt(int(shardId)), false, false, nil); err != nil {
+			if _, err := vs.doCopyFileWithThrottler(client, true, req.Collection, req.VolumeId, math.MaxUint32, math.MaxInt64, dataBaseFileName, erasure_coding.ToExt(int(shardId)), false, false, nil, throttler); err != nil {
 				return err
 			}
 		}
@@ -409,17 +434,18 @@ func (vs *VolumeServer) VolumeEcShardsToVolume(ctx context.Context, req *volume_
 	glog.V(0).Infof("VolumeEcShardsToVolume: %v", req)
 
 	// collect .ec00 ~ .ec09 files
-	shardFileNames := make([]string, erasure_coding.DataShardsCount)
+	shardFileNames := make([]string, erasure_coding.MaxShardCount)
 	v, found := vs.store.CollectEcShards(needle.VolumeId(req.VolumeId), shardFileNames)
 	if !found {
 		return nil, fmt.Errorf("ec volume %d not found", req.VolumeId)
 	}
+	shardFileNames = shardFileNames[:v.Scheme.DataShards]
 
 	if v.Collection != req.Collection {
 		return nil, fmt.Errorf("existing collection:%v unexpected input: %v", v.Collection, req.Collection)
 	}
 
-	for shardId := 0; shardId < erasure_coding.DataShardsCount; shardId++ {
+	for shardId := 0; shardId < v.Scheme.DataShards; shardId++ {
 		if shardFileNames[shardId] == "" {
 			return nil, fmt.Errorf("ec volume %d missing shard %d", req.VolumeId, shardId)
 		}
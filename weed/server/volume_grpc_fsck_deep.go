@@ -0,0 +1,50 @@
+package weed_server
+
+import (
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const volumeFsckDeepBatchSize = 1024
+
+// VolumeFsckDeep reads the whole volume at the requested rate, streaming the problems found
+func (vs *VolumeServer) VolumeFsckDeep(req *volume_server_pb.VolumeFsckDeepRequest, stream volume_server_pb.VolumeServer_VolumeFsckDeepServer) error {
+	v := vs.store.GetVolume(needle.VolumeId(req.VolumeId))
+	if v == nil {
+		return fmt.Errorf("not found volume id %d", req.VolumeId)
+	}
+
+	resp := &volume_server_pb.VolumeFsckDeepResponse{}
+	result, err := v.FsckDeep(stream.Context(), util.NewWriteThrottler(req.BytesPerSecond), func(problem storage.FsckProblem) error {
+		glog.Warningf("fsck volume %d: %s needle %s offset %d size %d %s", v.Id, problem.Kind, problem.NeedleId, problem.Offset, problem.Size, problem.Detail)
+		resp.Problems = append(resp.Problems, &volume_server_pb.VolumeFsckDeepResponse_Problem{
+			Kind:     problem.Kind,
+			NeedleId: uint64(problem.NeedleId),
+			Offset:   problem.Offset,
+			Size:     int32(problem.Size),
+			Detail:   problem.Detail,
+		})
+		if len(resp.Problems) < volumeFsckDeepBatchSize {
+			return nil
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+		resp = &volume_server_pb.VolumeFsckDeepResponse{}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	resp.CheckedCount = result.CheckedCount
+	resp.CheckedBytes = result.CheckedBytes
+	resp.DataFileSize = result.DataFileSize
+	resp.IndexFileSize = result.IndexFileSize
+	return stream.Send(resp)
+}
@@ -0,0 +1,52 @@
+package weed_server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+const volumeManifestBatchSize = 1024
+
+// VolumeManifest streams the live needles of a volume, signed with the volume.manifest.key in security.toml
+func (vs *VolumeServer) VolumeManifest(req *volume_server_pb.VolumeManifestRequest, stream volume_server_pb.VolumeServer_VolumeManifestServer) error {
+	if len(vs.manifestSigningKey) == 0 {
+		return fmt.Errorf("volume.manifest.key is not set in security.toml")
+	}
+	v := vs.store.GetVolume(needle.VolumeId(req.VolumeId))
+	if v == nil {
+		return fmt.Errorf("not found volume id %d", req.VolumeId)
+	}
+
+	signer := storage.NewManifestSigner(vs.manifestSigningKey, v.Id, v.Collection)
+	resp := &volume_server_pb.VolumeManifestResponse{}
+	err := v.WalkManifest(func(entry storage.ManifestEntry) error {
+		signer.Add(entry)
+		resp.Entries = append(resp.Entries, &volume_server_pb.VolumeManifestResponse_Entry{
+			NeedleId: uint64(entry.NeedleId),
+			Cookie:   uint32(entry.Cookie),
+			Size:     uint32(entry.Size),
+			Crc:      entry.Checksum,
+		})
+		if len(resp.Entries) < volumeManifestBatchSize {
+			return nil
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+		resp = &volume_server_pb.VolumeManifestResponse{}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	resp.Collection = v.Collection
+	resp.EntryCount = signer.Count()
+	resp.SignedAtNs = time.Now().UnixNano()
+	resp.Signature = signer.Sign(resp.SignedAtNs)
+	return stream.Send(resp)
+}
@@ -0,0 +1,55 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func (vs *VolumeServer) VolumeSnapshot(ctx context.Context, req *volume_server_pb.VolumeSnapshotRequest) (*volume_server_pb.VolumeSnapshotResponse, error) {
+
+	snapshot, err := vs.store.SnapshotVolume(needle.VolumeId(req.VolumeId), req.SnapshotName)
+	if err != nil {
+		glog.Errorf("volume snapshot %v: %v", req, err)
+		return nil, err
+	}
+	glog.V(2).Infof("volume snapshot %v", req)
+
+	return &volume_server_pb.VolumeSnapshotResponse{
+		DatFileSize: uint64(snapshot.DatFileSize),
+		IdxFileSize: uint64(snapshot.IdxFileSize),
+		IsReflink:   snapshot.IsReflink,
+	}, nil
+}
+
+func (vs *VolumeServer) VolumeSnapshotDelete(ctx context.Context, req *volume_server_pb.VolumeSnapshotDeleteRequest) (*volume_server_pb.VolumeSnapshotDeleteResponse, error) {
+
+	resp := &volume_server_pb.VolumeSnapshotDeleteResponse{}
+
+	err := vs.store.DeleteVolumeSnapshot(needle.VolumeId(req.VolumeId), req.SnapshotName)
+
+	if err != nil {
+		glog.Errorf("volume snapshot delete %v: %v", req, err)
+	} else {
+		glog.V(2).Infof("volume snapshot delete %v", req)
+	}
+
+	return resp, err
+}
+
+func (vs *VolumeServer) VolumeClone(ctx context.Context, req *volume_server_pb.VolumeCloneRequest) (*volume_server_pb.VolumeCloneResponse, error) {
+
+	resp := &volume_server_pb.VolumeCloneResponse{}
+
+	err := vs.store.CloneVolume(needle.VolumeId(req.VolumeId), req.SnapshotName, needle.VolumeId(req.NewVolumeId))
+
+	if err != nil {
+		glog.Errorf("volume clone %v: %v", req, err)
+	} else {
+		glog.V(2).Infof("volume clone %v", req)
+	}
+
+	return resp, err
+}
@@ -30,11 +30,16 @@ func (vs *VolumeServer) VolumeTierMoveDatFromRemote(req *volume_server_pb.Volume
 	}
 
 	// check whether the local .dat already exists
-	_, ok := v.DataBackend.(*backend.DiskFile)
+	_, ok := backend.UnwrapDiskFile(v.DataBackend)
 	if ok {
 		return fmt.Errorf("volume %d is already on local disk", req.VolumeId)
 	}
 
+	// the archived remote file is downloaded after restored
+	if v.IsRemoteArchived() {
+		return fmt.Errorf("volume %d remote file is archived, retry after it is restored", req.VolumeId)
+	}
+
 	// check valid storage backend type
 	backendStorage, found := backend.BackendStorages[storageName]
 	if !found {
@@ -24,8 +24,17 @@ func (vs *VolumeServer) VolumeTierMoveDatToRemote(req *volume_server_pb.VolumeTi
 		return fmt.Errorf("existing collection:%v unexpected input: %v", v.Collection, req.Collection)
 	}
 
+	// the encrypted data file can only be decrypted with the data key kept in the local .vif file
+	if v.IsEncrypted() {
+		return fmt.Errorf("volume %d is encrypted and can not be tiered", req.VolumeId)
+	}
+
+	if v.IsObjectStored() {
+		return fmt.Errorf("volume %d is on the object store and can not be tiered", req.VolumeId)
+	}
+
 	// locate the disk file
-	diskFile, ok := v.DataBackend.(*backend.DiskFile)
+	diskFile, ok := backend.UnwrapDiskFile(v.DataBackend)
 	if !ok {
 		return nil // already copied to remove. fmt.Errorf("volume %d is not on local disk", req.VolumeId)
 	}
@@ -77,6 +86,7 @@ func (vs *VolumeServer) VolumeTierMoveDatToRemote(req *volume_server_pb.VolumeTi
 		FileSize:     uint64(size),
 		ModifiedTime: uint64(time.Now().Unix()),
 		Extension:    ".dat",
+		IsArchived:   backend.IsArchivalStorage(backendStorage),
 	})
 
 	if err := v.SaveVolumeInfo(); err != nil {
@@ -34,6 +34,14 @@ func (vs *VolumeServer) VacuumVolumeCheck(ctx context.Context, req *volume_serve
 }
 
 func (vs *VolumeServer) VacuumVolumeCompact(req *volume_server_pb.VacuumVolumeCompactRequest, stream volume_server_pb.VolumeServer_VacuumVolumeCompactServer) error {
+	// queued within the concurrency limits, before the compaction time is measured
+	done, err := vs.store.WaitVacuumTurn(stream.Context(), needle.VolumeId(req.VolumeId))
+	if err != nil {
+		glog.Errorf("failed to wait to compact volume %d: %v", req.VolumeId, err)
+		return err
+	}
+	defer done()
+
 	start := time.Now()
 	defer func(start time.Time) {
 		stats.VolumeServerVacuumingHistogram.WithLabelValues("compact").Observe(time.Since(start).Seconds())
@@ -44,7 +52,7 @@ func (vs *VolumeServer) VacuumVolumeCompact(req *volume_server_pb.VacuumVolumeCo
 	nextReportTarget := reportInterval
 	fs, fsErr := procfs.NewDefaultFS()
 	var sendErr error
-	err := vs.store.CompactVolume(needle.VolumeId(req.VolumeId), req.Preallocate, vs.compactionBytePerSecond, func(processed int64) bool {
+	err = vs.store.CompactVolume(needle.VolumeId(req.VolumeId), req.Preallocate, vs.compactionBytePerSecond, func(processed int64) bool {
 		if processed > nextReportTarget {
 			resp.ProcessedBytes = processed
 			if fsErr == nil && numCPU > 0 {
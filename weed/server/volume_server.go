@@ -2,11 +2,13 @@ package weed_server
 
 import (
 	"net/http"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/pb"
 	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
 
 	"google.golang.org/grpc"
@@ -40,6 +42,8 @@ type VolumeServer struct {
 	guard           *security.Guard
 	grpcDialOption  grpc.DialOption
 
+	manifestSigningKey security.SigningKey
+
 	needleMapKind           storage.NeedleMapKind
 	ldbTimout               int64
 	FixJpgOrientation       bool
@@ -48,8 +52,12 @@ type VolumeServer struct {
 	metricsAddress          string
 	metricsIntervalSec      int
 	fileSizeLimitBytes      int64
+	zstdCollections         map[string]bool
+	qos                     *volumeQos
 	isHeartbeating          bool
 	stopChan                chan bool
+	drainedChan             chan struct{}
+	drainedOnce             sync.Once
 }
 
 func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
@@ -70,6 +78,38 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 	hasSlowRead bool,
 	readBufferSizeMB int,
 	ldbTimeout int64,
+	scrubOption storage.ScrubOption,
+	zstdCollections []string,
+	encryptedCollections []string,
+	deduplicatedCollections []string,
+	checksumAlgorithm string,
+	ioUring bool,
+	needleWal bool,
+	compactInPlace bool,
+	zoned bool,
+	readOnlyIndex string,
+	tierCacheDir string,
+	tierCacheSizeMB int,
+	qosCollections map[string]CollectionQos,
+	qosConcurrency int,
+	replicaStream bool,
+	groupCommitDelay time.Duration,
+	diskFailureThreshold int,
+	discardDelay time.Duration,
+	needleFilterCollections []string,
+	diskReadCacheDir string,
+	diskReadCacheSizeMB int,
+	objectStoreBackend string,
+	objectStoreSegmentSizeMB int,
+	ecReadHedgeDelay time.Duration,
+	pebbleCacheSizeMB int,
+	inPlaceOverwriteCollections []string,
+	vacuumConcurrency int,
+	vacuumConcurrencyPerDisk int,
+	pageCacheStreamSizeMB int,
+	sendfileSizeMB int,
+	tags string,
+	weight float64,
 ) *VolumeServer {
 
 	v := util.GetViper()
@@ -82,6 +122,10 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 	v.SetDefault("jwt.signing.read.expires_after_seconds", 60)
 	readExpiresAfterSec := v.GetInt("jwt.signing.read.expires_after_seconds")
 
+	if err := storage.LoadVolumeEncryption(v); err != nil {
+		glog.Fatalf("load volume encryption: %v", err)
+	}
+
 	vs := &VolumeServer{
 		pulseSeconds:                  pulseSeconds,
 		dataCenter:                    dataCenter,
@@ -94,6 +138,7 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 		fileSizeLimitBytes:            int64(fileSizeLimitMB) * 1024 * 1024,
 		isHeartbeating:                true,
 		stopChan:                      make(chan bool),
+		drainedChan:                   make(chan struct{}),
 		inFlightUploadDataLimitCond:   sync.NewCond(new(sync.Mutex)),
 		inFlightDownloadDataLimitCond: sync.NewCond(new(sync.Mutex)),
 		concurrentUploadLimit:         concurrentUploadLimit,
@@ -102,13 +147,86 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 		hasSlowRead:                   hasSlowRead,
 		readBufferSizeMB:              readBufferSizeMB,
 		ldbTimout:                     ldbTimeout,
+		zstdCollections:               make(map[string]bool),
+	}
+	for _, collection := range zstdCollections {
+		vs.zstdCollections[collection] = true
+	}
+	if len(qosCollections) > 0 || qosConcurrency > 0 {
+		vs.qos = newVolumeQos(qosCollections, qosConcurrency)
 	}
 	vs.SeedMasterNodes = masterNodes
 
 	vs.checkWithMaster()
 
+	if ioUring {
+		if err := backend.EnableIoUring(); err != nil {
+			glog.Warningf("read with pread instead of io_uring: %v", err)
+		}
+	}
+	if tierCacheSizeMB > 0 {
+		if tierCacheDir == "" {
+			tierCacheDir = filepath.Join(folders[0], "tier_cache")
+		}
+		if err := backend.EnableRemoteReadCache(tierCacheDir, int64(tierCacheSizeMB)*1024*1024); err != nil {
+			glog.Fatalf("remote tier read cache: %v", err)
+		}
+	}
+	if diskReadCacheSizeMB > 0 {
+		if diskReadCacheDir == "" {
+			diskReadCacheDir = defaultDiskReadCacheDir(folders, diskTypes)
+		}
+		if diskReadCacheDir == "" {
+			glog.Fatalf("hard drive read cache: no ssd folder, set the cache directory on the faster device")
+		}
+		if err := backend.EnableDiskReadCache(diskReadCacheDir, int64(diskReadCacheSizeMB)*1024*1024); err != nil {
+			glog.Fatalf("hard drive read cache: %v", err)
+		}
+	}
+	backend.SetPageCacheStreamSize(int64(pageCacheStreamSizeMB) * 1024 * 1024)
+	backend.SetSendfileSize(int64(sendfileSizeMB) * 1024 * 1024)
+	if needleMapKind == storage.NeedleMapPebble {
+		storage.SetPebbleCacheSize(int64(pebbleCacheSizeMB) * 1024 * 1024)
+	}
 	vs.store = storage.NewStore(vs.grpcDialOption, ip, port, grpcPort, publicUrl, folders, maxCounts, minFreeSpaces, idxFolder, vs.needleMapKind, diskTypes, ldbTimeout)
+	if err := vs.store.SetEncryptedCollections(encryptedCollections); err != nil {
+		glog.Fatalf("volume encryption: %v", err)
+	}
+	vs.store.SetDeduplicatedCollections(deduplicatedCollections)
+	if err := vs.store.SetChecksumAlgorithm(checksumAlgorithm); err != nil {
+		glog.Fatalf("volume checksum: %v", err)
+	}
+	if err := vs.store.LoadNeedleWal(needleWal); err != nil {
+		glog.Fatalf("write-ahead log: %v", err)
+	}
+	vs.store.SetCompactInPlace(compactInPlace)
+	if vacuumConcurrency > 0 || vacuumConcurrencyPerDisk > 0 {
+		vs.store.SetVacuumConcurrency(vacuumConcurrency, vacuumConcurrencyPerDisk)
+	}
+	storage.SetGroupCommitDelay(groupCommitDelay)
+	storage.SetEcReadHedgeDelay(ecReadHedgeDelay)
+	vs.store.SetDiskFailureThreshold(diskFailureThreshold)
+	vs.store.SetDiscardDelay(discardDelay)
+	vs.store.SetNeedleFilterCollections(needleFilterCollections)
+	vs.store.SetInPlaceOverwriteCollections(inPlaceOverwriteCollections)
+	vs.store.CacheHardDriveReads()
+	if err := vs.store.SetObjectStore(objectStoreBackend, objectStoreSegmentSizeMB); err != nil {
+		glog.Fatalf("object store: %v", err)
+	}
+	if zoned {
+		vs.store.SetZoned()
+	}
+	if err := vs.store.SetReadOnlyIndex(readOnlyIndex); err != nil {
+		glog.Fatalf("read only index: %v", err)
+	}
+	if err := vs.store.SetTags(tags); err != nil {
+		glog.Fatalf("volume server tags: %v", err)
+	}
+	if err := vs.store.SetWeight(weight); err != nil {
+		glog.Fatalf("volume server weight: %v", err)
+	}
 	vs.guard = security.NewGuard(whiteList, signingKey, expiresAfterSec, readSigningKey, readExpiresAfterSec)
+	vs.manifestSigningKey = security.SigningKey(v.GetString("volume.manifest.key"))
 
 	handleStaticResources(adminMux)
 	adminMux.HandleFunc("/status", vs.statusHandler)
@@ -130,11 +248,31 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 	}
 
 	go vs.heartbeat()
+	vs.store.StartScrubbing(scrubOption)
+	vs.store.StartReadRepair()
+	if replicaStream {
+		go vs.loopReplicaStreams()
+	}
 	go stats.LoopPushingMetric("volumeServer", util.JoinHostPort(ip, port), vs.metricsAddress, vs.metricsIntervalSec)
 
 	return vs
 }
 
+// shouldCompressWithZstd tells whether the needle data uploaded to the collection is compressed with zstd
+func (vs *VolumeServer) shouldCompressWithZstd(collection string) bool {
+	return vs.zstdCollections["*"] || vs.zstdCollections[collection]
+}
+
+// defaultDiskReadCacheDir caches the hard drive reads under the first ssd folder
+func defaultDiskReadCacheDir(folders []string, diskTypes []types.DiskType) string {
+	for i, diskType := range diskTypes {
+		if diskType == types.SsdType && i < len(folders) {
+			return filepath.Join(folders[i], "hdd_cache")
+		}
+	}
+	return ""
+}
+
 func (vs *VolumeServer) SetStopping() {
 	glog.V(0).Infoln("Stopping volume server...")
 	vs.store.SetStopping()
@@ -0,0 +1,46 @@
+package weed_server
+
+import (
+	"context"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+)
+
+// VolumeServerDrain stops accepting writes, so the master moves the volumes and ec shards away.
+// Once empty, the volume server stops the heartbeat and exits.
+func (vs *VolumeServer) VolumeServerDrain(ctx context.Context, req *volume_server_pb.VolumeServerDrainRequest) (*volume_server_pb.VolumeServerDrainResponse, error) {
+
+	resp := &volume_server_pb.VolumeServerDrainResponse{}
+
+	if req.Cancel {
+		vs.store.SetDraining(false)
+		return resp, nil
+	}
+	if !vs.store.IsDraining() {
+		vs.store.SetDraining(true)
+		go vs.waitUntilDrained()
+	}
+
+	return resp, nil
+}
+
+// Drained is closed when the draining volume server has nothing left
+func (vs *VolumeServer) Drained() <-chan struct{} {
+	return vs.drainedChan
+}
+
+func (vs *VolumeServer) waitUntilDrained() {
+	for vs.store.IsDraining() {
+		if vs.store.IsEmpty() {
+			vs.drainedOnce.Do(func() {
+				glog.V(0).Infof("volume server %s:%d is drained", vs.store.Ip, vs.store.Port)
+				vs.StopHeartbeat()
+				close(vs.drainedChan)
+			})
+			return
+		}
+		time.Sleep(time.Duration(vs.pulseSeconds) * time.Second)
+	}
+}
@@ -41,6 +41,11 @@ func (vs *VolumeServer) privateStoreHandler(w http.ResponseWriter, r *http.Reque
 	defer func(start time.Time) {
 		stats.VolumeServerRequestHistogram.WithLabelValues(r.Method).Observe(time.Since(start).Seconds())
 	}(start)
+	w, release, ok := vs.limitQos(w, r)
+	if !ok {
+		return
+	}
+	defer release()
 	switch r.Method {
 	case "GET", "HEAD":
 		stats.ReadRequest()
@@ -123,6 +128,11 @@ func (vs *VolumeServer) publicReadOnlyHandler(w http.ResponseWriter, r *http.Req
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 	}
+	w, release, ok := vs.limitQos(w, r)
+	if !ok {
+		return
+	}
+	defer release()
 	switch r.Method {
 	case "GET", "HEAD":
 		stats.ReadRequest()
@@ -22,6 +22,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/images"
 	"github.com/seaweedfs/seaweedfs/weed/operation"
 	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 )
@@ -142,12 +143,16 @@ func (vs *VolumeServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil && err != storage.ErrorDeleted && hasVolume {
 		glog.V(4).Infof("read needle: %v", err)
 		// start to fix it from other replicas, if not deleted and hasVolume and is not a replicated request
+		vs.store.QueueReadRepair(volumeId, n.Id, err)
 	}
 	// glog.V(4).Infoln("read bytes", count, "error", err)
 	if err != nil || count < 0 {
 		glog.V(3).Infof("read %s isNormalVolume %v error: %v", r.URL.Path, hasVolume, err)
 		if err == storage.ErrorNotFound || err == storage.ErrorDeleted {
 			w.WriteHeader(http.StatusNotFound)
+		} else if hasVolume && vs.store.IsVolumeRemoteArchived(volumeId) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(backend.RestorePollInterval.Seconds())))
+			w.WriteHeader(http.StatusServiceUnavailable)
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
 		}
@@ -211,10 +216,8 @@ func (vs *VolumeServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request)
 			if n.Data, err = util.DecompressData(n.Data); err != nil {
 				glog.V(0).Infoln("ungzip error:", err, r.URL.Path)
 			}
-			// } else if strings.Contains(r.Header.Get("Accept-Encoding"), "zstd") && util.IsZstdContent(n.Data) {
-			//	w.Header().Set("Content-Encoding", "zstd")
-		} else if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") && util.IsGzippedContent(n.Data) {
-			w.Header().Set("Content-Encoding", "gzip")
+		} else if contentEncoding := acceptedContentEncoding(r.Header.Get("Accept-Encoding"), n.Data); contentEncoding != "" {
+			w.Header().Set("Content-Encoding", contentEncoding)
 		} else {
 			if n.Data, err = util.DecompressData(n.Data); err != nil {
 				glog.V(0).Infoln("uncompress error:", err, r.URL.Path)
@@ -233,6 +236,18 @@ func (vs *VolumeServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// acceptedContentEncoding returns the encoding to serve the compressed data as is,
+// or empty if the client does not accept it and the data should be decompressed.
+func acceptedContentEncoding(acceptEncoding string, data []byte) string {
+	if strings.Contains(acceptEncoding, "zstd") && util.IsZstdContent(data) {
+		return "zstd"
+	}
+	if strings.Contains(acceptEncoding, "gzip") && util.IsGzippedContent(data) {
+		return "gzip"
+	}
+	return ""
+}
+
 func shouldAttemptStreamWrite(hasLocalVolume bool, ext string, r *http.Request) (shouldAttempt bool, mustMetaOnly bool) {
 	if !hasLocalVolume {
 		return false, false
@@ -281,6 +296,7 @@ func (vs *VolumeServer) tryHandleChunkedFile(n *needle.Needle, fileName string,
 
 	chunkedFileReader := operation.NewChunkedFileReader(chunkManifest.Chunks, vs.GetMaster(), vs.grpcDialOption)
 	defer chunkedFileReader.Close()
+	chunkedFileReader.SetParallelReads(chunkManifest.StripeWidth)
 
 	rs := conditionallyCropImages(chunkedFileReader, ext, r)
 	rs = conditionallyResizeImages(rs, ext, r)
@@ -398,6 +414,8 @@ func (vs *VolumeServer) streamWriteResponseContent(filename string, mimeType str
 		return
 	}
 
+	// not sent by the kernel if the response is throttled
+	_, readOption.CanSendfile = w.(io.ReaderFrom)
 	processRangeRequest(r, w, totalSize, mimeType, func(writer io.Writer, offset int64, size int64) error {
 		return vs.store.ReadVolumeNeedleDataInto(volumeId, n, readOption, writer, offset, size)
 	})
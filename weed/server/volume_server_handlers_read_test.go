@@ -0,0 +1,41 @@
+package weed_server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestAcceptedContentEncoding(t *testing.T) {
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog\n"), 100)
+	gzipped, _ := util.GzipData(text)
+	zstded, _ := util.ZstdData(text)
+
+	for _, tc := range []struct {
+		name           string
+		acceptEncoding string
+		data           []byte
+		expected       string
+	}{
+		{"zstd to zstd client", "gzip, deflate, br, zstd", zstded, "zstd"},
+		{"zstd to gzip only client", "gzip, deflate", zstded, ""},
+		{"zstd to client without encodings", "", zstded, ""},
+		{"gzip to gzip client", "gzip, deflate", gzipped, "gzip"},
+		{"gzip to zstd only client", "zstd", gzipped, ""},
+		{"gzip to zstd client", "zstd, gzip", gzipped, "gzip"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			contentEncoding := acceptedContentEncoding(tc.acceptEncoding, tc.data)
+			if contentEncoding != tc.expected {
+				t.Fatalf("Content-Encoding %q, expected %q", contentEncoding, tc.expected)
+			}
+			if contentEncoding == "" {
+				// served decompressed instead
+				if decompressed, err := util.DecompressData(tc.data); err != nil || !bytes.Equal(decompressed, text) {
+					t.Errorf("decompress: %v", err)
+				}
+			}
+		})
+	}
+}
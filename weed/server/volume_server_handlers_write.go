@@ -38,7 +38,14 @@ func (vs *VolumeServer) PostHandler(w http.ResponseWriter, r *http.Request) {
 	bytesBuffer := bufPool.Get().(*bytes.Buffer)
 	defer bufPool.Put(bytesBuffer)
 
-	reqNeedle, originalSize, contentMd5, ne := needle.CreateNeedleFromRequest(r, vs.FixJpgOrientation, vs.fileSizeLimitBytes, bytesBuffer)
+	compressWithZstd := false
+	checksumAlgorithm := needle.ChecksumCrc32c
+	if v := vs.store.GetVolume(volumeId); v != nil {
+		compressWithZstd = vs.shouldCompressWithZstd(v.Collection)
+		checksumAlgorithm = v.ChecksumAlgorithm()
+	}
+
+	reqNeedle, originalSize, contentMd5, ne := needle.CreateNeedleFromRequest(r, vs.FixJpgOrientation, vs.fileSizeLimitBytes, bytesBuffer, compressWithZstd, checksumAlgorithm)
 	if ne != nil {
 		writeJsonError(w, r, http.StatusBadRequest, ne)
 		return
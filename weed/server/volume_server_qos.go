@@ -0,0 +1,253 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// Each collection can be capped by its iops and bandwidth. Unlike the filer client limits,
+// the requests over the caps wait instead of being rejected, since they are mostly internal traffic.
+// With a concurrency limit, the waiting requests take turns between the collections,
+// so a busy collection only gets its fair share of the volume server under contention.
+
+// qosDefaultCollection applies to the collections without their own caps
+const qosDefaultCollection = "*"
+
+// CollectionQos are the caps of a collection. A zero value means no cap.
+type CollectionQos struct {
+	Iops           float64
+	BytesPerSecond int64
+}
+
+// ParseCollectionQos parses comma separated "<collection>=<iops>/<MBps>", e.g. "logs=200/50,*=1000/0",
+// where "*" applies to the collections not listed.
+func ParseCollectionQos(spec string) (map[string]CollectionQos, error) {
+	caps := make(map[string]CollectionQos)
+	for _, item := range util.StringSplit(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		sepIndex := strings.LastIndex(item, "=")
+		parts := strings.Split(item[sepIndex+1:], "/")
+		if sepIndex <= 0 || len(parts) != 2 {
+			return nil, fmt.Errorf("invalid collection qos %q, expecting <collection>=<iops>/<MBps>", item)
+		}
+		iops, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid iops in %q: %v", item, err)
+		}
+		mbps, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MBps in %q: %v", item, err)
+		}
+		caps[item[:sepIndex]] = CollectionQos{
+			Iops:           iops,
+			BytesPerSecond: mbps * 1024 * 1024,
+		}
+	}
+	return caps, nil
+}
+
+type collectionQosState struct {
+	iops      *rate.Limiter
+	bandwidth *rate.Limiter
+}
+
+type volumeQos struct {
+	caps        map[string]CollectionQos
+	lock        sync.Mutex
+	collections map[string]*collectionQosState
+	queue       *fairQueue
+}
+
+func newVolumeQos(caps map[string]CollectionQos, concurrency int) *volumeQos {
+	q := &volumeQos{
+		caps:        caps,
+		collections: make(map[string]*collectionQosState),
+	}
+	if concurrency > 0 {
+		q.queue = newFairQueue(concurrency)
+	}
+	return q
+}
+
+func (q *volumeQos) stateOf(collection string) *collectionQosState {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	state, found := q.collections[collection]
+	if found {
+		return state
+	}
+	caps, found := q.caps[collection]
+	if !found {
+		caps = q.caps[qosDefaultCollection]
+	}
+	state = &collectionQosState{}
+	if caps.Iops > 0 {
+		state.iops = rate.NewLimiter(rate.Limit(caps.Iops), int(math.Max(1, math.Ceil(caps.Iops))))
+	}
+	if caps.BytesPerSecond > 0 {
+		state.bandwidth = rate.NewLimiter(rate.Limit(caps.BytesPerSecond), int(caps.BytesPerSecond))
+	}
+	q.collections[collection] = state
+	return state
+}
+
+// collectionOfHttpRequest finds the collection of the volume in the request path
+func (vs *VolumeServer) collectionOfHttpRequest(r *http.Request) (string, bool) {
+	vid, _, _, _, _ := parseURLPath(r.URL.Path)
+	volumeId, err := needle.NewVolumeId(vid)
+	if err != nil {
+		return "", false
+	}
+	if v := vs.store.GetVolume(volumeId); v != nil {
+		return v.Collection, true
+	}
+	if ev, found := vs.store.FindEcVolume(volumeId); found {
+		return ev.Collection, true
+	}
+	return "", false
+}
+
+// limitQos waits for the iops cap and the fair share of the collection, and throttles reading the request body
+// and writing the response by the bandwidth cap. The returned release function must be called once the request is done.
+func (vs *VolumeServer) limitQos(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func(), bool) {
+	if vs.qos == nil {
+		return w, func() {}, true
+	}
+	switch r.Method {
+	case "GET", "HEAD", "PUT", "POST", "DELETE":
+	default:
+		return w, func() {}, true
+	}
+	collection, found := vs.collectionOfHttpRequest(r)
+	if !found {
+		return w, func() {}, true
+	}
+
+	state := vs.qos.stateOf(collection)
+	if state.iops != nil {
+		if err := state.iops.Wait(r.Context()); err != nil {
+			glog.V(1).Infof("qos %s %s: %v", r.Method, r.URL.Path, err)
+			writeJsonError(w, r, http.StatusTooManyRequests, fmt.Errorf("collection %q over iops: %v", collection, err))
+			return w, nil, false
+		}
+	}
+
+	release := func() {}
+	// the replicated writes are excluded, to avoid two volume servers waiting for each other
+	if vs.qos.queue != nil && r.URL.Query().Get("type") != "replicate" {
+		if err := vs.qos.queue.acquire(r.Context(), collection); err != nil {
+			glog.V(1).Infof("qos %s %s: %v", r.Method, r.URL.Path, err)
+			writeJsonError(w, r, http.StatusTooManyRequests, fmt.Errorf("collection %q waiting for its turn: %v", collection, err))
+			return w, nil, false
+		}
+		release = vs.qos.queue.release
+	}
+
+	if state.bandwidth != nil {
+		r.Body = &throttledReadCloser{ReadCloser: r.Body, ctx: r.Context(), limiter: state.bandwidth}
+		w = &throttledResponseWriter{ResponseWriter: w, ctx: r.Context(), limiter: state.bandwidth}
+	}
+	return w, release, true
+}
+
+// fairQueue admits up to the concurrency of requests. When more requests are waiting,
+// a finished request hands its slot over to the next collection in turn.
+type fairQueue struct {
+	concurrency int
+	lock        sync.Mutex
+	inFlight    int
+	waiting     map[string][]chan struct{}
+	turns       []string // the collections with waiting requests, in round robin order
+}
+
+func newFairQueue(concurrency int) *fairQueue {
+	return &fairQueue{
+		concurrency: concurrency,
+		waiting:     make(map[string][]chan struct{}),
+	}
+}
+
+func (q *fairQueue) acquire(ctx context.Context, collection string) error {
+	q.lock.Lock()
+	if q.inFlight < q.concurrency && len(q.turns) == 0 {
+		q.inFlight++
+		q.lock.Unlock()
+		return nil
+	}
+	admitted := make(chan struct{})
+	if len(q.waiting[collection]) == 0 {
+		q.turns = append(q.turns, collection)
+	}
+	q.waiting[collection] = append(q.waiting[collection], admitted)
+	q.lock.Unlock()
+
+	select {
+	case <-admitted:
+		return nil
+	case <-ctx.Done():
+		q.lock.Lock()
+		removed := q.removeWaiting(collection, admitted)
+		q.lock.Unlock()
+		if !removed {
+			// admitted at the same time, so pass the slot on
+			q.release()
+		}
+		return ctx.Err()
+	}
+}
+
+func (q *fairQueue) release() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.turns) == 0 {
+		q.inFlight--
+		return
+	}
+	collection := q.turns[0]
+	q.turns = q.turns[1:]
+	waiting := q.waiting[collection]
+	admitted := waiting[0]
+	if len(waiting) > 1 {
+		q.waiting[collection] = waiting[1:]
+		q.turns = append(q.turns, collection)
+	} else {
+		delete(q.waiting, collection)
+	}
+	close(admitted)
+}
+
+func (q *fairQueue) removeWaiting(collection string, admitted chan struct{}) bool {
+	waiting := q.waiting[collection]
+	for i, ch := range waiting {
+		if ch != admitted {
+			continue
+		}
+		if len(waiting) > 1 {
+			q.waiting[collection] = append(waiting[:i:i], waiting[i+1:]...)
+			return true
+		}
+		delete(q.waiting, collection)
+		for j, c := range q.turns {
+			if c == collection {
+				q.turns = append(q.turns[:j:j], q.turns[j+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package weed_server
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseCollectionQos(t *testing.T) {
+	caps, err := ParseCollectionQos("logs=200/50, *=1000/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := caps["logs"]; c.Iops != 200 || c.BytesPerSecond != 50*1024*1024 {
+		t.Errorf("logs: %+v", c)
+	}
+	if c := caps[qosDefaultCollection]; c.Iops != 1000 || c.BytesPerSecond != 0 {
+		t.Errorf("default: %+v", c)
+	}
+	for _, spec := range []string{"logs", "logs=1", "=1/2", "logs=a/2", "logs=1/b"} {
+		if _, err := ParseCollectionQos(spec); err == nil {
+			t.Errorf("expecting an error for %q", spec)
+		}
+	}
+}
+
+func TestFairQueueTakesTurns(t *testing.T) {
+	q := newFairQueue(1)
+	ctx := context.Background()
+	if err := q.acquire(ctx, "bulk"); err != nil {
+		t.Fatal(err)
+	}
+
+	admitted := make(chan string, 4)
+	waitFor := func(collection string) {
+		go func() {
+			if err := q.acquire(ctx, collection); err == nil {
+				admitted <- collection
+			}
+		}()
+		// keep the order of the waiting requests
+		for {
+			q.lock.Lock()
+			n := len(q.waiting[collection])
+			q.lock.Unlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor("bulk")
+	waitFor("bulk")
+	waitFor("interactive")
+
+	var order []string
+	for i := 0; i < 3; i++ {
+		q.release()
+		order = append(order, <-admitted)
+	}
+	if order[0] != "bulk" || order[1] != "interactive" || order[2] != "bulk" {
+		t.Errorf("unexpected order %v", order)
+	}
+	q.release()
+	if q.inFlight != 0 || len(q.turns) != 0 {
+		t.Errorf("in flight %d, turns %v", q.inFlight, q.turns)
+	}
+}
+
+func TestFairQueueCancelWaiting(t *testing.T) {
+	q := newFairQueue(1)
+	if err := q.acquire(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.acquire(ctx, "b"); err == nil {
+		t.Fatal("expecting the waiting to be cancelled")
+	}
+	if len(q.turns) != 0 || len(q.waiting) != 0 {
+		t.Errorf("turns %v, waiting %v", q.turns, q.waiting)
+	}
+	q.release()
+	if err := q.acquire(context.Background(), "b"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,175 @@
+package weed_server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// With the replica streaming, each replicated volume keeps tailing the needle appends of its replicas,
+// instead of waiting for volume.fix.replication or volume.check.disk to copy the differences.
+// The streams are started and stopped as the replicas move, and resume from the last applied needle.
+
+const (
+	replicaStreamCheckInterval = 30 * time.Second
+	replicaStreamRetryInterval = 10 * time.Second
+)
+
+type replicaStreamKey struct {
+	volumeId needle.VolumeId
+	peer     pb.ServerAddress
+}
+
+// replicaStreamProgress is the append timestamp of the last needle applied from each replica of a volume
+type replicaStreamProgress struct {
+	sync.Mutex
+	sinceNs map[string]uint64
+	isDirty bool
+}
+
+func (p *replicaStreamProgress) get(peer pb.ServerAddress) (sinceNs uint64, found bool) {
+	p.Lock()
+	defer p.Unlock()
+	sinceNs, found = p.sinceNs[string(peer)]
+	return
+}
+
+func (p *replicaStreamProgress) set(peer pb.ServerAddress, sinceNs uint64) {
+	p.Lock()
+	defer p.Unlock()
+	p.sinceNs[string(peer)] = sinceNs
+	p.isDirty = true
+}
+
+func (vs *VolumeServer) loopReplicaStreams() {
+	streams := make(map[replicaStreamKey]context.CancelFunc)
+	progresses := make(map[needle.VolumeId]*replicaStreamProgress)
+	for {
+		time.Sleep(replicaStreamCheckInterval)
+
+		volumes := make(map[needle.VolumeId]*storage.Volume)
+		wanted := make(map[replicaStreamKey]bool)
+		if vs.isHeartbeating {
+			for _, v := range vs.store.ReplicaStreamVolumes() {
+				volumes[v.Id] = v
+			}
+			for _, key := range vs.lookupReplicaStreams(volumes) {
+				wanted[key] = true
+			}
+		}
+
+		for key, cancel := range streams {
+			if !wanted[key] {
+				glog.V(0).Infof("stop streaming volume %d from %s", key.volumeId, key.peer)
+				cancel()
+				delete(streams, key)
+			}
+		}
+		for vid, progress := range progresses {
+			v, found := volumes[vid]
+			if !found {
+				delete(progresses, vid)
+				continue
+			}
+			progress.Lock()
+			if progress.isDirty {
+				if err := v.SaveReplicaStreamProgress(progress.sinceNs); err != nil {
+					glog.Warningf("save volume %d replica stream progress: %v", vid, err)
+				}
+				progress.isDirty = false
+			}
+			progress.Unlock()
+		}
+		for key := range wanted {
+			if _, found := streams[key]; found {
+				continue
+			}
+			v := volumes[key.volumeId]
+			progress, found := progresses[key.volumeId]
+			if !found {
+				sinceNs, err := v.LoadReplicaStreamProgress()
+				if err != nil {
+					glog.Warningf("volume %d replica stream progress: %v", v.Id, err)
+					continue
+				}
+				progress = &replicaStreamProgress{sinceNs: sinceNs}
+				progresses[key.volumeId] = progress
+			}
+			if _, found := progress.get(key.peer); !found {
+				// the needles before are replicated when written, or copied with the volume
+				progress.set(key.peer, v.LastAppendAtNs())
+			}
+			glog.V(0).Infof("start streaming volume %d from %s", key.volumeId, key.peer)
+			ctx, cancel := context.WithCancel(context.Background())
+			streams[key] = cancel
+			go vs.streamFromReplica(ctx, key, v.Collection, progress)
+		}
+	}
+}
+
+// lookupReplicaStreams lists the other replicas of each volume
+func (vs *VolumeServer) lookupReplicaStreams(volumes map[needle.VolumeId]*storage.Volume) (keys []replicaStreamKey) {
+	if len(volumes) == 0 {
+		return nil
+	}
+	var vids []string
+	for vid := range volumes {
+		vids = append(vids, vid.String())
+	}
+	lookups, err := operation.LookupVolumeIds(vs.GetMaster, vs.grpcDialOption, vids)
+	if err != nil {
+		glog.V(0).Infof("lookup replicas to stream from: %v", err)
+		return nil
+	}
+	self := util.JoinHostPort(vs.store.Ip, vs.store.Port)
+	for vid := range volumes {
+		lookup, found := lookups[vid.String()]
+		if !found {
+			continue
+		}
+		for _, location := range lookup.Locations {
+			if location.Url != self {
+				keys = append(keys, replicaStreamKey{volumeId: vid, peer: location.ServerAddress()})
+			}
+		}
+	}
+	return
+}
+
+// streamFromReplica applies the needles appended to the replica, and retries until cancelled
+func (vs *VolumeServer) streamFromReplica(ctx context.Context, key replicaStreamKey, collection string, progress *replicaStreamProgress) {
+	for {
+		sinceNs, _ := progress.get(key.peer)
+		err := operation.TailVolumeFromSourceWithContext(ctx, key.peer, vs.grpcDialOption, key.volumeId, sinceNs, 0, func(n *needle.Needle) error {
+			applied, err := vs.store.ApplyReplicatedNeedle(key.volumeId, n)
+			if err != nil {
+				return err
+			}
+			if applied {
+				stats.VolumeServerReplicaStreamCounter.WithLabelValues(collection, "applied").Inc()
+				glog.V(1).Infof("applied volume %d needle %s from %s", key.volumeId, n.Id, key.peer)
+			} else {
+				stats.VolumeServerReplicaStreamCounter.WithLabelValues(collection, "skipped").Inc()
+			}
+			progress.set(key.peer, n.AppendAtNs)
+			return nil
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		glog.V(0).Infof("stream volume %d from %s: %v", key.volumeId, key.peer, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(replicaStreamRetryInterval):
+		}
+	}
+}
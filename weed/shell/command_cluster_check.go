@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/cluster"
 	"github.com/seaweedfs/seaweedfs/weed/pb"
@@ -25,17 +26,34 @@ func (c *commandClusterCheck) Name() string {
 }
 
 func (c *commandClusterCheck) Help() string {
-	return `check current cluster network connectivity
+	return `check the cluster health, and the network connectivity between all the servers
 
-	cluster.check
+	cluster.check                   # check the connectivity, and diagnose the cluster
+	cluster.check -ping=false       # only diagnose the cluster
+	cluster.check -clockSkew 200ms  # warn about the clocks apart by more than 200ms
+
+	The diagnostics, each with a severity of critical, warning or info:
+	  * the volumes under replicated, over replicated, or violating the replica placement
+	  * the read only volumes, and the volumes read only on some replicas
+	  * the ec volumes missing shards, and not recoverable
+	  * the clocks apart between the servers
+	  * the filers failing to read their filer stores
+	  * the different versions of the masters, volume servers and filers
+	  * the misconfigurations, e.g. no hdd disk, full volume servers, or a default replication
+	    the topology can not satisfy
+
+	The command fails if there is any critical finding.
+	Run with "weed shell -o json" for the findings as structured output.
 
 `
 }
 
 func (c *commandClusterCheck) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
 
-	clusterPsCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
-	if err = clusterPsCommand.Parse(args); err != nil {
+	clusterCheckCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	ping := clusterCheckCommand.Bool("ping", true, "check the connections between all the servers")
+	clockSkew := clusterCheckCommand.Duration("clockSkew", time.Second, "warn about the clocks apart by more than this")
+	if err = clusterCheckCommand.Parse(args); err != nil {
 		return nil
 	}
 
@@ -46,27 +64,23 @@ func (c *commandClusterCheck) Do(args []string, commandEnv *CommandEnv, writer i
 	}
 	fmt.Fprintf(writer, "Topology volumeSizeLimit:%d MB%s\n", volumeSizeLimitMb, diskInfosToString(topologyInfo.DiskInfos))
 
-	emptyDiskTypeDiskInfo, emptyDiskTypeFound := topologyInfo.DiskInfos[""]
-	hddDiskTypeDiskInfo, hddDiskTypeFound := topologyInfo.DiskInfos["hdd"]
-	if !emptyDiskTypeFound && !hddDiskTypeFound {
-		return fmt.Errorf("Need to a hdd disk type!")
-	}
-	if emptyDiskTypeFound && emptyDiskTypeDiskInfo.MaxVolumeCount == 0 || hddDiskTypeFound && hddDiskTypeDiskInfo.MaxVolumeCount == 0 {
-		return fmt.Errorf("Need to a hdd disk type!")
-	}
-
 	// collect filers
 	var filers []pb.ServerAddress
+	versions := make(map[string][]string)
 	err = commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
 		resp, err := client.ListClusterNodes(context.Background(), &master_pb.ListClusterNodesRequest{
 			ClientType: cluster.FilerType,
 			FilerGroup: *commandEnv.option.FilerGroup,
 		})
+		if err != nil {
+			return err
+		}
 
 		for _, node := range resp.ClusterNodes {
 			filers = append(filers, pb.ServerAddress(node.Address))
+			addVersion(versions, node.Version, "filer "+node.Address)
 		}
-		return err
+		return nil
 	})
 	if err != nil {
 		return
@@ -75,17 +89,9 @@ func (c *commandClusterCheck) Do(args []string, commandEnv *CommandEnv, writer i
 
 	// collect volume servers
 	var volumeServers []pb.ServerAddress
-	t, _, err := collectTopologyInfo(commandEnv, 0)
-	if err != nil {
-		return err
-	}
-	for _, dc := range t.DataCenterInfos {
-		for _, r := range dc.RackInfos {
-			for _, dn := range r.DataNodeInfos {
-				volumeServers = append(volumeServers, pb.NewServerAddressFromDataNode(dn))
-			}
-		}
-	}
+	eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+		volumeServers = append(volumeServers, pb.NewServerAddressFromDataNode(dn))
+	})
 	fmt.Fprintf(writer, "the cluster has %d volume servers: %+v\n", len(volumeServers), volumeServers)
 
 	// collect all masters
@@ -94,22 +100,83 @@ func (c *commandClusterCheck) Do(args []string, commandEnv *CommandEnv, writer i
 		masters = append(masters, master)
 	}
 
+	checks := &clusterCheckResult{
+		Masters:       masters,
+		Filers:        filers,
+		VolumeServers: volumeServers,
+		Checks:        []*clusterCheck{},
+		Findings:      []*clusterCheckFinding{},
+	}
+	if *ping {
+		checks.checkConnections(commandEnv, writer)
+	}
+
+	// diagnose the cluster
+	defaultReplication := ""
+	for _, master := range masters {
+		pb.WithMasterClient(false, master, commandEnv.option.GrpcDialOption, false, func(client master_pb.SeaweedClient) error {
+			resp, err := client.GetMasterConfiguration(context.Background(), &master_pb.GetMasterConfigurationRequest{})
+			if err != nil {
+				checks.report(severityCritical, "connectivity", "master "+string(master), fmt.Sprintf("get the configuration: %v", err))
+				return err
+			}
+			defaultReplication = resp.DefaultReplication
+			addVersion(versions, resp.Version, "master "+string(master))
+			return nil
+		})
+	}
+	for _, volumeServer := range volumeServers {
+		pb.WithVolumeServerClient(false, volumeServer, commandEnv.option.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+			resp, err := client.VolumeServerStatus(context.Background(), &volume_server_pb.VolumeServerStatusRequest{})
+			if err != nil {
+				checks.report(severityCritical, "connectivity", "volume server "+string(volumeServer), fmt.Sprintf("get the status: %v", err))
+				return err
+			}
+			addVersion(versions, resp.Version, "volume server "+string(volumeServer))
+			return nil
+		})
+	}
+	for _, filer := range filers {
+		checks.checkFilerStore(commandEnv, filer)
+	}
+	deferredVolumeIds, err := collectDeferredVolumeIds(commandEnv)
+	if err != nil {
+		return err
+	}
+	checks.diagnoseConfiguration(topologyInfo, defaultReplication)
+	checks.diagnoseVolumes(topologyInfo, deferredVolumeIds)
+	checks.diagnoseEcShards(topologyInfo)
+	checks.diagnoseClockSkew(*clockSkew)
+	checks.diagnoseVersions(versions)
+
+	critical := checks.printFindings(writer)
+	commandEnv.setResult(checks)
+	if critical > 0 {
+		return fmt.Errorf("%d critical findings", critical)
+	}
+	return nil
+}
+
+// checkConnections pings from each server to the others
+func (r *clusterCheckResult) checkConnections(commandEnv *CommandEnv, writer io.Writer) {
+	masters, filers, volumeServers := r.Masters, r.Filers, r.VolumeServers
+
 	// check from master to volume servers
 	for _, master := range masters {
 		for _, volumeServer := range volumeServers {
-			fmt.Fprintf(writer, "checking master %s to volume server %s ... ", string(master), string(volumeServer))
+			r.begin(writer, "master", master, "volume server", volumeServer)
 			err := pb.WithMasterClient(false, master, commandEnv.option.GrpcDialOption, false, func(client master_pb.SeaweedClient) error {
 				pong, err := client.Ping(context.Background(), &master_pb.PingRequest{
 					Target:     string(volumeServer),
 					TargetType: cluster.VolumeServerType,
 				})
 				if err == nil {
-					printTiming(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
+					r.ok(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
 				}
 				return err
 			})
 			if err != nil {
-				fmt.Fprintf(writer, "%v\n", err)
+				r.fail(writer, err)
 			}
 		}
 	}
@@ -120,19 +187,19 @@ func (c *commandClusterCheck) Do(args []string, commandEnv *CommandEnv, writer i
 			if sourceMaster == targetMaster {
 				continue
 			}
-			fmt.Fprintf(writer, "checking master %s to %s ... ", string(sourceMaster), string(targetMaster))
+			r.begin(writer, "master", sourceMaster, "master", targetMaster)
 			err := pb.WithMasterClient(false, sourceMaster, commandEnv.option.GrpcDialOption, false, func(client master_pb.SeaweedClient) error {
 				pong, err := client.Ping(context.Background(), &master_pb.PingRequest{
 					Target:     string(targetMaster),
 					TargetType: cluster.MasterType,
 				})
 				if err == nil {
-					printTiming(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
+					r.ok(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
 				}
 				return err
 			})
 			if err != nil {
-				fmt.Fprintf(writer, "%v\n", err)
+				r.fail(writer, err)
 			}
 		}
 	}
@@ -140,19 +207,19 @@ func (c *commandClusterCheck) Do(args []string, commandEnv *CommandEnv, writer i
 	// check from volume servers to masters
 	for _, volumeServer := range volumeServers {
 		for _, master := range masters {
-			fmt.Fprintf(writer, "checking volume server %s to master %s ... ", string(volumeServer), string(master))
+			r.begin(writer, "volume server", volumeServer, "master", master)
 			err := pb.WithVolumeServerClient(false, volumeServer, commandEnv.option.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
 				pong, err := client.Ping(context.Background(), &volume_server_pb.PingRequest{
 					Target:     string(master),
 					TargetType: cluster.MasterType,
 				})
 				if err == nil {
-					printTiming(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
+					r.ok(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
 				}
 				return err
 			})
 			if err != nil {
-				fmt.Fprintf(writer, "%v\n", err)
+				r.fail(writer, err)
 			}
 		}
 	}
@@ -160,19 +227,19 @@ func (c *commandClusterCheck) Do(args []string, commandEnv *CommandEnv, writer i
 	// check from filers to masters
 	for _, filer := range filers {
 		for _, master := range masters {
-			fmt.Fprintf(writer, "checking filer %s to master %s ... ", string(filer), string(master))
+			r.begin(writer, "filer", filer, "master", master)
 			err := pb.WithFilerClient(false, 0, filer, commandEnv.option.GrpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
 				pong, err := client.Ping(context.Background(), &filer_pb.PingRequest{
 					Target:     string(master),
 					TargetType: cluster.MasterType,
 				})
 				if err == nil {
-					printTiming(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
+					r.ok(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
 				}
 				return err
 			})
 			if err != nil {
-				fmt.Fprintf(writer, "%v\n", err)
+				r.fail(writer, err)
 			}
 		}
 	}
@@ -180,19 +247,19 @@ func (c *commandClusterCheck) Do(args []string, commandEnv *CommandEnv, writer i
 	// check from filers to volume servers
 	for _, filer := range filers {
 		for _, volumeServer := range volumeServers {
-			fmt.Fprintf(writer, "checking filer %s to volume server %s ... ", string(filer), string(volumeServer))
+			r.begin(writer, "filer", filer, "volume server", volumeServer)
 			err := pb.WithFilerClient(false, 0, filer, commandEnv.option.GrpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
 				pong, err := client.Ping(context.Background(), &filer_pb.PingRequest{
 					Target:     string(volumeServer),
 					TargetType: cluster.VolumeServerType,
 				})
 				if err == nil {
-					printTiming(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
+					r.ok(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
 				}
 				return err
 			})
 			if err != nil {
-				fmt.Fprintf(writer, "%v\n", err)
+				r.fail(writer, err)
 			}
 		}
 	}
@@ -203,19 +270,19 @@ func (c *commandClusterCheck) Do(args []string, commandEnv *CommandEnv, writer i
 			if sourceVolumeServer == targetVolumeServer {
 				continue
 			}
-			fmt.Fprintf(writer, "checking volume server %s to %s ... ", string(sourceVolumeServer), string(targetVolumeServer))
+			r.begin(writer, "volume server", sourceVolumeServer, "volume server", targetVolumeServer)
 			err := pb.WithVolumeServerClient(false, sourceVolumeServer, commandEnv.option.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
 				pong, err := client.Ping(context.Background(), &volume_server_pb.PingRequest{
 					Target:     string(targetVolumeServer),
 					TargetType: cluster.VolumeServerType,
 				})
 				if err == nil {
-					printTiming(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
+					r.ok(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
 				}
 				return err
 			})
 			if err != nil {
-				fmt.Fprintf(writer, "%v\n", err)
+				r.fail(writer, err)
 			}
 		}
 	}
@@ -223,28 +290,62 @@ func (c *commandClusterCheck) Do(args []string, commandEnv *CommandEnv, writer i
 	// check between filers, and need to connect to itself
 	for _, sourceFiler := range filers {
 		for _, targetFiler := range filers {
-			fmt.Fprintf(writer, "checking filer %s to %s ... ", string(sourceFiler), string(targetFiler))
+			r.begin(writer, "filer", sourceFiler, "filer", targetFiler)
 			err := pb.WithFilerClient(false, 0, sourceFiler, commandEnv.option.GrpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
 				pong, err := client.Ping(context.Background(), &filer_pb.PingRequest{
 					Target:     string(targetFiler),
 					TargetType: cluster.FilerType,
 				})
 				if err == nil {
-					printTiming(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
+					r.ok(writer, pong.StartTimeNs, pong.RemoteTimeNs, pong.StopTimeNs)
 				}
 				return err
 			})
 			if err != nil {
-				fmt.Fprintf(writer, "%v\n", err)
+				r.fail(writer, err)
 			}
 		}
 	}
+}
 
-	return nil
+type clusterCheckResult struct {
+	Masters       []pb.ServerAddress     `json:"masters"`
+	Filers        []pb.ServerAddress     `json:"filers"`
+	VolumeServers []pb.ServerAddress     `json:"volumeServers"`
+	Checks        []*clusterCheck        `json:"checks"`
+	Findings      []*clusterCheckFinding `json:"findings"`
+}
+
+// clusterCheck is the result of one server pinging another
+type clusterCheck struct {
+	SourceType   string           `json:"sourceType"`
+	Source       pb.ServerAddress `json:"source"`
+	TargetType   string           `json:"targetType"`
+	Target       pb.ServerAddress `json:"target"`
+	Ok           bool             `json:"ok"`
+	RoundTripMs  float32          `json:"roundTripMs,omitempty"`
+	ClockDeltaMs float32          `json:"clockDeltaMs,omitempty"`
+	Error        string           `json:"error,omitempty"`
+}
+
+func (r *clusterCheckResult) begin(writer io.Writer, sourceType string, source pb.ServerAddress, targetType string, target pb.ServerAddress) {
+	if sourceType == targetType {
+		fmt.Fprintf(writer, "checking %s %s to %s ... ", sourceType, string(source), string(target))
+	} else {
+		fmt.Fprintf(writer, "checking %s %s to %s %s ... ", sourceType, string(source), targetType, string(target))
+	}
+	r.Checks = append(r.Checks, &clusterCheck{SourceType: sourceType, Source: source, TargetType: targetType, Target: target})
+}
+
+func (r *clusterCheckResult) ok(writer io.Writer, startNs, remoteNs, stopNs int64) {
+	check := r.Checks[len(r.Checks)-1]
+	check.Ok = true
+	check.RoundTripMs = float32(stopNs-startNs) / 1000000
+	check.ClockDeltaMs = float32(remoteNs-(startNs+stopNs)/2) / 1000000
+	fmt.Fprintf(writer, "ok round trip %.3fms clock delta %.3fms\n", check.RoundTripMs, check.ClockDeltaMs)
 }
 
-func printTiming(writer io.Writer, startNs, remoteNs, stopNs int64) {
-	roundTripTimeMs := float32(stopNs-startNs) / 1000000
-	deltaTimeMs := float32(remoteNs-(startNs+stopNs)/2) / 1000000
-	fmt.Fprintf(writer, "ok round trip %.3fms clock delta %.3fms\n", roundTripTimeMs, deltaTimeMs)
+func (r *clusterCheckResult) fail(writer io.Writer, err error) {
+	r.Checks[len(r.Checks)-1].Error = err.Error()
+	fmt.Fprintf(writer, "%v\n", err)
 }
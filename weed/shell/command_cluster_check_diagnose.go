@@ -0,0 +1,259 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+)
+
+const (
+	severityCritical = "critical" // the data is at risk, or a server is not working
+	severityWarning  = "warning"
+	severityInfo     = "info"
+)
+
+var severityOrder = map[string]int{severityCritical: 0, severityWarning: 1, severityInfo: 2}
+
+// clusterCheckFinding is one problem found by cluster.check
+type clusterCheckFinding struct {
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Subject  string `json:"subject"`
+	Message  string `json:"message"`
+}
+
+func (r *clusterCheckResult) report(severity, check, subject, message string) {
+	r.Findings = append(r.Findings, &clusterCheckFinding{Severity: severity, Check: check, Subject: subject, Message: message})
+}
+
+// printFindings prints the findings, the most severe first, and returns the count of the critical ones
+func (r *clusterCheckResult) printFindings(writer io.Writer) (critical int) {
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		return severityOrder[r.Findings[i].Severity] < severityOrder[r.Findings[j].Severity]
+	})
+	counts := make(map[string]int)
+	for _, f := range r.Findings {
+		counts[f.Severity]++
+		fmt.Fprintf(writer, "[%s] %s %s: %s\n", f.Severity, f.Check, f.Subject, f.Message)
+	}
+	fmt.Fprintf(writer, "%d critical, %d warning, %d info findings\n", counts[severityCritical], counts[severityWarning], counts[severityInfo])
+	return counts[severityCritical]
+}
+
+// diagnoseConfiguration checks the disk types, the volume slots, and whether the topology can satisfy the default replication
+func (r *clusterCheckResult) diagnoseConfiguration(t *master_pb.TopologyInfo, defaultReplication string) {
+	emptyDiskTypeDiskInfo, emptyDiskTypeFound := t.DiskInfos[""]
+	hddDiskTypeDiskInfo, hddDiskTypeFound := t.DiskInfos["hdd"]
+	if !emptyDiskTypeFound && !hddDiskTypeFound {
+		r.report(severityCritical, "configuration", "cluster", "no hdd disk type")
+	} else if emptyDiskTypeFound && emptyDiskTypeDiskInfo.MaxVolumeCount == 0 || hddDiskTypeFound && hddDiskTypeDiskInfo.MaxVolumeCount == 0 {
+		r.report(severityCritical, "configuration", "cluster", "no volume slots on the hdd disks")
+	}
+
+	eachDataNode(t, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+		subject := "volume server " + dn.Id
+		var maxVolumeCount, freeVolumeCount int64
+		for _, diskInfo := range dn.DiskInfos {
+			maxVolumeCount += diskInfo.MaxVolumeCount
+			freeVolumeCount += diskInfo.FreeVolumeCount
+		}
+		switch {
+		case maxVolumeCount == 0:
+			r.report(severityWarning, "configuration", subject, "no volume slots configured")
+		case freeVolumeCount <= 0:
+			r.report(severityWarning, "configuration", subject, fmt.Sprintf("all %d volume slots are used", maxVolumeCount))
+		}
+		if dn.IsInMaintenance {
+			r.report(severityInfo, "configuration", subject, "in maintenance")
+		}
+	})
+
+	if defaultReplication == "" {
+		return
+	}
+	rp, err := super_block.NewReplicaPlacementFromString(defaultReplication)
+	if err != nil {
+		r.report(severityCritical, "configuration", "cluster", fmt.Sprintf("parse the default replication %s: %v", defaultReplication, err))
+		return
+	}
+	if problem := unsatisfiedReplication(t, rp); problem != "" {
+		r.report(severityWarning, "configuration", "cluster", fmt.Sprintf("the default replication %s %s", rp, problem))
+	}
+}
+
+// unsatisfiedReplication tells what the topology misses to place the replicas, or empty if nothing
+func unsatisfiedReplication(t *master_pb.TopologyInfo, rp *super_block.ReplicaPlacement) string {
+	maxRacks, maxNodes := 0, 0
+	for _, dc := range t.DataCenterInfos {
+		if len(dc.RackInfos) > maxRacks {
+			maxRacks = len(dc.RackInfos)
+		}
+		for _, rack := range dc.RackInfos {
+			if len(rack.DataNodeInfos) > maxNodes {
+				maxNodes = len(rack.DataNodeInfos)
+			}
+		}
+	}
+	switch {
+	case len(t.DataCenterInfos) < rp.DiffDataCenterCount+1:
+		return fmt.Sprintf("needs %d data centers, but the cluster has %d", rp.DiffDataCenterCount+1, len(t.DataCenterInfos))
+	case maxRacks < rp.DiffRackCount+1:
+		return fmt.Sprintf("needs %d racks in a data center, but the cluster has at most %d", rp.DiffRackCount+1, maxRacks)
+	case maxNodes < rp.SameRackCount+1:
+		return fmt.Sprintf("needs %d volume servers in a rack, but the cluster has at most %d", rp.SameRackCount+1, maxNodes)
+	}
+	return ""
+}
+
+// diagnoseVolumes checks the replicas of each volume against its replica placement, and the read only replicas
+func (r *clusterCheckResult) diagnoseVolumes(t *master_pb.TopologyInfo, deferredVolumeIds map[uint32]bool) {
+	volumeReplicas, _ := collectVolumeReplicaLocations(t)
+	var vids []uint32
+	for vid := range volumeReplicas {
+		vids = append(vids, vid)
+	}
+	slices.Sort(vids)
+
+	for _, vid := range vids {
+		replicas := volumeReplicas[vid]
+		subject := fmt.Sprintf("volume %d", vid)
+		var nodes, readOnlyNodes []string
+		for _, replica := range replicas {
+			nodes = append(nodes, replica.location.dataNode.Id)
+			if replica.info.ReadOnly {
+				readOnlyNodes = append(readOnlyNodes, replica.location.dataNode.Id)
+			}
+		}
+		replicaPlacement, _ := super_block.NewReplicaPlacementFromByte(byte(replicas[0].info.ReplicaPlacement))
+		copyCount := replicaPlacement.GetCopyCount()
+		switch {
+		case copyCount > len(replicas) && deferredVolumeIds[vid]:
+			r.report(severityInfo, "replication", subject, fmt.Sprintf("replication %s misses the replicas on the volume servers in maintenance", replicaPlacement))
+		case copyCount > len(replicas):
+			severity := severityWarning
+			if len(replicas) == 1 {
+				severity = severityCritical
+			}
+			r.report(severity, "replication", subject, fmt.Sprintf("under replicated, replication %s with %d of %d replicas on %v", replicaPlacement, len(replicas), copyCount, nodes))
+		case copyCount < len(replicas):
+			r.report(severityInfo, "replication", subject, fmt.Sprintf("over replicated, replication %s with %d replicas on %v", replicaPlacement, len(replicas), nodes))
+		case isMisplaced(replicas, replicaPlacement):
+			r.report(severityWarning, "replication", subject, fmt.Sprintf("the replicas on %v violate the replication %s", nodes, replicaPlacement))
+		}
+
+		switch {
+		case len(readOnlyNodes) == len(replicas):
+			r.report(severityInfo, "read_only", subject, fmt.Sprintf("read only on %v", readOnlyNodes))
+		case len(readOnlyNodes) > 0:
+			r.report(severityWarning, "read_only", subject, fmt.Sprintf("read only on %v, but writable on the other replicas", readOnlyNodes))
+		}
+	}
+}
+
+// diagnoseEcShards checks each ec volume has all its shards, or at least enough to recover the data
+func (r *clusterCheckResult) diagnoseEcShards(t *master_pb.TopologyInfo) {
+	type ecVolume struct {
+		shards erasure_coding.ShardBits
+		scheme erasure_coding.Scheme
+	}
+	ecVolumes := make(map[uint32]*ecVolume)
+	eachDataNode(t, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+		for _, diskInfo := range dn.DiskInfos {
+			for _, shardInfo := range diskInfo.EcShardInfos {
+				v, found := ecVolumes[shardInfo.Id]
+				if !found {
+					v = &ecVolume{scheme: erasure_coding.DefaultScheme}
+					ecVolumes[shardInfo.Id] = v
+				}
+				v.shards = v.shards.Plus(erasure_coding.ShardBits(shardInfo.EcIndexBits))
+				if shardInfo.DataShards > 0 {
+					v.scheme = erasure_coding.NewScheme(shardInfo.DataShards, shardInfo.ParityShards)
+				}
+			}
+		}
+	})
+	var vids []uint32
+	for vid := range ecVolumes {
+		vids = append(vids, vid)
+	}
+	slices.Sort(vids)
+
+	for _, vid := range vids {
+		v := ecVolumes[vid]
+		var missing []erasure_coding.ShardId
+		for id := 0; id < v.scheme.TotalShards(); id++ {
+			if !v.shards.HasShardId(erasure_coding.ShardId(id)) {
+				missing = append(missing, erasure_coding.ShardId(id))
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		subject := fmt.Sprintf("ec volume %d", vid)
+		if present := v.scheme.TotalShards() - len(missing); present < v.scheme.DataShards {
+			r.report(severityCritical, "ec_shards", subject, fmt.Sprintf("not recoverable, missing shards %v of the %s scheme", missing, v.scheme))
+		} else {
+			r.report(severityWarning, "ec_shards", subject, fmt.Sprintf("missing shards %v of the %s scheme", missing, v.scheme))
+		}
+	}
+}
+
+// diagnoseClockSkew checks the clock deltas measured by the pings
+func (r *clusterCheckResult) diagnoseClockSkew(maxSkew time.Duration) {
+	maxSkewMs := float64(maxSkew) / float64(time.Millisecond)
+	for _, check := range r.Checks {
+		if check.Ok && math.Abs(float64(check.ClockDeltaMs)) > maxSkewMs {
+			r.report(severityWarning, "clock_skew", fmt.Sprintf("%s %s", check.TargetType, check.Target),
+				fmt.Sprintf("the clock is %.3fms apart from %s %s", check.ClockDeltaMs, check.SourceType, check.Source))
+		}
+	}
+}
+
+func addVersion(versions map[string][]string, version, server string) {
+	if version != "" {
+		versions[version] = append(versions[version], server)
+	}
+}
+
+// diagnoseVersions checks all the servers run the same version
+func (r *clusterCheckResult) diagnoseVersions(versions map[string][]string) {
+	if len(versions) <= 1 {
+		return
+	}
+	var descriptions []string
+	for version, servers := range versions {
+		slices.Sort(servers)
+		descriptions = append(descriptions, fmt.Sprintf("%s on %s", version, strings.Join(servers, ", ")))
+	}
+	slices.Sort(descriptions)
+	r.report(severityWarning, "version", "cluster", fmt.Sprintf("%d versions: %s", len(versions), strings.Join(descriptions, "; ")))
+}
+
+// checkFilerStore reads a key through the filer, to check the filer store is reachable
+func (r *clusterCheckResult) checkFilerStore(commandEnv *CommandEnv, filer pb.ServerAddress) {
+	err := pb.WithFilerClient(false, 0, filer, commandEnv.option.GrpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.KvGet(context.Background(), &filer_pb.KvGetRequest{Key: []byte("cluster.check")})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("the filer store: %s", resp.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		r.report(severityCritical, "filer_store", "filer "+string(filer), err.Error())
+	}
+}
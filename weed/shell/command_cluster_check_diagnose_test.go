@@ -0,0 +1,81 @@
+package shell
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+func TestClusterCheckDiagnose(t *testing.T) {
+	volume := func(id uint32, replication uint32, readOnly bool) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{Id: id, ReplicaPlacement: replication, ReadOnly: readOnly}
+	}
+	ecShards := func(id uint32, shardIds ...uint32) *master_pb.VolumeEcShardInformationMessage {
+		var bits uint32
+		for _, shardId := range shardIds {
+			bits |= 1 << shardId
+		}
+		return &master_pb.VolumeEcShardInformationMessage{Id: id, EcIndexBits: bits}
+	}
+	node := func(id string, maxVolumeCount int64, volumes []*master_pb.VolumeInformationMessage, shards ...*master_pb.VolumeEcShardInformationMessage) *master_pb.DataNodeInfo {
+		return &master_pb.DataNodeInfo{Id: id, DiskInfos: map[string]*master_pb.DiskInfo{
+			"": {MaxVolumeCount: maxVolumeCount, FreeVolumeCount: maxVolumeCount - int64(len(volumes)), VolumeInfos: volumes, EcShardInfos: shards},
+		}}
+	}
+	a := node("a", 10, []*master_pb.VolumeInformationMessage{volume(1, 1, false), volume(2, 1, false), volume(3, 10, false), volume(4, 0, false), volume(5, 1, true), volume(7, 1, false)},
+		ecShards(10, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9), ecShards(11, 0, 1, 2, 3, 4, 5, 6, 7, 8))
+	b := node("b", 10, []*master_pb.VolumeInformationMessage{volume(2, 1, false), volume(3, 10, false), volume(5, 1, false)},
+		ecShards(10, 10, 11, 12))
+	c := node("c", 0, []*master_pb.VolumeInformationMessage{volume(4, 0, false), volume(6, 0, true)})
+	topologyInfo := &master_pb.TopologyInfo{
+		DiskInfos: map[string]*master_pb.DiskInfo{"": {MaxVolumeCount: 20}},
+		DataCenterInfos: []*master_pb.DataCenterInfo{{Id: "dc1", RackInfos: []*master_pb.RackInfo{
+			{Id: "rack1", DataNodeInfos: []*master_pb.DataNodeInfo{a, b}},
+			{Id: "rack2", DataNodeInfos: []*master_pb.DataNodeInfo{c}},
+		}}},
+	}
+
+	r := &clusterCheckResult{Checks: []*clusterCheck{
+		{SourceType: "master", Source: "m", TargetType: "volume server", Target: "a", Ok: true, ClockDeltaMs: 1500},
+		{SourceType: "master", Source: "m", TargetType: "volume server", Target: "b", Ok: true, ClockDeltaMs: -20},
+	}}
+	r.diagnoseConfiguration(topologyInfo, "200")
+	r.diagnoseVolumes(topologyInfo, map[uint32]bool{7: true})
+	r.diagnoseEcShards(topologyInfo)
+	r.diagnoseClockSkew(time.Second)
+	r.diagnoseVersions(map[string][]string{"3.57": {"master m", "volume server a"}, "3.56": {"volume server b"}})
+
+	expected := map[string]string{
+		"configuration volume server c": severityWarning,
+		"configuration cluster":         severityWarning,
+		"replication volume 1":          severityCritical,
+		"replication volume 3":          severityWarning,
+		"replication volume 4":          severityInfo,
+		"replication volume 7":          severityInfo,
+		"read_only volume 5":            severityWarning,
+		"read_only volume 6":            severityInfo,
+		"ec_shards ec volume 10":        severityWarning,
+		"ec_shards ec volume 11":        severityCritical,
+		"clock_skew volume server a":    severityWarning,
+		"version cluster":               severityWarning,
+	}
+	found := make(map[string]string)
+	for _, f := range r.Findings {
+		found[f.Check+" "+f.Subject] = f.Severity
+	}
+	for key, severity := range expected {
+		if found[key] != severity {
+			t.Errorf("%s: severity %q, expected %q", key, found[key], severity)
+		}
+	}
+	if len(found) != len(expected) {
+		t.Errorf("findings %v, expected %v", found, expected)
+	}
+
+	var buf bytes.Buffer
+	if critical := r.printFindings(&buf); critical != 2 || r.Findings[0].Severity != severityCritical {
+		t.Errorf("%d critical findings:\n%s", critical, buf.String())
+	}
+}
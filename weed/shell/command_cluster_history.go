@@ -0,0 +1,104 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandClusterHistory{})
+}
+
+type commandClusterHistory struct {
+}
+
+func (c *commandClusterHistory) Name() string {
+	return "cluster.history"
+}
+
+func (c *commandClusterHistory) Help() string {
+	return `list the topology changes recorded by the masters
+
+	cluster.history [-since 24h] [-until 2006-01-02T15:04:05Z] [-node <host>:<port>] [-volumeId <id>] [-collection <name>] [-types node_down,volumes_deleted] [-limit 100]
+
+	The leader records the volume servers joining and leaving, the volumes added, deleted and changed,
+	including the read only state and the replication, the ec shards, and the quota states of the collections.
+	The changes made by the leader itself carry the cause, e.g. the drain, rebalance, repair or lifecycle,
+	and the other changes are reported by the volume servers, e.g. by the volume.* commands.
+	The histories of all the masters are merged, each recorded while it was the leader,
+	and kept for "weed master -history.retention".
+
+	The types are node_up, node_down, volumes_added, volumes_deleted, volumes_changed, ec_shards_changed, and quota_state_changed.
+`
+}
+
+func (c *commandClusterHistory) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	historyCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	since := historyCommand.Duration("since", 24*time.Hour, "list the changes of this long ago till -until")
+	until := historyCommand.String("until", "", "the end time in RFC3339, empty for now")
+	node := historyCommand.String("node", "", "the volume server <host>:<port>")
+	volumeId := historyCommand.Uint("volumeId", 0, "the volume id")
+	collection := historyCommand.String("collection", "", "the collection")
+	types := historyCommand.String("types", "", "comma separated change types, empty for all")
+	limit := historyCommand.Int("limit", 1000, "list the latest changes only, 0 for all")
+	if err = historyCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	untilTime := time.Now()
+	if *until != "" {
+		if untilTime, err = time.Parse(time.RFC3339, *until); err != nil {
+			return fmt.Errorf("parse -until %s: %v", *until, err)
+		}
+	}
+	req := &master_pb.ListTopologyHistoryRequest{
+		SinceNs:    untilTime.Add(-*since).UnixNano(),
+		UntilNs:    untilTime.UnixNano(),
+		Node:       *node,
+		VolumeId:   uint32(*volumeId),
+		Collection: *collection,
+		Limit:      int32(*limit),
+	}
+	for _, t := range strings.Split(*types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			req.Types = append(req.Types, t)
+		}
+	}
+
+	return commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		resp, listErr := client.ListTopologyHistory(context.Background(), req)
+		if listErr != nil {
+			return listErr
+		}
+		for _, master := range resp.UnreachableMasters {
+			fmt.Fprintf(writer, "the history of master %s is not included, it is unreachable\n", master)
+		}
+		if len(resp.Changes) == 0 {
+			fmt.Fprintf(writer, "no topology changes\n")
+		}
+		for _, change := range resp.Changes {
+			fmt.Fprintf(writer, "%s %s", time.Unix(0, change.TsNs).Format(time.RFC3339), change.Type)
+			if change.Node != "" {
+				fmt.Fprintf(writer, " node %s in %s:%s", change.Node, change.DataCenter, change.Rack)
+			}
+			if change.VolumeId != 0 {
+				fmt.Fprintf(writer, " volume %d", change.VolumeId)
+			}
+			if change.Collection != "" {
+				fmt.Fprintf(writer, " collection %s", change.Collection)
+			}
+			if change.Detail != "" {
+				fmt.Fprintf(writer, ", %s", change.Detail)
+			}
+			fmt.Fprintf(writer, ", cause: %s\n", change.Cause)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,72 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandClusterLoad{})
+}
+
+type commandClusterLoad struct {
+}
+
+func (c *commandClusterLoad) Name() string {
+	return "cluster.load"
+}
+
+func (c *commandClusterLoad) Help() string {
+	return `show the capacity and the request rates by data center, rack, and volume server
+
+	cluster.load [-nodes]
+
+	The master rolls up the request rates reported by the volume servers in their heartbeats.
+	The same report is served as JSON on http://<master>/cluster/load for the autoscalers and the dashboards.
+`
+}
+
+func (c *commandClusterLoad) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	loadCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	showNodes := loadCommand.Bool("nodes", false, "also show each volume server")
+	if err = loadCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	return commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		resp, loadErr := client.GetClusterLoad(context.Background(), &master_pb.GetClusterLoadRequest{})
+		if loadErr != nil {
+			return loadErr
+		}
+		printClusterLoad(writer, "", "cluster", resp.Load)
+		for _, dc := range resp.DataCenters {
+			printClusterLoad(writer, "  ", "data center "+dc.Load.Id, dc.Load)
+			for _, rack := range dc.Racks {
+				printClusterLoad(writer, "    ", "rack "+rack.Load.Id, rack.Load)
+				if !*showNodes {
+					continue
+				}
+				for _, node := range rack.Nodes {
+					printClusterLoad(writer, "      ", node.Id, node)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func printClusterLoad(writer io.Writer, indent, name string, load *master_pb.ClusterLoad) {
+	fmt.Fprintf(writer, "%s%s: %d volume servers, %d/%d volumes, %d ec shards, %s of %s used\n", indent, name,
+		load.NodeCount, load.VolumeCount, load.MaxVolumeCount, load.EcShardCount,
+		util.BytesToHumanReadable(load.UsedBytes), util.BytesToHumanReadable(load.CapacityBytes))
+	fmt.Fprintf(writer, "%s  %.1f reads/s %s/s, %.1f writes/s %s/s, %.1f deletes/s, %.1f errors/s\n", indent,
+		load.ReadsPerSecond, util.BytesToHumanReadable(uint64(load.ReadBytesPerSecond)),
+		load.WritesPerSecond, util.BytesToHumanReadable(uint64(load.WrittenBytesPerSecond)),
+		load.DeletesPerSecond, load.ErrorsPerSecond)
+}
@@ -0,0 +1,65 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"io"
+)
+
+func init() {
+	Commands = append(Commands, &commandRaftServerDemote{})
+}
+
+type commandRaftServerDemote struct {
+}
+
+func (c *commandRaftServerDemote) Name() string {
+	return "cluster.raft.demote"
+}
+
+func (c *commandRaftServerDemote) Help() string {
+	return `demote a voter master to a non-voting learner of the raft cluster
+
+	Example:
+		cluster.raft.demote -id <server_name>
+
+	The learner keeps replicating the state, e.g. before replacing its hardware,
+	and can be removed with cluster.raft.remove without changing the quorum.
+	The leader is not demoted; restart it or send it SIGHUP to transfer the leadership first.
+	It is refused if the remaining connected voters would not make a quorum, unless -force is set.
+`
+}
+
+func (c *commandRaftServerDemote) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	raftServerDemoteCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	serverId := raftServerDemoteCommand.String("id", "", "server id")
+	force := raftServerDemoteCommand.Bool("force", false, "demote even without the quorum checks")
+	if err = raftServerDemoteCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if *serverId == "" {
+		return fmt.Errorf("empty server id")
+	}
+
+	err = commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		resp, err := client.RaftDemoteServer(context.Background(), &master_pb.RaftDemoteServerRequest{
+			Id:    *serverId,
+			Force: *force,
+		})
+		if err != nil {
+			return fmt.Errorf("raft demote server: %v", err)
+		}
+		fmt.Fprintf(writer, "demoted server %s, with %d voters\n", *serverId, resp.VoterCount)
+		if resp.VoterCount%2 == 0 {
+			fmt.Fprintf(writer, "the number of voters is even, demote another voter or promote a learner\n")
+		}
+		return nil
+	})
+
+	return err
+
+}
@@ -0,0 +1,66 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"io"
+)
+
+func init() {
+	Commands = append(Commands, &commandRaftServerPromote{})
+}
+
+type commandRaftServerPromote struct {
+}
+
+func (c *commandRaftServerPromote) Name() string {
+	return "cluster.raft.promote"
+}
+
+func (c *commandRaftServerPromote) Help() string {
+	return `promote a non-voting learner master to a voter of the raft cluster
+
+	Example:
+		cluster.raft.promote -id <server_name>
+
+	The learner already has the replicated state, so it votes right away.
+	It is refused if the learner is not connected to the leader, or the connected voters
+	would not make a quorum, unless -force is set.
+	Promote the learners two at a time to keep an odd number of voters, e.g. from 3 to 5 masters.
+	Started with -learners, the masters join as learners, see cluster.raft.ps for the suffrage.
+`
+}
+
+func (c *commandRaftServerPromote) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	raftServerPromoteCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	serverId := raftServerPromoteCommand.String("id", "", "server id")
+	force := raftServerPromoteCommand.Bool("force", false, "promote even without the quorum checks")
+	if err = raftServerPromoteCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if *serverId == "" {
+		return fmt.Errorf("empty server id")
+	}
+
+	err = commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		resp, err := client.RaftPromoteServer(context.Background(), &master_pb.RaftPromoteServerRequest{
+			Id:    *serverId,
+			Force: *force,
+		})
+		if err != nil {
+			return fmt.Errorf("raft promote server: %v", err)
+		}
+		fmt.Fprintf(writer, "promoted server %s, with %d voters\n", *serverId, resp.VoterCount)
+		if resp.VoterCount%2 == 0 {
+			fmt.Fprintf(writer, "the number of voters is even, promote another learner or demote a voter\n")
+		}
+		return nil
+	})
+
+	return err
+
+}
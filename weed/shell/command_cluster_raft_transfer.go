@@ -0,0 +1,56 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandRaftTransferLeadership{})
+}
+
+type commandRaftTransferLeadership struct {
+}
+
+func (c *commandRaftTransferLeadership) Name() string {
+	return "cluster.raft.transfer"
+}
+
+func (c *commandRaftTransferLeadership) Help() string {
+	return `transfer the raft leadership to another master
+
+	Example:
+		cluster.raft.transfer [-id <server_name>]
+
+	Without -id, the leadership goes to any up-to-date voter.
+	It is refused if the chosen master is not a voter, or not connected to the leader.
+	In a rolling upgrade, restart the followers first, then transfer the leadership and restart the old leader.
+	The masters also transfer the leadership on shutdown, after draining the assign and lookup requests
+	in flight for up to "weed master -shutdown.drainTimeout".
+`
+}
+
+func (c *commandRaftTransferLeadership) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	raftTransferCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	serverId := raftTransferCommand.String("id", "", "server id of the new leader, empty for any up-to-date voter")
+	if err = raftTransferCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	return commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		resp, err := client.RaftTransferLeadership(context.Background(), &master_pb.RaftTransferLeadershipRequest{
+			Id: *serverId,
+		})
+		if err != nil {
+			return fmt.Errorf("raft transfer leadership: %v", err)
+		}
+		fmt.Fprintf(writer, "transferred the leadership to %s\n", resp.Leader)
+		return nil
+	})
+
+}
@@ -0,0 +1,134 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandClusterSimulate{})
+}
+
+type commandClusterSimulate struct {
+}
+
+func (c *commandClusterSimulate) Name() string {
+	return "cluster.simulate"
+}
+
+func (c *commandClusterSimulate) Help() string {
+	return `simulate the placement and the data movement after adding or removing volume servers, or changing the replication
+
+	cluster.simulate [-addNodes <dc>:<rack>:<count>:<maxVolumeCount>[:<diskType>[:<weight>]],...]
+		[-removeRacks <dc>:<rack>,...] [-removeNodes <volume server url>,...] [-replication <collection>=<replication>,...]
+
+	// plan adding 4 volume servers with 100 volume slots each on a new rack
+	cluster.simulate -addNodes dc1:rack3:4:100
+	// plan replacing a rack, and replicating the logs on 2 racks
+	cluster.simulate -removeRacks dc1:rack1 -addNodes dc1:rack4:2:200 -replication logs=010
+
+	The master applies the change to a copy of the topology, deletes the replicas over the replica placement,
+	copies the missing replicas as the re-replication would, and moves the volumes as volume.balance would.
+	Nothing is changed in the cluster.
+`
+}
+
+func (c *commandClusterSimulate) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	simulateCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	addNodes := simulateCommand.String("addNodes", "", "comma separated groups of new volume servers, each as <dc>:<rack>:<count>:<maxVolumeCount>[:<diskType>[:<weight>]]")
+	removeRacks := simulateCommand.String("removeRacks", "", "comma separated racks to remove, each as <dc>:<rack>")
+	removeNodes := simulateCommand.String("removeNodes", "", "comma separated volume servers to remove")
+	replication := simulateCommand.String("replication", "", "comma separated collections with their new replication, each as <collection>=<replication>")
+	if err = simulateCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	req := &master_pb.SimulatePlacementRequest{
+		RemoveRacks: util.StringSplit(*removeRacks, ","),
+		RemoveNodes: util.StringSplit(*removeNodes, ","),
+	}
+	for _, group := range util.StringSplit(*addNodes, ",") {
+		newNodes, parseErr := parseSimulatedNodes(group)
+		if parseErr != nil {
+			return parseErr
+		}
+		req.AddNodes = append(req.AddNodes, newNodes)
+	}
+	for _, item := range util.StringSplit(*replication, ",") {
+		collection, rp, found := strings.Cut(item, "=")
+		if !found {
+			return fmt.Errorf("expect <collection>=<replication> in %q", item)
+		}
+		req.Replications = append(req.Replications, &master_pb.SimulatePlacementRequest_CollectionReplication{
+			Collection:  collection,
+			Replication: rp,
+		})
+	}
+
+	return commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		resp, simulateErr := client.SimulatePlacement(context.Background(), req)
+		if simulateErr != nil {
+			return simulateErr
+		}
+		for _, node := range resp.Nodes {
+			var state string
+			switch {
+			case node.IsAdded:
+				state = " (added)"
+			case node.IsRemoved:
+				state = " (removed)"
+			}
+			fmt.Fprintf(writer, "%s %s %s%s: %d => %d of %d volumes\n", node.DataCenter, node.Rack, node.Url, state,
+				node.VolumeCountBefore, node.VolumeCountAfter, node.MaxVolumeCount)
+		}
+		fmt.Fprintf(writer, "copy %d missing replicas, %s\n", resp.CopiedReplicaCount, util.BytesToHumanReadable(resp.CopiedBytes))
+		fmt.Fprintf(writer, "move %d volumes to balance, %s\n", resp.MovedVolumeCount, util.BytesToHumanReadable(resp.MovedBytes))
+		fmt.Fprintf(writer, "delete %d extra replicas, %s\n", resp.DeletedReplicaCount, util.BytesToHumanReadable(resp.DeletedBytes))
+		if resp.UnplacedReplicaCount > 0 {
+			fmt.Fprintf(writer, "%d missing replicas fit no volume server by capacity and replica placement\n", resp.UnplacedReplicaCount)
+		}
+		if len(resp.LostVolumeIds) > 0 {
+			fmt.Fprintf(writer, "volumes %v are only on the removed volume servers\n", resp.LostVolumeIds)
+		}
+		return nil
+	})
+}
+
+// parseSimulatedNodes parses <dc>:<rack>:<count>:<maxVolumeCount>[:<diskType>[:<weight>]]
+func parseSimulatedNodes(s string) (*master_pb.SimulatePlacementRequest_NewNodes, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 4 || len(parts) > 6 {
+		return nil, fmt.Errorf("expect <dc>:<rack>:<count>:<maxVolumeCount>[:<diskType>[:<weight>]] in %q", s)
+	}
+	count, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("count in %q: %v", s, err)
+	}
+	maxVolumeCount, err := strconv.ParseUint(parts[3], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("max volume count in %q: %v", s, err)
+	}
+	newNodes := &master_pb.SimulatePlacementRequest_NewNodes{
+		DataCenter:     parts[0],
+		Rack:           parts[1],
+		Count:          uint32(count),
+		MaxVolumeCount: uint32(maxVolumeCount),
+	}
+	if len(parts) > 4 {
+		newNodes.DiskType = parts[4]
+	}
+	if len(parts) > 5 {
+		if newNodes.Weight, err = strconv.ParseFloat(parts[5], 64); err != nil {
+			return nil, fmt.Errorf("weight in %q: %v", s, err)
+		}
+	}
+	return newNodes, nil
+}
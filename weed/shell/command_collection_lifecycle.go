@@ -0,0 +1,69 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandCollectionLifecycle{})
+}
+
+type commandCollectionLifecycle struct {
+}
+
+func (c *commandCollectionLifecycle) Name() string {
+	return "collection.lifecycle"
+}
+
+func (c *commandCollectionLifecycle) Help() string {
+	return `report the lifecycle actions of the collections aged by the master
+
+	collection.lifecycle [-collection <name>]
+
+	With "weed master -lifecycle.collections", the leader ages each volume of the collections by the time
+	since its last write: seals it as read only, moves it to the remote tier, and deletes it with all its replicas.
+	The actions due now are listed as a dry run, followed by the recently applied ones.
+	With "weed master -lifecycle.dryRun", the actions due are only reported, here and in the master log.
+`
+}
+
+func (c *commandCollectionLifecycle) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	lifecycleCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	collection := lifecycleCommand.String("collection", "", "the collection, empty for all the collections with a lifecycle")
+	if err = lifecycleCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	return commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		resp, listErr := client.ListLifecycleActions(context.Background(), &master_pb.ListLifecycleActionsRequest{
+			Collection: *collection,
+		})
+		if listErr != nil {
+			return listErr
+		}
+		if resp.DryRun {
+			fmt.Fprintf(writer, "the master only reports the lifecycle actions\n")
+		}
+		if len(resp.Planned) == 0 {
+			fmt.Fprintf(writer, "no lifecycle actions are due\n")
+		}
+		for _, a := range resp.Planned {
+			fmt.Fprintf(writer, "due: %s volume %d of collection %s, %s\n", a.Action, a.VolumeId, a.Collection, a.Reason)
+		}
+		for _, a := range resp.Applied {
+			fmt.Fprintf(writer, "%s: %s volume %d of collection %s, %s\n",
+				time.Unix(0, a.AppliedAtNs).Format(time.RFC3339), a.Action, a.VolumeId, a.Collection, a.Reason)
+			if a.Error != "" {
+				fmt.Fprintf(writer, "  error: %s\n", a.Error)
+			}
+		}
+		return nil
+	})
+}
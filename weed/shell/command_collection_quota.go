@@ -0,0 +1,102 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandCollectionQuota{})
+}
+
+type commandCollectionQuota struct {
+}
+
+func (c *commandCollectionQuota) Name() string {
+	return "collection.quota"
+}
+
+func (c *commandCollectionQuota) Help() string {
+	return `set, delete, or list the quotas of the collections enforced by the master
+
+	collection.quota -collection <name> [-maxVolumes=100] [-maxSize=10TiB] [-warn=80]
+	collection.quota -collection <name> -delete
+	collection.quota    # list the quotas with their usage
+
+	No more volumes are grown for the collection beyond -maxVolumes,
+	and no more writes are assigned once its volumes hold -maxSize logical bytes, without the deleted bytes.
+	The usage is refreshed every few seconds, and the topology watchers are told when a collection
+	reaches -warn percent or the limit of its quota.
+	The quotas are kept on the leader master, set them also with "weed master -quota.collections"
+	to keep them across the leader changes.
+`
+}
+
+func (c *commandCollectionQuota) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	quotaCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	collection := quotaCommand.String("collection", "", "the collection")
+	maxVolumes := quotaCommand.Int("maxVolumes", 0, "the max number of volumes, 0 for no limit")
+	maxSize := quotaCommand.String("maxSize", "", "the max logical bytes, e.g. 10TiB, empty for no limit")
+	warn := quotaCommand.Int("warn", 80, "warn once the usage reaches this percentage of either limit, 0 to not warn")
+	isDelete := quotaCommand.Bool("delete", false, "delete the quota")
+	if err = quotaCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if *collection == "" {
+		return listCollectionQuotas(commandEnv, writer)
+	}
+
+	var maxLogicalBytes uint64
+	if *maxSize != "" {
+		if maxLogicalBytes, err = util.ParseBytes(*maxSize); err != nil {
+			return fmt.Errorf("maxSize %s: %v", *maxSize, err)
+		}
+	}
+	if !*isDelete && *maxVolumes <= 0 && maxLogicalBytes == 0 {
+		return fmt.Errorf("set -maxVolumes or -maxSize, or -delete")
+	}
+
+	return commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		_, setErr := client.SetCollectionQuota(context.Background(), &master_pb.SetCollectionQuotaRequest{
+			Quota: &master_pb.CollectionQuota{
+				Collection:      *collection,
+				MaxVolumeCount:  int64(*maxVolumes),
+				MaxLogicalBytes: maxLogicalBytes,
+				WarnPercent:     int32(*warn),
+			},
+			Delete: *isDelete,
+		})
+		if setErr != nil {
+			return fmt.Errorf("quota of collection %s: %v", *collection, setErr)
+		}
+		if *isDelete {
+			fmt.Fprintf(writer, "deleted the quota of collection %s\n", *collection)
+			return nil
+		}
+		return listCollectionQuotas(commandEnv, writer)
+	})
+}
+
+func listCollectionQuotas(commandEnv *CommandEnv, writer io.Writer) error {
+	return commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		resp, err := client.ListCollectionQuotas(context.Background(), &master_pb.ListCollectionQuotasRequest{})
+		if err != nil {
+			return err
+		}
+		if len(resp.Quotas) == 0 {
+			fmt.Fprintf(writer, "no collections have quotas\n")
+		}
+		for _, q := range resp.Quotas {
+			fmt.Fprintf(writer, "collection:%q\tstate:%s\tvolumes:%d/%d\tlogicalBytes:%d/%d\twarn:%d%%\n",
+				q.Collection, q.State, q.VolumeCount, q.MaxVolumeCount, q.LogicalBytes, q.MaxLogicalBytes, q.WarnPercent)
+		}
+		return nil
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"math"
 
 	"github.com/seaweedfs/seaweedfs/weed/pb"
 	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
@@ -26,7 +27,10 @@ func (c *commandEcBalance) Name() string {
 func (c *commandEcBalance) Help() string {
 	return `balance all ec shards among all racks and volume servers
 
-	ec.balance [-c EACH_COLLECTION|<collection_name>] [-force] [-dataCenter <data_center>]
+	ec.balance [-c EACH_COLLECTION|<collection_name>] [-force] [-dataCenter <data_center>] [-checkpoint <file>]
+
+	With -checkpoint, the plan and the finished collections are recorded in the file, and running
+	again with the same file resumes an interrupted run. The file is removed once all are balanced.
 
 	Algorithm:
 
@@ -70,7 +74,7 @@ func (c *commandEcBalance) Help() string {
 	// move ec shards 
 	func doBalanceEcShardsWithinOneRack(volumeId, shards, rackId){
 		tracks volumeServer~volumeIdShardCount mapping
-		averageShardCount = len(shards) / numVolumeServers
+		averageShardCount = len(shards) / numVolumeServers, scaled by the -weight of each volume server
 		volumeServersOverAverage = volume servers with volumeId's ec shard counts > averageShardsPerEcRack
 		ecShardsToMove = select overflown ec shards from volumeServersOverAverage
 		for each ecShardsToMove {
@@ -81,7 +85,7 @@ func (c *commandEcBalance) Help() string {
 
 	// move ec shards while keeping shard distribution for the same volume unchanged or more even
 	func balanceEcRack(rack){
-		averageShardCount = total shards / numVolumeServers
+		averageShardCount = total shards / numVolumeServers, scaled by the -weight of each volume server
 		for hasMovedOneEcShard {
 			sort all volume servers ordered by the number of local ec shards
 			pick the volume server A with the lowest number of ec shards x
@@ -104,6 +108,7 @@ func (c *commandEcBalance) Do(args []string, commandEnv *CommandEnv, writer io.W
 	collection := balanceCommand.String("collection", "EACH_COLLECTION", "collection name, or \"EACH_COLLECTION\" for each collection")
 	dc := balanceCommand.String("dataCenter", "", "only apply the balancing for this dataCenter")
 	applyBalancing := balanceCommand.Bool("force", false, "apply the balancing plan")
+	checkpoint := balanceCommand.String("checkpoint", "", "the local file of the plan and progress, to resume an interrupted run")
 	if err = balanceCommand.Parse(args); err != nil {
 		return nil
 	}
@@ -124,29 +129,58 @@ func (c *commandEcBalance) Do(args []string, commandEnv *CommandEnv, writer io.W
 
 	racks := collectRacks(allEcNodes)
 
-	if *collection == "EACH_COLLECTION" {
-		collections, err := ListCollectionNames(commandEnv, false, true)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("balanceEcVolumes collections %+v\n", len(collections))
-		for _, c := range collections {
-			fmt.Printf("balanceEcVolumes collection %+v\n", c)
-			if err = balanceEcVolumes(commandEnv, c, allEcNodes, racks, *applyBalancing); err != nil {
+	cp, err := loadEcCheckpoint(*checkpoint, c.Name())
+	if err != nil {
+		return err
+	}
+	cp.resumed(writer)
+	if !*applyBalancing {
+		cp.fileName = ""
+	}
+	if len(cp.Steps) == 0 {
+		collections := []string{*collection}
+		if *collection == "EACH_COLLECTION" {
+			if collections, err = ListCollectionNames(commandEnv, false, true); err != nil {
 				return err
 			}
+			fmt.Fprintf(writer, "balanceEcVolumes collections %+v\n", len(collections))
+		}
+		for _, c := range collections {
+			cp.Steps = append(cp.Steps, &ecCheckpointStep{Collection: c})
 		}
-	} else {
-		if err = balanceEcVolumes(commandEnv, *collection, allEcNodes, racks, *applyBalancing); err != nil {
+		cp.Steps = append(cp.Steps, &ecCheckpointStep{Racks: true})
+		if err = cp.save(); err != nil {
 			return err
 		}
 	}
 
-	if err := balanceEcRacks(commandEnv, racks, *applyBalancing); err != nil {
-		return fmt.Errorf("balance ec racks: %v", err)
+	for i, step := range cp.Steps {
+		if step.Status == ecStepDone {
+			continue
+		}
+		if step.Racks {
+			fmt.Fprintf(writer, "[%d/%d] balance ec racks\n", i+1, len(cp.Steps))
+			if err = balanceEcRacks(commandEnv, writer, racks, *applyBalancing); err != nil {
+				err = fmt.Errorf("balance ec racks: %v", err)
+			}
+		} else {
+			fmt.Fprintf(writer, "[%d/%d] balance ec collection %s\n", i+1, len(cp.Steps), step.Collection)
+			err = balanceEcVolumes(commandEnv, writer, step.Collection, allEcNodes, racks, *applyBalancing)
+		}
+		if err != nil {
+			step.Status, step.Error = ecStepFailed, err.Error()
+			if saveErr := cp.save(); saveErr != nil {
+				fmt.Fprintf(writer, "%v\n", saveErr)
+			}
+			return err
+		}
+		step.Status, step.Error = ecStepDone, ""
+		if err = cp.save(); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return cp.finish()
 }
 
 func collectRacks(allEcNodes []*EcNode) map[RackId]*EcRack {
@@ -164,41 +198,41 @@ func collectRacks(allEcNodes []*EcNode) map[RackId]*EcRack {
 	return racks
 }
 
-func balanceEcVolumes(commandEnv *CommandEnv, collection string, allEcNodes []*EcNode, racks map[RackId]*EcRack, applyBalancing bool) error {
+func balanceEcVolumes(commandEnv *CommandEnv, writer io.Writer, collection string, allEcNodes []*EcNode, racks map[RackId]*EcRack, applyBalancing bool) error {
 
-	fmt.Printf("balanceEcVolumes %s\n", collection)
+	fmt.Fprintf(writer, "balanceEcVolumes %s\n", collection)
 
-	if err := deleteDuplicatedEcShards(commandEnv, allEcNodes, collection, applyBalancing); err != nil {
+	if err := deleteDuplicatedEcShards(commandEnv, writer, allEcNodes, collection, applyBalancing); err != nil {
 		return fmt.Errorf("delete duplicated collection %s ec shards: %v", collection, err)
 	}
 
-	if err := balanceEcShardsAcrossRacks(commandEnv, allEcNodes, racks, collection, applyBalancing); err != nil {
+	if err := balanceEcShardsAcrossRacks(commandEnv, writer, allEcNodes, racks, collection, applyBalancing); err != nil {
 		return fmt.Errorf("balance across racks collection %s ec shards: %v", collection, err)
 	}
 
-	if err := balanceEcShardsWithinRacks(commandEnv, allEcNodes, racks, collection, applyBalancing); err != nil {
+	if err := balanceEcShardsWithinRacks(commandEnv, writer, allEcNodes, racks, collection, applyBalancing); err != nil {
 		return fmt.Errorf("balance within racks collection %s ec shards: %v", collection, err)
 	}
 
 	return nil
 }
 
-func deleteDuplicatedEcShards(commandEnv *CommandEnv, allEcNodes []*EcNode, collection string, applyBalancing bool) error {
+func deleteDuplicatedEcShards(commandEnv *CommandEnv, writer io.Writer, allEcNodes []*EcNode, collection string, applyBalancing bool) error {
 	// vid => []ecNode
 	vidLocations := collectVolumeIdToEcNodes(allEcNodes, collection)
 	// deduplicate ec shards
 	for vid, locations := range vidLocations {
-		if err := doDeduplicateEcShards(commandEnv, collection, vid, locations, applyBalancing); err != nil {
+		if err := doDeduplicateEcShards(commandEnv, writer, collection, vid, locations, applyBalancing); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func doDeduplicateEcShards(commandEnv *CommandEnv, collection string, vid needle.VolumeId, locations []*EcNode, applyBalancing bool) error {
+func doDeduplicateEcShards(commandEnv *CommandEnv, writer io.Writer, collection string, vid needle.VolumeId, locations []*EcNode, applyBalancing bool) error {
 
 	// check whether this volume has ecNodes that are over average
-	shardToLocations := make([][]*EcNode, erasure_coding.TotalShardsCount)
+	shardToLocations := make([][]*EcNode, erasure_coding.MaxShardCount)
 	for _, ecNode := range locations {
 		shardBits := findEcVolumeShards(ecNode, vid)
 		for _, shardId := range shardBits.ShardIds() {
@@ -210,17 +244,17 @@ func doDeduplicateEcShards(commandEnv *CommandEnv, collection string, vid needle
 			continue
 		}
 		sortEcNodesByFreeslotsAscending(ecNodes)
-		fmt.Printf("ec shard %d.%d has %d copies, keeping %v\n", vid, shardId, len(ecNodes), ecNodes[0].info.Id)
+		fmt.Fprintf(writer, "ec shard %d.%d has %d copies, keeping %v\n", vid, shardId, len(ecNodes), ecNodes[0].info.Id)
 		if !applyBalancing {
 			continue
 		}
 
 		duplicatedShardIds := []uint32{uint32(shardId)}
 		for _, ecNode := range ecNodes[1:] {
-			if err := unmountEcShards(commandEnv.option.GrpcDialOption, vid, pb.NewServerAddressFromDataNode(ecNode.info), duplicatedShardIds); err != nil {
+			if err := unmountEcShards(commandEnv.option.GrpcDialOption, writer, vid, pb.NewServerAddressFromDataNode(ecNode.info), duplicatedShardIds); err != nil {
 				return err
 			}
-			if err := sourceServerDeleteEcShards(commandEnv.option.GrpcDialOption, collection, vid, pb.NewServerAddressFromDataNode(ecNode.info), duplicatedShardIds); err != nil {
+			if err := sourceServerDeleteEcShards(commandEnv.option.GrpcDialOption, writer, collection, vid, pb.NewServerAddressFromDataNode(ecNode.info), duplicatedShardIds); err != nil {
 				return err
 			}
 			ecNode.deleteEcVolumeShards(vid, duplicatedShardIds)
@@ -229,22 +263,22 @@ func doDeduplicateEcShards(commandEnv *CommandEnv, collection string, vid needle
 	return nil
 }
 
-func balanceEcShardsAcrossRacks(commandEnv *CommandEnv, allEcNodes []*EcNode, racks map[RackId]*EcRack, collection string, applyBalancing bool) error {
+func balanceEcShardsAcrossRacks(commandEnv *CommandEnv, writer io.Writer, allEcNodes []*EcNode, racks map[RackId]*EcRack, collection string, applyBalancing bool) error {
 	// collect vid => []ecNode, since previous steps can change the locations
 	vidLocations := collectVolumeIdToEcNodes(allEcNodes, collection)
 	// spread the ec shards evenly
 	for vid, locations := range vidLocations {
-		if err := doBalanceEcShardsAcrossRacks(commandEnv, collection, vid, locations, racks, applyBalancing); err != nil {
+		if err := doBalanceEcShardsAcrossRacks(commandEnv, writer, collection, vid, locations, racks, applyBalancing); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func doBalanceEcShardsAcrossRacks(commandEnv *CommandEnv, collection string, vid needle.VolumeId, locations []*EcNode, racks map[RackId]*EcRack, applyBalancing bool) error {
+func doBalanceEcShardsAcrossRacks(commandEnv *CommandEnv, writer io.Writer, collection string, vid needle.VolumeId, locations []*EcNode, racks map[RackId]*EcRack, applyBalancing bool) error {
 
 	// calculate average number of shards an ec rack should have for one volume
-	averageShardsPerEcRack := ceilDivide(erasure_coding.TotalShardsCount, len(racks))
+	averageShardsPerEcRack := ceilDivide(findEcVolumeScheme(locations, vid).TotalShards(), len(racks))
 
 	// see the volume's shards are in how many racks, and how many in each rack
 	rackToShardCount := groupByCount(locations, func(ecNode *EcNode) (id string, count int) {
@@ -269,14 +303,14 @@ func doBalanceEcShardsAcrossRacks(commandEnv *CommandEnv, collection string, vid
 	for shardId, ecNode := range ecShardsToMove {
 		rackId := pickOneRack(racks, rackToShardCount, averageShardsPerEcRack)
 		if rackId == "" {
-			fmt.Printf("ec shard %d.%d at %s can not find a destination rack\n", vid, shardId, ecNode.info.Id)
+			fmt.Fprintf(writer, "ec shard %d.%d at %s can not find a destination rack\n", vid, shardId, ecNode.info.Id)
 			continue
 		}
 		var possibleDestinationEcNodes []*EcNode
 		for _, n := range racks[rackId].ecNodes {
 			possibleDestinationEcNodes = append(possibleDestinationEcNodes, n)
 		}
-		err := pickOneEcNodeAndMoveOneShard(commandEnv, averageShardsPerEcRack, ecNode, collection, vid, shardId, possibleDestinationEcNodes, applyBalancing)
+		err := pickOneEcNodeAndMoveOneShard(commandEnv, writer, func(*EcNode) int { return averageShardsPerEcRack }, ecNode, collection, vid, shardId, possibleDestinationEcNodes, applyBalancing)
 		if err != nil {
 			return err
 		}
@@ -308,7 +342,7 @@ func pickOneRack(rackToEcNodes map[RackId]*EcRack, rackToShardCount map[string]i
 	return ""
 }
 
-func balanceEcShardsWithinRacks(commandEnv *CommandEnv, allEcNodes []*EcNode, racks map[RackId]*EcRack, collection string, applyBalancing bool) error {
+func balanceEcShardsWithinRacks(commandEnv *CommandEnv, writer io.Writer, allEcNodes []*EcNode, racks map[RackId]*EcRack, collection string, applyBalancing bool) error {
 	// collect vid => []ecNode, since previous steps can change the locations
 	vidLocations := collectVolumeIdToEcNodes(allEcNodes, collection)
 
@@ -333,8 +367,8 @@ func balanceEcShardsWithinRacks(commandEnv *CommandEnv, allEcNodes []*EcNode, ra
 				}
 			}
 			sourceEcNodes := rackEcNodesWithVid[rackId]
-			averageShardsPerEcNode := ceilDivide(rackToShardCount[rackId], len(possibleDestinationEcNodes))
-			if err := doBalanceEcShardsWithinOneRack(commandEnv, averageShardsPerEcNode, collection, vid, sourceEcNodes, possibleDestinationEcNodes, applyBalancing); err != nil {
+			shardLimit := weightedShardLimit(rackToShardCount[rackId], possibleDestinationEcNodes)
+			if err := doBalanceEcShardsWithinOneRack(commandEnv, writer, shardLimit, collection, vid, sourceEcNodes, possibleDestinationEcNodes, applyBalancing); err != nil {
 				return err
 			}
 		}
@@ -342,12 +376,12 @@ func balanceEcShardsWithinRacks(commandEnv *CommandEnv, allEcNodes []*EcNode, ra
 	return nil
 }
 
-func doBalanceEcShardsWithinOneRack(commandEnv *CommandEnv, averageShardsPerEcNode int, collection string, vid needle.VolumeId, existingLocations, possibleDestinationEcNodes []*EcNode, applyBalancing bool) error {
+func doBalanceEcShardsWithinOneRack(commandEnv *CommandEnv, writer io.Writer, shardLimit func(ecNode *EcNode) int, collection string, vid needle.VolumeId, existingLocations, possibleDestinationEcNodes []*EcNode, applyBalancing bool) error {
 
 	for _, ecNode := range existingLocations {
 
 		shardBits := findEcVolumeShards(ecNode, vid)
-		overLimitCount := shardBits.ShardIdCount() - averageShardsPerEcNode
+		overLimitCount := shardBits.ShardIdCount() - shardLimit(ecNode)
 
 		for _, shardId := range shardBits.ShardIds() {
 
@@ -355,9 +389,9 @@ func doBalanceEcShardsWithinOneRack(commandEnv *CommandEnv, averageShardsPerEcNo
 				break
 			}
 
-			fmt.Printf("%s has %d overlimit, moving ec shard %d.%d\n", ecNode.info.Id, overLimitCount, vid, shardId)
+			fmt.Fprintf(writer, "%s has %d overlimit, moving ec shard %d.%d\n", ecNode.info.Id, overLimitCount, vid, shardId)
 
-			err := pickOneEcNodeAndMoveOneShard(commandEnv, averageShardsPerEcNode, ecNode, collection, vid, shardId, possibleDestinationEcNodes, applyBalancing)
+			err := pickOneEcNodeAndMoveOneShard(commandEnv, writer, shardLimit, ecNode, collection, vid, shardId, possibleDestinationEcNodes, applyBalancing)
 			if err != nil {
 				return err
 			}
@@ -369,18 +403,18 @@ func doBalanceEcShardsWithinOneRack(commandEnv *CommandEnv, averageShardsPerEcNo
 	return nil
 }
 
-func balanceEcRacks(commandEnv *CommandEnv, racks map[RackId]*EcRack, applyBalancing bool) error {
+func balanceEcRacks(commandEnv *CommandEnv, writer io.Writer, racks map[RackId]*EcRack, applyBalancing bool) error {
 
 	// balance one rack for all ec shards
 	for _, ecRack := range racks {
-		if err := doBalanceEcRack(commandEnv, ecRack, applyBalancing); err != nil {
+		if err := doBalanceEcRack(commandEnv, writer, ecRack, applyBalancing); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func doBalanceEcRack(commandEnv *CommandEnv, ecRack *EcRack, applyBalancing bool) error {
+func doBalanceEcRack(commandEnv *CommandEnv, writer io.Writer, ecRack *EcRack, applyBalancing bool) error {
 
 	if len(ecRack.ecNodes) <= 1 {
 		return nil
@@ -407,7 +441,7 @@ func doBalanceEcRack(commandEnv *CommandEnv, ecRack *EcRack, applyBalancing bool
 		totalShardCount += count
 	}
 
-	averageShardCount := ceilDivide(totalShardCount, len(rackEcNodes))
+	shardLimit := weightedShardLimit(totalShardCount, rackEcNodes)
 
 	hasMove := true
 	for hasMove {
@@ -417,7 +451,7 @@ func doBalanceEcRack(commandEnv *CommandEnv, ecRack *EcRack, applyBalancing bool
 		})
 		emptyNode, fullNode := rackEcNodes[0], rackEcNodes[len(rackEcNodes)-1]
 		emptyNodeShardCount, fullNodeShardCount := ecNodeIdToShardCount[emptyNode.info.Id], ecNodeIdToShardCount[fullNode.info.Id]
-		if fullNodeShardCount > averageShardCount && emptyNodeShardCount+1 <= averageShardCount {
+		if fullNodeShardCount > shardLimit(fullNode) && emptyNodeShardCount+1 <= shardLimit(emptyNode) {
 
 			emptyNodeIds := make(map[uint32]bool)
 			if emptyDiskInfo, found := emptyNode.info.DiskInfos[string(types.HardDriveType)]; found {
@@ -430,9 +464,9 @@ func doBalanceEcRack(commandEnv *CommandEnv, ecRack *EcRack, applyBalancing bool
 					if _, found := emptyNodeIds[shards.Id]; !found {
 						for _, shardId := range erasure_coding.ShardBits(shards.EcIndexBits).ShardIds() {
 
-							fmt.Printf("%s moves ec shards %d.%d to %s\n", fullNode.info.Id, shards.Id, shardId, emptyNode.info.Id)
+							fmt.Fprintf(writer, "%s moves ec shards %d.%d to %s\n", fullNode.info.Id, shards.Id, shardId, emptyNode.info.Id)
 
-							err := moveMountedShardToEcNode(commandEnv, fullNode, shards.Collection, needle.VolumeId(shards.Id), shardId, emptyNode, applyBalancing)
+							err := moveMountedShardToEcNode(commandEnv, writer, fullNode, shards.Collection, needle.VolumeId(shards.Id), shardId, emptyNode, applyBalancing)
 							if err != nil {
 								return err
 							}
@@ -452,7 +486,7 @@ func doBalanceEcRack(commandEnv *CommandEnv, ecRack *EcRack, applyBalancing bool
 	return nil
 }
 
-func pickOneEcNodeAndMoveOneShard(commandEnv *CommandEnv, averageShardsPerEcNode int, existingLocation *EcNode, collection string, vid needle.VolumeId, shardId erasure_coding.ShardId, possibleDestinationEcNodes []*EcNode, applyBalancing bool) error {
+func pickOneEcNodeAndMoveOneShard(commandEnv *CommandEnv, writer io.Writer, shardLimit func(ecNode *EcNode) int, existingLocation *EcNode, collection string, vid needle.VolumeId, shardId erasure_coding.ShardId, possibleDestinationEcNodes []*EcNode, applyBalancing bool) error {
 
 	sortEcNodesByFreeslotsDescending(possibleDestinationEcNodes)
 
@@ -464,13 +498,13 @@ func pickOneEcNodeAndMoveOneShard(commandEnv *CommandEnv, averageShardsPerEcNode
 		if destEcNode.freeEcSlot <= 0 {
 			continue
 		}
-		if findEcVolumeShards(destEcNode, vid).ShardIdCount() >= averageShardsPerEcNode {
+		if findEcVolumeShards(destEcNode, vid).ShardIdCount() >= shardLimit(destEcNode) {
 			continue
 		}
 
-		fmt.Printf("%s moves ec shard %d.%d to %s\n", existingLocation.info.Id, vid, shardId, destEcNode.info.Id)
+		fmt.Fprintf(writer, "%s moves ec shard %d.%d to %s\n", existingLocation.info.Id, vid, shardId, destEcNode.info.Id)
 
-		err := moveMountedShardToEcNode(commandEnv, existingLocation, collection, vid, shardId, destEcNode, applyBalancing)
+		err := moveMountedShardToEcNode(commandEnv, writer, existingLocation, collection, vid, shardId, destEcNode, applyBalancing)
 		if err != nil {
 			return err
 		}
@@ -481,6 +515,21 @@ func pickOneEcNodeAndMoveOneShard(commandEnv *CommandEnv, averageShardsPerEcNode
 	return nil
 }
 
+// weightedShardLimit spreads the shards over the volume servers by their weights,
+// rounding up so the shards always fit
+func weightedShardLimit(shardCount int, ecNodes []*EcNode) func(ecNode *EcNode) int {
+	var totalWeight float64
+	for _, ecNode := range ecNodes {
+		totalWeight += nodeWeight(ecNode.info)
+	}
+	return func(ecNode *EcNode) int {
+		if totalWeight <= 0 {
+			return shardCount
+		}
+		return int(math.Ceil(float64(shardCount) * nodeWeight(ecNode.info) / totalWeight))
+	}
+}
+
 func pickNEcShardsToMoveFrom(ecNodes []*EcNode, vid needle.VolumeId, n int) map[erasure_coding.ShardId]*EcNode {
 	picked := make(map[erasure_coding.ShardId]*EcNode)
 	var candidateEcNodes []*CandidateEcNode
@@ -0,0 +1,98 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// With -checkpoint, ec.rebuild and ec.balance record their plan and progress in a local file, rewritten
+// after each step. Run again with the same file, an interrupted run resumes the plan, skipping the
+// finished steps. The file is removed once all the steps are finished.
+
+const (
+	ecStepDone   = "done"
+	ecStepFailed = "failed"
+)
+
+type ecCheckpoint struct {
+	fileName string
+	Command  string              `json:"command"`
+	Steps    []*ecCheckpointStep `json:"steps"`
+}
+
+type ecCheckpointStep struct {
+	Collection string   `json:"collection"`
+	VolumeId   uint32   `json:"volumeId,omitempty"`
+	Shards     []uint32 `json:"shards,omitempty"` // the missing shards to rebuild
+	Node       string   `json:"node,omitempty"`   // the volume server rebuilding the shards
+	Racks      bool     `json:"racks,omitempty"`  // balancing the ec shards within the racks
+	Status     string   `json:"status,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// loadEcCheckpoint reads the checkpoint of the command, or starts a new one without any step
+func loadEcCheckpoint(fileName, command string) (*ecCheckpoint, error) {
+	cp := &ecCheckpoint{fileName: fileName, Command: command}
+	if fileName == "" {
+		return cp, nil
+	}
+	data, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read the checkpoint %s: %v", fileName, err)
+	}
+	if err = json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("parse the checkpoint %s: %v", fileName, err)
+	}
+	if cp.Command != command {
+		return nil, fmt.Errorf("the checkpoint %s is of %s, not %s", fileName, cp.Command, command)
+	}
+	return cp, nil
+}
+
+// save rewrites the checkpoint file, through a temporary file so an interruption keeps the last one
+func (cp *ecCheckpoint) save() error {
+	if cp.fileName == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFileName := cp.fileName + ".tmp"
+	if err = os.WriteFile(tmpFileName, data, 0644); err != nil {
+		return fmt.Errorf("write the checkpoint %s: %v", tmpFileName, err)
+	}
+	return os.Rename(tmpFileName, cp.fileName)
+}
+
+func (cp *ecCheckpoint) doneSteps() (done int) {
+	for _, step := range cp.Steps {
+		if step.Status == ecStepDone {
+			done++
+		}
+	}
+	return
+}
+
+// resumed tells about the finished steps of the loaded checkpoint
+func (cp *ecCheckpoint) resumed(writer io.Writer) {
+	if done := cp.doneSteps(); done > 0 {
+		fmt.Fprintf(writer, "resume from %s with %d of %d steps done\n", cp.fileName, done, len(cp.Steps))
+	}
+}
+
+// finish removes the checkpoint file once all the steps are done
+func (cp *ecCheckpoint) finish() error {
+	if cp.fileName == "" || cp.doneSteps() < len(cp.Steps) {
+		return nil
+	}
+	if err := os.Remove(cp.fileName); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove the checkpoint %s: %v", cp.fileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEcCheckpointResume(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "checkpoint")
+	cp, err := loadEcCheckpoint(fileName, "ec.rebuild")
+	if err != nil || len(cp.Steps) != 0 {
+		t.Fatalf("missing checkpoint: %+v %v", cp, err)
+	}
+	cp.Steps = []*ecCheckpointStep{
+		{Collection: "c1", VolumeId: 1, Shards: []uint32{3}, Node: "dn1", Status: ecStepDone},
+		{Collection: "c1", VolumeId: 2, Shards: []uint32{0, 13}, Node: "dn2"},
+	}
+	if err = cp.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = loadEcCheckpoint(fileName, "ec.balance"); err == nil {
+		t.Errorf("loaded the checkpoint of another command")
+	}
+	resumed, err := loadEcCheckpoint(fileName, "ec.rebuild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resumed.Steps, cp.Steps) {
+		t.Errorf("resumed steps %+v, expected %+v", resumed.Steps, cp.Steps)
+	}
+	var buf bytes.Buffer
+	resumed.resumed(&buf)
+	if !strings.Contains(buf.String(), "1 of 2 steps done") {
+		t.Errorf("resumed output: %s", buf.String())
+	}
+
+	if err = resumed.finish(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(fileName); err != nil {
+		t.Errorf("removed the unfinished checkpoint: %v", err)
+	}
+	resumed.Steps[1].Status = ecStepDone
+	if err = resumed.finish(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(fileName); !os.IsNotExist(err) {
+		t.Errorf("kept the finished checkpoint: %v", err)
+	}
+}
+
+func TestPlanEcRebuild(t *testing.T) {
+	ecNodes := []*EcNode{
+		newEcNode("dc1", "rack1", "dn1", 100).
+			addEcVolumeAndShardsForTest(2, "c1", []uint32{0, 1, 2, 3, 4, 5, 6}).
+			addEcVolumeAndShardsForTest(1, "c1", []uint32{0, 1, 2, 3, 4, 5, 6}),
+		newEcNode("dc1", "rack2", "dn2", 100).
+			addEcVolumeAndShardsForTest(2, "c1", []uint32{7, 8, 9, 10, 11}).
+			addEcVolumeAndShardsForTest(1, "c1", []uint32{7, 8, 9, 10, 11, 12, 13}).
+			addEcVolumeAndShardsForTest(3, "c2", []uint32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}),
+	}
+
+	steps := planEcRebuild(ecNodes, []string{"c1", "c2"})
+	expected := []*ecCheckpointStep{
+		{Collection: "c1", VolumeId: 2, Shards: []uint32{12, 13}},
+		{Collection: "c2", VolumeId: 3, Shards: []uint32{13}},
+	}
+	if !reflect.DeepEqual(steps, expected) {
+		for _, step := range steps {
+			t.Logf("planned %+v", step)
+		}
+		t.Errorf("unexpected plan")
+	}
+}
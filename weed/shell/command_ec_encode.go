@@ -7,6 +7,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/pb"
 	"io"
 	"math/rand"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
 	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 	"github.com/seaweedfs/seaweedfs/weed/wdclient"
 )
 
@@ -34,8 +36,9 @@ func (c *commandEcEncode) Name() string {
 func (c *commandEcEncode) Help() string {
 	return `apply erasure coding to a volume
 
-	ec.encode [-collection=""] [-fullPercent=95 -quietFor=1h]
-	ec.encode [-collection=""] [-volumeId=<volume_id>]
+	ec.encode [-collection=""] [-fullPercent=95 -quietFor=1h] [-scheme=10+4]
+	ec.encode [-collection=""] [-volumeId=<volume_id>] [-scheme=10+4]
+	ec.encode [-collection=""] [-window=20:00-06:00] [-ioBytePerSecond=0] [-parallelLimit=1]
 
 	This command will:
 	1. freeze one volume
@@ -51,6 +54,16 @@ func (c *commandEcEncode) Help() string {
 	If you only have less than 4 volume servers, with erasure coding, at least you can afford to
 	have 4 corrupted shard files.
 
+	The -scheme option sets other data and parity shards, e.g. 4+2 for small clusters, or 8+3.
+	The scheme is saved with the ec volume, so ec.rebuild, ec.balance and ec.decode follow it.
+	Each volume server should have at most as many shards of a volume as the parity shards.
+
+	To keep encoding cold data away from the production traffic:
+	The -window option only starts encoding volumes within these times of the day, in the local time,
+	e.g. "20:00-06:00" or "00:00-07:00,12:00-13:00". The volumes left are encoded in the next run.
+	The -ioBytePerSecond option limits the speed of generating and copying the shards of each volume.
+	The -parallelLimit option sets the number of volumes encoded at the same time.
+
 `
 }
 
@@ -62,9 +75,24 @@ func (c *commandEcEncode) Do(args []string, commandEnv *CommandEnv, writer io.Wr
 	fullPercentage := encodeCommand.Float64("fullPercent", 95, "the volume reaches the percentage of max volume size")
 	quietPeriod := encodeCommand.Duration("quietFor", time.Hour, "select volumes without no writes for this period")
 	parallelCopy := encodeCommand.Bool("parallelCopy", true, "copy shards in parallel")
+	schemeString := encodeCommand.String("scheme", erasure_coding.DefaultScheme.String(), "the data and parity shards, e.g. 10+4 or 4+2")
+	windowString := encodeCommand.String("window", "", "only start encoding volumes within these times of the day, e.g. 20:00-06:00")
+	ioBytePerSecond := encodeCommand.Int64("ioBytePerSecond", 0, "limit the speed of generating and copying the shards of each volume")
+	parallelLimit := encodeCommand.Int("parallelLimit", 1, "the number of volumes encoded at the same time")
 	if err = encodeCommand.Parse(args); err != nil {
 		return nil
 	}
+	scheme, err := erasure_coding.ParseScheme(*schemeString)
+	if err != nil {
+		return err
+	}
+	windows, err := parseEcEncodeWindows(*windowString)
+	if err != nil {
+		return err
+	}
+	if *parallelLimit < 1 {
+		return fmt.Errorf("parallelLimit %d should be at least 1", *parallelLimit)
+	}
 
 	if err = commandEnv.confirmIsLocked(args); err != nil {
 		return
@@ -74,25 +102,108 @@ func (c *commandEcEncode) Do(args []string, commandEnv *CommandEnv, writer io.Wr
 
 	// volumeId is provided
 	if vid != 0 {
-		return doEcEncode(commandEnv, *collection, vid, *parallelCopy)
+		if !isInEcEncodeWindows(windows, time.Now()) {
+			return fmt.Errorf("outside the encoding window %s", *windowString)
+		}
+		return doEcEncode(commandEnv, writer, *collection, vid, scheme, *parallelCopy, *ioBytePerSecond)
 	}
 
 	// apply to all volumes in the collection
-	volumeIds, err := collectVolumeIdsForEcEncode(commandEnv, *collection, *fullPercentage, *quietPeriod)
+	volumeIds, err := collectVolumeIdsForEcEncode(commandEnv, writer, *collection, *fullPercentage, *quietPeriod)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("ec encode volumes: %v\n", volumeIds)
-	for _, vid := range volumeIds {
-		if err = doEcEncode(commandEnv, *collection, vid, *parallelCopy); err != nil {
-			return err
+	fmt.Fprintf(writer, "ec encode volumes: %v\n", volumeIds)
+
+	var wg sync.WaitGroup
+	var encodeErrLock sync.Mutex
+	var encodeErr error
+	executor := util.NewLimitedConcurrentExecutor(*parallelLimit)
+	for i, vid := range volumeIds {
+		if !isInEcEncodeWindows(windows, time.Now()) {
+			fmt.Fprintf(writer, "outside the encoding window %s, %d volumes left: %v\n", *windowString, len(volumeIds)-i, volumeIds[i:])
+			break
+		}
+		encodeErrLock.Lock()
+		failed := encodeErr != nil
+		encodeErrLock.Unlock()
+		if failed {
+			break
 		}
+		vid := vid
+		wg.Add(1)
+		executor.Execute(func() {
+			defer wg.Done()
+			if err := doEcEncode(commandEnv, writer, *collection, vid, scheme, *parallelCopy, *ioBytePerSecond); err != nil {
+				encodeErrLock.Lock()
+				if encodeErr == nil {
+					encodeErr = err
+				}
+				encodeErrLock.Unlock()
+			}
+		})
 	}
+	wg.Wait()
 
-	return nil
+	return encodeErr
+}
+
+// ecEncodeWindow is a time of the day from start to end, crossing midnight if the end is before the start
+type ecEncodeWindow struct {
+	start, end time.Duration
+}
+
+func parseEcEncodeWindows(s string) (windows []ecEncodeWindow, err error) {
+	if s == "" {
+		return nil, nil
+	}
+	for _, w := range strings.Split(s, ",") {
+		startString, endString, found := strings.Cut(strings.TrimSpace(w), "-")
+		if !found {
+			return nil, fmt.Errorf("window %q should be like 20:00-06:00", w)
+		}
+		var window ecEncodeWindow
+		if window.start, err = parseTimeOfDay(startString); err != nil {
+			return nil, fmt.Errorf("window %q: %v", w, err)
+		}
+		if window.end, err = parseTimeOfDay(endString); err != nil {
+			return nil, fmt.Errorf("window %q: %v", w, err)
+		}
+		if window.start == window.end {
+			return nil, fmt.Errorf("window %q is empty", w)
+		}
+		windows = append(windows, window)
+	}
+	return
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// isInEcEncodeWindows is true if there is no window
+func isInEcEncodeWindows(windows []ecEncodeWindow, now time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	year, month, day := now.Date()
+	timeOfDay := now.Sub(time.Date(year, month, day, 0, 0, 0, 0, now.Location()))
+	for _, window := range windows {
+		if window.start < window.end && window.start <= timeOfDay && timeOfDay < window.end {
+			return true
+		}
+		if window.start > window.end && (window.start <= timeOfDay || timeOfDay < window.end) {
+			return true
+		}
+	}
+	return false
 }
 
-func doEcEncode(commandEnv *CommandEnv, collection string, vid needle.VolumeId, parallelCopy bool) (err error) {
+func doEcEncode(commandEnv *CommandEnv, writer io.Writer, collection string, vid needle.VolumeId, scheme erasure_coding.Scheme, parallelCopy bool, ioBytePerSecond int64) (err error) {
 	if !commandEnv.isLocked() {
 		return fmt.Errorf("lock is lost")
 	}
@@ -106,19 +217,19 @@ func doEcEncode(commandEnv *CommandEnv, collection string, vid needle.VolumeId,
 	// fmt.Printf("found ec %d shards on %v\n", vid, locations)
 
 	// mark the volume as readonly
-	err = markVolumeReplicasWritable(commandEnv.option.GrpcDialOption, vid, locations, false)
+	err = markVolumeReplicasWritable(commandEnv.option.GrpcDialOption, writer, vid, locations, false)
 	if err != nil {
 		return fmt.Errorf("mark volume %d as readonly on %s: %v", vid, locations[0].Url, err)
 	}
 
 	// generate ec shards
-	err = generateEcShards(commandEnv.option.GrpcDialOption, vid, collection, locations[0].ServerAddress())
+	err = generateEcShards(commandEnv.option.GrpcDialOption, writer, vid, collection, scheme, locations[0].ServerAddress(), ioBytePerSecond)
 	if err != nil {
 		return fmt.Errorf("generate ec shards for volume %d on %s: %v", vid, locations[0].Url, err)
 	}
 
 	// balance the ec shards to current cluster
-	err = spreadEcShards(commandEnv, vid, collection, locations, parallelCopy)
+	err = spreadEcShards(commandEnv, writer, vid, collection, scheme, locations, parallelCopy, ioBytePerSecond)
 	if err != nil {
 		return fmt.Errorf("spread ec shards for volume %d from %s: %v", vid, locations[0].Url, err)
 	}
@@ -126,14 +237,17 @@ func doEcEncode(commandEnv *CommandEnv, collection string, vid needle.VolumeId,
 	return nil
 }
 
-func generateEcShards(grpcDialOption grpc.DialOption, volumeId needle.VolumeId, collection string, sourceVolumeServer pb.ServerAddress) error {
+func generateEcShards(grpcDialOption grpc.DialOption, writer io.Writer, volumeId needle.VolumeId, collection string, scheme erasure_coding.Scheme, sourceVolumeServer pb.ServerAddress, ioBytePerSecond int64) error {
 
-	fmt.Printf("generateEcShards %s %d on %s ...\n", collection, volumeId, sourceVolumeServer)
+	fmt.Fprintf(writer, "generateEcShards %s %d with %s on %s ...\n", collection, volumeId, scheme, sourceVolumeServer)
 
 	err := operation.WithVolumeServerClient(false, sourceVolumeServer, grpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
 		_, genErr := volumeServerClient.VolumeEcShardsGenerate(context.Background(), &volume_server_pb.VolumeEcShardsGenerateRequest{
-			VolumeId:   uint32(volumeId),
-			Collection: collection,
+			VolumeId:        uint32(volumeId),
+			Collection:      collection,
+			DataShards:      uint32(scheme.DataShards),
+			ParityShards:    uint32(scheme.ParityShards),
+			IoBytePerSecond: ioBytePerSecond,
 		})
 		return genErr
 	})
@@ -142,45 +256,45 @@ func generateEcShards(grpcDialOption grpc.DialOption, volumeId needle.VolumeId,
 
 }
 
-func spreadEcShards(commandEnv *CommandEnv, volumeId needle.VolumeId, collection string, existingLocations []wdclient.Location, parallelCopy bool) (err error) {
+func spreadEcShards(commandEnv *CommandEnv, writer io.Writer, volumeId needle.VolumeId, collection string, scheme erasure_coding.Scheme, existingLocations []wdclient.Location, parallelCopy bool, ioBytePerSecond int64) (err error) {
 
 	allEcNodes, totalFreeEcSlots, err := collectEcNodes(commandEnv, "")
 	if err != nil {
 		return err
 	}
 
-	if totalFreeEcSlots < erasure_coding.TotalShardsCount {
+	if totalFreeEcSlots < scheme.TotalShards() {
 		return fmt.Errorf("not enough free ec shard slots. only %d left", totalFreeEcSlots)
 	}
 	allocatedDataNodes := allEcNodes
-	if len(allocatedDataNodes) > erasure_coding.TotalShardsCount {
-		allocatedDataNodes = allocatedDataNodes[:erasure_coding.TotalShardsCount]
+	if len(allocatedDataNodes) > scheme.TotalShards() {
+		allocatedDataNodes = allocatedDataNodes[:scheme.TotalShards()]
 	}
 
 	// calculate how many shards to allocate for these servers
-	allocatedEcIds := balancedEcDistribution(allocatedDataNodes)
+	allocatedEcIds := balancedEcDistribution(allocatedDataNodes, scheme.TotalShards())
 
 	// ask the data nodes to copy from the source volume server
-	copiedShardIds, err := parallelCopyEcShardsFromSource(commandEnv.option.GrpcDialOption, allocatedDataNodes, allocatedEcIds, volumeId, collection, existingLocations[0], parallelCopy)
+	copiedShardIds, err := parallelCopyEcShardsFromSource(commandEnv.option.GrpcDialOption, writer, allocatedDataNodes, allocatedEcIds, volumeId, collection, existingLocations[0], parallelCopy, ioBytePerSecond)
 	if err != nil {
 		return err
 	}
 
 	// unmount the to be deleted shards
-	err = unmountEcShards(commandEnv.option.GrpcDialOption, volumeId, existingLocations[0].ServerAddress(), copiedShardIds)
+	err = unmountEcShards(commandEnv.option.GrpcDialOption, writer, volumeId, existingLocations[0].ServerAddress(), copiedShardIds)
 	if err != nil {
 		return err
 	}
 
 	// ask the source volume server to clean up copied ec shards
-	err = sourceServerDeleteEcShards(commandEnv.option.GrpcDialOption, collection, volumeId, existingLocations[0].ServerAddress(), copiedShardIds)
+	err = sourceServerDeleteEcShards(commandEnv.option.GrpcDialOption, writer, collection, volumeId, existingLocations[0].ServerAddress(), copiedShardIds)
 	if err != nil {
 		return fmt.Errorf("source delete copied ecShards %s %d.%v: %v", existingLocations[0].Url, volumeId, copiedShardIds, err)
 	}
 
 	// ask the source volume server to delete the original volume
 	for _, location := range existingLocations {
-		fmt.Printf("delete volume %d from %s\n", volumeId, location.Url)
+		fmt.Fprintf(writer, "delete volume %d from %s\n", volumeId, location.Url)
 		err = deleteVolume(commandEnv.option.GrpcDialOption, volumeId, location.ServerAddress(), false)
 		if err != nil {
 			return fmt.Errorf("deleteVolume %s volume %d: %v", location.Url, volumeId, err)
@@ -191,16 +305,16 @@ func spreadEcShards(commandEnv *CommandEnv, volumeId needle.VolumeId, collection
 
 }
 
-func parallelCopyEcShardsFromSource(grpcDialOption grpc.DialOption, targetServers []*EcNode, allocatedEcIds [][]uint32, volumeId needle.VolumeId, collection string, existingLocation wdclient.Location, parallelCopy bool) (actuallyCopied []uint32, err error) {
+func parallelCopyEcShardsFromSource(grpcDialOption grpc.DialOption, writer io.Writer, targetServers []*EcNode, allocatedEcIds [][]uint32, volumeId needle.VolumeId, collection string, existingLocation wdclient.Location, parallelCopy bool, ioBytePerSecond int64) (actuallyCopied []uint32, err error) {
 
-	fmt.Printf("parallelCopyEcShardsFromSource %d %s\n", volumeId, existingLocation.Url)
+	fmt.Fprintf(writer, "parallelCopyEcShardsFromSource %d %s\n", volumeId, existingLocation.Url)
 
 	var wg sync.WaitGroup
 	shardIdChan := make(chan []uint32, len(targetServers))
 	copyFunc := func(server *EcNode, allocatedEcShardIds []uint32) {
 		defer wg.Done()
-		copiedShardIds, copyErr := oneServerCopyAndMountEcShardsFromSource(grpcDialOption, server,
-			allocatedEcShardIds, volumeId, collection, existingLocation.ServerAddress())
+		copiedShardIds, copyErr := oneServerCopyAndMountEcShardsFromSource(grpcDialOption, writer, server,
+			allocatedEcShardIds, volumeId, collection, existingLocation.ServerAddress(), ioBytePerSecond)
 		if copyErr != nil {
 			err = copyErr
 		} else {
@@ -209,11 +323,11 @@ func parallelCopyEcShardsFromSource(grpcDialOption grpc.DialOption, targetServer
 		}
 	}
 	cleanupFunc := func(server *EcNode, allocatedEcShardIds []uint32) {
-		if err := unmountEcShards(grpcDialOption, volumeId, pb.NewServerAddressFromDataNode(server.info), allocatedEcShardIds); err != nil {
-			fmt.Printf("unmount aborted shards %d.%v on %s: %v\n", volumeId, allocatedEcShardIds, server.info.Id, err)
+		if err := unmountEcShards(grpcDialOption, writer, volumeId, pb.NewServerAddressFromDataNode(server.info), allocatedEcShardIds); err != nil {
+			fmt.Fprintf(writer, "unmount aborted shards %d.%v on %s: %v\n", volumeId, allocatedEcShardIds, server.info.Id, err)
 		}
-		if err := sourceServerDeleteEcShards(grpcDialOption, collection, volumeId, pb.NewServerAddressFromDataNode(server.info), allocatedEcShardIds); err != nil {
-			fmt.Printf("remove aborted shards %d.%v on %s: %v\n", volumeId, allocatedEcShardIds, server.info.Id, err)
+		if err := sourceServerDeleteEcShards(grpcDialOption, writer, collection, volumeId, pb.NewServerAddressFromDataNode(server.info), allocatedEcShardIds); err != nil {
+			fmt.Fprintf(writer, "remove aborted shards %d.%v on %s: %v\n", volumeId, allocatedEcShardIds, server.info.Id, err)
 		}
 	}
 
@@ -250,11 +364,11 @@ func parallelCopyEcShardsFromSource(grpcDialOption grpc.DialOption, targetServer
 	return
 }
 
-func balancedEcDistribution(servers []*EcNode) (allocated [][]uint32) {
+func balancedEcDistribution(servers []*EcNode, totalShards int) (allocated [][]uint32) {
 	allocated = make([][]uint32, len(servers))
 	allocatedShardIdIndex := uint32(0)
 	serverIndex := rand.Intn(len(servers))
-	for allocatedShardIdIndex < erasure_coding.TotalShardsCount {
+	for allocatedShardIdIndex < uint32(totalShards) {
 		if servers[serverIndex].freeEcSlot > 0 {
 			allocated[serverIndex] = append(allocated[serverIndex], allocatedShardIdIndex)
 			allocatedShardIdIndex++
@@ -268,7 +382,7 @@ func balancedEcDistribution(servers []*EcNode) (allocated [][]uint32) {
 	return allocated
 }
 
-func collectVolumeIdsForEcEncode(commandEnv *CommandEnv, selectedCollection string, fullPercentage float64, quietPeriod time.Duration) (vids []needle.VolumeId, err error) {
+func collectVolumeIdsForEcEncode(commandEnv *CommandEnv, writer io.Writer, selectedCollection string, fullPercentage float64, quietPeriod time.Duration) (vids []needle.VolumeId, err error) {
 
 	// collect topology information
 	topologyInfo, volumeSizeLimitMb, err := collectTopologyInfo(commandEnv, 0)
@@ -279,7 +393,7 @@ func collectVolumeIdsForEcEncode(commandEnv *CommandEnv, selectedCollection stri
 	quietSeconds := int64(quietPeriod / time.Second)
 	nowUnixSeconds := time.Now().Unix()
 
-	fmt.Printf("collect volumes quiet for: %d seconds\n", quietSeconds)
+	fmt.Fprintf(writer, "collect volumes quiet for: %d seconds\n", quietSeconds)
 
 	vidMap := make(map[uint32]bool)
 	eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
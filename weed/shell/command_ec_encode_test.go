@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
 	"testing"
+	"time"
 )
 
 func TestEcDistribution(t *testing.T) {
@@ -29,3 +30,34 @@ func TestEcDistribution(t *testing.T) {
 	}
 
 }
+
+func TestEcEncodeWindows(t *testing.T) {
+	windows, err := parseEcEncodeWindows("20:00-06:00, 12:00-13:30")
+	if err != nil {
+		t.Fatalf("parse windows: %v", err)
+	}
+	for clock, expected := range map[string]bool{
+		"19:59": false,
+		"20:00": true,
+		"23:59": true,
+		"00:00": true,
+		"05:59": true,
+		"06:00": false,
+		"12:00": true,
+		"13:29": true,
+		"13:30": false,
+	} {
+		now, _ := time.ParseInLocation("2006-01-02 15:04", "2024-03-05 "+clock, time.Local)
+		if isInEcEncodeWindows(windows, now) != expected {
+			t.Errorf("%s in the windows should be %v", clock, expected)
+		}
+	}
+	if !isInEcEncodeWindows(nil, time.Now()) {
+		t.Errorf("no window should be always open")
+	}
+	for _, s := range []string{"20:00", "20:00-20:00", "25:00-06:00", "8pm-6am"} {
+		if _, err := parseEcEncodeWindows(s); err == nil {
+			t.Errorf("parse %q: expected error", s)
+		}
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"flag"
 	"fmt"
 	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"golang.org/x/exp/slices"
 	"io"
+	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/operation"
 	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
@@ -28,7 +30,12 @@ func (c *commandEcRebuild) Name() string {
 func (c *commandEcRebuild) Help() string {
 	return `find and rebuild missing ec shards among volume servers
 
-	ec.rebuild [-c EACH_COLLECTION|<collection_name>] [-force]
+	ec.rebuild [-c EACH_COLLECTION|<collection_name>] [-force] [-checkpoint <file>]
+
+	Each ec volume with missing shards is reported as it is rebuilt.
+	With -checkpoint, the plan and the rebuilt volumes are recorded in the file, and running again
+	with the same file resumes an interrupted run, on the same volume server for the volume in progress.
+	The file is removed once all are rebuilt.
 
 	Algorithm:
 
@@ -60,6 +67,7 @@ func (c *commandEcRebuild) Do(args []string, commandEnv *CommandEnv, writer io.W
 	fixCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
 	collection := fixCommand.String("collection", "EACH_COLLECTION", "collection name, or \"EACH_COLLECTION\" for each collection")
 	applyChanges := fixCommand.Bool("force", false, "apply the changes")
+	checkpoint := fixCommand.String("checkpoint", "", "the local file of the plan and progress, to resume an interrupted run")
 	if err = fixCommand.Parse(args); err != nil {
 		return nil
 	}
@@ -75,71 +83,136 @@ func (c *commandEcRebuild) Do(args []string, commandEnv *CommandEnv, writer io.W
 		return err
 	}
 
-	if *collection == "EACH_COLLECTION" {
-		collections, err := ListCollectionNames(commandEnv, false, true)
-		if err != nil {
+	cp, err := loadEcCheckpoint(*checkpoint, c.Name())
+	if err != nil {
+		return err
+	}
+	cp.resumed(writer)
+	if !*applyChanges {
+		cp.fileName = ""
+	}
+	if len(cp.Steps) == 0 {
+		collections := []string{*collection}
+		if *collection == "EACH_COLLECTION" {
+			if collections, err = ListCollectionNames(commandEnv, false, true); err != nil {
+				return err
+			}
+			fmt.Fprintf(writer, "rebuildEcVolumes collections %+v\n", len(collections))
+		}
+		cp.Steps = planEcRebuild(allEcNodes, collections)
+		if err = cp.save(); err != nil {
 			return err
 		}
-		fmt.Printf("rebuildEcVolumes collections %+v\n", len(collections))
-		for _, c := range collections {
-			fmt.Printf("rebuildEcVolumes collection %+v\n", c)
-			if err = rebuildEcVolumes(commandEnv, allEcNodes, c, writer, *applyChanges); err != nil {
-				return err
+	}
+
+	for i, step := range cp.Steps {
+		if step.Status == ecStepDone {
+			continue
+		}
+		fmt.Fprintf(writer, "[%d/%d] ec volume %d collection %s: missing shards %v\n", i+1, len(cp.Steps), step.VolumeId, step.Collection, step.Shards)
+		start := time.Now()
+		if err = rebuildEcVolume(commandEnv, allEcNodes, step, writer, *applyChanges, cp.save); err != nil {
+			step.Status, step.Error = ecStepFailed, err.Error()
+			if saveErr := cp.save(); saveErr != nil {
+				fmt.Fprintf(writer, "%v\n", saveErr)
 			}
+			return err
 		}
-	} else {
-		if err = rebuildEcVolumes(commandEnv, allEcNodes, *collection, writer, *applyChanges); err != nil {
+		step.Status, step.Error = ecStepDone, ""
+		if err = cp.save(); err != nil {
 			return err
 		}
+		fmt.Fprintf(writer, "[%d/%d] ec volume %d collection %s: rebuilt on %s in %v\n", i+1, len(cp.Steps), step.VolumeId, step.Collection, step.Node, time.Since(start).Round(time.Millisecond))
 	}
 
-	return nil
+	return cp.finish()
 }
 
-func rebuildEcVolumes(commandEnv *CommandEnv, allEcNodes []*EcNode, collection string, writer io.Writer, applyChanges bool) error {
-
-	fmt.Printf("rebuildEcVolumes %s\n", collection)
+// planEcRebuild lists the ec volumes missing shards, by collection and volume id
+func planEcRebuild(allEcNodes []*EcNode, collections []string) (steps []*ecCheckpointStep) {
+	for _, collection := range collections {
+		ecShardMap := collectEcShardMap(allEcNodes, collection)
+		var vids []needle.VolumeId
+		for vid := range ecShardMap {
+			vids = append(vids, vid)
+		}
+		slices.Sort(vids)
+		for _, vid := range vids {
+			scheme := findEcVolumeScheme(allEcNodes, vid)
+			step := &ecCheckpointStep{Collection: collection, VolumeId: uint32(vid)}
+			for shardId, locations := range ecShardMap[vid][:scheme.TotalShards()] {
+				if len(locations) == 0 {
+					step.Shards = append(step.Shards, uint32(shardId))
+				}
+			}
+			if len(step.Shards) > 0 {
+				steps = append(steps, step)
+			}
+		}
+	}
+	return
+}
 
-	// collect vid => each shard locations, similar to ecShardMap in topology.go
+// collectEcShardMap collects vid => each shard locations, similar to ecShardMap in topology.go
+func collectEcShardMap(allEcNodes []*EcNode, collection string) EcShardMap {
 	ecShardMap := make(EcShardMap)
 	for _, ecNode := range allEcNodes {
 		ecShardMap.registerEcNode(ecNode, collection)
 	}
+	return ecShardMap
+}
 
-	for vid, locations := range ecShardMap {
-		shardCount := locations.shardCount()
-		if shardCount == erasure_coding.TotalShardsCount {
-			continue
-		}
-		if shardCount < erasure_coding.DataShardsCount {
-			return fmt.Errorf("ec volume %d is unrepairable with %d shards\n", vid, shardCount)
-		}
+// rebuildEcVolume rebuilds the missing shards of the planned ec volume, on the recorded volume server if any
+func rebuildEcVolume(commandEnv *CommandEnv, allEcNodes []*EcNode, step *ecCheckpointStep, writer io.Writer, applyChanges bool, save func() error) error {
 
-		sortEcNodesByFreeslotsDescending(allEcNodes)
+	vid := needle.VolumeId(step.VolumeId)
+	locations, found := collectEcShardMap(allEcNodes, step.Collection)[vid]
+	if !found {
+		fmt.Fprintf(writer, "ec volume %d is not found\n", vid)
+		return nil
+	}
+	scheme := findEcVolumeScheme(allEcNodes, vid)
+	locations = locations[:scheme.TotalShards()]
+	shardCount := locations.shardCount()
+	if shardCount == scheme.TotalShards() {
+		fmt.Fprintf(writer, "ec volume %d has all the %d shards\n", vid, shardCount)
+		return nil
+	}
+	if shardCount < scheme.DataShards {
+		return fmt.Errorf("ec volume %d is unrepairable with %d shards\n", vid, shardCount)
+	}
 
-		if allEcNodes[0].freeEcSlot < erasure_coding.TotalShardsCount {
-			return fmt.Errorf("disk space is not enough")
+	sortEcNodesByFreeslotsDescending(allEcNodes)
+	rebuilder := allEcNodes[0]
+	for _, ecNode := range allEcNodes {
+		// resume on the same volume server, with the shards copied before
+		if ecNode.info.Id == step.Node && ecNode.freeEcSlot >= scheme.TotalShards() {
+			rebuilder = ecNode
 		}
+	}
 
-		if err := rebuildOneEcVolume(commandEnv, allEcNodes[0], collection, vid, locations, writer, applyChanges); err != nil {
-			return err
-		}
+	if rebuilder.freeEcSlot < scheme.TotalShards() {
+		return fmt.Errorf("disk space is not enough")
 	}
 
-	return nil
+	step.Node = rebuilder.info.Id
+	if err := save(); err != nil {
+		return err
+	}
+	return rebuildOneEcVolume(commandEnv, rebuilder, step.Collection, vid, scheme, locations, writer, applyChanges)
 }
 
-func rebuildOneEcVolume(commandEnv *CommandEnv, rebuilder *EcNode, collection string, volumeId needle.VolumeId, locations EcShardLocations, writer io.Writer, applyChanges bool) error {
+func rebuildOneEcVolume(commandEnv *CommandEnv, rebuilder *EcNode, collection string, volumeId needle.VolumeId, scheme erasure_coding.Scheme, locations EcShardLocations, writer io.Writer, applyChanges bool) error {
 
 	if !commandEnv.isLocked() {
 		return fmt.Errorf("lock is lost")
 	}
 
-	fmt.Printf("rebuildOneEcVolume %s %d\n", collection, volumeId)
+	fmt.Fprintf(writer, "rebuildOneEcVolume %s %d %s\n", collection, volumeId, scheme)
 
 	// collect shard files to rebuilder local disk
 	var generatedShardIds []uint32
-	copiedShardIds, _, err := prepareDataToRecover(commandEnv, rebuilder, collection, volumeId, locations, writer, applyChanges)
+	copiedShardIds, _, err := prepareDataToRecover(commandEnv, rebuilder, collection, volumeId, scheme, locations, writer, applyChanges)
 	if err != nil {
 		return err
 	}
@@ -147,7 +220,7 @@ func rebuildOneEcVolume(commandEnv *CommandEnv, rebuilder *EcNode, collection st
 		// clean up working files
 
 		// ask the rebuilder to delete the copied shards
-		err = sourceServerDeleteEcShards(commandEnv.option.GrpcDialOption, collection, volumeId, pb.NewServerAddressFromDataNode(rebuilder.info), copiedShardIds)
+		err = sourceServerDeleteEcShards(commandEnv.option.GrpcDialOption, writer, collection, volumeId, pb.NewServerAddressFromDataNode(rebuilder.info), copiedShardIds)
 		if err != nil {
 			fmt.Fprintf(writer, "%s delete copied ec shards %s %d.%v\n", rebuilder.info.Id, collection, volumeId, copiedShardIds)
 		}
@@ -165,12 +238,13 @@ func rebuildOneEcVolume(commandEnv *CommandEnv, rebuilder *EcNode, collection st
 	}
 
 	// mount the generated shards
-	err = mountEcShards(commandEnv.option.GrpcDialOption, collection, volumeId, pb.NewServerAddressFromDataNode(rebuilder.info), generatedShardIds)
+	err = mountEcShards(commandEnv.option.GrpcDialOption, writer, collection, volumeId, pb.NewServerAddressFromDataNode(rebuilder.info), generatedShardIds)
 	if err != nil {
 		return err
 	}
 
 	rebuilder.addEcVolumeShards(volumeId, collection, generatedShardIds)
+	fmt.Fprintf(writer, "%s rebuilt and mounted ec shards %d.%v\n", rebuilder.info.Id, volumeId, generatedShardIds)
 
 	return nil
 }
@@ -190,7 +264,7 @@ func generateMissingShards(grpcDialOption grpc.DialOption, collection string, vo
 	return
 }
 
-func prepareDataToRecover(commandEnv *CommandEnv, rebuilder *EcNode, collection string, volumeId needle.VolumeId, locations EcShardLocations, writer io.Writer, applyBalancing bool) (copiedShardIds []uint32, localShardIds []uint32, err error) {
+func prepareDataToRecover(commandEnv *CommandEnv, rebuilder *EcNode, collection string, volumeId needle.VolumeId, scheme erasure_coding.Scheme, locations EcShardLocations, writer io.Writer, applyBalancing bool) (copiedShardIds []uint32, localShardIds []uint32, err error) {
 
 	needEcxFile := true
 	var localShardBits erasure_coding.ShardBits
@@ -243,7 +317,7 @@ func prepareDataToRecover(commandEnv *CommandEnv, rebuilder *EcNode, collection
 
 	}
 
-	if len(copiedShardIds)+len(localShardIds) >= erasure_coding.DataShardsCount {
+	if len(copiedShardIds)+len(localShardIds) >= scheme.DataShards {
 		return copiedShardIds, localShardIds, nil
 	}
 
@@ -260,7 +334,7 @@ func (ecShardMap EcShardMap) registerEcNode(ecNode *EcNode, collection string) {
 			if shardInfo.Collection == collection {
 				existing, found := ecShardMap[needle.VolumeId(shardInfo.Id)]
 				if !found {
-					existing = make([][]*EcNode, erasure_coding.TotalShardsCount)
+					existing = make([][]*EcNode, erasure_coding.MaxShardCount)
 					ecShardMap[needle.VolumeId(shardInfo.Id)] = existing
 				}
 				for _, shardId := range erasure_coding.ShardBits(shardInfo.EcIndexBits).ShardIds() {
@@ -2,9 +2,11 @@ package shell
 
 import (
 	"fmt"
+	"os"
 	"testing"
 
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 )
 
@@ -15,7 +17,7 @@ func TestCommandEcDistribution(t *testing.T) {
 		newEcNode("dc1", "rack2", "dn2", 100),
 	}
 
-	allocated := balancedEcDistribution(allEcNodes)
+	allocated := balancedEcDistribution(allEcNodes, erasure_coding.TotalShardsCount)
 
 	fmt.Printf("allocated: %+v", allocated)
 }
@@ -28,7 +30,7 @@ func TestCommandEcBalanceSmall(t *testing.T) {
 	}
 
 	racks := collectRacks(allEcNodes)
-	balanceEcVolumes(nil, "c1", allEcNodes, racks, false)
+	balanceEcVolumes(nil, os.Stdout, "c1", allEcNodes, racks, false)
 }
 
 func TestCommandEcBalanceNothingToMove(t *testing.T) {
@@ -43,7 +45,7 @@ func TestCommandEcBalanceNothingToMove(t *testing.T) {
 	}
 
 	racks := collectRacks(allEcNodes)
-	balanceEcVolumes(nil, "c1", allEcNodes, racks, false)
+	balanceEcVolumes(nil, os.Stdout, "c1", allEcNodes, racks, false)
 }
 
 func TestCommandEcBalanceAddNewServers(t *testing.T) {
@@ -60,7 +62,7 @@ func TestCommandEcBalanceAddNewServers(t *testing.T) {
 	}
 
 	racks := collectRacks(allEcNodes)
-	balanceEcVolumes(nil, "c1", allEcNodes, racks, false)
+	balanceEcVolumes(nil, os.Stdout, "c1", allEcNodes, racks, false)
 }
 
 func TestCommandEcBalanceAddNewRacks(t *testing.T) {
@@ -77,7 +79,7 @@ func TestCommandEcBalanceAddNewRacks(t *testing.T) {
 	}
 
 	racks := collectRacks(allEcNodes)
-	balanceEcVolumes(nil, "c1", allEcNodes, racks, false)
+	balanceEcVolumes(nil, os.Stdout, "c1", allEcNodes, racks, false)
 }
 
 func TestCommandEcBalanceVolumeEvenButRackUneven(t *testing.T) {
@@ -119,8 +121,8 @@ func TestCommandEcBalanceVolumeEvenButRackUneven(t *testing.T) {
 	}
 
 	racks := collectRacks(allEcNodes)
-	balanceEcVolumes(nil, "c1", allEcNodes, racks, false)
-	balanceEcRacks(nil, racks, false)
+	balanceEcVolumes(nil, os.Stdout, "c1", allEcNodes, racks, false)
+	balanceEcRacks(nil, os.Stdout, racks, false)
 }
 
 func newEcNode(dc string, rack string, dataNodeId string, freeEcSlot int) *EcNode {
@@ -138,3 +140,15 @@ func newEcNode(dc string, rack string, dataNodeId string, freeEcSlot int) *EcNod
 func (ecNode *EcNode) addEcVolumeAndShardsForTest(vid uint32, collection string, shardIds []uint32) *EcNode {
 	return ecNode.addEcVolumeShards(needle.VolumeId(vid), collection, shardIds)
 }
+
+func TestWeightedShardLimit(t *testing.T) {
+	small1, small2, large := newEcNode("dc1", "rack1", "dn1", 100), newEcNode("dc1", "rack1", "dn2", 100), newEcNode("dc1", "rack1", "dn3", 100)
+	if limit := weightedShardLimit(erasure_coding.TotalShardsCount, []*EcNode{small1, small2, large}); limit(small1) != 5 || limit(large) != 5 {
+		t.Errorf("unweighted limits %d and %d, expected 5", limit(small1), limit(large))
+	}
+	large.info.Weight = 3
+	limit := weightedShardLimit(10, []*EcNode{small1, small2, large})
+	if limit(small1) != 2 || limit(small2) != 2 || limit(large) != 6 {
+		t.Errorf("weighted limits %d, %d and %d, expected 2, 2 and 6", limit(small1), limit(small2), limit(large))
+	}
+}
@@ -0,0 +1,92 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsChecksum{})
+}
+
+type commandFsChecksum struct {
+}
+
+func (c *commandFsChecksum) Name() string {
+	return "fs.checksum"
+}
+
+func (c *commandFsChecksum) Help() string {
+	return `show the checksum of a directory tree, which changes whenever anything inside it changes
+
+	fs.checksum /dir                # the checksum of the directory tree
+	fs.checksum -children /dir      # also the checksums of each file and sub directory
+	fs.checksum -rebuild /dir       # recompute the checksums of the directory tree
+
+	The filer has to run with "-dirChecksums". Compare the checksums of two clusters
+	to find the changed sub directories, without walking the unchanged ones.
+`
+}
+
+func (c *commandFsChecksum) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	checksumCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	includeChildren := checksumCommand.Bool("children", false, "list the checksums of the children")
+	rebuild := checksumCommand.Bool("rebuild", false, "recompute the checksums of the directory tree")
+	if err = checksumCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	path, err := commandEnv.parseUrl(findInputDirectory(checksumCommand.Args()))
+	if err != nil {
+		return err
+	}
+
+	return commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		if *rebuild {
+			resp, err := client.RebuildDirectoryChecksum(context.Background(), &filer_pb.RebuildDirectoryChecksumRequest{
+				Directory: path,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(writer, "%s %s rebuilt\n", resp.Checksum, path)
+			return nil
+		}
+
+		lastFileName := ""
+		for {
+			resp, err := client.GetDirectoryChecksum(context.Background(), &filer_pb.GetDirectoryChecksumRequest{
+				Directory:         path,
+				IncludeChildren:   *includeChildren,
+				StartFromFileName: lastFileName,
+				Limit:             1024,
+			})
+			if err != nil {
+				return err
+			}
+			if lastFileName == "" {
+				if !resp.IsTracked {
+					fmt.Fprintf(writer, "%s is not tracked, run with -rebuild first\n", path)
+					return nil
+				}
+				fmt.Fprintf(writer, "%s %s\n", resp.Checksum, path)
+			}
+			for _, child := range resp.Children {
+				name := child.Name
+				if child.IsDirectory {
+					name += "/"
+				}
+				fmt.Fprintf(writer, "%s   %s\n", child.Checksum, name)
+				lastFileName = child.Name
+			}
+			if len(resp.Children) < 1024 {
+				return nil
+			}
+		}
+	})
+}
@@ -37,6 +37,18 @@ func (c *commandFsConfigure) Help() string {
 	# example: configure adding only 1 physical volume for each bucket collection
 	fs.configure -locationPrefix=/buckets/ -volumeGrowthCount=1
 
+	# example: pack the many small files under a folder into shared chunks
+	fs.configure -locationPrefix=/my/thumbnails/ -packSmallFiles
+
+	# example: compress the compressible files under a folder, and decompress them on read
+	fs.configure -locationPrefix=/my/logs/ -compression=zstd
+
+	# example: share the chunks of the same content, for backups with many duplicated files
+	fs.configure -locationPrefix=/backups/ -dedupe
+
+	# example: encrypt the files of a tenant, with the cipher keys wrapped by its master key in the filer's key manager
+	fs.configure -locationPrefix=/tenants/acme/ -encryptionKeyId=acme
+
 	# apply the changes
 	fs.configure -locationPrefix=/my/folder -collection=abc -apply
 
@@ -59,6 +71,10 @@ func (c *commandFsConfigure) Do(args []string, commandEnv *CommandEnv, writer io
 	dataCenter := fsConfigureCommand.String("dataCenter", "", "assign writes to this dataCenter")
 	rack := fsConfigureCommand.String("rack", "", "assign writes to this rack")
 	dataNode := fsConfigureCommand.String("dataNode", "", "assign writes to this dataNode")
+	packSmallFiles := fsConfigureCommand.Bool("packSmallFiles", false, "let the filer pack small files into shared chunks in the background")
+	compression := fsConfigureCommand.String("compression", "", "[gzip|zstd|none] compress the compressible file content, or never compress")
+	dedupe := fsConfigureCommand.Bool("dedupe", false, "let the files of the same chunk content share the chunks")
+	encryptionKeyId := fsConfigureCommand.String("encryptionKeyId", "", "encrypt the files, with the cipher keys wrapped by this master key, see \"filer.kms\" in filer.toml")
 	volumeGrowthCount := fsConfigureCommand.Int("volumeGrowthCount", 0, "the number of physical volumes to add if no writable volumes")
 	isDelete := fsConfigureCommand.Bool("delete", false, "delete the configuration by locationPrefix")
 	apply := fsConfigureCommand.Bool("apply", false, "update and apply filer configuration")
@@ -85,6 +101,17 @@ func (c *commandFsConfigure) Do(args []string, commandEnv *CommandEnv, writer io
 			DataCenter:        *dataCenter,
 			Rack:              *rack,
 			DataNode:          *dataNode,
+			PackSmallFiles:    *packSmallFiles,
+			Compression:       *compression,
+			Dedupe:            *dedupe,
+			EncryptionKeyId:   *encryptionKeyId,
+		}
+
+		// check compression
+		switch *compression {
+		case "", "gzip", "zstd", "none":
+		default:
+			return fmt.Errorf("unknown compression %s, expecting gzip, zstd or none", *compression)
 		}
 
 		// check collection
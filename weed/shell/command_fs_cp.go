@@ -0,0 +1,102 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsCp{})
+}
+
+type commandFsCp struct {
+}
+
+func (c *commandFsCp) Name() string {
+	return "fs.cp"
+}
+
+func (c *commandFsCp) Help() string {
+	return `copy a file or a folder on the filer, without downloading the content
+
+	fs.cp [-deep] [-preserve] [-resumeFrom=<path>] <source entry> <destination entry>
+
+	fs.cp /dir/file_name /dir2/filename2
+	fs.cp /dir/dir2 /dir3/
+	fs.cp -deep -preserve /dir/dir2 /dir3/new_dir
+
+	By default the copied files share the chunks with the source files, and
+	the shared chunks are only deleted after all files using them are deleted.
+	With -deep, the file content is copied to new chunks.
+	If the copy is interrupted, run it again with the -resumeFrom path printed last.
+
+`
+}
+
+func (c *commandFsCp) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	cpCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	deepCopy := cpCommand.Bool("deep", false, "copy the file content to new chunks")
+	preserve := cpCommand.Bool("preserve", false, "keep the modification time and extended attributes")
+	resumeFrom := cpCommand.String("resumeFrom", "", "resume an interrupted copy after this path, relative to the source")
+	if err = cpCommand.Parse(args); err != nil {
+		return nil
+	}
+	if cpCommand.NArg() != 2 {
+		return fmt.Errorf("need to have 2 arguments")
+	}
+
+	sourcePath, err := commandEnv.parseUrl(cpCommand.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	destinationPath, err := commandEnv.parseUrl(cpCommand.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	_, sourceName := util.FullPath(sourcePath).DirAndName()
+	destinationDir, destinationName := util.FullPath(destinationPath).DirAndName()
+
+	return commandEnv.WithFilerClient(true, func(client filer_pb.SeaweedFilerClient) error {
+
+		targetPath := destinationPath
+		respDestinationLookupEntry, err := filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{
+			Directory: destinationDir,
+			Name:      destinationName,
+		})
+		if err == nil && respDestinationLookupEntry.Entry.IsDirectory {
+			// copy into a directory
+			targetPath = string(util.FullPath(destinationPath).Child(sourceName))
+		}
+
+		stream, err := client.CopyEntries(context.Background(), &filer_pb.CopyEntriesRequest{
+			SourcePath:         sourcePath,
+			TargetPath:         targetPath,
+			DeepCopy:           *deepCopy,
+			PreserveAttributes: *preserve,
+			ResumeFrom:         *resumeFrom,
+		})
+		if err != nil {
+			return err
+		}
+		for {
+			resp, recvErr := stream.Recv()
+			if recvErr != nil {
+				return fmt.Errorf("copy %s => %s: %v", sourcePath, targetPath, recvErr)
+			}
+			if resp.IsCompleted {
+				fmt.Fprintf(writer, "copy: %s => %s, %d entries %d bytes\n", sourcePath, targetPath, resp.CopiedCount, resp.CopiedBytes)
+				return nil
+			}
+			fmt.Fprintf(writer, "copied %d entries %d bytes, -resumeFrom=%s\n", resp.CopiedCount, resp.CopiedBytes, resp.ResumeFrom)
+		}
+	})
+
+}
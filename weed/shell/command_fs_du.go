@@ -1,6 +1,8 @@
 package shell
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"io"
 
@@ -26,16 +28,43 @@ func (c *commandFsDu) Help() string {
 	fs.du /dir
 	fs.du /dir/file_name
 	fs.du /dir/file_prefix
+	fs.du -physical /dir           # count the hard links and the chunks shared by deduplicated files once
+	fs.du -physical -byChild /dir  # also count each file and directory in /dir
+	fs.du -depth 2 /dir            # also count each file and directory down to 2 levels under /dir
+	fs.du -breakdown /dir          # also count the files by collection, owner, and TTL class
+
+	With -physical, -depth or -breakdown, the filer walks the tree itself, and counts both the logical size,
+	and the physical size stored on the volume servers before replication.
+	The chunks shared by multiple children or breakdowns are counted in each of them.
+	The owner is the user name of the file, or else its uid.
 `
 }
 
 func (c *commandFsDu) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
 
-	path, err := commandEnv.parseUrl(findInputDirectory(args))
+	duCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	physical := duCommand.Bool("physical", false, "count the physical size on the filer, with the hard links and shared chunks once")
+	byChild := duCommand.Bool("byChild", false, "with -physical, also count each direct child of the directory")
+	depth := duCommand.Int("depth", 0, "also count each child down to this depth on the filer, 1 is the same as -byChild")
+	breakdown := duCommand.Bool("breakdown", false, "also count the files by collection, owner, and TTL class on the filer")
+	if err = duCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	path, err := commandEnv.parseUrl(findInputDirectory(duCommand.Args()))
 	if err != nil {
 		return err
 	}
 
+	if *physical || *depth > 0 || *breakdown {
+		return duOnFiler(writer, commandEnv, &filer_pb.DiskUsageRequest{
+			Directory: path,
+			ByChild:   *byChild,
+			Depth:     int32(*depth),
+			Breakdown: *breakdown,
+		})
+	}
+
 	if commandEnv.isDirectory(path) {
 		path = path + "/"
 	}
@@ -46,12 +75,19 @@ func (c *commandFsDu) Do(args []string, commandEnv *CommandEnv, writer io.Writer
 
 	if name == "" && err == nil {
 		fmt.Fprintf(writer, "block:%4d\tlogical size:%10d\t%s\n", blockCount, byteCount, dir)
+		commandEnv.setResult(&fsDuResult{Path: dir, BlockCount: blockCount, LogicalSize: byteCount})
 	}
 
 	return
 
 }
 
+type fsDuResult struct {
+	Path        string `json:"path"`
+	BlockCount  uint64 `json:"blockCount"`
+	LogicalSize uint64 `json:"logicalSize"`
+}
+
 func duTraverseDirectory(writer io.Writer, filerClient filer_pb.FilerClient, dir, name string) (blockCount, byteCount uint64, err error) {
 
 	err = filer_pb.ReadDirAllEntries(filerClient, util.FullPath(dir), name, func(entry *filer_pb.Entry, isLast bool) error {
@@ -82,3 +118,40 @@ func duTraverseDirectory(writer io.Writer, filerClient filer_pb.FilerClient, dir
 	})
 	return
 }
+
+func duOnFiler(writer io.Writer, commandEnv *CommandEnv, req *filer_pb.DiskUsageRequest) error {
+	return commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.DiskUsage(context.Background(), req)
+		if err != nil {
+			return err
+		}
+		for _, child := range resp.Children {
+			printDiskUsage(writer, child, child.Path)
+		}
+		printDiskUsage(writer, resp.Total, resp.Total.Path)
+		printDiskUsageBreakdown(writer, "collection", resp.Collections, "(default)")
+		printDiskUsageBreakdown(writer, "owner", resp.Owners, "")
+		printDiskUsageBreakdown(writer, "ttl", resp.Ttls, "(none)")
+		commandEnv.setResult(resp)
+		return nil
+	})
+}
+
+func printDiskUsageBreakdown(writer io.Writer, kind string, usages []*filer_pb.DiskUsageResponse_Usage, emptyKey string) {
+	if len(usages) == 0 {
+		return
+	}
+	fmt.Fprintf(writer, "by %s:\n", kind)
+	for _, usage := range usages {
+		key := usage.Key
+		if key == "" {
+			key = emptyKey
+		}
+		printDiskUsage(writer, usage, fmt.Sprintf("%s %s", kind, key))
+	}
+}
+
+func printDiskUsage(writer io.Writer, usage *filer_pb.DiskUsageResponse_Usage, name string) {
+	fmt.Fprintf(writer, "files:%6d\tlogical size:%10d\tphysical size:%10d\tshared chunks:%4d\thard links:%4d\t%s\n",
+		usage.FileCount, usage.LogicalSize, usage.PhysicalSize, usage.SharedChunkCount, usage.HardLinkFileCount, name)
+}
@@ -0,0 +1,61 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsEncryptionRewrap{})
+}
+
+type commandFsEncryptionRewrap struct {
+}
+
+func (c *commandFsEncryptionRewrap) Name() string {
+	return "fs.encryption.rewrap"
+}
+
+func (c *commandFsEncryptionRewrap) Help() string {
+	return `wrap the cipher keys of the encrypted files again, with new data keys
+
+	fs.encryption.rewrap /tenants/acme/
+
+	The cipher keys of the files under the folders configured with "fs.configure -encryptionKeyId"
+	are wrapped by data keys, which are wrapped by the master key in the filer's key manager.
+	Run this command to re-wrap the files with the currently configured master key, e.g.
+	  * after rotating the master key in the KMS, so the old key version can be retired
+	  * after changing the key id of a folder, so the old master key can be removed
+	  * after configuring a key id for a folder with existing encrypted files
+	The file content is not changed.
+
+`
+}
+
+func (c *commandFsEncryptionRewrap) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsEncryptionRewrapCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	if err = fsEncryptionRewrapCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	path, err := commandEnv.parseUrl(findInputDirectory(fsEncryptionRewrapCommand.Args()))
+	if err != nil {
+		return err
+	}
+
+	return commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.RewrapEncryptionKeys(context.Background(), &filer_pb.RewrapEncryptionKeysRequest{
+			Directory: path,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(writer, "rewrapped %d files under %s\n", resp.RewrappedCount, path)
+		return nil
+	})
+}
@@ -0,0 +1,277 @@
+package shell
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsFind{})
+}
+
+type commandFsFind struct {
+}
+
+func (c *commandFsFind) Name() string {
+	return "fs.find"
+}
+
+func (c *commandFsFind) Help() string {
+	return `find the files and directories matching all the filters, and optionally run a command on each of them
+
+	fs.find -name "*.log" -olderThan 30d /logs                     # the log files not modified for 30 days
+	fs.find -type f -minSize 1GiB -owner alice /home              # the large files of alice
+	fs.find -regex "/tmp/[0-9]+/" -newerThan 1h                   # by a regular expression of the full path
+	fs.find -xattr user.project=alpha* -ttl none /data            # by an extended attribute, a value glob, and no TTL
+	fs.find -name "*.tmp" -olderThan 7d -exec "fs.rm {}" /data    # remove the matching entries
+	fs.find -name "*.json" -exec "fs.meta.cat {}" /conf           # show the metadata of the matching entries
+
+	The directories are walked from the given directory, or the current directory.
+	The owner is the user name of the entry, or else its uid.
+	The TTL is as in the volume TTLs, e.g. 3d or 1w, or none.
+	The -exec command runs after the walk, once for each matching entry, with {} replaced by its full path,
+	and on the entries of a directory before the directory itself. The quoted arguments may have spaces.
+	Run without -exec first to check the matching entries.
+`
+}
+
+type fsFindFilter struct {
+	name       string
+	regex      *regexp.Regexp
+	entryType  string
+	minSize    uint64
+	maxSize    uint64
+	olderThan  time.Duration
+	newerThan  time.Duration
+	owner      string
+	xattrKey   string
+	xattrValue string // a glob, empty for any value
+	ttl        string
+}
+
+func (c *commandFsFind) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	findCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	name := findCommand.String("name", "", "the glob of the entry name, e.g. *.log")
+	regex := findCommand.String("regex", "", "the regular expression of the full path")
+	entryType := findCommand.String("type", "", "f for the files, d for the directories, empty for both")
+	minSize := findCommand.String("minSize", "", "the min file size, e.g. 100MiB")
+	maxSize := findCommand.String("maxSize", "", "the max file size, e.g. 1GiB")
+	olderThan := findCommand.String("olderThan", "", "modified longer ago than this, e.g. 30d or 12h")
+	newerThan := findCommand.String("newerThan", "", "modified within this, e.g. 30d or 12h")
+	owner := findCommand.String("owner", "", "the user name or the uid of the owner")
+	xattr := findCommand.String("xattr", "", "the extended attribute key, or key=value with a glob of the value")
+	ttl := findCommand.String("ttl", "", "the TTL, e.g. 7d, or none")
+	maxDepth := findCommand.Int("maxDepth", -1, "the max levels of the directories to walk, -1 for all")
+	exec := findCommand.String("exec", "", "the command to run on each matching entry, with {} for its full path")
+	if err = findCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	filter := &fsFindFilter{
+		name:      *name,
+		entryType: *entryType,
+		owner:     *owner,
+		ttl:       *ttl,
+	}
+	if *regex != "" {
+		if filter.regex, err = regexp.Compile(*regex); err != nil {
+			return fmt.Errorf("parse -regex %s: %v", *regex, err)
+		}
+	}
+	if *minSize != "" {
+		if filter.minSize, err = util.ParseBytes(*minSize); err != nil {
+			return fmt.Errorf("parse -minSize %s: %v", *minSize, err)
+		}
+	}
+	if *maxSize != "" {
+		if filter.maxSize, err = util.ParseBytes(*maxSize); err != nil {
+			return fmt.Errorf("parse -maxSize %s: %v", *maxSize, err)
+		}
+	}
+	if *olderThan != "" {
+		if filter.olderThan, err = parseFindAge(*olderThan); err != nil {
+			return fmt.Errorf("parse -olderThan %s: %v", *olderThan, err)
+		}
+	}
+	if *newerThan != "" {
+		if filter.newerThan, err = parseFindAge(*newerThan); err != nil {
+			return fmt.Errorf("parse -newerThan %s: %v", *newerThan, err)
+		}
+	}
+	filter.xattrKey, filter.xattrValue, _ = strings.Cut(*xattr, "=")
+
+	var execCommand command
+	var execArgs []string
+	if fields := splitCommandArgs(*exec); len(fields) > 0 {
+		for _, cmd := range Commands {
+			if cmd.Name() == fields[0] || cmd.Name() == "fs."+fields[0] {
+				execCommand = cmd
+			}
+		}
+		if execCommand == nil {
+			return fmt.Errorf("unknown command %s in -exec", fields[0])
+		}
+		execArgs = fields[1:]
+	}
+
+	path, err := commandEnv.parseUrl(findInputDirectory(findCommand.Args()))
+	if err != nil {
+		return err
+	}
+
+	found := []util.FullPath{}
+	var foundBytes uint64
+	now := time.Now()
+	err = walkFsFind(commandEnv, util.FullPath(path), *maxDepth, func(p util.FullPath, entry *filer_pb.Entry) {
+		if !filter.match(p, entry, now) {
+			return
+		}
+		found = append(found, p)
+		if !entry.IsDirectory {
+			foundBytes += filer.FileSize(entry)
+		}
+		if execCommand == nil {
+			fmt.Fprintf(writer, "%s\n", p)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if execCommand != nil {
+		err = execFsFind(execCommand, execArgs, found, commandEnv, writer)
+	}
+	fmt.Fprintf(writer, "found %d entries with %d bytes under %s\n", len(found), foundBytes, path)
+	commandEnv.setResult(&fsFindResult{Directory: path, Found: found, FoundBytes: foundBytes})
+	return err
+}
+
+// execFsFind runs the command on the found entries in the reverse order of the walk,
+// so the entries of a directory before the directory, and fails if the command fails on any of them
+func execFsFind(execCommand command, execArgs []string, found []util.FullPath, commandEnv *CommandEnv, writer io.Writer) error {
+	var failed int
+	var firstErr error
+	for i := len(found) - 1; i >= 0; i-- {
+		p := found[i]
+		commandArgs := make([]string, len(execArgs))
+		for j, arg := range execArgs {
+			commandArgs[j] = strings.ReplaceAll(arg, "{}", string(p))
+		}
+		if err := execCommand.Do(commandArgs, commandEnv, writer); err != nil {
+			fmt.Fprintf(writer, "%s %s: %v\n", execCommand.Name(), p, err)
+			if failed++; firstErr == nil {
+				firstErr = fmt.Errorf("%s %s: %v", execCommand.Name(), p, err)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("-exec failed on %d of %d entries, first %v", failed, len(found), firstErr)
+	}
+	return nil
+}
+
+type fsFindResult struct {
+	Directory  string          `json:"directory"`
+	Found      []util.FullPath `json:"found"`
+	FoundBytes uint64          `json:"foundBytes"`
+}
+
+// walkFsFind visits the entries under the directory, each directory before its entries
+func walkFsFind(filerClient filer_pb.FilerClient, dir util.FullPath, maxDepth int, fn func(p util.FullPath, entry *filer_pb.Entry)) error {
+	if maxDepth == 0 {
+		return nil
+	}
+	return filer_pb.ReadDirAllEntries(filerClient, dir, "", func(entry *filer_pb.Entry, isLast bool) error {
+		p := dir.Child(entry.Name)
+		fn(p, entry)
+		if entry.IsDirectory {
+			return walkFsFind(filerClient, p, maxDepth-1, fn)
+		}
+		return nil
+	})
+}
+
+func (f *fsFindFilter) match(p util.FullPath, entry *filer_pb.Entry, now time.Time) bool {
+	if f.name != "" {
+		if matched, _ := filepath.Match(f.name, entry.Name); !matched {
+			return false
+		}
+	}
+	if f.regex != nil && !f.regex.MatchString(string(p)) {
+		return false
+	}
+	switch f.entryType {
+	case "f":
+		if entry.IsDirectory {
+			return false
+		}
+	case "d":
+		if !entry.IsDirectory {
+			return false
+		}
+	}
+	if f.minSize > 0 || f.maxSize > 0 {
+		size := filer.FileSize(entry)
+		if entry.IsDirectory || size < f.minSize || (f.maxSize > 0 && size > f.maxSize) {
+			return false
+		}
+	}
+
+	attr := entry.Attributes
+	if attr == nil {
+		attr = &filer_pb.FuseAttributes{}
+	}
+	age := now.Sub(time.Unix(attr.Mtime, 0))
+	if (f.olderThan > 0 && age <= f.olderThan) || (f.newerThan > 0 && age > f.newerThan) {
+		return false
+	}
+	if f.owner != "" && f.owner != attr.UserName && f.owner != strconv.FormatUint(uint64(attr.Uid), 10) {
+		return false
+	}
+	if f.ttl != "" {
+		ttl := needle.SecondsToTTL(attr.TtlSec)
+		if (f.ttl == "none" && ttl != "") || (f.ttl != "none" && f.ttl != ttl) {
+			return false
+		}
+	}
+	if f.xattrKey != "" {
+		value, found := entry.Extended[f.xattrKey]
+		if !found {
+			// set by the mount
+			value, found = entry.Extended["xattr-"+f.xattrKey]
+		}
+		if !found {
+			return false
+		}
+		if f.xattrValue != "" {
+			if matched, _ := filepath.Match(f.xattrValue, string(value)); !matched {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseFindAge parses a duration, also in days, e.g. "30d"
+func parseFindAge(s string) (time.Duration, error) {
+	if days, isDays := strings.CutSuffix(s, "d"); isDays {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,113 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestFsFindFilter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	entry := &filer_pb.Entry{
+		Name: "app.log",
+		Attributes: &filer_pb.FuseAttributes{
+			FileSize: 2 * 1024 * 1024,
+			Mtime:    now.Add(-40 * 24 * time.Hour).Unix(),
+			Uid:      1000,
+			UserName: "alice",
+			TtlSec:   7 * 24 * 3600,
+		},
+		Extended: map[string][]byte{"xattr-user.project": []byte("alpha-1")},
+	}
+	p := util.FullPath("/logs/2023/app.log")
+
+	matching := []*fsFindFilter{
+		{},
+		{name: "*.log", entryType: "f"},
+		{regex: regexp.MustCompile("^/logs/[0-9]+/")},
+		{minSize: 1024 * 1024, maxSize: 4 * 1024 * 1024},
+		{olderThan: 30 * 24 * time.Hour, newerThan: 60 * 24 * time.Hour},
+		{owner: "alice"},
+		{owner: "1000"},
+		{ttl: "1w"},
+		{xattrKey: "user.project", xattrValue: "alpha*"},
+		{xattrKey: "user.project"},
+	}
+	for i, f := range matching {
+		if !f.match(p, entry, now) {
+			t.Errorf("filter %d %+v does not match", i, f)
+		}
+	}
+
+	notMatching := []*fsFindFilter{
+		{name: "*.txt"},
+		{entryType: "d"},
+		{regex: regexp.MustCompile("^/tmp/")},
+		{minSize: 4 * 1024 * 1024},
+		{maxSize: 1024 * 1024},
+		{olderThan: 60 * 24 * time.Hour},
+		{newerThan: 30 * 24 * time.Hour},
+		{owner: "bob"},
+		{ttl: "none"},
+		{xattrKey: "user.project", xattrValue: "beta*"},
+		{xattrKey: "user.team"},
+	}
+	for i, f := range notMatching {
+		if f.match(p, entry, now) {
+			t.Errorf("filter %d %+v matches", i, f)
+		}
+	}
+
+	if age, err := parseFindAge("30d"); err != nil || age != 30*24*time.Hour {
+		t.Errorf("parse 30d: %v %v", age, err)
+	}
+	if age, err := parseFindAge("90m"); err != nil || age != 90*time.Minute {
+		t.Errorf("parse 90m: %v %v", age, err)
+	}
+}
+
+type recordingCommand struct {
+	runs    []string
+	failing string
+}
+
+func (c *recordingCommand) Name() string { return "fs.record" }
+func (c *recordingCommand) Help() string { return "" }
+func (c *recordingCommand) Do(args []string, commandEnv *CommandEnv, writer io.Writer) error {
+	c.runs = append(c.runs, strings.Join(args, "|"))
+	if args[len(args)-1] == c.failing {
+		return fmt.Errorf("failed")
+	}
+	return nil
+}
+
+func TestExecFsFind(t *testing.T) {
+	args := splitCommandArgs(`fs.record -m "a b" '{}'`)
+	if strings.Join(args, "|") != "fs.record|-m|a b|{}" {
+		t.Fatalf("split args %q", args)
+	}
+
+	// the walk visits each directory before its entries
+	found := []util.FullPath{"/d", "/d/a", "/d/sub", "/d/sub/b"}
+	c := &recordingCommand{failing: "/d/a"}
+	var out bytes.Buffer
+	err := execFsFind(c, args[1:], found, &CommandEnv{}, &out)
+	if expected := "-m|a b|/d/sub/b -m|a b|/d/sub -m|a b|/d/a -m|a b|/d"; strings.Join(c.runs, " ") != expected {
+		t.Errorf("ran %v, expected %s", c.runs, expected)
+	}
+	if err == nil || !strings.Contains(err.Error(), "1 of 4") {
+		t.Errorf("failure on /d/a: %v", err)
+	}
+
+	c.failing = ""
+	if err = execFsFind(c, args[1:], found, &CommandEnv{}, &out); err != nil {
+		t.Errorf("no failure: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsFindXattr{})
+}
+
+type commandFsFindXattr struct {
+}
+
+func (c *commandFsFindXattr) Name() string {
+	return "fs.find.xattr"
+}
+
+func (c *commandFsFindXattr) Help() string {
+	return `find the entries with an extended attribute value, by the index of the filer
+
+	fs.find.xattr -key=user.project -value=alpha /data      # the entries under /data with user.project=alpha
+	fs.find.xattr -key=Seaweed-Team -value=infra            # the entries under the current directory
+	fs.find.xattr -key=user.project -rebuild                # index the existing entries, after adding an indexed key
+
+	The filer only indexes the keys set by "weed filer -xattrIndex.keys=...".
+	The extended attributes set by the mount are also found by their names without the "xattr-" prefix.
+
+`
+}
+
+func (c *commandFsFindXattr) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	findCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	key := findCommand.String("key", "", "the extended attribute key")
+	value := findCommand.String("value", "", "the extended attribute value")
+	limit := findCommand.Int("limit", 0, "find at most this many entries, 0 for the filer listing limit")
+	rebuild := findCommand.Bool("rebuild", false, "index the extended attribute of all existing entries")
+	if err = findCommand.Parse(args); err != nil {
+		return nil
+	}
+	if *key == "" {
+		return fmt.Errorf("missing -key")
+	}
+
+	path, err := commandEnv.parseUrl(findInputDirectory(findCommand.Args()))
+	if err != nil {
+		return err
+	}
+
+	return commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		if *rebuild {
+			resp, err := client.RebuildXattrIndex(context.Background(), &filer_pb.RebuildXattrIndexRequest{
+				Key: *key,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(writer, "indexed %s of %d entries\n", *key, resp.IndexedCount)
+			return nil
+		}
+
+		stream, err := client.FindEntriesByXattr(context.Background(), &filer_pb.FindEntriesByXattrRequest{
+			Key:       *key,
+			Value:     *value,
+			Directory: path,
+			Limit:     uint32(*limit),
+		})
+		if err != nil {
+			return err
+		}
+		var count int
+		for {
+			resp, recvErr := stream.Recv()
+			if recvErr == io.EOF {
+				break
+			}
+			if recvErr != nil {
+				return recvErr
+			}
+			count++
+			fmt.Fprintf(writer, "%s\n", util.NewFullPath(resp.Directory, resp.Entry.Name))
+		}
+		fmt.Fprintf(writer, "found %d entries with %s=%s under %s\n", count, *key, *value, path)
+		return nil
+	})
+}
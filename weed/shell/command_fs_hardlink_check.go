@@ -0,0 +1,97 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsHardLinkCheck{})
+}
+
+type commandFsHardLinkCheck struct {
+}
+
+func (c *commandFsHardLinkCheck) Name() string {
+	return "fs.hardlink.check"
+}
+
+func (c *commandFsHardLinkCheck) Help() string {
+	return `check the reference counts of all hard links
+
+	fs.hardlink.check           # report the problems only
+	fs.hardlink.check -repair   # fix the counts, restore dangling hard links, and delete orphaned ones
+
+	The filer walks the whole tree to count the entries linked to each hard link, and finds:
+	  * miscounted: the count differs from the linked entries, so the content could be leaked or deleted too early
+	  * dangling: the linked entries refer to a missing hard link, which is restored from the linked entries
+	  * orphaned: no entry links to the hard link, so its chunks can be reclaimed
+	The orphaned hard links are found only on the filer stores able to list hard links, e.g. leveldb2.
+
+	Run it when the hard links are not being changed, e.g. as a maintenance script on master:
+	  [master.maintenance]
+	  scripts = """
+	    fs.hardlink.check -repair
+	  """
+
+`
+}
+
+func (c *commandFsHardLinkCheck) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsHardLinkCheckCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	repair := fsHardLinkCheckCommand.Bool("repair", false, "fix the counts, restore dangling hard links, and delete orphaned ones with their chunks")
+	if err = fsHardLinkCheckCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	return commandEnv.WithFilerClient(true, func(client filer_pb.SeaweedFilerClient) error {
+		stream, err := client.CheckHardLinks(context.Background(), &filer_pb.CheckHardLinksRequest{
+			Repair: *repair,
+		})
+		if err != nil {
+			return err
+		}
+		for {
+			resp, recvErr := stream.Recv()
+			if recvErr != nil {
+				if recvErr == io.EOF {
+					return nil
+				}
+				return recvErr
+			}
+			if problem := resp.Problem; problem != nil {
+				printHardLinkProblem(writer, problem)
+			}
+			if summary := resp.Summary; summary != nil {
+				fmt.Fprintf(writer, "%d hard links: %d miscounted, %d dangling, %d orphaned, %s reclaimable, %d repaired\n",
+					summary.HardLinks, summary.Miscounted, summary.Dangling, summary.Orphaned,
+					util.BytesToHumanReadable(summary.ReclaimableBytes), summary.Repaired)
+				if !summary.OrphansChecked {
+					fmt.Fprintf(writer, "orphaned hard links are not checked, since the filer store can not list hard links\n")
+				}
+			}
+		}
+	})
+}
+
+func printHardLinkProblem(writer io.Writer, problem *filer_pb.CheckHardLinksResponse_Problem) {
+	status := ""
+	if problem.Repaired {
+		status = " repaired"
+	}
+	switch problem.Kind {
+	case "miscounted":
+		fmt.Fprintf(writer, "miscounted hard link %x: count %d, linked by %d: %s%s\n", problem.HardLinkId, problem.Counter, problem.References, strings.Join(problem.Paths, " "), status)
+	case "dangling":
+		fmt.Fprintf(writer, "dangling hard link %x, linked by %d: %s%s\n", problem.HardLinkId, problem.References, strings.Join(problem.Paths, " "), status)
+	default:
+		fmt.Fprintf(writer, "%s hard link %x: count %d, %s reclaimable%s\n", problem.Kind, problem.HardLinkId, problem.Counter, util.BytesToHumanReadable(problem.ReclaimableBytes), status)
+	}
+}
@@ -61,6 +61,7 @@ func (c *commandFsLs) Do(args []string, commandEnv *CommandEnv, writer io.Writer
 
 	dir, name := util.FullPath(path).DirAndName()
 	entryCount := 0
+	entries := []*fsLsEntry{}
 
 	err = filer_pb.ReadDirAllEntries(commandEnv, util.FullPath(dir), name, func(entry *filer_pb.Entry, isLast bool) error {
 
@@ -69,6 +70,9 @@ func (c *commandFsLs) Do(args []string, commandEnv *CommandEnv, writer io.Writer
 		}
 
 		entryCount++
+		if commandEnv.isJsonOutput() {
+			entries = append(entries, newFsLsEntry(util.FullPath(dir).Child(entry.Name), entry))
+		}
 
 		if isLongFormat {
 			fileMode := os.FileMode(entry.Attributes.FileMode)
@@ -106,6 +110,33 @@ func (c *commandFsLs) Do(args []string, commandEnv *CommandEnv, writer io.Writer
 	if isLongFormat && err == nil {
 		fmt.Fprintf(writer, "total %d\n", entryCount)
 	}
+	commandEnv.setResult(entries)
 
 	return
 }
+
+type fsLsEntry struct {
+	Path        string `json:"path"`
+	IsDirectory bool   `json:"isDirectory"`
+	Size        uint64 `json:"size"`
+	Chunks      int    `json:"chunks"`
+	Mode        string `json:"mode"`
+	Uid         uint32 `json:"uid"`
+	Gid         uint32 `json:"gid"`
+	UserName    string `json:"userName,omitempty"`
+	Mtime       int64  `json:"mtime"`
+}
+
+func newFsLsEntry(p util.FullPath, entry *filer_pb.Entry) *fsLsEntry {
+	e := &fsLsEntry{
+		Path:        string(p),
+		IsDirectory: entry.IsDirectory,
+		Size:        filer.FileSize(entry),
+		Chunks:      len(entry.GetChunks()),
+	}
+	if attr := entry.Attributes; attr != nil {
+		e.Mode = os.FileMode(attr.FileMode).String()
+		e.Uid, e.Gid, e.UserName, e.Mtime = attr.Uid, attr.Gid, attr.UserName, attr.Mtime
+	}
+	return e
+}
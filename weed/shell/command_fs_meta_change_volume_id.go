@@ -69,7 +69,7 @@ func (c *commandFsMetaChangeVolumeId) Do(args []string, commandEnv *CommandEnv,
 				var hasChanges bool
 				for _, chunk := range entry.Chunks {
 					if chunk.IsChunkManifest {
-						fmt.Printf("Change volume id for large file is not implemented yet: %s/%s\n", parentPath, entry.Name)
+						fmt.Fprintf(writer, "Change volume id for large file is not implemented yet: %s/%s\n", parentPath, entry.Name)
 						return
 					}
 					chunkVolumeId := chunk.Fid.VolumeId
@@ -86,7 +86,7 @@ func (c *commandFsMetaChangeVolumeId) Do(args []string, commandEnv *CommandEnv,
 							Directory: string(parentPath),
 							Entry:     entry,
 						}); updateErr != nil {
-							fmt.Printf("failed to update %s/%s: %v\n", parentPath, entry.Name, updateErr)
+							fmt.Fprintf(writer, "failed to update %s/%s: %v\n", parentPath, entry.Name, updateErr)
 						}
 					}
 				}
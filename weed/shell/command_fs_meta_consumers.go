@@ -0,0 +1,90 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsMetaConsumers{})
+}
+
+type commandFsMetaConsumers struct {
+}
+
+func (c *commandFsMetaConsumers) Name() string {
+	return "fs.meta.consumers"
+}
+
+func (c *commandFsMetaConsumers) Help() string {
+	return `list, rewind, or delete the committed offsets of the named metadata consumers
+
+	fs.meta.consumers                                  # list the consumers and their offsets
+	fs.meta.consumers -name=backup -tsNs=1700000000000000000  # move the consumer to the offset
+	fs.meta.consumers -name=backup -delete             # forget the consumer
+
+	A named consumer subscribes to the metadata changes with a consumer name, and resumes after
+	its committed offset when subscribing again.
+
+`
+}
+
+func (c *commandFsMetaConsumers) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsMetaConsumersCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	name := fsMetaConsumersCommand.String("name", "", "the consumer name")
+	tsNs := fsMetaConsumersCommand.Int64("tsNs", 0, "move the consumer to this offset, in nanoseconds")
+	isDelete := fsMetaConsumersCommand.Bool("delete", false, "delete the consumer offset")
+	if err = fsMetaConsumersCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if (*tsNs != 0 || *isDelete) && *name == "" {
+		return fmt.Errorf("missing -name")
+	}
+
+	return commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		if *isDelete {
+			if _, err := client.DeleteConsumerOffset(context.Background(), &filer_pb.DeleteConsumerOffsetRequest{
+				ConsumerName: *name,
+			}); err != nil {
+				return err
+			}
+			fmt.Fprintf(writer, "deleted consumer %s\n", *name)
+			return nil
+		}
+		if *tsNs != 0 {
+			resp, err := client.CommitConsumerOffset(context.Background(), &filer_pb.CommitConsumerOffsetRequest{
+				ConsumerName: *name,
+				TsNs:         *tsNs,
+				Rewind:       true,
+			})
+			if err != nil {
+				return err
+			}
+			printConsumerOffset(writer, resp.Offset)
+			return nil
+		}
+
+		resp, err := client.ListConsumerOffsets(context.Background(), &filer_pb.ListConsumerOffsetsRequest{})
+		if err != nil {
+			return err
+		}
+		for _, offset := range resp.Offsets {
+			if *name == "" || offset.ConsumerName == *name {
+				printConsumerOffset(writer, offset)
+			}
+		}
+		return nil
+	})
+}
+
+func printConsumerOffset(writer io.Writer, offset *filer_pb.ConsumerOffset) {
+	fmt.Fprintf(writer, "%s\toffset %d (%v)\tcommitted at %v\n", offset.ConsumerName, offset.TsNs,
+		time.Unix(0, offset.TsNs).Format(time.RFC3339), time.Unix(0, offset.CommittedAtNs).Format(time.RFC3339))
+}
@@ -0,0 +1,279 @@
+package shell
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/util/parquet"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsMetaExport{})
+}
+
+type commandFsMetaExport struct {
+}
+
+func (c *commandFsMetaExport) Name() string {
+	return "fs.meta.export"
+}
+
+func (c *commandFsMetaExport) Help() string {
+	return `export the directory and file meta data as parquet files, for analytics
+
+	fs.meta.export /                        # export from the root
+	fs.meta.export -o /data/namespace /     # export from the root, output to the local /data/namespace directory
+	fs.meta.export /path/to/export          # export from the directory /path/to/export
+
+	The output is partitioned by collection, as <output>/collection=<name>/part-<n>.parquet,
+	with one row per file or directory:
+		path, is_directory, size, mtime, crtime, mode, uid, gid, owner,
+		replication, ttl_sec, chunk_count, xattrs (as a json object)
+
+	e.g. with DuckDB:
+		SELECT collection, sum(size) FROM read_parquet('/data/namespace/*/*.parquet', hive_partitioning = true) GROUP BY 1;
+
+`
+}
+
+var metaExportColumns = []parquet.Column{
+	{Name: "path", Type: parquet.String},
+	{Name: "is_directory", Type: parquet.Boolean},
+	{Name: "size", Type: parquet.Int64},
+	{Name: "mtime", Type: parquet.TimestampMillis},
+	{Name: "crtime", Type: parquet.TimestampMillis},
+	{Name: "mode", Type: parquet.Int32},
+	{Name: "uid", Type: parquet.Int32},
+	{Name: "gid", Type: parquet.Int32},
+	{Name: "owner", Type: parquet.String},
+	{Name: "replication", Type: parquet.String},
+	{Name: "ttl_sec", Type: parquet.Int32},
+	{Name: "chunk_count", Type: parquet.Int32},
+	{Name: "xattrs", Type: parquet.String},
+}
+
+func (c *commandFsMetaExport) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsMetaExportCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	verbose := fsMetaExportCommand.Bool("v", false, "print out each processed files")
+	outputDir := fsMetaExportCommand.String("o", "", "output the parquet files to this local directory")
+	rowsPerFile := fsMetaExportCommand.Int("rowsPerFile", 1000000, "start a new parquet file after this many rows")
+	rowGroupSize := fsMetaExportCommand.Int("rowGroupSize", 100000, "rows per parquet row group")
+	if err = fsMetaExportCommand.Parse(args); err != nil {
+		return err
+	}
+
+	path, parseErr := commandEnv.parseUrl(findInputDirectory(fsMetaExportCommand.Args()))
+	if parseErr != nil {
+		return parseErr
+	}
+
+	dir := *outputDir
+	if dir == "" {
+		t := time.Now()
+		dir = fmt.Sprintf("%s-%4d%02d%02d-%02d%02d%02d.parquet",
+			commandEnv.option.FilerAddress.ToHttpAddress(), t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
+	}
+
+	// entries do not record the collection, so resolve it as the filer does when writing
+	fc, err := filer.ReadFilerConf(commandEnv.option.FilerAddress, commandEnv.option.GrpcDialOption, commandEnv.MasterClient)
+	if err != nil {
+		return err
+	}
+	filerBucketsPath, err := readFilerBucketsPath(commandEnv)
+	if err != nil {
+		return fmt.Errorf("read buckets: %v", err)
+	}
+
+	exporter := &metaExporter{
+		commandEnv:   commandEnv,
+		filerConf:    fc,
+		bucketsPath:  filerBucketsPath,
+		dir:          dir,
+		rowsPerFile:  *rowsPerFile,
+		rowGroupSize: *rowGroupSize,
+		partitions:   make(map[string]*metaExportPartition),
+	}
+
+	err = doTraverseBfsAndSaving(commandEnv, writer, path, *verbose, func(entry *filer_pb.FullEntry, outputChan chan interface{}) error {
+		outputChan <- entry
+		return nil
+	}, func(outputChan chan interface{}) {
+		for item := range outputChan {
+			if exporter.err != nil {
+				continue
+			}
+			exporter.err = exporter.add(item.(*filer_pb.FullEntry))
+		}
+		if closeErr := exporter.close(); exporter.err == nil {
+			exporter.err = closeErr
+		}
+	})
+	if err == nil {
+		err = exporter.err
+	}
+
+	if err == nil {
+		fmt.Fprintf(writer, "meta data for http://%s%s is exported to %s\n", commandEnv.option.FilerAddress.ToHttpAddress(), path, dir)
+	}
+
+	return err
+}
+
+type metaExportPartition struct {
+	file    *os.File
+	writer  *parquet.Writer
+	rows    int
+	fileSeq int
+}
+
+type metaExporter struct {
+	commandEnv   *CommandEnv
+	filerConf    *filer.FilerConf
+	bucketsPath  string
+	dir          string
+	rowsPerFile  int
+	rowGroupSize int
+	partitions   map[string]*metaExportPartition
+	err          error
+}
+
+func (e *metaExporter) add(fullEntry *filer_pb.FullEntry) error {
+	entry := fullEntry.Entry
+	attr := entry.Attributes
+	if attr == nil {
+		attr = &filer_pb.FuseAttributes{}
+	}
+
+	fullPath := util.NewFullPath(fullEntry.Dir, entry.Name)
+	collection, replication := e.storageOf(fullPath)
+	if collection == "" {
+		collection = "_default"
+	}
+	partition, err := e.partition(collection)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	if !entry.IsDirectory {
+		size = int64(filer.FileSize(entry))
+	}
+	err = partition.writer.Write(
+		string(fullPath),
+		entry.IsDirectory,
+		size,
+		time.Unix(attr.Mtime, 0),
+		time.Unix(attr.Crtime, 0),
+		int32(attr.FileMode),
+		int32(attr.Uid),
+		int32(attr.Gid),
+		attr.UserName,
+		replication,
+		attr.TtlSec,
+		int32(len(entry.GetChunks())),
+		extendedToJson(entry.Extended),
+	)
+	if err != nil {
+		return err
+	}
+
+	partition.rows++
+	if partition.rows >= e.rowsPerFile {
+		if err = partition.close(); err != nil {
+			return err
+		}
+		partition.writer = nil
+	}
+	return nil
+}
+
+// storageOf resolves the collection and replication from the path rules, or else from the bucket.
+func (e *metaExporter) storageOf(fullPath util.FullPath) (collection, replication string) {
+	rule := e.filerConf.MatchStorageRule(string(fullPath))
+	collection, replication = rule.Collection, rule.Replication
+	if collection == "" && strings.HasPrefix(string(fullPath), e.bucketsPath+"/") {
+		bucketAndPath := string(fullPath)[len(e.bucketsPath)+1:]
+		if bucket, _, found := strings.Cut(bucketAndPath, "/"); found {
+			collection = getCollectionName(e.commandEnv, bucket)
+		}
+	}
+	return
+}
+
+func (e *metaExporter) partition(collection string) (*metaExportPartition, error) {
+	partition, found := e.partitions[collection]
+	if !found {
+		partition = &metaExportPartition{}
+		e.partitions[collection] = partition
+	}
+	if partition.writer != nil {
+		return partition, nil
+	}
+
+	partitionDir := filepath.Join(e.dir, "collection="+collection)
+	if err := os.MkdirAll(partitionDir, 0755); err != nil {
+		return nil, err
+	}
+	fileName := filepath.Join(partitionDir, fmt.Sprintf("part-%05d.parquet", partition.fileSeq))
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file %s: %v", fileName, err)
+	}
+	writer, err := parquet.NewWriter(file, metaExportColumns, e.rowGroupSize)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	partition.file, partition.writer, partition.rows = file, writer, 0
+	partition.fileSeq++
+	return partition, nil
+}
+
+func (p *metaExportPartition) close() error {
+	if p.writer == nil {
+		return nil
+	}
+	if err := p.writer.Close(); err != nil {
+		p.file.Close()
+		return fmt.Errorf("write %s: %v", p.file.Name(), err)
+	}
+	return p.file.Close()
+}
+
+func (e *metaExporter) close() (err error) {
+	for _, partition := range e.partitions {
+		if closeErr := partition.close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return
+}
+
+// extendedToJson keeps the text values, and base64 encodes the binary values with a "base64:" prefix.
+func extendedToJson(extended map[string][]byte) string {
+	if len(extended) == 0 {
+		return "{}"
+	}
+	values := make(map[string]string, len(extended))
+	for k, v := range extended {
+		if utf8.Valid(v) {
+			values[k] = string(v)
+		} else {
+			values[k] = "base64:" + base64.StdEncoding.EncodeToString(v)
+		}
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
@@ -0,0 +1,67 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsShardDir{})
+}
+
+type commandFsShardDir struct {
+}
+
+func (c *commandFsShardDir) Name() string {
+	return "fs.shardDir"
+}
+
+func (c *commandFsShardDir) Help() string {
+	return `convert a large directory to keep its children in hash-partitioned shards
+
+	fs.shardDir -shards=64 /path/to/large/dir
+
+	The conversion runs online on the filer. The directory can be used normally during and after
+	the conversion, and clients still see one flat directory. Listing a sharded directory
+	merges the listings of all shards, so use it for directories with millions of children.
+`
+}
+
+func (c *commandFsShardDir) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	shardDirCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	shardCount := shardDirCommand.Int("shards", 64, "number of shards")
+	if err = shardDirCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	path, err := commandEnv.parseUrl(findInputDirectory(shardDirCommand.Args()))
+	if err != nil {
+		return err
+	}
+
+	return commandEnv.WithFilerClient(true, func(client filer_pb.SeaweedFilerClient) error {
+		stream, err := client.ShardDirectory(context.Background(), &filer_pb.ShardDirectoryRequest{
+			Directory:  path,
+			ShardCount: int32(*shardCount),
+		})
+		if err != nil {
+			return err
+		}
+		for {
+			resp, recvErr := stream.Recv()
+			if recvErr != nil {
+				return recvErr
+			}
+			if resp.IsCompleted {
+				fmt.Fprintf(writer, "%s is sharded into %d shards\n", path, *shardCount)
+				return nil
+			}
+			fmt.Fprintf(writer, "moved %d entries\n", resp.MovedCount)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsStoreMigration{})
+}
+
+type commandFsStoreMigration struct {
+}
+
+func (c *commandFsStoreMigration) Name() string {
+	return "fs.store.migration"
+}
+
+func (c *commandFsStoreMigration) Help() string {
+	return `show the progress of the online filer store migration, or cut over to the new filer store
+
+	fs.store.migration            # show the progress
+	fs.store.migration -cutOver   # switch the reads to the new filer store, once verified
+
+	The migration is configured in filer.toml, with "migrate = true" for the new filer store.
+	The changes are written to both filer stores, while the existing entries are copied, and then
+	verified. After the cut over, enable only the new filer store in filer.toml, and restart the filer.
+
+`
+}
+
+func (c *commandFsStoreMigration) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsStoreMigrationCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	cutOver := fsStoreMigrationCommand.Bool("cutOver", false, "switch the reads to the new filer store")
+	if err = fsStoreMigrationCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if *cutOver {
+		if err = commandEnv.confirmIsLocked(args); err != nil {
+			return
+		}
+	}
+
+	return commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.StoreMigration(context.Background(), &filer_pb.StoreMigrationRequest{
+			CutOver: *cutOver,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(writer, "migrate %s to %s since %v: %s\n", resp.FromStore, resp.ToStore, time.Unix(0, resp.StartedAtNs).Format(time.RFC3339), resp.Phase)
+		fmt.Fprintf(writer, "  copied entries:   %d\n", resp.CopiedEntries)
+		fmt.Fprintf(writer, "  verified entries: %d\n", resp.VerifiedEntries)
+		fmt.Fprintf(writer, "  repaired entries: %d\n", resp.RepairedEntries)
+		fmt.Fprintf(writer, "  failed writes:    %d\n", resp.FailedWrites)
+		if resp.LastError != "" {
+			fmt.Fprintf(writer, "  last error:       %s\n", resp.LastError)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,102 @@
+package shell
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/replication"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsSyncConflicts{})
+}
+
+type commandFsSyncConflicts struct {
+}
+
+func (c *commandFsSyncConflicts) Name() string {
+	return "fs.sync.conflicts"
+}
+
+func (c *commandFsSyncConflicts) Help() string {
+	return `report the conflicts met by "weed filer.sync" when writing to this filer
+
+	fs.sync.conflicts                             # list all recorded conflicts
+	fs.sync.conflicts -resolution=queued          # list the changes queued for manual resolution
+	fs.sync.conflicts -path=/shared/              # list the conflicts under a path
+	fs.sync.conflicts -v                          # print each conflict record as json
+	fs.sync.conflicts -path=/shared/ -purge       # remove the listed records, after resolving them
+
+	The resolutions are:
+		apply_incoming  : the incoming change overwrote the target version, by "lww"
+		keep_existing   : the target version was kept, by "lww" or "rename"
+		conflicted_copy : the incoming version was written next to the target version, by "rename"
+		queued          : the target version was kept, and the incoming change waits for manual resolution, by "queue"
+
+`
+}
+
+func (c *commandFsSyncConflicts) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsSyncConflictsCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	resolution := fsSyncConflictsCommand.String("resolution", "", "only the conflicts of this resolution: apply_incoming, keep_existing, conflicted_copy, or queued")
+	pathPrefix := fsSyncConflictsCommand.String("path", "", "only the conflicts of the paths with this prefix")
+	verbose := fsSyncConflictsCommand.Bool("v", false, "print each conflict record as json")
+	purge := fsSyncConflictsCommand.Bool("purge", false, "remove the listed conflict records")
+	if err = fsSyncConflictsCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	var names []string
+	counts := make(map[string]int)
+	err = replication.ReadConflictRecords(commandEnv, func(name string, record *replication.ConflictRecord) error {
+		if *resolution != "" && record.Resolution != *resolution {
+			return nil
+		}
+		if !strings.HasPrefix(record.Path, *pathPrefix) {
+			return nil
+		}
+		names = append(names, name)
+		counts[record.Resolution]++
+		if *verbose {
+			data, _ := json.MarshalIndent(record, "", "  ")
+			fmt.Fprintf(writer, "%s\n", data)
+			return nil
+		}
+		fmt.Fprintf(writer, "%s %-15s %-9s %s from %s\n",
+			time.Unix(0, record.EventTsNs).Format(time.RFC3339), record.Resolution, record.Strategy, record.Path, record.SourceFiler)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(writer, "total %d conflicts", len(names))
+	for _, r := range []replication.ConflictResolution{replication.ApplyIncoming, replication.KeepExisting, replication.WriteConflictedCopy, replication.QueueForManualResolution} {
+		if count := counts[r.String()]; count > 0 {
+			fmt.Fprintf(writer, ", %s:%d", r, count)
+		}
+	}
+	fmt.Fprintln(writer)
+
+	if !*purge || len(names) == 0 {
+		return nil
+	}
+	err = commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		for _, name := range names {
+			if err := replication.DeleteConflictRecord(client, name); err != nil {
+				return fmt.Errorf("remove conflict record %s: %v", name, err)
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		fmt.Fprintf(writer, "removed %d conflict records\n", len(names))
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsUsage{})
+}
+
+type commandFsUsage struct {
+}
+
+func (c *commandFsUsage) Name() string {
+	return "fs.usage"
+}
+
+func (c *commandFsUsage) Help() string {
+	return `show the total file size and file count of each collection and top level directory
+
+	fs.usage              # read the usage statistics maintained by the filer
+	fs.usage -rebuild     # walk the whole tree to count the usage again
+
+	The filer needs to run with "-usageStats" to maintain the usage statistics.
+
+`
+}
+
+func (c *commandFsUsage) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsUsageCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	rebuild := fsUsageCommand.Bool("rebuild", false, "walk the whole tree to count the usage again")
+	if err = fsUsageCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	return commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.GetUsageStats(context.Background(), &filer_pb.GetUsageStatsRequest{
+			Rebuild: *rebuild,
+		})
+		if err != nil {
+			return err
+		}
+		if !resp.IsCounted {
+			fmt.Fprintf(writer, "the usage is still being counted for the first time\n")
+		}
+		printUsages(writer, "collection", resp.Collections)
+		printUsages(writer, "directory", resp.Directories)
+		return nil
+	})
+}
+
+func printUsages(writer io.Writer, kind string, usages []*filer_pb.GetUsageStatsResponse_Usage) {
+	var totalBytes, totalFiles int64
+	for _, usage := range usages {
+		name := usage.Name
+		if name == "" {
+			name = "<default>"
+		}
+		fmt.Fprintf(writer, "%s %-32s %10s %12d bytes %10d files\n", kind, name, util.BytesToHumanReadable(uint64(usage.Bytes)), usage.Bytes, usage.FileCount)
+		totalBytes += usage.Bytes
+		totalFiles += usage.FileCount
+	}
+	fmt.Fprintf(writer, "total %d %s: %d bytes %d files\n", len(usages), kind, totalBytes, totalFiles)
+}
@@ -0,0 +1,114 @@
+package shell
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// With "weed shell -o json", each command prints one JSON object per line instead of its text, so the
+// scripts can parse the output. The object has the printed lines, the error, and the structured result
+// of the commands recording one with setResult, e.g. volume.list, cluster.check, fs.ls, fs.du and fs.find.
+// The other commands have their printed text as the result, so every command has one.
+// The commands print to their writer, never to the standard output, which is the JSON lines stream.
+
+const (
+	OutputText = "text"
+	OutputJson = "json"
+)
+
+type jsonCommandOutput struct {
+	Command string          `json:"command"`
+	Args    []string        `json:"args"`
+	Lines   []string        `json:"lines"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// textResult is the result of the commands without a structured one
+type textResult struct {
+	Output string `json:"output"`
+}
+
+func (ce *CommandEnv) isJsonOutput() bool {
+	return ce.option.Output != nil && *ce.option.Output == OutputJson
+}
+
+// setResult records the structured result of the running command, only kept in the json output mode
+func (ce *CommandEnv) setResult(result interface{}) {
+	if ce.isJsonOutput() {
+		ce.result = result
+	}
+}
+
+// lockedBuffer collects the output of the commands printing from several goroutines
+type lockedBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+// runCommandJson runs the command, and prints its output, result and error as one JSON line
+func runCommandJson(c command, name string, args []string, commandEnv *CommandEnv, writer io.Writer) error {
+	var buf lockedBuffer
+	commandEnv.result = nil
+	var err error
+	if c == nil {
+		err = fmt.Errorf("unknown command: %v", name)
+	} else {
+		err = c.Do(args, commandEnv, &buf)
+		if commandEnv.result == nil {
+			commandEnv.result = &textResult{Output: buf.buf.String()}
+		}
+	}
+	out := toJsonCommandOutput(name, args, buf.buf.String(), commandEnv.result, err)
+	commandEnv.result = nil
+
+	line, _ := json.Marshal(out)
+	fmt.Fprintf(writer, "%s\n", line)
+	return err
+}
+
+func toJsonCommandOutput(name string, args []string, text string, result interface{}, err error) *jsonCommandOutput {
+	out := &jsonCommandOutput{
+		Command: name,
+		Args:    args,
+		Lines:   []string{},
+	}
+	if out.Args == nil {
+		out.Args = []string{}
+	}
+	if text = strings.TrimSuffix(text, "\n"); text != "" {
+		out.Lines = strings.Split(text, "\n")
+	}
+	if result != nil {
+		value, marshalErr := marshalResult(result)
+		if marshalErr != nil && err == nil {
+			err = fmt.Errorf("marshal the result: %v", marshalErr)
+		}
+		out.Result = value
+	}
+	if err != nil {
+		out.Error = err.Error()
+	}
+	return out
+}
+
+// marshalResult marshals the proto messages with protojson, and the others with encoding/json
+func marshalResult(result interface{}) (json.RawMessage, error) {
+	if m, isProto := result.(proto.Message); isProto {
+		return protojson.Marshal(m)
+	}
+	return json.Marshal(result)
+}
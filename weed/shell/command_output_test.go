@@ -0,0 +1,120 @@
+package shell
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+type fakeJsonCommand struct {
+	result interface{}
+	err    error
+}
+
+func (c *fakeJsonCommand) Name() string { return "fake" }
+func (c *fakeJsonCommand) Help() string { return "" }
+func (c *fakeJsonCommand) Do(args []string, commandEnv *CommandEnv, writer io.Writer) error {
+	fmt.Fprintf(writer, "first\nsecond\n")
+	commandEnv.setResult(c.result)
+	return c.err
+}
+
+func TestRunCommandJson(t *testing.T) {
+	output := OutputJson
+	commandEnv := &CommandEnv{option: &ShellOptions{Output: &output}}
+
+	run := func(c command, name string, args ...string) (out struct {
+		Command string          `json:"command"`
+		Args    []string        `json:"args"`
+		Lines   []string        `json:"lines"`
+		Result  json.RawMessage `json:"result"`
+		Error   string          `json:"error"`
+	}) {
+		var buf bytes.Buffer
+		runCommandJson(c, name, args, commandEnv, &buf)
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatalf("parse %q: %v", buf.String(), err)
+		}
+		return
+	}
+
+	out := run(&fakeJsonCommand{result: &fsDuResult{Path: "/a", LogicalSize: 3}}, "fake", "-v")
+	if out.Command != "fake" || len(out.Args) != 1 || len(out.Lines) != 2 || out.Lines[1] != "second" || out.Error != "" {
+		t.Errorf("unexpected output %+v", out)
+	}
+	if string(out.Result) != `{"path":"/a","blockCount":0,"logicalSize":3}` {
+		t.Errorf("result %s", out.Result)
+	}
+
+	out = run(&fakeJsonCommand{result: &filer_pb.DiskUsageResponse_Usage{Path: "/a", FileCount: 2}, err: fmt.Errorf("failed")}, "fake")
+	if string(out.Result) == "" || out.Error != "failed" {
+		t.Errorf("unexpected output %+v", out)
+	}
+	var usage map[string]interface{}
+	if err := json.Unmarshal(out.Result, &usage); err != nil || usage["fileCount"] != "2" {
+		t.Errorf("proto result %s: %v", out.Result, err)
+	}
+
+	out = run(&fakeJsonCommand{}, "fake")
+	if string(out.Result) != `{"output":"first\nsecond\n"}` {
+		t.Errorf("text result %s", out.Result)
+	}
+
+	out = run(nil, "unknown")
+	if out.Error != "unknown command: unknown" || out.Result != nil || len(out.Lines) != 0 {
+		t.Errorf("unexpected output %+v", out)
+	}
+}
+
+// TestCommandsPrintToWriter fails on the commands printing to the standard output, which would corrupt the json output
+func TestCommandsPrintToWriter(t *testing.T) {
+	files, err := filepath.Glob("command_*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if name := selectorName(call.Fun); name == "fmt.Print" || name == "fmt.Printf" || name == "fmt.Println" {
+				t.Errorf("%s: %s prints to the standard output", fset.Position(call.Pos()), name)
+			}
+			for _, arg := range call.Args {
+				if selectorName(arg) == "os.Stdout" {
+					t.Errorf("%s: %s writes to the standard output", fset.Position(call.Pos()), selectorName(call.Fun))
+				}
+			}
+			return true
+		})
+	}
+}
+
+func selectorName(expr ast.Expr) string {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	if x, ok := sel.X.(*ast.Ident); ok {
+		return x.Name + "." + sel.Sel.Name
+	}
+	return ""
+}
@@ -1,6 +1,7 @@
 package shell
 
 import (
+	"os"
 	"testing"
 
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
@@ -255,7 +256,7 @@ func TestBalance(t *testing.T) {
 	volumeReplicas, _ := collectVolumeReplicaLocations(topologyInfo)
 	diskTypes := collectVolumeDiskTypes(topologyInfo)
 
-	if err := balanceVolumeServers(nil, diskTypes, volumeReplicas, volumeServers, 30*1024*1024*1024, "ALL_COLLECTIONS", false); err != nil {
+	if err := balanceVolumeServers(nil, os.Stdout, diskTypes, volumeReplicas, volumeServers, 30*1024*1024*1024, "ALL_COLLECTIONS", false); err != nil {
 		t.Errorf("balance: %v", err)
 	}
 
@@ -264,10 +265,25 @@ func TestBalance(t *testing.T) {
 func TestVolumeSelection(t *testing.T) {
 	topologyInfo := parseOutput(topoData)
 
-	vids, err := collectVolumeIdsForTierChange(nil, topologyInfo, 1000, types.ToDiskType("hdd"), "", 20.0, 0)
+	vids, err := collectVolumeIdsForTierChange(nil, os.Stdout, topologyInfo, 1000, types.ToDiskType("hdd"), "", 20.0, 0)
 	if err != nil {
 		t.Errorf("collectVolumeIdsForTierChange: %v", err)
 	}
 	assert.Equal(t, 378, len(vids))
 
 }
+
+func TestCapacityByWeightedMaxVolumeCount(t *testing.T) {
+	info := &master_pb.DataNodeInfo{DiskInfos: map[string]*master_pb.DiskInfo{"": {MaxVolumeCount: 8, VolumeCount: 8}}}
+	capacityFunc := capacityByMaxVolumeCount(types.HardDriveType)
+	if capacity := capacityFunc(info); capacity != 8 {
+		t.Errorf("unweighted capacity %v", capacity)
+	}
+	info.Weight = 5
+	if capacity := capacityFunc(info); capacity != 40 {
+		t.Errorf("capacity weighted 5 is %v, expected 40", capacity)
+	}
+	if free := capacityByFreeVolumeCount(types.HardDriveType)(info); free != 0 {
+		t.Errorf("free slots %v of the full volume server", free)
+	}
+}
@@ -14,7 +14,16 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/time/rate"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
 )
 
 func init() {
@@ -22,7 +31,8 @@ func init() {
 }
 
 type commandVolumeCheckDisk struct {
-	env *CommandEnv
+	env       *CommandEnv
+	throttler *volumeServerThrottler
 }
 
 func (c *commandVolumeCheckDisk) Name() string {
@@ -40,6 +50,14 @@ func (c *commandVolumeCheckDisk) Help() string {
         append entries in A and not in B to B
         append entries in B and not in A to A
 
+	volume.check.disk -parallel 8 -maxMBps 50 -checkpoint /tmp/check.disk -force
+
+	The volumes are checked in parallel, with the progress on the standard error.
+	With -maxMBps, the bytes read from or written to each volume server are limited per second.
+	With -checkpoint, the volumes checked without errors are recorded in the local file, and skipped
+	when run again, so an interrupted check resumes where it stopped. The file is removed once all
+	the volumes are checked without errors.
+
 `
 }
 
@@ -52,16 +70,24 @@ func (c *commandVolumeCheckDisk) Do(args []string, commandEnv *CommandEnv, write
 	applyChanges := fsckCommand.Bool("force", false, "apply the fix")
 	syncDeletions := fsckCommand.Bool("syncDeleted", false, "sync of deletions the fix")
 	nonRepairThreshold := fsckCommand.Float64("nonRepairThreshold", 0.3, "repair when missing keys is not more than this limit")
+	parallel := fsckCommand.Int("parallel", 4, "how many volumes to check at the same time")
+	maxMBps := fsckCommand.Float64("maxMBps", 0, "the max MB/s read from or written to each volume server, 0 for no limit")
+	checkpoint := fsckCommand.String("checkpoint", "", "the local file of the checked volumes, to resume an interrupted check")
+	showProgress := fsckCommand.Bool("progress", true, "show the progress on the standard error")
 	if err = fsckCommand.Parse(args); err != nil {
 		return nil
 	}
 	infoAboutSimulationMode(writer, *applyChanges, "-force")
+	if *parallel < 1 {
+		return fmt.Errorf("-parallel %d should be at least 1", *parallel)
+	}
 
 	if err = commandEnv.confirmIsLocked(args); err != nil {
 		return
 	}
 
 	c.env = commandEnv
+	c.throttler = newVolumeServerThrottler(*maxMBps * 1024 * 1024)
 
 	// collect topology information
 	topologyInfo, _, err := collectTopologyInfo(commandEnv, 0)
@@ -70,40 +96,121 @@ func (c *commandVolumeCheckDisk) Do(args []string, commandEnv *CommandEnv, write
 	}
 	volumeReplicas, _ := collectVolumeReplicaLocations(topologyInfo)
 
-	// pick 1 pairs of volume replica
-	fileCount := func(replica *VolumeReplica) uint64 {
-		return replica.info.FileCount - replica.info.DeleteCount
+	checked, err := loadVolumeCheckpoint(*checkpoint)
+	if err != nil {
+		return err
 	}
-
-	for _, replicas := range volumeReplicas {
-		if *volumeId > 0 && replicas[0].info.Id != uint32(*volumeId) {
+	var vids []uint32
+	for vid, replicas := range volumeReplicas {
+		if (*volumeId > 0 && vid != uint32(*volumeId)) || len(replicas) < 2 {
 			continue
 		}
-		slices.SortFunc(replicas, func(a, b *VolumeReplica) int {
-			return int(fileCount(b) - fileCount(a))
-		})
-		for len(replicas) >= 2 {
-			a, b := replicas[0], replicas[1]
-			if !*slowMode {
-				if fileCount(a) == fileCount(b) {
-					replicas = replicas[1:]
-					continue
+		if checked[vid] {
+			continue
+		}
+		vids = append(vids, vid)
+	}
+	slices.Sort(vids)
+	if len(checked) > 0 {
+		fmt.Fprintf(writer, "skip %d volumes checked in %s\n", len(checked), *checkpoint)
+	}
+
+	var checkpointFile *os.File
+	if *checkpoint != "" {
+		if checkpointFile, err = os.OpenFile(*checkpoint, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+			return fmt.Errorf("open the checkpoint %s: %v", *checkpoint, err)
+		}
+		defer checkpointFile.Close()
+	}
+
+	var bar *progressbar.ProgressBar
+	if *showProgress {
+		bar = progressbar.NewOptions(len(vids),
+			progressbar.OptionSetWriter(os.Stderr),
+			progressbar.OptionSetDescription(c.Name()),
+			progressbar.OptionShowCount(),
+			progressbar.OptionSetPredictTime(true),
+			progressbar.OptionClearOnFinish(),
+		)
+	}
+
+	var outputLock sync.Mutex
+	var failed int
+	jobs := make(chan uint32)
+	var wg sync.WaitGroup
+	for i := 0; i < *parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for vid := range jobs {
+				// keep the output of each volume together
+				var buf bytes.Buffer
+				ok := c.checkVolume(volumeReplicas[vid], *slowMode, *applyChanges, *syncDeletions, *nonRepairThreshold, *verbose, &buf)
+
+				outputLock.Lock()
+				writer.Write(buf.Bytes())
+				if !ok {
+					failed++
+				} else if checkpointFile != nil {
+					if _, err := fmt.Fprintf(checkpointFile, "%d\n", vid); err != nil {
+						fmt.Fprintf(writer, "record volume %d in the checkpoint: %v\n", vid, err)
+					}
+				}
+				if bar != nil {
+					bar.Add(1)
 				}
+				outputLock.Unlock()
 			}
-			if a.info.ReadOnly || b.info.ReadOnly {
-				fmt.Fprintf(writer, "skipping readonly volume %d on %s and %s\n", a.info.Id, a.location.dataNode.Id, b.location.dataNode.Id)
+		}()
+	}
+	for _, vid := range vids {
+		jobs <- vid
+	}
+	close(jobs)
+	wg.Wait()
+
+	fmt.Fprintf(writer, "checked %d volumes, %d with errors\n", len(vids), failed)
+	if checkpointFile != nil && failed == 0 {
+		checkpointFile.Close()
+		if err = os.Remove(*checkpoint); err != nil {
+			return fmt.Errorf("remove the checkpoint %s: %v", *checkpoint, err)
+		}
+	}
+	return nil
+}
+
+// checkVolume syncs the pairs of the replicas of one volume, and returns false on any error
+func (c *commandVolumeCheckDisk) checkVolume(replicas []*VolumeReplica, slowMode, applyChanges, syncDeletions bool, nonRepairThreshold float64, verbose bool, writer io.Writer) (ok bool) {
+	// pick 1 pairs of volume replica
+	fileCount := func(replica *VolumeReplica) uint64 {
+		return replica.info.FileCount - replica.info.DeleteCount
+	}
+
+	ok = true
+	slices.SortFunc(replicas, func(a, b *VolumeReplica) int {
+		return int(fileCount(b) - fileCount(a))
+	})
+	for len(replicas) >= 2 {
+		a, b := replicas[0], replicas[1]
+		if !slowMode {
+			if fileCount(a) == fileCount(b) {
 				replicas = replicas[1:]
 				continue
 			}
-
-			if err := c.syncTwoReplicas(a, b, *applyChanges, *syncDeletions, *nonRepairThreshold, *verbose, writer); err != nil {
-				fmt.Fprintf(writer, "sync volume %d on %s and %s: %v\n", a.info.Id, a.location.dataNode.Id, b.location.dataNode.Id, err)
-			}
+		}
+		if a.info.ReadOnly || b.info.ReadOnly {
+			fmt.Fprintf(writer, "skipping readonly volume %d on %s and %s\n", a.info.Id, a.location.dataNode.Id, b.location.dataNode.Id)
 			replicas = replicas[1:]
+			continue
 		}
-	}
 
-	return nil
+		if err := c.syncTwoReplicas(a, b, applyChanges, syncDeletions, nonRepairThreshold, verbose, writer); err != nil {
+			fmt.Fprintf(writer, "sync volume %d on %s and %s: %v\n", a.info.Id, a.location.dataNode.Id, b.location.dataNode.Id, err)
+			ok = false
+		}
+		replicas = replicas[1:]
+	}
+	return
 }
 
 func (c *commandVolumeCheckDisk) syncTwoReplicas(a *VolumeReplica, b *VolumeReplica, applyChanges bool, doSyncDeletions bool, nonRepairThreshold float64, verbose bool, writer io.Writer) (err error) {
@@ -125,6 +232,8 @@ func (c *commandVolumeCheckDisk) checkBoth(a *VolumeReplica, b *VolumeReplica, a
 
 	// read index db
 	readIndexDbCutoffFrom := uint64(time.Now().UnixNano())
+	c.throttler.waitIndex(a)
+	c.throttler.waitIndex(b)
 	if err = readIndexDatabase(aDB, a.info.Collection, a.info.Id, pb.NewServerAddressFromDataNode(a.location.dataNode), verbose, writer, c.env.option.GrpcDialOption); err != nil {
 		return true, true, fmt.Errorf("readIndexDatabase %s volume %d: %v", a.location.dataNode, a.info.Id, err)
 	}
@@ -133,16 +242,16 @@ func (c *commandVolumeCheckDisk) checkBoth(a *VolumeReplica, b *VolumeReplica, a
 	}
 
 	// find and make up the differences
-	if aHasChanges, err = doVolumeCheckDisk(bDB, aDB, b, a, verbose, writer, applyChanges, doSyncDeletions, nonRepairThreshold, readIndexDbCutoffFrom, c.env.option.GrpcDialOption); err != nil {
+	if aHasChanges, err = doVolumeCheckDisk(bDB, aDB, b, a, verbose, writer, applyChanges, doSyncDeletions, nonRepairThreshold, readIndexDbCutoffFrom, c.env.option.GrpcDialOption, c.throttler); err != nil {
 		return true, true, fmt.Errorf("doVolumeCheckDisk source:%s target:%s volume %d: %v", b.location.dataNode.Id, a.location.dataNode.Id, b.info.Id, err)
 	}
-	if bHasChanges, err = doVolumeCheckDisk(aDB, bDB, a, b, verbose, writer, applyChanges, doSyncDeletions, nonRepairThreshold, readIndexDbCutoffFrom, c.env.option.GrpcDialOption); err != nil {
+	if bHasChanges, err = doVolumeCheckDisk(aDB, bDB, a, b, verbose, writer, applyChanges, doSyncDeletions, nonRepairThreshold, readIndexDbCutoffFrom, c.env.option.GrpcDialOption, c.throttler); err != nil {
 		return true, true, fmt.Errorf("doVolumeCheckDisk source:%s target:%s volume %d: %v", a.location.dataNode.Id, b.location.dataNode.Id, a.info.Id, err)
 	}
 	return
 }
 
-func doVolumeCheckDisk(minuend, subtrahend *needle_map.MemDb, source, target *VolumeReplica, verbose bool, writer io.Writer, applyChanges bool, doSyncDeletions bool, nonRepairThreshold float64, cutoffFromAtNs uint64, grpcDialOption grpc.DialOption) (hasChanges bool, err error) {
+func doVolumeCheckDisk(minuend, subtrahend *needle_map.MemDb, source, target *VolumeReplica, verbose bool, writer io.Writer, applyChanges bool, doSyncDeletions bool, nonRepairThreshold float64, cutoffFromAtNs uint64, grpcDialOption grpc.DialOption, throttler *volumeServerThrottler) (hasChanges bool, err error) {
 
 	// find missing keys
 	// hash join, can be more efficient
@@ -192,6 +301,7 @@ func doVolumeCheckDisk(minuend, subtrahend *needle_map.MemDb, source, target *Vo
 	}
 
 	for _, needleValue := range missingNeedles {
+		throttler.wait(pb.NewServerAddressFromDataNode(source.location.dataNode), int(needleValue.Size))
 		needleBlob, err := readSourceNeedleBlob(grpcDialOption, pb.NewServerAddressFromDataNode(source.location.dataNode), source.info.Id, needleValue)
 		if err != nil {
 			return hasChanges, err
@@ -207,6 +317,7 @@ func doVolumeCheckDisk(minuend, subtrahend *needle_map.MemDb, source, target *Vo
 
 		hasChanges = true
 
+		throttler.wait(pb.NewServerAddressFromDataNode(target.location.dataNode), len(needleBlob))
 		if err = writeNeedleBlobToTarget(grpcDialOption, pb.NewServerAddressFromDataNode(target.location.dataNode), source.info.Id, needleValue, needleBlob); err != nil {
 			return hasChanges, err
 		}
@@ -323,3 +434,77 @@ func writeToBuffer(client volume_server_pb.VolumeServer_CopyFileClient, buf *byt
 	}
 	return nil
 }
+
+// volumeServerThrottler limits the bytes per second transferred with each volume server
+type volumeServerThrottler struct {
+	bytesPerSecond float64
+	lock           sync.Mutex
+	limiters       map[pb.ServerAddress]*rate.Limiter
+}
+
+// newVolumeServerThrottler returns nil for no limit
+func newVolumeServerThrottler(bytesPerSecond float64) *volumeServerThrottler {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &volumeServerThrottler{
+		bytesPerSecond: bytesPerSecond,
+		limiters:       make(map[pb.ServerAddress]*rate.Limiter),
+	}
+}
+
+func (t *volumeServerThrottler) wait(server pb.ServerAddress, n int) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	limiter, found := t.limiters[server]
+	if !found {
+		burst := int(math.Max(1, t.bytesPerSecond))
+		limiter = rate.NewLimiter(rate.Limit(t.bytesPerSecond), burst)
+		t.limiters[server] = limiter
+	}
+	t.lock.Unlock()
+
+	// WaitN fails on more than the burst
+	for n > 0 {
+		chunk := n
+		if chunk > limiter.Burst() {
+			chunk = limiter.Burst()
+		}
+		limiter.WaitN(context.Background(), chunk)
+		n -= chunk
+	}
+}
+
+// waitIndex waits for the index file of the replica, by its entries
+func (t *volumeServerThrottler) waitIndex(replica *VolumeReplica) {
+	t.wait(pb.NewServerAddressFromDataNode(replica.location.dataNode), int((replica.info.FileCount+replica.info.DeleteCount)*types.NeedleMapEntrySize))
+}
+
+// loadVolumeCheckpoint reads the volume ids recorded in the checkpoint file, if any
+func loadVolumeCheckpoint(fileName string) (checked map[uint32]bool, err error) {
+	checked = make(map[uint32]bool)
+	if fileName == "" {
+		return
+	}
+	data, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return checked, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read the checkpoint %s: %v", fileName, err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		vid, parseErr := strconv.ParseUint(line, 10, 32)
+		if parseErr != nil {
+			// a partial line written before an interruption
+			continue
+		}
+		checked[uint32(vid)] = true
+	}
+	return checked, nil
+}
@@ -0,0 +1,36 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadVolumeCheckpoint(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "checkpoint")
+	if checked, err := loadVolumeCheckpoint(fileName); err != nil || len(checked) != 0 {
+		t.Fatalf("missing checkpoint: %v %v", checked, err)
+	}
+	if err := os.WriteFile(fileName, []byte("3\n12\n\n7"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checked, err := loadVolumeCheckpoint(fileName)
+	if err != nil || len(checked) != 3 || !checked[3] || !checked[12] || !checked[7] {
+		t.Errorf("checkpoint %v: %v", checked, err)
+	}
+}
+
+func TestVolumeServerThrottler(t *testing.T) {
+	var unlimited *volumeServerThrottler
+	unlimited.wait("a:8080", 1<<30)
+
+	throttler := newVolumeServerThrottler(1000)
+	start := time.Now()
+	// the first second is the burst, and 1500 more bytes take 1.5 seconds
+	throttler.wait("a:8080", 2500)
+	throttler.wait("b:8080", 1000)
+	if elapsed := time.Since(start); elapsed < 1400*time.Millisecond || elapsed > 3*time.Second {
+		t.Errorf("waited %v for 2500 bytes at 1000 bytes per second", elapsed)
+	}
+}
@@ -27,6 +27,7 @@ func (c *commandVolumeCopy) Help() string {
 
 	This command copies a volume from one volume server to another volume server.
 	Usually you will want to unmount the volume first before copying.
+	With "-parallel <n>", n segments of the .dat file are copied at the same time.
 
 `
 }
@@ -37,6 +38,7 @@ func (c *commandVolumeCopy) Do(args []string, commandEnv *CommandEnv, writer io.
 	volumeIdInt := volCopyCommand.Int("volumeId", 0, "the volume id")
 	sourceNodeStr := volCopyCommand.String("source", "", "the source volume server <host>:<port>")
 	targetNodeStr := volCopyCommand.String("target", "", "the target volume server <host>:<port>")
+	parallelSegments := volCopyCommand.Int("parallel", 1, "number of the .dat file segments copied in parallel")
 	if err = volCopyCommand.Parse(args); err != nil {
 		return nil
 	}
@@ -53,6 +55,6 @@ func (c *commandVolumeCopy) Do(args []string, commandEnv *CommandEnv, writer io.
 		return fmt.Errorf("source and target volume servers are the same!")
 	}
 
-	_, err = copyVolume(commandEnv.option.GrpcDialOption, writer, volumeId, sourceVolumeServer, targetVolumeServer, "", 0)
+	_, err = copyVolume(commandEnv.option.GrpcDialOption, writer, volumeId, sourceVolumeServer, targetVolumeServer, "", 0, *parallelSegments)
 	return
 }
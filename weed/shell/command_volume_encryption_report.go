@@ -0,0 +1,122 @@
+package shell
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeEncryptionReport{})
+}
+
+type commandVolumeEncryptionReport struct {
+}
+
+func (c *commandVolumeEncryptionReport) Name() string {
+	return "volume.encryption.report"
+}
+
+func (c *commandVolumeEncryptionReport) Help() string {
+	return `report the encrypted volumes of each collection, and the master keys wrapping their data keys
+
+	volume.encryption.report [-collectionPattern=<pattern>] [-v]
+
+	The volumes are encrypted by the volume servers started with "-encryption.collections".
+	The ec volumes are not counted, since only the unencrypted volumes can be erasure coded.
+	With -v, the unencrypted volumes are listed, and the volumes with their master keys.
+
+`
+}
+
+type encryptionCoverage struct {
+	volumeCount    int
+	encryptedCount int
+	size           uint64
+	encryptedSize  uint64
+	keyIdCounts    map[string]int
+}
+
+func (c *commandVolumeEncryptionReport) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	reportCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	collectionPattern := reportCommand.String("collectionPattern", "", "match with wildcard characters '*' and '?'")
+	verbose := reportCommand.Bool("v", false, "list the volumes")
+	if err = reportCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	topologyInfo, _, err := collectTopologyInfo(commandEnv, 0)
+	if err != nil {
+		return err
+	}
+
+	coverages := make(map[string]*encryptionCoverage)
+	total := &encryptionCoverage{keyIdCounts: make(map[string]int)}
+	eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+		for _, diskInfo := range dn.DiskInfos {
+			for _, v := range diskInfo.VolumeInfos {
+				if *collectionPattern != "" {
+					if matched, _ := filepath.Match(*collectionPattern, v.Collection); !matched {
+						continue
+					}
+				}
+				coverage, found := coverages[v.Collection]
+				if !found {
+					coverage = &encryptionCoverage{keyIdCounts: make(map[string]int)}
+					coverages[v.Collection] = coverage
+				}
+				for _, cov := range []*encryptionCoverage{coverage, total} {
+					cov.volumeCount++
+					cov.size += v.Size
+					if v.EncryptionKeyId != "" {
+						cov.encryptedCount++
+						cov.encryptedSize += v.Size
+						cov.keyIdCounts[v.EncryptionKeyId]++
+					}
+				}
+				if *verbose {
+					if v.EncryptionKeyId == "" {
+						fmt.Fprintf(writer, "volume %d collection %q on %s is not encrypted\n", v.Id, v.Collection, dn.Id)
+					} else {
+						fmt.Fprintf(writer, "volume %d collection %q on %s is encrypted with %s\n", v.Id, v.Collection, dn.Id, v.EncryptionKeyId)
+					}
+				}
+			}
+		}
+	})
+
+	var collections []string
+	for collection := range coverages {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+	for _, collection := range collections {
+		fmt.Fprintf(writer, "collection %q: %s\n", collection, coverages[collection])
+	}
+	fmt.Fprintf(writer, "total: %s\n", total)
+
+	return nil
+}
+
+func (cov *encryptionCoverage) String() string {
+	percentage := float64(100)
+	if cov.volumeCount > 0 {
+		percentage = float64(cov.encryptedCount) * 100 / float64(cov.volumeCount)
+	}
+	var keyIds []string
+	for keyId, count := range cov.keyIdCounts {
+		keyIds = append(keyIds, fmt.Sprintf("%s:%d", keyId, count))
+	}
+	sort.Strings(keyIds)
+	return fmt.Sprintf("%d/%d volumes encrypted (%.1f%%), %s/%s, master keys [%s]",
+		cov.encryptedCount, cov.volumeCount, percentage,
+		util.BytesToHumanReadable(cov.encryptedSize), util.BytesToHumanReadable(cov.size),
+		strings.Join(keyIds, " "))
+}
@@ -0,0 +1,98 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeEncryptionRotate{})
+}
+
+type commandVolumeEncryptionRotate struct {
+}
+
+func (c *commandVolumeEncryptionRotate) Name() string {
+	return "volume.encryption.rotate"
+}
+
+func (c *commandVolumeEncryptionRotate) Help() string {
+	return `wrap the data keys of the encrypted volumes with the current master key
+
+	volume.encryption.rotate [-volumeId=<volume id>] [-collectionPattern=<pattern>]
+
+	After changing "volume.encryption.key_id" in security.toml of the volume servers, the data keys
+	are re-wrapped when the volumes are loaded. This command re-wraps them without restarting,
+	e.g. after the master key is rotated in the key manager. The volume data is not rewritten.
+
+`
+}
+
+func (c *commandVolumeEncryptionRotate) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	rotateCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeId := rotateCommand.Uint("volumeId", 0, "the volume id")
+	collectionPattern := rotateCommand.String("collectionPattern", "", "match with wildcard characters '*' and '?'")
+	if err = rotateCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if err = commandEnv.confirmIsLocked(args); err != nil {
+		return
+	}
+
+	topologyInfo, _, err := collectTopologyInfo(commandEnv, 0)
+	if err != nil {
+		return err
+	}
+
+	eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+		if err != nil {
+			return
+		}
+		var targetVolumeIds []uint32
+		for _, diskInfo := range dn.DiskInfos {
+			for _, v := range diskInfo.VolumeInfos {
+				if v.EncryptionKeyId == "" {
+					continue
+				}
+				if *volumeId > 0 && v.Id != uint32(*volumeId) {
+					continue
+				}
+				if *collectionPattern != "" {
+					if matched, _ := filepath.Match(*collectionPattern, v.Collection); !matched {
+						continue
+					}
+				}
+				targetVolumeIds = append(targetVolumeIds, v.Id)
+			}
+		}
+		if len(targetVolumeIds) == 0 {
+			return
+		}
+		err = operation.WithVolumeServerClient(false, pb.NewServerAddressFromDataNode(dn), commandEnv.option.GrpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+			for _, targetVolumeId := range targetVolumeIds {
+				resp, rotateErr := volumeServerClient.VolumeEncryptionRotate(context.Background(), &volume_server_pb.VolumeEncryptionRotateRequest{
+					VolumeId: targetVolumeId,
+				})
+				if rotateErr != nil {
+					return fmt.Errorf("rotate volume %d on %s: %v", targetVolumeId, dn.Id, rotateErr)
+				}
+				if resp.PreviousKeyId != resp.KeyId {
+					fmt.Fprintf(writer, "volume %d on %s: %s => %s\n", targetVolumeId, dn.Id, resp.PreviousKeyId, resp.KeyId)
+				}
+			}
+			return nil
+		})
+	})
+
+	return err
+}
@@ -50,6 +50,8 @@ func (c *commandVolumeFixReplication) Help() string {
 		  If there are multiple replicas are missing, e.g. replica count is > 2, you may need to run this multiple times.
 		* do not run this too quickly within seconds, since the new volume replica may take a few seconds 
 		  to register itself to the master.
+		* the volumes missing the replicas on the offline volume servers in maintenance are not fixed
+		  until the grace period of volumeServer.maintenance ends.
 
 `
 }
@@ -92,12 +94,19 @@ func (c *commandVolumeFixReplication) Do(args []string, commandEnv *CommandEnv,
 			return fmt.Errorf("no data nodes at all")
 		}
 
+		deferredVolumeIds, err := collectDeferredVolumeIds(commandEnv)
+		if err != nil {
+			return err
+		}
+
 		// find all under replicated volumes
 		var underReplicatedVolumeIds, overReplicatedVolumeIds, misplacedVolumeIds []uint32
 		for vid, replicas := range volumeReplicas {
 			replica := replicas[0]
 			replicaPlacement, _ := super_block.NewReplicaPlacementFromByte(byte(replica.info.ReplicaPlacement))
 			switch {
+			case replicaPlacement.GetCopyCount() > len(replicas) && deferredVolumeIds[vid]:
+				fmt.Fprintf(writer, "volume %d replication %s misses the replicas on the volume servers in maintenance, deferred\n", vid, replicaPlacement)
 			case replicaPlacement.GetCopyCount() > len(replicas):
 				underReplicatedVolumeIds = append(underReplicatedVolumeIds, vid)
 			case isMisplaced(replicas, replicaPlacement):
@@ -206,7 +215,7 @@ func checkOneVolume(a *VolumeReplica, b *VolumeReplica, writer io.Writer, grpcDi
 	if err := readIndexDatabase(bDB, b.info.Collection, b.info.Id, pb.NewServerAddressFromDataNode(b.location.dataNode), false, writer, grpcDialOption); err != nil {
 		return fmt.Errorf("readIndexDatabase %s volume %d: %v", b.location.dataNode, b.info.Id, err)
 	}
-	if _, err = doVolumeCheckDisk(aDB, bDB, a, b, false, writer, true, false, float64(1), readIndexDbCutoffFrom, grpcDialOption); err != nil {
+	if _, err = doVolumeCheckDisk(aDB, bDB, a, b, false, writer, true, false, float64(1), readIndexDbCutoffFrom, grpcDialOption, nil); err != nil {
 		return fmt.Errorf("doVolumeCheckDisk source:%s target:%s volume %d: %v", a.location.dataNode.Id, b.location.dataNode.Id, a.info.Id, err)
 	}
 	return
@@ -74,6 +74,11 @@ func (c *commandVolumeFsck) Help() string {
 	2. collect all file ids from the filer, as set B
 	3. find out the set B subtract A
 
+	If -onFiler is enabled, the filer checks both ways in the background, including the entries
+	changed during the check, and reports the orphan chunks and the entries with missing chunks.
+	With -reallyDeleteFromVolume, the orphan chunks are deleted from the volumes, but the entries
+	with missing chunks are kept. The same filer should not be also changed by other filers.
+
 `
 }
 
@@ -90,6 +95,7 @@ func (c *commandVolumeFsck) Do(args []string, commandEnv *CommandEnv, writer io.
 	tempPath := fsckCommand.String("tempPath", path.Join(os.TempDir()), "path for temporary idx files")
 	cutoffTimeAgo := fsckCommand.Duration("cutoffTimeAgo", 5*time.Minute, "only include entries  on volume servers before this cutoff time to check orphan chunks")
 	c.verifyNeedle = fsckCommand.Bool("verifyNeedles", false, "check needles status from volume server")
+	onFiler := fsckCommand.Bool("onFiler", false, "let the filer find both the orphan chunks and the missing chunks, see \"help volume.fsck\"")
 
 	if err = fsckCommand.Parse(args); err != nil {
 		return nil
@@ -111,6 +117,10 @@ func (c *commandVolumeFsck) Do(args []string, commandEnv *CommandEnv, writer io.
 	c.env = commandEnv
 	c.writer = writer
 
+	if *onFiler {
+		return c.auditOnFiler(*cutoffTimeAgo, *applyPurging)
+	}
+
 	c.bucketsPath, err = readFilerBucketsPath(commandEnv)
 	if err != nil {
 		return fmt.Errorf("read filer buckets path: %v", err)
@@ -247,7 +257,7 @@ func (c *commandVolumeFsck) collectFilerFileIdAndPaths(dataNodeVolumeIdToVInfo m
 				} else if *c.findMissingChunksInFiler && len(c.volumeIds) == 0 {
 					fmt.Fprintf(c.writer, "%d,%x%08x %s volume not found\n", i.vid, i.fileKey, i.cookie, i.path)
 					if purgeAbsent {
-						fmt.Printf("deleting path %s after volume not found", i.path)
+						fmt.Fprintf(c.writer, "deleting path %s after volume not found\n", i.path)
 						c.httpDelete(i.path)
 					}
 				}
@@ -583,7 +593,8 @@ func (c *commandVolumeFsck) oneVolumeFileIdsSubtractFilerFileIds(dataNodeId stri
 
 	var orphanFileCount uint64
 	if err = volumeFileIdDb.AscendingVisit(func(n needle_map.NeedleValue) error {
-		if n.Size.IsDeleted() {
+		if n.Size.IsDeleted() || storage.IsDedupBlobId(n.Key) {
+			// the blob needles are referred by the needles of the deduplicated volumes
 			return nil
 		}
 		orphanFileIds = append(orphanFileIds, n.Key.FileId(volumeId))
@@ -715,3 +726,89 @@ func writeToFile(bytes []byte, fileName string) error {
 	dst.Write(bytes)
 	return nil
 }
+
+// auditOnFiler runs the chunk audit on the filer, and purges the orphan chunks found by it
+func (c *commandVolumeFsck) auditOnFiler(cutoffTimeAgo time.Duration, applyPurging bool) error {
+	req := &filer_pb.ChunkAuditRequest{
+		Start:            true,
+		Collection:       *c.collection,
+		CutoffSecondsAgo: int64(cutoffTimeAgo.Seconds()),
+	}
+	for vid := range c.volumeIds {
+		req.VolumeIds = append(req.VolumeIds, vid)
+	}
+	return c.env.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.ChunkAudit(context.Background(), req)
+		if err != nil {
+			return fmt.Errorf("start chunk audit: %v", err)
+		}
+		auditId := resp.AuditId
+		fmt.Fprintf(c.writer, "chunk audit %s started\n", auditId)
+
+		limit := int32(0)
+		if !*c.verbose {
+			limit = 100
+		}
+		for resp.IsRunning {
+			time.Sleep(2 * time.Second)
+			if resp, err = client.ChunkAudit(context.Background(), &filer_pb.ChunkAuditRequest{Limit: limit}); err != nil {
+				return fmt.Errorf("check chunk audit: %v", err)
+			}
+			if resp.AuditId != auditId {
+				return fmt.Errorf("chunk audit %s is replaced by %s", auditId, resp.AuditId)
+			}
+			if *c.verbose && resp.IsRunning {
+				fmt.Fprintf(c.writer, "checked %d entries, %d chunks\n", resp.ScannedEntries, resp.ReferencedChunks)
+			}
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("chunk audit %s: %s", auditId, resp.Error)
+		}
+
+		var totalInUseCount, totalOrphanChunkCount, totalOrphanDataSize uint64
+		for _, v := range resp.Volumes {
+			totalInUseCount += v.InUseCount
+			totalOrphanChunkCount += v.OrphanCount
+			totalOrphanDataSize += v.OrphanSize
+			if v.OrphanCount == 0 {
+				continue
+			}
+			pct := float64(v.OrphanCount*100) / float64(v.OrphanCount+v.InUseCount)
+			fmt.Fprintf(c.writer, "volume:%d\tentries:%d\torphan:%d\t%.2f%%\t%dB\n",
+				v.VolumeId, v.OrphanCount+v.InUseCount, v.OrphanCount, pct, v.OrphanSize)
+			for _, fid := range v.OrphanFileIds {
+				fmt.Fprintf(c.writer, "%s:%s\n", v.Collection, fid)
+			}
+		}
+		for _, d := range resp.Dangling {
+			fmt.Fprintf(c.writer, "%s %s %s\n", d.FileId, d.Path, d.Reason)
+		}
+		if totalOrphanChunkCount+totalInUseCount > 0 {
+			pct := float64(totalOrphanChunkCount*100) / float64(totalOrphanChunkCount+totalInUseCount)
+			fmt.Fprintf(c.writer, "\nTotal\t\tentries:%d\torphan:%d\t%.2f%%\t%dB\n",
+				totalOrphanChunkCount+totalInUseCount, totalOrphanChunkCount, pct, totalOrphanDataSize)
+		}
+		fmt.Fprintf(c.writer, "missing chunks:%d\n", resp.DanglingCount)
+
+		if !applyPurging || totalOrphanChunkCount == 0 {
+			return nil
+		}
+		resp, err = client.ChunkAudit(context.Background(), &filer_pb.ChunkAuditRequest{
+			PurgeAuditId: auditId,
+			ForcePurging: *c.forcePurging,
+			Limit:        1,
+		})
+		if err != nil {
+			return fmt.Errorf("purge chunk audit %s: %v", auditId, err)
+		}
+		var purgedCount uint64
+		for _, v := range resp.Volumes {
+			purgedCount += v.PurgedCount
+		}
+		for _, purgeError := range resp.PurgeErrors {
+			fmt.Fprintf(c.writer, "purge error: %s\n", purgeError)
+		}
+		fmt.Fprintf(c.writer, "purged %d orphan chunks\n", purgedCount)
+		return nil
+	})
+}
@@ -0,0 +1,184 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeFsckDeep{})
+}
+
+type commandVolumeFsckDeep struct {
+}
+
+func (c *commandVolumeFsckDeep) Name() string {
+	return "volume.fsck.deep"
+}
+
+func (c *commandVolumeFsckDeep) Help() string {
+	return `read every needle of the volumes on the volume servers, and report the problems as json
+
+	volume.fsck.deep [-volumeId=<volume id>] [-collection=<collection>] [-MBps=50] [-o=<report file>]
+
+	Each replica of the volumes is checked online by its volume server, one volume at a time per volume server,
+	reading at most -MBps, for:
+	  torn_index         : the .idx file ends with a partial entry
+	  beyond_data_file   : an index entry points past the end of the .dat file
+	  index_disagreement : the needle map of the volume differs from the last index entry of a needle
+	  unreadable, id_mismatch, size_mismatch, checksum : a live needle fails to verify
+	  unindexed_needle   : a complete needle is appended after the last indexed needle, but not indexed
+	  torn_write         : the .dat file ends with a partial needle
+	The volumes tiered to the remote storage are skipped. Nothing is repaired.
+
+`
+}
+
+type fsckDeepProblem struct {
+	Kind     string `json:"kind"`
+	NeedleId string `json:"needleId,omitempty"`
+	Offset   int64  `json:"offset"`
+	Size     int32  `json:"size"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+type fsckDeepVolumeReport struct {
+	VolumeId      uint32            `json:"volumeId"`
+	Collection    string            `json:"collection"`
+	Server        string            `json:"server"`
+	CheckedCount  uint64            `json:"checkedCount"`
+	CheckedBytes  uint64            `json:"checkedBytes"`
+	DataFileSize  int64             `json:"dataFileSize"`
+	IndexFileSize int64             `json:"indexFileSize"`
+	Error         string            `json:"error,omitempty"`
+	Problems      []fsckDeepProblem `json:"problems"`
+}
+
+type fsckDeepReport struct {
+	Volumes      []*fsckDeepVolumeReport `json:"volumes"`
+	ProblemCount int                     `json:"problemCount"`
+	ErrorCount   int                     `json:"errorCount"`
+}
+
+func (c *commandVolumeFsckDeep) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsckCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeId := fsckCommand.Int("volumeId", 0, "only this volume id, 0 for all volumes")
+	collection := fsckCommand.String("collection", "", "only the volumes of this collection")
+	mbps := fsckCommand.Int("MBps", 50, "read speed limit on each volume server in megabytes per second, 0 for no limit")
+	outputFileName := fsckCommand.String("o", "", "write the report to this file")
+	if err = fsckCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	topologyInfo, _, err := collectTopologyInfo(commandEnv, 0)
+	if err != nil {
+		return err
+	}
+	serverVolumes := make(map[pb.ServerAddress][]*fsckDeepVolumeReport)
+	eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+		for _, diskInfo := range dn.DiskInfos {
+			for _, v := range diskInfo.VolumeInfos {
+				if *volumeId != 0 && v.Id != uint32(*volumeId) || *collection != "" && v.Collection != *collection || v.RemoteStorageName != "" {
+					continue
+				}
+				server := pb.NewServerAddressFromDataNode(dn)
+				serverVolumes[server] = append(serverVolumes[server], &fsckDeepVolumeReport{
+					VolumeId:   v.Id,
+					Collection: v.Collection,
+					Server:     string(server),
+					Problems:   []fsckDeepProblem{},
+				})
+			}
+		}
+	})
+
+	report := &fsckDeepReport{}
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	for server, volumes := range serverVolumes {
+		server, volumes := server, volumes
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, volumeReport := range volumes {
+				if fsckErr := fsckDeepVolume(commandEnv, server, volumeReport, int64(*mbps)*1024*1024); fsckErr != nil {
+					volumeReport.Error = fsckErr.Error()
+				}
+				lock.Lock()
+				report.Volumes = append(report.Volumes, volumeReport)
+				if *outputFileName != "" {
+					fmt.Fprintf(writer, "volume %d on %s: %d needles checked, %d problems %s\n", volumeReport.VolumeId, server, volumeReport.CheckedCount, len(volumeReport.Problems), volumeReport.Error)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(report.Volumes, func(i, j int) bool {
+		a, b := report.Volumes[i], report.Volumes[j]
+		return a.VolumeId < b.VolumeId || a.VolumeId == b.VolumeId && a.Server < b.Server
+	})
+	for _, volumeReport := range report.Volumes {
+		report.ProblemCount += len(volumeReport.Problems)
+		if volumeReport.Error != "" {
+			report.ErrorCount++
+		}
+	}
+	data, _ := json.MarshalIndent(report, "", "  ")
+
+	if *outputFileName == "" {
+		fmt.Fprintf(writer, "%s\n", data)
+		return nil
+	}
+	if err = os.WriteFile(*outputFileName, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %v", *outputFileName, err)
+	}
+	fmt.Fprintf(writer, "%d volumes checked with %d problems and %d errors, report is saved to %s\n", len(report.Volumes), report.ProblemCount, report.ErrorCount, *outputFileName)
+	return nil
+}
+
+func fsckDeepVolume(commandEnv *CommandEnv, server pb.ServerAddress, volumeReport *fsckDeepVolumeReport, bytesPerSecond int64) error {
+	return operation.WithVolumeServerClient(true, server, commandEnv.option.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		stream, err := client.VolumeFsckDeep(context.Background(), &volume_server_pb.VolumeFsckDeepRequest{
+			VolumeId:       volumeReport.VolumeId,
+			BytesPerSecond: bytesPerSecond,
+		})
+		if err != nil {
+			return err
+		}
+		for {
+			resp, recvErr := stream.Recv()
+			if recvErr == io.EOF {
+				return nil
+			}
+			if recvErr != nil {
+				return recvErr
+			}
+			for _, problem := range resp.Problems {
+				p := fsckDeepProblem{Kind: problem.Kind, Offset: problem.Offset, Size: problem.Size, Detail: problem.Detail}
+				if problem.NeedleId != 0 {
+					p.NeedleId = types.NeedleId(problem.NeedleId).String()
+				}
+				volumeReport.Problems = append(volumeReport.Problems, p)
+			}
+			volumeReport.CheckedCount = resp.CheckedCount
+			volumeReport.CheckedBytes = resp.CheckedBytes
+			volumeReport.DataFileSize = resp.DataFileSize
+			volumeReport.IndexFileSize = resp.IndexFileSize
+		}
+	})
+}
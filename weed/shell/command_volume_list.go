@@ -2,11 +2,14 @@ package shell
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
 	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
 	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	"path/filepath"
 	"strings"
 
@@ -59,10 +62,72 @@ func (c *commandVolumeList) Do(args []string, commandEnv *CommandEnv, writer io.
 		return err
 	}
 
-	c.writeTopologyInfo(writer, topologyInfo, volumeSizeLimitMb, *verbosityLevel)
+	s := c.writeTopologyInfo(writer, topologyInfo, volumeSizeLimitMb, *verbosityLevel)
+	if commandEnv.isJsonOutput() {
+		topology, err := protojson.Marshal(c.filterTopologyInfo(topologyInfo))
+		if err != nil {
+			return err
+		}
+		commandEnv.setResult(&volumeListResult{
+			VolumeSizeLimitMb: volumeSizeLimitMb,
+			Statistics:        s,
+			Topology:          topology,
+		})
+	}
 	return nil
 }
 
+type volumeListResult struct {
+	VolumeSizeLimitMb uint64          `json:"volumeSizeLimitMb"`
+	Statistics        statistics      `json:"statistics"`
+	Topology          json.RawMessage `json:"topology"`
+}
+
+// filterTopologyInfo copies the data centers, racks, data nodes, volumes and ec shards matching the filters
+func (c *commandVolumeList) filterTopologyInfo(t *master_pb.TopologyInfo) *master_pb.TopologyInfo {
+	t = proto.Clone(t).(*master_pb.TopologyInfo)
+	var dcs []*master_pb.DataCenterInfo
+	for _, dc := range t.DataCenterInfos {
+		if *c.dataCenter != "" && *c.dataCenter != dc.Id {
+			continue
+		}
+		var racks []*master_pb.RackInfo
+		for _, r := range dc.RackInfos {
+			if *c.rack != "" && *c.rack != r.Id {
+				continue
+			}
+			var dns []*master_pb.DataNodeInfo
+			for _, dn := range r.DataNodeInfos {
+				if *c.dataNode != "" && *c.dataNode != dn.Id {
+					continue
+				}
+				for _, diskInfo := range dn.DiskInfos {
+					var volumes []*master_pb.VolumeInformationMessage
+					for _, vi := range diskInfo.VolumeInfos {
+						if !c.isNotMatchDiskInfo(vi.ReadOnly, vi.Collection, vi.Id) {
+							volumes = append(volumes, vi)
+						}
+					}
+					var ecShards []*master_pb.VolumeEcShardInformationMessage
+					for _, ecShardInfo := range diskInfo.EcShardInfos {
+						if !c.isNotMatchDiskInfo(false, ecShardInfo.Collection, ecShardInfo.Id) {
+							ecShards = append(ecShards, ecShardInfo)
+						}
+					}
+					diskInfo.VolumeInfos, diskInfo.EcShardInfos = volumes, ecShards
+				}
+				dns = append(dns, dn)
+			}
+			r.DataNodeInfos = dns
+			racks = append(racks, r)
+		}
+		dc.RackInfos = racks
+		dcs = append(dcs, dc)
+	}
+	t.DataCenterInfos = dcs
+	return t
+}
+
 func diskInfosToString(diskInfos map[string]*master_pb.DiskInfo) string {
 	var buf bytes.Buffer
 	for diskType, diskInfo := range diskInfos {
@@ -129,7 +194,11 @@ func (c *commandVolumeList) writeRackInfo(writer io.Writer, t *master_pb.RackInf
 }
 
 func (c *commandVolumeList) writeDataNodeInfo(writer io.Writer, t *master_pb.DataNodeInfo, verbosityLevel int) statistics {
-	output(verbosityLevel >= 3, writer, "      DataNode %s%s\n", t.Id, diskInfosToString(t.DiskInfos))
+	var maintenance string
+	if t.IsInMaintenance {
+		maintenance = " maintenance"
+	}
+	output(verbosityLevel >= 3, writer, "      DataNode %s%s%s\n", t.Id, maintenance, diskInfosToString(t.DiskInfos))
 	var s statistics
 	for _, diskInfo := range t.DiskInfos {
 		s = s.plus(c.writeDiskInfo(writer, diskInfo, verbosityLevel))
@@ -191,10 +260,10 @@ func output(condition bool, w io.Writer, format string, a ...interface{}) {
 }
 
 type statistics struct {
-	Size             uint64
-	FileCount        uint64
-	DeletedFileCount uint64
-	DeletedBytes     uint64
+	Size             uint64 `json:"size"`
+	FileCount        uint64 `json:"fileCount"`
+	DeletedFileCount uint64 `json:"deletedFileCount"`
+	DeletedBytes     uint64 `json:"deletedBytes"`
 }
 
 func newStatistics(t *master_pb.VolumeInformationMessage) statistics {
@@ -0,0 +1,104 @@
+package shell
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeManifest{})
+}
+
+type commandVolumeManifest struct {
+}
+
+func (c *commandVolumeManifest) Name() string {
+	return "volume.manifest"
+}
+
+func (c *commandVolumeManifest) Help() string {
+	return `export the signed manifest of a volume, listing the id, cookie, size and checksum of its needles
+
+	volume.manifest -volumeId <volume id> [-node <volume server host:port>] [-o <file>]
+
+	The manifest is read from the first volume server of the volume, or the one given by -node,
+	and signed by it with the volume.manifest.key in security.toml.
+	Each line is "<needle id> <cookie> <size> <crc>", in the needle id order and hex except the size,
+	and the last line is "signature <volume id> <collection> <entry count> <signed at ns> <hmac-sha256>".
+	The audit systems holding the key validate the holdings without reading the volume data.
+
+`
+}
+
+func (c *commandVolumeManifest) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	manifestCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeId := manifestCommand.Int("volumeId", 0, "the volume id")
+	nodeStr := manifestCommand.String("node", "", "the volume server <host>:<port>")
+	outputFileName := manifestCommand.String("o", "", "output the manifest to this file")
+	if err = manifestCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	vid := needle.VolumeId(*volumeId)
+	if vid == 0 {
+		return fmt.Errorf("missing -volumeId")
+	}
+	volumeServer := pb.ServerAddress(*nodeStr)
+	if volumeServer == "" {
+		locations, found := commandEnv.MasterClient.GetLocationsClone(uint32(vid))
+		if !found || len(locations) == 0 {
+			return fmt.Errorf("volume %d not found", vid)
+		}
+		volumeServer = locations[0].ServerAddress()
+	}
+
+	dst := writer
+	if *outputFileName != "" {
+		f, openErr := os.OpenFile(*outputFileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if openErr != nil {
+			return fmt.Errorf("failed to create file %s: %v", *outputFileName, openErr)
+		}
+		defer f.Close()
+		dst = f
+	}
+
+	err = operation.WithVolumeServerClient(true, volumeServer, commandEnv.option.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		stream, streamErr := client.VolumeManifest(context.Background(), &volume_server_pb.VolumeManifestRequest{
+			VolumeId: uint32(vid),
+		})
+		if streamErr != nil {
+			return streamErr
+		}
+		for {
+			resp, recvErr := stream.Recv()
+			if recvErr == io.EOF {
+				return fmt.Errorf("manifest of volume %d on %s ends without the signature", vid, volumeServer)
+			}
+			if recvErr != nil {
+				return recvErr
+			}
+			for _, entry := range resp.Entries {
+				fmt.Fprintf(dst, "%x %08x %d %08x\n", entry.NeedleId, entry.Cookie, entry.Size, entry.Crc)
+			}
+			if len(resp.Signature) > 0 {
+				fmt.Fprintf(dst, "signature %d %q %d %d %s\n", vid, resp.Collection, resp.EntryCount, resp.SignedAtNs, hex.EncodeToString(resp.Signature))
+				if *outputFileName != "" {
+					fmt.Fprintf(writer, "manifest of volume %d on %s with %d needles is saved to %s\n", vid, volumeServer, resp.EntryCount, *outputFileName)
+				}
+				return nil
+			}
+		}
+	})
+
+	return err
+}
@@ -45,6 +45,10 @@ func (c *commandVolumeMove) Help() string {
 
 	The option "-disk [hdd|ssd|<tag>]" can be used to change the volume disk type.
 
+	The .dat file is copied in segments, each verified by its checksum. With "-parallel <n>",
+	n segments are copied at the same time, all within the "-ioBytePerSecond" limit.
+	An interrupted copy is retried, and resumes from the segments already copied to the target.
+
 `
 }
 
@@ -56,6 +60,7 @@ func (c *commandVolumeMove) Do(args []string, commandEnv *CommandEnv, writer io.
 	targetNodeStr := volMoveCommand.String("target", "", "the target volume server <host>:<port>")
 	diskTypeStr := volMoveCommand.String("disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
 	ioBytePerSecond := volMoveCommand.Int64("ioBytePerSecond", 0, "limit the speed of move")
+	parallelSegments := volMoveCommand.Int("parallel", 1, "number of the .dat file segments copied in parallel")
 	if err = volMoveCommand.Parse(args); err != nil {
 		return nil
 	}
@@ -72,14 +77,14 @@ func (c *commandVolumeMove) Do(args []string, commandEnv *CommandEnv, writer io.
 		return fmt.Errorf("source and target volume servers are the same!")
 	}
 
-	return LiveMoveVolume(commandEnv.option.GrpcDialOption, writer, volumeId, sourceVolumeServer, targetVolumeServer, 5*time.Second, *diskTypeStr, *ioBytePerSecond, false)
+	return LiveMoveVolume(commandEnv.option.GrpcDialOption, writer, volumeId, sourceVolumeServer, targetVolumeServer, 5*time.Second, *diskTypeStr, *ioBytePerSecond, *parallelSegments, false)
 }
 
 // LiveMoveVolume moves one volume from one source volume server to one target volume server, with idleTimeout to drain the incoming requests.
-func LiveMoveVolume(grpcDialOption grpc.DialOption, writer io.Writer, volumeId needle.VolumeId, sourceVolumeServer, targetVolumeServer pb.ServerAddress, idleTimeout time.Duration, diskType string, ioBytePerSecond int64, skipTailError bool) (err error) {
+func LiveMoveVolume(grpcDialOption grpc.DialOption, writer io.Writer, volumeId needle.VolumeId, sourceVolumeServer, targetVolumeServer pb.ServerAddress, idleTimeout time.Duration, diskType string, ioBytePerSecond int64, parallelSegments int, skipTailError bool) (err error) {
 
 	log.Printf("copying volume %d from %s to %s", volumeId, sourceVolumeServer, targetVolumeServer)
-	lastAppendAtNs, err := copyVolume(grpcDialOption, writer, volumeId, sourceVolumeServer, targetVolumeServer, diskType, ioBytePerSecond)
+	lastAppendAtNs, err := copyVolume(grpcDialOption, writer, volumeId, sourceVolumeServer, targetVolumeServer, diskType, ioBytePerSecond, parallelSegments)
 	if err != nil {
 		return fmt.Errorf("copy volume %d from %s to %s: %v", volumeId, sourceVolumeServer, targetVolumeServer, err)
 	}
@@ -102,7 +107,10 @@ func LiveMoveVolume(grpcDialOption grpc.DialOption, writer io.Writer, volumeId n
 	return nil
 }
 
-func copyVolume(grpcDialOption grpc.DialOption, writer io.Writer, volumeId needle.VolumeId, sourceVolumeServer, targetVolumeServer pb.ServerAddress, diskType string, ioBytePerSecond int64) (lastAppendAtNs uint64, err error) {
+// copyVolumeAttempts retries copying the volume, which resumes from the segments already copied to the target
+const copyVolumeAttempts = 3
+
+func copyVolume(grpcDialOption grpc.DialOption, writer io.Writer, volumeId needle.VolumeId, sourceVolumeServer, targetVolumeServer pb.ServerAddress, diskType string, ioBytePerSecond int64, parallelSegments int) (lastAppendAtNs uint64, err error) {
 
 	// check to see if the volume is already read-only and if its not then we need
 	// to mark it as read-only and then before we return we need to undo what we
@@ -141,12 +149,27 @@ func copyVolume(grpcDialOption grpc.DialOption, writer io.Writer, volumeId needl
 		return
 	}
 
+	for attempt := 1; attempt <= copyVolumeAttempts; attempt++ {
+		if attempt > 1 {
+			fmt.Fprintf(writer, "retry copying volume %d, resuming from the copied segments: %v\n", volumeId, err)
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if lastAppendAtNs, err = copyVolumeOnce(grpcDialOption, writer, volumeId, sourceVolumeServer, targetVolumeServer, diskType, ioBytePerSecond, parallelSegments); err == nil {
+			return
+		}
+	}
+
+	return
+}
+
+func copyVolumeOnce(grpcDialOption grpc.DialOption, writer io.Writer, volumeId needle.VolumeId, sourceVolumeServer, targetVolumeServer pb.ServerAddress, diskType string, ioBytePerSecond int64, parallelSegments int) (lastAppendAtNs uint64, err error) {
 	err = operation.WithVolumeServerClient(true, targetVolumeServer, grpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
 		stream, replicateErr := volumeServerClient.VolumeCopy(context.Background(), &volume_server_pb.VolumeCopyRequest{
-			VolumeId:        uint32(volumeId),
-			SourceDataNode:  string(sourceVolumeServer),
-			DiskType:        diskType,
-			IoBytePerSecond: ioBytePerSecond,
+			VolumeId:         uint32(volumeId),
+			SourceDataNode:   string(sourceVolumeServer),
+			DiskType:         diskType,
+			IoBytePerSecond:  ioBytePerSecond,
+			ParallelSegments: uint32(parallelSegments),
 		})
 		if replicateErr != nil {
 			return replicateErr
@@ -212,9 +235,9 @@ func markVolumeWritable(grpcDialOption grpc.DialOption, volumeId needle.VolumeId
 	})
 }
 
-func markVolumeReplicasWritable(grpcDialOption grpc.DialOption, volumeId needle.VolumeId, locations []wdclient.Location, writable bool) error {
+func markVolumeReplicasWritable(grpcDialOption grpc.DialOption, writer io.Writer, volumeId needle.VolumeId, locations []wdclient.Location, writable bool) error {
 	for _, location := range locations {
-		fmt.Printf("markVolumeReadonly %d on %s ...\n", volumeId, location.Url)
+		fmt.Fprintf(writer, "markVolumeReadonly %d on %s ...\n", volumeId, location.Url)
 		if err := markVolumeWritable(grpcDialOption, volumeId, location.ServerAddress(), writable); err != nil {
 			return err
 		}
@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeRepair{})
+}
+
+type commandVolumeRepair struct {
+}
+
+func (c *commandVolumeRepair) Name() string {
+	return "volume.repair"
+}
+
+func (c *commandVolumeRepair) Help() string {
+	return `show the re-replication of the volumes missing replicas by the master
+
+	volume.repair
+
+	With "weed master -repair.maxConcurrent", the master re-replicates the volumes missing replicas
+	after the volume servers fail. A volume is repaired once it misses replicas for "-repair.delay",
+	and the volumes with the fewest replicas left go first. The volumes missing the replicas on
+	the volume servers in maintenance are deferred until the grace period ends.
+
+	The volumes are listed in the repair order.
+`
+}
+
+func (c *commandVolumeRepair) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	volRepairCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	if err = volRepairCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	return commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		resp, listErr := client.ListVolumeRepairs(context.Background(), &master_pb.ListVolumeRepairsRequest{})
+		if listErr != nil {
+			return listErr
+		}
+		if resp.MaxConcurrent == 0 {
+			fmt.Fprintf(writer, "the master does not re-replicate the volumes, run volume.fix.replication instead\n")
+			return nil
+		}
+		fmt.Fprintf(writer, "repairing %d volumes at a time after %v, at %d bytes per second, repaired %d, failed %d\n",
+			resp.MaxConcurrent, time.Duration(resp.DelaySeconds)*time.Second, resp.BytePerSecond, resp.RepairedCount, resp.FailedCount)
+		if len(resp.Repairs) == 0 {
+			fmt.Fprintf(writer, "no volumes are missing replicas\n")
+		}
+		for _, r := range resp.Repairs {
+			state := "waiting"
+			switch {
+			case r.Target != "":
+				state = "copying to " + r.Target
+			case r.IsDeferred:
+				state = "deferred for maintenance"
+			}
+			fmt.Fprintf(writer, "volume %d collection %q replication %s has %d replicas since %s, %s\n",
+				r.VolumeId, r.Collection, r.Replication, r.ReplicaCount, time.Unix(0, r.FirstSeenNs).Format(time.RFC3339), state)
+			if r.LastError != "" {
+				fmt.Fprintf(writer, "  last error: %s\n", r.LastError)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,194 @@
+package shell
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeRetention{})
+}
+
+type commandVolumeRetention struct {
+}
+
+func (c *commandVolumeRetention) Name() string {
+	return "volume.retention"
+}
+
+func (c *commandVolumeRetention) Help() string {
+	return `seal, tier, and delete the whole volumes of a collection as they age
+
+	volume.retention -collection=<collection_pattern> [-sealAfter=24h] [-tierAfter=168h -tierBackend=s3.default] [-deleteAfter=720h] [-force]
+
+	Each volume of the collection is aged as a unit, which is much cheaper than the TTL of each needle
+	for the uniformly aging data, e.g. logs:
+	  * sealed as read only, once created for -sealAfter, so each volume holds the data of a bounded time span.
+	  * moved to the remote tier -tierBackend, once not written for -tierAfter.
+	  * deleted with all its replicas, once not written for -deleteAfter, so no needle is younger than the retention.
+	A zero period skips the step. The volumes created before their creation time is recorded are not sealed by age.
+
+	Without -force, the actions are only printed. To apply the retention periodically,
+	add the command to the maintenance scripts in master.toml, e.g.:
+	  volume.retention -collection=logs -sealAfter=24h -deleteAfter=720h -force
+
+`
+}
+
+type volumeRetentionAction int
+
+const (
+	volumeRetentionKeep volumeRetentionAction = iota
+	volumeRetentionSeal
+	volumeRetentionTier
+	volumeRetentionDelete
+)
+
+func (a volumeRetentionAction) String() string {
+	switch a {
+	case volumeRetentionSeal:
+		return "seal"
+	case volumeRetentionTier:
+		return "tier"
+	case volumeRetentionDelete:
+		return "delete"
+	}
+	return "keep"
+}
+
+type volumeRetention struct {
+	sealAfter   time.Duration
+	tierAfter   time.Duration
+	deleteAfter time.Duration
+}
+
+type volumeReplica struct {
+	location *master_pb.DataNodeInfo
+	info     *master_pb.VolumeInformationMessage
+}
+
+func (c *commandVolumeRetention) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	retentionCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	collectionPattern := retentionCommand.String("collection", "", "the collection name pattern, e.g. \"logs_*\"")
+	sealAfter := retentionCommand.Duration("sealAfter", 0, "seal the volumes created for this period as read only, 0 to skip")
+	tierAfter := retentionCommand.Duration("tierAfter", 0, "move the volumes not written for this period to the remote tier, 0 to skip")
+	tierBackend := retentionCommand.String("tierBackend", "", "the remote tier, e.g. \"s3.default\" in [storage.backend.s3.default] of master.toml")
+	deleteAfter := retentionCommand.Duration("deleteAfter", 0, "delete the volumes not written for this period, 0 to skip")
+	applyChanges := retentionCommand.Bool("force", false, "apply the retention, instead of printing the actions")
+	if err = retentionCommand.Parse(args); err != nil {
+		return nil
+	}
+	infoAboutSimulationMode(writer, *applyChanges, "-force")
+
+	if *collectionPattern == "" {
+		return fmt.Errorf("the collection of the retention is not set")
+	}
+	if *tierAfter > 0 && *tierBackend == "" {
+		return fmt.Errorf("the remote tier of -tierAfter is not set")
+	}
+	if *deleteAfter > 0 && *tierAfter >= *deleteAfter {
+		return fmt.Errorf("-tierAfter %v is not before -deleteAfter %v", *tierAfter, *deleteAfter)
+	}
+	retention := volumeRetention{sealAfter: *sealAfter, tierAfter: *tierAfter, deleteAfter: *deleteAfter}
+
+	if err = commandEnv.confirmIsLocked(args); err != nil {
+		return
+	}
+
+	topologyInfo, _, err := collectTopologyInfo(commandEnv, 0)
+	if err != nil {
+		return err
+	}
+	volumeReplicas := make(map[uint32][]volumeReplica)
+	eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+		for _, diskInfo := range dn.DiskInfos {
+			for _, v := range diskInfo.VolumeInfos {
+				if matched, _ := filepath.Match(*collectionPattern, v.Collection); matched {
+					volumeReplicas[v.Id] = append(volumeReplicas[v.Id], volumeReplica{location: dn, info: v})
+				}
+			}
+		}
+	})
+	var vids []uint32
+	for vid := range volumeReplicas {
+		vids = append(vids, vid)
+	}
+	sort.Slice(vids, func(i, j int) bool { return vids[i] < vids[j] })
+
+	now := time.Now()
+	for _, vid := range vids {
+		replicas := volumeReplicas[vid]
+		action := retention.actionOf(replicas, now)
+		if action == volumeRetentionKeep {
+			continue
+		}
+		fmt.Fprintf(writer, "%s volume %d of collection %s\n", action, vid, replicas[0].info.Collection)
+		if !*applyChanges {
+			continue
+		}
+		if err = applyVolumeRetention(commandEnv, writer, replicas, action, *tierBackend); err != nil {
+			return fmt.Errorf("%s volume %d: %v", action, vid, err)
+		}
+	}
+
+	return nil
+}
+
+// actionOf ages the volume by its newest replica, and takes the last step due
+func (r volumeRetention) actionOf(replicas []volumeReplica, now time.Time) volumeRetentionAction {
+	var modifiedAtSecond, createdAtSecond int64
+	isReadOnly, isRemote := true, false
+	for _, replica := range replicas {
+		if replica.info.ModifiedAtSecond > modifiedAtSecond {
+			modifiedAtSecond = replica.info.ModifiedAtSecond
+		}
+		if replica.info.CreatedAtSecond > createdAtSecond {
+			createdAtSecond = replica.info.CreatedAtSecond
+		}
+		isReadOnly = isReadOnly && replica.info.ReadOnly
+		isRemote = isRemote || replica.info.RemoteStorageName != ""
+	}
+	if modifiedAtSecond > 0 {
+		quietFor := now.Sub(time.Unix(modifiedAtSecond, 0))
+		if r.deleteAfter > 0 && quietFor > r.deleteAfter {
+			return volumeRetentionDelete
+		}
+		if r.tierAfter > 0 && quietFor > r.tierAfter && !isRemote {
+			return volumeRetentionTier
+		}
+	}
+	if r.sealAfter > 0 && createdAtSecond > 0 && now.Sub(time.Unix(createdAtSecond, 0)) > r.sealAfter && !isReadOnly {
+		return volumeRetentionSeal
+	}
+	return volumeRetentionKeep
+}
+
+func applyVolumeRetention(commandEnv *CommandEnv, writer io.Writer, replicas []volumeReplica, action volumeRetentionAction, tierBackend string) error {
+	vid := needle.VolumeId(replicas[0].info.Id)
+	switch action {
+	case volumeRetentionSeal:
+		for _, replica := range replicas {
+			if err := markVolumeWritable(commandEnv.option.GrpcDialOption, vid, pb.NewServerAddressFromDataNode(replica.location), false); err != nil {
+				return err
+			}
+		}
+	case volumeRetentionTier:
+		return doVolumeTierUpload(commandEnv, writer, replicas[0].info.Collection, vid, tierBackend, false)
+	case volumeRetentionDelete:
+		for _, replica := range replicas {
+			if err := deleteVolume(commandEnv.option.GrpcDialOption, vid, pb.NewServerAddressFromDataNode(replica.location), false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
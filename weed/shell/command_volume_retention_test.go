@@ -0,0 +1,48 @@
+package shell
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+func TestVolumeRetentionAction(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	hoursAgo := func(hours int) int64 {
+		return now.Add(-time.Duration(hours) * time.Hour).Unix()
+	}
+	retention := volumeRetention{sealAfter: 24 * time.Hour, tierAfter: 48 * time.Hour, deleteAfter: 96 * time.Hour}
+
+	tests := []struct {
+		name     string
+		infos    []*master_pb.VolumeInformationMessage
+		expected volumeRetentionAction
+	}{
+		{"new", []*master_pb.VolumeInformationMessage{{CreatedAtSecond: hoursAgo(1), ModifiedAtSecond: hoursAgo(1)}}, volumeRetentionKeep},
+		{"created a day ago", []*master_pb.VolumeInformationMessage{{CreatedAtSecond: hoursAgo(25), ModifiedAtSecond: hoursAgo(1)}}, volumeRetentionSeal},
+		{"sealed", []*master_pb.VolumeInformationMessage{{CreatedAtSecond: hoursAgo(25), ModifiedAtSecond: hoursAgo(1), ReadOnly: true}}, volumeRetentionKeep},
+		{"one writable replica", []*master_pb.VolumeInformationMessage{
+			{CreatedAtSecond: hoursAgo(25), ModifiedAtSecond: hoursAgo(1), ReadOnly: true},
+			{CreatedAtSecond: hoursAgo(25), ModifiedAtSecond: hoursAgo(1)},
+		}, volumeRetentionSeal},
+		{"creation not recorded", []*master_pb.VolumeInformationMessage{{ModifiedAtSecond: hoursAgo(30)}}, volumeRetentionKeep},
+		{"quiet for two days", []*master_pb.VolumeInformationMessage{{CreatedAtSecond: hoursAgo(60), ModifiedAtSecond: hoursAgo(50), ReadOnly: true}}, volumeRetentionTier},
+		{"tiered", []*master_pb.VolumeInformationMessage{{CreatedAtSecond: hoursAgo(60), ModifiedAtSecond: hoursAgo(50), ReadOnly: true, RemoteStorageName: "s3.default"}}, volumeRetentionKeep},
+		{"one replica written lately", []*master_pb.VolumeInformationMessage{
+			{CreatedAtSecond: hoursAgo(100), ModifiedAtSecond: hoursAgo(99)},
+			{CreatedAtSecond: hoursAgo(100), ModifiedAtSecond: hoursAgo(50)},
+		}, volumeRetentionTier},
+		{"expired", []*master_pb.VolumeInformationMessage{{CreatedAtSecond: hoursAgo(100), ModifiedAtSecond: hoursAgo(99), RemoteStorageName: "s3.default"}}, volumeRetentionDelete},
+		{"never written", []*master_pb.VolumeInformationMessage{{CreatedAtSecond: hoursAgo(100)}}, volumeRetentionSeal},
+	}
+	for _, tt := range tests {
+		var replicas []volumeReplica
+		for _, info := range tt.infos {
+			replicas = append(replicas, volumeReplica{info: info})
+		}
+		if action := retention.actionOf(replicas, now); action != tt.expected {
+			t.Errorf("%s: %s, expected %s", tt.name, action, tt.expected)
+		}
+	}
+}
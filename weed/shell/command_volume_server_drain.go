@@ -0,0 +1,100 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeServerDrain{})
+}
+
+type commandVolumeServerDrain struct {
+}
+
+func (c *commandVolumeServerDrain) Name() string {
+	return "volumeServer.drain"
+}
+
+func (c *commandVolumeServerDrain) Help() string {
+	return `drain a volume server and let it exit once empty
+
+	volumeServer.drain -node <volume server host:port>
+	volumeServer.drain -node <volume server host:port> -cancel
+	volumeServer.drain    # show the progress of the draining volume servers
+
+	The volume server stops accepting writes, and reports its volumes as read only.
+	The master moves its volumes and ec shards to the other volume servers one at a time,
+	pausing between the moves as configured by "weed master -drain.interval".
+	Once empty, the volume server stops sending heartbeats and exits.
+
+	Cancelling makes the volume server writable again, with the moved volumes and ec shards staying on the peers.
+`
+}
+
+func (c *commandVolumeServerDrain) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	vsDrainCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeServer := vsDrainCommand.String("node", "", "<host>:<port> of the volume server")
+	cancel := vsDrainCommand.Bool("cancel", false, "stop draining the volume server")
+	if err = vsDrainCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if *volumeServer == "" {
+		return listVolumeServerDrains(commandEnv, writer)
+	}
+
+	if err = commandEnv.confirmIsLocked(args); err != nil {
+		return
+	}
+
+	return commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		_, drainErr := client.DrainVolumeServer(context.Background(), &master_pb.DrainVolumeServerRequest{
+			Node:   *volumeServer,
+			Cancel: *cancel,
+		})
+		if drainErr != nil {
+			return fmt.Errorf("drain volume server %s: %v", *volumeServer, drainErr)
+		}
+		if *cancel {
+			fmt.Fprintf(writer, "stopped draining volume server %s\n", *volumeServer)
+		} else {
+			fmt.Fprintf(writer, "draining volume server %s, run volumeServer.drain to see the progress\n", *volumeServer)
+		}
+		return nil
+	})
+}
+
+func listVolumeServerDrains(commandEnv *CommandEnv, writer io.Writer) error {
+	return commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		resp, err := client.ListVolumeServerDrains(context.Background(), &master_pb.ListVolumeServerDrainsRequest{})
+		if err != nil {
+			return err
+		}
+		if len(resp.Drains) == 0 {
+			fmt.Fprintf(writer, "no volume servers are draining\n")
+		}
+		for _, d := range resp.Drains {
+			state := "draining"
+			if d.IsDone {
+				state = "drained"
+			}
+			fmt.Fprintf(writer, "%s %s since %s: moved %d volumes %d ec shards, remaining %d volumes %d ec shards\n",
+				d.Node, state, time.Unix(0, d.StartedAtNs).Format(time.RFC3339),
+				d.MovedVolumeCount, d.MovedEcShardCount, d.RemainingVolumeCount, d.RemainingEcShardCount)
+			if d.CurrentMove != "" {
+				fmt.Fprintf(writer, "  moving %s\n", d.CurrentMove)
+			}
+			if d.LastError != "" {
+				fmt.Fprintf(writer, "  last error: %s\n", d.LastError)
+			}
+		}
+		return nil
+	})
+}
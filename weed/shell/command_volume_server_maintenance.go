@@ -0,0 +1,134 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeServerMaintenance{})
+}
+
+type commandVolumeServerMaintenance struct {
+}
+
+func (c *commandVolumeServerMaintenance) Name() string {
+	return "volumeServer.maintenance"
+}
+
+func (c *commandVolumeServerMaintenance) Help() string {
+	return `put a volume server or a whole rack in maintenance, e.g. for a planned reboot or firmware update
+
+	volumeServer.maintenance -node <volume server host:port> [-gracePeriod=1h] [-reason="firmware update"]
+	volumeServer.maintenance -rack <rack> [-dataCenter <data center>] [-gracePeriod=1h]
+	volumeServer.maintenance -node <volume server host:port> -cancel
+	volumeServer.maintenance    # show the volume servers and racks in maintenance
+
+	In maintenance, no new volumes are placed on the volume servers, and no new writes are assigned
+	to the volumes with a replica on them, while the reads continue.
+	Once the volume servers go offline, their volumes are not re-replicated by volume.fix.replication or the master
+	until the grace period ends. Setting the maintenance again extends the grace period.
+	The volume servers are shown with "maintenance" in volume.list.
+
+	Cancelling makes the volumes writable again.
+`
+}
+
+func (c *commandVolumeServerMaintenance) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	maintenanceCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeServer := maintenanceCommand.String("node", "", "<host>:<port> of the volume server")
+	dataCenter := maintenanceCommand.String("dataCenter", "", "the data center of the rack")
+	rack := maintenanceCommand.String("rack", "", "the rack, instead of the volume server")
+	gracePeriod := maintenanceCommand.Duration("gracePeriod", time.Hour, "defer re-replicating the volumes of the offline volume servers for this long")
+	reason := maintenanceCommand.String("reason", "", "the reason shown with the maintenance")
+	cancel := maintenanceCommand.Bool("cancel", false, "end the maintenance")
+	if err = maintenanceCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if *volumeServer == "" && *rack == "" {
+		return listMaintenances(commandEnv, writer)
+	}
+	if *volumeServer != "" && *rack != "" {
+		return fmt.Errorf("set either -node or -rack")
+	}
+
+	if err = commandEnv.confirmIsLocked(args); err != nil {
+		return
+	}
+
+	target := *volumeServer
+	if target == "" {
+		target = fmt.Sprintf("rack %s %s", *dataCenter, *rack)
+	}
+	return commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		_, setErr := client.SetMaintenance(context.Background(), &master_pb.SetMaintenanceRequest{
+			Node:               *volumeServer,
+			DataCenter:         *dataCenter,
+			Rack:               *rack,
+			GracePeriodSeconds: int64(gracePeriod.Seconds()),
+			Reason:             *reason,
+			Cancel:             *cancel,
+		})
+		if setErr != nil {
+			return fmt.Errorf("maintenance of %s: %v", target, setErr)
+		}
+		if *cancel {
+			fmt.Fprintf(writer, "ended the maintenance of %s\n", target)
+		} else {
+			fmt.Fprintf(writer, "%s is in maintenance, with a grace period of %v\n", target, *gracePeriod)
+		}
+		return nil
+	})
+}
+
+func listMaintenances(commandEnv *CommandEnv, writer io.Writer) error {
+	return commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		resp, err := client.ListMaintenances(context.Background(), &master_pb.ListMaintenancesRequest{})
+		if err != nil {
+			return err
+		}
+		if len(resp.Maintenances) == 0 {
+			fmt.Fprintf(writer, "no volume servers are in maintenance\n")
+		}
+		for _, m := range resp.Maintenances {
+			target := m.Node
+			if target == "" {
+				target = fmt.Sprintf("rack %s %s", m.DataCenter, m.Rack)
+			}
+			fmt.Fprintf(writer, "%s in maintenance since %s, grace period until %s %s\n", target,
+				time.Unix(0, m.StartedAtNs).Format(time.RFC3339), time.Unix(0, m.GraceUntilNs).Format(time.RFC3339), m.Reason)
+			if len(m.OnlineNodes) > 0 {
+				fmt.Fprintf(writer, "  online: %v\n", m.OnlineNodes)
+			}
+			if len(m.OfflineNodes) > 0 {
+				fmt.Fprintf(writer, "  offline: %v, re-replication deferred for %d volumes\n", m.OfflineNodes, len(m.DeferredVolumeIds))
+			}
+		}
+		return nil
+	})
+}
+
+// collectDeferredVolumeIds lists the volumes on the offline volume servers in maintenance, not to re-replicate
+func collectDeferredVolumeIds(commandEnv *CommandEnv) (deferred map[uint32]bool, err error) {
+	deferred = make(map[uint32]bool)
+	err = commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+		resp, listErr := client.ListMaintenances(context.Background(), &master_pb.ListMaintenancesRequest{})
+		if listErr != nil {
+			return listErr
+		}
+		for _, m := range resp.Maintenances {
+			for _, vid := range m.DeferredVolumeIds {
+				deferred[vid] = true
+			}
+		}
+		return nil
+	})
+	return
+}
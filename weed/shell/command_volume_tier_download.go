@@ -73,7 +73,7 @@ func (c *commandVolumeTierDownload) Do(args []string, commandEnv *CommandEnv, wr
 	if err != nil {
 		return err
 	}
-	fmt.Printf("tier download volumes: %v\n", volumeIds)
+	fmt.Fprintf(writer, "tier download volumes: %v\n", volumeIds)
 	for _, vid := range volumeIds {
 		if err = doVolumeTierDownload(commandEnv, writer, *collection, vid); err != nil {
 			return err
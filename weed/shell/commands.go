@@ -29,6 +29,7 @@ type ShellOptions struct {
 	FilerGroup   *string
 	FilerAddress pb.ServerAddress
 	Directory    string
+	Output       *string // text or json, text if nil
 }
 
 type CommandEnv struct {
@@ -36,6 +37,7 @@ type CommandEnv struct {
 	MasterClient *wdclient.MasterClient
 	option       *ShellOptions
 	locker       *exclusive_locks.ExclusiveLocker
+	result       interface{} // the structured result of the running command
 }
 
 type command interface {
@@ -0,0 +1,121 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// Besides the prompt, weed shell runs a script of commands, e.g. from cron or CI. A script line has one
+// or more commands separated by ";", and the lines starting with "#" are comments. ${name} is replaced by
+// the variable of the name, or else the environment variable, and a command with an undefined variable
+// is not run. The exit code is of the first failure, and with fail-fast the script stops there.
+
+// The exit codes of a script
+const (
+	ExitOk             = 0
+	ExitCommandFailed  = 1 // a command returned an error
+	ExitUnknownCommand = 2
+	ExitBadScript      = 3 // a command with an undefined variable
+	ExitNotConnected   = 4 // no master connected within the timeout
+)
+
+type BatchOptions struct {
+	FailFast       bool
+	Vars           map[string]string
+	ConnectTimeout time.Duration
+}
+
+var scriptVarRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_.]*)\}`)
+
+// RunBatch runs the script without the prompt, and returns the exit code
+func RunBatch(options ShellOptions, script string, batch BatchOptions) int {
+	slices.SortFunc(Commands, func(a, b command) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	commandEnv := NewCommandEnv(&options)
+	if err := connectCluster(commandEnv, os.Stderr, batch.ConnectTimeout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return ExitNotConnected
+	}
+
+	return runScript(script, batch, func(cmd string) (bool, int) {
+		return runEachCmd(cmd, commandEnv)
+	})
+}
+
+func runScript(script string, batch BatchOptions, run func(cmd string) (quit bool, exitCode int)) int {
+	exitCode := ExitOk
+	// fail records the first failure, and tells whether to stop
+	fail := func(code int) bool {
+		if exitCode == ExitOk {
+			exitCode = code
+		}
+		return batch.FailFast
+	}
+	for i, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, cmd := range strings.Split(line, ";") {
+			cmd, err := substituteScriptVars(strings.TrimSpace(cmd), batch.Vars)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "line %d: %v\n", i+1, err)
+				if fail(ExitBadScript) {
+					return exitCode
+				}
+				continue
+			}
+			quit, code := run(cmd)
+			if code != ExitOk && fail(code) {
+				return exitCode
+			}
+			if quit {
+				return exitCode
+			}
+		}
+	}
+	return exitCode
+}
+
+// substituteScriptVars replaces ${name} by the variable, or else the environment variable
+func substituteScriptVars(cmd string, vars map[string]string) (string, error) {
+	var undefined []string
+	cmd = scriptVarRegexp.ReplaceAllStringFunc(cmd, func(s string) string {
+		name := scriptVarRegexp.FindStringSubmatch(s)[1]
+		if value, found := vars[name]; found {
+			return value
+		}
+		if value, found := os.LookupEnv(name); found {
+			return value
+		}
+		undefined = append(undefined, name)
+		return s
+	})
+	if len(undefined) > 0 {
+		return cmd, fmt.Errorf("undefined variables %v in %q", undefined, cmd)
+	}
+	return cmd, nil
+}
+
+// ParseScriptVars parses the variables given as "name=value,name=value"
+func ParseScriptVars(s string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, pair := range util.StringSplit(s, ",") {
+		name, value, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("variable %q is not name=value", pair)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
@@ -0,0 +1,61 @@
+package shell
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunScript(t *testing.T) {
+	t.Setenv("SHELL_BATCH_TEST_DAYS", "30")
+	run := func(script string, failFast bool) (ran []string, exitCode int) {
+		vars := map[string]string{"collection": "logs"}
+		exitCode = runScript(script, BatchOptions{FailFast: failFast, Vars: vars}, func(cmd string) (bool, int) {
+			ran = append(ran, cmd)
+			switch cmd {
+			case "fails":
+				return false, ExitCommandFailed
+			case "unknown":
+				return false, ExitUnknownCommand
+			case "exit":
+				return true, ExitOk
+			}
+			return false, ExitOk
+		})
+		return
+	}
+
+	script := `
+# the maintenance
+lock; volume.list -collectionPattern ${collection}
+fs.find -olderThan ${SHELL_BATCH_TEST_DAYS}d ${undefined} /
+unknown ; fails
+exit
+never
+`
+	ran, exitCode := run(script, false)
+	if expected := []string{"lock", "volume.list -collectionPattern logs", "unknown", "fails", "exit"}; !reflect.DeepEqual(ran, expected) || exitCode != ExitBadScript {
+		t.Errorf("ran %q with exit code %d", ran, exitCode)
+	}
+	ran, exitCode = run(script, true)
+	if expected := []string{"lock", "volume.list -collectionPattern logs"}; !reflect.DeepEqual(ran, expected) || exitCode != ExitBadScript {
+		t.Errorf("fail fast ran %q with exit code %d", ran, exitCode)
+	}
+
+	ran, exitCode = run("fs.find -olderThan ${SHELL_BATCH_TEST_DAYS}d; fails; unknown", true)
+	if expected := []string{"fs.find -olderThan 30d", "fails"}; !reflect.DeepEqual(ran, expected) || exitCode != ExitCommandFailed {
+		t.Errorf("fail fast ran %q with exit code %d", ran, exitCode)
+	}
+	if _, exitCode = run("lock\nunlock", true); exitCode != ExitOk {
+		t.Errorf("exit code %d", exitCode)
+	}
+}
+
+func TestParseScriptVars(t *testing.T) {
+	vars, err := ParseScriptVars("collection=logs, days=30,empty=")
+	if expected := map[string]string{"collection": "logs", "days": "30", "empty": ""}; err != nil || !reflect.DeepEqual(vars, expected) {
+		t.Errorf("parsed %v: %v", vars, err)
+	}
+	if _, err = ParseScriptVars("collection"); err == nil {
+		t.Errorf("parsed a variable without a value")
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"path"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/peterh/liner"
 )
@@ -43,57 +44,19 @@ func RunShell(options ShellOptions) {
 
 	defer saveHistory()
 
-	reg, _ := regexp.Compile(`'.*?'|".*?"|\S+`)
-
 	commandEnv := NewCommandEnv(&options)
 
-	go commandEnv.MasterClient.KeepConnectedToMaster()
-	commandEnv.MasterClient.WaitUntilConnected()
-
-	if commandEnv.option.FilerAddress == "" {
-		var filers []pb.ServerAddress
-		commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
-			resp, err := client.ListClusterNodes(context.Background(), &master_pb.ListClusterNodesRequest{
-				ClientType: cluster.FilerType,
-				FilerGroup: *options.FilerGroup,
-			})
-			if err != nil {
-				return err
-			}
-
-			for _, clusterNode := range resp.ClusterNodes {
-				filers = append(filers, pb.ServerAddress(clusterNode.Address))
-			}
-			return nil
-		})
-		fmt.Printf("master: %s ", *options.Masters)
-		if len(filers) > 0 {
-			fmt.Printf("filers: %v", filers)
-			commandEnv.option.FilerAddress = filers[rand.Intn(len(filers))]
-		}
-		fmt.Println()
+	// keep the standard output for the json lines
+	var banner io.Writer = os.Stdout
+	prompt := "> "
+	if commandEnv.isJsonOutput() {
+		banner, prompt = os.Stderr, ""
 	}
 
-	if commandEnv.option.FilerAddress != "" {
-		commandEnv.WithFilerClient(false, func(filerClient filer_pb.SeaweedFilerClient) error {
-			resp, err := filerClient.GetFilerConfiguration(context.Background(), &filer_pb.GetFilerConfigurationRequest{})
-			if err != nil {
-				return err
-			}
-			if resp.ClusterId != "" {
-				fmt.Printf(`
----
-Free Monitoring Data URL:
-https://cloud.seaweedfs.com/ui/%s
----
-`, resp.ClusterId)
-			}
-			return nil
-		})
-	}
+	connectCluster(commandEnv, banner, 0)
 
 	for {
-		cmd, err := line.Prompt("> ")
+		cmd, err := line.Prompt(prompt)
 		if err != nil {
 			if err != io.EOF {
 				fmt.Printf("%v\n", err)
@@ -102,50 +65,126 @@ https://cloud.seaweedfs.com/ui/%s
 		}
 
 		for _, c := range util.StringSplit(cmd, ";") {
-			if processEachCmd(reg, c, commandEnv) {
+			if processEachCmd(c, commandEnv) {
 				return
 			}
 		}
 	}
 }
 
-func processEachCmd(reg *regexp.Regexp, cmd string, commandEnv *CommandEnv) bool {
-	cmds := reg.FindAllString(cmd, -1)
-
+func processEachCmd(cmd string, commandEnv *CommandEnv) bool {
 	line.AppendHistory(cmd)
 
+	quit, _ := runEachCmd(cmd, commandEnv)
+	return quit
+}
+
+var commandArgsRegexp = regexp.MustCompile(`'.*?'|".*?"|\S+`)
+
+// splitCommandArgs splits the command line by spaces, keeping the quoted arguments with spaces
+func splitCommandArgs(cmd string) (args []string) {
+	for _, arg := range commandArgsRegexp.FindAllString(cmd, -1) {
+		args = append(args, strings.Trim(arg, "\"'"))
+	}
+	return
+}
+
+// runEachCmd runs one command, and returns whether to quit, and the exit code of its failure
+func runEachCmd(cmd string, commandEnv *CommandEnv) (quit bool, exitCode int) {
+	cmds := splitCommandArgs(cmd)
+
 	if len(cmds) == 0 {
-		return false
+		return false, ExitOk
 	} else {
 
-		args := make([]string, len(cmds[1:]))
-
-		for i := range args {
-			args[i] = strings.Trim(string(cmds[1+i]), "\"'")
-		}
+		args := cmds[1:]
 
 		cmd := cmds[0]
 		if cmd == "help" || cmd == "?" {
 			printHelp(cmds)
 		} else if cmd == "exit" || cmd == "quit" {
-			return true
+			return true, ExitOk
 		} else {
 			foundCommand := false
 			for _, c := range Commands {
 				if c.Name() == cmd || c.Name() == "fs."+cmd {
-					if err := c.Do(args, commandEnv, os.Stdout); err != nil {
+					var err error
+					if commandEnv.isJsonOutput() {
+						err = runCommandJson(c, c.Name(), args, commandEnv, os.Stdout)
+					} else if err = c.Do(args, commandEnv, os.Stdout); err != nil {
 						fmt.Fprintf(os.Stderr, "error: %v\n", err)
 					}
+					if err != nil {
+						exitCode = ExitCommandFailed
+					}
 					foundCommand = true
 				}
 			}
 			if !foundCommand {
-				fmt.Fprintf(os.Stderr, "unknown command: %v\n", cmd)
+				if commandEnv.isJsonOutput() {
+					runCommandJson(nil, cmd, args, commandEnv, os.Stdout)
+				} else {
+					fmt.Fprintf(os.Stderr, "unknown command: %v\n", cmd)
+				}
+				exitCode = ExitUnknownCommand
 			}
 		}
 
 	}
-	return false
+	return false, exitCode
+}
+
+// connectCluster connects to the masters, waiting up to the timeout unless 0, and picks a filer unless given
+func connectCluster(commandEnv *CommandEnv, banner io.Writer, timeout time.Duration) error {
+	go commandEnv.MasterClient.KeepConnectedToMaster()
+	if timeout == 0 {
+		commandEnv.MasterClient.WaitUntilConnected()
+	} else if !commandEnv.MasterClient.WaitUntilConnectedWithTimeout(timeout) {
+		return fmt.Errorf("no master of %s connected in %v", *commandEnv.option.Masters, timeout)
+	}
+
+	if commandEnv.option.FilerAddress == "" {
+		var filers []pb.ServerAddress
+		commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+			resp, err := client.ListClusterNodes(context.Background(), &master_pb.ListClusterNodesRequest{
+				ClientType: cluster.FilerType,
+				FilerGroup: *commandEnv.option.FilerGroup,
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, clusterNode := range resp.ClusterNodes {
+				filers = append(filers, pb.ServerAddress(clusterNode.Address))
+			}
+			return nil
+		})
+		fmt.Fprintf(banner, "master: %s ", *commandEnv.option.Masters)
+		if len(filers) > 0 {
+			fmt.Fprintf(banner, "filers: %v", filers)
+			commandEnv.option.FilerAddress = filers[rand.Intn(len(filers))]
+		}
+		fmt.Fprintln(banner)
+	}
+
+	if commandEnv.option.FilerAddress != "" {
+		commandEnv.WithFilerClient(false, func(filerClient filer_pb.SeaweedFilerClient) error {
+			resp, err := filerClient.GetFilerConfiguration(context.Background(), &filer_pb.GetFilerConfigurationRequest{})
+			if err != nil {
+				return err
+			}
+			if resp.ClusterId != "" {
+				fmt.Fprintf(banner, `
+---
+Free Monitoring Data URL:
+https://cloud.seaweedfs.com/ui/%s
+---
+`, resp.ClusterId)
+			}
+			return nil
+		})
+	}
+	return nil
 }
 
 func printGenericHelp() {
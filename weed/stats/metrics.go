@@ -23,9 +23,10 @@ const (
 	NoWriteOrDelete  = "noWriteOrDelete"
 	NoWriteCanDelete = "noWriteCanDelete"
 	IsDiskSpaceLow   = "isDiskSpaceLow"
+	IsDiskFailing    = "isDiskFailing"
 )
 
-var readOnlyVolumeTypes = [4]string{IsReadOnly, NoWriteOrDelete, NoWriteCanDelete, IsDiskSpaceLow}
+var readOnlyVolumeTypes = [5]string{IsReadOnly, NoWriteOrDelete, NoWriteCanDelete, IsDiskSpaceLow, IsDiskFailing}
 
 var (
 	Gather = prometheus.NewRegistry()
@@ -78,6 +79,14 @@ var (
 			Help:      "Counter of master leader changes.",
 		}, []string{"type"})
 
+	MasterAdmissionCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "master",
+			Name:      "admission_rejected",
+			Help:      "Counter of the assign and lookup requests rejected by the master admission control.",
+		}, []string{"type"})
+
 	FilerRequestCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: Namespace,
@@ -103,6 +112,22 @@ var (
 			Help:      "The last send timestamp of the filer subscription.",
 		}, []string{"sourceFiler", "clientName", "path"})
 
+	FilerUsageBytesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "filer",
+			Name:      "usage_bytes",
+			Help:      "Total file size of each collection or top level directory.",
+		}, []string{"type", "name"})
+
+	FilerUsageFilesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "filer",
+			Name:      "usage_files",
+			Help:      "File count of each collection or top level directory.",
+		}, []string{"type", "name"})
+
 	FilerStoreCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: Namespace,
@@ -120,6 +145,14 @@ var (
 			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 24),
 		}, []string{"store", "type"})
 
+	FilerMetaCacheCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "filerStore",
+			Name:      "meta_cache_total",
+			Help:      "Counter of filer store meta cache hits and misses.",
+		}, []string{"type", "result"})
+
 	FilerSyncOffsetGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: Namespace,
@@ -128,6 +161,14 @@ var (
 			Help:      "The offset of the filer synchronization service.",
 		}, []string{"sourceFiler", "targetFiler", "clientName", "path"})
 
+	FilerSyncConflictCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "filerSync",
+			Name:      "conflicts",
+			Help:      "Counter of conflicts detected by the filer synchronization service.",
+		}, []string{"sourceFiler", "targetFiler", "resolution"})
+
 	VolumeServerRequestCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: Namespace,
@@ -152,6 +193,38 @@ var (
 			Help:      "Counter of volume vacuuming commit counter",
 		}, []string{"success"})
 
+	VolumeServerScrubCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "scrub_needles",
+			Help:      "Counter of needles checked, corrupted, repaired or failed to repair by the background scrubbing.",
+		}, []string{"collection", "type"})
+
+	VolumeServerReadRepairCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "read_repair_needles",
+			Help:      "Counter of needles found corrupted or missing by the reads, by the result of repairing them from the replicas.",
+		}, []string{"collection", "reason", "result"})
+
+	VolumeServerReplicaStreamCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "replica_stream_needles",
+			Help:      "Counter of needles tailed from the volume replicas, applied or skipped as already replicated.",
+		}, []string{"collection", "type"})
+
+	VolumeServerScrubBytesCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "scrub_bytes",
+			Help:      "Counter of bytes read by the background scrubbing.",
+		})
+
 	VolumeServerVacuumingHistogram = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: Namespace,
@@ -170,6 +243,64 @@ var (
 			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 24),
 		}, []string{"type"})
 
+	VolumeServerNeedleHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "needle_seconds",
+			Help:      "Bucketed histogram of the needle read, write and delete time by collection and disk.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 24),
+		}, []string{"collection", "disk", "type"})
+
+	VolumeServerNeedleErrorCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "needle_errors",
+			Help:      "Counter of the failed needle reads, writes and deletes by collection and disk, not counting the missing needles.",
+		}, []string{"collection", "disk", "type"})
+
+	VolumeServerNeedleQueueGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "needle_queue_depth",
+			Help:      "Number of the needle reads, writes and deletes waiting or in progress by collection and disk.",
+		}, []string{"collection", "disk", "type"})
+
+	VolumeServerGroupCommitHistogram = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "group_commit_needles",
+			Help:      "Bucketed histogram of the needle writes and deletes sharing one fsync.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 8),
+		})
+
+	VolumeServerDiskReadCacheCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "disk_read_cache",
+			Help:      "Counter of the hard drive volume reads from the read cache device, by hit, miss, or skipped as a sequential scan.",
+		}, []string{"type"})
+
+	VolumeServerInPlaceOverwriteCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "in_place_overwrite",
+			Help:      "Counter of the needles overwritten in place, instead of appended.",
+		}, []string{"collection"})
+
+	VolumeServerEcReadCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "ec_read",
+			Help:      "Counter of the ec shard interval reads, by local, remote, recovered from the other shards, or hedged by recovering a slow remote shard.",
+		}, []string{"type"})
+
 	VolumeServerVolumeCounter = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: Namespace,
@@ -244,13 +375,18 @@ func init() {
 	Gather.MustRegister(MasterReceivedHeartbeatCounter)
 	Gather.MustRegister(MasterLeaderChangeCounter)
 	Gather.MustRegister(MasterReplicaPlacementMismatch)
+	Gather.MustRegister(MasterAdmissionCounter)
 
 	Gather.MustRegister(FilerRequestCounter)
 	Gather.MustRegister(FilerRequestHistogram)
 	Gather.MustRegister(FilerStoreCounter)
 	Gather.MustRegister(FilerStoreHistogram)
+	Gather.MustRegister(FilerMetaCacheCounter)
 	Gather.MustRegister(FilerSyncOffsetGauge)
+	Gather.MustRegister(FilerSyncConflictCounter)
 	Gather.MustRegister(FilerServerLastSendTsOfSubscribeGauge)
+	Gather.MustRegister(FilerUsageBytesGauge)
+	Gather.MustRegister(FilerUsageFilesGauge)
 	Gather.MustRegister(collectors.NewGoCollector())
 	Gather.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 
@@ -259,6 +395,17 @@ func init() {
 	Gather.MustRegister(VolumeServerVacuumingCompactCounter)
 	Gather.MustRegister(VolumeServerVacuumingCommitCounter)
 	Gather.MustRegister(VolumeServerVacuumingHistogram)
+	Gather.MustRegister(VolumeServerScrubCounter)
+	Gather.MustRegister(VolumeServerScrubBytesCounter)
+	Gather.MustRegister(VolumeServerReadRepairCounter)
+	Gather.MustRegister(VolumeServerReplicaStreamCounter)
+	Gather.MustRegister(VolumeServerNeedleHistogram)
+	Gather.MustRegister(VolumeServerNeedleErrorCounter)
+	Gather.MustRegister(VolumeServerNeedleQueueGauge)
+	Gather.MustRegister(VolumeServerGroupCommitHistogram)
+	Gather.MustRegister(VolumeServerDiskReadCacheCounter)
+	Gather.MustRegister(VolumeServerInPlaceOverwriteCounter)
+	Gather.MustRegister(VolumeServerEcReadCounter)
 	Gather.MustRegister(VolumeServerVolumeCounter)
 	Gather.MustRegister(VolumeServerMaxVolumeCounter)
 	Gather.MustRegister(VolumeServerReadOnlyVolumeGauge)
@@ -322,4 +469,6 @@ func DeleteCollectionMetrics(collection string) {
 		VolumeServerReadOnlyVolumeGauge.DeleteLabelValues(collection, volume_type)
 	}
 	VolumeServerVolumeCounter.DeleteLabelValues(collection, "volume")
+	VolumeServerNeedleHistogram.DeletePartialMatch(prometheus.Labels{"collection": collection})
+	VolumeServerNeedleErrorCounter.DeletePartialMatch(prometheus.Labels{"collection": collection})
 }
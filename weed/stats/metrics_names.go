@@ -10,6 +10,9 @@ const (
 	ErrorSizeMismatch           = "errorSizeMismatch"
 	ErrorCRC                    = "errorCRC"
 	ErrorIndexOutOfRange        = "errorIndexOutOfRange"
+	ReadNeedle                  = "readNeedle"
+	WriteNeedle                 = "writeNeedle"
+	DeleteNeedle                = "deleteNeedle"
 
 	// master topology
 	ErrorWriteToLocalDisk = "errorWriteToLocalDisk"
@@ -26,6 +29,7 @@ const (
 
 	// filer handler
 	DirList                  = "dirList"
+	DirArchive               = "dirArchive"
 	ContentSaveToFiler       = "contentSaveToFiler"
 	AutoChunk                = "autoChunk"
 	ChunkProxy               = "chunkProxy"
@@ -34,10 +38,20 @@ const (
 	ChunkDoUploadRetry       = "chunkDoUploadRetry"
 	ChunkUploadRetry         = "chunkUploadRetry"
 	ChunkAssignRetry         = "chunkAssignRetry"
+	ChunkDedupe              = "chunkDedupe"
 	ErrorReadNotFound        = "read.notfound"
 	ErrorReadInternal        = "read.internal.error"
 	ErrorWriteEntry          = "write.entry.failed"
 	RepeatErrorUploadContent = "upload.content.repeat.failed"
 	ErrorReadCache           = "read.cache.failed"
 	ErrorReadStream          = "read.stream.failed"
+
+	// filer client limits
+	ErrorTooManyRequests           = "request.rate.limited"
+	ErrorTooManyConcurrentRequests = "request.concurrency.limited"
+
+	// master admission control
+	AdmissionClientLimited = "client.limited"
+	AdmissionQueueFull     = "queue.full"
+	AdmissionQueueTimeout  = "queue.timeout"
 )
@@ -43,6 +43,9 @@ func (df *DiskFile) ReadAt(p []byte, off int64) (n int, err error) {
 	if df.File == nil {
 		return 0, os.ErrClosed
 	}
+	if diskFileReader != nil {
+		return diskFileReader.ReadAt(df.File, p, off)
+	}
 	return df.File.ReadAt(p, off)
 }
 
@@ -0,0 +1,127 @@
+package backend
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// diskReadCache is set when the reads of the volumes on the hard drives are cached on a faster device
+var diskReadCache *ReadCache
+
+// sequentialScanReads is the number of back to back reads taken as a sequential scan,
+// e.g. scrubbing, vacuuming or tailing the volume, whose reads are not admitted to the cache
+const sequentialScanReads = 4
+
+// EnableDiskReadCache keeps the recently read needles of the volumes on the hard drives
+// in the directory on a faster device, evicting the least recently read ones when the cached bytes exceed the size limit.
+func EnableDiskReadCache(dir string, sizeLimit int64) error {
+	c, err := NewReadCache(dir, sizeLimit)
+	if err != nil {
+		return err
+	}
+	diskReadCache = c
+	return nil
+}
+
+func HasDiskReadCache() bool {
+	return diskReadCache != nil
+}
+
+// MaybeCacheDiskFile wraps the local data file if the disk read cache is enabled.
+// The cached ranges are keyed by the loaded data file, so they are not reused once the volume is reloaded,
+// e.g. after the compaction replaced the data file, or the volume is deleted and created again.
+func MaybeCacheDiskFile(file BackendStorageFile) BackendStorageFile {
+	diskFile, ok := file.(*DiskFile)
+	if diskReadCache == nil || !ok {
+		return file
+	}
+	return &DiskCachedFile{
+		BackendStorageFile: diskFile,
+		cache:              diskReadCache,
+		key:                util.Md5String([]byte(diskFile.Name() + "/" + uuid.New().String())),
+		lastReadEnd:        -1,
+	}
+}
+
+// UnwrapDiskFile returns the local data file, read through the disk read cache or not
+func UnwrapDiskFile(file BackendStorageFile) (*DiskFile, bool) {
+	if cachedFile, ok := file.(*DiskCachedFile); ok {
+		file = cachedFile.BackendStorageFile
+	}
+	diskFile, ok := file.(*DiskFile)
+	return diskFile, ok
+}
+
+var _ BackendStorageFile = &DiskCachedFile{}
+
+// DiskCachedFile reads the ranges of the local data file from the cache device, and caches the missed ranges
+// unless they are read by a sequential scan
+type DiskCachedFile struct {
+	BackendStorageFile
+	cache *ReadCache
+	key   string
+	// bumped on truncation, since the truncated range is written again, and on overwrites
+	generation uint32
+
+	scanLock        sync.Mutex
+	lastReadEnd     int64
+	sequentialReads int
+}
+
+func (f *DiskCachedFile) cacheKey() string {
+	return fmt.Sprintf("%s_%d", f.key, atomic.LoadUint32(&f.generation))
+}
+
+func (f *DiskCachedFile) ReadAt(p []byte, off int64) (n int, err error) {
+	key := f.cacheKey()
+	isAdmitted := f.admit(off, len(p))
+	if f.cache.ReadAt(key, p, off) {
+		stats.VolumeServerDiskReadCacheCounter.WithLabelValues("hit").Inc()
+		return len(p), nil
+	}
+	n, err = f.BackendStorageFile.ReadAt(p, off)
+	if !isAdmitted {
+		stats.VolumeServerDiskReadCacheCounter.WithLabelValues("skipped").Inc()
+		return
+	}
+	stats.VolumeServerDiskReadCacheCounter.WithLabelValues("miss").Inc()
+	if err == nil && n == len(p) {
+		f.cache.Set(key, p, off)
+	}
+	return
+}
+
+// admit is false for the reads right after the previous ones, once they look like a sequential scan
+func (f *DiskCachedFile) admit(off int64, size int) bool {
+	f.scanLock.Lock()
+	defer f.scanLock.Unlock()
+	if off == f.lastReadEnd {
+		f.sequentialReads++
+	} else {
+		f.sequentialReads = 0
+	}
+	f.lastReadEnd = off + int64(size)
+	return f.sequentialReads < sequentialScanReads
+}
+
+// WriteAt drops the cached ranges if the needles are overwritten in place, since the ranges are not indexed by their offsets.
+// The appended needles are not cached before they are written.
+func (f *DiskCachedFile) WriteAt(p []byte, off int64) (n int, err error) {
+	size, _, statErr := f.BackendStorageFile.GetStat()
+	n, err = f.BackendStorageFile.WriteAt(p, off)
+	if statErr == nil && off < size {
+		atomic.AddUint32(&f.generation, 1)
+	}
+	return
+}
+
+func (f *DiskCachedFile) Truncate(off int64) error {
+	atomic.AddUint32(&f.generation, 1)
+	return f.BackendStorageFile.Truncate(off)
+}
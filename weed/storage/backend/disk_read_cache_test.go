@@ -0,0 +1,92 @@
+package backend
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type truncatingFile struct {
+	countingFile
+}
+
+func (f *truncatingFile) Truncate(off int64) error {
+	f.data = f.data[:off]
+	return nil
+}
+
+func TestDiskCachedFileReadAt(t *testing.T) {
+	cache, err := NewReadCache(t.TempDir(), 4096)
+	if err != nil {
+		t.Fatalf("new read cache: %v", err)
+	}
+	disk := &truncatingFile{countingFile{data: bytes.Repeat([]byte("0123456789"), 100)}}
+	f := &DiskCachedFile{BackendStorageFile: disk, cache: cache, key: "disk", lastReadEnd: -1}
+
+	read := func(off int64, size int) {
+		p := make([]byte, size)
+		if n, err := f.ReadAt(p, off); err != nil || n != size {
+			t.Fatalf("read at %d: %d %v", off, n, err)
+		}
+		if !bytes.Equal(p, disk.data[off:off+int64(size)]) {
+			t.Fatalf("unexpected data at %d: %s", off, p)
+		}
+	}
+
+	// the random reads are cached
+	read(500, 10)
+	read(100, 10)
+	read(500, 10)
+	read(100, 10)
+	if disk.reads != 2 {
+		t.Fatalf("disk reads %d, expected 2", disk.reads)
+	}
+
+	// the sequential scan is not admitted after the first reads
+	disk.reads = 0
+	for off := int64(0); off < 100; off += 10 {
+		read(off, 10)
+	}
+	if count, _ := cache.Size(); count != 2+sequentialScanReads {
+		t.Fatalf("cached %d ranges, expected %d", count, 2+sequentialScanReads)
+	}
+
+	// the ranges cached before the truncation are not read again
+	disk.reads = 0
+	if err := f.Truncate(600); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	disk.data = append(disk.data, bytes.Repeat([]byte("abcdefghij"), 40)...)
+	read(500, 10)
+	if disk.reads != 1 {
+		t.Fatalf("disk reads %d after truncation, expected 1", disk.reads)
+	}
+}
+
+func TestUnwrapDiskFile(t *testing.T) {
+	dataFile, err := os.Create(filepath.Join(t.TempDir(), "1.dat"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	diskFile := NewDiskFile(dataFile)
+	defer diskFile.Close()
+
+	if f := MaybeCacheDiskFile(diskFile); f != diskFile {
+		t.Fatalf("disk file wrapped without the read cache")
+	}
+	diskReadCache, err = NewReadCache(t.TempDir(), 4096)
+	if err != nil {
+		t.Fatalf("new read cache: %v", err)
+	}
+	defer func() {
+		diskReadCache = nil
+	}()
+	f := MaybeCacheDiskFile(diskFile)
+	if _, ok := f.(*DiskCachedFile); !ok {
+		t.Fatalf("disk file not wrapped with the read cache")
+	}
+	if unwrapped, ok := UnwrapDiskFile(f); !ok || unwrapped != diskFile {
+		t.Fatalf("unwrapped %v", unwrapped)
+	}
+}
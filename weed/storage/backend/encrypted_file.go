@@ -0,0 +1,67 @@
+package backend
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+)
+
+// EncryptedFilePlainPrefixSize is the super block header kept in plain text,
+// so the compaction revision can be read before decrypting the rest of the file.
+const EncryptedFilePlainPrefixSize = 8
+
+var _ BackendStorageFile = &EncryptedFile{}
+
+// EncryptedFile encrypts the data with AES-CTR. The counter is the nonce followed by the block index of the offset,
+// so any range of the file can be read or written in place.
+type EncryptedFile struct {
+	BackendStorageFile
+	block cipher.Block
+	nonce uint64
+}
+
+// NewEncryptedFile wraps the file with the data key. The nonce must be different
+// whenever the same offsets of the file are rewritten with different data, e.g., after compaction.
+func NewEncryptedFile(file BackendStorageFile, dataKey []byte, nonce uint64) (*EncryptedFile, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedFile{
+		BackendStorageFile: file,
+		block:              block,
+		nonce:              nonce,
+	}, nil
+}
+
+func (f *EncryptedFile) ReadAt(p []byte, off int64) (n int, err error) {
+	n, err = f.BackendStorageFile.ReadAt(p, off)
+	f.xorKeyStream(p[:n], off)
+	return
+}
+
+func (f *EncryptedFile) WriteAt(p []byte, off int64) (n int, err error) {
+	encrypted := make([]byte, len(p))
+	copy(encrypted, p)
+	f.xorKeyStream(encrypted, off)
+	return f.BackendStorageFile.WriteAt(encrypted, off)
+}
+
+func (f *EncryptedFile) xorKeyStream(p []byte, off int64) {
+	if off < EncryptedFilePlainPrefixSize {
+		skip := EncryptedFilePlainPrefixSize - off
+		if int64(len(p)) <= skip {
+			return
+		}
+		p, off = p[skip:], EncryptedFilePlainPrefixSize
+	}
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[0:8], f.nonce)
+	binary.BigEndian.PutUint64(iv[8:16], uint64(off/aes.BlockSize))
+	stream := cipher.NewCTR(f.block, iv)
+	if skip := off % aes.BlockSize; skip > 0 {
+		discard := make([]byte, skip)
+		stream.XORKeyStream(discard, discard)
+	}
+	stream.XORKeyStream(p, p)
+}
@@ -0,0 +1,28 @@
+package backend
+
+import (
+	"os"
+)
+
+const (
+	// ioUringEntries is the number of the reads submitted together
+	ioUringEntries = 128
+	// ioUringBufferSize is the size of each registered buffer, and the larger reads use pread
+	ioUringBufferSize = 32 * 1024
+)
+
+// diskFileReader is set when the reads of the disk files go through io_uring
+var diskFileReader interface {
+	ReadAt(f *os.File, p []byte, off int64) (n int, err error)
+}
+
+// EnableIoUring reads the disk files with io_uring on linux, batching the small reads from all volumes
+// into the registered buffers. The reads fall back to pread if io_uring is not available.
+func EnableIoUring() error {
+	reader, err := newIoUringReader(ioUringEntries, ioUringBufferSize)
+	if err != nil {
+		return err
+	}
+	diskFileReader = reader
+	return nil
+}
@@ -0,0 +1,312 @@
+//go:build linux
+// +build linux
+
+package backend
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+const (
+	ioringOpReadFixed = 4
+
+	ioringEnterGetEvents = 1 << 0
+
+	ioringRegisterBuffers = 0
+
+	ioringOffSqRing = 0
+	ioringOffCqRing = 0x8000000
+	ioringOffSqes   = 0x10000000
+
+	ioUringSqeSize = 64
+	ioUringCqeSize = 16
+)
+
+// ioUringParams is struct io_uring_params
+type ioUringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCpu  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFd         uint32
+	resv         [3]uint32
+	sqOff        struct {
+		head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+		userAddr                                                        uint64
+	}
+	cqOff struct {
+		head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+		userAddr                                                        uint64
+	}
+}
+
+// ioUringSqe is struct io_uring_sqe
+type ioUringSqe struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+// ioUringCqe is struct io_uring_cqe
+type ioUringCqe struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+type ioUringRequest struct {
+	fd   int32
+	p    []byte
+	off  int64
+	n    int
+	err  error
+	done chan struct{}
+}
+
+// ioUringReader owns one ring. A single goroutine submits the pending reads together,
+// each into its own registered buffer, and waits for all of them to complete.
+type ioUringReader struct {
+	fd         int
+	entries    uint32
+	bufferSize int
+	buffers    []byte
+	requests   chan *ioUringRequest
+	broken     atomic.Bool
+
+	sqRing, cqRing, sqesMem []byte
+	sqTail, sqMask          *uint32
+	sqArray                 []uint32
+	sqes                    []ioUringSqe
+	cqHead, cqTail, cqMask  *uint32
+	cqes                    []ioUringCqe
+
+	requestPool sync.Pool
+}
+
+func newIoUringReader(entries uint32, bufferSize int) (*ioUringReader, error) {
+	params := &ioUringParams{}
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(params)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring_setup: %v", errno)
+	}
+	r := &ioUringReader{
+		fd:         int(fd),
+		entries:    params.sqEntries,
+		bufferSize: bufferSize,
+		requests:   make(chan *ioUringRequest, params.sqEntries),
+	}
+	r.requestPool.New = func() any {
+		return &ioUringRequest{done: make(chan struct{}, 1)}
+	}
+	if err := r.mmapRings(params); err != nil {
+		r.close()
+		return nil, err
+	}
+	if err := r.registerBuffers(); err != nil {
+		r.close()
+		return nil, err
+	}
+	go r.loop()
+	glog.V(0).Infof("read with io_uring, %d entries of %d bytes registered buffers", r.entries, r.bufferSize)
+	return r, nil
+}
+
+func (r *ioUringReader) mmapRings(params *ioUringParams) (err error) {
+	r.sqRing, err = unix.Mmap(r.fd, ioringOffSqRing, int(params.sqOff.array+params.sqEntries*4),
+		unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		return fmt.Errorf("mmap sq ring: %v", err)
+	}
+	r.cqRing, err = unix.Mmap(r.fd, ioringOffCqRing, int(params.cqOff.cqes+params.cqEntries*ioUringCqeSize),
+		unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		return fmt.Errorf("mmap cq ring: %v", err)
+	}
+	r.sqesMem, err = unix.Mmap(r.fd, ioringOffSqes, int(params.sqEntries*ioUringSqeSize),
+		unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		return fmt.Errorf("mmap sqes: %v", err)
+	}
+
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqRing[params.sqOff.tail]))
+	r.sqMask = (*uint32)(unsafe.Pointer(&r.sqRing[params.sqOff.ringMask]))
+	r.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&r.sqRing[params.sqOff.array])), params.sqEntries)
+	r.sqes = unsafe.Slice((*ioUringSqe)(unsafe.Pointer(&r.sqesMem[0])), params.sqEntries)
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqRing[params.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqRing[params.cqOff.tail]))
+	r.cqMask = (*uint32)(unsafe.Pointer(&r.cqRing[params.cqOff.ringMask]))
+	r.cqes = unsafe.Slice((*ioUringCqe)(unsafe.Pointer(&r.cqRing[params.cqOff.cqes])), params.cqEntries)
+	return nil
+}
+
+// registerBuffers pins one buffer for each entry, so the kernel does not map the pages for every read
+func (r *ioUringReader) registerBuffers() error {
+	r.buffers = make([]byte, int(r.entries)*r.bufferSize)
+	iovecs := make([]unix.Iovec, r.entries)
+	for i := range iovecs {
+		iovecs[i].Base = &r.buffers[i*r.bufferSize]
+		iovecs[i].SetLen(r.bufferSize)
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_IO_URING_REGISTER, uintptr(r.fd), ioringRegisterBuffers,
+		uintptr(unsafe.Pointer(&iovecs[0])), uintptr(len(iovecs)), 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("io_uring_register %d buffers: %v", r.entries, errno)
+	}
+	return nil
+}
+
+func (r *ioUringReader) close() {
+	for _, mem := range [][]byte{r.sqRing, r.cqRing, r.sqesMem} {
+		if mem != nil {
+			unix.Munmap(mem)
+		}
+	}
+	syscall.Close(r.fd)
+}
+
+func (r *ioUringReader) ReadAt(f *os.File, p []byte, off int64) (n int, err error) {
+	if len(p) == 0 || len(p) > r.bufferSize || r.broken.Load() {
+		return f.ReadAt(p, off)
+	}
+	rawConn, err := f.SyscallConn()
+	if err != nil {
+		return f.ReadAt(p, off)
+	}
+	// the file descriptor is kept open until the read completes
+	controlErr := rawConn.Read(func(fd uintptr) bool {
+		req := r.requestPool.Get().(*ioUringRequest)
+		req.fd, req.p, req.off = int32(fd), p, off
+		r.requests <- req
+		<-req.done
+		n, err = req.n, req.err
+		req.p = nil
+		r.requestPool.Put(req)
+		return true
+	})
+	if controlErr != nil {
+		return f.ReadAt(p, off)
+	}
+	if err == errIoUringBroken {
+		return f.ReadAt(p, off)
+	}
+	if err == nil && n < len(p) {
+		// a short read, near the end of the file
+		var m int
+		m, err = f.ReadAt(p[n:], off+int64(n))
+		n += m
+	}
+	return n, err
+}
+
+var errIoUringBroken = fmt.Errorf("io_uring is broken")
+
+func (r *ioUringReader) loop() {
+	batch := make([]*ioUringRequest, 0, r.entries)
+	for req := range r.requests {
+		batch = append(batch[:0], req)
+	collecting:
+		for len(batch) < int(r.entries) {
+			select {
+			case req = <-r.requests:
+				batch = append(batch, req)
+			default:
+				break collecting
+			}
+		}
+		if r.broken.Load() {
+			r.finish(batch, errIoUringBroken)
+			continue
+		}
+		if err := r.submitAndWait(batch); err != nil {
+			glog.Errorf("io_uring read: %v, falling back to pread", err)
+			// the submitted reads may still complete into the registered buffers, which are never reused
+			r.broken.Store(true)
+			r.finish(batch, errIoUringBroken)
+		}
+	}
+}
+
+func (r *ioUringReader) finish(batch []*ioUringRequest, err error) {
+	for _, req := range batch {
+		if req != nil {
+			req.n, req.err = 0, err
+			req.done <- struct{}{}
+		}
+	}
+}
+
+// submitAndWait reads each request into its registered buffer, and copies the data out on completion
+func (r *ioUringReader) submitAndWait(batch []*ioUringRequest) error {
+	tail := atomic.LoadUint32(r.sqTail)
+	mask := *r.sqMask
+	for i, req := range batch {
+		index := (tail + uint32(i)) & mask
+		r.sqes[index] = ioUringSqe{
+			opcode:   ioringOpReadFixed,
+			fd:       req.fd,
+			off:      uint64(req.off),
+			addr:     uint64(uintptr(unsafe.Pointer(&r.buffers[i*r.bufferSize]))),
+			len:      uint32(len(req.p)),
+			userData: uint64(i),
+			bufIndex: uint16(i),
+		}
+		r.sqArray[index] = index
+	}
+	atomic.StoreUint32(r.sqTail, tail+uint32(len(batch)))
+
+	submitted, completed := 0, 0
+	for completed < len(batch) {
+		entered, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd),
+			uintptr(len(batch)-submitted), 1, ioringEnterGetEvents, 0, 0)
+		if errno == syscall.EINTR || errno == syscall.EAGAIN {
+			continue
+		}
+		if errno != 0 {
+			return fmt.Errorf("io_uring_enter: %v", errno)
+		}
+		submitted += int(entered)
+
+		head := atomic.LoadUint32(r.cqHead)
+		for ; head != atomic.LoadUint32(r.cqTail); head++ {
+			cqe := r.cqes[head&*r.cqMask]
+			i := int(cqe.userData)
+			req := batch[i]
+			if cqe.res < 0 {
+				req.n, req.err = 0, syscall.Errno(-cqe.res)
+			} else if cqe.res == 0 {
+				req.n, req.err = 0, io.EOF
+			} else {
+				req.n = copy(req.p, r.buffers[i*r.bufferSize:i*r.bufferSize+int(cqe.res)])
+				req.err = nil
+			}
+			req.done <- struct{}{}
+			batch[i] = nil
+			completed++
+		}
+		atomic.StoreUint32(r.cqHead, head)
+	}
+	return nil
+}
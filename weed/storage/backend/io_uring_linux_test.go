@@ -0,0 +1,79 @@
+//go:build linux
+// +build linux
+
+package backend
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestIoUringReadAt(t *testing.T) {
+	if size := unsafe.Sizeof(ioUringParams{}); size != 120 {
+		t.Fatalf("io_uring_params size %d", size)
+	}
+	if size := unsafe.Sizeof(ioUringSqe{}); size != ioUringSqeSize {
+		t.Fatalf("io_uring_sqe size %d", size)
+	}
+	if size := unsafe.Sizeof(ioUringCqe{}); size != ioUringCqeSize {
+		t.Fatalf("io_uring_cqe size %d", size)
+	}
+
+	reader, err := newIoUringReader(8, 4096)
+	if err != nil {
+		t.Skipf("io_uring is not available: %v", err)
+	}
+
+	data := make([]byte, 1024*1024+100)
+	rand.Read(data)
+	fileName := filepath.Join(t.TempDir(), "1.dat")
+	if err := os.WriteFile(fileName, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 32; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				// small reads go through io_uring, the larger ones fall back to pread
+				size := rand.Intn(6000) + 1
+				off := rand.Int63n(int64(len(data)))
+				p := make([]byte, size)
+				n, err := reader.ReadAt(f, p, off)
+				expected := data[off:]
+				if len(expected) > size {
+					expected = expected[:size]
+				}
+				if n != len(expected) || !bytes.Equal(p[:n], expected) {
+					t.Errorf("read %d bytes at %d: got %d bytes, %v", size, off, n, err)
+					return
+				}
+				if n < size && err != io.EOF {
+					t.Errorf("short read %d bytes at %d: %v", n, off, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := reader.ReadAt(f, make([]byte, 10), int64(len(data))); err != io.EOF {
+		t.Errorf("read after the end: %v", err)
+	}
+	if reader.broken.Load() {
+		t.Errorf("io_uring is broken")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package backend
+
+import (
+	"fmt"
+	"os"
+)
+
+type ioUringReader struct {
+}
+
+func newIoUringReader(entries uint32, bufferSize int) (*ioUringReader, error) {
+	return nil, fmt.Errorf("io_uring is only available on linux")
+}
+
+func (r *ioUringReader) ReadAt(f *os.File, p []byte, off int64) (n int, err error) {
+	return f.ReadAt(p, off)
+}
@@ -0,0 +1,217 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+// ObjectBackendStorage stores the segments of the volume data files at the given keys
+type ObjectBackendStorage interface {
+	BackendStorage
+	PutObject(key string, reader io.Reader) error
+}
+
+// The data file of a volume on the object store is split into segments of the same size.
+// The writes are buffered in the local file, at the same offsets, and each full segment is uploaded
+// as one object. Once its uploaded size is saved, the segment is removed from the local file by punching a hole.
+
+var errPunchHoleNotSupported = errors.New("punching holes is not supported")
+
+func ObjectSegmentKey(key string, segment int64) string {
+	return fmt.Sprintf("%s/%08d", key, segment)
+}
+
+// objectStoreState is shared by the data file and its views
+type objectStoreState struct {
+	lock         sync.RWMutex
+	uploadedSize int64
+	// the end of the segment being uploaded, or 0
+	uploadingEnd int64
+	// the local file is truncated into the segment being uploaded
+	isUploadTruncated bool
+	uploadWait        sync.WaitGroup
+}
+
+type ObjectStoreFile struct {
+	BackendStorageFile
+	storage     ObjectBackendStorage
+	key         string
+	segmentSize int64
+	state       *objectStoreState
+	// saves the uploaded size, and is nil for the views not uploading
+	onUploaded func(uploadedSize int64) error
+}
+
+func NewObjectStoreFile(local BackendStorageFile, storage ObjectBackendStorage, key string, segmentSize, uploadedSize int64, onUploaded func(uploadedSize int64) error) *ObjectStoreFile {
+	return &ObjectStoreFile{
+		BackendStorageFile: local,
+		storage:            storage,
+		key:                key,
+		segmentSize:        segmentSize,
+		state:              &objectStoreState{uploadedSize: uploadedSize},
+		onUploaded:         onUploaded,
+	}
+}
+
+// NewView reads the same data with another local file, without uploading
+func (f *ObjectStoreFile) NewView(local BackendStorageFile) *ObjectStoreFile {
+	return &ObjectStoreFile{
+		BackendStorageFile: local,
+		storage:            f.storage,
+		key:                f.key,
+		segmentSize:        f.segmentSize,
+		state:              f.state,
+	}
+}
+
+func (f *ObjectStoreFile) UploadedSize() int64 {
+	f.state.lock.RLock()
+	defer f.state.lock.RUnlock()
+	return f.state.uploadedSize
+}
+
+func (f *ObjectStoreFile) ReadAt(p []byte, off int64) (n int, err error) {
+	f.state.lock.RLock()
+	defer f.state.lock.RUnlock()
+
+	if off < f.state.uploadedSize {
+		uploaded := len(p)
+		if off+int64(uploaded) > f.state.uploadedSize {
+			uploaded = int(f.state.uploadedSize - off)
+		}
+		if n, err = f.readSegments(p[:uploaded], off); err != nil || n == len(p) {
+			return
+		}
+		p, off = p[n:], off+int64(n)
+	}
+	m, err := f.BackendStorageFile.ReadAt(p, off)
+	return n + m, err
+}
+
+func (f *ObjectStoreFile) readSegments(p []byte, off int64) (n int, err error) {
+	for n < len(p) {
+		segment, segmentOffset := off/f.segmentSize, off%f.segmentSize
+		size := len(p) - n
+		if int64(size) > f.segmentSize-segmentOffset {
+			size = int(f.segmentSize - segmentOffset)
+		}
+		segmentFile := f.storage.NewStorageFile(ObjectSegmentKey(f.key, segment), nil)
+		count, readErr := segmentFile.ReadAt(p[n:n+size], segmentOffset)
+		n += count
+		off += int64(count)
+		if readErr != nil {
+			return n, readErr
+		}
+		if count < size {
+			return n, io.ErrUnexpectedEOF
+		}
+	}
+	return n, nil
+}
+
+func (f *ObjectStoreFile) WriteAt(p []byte, off int64) (n int, err error) {
+	if uploadedSize := f.UploadedSize(); off < uploadedSize {
+		return 0, fmt.Errorf("write %s at %d, before the uploaded size %d", f.key, off, uploadedSize)
+	}
+	if n, err = f.BackendStorageFile.WriteAt(p, off); err == nil {
+		f.maybeUpload()
+	}
+	return
+}
+
+func (f *ObjectStoreFile) Truncate(off int64) error {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+	if off < f.state.uploadedSize {
+		return fmt.Errorf("truncate %s to %d, before the uploaded size %d", f.key, off, f.state.uploadedSize)
+	}
+	if off < f.state.uploadingEnd {
+		f.state.isUploadTruncated = true
+	}
+	return f.BackendStorageFile.Truncate(off)
+}
+
+func (f *ObjectStoreFile) Close() error {
+	f.state.uploadWait.Wait()
+	return f.BackendStorageFile.Close()
+}
+
+// MaybeUpload uploads the next segment in the background, if it is full
+func (f *ObjectStoreFile) MaybeUpload() {
+	f.maybeUpload()
+}
+
+func (f *ObjectStoreFile) maybeUpload() {
+	if f.onUploaded == nil {
+		return
+	}
+	localSize, _, err := f.BackendStorageFile.GetStat()
+	if err != nil {
+		return
+	}
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+	if f.state.uploadingEnd != 0 || localSize < f.state.uploadedSize+f.segmentSize {
+		return
+	}
+	f.state.uploadingEnd = f.state.uploadedSize + f.segmentSize
+	f.state.isUploadTruncated = false
+	f.state.uploadWait.Add(1)
+	go f.upload(f.state.uploadedSize)
+}
+
+func (f *ObjectStoreFile) upload(offset int64) {
+	defer f.state.uploadWait.Done()
+
+	segment := offset / f.segmentSize
+	err := f.storage.PutObject(ObjectSegmentKey(f.key, segment), io.NewSectionReader(f.BackendStorageFile, offset, f.segmentSize))
+
+	f.state.lock.Lock()
+	isUploaded := err == nil && !f.state.isUploadTruncated
+	if isUploaded {
+		f.state.uploadedSize = offset + f.segmentSize
+	}
+	f.state.uploadingEnd = 0
+	f.state.lock.Unlock()
+
+	if err != nil {
+		glog.Warningf("upload segment %d of %s: %v", segment, f.key, err)
+		return
+	}
+	if !isUploaded {
+		// uploaded again after the truncated data is written
+		return
+	}
+	// keep the local data until the uploaded size is saved
+	if err = f.onUploaded(offset + f.segmentSize); err != nil {
+		glog.Warningf("save uploaded size of %s: %v", f.key, err)
+		return
+	}
+	if diskFile, ok := f.BackendStorageFile.(*DiskFile); ok {
+		f.state.lock.Lock()
+		err = punchHole(diskFile.File, offset, f.segmentSize)
+		f.state.lock.Unlock()
+		if err != nil && err != errPunchHoleNotSupported {
+			glog.Warningf("remove uploaded segment %d of %s from %s: %v", segment, f.key, diskFile.Name(), err)
+		}
+	}
+	f.maybeUpload()
+}
+
+// DeleteSegments deletes the uploaded segments
+func (f *ObjectStoreFile) DeleteSegments() {
+	uploadedSize := f.UploadedSize()
+	DeleteObjectSegments(f.storage, f.key, uploadedSize/f.segmentSize)
+}
+
+func DeleteObjectSegments(storage ObjectBackendStorage, key string, segmentCount int64) {
+	for segment := int64(0); segment < segmentCount; segment++ {
+		if err := storage.DeleteFile(ObjectSegmentKey(key, segment)); err != nil {
+			glog.Warningf("delete segment %d of %s: %v", segment, key, err)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux
+// +build linux
+
+package backend
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func punchHole(f *os.File, offset, length int64) error {
+	err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, offset, length)
+	if err == unix.EOPNOTSUPP {
+		return errPunchHoleNotSupported
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package backend
+
+import (
+	"os"
+)
+
+// the uploaded segments are kept in the local file
+func punchHole(f *os.File, offset, length int64) error {
+	return errPunchHoleNotSupported
+}
@@ -0,0 +1,109 @@
+package backend
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+)
+
+type memoryObjectStorage struct {
+	BackendStorage
+	lock    sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *memoryObjectStorage) PutObject(key string, reader io.Reader) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.objects[key] = data
+	return nil
+}
+
+func (s *memoryObjectStorage) DeleteFile(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *memoryObjectStorage) NewStorageFile(key string, tierInfo *volume_server_pb.VolumeInfo) BackendStorageFile {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return &countingFile{data: s.objects[key]}
+}
+
+func TestObjectStoreFile(t *testing.T) {
+	dataFile, err := os.Create(filepath.Join(t.TempDir(), "1.dat"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	storage := &memoryObjectStorage{objects: make(map[string][]byte)}
+	var savedSize int64
+	var savedLock sync.Mutex
+	f := NewObjectStoreFile(NewDiskFile(dataFile), storage, "1", 4096, 0, func(uploadedSize int64) error {
+		savedLock.Lock()
+		defer savedLock.Unlock()
+		savedSize = uploadedSize
+		return nil
+	})
+	defer f.Close()
+
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	for off := 0; off < len(data); off += 1000 {
+		if _, err := f.WriteAt(data[off:off+1000], int64(off)); err != nil {
+			t.Fatalf("write at %d: %v", off, err)
+		}
+	}
+	for deadline := time.Now().Add(5 * time.Second); f.UploadedSize() < 8192; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("uploaded size %d, expected 8192", f.UploadedSize())
+		}
+	}
+	f.state.uploadWait.Wait()
+	savedLock.Lock()
+	if savedSize != 8192 {
+		t.Fatalf("saved uploaded size %d, expected 8192", savedSize)
+	}
+	savedLock.Unlock()
+	if len(storage.objects) != 2 || !bytes.Equal(storage.objects["1/00000001"], data[4096:8192]) {
+		t.Fatalf("uploaded objects %d", len(storage.objects))
+	}
+
+	// read across the uploaded segments and the local file
+	p := make([]byte, 9000)
+	if n, err := f.ReadAt(p, 500); err != nil || n != len(p) || !bytes.Equal(p, data[500:9500]) {
+		t.Fatalf("read %d: %v", n, err)
+	}
+	view := f.NewView(NewDiskFile(dataFile))
+	if n, err := view.ReadAt(p[:100], 8150); err != nil || n != 100 || !bytes.Equal(p[:100], data[8150:8250]) {
+		t.Fatalf("read view %d: %v", n, err)
+	}
+
+	if _, err := f.WriteAt(data[:10], 8000); err == nil {
+		t.Fatalf("written into the uploaded segment")
+	}
+	if err := f.Truncate(8000); err == nil {
+		t.Fatalf("truncated into the uploaded segment")
+	}
+	if err := f.Truncate(9000); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	f.DeleteSegments()
+	if len(storage.objects) != 0 {
+		t.Fatalf("%d objects left after deleted", len(storage.objects))
+	}
+}
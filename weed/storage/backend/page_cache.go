@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"os"
+)
+
+// The bulk transfers, e.g. the large needles streamed to the clients, or the volume files copied between the servers,
+// drop their pages from the OS page cache behind them, so they do not evict the hot needles read by the others.
+
+// pageCacheStreamSize is the size of the transfers taken as bulk ones, or 0 to keep their pages
+var pageCacheStreamSize int64
+
+// SetPageCacheStreamSize drops the pages of the reads and copies of at least the size from the OS page cache, 0 to keep them
+func SetPageCacheStreamSize(size int64) {
+	pageCacheStreamSize = size
+}
+
+// IsPageCacheStream is true if the transfer of the size drops its pages from the OS page cache
+func IsPageCacheStream(size int64) bool {
+	return pageCacheStreamSize > 0 && size >= pageCacheStreamSize
+}
+
+// DropReadPages drops the read range of the local data file from the OS page cache, and skips the files not on the local disk
+func DropReadPages(file BackendStorageFile, offset, length int64) {
+	if diskFile, ok := UnwrapDiskFile(file); ok {
+		DropFileReadPages(diskFile.File, offset, length)
+	}
+}
+
+// DropFileReadPages drops the read range of the file from the OS page cache
+func DropFileReadPages(f *os.File, offset, length int64) {
+	if f == nil || length <= 0 {
+		return
+	}
+	dropPages(f, offset, length)
+}
+
+// DropFileWrittenPages writes back the written range of the file, and drops it from the OS page cache,
+// since the dirty pages are not dropped
+func DropFileWrittenPages(f *os.File, offset, length int64) {
+	if f == nil || length <= 0 {
+		return
+	}
+	writeBackPages(f, offset, length)
+	dropPages(f, offset, length)
+}
@@ -0,0 +1,24 @@
+//go:build linux
+// +build linux
+
+package backend
+
+import (
+	"os"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"golang.org/x/sys/unix"
+)
+
+func dropPages(f *os.File, offset, length int64) {
+	if err := unix.Fadvise(int(f.Fd()), offset, length, unix.FADV_DONTNEED); err != nil {
+		glog.V(3).Infof("drop pages of %s at %d: %v", f.Name(), offset, err)
+	}
+}
+
+func writeBackPages(f *os.File, offset, length int64) {
+	flags := unix.SYNC_FILE_RANGE_WAIT_BEFORE | unix.SYNC_FILE_RANGE_WRITE | unix.SYNC_FILE_RANGE_WAIT_AFTER
+	if err := unix.SyncFileRange(int(f.Fd()), offset, length, flags); err != nil {
+		glog.V(3).Infof("write back pages of %s at %d: %v", f.Name(), offset, err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package backend
+
+import (
+	"os"
+)
+
+// the pages are left to the OS page cache
+func dropPages(f *os.File, offset, length int64) {
+}
+
+func writeBackPages(f *os.File, offset, length int64) {
+}
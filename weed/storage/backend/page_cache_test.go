@@ -0,0 +1,38 @@
+package backend
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDropPageCache(t *testing.T) {
+	defer SetPageCacheStreamSize(0)
+	if IsPageCacheStream(1 << 30) {
+		t.Fatalf("dropped pages by default")
+	}
+	SetPageCacheStreamSize(4096)
+	if IsPageCacheStream(4095) || !IsPageCacheStream(4096) {
+		t.Fatalf("stream size not applied")
+	}
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "1.dat"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	diskFile := NewDiskFile(f)
+	defer diskFile.Close()
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	if _, err = diskFile.WriteAt(data, 0); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	DropFileWrittenPages(f, 0, int64(len(data)))
+	DropReadPages(diskFile, 0, int64(len(data)))
+
+	// the dropped pages are read from the disk again
+	p := make([]byte, len(data))
+	if n, err := diskFile.ReadAt(p, 0); err != nil || n != len(p) || !bytes.Equal(p, data) {
+		t.Fatalf("read %d after dropped: %v", n, err)
+	}
+}
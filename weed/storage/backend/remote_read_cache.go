@@ -0,0 +1,219 @@
+package backend
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// remoteReadCache is set when the reads of the remote tier files are cached on local disk
+var remoteReadCache *ReadCache
+
+// EnableRemoteReadCache keeps the recently read needles of the volumes tiered to the remote storages
+// in the directory, evicting the least recently read ones when the cached bytes exceed the size limit.
+func EnableRemoteReadCache(dir string, sizeLimit int64) error {
+	c, err := NewReadCache(dir, sizeLimit)
+	if err != nil {
+		return err
+	}
+	remoteReadCache = c
+	return nil
+}
+
+// MaybeCacheRemoteFile wraps the remote tier file if the remote read cache is enabled.
+// The key identifies the remote file, and changes when the volume is uploaded again.
+func MaybeCacheRemoteFile(file BackendStorageFile, key string) BackendStorageFile {
+	if remoteReadCache == nil {
+		return file
+	}
+	return &CachedFile{
+		BackendStorageFile: file,
+		cache:              remoteReadCache,
+		key:                util.Md5String([]byte(key)),
+	}
+}
+
+var _ BackendStorageFile = &CachedFile{}
+
+// CachedFile reads the ranges of the remote file from the local cache, and caches the missed ranges
+type CachedFile struct {
+	BackendStorageFile
+	cache *ReadCache
+	key   string
+}
+
+func (f *CachedFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if f.cache.ReadAt(f.key, p, off) {
+		return len(p), nil
+	}
+	n, err = f.BackendStorageFile.ReadAt(p, off)
+	if err == nil && n == len(p) {
+		f.cache.Set(f.key, p, off)
+	}
+	return
+}
+
+// ReadCache is a size bounded LRU of the byte ranges, each stored as a file in the directory
+type ReadCache struct {
+	dir       string
+	sizeLimit int64
+
+	sync.Mutex
+	size    int64
+	lru     *list.List // the most recently read entry is at the front
+	entries map[string]*list.Element
+}
+
+type readCacheEntry struct {
+	name string
+	size int64
+}
+
+// NewReadCache loads the entries cached before the restart, with the least recently modified evicted first
+func NewReadCache(dir string, sizeLimit int64) (*ReadCache, error) {
+	if sizeLimit <= 0 {
+		return nil, fmt.Errorf("read cache size limit %d should be positive", sizeLimit)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create read cache dir %s: %v", dir, err)
+	}
+	c := &ReadCache{
+		dir:       dir,
+		sizeLimit: sizeLimit,
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("list read cache dir %s: %v", dir, err)
+	}
+	var infos []os.FileInfo
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(dirEntry.Name(), ".tmp") {
+			os.Remove(filepath.Join(dir, dirEntry.Name()))
+			continue
+		}
+		if info, err := dirEntry.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().After(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		c.entries[info.Name()] = c.lru.PushBack(&readCacheEntry{name: info.Name(), size: info.Size()})
+		c.size += info.Size()
+	}
+	c.Lock()
+	c.evict()
+	c.Unlock()
+	glog.V(0).Infof("read cache %s has %d entries, %d bytes", dir, len(c.entries), c.size)
+	return c, nil
+}
+
+func readCacheEntryName(key string, off int64, size int) string {
+	return fmt.Sprintf("%s_%d_%d", key, off, size)
+}
+
+// ReadAt fills p with the cached range, and returns false if not cached
+func (c *ReadCache) ReadAt(key string, p []byte, off int64) bool {
+	name := readCacheEntryName(key, off, len(p))
+	c.Lock()
+	element, found := c.entries[name]
+	if found {
+		c.lru.MoveToFront(element)
+	}
+	c.Unlock()
+	if !found {
+		return false
+	}
+
+	f, err := os.Open(filepath.Join(c.dir, name))
+	if err != nil {
+		c.remove(name)
+		return false
+	}
+	defer f.Close()
+	if _, err = io.ReadFull(f, p); err != nil {
+		glog.V(1).Infof("read cache %s: %v", name, err)
+		c.remove(name)
+		return false
+	}
+	return true
+}
+
+// Set caches the range, which is skipped if larger than one eighth of the size limit
+func (c *ReadCache) Set(key string, p []byte, off int64) {
+	if int64(len(p)) > c.sizeLimit/8 {
+		return
+	}
+	name := readCacheEntryName(key, off, len(p))
+	c.Lock()
+	_, found := c.entries[name]
+	c.Unlock()
+	if found {
+		return
+	}
+
+	// write to a temp file first, so a partially written entry is never read
+	fileName := filepath.Join(c.dir, name)
+	if err := os.WriteFile(fileName+".tmp", p, 0644); err != nil {
+		glog.V(0).Infof("write read cache %s: %v", name, err)
+		os.Remove(fileName + ".tmp")
+		return
+	}
+	if err := os.Rename(fileName+".tmp", fileName); err != nil {
+		glog.V(0).Infof("rename read cache %s: %v", name, err)
+		os.Remove(fileName + ".tmp")
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if _, found := c.entries[name]; found {
+		return
+	}
+	c.entries[name] = c.lru.PushFront(&readCacheEntry{name: name, size: int64(len(p))})
+	c.size += int64(len(p))
+	c.evict()
+}
+
+func (c *ReadCache) remove(name string) {
+	c.Lock()
+	defer c.Unlock()
+	if element, found := c.entries[name]; found {
+		c.removeElement(element)
+	}
+}
+
+func (c *ReadCache) evict() {
+	for c.size > c.sizeLimit {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *ReadCache) removeElement(element *list.Element) {
+	entry := c.lru.Remove(element).(*readCacheEntry)
+	delete(c.entries, entry.name)
+	c.size -= entry.size
+	os.Remove(filepath.Join(c.dir, entry.name))
+}
+
+// Size returns the number of entries and bytes in the cache
+func (c *ReadCache) Size() (count int, size int64) {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.entries), c.size
+}
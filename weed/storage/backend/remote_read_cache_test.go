@@ -0,0 +1,87 @@
+package backend
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type countingFile struct {
+	BackendStorageFile
+	data  []byte
+	reads int
+}
+
+func (f *countingFile) ReadAt(p []byte, off int64) (n int, err error) {
+	f.reads++
+	return copy(p, f.data[off:]), nil
+}
+
+func TestCachedFileReadAt(t *testing.T) {
+	cache, err := NewReadCache(t.TempDir(), 1024)
+	if err != nil {
+		t.Fatalf("new read cache: %v", err)
+	}
+	remote := &countingFile{data: bytes.Repeat([]byte("0123456789"), 100)}
+	f := &CachedFile{BackendStorageFile: remote, cache: cache, key: "remote"}
+
+	for i := 0; i < 3; i++ {
+		p := make([]byte, 100)
+		if n, err := f.ReadAt(p, 25); err != nil || n != len(p) {
+			t.Fatalf("read at: %d %v", n, err)
+		}
+		if !bytes.Equal(p, remote.data[25:125]) {
+			t.Fatalf("unexpected data %s", p)
+		}
+	}
+	if remote.reads != 1 {
+		t.Fatalf("remote reads %d, expected 1", remote.reads)
+	}
+
+	// larger than one eighth of the cache is not cached
+	p := make([]byte, 200)
+	f.ReadAt(p, 0)
+	f.ReadAt(p, 0)
+	if remote.reads != 3 {
+		t.Fatalf("remote reads %d, expected 3", remote.reads)
+	}
+}
+
+func TestReadCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewReadCache(dir, 1024)
+	if err != nil {
+		t.Fatalf("new read cache: %v", err)
+	}
+	data := make([]byte, 100)
+	for i := int64(0); i < 12; i++ {
+		cache.Set("k", data, i*100)
+		// keep the first entry recently read
+		if !cache.ReadAt("k", data, 0) {
+			t.Fatalf("entry 0 evicted after setting entry %d", i)
+		}
+	}
+	if count, size := cache.Size(); count != 10 || size != 1000 {
+		t.Fatalf("cache has %d entries, %d bytes", count, size)
+	}
+	if cache.ReadAt("k", data, 100) {
+		t.Fatalf("least recently read entry 1 is not evicted")
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 10 {
+		t.Fatalf("%d cache files", len(files))
+	}
+
+	// the entries are loaded again after restart
+	reloaded, err := NewReadCache(dir, 1024)
+	if err != nil {
+		t.Fatalf("reload read cache: %v", err)
+	}
+	if count, _ := reloaded.Size(); count != 10 {
+		t.Fatalf("reloaded %d entries", count)
+	}
+	if !reloaded.ReadAt("k", data, 1100) {
+		t.Fatalf("entry 11 not found in %s", filepath.Join(dir, readCacheEntryName("k", 1100, 100)))
+	}
+}
@@ -0,0 +1,140 @@
+package backend
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+// The archival remote storages keep the files offline, e.g. the s3 GLACIER and DEEP_ARCHIVE storage classes
+// or a tape gateway, and a file has to be restored before it can be read, which takes minutes to hours.
+// The first read of an archived file queues a restore job, and the reads fail with ErrRemoteFileArchived
+// until the job finds the file restored. The restored copy expires after the days configured on the storage.
+
+var ErrRemoteFileArchived = errors.New("the remote file is archived, and is being restored")
+
+const (
+	RestoreStateArchived  = "archived"
+	RestoreStateRestoring = "restoring"
+	RestoreStateRestored  = "restored"
+)
+
+var (
+	RestorePollInterval = time.Minute
+	// the reads stop a while before the restored copy expires, to restore it again in time
+	restoreExpiryMargin = time.Hour
+)
+
+// ArchivalBackendStorage is a remote storage whose files may be archived offline
+type ArchivalBackendStorage interface {
+	BackendStorage
+	IsArchival() bool
+	// RestoreFile requests to bring the archived file online, it is fine if the file is being restored already
+	RestoreFile(key string) error
+	GetRestoreStatus(key string) (RestoreStatus, error)
+}
+
+type RestoreStatus struct {
+	State     string
+	ExpiresAt time.Time // of the restored copy
+}
+
+func (s RestoreStatus) isReadable(now time.Time) bool {
+	return s.State == RestoreStateRestored && (s.ExpiresAt.IsZero() || now.Add(restoreExpiryMargin).Before(s.ExpiresAt))
+}
+
+// IsArchivalStorage tells whether the files uploaded to the remote storage are archived offline
+func IsArchivalStorage(storage BackendStorage) bool {
+	archival, ok := storage.(ArchivalBackendStorage)
+	return ok && archival.IsArchival()
+}
+
+var _ BackendStorageFile = &ArchivedFile{}
+
+// ArchivedFile reads the archived remote file once it is restored, and queues a restore job on the first read
+type ArchivedFile struct {
+	BackendStorageFile
+	storage ArchivalBackendStorage
+	key     string
+
+	lock     sync.Mutex
+	status   RestoreStatus
+	isQueued bool
+}
+
+func NewArchivedFile(file BackendStorageFile, storage ArchivalBackendStorage, key string) *ArchivedFile {
+	return &ArchivedFile{
+		BackendStorageFile: file,
+		storage:            storage,
+		key:                key,
+		status:             RestoreStatus{State: RestoreStateArchived},
+	}
+}
+
+func (f *ArchivedFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if !f.RequestRestore() {
+		return 0, ErrRemoteFileArchived
+	}
+	return f.BackendStorageFile.ReadAt(p, off)
+}
+
+// RequestRestore tells whether the file can be read, or else queues a restore job if not queued yet
+func (f *ArchivedFile) RequestRestore() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.status.isReadable(time.Now()) {
+		return true
+	}
+	if !f.isQueued {
+		f.isQueued = true
+		go f.restore()
+	}
+	return false
+}
+
+func (f *ArchivedFile) RestoreStatus() RestoreStatus {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.status
+}
+
+// restore requests to restore the file unless it is restored or being restored, and waits until it is readable
+func (f *ArchivedFile) restore() {
+	defer func() {
+		f.lock.Lock()
+		f.isQueued = false
+		f.lock.Unlock()
+	}()
+	requested := false
+	for {
+		status, err := f.storage.GetRestoreStatus(f.key)
+		if err == nil && status.isReadable(time.Now()) {
+			f.setStatus(status)
+			glog.V(0).Infof("restored archived remote file %s until %v", f.key, status.ExpiresAt)
+			return
+		}
+		if err == nil && !requested && status.State != RestoreStateRestoring {
+			glog.V(0).Infof("restore archived remote file %s", f.key)
+			if err = f.storage.RestoreFile(f.key); err == nil {
+				requested = true
+				status.State = RestoreStateRestoring
+			}
+		}
+		if err != nil {
+			// the next read queues the restore job again
+			glog.Warningf("restore archived remote file %s: %v", f.key, err)
+			f.setStatus(RestoreStatus{State: RestoreStateArchived})
+			return
+		}
+		f.setStatus(status)
+		time.Sleep(RestorePollInterval)
+	}
+}
+
+func (f *ArchivedFile) setStatus(status RestoreStatus) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.status = status
+}
@@ -0,0 +1,80 @@
+package backend
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeArchivalStorage struct {
+	BackendStorage
+	lock     sync.Mutex
+	restores int
+	polls    int
+}
+
+func (s *fakeArchivalStorage) IsArchival() bool {
+	return true
+}
+
+func (s *fakeArchivalStorage) RestoreFile(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.restores++
+	return nil
+}
+
+// GetRestoreStatus reports the file restored on the second poll after the restore request
+func (s *fakeArchivalStorage) GetRestoreStatus(key string) (RestoreStatus, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.restores == 0 {
+		return RestoreStatus{State: RestoreStateArchived}, nil
+	}
+	s.polls++
+	if s.polls < 2 {
+		return RestoreStatus{State: RestoreStateRestoring}, nil
+	}
+	return RestoreStatus{State: RestoreStateRestored, ExpiresAt: time.Now().Add(24 * time.Hour)}, nil
+}
+
+func TestArchivedFileReadAt(t *testing.T) {
+	defer func(interval time.Duration) { RestorePollInterval = interval }(RestorePollInterval)
+	RestorePollInterval = 10 * time.Millisecond
+
+	storage := &fakeArchivalStorage{}
+	remote := &countingFile{data: bytes.Repeat([]byte("0123456789"), 10)}
+	f := NewArchivedFile(remote, storage, "remote")
+
+	p := make([]byte, 10)
+	if _, err := f.ReadAt(p, 0); err != ErrRemoteFileArchived {
+		t.Fatalf("read archived file: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for f.RestoreStatus().State != RestoreStateRestored {
+		if time.Now().After(deadline) {
+			t.Fatalf("restore state %s", f.RestoreStatus().State)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n, err := f.ReadAt(p, 5); err != nil || n != len(p) {
+		t.Fatalf("read restored file: %d %v", n, err)
+	}
+	if !bytes.Equal(p, remote.data[5:15]) {
+		t.Fatalf("unexpected data %s", p)
+	}
+	if storage.restores != 1 {
+		t.Fatalf("restore requests %d, expected 1", storage.restores)
+	}
+}
+
+func TestRestoreStatusExpiring(t *testing.T) {
+	now := time.Now()
+	if (RestoreStatus{State: RestoreStateRestored, ExpiresAt: now.Add(10 * time.Minute)}).isReadable(now) {
+		t.Fatalf("the restored copy expiring soon should be restored again")
+	}
+	if !(RestoreStatus{State: RestoreStateRestored}).isReadable(now) {
+		t.Fatalf("the restored copy without expiry should be readable")
+	}
+}
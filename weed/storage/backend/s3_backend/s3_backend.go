@@ -5,6 +5,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/util"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -39,6 +40,8 @@ type S3BackendStorage struct {
 	bucket                string
 	endpoint              string
 	storageClass          string
+	restoreDays           int64
+	restoreTier           string
 	conn                  s3iface.S3API
 }
 
@@ -54,6 +57,14 @@ func newS3BackendStorage(configuration backend.StringProperties, configPrefix st
 	if s.storageClass == "" {
 		s.storageClass = "STANDARD_IA"
 	}
+	s.restoreDays, _ = strconv.ParseInt(configuration.GetString(configPrefix+"restore_days"), 10, 64)
+	if s.restoreDays <= 0 {
+		s.restoreDays = 7
+	}
+	s.restoreTier = configuration.GetString(configPrefix + "restore_tier")
+	if s.restoreTier == "" {
+		s.restoreTier = s3.TierStandard
+	}
 
 	s.conn, err = createSession(s.aws_access_key_id, s.aws_secret_access_key, s.region, s.endpoint)
 
@@ -69,6 +80,8 @@ func (s *S3BackendStorage) ToProperties() map[string]string {
 	m["bucket"] = s.bucket
 	m["endpoint"] = s.endpoint
 	m["storage_class"] = s.storageClass
+	m["restore_days"] = strconv.FormatInt(s.restoreDays, 10)
+	m["restore_tier"] = s.restoreTier
 	return m
 }
 
@@ -118,6 +131,39 @@ func (s *S3BackendStorage) DeleteFile(key string) (err error) {
 	return
 }
 
+func (s *S3BackendStorage) PutObject(key string, reader io.Reader) (err error) {
+
+	glog.V(2).Infof("put object %s to remote s3.%s", key, s.id)
+
+	return util.Retry("put object to S3", func() error {
+		// read again from the start after a failed upload
+		if seeker, ok := reader.(io.Seeker); ok {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		return putToS3(s.conn, reader, s.bucket, key, s.storageClass)
+	})
+}
+
+// IsArchival tells whether the files are uploaded to the storage classes read only after restored
+func (s *S3BackendStorage) IsArchival() bool {
+	return s.storageClass == s3.StorageClassGlacier || s.storageClass == s3.StorageClassDeepArchive
+}
+
+func (s *S3BackendStorage) RestoreFile(key string) (err error) {
+
+	glog.V(1).Infof("restore dat file %s from remote s3.%s for %d days", key, s.id, s.restoreDays)
+
+	err = restoreFromS3(s.conn, s.bucket, key, s.restoreDays, s.restoreTier)
+
+	return
+}
+
+func (s *S3BackendStorage) GetRestoreStatus(key string) (status backend.RestoreStatus, err error) {
+	return getS3RestoreStatus(s.conn, s.bucket, key)
+}
+
 type S3BackendStorageFile struct {
 	backendStorage *S3BackendStorage
 	key            string
@@ -0,0 +1,63 @@
+package s3_backend
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+)
+
+func restoreFromS3(sess s3iface.S3API, bucket string, key string, days int64, tier string) error {
+	_, err := sess.RestoreObject(&s3.RestoreObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		RestoreRequest: &s3.RestoreRequest{
+			Days: aws.Int64(days),
+			GlacierJobParameters: &s3.GlacierJobParameters{
+				Tier: aws.String(tier),
+			},
+		},
+	})
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "RestoreAlreadyInProgress" {
+		return nil
+	}
+	return err
+}
+
+func getS3RestoreStatus(sess s3iface.S3API, bucket string, key string) (status backend.RestoreStatus, err error) {
+	headObjectOutput, err := sess.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return status, err
+	}
+	storageClass := aws.StringValue(headObjectOutput.StorageClass)
+	if storageClass != s3.StorageClassGlacier && storageClass != s3.StorageClassDeepArchive {
+		// moved to an online storage class
+		return backend.RestoreStatus{State: backend.RestoreStateRestored}, nil
+	}
+	return parseS3Restore(aws.StringValue(headObjectOutput.Restore)), nil
+}
+
+// parseS3Restore parses the x-amz-restore header, e.g. ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+func parseS3Restore(restore string) backend.RestoreStatus {
+	if restore == "" {
+		return backend.RestoreStatus{State: backend.RestoreStateArchived}
+	}
+	if strings.Contains(restore, `ongoing-request="true"`) {
+		return backend.RestoreStatus{State: backend.RestoreStateRestoring}
+	}
+	status := backend.RestoreStatus{State: backend.RestoreStateRestored}
+	if _, expiry, found := strings.Cut(restore, `expiry-date="`); found {
+		expiry, _, _ = strings.Cut(expiry, `"`)
+		status.ExpiresAt, _ = time.Parse(http.TimeFormat, expiry)
+	}
+	return status
+}
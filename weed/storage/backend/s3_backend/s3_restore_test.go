@@ -0,0 +1,21 @@
+package s3_backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+)
+
+func TestParseS3Restore(t *testing.T) {
+	if status := parseS3Restore(""); status.State != backend.RestoreStateArchived {
+		t.Errorf("no restore header: %+v", status)
+	}
+	if status := parseS3Restore(`ongoing-request="true"`); status.State != backend.RestoreStateRestoring {
+		t.Errorf("ongoing restore: %+v", status)
+	}
+	status := parseS3Restore(`ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`)
+	if status.State != backend.RestoreStateRestored || !status.ExpiresAt.Equal(time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("restored: %+v", status)
+	}
+}
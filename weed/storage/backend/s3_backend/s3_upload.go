@@ -5,6 +5,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"io"
 	"os"
 	"sync"
 
@@ -106,3 +107,21 @@ func (r *s3UploadProgressedReader) ReadAt(p []byte, off int64) (int, error) {
 func (r *s3UploadProgressedReader) Seek(offset int64, whence int) (int64, error) {
 	return r.fp.Seek(offset, whence)
 }
+
+func putToS3(sess s3iface.S3API, reader io.Reader, destBucket string, destKey string, storageClass string) error {
+	uploader := s3manager.NewUploaderWithClient(sess, func(u *s3manager.Uploader) {
+		u.PartSize = 64 * 1024 * 1024
+		u.Concurrency = 5
+	})
+	result, err := uploader.Upload(&s3manager.UploadInput{
+		Bucket:       aws.String(destBucket),
+		Key:          aws.String(destKey),
+		Body:         reader,
+		StorageClass: aws.String(storageClass),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %v", destKey, err)
+	}
+	glog.V(2).Infof("uploaded to %s", result.Location)
+	return nil
+}
@@ -0,0 +1,42 @@
+package backend
+
+import (
+	"io"
+	"os"
+)
+
+// The large needle reads are sent from the local data file to the socket by the kernel, e.g. with sendfile on linux,
+// instead of being copied through the user space buffers. The sent needle data is not verified by its checksum.
+
+// sendfileSize is the size of the reads sent by the kernel, or 0 to copy all reads
+var sendfileSize int64
+
+// SetSendfileSize sends the reads of at least the size by the kernel, 0 to copy all reads
+func SetSendfileSize(size int64) {
+	sendfileSize = size
+}
+
+// IsSendfile is true if the read of the size is sent by the kernel
+func IsSendfile(size int64) bool {
+	return sendfileSize > 0 && size >= sendfileSize
+}
+
+// OpenSendfile opens the local data file again for sendfile, which reads from the file position,
+// so it is not shared with the other reads. It is false for the files not on the local disk,
+// and for the files read through the cache device.
+func OpenSendfile(file BackendStorageFile) (*os.File, bool, error) {
+	diskFile, ok := file.(*DiskFile)
+	if !ok {
+		return nil, false, nil
+	}
+	f, err := os.Open(diskFile.File.Name())
+	return f, true, err
+}
+
+// SendFileRange writes the range of the file to the writer, by sendfile if the writer is a socket or an http response
+func SendFileRange(w io.ReaderFrom, f *os.File, offset, size int64) (int64, error) {
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return w.ReadFrom(&io.LimitedReader{R: f, N: size})
+}
@@ -27,6 +27,8 @@ type DiskLocation struct {
 	MaxVolumeCount         int32
 	OriginalMaxVolumeCount int32
 	MinFreeSpace           util.MinFreeSpace
+	ZonedModel             string // host-managed or host-aware for the SMR and ZNS disks, empty otherwise
+	ZoneSize               int64
 	volumes                map[needle.VolumeId]*Volume
 	volumesLock            sync.RWMutex
 
@@ -36,6 +38,20 @@ type DiskLocation struct {
 
 	isDiskSpaceLow bool
 	closeCh        chan struct{}
+
+	// the volumes turn read only once the I/O errors accumulate on the disk
+	isDiskFailing    bool
+	ioErrorThreshold int
+	ioErrorTimes     []time.Time
+	ioErrorLock      sync.Mutex
+
+	// the free space is discarded after the volumes are vacuumed or deleted
+	discardDelay time.Duration
+	discardTimer *time.Timer
+	discardLock  sync.Mutex
+
+	// wal is the optional write-ahead log of the needle writes
+	wal *needleWal
 }
 
 func GenerateDirUuid(dir string) (dirUuidString string, err error) {
@@ -82,6 +98,7 @@ func NewDiskLocation(dir string, maxVolumeCount int32, minFreeSpace util.MinFree
 	location.volumes = make(map[needle.VolumeId]*Volume)
 	location.ecVolumes = make(map[needle.VolumeId]*erasure_coding.EcVolume)
 	location.closeCh = make(chan struct{})
+	location.detectZonedModel()
 	go func() {
 		location.CheckDiskSpace()
 		for {
@@ -299,6 +316,7 @@ func (l *DiskLocation) deleteVolumeById(vid needle.VolumeId, onlyEmpty bool) (fo
 	}
 	found = true
 	delete(l.volumes, vid)
+	l.requestDiscard()
 	return
 }
 
@@ -396,6 +414,11 @@ func (l *DiskLocation) Close() {
 	}
 	l.ecVolumesLock.Unlock()
 
+	if l.wal != nil {
+		l.wal.close()
+	}
+	l.stopDiscard()
+
 	close(l.closeCh)
 	return
 }
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+// After the volumes are vacuumed or deleted, the disk is asked to discard its free space, like fstrim,
+// so the thin provisioned and SSD disks reclaim the freed blocks promptly,
+// without mounting the file system with the discard option.
+// The requests within the delay are coalesced into one discard of the file system.
+
+var errDiscardNotSupported = errors.New("discarding the free space is not supported")
+
+// SetDiscardDelay discards the free space of a disk this long after its volumes are vacuumed or deleted, 0 to never
+func (s *Store) SetDiscardDelay(delay time.Duration) {
+	for _, location := range s.Locations {
+		location.discardLock.Lock()
+		location.discardDelay = delay
+		location.discardLock.Unlock()
+	}
+}
+
+// requestDiscard discards the free space of the disk after the delay, unless already requested
+func (l *DiskLocation) requestDiscard() {
+	l.discardLock.Lock()
+	defer l.discardLock.Unlock()
+	if l.discardDelay <= 0 || l.discardTimer != nil {
+		return
+	}
+	l.discardTimer = time.AfterFunc(l.discardDelay, l.discard)
+}
+
+func (l *DiskLocation) discard() {
+	l.discardLock.Lock()
+	l.discardTimer = nil
+	l.discardLock.Unlock()
+
+	discarded, err := discardFreeSpace(l.Directory)
+	if err == errDiscardNotSupported {
+		glog.V(1).Infof("discard free space of %s: %v", l.Directory, err)
+		return
+	}
+	if err != nil {
+		glog.Warningf("discard free space of %s: %v", l.Directory, err)
+		return
+	}
+	glog.V(0).Infof("discarded %d bytes of free space of %s", discarded, l.Directory)
+}
+
+func (l *DiskLocation) stopDiscard() {
+	l.discardLock.Lock()
+	defer l.discardLock.Unlock()
+	if l.discardTimer != nil {
+		l.discardTimer.Stop()
+		l.discardTimer = nil
+	}
+}
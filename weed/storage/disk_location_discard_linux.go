@@ -0,0 +1,40 @@
+//go:build linux
+// +build linux
+
+package storage
+
+import (
+	"math"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// fitrim is _IOWR('X', 121, struct fstrim_range)
+const fitrim = 0xc0185879
+
+type fstrimRange struct {
+	start  uint64
+	length uint64
+	minLen uint64
+}
+
+// discardFreeSpace discards the free blocks of the file system of the directory, and returns the discarded bytes
+func discardFreeSpace(dir string) (int64, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := fstrimRange{length: math.MaxUint64}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), fitrim, uintptr(unsafe.Pointer(&r)))
+	switch errno {
+	case 0:
+		return int64(r.length), nil
+	case unix.EOPNOTSUPP, unix.ENOTTY:
+		return 0, errDiscardNotSupported
+	default:
+		return 0, errno
+	}
+}
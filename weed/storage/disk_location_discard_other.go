@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package storage
+
+func discardFreeSpace(dir string) (int64, error) {
+	return 0, errDiscardNotSupported
+}
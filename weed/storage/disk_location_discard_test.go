@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestRequestDiscard(t *testing.T) {
+	s := NewStore(nil, "localhost", 8080, 18080, "localhost:8080", []string{t.TempDir()}, []int32{10},
+		[]util.MinFreeSpace{{}}, "", NeedleMapInMemory, []types.DiskType{types.HardDriveType}, 0)
+	defer s.Close()
+	location := s.Locations[0]
+
+	location.requestDiscard()
+	if location.discardTimer != nil {
+		t.Fatalf("discard requested while disabled")
+	}
+
+	s.SetDiscardDelay(time.Hour)
+	if err := s.AddVolume(1, "", NeedleMapInMemory, "000", "", 0, 0, types.HardDriveType, 0); err != nil {
+		t.Fatalf("add volume: %v", err)
+	}
+	<-s.NewVolumesChan
+	if location.discardTimer != nil {
+		t.Fatalf("discard requested before deleting")
+	}
+	if err := s.DeleteVolume(1, false); err != nil {
+		t.Fatalf("delete volume: %v", err)
+	}
+	timer := location.discardTimer
+	if timer == nil {
+		t.Fatalf("discard not requested after deleting")
+	}
+	location.requestDiscard()
+	if location.discardTimer != timer {
+		t.Fatalf("discard requests not coalesced")
+	}
+
+	location.stopDiscard()
+	s.SetDiscardDelay(time.Millisecond)
+	location.requestDiscard()
+	for i := 0; i < 100 && location.isDiscardRequested(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if location.isDiscardRequested() {
+		t.Fatalf("discard not done")
+	}
+}
+
+func (l *DiskLocation) isDiscardRequested() bool {
+	l.discardLock.Lock()
+	defer l.discardLock.Unlock()
+	return l.discardTimer != nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"errors"
+	"syscall"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+// A disk is failing once ioErrorThreshold I/O errors happen on it within diskFailureWindow.
+// Its volumes turn read only, and are reported to the master to be re-replicated from the other replicas,
+// while the volumes on the other disks keep serving. The disk stays failing until the volume server restarts.
+
+const diskFailureWindow = time.Minute
+
+// SetDiskFailureThreshold marks a disk failing after the number of I/O errors within a minute, 0 to never
+func (s *Store) SetDiskFailureThreshold(threshold int) {
+	for _, location := range s.Locations {
+		location.ioErrorLock.Lock()
+		location.ioErrorThreshold = threshold
+		location.ioErrorLock.Unlock()
+	}
+}
+
+func isIoError(err error) bool {
+	return errors.Is(err, syscall.EIO) || err.Error() == "input/output error"
+}
+
+// recordIoError counts the I/O error of the volume, and marks the disk failing if the errors accumulate
+func (l *DiskLocation) recordIoError(vid needle.VolumeId, err error) {
+	if l == nil {
+		return
+	}
+	l.ioErrorLock.Lock()
+	defer l.ioErrorLock.Unlock()
+
+	if l.ioErrorThreshold <= 0 || l.isDiskFailing {
+		return
+	}
+	now := time.Now()
+	recent := l.ioErrorTimes[:0]
+	for _, t := range l.ioErrorTimes {
+		if now.Sub(t) < diskFailureWindow {
+			recent = append(recent, t)
+		}
+	}
+	l.ioErrorTimes = append(recent, now)
+	glog.Warningf("volume %d on %s has I/O error %d of %d: %v", vid, l.Directory, len(l.ioErrorTimes), l.ioErrorThreshold, err)
+	if len(l.ioErrorTimes) >= l.ioErrorThreshold {
+		l.isDiskFailing = true
+		l.ioErrorTimes = nil
+		glog.Errorf("disk %s is failing, its volumes are read only and re-replicated", l.Directory)
+	}
+}
+
+// IsDiskFailing is true if the I/O errors accumulated on the disk
+func (l *DiskLocation) IsDiskFailing() bool {
+	l.ioErrorLock.Lock()
+	defer l.ioErrorLock.Unlock()
+	return l.isDiskFailing
+}
+
+func (v *Volume) isOnFailingDisk() bool {
+	return v.location != nil && v.location.IsDiskFailing()
+}
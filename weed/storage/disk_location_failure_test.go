@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestDiskFailure(t *testing.T) {
+	s := NewStore(nil, "localhost", 8080, 18080, "localhost:8080", []string{t.TempDir(), t.TempDir()}, []int32{10, 10},
+		[]util.MinFreeSpace{{}, {}}, "", NeedleMapInMemory, []types.DiskType{types.HardDriveType, types.HardDriveType}, 0)
+	defer s.Close()
+	s.SetDiskFailureThreshold(3)
+	if err := s.AddVolume(1, "", NeedleMapInMemory, "000", "", 0, 0, types.HardDriveType, 0); err != nil {
+		t.Fatalf("add volume: %v", err)
+	}
+	<-s.NewVolumesChan
+	failing, healthy := s.Locations[0], s.Locations[1]
+	v, found := failing.FindVolume(1)
+	if !found {
+		failing, healthy = healthy, failing
+		v, _ = failing.FindVolume(1)
+	}
+
+	eio := fmt.Errorf("read %s: %w", v.FileName(".dat"), syscall.EIO)
+	for i := 0; i < 2; i++ {
+		v.checkReadWriteError(eio)
+	}
+	v.checkReadWriteError(fmt.Errorf("CRC error"))
+	if failing.IsDiskFailing() || v.IsReadOnly() {
+		t.Fatalf("disk failing before reaching the threshold")
+	}
+	v.checkReadWriteError(eio)
+	if !failing.IsDiskFailing() || !v.IsReadOnly() {
+		t.Fatalf("disk not failing after reaching the threshold")
+	}
+	if healthy.IsDiskFailing() {
+		t.Fatalf("healthy disk failing")
+	}
+	if _, err := s.WriteVolumeNeedle(1, newRandomNeedle(1), false, false); err == nil {
+		t.Fatalf("write to the volume on the failing disk")
+	}
+	if location := s.FindFreeLocation(types.HardDriveType); location != healthy {
+		t.Fatalf("new volume on %v, expected the healthy disk", location)
+	}
+	heartbeat := s.CollectHeartbeat()
+	if len(heartbeat.Volumes) != 1 || !heartbeat.Volumes[0].DiskFailing || !heartbeat.Volumes[0].ReadOnly {
+		t.Fatalf("heartbeat volumes %+v", heartbeat.Volumes)
+	}
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+const (
+	walCheckpointInterval = time.Minute
+	walCheckpointSize     = 64 * 1024 * 1024
+)
+
+// replayNeedleWal appends the needles in the write-ahead log, which are lost from the volumes after a crash.
+// The volumes are synced before the log is removed.
+func (l *DiskLocation) replayNeedleWal() error {
+	fileName := filepath.Join(l.Directory, NeedleWalFileName)
+	replayedVolumes := make(map[needle.VolumeId]*Volume)
+	lastAppendAtNs := make(map[needle.VolumeId]uint64)
+	replayedCount := 0
+	err := readWalRecords(fileName, func(record *walRecord) error {
+		v, found := l.FindVolume(record.volumeId)
+		if !found {
+			return nil
+		}
+		sinceNs, seen := lastAppendAtNs[v.Id]
+		if !seen {
+			sinceNs = v.getLastAppendAtNs()
+			lastAppendAtNs[v.Id] = sinceNs
+		}
+		// the later needles are appended in order, so the volume has all the needles up to its last one
+		if record.appendAtNs <= sinceNs {
+			return nil
+		}
+		if err := v.replayWalRecord(record); err != nil {
+			return fmt.Errorf("replay needle %s of volume %d: %v", record.needleId, v.Id, err)
+		}
+		replayedVolumes[v.Id] = v
+		replayedCount++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("replay %s: %v", fileName, err)
+	}
+	for _, v := range replayedVolumes {
+		v.SyncToDisk()
+	}
+	if replayedCount > 0 {
+		glog.V(0).Infof("replayed %d needles into %d volumes from %s", replayedCount, len(replayedVolumes), fileName)
+	}
+	if err = os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// loopNeedleWalCheckpoint syncs the volumes and drops the synced records, so the log does not grow unbounded
+func (l *DiskLocation) loopNeedleWalCheckpoint() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	lastCheckpoint := time.Now()
+	for {
+		select {
+		case <-l.closeCh:
+			return
+		case <-ticker.C:
+		}
+		size := l.wal.size()
+		if size < walCheckpointSize && (size == 0 || time.Since(lastCheckpoint) < walCheckpointInterval) {
+			continue
+		}
+		if err := l.wal.checkpoint(l.syncVolume); err != nil {
+			if err == os.ErrClosed {
+				return
+			}
+			glog.Errorf("checkpoint %s: %v", l.wal.fileName, err)
+			continue
+		}
+		lastCheckpoint = time.Now()
+	}
+}
+
+func (l *DiskLocation) syncVolume(vid needle.VolumeId) {
+	if v, found := l.FindVolume(vid); found {
+		v.SyncToDisk()
+	}
+}
+
+func (v *Volume) getLastAppendAtNs() uint64 {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+	return v.lastAppendAtNs
+}
+
+func (v *Volume) replayWalRecord(record *walRecord) error {
+	switch record.kind {
+	case walRecordPut:
+		return v.WriteNeedleBlob(record.needleId, record.blob, record.size)
+	case walRecordDelete:
+		_, err := v.syncDelete(&needle.Needle{Id: record.needleId})
+		return err
+	}
+	return fmt.Errorf("unknown record kind %d", record.kind)
+}
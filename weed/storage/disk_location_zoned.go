@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+const (
+	// the zoned models reported in /sys/block/<disk>/queue/zoned
+	ZonedModelHostManaged = "host-managed"
+	ZonedModelHostAware   = "host-aware"
+)
+
+// IsZoned is true for the host-managed SMR and ZNS disks. The volume files are only appended,
+// which a zoned file system, like btrfs or f2fs in zoned mode, writes sequentially into the zones.
+// The preallocation, memory mapped volumes, and compacting in place are not used, since they write
+// out of order. Vacuum copies the live needles to a new file and removes the old one, so the file system
+// resets the whole zones of the old file, instead of relocating the valid blocks of partially deleted zones.
+func (l *DiskLocation) IsZoned() bool {
+	return l.ZonedModel != ""
+}
+
+// SetZoned uses the zoned layout for all disk locations, such as on devices not detected as zoned
+func (s *Store) SetZoned() {
+	for _, location := range s.Locations {
+		if !location.IsZoned() {
+			location.ZonedModel = ZonedModelHostManaged
+			glog.V(0).Infof("use zoned layout for %s", location.Directory)
+		}
+	}
+}
+
+func (l *DiskLocation) detectZonedModel() {
+	l.ZonedModel, l.ZoneSize = detectZonedDevice(l.Directory)
+	if l.IsZoned() {
+		glog.V(0).Infof("use zoned layout for %s on %s disk with %d bytes zones", l.Directory, l.ZonedModel, l.ZoneSize)
+	}
+}
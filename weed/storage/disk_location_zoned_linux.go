@@ -0,0 +1,41 @@
+//go:build linux
+// +build linux
+
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// detectZonedDevice reads the zoned model and the zone size of the disk of the directory from sysfs
+func detectZonedDevice(dir string) (zonedModel string, zoneSize int64) {
+	var stat unix.Stat_t
+	if err := unix.Stat(dir, &stat); err != nil {
+		return "", 0
+	}
+	deviceDir := fmt.Sprintf("/sys/dev/block/%d:%d", unix.Major(uint64(stat.Dev)), unix.Minor(uint64(stat.Dev)))
+	// the queue of a partition is in the directory of its disk
+	for _, queueDir := range []string{filepath.Join(deviceDir, "queue"), filepath.Join(deviceDir, "..", "queue")} {
+		model, err := os.ReadFile(filepath.Join(queueDir, "zoned"))
+		if err != nil {
+			continue
+		}
+		zonedModel = strings.TrimSpace(string(model))
+		if zonedModel != ZonedModelHostManaged && zonedModel != ZonedModelHostAware {
+			return "", 0
+		}
+		if sectors, err := os.ReadFile(filepath.Join(queueDir, "chunk_sectors")); err == nil {
+			if n, err := strconv.ParseInt(strings.TrimSpace(string(sectors)), 10, 64); err == nil {
+				zoneSize = n * 512
+			}
+		}
+		return zonedModel, zoneSize
+	}
+	return "", 0
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package storage
+
+func detectZonedDevice(dir string) (zonedModel string, zoneSize int64) {
+	return "", 0
+}
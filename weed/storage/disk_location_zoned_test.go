@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestZonedLayout(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(nil, "localhost", 8080, 18080, "localhost:8080", []string{dir}, []int32{10},
+		[]util.MinFreeSpace{{}}, "", NeedleMapInMemory, []types.DiskType{types.HardDriveType}, 0)
+	defer s.Close()
+	if s.Locations[0].IsZoned() {
+		t.Skipf("%s is on a zoned disk", dir)
+	}
+	s.SetZoned()
+	if !s.Locations[0].IsZoned() {
+		t.Fatalf("location is not zoned")
+	}
+
+	if err := s.AddVolume(1, "", NeedleMapInMemory, "000", "", 1024*1024, 0, types.HardDriveType, 0); err != nil {
+		t.Fatalf("add volume: %v", err)
+	}
+	<-s.NewVolumesChan
+
+	if err := s.AddVolume(2, "", NeedleMapInMemory, "000", "", 0, 64, types.HardDriveType, 0); err == nil {
+		t.Errorf("memory mapped volume is added to zoned location")
+	}
+
+	s.SetCompactInPlace(true)
+	n := newRandomNeedle(1)
+	if _, err := s.WriteVolumeNeedle(1, n, true, false); err != nil {
+		t.Fatalf("write needle: %v", err)
+	}
+	if _, err := s.DeleteVolumeNeedle(1, n); err != nil {
+		t.Fatalf("delete needle: %v", err)
+	}
+	if err := s.CompactVolume(1, 1024*1024, 0, nil); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if _, err := os.Stat(s.GetVolume(1).FileName(".cpd")); err != nil {
+		t.Errorf("zoned volume is not compacted to a new file: %v", err)
+	}
+	if _, _, err := s.CommitCompactVolume(1); err != nil {
+		t.Fatalf("commit compact: %v", err)
+	}
+	if s.GetVolume(1).DeletedCount() != 0 {
+		t.Errorf("deleted count %d after vacuum", s.GetVolume(1).DeletedCount())
+	}
+}
@@ -150,7 +150,7 @@ func iterateEcjFile(baseFileName string, processNeedleFn func(key types.NeedleId
 
 }
 
-// WriteDatFile generates .dat from from .ec00 ~ .ec09 files
+// WriteDatFile generates .dat from from .ec00 ~ .ec09 files, i.e. the data shards given as shardFileNames
 func WriteDatFile(baseFileName string, datFileSize int64, shardFileNames []string) error {
 
 	datFile, openErr := os.OpenFile(baseFileName+".dat", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
@@ -159,25 +159,26 @@ func WriteDatFile(baseFileName string, datFileSize int64, shardFileNames []strin
 	}
 	defer datFile.Close()
 
-	inputFiles := make([]*os.File, DataShardsCount)
+	dataShards := len(shardFileNames)
+	inputFiles := make([]*os.File, dataShards)
 
 	defer func() {
-		for shardId := 0; shardId < DataShardsCount; shardId++ {
+		for shardId := 0; shardId < dataShards; shardId++ {
 			if inputFiles[shardId] != nil {
 				inputFiles[shardId].Close()
 			}
 		}
 	}()
 
-	for shardId := 0; shardId < DataShardsCount; shardId++ {
+	for shardId := 0; shardId < dataShards; shardId++ {
 		inputFiles[shardId], openErr = os.OpenFile(shardFileNames[shardId], os.O_RDONLY, 0)
 		if openErr != nil {
 			return openErr
 		}
 	}
 
-	for datFileSize >= DataShardsCount*ErasureCodingLargeBlockSize {
-		for shardId := 0; shardId < DataShardsCount; shardId++ {
+	for datFileSize >= int64(dataShards)*ErasureCodingLargeBlockSize {
+		for shardId := 0; shardId < dataShards; shardId++ {
 			w, err := io.CopyN(datFile, inputFiles[shardId], ErasureCodingLargeBlockSize)
 			if w != ErasureCodingLargeBlockSize {
 				return fmt.Errorf("copy %s large block %d: %v", baseFileName, shardId, err)
@@ -187,7 +188,7 @@ func WriteDatFile(baseFileName string, datFileSize int64, shardFileNames []strin
 	}
 
 	for datFileSize > 0 {
-		for shardId := 0; shardId < DataShardsCount; shardId++ {
+		for shardId := 0; shardId < dataShards; shardId++ {
 			toRead := min(datFileSize, ErasureCodingSmallBlockSize)
 			w, err := io.CopyN(datFile, inputFiles[shardId], toRead)
 			if w != toRead {
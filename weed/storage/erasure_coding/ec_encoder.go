@@ -53,20 +53,25 @@ func WriteSortedFileFromIdx(baseFileName string, ext string) (e error) {
 	return nil
 }
 
-// WriteEcFiles generates .ec00 ~ .ec13 files
-func WriteEcFiles(baseFileName string) error {
-	return generateEcFiles(baseFileName, 256*1024, ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize)
+// WriteEcFiles generates .ec00 ~ .ec13 files, or as many as the shards of the scheme
+func WriteEcFiles(baseFileName string, scheme Scheme) error {
+	return WriteEcFilesWithThrottler(baseFileName, scheme, util.NewWriteThrottler(0))
 }
 
-func RebuildEcFiles(baseFileName string) ([]uint32, error) {
-	return generateMissingEcFiles(baseFileName, 256*1024, ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize)
+// WriteEcFilesWithThrottler slows down reading the .dat file to the throttler speed
+func WriteEcFilesWithThrottler(baseFileName string, scheme Scheme, throttler *util.WriteThrottler) error {
+	return generateEcFiles(baseFileName, scheme, 256*1024, ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, throttler)
+}
+
+func RebuildEcFiles(baseFileName string, scheme Scheme) ([]uint32, error) {
+	return generateMissingEcFiles(baseFileName, scheme, 256*1024, ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize)
 }
 
 func ToExt(ecIndex int) string {
 	return fmt.Sprintf(".ec%02d", ecIndex)
 }
 
-func generateEcFiles(baseFileName string, bufferSize int, largeBlockSize int64, smallBlockSize int64) error {
+func generateEcFiles(baseFileName string, scheme Scheme, bufferSize int, largeBlockSize int64, smallBlockSize int64, throttler *util.WriteThrottler) error {
 	file, err := os.OpenFile(baseFileName+".dat", os.O_RDONLY, 0)
 	if err != nil {
 		return fmt.Errorf("failed to open dat file: %v", err)
@@ -78,20 +83,21 @@ func generateEcFiles(baseFileName string, bufferSize int, largeBlockSize int64,
 		return fmt.Errorf("failed to stat dat file: %v", err)
 	}
 
-	glog.V(0).Infof("encodeDatFile %s.dat size:%d", baseFileName, fi.Size())
-	err = encodeDatFile(fi.Size(), baseFileName, bufferSize, largeBlockSize, file, smallBlockSize)
+	glog.V(0).Infof("encodeDatFile %s.dat size:%d scheme:%s", baseFileName, fi.Size(), scheme)
+	err = encodeDatFile(fi.Size(), baseFileName, scheme, bufferSize, largeBlockSize, file, smallBlockSize, throttler)
 	if err != nil {
 		return fmt.Errorf("encodeDatFile: %v", err)
 	}
 	return nil
 }
 
-func generateMissingEcFiles(baseFileName string, bufferSize int, largeBlockSize int64, smallBlockSize int64) (generatedShardIds []uint32, err error) {
+func generateMissingEcFiles(baseFileName string, scheme Scheme, bufferSize int, largeBlockSize int64, smallBlockSize int64) (generatedShardIds []uint32, err error) {
 
-	shardHasData := make([]bool, TotalShardsCount)
-	inputFiles := make([]*os.File, TotalShardsCount)
-	outputFiles := make([]*os.File, TotalShardsCount)
-	for shardId := 0; shardId < TotalShardsCount; shardId++ {
+	totalShards := scheme.TotalShards()
+	shardHasData := make([]bool, totalShards)
+	inputFiles := make([]*os.File, totalShards)
+	outputFiles := make([]*os.File, totalShards)
+	for shardId := 0; shardId < totalShards; shardId++ {
 		shardFileName := baseFileName + ToExt(shardId)
 		if util.FileExists(shardFileName) {
 			shardHasData[shardId] = true
@@ -110,14 +116,14 @@ func generateMissingEcFiles(baseFileName string, bufferSize int, largeBlockSize
 		}
 	}
 
-	err = rebuildEcFiles(shardHasData, inputFiles, outputFiles)
+	err = rebuildEcFiles(scheme, shardHasData, inputFiles, outputFiles)
 	if err != nil {
 		return nil, fmt.Errorf("rebuildEcFiles: %v", err)
 	}
 	return
 }
 
-func encodeData(file *os.File, enc reedsolomon.Encoder, startOffset, blockSize int64, buffers [][]byte, outputs []*os.File) error {
+func encodeData(file *os.File, enc reedsolomon.Encoder, dataShards int, startOffset, blockSize int64, buffers [][]byte, outputs []*os.File, throttler *util.WriteThrottler) error {
 
 	bufferSize := int64(len(buffers[0]))
 	if bufferSize == 0 {
@@ -130,17 +136,18 @@ func encodeData(file *os.File, enc reedsolomon.Encoder, startOffset, blockSize i
 	}
 
 	for b := int64(0); b < batchCount; b++ {
-		err := encodeDataOneBatch(file, enc, startOffset+b*bufferSize, blockSize, buffers, outputs)
+		err := encodeDataOneBatch(file, enc, dataShards, startOffset+b*bufferSize, blockSize, buffers, outputs)
 		if err != nil {
 			return err
 		}
+		throttler.MaybeSlowdown(int64(dataShards) * bufferSize)
 	}
 
 	return nil
 }
 
-func openEcFiles(baseFileName string, forRead bool) (files []*os.File, err error) {
-	for i := 0; i < TotalShardsCount; i++ {
+func openEcFiles(baseFileName string, scheme Scheme, forRead bool) (files []*os.File, err error) {
+	for i := 0; i < scheme.TotalShards(); i++ {
 		fname := baseFileName + ToExt(i)
 		openOption := os.O_TRUNC | os.O_CREATE | os.O_WRONLY
 		if forRead {
@@ -163,10 +170,10 @@ func closeEcFiles(files []*os.File) {
 	}
 }
 
-func encodeDataOneBatch(file *os.File, enc reedsolomon.Encoder, startOffset, blockSize int64, buffers [][]byte, outputs []*os.File) error {
+func encodeDataOneBatch(file *os.File, enc reedsolomon.Encoder, dataShards int, startOffset, blockSize int64, buffers [][]byte, outputs []*os.File) error {
 
 	// read data into buffers
-	for i := 0; i < DataShardsCount; i++ {
+	for i := 0; i < dataShards; i++ {
 		n, err := file.ReadAt(buffers[i], startOffset+blockSize*int64(i))
 		if err != nil {
 			if err != io.EOF {
@@ -185,7 +192,7 @@ func encodeDataOneBatch(file *os.File, enc reedsolomon.Encoder, startOffset, blo
 		return err
 	}
 
-	for i := 0; i < TotalShardsCount; i++ {
+	for i := range outputs {
 		_, err := outputs[i].Write(buffers[i])
 		if err != nil {
 			return err
@@ -195,53 +202,54 @@ func encodeDataOneBatch(file *os.File, enc reedsolomon.Encoder, startOffset, blo
 	return nil
 }
 
-func encodeDatFile(remainingSize int64, baseFileName string, bufferSize int, largeBlockSize int64, file *os.File, smallBlockSize int64) error {
+func encodeDatFile(remainingSize int64, baseFileName string, scheme Scheme, bufferSize int, largeBlockSize int64, file *os.File, smallBlockSize int64, throttler *util.WriteThrottler) error {
 
 	var processedSize int64
 
-	enc, err := reedsolomon.New(DataShardsCount, ParityShardsCount)
+	enc, err := scheme.newEncoder()
 	if err != nil {
 		return fmt.Errorf("failed to create encoder: %v", err)
 	}
 
-	buffers := make([][]byte, TotalShardsCount)
+	buffers := make([][]byte, scheme.TotalShards())
 	for i := range buffers {
 		buffers[i] = make([]byte, bufferSize)
 	}
 
-	outputs, err := openEcFiles(baseFileName, false)
+	outputs, err := openEcFiles(baseFileName, scheme, false)
 	defer closeEcFiles(outputs)
 	if err != nil {
 		return fmt.Errorf("failed to open ec files %s: %v", baseFileName, err)
 	}
 
-	for remainingSize > largeBlockSize*DataShardsCount {
-		err = encodeData(file, enc, processedSize, largeBlockSize, buffers, outputs)
+	dataShards := int64(scheme.DataShards)
+	for remainingSize > largeBlockSize*dataShards {
+		err = encodeData(file, enc, scheme.DataShards, processedSize, largeBlockSize, buffers, outputs, throttler)
 		if err != nil {
 			return fmt.Errorf("failed to encode large chunk data: %v", err)
 		}
-		remainingSize -= largeBlockSize * DataShardsCount
-		processedSize += largeBlockSize * DataShardsCount
+		remainingSize -= largeBlockSize * dataShards
+		processedSize += largeBlockSize * dataShards
 	}
 	for remainingSize > 0 {
-		err = encodeData(file, enc, processedSize, smallBlockSize, buffers, outputs)
+		err = encodeData(file, enc, scheme.DataShards, processedSize, smallBlockSize, buffers, outputs, throttler)
 		if err != nil {
 			return fmt.Errorf("failed to encode small chunk data: %v", err)
 		}
-		remainingSize -= smallBlockSize * DataShardsCount
-		processedSize += smallBlockSize * DataShardsCount
+		remainingSize -= smallBlockSize * dataShards
+		processedSize += smallBlockSize * dataShards
 	}
 	return nil
 }
 
-func rebuildEcFiles(shardHasData []bool, inputFiles []*os.File, outputFiles []*os.File) error {
+func rebuildEcFiles(scheme Scheme, shardHasData []bool, inputFiles []*os.File, outputFiles []*os.File) error {
 
-	enc, err := reedsolomon.New(DataShardsCount, ParityShardsCount)
+	enc, err := scheme.newEncoder()
 	if err != nil {
 		return fmt.Errorf("failed to create encoder: %v", err)
 	}
 
-	buffers := make([][]byte, TotalShardsCount)
+	buffers := make([][]byte, scheme.TotalShards())
 	for i := range buffers {
 		if shardHasData[i] {
 			buffers[i] = make([]byte, ErasureCodingSmallBlockSize)
@@ -253,7 +261,7 @@ func rebuildEcFiles(shardHasData []bool, inputFiles []*os.File, outputFiles []*o
 	for {
 
 		// read the input data from files
-		for i := 0; i < TotalShardsCount; i++ {
+		for i := range buffers {
 			if shardHasData[i] {
 				n, _ := inputFiles[i].ReadAt(buffers[i], startOffset)
 				if n == 0 {
@@ -277,7 +285,7 @@ func rebuildEcFiles(shardHasData []bool, inputFiles []*os.File, outputFiles []*o
 		}
 
 		// write the data to output files
-		for i := 0; i < TotalShardsCount; i++ {
+		for i := range buffers {
 			if !shardHasData[i] {
 				n, _ := outputFiles[i].WriteAt(buffers[i][:inputBufferDataSize], startOffset)
 				if inputBufferDataSize != n {
@@ -10,13 +10,20 @@ type Interval struct {
 	Size                types.Size
 	IsLargeBlock        bool
 	LargeBlockRowsCount int
+	// the data shards of the scheme, DataShardsCount if not set
+	DataShards int
 }
 
 func LocateData(largeBlockLength, smallBlockLength int64, datSize int64, offset int64, size types.Size) (intervals []Interval) {
-	blockIndex, isLargeBlock, innerBlockOffset := locateOffset(largeBlockLength, smallBlockLength, datSize, offset)
+	return DefaultScheme.LocateData(largeBlockLength, smallBlockLength, datSize, offset, size)
+}
+
+func (s Scheme) LocateData(largeBlockLength, smallBlockLength int64, datSize int64, offset int64, size types.Size) (intervals []Interval) {
+	dataShards := int64(s.DataShards)
+	blockIndex, isLargeBlock, innerBlockOffset := locateOffset(dataShards, largeBlockLength, smallBlockLength, datSize, offset)
 
-	// adding DataShardsCount*smallBlockLength to ensure we can derive the number of large block size from a shard size
-	nLargeBlockRows := int((datSize + DataShardsCount*smallBlockLength) / (largeBlockLength * DataShardsCount))
+	// adding dataShards*smallBlockLength to ensure we can derive the number of large block size from a shard size
+	nLargeBlockRows := int((datSize + dataShards*smallBlockLength) / (largeBlockLength * dataShards))
 
 	for size > 0 {
 		interval := Interval{
@@ -24,6 +31,7 @@ func LocateData(largeBlockLength, smallBlockLength int64, datSize int64, offset
 			InnerBlockOffset:    innerBlockOffset,
 			IsLargeBlock:        isLargeBlock,
 			LargeBlockRowsCount: nLargeBlockRows,
+			DataShards:          s.DataShards,
 		}
 
 		blockRemaining := largeBlockLength - innerBlockOffset
@@ -41,7 +49,7 @@ func LocateData(largeBlockLength, smallBlockLength int64, datSize int64, offset
 
 		size -= interval.Size
 		blockIndex += 1
-		if isLargeBlock && blockIndex == nLargeBlockRows*DataShardsCount {
+		if isLargeBlock && blockIndex == nLargeBlockRows*s.DataShards {
 			isLargeBlock = false
 			blockIndex = 0
 		}
@@ -51,9 +59,9 @@ func LocateData(largeBlockLength, smallBlockLength int64, datSize int64, offset
 	return
 }
 
-func locateOffset(largeBlockLength, smallBlockLength int64, datSize int64, offset int64) (blockIndex int, isLargeBlock bool, innerBlockOffset int64) {
-	largeRowSize := largeBlockLength * DataShardsCount
-	nLargeBlockRows := datSize / (largeBlockLength * DataShardsCount)
+func locateOffset(dataShards, largeBlockLength, smallBlockLength int64, datSize int64, offset int64) (blockIndex int, isLargeBlock bool, innerBlockOffset int64) {
+	largeRowSize := largeBlockLength * dataShards
+	nLargeBlockRows := datSize / (largeBlockLength * dataShards)
 
 	// if offset is within the large block area
 	if offset < nLargeBlockRows*largeRowSize {
@@ -75,13 +83,17 @@ func locateOffsetWithinBlocks(blockLength int64, offset int64) (blockIndex int,
 }
 
 func (interval Interval) ToShardIdAndOffset(largeBlockSize, smallBlockSize int64) (ShardId, int64) {
+	dataShards := interval.DataShards
+	if dataShards == 0 {
+		dataShards = DataShardsCount
+	}
 	ecFileOffset := interval.InnerBlockOffset
-	rowIndex := interval.BlockIndex / DataShardsCount
+	rowIndex := interval.BlockIndex / dataShards
 	if interval.IsLargeBlock {
 		ecFileOffset += int64(rowIndex) * largeBlockSize
 	} else {
 		ecFileOffset += int64(interval.LargeBlockRowsCount)*largeBlockSize + int64(rowIndex)*smallBlockSize
 	}
-	ecFileIndex := interval.BlockIndex % DataShardsCount
+	ecFileIndex := interval.BlockIndex % dataShards
 	return ShardId(ecFileIndex), ecFileOffset
 }
@@ -0,0 +1,105 @@
+package erasure_coding
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/reedsolomon"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/volume_info"
+)
+
+// MaxShardCount is the most shards of one ec volume, limited by the 32 bits of ShardBits
+const MaxShardCount = 32
+
+// Scheme is how an ec volume is split into data shards and parity shards.
+// The volume can be read with any DataShards of its shards.
+type Scheme struct {
+	DataShards   int
+	ParityShards int
+}
+
+var DefaultScheme = Scheme{DataShards: DataShardsCount, ParityShards: ParityShardsCount}
+
+// NewScheme returns the scheme of the stored or reported counts, where 0 means the default 10+4.
+func NewScheme(dataShards, parityShards uint32) Scheme {
+	if dataShards == 0 || parityShards == 0 {
+		return DefaultScheme
+	}
+	return Scheme{DataShards: int(dataShards), ParityShards: int(parityShards)}
+}
+
+// ParseScheme parses the scheme in the form of "data+parity", e.g. "8+3".
+func ParseScheme(s string) (Scheme, error) {
+	data, parity, found := strings.Cut(strings.TrimSpace(s), "+")
+	if !found {
+		return Scheme{}, fmt.Errorf("ec scheme %q should be data+parity shards, e.g. 10+4", s)
+	}
+	dataShards, err := strconv.Atoi(data)
+	if err != nil {
+		return Scheme{}, fmt.Errorf("ec scheme %q data shards: %v", s, err)
+	}
+	parityShards, err := strconv.Atoi(parity)
+	if err != nil {
+		return Scheme{}, fmt.Errorf("ec scheme %q parity shards: %v", s, err)
+	}
+	scheme := Scheme{DataShards: dataShards, ParityShards: parityShards}
+	return scheme, scheme.Validate()
+}
+
+func (s Scheme) Validate() error {
+	if s.DataShards < 1 || s.ParityShards < 1 {
+		return fmt.Errorf("ec scheme %s needs at least 1 data shard and 1 parity shard", s)
+	}
+	if s.TotalShards() > MaxShardCount {
+		return fmt.Errorf("ec scheme %s has more than %d shards", s, MaxShardCount)
+	}
+	return nil
+}
+
+func (s Scheme) TotalShards() int {
+	return s.DataShards + s.ParityShards
+}
+
+func (s Scheme) IsDefault() bool {
+	return s == DefaultScheme
+}
+
+func (s Scheme) String() string {
+	return fmt.Sprintf("%d+%d", s.DataShards, s.ParityShards)
+}
+
+func (s Scheme) newEncoder() (reedsolomon.Encoder, error) {
+	return reedsolomon.New(s.DataShards, s.ParityShards)
+}
+
+// ToEcShardConfig is the scheme to save in the volume info, nil for the default scheme,
+// so the volumes encoded before the schemes are configurable keep the same volume info.
+func (s Scheme) ToEcShardConfig() *volume_server_pb.EcShardConfig {
+	if s.IsDefault() {
+		return nil
+	}
+	return &volume_server_pb.EcShardConfig{
+		DataShards:   uint32(s.DataShards),
+		ParityShards: uint32(s.ParityShards),
+	}
+}
+
+func SchemeOfVolumeInfo(volumeInfo *volume_server_pb.VolumeInfo) Scheme {
+	config := volumeInfo.GetEcShardConfig()
+	return NewScheme(config.GetDataShards(), config.GetParityShards())
+}
+
+// LoadScheme reads the scheme from the .vif file of the ec volume, or the default scheme without the file.
+func LoadScheme(dataBaseFileName string) (Scheme, error) {
+	volumeInfo, _, found, err := volume_info.MaybeLoadVolumeInfo(dataBaseFileName + ".vif")
+	if err != nil {
+		return Scheme{}, err
+	}
+	if !found {
+		return DefaultScheme, nil
+	}
+	return SchemeOfVolumeInfo(volumeInfo), nil
+}
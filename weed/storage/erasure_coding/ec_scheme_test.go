@@ -0,0 +1,94 @@
+package erasure_coding
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestParseScheme(t *testing.T) {
+	if scheme, err := ParseScheme("8+3"); err != nil || scheme.DataShards != 8 || scheme.ParityShards != 3 {
+		t.Errorf("parse 8+3: %v %v", scheme, err)
+	}
+	for _, s := range []string{"10", "0+4", "4+0", "a+2", "30+3"} {
+		if _, err := ParseScheme(s); err == nil {
+			t.Errorf("parse %s: expected error", s)
+		}
+	}
+	if NewScheme(0, 0) != DefaultScheme || NewScheme(4, 2).String() != "4+2" {
+		t.Errorf("new scheme")
+	}
+}
+
+func TestEncodingRebuildingWithScheme(t *testing.T) {
+	scheme := Scheme{DataShards: 4, ParityShards: 2}
+	baseFileName := filepath.Join(t.TempDir(), "1")
+	for _, ext := range []string{".dat", ".idx"} {
+		data, err := os.ReadFile("1" + ext)
+		if err != nil {
+			t.Fatalf("read 1%s: %v", ext, err)
+		}
+		os.WriteFile(baseFileName+ext, data, 0644)
+	}
+
+	if err := generateEcFiles(baseFileName, scheme, 50, largeBlockSize, smallBlockSize, util.NewWriteThrottler(0)); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if _, err := os.Stat(baseFileName + ToExt(scheme.TotalShards())); !os.IsNotExist(err) {
+		t.Fatalf("generated more than %d shards", scheme.TotalShards())
+	}
+
+	// lose as many shards as the parity shards
+	original := make(map[int][]byte)
+	for _, shardId := range []int{1, 4} {
+		original[shardId], _ = os.ReadFile(baseFileName + ToExt(shardId))
+		os.Remove(baseFileName + ToExt(shardId))
+	}
+	generated, err := generateMissingEcFiles(baseFileName, scheme, 50, largeBlockSize, smallBlockSize)
+	if err != nil || len(generated) != 2 {
+		t.Fatalf("rebuild: %v %v", generated, err)
+	}
+	for shardId, data := range original {
+		if rebuilt, _ := os.ReadFile(baseFileName + ToExt(shardId)); !bytes.Equal(rebuilt, data) {
+			t.Errorf("rebuilt shard %d differs", shardId)
+		}
+	}
+
+	// read each needle from the data shards
+	nm, err := readNeedleMap(baseFileName)
+	if err != nil {
+		t.Fatalf("read needle map: %v", err)
+	}
+	defer nm.Close()
+	datData, _ := os.ReadFile(baseFileName + ".dat")
+	ecFiles, err := openEcFiles(baseFileName, scheme, true)
+	if err != nil {
+		t.Fatalf("open ec files: %v", err)
+	}
+	defer closeEcFiles(ecFiles)
+
+	err = nm.AscendingVisit(func(value needle_map.NeedleValue) error {
+		offset := value.Offset.ToActualOffset()
+		var ecData []byte
+		for _, interval := range scheme.LocateData(largeBlockSize, smallBlockSize, int64(len(datData)), offset, value.Size) {
+			shardId, shardOffset := interval.ToShardIdAndOffset(largeBlockSize, smallBlockSize)
+			if int(shardId) >= scheme.DataShards {
+				t.Fatalf("needle %d located in parity shard %d", value.Key, shardId)
+			}
+			data := make([]byte, interval.Size)
+			ecFiles[shardId].ReadAt(data, shardOffset)
+			ecData = append(ecData, data...)
+		}
+		if !bytes.Equal(ecData, datData[offset:offset+int64(value.Size)]) {
+			t.Errorf("needle %d read from the shards differs", value.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("visit: %v", err)
+	}
+}
@@ -96,3 +96,20 @@ func (shard *EcVolumeShard) ReadAt(buf []byte, offset int64) (int, error) {
 	return shard.ecdFile.ReadAt(buf, offset)
 
 }
+
+// WriteAt overwrites the shard file, to repair the corrupted data with the data recovered from the other shards.
+func (shard *EcVolumeShard) WriteAt(buf []byte, offset int64) (int, error) {
+
+	fileName := shard.FileName() + ToExt(int(shard.ShardId))
+	f, err := os.OpenFile(fileName, os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("open ec volume shard %s: %v", fileName, err)
+	}
+	defer f.Close()
+	n, err := f.WriteAt(buf, offset)
+	if err != nil {
+		return n, err
+	}
+	return n, f.Sync()
+
+}
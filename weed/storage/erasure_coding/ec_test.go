@@ -11,6 +11,7 @@ import (
 
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
 const (
@@ -22,7 +23,7 @@ func TestEncodingDecoding(t *testing.T) {
 	bufferSize := 50
 	baseFileName := "1"
 
-	err := generateEcFiles(baseFileName, bufferSize, largeBlockSize, smallBlockSize)
+	err := generateEcFiles(baseFileName, DefaultScheme, bufferSize, largeBlockSize, smallBlockSize, util.NewWriteThrottler(0))
 	if err != nil {
 		t.Logf("generateEcFiles: %v", err)
 	}
@@ -59,7 +60,7 @@ func validateFiles(baseFileName string) error {
 		return fmt.Errorf("failed to stat dat file: %v", err)
 	}
 
-	ecFiles, err := openEcFiles(baseFileName, true)
+	ecFiles, err := openEcFiles(baseFileName, DefaultScheme, true)
 	if err != nil {
 		return fmt.Errorf("error opening ec files: %w", err)
 	}
@@ -194,7 +195,7 @@ func TestLocateData(t *testing.T) {
 	if len(intervals) != 1 {
 		t.Errorf("unexpected interval size %d", len(intervals))
 	}
-	if !intervals[0].sameAs(Interval{0, 0, 1, false, 1}) {
+	if !intervals[0].sameAs(Interval{0, 0, 1, false, 1, DataShardsCount}) {
 		t.Errorf("unexpected interval %+v", intervals[0])
 	}
 
@@ -39,6 +39,9 @@ type EcVolume struct {
 	ecjFile                   *os.File
 	ecjFileAccessLock         sync.Mutex
 	diskType                  types.DiskType
+	Scheme                    Scheme
+	IsDeduplicated            bool                     // the needles refer to the blob needles with the contents
+	ChecksumAlgorithm         needle.ChecksumAlgorithm // of the new needles, once decoded back to a volume
 }
 
 func NewEcVolume(diskType types.DiskType, dir string, dirIdx string, collection string, vid needle.VolumeId) (ev *EcVolume, err error) {
@@ -66,8 +69,16 @@ func NewEcVolume(diskType types.DiskType, dir string, dirIdx string, collection
 
 	// read volume info
 	ev.Version = needle.Version3
+	ev.Scheme = DefaultScheme
 	if volumeInfo, _, found, _ := volume_info.MaybeLoadVolumeInfo(dataBaseFileName + ".vif"); found {
 		ev.Version = needle.Version(volumeInfo.Version)
+		ev.Scheme = SchemeOfVolumeInfo(volumeInfo)
+		ev.IsDeduplicated = volumeInfo.Deduplicated
+		if ev.ChecksumAlgorithm, err = needle.ParseChecksumAlgorithm(volumeInfo.ChecksumAlgorithm); err != nil {
+			_ = ev.ecxFile.Close()
+			_ = ev.ecjFile.Close()
+			return nil, fmt.Errorf("ec volume %d: %v", vid, err)
+		}
 	} else {
 		volume_info.SaveVolumeInfo(dataBaseFileName+".vif", &volume_server_pb.VolumeInfo{Version: uint32(ev.Version)})
 	}
@@ -196,9 +207,11 @@ func (ev *EcVolume) ToVolumeEcShardInformationMessage() (messages []*master_pb.V
 	for _, s := range ev.Shards {
 		if s.VolumeId != prevVolumeId {
 			m = &master_pb.VolumeEcShardInformationMessage{
-				Id:         uint32(s.VolumeId),
-				Collection: s.Collection,
-				DiskType:   string(ev.diskType),
+				Id:           uint32(s.VolumeId),
+				Collection:   s.Collection,
+				DiskType:     string(ev.diskType),
+				DataShards:   uint32(ev.Scheme.DataShards),
+				ParityShards: uint32(ev.Scheme.ParityShards),
 			}
 			messages = append(messages, m)
 		}
@@ -223,11 +236,16 @@ func (ev *EcVolume) LocateEcShardNeedle(needleId types.NeedleId, version needle.
 func (ev *EcVolume) LocateEcShardNeedleInterval(version needle.Version, offset int64, size types.Size) (intervals []Interval) {
 	shard := ev.Shards[0]
 	// calculate the locations in the ec shards
-	intervals = LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, DataShardsCount*shard.ecdFileSize, offset, types.Size(needle.GetActualSize(size, version)))
+	intervals = ev.Scheme.LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, int64(ev.Scheme.DataShards)*shard.ecdFileSize, offset, types.Size(needle.GetActualSize(size, version)))
 
 	return
 }
 
+// WalkIndex visits the entries of the sorted .ecx file, including the deleted ones.
+func (ev *EcVolume) WalkIndex(fn func(key types.NeedleId, offset types.Offset, size types.Size) error) error {
+	return idx.WalkIndexFile(ev.ecxFile, 0, fn)
+}
+
 func (ev *EcVolume) FindNeedleFromEcx(needleId types.NeedleId) (offset types.Offset, size types.Size, err error) {
 	return SearchNeedleFromSortedIndex(ev.ecxFile, ev.ecxFileSize, needleId, nil)
 }
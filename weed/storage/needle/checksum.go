@@ -0,0 +1,100 @@
+package needle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+// ChecksumAlgorithm checksums the needle data. The default of the new needles is chosen for each new volume,
+// and kept in the .vif file. Each version 3 needle keeps the id of its algorithm, so the needles can be
+// compacted, tailed or copied to the volumes of another algorithm as is.
+type ChecksumAlgorithm uint8
+
+const (
+	ChecksumCrc32c   ChecksumAlgorithm = iota // hardware accelerated on most cpus, the default
+	ChecksumXxHash64                          // the lower 32 bits of xxHash64, faster on large data
+)
+
+func ParseChecksumAlgorithm(name string) (ChecksumAlgorithm, error) {
+	switch strings.ToLower(name) {
+	case "", "crc32c":
+		return ChecksumCrc32c, nil
+	case "xxhash64":
+		return ChecksumXxHash64, nil
+	}
+	return ChecksumCrc32c, fmt.Errorf("unknown checksum algorithm %q", name)
+}
+
+func (a ChecksumAlgorithm) String() string {
+	switch a {
+	case ChecksumXxHash64:
+		return "xxhash64"
+	}
+	return "crc32c"
+}
+
+// The first padding byte of the version 3 needles always repeated the top byte of the needle size.
+// The id of the checksum algorithm is stored xor'ed with it, so the needles written before read as crc32c.
+func checksumAlgorithmPadding(a ChecksumAlgorithm, size Size) byte {
+	return byte(a) ^ byte(uint32(size)>>24)
+}
+
+// readChecksumAlgorithm reads the checksum algorithm from the tail of the needle, from the checksum to the padding
+func readChecksumAlgorithm(tail []byte, size Size, version Version) ChecksumAlgorithm {
+	paddingIndex := NeedleChecksumSize + TimestampSize
+	if version != Version3 || len(tail) <= paddingIndex {
+		return ChecksumCrc32c
+	}
+	return ChecksumAlgorithm(tail[paddingIndex] ^ byte(uint32(size)>>24))
+}
+
+func (a ChecksumAlgorithm) Sum(b []byte) CRC {
+	switch a {
+	case ChecksumXxHash64:
+		return CRC(xxhash.Sum64(b))
+	}
+	return NewCRC(b)
+}
+
+// Checksummer computes the checksum of the needle data written in pieces
+type Checksummer interface {
+	Write(p []byte) (int, error)
+	Sum() CRC
+}
+
+func (a ChecksumAlgorithm) NewChecksummer() Checksummer {
+	switch a {
+	case ChecksumXxHash64:
+		return &xxHashChecksummer{xxhash.New()}
+	}
+	return &crcChecksummer{}
+}
+
+type crcChecksummer struct {
+	crc CRC
+}
+
+func (c *crcChecksummer) Write(p []byte) (int, error) {
+	c.crc = c.crc.Update(p)
+	return len(p), nil
+}
+
+func (c *crcChecksummer) Sum() CRC {
+	return c.crc
+}
+
+type xxHashChecksummer struct {
+	digest *xxhash.Digest
+}
+
+func (c *xxHashChecksummer) Write(p []byte) (int, error) {
+	return c.digest.Write(p)
+}
+
+func (c *xxHashChecksummer) Sum() CRC {
+	return CRC(c.digest.Sum64())
+}
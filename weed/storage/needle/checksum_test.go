@@ -0,0 +1,46 @@
+package needle
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func TestChecksummer(t *testing.T) {
+	data := make([]byte, 10000)
+	rand.Read(data)
+	for _, algorithm := range []ChecksumAlgorithm{ChecksumCrc32c, ChecksumXxHash64} {
+		checksummer := algorithm.NewChecksummer()
+		checksummer.Write(data[:777])
+		checksummer.Write(data[777:])
+		if checksummer.Sum() != algorithm.Sum(data) {
+			t.Errorf("%v checksum of the pieces differs", algorithm)
+		}
+	}
+}
+
+func TestChecksumAlgorithmInPadding(t *testing.T) {
+	for _, algorithm := range []ChecksumAlgorithm{ChecksumCrc32c, ChecksumXxHash64} {
+		n := &Needle{Id: 0x1234, Cookie: 0x5678, Data: []byte("some data"), ChecksumAlgorithm: algorithm}
+		n.Checksum = algorithm.Sum(n.Data)
+		var buf bytes.Buffer
+		if _, _, err := n.prepareWriteBuffer(Version3, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		read := &Needle{}
+		if err := read.ReadBytes(buf.Bytes(), 0, n.Size, Version3); err != nil {
+			t.Fatalf("%v: %v", algorithm, err)
+		}
+		if read.ChecksumAlgorithm != algorithm || read.Checksum != n.Checksum {
+			t.Errorf("%v read as %v", algorithm, read.ChecksumAlgorithm)
+		}
+
+		paddingIndex := types.NeedleHeaderSize + int(n.Size) + NeedleChecksumSize + types.TimestampSize
+		if algorithm == ChecksumCrc32c && buf.Bytes()[paddingIndex] != byte(uint32(n.Size)>>24) {
+			t.Errorf("the crc32c padding differs from the needles written before")
+		}
+	}
+}
@@ -42,6 +42,8 @@ type Needle struct {
 	Checksum   CRC    `comment:"CRC32 to check integrity"`
 	AppendAtNs uint64 `comment:"append timestamp in nano seconds"` //version3
 	Padding    []byte `comment:"Aligned to 8 bytes"`
+
+	ChecksumAlgorithm ChecksumAlgorithm // in the first padding byte of version 3
 }
 
 func (n *Needle) String() (str string) {
@@ -49,9 +51,9 @@ func (n *Needle) String() (str string) {
 	return
 }
 
-func CreateNeedleFromRequest(r *http.Request, fixJpgOrientation bool, sizeLimit int64, bytesBuffer *bytes.Buffer) (n *Needle, originalSize int, contentMd5 string, e error) {
+func CreateNeedleFromRequest(r *http.Request, fixJpgOrientation bool, sizeLimit int64, bytesBuffer *bytes.Buffer, compressWithZstd bool, checksumAlgorithm ChecksumAlgorithm) (n *Needle, originalSize int, contentMd5 string, e error) {
 	n = new(Needle)
-	pu, e := ParseUpload(r, sizeLimit, bytesBuffer)
+	pu, e := parseUpload(r, sizeLimit, bytesBuffer, compressWithZstd)
 	if e != nil {
 		return
 	}
@@ -82,10 +84,13 @@ func CreateNeedleFromRequest(r *http.Request, fixJpgOrientation bool, sizeLimit
 			n.SetHasPairs()
 		}
 	}
-	if pu.IsGzipped {
+	if pu.IsGzipped || pu.IsZstd {
 		// println(r.URL.Path, "is set to compressed", pu.FileName, pu.IsGzipped, "dataSize", pu.OriginalDataSize)
 		n.SetIsCompressed()
 	}
+	if pu.IsZstd {
+		n.SetIsZstd()
+	}
 	if n.LastModified == 0 {
 		n.LastModified = uint64(time.Now().Unix())
 	}
@@ -105,7 +110,8 @@ func CreateNeedleFromRequest(r *http.Request, fixJpgOrientation bool, sizeLimit
 		}
 	}
 
-	n.Checksum = NewCRC(n.Data)
+	n.ChecksumAlgorithm = checksumAlgorithm
+	n.Checksum = checksumAlgorithm.Sum(n.Data)
 
 	commaSep := strings.LastIndex(r.URL.Path, ",")
 	dotSep := strings.LastIndex(r.URL.Path, ".")
@@ -18,13 +18,13 @@ import (
 )
 
 type ParsedUpload struct {
-	FileName    string
-	Data        []byte
-	bytesBuffer *bytes.Buffer
-	MimeType    string
-	PairMap     map[string]string
-	IsGzipped   bool
-	// IsZstd           bool
+	FileName         string
+	Data             []byte
+	bytesBuffer      *bytes.Buffer
+	MimeType         string
+	PairMap          map[string]string
+	IsGzipped        bool
+	IsZstd           bool
 	OriginalDataSize int
 	ModifiedTime     uint64
 	Ttl              *TTL
@@ -34,6 +34,12 @@ type ParsedUpload struct {
 }
 
 func ParseUpload(r *http.Request, sizeLimit int64, bytesBuffer *bytes.Buffer) (pu *ParsedUpload, e error) {
+	return parseUpload(r, sizeLimit, bytesBuffer, false)
+}
+
+// parseUpload compresses the uncompressed data of compressable types with gzip,
+// or with zstd if compressWithZstd, which also tries the data of unknown types.
+func parseUpload(r *http.Request, sizeLimit int64, bytesBuffer *bytes.Buffer, compressWithZstd bool) (pu *ParsedUpload, e error) {
 	bytesBuffer.Reset()
 	pu = &ParsedUpload{bytesBuffer: bytesBuffer}
 	pu.PairMap = make(map[string]string)
@@ -58,7 +64,7 @@ func ParseUpload(r *http.Request, sizeLimit int64, bytesBuffer *bytes.Buffer) (p
 	pu.OriginalDataSize = len(pu.Data)
 	pu.UncompressedData = pu.Data
 	// println("received data", len(pu.Data), "isGzipped", pu.IsGzipped, "mime", pu.MimeType, "name", pu.FileName)
-	if pu.IsGzipped {
+	if pu.IsGzipped || pu.IsZstd {
 		if unzipped, e := util.DecompressData(pu.Data); e == nil {
 			pu.OriginalDataSize = len(unzipped)
 			pu.UncompressedData = unzipped
@@ -74,7 +80,15 @@ func ParseUpload(r *http.Request, sizeLimit int64, bytesBuffer *bytes.Buffer) (p
 		if mimeType == "application/octet-stream" {
 			mimeType = ""
 		}
-		if shouldBeCompressed, iAmSure := util.IsCompressableFileType(ext, mimeType); shouldBeCompressed && iAmSure {
+		shouldBeCompressed, iAmSure := util.IsCompressableFileType(ext, mimeType)
+		if compressWithZstd && (shouldBeCompressed || !iAmSure) {
+			if compressedData, err := util.ZstdData(pu.Data); err == nil {
+				if len(compressedData)*10 < len(pu.Data)*9 {
+					pu.Data = compressedData
+					pu.IsZstd = true
+				}
+			}
+		} else if shouldBeCompressed && iAmSure {
 			// println("ext", ext, "iAmSure", iAmSure, "shouldBeCompressed", shouldBeCompressed, "mimeType", pu.MimeType)
 			if compressedData, err := util.GzipData(pu.Data); err == nil {
 				if len(compressedData)*10 < len(pu.Data)*9 {
@@ -102,7 +116,7 @@ func ParseUpload(r *http.Request, sizeLimit int64, bytesBuffer *bytes.Buffer) (p
 
 func parsePut(r *http.Request, sizeLimit int64, pu *ParsedUpload) error {
 	pu.IsGzipped = r.Header.Get("Content-Encoding") == "gzip"
-	// pu.IsZstd = r.Header.Get("Content-Encoding") == "zstd"
+	pu.IsZstd = r.Header.Get("Content-Encoding") == "zstd"
 	pu.MimeType = r.Header.Get("Content-Type")
 	pu.FileName = ""
 	dataSize, err := pu.bytesBuffer.ReadFrom(io.LimitReader(r.Body, sizeLimit+1))
@@ -201,7 +215,7 @@ func parseMultipart(r *http.Request, sizeLimit int64, pu *ParsedUpload) (e error
 
 	}
 	pu.IsGzipped = part.Header.Get("Content-Encoding") == "gzip"
-	// pu.IsZstd = part.Header.Get("Content-Encoding") == "zstd"
+	pu.IsZstd = part.Header.Get("Content-Encoding") == "zstd"
 
 	return
 }
@@ -0,0 +1,45 @@
+package needle
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestCreateNeedleWithZstd(t *testing.T) {
+	text := strings.Repeat("the quick brown fox jumps over the lazy dog\n", 100)
+
+	newRequest := func(data []byte, mimeType string) *http.Request {
+		r, _ := http.NewRequest("PUT", "http://localhost:8080/3,01637037d6", bytes.NewReader(data))
+		r.Header.Set("Content-Type", mimeType)
+		return r
+	}
+
+	n, originalSize, _, err := CreateNeedleFromRequest(newRequest([]byte(text), "text/plain"), false, 1024*1024, &bytes.Buffer{}, true, ChecksumCrc32c)
+	if err != nil {
+		t.Fatalf("create needle: %v", err)
+	}
+	if !n.IsCompressed() || !n.IsZstd() || !util.IsZstdContent(n.Data) {
+		t.Fatalf("text is not compressed with zstd, flags %x", n.Flags)
+	}
+	if originalSize != len(text) || len(n.Data) >= len(text) {
+		t.Fatalf("original size %d, compressed size %d", originalSize, len(n.Data))
+	}
+	if n.Compression() != "zstd" {
+		t.Fatalf("compression %s", n.Compression())
+	}
+	if data, err := util.DecompressData(n.Data); err != nil || string(data) != text {
+		t.Fatalf("decompress: %v", err)
+	}
+
+	n, _, _, err = CreateNeedleFromRequest(newRequest([]byte(text), "image/jpeg"), false, 1024*1024, &bytes.Buffer{}, true, ChecksumCrc32c)
+	if err != nil {
+		t.Fatalf("create needle: %v", err)
+	}
+	if n.IsCompressed() || n.IsZstd() {
+		t.Fatalf("compressed image is compressed again, flags %x", n.Flags)
+	}
+}
@@ -18,6 +18,7 @@ const (
 	FlagHasLastModifiedDate = 0x08
 	FlagHasTtl              = 0x10
 	FlagHasPairs            = 0x20
+	FlagIsZstd              = 0x40
 	FlagIsChunkManifest     = 0x80
 	LastModifiedBytesLength = 5
 	TtlBytesLength          = 2
@@ -70,9 +71,10 @@ func (n *Needle) ReadBytes(bytes []byte, offset int64, size Size, version Versio
 	if err != nil && err != io.EOF {
 		return err
 	}
+	n.ChecksumAlgorithm = readChecksumAlgorithm(bytes[NeedleHeaderSize+size:], size, version)
 	if size > 0 {
 		checksum := util.BytesToUint32(bytes[NeedleHeaderSize+size : NeedleHeaderSize+size+NeedleChecksumSize])
-		newChecksum := NewCRC(n.Data)
+		newChecksum := n.ChecksumAlgorithm.Sum(n.Data)
 		if checksum != newChecksum.Value() && checksum != uint32(newChecksum) {
 			// the crc.Value() function is to be deprecated. this double checking is for backward compatible.
 			stats.VolumeServerRequestCounter.WithLabelValues(stats.ErrorCRC).Inc()
@@ -232,13 +234,14 @@ func (n *Needle) ReadNeedleBodyBytes(needleBody []byte, version Version) (err er
 	if len(needleBody) <= 0 {
 		return nil
 	}
+	// keep the checksum as written, with the algorithm of the needle, e.g. when compacted or tailed
+	n.Checksum = CRC(util.BytesToUint32(needleBody[n.Size : n.Size+NeedleChecksumSize]))
+	n.ChecksumAlgorithm = readChecksumAlgorithm(needleBody[n.Size:], n.Size, version)
 	switch version {
 	case Version1:
 		n.Data = needleBody[:n.Size]
-		n.Checksum = NewCRC(n.Data)
 	case Version2, Version3:
 		err = n.readNeedleDataVersion2(needleBody[0:n.Size])
-		n.Checksum = NewCRC(n.Data)
 
 		if version == Version3 {
 			tsOffset := n.Size + NeedleChecksumSize
@@ -256,6 +259,20 @@ func (n *Needle) IsCompressed() bool {
 func (n *Needle) SetIsCompressed() {
 	n.Flags = n.Flags | FlagIsCompressed
 }
+func (n *Needle) IsZstd() bool {
+	return n.Flags&FlagIsZstd > 0
+}
+func (n *Needle) SetIsZstd() {
+	n.Flags = n.Flags | FlagIsZstd
+}
+
+// Compression is the codec of the compressed data, "zstd" or "gzip"
+func (n *Needle) Compression() string {
+	if n.IsZstd() || util.IsZstdContent(n.Data) {
+		return "zstd"
+	}
+	return "gzip"
+}
 func (n *Needle) HasName() bool {
 	return n.Flags&FlagHasName > 0
 }
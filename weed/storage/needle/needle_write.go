@@ -103,6 +103,7 @@ func (n *Needle) prepareWriteBuffer(version Version, writeBytes *bytes.Buffer) (
 		} else {
 			// version3
 			util.Uint64toBytes(header[NeedleChecksumSize:NeedleChecksumSize+TimestampSize], n.AppendAtNs)
+			header[NeedleChecksumSize+TimestampSize] = checksumAlgorithmPadding(n.ChecksumAlgorithm, n.Size)
 			writeBytes.Write(header[0 : NeedleChecksumSize+TimestampSize+padding])
 		}
 
@@ -144,6 +145,16 @@ func (n *Needle) Append(w backend.BackendStorageFile, version Version) (offset u
 	return offset, size, actualSize, err
 }
 
+// ToBytes returns the needle as appended to the volume, after n.Append
+func (n *Needle) ToBytes(version Version) ([]byte, error) {
+	bytesBuffer := bufPool.Get().(*bytes.Buffer)
+	defer bufPool.Put(bytesBuffer)
+	if _, _, err := n.prepareWriteBuffer(version, bytesBuffer); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), bytesBuffer.Bytes()...), nil
+}
+
 func WriteNeedleBlob(w backend.BackendStorageFile, dataSlice []byte, size Size, appendAtNs uint64, version Version) (offset uint64, err error) {
 
 	if end, _, e := w.GetStat(); e == nil {
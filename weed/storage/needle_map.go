@@ -1,14 +1,15 @@
 package storage
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"sync"
 
+	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/storage/idx"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
 	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
-	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
 type NeedleMapKind int
@@ -18,6 +19,7 @@ const (
 	NeedleMapLevelDb                     // small memory footprint, 4MB total, 1 write buffer, 3 block buffer
 	NeedleMapLevelDbMedium               // medium memory footprint, 8MB total, 3 write buffer, 5 block buffer
 	NeedleMapLevelDbLarge                // large memory footprint, 12MB total, 4write buffer, 8 block buffer
+	NeedleMapPebble                      // blocks cached in the cache shared by all volumes, see SetPebbleCacheSize
 )
 
 type NeedleMapper interface {
@@ -47,7 +49,48 @@ type baseNeedleMapper struct {
 type TempNeedleMapper interface {
 	NeedleMapper
 	DoOffsetLoading(v *Volume, indexFile *os.File, startFrom uint64) error
-	UpdateNeedleMap(v *Volume, indexFile *os.File, opts *opt.Options, ldbTimeout int64) error
+	UpdateNeedleMap(v *Volume, indexFile *os.File) error
+}
+
+// loadNeedleMap loads the needle map of the kind from the index file
+func (v *Volume) loadNeedleMap(kind NeedleMapKind, indexFile *os.File) (NeedleMapper, error) {
+	switch kind {
+	case NeedleMapInMemory:
+		glog.V(0).Infoln("loading memory index", v.FileName(".idx"), "to memory")
+		nm, err := LoadCompactNeedleMap(indexFile)
+		if err != nil {
+			return nil, err
+		}
+		return nm, nil
+	case NeedleMapLevelDb, NeedleMapLevelDbMedium, NeedleMapLevelDbLarge:
+		glog.V(0).Infoln("loading leveldb index", v.FileName(".ldb"))
+		nm, err := NewLevelDbNeedleMap(v.FileName(".ldb"), indexFile, levelDbOptions(kind), v.ldbTimeout)
+		if err != nil {
+			return nil, err
+		}
+		return nm, nil
+	case NeedleMapPebble:
+		glog.V(0).Infoln("loading pebble index", v.FileName(".pdb"))
+		nm, err := NewPebbleNeedleMap(v.FileName(".pdb"), indexFile)
+		if err != nil {
+			return nil, err
+		}
+		return nm, nil
+	}
+	return nil, fmt.Errorf("unknown needle map kind %d", kind)
+}
+
+// newTempNeedleMapper creates the needle map of the kind to load the compacted index file
+func (v *Volume) newTempNeedleMapper(kind NeedleMapKind) TempNeedleMapper {
+	switch kind {
+	case NeedleMapLevelDb, NeedleMapLevelDbMedium, NeedleMapLevelDbLarge:
+		return &LevelDbNeedleMap{dbFileName: v.FileName(".ldb")}
+	case NeedleMapPebble:
+		return &PebbleNeedleMap{dbFileName: v.FileName(".pdb")}
+	}
+	return &NeedleMap{
+		m: needle_map.NewCompactMap(),
+	}
 }
 
 func (nm *baseNeedleMapper) IndexFileSize() uint64 {
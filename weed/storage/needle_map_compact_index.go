@@ -0,0 +1,341 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The .cdx file is a denser encoding of the live entries of the .idx file, for the read only volumes.
+// The entries are sorted by key, and grouped into blocks of compactIndexBlockSize entries.
+// Each entry is the uvarint key delta from the previous entry of the block,
+// followed by the offset in padding units and the size, each in as few bytes as the volume needs.
+// The header keeps the volume metrics and the .idx file size, so loading it needs no walk of the .idx file.
+
+const (
+	compactIndexMagic      = "CDX"
+	compactIndexVersion    = 2
+	compactIndexHeaderSize = 56
+	compactIndexBlockSize  = 64
+	// the first key and the data offset of each block
+	compactIndexBlockEntrySize = NeedleIdSize + 4
+)
+
+type compactIndexHeader struct {
+	offsetWidth int
+	sizeWidth   int
+	entryCount  uint64
+	idxFileSize uint64
+	metric      mapMetric
+}
+
+type compactIndexBlock struct {
+	firstKey   NeedleId
+	dataOffset uint32
+}
+
+type CompactIndexNeedleMap struct {
+	baseNeedleMapper
+	baseFileName string
+	header       compactIndexHeader
+	blocks       []compactIndexBlock
+	data         []byte // the encoded entries
+	deleted      map[NeedleId]struct{}
+	deletedLock  sync.RWMutex
+}
+
+func NewCompactIndexNeedleMap(indexBaseFileName string, indexFile *os.File) (m *CompactIndexNeedleMap, err error) {
+	m = &CompactIndexNeedleMap{baseFileName: indexBaseFileName, deleted: make(map[NeedleId]struct{})}
+	m.indexFile = indexFile
+	if stat, statErr := indexFile.Stat(); statErr == nil {
+		m.indexFileOffset = stat.Size()
+	}
+	fileName := indexBaseFileName + ".cdx"
+	if !IsCompactIndexFresh(indexBaseFileName) {
+		glog.V(0).Infof("Start to Generate %s from %s", fileName, indexFile.Name())
+		if err = WriteCompactIndexFromIdx(indexBaseFileName); err != nil {
+			return nil, err
+		}
+		glog.V(0).Infof("Finished Generating %s from %s", fileName, indexFile.Name())
+	}
+	glog.V(1).Infof("Loading %s...", fileName)
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if m.header, m.blocks, m.data, err = parseCompactIndex(data); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", fileName, err)
+	}
+	m.mapMetric = m.header.metric
+	return m, nil
+}
+
+// IsCompactIndexFresh is true if the .cdx file is generated from the current .idx file
+func IsCompactIndexFresh(indexBaseFileName string) bool {
+	cdxFile, err := os.Open(indexBaseFileName + ".cdx")
+	if err != nil {
+		return false
+	}
+	defer cdxFile.Close()
+	headerBytes := make([]byte, compactIndexHeaderSize)
+	if _, err = cdxFile.ReadAt(headerBytes, 0); err != nil {
+		return false
+	}
+	header, err := parseCompactIndexHeader(headerBytes)
+	if err != nil {
+		return false
+	}
+	cdxStat, cdxStatErr := cdxFile.Stat()
+	idxStat, idxStatErr := os.Stat(indexBaseFileName + ".idx")
+	if cdxStatErr != nil || idxStatErr != nil {
+		return false
+	}
+	return uint64(idxStat.Size()) == header.idxFileSize && !cdxStat.ModTime().Before(idxStat.ModTime())
+}
+
+// WriteCompactIndexFromIdx generates the .cdx file from the live entries of the .idx file
+func WriteCompactIndexFromIdx(indexBaseFileName string) (err error) {
+	indexFile, err := os.OpenFile(indexBaseFileName+".idx", os.O_RDONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer indexFile.Close()
+	stat, err := indexFile.Stat()
+	if err != nil {
+		return err
+	}
+	header := compactIndexHeader{idxFileSize: uint64(stat.Size())}
+	mm, err := newNeedleMapMetricFromIndexFile(indexFile)
+	if err != nil {
+		return fmt.Errorf("read metrics of %s: %v", indexFile.Name(), err)
+	}
+	header.metric = *mm
+
+	db := needle_map.NewMemDb()
+	defer db.Close()
+	if err = db.LoadFromReaderAt(indexFile); err != nil {
+		return fmt.Errorf("load %s: %v", indexFile.Name(), err)
+	}
+	var maxOffsetUnits uint64
+	var maxSize Size
+	db.AscendingVisit(func(value needle_map.NeedleValue) error {
+		header.entryCount++
+		if units := offsetToUnits(value.Offset); units > maxOffsetUnits {
+			maxOffsetUnits = units
+		}
+		if value.Size > maxSize {
+			maxSize = value.Size
+		}
+		return nil
+	})
+	header.offsetWidth, header.sizeWidth = byteWidth(maxOffsetUnits), byteWidth(uint64(maxSize))
+
+	var blocks []compactIndexBlock
+	var data []byte
+	var previousKey NeedleId
+	entry := make([]byte, binary.MaxVarintLen64+8+8)
+	var i uint64
+	err = db.AscendingVisit(func(value needle_map.NeedleValue) error {
+		if i%compactIndexBlockSize == 0 {
+			blocks = append(blocks, compactIndexBlock{firstKey: value.Key, dataOffset: uint32(len(data))})
+			previousKey = value.Key
+		}
+		i++
+		n := binary.PutUvarint(entry, uint64(value.Key-previousKey))
+		putUintWidth(entry[n:], offsetToUnits(value.Offset), header.offsetWidth)
+		n += header.offsetWidth
+		putUintWidth(entry[n:], uint64(value.Size), header.sizeWidth)
+		n += header.sizeWidth
+		data = append(data, entry[:n]...)
+		previousKey = value.Key
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, compactIndexHeaderSize, compactIndexHeaderSize+len(blocks)*compactIndexBlockEntrySize+len(data))
+	header.toBytes(b)
+	blockEntry := make([]byte, compactIndexBlockEntrySize)
+	for _, block := range blocks {
+		NeedleIdToBytes(blockEntry[:NeedleIdSize], block.firstKey)
+		util.Uint32toBytes(blockEntry[NeedleIdSize:], block.dataOffset)
+		b = append(b, blockEntry...)
+	}
+	b = append(b, data...)
+	return util.WriteFile(indexBaseFileName+".cdx", b, 0644)
+}
+
+func (h *compactIndexHeader) toBytes(b []byte) {
+	copy(b, compactIndexMagic)
+	b[3] = compactIndexVersion
+	b[4], b[5] = byte(h.offsetWidth), byte(h.sizeWidth)
+	util.Uint64toBytes(b[8:16], h.entryCount)
+	util.Uint64toBytes(b[16:24], h.idxFileSize)
+	util.Uint32toBytes(b[24:28], h.metric.FileCounter)
+	util.Uint32toBytes(b[28:32], h.metric.DeletionCounter)
+	util.Uint64toBytes(b[32:40], h.metric.FileByteCounter)
+	util.Uint64toBytes(b[40:48], h.metric.DeletionByteCounter)
+	util.Uint64toBytes(b[48:56], h.metric.MaximumFileKey)
+}
+
+func parseCompactIndexHeader(b []byte) (h compactIndexHeader, err error) {
+	if len(b) < compactIndexHeaderSize || string(b[:3]) != compactIndexMagic {
+		return h, fmt.Errorf("not a compact index")
+	}
+	if b[3] != compactIndexVersion {
+		return h, fmt.Errorf("unsupported compact index version %d", b[3])
+	}
+	h.offsetWidth, h.sizeWidth = int(b[4]), int(b[5])
+	if h.offsetWidth > 8 || h.sizeWidth > 8 {
+		return h, fmt.Errorf("unexpected entry widths %d and %d", h.offsetWidth, h.sizeWidth)
+	}
+	h.entryCount = util.BytesToUint64(b[8:16])
+	h.idxFileSize = util.BytesToUint64(b[16:24])
+	h.metric.FileCounter = util.BytesToUint32(b[24:28])
+	h.metric.DeletionCounter = util.BytesToUint32(b[28:32])
+	h.metric.FileByteCounter = util.BytesToUint64(b[32:40])
+	h.metric.DeletionByteCounter = util.BytesToUint64(b[40:48])
+	h.metric.MaximumFileKey = util.BytesToUint64(b[48:56])
+	return h, nil
+}
+
+func parseCompactIndex(b []byte) (h compactIndexHeader, blocks []compactIndexBlock, data []byte, err error) {
+	if h, err = parseCompactIndexHeader(b); err != nil {
+		return
+	}
+	blockCount := (h.entryCount + compactIndexBlockSize - 1) / compactIndexBlockSize
+	dataStart := compactIndexHeaderSize + blockCount*compactIndexBlockEntrySize
+	if uint64(len(b)) < dataStart {
+		return h, nil, nil, fmt.Errorf("truncated block index")
+	}
+	blocks = make([]compactIndexBlock, blockCount)
+	for i := range blocks {
+		entry := b[compactIndexHeaderSize+i*compactIndexBlockEntrySize:]
+		blocks[i].firstKey = BytesToNeedleId(entry[:NeedleIdSize])
+		blocks[i].dataOffset = util.BytesToUint32(entry[NeedleIdSize:compactIndexBlockEntrySize])
+	}
+	// copy the entries, so the file content can be released
+	data = append([]byte(nil), b[dataStart:]...)
+	return h, blocks, data, nil
+}
+
+// search finds the block by its first key, and decodes the entries of the block up to the key
+func (m *CompactIndexNeedleMap) search(key NeedleId) (offset Offset, size Size, found bool) {
+	i := sort.Search(len(m.blocks), func(i int) bool {
+		return m.blocks[i].firstKey > key
+	}) - 1
+	if i < 0 {
+		return Offset{}, TombstoneFileSize, false
+	}
+	entryCount := compactIndexBlockSize
+	if i == len(m.blocks)-1 {
+		entryCount = int(m.header.entryCount - uint64(i)*compactIndexBlockSize)
+	}
+	data := m.data[m.blocks[i].dataOffset:]
+	k := m.blocks[i].firstKey
+	for j := 0; j < entryCount; j++ {
+		delta, n := binary.Uvarint(data)
+		if n <= 0 {
+			return Offset{}, TombstoneFileSize, false
+		}
+		k += NeedleId(delta)
+		if k > key {
+			break
+		}
+		data = data[n:]
+		if k == key {
+			units := getUintWidth(data, m.header.offsetWidth)
+			size = Size(getUintWidth(data[m.header.offsetWidth:], m.header.sizeWidth))
+			return unitsToOffset(units), size, true
+		}
+		data = data[m.header.offsetWidth+m.header.sizeWidth:]
+	}
+	return Offset{}, TombstoneFileSize, false
+}
+
+func (m *CompactIndexNeedleMap) isDeleted(key NeedleId) bool {
+	m.deletedLock.RLock()
+	defer m.deletedLock.RUnlock()
+	_, found := m.deleted[key]
+	return found
+}
+
+func (m *CompactIndexNeedleMap) Get(key NeedleId) (element *needle_map.NeedleValue, ok bool) {
+	offset, size, found := m.search(key)
+	if found && m.isDeleted(key) {
+		size = -size
+	}
+	return &needle_map.NeedleValue{Key: key, Offset: offset, Size: size}, found
+}
+
+func (m *CompactIndexNeedleMap) Put(key NeedleId, offset Offset, size Size) error {
+	return os.ErrInvalid
+}
+
+func (m *CompactIndexNeedleMap) Delete(key NeedleId, offset Offset) error {
+	_, size, found := m.search(key)
+	if !found || m.isDeleted(key) {
+		return nil
+	}
+	// write to index file first
+	if err := m.appendToIndexFile(key, offset, TombstoneFileSize); err != nil {
+		return err
+	}
+	m.deletedLock.Lock()
+	m.deleted[key] = struct{}{}
+	m.deletedLock.Unlock()
+	m.logDelete(size)
+	return nil
+}
+
+func (m *CompactIndexNeedleMap) Close() {
+	if m == nil {
+		return
+	}
+	if m.indexFile != nil {
+		m.indexFile.Close()
+	}
+}
+
+func (m *CompactIndexNeedleMap) Destroy() error {
+	m.Close()
+	os.Remove(m.indexFile.Name())
+	return os.Remove(m.baseFileName + ".cdx")
+}
+
+func offsetToUnits(offset Offset) uint64 {
+	return uint64(offset.ToActualOffset() / NeedlePaddingSize)
+}
+
+func unitsToOffset(units uint64) Offset {
+	return ToOffset(int64(units) * NeedlePaddingSize)
+}
+
+// byteWidth is the number of bytes to keep the value, at least one
+func byteWidth(value uint64) (width int) {
+	for width = 1; width < 8 && value>>(8*width) != 0; width++ {
+	}
+	return
+}
+
+func putUintWidth(b []byte, value uint64, width int) {
+	for i := width - 1; i >= 0; i-- {
+		b[i] = byte(value)
+		value >>= 8
+	}
+}
+
+func getUintWidth(b []byte, width int) (value uint64) {
+	for i := 0; i < width; i++ {
+		value = value<<8 | uint64(b[i])
+	}
+	return
+}
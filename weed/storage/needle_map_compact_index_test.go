@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func TestCompactIndexNeedleMap(t *testing.T) {
+	baseFileName := filepath.Join(t.TempDir(), "1")
+	indexFile, err := os.OpenFile(baseFileName+".idx", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+	nm, err := LoadCompactNeedleMap(indexFile)
+	if err != nil {
+		t.Fatalf("load index: %v", err)
+	}
+	// spread the keys over several blocks, with gaps
+	for i := 1; i <= 1000; i++ {
+		key := NeedleId(i * 7)
+		if err := nm.Put(key, ToOffset(int64(i)*4096), Size(100+i)); err != nil {
+			t.Fatalf("put %d: %v", key, err)
+		}
+	}
+	for i := 1; i <= 1000; i += 10 {
+		if err := nm.Delete(NeedleId(i*7), ToOffset(int64(i)*4096)); err != nil {
+			t.Fatalf("delete %d: %v", i*7, err)
+		}
+	}
+	// the same metrics as the sorted index of the read only volume
+	mm, err := newNeedleMapMetricFromIndexFile(indexFile)
+	if err != nil {
+		t.Fatalf("index metrics: %v", err)
+	}
+	fileCount, deletedCount, maxFileKey := mm.FileCount(), mm.DeletedCount(), mm.MaxFileKey()
+	nm.Close()
+
+	if IsCompactIndexFresh(baseFileName) {
+		t.Fatalf("compact index is fresh before written")
+	}
+	indexFile, err = os.OpenFile(baseFileName+".idx", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("open index: %v", err)
+	}
+	cm, err := NewCompactIndexNeedleMap(baseFileName, indexFile)
+	if err != nil {
+		t.Fatalf("load compact index: %v", err)
+	}
+	if !IsCompactIndexFresh(baseFileName) {
+		t.Fatalf("compact index is not fresh after written")
+	}
+	if cm.FileCount() != fileCount || cm.DeletedCount() != deletedCount || cm.MaxFileKey() != maxFileKey {
+		t.Fatalf("metrics %d %d %d, expected %d %d %d", cm.FileCount(), cm.DeletedCount(), cm.MaxFileKey(), fileCount, deletedCount, maxFileKey)
+	}
+	for i := 1; i <= 1000; i++ {
+		key := NeedleId(i * 7)
+		value, found := cm.Get(key)
+		if i%10 == 1 {
+			if found {
+				t.Fatalf("deleted needle %d found", key)
+			}
+			continue
+		}
+		if !found || value.Offset != ToOffset(int64(i)*4096) || value.Size != Size(100+i) {
+			t.Fatalf("needle %d: %+v %v", key, value, found)
+		}
+		if _, found = cm.Get(key + 1); found {
+			t.Fatalf("missing needle %d found", key+1)
+		}
+	}
+	if _, found := cm.Get(0); found {
+		t.Fatalf("needle 0 found")
+	}
+
+	if err := cm.Delete(14, ToOffset(2*4096)); err != nil {
+		t.Fatalf("delete 14: %v", err)
+	}
+	if value, found := cm.Get(14); !found || !value.Size.IsDeleted() {
+		t.Fatalf("needle 14 after deletion: %+v %v", value, found)
+	}
+	if cm.DeletedCount() != deletedCount+1 {
+		t.Fatalf("deleted count %d, expected %d", cm.DeletedCount(), deletedCount+1)
+	}
+	cm.Close()
+	if IsCompactIndexFresh(baseFileName) {
+		t.Fatalf("compact index is fresh after the .idx file changed")
+	}
+}
+
+func TestByteWidth(t *testing.T) {
+	for value, expected := range map[uint64]int{0: 1, 255: 1, 256: 2, 1<<24 - 1: 3, 1 << 32: 5} {
+		if width := byteWidth(value); width != expected {
+			t.Errorf("byteWidth(%d) = %d, expected %d", value, width, expected)
+		}
+		b := make([]byte, 8)
+		putUintWidth(b, value, expected)
+		if decoded := getUintWidth(b, expected); decoded != value {
+			t.Errorf("decoded %d, expected %d", decoded, value)
+		}
+	}
+}
@@ -26,6 +26,29 @@ const watermarkBatchSize = 10000
 
 var watermarkKey = []byte("idx_entry_watermark")
 
+// levelDbOptions is the memory footprint of the leveldb needle map kind
+func levelDbOptions(kind NeedleMapKind) *opt.Options {
+	switch kind {
+	case NeedleMapLevelDbMedium:
+		return &opt.Options{
+			BlockCacheCapacity:            4 * 1024 * 1024, // default value is 8MiB
+			WriteBuffer:                   2 * 1024 * 1024, // default value is 4MiB
+			CompactionTableSizeMultiplier: 10,              // default value is 1
+		}
+	case NeedleMapLevelDbLarge:
+		return &opt.Options{
+			BlockCacheCapacity:            8 * 1024 * 1024, // default value is 8MiB
+			WriteBuffer:                   4 * 1024 * 1024, // default value is 4MiB
+			CompactionTableSizeMultiplier: 10,              // default value is 1
+		}
+	}
+	return &opt.Options{
+		BlockCacheCapacity:            2 * 1024 * 1024, // default value is 8MiB
+		WriteBuffer:                   1 * 1024 * 1024, // default value is 4MiB
+		CompactionTableSizeMultiplier: 10,              // default value is 1
+	}
+}
+
 type LevelDbNeedleMap struct {
 	baseNeedleMapper
 	dbFileName    string
@@ -275,7 +298,8 @@ func (m *LevelDbNeedleMap) Destroy() error {
 	return os.RemoveAll(m.dbFileName)
 }
 
-func (m *LevelDbNeedleMap) UpdateNeedleMap(v *Volume, indexFile *os.File, opts *opt.Options, ldbTimeout int64) error {
+func (m *LevelDbNeedleMap) UpdateNeedleMap(v *Volume, indexFile *os.File) error {
+	opts, ldbTimeout := levelDbOptions(v.needleMapKind), v.ldbTimeout
 	if v.nm != nil {
 		v.nm.Close()
 		v.nm = nil
@@ -7,7 +7,6 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/storage/idx"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
 	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
-	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
 type NeedleMap struct {
@@ -84,7 +83,7 @@ func (nm *NeedleMap) Destroy() error {
 	return os.Remove(nm.indexFile.Name())
 }
 
-func (nm *NeedleMap) UpdateNeedleMap(v *Volume, indexFile *os.File, opts *opt.Options, ldbTimeout int64) error {
+func (nm *NeedleMap) UpdateNeedleMap(v *Volume, indexFile *os.File) error {
 	if v.nm != nil {
 		v.nm.Close()
 		v.nm = nil
@@ -86,7 +86,8 @@ func (mm *mapMetric) MaybeSetMaxFileKey(key NeedleId) {
 	if mm == nil {
 		return
 	}
-	if key > mm.MaxFileKey() {
+	// the blob needles of the deduplicated volumes are not assigned by the master
+	if key > mm.MaxFileKey() && !IsDedupBlobId(key) {
 		atomic.StoreUint64(&mm.MaximumFileKey, uint64(key))
 	}
 }
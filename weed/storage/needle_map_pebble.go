@@ -0,0 +1,347 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cockroachdb/pebble"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/idx"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The pebble needle map keeps the needle entries in a pebble db next to the .idx file.
+// The .idx file is always written first, and the db marks the count of the applied .idx entries
+// as the watermark, so the entries after the watermark are applied again when the db is opened.
+
+// pebbleCache is the block cache shared by the pebble needle maps of all volumes
+var pebbleCache *pebble.Cache
+
+// SetPebbleCacheSize sets the block cache shared by the pebble needle maps of all volumes
+func SetPebbleCacheSize(size int64) {
+	if pebbleCache != nil {
+		pebbleCache.Unref()
+	}
+	pebbleCache = pebble.NewCache(size)
+}
+
+type pebbleLogger struct{}
+
+func (pebbleLogger) Infof(format string, args ...interface{}) {
+	glog.V(1).Infof(format, args...)
+}
+
+func (pebbleLogger) Fatalf(format string, args ...interface{}) {
+	glog.Fatalf(format, args...)
+}
+
+func openPebble(dbFileName string) (*pebble.DB, error) {
+	return pebble.Open(dbFileName, &pebble.Options{
+		Cache:  pebbleCache,
+		Logger: pebbleLogger{},
+	})
+}
+
+type PebbleNeedleMap struct {
+	baseNeedleMapper
+	dbFileName  string
+	db          *pebble.DB
+	recordCount uint64
+}
+
+func NewPebbleNeedleMap(dbFileName string, indexFile *os.File) (m *PebbleNeedleMap, err error) {
+	m = &PebbleNeedleMap{dbFileName: dbFileName}
+	if err = m.open(indexFile); err != nil {
+		return nil, err
+	}
+	mm, indexLoadError := newNeedleMapMetricFromIndexFile(indexFile)
+	if indexLoadError != nil {
+		m.db.Close()
+		return nil, indexLoadError
+	}
+	m.mapMetric = *mm
+	return
+}
+
+// open opens the db, and applies the index entries after the watermark
+func (m *PebbleNeedleMap) open(indexFile *os.File) (err error) {
+	stat, err := indexFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat file %s: %v", indexFile.Name(), err)
+	}
+	m.indexFile = indexFile
+	m.indexFileOffset = stat.Size()
+	m.recordCount = uint64(stat.Size() / NeedleMapEntrySize)
+
+	glog.V(1).Infof("Opening %s...", m.dbFileName)
+	if m.db, err = openPebble(m.dbFileName); err != nil {
+		return fmt.Errorf("open %s: %v", m.dbFileName, err)
+	}
+	watermark := getPebbleWatermark(m.db)
+	if watermark > m.recordCount {
+		// the index file is replaced or truncated
+		glog.Warningf("regenerate %s with watermark %d over %d index entries", m.dbFileName, watermark, m.recordCount)
+		m.db.Close()
+		if err = os.RemoveAll(m.dbFileName); err != nil {
+			return err
+		}
+		if m.db, err = openPebble(m.dbFileName); err != nil {
+			return fmt.Errorf("open %s: %v", m.dbFileName, err)
+		}
+		watermark = 0
+	}
+	glog.V(0).Infof("Loading %s... , watermark: %d, num of entries:%d", m.dbFileName, watermark, m.recordCount-watermark)
+	if _, err = loadPebbleFromIndexFile(m.db, indexFile, watermark, nil); err != nil {
+		m.db.Close()
+		return fmt.Errorf("load %s from %s: %v", m.dbFileName, indexFile.Name(), err)
+	}
+	return nil
+}
+
+// loadPebbleFromIndexFile applies the index entries from the startFrom entry, and returns the count of the index entries.
+// The metric is updated with the entries if not nil.
+func loadPebbleFromIndexFile(db *pebble.DB, indexFile *os.File, startFrom uint64, metric *mapMetric) (recordCount uint64, err error) {
+	recordCount = startFrom
+	batch := db.NewIndexedBatch()
+	defer func() {
+		batch.Close()
+	}()
+	err = idx.WalkIndexFile(indexFile, startFrom, func(key NeedleId, offset Offset, size Size) error {
+		if metric != nil {
+			oldNeedle, found, err := pebbleGet(batch, key)
+			if err != nil {
+				return err
+			}
+			metric.MaybeSetMaxFileKey(key)
+			metric.FileCounter++
+			if !offset.IsZero() && size.IsValid() {
+				metric.FileByteCounter += uint64(size)
+				if found && !oldNeedle.Offset.IsZero() && oldNeedle.Size.IsValid() {
+					metric.DeletionCounter++
+					metric.DeletionByteCounter += uint64(oldNeedle.Size)
+				}
+			} else if found {
+				metric.DeletionCounter++
+				if oldNeedle.Size.IsValid() {
+					metric.DeletionByteCounter += uint64(oldNeedle.Size)
+				}
+			}
+		}
+		var e error
+		if !offset.IsZero() && size.IsValid() {
+			e = pebbleWrite(batch, key, offset, size)
+		} else {
+			e = pebbleDelete(batch, key)
+		}
+		if e != nil {
+			return e
+		}
+		recordCount++
+		if recordCount%watermarkBatchSize == 0 {
+			if e = setPebbleWatermark(batch, recordCount); e != nil {
+				return e
+			}
+			if e = batch.Commit(pebble.NoSync); e != nil {
+				return e
+			}
+			batch.Close()
+			batch = db.NewIndexedBatch()
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	if err = setPebbleWatermark(batch, recordCount); err != nil {
+		return
+	}
+	err = batch.Commit(pebble.Sync)
+	return
+}
+
+func getPebbleWatermark(db *pebble.DB) uint64 {
+	data, closer, err := db.Get(watermarkKey)
+	if err != nil {
+		if err != pebble.ErrNotFound {
+			glog.V(1).Infof("read previous watermark from db: %v", err)
+		}
+		return 0
+	}
+	defer closer.Close()
+	if len(data) != 8 {
+		return 0
+	}
+	return util.BytesToUint64(data)
+}
+
+func setPebbleWatermark(w pebble.Writer, watermark uint64) error {
+	var wmBytes = make([]byte, 8)
+	util.Uint64toBytes(wmBytes, watermark)
+	if err := w.Set(watermarkKey, wmBytes, nil); err != nil {
+		return fmt.Errorf("failed to set watermark: %v", err)
+	}
+	return nil
+}
+
+func pebbleGet(r pebble.Reader, key NeedleId) (element *needle_map.NeedleValue, found bool, err error) {
+	bytes := make([]byte, NeedleIdSize)
+	NeedleIdToBytes(bytes, key)
+	data, closer, err := r.Get(bytes)
+	if err == pebble.ErrNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer closer.Close()
+	if len(data) != OffsetSize+SizeSize {
+		return nil, false, nil
+	}
+	offset := BytesToOffset(data[0:OffsetSize])
+	size := BytesToSize(data[OffsetSize : OffsetSize+SizeSize])
+	return &needle_map.NeedleValue{Key: key, Offset: offset, Size: size}, true, nil
+}
+
+func pebbleWrite(w pebble.Writer, key NeedleId, offset Offset, size Size) error {
+	bytes := needle_map.ToBytes(key, offset, size)
+	if err := w.Set(bytes[0:NeedleIdSize], bytes[NeedleIdSize:NeedleIdSize+OffsetSize+SizeSize], nil); err != nil {
+		return fmt.Errorf("failed to write pebble: %v", err)
+	}
+	return nil
+}
+
+func pebbleDelete(w pebble.Writer, key NeedleId) error {
+	bytes := make([]byte, NeedleIdSize)
+	NeedleIdToBytes(bytes, key)
+	return w.Delete(bytes, nil)
+}
+
+func (m *PebbleNeedleMap) Get(key NeedleId) (element *needle_map.NeedleValue, ok bool) {
+	element, ok, err := pebbleGet(m.db, key)
+	if err != nil {
+		glog.V(0).Infof("read %d from %s: %v", key, m.dbFileName, err)
+		return nil, false
+	}
+	return element, ok
+}
+
+// write writes the entry, and the watermark every watermarkBatchSize entries, after the index file
+func (m *PebbleNeedleMap) write(key NeedleId, offset Offset, size Size) error {
+	m.recordCount++
+	batch := m.db.NewBatch()
+	defer batch.Close()
+	if err := pebbleWrite(batch, key, offset, size); err != nil {
+		return err
+	}
+	if m.recordCount%watermarkBatchSize == 0 {
+		glog.V(1).Infof("put cnt:%d for %s,watermark: %d", m.recordCount, m.dbFileName, m.recordCount)
+		if err := setPebbleWatermark(batch, m.recordCount); err != nil {
+			return err
+		}
+	}
+	return batch.Commit(pebble.NoSync)
+}
+
+func (m *PebbleNeedleMap) Put(key NeedleId, offset Offset, size Size) error {
+	var oldSize Size
+	if oldNeedle, ok := m.Get(key); ok {
+		oldSize = oldNeedle.Size
+	}
+	m.logPut(key, oldSize, size)
+	// write to index file first
+	if err := m.appendToIndexFile(key, offset, size); err != nil {
+		return fmt.Errorf("cannot write to indexfile %s: %v", m.indexFile.Name(), err)
+	}
+	return m.write(key, offset, size)
+}
+
+func (m *PebbleNeedleMap) Delete(key NeedleId, offset Offset) error {
+	oldNeedle, found := m.Get(key)
+	if !found || oldNeedle.Size.IsDeleted() {
+		return nil
+	}
+	m.logDelete(oldNeedle.Size)
+
+	// write to index file first
+	if err := m.appendToIndexFile(key, offset, TombstoneFileSize); err != nil {
+		return err
+	}
+	return m.write(key, oldNeedle.Offset, -oldNeedle.Size)
+}
+
+func (m *PebbleNeedleMap) Close() {
+	if m.indexFile != nil {
+		indexFileName := m.indexFile.Name()
+		if err := m.indexFile.Sync(); err != nil {
+			glog.Warningf("sync file %s failed: %v", indexFileName, err)
+		}
+		if err := m.indexFile.Close(); err != nil {
+			glog.Warningf("close index file %s failed: %v", indexFileName, err)
+		}
+	}
+
+	if m.db != nil {
+		if err := m.db.Close(); err != nil {
+			glog.Warningf("close pebble %s failed: %v", m.dbFileName, err)
+		}
+		m.db = nil
+	}
+}
+
+func (m *PebbleNeedleMap) Destroy() error {
+	m.Close()
+	os.Remove(m.indexFile.Name())
+	return os.RemoveAll(m.dbFileName)
+}
+
+// DoOffsetLoading loads the compacted index file into the .cppdb file
+func (m *PebbleNeedleMap) DoOffsetLoading(v *Volume, indexFile *os.File, startFrom uint64) (err error) {
+	glog.V(0).Infof("loading idx to pebble from offset %d for file: %s", startFrom, indexFile.Name())
+	dbFileName := v.FileName(".cppdb")
+	if startFrom == 0 {
+		os.RemoveAll(dbFileName)
+	}
+	db, err := openPebble(dbFileName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		db.Close()
+		if err != nil {
+			os.RemoveAll(dbFileName)
+		}
+	}()
+	_, err = loadPebbleFromIndexFile(db, indexFile, startFrom, &m.mapMetric)
+	return err
+}
+
+// UpdateNeedleMap replaces the .pdb file of the volume with the compacted .cppdb file
+func (m *PebbleNeedleMap) UpdateNeedleMap(v *Volume, indexFile *os.File) error {
+	if v.nm != nil {
+		v.nm.Close()
+		v.nm = nil
+	}
+	defer func() {
+		if v.tmpNm != nil {
+			v.tmpNm.Close()
+			v.tmpNm = nil
+		}
+	}()
+	dbFileName := v.FileName(".pdb")
+	if err := os.RemoveAll(dbFileName); err != nil {
+		return err
+	}
+	if err := os.Rename(v.FileName(".cppdb"), dbFileName); err != nil {
+		return fmt.Errorf("rename %s: %v", dbFileName, err)
+	}
+	m.dbFileName = dbFileName
+	if err := m.open(indexFile); err != nil {
+		return err
+	}
+	v.nm = m
+	v.tmpNm = nil
+	return nil
+}
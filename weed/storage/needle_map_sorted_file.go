@@ -5,6 +5,7 @@ import (
 
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/idx"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
 	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
 )
@@ -14,11 +15,15 @@ type SortedFileNeedleMap struct {
 	baseFileName string
 	dbFile       *os.File
 	dbFileSize   int64
+	dbData       []byte // the memory mapped .sdx file, or nil to search with pread
 }
 
-func NewSortedFileNeedleMap(indexBaseFileName string, indexFile *os.File) (m *SortedFileNeedleMap, err error) {
+func NewSortedFileNeedleMap(indexBaseFileName string, indexFile *os.File, memoryMapped bool) (m *SortedFileNeedleMap, err error) {
 	m = &SortedFileNeedleMap{baseFileName: indexBaseFileName}
 	m.indexFile = indexFile
+	if stat, statErr := indexFile.Stat(); statErr == nil {
+		m.indexFileOffset = stat.Size()
+	}
 	fileName := indexBaseFileName + ".sdx"
 	if !isSortedFileFresh(fileName, indexFile) {
 		glog.V(0).Infof("Start to Generate %s from %s", fileName, indexFile.Name())
@@ -32,10 +37,16 @@ func NewSortedFileNeedleMap(indexBaseFileName string, indexFile *os.File) (m *So
 	}
 	dbStat, _ := m.dbFile.Stat()
 	m.dbFileSize = dbStat.Size()
+	if memoryMapped && m.dbFileSize > 0 {
+		if m.dbData, err = mmapSortedFile(m.dbFile, m.dbFileSize); err != nil {
+			glog.Warningf("memory map %s: %v, fall back to reading the file", fileName, err)
+			m.dbData, err = nil, nil
+		}
+	}
 	glog.V(1).Infof("Loading %s...", indexFile.Name())
 	mm, indexLoadError := newNeedleMapMetricFromIndexFile(indexFile)
 	if indexLoadError != nil {
-		_ = m.dbFile.Close()
+		m.Close()
 		return nil, indexLoadError
 	}
 	m.mapMetric = *mm
@@ -60,12 +71,41 @@ func isSortedFileFresh(dbFileName string, indexFile *os.File) bool {
 }
 
 func (m *SortedFileNeedleMap) Get(key NeedleId) (element *needle_map.NeedleValue, ok bool) {
+	if m.dbData != nil {
+		offset, size, found := searchSortedIndexData(m.dbData, key)
+		return &needle_map.NeedleValue{Key: key, Offset: offset, Size: size}, found
+	}
 	offset, size, err := erasure_coding.SearchNeedleFromSortedIndex(m.dbFile, m.dbFileSize, key, nil)
 	ok = err == nil
 	return &needle_map.NeedleValue{Key: key, Offset: offset, Size: size}, ok
 
 }
 
+// searchSortedIndexData binary searches the needle in the memory mapped .sdx file
+func searchSortedIndexData(data []byte, key NeedleId) (offset Offset, size Size, found bool) {
+	l, h := 0, len(data)/NeedleMapEntrySize
+	for l < h {
+		m := (l + h) / 2
+		entry := data[m*NeedleMapEntrySize : (m+1)*NeedleMapEntrySize]
+		k := BytesToNeedleId(entry[:NeedleIdSize])
+		if k == key {
+			_, offset, size = idx.IdxFileEntry(entry)
+			return offset, size, true
+		}
+		if k < key {
+			l = m + 1
+		} else {
+			h = m
+		}
+	}
+	return Offset{}, TombstoneFileSize, false
+}
+
+// IsMemoryMapped is true if the .sdx file is searched in memory mapped pages
+func (m *SortedFileNeedleMap) IsMemoryMapped() bool {
+	return m.dbData != nil
+}
+
 func (m *SortedFileNeedleMap) Put(key NeedleId, offset Offset, size Size) error {
 	return os.ErrInvalid
 }
@@ -101,6 +141,12 @@ func (m *SortedFileNeedleMap) Close() {
 	if m.indexFile != nil {
 		m.indexFile.Close()
 	}
+	if m.dbData != nil {
+		if err := munmapSortedFile(m.dbData); err != nil {
+			glog.Warningf("munmap %s.sdx: %v", m.baseFileName, err)
+		}
+		m.dbData = nil
+	}
 	if m.dbFile != nil {
 		m.dbFile.Close()
 	}
@@ -0,0 +1,24 @@
+//go:build linux
+// +build linux
+
+package storage
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func mmapSortedFile(f *os.File, size int64) ([]byte, error) {
+	data, err := unix.Mmap(int(f.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	// the lookups jump around the file, so reading ahead only pollutes the page cache
+	_ = unix.Madvise(data, unix.MADV_RANDOM)
+	return data, nil
+}
+
+func munmapSortedFile(data []byte) error {
+	return unix.Munmap(data)
+}
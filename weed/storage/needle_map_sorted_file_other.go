@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package storage
+
+import (
+	"fmt"
+	"os"
+)
+
+func mmapSortedFile(f *os.File, size int64) ([]byte, error) {
+	return nil, fmt.Errorf("memory mapped index not implemented for this platform")
+}
+
+func munmapSortedFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const (
+	NeedleWalFileName = "needles.wal"
+
+	walRecordHeaderSize = 4 + 4 // crc, payload length
+	walPayloadMetaSize  = 1 + 4 + NeedleIdSize + 8 + SizeSize
+	walMaxPayloadSize   = walPayloadMetaSize + math.MaxInt32
+
+	walRecordPut    = byte(1)
+	walRecordDelete = byte(2)
+)
+
+var walCrcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// walRecord is one needle append, which is also in the volume .dat file but may not be synced yet
+type walRecord struct {
+	kind       byte
+	volumeId   needle.VolumeId
+	needleId   NeedleId
+	appendAtNs uint64
+	size       Size
+	blob       []byte // the needle as appended, empty for deletes
+}
+
+func (r *walRecord) toBytes() []byte {
+	payloadSize := walPayloadMetaSize + len(r.blob)
+	b := make([]byte, walRecordHeaderSize+payloadSize)
+	payload := b[walRecordHeaderSize:]
+	payload[0] = r.kind
+	util.Uint32toBytes(payload[1:5], uint32(r.volumeId))
+	NeedleIdToBytes(payload[5:5+NeedleIdSize], r.needleId)
+	util.Uint64toBytes(payload[5+NeedleIdSize:13+NeedleIdSize], r.appendAtNs)
+	SizeToBytes(payload[13+NeedleIdSize:walPayloadMetaSize], r.size)
+	copy(payload[walPayloadMetaSize:], r.blob)
+	util.Uint32toBytes(b[0:4], crc32.Checksum(payload, walCrcTable))
+	util.Uint32toBytes(b[4:8], uint32(payloadSize))
+	return b
+}
+
+// readWalRecord returns io.EOF at the end of the log, or at a torn record from a crash while appending
+func readWalRecord(r io.Reader) (*walRecord, error) {
+	header := make([]byte, walRecordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, io.EOF
+	}
+	payloadSize := util.BytesToUint32(header[4:8])
+	if payloadSize < walPayloadMetaSize || uint64(payloadSize) > walMaxPayloadSize {
+		return nil, io.EOF
+	}
+	payload := make([]byte, payloadSize)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, io.EOF
+	}
+	if crc32.Checksum(payload, walCrcTable) != util.BytesToUint32(header[0:4]) {
+		return nil, io.EOF
+	}
+	return &walRecord{
+		kind:       payload[0],
+		volumeId:   needle.VolumeId(util.BytesToUint32(payload[1:5])),
+		needleId:   BytesToNeedleId(payload[5 : 5+NeedleIdSize]),
+		appendAtNs: util.BytesToUint64(payload[5+NeedleIdSize : 13+NeedleIdSize]),
+		size:       BytesToSize(payload[13+NeedleIdSize : walPayloadMetaSize]),
+		blob:       payload[walPayloadMetaSize:],
+	}, nil
+}
+
+// readWalRecords calls fn for each complete record in the log file
+func readWalRecords(fileName string, fn func(record *walRecord) error) error {
+	f, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := bufio.NewReaderSize(f, 1024*1024)
+	for {
+		record, err := readWalRecord(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err = fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// needleWal is the write-ahead log of one disk location. The needles are appended to the volumes first,
+// then to the log, and the write is acknowledged after fdatasync of the log. The concurrent appends
+// share one fdatasync. A checkpoint syncs the volumes with records in the log, and drops those records.
+type needleWal struct {
+	fileName string
+
+	// accessLock serializes the appends
+	accessLock sync.Mutex
+	file       *os.File
+	fileSize   int64
+	appended   uint64
+	volumes    map[needle.VolumeId]uint64 // the last record of each volume in the log
+
+	// syncLock serializes the fdatasync
+	syncLock sync.Mutex
+	synced   uint64
+	syncErr  error
+}
+
+func openNeedleWal(dir string) (*needleWal, error) {
+	fileName := filepath.Join(dir, NeedleWalFileName)
+	file, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %v", fileName, err)
+	}
+	return &needleWal{
+		fileName: fileName,
+		file:     file,
+		volumes:  make(map[needle.VolumeId]uint64),
+	}, nil
+}
+
+func (w *needleWal) appendNeedle(vid needle.VolumeId, n *needle.Needle, version needle.Version) error {
+	blob, err := n.ToBytes(version)
+	if err != nil {
+		return err
+	}
+	return w.append(&walRecord{
+		kind:       walRecordPut,
+		volumeId:   vid,
+		needleId:   n.Id,
+		appendAtNs: n.AppendAtNs,
+		size:       n.Size,
+		blob:       blob,
+	})
+}
+
+func (w *needleWal) appendDelete(vid needle.VolumeId, n *needle.Needle) error {
+	return w.append(&walRecord{
+		kind:       walRecordDelete,
+		volumeId:   vid,
+		needleId:   n.Id,
+		appendAtNs: n.AppendAtNs,
+	})
+}
+
+func (w *needleWal) append(record *walRecord) error {
+	b := record.toBytes()
+
+	w.accessLock.Lock()
+	if w.file == nil {
+		w.accessLock.Unlock()
+		return os.ErrClosed
+	}
+	if _, err := w.file.WriteAt(b, w.fileSize); err != nil {
+		w.accessLock.Unlock()
+		return fmt.Errorf("append to %s: %v", w.fileName, err)
+	}
+	w.fileSize += int64(len(b))
+	w.appended++
+	seq := w.appended
+	w.volumes[record.volumeId] = seq
+	w.accessLock.Unlock()
+
+	return w.syncUpTo(seq)
+}
+
+// syncUpTo returns after the record of seq is synced, together with all records appended before the fdatasync
+func (w *needleWal) syncUpTo(seq uint64) error {
+	w.syncLock.Lock()
+	defer w.syncLock.Unlock()
+	if w.synced >= seq {
+		return nil
+	}
+	if w.syncErr != nil {
+		return w.syncErr
+	}
+
+	w.accessLock.Lock()
+	appended, file := w.appended, w.file
+	w.accessLock.Unlock()
+	if file == nil {
+		return os.ErrClosed
+	}
+
+	if err := fdatasync(file); err != nil {
+		// the records after the failed fdatasync may be lost, so the later writes fail until a checkpoint
+		w.syncErr = fmt.Errorf("fdatasync %s: %v", w.fileName, err)
+		return w.syncErr
+	}
+	w.synced = appended
+	return nil
+}
+
+func (w *needleWal) size() int64 {
+	w.accessLock.Lock()
+	defer w.accessLock.Unlock()
+	return w.fileSize
+}
+
+// checkpoint syncs the volumes with records in the log, and rewrites the log with only the records
+// appended during the volume sync. The old log is kept until the new one is renamed over it.
+func (w *needleWal) checkpoint(syncVolume func(vid needle.VolumeId)) error {
+	w.accessLock.Lock()
+	if w.file == nil {
+		w.accessLock.Unlock()
+		return os.ErrClosed
+	}
+	checkpointSeq, checkpointSize := w.appended, w.fileSize
+	var volumeIds []needle.VolumeId
+	for vid := range w.volumes {
+		volumeIds = append(volumeIds, vid)
+	}
+	w.accessLock.Unlock()
+
+	if checkpointSize == 0 {
+		return nil
+	}
+	for _, vid := range volumeIds {
+		syncVolume(vid)
+	}
+
+	w.syncLock.Lock()
+	defer w.syncLock.Unlock()
+	w.accessLock.Lock()
+	defer w.accessLock.Unlock()
+
+	tail := make([]byte, w.fileSize-checkpointSize)
+	if _, err := w.file.ReadAt(tail, checkpointSize); err != nil {
+		return fmt.Errorf("read %s: %v", w.fileName, err)
+	}
+	tmpFileName := w.fileName + ".tmp"
+	tmpFile, err := os.OpenFile(tmpFileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("open %s: %v", tmpFileName, err)
+	}
+	if _, err = tmpFile.Write(tail); err == nil {
+		err = fdatasync(tmpFile)
+	}
+	if err == nil {
+		err = os.Rename(tmpFileName, w.fileName)
+	}
+	if err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFileName)
+		return fmt.Errorf("rewrite %s: %v", w.fileName, err)
+	}
+	if dir, dirErr := os.Open(filepath.Dir(w.fileName)); dirErr == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	w.file.Close()
+	w.file, w.fileSize = tmpFile, int64(len(tail))
+	w.synced, w.syncErr = w.appended, nil
+	for vid, seq := range w.volumes {
+		if seq <= checkpointSeq {
+			delete(w.volumes, vid)
+		}
+	}
+	return nil
+}
+
+// close removes the log, after the volumes are closed and synced
+func (w *needleWal) close() {
+	w.syncLock.Lock()
+	defer w.syncLock.Unlock()
+	w.accessLock.Lock()
+	defer w.accessLock.Unlock()
+	if w.file == nil {
+		return
+	}
+	w.file.Close()
+	w.file = nil
+	os.Remove(w.fileName)
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestNeedleWalReplay(t *testing.T) {
+	dir := t.TempDir()
+	l := NewDiskLocation(dir, 10, util.MinFreeSpace{}, "", types.HardDriveType)
+	defer l.Close()
+
+	loadVolume := func() *Volume {
+		v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+		if err != nil {
+			t.Fatalf("volume creation: %v", err)
+		}
+		l.SetVolume(1, v)
+		return v
+	}
+	data := func(i int) []byte {
+		return bytes.Repeat([]byte(fmt.Sprintf("needle %d ", i)), 100)
+	}
+	v := loadVolume()
+	write := func(wal *needleWal, i int) {
+		n := newEmptyNeedle(uint64(i))
+		n.Data = data(i)
+		n.Checksum = needle.NewCRC(n.Data)
+		if _, _, _, err := v.writeNeedle2(n, true, false); err != nil {
+			t.Fatalf("write needle %d: %v", i, err)
+		}
+		if err := wal.appendNeedle(v.Id, n, v.Version()); err != nil {
+			t.Fatalf("log needle %d: %v", i, err)
+		}
+	}
+
+	wal, err := openNeedleWal(dir)
+	if err != nil {
+		t.Fatalf("open wal: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		write(wal, i)
+	}
+	if err = wal.checkpoint(l.syncVolume); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	if wal.size() != 0 {
+		t.Fatalf("wal size %d after checkpoint", wal.size())
+	}
+	datSize, idxSize := fileSize(t, v.FileName(".dat")), fileSize(t, v.FileName(".idx"))
+
+	for i := 4; i <= 10; i++ {
+		write(wal, i)
+	}
+	for _, i := range []int{2, 5} {
+		n := newEmptyNeedle(uint64(i))
+		if _, err := v.deleteNeedle2(n); err != nil {
+			t.Fatalf("delete needle %d: %v", i, err)
+		}
+		if err := wal.appendDelete(v.Id, n); err != nil {
+			t.Fatalf("log deletion %d: %v", i, err)
+		}
+	}
+
+	// lose the volume writes after the checkpoint, and tear the last log record
+	walFileName := filepath.Join(dir, NeedleWalFileName)
+	walBytes, err := os.ReadFile(walFileName)
+	if err != nil {
+		t.Fatalf("read wal: %v", err)
+	}
+	wal.file.Close()
+	v.Close()
+	if err = os.Truncate(v.FileName(".dat"), datSize); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Truncate(v.FileName(".idx"), idxSize); err != nil {
+		t.Fatal(err)
+	}
+	torn := (&walRecord{kind: walRecordDelete, volumeId: 1, needleId: 7}).toBytes()
+	walBytes = append(walBytes, torn[:len(torn)-1]...)
+	if err = os.WriteFile(walFileName, walBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	v = loadVolume()
+	if err = l.replayNeedleWal(); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if _, err = os.Stat(walFileName); !os.IsNotExist(err) {
+		t.Fatalf("wal is not removed after replay: %v", err)
+	}
+	for i := 1; i <= 10; i++ {
+		n := newEmptyNeedle(uint64(i))
+		_, err := v.readNeedle(n, nil, nil)
+		if i == 2 || i == 5 {
+			if err == nil {
+				t.Errorf("deleted needle %d is read", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("read needle %d: %v", i, err)
+		} else if !bytes.Equal(n.Data, data(i)) {
+			t.Errorf("needle %d data mismatch", i)
+		}
+	}
+
+	// replaying again does not append the needles twice
+	contentSize := v.ContentSize()
+	if err = os.WriteFile(walFileName, walBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.replayNeedleWal(); err != nil {
+		t.Fatalf("replay again: %v", err)
+	}
+	if v.ContentSize() != contentSize {
+		t.Errorf("content size %d after replaying again, expected %d", v.ContentSize(), contentSize)
+	}
+}
+
+func fileSize(t *testing.T, fileName string) int64 {
+	stat, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatalf("stat %s: %v", fileName, err)
+	}
+	return stat.Size()
+}
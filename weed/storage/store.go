@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/pb"
 	"github.com/seaweedfs/seaweedfs/weed/storage/volume_info"
@@ -49,6 +50,9 @@ type ReadOption struct {
 	// increasing ReadBufferSize can reduce the number of get locks times and shorten read P99 latency.
 	// but will increase memory usage a bit. Use with hasSlowRead normally.
 	ReadBufferSize int
+
+	// the writer is backed by a socket, so the large reads can be sent by the kernel
+	CanSendfile bool
 }
 
 /*
@@ -72,6 +76,27 @@ type Store struct {
 	NewEcShardsChan     chan master_pb.VolumeEcShardInformationMessage
 	DeletedEcShardsChan chan master_pb.VolumeEcShardInformationMessage
 	isStopping          bool
+
+	encryptedCollections    map[string]bool
+	deduplicatedCollections map[string]bool
+	needleFilterCollections map[string]bool
+	// overwrite the needles of the same size in place
+	inPlaceOverwriteCollections map[string]bool
+	checksumAlgorithm           needle.ChecksumAlgorithm
+	compactInPlace              bool
+	objectStoreBackend          string
+	objectStoreSegmentSize      int64
+	readOnlyIndex               string
+	vacuumLimiter               *vacuumLimiter
+	isDraining                  bool
+	tags                        map[string]string
+	weight                      float64
+	writeLoad                   writeLoad
+	requestLoad                 requestLoad
+
+	readRepairChan    chan readRepair
+	readRepairLock    sync.Mutex
+	readRepairPending map[readRepair]bool
 }
 
 func (s *Store) String() (str string) {
@@ -115,6 +140,9 @@ func (s *Store) AddVolume(volumeId needle.VolumeId, collection string, needleMap
 	if e != nil {
 		return e
 	}
+	if e = s.checkNotDraining(); e != nil {
+		return e
+	}
 	e = s.addVolume(volumeId, collection, needleMapKind, rt, ttl, preallocate, MemoryMapMaxSizeMb, diskType, ldbTimeout)
 	return e
 }
@@ -130,6 +158,15 @@ func (s *Store) DeleteCollection(collection string) (e error) {
 	return
 }
 
+func (s *Store) findVolumeAndLocation(vid needle.VolumeId) (*Volume, *DiskLocation) {
+	for _, location := range s.Locations {
+		if v, found := location.FindVolume(vid); found {
+			return v, location
+		}
+	}
+	return nil, nil
+}
+
 func (s *Store) findVolume(vid needle.VolumeId) *Volume {
 	for _, location := range s.Locations {
 		if v, found := location.FindVolume(vid); found {
@@ -144,7 +181,7 @@ func (s *Store) FindFreeLocation(diskType DiskType) (ret *DiskLocation) {
 		if diskType != location.DiskType {
 			continue
 		}
-		if location.isDiskSpaceLow {
+		if location.isDiskSpaceLow || location.IsDiskFailing() {
 			continue
 		}
 		currentFreeCount := location.MaxVolumeCount - int32(location.VolumesLen())
@@ -165,7 +202,41 @@ func (s *Store) addVolume(vid needle.VolumeId, collection string, needleMapKind
 	if location := s.FindFreeLocation(diskType); location != nil {
 		glog.V(0).Infof("In dir %s adds volume:%v collection:%s replicaPlacement:%v ttl:%v",
 			location.Directory, vid, collection, replicaPlacement, ttl)
+		if location.IsZoned() {
+			if memoryMapMaxSizeMb != 0 {
+				return fmt.Errorf("memory mapped volume %d is not supported on zoned disk %s", vid, location.Directory)
+			}
+			// fallocate writes out of order, and is not supported by zoned file systems
+			preallocate = 0
+		}
+		if location.DiskType == ObjectStoreType {
+			if memoryMapMaxSizeMb != 0 {
+				return fmt.Errorf("memory mapped volume %d is not supported on object disk %s", vid, location.Directory)
+			}
+			preallocate = 0
+			if err := s.prepareObjectStoreVolume(VolumeFileName(location.Directory, collection, int(vid))); err != nil {
+				return fmt.Errorf("object store volume %d: %v", vid, err)
+			}
+		}
+		if s.shouldEncrypt(collection) {
+			if err := prepareEncryptedVolume(VolumeFileName(location.Directory, collection, int(vid))); err != nil {
+				return fmt.Errorf("encrypt volume %d: %v", vid, err)
+			}
+		}
+		if s.shouldDeduplicate(collection) {
+			if err := prepareDeduplicatedVolume(VolumeFileName(location.Directory, collection, int(vid))); err != nil {
+				return fmt.Errorf("deduplicate volume %d: %v", vid, err)
+			}
+		}
+		if s.checksumAlgorithm != needle.ChecksumCrc32c {
+			if err := prepareVolumeChecksum(VolumeFileName(location.Directory, collection, int(vid)), s.checksumAlgorithm); err != nil {
+				return fmt.Errorf("checksum volume %d: %v", vid, err)
+			}
+		}
 		if volume, err := NewVolume(location.Directory, location.IdxDirectory, collection, vid, needleMapKind, replicaPlacement, ttl, preallocate, memoryMapMaxSizeMb, ldbTimeout); err == nil {
+			s.maybeUseNeedleFilter(volume)
+			s.maybeOverwriteInPlace(volume)
+			s.maybeUseDiskReadCache(location, volume)
 			location.SetVolume(vid, volume)
 			glog.V(0).Infof("add volume %d", vid)
 			s.NewVolumesChan <- master_pb.VolumeShortInformationMessage{
@@ -264,6 +335,9 @@ func (s *Store) CollectHeartbeat() *master_pb.Heartbeat {
 			if maxFileKey < curMaxFileKey {
 				maxFileKey = curMaxFileKey
 			}
+			if s.isDraining {
+				volumeMessage.ReadOnly = true
+			}
 			shouldDeleteVolume := false
 			if !v.expired(volumeMessage.Size, s.GetVolumeSizeLimit()) {
 				volumeMessages = append(volumeMessages, volumeMessage)
@@ -299,6 +373,7 @@ func (s *Store) CollectHeartbeat() *master_pb.Heartbeat {
 					stats.NoWriteOrDelete:  0,
 					stats.NoWriteCanDelete: 0,
 					stats.IsDiskSpaceLow:   0,
+					stats.IsDiskFailing:    0,
 				}
 			}
 			if !shouldDeleteVolume && v.IsReadOnly() {
@@ -312,6 +387,9 @@ func (s *Store) CollectHeartbeat() *master_pb.Heartbeat {
 				if v.location.isDiskSpaceLow {
 					collectionVolumeReadOnlyCount[v.Collection][stats.IsDiskSpaceLow] += 1
 				}
+				if v.isOnFailingDisk() {
+					collectionVolumeReadOnlyCount[v.Collection][stats.IsDiskFailing] += 1
+				}
 			}
 		}
 		location.volumesLock.RUnlock()
@@ -348,6 +426,7 @@ func (s *Store) CollectHeartbeat() *master_pb.Heartbeat {
 		}
 	}
 
+	writeLatency, inFlightWrites := s.writeLoad.report()
 	return &master_pb.Heartbeat{
 		Ip:              s.Ip,
 		Port:            uint32(s.Port),
@@ -360,6 +439,12 @@ func (s *Store) CollectHeartbeat() *master_pb.Heartbeat {
 		Volumes:         volumeMessages,
 		HasNoVolumes:    len(volumeMessages) == 0,
 		LocationUuids:   uuidList,
+		IsDraining:      s.isDraining,
+		Tags:            s.tags,
+		WriteLatencyUs:  uint64(writeLatency.Microseconds()),
+		InFlightWrites:  uint32(inFlightWrites),
+		RequestLoad:     s.requestLoad.report(time.Now()),
+		Weight:          s.weight,
 	}
 
 }
@@ -384,12 +469,22 @@ func (s *Store) Close() {
 }
 
 func (s *Store) WriteVolumeNeedle(i needle.VolumeId, n *needle.Needle, checkCookie bool, fsync bool) (isUnchanged bool, err error) {
-	if v := s.findVolume(i); v != nil {
+	if v, wal := s.findVolumeWithWal(i); v != nil {
 		if v.IsReadOnly() {
 			err = fmt.Errorf("volume %d is read only", i)
 			return
 		}
-		_, _, isUnchanged, err = v.writeNeedle2(n, checkCookie, fsync && s.isStopping)
+		if err = s.checkNotDraining(); err != nil {
+			return
+		}
+		done, loadDone := v.trackNeedleRequest(stats.WriteNeedle), s.writeLoad.begin()
+		_, _, isUnchanged, err = v.writeNeedle2(n, checkCookie, fsync && (s.isStopping || groupCommitDelay > 0) && wal == nil)
+		if err == nil && !isUnchanged && wal != nil {
+			err = wal.appendNeedle(v.Id, n, v.Version())
+		}
+		loadDone()
+		done(err)
+		s.requestLoad.record(stats.WriteNeedle, uint64(len(n.Data)), err)
 		return
 	}
 	glog.V(0).Infoln("volume", i, "not found!")
@@ -398,18 +493,29 @@ func (s *Store) WriteVolumeNeedle(i needle.VolumeId, n *needle.Needle, checkCook
 }
 
 func (s *Store) DeleteVolumeNeedle(i needle.VolumeId, n *needle.Needle) (Size, error) {
-	if v := s.findVolume(i); v != nil {
+	if v, wal := s.findVolumeWithWal(i); v != nil {
 		if v.noWriteOrDelete {
 			return 0, fmt.Errorf("volume %d is read only", i)
 		}
-		return v.deleteNeedle2(n)
+		done := v.trackNeedleRequest(stats.DeleteNeedle)
+		size, err := v.deleteNeedle2(n)
+		if err == nil && size > 0 && wal != nil {
+			err = wal.appendDelete(v.Id, n)
+		}
+		done(err)
+		s.requestLoad.record(stats.DeleteNeedle, 0, err)
+		return size, err
 	}
 	return 0, fmt.Errorf("volume %d not found on %s:%d", i, s.Ip, s.Port)
 }
 
-func (s *Store) ReadVolumeNeedle(i needle.VolumeId, n *needle.Needle, readOption *ReadOption, onReadSizeFn func(size Size)) (int, error) {
+func (s *Store) ReadVolumeNeedle(i needle.VolumeId, n *needle.Needle, readOption *ReadOption, onReadSizeFn func(size Size)) (count int, err error) {
 	if v := s.findVolume(i); v != nil {
-		return v.readNeedle(n, readOption, onReadSizeFn)
+		done := v.trackNeedleRequest(stats.ReadNeedle)
+		count, err = v.readNeedle(n, readOption, onReadSizeFn)
+		done(err)
+		s.requestLoad.record(stats.ReadNeedle, uint64(count), err)
+		return
 	}
 	return 0, fmt.Errorf("volume %d not found", i)
 }
@@ -423,7 +529,11 @@ func (s *Store) ReadVolumeNeedleMetaAt(i needle.VolumeId, n *needle.Needle, offs
 
 func (s *Store) ReadVolumeNeedleDataInto(i needle.VolumeId, n *needle.Needle, readOption *ReadOption, writer io.Writer, offset int64, size int64) error {
 	if v := s.findVolume(i); v != nil {
-		return v.readNeedleDataInto(n, readOption, writer, offset, size)
+		done := v.trackNeedleRequest(stats.ReadNeedle)
+		err := v.readNeedleDataInto(n, readOption, writer, offset, size)
+		done(err)
+		s.requestLoad.record(stats.ReadNeedle, uint64(size), err)
+		return err
 	}
 	return fmt.Errorf("volume %d not found", i)
 }
@@ -436,6 +546,12 @@ func (s *Store) HasVolume(i needle.VolumeId) bool {
 	return v != nil
 }
 
+// IsVolumeRemoteArchived tells whether the volume is tiered to an archived remote file being restored
+func (s *Store) IsVolumeRemoteArchived(i needle.VolumeId) bool {
+	v := s.findVolume(i)
+	return v != nil && v.IsRemoteArchived()
+}
+
 func (s *Store) MarkVolumeReadonly(i needle.VolumeId) error {
 	v := s.findVolume(i)
 	if v == nil {
@@ -444,6 +560,7 @@ func (s *Store) MarkVolumeReadonly(i needle.VolumeId) error {
 	v.noWriteLock.Lock()
 	v.noWriteOrDelete = true
 	v.noWriteLock.Unlock()
+	s.maybeUseSortedNeedleMap(v)
 	return nil
 }
 
@@ -452,6 +569,9 @@ func (s *Store) MarkVolumeWritable(i needle.VolumeId) error {
 	if v == nil {
 		return fmt.Errorf("volume %d not found", i)
 	}
+	if err := s.maybeUseInMemoryNeedleMap(v); err != nil {
+		return err
+	}
 	v.noWriteLock.Lock()
 	v.noWriteOrDelete = false
 	v.noWriteLock.Unlock()
@@ -463,6 +583,12 @@ func (s *Store) MountVolume(i needle.VolumeId) error {
 		if found := location.LoadVolume(i, s.NeedleMapKind); found == true {
 			glog.V(0).Infof("mount volume %d", i)
 			v := s.findVolume(i)
+			if v.isNoWrite() {
+				s.maybeUseSortedNeedleMap(v)
+			}
+			s.maybeUseNeedleFilter(v)
+			s.maybeOverwriteInPlace(v)
+			s.maybeUseDiskReadCache(location, v)
 			s.NewVolumesChan <- master_pb.VolumeShortInformationMessage{
 				Id:               uint32(v.Id),
 				Collection:       v.Collection,
@@ -0,0 +1,13 @@
+package storage
+
+// SetDeduplicatedCollections sets the collections whose new volumes store the identical needle contents once, "*" for all collections
+func (s *Store) SetDeduplicatedCollections(collections []string) {
+	s.deduplicatedCollections = make(map[string]bool)
+	for _, collection := range collections {
+		s.deduplicatedCollections[collection] = true
+	}
+}
+
+func (s *Store) shouldDeduplicate(collection string) bool {
+	return s.deduplicatedCollections["*"] || s.deduplicatedCollections[collection]
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+// CacheHardDriveReads reads the volumes on the hard drives through the read cache device, if enabled.
+// The encrypted volumes are skipped, so no plain text is kept on the cache device.
+func (s *Store) CacheHardDriveReads() {
+	if !backend.HasDiskReadCache() {
+		return
+	}
+	for _, location := range s.Locations {
+		location.volumesLock.RLock()
+		for _, v := range location.volumes {
+			s.maybeUseDiskReadCache(location, v)
+		}
+		location.volumesLock.RUnlock()
+	}
+}
+
+func (s *Store) maybeUseDiskReadCache(location *DiskLocation, v *Volume) {
+	if !backend.HasDiskReadCache() || location.DiskType != HardDriveType {
+		return
+	}
+	v.useDiskReadCache()
+}
+
+// useDiskReadCache wraps the data file, and wraps it again whenever the volume is reloaded
+func (v *Volume) useDiskReadCache() {
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+	v.hasDiskReadCache = true
+	if v.DataBackend != nil {
+		v.DataBackend = backend.MaybeCacheDiskFile(v.DataBackend)
+	}
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+// SetDraining stops or resumes accepting writes. While draining, the volumes are reported
+// read only to the master, which moves the volumes and ec shards to the other volume servers.
+func (s *Store) SetDraining(isDraining bool) {
+	if s.isDraining != isDraining {
+		glog.V(0).Infof("volume server %s:%d draining: %v", s.Ip, s.Port, isDraining)
+	}
+	s.isDraining = isDraining
+}
+
+func (s *Store) IsDraining() bool {
+	return s.isDraining
+}
+
+// IsEmpty tells whether no volumes or ec shards are left
+func (s *Store) IsEmpty() bool {
+	for _, location := range s.Locations {
+		if location.VolumesLen() > 0 || location.EcVolumesLen() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Store) checkNotDraining() error {
+	if s.isDraining {
+		return fmt.Errorf("volume server %s:%d is draining", s.Ip, s.Port)
+	}
+	return nil
+}
@@ -22,6 +22,14 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
 )
 
+// ecReadHedgeDelay is how long to wait for a remote ec shard, before also recovering it from the other shards
+var ecReadHedgeDelay time.Duration
+
+// SetEcReadHedgeDelay recovers the remote ec shards slower than the delay from the other shards, 0 to wait for the shards
+func SetEcReadHedgeDelay(delay time.Duration) {
+	ecReadHedgeDelay = delay
+}
+
 func (s *Store) CollectErasureCodingHeartbeat() *master_pb.Heartbeat {
 	var ecShardMessages []*master_pb.VolumeEcShardInformationMessage
 	collectionEcShardSize := make(map[string]int64)
@@ -54,12 +62,18 @@ func (s *Store) MountEcShards(collection string, vid needle.VolumeId, shardId er
 			glog.V(0).Infof("MountEcShards %d.%d", vid, shardId)
 
 			var shardBits erasure_coding.ShardBits
+			scheme := erasure_coding.DefaultScheme
+			if ecVolume, found := location.FindEcVolume(vid); found {
+				scheme = ecVolume.Scheme
+			}
 
 			s.NewEcShardsChan <- master_pb.VolumeEcShardInformationMessage{
-				Id:          uint32(vid),
-				Collection:  collection,
-				EcIndexBits: uint32(shardBits.AddShardId(shardId)),
-				DiskType:    string(location.DiskType),
+				Id:           uint32(vid),
+				Collection:   collection,
+				EcIndexBits:  uint32(shardBits.AddShardId(shardId)),
+				DiskType:     string(location.DiskType),
+				DataShards:   uint32(scheme.DataShards),
+				ParityShards: uint32(scheme.ParityShards),
 			}
 			return nil
 		} else if err == os.ErrNotExist {
@@ -136,41 +150,60 @@ func (s *Store) DestroyEcVolume(vid needle.VolumeId) {
 func (s *Store) ReadEcShardNeedle(vid needle.VolumeId, n *needle.Needle, onReadSizeFn func(size types.Size)) (int, error) {
 	for _, location := range s.Locations {
 		if localEcVolume, found := location.FindEcVolume(vid); found {
+			done := trackNeedleRequest(localEcVolume.Collection, location.Directory, stats.ReadNeedle)
+			count, err := s.readEcShardNeedle(vid, localEcVolume, n, onReadSizeFn)
+			done(err)
+			s.requestLoad.record(stats.ReadNeedle, uint64(count), err)
+			return count, err
+		}
+	}
+	return 0, fmt.Errorf("ec shard %d not found", vid)
+}
 
-			offset, size, intervals, err := localEcVolume.LocateEcShardNeedle(n.Id, localEcVolume.Version)
-			if err != nil {
-				return 0, fmt.Errorf("locate in local ec volume: %v", err)
-			}
-			if size.IsDeleted() {
-				return 0, ErrorDeleted
-			}
+func (s *Store) readEcShardNeedle(vid needle.VolumeId, localEcVolume *erasure_coding.EcVolume, n *needle.Needle, onReadSizeFn func(size types.Size)) (int, error) {
+	offset, size, intervals, err := localEcVolume.LocateEcShardNeedle(n.Id, localEcVolume.Version)
+	if err != nil {
+		return 0, fmt.Errorf("locate in local ec volume: %w", err)
+	}
+	if size.IsDeleted() {
+		return 0, ErrorDeleted
+	}
 
-			if onReadSizeFn != nil {
-				onReadSizeFn(size)
-			}
+	if onReadSizeFn != nil {
+		onReadSizeFn(size)
+	}
 
-			glog.V(3).Infof("read ec volume %d offset %d size %d intervals:%+v", vid, offset.ToActualOffset(), size, intervals)
+	glog.V(3).Infof("read ec volume %d offset %d size %d intervals:%+v", vid, offset.ToActualOffset(), size, intervals)
 
-			if len(intervals) > 1 {
-				glog.V(3).Infof("ReadEcShardNeedle needle id %s intervals:%+v", n.String(), intervals)
-			}
-			bytes, isDeleted, err := s.readEcShardIntervals(vid, n.Id, localEcVolume, intervals)
-			if err != nil {
-				return 0, fmt.Errorf("ReadEcShardIntervals: %v", err)
-			}
-			if isDeleted {
-				return 0, ErrorDeleted
-			}
+	if len(intervals) > 1 {
+		glog.V(3).Infof("ReadEcShardNeedle needle id %s intervals:%+v", n.String(), intervals)
+	}
+	bytes, isDeleted, err := s.readEcShardIntervals(vid, n.Id, localEcVolume, intervals)
+	if err != nil {
+		return 0, fmt.Errorf("ReadEcShardIntervals: %v", err)
+	}
+	if isDeleted {
+		return 0, ErrorDeleted
+	}
 
-			err = n.ReadBytes(bytes, offset.ToActualOffset(), size, localEcVolume.Version)
-			if err != nil {
-				return 0, fmt.Errorf("readbytes: %v", err)
-			}
+	err = n.ReadBytes(bytes, offset.ToActualOffset(), size, localEcVolume.Version)
+	if err != nil {
+		return 0, fmt.Errorf("readbytes: %v", err)
+	}
 
-			return len(bytes), nil
+	if localEcVolume.IsDeduplicated && !IsDedupBlobId(n.Id) {
+		blobId, refErr := blobRefOf(n)
+		if refErr != nil {
+			return 0, refErr
 		}
+		blob := &needle.Needle{Id: blobId}
+		if _, err = s.readEcShardNeedle(vid, localEcVolume, blob, nil); err != nil {
+			return 0, fmt.Errorf("read blob %s of needle %s: %v", blobId, n.Id, err)
+		}
+		n.Data, n.DataSize, n.Checksum = blob.Data, blob.DataSize, blob.Checksum
 	}
-	return 0, fmt.Errorf("ec shard %d not found", vid)
+
+	return len(bytes), nil
 }
 
 func (s *Store) readEcShardIntervals(vid needle.VolumeId, needleId types.NeedleId, ecVolume *erasure_coding.EcVolume, intervals []erasure_coding.Interval) (data []byte, is_deleted bool, err error) {
@@ -179,19 +212,32 @@ func (s *Store) readEcShardIntervals(vid needle.VolumeId, needleId types.NeedleI
 		return nil, false, fmt.Errorf("failed to locate shard via master grpc %s: %v", s.MasterAddress, err)
 	}
 
+	if len(intervals) == 1 {
+		return s.readOneEcShardInterval(needleId, ecVolume, intervals[0])
+	}
+
+	// the intervals are on different shards, and read at the same time
+	datas := make([][]byte, len(intervals))
+	deletes := make([]bool, len(intervals))
+	errs := make([]error, len(intervals))
+	var wg sync.WaitGroup
 	for i, interval := range intervals {
-		if d, isDeleted, e := s.readOneEcShardInterval(needleId, ecVolume, interval); e != nil {
-			return nil, isDeleted, e
-		} else {
-			if isDeleted {
-				is_deleted = true
-			}
-			if i == 0 {
-				data = d
-			} else {
-				data = append(data, d...)
-			}
+		wg.Add(1)
+		go func(i int, interval erasure_coding.Interval) {
+			defer wg.Done()
+			datas[i], deletes[i], errs[i] = s.readOneEcShardInterval(needleId, ecVolume, interval)
+		}(i, interval)
+	}
+	wg.Wait()
+
+	for i := range intervals {
+		if errs[i] != nil {
+			return nil, deletes[i], errs[i]
 		}
+		if deletes[i] {
+			is_deleted = true
+		}
+		data = append(data, datas[i]...)
 	}
 	return
 }
@@ -204,26 +250,89 @@ func (s *Store) readOneEcShardInterval(needleId types.NeedleId, ecVolume *erasur
 			glog.V(0).Infof("read local ec shard %d.%d offset %d: %v", ecVolume.VolumeId, shardId, actualOffset, err)
 			return
 		}
+		stats.VolumeServerEcReadCounter.WithLabelValues("local").Inc()
 	} else {
-		ecVolume.ShardLocationsLock.RLock()
-		sourceDataNodes, hasShardIdLocation := ecVolume.ShardLocations[shardId]
-		ecVolume.ShardLocationsLock.RUnlock()
-
-		// try reading directly
-		if hasShardIdLocation {
-			_, is_deleted, err = s.readRemoteEcShardInterval(sourceDataNodes, needleId, ecVolume.VolumeId, shardId, data, actualOffset)
-			if err == nil {
-				return
-			}
-			glog.V(0).Infof("clearing ec shard %d.%d locations: %v", ecVolume.VolumeId, shardId, err)
+		is_deleted, err = s.readRemoteOrRecoverEcShardInterval(needleId, ecVolume, shardId, data, actualOffset)
+	}
+	return
+}
+
+type ecShardIntervalRead struct {
+	data        []byte
+	isDeleted   bool
+	err         error
+	isRecovered bool
+}
+
+// readRemoteOrRecoverEcShardInterval reads the shard from its volume servers, and if it is missing,
+// fails, or is slower than the hedge delay, recovers it from the other shards at the same time.
+// The first successful read is used, and the other one is canceled.
+func (s *Store) readRemoteOrRecoverEcShardInterval(needleId types.NeedleId, ecVolume *erasure_coding.EcVolume, shardId erasure_coding.ShardId, buf []byte, offset int64) (is_deleted bool, err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ecVolume.ShardLocationsLock.RLock()
+	sourceDataNodes, hasShardIdLocation := ecVolume.ShardLocations[shardId]
+	ecVolume.ShardLocationsLock.RUnlock()
+
+	reads := make(chan ecShardIntervalRead, 2)
+	pending := 0
+	isRecovering := false
+	startRecovering := func() {
+		isRecovering = true
+		pending++
+		go func() {
+			data := make([]byte, len(buf))
+			_, isDeleted, recoverErr := s.recoverOneRemoteEcShardInterval(ctx, needleId, ecVolume, shardId, data, offset)
+			reads <- ecShardIntervalRead{data: data, isDeleted: isDeleted, err: recoverErr, isRecovered: true}
+		}()
+	}
+
+	var hedge <-chan time.Time
+	if hasShardIdLocation {
+		pending++
+		go func() {
+			data := make([]byte, len(buf))
+			_, isDeleted, readErr := s.readRemoteEcShardInterval(ctx, sourceDataNodes, needleId, ecVolume.VolumeId, shardId, data, offset)
+			reads <- ecShardIntervalRead{data: data, isDeleted: isDeleted, err: readErr}
+		}()
+		if ecReadHedgeDelay > 0 {
+			hedgeTimer := time.NewTimer(ecReadHedgeDelay)
+			defer hedgeTimer.Stop()
+			hedge = hedgeTimer.C
 		}
+	} else {
+		startRecovering()
+	}
 
-		// try reading by recovering from other shards
-		_, is_deleted, err = s.recoverOneRemoteEcShardInterval(needleId, ecVolume, shardId, data, actualOffset)
-		if err == nil {
-			return
+	for pending > 0 {
+		select {
+		case <-hedge:
+			if !isRecovering {
+				stats.VolumeServerEcReadCounter.WithLabelValues("hedged").Inc()
+				startRecovering()
+			}
+		case read := <-reads:
+			pending--
+			if read.err == nil {
+				if read.isRecovered {
+					stats.VolumeServerEcReadCounter.WithLabelValues("recovered").Inc()
+				} else {
+					stats.VolumeServerEcReadCounter.WithLabelValues("remote").Inc()
+				}
+				copy(buf, read.data)
+				return read.isDeleted, nil
+			}
+			is_deleted, err = read.isDeleted, read.err
+			if read.isRecovered {
+				glog.V(0).Infof("recover ec shard %d.%d : %v", ecVolume.VolumeId, shardId, read.err)
+			} else {
+				glog.V(0).Infof("clearing ec shard %d.%d locations: %v", ecVolume.VolumeId, shardId, read.err)
+				if !isRecovering {
+					startRecovering()
+				}
+			}
 		}
-		glog.V(0).Infof("recover ec shard %d.%d : %v", ecVolume.VolumeId, shardId, err)
 	}
 	return
 }
@@ -237,12 +346,13 @@ func forgetShardId(ecVolume *erasure_coding.EcVolume, shardId erasure_coding.Sha
 
 func (s *Store) cachedLookupEcShardLocations(ecVolume *erasure_coding.EcVolume) (err error) {
 
+	scheme := ecVolume.Scheme
 	shardCount := len(ecVolume.ShardLocations)
-	if shardCount < erasure_coding.DataShardsCount &&
+	if shardCount < scheme.DataShards &&
 		ecVolume.ShardLocationsRefreshTime.Add(11*time.Second).After(time.Now()) ||
-		shardCount == erasure_coding.TotalShardsCount &&
+		shardCount == scheme.TotalShards() &&
 			ecVolume.ShardLocationsRefreshTime.Add(37*time.Minute).After(time.Now()) ||
-		shardCount >= erasure_coding.DataShardsCount &&
+		shardCount >= scheme.DataShards &&
 			ecVolume.ShardLocationsRefreshTime.Add(7*time.Minute).After(time.Now()) {
 		// still fresh
 		return nil
@@ -258,8 +368,8 @@ func (s *Store) cachedLookupEcShardLocations(ecVolume *erasure_coding.EcVolume)
 		if err != nil {
 			return fmt.Errorf("lookup ec volume %d: %v", ecVolume.VolumeId, err)
 		}
-		if len(resp.ShardIdLocations) < erasure_coding.DataShardsCount {
-			return fmt.Errorf("only %d shards found but %d required", len(resp.ShardIdLocations), erasure_coding.DataShardsCount)
+		if len(resp.ShardIdLocations) < scheme.DataShards {
+			return fmt.Errorf("only %d shards found but %d required", len(resp.ShardIdLocations), scheme.DataShards)
 		}
 
 		ecVolume.ShardLocationsLock.Lock()
@@ -278,7 +388,7 @@ func (s *Store) cachedLookupEcShardLocations(ecVolume *erasure_coding.EcVolume)
 	return
 }
 
-func (s *Store) readRemoteEcShardInterval(sourceDataNodes []pb.ServerAddress, needleId types.NeedleId, vid needle.VolumeId, shardId erasure_coding.ShardId, buf []byte, offset int64) (n int, is_deleted bool, err error) {
+func (s *Store) readRemoteEcShardInterval(ctx context.Context, sourceDataNodes []pb.ServerAddress, needleId types.NeedleId, vid needle.VolumeId, shardId erasure_coding.ShardId, buf []byte, offset int64) (n int, is_deleted bool, err error) {
 
 	if len(sourceDataNodes) == 0 {
 		return 0, false, fmt.Errorf("failed to find ec shard %d.%d", vid, shardId)
@@ -286,8 +396,8 @@ func (s *Store) readRemoteEcShardInterval(sourceDataNodes []pb.ServerAddress, ne
 
 	for _, sourceDataNode := range sourceDataNodes {
 		glog.V(3).Infof("read remote ec shard %d.%d from %s", vid, shardId, sourceDataNode)
-		n, is_deleted, err = s.doReadRemoteEcShardInterval(sourceDataNode, needleId, vid, shardId, buf, offset)
-		if err == nil {
+		n, is_deleted, err = s.doReadRemoteEcShardInterval(ctx, sourceDataNode, needleId, vid, shardId, buf, offset)
+		if err == nil || ctx.Err() != nil {
 			return
 		}
 		glog.V(1).Infof("read remote ec shard %d.%d from %s: %v", vid, shardId, sourceDataNode, err)
@@ -296,12 +406,12 @@ func (s *Store) readRemoteEcShardInterval(sourceDataNodes []pb.ServerAddress, ne
 	return
 }
 
-func (s *Store) doReadRemoteEcShardInterval(sourceDataNode pb.ServerAddress, needleId types.NeedleId, vid needle.VolumeId, shardId erasure_coding.ShardId, buf []byte, offset int64) (n int, is_deleted bool, err error) {
+func (s *Store) doReadRemoteEcShardInterval(ctx context.Context, sourceDataNode pb.ServerAddress, needleId types.NeedleId, vid needle.VolumeId, shardId erasure_coding.ShardId, buf []byte, offset int64) (n int, is_deleted bool, err error) {
 
 	err = operation.WithVolumeServerClient(false, sourceDataNode, s.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
 
 		// copy data slice
-		shardReadClient, err := client.VolumeEcShardRead(context.Background(), &volume_server_pb.VolumeEcShardReadRequest{
+		shardReadClient, err := client.VolumeEcShardRead(ctx, &volume_server_pb.VolumeEcShardReadRequest{
 			VolumeId: uint32(vid),
 			ShardId:  uint32(shardId),
 			Offset:   offset,
@@ -336,50 +446,87 @@ func (s *Store) doReadRemoteEcShardInterval(sourceDataNode pb.ServerAddress, nee
 	return
 }
 
-func (s *Store) recoverOneRemoteEcShardInterval(needleId types.NeedleId, ecVolume *erasure_coding.EcVolume, shardIdToRecover erasure_coding.ShardId, buf []byte, offset int64) (n int, is_deleted bool, err error) {
+type ecShardRead struct {
+	shardId   erasure_coding.ShardId
+	data      []byte
+	isDeleted bool
+}
+
+// recoverOneRemoteEcShardInterval reads the other shards at the same time, and reconstructs the shard
+// once enough of them are read, canceling the slower reads.
+func (s *Store) recoverOneRemoteEcShardInterval(ctx context.Context, needleId types.NeedleId, ecVolume *erasure_coding.EcVolume, shardIdToRecover erasure_coding.ShardId, buf []byte, offset int64) (n int, is_deleted bool, err error) {
 	glog.V(3).Infof("recover ec shard %d.%d from other locations", ecVolume.VolumeId, shardIdToRecover)
 
-	enc, err := reedsolomon.New(erasure_coding.DataShardsCount, erasure_coding.ParityShardsCount)
+	enc, err := reedsolomon.New(ecVolume.Scheme.DataShards, ecVolume.Scheme.ParityShards)
 	if err != nil {
 		return 0, false, fmt.Errorf("failed to create encoder: %v", err)
 	}
 
-	bufs := make([][]byte, erasure_coding.TotalShardsCount)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	var wg sync.WaitGroup
+	reads := make(chan ecShardRead, ecVolume.Scheme.TotalShards())
+	readShard := func(shardId erasure_coding.ShardId, read func(data []byte) (int, bool, error)) {
+		data := make([]byte, len(buf))
+		nRead, isDeleted, readErr := read(data)
+		if readErr != nil || nRead != len(buf) {
+			data = nil
+		}
+		reads <- ecShardRead{shardId: shardId, data: data, isDeleted: isDeleted}
+	}
+
+	pending := 0
 	ecVolume.ShardLocationsLock.RLock()
-	for shardId, locations := range ecVolume.ShardLocations {
+	for id := 0; id < ecVolume.Scheme.TotalShards(); id++ {
+		shardId := erasure_coding.ShardId(id)
 
-		// skip current shard or empty shard
+		// skip current shard
 		if shardId == shardIdToRecover {
 			continue
 		}
+
+		// read the local shard directly
+		if shard, found := ecVolume.FindEcVolumeShard(shardId); found {
+			pending++
+			go readShard(shardId, func(data []byte) (int, bool, error) {
+				nRead, readErr := shard.ReadAt(data, offset)
+				return nRead, false, readErr
+			})
+			continue
+		}
+
+		// skip empty shard
+		locations := ecVolume.ShardLocations[shardId]
 		if len(locations) == 0 {
 			glog.V(3).Infof("readRemoteEcShardInterval missing %d.%d from %+v", ecVolume.VolumeId, shardId, locations)
 			continue
 		}
 
 		// read from remote locations
-		wg.Add(1)
-		go func(shardId erasure_coding.ShardId, locations []pb.ServerAddress) {
-			defer wg.Done()
-			data := make([]byte, len(buf))
-			nRead, isDeleted, readErr := s.readRemoteEcShardInterval(locations, needleId, ecVolume.VolumeId, shardId, data, offset)
-			if readErr != nil {
+		pending++
+		go readShard(shardId, func(data []byte) (int, bool, error) {
+			nRead, isDeleted, readErr := s.readRemoteEcShardInterval(ctx, locations, needleId, ecVolume.VolumeId, shardId, data, offset)
+			if readErr != nil && ctx.Err() == nil {
 				glog.V(3).Infof("recover: readRemoteEcShardInterval %d.%d %d bytes from %+v: %v", ecVolume.VolumeId, shardId, nRead, locations, readErr)
 				forgetShardId(ecVolume, shardId)
 			}
-			if isDeleted {
-				is_deleted = true
-			}
-			if nRead == len(buf) {
-				bufs[shardId] = data
-			}
-		}(shardId, locations)
+			return nRead, isDeleted, readErr
+		})
 	}
 	ecVolume.ShardLocationsLock.RUnlock()
 
-	wg.Wait()
+	bufs := make([][]byte, ecVolume.Scheme.TotalShards())
+	readCount := 0
+	for ; pending > 0 && readCount < ecVolume.Scheme.DataShards; pending-- {
+		read := <-reads
+		if read.isDeleted {
+			is_deleted = true
+		}
+		if read.data != nil {
+			bufs[read.shardId] = read.data
+			readCount++
+		}
+	}
 
 	if err = enc.ReconstructData(bufs); err != nil {
 		glog.V(3).Infof("recovered ec shard %d.%d failed: %v", ecVolume.VolumeId, shardIdToRecover, err)
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/storage/volume_info"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestReadEcShardNeedleRecovered(t *testing.T) {
+	s := NewStore(nil, "localhost", 8080, 18080, "localhost:8080", []string{t.TempDir()}, []int32{10},
+		[]util.MinFreeSpace{{}}, "", NeedleMapInMemory, []types.DiskType{types.HardDriveType}, 0)
+	defer s.Close()
+	if err := s.SetChecksumAlgorithm("xxhash64"); err != nil {
+		t.Fatalf("set checksum algorithm: %v", err)
+	}
+	if err := s.AddVolume(1, "", NeedleMapInMemory, "000", "", 0, 0, types.HardDriveType, 0); err != nil {
+		t.Fatalf("add volume: %v", err)
+	}
+	<-s.NewVolumesChan
+
+	// the large needle spans the small blocks of several data shards
+	data := make([]byte, 3*erasure_coding.ErasureCodingSmallBlockSize)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	large := &needle.Needle{Id: 1, Cookie: 0x12345678, Data: data, Checksum: needle.NewCRC(data)}
+	if _, err := s.WriteVolumeNeedle(1, large, false, false); err != nil {
+		t.Fatalf("write needle: %v", err)
+	}
+	if _, err := s.WriteVolumeNeedle(1, newRandomNeedle(2), false, false); err != nil {
+		t.Fatalf("write needle: %v", err)
+	}
+	v := s.GetVolume(1)
+	v.SyncToDisk()
+	baseFileName := v.FileName("")
+	if err := erasure_coding.WriteSortedFileFromIdx(baseFileName, ".ecx"); err != nil {
+		t.Fatalf("write ecx: %v", err)
+	}
+	if err := erasure_coding.WriteEcFiles(baseFileName, erasure_coding.DefaultScheme); err != nil {
+		t.Fatalf("write ec files: %v", err)
+	}
+	if err := volume_info.SaveVolumeInfo(baseFileName+".vif", &volume_server_pb.VolumeInfo{
+		Version:           uint32(v.Version()),
+		ChecksumAlgorithm: v.ChecksumAlgorithm().String(),
+	}); err != nil {
+		t.Fatalf("save volume info: %v", err)
+	}
+
+	// the data shard 1 is lost, and recovered from the other local shards
+	if err := os.Remove(baseFileName + erasure_coding.ToExt(1)); err != nil {
+		t.Fatalf("remove shard 1: %v", err)
+	}
+	location := s.Locations[0]
+	for shardId := 0; shardId < erasure_coding.TotalShardsCount; shardId++ {
+		if shardId == 1 {
+			continue
+		}
+		if err := location.LoadEcShard("", 1, erasure_coding.ShardId(shardId)); err != nil {
+			t.Fatalf("load ec shard %d: %v", shardId, err)
+		}
+	}
+	ecVolume, _ := location.FindEcVolume(1)
+	if ecVolume.ChecksumAlgorithm != needle.ChecksumXxHash64 {
+		t.Errorf("ec volume checksum algorithm %v", ecVolume.ChecksumAlgorithm)
+	}
+	// no shard on the other volume servers
+	ecVolume.ShardLocationsRefreshTime = time.Now()
+
+	n := &needle.Needle{Id: 1}
+	if _, err := s.ReadEcShardNeedle(1, n, nil); err != nil {
+		t.Fatalf("read ec needle: %v", err)
+	}
+	if n.Cookie != large.Cookie || !bytes.Equal(n.Data, data) {
+		t.Fatalf("read ec needle with cookie %x and %d bytes", n.Cookie, len(n.Data))
+	}
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+// SetEncryptedCollections sets the collections whose new volumes are encrypted, "*" for all collections
+func (s *Store) SetEncryptedCollections(collections []string) error {
+	if len(collections) > 0 && volumeKeyManager == nil {
+		return fmt.Errorf("no key manager is configured under volume.kms to encrypt collections %v", collections)
+	}
+	s.encryptedCollections = make(map[string]bool)
+	for _, collection := range collections {
+		s.encryptedCollections[collection] = true
+	}
+	return nil
+}
+
+func (s *Store) shouldEncrypt(collection string) bool {
+	return s.encryptedCollections["*"] || s.encryptedCollections[collection]
+}
+
+func (s *Store) RotateEncryptionKey(vid needle.VolumeId) (previousKeyId, keyId string, err error) {
+	v := s.findVolume(vid)
+	if v == nil {
+		return "", "", fmt.Errorf("volume %d not found", vid)
+	}
+	return v.RotateEncryptionKey()
+}
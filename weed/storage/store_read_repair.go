@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+// A read finding the needle corrupted or missing in a replicated volume queues a read repair,
+// which checks the local copy again, and appends a healthy copy from one of the replicas.
+// The client still fails over to the other replicas, but the bad copy is not left in place.
+// The queue is bounded, and the repairs are dropped when it is full, since the scrubbing finds the corruptions later.
+
+const readRepairQueueSize = 1024
+
+const (
+	readRepairCorrupted = "corrupted"
+	readRepairMissing   = "missing"
+)
+
+type readRepair struct {
+	volumeId needle.VolumeId
+	needleId NeedleId
+	reason   string
+}
+
+// readRepairStatus is what the read repairs fixed in the volume, reported to the master
+type readRepairStatus struct {
+	repairedCount    uint64
+	repairedAtSecond int64
+}
+
+func (v *Volume) readRepaired() {
+	atomic.AddUint64(&v.readRepairStatus.repairedCount, 1)
+	atomic.StoreInt64(&v.readRepairStatus.repairedAtSecond, time.Now().Unix())
+}
+
+func (v *Volume) ReadRepairStatus() (repairedCount uint64, repairedAtSecond int64) {
+	return atomic.LoadUint64(&v.readRepairStatus.repairedCount), atomic.LoadInt64(&v.readRepairStatus.repairedAtSecond)
+}
+
+// StartReadRepair starts to repair the needles queued by the failed reads
+func (s *Store) StartReadRepair() {
+	s.readRepairPending = make(map[readRepair]bool)
+	s.readRepairChan = make(chan readRepair, readRepairQueueSize)
+	go func() {
+		for r := range s.readRepairChan {
+			s.repairOnRead(r)
+			s.readRepairLock.Lock()
+			delete(s.readRepairPending, r)
+			s.readRepairLock.Unlock()
+		}
+	}()
+}
+
+// QueueReadRepair queues to repair the needle from the replicas, after reading it failed with the error
+func (s *Store) QueueReadRepair(vid needle.VolumeId, needleId NeedleId, readErr error) {
+	if s.readRepairChan == nil || readErr == ErrorDeleted {
+		return
+	}
+	v := s.findVolume(vid)
+	if v == nil || !v.NeedToReplicate() || v.HasRemoteFile() {
+		return
+	}
+	r := readRepair{volumeId: vid, needleId: needleId, reason: readRepairCorrupted}
+	if readErr == ErrorNotFound {
+		r.reason = readRepairMissing
+	}
+
+	s.readRepairLock.Lock()
+	defer s.readRepairLock.Unlock()
+	if s.readRepairPending[r] {
+		return
+	}
+	select {
+	case s.readRepairChan <- r:
+		s.readRepairPending[r] = true
+	default:
+		stats.VolumeServerReadRepairCounter.WithLabelValues(v.Collection, r.reason, "dropped").Inc()
+	}
+}
+
+func (s *Store) repairOnRead(r readRepair) {
+	v := s.findVolume(r.volumeId)
+	if v == nil {
+		return
+	}
+	if !v.needsReadRepair(r) {
+		stats.VolumeServerReadRepairCounter.WithLabelValues(v.Collection, r.reason, "healthy").Inc()
+		return
+	}
+	if err := s.repairNeedleFromReplicas(v, r.needleId); err != nil {
+		if r.reason == readRepairMissing {
+			// not written to any replica, or deleted from all of them
+			stats.VolumeServerReadRepairCounter.WithLabelValues(v.Collection, r.reason, "absent").Inc()
+			glog.V(1).Infof("read repair volume %d missing needle %s: %v", v.Id, r.needleId, err)
+			return
+		}
+		stats.VolumeServerReadRepairCounter.WithLabelValues(v.Collection, r.reason, "unrepaired").Inc()
+		glog.Errorf("read repair volume %d corrupted needle %s: %v", v.Id, r.needleId, err)
+		return
+	}
+	v.readRepaired()
+	stats.VolumeServerReadRepairCounter.WithLabelValues(v.Collection, r.reason, "repaired").Inc()
+	glog.V(0).Infof("read repaired volume %d %s needle %s from replicas", v.Id, r.reason, r.needleId)
+}
+
+// needsReadRepair checks the local copy of the needle again, since the read may have failed for other reasons,
+// e.g. the needle expired, or was written or deleted after the read.
+func (v *Volume) needsReadRepair(r readRepair) bool {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+
+	if v.nm == nil || v.DataBackend == nil {
+		// closed
+		return false
+	}
+	nv, ok := v.nm.Get(r.needleId)
+	if !ok || nv.Offset.IsZero() {
+		return r.reason == readRepairMissing
+	}
+	if nv.Size.IsDeleted() || r.reason == readRepairMissing {
+		return false
+	}
+	_, err := v.readVerifiedNeedleBlob(r.needleId, nv.Offset.ToActualOffset(), nv.Size)
+	return err != nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func TestNeedsReadRepair(t *testing.T) {
+	dir := t.TempDir()
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+
+	for i := 1; i <= 3; i++ {
+		if _, _, _, err := v.writeNeedle2(newRandomNeedle(uint64(i)), true, false); err != nil {
+			t.Fatalf("write needle %d: %v", i, err)
+		}
+	}
+	if _, err := v.deleteNeedle2(&needle.Needle{Id: 2}); err != nil {
+		t.Fatalf("delete needle 2: %v", err)
+	}
+
+	// flip one byte of the data of needle 3
+	nv, _ := v.nm.Get(3)
+	dataOffset := nv.Offset.ToActualOffset() + types.NeedleHeaderSize + 4
+	b := make([]byte, 1)
+	v.DataBackend.ReadAt(b, dataOffset)
+	b[0] ^= 0xff
+	v.DataBackend.WriteAt(b, dataOffset)
+
+	tests := []struct {
+		needleId types.NeedleId
+		reason   string
+		expected bool
+	}{
+		{1, readRepairCorrupted, false}, // healthy
+		{1, readRepairMissing, false},   // written after the read
+		{2, readRepairMissing, false},   // deleted
+		{3, readRepairCorrupted, true},
+		{4, readRepairMissing, true},
+	}
+	for _, tt := range tests {
+		if actual := v.needsReadRepair(readRepair{volumeId: 1, needleId: tt.needleId, reason: tt.reason}); actual != tt.expected {
+			t.Errorf("needle %s %s: needs read repair %v, expected %v", tt.needleId, tt.reason, actual, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+const (
+	// the read only volumes look up the needles in the sorted index file with pread
+	ReadOnlyIndexSorted = "sorted"
+	// the read only volumes look up the needles in the memory mapped sorted index file
+	ReadOnlyIndexMmap = "mmap"
+	// the read only volumes look up the needles in the compact index file loaded in memory
+	ReadOnlyIndexCompact = "compact"
+)
+
+// SetReadOnlyIndex looks up the needles of the read only volumes in the sorted .sdx or the compact .cdx files,
+// including the volumes marked read only at runtime, whose in memory index is released.
+// Empty keeps the index of the volumes marked read only in memory.
+func (s *Store) SetReadOnlyIndex(readOnlyIndex string) error {
+	switch readOnlyIndex {
+	case "", ReadOnlyIndexSorted, ReadOnlyIndexMmap, ReadOnlyIndexCompact:
+	default:
+		return fmt.Errorf("unknown read only index %q, expecting %q, %q or %q", readOnlyIndex, ReadOnlyIndexSorted, ReadOnlyIndexMmap, ReadOnlyIndexCompact)
+	}
+	s.readOnlyIndex = readOnlyIndex
+	if readOnlyIndex == "" {
+		return nil
+	}
+	for _, location := range s.Locations {
+		for _, v := range location.readOnlyVolumes() {
+			s.maybeUseSortedNeedleMap(v)
+		}
+	}
+	return nil
+}
+
+func (l *DiskLocation) readOnlyVolumes() (volumes []*Volume) {
+	l.volumesLock.RLock()
+	defer l.volumesLock.RUnlock()
+	for _, v := range l.volumes {
+		if v.isNoWrite() {
+			volumes = append(volumes, v)
+		}
+	}
+	return
+}
+
+func (s *Store) maybeUseSortedNeedleMap(v *Volume) {
+	if s.readOnlyIndex == "" {
+		return
+	}
+	var err error
+	if s.readOnlyIndex == ReadOnlyIndexCompact {
+		err = v.useCompactNeedleMap()
+	} else {
+		err = v.useSortedNeedleMap(s.readOnlyIndex == ReadOnlyIndexMmap)
+	}
+	if err != nil {
+		glog.Warningf("volume %d keeps the index in memory: %v", v.Id, err)
+	}
+}
+
+func (s *Store) maybeUseInMemoryNeedleMap(v *Volume) error {
+	if s.readOnlyIndex == "" {
+		return nil
+	}
+	return v.useInMemoryNeedleMap()
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// A replicated volume tails the needles appended to each of its replicas, and applies the ones it is missing,
+// so the replicas converge within seconds after a failed replicated write, and catch up after a short outage.
+// The .sync file keeps the append timestamp of the last needle applied from each replica,
+// so a restarted stream only transfers the needles appended since then.
+// Each replica stamps its own append time, so the needles already having the same size are taken as replicated.
+
+// CanStreamFromReplicas tells whether the volume applies the needles tailed from its replicas.
+// The deduplicated volumes are skipped, since their needles refer to the blob needles.
+func (v *Volume) CanStreamFromReplicas() bool {
+	return v.NeedToReplicate() && !v.IsReadOnly() && !v.HasRemoteFile() && !v.IsDeduplicated()
+}
+
+// ReplicaStreamVolumes lists the volumes applying the needles tailed from their replicas
+func (s *Store) ReplicaStreamVolumes() (volumes []*Volume) {
+	for _, location := range s.Locations {
+		location.volumesLock.RLock()
+		for _, v := range location.volumes {
+			if v.CanStreamFromReplicas() {
+				volumes = append(volumes, v)
+			}
+		}
+		location.volumesLock.RUnlock()
+	}
+	return
+}
+
+// LoadReplicaStreamProgress reads the append timestamp of the last needle applied from each replica
+func (v *Volume) LoadReplicaStreamProgress() (sinceNs map[string]uint64, err error) {
+	sinceNs = make(map[string]uint64)
+	data, err := os.ReadFile(v.FileName(".sync"))
+	if os.IsNotExist(err) {
+		return sinceNs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &sinceNs); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", v.FileName(".sync"), err)
+	}
+	return sinceNs, nil
+}
+
+func (v *Volume) SaveReplicaStreamProgress(sinceNs map[string]uint64) error {
+	data, err := json.Marshal(sinceNs)
+	if err != nil {
+		return err
+	}
+	return util.WriteFile(v.FileName(".sync"), data, 0644)
+}
+
+// LastAppendAtNs is the append timestamp of the last needle written to the volume
+func (v *Volume) LastAppendAtNs() uint64 {
+	return v.getLastAppendAtNs()
+}
+
+// ApplyReplicatedNeedle writes or deletes the needle tailed from a replica, if it is newer than the local copy.
+// A needle without data is a deletion.
+func (s *Store) ApplyReplicatedNeedle(vid needle.VolumeId, n *needle.Needle) (applied bool, err error) {
+	v := s.findVolume(vid)
+	if v == nil {
+		return false, fmt.Errorf("volume %d not found", vid)
+	}
+	isDeletion := n.Size == 0
+	if !v.isNewerThanLocal(n, isDeletion) {
+		return false, nil
+	}
+	if isDeletion {
+		_, err = s.DeleteVolumeNeedle(vid, n)
+		return err == nil, err
+	}
+	isUnchanged, err := s.WriteVolumeNeedle(vid, n, false, false)
+	return err == nil && !isUnchanged, err
+}
+
+// isNewerThanLocal compares the needle tailed from a replica with the local copy.
+// A local deletion wins, since the deletion is streamed to the replica as well.
+func (v *Volume) isNewerThanLocal(n *needle.Needle, isDeletion bool) bool {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+
+	if v.nm == nil {
+		// closed
+		return false
+	}
+	nv, ok := v.nm.Get(n.Id)
+	if !ok || nv.Offset.IsZero() {
+		return !isDeletion
+	}
+	if nv.Size.IsDeleted() {
+		return false
+	}
+	if !isDeletion && nv.Size == n.Size {
+		return false
+	}
+	if v.Version() != needle.Version3 {
+		return true
+	}
+	localAppendAtNs, err := readNeedleAppendAtNs(v.DataBackend, nv.Offset.ToActualOffset(), nv.Size)
+	return err != nil || localAppendAtNs < n.AppendAtNs
+}
+
+// readNeedleAppendAtNs reads the append timestamp after the needle data and checksum, of the version 3 needle
+func readNeedleAppendAtNs(r backend.BackendStorageFile, offset int64, size Size) (uint64, error) {
+	b := make([]byte, TimestampSize)
+	if _, err := r.ReadAt(b, offset+NeedleHeaderSize+int64(size)+needle.NeedleChecksumSize); err != nil {
+		return 0, err
+	}
+	return util.BytesToUint64(b), nil
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// replicaTailer applies the needles of the replica volume file to the local store, as tailed by the replica stream
+type replicaTailer struct {
+	local   *Store
+	applied []types.NeedleId
+	t       *testing.T
+}
+
+func (r *replicaTailer) VisitSuperBlock(super_block.SuperBlock) error { return nil }
+func (r *replicaTailer) ReadNeedleBody() bool                         { return true }
+func (r *replicaTailer) VisitNeedle(_ *needle.Needle, _ int64, needleHeader, needleBody []byte) error {
+	n := new(needle.Needle)
+	n.ParseNeedleHeader(needleHeader)
+	if err := n.ReadNeedleBodyBytes(needleBody, needle.CurrentVersion); err != nil {
+		return err
+	}
+	applied, err := r.local.ApplyReplicatedNeedle(1, n)
+	if err != nil {
+		r.t.Fatalf("apply needle %s: %v", n.Id, err)
+	}
+	if applied {
+		r.applied = append(r.applied, n.Id)
+	}
+	return nil
+}
+
+func newReplicaStreamStore(t *testing.T) *Store {
+	s := NewStore(nil, "localhost", 8080, 18080, "localhost:8080", []string{t.TempDir()}, []int32{10},
+		[]util.MinFreeSpace{{}}, "", NeedleMapInMemory, []types.DiskType{types.HardDriveType}, 0)
+	if err := s.AddVolume(1, "", NeedleMapInMemory, "001", "", 0, 0, types.HardDriveType, 0); err != nil {
+		t.Fatalf("add volume: %v", err)
+	}
+	<-s.NewVolumesChan
+	return s
+}
+
+func TestApplyReplicatedNeedle(t *testing.T) {
+	local, peer := newReplicaStreamStore(t), newReplicaStreamStore(t)
+	defer local.Close()
+	defer peer.Close()
+
+	for i := uint64(1); i <= 3; i++ {
+		if _, err := peer.WriteVolumeNeedle(1, newRandomNeedle(i), false, false); err != nil {
+			t.Fatalf("write peer needle %d: %v", i, err)
+		}
+	}
+	// needle 1 is replicated, needle 2 is missed, and needle 3 is deleted locally
+	n := new(needle.Needle)
+	n.Id = 1
+	if _, err := peer.ReadVolumeNeedle(1, n, nil, nil); err != nil {
+		t.Fatalf("read peer needle 1: %v", err)
+	}
+	if _, err := local.WriteVolumeNeedle(1, &needle.Needle{Id: 1, Data: n.Data, Checksum: n.Checksum}, false, false); err != nil {
+		t.Fatalf("write local needle 1: %v", err)
+	}
+	if _, err := local.WriteVolumeNeedle(1, newRandomNeedle(3), false, false); err != nil {
+		t.Fatalf("write local needle 3: %v", err)
+	}
+	if _, err := local.DeleteVolumeNeedle(1, newEmptyNeedle(3)); err != nil {
+		t.Fatalf("delete local needle 3: %v", err)
+	}
+
+	tail := func() []types.NeedleId {
+		v := peer.GetVolume(1)
+		tailer := &replicaTailer{local: local, t: t}
+		if err := ScanVolumeFileFrom(v.Version(), v.DataBackend, super_block.SuperBlockSize, tailer); err != nil {
+			t.Fatalf("scan peer volume: %v", err)
+		}
+		return tailer.applied
+	}
+	if applied := tail(); len(applied) != 1 || applied[0] != 2 {
+		t.Fatalf("applied needles %v, expected [2]", applied)
+	}
+	if _, _, err := local.GetVolume(1).ReadVerifiedNeedleBlob(2); err != nil {
+		t.Fatalf("read applied needle 2: %v", err)
+	}
+
+	// the deletion on the peer is applied, and the deletion of a needle missing locally is skipped
+	for _, id := range []uint64{2, 4} {
+		if _, err := peer.DeleteVolumeNeedle(1, newEmptyNeedle(id)); err != nil {
+			t.Fatalf("delete peer needle %d: %v", id, err)
+		}
+	}
+	if applied := tail(); len(applied) != 1 || applied[0] != 2 {
+		t.Fatalf("applied needles %v, expected [2]", applied)
+	}
+	if _, _, err := local.GetVolume(1).ReadVerifiedNeedleBlob(2); err != ErrorNotFound {
+		t.Fatalf("read deleted needle 2: %v", err)
+	}
+	if applied := tail(); len(applied) != 0 {
+		t.Fatalf("applied needles %v again", applied)
+	}
+}
+
+func TestReplicaStreamProgress(t *testing.T) {
+	s := newReplicaStreamStore(t)
+	defer s.Close()
+	v := s.GetVolume(1)
+
+	if progress, err := v.LoadReplicaStreamProgress(); err != nil || len(progress) != 0 {
+		t.Fatalf("load progress: %v %v", progress, err)
+	}
+	if err := v.SaveReplicaStreamProgress(map[string]uint64{"peer:8080": 123}); err != nil {
+		t.Fatalf("save progress: %v", err)
+	}
+	if progress, err := v.LoadReplicaStreamProgress(); err != nil || progress["peer:8080"] != 123 {
+		t.Fatalf("load progress: %v %v", progress, err)
+	}
+	if volumes := s.ReplicaStreamVolumes(); len(volumes) != 1 {
+		t.Fatalf("replica stream volumes %d, expected 1", len(volumes))
+	}
+}
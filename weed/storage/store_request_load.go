@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+)
+
+// The needle requests are counted between the full heartbeats, so the master rolls up
+// the request rates of the cluster without scraping every volume server.
+
+type requestLoad struct {
+	reads        uint64 // atomic
+	writes       uint64 // atomic
+	deletes      uint64 // atomic
+	readBytes    uint64 // atomic
+	writtenBytes uint64 // atomic
+	errors       uint64 // atomic
+	since        int64  // atomic, the unix nano time of the last report
+}
+
+func (l *requestLoad) record(requestType string, bytes uint64, err error) {
+	switch requestType {
+	case stats.ReadNeedle:
+		atomic.AddUint64(&l.reads, 1)
+		atomic.AddUint64(&l.readBytes, bytes)
+	case stats.WriteNeedle:
+		atomic.AddUint64(&l.writes, 1)
+		atomic.AddUint64(&l.writtenBytes, bytes)
+	case stats.DeleteNeedle:
+		atomic.AddUint64(&l.deletes, 1)
+	}
+	if isNeedleRequestError(err) {
+		atomic.AddUint64(&l.errors, 1)
+	}
+}
+
+// report returns the requests since the last report, over an unknown zero interval for the first report
+func (l *requestLoad) report(now time.Time) *master_pb.RequestLoad {
+	load := &master_pb.RequestLoad{
+		Reads:        atomic.SwapUint64(&l.reads, 0),
+		Writes:       atomic.SwapUint64(&l.writes, 0),
+		Deletes:      atomic.SwapUint64(&l.deletes, 0),
+		ReadBytes:    atomic.SwapUint64(&l.readBytes, 0),
+		WrittenBytes: atomic.SwapUint64(&l.writtenBytes, 0),
+		Errors:       atomic.SwapUint64(&l.errors, 0),
+	}
+	if since := atomic.SwapInt64(&l.since, now.UnixNano()); since > 0 {
+		load.IntervalMs = time.Duration(now.UnixNano() - since).Milliseconds()
+	}
+	return load
+}
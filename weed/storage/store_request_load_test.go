@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+)
+
+func TestRequestLoad(t *testing.T) {
+	var l requestLoad
+	now := time.Unix(1000, 0)
+	l.report(now)
+
+	l.record(stats.ReadNeedle, 100, nil)
+	l.record(stats.ReadNeedle, 0, ErrorNotFound)
+	l.record(stats.WriteNeedle, 50, nil)
+	l.record(stats.WriteNeedle, 0, fmt.Errorf("disk full"))
+	l.record(stats.DeleteNeedle, 0, nil)
+
+	load := l.report(now.Add(10 * time.Second))
+	if load.Reads != 2 || load.ReadBytes != 100 || load.Writes != 2 || load.WrittenBytes != 50 || load.Deletes != 1 {
+		t.Errorf("requests %+v", load)
+	}
+	if load.Errors != 1 {
+		t.Errorf("%d errors, expected only the failed write", load.Errors)
+	}
+	if load.IntervalMs != 10000 {
+		t.Errorf("interval %dms", load.IntervalMs)
+	}
+	if load = l.report(now.Add(15 * time.Second)); load.Reads != 0 || load.IntervalMs != 5000 {
+		t.Errorf("requests after the report %+v", load)
+	}
+}
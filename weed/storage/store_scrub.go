@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// ScrubOption configures the background scrubbing of the volumes and ec volumes
+type ScrubOption struct {
+	BytesPerSecond int64         // the rate to read the needles, 0 to disable the scrubbing
+	Interval       time.Duration // the pause after each round over all volumes
+}
+
+// StartScrubbing keeps reading the needles at the configured rate, verifies their checksums,
+// and repairs the corrupted copies from the healthy replicas, or from the other ec shards.
+func (s *Store) StartScrubbing(option ScrubOption) {
+	if option.BytesPerSecond <= 0 {
+		return
+	}
+	glog.V(0).Infof("scrub volumes at %d bytes per second, pausing %v between rounds", option.BytesPerSecond, option.Interval)
+	go func() {
+		throttler := util.NewWriteThrottler(option.BytesPerSecond)
+		for !s.isStopping {
+			for _, location := range s.Locations {
+				for _, v := range location.scrubbableVolumes() {
+					s.scrubVolume(location, v, throttler)
+				}
+			}
+			for _, ev := range s.EcVolumes() {
+				s.scrubEcVolume(ev, throttler)
+			}
+			time.Sleep(option.Interval)
+		}
+	}()
+}
+
+func (l *DiskLocation) scrubbableVolumes() (volumes []*Volume) {
+	l.volumesLock.RLock()
+	defer l.volumesLock.RUnlock()
+	for _, v := range l.volumes {
+		if !v.HasRemoteFile() {
+			volumes = append(volumes, v)
+		}
+	}
+	return
+}
+
+func (s *Store) scrubVolume(location *DiskLocation, v *Volume, throttler *util.WriteThrottler) {
+	var corrupted, repaired uint64
+	defer func() {
+		v.scrubbed(corrupted, repaired)
+	}()
+
+	entryCount := int64(v.IndexFileSize() / NeedleMapEntrySize)
+	for i := int64(0); i < entryCount && !s.isStopping; i++ {
+		if found, ok := location.FindVolume(v.Id); !ok || found != v {
+			// unmounted or deleted
+			return
+		}
+		if v.isCompacting || v.isCommitCompacting {
+			// the needles are scrubbed again in the next round
+			return
+		}
+		key, readBytes, corruption := v.scrubIndexEntry(i)
+		if readBytes == 0 {
+			continue
+		}
+		throttler.MaybeSlowdown(readBytes)
+		stats.VolumeServerScrubBytesCounter.Add(float64(readBytes))
+		stats.VolumeServerScrubCounter.WithLabelValues(v.Collection, "checked").Inc()
+		if corruption == nil {
+			continue
+		}
+
+		corrupted++
+		stats.VolumeServerScrubCounter.WithLabelValues(v.Collection, "corrupted").Inc()
+		glog.Warningf("scrub volume %d needle %s: %v", v.Id, key, corruption)
+		if err := s.repairNeedleFromReplicas(v, key); err != nil {
+			stats.VolumeServerScrubCounter.WithLabelValues(v.Collection, "unrepaired").Inc()
+			glog.Errorf("repair volume %d needle %s: %v", v.Id, key, err)
+			continue
+		}
+		repaired++
+		stats.VolumeServerScrubCounter.WithLabelValues(v.Collection, "repaired").Inc()
+		glog.V(0).Infof("repaired volume %d needle %s from replicas", v.Id, key)
+	}
+}
+
+// repairNeedleFromReplicas appends a healthy copy of the needle from one of the replicas,
+// so the corrupted copy is left as garbage to be vacuumed.
+func (s *Store) repairNeedleFromReplicas(v *Volume, needleId NeedleId) error {
+	if !v.NeedToReplicate() {
+		return fmt.Errorf("no replicas with replication %s", v.ReplicaPlacement)
+	}
+	lookup, err := operation.LookupVolumeId(func() pb.ServerAddress { return s.MasterAddress }, s.grpcDialOption, v.Id.String())
+	if err != nil {
+		return fmt.Errorf("lookup volume %d: %v", v.Id, err)
+	}
+	self := util.JoinHostPort(s.Ip, s.Port)
+	for _, location := range lookup.Locations {
+		if location.Url == self {
+			continue
+		}
+		var resp *volume_server_pb.ReadNeedleBlobResponse
+		err = operation.WithVolumeServerClient(false, location.ServerAddress(), s.grpcDialOption, func(client volume_server_pb.VolumeServerClient) (readErr error) {
+			resp, readErr = client.ReadNeedleBlob(context.Background(), &volume_server_pb.ReadNeedleBlobRequest{
+				VolumeId: uint32(v.Id),
+				NeedleId: uint64(needleId),
+			})
+			return readErr
+		})
+		if err != nil {
+			glog.V(0).Infof("read volume %d needle %s from %s: %v", v.Id, needleId, location.Url, err)
+			continue
+		}
+		return v.WriteNeedleBlob(needleId, resp.NeedleBlob, Size(resp.Size))
+	}
+	return fmt.Errorf("no healthy copy in %d locations", len(lookup.Locations))
+}
+
+func (s *Store) scrubEcVolume(ev *erasure_coding.EcVolume, throttler *util.WriteThrottler) {
+	err := ev.WalkIndex(func(key NeedleId, offset Offset, size Size) error {
+		if s.isStopping {
+			return io.EOF
+		}
+		if offset.IsZero() || size.IsDeleted() {
+			return nil
+		}
+		readBytes, corruption := s.scrubEcNeedle(ev, key, offset, size)
+		if readBytes == 0 {
+			return nil
+		}
+		throttler.MaybeSlowdown(readBytes)
+		stats.VolumeServerScrubBytesCounter.Add(float64(readBytes))
+		stats.VolumeServerScrubCounter.WithLabelValues(ev.Collection, "checked").Inc()
+		if corruption == nil {
+			return nil
+		}
+
+		stats.VolumeServerScrubCounter.WithLabelValues(ev.Collection, "corrupted").Inc()
+		glog.Warningf("scrub ec volume %d needle %s: %v", ev.VolumeId, key, corruption)
+		if err := s.repairEcNeedle(ev, key, offset, size); err != nil {
+			stats.VolumeServerScrubCounter.WithLabelValues(ev.Collection, "unrepaired").Inc()
+			glog.Errorf("repair ec volume %d needle %s: %v", ev.VolumeId, key, err)
+			return nil
+		}
+		stats.VolumeServerScrubCounter.WithLabelValues(ev.Collection, "repaired").Inc()
+		glog.V(0).Infof("repaired ec volume %d needle %s from other shards", ev.VolumeId, key)
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		glog.V(1).Infof("scrub ec volume %d: %v", ev.VolumeId, err)
+	}
+}
+
+// scrubEcNeedle verifies the needle if all of it is on the local shards.
+// The other needles are verified by the volume servers having their shards.
+func (s *Store) scrubEcNeedle(ev *erasure_coding.EcVolume, needleId NeedleId, offset Offset, size Size) (readBytes int64, corruption error) {
+	intervals := ev.LocateEcShardNeedleInterval(ev.Version, offset.ToActualOffset(), Size(needle.GetActualSize(size, ev.Version)))
+	var data []byte
+	for _, interval := range intervals {
+		shardId, shardOffset := interval.ToShardIdAndOffset(erasure_coding.ErasureCodingLargeBlockSize, erasure_coding.ErasureCodingSmallBlockSize)
+		shard, found := ev.FindEcVolumeShard(shardId)
+		if !found {
+			return 0, nil
+		}
+		buf := make([]byte, interval.Size)
+		if _, err := shard.ReadAt(buf, shardOffset); err != nil {
+			return int64(len(data)) + int64(interval.Size), err
+		}
+		data = append(data, buf...)
+	}
+	return int64(len(data)), verifyNeedleBytes(data, needleId, offset, size, ev.Version)
+}
+
+// repairEcNeedle recovers the needle from the other shards, and overwrites the local shards with the recovered data.
+func (s *Store) repairEcNeedle(ev *erasure_coding.EcVolume, needleId NeedleId, offset Offset, size Size) error {
+	if err := s.cachedLookupEcShardLocations(ev); err != nil {
+		return fmt.Errorf("locate ec shards: %v", err)
+	}
+	intervals := ev.LocateEcShardNeedleInterval(ev.Version, offset.ToActualOffset(), Size(needle.GetActualSize(size, ev.Version)))
+	var data []byte
+	recovered := make([][]byte, len(intervals))
+	for i, interval := range intervals {
+		shardId, shardOffset := interval.ToShardIdAndOffset(erasure_coding.ErasureCodingLargeBlockSize, erasure_coding.ErasureCodingSmallBlockSize)
+		recovered[i] = make([]byte, interval.Size)
+		if _, _, err := s.recoverOneRemoteEcShardInterval(context.Background(), needleId, ev, shardId, recovered[i], shardOffset); err != nil {
+			return fmt.Errorf("recover shard %d offset %d: %v", shardId, shardOffset, err)
+		}
+		data = append(data, recovered[i]...)
+	}
+	if err := verifyNeedleBytes(data, needleId, offset, size, ev.Version); err != nil {
+		return fmt.Errorf("recovered data: %v", err)
+	}
+
+	for i, interval := range intervals {
+		shardId, shardOffset := interval.ToShardIdAndOffset(erasure_coding.ErasureCodingLargeBlockSize, erasure_coding.ErasureCodingSmallBlockSize)
+		shard, found := ev.FindEcVolumeShard(shardId)
+		if !found {
+			return fmt.Errorf("shard %d is unmounted", shardId)
+		}
+		local := make([]byte, interval.Size)
+		if _, err := shard.ReadAt(local, shardOffset); err == nil && bytes.Equal(local, recovered[i]) {
+			continue
+		}
+		if _, err := shard.WriteAt(recovered[i], shardOffset); err != nil {
+			return fmt.Errorf("write shard %d offset %d: %v", shardId, shardOffset, err)
+		}
+	}
+	return nil
+}
+
+func verifyNeedleBytes(data []byte, needleId NeedleId, offset Offset, size Size, version needle.Version) error {
+	n := &needle.Needle{}
+	if err := n.ReadBytes(data, offset.ToActualOffset(), size, version); err != nil {
+		return err
+	}
+	if n.Id != needleId {
+		return fmt.Errorf("found needle %s instead of %s", n.Id, needleId)
+	}
+	return nil
+}
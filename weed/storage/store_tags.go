@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetTags sets the comma separated tags of the volume server, e.g. "zone=a,hw=nvme,gpu",
+// reported to the master to match the placement constraints of the collections and assign requests
+func (s *Store) SetTags(tags string) error {
+	parsed, err := ParseNodeTags(tags)
+	if err != nil {
+		return err
+	}
+	s.tags = parsed
+	return nil
+}
+
+func (s *Store) Tags() map[string]string {
+	return s.tags
+}
+
+// ParseNodeTags parses the comma separated key=value tags, and a key without a value is a tag with an empty value
+func ParseNodeTags(tags string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, item := range strings.Split(tags, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(item, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" || strings.ContainsAny(key, "!|=") || strings.ContainsAny(value, "|=") {
+			return nil, fmt.Errorf("invalid tag %q", item)
+		}
+		if _, found := parsed[key]; found {
+			return nil, fmt.Errorf("duplicated tag %q", key)
+		}
+		parsed[key] = value
+	}
+	if len(parsed) == 0 {
+		return nil, nil
+	}
+	return parsed, nil
+}
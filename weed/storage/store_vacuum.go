@@ -10,33 +10,63 @@ import (
 )
 
 func (s *Store) CheckCompactVolume(volumeId needle.VolumeId) (float64, error) {
-	if v := s.findVolume(volumeId); v != nil {
-		glog.V(3).Infof("volume %d garbage level: %f", volumeId, v.garbageLevel())
-		return v.garbageLevel(), nil
+	if v, location := s.findVolumeAndLocation(volumeId); v != nil {
+		garbageLevel := v.garbageLevel()
+		if s.compactInPlace && !location.IsZoned() && !v.IsObjectStored() {
+			garbageLevel = v.inPlaceGarbageLevel()
+		}
+		glog.V(3).Infof("volume %d garbage level: %f", volumeId, garbageLevel)
+		return garbageLevel, nil
 	}
 	return 0, fmt.Errorf("volume id %d is not found during check compact", volumeId)
 }
 func (s *Store) CompactVolume(vid needle.VolumeId, preallocate int64, compactionBytePerSecond int64, progressFn ProgressFunc) error {
-	if v := s.findVolume(vid); v != nil {
+	if v, location := s.findVolumeAndLocation(vid); v != nil {
+		// the zoned disks only append, and the uploaded segments on the object store are immutable,
+		// so the live needles are copied to a new file
+		if s.compactInPlace && !location.IsZoned() && !v.IsObjectStored() {
+			reclaimed, err := v.CompactInPlace(progressFn)
+			if err != errPunchHoleNotSupported {
+				if err == nil && reclaimed > 0 {
+					location.requestDiscard()
+				}
+				return err
+			}
+			glog.V(0).Infof("volume %d can not be compacted in place: %v", vid, err)
+		}
 		s := stats.NewDiskStatus(v.dir)
 		if int64(s.Free) < preallocate {
 			return fmt.Errorf("free space: %d bytes, not enough for %d bytes", s.Free, preallocate)
 		}
+		if location.IsZoned() || v.IsObjectStored() {
+			preallocate = 0
+		}
 		return v.Compact2(preallocate, compactionBytePerSecond, progressFn)
 	}
 	return fmt.Errorf("volume id %d is not found during compact", vid)
 }
+
+// SetCompactInPlace reclaims the deleted space of the volumes by punching holes,
+// instead of copying the live needles to new volume files.
+func (s *Store) SetCompactInPlace(compactInPlace bool) {
+	s.compactInPlace = compactInPlace
+}
+
 func (s *Store) CommitCompactVolume(vid needle.VolumeId) (bool, int64, error) {
 	if s.isStopping {
 		return false, 0, fmt.Errorf("volume id %d skips compact because volume is stopping", vid)
 	}
-	if v := s.findVolume(vid); v != nil {
+	if v, location := s.findVolumeAndLocation(vid); v != nil {
 		isReadOnly := v.IsReadOnly()
 		err := v.CommitCompact()
 		var volumeSize int64 = 0
 		if err == nil && v.DataBackend != nil {
 			volumeSize, _, _ = v.DataBackend.GetStat()
 		}
+		if err == nil {
+			// the old volume files are removed
+			location.requestDiscard()
+		}
 		return isReadOnly, volumeSize, err
 	}
 	return false, 0, fmt.Errorf("volume id %d is not found during commit compact", vid)
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+// The compactions wait in a queue once the concurrent ones reach the limit of the volume server,
+// or of the physical disk holding the volume, since the compactions on one disk slow down all its reads.
+// The waiting compaction of the volume with the most garbage runs first, and the earlier one for the same garbage.
+
+type vacuumWaiter struct {
+	disk     string
+	priority float64
+	seq      uint64
+	ready    chan struct{}
+}
+
+type vacuumLimiter struct {
+	lock          sync.Mutex
+	serverLimit   int
+	diskLimit     int
+	running       int
+	runningByDisk map[string]int
+	waiting       []*vacuumWaiter
+	seq           uint64
+}
+
+// SetVacuumConcurrency limits the concurrent compactions on the volume server, and on each physical disk, 0 for no limit
+func (s *Store) SetVacuumConcurrency(serverLimit, diskLimit int) {
+	s.vacuumLimiter = &vacuumLimiter{
+		serverLimit:   serverLimit,
+		diskLimit:     diskLimit,
+		runningByDisk: make(map[string]int),
+	}
+}
+
+// WaitVacuumTurn waits until the volume can be compacted within the limits, and returns the function to call after the compaction
+func (s *Store) WaitVacuumTurn(ctx context.Context, vid needle.VolumeId) (done func(), err error) {
+	v, location := s.findVolumeAndLocation(vid)
+	if v == nil {
+		return nil, fmt.Errorf("volume id %d is not found during compact", vid)
+	}
+	if s.vacuumLimiter == nil {
+		return func() {}, nil
+	}
+	priority, _ := s.CheckCompactVolume(vid)
+	return s.vacuumLimiter.wait(ctx, vid, location.diskDevice(), priority)
+}
+
+func (l *vacuumLimiter) wait(ctx context.Context, vid needle.VolumeId, disk string, priority float64) (done func(), err error) {
+	l.lock.Lock()
+	l.seq++
+	w := &vacuumWaiter{disk: disk, priority: priority, seq: l.seq, ready: make(chan struct{})}
+	l.waiting = append(l.waiting, w)
+	l.dispatch()
+	queued := len(l.waiting)
+	l.lock.Unlock()
+
+	done = func() {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		l.running--
+		l.runningByDisk[disk]--
+		l.dispatch()
+	}
+	select {
+	case <-w.ready:
+		return done, nil
+	default:
+	}
+	glog.V(0).Infof("volume %d waits to compact, %d compactions waiting", vid, queued)
+	select {
+	case <-w.ready:
+		return done, nil
+	case <-ctx.Done():
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	select {
+	case <-w.ready:
+		// started right before canceled
+		l.running--
+		l.runningByDisk[disk]--
+		l.dispatch()
+	default:
+		l.remove(w)
+	}
+	return nil, ctx.Err()
+}
+
+// dispatch starts the waiting compactions by priority, as long as their disks and the server are under the limits
+func (l *vacuumLimiter) dispatch() {
+	for l.serverLimit <= 0 || l.running < l.serverLimit {
+		var next *vacuumWaiter
+		for _, w := range l.waiting {
+			if l.diskLimit > 0 && l.runningByDisk[w.disk] >= l.diskLimit {
+				continue
+			}
+			if next == nil || w.priority > next.priority || w.priority == next.priority && w.seq < next.seq {
+				next = w
+			}
+		}
+		if next == nil {
+			return
+		}
+		l.remove(next)
+		l.running++
+		l.runningByDisk[next.disk]++
+		close(next.ready)
+	}
+}
+
+func (l *vacuumLimiter) remove(w *vacuumWaiter) {
+	for i, x := range l.waiting {
+		if x == w {
+			l.waiting = append(l.waiting[:i], l.waiting[i+1:]...)
+			return
+		}
+	}
+}
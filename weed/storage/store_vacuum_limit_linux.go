@@ -0,0 +1,18 @@
+//go:build linux
+// +build linux
+
+package storage
+
+import (
+	"strconv"
+	"syscall"
+)
+
+// diskDevice identifies the disk of the folder by its device id, shared by the folders on the same file system
+func (l *DiskLocation) diskDevice() string {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(l.Directory, &stat); err != nil {
+		return l.Directory
+	}
+	return strconv.FormatUint(uint64(stat.Dev), 10)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package storage
+
+// diskDevice identifies the disk of the folder by the folder itself
+func (l *DiskLocation) diskDevice() string {
+	return l.Directory
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func TestVacuumLimiter(t *testing.T) {
+	l := &vacuumLimiter{serverLimit: 2, diskLimit: 1, runningByDisk: make(map[string]int)}
+	ctx := context.Background()
+
+	doneA, err := l.wait(ctx, 1, "a", 0.5)
+	if err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	started := make(chan int, 3)
+	dones := make(chan func(), 3)
+	for vid, priority := range map[int]float64{2: 0.3, 3: 0.9} {
+		vid, priority := vid, priority
+		go func() {
+			done, _ := l.wait(ctx, needle.VolumeId(vid), "a", priority)
+			started <- vid
+			dones <- done
+		}()
+	}
+	// the other disk is under the limits
+	doneB, err := l.wait(ctx, 4, "b", 0.1)
+	if err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	doneB()
+
+	// canceled while waiting for the server limit
+	doneC, _ := l.wait(ctx, 5, "c", 0.1)
+	canceledCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err = l.wait(canceledCtx, 6, "d", 1); err == nil {
+		t.Fatalf("waited over the server limit")
+	}
+	doneC()
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		l.lock.Lock()
+		waiting := len(l.waiting)
+		l.lock.Unlock()
+		if waiting == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d compactions waiting, expected 2", waiting)
+		}
+	}
+	select {
+	case vid := <-started:
+		t.Fatalf("volume %d compacted over the disk limit", vid)
+	default:
+	}
+
+	// the volume with more garbage runs first
+	doneA()
+	if vid := <-started; vid != 3 {
+		t.Fatalf("compacted volume %d first", vid)
+	}
+	(<-dones)()
+	if vid := <-started; vid != 2 {
+		t.Fatalf("compacted volume %d second", vid)
+	}
+	(<-dones)()
+	if l.running != 0 || len(l.waiting) != 0 {
+		t.Fatalf("%d running, %d waiting", l.running, len(l.waiting))
+	}
+}
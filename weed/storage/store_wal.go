@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+// LoadNeedleWal replays the write-ahead log of each disk location, after the volumes are loaded.
+// If enabled, the needle writes are acknowledged after fdatasync of the log, instead of the volume files.
+func (s *Store) LoadNeedleWal(enabled bool) error {
+	for _, location := range s.Locations {
+		if err := location.replayNeedleWal(); err != nil {
+			return err
+		}
+		if !enabled {
+			continue
+		}
+		wal, err := openNeedleWal(location.Directory)
+		if err != nil {
+			return err
+		}
+		location.wal = wal
+		go location.loopNeedleWalCheckpoint()
+		glog.V(0).Infof("write-ahead log %s", wal.fileName)
+	}
+	return nil
+}
+
+// findVolumeWithWal returns the volume, and the write-ahead log of its disk location if enabled.
+// The encrypted volumes are not logged, since the log would keep the plain text.
+func (s *Store) findVolumeWithWal(vid needle.VolumeId) (*Volume, *needleWal) {
+	v, location := s.findVolumeAndLocation(vid)
+	if v == nil || v.IsEncrypted() {
+		return v, nil
+	}
+	return v, location.wal
+}
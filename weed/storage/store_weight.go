@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// SetWeight sets the capacity and performance weight of the volume server relative to the others,
+// reported to the master to scale its share of the volumes
+func (s *Store) SetWeight(weight float64) error {
+	if weight <= 0 {
+		return fmt.Errorf("weight %v is not positive", weight)
+	}
+	s.weight = weight
+	return nil
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// The write load of the volume server is reported in the heartbeats, so the master places
+// fewer new volumes on the slower or busier volume servers.
+
+// the weight of the latest write in the moving average of the write latency is 1/writeLatencyDecay
+const writeLatencyDecay = 16
+
+type writeLoad struct {
+	inFlight     int64 // atomic
+	peakInFlight int64 // atomic, since the last report
+	latency      int64 // atomic, the moving average in nanoseconds
+	writes       int64 // atomic, since the last report
+}
+
+func (l *writeLoad) begin() (done func()) {
+	inFlight := atomic.AddInt64(&l.inFlight, 1)
+	for peak := atomic.LoadInt64(&l.peakInFlight); inFlight > peak; peak = atomic.LoadInt64(&l.peakInFlight) {
+		if atomic.CompareAndSwapInt64(&l.peakInFlight, peak, inFlight) {
+			break
+		}
+	}
+	start := time.Now()
+	return func() {
+		atomic.AddInt64(&l.inFlight, -1)
+		atomic.AddInt64(&l.writes, 1)
+		sample := int64(time.Since(start))
+		for {
+			latency := atomic.LoadInt64(&l.latency)
+			updated := sample
+			if latency > 0 {
+				updated = latency + (sample-latency)/writeLatencyDecay
+			}
+			if atomic.CompareAndSwapInt64(&l.latency, latency, updated) {
+				return
+			}
+		}
+	}
+}
+
+// report returns the write latency, halved if idle since the last report so an old burst is forgotten,
+// and the most in flight writes since the last report
+func (l *writeLoad) report() (latency time.Duration, peakInFlight int64) {
+	if atomic.SwapInt64(&l.writes, 0) == 0 {
+		for {
+			old := atomic.LoadInt64(&l.latency)
+			if atomic.CompareAndSwapInt64(&l.latency, old, old/2) {
+				break
+			}
+		}
+	}
+	peakInFlight = atomic.SwapInt64(&l.peakInFlight, atomic.LoadInt64(&l.inFlight))
+	return time.Duration(atomic.LoadInt64(&l.latency)), peakInFlight
+}
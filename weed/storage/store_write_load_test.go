@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWriteLoad(t *testing.T) {
+	var l writeLoad
+	first, second := l.begin(), l.begin()
+	time.Sleep(2 * time.Millisecond)
+	first()
+	second()
+
+	latency, peakInFlight := l.report()
+	if latency < 2*time.Millisecond || peakInFlight != 2 {
+		t.Errorf("latency %v with %d in flight writes, expected over 2ms with 2", latency, peakInFlight)
+	}
+	idleLatency, peakInFlight := l.report()
+	if idleLatency != latency/2 || peakInFlight != 0 {
+		t.Errorf("idle latency %v with %d in flight writes, expected %v with none", idleLatency, peakInFlight, latency/2)
+	}
+}
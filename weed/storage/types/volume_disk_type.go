@@ -9,6 +9,8 @@ type DiskType string
 const (
 	HardDriveType DiskType = ""
 	SsdType                = "ssd"
+	// the volume data files are stored on the object store
+	ObjectStoreType = "object"
 )
 
 func ToDiskType(vt string) (diskType DiskType) {
@@ -27,10 +27,19 @@ type Volume struct {
 	nm                 NeedleMapper
 	tmpNm              TempNeedleMapper
 	needleMapKind      NeedleMapKind
+	memoryMappedIndex  bool // memory map the sorted index of the read only volume
+	compactIndex       bool // look up the needles of the read only volume in the compact index
+	hasNeedleFilter    bool // keep a bloom filter of the needle ids, to skip looking up the missing needles
+	needleFilter       *needleFilter
+	hasDiskReadCache   bool // read the data file through the read cache device
+	overwritesInPlace  bool // overwrite the needles of the same size in place, instead of appending them
+	overwriteLog       *overwriteLog
 	noWriteOrDelete    bool // if readonly, either noWriteOrDelete or noWriteCanDelete
 	noWriteCanDelete   bool // if readonly, either noWriteOrDelete or noWriteCanDelete
 	noWriteLock        sync.RWMutex
-	hasRemoteFile      bool // if the volume has a remote file
+	hasRemoteFile      bool                     // if the volume has a remote file
+	archivedFile       *backend.ArchivedFile    // if the remote file is archived offline
+	objectStoreFile    *backend.ObjectStoreFile // if the data file is on the object store
 	MemoryMapMaxSizeMb uint32
 
 	super_block.SuperBlock
@@ -47,11 +56,22 @@ type Volume struct {
 
 	isCompacting       bool
 	isCommitCompacting bool
+	isCompactedInPlace bool // no files to commit after compacting in place
 
 	volumeInfo *volume_server_pb.VolumeInfo
 	location   *DiskLocation
 
 	lastIoError error
+
+	scrubStatus      scrubStatus
+	readStatus       readStatus
+	readRepairStatus readRepairStatus
+
+	dataKey []byte // decrypts the data file if the volume is encrypted
+
+	blobRefs map[types.NeedleId]uint32 // the count of needles referring to each blob needle, if the volume is deduplicated
+
+	checksumAlgorithm needle.ChecksumAlgorithm
 }
 
 func NewVolume(dirname string, dirIdx string, collection string, id needle.VolumeId, needleMapKind NeedleMapKind, replicaPlacement *super_block.ReplicaPlacement, ttl *needle.TTL, preallocate int64, memoryMapMaxSizeMb uint32, ldbTimeout int64) (v *Volume, e error) {
@@ -61,6 +81,7 @@ func NewVolume(dirname string, dirIdx string, collection string, id needle.Volum
 	v.SuperBlock = super_block.SuperBlock{ReplicaPlacement: replicaPlacement, Ttl: ttl}
 	v.needleMapKind = needleMapKind
 	v.ldbTimeout = ldbTimeout
+	v.readStatus.readAtSecond = time.Now().Unix()
 	e = v.load(true, true, needleMapKind, preallocate)
 	v.startWorker()
 	return
@@ -92,7 +113,7 @@ func (v *Volume) IndexFileName() (fileName string) {
 
 func (v *Volume) FileName(ext string) (fileName string) {
 	switch ext {
-	case ".idx", ".cpx", ".ldb", ".cpldb":
+	case ".idx", ".cpx", ".ldb", ".cpldb", ".pdb", ".cppdb":
 		return VolumeFileName(v.dirIdx, v.Collection, int(v.Id)) + ext
 	}
 	// .dat, .cpd, .vif
@@ -216,6 +237,8 @@ func (v *Volume) SyncToDisk() {
 	if v.DataBackend != nil {
 		if err := v.DataBackend.Sync(); err != nil {
 			glog.Warningf("Volume Close fail to sync volume %d", v.Id)
+		} else if err = v.checkpointOverwriteLog(); err != nil {
+			glog.Warningf("Volume %d fail to empty the overwrite log: %v", v.Id, err)
 		}
 	}
 }
@@ -242,9 +265,11 @@ func (v *Volume) doClose() {
 		v.nm = nil
 	}
 	if v.DataBackend != nil {
-		if err := v.DataBackend.Close(); err != nil {
+		err := v.DataBackend.Close()
+		if err != nil {
 			glog.Warningf("Volume Close fail to sync volume %d", v.Id)
 		}
+		v.closeOverwriteLog(err == nil)
 		v.DataBackend = nil
 		stats.VolumeServerVolumeCounter.WithLabelValues(v.Collection, "volume").Dec()
 	}
@@ -339,6 +364,13 @@ func (v *Volume) ToVolumeInformationMessage() (types.NeedleId, *master_pb.Volume
 	}
 
 	volumeInfo.RemoteStorageName, volumeInfo.RemoteStorageKey = v.RemoteStorageNameKey()
+	volumeInfo.ScrubCorruptedCount, volumeInfo.ScrubRepairedCount, volumeInfo.ScrubbedAtSecond = v.ScrubStatus()
+	volumeInfo.EncryptionKeyId = v.EncryptionKeyId()
+	volumeInfo.ReadAtSecond, volumeInfo.ReadCount = v.ReadStatus()
+	volumeInfo.RemoteRestoreState, volumeInfo.RemoteRestoreExpiresAtSecond = v.RemoteRestoreStatus()
+	volumeInfo.ReadRepairedCount, volumeInfo.ReadRepairedAtSecond = v.ReadRepairStatus()
+	volumeInfo.DiskFailing = v.isOnFailingDisk()
+	volumeInfo.CreatedAtSecond = v.volumeInfo.GetCreatedAtSecond()
 
 	return maxFileKey, volumeInfo
 }
@@ -356,5 +388,5 @@ func (v *Volume) RemoteStorageNameKey() (storageName, storageKey string) {
 func (v *Volume) IsReadOnly() bool {
 	v.noWriteLock.RLock()
 	defer v.noWriteLock.RUnlock()
-	return v.noWriteOrDelete || v.noWriteCanDelete || v.location.isDiskSpaceLow
+	return v.noWriteOrDelete || v.noWriteCanDelete || v.location.isDiskSpaceLow || v.isOnFailingDisk()
 }
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/volume_info"
+)
+
+func (v *Volume) ChecksumAlgorithm() needle.ChecksumAlgorithm {
+	return v.checksumAlgorithm
+}
+
+// prepareVolumeChecksum saves the checksum algorithm to the .vif file, before the volume is created
+func prepareVolumeChecksum(baseFileName string, checksumAlgorithm needle.ChecksumAlgorithm) error {
+	volumeInfo, _, _, err := volume_info.MaybeLoadVolumeInfo(baseFileName + ".vif")
+	if err != nil {
+		return err
+	}
+	volumeInfo.ChecksumAlgorithm = checksumAlgorithm.String()
+	return volume_info.SaveVolumeInfo(baseFileName+".vif", volumeInfo)
+}
+
+// SetChecksumAlgorithm sets the checksum algorithm of the new volumes
+func (s *Store) SetChecksumAlgorithm(name string) (err error) {
+	s.checksumAlgorithm, err = needle.ParseChecksumAlgorithm(name)
+	return
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+)
+
+func TestVolumeChecksumAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	if err := prepareVolumeChecksum(VolumeFileName(dir, "", 1), needle.ChecksumXxHash64); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	if v.ChecksumAlgorithm() != needle.ChecksumXxHash64 {
+		t.Fatalf("checksum algorithm %v", v.ChecksumAlgorithm())
+	}
+
+	// a new needle, and one checksummed with crc32c, e.g. tailed from another volume
+	written := map[uint64]*needle.Needle{}
+	for id, algorithm := range map[uint64]needle.ChecksumAlgorithm{1: needle.ChecksumXxHash64, 2: needle.ChecksumCrc32c} {
+		n := newEmptyNeedle(id)
+		n.Data = make([]byte, 1024)
+		if id == 1 {
+			// read in pages with the meta first
+			n.Data = make([]byte, 2*PagedReadLimit)
+		}
+		rand.Read(n.Data)
+		n.ChecksumAlgorithm, n.Checksum = algorithm, algorithm.Sum(n.Data)
+		if _, _, _, err = v.writeNeedle2(n, true, false); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		written[id] = n
+	}
+	verifyChecksummedNeedles(t, v, written)
+
+	if err = v.Compact2(0, 0, nil); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if err = v.CommitCompact(); err != nil {
+		t.Fatalf("commit compact: %v", err)
+	}
+	v.Close()
+
+	if v, err = NewVolume(dir, dir, "", 1, NeedleMapInMemory, nil, nil, 0, 0, 0); err != nil {
+		t.Fatalf("volume reload: %v", err)
+	}
+	defer v.Close()
+	verifyChecksummedNeedles(t, v, written)
+
+	// tail to a crc32c volume, parsing the needles as the tail receiver
+	tailed, err := NewVolume(dir, dir, "", 2, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer tailed.Close()
+	if err = ScanVolumeFileFrom(v.Version(), v.DataBackend, int64(v.SuperBlock.BlockSize()), &tailingScanner{dst: tailed}); err != nil {
+		t.Fatalf("tail: %v", err)
+	}
+	verifyChecksummedNeedles(t, tailed, written)
+}
+
+func verifyChecksummedNeedles(t *testing.T, v *Volume, written map[uint64]*needle.Needle) {
+	for id, n := range written {
+		read := newEmptyNeedle(id)
+		if _, err := v.readNeedle(read, nil, nil); err != nil {
+			t.Fatalf("volume %d read %d: %v", v.Id, id, err)
+		}
+		if !bytes.Equal(read.Data, n.Data) || read.Checksum != n.Checksum || read.ChecksumAlgorithm != n.ChecksumAlgorithm {
+			t.Errorf("volume %d read %d checksum %x %v, expected %x %v", v.Id, id, read.Checksum, read.ChecksumAlgorithm, n.Checksum, n.ChecksumAlgorithm)
+		}
+
+		// the streamed read verifies the checksum too
+		var streamed bytes.Buffer
+		meta := newEmptyNeedle(id)
+		readOption := &ReadOption{ReadBufferSize: 64, AttemptMetaOnly: true}
+		if _, err := v.readNeedle(meta, readOption, nil); err != nil {
+			t.Fatalf("volume %d read meta %d: %v", v.Id, id, err)
+		}
+		if err := v.readNeedleDataInto(meta, readOption, &streamed, 0, int64(len(n.Data))); err != nil {
+			t.Fatalf("volume %d stream %d: %v", v.Id, id, err)
+		}
+		if !bytes.Equal(streamed.Bytes(), n.Data) {
+			t.Errorf("volume %d streamed %d data differs", v.Id, id)
+		}
+	}
+}
+
+type tailingScanner struct {
+	dst *Volume
+}
+
+func (s *tailingScanner) VisitSuperBlock(super_block.SuperBlock) error { return nil }
+func (s *tailingScanner) ReadNeedleBody() bool                         { return true }
+func (s *tailingScanner) VisitNeedle(_ *needle.Needle, offset int64, needleHeader, needleBody []byte) error {
+	n := new(needle.Needle)
+	n.ParseNeedleHeader(needleHeader)
+	if err := n.ReadNeedleBodyBytes(needleBody, needle.CurrentVersion); err != nil {
+		return err
+	}
+	_, _, _, err := s.dst.writeNeedle2(n, false, false)
+	return err
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/storage/volume_info"
+)
+
+// A deduplicated volume stores each distinct needle content once, as a blob needle.
+// The blob needle id is the first 63 bits of the sha256 of the content with the highest bit set,
+// and its name is the full sha256 to tell apart the contents sharing the same id.
+// The needles written by the clients keep their metadata, with the blob needle id as the data.
+// The blob needle is deleted once no needles refer to it, and its space is reclaimed by vacuum.
+
+const dedupBlobIdBit = NeedleId(1 << 63)
+
+// IsDedupBlobId tells whether the needle id is reserved for the blob needles of the deduplicated volumes
+func IsDedupBlobId(id NeedleId) bool {
+	return id&dedupBlobIdBit != 0
+}
+
+func (v *Volume) IsDeduplicated() bool {
+	return v.volumeInfo.GetDeduplicated()
+}
+
+// prepareDeduplicatedVolume marks the volume as deduplicated in the .vif file, before the volume is created
+func prepareDeduplicatedVolume(baseFileName string) error {
+	volumeInfo, _, _, err := volume_info.MaybeLoadVolumeInfo(baseFileName + ".vif")
+	if err != nil {
+		return err
+	}
+	volumeInfo.Deduplicated = true
+	return volume_info.SaveVolumeInfo(baseFileName+".vif", volumeInfo)
+}
+
+// loadBlobRefs counts the needles referring to each blob needle
+func (v *Volume) loadBlobRefs() error {
+	v.blobRefs = make(map[NeedleId]uint32)
+	if !v.IsDeduplicated() {
+		return nil
+	}
+	db := needle_map.NewMemDb()
+	defer db.Close()
+	if err := db.LoadFromIdx(v.FileName(".idx")); err != nil {
+		return err
+	}
+	return db.AscendingVisit(func(value needle_map.NeedleValue) error {
+		if IsDedupBlobId(value.Key) {
+			return nil
+		}
+		blobId, err := v.readBlobRef(value.Offset, value.Size)
+		if err != nil {
+			return fmt.Errorf("read needle %s: %v", value.Key, err)
+		}
+		v.blobRefs[blobId]++
+		return nil
+	})
+}
+
+func (v *Volume) readBlobRef(offset Offset, size Size) (NeedleId, error) {
+	n := new(needle.Needle)
+	if err := n.ReadData(v.DataBackend, offset.ToActualOffset(), size, v.Version()); err != nil {
+		return 0, err
+	}
+	return blobRefOf(n)
+}
+
+func blobRefOf(n *needle.Needle) (NeedleId, error) {
+	if len(n.Data) != NeedleIdSize {
+		return 0, fmt.Errorf("needle %s does not refer to a blob", n.Id)
+	}
+	return BytesToNeedleId(n.Data), nil
+}
+
+// findBlobRef finds the blob needle referred by the existing needle
+func (v *Volume) findBlobRef(needleId NeedleId) (blobId NeedleId, found bool) {
+	nv, ok := v.nm.Get(needleId)
+	if !ok || nv.Offset.IsZero() || !nv.Size.IsValid() {
+		return 0, false
+	}
+	blobId, err := v.readBlobRef(nv.Offset, nv.Size)
+	if err != nil {
+		glog.Warningf("volume %d: %v", v.Id, err)
+		return 0, false
+	}
+	return blobId, true
+}
+
+// referToBlob stores the needle content as a blob needle, and replaces the needle data with the blob needle id.
+// The returned function restores the needle, and releases the blob needle if the write failed,
+// or else the blob needle referred by the overwritten needle.
+func (v *Volume) referToBlob(n *needle.Needle) (restore func(written bool), err error) {
+	if IsDedupBlobId(n.Id) {
+		return nil, fmt.Errorf("needle id %s is reserved for the deduplicated contents", n.Id)
+	}
+	oldBlobId, hasOldBlob := v.findBlobRef(n.Id)
+
+	blobId, err := v.writeBlob(n.Data)
+	if err != nil {
+		return nil, err
+	}
+	v.blobRefs[blobId]++
+
+	data, checksum := n.Data, n.Checksum
+	n.Data = make([]byte, NeedleIdSize)
+	NeedleIdToBytes(n.Data, blobId)
+	n.Checksum = n.ChecksumAlgorithm.Sum(n.Data)
+
+	return func(written bool) {
+		n.Data, n.Checksum, n.DataSize = data, checksum, uint32(len(data))
+		if !written {
+			v.releaseBlob(blobId)
+		} else if hasOldBlob {
+			v.releaseBlob(oldBlobId)
+		}
+	}, nil
+}
+
+// writeBlob finds or appends the blob needle with the content
+func (v *Volume) writeBlob(data []byte) (blobId NeedleId, err error) {
+	hash := sha256.Sum256(data)
+	blobId = BytesToNeedleId(hash[:NeedleIdSize]) | dedupBlobIdBit
+	for {
+		nv, ok := v.nm.Get(blobId)
+		if !ok || nv.Offset.IsZero() || !nv.Size.IsValid() {
+			break
+		}
+		existing := new(needle.Needle)
+		if err = existing.ReadNeedleMeta(v.DataBackend, nv.Offset.ToActualOffset(), nv.Size, v.Version()); err != nil {
+			return 0, fmt.Errorf("read blob %s: %v", blobId, err)
+		}
+		if bytes.Equal(existing.Name, hash[:]) {
+			return blobId, nil
+		}
+		// a different content with the same blob needle id
+		blobId = (blobId + 1) | dedupBlobIdBit
+	}
+
+	blob := &needle.Needle{
+		Id:       blobId,
+		Cookie:   BytesToCookie(hash[NeedleIdSize : NeedleIdSize+CookieSize]),
+		Data:     data,
+		Checksum: v.checksumAlgorithm.Sum(data),
+		Name:     hash[:],
+		NameSize: uint8(len(hash)),
+	}
+	blob.ChecksumAlgorithm = v.checksumAlgorithm
+	blob.SetHasName()
+	blob.UpdateAppendAtNs(v.lastAppendAtNs)
+	offset, _, _, err := blob.Append(v.DataBackend, v.Version())
+	v.checkReadWriteError(err)
+	if err != nil {
+		return 0, err
+	}
+	v.lastAppendAtNs = blob.AppendAtNs
+	v.needleFilter.add(blobId)
+	if err = v.nm.Put(blobId, ToOffset(int64(offset)), blob.Size); err != nil {
+		glog.V(4).Infof("failed to save in needle map %d: %v", blobId, err)
+	}
+	return blobId, nil
+}
+
+// releaseBlob deletes the blob needle once no needles refer to it
+func (v *Volume) releaseBlob(blobId NeedleId) {
+	if v.blobRefs[blobId] > 1 {
+		v.blobRefs[blobId]--
+		return
+	}
+	delete(v.blobRefs, blobId)
+
+	var offset uint64
+	if !v.hasRemoteFile {
+		blob := &needle.Needle{Id: blobId}
+		blob.UpdateAppendAtNs(v.lastAppendAtNs)
+		var err error
+		offset, _, _, err = blob.Append(v.DataBackend, v.Version())
+		v.checkReadWriteError(err)
+		if err != nil {
+			glog.Errorf("volume %d delete blob %s: %v", v.Id, blobId, err)
+			return
+		}
+		v.lastAppendAtNs = blob.AppendAtNs
+	}
+	if err := v.nm.Delete(blobId, ToOffset(int64(offset))); err != nil {
+		glog.Errorf("volume %d delete blob %s: %v", v.Id, blobId, err)
+	}
+}
+
+// readBlob replaces the data of the needle with the content of the blob needle it refers to
+func (v *Volume) readBlob(n *needle.Needle, readOption *ReadOption) error {
+	blobId, err := blobRefOf(n)
+	if err != nil {
+		return err
+	}
+	nv, ok := v.nm.Get(blobId)
+	if !ok || nv.Offset.IsZero() {
+		return ErrorNotFound
+	}
+	readSize := nv.Size
+	if readSize.IsDeleted() {
+		if readOption == nil || !readOption.ReadDeleted || readSize == TombstoneFileSize {
+			return ErrorDeleted
+		}
+		readSize = -readSize
+	}
+	blob := new(needle.Needle)
+	if err = blob.ReadData(v.DataBackend, nv.Offset.ToActualOffset(), readSize, v.Version()); err != nil {
+		return fmt.Errorf("read blob %s of needle %s: %v", blobId, n.Id, err)
+	}
+	n.Data, n.DataSize, n.Checksum = blob.Data, blob.DataSize, blob.Checksum
+	return nil
+}
+
+// countRawBlobRef counts the blob needle referred by the needle written as is, e.g. when synced from a replica
+func (v *Volume) countRawBlobRef(needleId NeedleId, needleBlob []byte, size Size, oldBlobId NeedleId, hasOldBlob bool) {
+	n := new(needle.Needle)
+	if err := n.ReadBytes(needleBlob, 0, size, v.Version()); err != nil {
+		glog.Warningf("volume %d parse needle %s: %v", v.Id, needleId, err)
+		return
+	}
+	if blobId, err := blobRefOf(n); err == nil {
+		v.blobRefs[blobId]++
+	}
+	if hasOldBlob {
+		v.releaseBlob(oldBlobId)
+	}
+}
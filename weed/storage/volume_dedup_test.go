@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+)
+
+func TestDeduplicatedVolume(t *testing.T) {
+	dir := t.TempDir()
+	if err := prepareDeduplicatedVolume(VolumeFileName(dir, "", 1)); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	if !v.IsDeduplicated() {
+		t.Fatalf("volume is not deduplicated")
+	}
+
+	content := make([]byte, 256*1024)
+	rand.Read(content)
+	write := func(i uint64, data []byte) {
+		n := newEmptyNeedle(i)
+		n.Data = data
+		n.Checksum = needle.NewCRC(n.Data)
+		if _, _, _, err := v.writeNeedle2(n, true, false); err != nil {
+			t.Fatalf("write needle %d: %v", i, err)
+		}
+		if !bytes.Equal(n.Data, data) {
+			t.Fatalf("needle %d data is not restored after writing", i)
+		}
+	}
+	read := func(i uint64) ([]byte, error) {
+		n := newEmptyNeedle(i)
+		_, err := v.readNeedle(n, nil, nil)
+		return n.Data, err
+	}
+	for i := uint64(1); i <= 10; i++ {
+		write(i, content)
+	}
+	write(11, []byte("another content"))
+
+	if size := v.ContentSize(); size > 2*uint64(len(content)) {
+		t.Errorf("content size %d for 10 identical needles of %d bytes", size, len(content))
+	}
+	if len(v.blobRefs) != 2 {
+		t.Errorf("%d blobs, expected 2", len(v.blobRefs))
+	}
+	for i := uint64(1); i <= 10; i++ {
+		if data, err := read(i); err != nil || !bytes.Equal(data, content) {
+			t.Fatalf("read needle %d: %v", i, err)
+		}
+	}
+	if v.MaxFileKey() != 11 {
+		t.Errorf("max file key %d", v.MaxFileKey())
+	}
+
+	// overwriting the last reference releases the blob
+	write(11, content)
+	for i := uint64(1); i <= 10; i++ {
+		if _, err := v.deleteNeedle2(newEmptyNeedle(i)); err != nil {
+			t.Fatalf("delete needle %d: %v", i, err)
+		}
+	}
+	if len(v.blobRefs) != 1 {
+		t.Errorf("%d blobs after deletion, expected 1", len(v.blobRefs))
+	}
+	if data, err := read(11); err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("read needle 11: %v", err)
+	}
+
+	if err = v.Compact2(0, 0, nil); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if err = v.CommitCompact(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if size, _, _ := v.FileStat(); size > uint64(len(content))+4096 {
+		t.Errorf("data file size %d after vacuum", size)
+	}
+	v.Close()
+
+	if v, err = NewVolume(dir, dir, "", 1, NeedleMapInMemory, nil, nil, 0, 0, 0); err != nil {
+		t.Fatalf("volume reload: %v", err)
+	}
+	defer v.Close()
+	if blobId, found := v.findBlobRef(11); !found || len(v.blobRefs) != 1 || v.blobRefs[blobId] != 1 {
+		t.Errorf("reloaded blob references %v", v.blobRefs)
+	}
+	if data, err := read(11); err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("read needle 11 after reload: %v", err)
+	}
+	if _, err := read(1); err != ErrorNotFound {
+		t.Errorf("read deleted needle 1: %v", err)
+	}
+}
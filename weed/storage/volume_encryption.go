@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/kms"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+	"github.com/seaweedfs/seaweedfs/weed/storage/volume_info"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const volumeKeyCallTimeout = 10 * time.Second
+
+var (
+	// volumeKeyManager unwraps the data keys of the encrypted volumes
+	volumeKeyManager kms.KeyManager
+	// volumeMasterKeyId wraps the data keys of the new volumes, and the older data keys on rotation
+	volumeMasterKeyId string
+)
+
+// LoadVolumeEncryption loads the key manager under "volume.kms" in security.toml,
+// and the master key under "volume.encryption", before the volumes are loaded.
+func LoadVolumeEncryption(config util.Configuration) error {
+	keyManager, err := kms.LoadKeyManager(config, "volume.kms.")
+	if err != nil {
+		return err
+	}
+	masterKeyId := config.GetString("volume.encryption.key_id")
+	if keyManager != nil && masterKeyId == "" {
+		return fmt.Errorf("volume.encryption.key_id is not set for key manager %s", keyManager.GetName())
+	}
+	volumeKeyManager, volumeMasterKeyId = keyManager, masterKeyId
+	return nil
+}
+
+func (v *Volume) IsEncrypted() bool {
+	return v.volumeInfo.GetEncryption() != nil
+}
+
+// EncryptionKeyId is the master key wrapping the data key, empty if the volume is not encrypted
+func (v *Volume) EncryptionKeyId() string {
+	return v.volumeInfo.GetEncryption().GetKeyId()
+}
+
+// prepareEncryptedVolume saves a new data key to the .vif file, before the volume is created
+func prepareEncryptedVolume(baseFileName string) error {
+	if volumeKeyManager == nil {
+		return fmt.Errorf("no key manager is configured")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), volumeKeyCallTimeout)
+	defer cancel()
+	wrappedKey, err := volumeKeyManager.WrapKey(ctx, volumeMasterKeyId, util.GenCipherKey())
+	if err != nil {
+		return fmt.Errorf("wrap data key with %s: %v", volumeMasterKeyId, err)
+	}
+	return volume_info.SaveVolumeInfo(baseFileName+".vif", &volume_server_pb.VolumeInfo{
+		Encryption: &volume_server_pb.VolumeEncryption{
+			KeyId:      volumeMasterKeyId,
+			WrappedKey: wrappedKey,
+		},
+	})
+}
+
+// maybeEncryptDataBackend wraps the data file to encrypt and decrypt with the data key, if the volume is encrypted
+func (v *Volume) maybeEncryptDataBackend() error {
+	if !v.IsEncrypted() || v.DataBackend == nil {
+		return nil
+	}
+	if v.dataKey == nil {
+		if volumeKeyManager == nil {
+			return fmt.Errorf("no key manager is configured to decrypt volume %d", v.Id)
+		}
+		encryption := v.volumeInfo.Encryption
+		ctx, cancel := context.WithTimeout(context.Background(), volumeKeyCallTimeout)
+		defer cancel()
+		dataKey, err := volumeKeyManager.UnwrapKey(ctx, encryption.KeyId, encryption.WrappedKey)
+		if err != nil {
+			return fmt.Errorf("unwrap data key of volume %d with %s: %v", v.Id, encryption.KeyId, err)
+		}
+		v.dataKey = dataKey
+		if encryption.KeyId != volumeMasterKeyId {
+			if _, _, err = v.rotateEncryptionKey(); err != nil {
+				glog.Warningf("wrap data key of volume %d with %s: %v", v.Id, volumeMasterKeyId, err)
+			}
+		}
+	}
+
+	// the super block header is in plain text
+	compactionRevision := v.SuperBlock.CompactionRevision
+	header := make([]byte, backend.EncryptedFilePlainPrefixSize)
+	if n, _ := v.DataBackend.ReadAt(header, 0); n == len(header) {
+		compactionRevision = util.BytesToUint16(header[4:6])
+	}
+	dataBackend, err := v.encryptDataFile(v.DataBackend, compactionRevision)
+	if err != nil {
+		return err
+	}
+	v.DataBackend = dataBackend
+	return nil
+}
+
+// encryptDataFile wraps the data file of the compaction revision, which is part of the nonce,
+// so the compacted data file does not reuse the key stream at the same offsets.
+func (v *Volume) encryptDataFile(dataFile backend.BackendStorageFile, compactionRevision uint16) (backend.BackendStorageFile, error) {
+	if v.dataKey == nil {
+		return dataFile, nil
+	}
+	encryptedFile, err := backend.NewEncryptedFile(dataFile, v.dataKey, uint64(compactionRevision))
+	if err != nil {
+		return nil, fmt.Errorf("encrypt volume %d data file: %v", v.Id, err)
+	}
+	return encryptedFile, nil
+}
+
+// RotateEncryptionKey wraps the data key with the current master key, without rewriting the data.
+func (v *Volume) RotateEncryptionKey() (previousKeyId, keyId string, err error) {
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+	if !v.IsEncrypted() {
+		return "", "", fmt.Errorf("volume %d is not encrypted", v.Id)
+	}
+	return v.rotateEncryptionKey()
+}
+
+func (v *Volume) rotateEncryptionKey() (previousKeyId, keyId string, err error) {
+	encryption := v.volumeInfo.Encryption
+	if encryption.KeyId == volumeMasterKeyId {
+		return encryption.KeyId, encryption.KeyId, nil
+	}
+	if volumeKeyManager == nil || v.dataKey == nil {
+		return encryption.KeyId, "", fmt.Errorf("no key manager is configured")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), volumeKeyCallTimeout)
+	defer cancel()
+	wrappedKey, err := volumeKeyManager.WrapKey(ctx, volumeMasterKeyId, v.dataKey)
+	if err != nil {
+		return encryption.KeyId, "", fmt.Errorf("wrap data key with %s: %v", volumeMasterKeyId, err)
+	}
+	v.volumeInfo.Encryption = &volume_server_pb.VolumeEncryption{
+		KeyId:      volumeMasterKeyId,
+		WrappedKey: wrappedKey,
+	}
+	if err = v.SaveVolumeInfo(); err != nil {
+		v.volumeInfo.Encryption = encryption
+		return encryption.KeyId, "", fmt.Errorf("save volume info: %v", err)
+	}
+	glog.V(0).Infof("volume %d data key is wrapped with %s instead of %s", v.Id, volumeMasterKeyId, encryption.KeyId)
+	return encryption.KeyId, volumeMasterKeyId, nil
+}
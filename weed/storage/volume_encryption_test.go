@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/kms"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func TestEncryptedVolume(t *testing.T) {
+	keyManager, err := kms.NewLocalKeyManager([]string{
+		"old:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)),
+		"new:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32)),
+	})
+	if err != nil {
+		t.Fatalf("key manager: %v", err)
+	}
+	volumeKeyManager, volumeMasterKeyId = keyManager, "old"
+	defer func() {
+		volumeKeyManager, volumeMasterKeyId = nil, ""
+	}()
+
+	dir := t.TempDir()
+	if err = prepareEncryptedVolume(VolumeFileName(dir, "", 1)); err != nil {
+		t.Fatalf("prepare encrypted volume: %v", err)
+	}
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	if !v.IsEncrypted() || v.EncryptionKeyId() != "old" {
+		t.Fatalf("volume encrypted %v with %s", v.IsEncrypted(), v.EncryptionKeyId())
+	}
+
+	plainText := bytes.Repeat([]byte("plain text needle data "), 100)
+	for i := 1; i <= 10; i++ {
+		n := newEmptyNeedle(uint64(i))
+		n.Data = plainText
+		n.Checksum = needle.NewCRC(n.Data)
+		if _, _, _, err := v.writeNeedle2(n, true, false); err != nil {
+			t.Fatalf("write needle %d: %v", i, err)
+		}
+	}
+	for i := 1; i <= 10; i += 2 {
+		if _, err := v.deleteNeedle2(newEmptyNeedle(uint64(i))); err != nil {
+			t.Fatalf("delete needle %d: %v", i, err)
+		}
+	}
+
+	verify := func(when string) {
+		v.SyncToDisk()
+		raw, err := os.ReadFile(v.FileName(".dat"))
+		if err != nil {
+			t.Fatalf("%s: read data file: %v", when, err)
+		}
+		if bytes.Contains(raw, plainText[:64]) {
+			t.Fatalf("%s: plain text found in the data file", when)
+		}
+		for i := 2; i <= 10; i += 2 {
+			n := newEmptyNeedle(uint64(i))
+			if _, err := v.readNeedle(n, nil, nil); err != nil {
+				t.Fatalf("%s: read needle %d: %v", when, i, err)
+			}
+			if !bytes.Equal(n.Data, plainText) {
+				t.Fatalf("%s: needle %d data mismatch", when, i)
+			}
+		}
+	}
+	verify("written")
+
+	if err = v.Compact2(0, 0, nil); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if err = v.CommitCompact(); err != nil {
+		t.Fatalf("commit compact: %v", err)
+	}
+	if v.SuperBlock.CompactionRevision != 1 {
+		t.Fatalf("compaction revision %d", v.SuperBlock.CompactionRevision)
+	}
+	if fileCount := v.FileCount(); fileCount != 5 {
+		t.Fatalf("file count %d after compaction", fileCount)
+	}
+	verify("compacted")
+
+	volumeMasterKeyId = "new"
+	if previousKeyId, keyId, err := v.RotateEncryptionKey(); err != nil || previousKeyId != "old" || keyId != "new" {
+		t.Fatalf("rotate %s => %s: %v", previousKeyId, keyId, err)
+	}
+	v.Close()
+
+	// the wrapped data key is loaded from the .vif file
+	if v, err = NewVolume(dir, dir, "", 1, NeedleMapInMemory, nil, nil, 0, 0, 0); err != nil {
+		t.Fatalf("volume reloading: %v", err)
+	}
+	defer v.Close()
+	if v.EncryptionKeyId() != "new" {
+		t.Fatalf("volume encrypted with %s after rotation", v.EncryptionKeyId())
+	}
+	verify("reloaded")
+
+	n := newEmptyNeedle(uint64(12))
+	n.Data = plainText
+	n.Checksum = needle.NewCRC(n.Data)
+	if _, _, _, err := v.writeNeedle2(n, true, false); err != nil {
+		t.Fatalf("write needle after reloading: %v", err)
+	}
+	if nv, ok := v.nm.Get(types.NeedleId(12)); !ok || nv.Size.IsDeleted() {
+		t.Fatalf("needle written after reloading not found")
+	}
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/idx"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The deep fsck checks a volume online, against the .dat and .idx sizes when it starts:
+// every index entry points into the data file, the needle map agrees with the last index entry of each needle,
+// every live needle is read and matches its id, size and checksum, and the data file ends right after
+// the last indexed needle, or else holds complete needles missing from the index, or a torn write.
+
+const (
+	fsckTornIndex         = "torn_index"         // the .idx file ends with a partial entry
+	fsckBeyondDataFile    = "beyond_data_file"   // the index entry points past the end of the .dat file, e.g. truncated
+	fsckIndexDisagreement = "index_disagreement" // the needle map differs from the last index entry
+	fsckUnreadable        = "unreadable"
+	fsckIdMismatch        = "id_mismatch"
+	fsckSizeMismatch      = "size_mismatch"
+	fsckChecksum          = "checksum"
+	fsckUnindexedNeedle   = "unindexed_needle" // a complete needle after the last indexed one
+	fsckTornWrite         = "torn_write"       // a partial needle at the end of the .dat file
+)
+
+type FsckProblem struct {
+	Kind     string
+	NeedleId NeedleId
+	Offset   int64
+	Size     Size
+	Detail   string
+}
+
+type FsckResult struct {
+	CheckedCount  uint64
+	CheckedBytes  uint64
+	DataFileSize  int64
+	IndexFileSize int64
+}
+
+// FsckDeep reads the whole volume at the rate of the throttler until canceled, and reports each problem found
+func (v *Volume) FsckDeep(ctx context.Context, throttler *util.WriteThrottler, report func(problem FsckProblem) error) (result FsckResult, err error) {
+	if v.HasRemoteFile() {
+		return result, fmt.Errorf("volume %d is tiered to the remote storage", v.Id)
+	}
+
+	v.dataFileAccessLock.RLock()
+	if v.DataBackend == nil || v.nm == nil {
+		v.dataFileAccessLock.RUnlock()
+		return result, fmt.Errorf("volume %d is closed", v.Id)
+	}
+	result.DataFileSize, _, err = v.DataBackend.GetStat()
+	result.IndexFileSize = int64(v.IndexFileSize())
+	revision := v.SuperBlock.CompactionRevision
+	superBlockSize := int64(v.SuperBlock.BlockSize())
+	version := v.Version()
+	v.dataFileAccessLock.RUnlock()
+	if err != nil {
+		return result, fmt.Errorf("stat volume %d: %v", v.Id, err)
+	}
+
+	indexSize := result.IndexFileSize - result.IndexFileSize%NeedleMapEntrySize
+	if indexSize != result.IndexFileSize {
+		if err = report(FsckProblem{Kind: fsckTornIndex, Offset: indexSize, Detail: fmt.Sprintf("%d bytes after the last index entry", result.IndexFileSize-indexSize)}); err != nil {
+			return
+		}
+	}
+
+	// the index entries, and the end of the last indexed needle
+	indexFile, err := os.Open(v.FileName(".idx"))
+	if err != nil {
+		return result, err
+	}
+	defer indexFile.Close()
+	db := needle_map.NewMemDb()
+	defer db.Close()
+	tailOffset := superBlockSize
+	err = idx.WalkIndexFile(io.NewSectionReader(indexFile, 0, indexSize), 0, func(key NeedleId, offset Offset, size Size) error {
+		if offset.IsZero() {
+			return db.Delete(key)
+		}
+		actualOffset, actualSize := offset.ToActualOffset(), needle.GetActualSize(0, version)
+		if size.IsValid() {
+			actualSize = needle.GetActualSize(size, version)
+		}
+		if actualOffset+actualSize > result.DataFileSize {
+			if reportErr := report(FsckProblem{Kind: fsckBeyondDataFile, NeedleId: key, Offset: actualOffset, Size: size}); reportErr != nil {
+				return reportErr
+			}
+		} else if actualOffset+actualSize > tailOffset {
+			tailOffset = actualOffset + actualSize
+		}
+		if size.IsDeleted() {
+			return db.Delete(key)
+		}
+		return db.Set(key, offset, size)
+	})
+	if err != nil {
+		return result, fmt.Errorf("walk index of volume %d: %v", v.Id, err)
+	}
+
+	// the live needles
+	err = db.AscendingVisit(func(value needle_map.NeedleValue) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		problem, readBytes, checkErr := v.fsckNeedle(value, revision, result)
+		if checkErr != nil {
+			return checkErr
+		}
+		throttler.MaybeSlowdown(readBytes)
+		if readBytes > 0 {
+			result.CheckedCount++
+			result.CheckedBytes += uint64(readBytes)
+		}
+		if problem != nil {
+			return report(*problem)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	// the append tail
+	err = v.fsckTail(tailOffset, result.DataFileSize, revision, report)
+	return
+}
+
+func (v *Volume) fsckNeedle(value needle_map.NeedleValue, revision uint16, result FsckResult) (problem *FsckProblem, readBytes int64, err error) {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+
+	if v.nm == nil || v.DataBackend == nil || v.SuperBlock.CompactionRevision != revision {
+		return nil, 0, fmt.Errorf("volume %d is closed or compacted during the fsck", v.Id)
+	}
+	offset := value.Offset.ToActualOffset()
+	if offset+needle.GetActualSize(value.Size, v.Version()) > result.DataFileSize {
+		// reported as beyond the data file
+		return nil, 0, nil
+	}
+	if nv, ok := v.nm.Get(value.Key); !ok || nv.Offset != value.Offset || nv.Size != value.Size {
+		if int64(v.IndexFileSize()) != result.IndexFileSize {
+			// written or deleted since the fsck started
+			return nil, 0, nil
+		}
+		detail := "missing in the needle map"
+		if ok {
+			detail = fmt.Sprintf("needle map has offset %d size %d", nv.Offset.ToActualOffset(), nv.Size)
+		}
+		return &FsckProblem{Kind: fsckIndexDisagreement, NeedleId: value.Key, Offset: offset, Size: value.Size, Detail: detail}, 0, nil
+	}
+
+	readBytes = needle.GetActualSize(value.Size, v.Version())
+	if _, verifyErr := v.readVerifiedNeedleBlob(value.Key, offset, value.Size); verifyErr != nil {
+		return &FsckProblem{Kind: v.fsckNeedleErrorKind(value, offset), NeedleId: value.Key, Offset: offset, Size: value.Size, Detail: verifyErr.Error()}, readBytes, nil
+	}
+	return nil, readBytes, nil
+}
+
+// fsckNeedleErrorKind tells which part of the needle failed the verification
+func (v *Volume) fsckNeedleErrorKind(value needle_map.NeedleValue, offset int64) string {
+	n, _, _, err := needle.ReadNeedleHeader(v.DataBackend, v.Version(), offset)
+	switch {
+	case err != nil:
+		return fsckUnreadable
+	case n.Id != value.Key:
+		return fsckIdMismatch
+	case n.Size != value.Size:
+		return fsckSizeMismatch
+	}
+	return fsckChecksum
+}
+
+// fsckTail checks the data after the last indexed needle, which a crash leaves while appending
+func (v *Volume) fsckTail(tailOffset, dataFileSize int64, revision uint16, report func(problem FsckProblem) error) error {
+	if OffsetSize == 4 && dataFileSize > int64(MaxPossibleVolumeSize) {
+		// the offsets of the index entries wrap around
+		return nil
+	}
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+	if v.DataBackend == nil || v.SuperBlock.CompactionRevision != revision {
+		return fmt.Errorf("volume %d is closed or compacted during the fsck", v.Id)
+	}
+	version := v.Version()
+	for offset := tailOffset; offset < dataFileSize; {
+		n, _, _, err := needle.ReadNeedleHeader(v.DataBackend, version, offset)
+		tornWrite := FsckProblem{Kind: fsckTornWrite, Offset: offset, Detail: fmt.Sprintf("%d bytes after the last complete needle", dataFileSize-offset)}
+		// the zeros of a file extended by a crash are not a deletion of needle 0
+		if err != nil || !n.Size.IsValid() && (n.Size != 0 || n.Id == 0) || offset+n.DiskSize(version) > dataFileSize {
+			return report(tornWrite)
+		}
+		blob, err := needle.ReadNeedleBlob(v.DataBackend, offset, n.Size, version)
+		if err == nil {
+			err = new(needle.Needle).ReadBytes(blob, offset, n.Size, version)
+		}
+		if err != nil {
+			return report(tornWrite)
+		}
+		if err = report(FsckProblem{Kind: fsckUnindexedNeedle, NeedleId: n.Id, Offset: offset, Size: n.Size}); err != nil {
+			return err
+		}
+		offset += n.DiskSize(version)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestVolumeFsckDeep(t *testing.T) {
+	dir := t.TempDir()
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+
+	fsck := func() (problems []FsckProblem) {
+		result, err := v.FsckDeep(context.Background(), util.NewWriteThrottler(0), func(problem FsckProblem) error {
+			problems = append(problems, problem)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("fsck: %v", err)
+		}
+		if result.CheckedCount != 9 {
+			t.Fatalf("checked %d needles", result.CheckedCount)
+		}
+		return
+	}
+
+	var corruptedOffset uint64
+	for i := 1; i <= 10; i++ {
+		offset, _, _, err := v.writeNeedle2(newRandomNeedle(uint64(i)), true, false)
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if i == 3 {
+			corruptedOffset = offset
+		}
+	}
+	if _, err = v.deleteNeedle2(newEmptyNeedle(5)); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if problems := fsck(); len(problems) != 0 {
+		t.Fatalf("problems in a healthy volume: %+v", problems)
+	}
+
+	// a flipped bit in the data of needle 3
+	b := make([]byte, 1)
+	dataOffset := int64(corruptedOffset) + types.NeedleHeaderSize + 4
+	v.DataBackend.ReadAt(b, dataOffset)
+	b[0] ^= 1
+	v.DataBackend.WriteAt(b, dataOffset)
+
+	// a needle appended without its index entry, and a torn needle after it
+	datSize, _, _ := v.DataBackend.GetStat()
+	if _, _, _, err = newRandomNeedle(11).Append(v.DataBackend, v.Version()); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	tornOffset, _, _ := v.DataBackend.GetStat()
+	blob, _ := newRandomNeedle(12).ToBytes(v.Version())
+	v.DataBackend.WriteAt(blob[:len(blob)/2], tornOffset)
+
+	problems := fsck()
+	expected := []FsckProblem{
+		{Kind: fsckChecksum, NeedleId: 3, Offset: int64(corruptedOffset)},
+		{Kind: fsckUnindexedNeedle, NeedleId: 11, Offset: datSize},
+		{Kind: fsckTornWrite, Offset: tornOffset},
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("problems: %+v", problems)
+	}
+	for i, problem := range problems {
+		if problem.Kind != expected[i].Kind || problem.NeedleId != expected[i].NeedleId || problem.Offset != expected[i].Offset {
+			t.Errorf("problem %+v, expected %+v", problem, expected[i])
+		}
+	}
+}
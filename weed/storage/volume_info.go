@@ -25,6 +25,25 @@ type VolumeInfo struct {
 	ModifiedAtSecond  int64
 	RemoteStorageName string
 	RemoteStorageKey  string
+
+	ScrubCorruptedCount uint64
+	ScrubRepairedCount  uint64
+	ScrubbedAtSecond    int64
+
+	EncryptionKeyId string
+
+	ReadAtSecond int64
+	ReadCount    uint64
+
+	RemoteRestoreState           string
+	RemoteRestoreExpiresAtSecond int64
+
+	ReadRepairedCount    uint64
+	ReadRepairedAtSecond int64
+
+	DiskFailing bool
+
+	CreatedAtSecond int64
 }
 
 func NewVolumeInfo(m *master_pb.VolumeInformationMessage) (vi VolumeInfo, err error) {
@@ -42,6 +61,25 @@ func NewVolumeInfo(m *master_pb.VolumeInformationMessage) (vi VolumeInfo, err er
 		RemoteStorageName: m.RemoteStorageName,
 		RemoteStorageKey:  m.RemoteStorageKey,
 		DiskType:          m.DiskType,
+
+		ScrubCorruptedCount: m.ScrubCorruptedCount,
+		ScrubRepairedCount:  m.ScrubRepairedCount,
+		ScrubbedAtSecond:    m.ScrubbedAtSecond,
+
+		EncryptionKeyId: m.EncryptionKeyId,
+
+		ReadAtSecond: m.ReadAtSecond,
+		ReadCount:    m.ReadCount,
+
+		RemoteRestoreState:           m.RemoteRestoreState,
+		RemoteRestoreExpiresAtSecond: m.RemoteRestoreExpiresAtSecond,
+
+		ReadRepairedCount:    m.ReadRepairedCount,
+		ReadRepairedAtSecond: m.ReadRepairedAtSecond,
+
+		DiskFailing: m.DiskFailing,
+
+		CreatedAtSecond: m.CreatedAtSecond,
 	}
 	rp, e := super_block.NewReplicaPlacementFromByte(byte(m.ReplicaPlacement))
 	if e != nil {
@@ -94,6 +132,25 @@ func (vi VolumeInfo) ToVolumeInformationMessage() *master_pb.VolumeInformationMe
 		RemoteStorageName: vi.RemoteStorageName,
 		RemoteStorageKey:  vi.RemoteStorageKey,
 		DiskType:          vi.DiskType,
+
+		ScrubCorruptedCount: vi.ScrubCorruptedCount,
+		ScrubRepairedCount:  vi.ScrubRepairedCount,
+		ScrubbedAtSecond:    vi.ScrubbedAtSecond,
+
+		EncryptionKeyId: vi.EncryptionKeyId,
+
+		ReadAtSecond: vi.ReadAtSecond,
+		ReadCount:    vi.ReadCount,
+
+		RemoteRestoreState:           vi.RemoteRestoreState,
+		RemoteRestoreExpiresAtSecond: vi.RemoteRestoreExpiresAtSecond,
+
+		ReadRepairedCount:    vi.ReadRepairedCount,
+		ReadRepairedAtSecond: vi.ReadRepairedAtSecond,
+
+		DiskFailing: vi.DiskFailing,
+
+		CreatedAtSecond: vi.CreatedAtSecond,
 	}
 }
 
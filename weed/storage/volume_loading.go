@@ -3,11 +3,10 @@ package storage
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
 
-	"github.com/syndtr/goleveldb/leveldb/opt"
-
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/stats"
 	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
@@ -41,7 +40,14 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 		}
 	}()
 
+	if err = v.recoverRenewedObjectStore(); err != nil {
+		return fmt.Errorf("volume %d: %v", v.Id, err)
+	}
 	hasVolumeInfoFile := v.maybeLoadVolumeInfo()
+	isCreated := false
+	if v.checksumAlgorithm, err = needle.ParseChecksumAlgorithm(v.volumeInfo.GetChecksumAlgorithm()); err != nil {
+		return fmt.Errorf("volume %d: %v", v.Id, err)
+	}
 
 	if v.HasRemoteFile() {
 		v.noWriteCanDelete = true
@@ -57,6 +63,9 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 		var dataFile *os.File
 		if canWrite {
 			dataFile, err = os.OpenFile(v.FileName(".dat"), os.O_RDWR|os.O_CREATE, 0644)
+			if err == nil {
+				err = v.replayOverwriteLog(dataFile)
+			}
 		} else {
 			glog.V(0).Infof("opening %s in READONLY mode", v.FileName(".dat"))
 			dataFile, err = os.Open(v.FileName(".dat"))
@@ -70,6 +79,7 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 	} else {
 		if createDatIfMissing {
 			v.DataBackend, err = backend.CreateVolumeFile(v.FileName(".dat"), preallocate, v.MemoryMapMaxSizeMb)
+			isCreated = true
 		} else {
 			return fmt.Errorf("volume data file %s does not exist", v.FileName(".dat"))
 		}
@@ -83,6 +93,16 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 		}
 	}
 
+	if err = v.useObjectStore(); err != nil {
+		return err
+	}
+	if err = v.maybeEncryptDataBackend(); err != nil {
+		return err
+	}
+	if v.hasDiskReadCache {
+		v.DataBackend = backend.MaybeCacheDiskFile(v.DataBackend)
+	}
+
 	if alreadyHasSuperBlock {
 		err = v.readSuperBlock()
 		if err == nil {
@@ -128,67 +148,20 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 			glog.V(0).Infof("volumeDataIntegrityChecking failed %v", err)
 		}
 
-		if v.noWriteOrDelete || v.noWriteCanDelete {
-			if v.nm, err = NewSortedFileNeedleMap(v.IndexFileName(), indexFile); err != nil {
+		if (v.noWriteOrDelete || v.noWriteCanDelete) && (v.compactIndex || IsCompactIndexFresh(v.IndexFileName())) {
+			if v.nm, err = NewCompactIndexNeedleMap(v.IndexFileName(), indexFile); err != nil {
+				glog.V(0).Infof("loading compact index %s error: %v", v.FileName(".cdx"), err)
+			}
+		} else if v.noWriteOrDelete || v.noWriteCanDelete {
+			if v.nm, err = NewSortedFileNeedleMap(v.IndexFileName(), indexFile, v.memoryMappedIndex); err != nil {
 				glog.V(0).Infof("loading sorted db %s error: %v", v.FileName(".sdx"), err)
 			}
 		} else {
-			switch needleMapKind {
-			case NeedleMapInMemory:
-				if v.tmpNm != nil {
-					glog.V(0).Infof("updating memory compact index %s ", v.FileName(".idx"))
-					err = v.tmpNm.UpdateNeedleMap(v, indexFile, nil, 0)
-				} else {
-					glog.V(0).Infoln("loading memory index", v.FileName(".idx"), "to memory")
-					if v.nm, err = LoadCompactNeedleMap(indexFile); err != nil {
-						glog.V(0).Infof("loading index %s to memory error: %v", v.FileName(".idx"), err)
-					}
-				}
-			case NeedleMapLevelDb:
-				opts := &opt.Options{
-					BlockCacheCapacity:            2 * 1024 * 1024, // default value is 8MiB
-					WriteBuffer:                   1 * 1024 * 1024, // default value is 4MiB
-					CompactionTableSizeMultiplier: 10,              // default value is 1
-				}
-				if v.tmpNm != nil {
-					glog.V(0).Infoln("updating leveldb index", v.FileName(".ldb"))
-					err = v.tmpNm.UpdateNeedleMap(v, indexFile, opts, v.ldbTimeout)
-				} else {
-					glog.V(0).Infoln("loading leveldb index", v.FileName(".ldb"))
-					if v.nm, err = NewLevelDbNeedleMap(v.FileName(".ldb"), indexFile, opts, v.ldbTimeout); err != nil {
-						glog.V(0).Infof("loading leveldb %s error: %v", v.FileName(".ldb"), err)
-					}
-				}
-			case NeedleMapLevelDbMedium:
-				opts := &opt.Options{
-					BlockCacheCapacity:            4 * 1024 * 1024, // default value is 8MiB
-					WriteBuffer:                   2 * 1024 * 1024, // default value is 4MiB
-					CompactionTableSizeMultiplier: 10,              // default value is 1
-				}
-				if v.tmpNm != nil {
-					glog.V(0).Infoln("updating leveldb medium index", v.FileName(".ldb"))
-					err = v.tmpNm.UpdateNeedleMap(v, indexFile, opts, v.ldbTimeout)
-				} else {
-					glog.V(0).Infoln("loading leveldb medium index", v.FileName(".ldb"))
-					if v.nm, err = NewLevelDbNeedleMap(v.FileName(".ldb"), indexFile, opts, v.ldbTimeout); err != nil {
-						glog.V(0).Infof("loading leveldb %s error: %v", v.FileName(".ldb"), err)
-					}
-				}
-			case NeedleMapLevelDbLarge:
-				opts := &opt.Options{
-					BlockCacheCapacity:            8 * 1024 * 1024, // default value is 8MiB
-					WriteBuffer:                   4 * 1024 * 1024, // default value is 4MiB
-					CompactionTableSizeMultiplier: 10,              // default value is 1
-				}
-				if v.tmpNm != nil {
-					glog.V(0).Infoln("updating leveldb large index", v.FileName(".ldb"))
-					err = v.tmpNm.UpdateNeedleMap(v, indexFile, opts, v.ldbTimeout)
-				} else {
-					glog.V(0).Infoln("loading leveldb large index", v.FileName(".ldb"))
-					if v.nm, err = NewLevelDbNeedleMap(v.FileName(".ldb"), indexFile, opts, v.ldbTimeout); err != nil {
-						glog.V(0).Infof("loading leveldb %s error: %v", v.FileName(".ldb"), err)
-					}
-				}
+			if v.tmpNm != nil {
+				glog.V(0).Infoln("updating compacted index", v.FileName(".idx"))
+				err = v.tmpNm.UpdateNeedleMap(v, indexFile)
+			} else if v.nm, err = v.loadNeedleMap(needleMapKind, indexFile); err != nil {
+				glog.V(0).Infof("loading index %s error: %v", v.FileName(".idx"), err)
 			}
 		}
 	}
@@ -196,11 +169,28 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 	if !hasVolumeInfoFile {
 		v.volumeInfo.Version = uint32(v.SuperBlock.Version)
 		v.volumeInfo.BytesOffset = uint32(types.OffsetSize)
+	}
+	if isCreated {
+		// the age of the volume for its retention
+		v.volumeInfo.CreatedAtSecond = time.Now().Unix()
+	}
+	if !hasVolumeInfoFile || isCreated {
 		if err := v.SaveVolumeInfo(); err != nil {
 			glog.Warningf("volume %d failed to save file info: %v", v.Id, err)
 		}
 	}
 
+	if err == nil && alsoLoadIndex {
+		if err = v.loadBlobRefs(); err != nil {
+			err = fmt.Errorf("load blob references of volume %d: %v", v.Id, err)
+		}
+	}
+	if err == nil && alsoLoadIndex && v.hasNeedleFilter {
+		if err = v.loadNeedleFilter(); err != nil {
+			err = fmt.Errorf("load needle filter of volume %d: %v", v.Id, err)
+		}
+	}
+
 	stats.VolumeServerVolumeCounter.WithLabelValues(v.Collection, "volume").Inc()
 
 	if err == nil {
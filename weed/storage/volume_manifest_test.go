@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestVolumeManifest(t *testing.T) {
+	s := NewStore(nil, "localhost", 8080, 18080, "localhost:8080", []string{t.TempDir()}, []int32{10},
+		[]util.MinFreeSpace{{}}, "", NeedleMapInMemory, []types.DiskType{types.HardDriveType}, 0)
+	defer s.Close()
+	if err := s.AddVolume(1, "audit", NeedleMapInMemory, "000", "", 0, 0, types.HardDriveType, 0); err != nil {
+		t.Fatalf("add volume: %v", err)
+	}
+	<-s.NewVolumesChan
+
+	written := make(map[types.NeedleId]*needle.Needle)
+	for _, i := range []uint64{5, 3, 8, 1} {
+		n := newRandomNeedle(i)
+		if _, err := s.WriteVolumeNeedle(1, n, false, false); err != nil {
+			t.Fatalf("write needle %d: %v", i, err)
+		}
+		written[n.Id] = n
+	}
+	if _, err := s.DeleteVolumeNeedle(1, newEmptyNeedle(8)); err != nil {
+		t.Fatalf("delete needle 8: %v", err)
+	}
+
+	v := s.GetVolume(1)
+	var entries []ManifestEntry
+	if err := v.WalkManifest(func(entry ManifestEntry) error {
+		entries = append(entries, entry)
+		return nil
+	}); err != nil {
+		t.Fatalf("walk manifest: %v", err)
+	}
+	if len(entries) != 3 || entries[0].NeedleId != 1 || entries[1].NeedleId != 3 || entries[2].NeedleId != 5 {
+		t.Fatalf("manifest entries %+v, expected needles 1 3 5", entries)
+	}
+	for _, entry := range entries {
+		n := written[entry.NeedleId]
+		if entry.Cookie != n.Cookie || entry.Size != n.Size || entry.Checksum != n.Checksum.Value() {
+			t.Fatalf("manifest entry %+v, written cookie %x size %d crc %x", entry, n.Cookie, n.Size, n.Checksum.Value())
+		}
+	}
+
+	sign := func(key string, entries []ManifestEntry) *ManifestSigner {
+		signer := NewManifestSigner([]byte(key), 1, "audit")
+		for _, entry := range entries {
+			signer.Add(entry)
+		}
+		return signer
+	}
+	signature := sign("secret", entries).Sign(123)
+	if !sign("secret", entries).Verify(123, signature) {
+		t.Fatalf("signature not verified")
+	}
+	if sign("other", entries).Verify(123, signature) {
+		t.Fatalf("signature verified with another key")
+	}
+	if sign("secret", entries[1:]).Verify(123, signature) {
+		t.Fatalf("signature verified with a missing entry")
+	}
+	entries[0].Checksum++
+	if sign("secret", entries).Verify(123, signature) {
+		t.Fatalf("signature verified with a changed checksum")
+	}
+}
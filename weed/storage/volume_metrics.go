@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+)
+
+func (v *Volume) trackNeedleRequest(requestType string) (done func(err error)) {
+	return trackNeedleRequest(v.Collection, v.dir, requestType)
+}
+
+// trackNeedleRequest counts the needle request as queued on the collection and disk,
+// and the returned function records its latency, and the error unless the needle is just missing.
+func trackNeedleRequest(collection, disk, requestType string) (done func(err error)) {
+	queue := stats.VolumeServerNeedleQueueGauge.WithLabelValues(collection, disk, requestType)
+	queue.Inc()
+	start := time.Now()
+	return func(err error) {
+		queue.Dec()
+		stats.VolumeServerNeedleHistogram.WithLabelValues(collection, disk, requestType).Observe(time.Since(start).Seconds())
+		if isNeedleRequestError(err) {
+			stats.VolumeServerNeedleErrorCounter.WithLabelValues(collection, disk, requestType).Inc()
+		}
+	}
+}
+
+// isNeedleRequestError tells whether the needle request failed, other than on a missing needle
+func isNeedleRequestError(err error) bool {
+	return err != nil && err != ErrorNotFound && err != ErrorDeleted && !errors.Is(err, erasure_coding.NotFoundError)
+}
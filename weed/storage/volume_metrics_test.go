@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+)
+
+func TestTrackNeedleRequest(t *testing.T) {
+	collection, disk := "metrics_test", t.TempDir()
+	queue := stats.VolumeServerNeedleQueueGauge.WithLabelValues(collection, disk, stats.ReadNeedle)
+	errorCounter := stats.VolumeServerNeedleErrorCounter.WithLabelValues(collection, disk, stats.ReadNeedle)
+
+	var dones []func(error)
+	for i := 0; i < 5; i++ {
+		dones = append(dones, trackNeedleRequest(collection, disk, stats.ReadNeedle))
+	}
+	if depth := testutil.ToFloat64(queue); depth != 5 {
+		t.Fatalf("queue depth %v, expected 5", depth)
+	}
+	dones[0](nil)
+	dones[1](ErrorNotFound)
+	dones[2](ErrorDeleted)
+	dones[3](fmt.Errorf("locate in local ec volume: %w", erasure_coding.NotFoundError))
+	dones[4](errors.New("CRC error"))
+
+	if depth := testutil.ToFloat64(queue); depth != 0 {
+		t.Fatalf("queue depth %v, expected 0", depth)
+	}
+	if count := testutil.ToFloat64(errorCounter); count != 1 {
+		t.Fatalf("error count %v, expected 1", count)
+	}
+	if count := testutil.CollectAndCount(stats.VolumeServerNeedleHistogram, "SeaweedFS_volumeServer_needle_seconds"); count == 0 {
+		t.Fatalf("no latency observed")
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"os"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/idx"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	boom "github.com/tylertreat/BoomFilters"
+)
+
+// The needle filter is a bloom filter of the needle ids ever written to the volume, kept in memory,
+// so the lookups of the missing needles, e.g. from probing clients or read repair checks,
+// are answered without looking up the index or touching the disk.
+// It is built from the .idx file when the volume is loaded, and each needle id is added before it is indexed.
+// A false positive only costs the usual index lookup.
+
+const (
+	needleFilterFalsePositiveRate = 0.001
+	needleFilterMinimumHint       = 1024
+)
+
+type needleFilter struct {
+	sync.RWMutex
+	filter *boom.ScalableBloomFilter
+}
+
+func newNeedleFilter(hint uint) *needleFilter {
+	if hint < needleFilterMinimumHint {
+		hint = needleFilterMinimumHint
+	}
+	return &needleFilter{filter: boom.NewScalableBloomFilter(hint, needleFilterFalsePositiveRate, 0.8)}
+}
+
+func (f *needleFilter) add(key NeedleId) {
+	if f == nil {
+		return
+	}
+	b := make([]byte, NeedleIdSize)
+	NeedleIdToBytes(b, key)
+	f.Lock()
+	defer f.Unlock()
+	f.filter.Add(b)
+}
+
+// mayContain is false if the needle id is never added, and true without the filter
+func (f *needleFilter) mayContain(key NeedleId) bool {
+	if f == nil {
+		return true
+	}
+	b := make([]byte, NeedleIdSize)
+	NeedleIdToBytes(b, key)
+	f.RLock()
+	defer f.RUnlock()
+	return f.filter.Test(b)
+}
+
+// SetNeedleFilterCollections keeps a bloom filter of the needle ids for the volumes of the collections, "*" for all collections
+func (s *Store) SetNeedleFilterCollections(collections []string) {
+	s.needleFilterCollections = make(map[string]bool)
+	for _, collection := range collections {
+		s.needleFilterCollections[collection] = true
+	}
+	if len(s.needleFilterCollections) == 0 {
+		return
+	}
+	for _, location := range s.Locations {
+		location.volumesLock.RLock()
+		for _, v := range location.volumes {
+			s.maybeUseNeedleFilter(v)
+		}
+		location.volumesLock.RUnlock()
+	}
+}
+
+func (s *Store) maybeUseNeedleFilter(v *Volume) {
+	if !s.needleFilterCollections["*"] && !s.needleFilterCollections[v.Collection] {
+		return
+	}
+	if err := v.useNeedleFilter(); err != nil {
+		glog.Warningf("volume %d looks up needles without the bloom filter: %v", v.Id, err)
+	}
+}
+
+// useNeedleFilter builds the needle filter, and rebuilds it whenever the volume is reloaded
+func (v *Volume) useNeedleFilter() error {
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+	v.hasNeedleFilter = true
+	return v.loadNeedleFilter()
+}
+
+func (v *Volume) loadNeedleFilter() error {
+	indexFile, err := os.Open(v.FileName(".idx"))
+	if err != nil {
+		v.needleFilter = nil
+		return err
+	}
+	defer indexFile.Close()
+	var hint uint
+	if stat, statErr := indexFile.Stat(); statErr == nil {
+		hint = uint(stat.Size() / NeedleMapEntrySize)
+	}
+	f := newNeedleFilter(hint)
+	if err = idx.WalkIndexFile(indexFile, 0, func(key NeedleId, offset Offset, size Size) error {
+		f.add(key)
+		return nil
+	}); err != nil {
+		v.needleFilter = nil
+		return err
+	}
+	v.needleFilter = f
+	return nil
+}
+
+// mayHaveNeedle is false if the needle is never written to the volume
+func (v *Volume) mayHaveNeedle(key NeedleId) bool {
+	return v.needleFilter.mayContain(key)
+}
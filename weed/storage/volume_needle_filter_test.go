@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestNeedleFilter(t *testing.T) {
+	s := NewStore(nil, "localhost", 8080, 18080, "localhost:8080", []string{t.TempDir()}, []int32{10},
+		[]util.MinFreeSpace{{}}, "", NeedleMapInMemory, []types.DiskType{types.HardDriveType}, 0)
+	defer s.Close()
+	if err := s.AddVolume(1, "filtered", NeedleMapInMemory, "000", "", 0, 0, types.HardDriveType, 0); err != nil {
+		t.Fatalf("add volume: %v", err)
+	}
+	<-s.NewVolumesChan
+	for i := uint64(1); i <= 10; i++ {
+		if _, err := s.WriteVolumeNeedle(1, newRandomNeedle(i), false, false); err != nil {
+			t.Fatalf("write needle %d: %v", i, err)
+		}
+	}
+	v := s.GetVolume(1)
+	if v.needleFilter != nil {
+		t.Fatalf("needle filter of the collection not configured")
+	}
+
+	// built from the index of the existing volume
+	s.SetNeedleFilterCollections([]string{"filtered"})
+	if v.needleFilter == nil {
+		t.Fatalf("needle filter not built")
+	}
+	if _, err := s.WriteVolumeNeedle(1, newRandomNeedle(11), false, false); err != nil {
+		t.Fatalf("write needle 11: %v", err)
+	}
+	for i := uint64(1); i <= 11; i++ {
+		if !v.mayHaveNeedle(types.Uint64ToNeedleId(i)) {
+			t.Fatalf("needle %d filtered out", i)
+		}
+		n := &needle.Needle{Id: types.Uint64ToNeedleId(i)}
+		if _, err := s.ReadVolumeNeedle(1, n, nil, nil); err != nil {
+			t.Fatalf("read needle %d: %v", i, err)
+		}
+	}
+	n := &needle.Needle{Id: 12}
+	if _, err := s.ReadVolumeNeedle(1, n, nil, nil); err != ErrorNotFound {
+		t.Fatalf("read missing needle: %v", err)
+	}
+	if _, err := s.DeleteVolumeNeedle(1, newEmptyNeedle(1)); err != nil {
+		t.Fatalf("delete needle 1: %v", err)
+	}
+	if _, err := s.ReadVolumeNeedle(1, &needle.Needle{Id: 1}, nil, nil); err != ErrorDeleted {
+		t.Fatalf("read deleted needle: %v", err)
+	}
+
+	// rebuilt when the volume is reloaded
+	if err := s.UnmountVolume(1); err != nil {
+		t.Fatalf("unmount volume: %v", err)
+	}
+	<-s.DeletedVolumesChan
+	if err := s.MountVolume(1); err != nil {
+		t.Fatalf("mount volume: %v", err)
+	}
+	<-s.NewVolumesChan
+	if v = s.GetVolume(1); v.needleFilter == nil || !v.mayHaveNeedle(11) {
+		t.Fatalf("needle filter not rebuilt after mounting")
+	}
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+
+	"github.com/google/uuid"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/storage/volume_info"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The volumes on the folders of the "object" disk type keep their .dat files on the object store,
+// and only the .idx, .vif files and the segment of the .dat file not uploaded yet on the local disk.
+
+// SetObjectStore stores the .dat files of the new volumes on the object disks in the storage backend
+func (s *Store) SetObjectStore(backendName string, segmentSizeMB int) error {
+	for _, location := range s.Locations {
+		if location.DiskType != ObjectStoreType {
+			continue
+		}
+		if _, err := objectBackendStorage(backendName); err != nil {
+			return fmt.Errorf("object disk %s: %v", location.Directory, err)
+		}
+		if segmentSizeMB <= 0 {
+			return fmt.Errorf("object disk %s: segment size %dMB", location.Directory, segmentSizeMB)
+		}
+	}
+	s.objectStoreBackend = backendName
+	s.objectStoreSegmentSize = int64(segmentSizeMB) * 1024 * 1024
+	return nil
+}
+
+func objectBackendStorage(backendName string) (backend.ObjectBackendStorage, error) {
+	backendStorage, found := backend.BackendStorages[backendName]
+	if !found {
+		return nil, fmt.Errorf("storage backend %q is not configured on the master", backendName)
+	}
+	objectStorage, ok := backendStorage.(backend.ObjectBackendStorage)
+	if !ok {
+		return nil, fmt.Errorf("storage backend %s does not store objects by keys", backendName)
+	}
+	if archival, ok := backendStorage.(backend.ArchivalBackendStorage); ok && archival.IsArchival() {
+		return nil, fmt.Errorf("storage backend %s archives the objects offline", backendName)
+	}
+	return objectStorage, nil
+}
+
+// prepareObjectStoreVolume marks the volume to be on the object store in the .vif file, before the volume is created
+func (s *Store) prepareObjectStoreVolume(baseFileName string) error {
+	if s.objectStoreBackend == "" {
+		return fmt.Errorf("the storage backend of the object disk is not set")
+	}
+	volumeInfo, _, _, err := volume_info.MaybeLoadVolumeInfo(baseFileName + ".vif")
+	if err != nil {
+		return err
+	}
+	volumeInfo.ObjectStore = &volume_server_pb.ObjectStoreFile{
+		BackendName: s.objectStoreBackend,
+		Key:         newObjectStoreKey(baseFileName),
+		SegmentSize: uint64(s.objectStoreSegmentSize),
+	}
+	return volume_info.SaveVolumeInfo(baseFileName+".vif", volumeInfo)
+}
+
+// newObjectStoreKey is unique for each replica and each compaction of the volume
+func newObjectStoreKey(baseFileName string) string {
+	return path.Join(filepath.Base(baseFileName), uuid.New().String())
+}
+
+func (v *Volume) IsObjectStored() bool {
+	return v.volumeInfo.GetObjectStore() != nil
+}
+
+// useObjectStore reads the uploaded segments of the data file from the object store, and uploads the full segments
+func (v *Volume) useObjectStore() error {
+	objectStore := v.volumeInfo.GetObjectStore()
+	if objectStore == nil || v.DataBackend == nil {
+		return nil
+	}
+	objectStorage, err := objectBackendStorage(objectStore.BackendName)
+	if err != nil {
+		return fmt.Errorf("volume %d: %v", v.Id, err)
+	}
+	v.objectStoreFile = backend.NewObjectStoreFile(v.DataBackend, objectStorage, objectStore.Key,
+		int64(objectStore.SegmentSize), int64(objectStore.UploadedSize), func(uploadedSize int64) error {
+			if v.volumeInfo.GetObjectStore() != objectStore {
+				return fmt.Errorf("volume %d is compacted", v.Id)
+			}
+			objectStore.UploadedSize = uint64(uploadedSize)
+			return v.SaveVolumeInfo()
+		})
+	v.DataBackend = v.objectStoreFile
+	v.objectStoreFile.MaybeUpload()
+	return nil
+}
+
+// objectStoreView reads the data file opened again, e.g. for compaction
+func (v *Volume) objectStoreView(dataFile backend.BackendStorageFile) backend.BackendStorageFile {
+	if v.objectStoreFile == nil {
+		return dataFile
+	}
+	return v.objectStoreFile.NewView(dataFile)
+}
+
+// renewObjectStore uploads the compacted data file with a new key, and returns the old one to delete its segments.
+// The new key is saved in the .cpv file, which replaces the .vif file once the compacted files are renamed,
+// by commitRenewedObjectStore, or by recoverRenewedObjectStore after a crash in between.
+func (v *Volume) renewObjectStore() (*volume_server_pb.ObjectStoreFile, error) {
+	objectStore := v.volumeInfo.GetObjectStore()
+	if objectStore == nil {
+		return nil, nil
+	}
+	v.volumeInfo.ObjectStore = &volume_server_pb.ObjectStoreFile{
+		BackendName: objectStore.BackendName,
+		Key:         newObjectStoreKey(v.DataFileName()),
+		SegmentSize: objectStore.SegmentSize,
+	}
+	if err := volume_info.SaveVolumeInfo(v.FileName(".cpv"), v.volumeInfo); err != nil {
+		v.volumeInfo.ObjectStore = objectStore
+		return nil, fmt.Errorf("save volume %d info: %v", v.Id, err)
+	}
+	return objectStore, nil
+}
+
+// commitRenewedObjectStore replaces the .vif file with the .cpv file, after the compacted files are renamed,
+// and deletes the segments of the old key once the new key is durable
+func (v *Volume) commitRenewedObjectStore(oldObjectStore *volume_server_pb.ObjectStoreFile) error {
+	if err := os.Rename(v.FileName(".cpv"), v.FileName(".vif")); err != nil {
+		return fmt.Errorf("rename %s: %v", v.FileName(".cpv"), err)
+	}
+	if err := syncDir(filepath.Dir(v.FileName(".vif"))); err != nil {
+		glog.Warningf("volume %d keeps the old segments of %s: %v", v.Id, oldObjectStore.Key, err)
+		return nil
+	}
+	v.deleteObjectStoreSegments(oldObjectStore)
+	return nil
+}
+
+// recoverRenewedObjectStore finishes or drops the renewed object store key, if the compaction commit crashed:
+// the .dat file is the compacted one once the .cpd file is renamed, so it needs the key in the .cpv file
+func (v *Volume) recoverRenewedObjectStore() error {
+	if !util.FileExists(v.FileName(".cpv")) {
+		return nil
+	}
+	if util.FileExists(v.FileName(".cpd")) {
+		return os.Remove(v.FileName(".cpv"))
+	}
+	oldVolumeInfo, _, _, err := volume_info.MaybeLoadVolumeInfo(v.FileName(".vif"))
+	if err != nil {
+		return err
+	}
+	glog.V(0).Infof("volume %d finishes committing the compacted data file", v.Id)
+	if oldObjectStore := oldVolumeInfo.GetObjectStore(); oldObjectStore != nil {
+		return v.commitRenewedObjectStore(oldObjectStore)
+	}
+	return os.Rename(v.FileName(".cpv"), v.FileName(".vif"))
+}
+
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (v *Volume) deleteObjectStoreSegments(objectStore *volume_server_pb.ObjectStoreFile) {
+	objectStorage, err := objectBackendStorage(objectStore.BackendName)
+	if err != nil {
+		glog.Warningf("delete segments of volume %d: %v", v.Id, err)
+		return
+	}
+	if objectStore.SegmentSize == 0 {
+		return
+	}
+	uploadedSize := int64(objectStore.UploadedSize)
+	if v.objectStoreFile != nil && v.objectStoreFile.UploadedSize() > uploadedSize {
+		uploadedSize = v.objectStoreFile.UploadedSize()
+	}
+	// the next segment may be uploaded, before its uploaded size is saved
+	segmentCount := uploadedSize/int64(objectStore.SegmentSize) + 1
+	go backend.DeleteObjectSegments(objectStorage, objectStore.Key, segmentCount)
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/volume_info"
+)
+
+func TestRecoverRenewedObjectStore(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		isRenamed   bool
+		expectedKey string
+	}{
+		{"crashed before renaming the compacted files", false, "old"},
+		{"crashed after renaming the compacted files", true, "new"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := &Volume{dir: t.TempDir(), Id: 1}
+			for ext, key := range map[string]string{".vif": "old", ".cpv": "new"} {
+				volumeInfo := &volume_server_pb.VolumeInfo{ObjectStore: &volume_server_pb.ObjectStoreFile{BackendName: "s3.default", Key: key, SegmentSize: 1024}}
+				if err := volume_info.SaveVolumeInfo(v.FileName(ext), volumeInfo); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !tc.isRenamed {
+				if err := os.WriteFile(v.FileName(".cpd"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := v.recoverRenewedObjectStore(); err != nil {
+				t.Fatalf("recover: %v", err)
+			}
+			volumeInfo, _, _, err := volume_info.MaybeLoadVolumeInfo(v.FileName(".vif"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key := volumeInfo.GetObjectStore().GetKey(); key != tc.expectedKey {
+				t.Errorf("object store key %q, expected %q", key, tc.expectedKey)
+			}
+			if _, err := os.Stat(v.FileName(".cpv")); !os.IsNotExist(err) {
+				t.Errorf("kept %s: %v", v.FileName(".cpv"), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The volumes of the collections set to overwrite in place write a needle over the existing one,
+// if it has the same cookie and size, instead of appending it and leaving the existing one as garbage to vacuum.
+// The needle is appended to the overwrite log of the volume and synced first, so a torn overwrite after a crash
+// is written again from the log when the volume is loaded. The log is emptied whenever the .dat file is synced.
+// The overwritten needle keeps its offset, its .idx entry and its append time, so the overwrites are not seen
+// by volume.tail, the incremental copies, or the replica streams, which all follow the appended needles.
+
+const (
+	overwriteLogRecordHeaderSize = 4 + 4 + 8 // crc, needle length, offset
+	// the .dat file is synced, and the log is emptied, once the log grows over the size
+	overwriteLogCheckpointSize = 4 * 1024 * 1024
+)
+
+// SetInPlaceOverwriteCollections overwrites the needles of the same size in place for the volumes of the collections, "*" for all collections
+func (s *Store) SetInPlaceOverwriteCollections(collections []string) {
+	s.inPlaceOverwriteCollections = make(map[string]bool)
+	for _, collection := range collections {
+		s.inPlaceOverwriteCollections[collection] = true
+	}
+	if len(s.inPlaceOverwriteCollections) == 0 {
+		return
+	}
+	for _, location := range s.Locations {
+		location.volumesLock.RLock()
+		for _, v := range location.volumes {
+			s.maybeOverwriteInPlace(v)
+		}
+		location.volumesLock.RUnlock()
+	}
+}
+
+func (s *Store) maybeOverwriteInPlace(v *Volume) {
+	if !s.inPlaceOverwriteCollections["*"] && !s.inPlaceOverwriteCollections[v.Collection] {
+		return
+	}
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+	v.overwritesInPlace = true
+}
+
+// canOverwriteInPlace is false if the data file is not a plain local file, or the volume is being compacted,
+// since the compaction only copies the needles appended after it started again
+func (v *Volume) canOverwriteInPlace() bool {
+	if !v.overwritesInPlace || v.isCompacting || v.isCommitCompacting || v.MemoryMapMaxSizeMb != 0 {
+		return false
+	}
+	if v.HasRemoteFile() || v.IsObjectStored() || v.IsEncrypted() || v.IsDeduplicated() || v.Version() != needle.Version3 {
+		return false
+	}
+	diskFile, ok := backend.UnwrapDiskFile(v.DataBackend)
+	if !ok {
+		return false
+	}
+	// the overwrites would also change the snapshots sharing the file
+	if links, err := hardLinkCount(diskFile.File); err != nil || links > 1 {
+		return false
+	}
+	return true
+}
+
+// overwriteInPlace writes the needle over the existing one, and returns false if their sizes are different
+func (v *Volume) overwriteInPlace(n *needle.Needle, nv *needle_map.NeedleValue) (bool, error) {
+	offset := nv.Offset.ToActualOffset()
+	appendAtNs, err := readNeedleAppendAtNs(v.DataBackend, offset, nv.Size)
+	if err != nil {
+		return false, err
+	}
+	// keep the append time, so the volume can still be binary searched by the append time
+	n.AppendAtNs = appendAtNs
+	blob, err := n.ToBytes(needle.Version3)
+	if err != nil {
+		return false, err
+	}
+	if n.Size != nv.Size {
+		return false, nil
+	}
+	if err = v.appendOverwriteLog(offset, blob); err != nil {
+		return false, fmt.Errorf("log overwrite of needle %s: %v", n.Id, err)
+	}
+	_, err = v.DataBackend.WriteAt(blob, offset)
+	v.checkReadWriteError(err)
+	if err != nil {
+		return true, err
+	}
+	stats.VolumeServerInPlaceOverwriteCounter.WithLabelValues(v.Collection).Inc()
+	return true, nil
+}
+
+// overwriteLog keeps the overwritten needles until the .dat file is synced
+type overwriteLog struct {
+	file *os.File
+	size int64
+}
+
+func toOverwriteLogRecord(offset int64, blob []byte) []byte {
+	b := make([]byte, overwriteLogRecordHeaderSize+len(blob))
+	util.Uint32toBytes(b[4:8], uint32(len(blob)))
+	util.Uint64toBytes(b[8:16], uint64(offset))
+	copy(b[overwriteLogRecordHeaderSize:], blob)
+	util.Uint32toBytes(b[0:4], crc32.Checksum(b[4:], walCrcTable))
+	return b
+}
+
+// readOverwriteLogRecord returns io.EOF at the end of the log, or at a torn record from a crash while logging
+func readOverwriteLogRecord(r io.Reader) (offset int64, blob []byte, err error) {
+	header := make([]byte, overwriteLogRecordHeaderSize)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return 0, nil, io.EOF
+	}
+	blobSize := util.BytesToUint32(header[4:8])
+	if blobSize < NeedleHeaderSize || blobSize > math.MaxInt32 {
+		return 0, nil, io.EOF
+	}
+	blob = make([]byte, blobSize)
+	if _, err = io.ReadFull(r, blob); err != nil {
+		return 0, nil, io.EOF
+	}
+	crc := crc32.Update(crc32.Checksum(header[4:], walCrcTable), walCrcTable, blob)
+	if crc != util.BytesToUint32(header[0:4]) {
+		return 0, nil, io.EOF
+	}
+	return int64(util.BytesToUint64(header[8:16])), blob, nil
+}
+
+func (v *Volume) appendOverwriteLog(offset int64, blob []byte) error {
+	if v.overwriteLog == nil {
+		file, err := os.OpenFile(v.FileName(".owl"), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		v.overwriteLog = &overwriteLog{file: file}
+	}
+	if v.overwriteLog.size >= overwriteLogCheckpointSize {
+		if err := v.checkpointOverwriteLog(); err != nil {
+			return err
+		}
+	}
+	b := toOverwriteLogRecord(offset, blob)
+	if _, err := v.overwriteLog.file.WriteAt(b, v.overwriteLog.size); err != nil {
+		return err
+	}
+	v.overwriteLog.size += int64(len(b))
+	return fdatasync(v.overwriteLog.file)
+}
+
+// checkpointOverwriteLog syncs the .dat file, and empties the log
+func (v *Volume) checkpointOverwriteLog() error {
+	if v.overwriteLog == nil || v.overwriteLog.size == 0 {
+		return nil
+	}
+	if err := v.DataBackend.Sync(); err != nil {
+		return err
+	}
+	if err := v.overwriteLog.file.Truncate(0); err != nil {
+		return err
+	}
+	v.overwriteLog.size = 0
+	return nil
+}
+
+// closeOverwriteLog removes the log if the .dat file is synced, or keeps it to replay when the volume is loaded
+func (v *Volume) closeOverwriteLog(isDataSynced bool) {
+	if v.overwriteLog == nil {
+		return
+	}
+	fileName := v.overwriteLog.file.Name()
+	v.overwriteLog.file.Close()
+	v.overwriteLog = nil
+	if isDataSynced {
+		if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+			glog.Warningf("remove %s: %v", fileName, err)
+		}
+	}
+}
+
+// replayOverwriteLog writes the logged needles again over the .dat file, and removes the log.
+// A logged needle is skipped if the .dat file has another needle at its offset, e.g. after compaction.
+func (v *Volume) replayOverwriteLog(dataFile *os.File) error {
+	fileName := v.FileName(".owl")
+	f, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	replayedCount := 0
+	reader := bufio.NewReaderSize(f, 1024*1024)
+	header := make([]byte, NeedleHeaderSize)
+	for {
+		offset, blob, readErr := readOverwriteLogRecord(reader)
+		if readErr == io.EOF {
+			break
+		}
+		if _, err = dataFile.ReadAt(header, offset); err != nil || !bytes.Equal(header, blob[:NeedleHeaderSize]) {
+			continue
+		}
+		if _, err = dataFile.WriteAt(blob, offset); err != nil {
+			return fmt.Errorf("replay %s: %v", fileName, err)
+		}
+		replayedCount++
+	}
+	if replayedCount > 0 {
+		if err = dataFile.Sync(); err != nil {
+			return err
+		}
+		glog.V(0).Infof("replayed %d overwritten needles of volume %d from %s", replayedCount, v.Id, fileName)
+	}
+	return os.Remove(fileName)
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+)
+
+func newFixedSizeNeedle(id uint64, fill byte) *needle.Needle {
+	n := newEmptyNeedle(id)
+	n.Cookie = 0x12345678
+	n.Data = bytes.Repeat([]byte{fill}, 100)
+	n.Checksum = needle.NewCRC(n.Data)
+	return n
+}
+
+func TestVolumeOverwriteInPlace(t *testing.T) {
+	dir := t.TempDir()
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	v.overwritesInPlace = true
+
+	if _, _, _, err = v.writeNeedle2(newFixedSizeNeedle(1, 'a'), true, false); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	datSize, _, _ := v.FileStat()
+	offset, _, _, err := v.writeNeedle2(newFixedSizeNeedle(1, 'b'), true, false)
+	if err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+	if overwrittenSize, _, _ := v.FileStat(); overwrittenSize != datSize || offset != super_block.SuperBlockSize {
+		t.Fatalf("overwritten at %d, .dat size %d, expected %d", offset, overwrittenSize, datSize)
+	}
+	if v.nm.DeletedCount() != 0 {
+		t.Fatalf("%d deleted needles", v.nm.DeletedCount())
+	}
+	read := newEmptyNeedle(1)
+	if _, err = v.readNeedle(read, nil, nil); err != nil || read.Data[0] != 'b' {
+		t.Fatalf("read overwritten needle: %v", err)
+	}
+
+	// a needle of another size is appended
+	if _, _, _, err = v.writeNeedle2(newRandomNeedle(1), true, false); err == nil {
+		t.Fatalf("overwritten with another cookie")
+	}
+	larger := newFixedSizeNeedle(1, 'c')
+	larger.Data = append(larger.Data, 'c')
+	larger.Checksum = needle.NewCRC(larger.Data)
+	if offset, _, _, err = v.writeNeedle2(larger, true, false); err != nil || offset != uint64(datSize) {
+		t.Fatalf("appended at %d: %v", offset, err)
+	}
+
+	// crashed after the overwrite is logged, before it is written into the .dat file
+	logged := newFixedSizeNeedle(2, 'd')
+	if offset, _, _, err = v.writeNeedle2(logged, true, false); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	logged = newFixedSizeNeedle(2, 'e')
+	logged.AppendAtNs = 1
+	blob, err := logged.ToBytes(v.Version())
+	if err != nil {
+		t.Fatalf("to bytes: %v", err)
+	}
+	if err = v.appendOverwriteLog(int64(offset), blob); err != nil {
+		t.Fatalf("log overwrite: %v", err)
+	}
+	v.closeOverwriteLog(false)
+	v.Close()
+
+	if v, err = NewVolume(dir, dir, "", 1, NeedleMapInMemory, nil, nil, 0, 0, 0); err != nil {
+		t.Fatalf("volume reload: %v", err)
+	}
+	defer v.Close()
+	read = newEmptyNeedle(2)
+	if _, err = v.readNeedle(read, nil, nil); err != nil || read.Data[0] != 'e' {
+		t.Fatalf("read replayed needle: %v", err)
+	}
+	read = newEmptyNeedle(1)
+	if _, err = v.readNeedle(read, nil, nil); err != nil || len(read.Data) != 101 {
+		t.Fatalf("read appended needle: %v", err)
+	}
+}
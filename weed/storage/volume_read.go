@@ -9,6 +9,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
 	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
 	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
 )
@@ -20,6 +21,10 @@ func (v *Volume) readNeedle(n *needle.Needle, readOption *ReadOption, onReadSize
 	v.dataFileAccessLock.RLock()
 	defer v.dataFileAccessLock.RUnlock()
 
+	v.markRead()
+	if !v.mayHaveNeedle(n.Id) {
+		return -1, ErrorNotFound
+	}
 	nv, ok := v.nm.Get(n.Id)
 	if !ok || nv.Offset.IsZero() {
 		return -1, ErrorNotFound
@@ -62,6 +67,11 @@ func (v *Volume) readNeedle(n *needle.Needle, readOption *ReadOption, onReadSize
 		if err != nil {
 			return 0, err
 		}
+		if v.IsDeduplicated() && !IsDedupBlobId(n.Id) {
+			if err = v.readBlob(n, readOption); err != nil {
+				return 0, err
+			}
+		}
 	}
 	count = int(n.DataSize)
 	if !n.HasTtl() {
@@ -109,7 +119,11 @@ func (v *Volume) readNeedleDataInto(n *needle.Needle, readOption *ReadOption, wr
 	if readOption.HasSlowRead {
 		v.dataFileAccessLock.RLock()
 	}
-	nv, ok := v.nm.Get(n.Id)
+	var nv *needle_map.NeedleValue
+	ok := v.mayHaveNeedle(n.Id)
+	if ok {
+		nv, ok = v.nm.Get(n.Id)
+	}
 	if readOption.HasSlowRead {
 		v.dataFileAccessLock.RUnlock()
 	}
@@ -135,11 +149,20 @@ func (v *Volume) readNeedleDataInto(n *needle.Needle, readOption *ReadOption, wr
 		actualOffset += int64(MaxPossibleVolumeSize)
 	}
 
+	if rf, ok := writer.(io.ReaderFrom); ok && readOption.CanSendfile && backend.IsSendfile(size) && v.canSendfile() {
+		if isSent, sendErr := v.sendNeedleData(n, readOption, rf, actualOffset, offset, size); isSent || sendErr != nil {
+			return sendErr
+		}
+	}
+
 	buf := mem.Allocate(min(readOption.ReadBufferSize, int(size)))
 	defer mem.Free(buf)
 
+	// the bulk reads do not keep the needle data in the page cache
+	isPageCacheStream := backend.IsPageCacheStream(size)
+
 	// read needle data
-	crc := needle.CRC(0)
+	checksummer := n.ChecksumAlgorithm.NewChecksummer()
 	for x := offset; x < offset+size; x += int64(len(buf)) {
 
 		if readOption.HasSlowRead {
@@ -159,13 +182,16 @@ func (v *Volume) readNeedleDataInto(n *needle.Needle, readOption *ReadOption, wr
 			readOption.VolumeRevision = v.SuperBlock.CompactionRevision
 		}
 		count, err := n.ReadNeedleData(v.DataBackend, actualOffset, buf, x)
+		if isPageCacheStream && count > 0 {
+			backend.DropReadPages(v.DataBackend, actualOffset+NeedleHeaderSize+DataSizeSize+x, int64(count))
+		}
 		if readOption.HasSlowRead {
 			v.dataFileAccessLock.RUnlock()
 		}
 
 		toWrite := min(count, int(offset+size-x))
 		if toWrite > 0 {
-			crc = crc.Update(buf[0:toWrite])
+			checksummer.Write(buf[0:toWrite])
 			if _, err = writer.Write(buf[0:toWrite]); err != nil {
 				return fmt.Errorf("ReadNeedleData write: %v", err)
 			}
@@ -181,7 +207,7 @@ func (v *Volume) readNeedleDataInto(n *needle.Needle, readOption *ReadOption, wr
 			break
 		}
 	}
-	if offset == 0 && size == int64(n.DataSize) && (n.Checksum != crc && uint32(n.Checksum) != crc.Value()) {
+	if crc := checksummer.Sum(); offset == 0 && size == int64(n.DataSize) && (n.Checksum != crc && uint32(n.Checksum) != crc.Value()) {
 		// the crc.Value() function is to be deprecated. this double checking is for backward compatible.
 		return fmt.Errorf("ReadNeedleData checksum %v expected %v", crc, n.Checksum)
 	}
@@ -189,6 +215,51 @@ func (v *Volume) readNeedleDataInto(n *needle.Needle, readOption *ReadOption, wr
 
 }
 
+func (v *Volume) canSendfile() bool {
+	return v.Version() != needle.Version1 && v.MemoryMapMaxSizeMb == 0 && !v.HasRemoteFile() && !v.IsEncrypted() && !v.IsDeduplicated()
+}
+
+// sendNeedleData sends the needle data from a file of its own, so the data is not read into the user space.
+// The file keeps the data of the current compaction revision, so it is sent without holding the lock for the slow reads.
+func (v *Volume) sendNeedleData(n *needle.Needle, readOption *ReadOption, writer io.ReaderFrom, actualOffset int64, offset int64, size int64) (isSent bool, err error) {
+	if readOption.HasSlowRead {
+		v.dataFileAccessLock.RLock()
+	}
+	if readOption.VolumeRevision != v.SuperBlock.CompactionRevision {
+		// the volume is compacted
+		nv, ok := v.nm.Get(n.Id)
+		if !ok || nv.Offset.IsZero() {
+			if readOption.HasSlowRead {
+				v.dataFileAccessLock.RUnlock()
+			}
+			return false, ErrorNotFound
+		}
+		actualOffset = nv.Offset.ToActualOffset()
+		readOption.VolumeRevision = v.SuperBlock.CompactionRevision
+	}
+	f, ok, err := backend.OpenSendfile(v.DataBackend)
+	if readOption.HasSlowRead {
+		v.dataFileAccessLock.RUnlock()
+	}
+	if !ok || err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	dataOffset := actualOffset + NeedleHeaderSize + DataSizeSize + offset
+	sent, err := backend.SendFileRange(writer, f, dataOffset, size)
+	if err == nil && sent < size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return true, fmt.Errorf("ReadNeedleData sendfile: %v", err)
+	}
+	if backend.IsPageCacheStream(size) {
+		backend.DropFileReadPages(f, dataOffset, size)
+	}
+	return true, nil
+}
+
 func min(x, y int) int {
 	if x < y {
 		return x
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// readStatus is how recently and how often the volume is read, reported to the master
+// to migrate the cold volumes to slower disks and the hot volumes to faster disks.
+// The volume counts as just read when loaded, so a restart does not make all volumes cold.
+type readStatus struct {
+	readAtSecond int64
+	readCount    uint64
+}
+
+func (v *Volume) markRead() {
+	atomic.AddUint64(&v.readStatus.readCount, 1)
+	if now := time.Now().Unix(); atomic.LoadInt64(&v.readStatus.readAtSecond) != now {
+		atomic.StoreInt64(&v.readStatus.readAtSecond, now)
+	}
+}
+
+func (v *Volume) ReadStatus() (readAtSecond int64, readCount uint64) {
+	return atomic.LoadInt64(&v.readStatus.readAtSecond), atomic.LoadUint64(&v.readStatus.readCount)
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+// isNoWrite is true if the volume is marked read only, not just on a disk with low free space
+func (v *Volume) isNoWrite() bool {
+	v.noWriteLock.RLock()
+	defer v.noWriteLock.RUnlock()
+	return v.noWriteOrDelete || v.noWriteCanDelete
+}
+
+// useSortedNeedleMap looks up the needles of the read only volume in the sorted .sdx file,
+// instead of keeping all the index entries in memory.
+func (v *Volume) useSortedNeedleMap(memoryMapped bool) error {
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+
+	v.memoryMappedIndex, v.compactIndex = memoryMapped, false
+	switch nm := v.nm.(type) {
+	case *NeedleMap, *CompactIndexNeedleMap:
+	case *SortedFileNeedleMap:
+		if nm.IsMemoryMapped() == memoryMapped {
+			return nil
+		}
+	default:
+		// not loaded, or already in leveldb
+		return nil
+	}
+
+	// the .sdx file is generated from the .idx file
+	if err := v.nm.Sync(); err != nil {
+		return fmt.Errorf("sync volume %d index: %v", v.Id, err)
+	}
+	flag := os.O_RDWR
+	if v.noWriteOrDelete {
+		flag = os.O_RDONLY
+	}
+	indexFile, err := os.OpenFile(v.FileName(".idx"), flag, 0644)
+	if err != nil {
+		return fmt.Errorf("open volume index %s: %v", v.FileName(".idx"), err)
+	}
+	sortedNm, err := NewSortedFileNeedleMap(v.IndexFileName(), indexFile, memoryMapped)
+	if err != nil {
+		indexFile.Close()
+		return fmt.Errorf("load sorted index %s: %v", v.FileName(".sdx"), err)
+	}
+	v.nm.Close()
+	v.nm = sortedNm
+	glog.V(0).Infof("volume %d looks up needles in %s, memory mapped: %v", v.Id, v.FileName(".sdx"), memoryMapped)
+	return nil
+}
+
+// useCompactNeedleMap looks up the needles of the read only volume in the compact .cdx file,
+// which keeps the live entries in memory with fewer bytes per entry.
+func (v *Volume) useCompactNeedleMap() error {
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+
+	v.compactIndex = true
+	switch v.nm.(type) {
+	case *NeedleMap, *SortedFileNeedleMap:
+	default:
+		// not loaded, already compact, or in leveldb
+		return nil
+	}
+
+	// the .cdx file is generated from the .idx file
+	if err := v.nm.Sync(); err != nil {
+		return fmt.Errorf("sync volume %d index: %v", v.Id, err)
+	}
+	flag := os.O_RDWR
+	if v.noWriteOrDelete {
+		flag = os.O_RDONLY
+	}
+	indexFile, err := os.OpenFile(v.FileName(".idx"), flag, 0644)
+	if err != nil {
+		return fmt.Errorf("open volume index %s: %v", v.FileName(".idx"), err)
+	}
+	compactNm, err := NewCompactIndexNeedleMap(v.IndexFileName(), indexFile)
+	if err != nil {
+		indexFile.Close()
+		return fmt.Errorf("load compact index %s: %v", v.FileName(".cdx"), err)
+	}
+	v.nm.Close()
+	v.nm = compactNm
+	glog.V(0).Infof("volume %d looks up needles in %s", v.Id, v.FileName(".cdx"))
+	return nil
+}
+
+// useInMemoryNeedleMap loads the index entries back into memory when the volume becomes writable
+func (v *Volume) useInMemoryNeedleMap() error {
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+
+	switch v.nm.(type) {
+	case *SortedFileNeedleMap, *CompactIndexNeedleMap:
+	default:
+		return nil
+	}
+	if v.needleMapKind != NeedleMapInMemory || v.noWriteCanDelete {
+		return nil
+	}
+	indexFile, err := os.OpenFile(v.FileName(".idx"), os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("open volume index %s: %v", v.FileName(".idx"), err)
+	}
+	nm, err := LoadCompactNeedleMap(indexFile)
+	if err != nil {
+		indexFile.Close()
+		return fmt.Errorf("load volume index %s to memory: %v", v.FileName(".idx"), err)
+	}
+	v.nm.Close()
+	v.nm = nm
+	glog.V(0).Infof("volume %d loads index %s to memory", v.Id, v.FileName(".idx"))
+	return nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+)
+
+func TestReadOnlyVolumeSortedIndex(t *testing.T) {
+	for _, memoryMapped := range []bool{false, true} {
+		dir := t.TempDir()
+		v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+		if err != nil {
+			t.Fatalf("volume creation: %v", err)
+		}
+
+		var needles []*needle.Needle
+		for i := 1; i <= 20; i++ {
+			n := newRandomNeedle(uint64(i))
+			if _, _, _, err := v.writeNeedle2(n, true, false); err != nil {
+				t.Fatalf("write needle %d: %v", i, err)
+			}
+			needles = append(needles, n)
+		}
+		if _, err := v.doDeleteRequest(needles[0]); err != nil {
+			t.Fatalf("delete needle: %v", err)
+		}
+
+		v.noWriteOrDelete = true
+		if err := v.useSortedNeedleMap(memoryMapped); err != nil {
+			t.Fatalf("use sorted index: %v", err)
+		}
+		sortedNm, ok := v.nm.(*SortedFileNeedleMap)
+		if !ok {
+			t.Fatalf("unexpected needle map %T", v.nm)
+		}
+		if sortedNm.IsMemoryMapped() != memoryMapped {
+			t.Fatalf("memory mapped %v, expected %v", sortedNm.IsMemoryMapped(), memoryMapped)
+		}
+		for i, n := range needles {
+			nv, found := v.nm.Get(n.Id)
+			if i == 0 {
+				if found && nv.Size.IsValid() {
+					t.Fatalf("deleted needle %d found", n.Id)
+				}
+				continue
+			}
+			if !found || nv.Size != n.Size {
+				t.Fatalf("needle %d found %v size %d, expected %d", n.Id, found, nv.Size, n.Size)
+			}
+		}
+		if _, found := v.nm.Get(needles[len(needles)-1].Id + 1); found {
+			t.Fatalf("missing needle found")
+		}
+
+		v.noWriteOrDelete = false
+		if err := v.useInMemoryNeedleMap(); err != nil {
+			t.Fatalf("use in memory index: %v", err)
+		}
+		if _, ok := v.nm.(*NeedleMap); !ok {
+			t.Fatalf("unexpected needle map %T", v.nm)
+		}
+		n := newRandomNeedle(21)
+		if _, _, _, err := v.writeNeedle2(n, true, false); err != nil {
+			t.Fatalf("write needle after writable: %v", err)
+		}
+		if _, found := v.nm.Get(n.Id); !found {
+			t.Fatalf("needle %d written after writable not found", n.Id)
+		}
+		v.Close()
+	}
+}
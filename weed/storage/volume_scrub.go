@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+// scrubStatus is what the background scrubbing found in the volume, reported to the master
+type scrubStatus struct {
+	corruptedCount   uint64
+	repairedCount    uint64
+	scrubbedAtSecond int64
+}
+
+func (v *Volume) scrubbed(corrupted, repaired uint64) {
+	atomic.AddUint64(&v.scrubStatus.corruptedCount, corrupted)
+	atomic.AddUint64(&v.scrubStatus.repairedCount, repaired)
+	atomic.StoreInt64(&v.scrubStatus.scrubbedAtSecond, time.Now().Unix())
+}
+
+func (v *Volume) ScrubStatus() (corruptedCount, repairedCount uint64, scrubbedAtSecond int64) {
+	return atomic.LoadUint64(&v.scrubStatus.corruptedCount),
+		atomic.LoadUint64(&v.scrubStatus.repairedCount),
+		atomic.LoadInt64(&v.scrubStatus.scrubbedAtSecond)
+}
+
+// ReadVerifiedNeedleBlob reads the current copy of the needle, and verifies its checksum.
+func (v *Volume) ReadVerifiedNeedleBlob(needleId NeedleId) (needleBlob []byte, size Size, err error) {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+
+	nv, ok := v.nm.Get(needleId)
+	if !ok || nv.Offset.IsZero() || nv.Size.IsDeleted() {
+		return nil, 0, ErrorNotFound
+	}
+	if needleBlob, err = v.readVerifiedNeedleBlob(needleId, nv.Offset.ToActualOffset(), nv.Size); err != nil {
+		return nil, nv.Size, err
+	}
+	return needleBlob, nv.Size, nil
+}
+
+func (v *Volume) readVerifiedNeedleBlob(needleId NeedleId, offset int64, size Size) (needleBlob []byte, err error) {
+	n := new(needle.Needle)
+	needleBlob, err = needle.ReadNeedleBlob(v.DataBackend, offset, size, v.Version())
+	if err == nil {
+		err = n.ReadBytes(needleBlob, offset, size, v.Version())
+	}
+	if err == needle.ErrorSizeMismatch && OffsetSize == 4 {
+		offset += int64(MaxPossibleVolumeSize)
+		if needleBlob, err = needle.ReadNeedleBlob(v.DataBackend, offset, size, v.Version()); err == nil {
+			err = n.ReadBytes(needleBlob, offset, size, v.Version())
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n.Id != needleId {
+		return nil, fmt.Errorf("found needle %s instead of %s at offset %d", n.Id, needleId, offset)
+	}
+	return needleBlob, nil
+}
+
+// scrubIndexEntry verifies the needle of the n-th index entry, if it is still the current copy.
+// It returns the bytes read, 0 if the entry is skipped, and the corruption found.
+func (v *Volume) scrubIndexEntry(n int64) (key NeedleId, readBytes int64, corruption error) {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+
+	if v.nm == nil || v.DataBackend == nil {
+		// closed
+		return 0, 0, nil
+	}
+	key, offset, size, err := v.nm.ReadIndexEntry(n)
+	if err != nil || offset.IsZero() || size.IsDeleted() {
+		return key, 0, nil
+	}
+	if nv, ok := v.nm.Get(key); !ok || nv.Offset != offset || nv.Size != size {
+		// overwritten or deleted later
+		return key, 0, nil
+	}
+	_, corruption = v.readVerifiedNeedleBlob(key, offset.ToActualOffset(), size)
+	return key, needle.GetActualSize(size, v.Version()), corruption
+}
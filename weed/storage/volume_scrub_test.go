@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func TestScrubAndRepairCorruptedNeedle(t *testing.T) {
+	dir, replicaDir := t.TempDir(), t.TempDir()
+
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+	replica, err := NewVolume(replicaDir, replicaDir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("replica creation: %v", err)
+	}
+	defer replica.Close()
+
+	for i := 1; i <= 5; i++ {
+		n := newRandomNeedle(uint64(i))
+		for _, vol := range []*Volume{v, replica} {
+			if _, _, _, err := vol.writeNeedle2(n, true, false); err != nil {
+				t.Fatalf("write needle %d: %v", i, err)
+			}
+		}
+	}
+
+	// flip one byte of the data of needle 3
+	nv, _ := v.nm.Get(3)
+	dataOffset := nv.Offset.ToActualOffset() + types.NeedleHeaderSize + 4
+	b := make([]byte, 1)
+	v.DataBackend.ReadAt(b, dataOffset)
+	b[0] ^= 0xff
+	v.DataBackend.WriteAt(b, dataOffset)
+
+	scrub := func() (corrupted []types.NeedleId) {
+		for i := int64(0); i < int64(v.IndexFileSize()/types.NeedleMapEntrySize); i++ {
+			key, readBytes, corruption := v.scrubIndexEntry(i)
+			if readBytes > 0 && corruption != nil {
+				corrupted = append(corrupted, key)
+			}
+		}
+		return
+	}
+	if corrupted := scrub(); len(corrupted) != 1 || corrupted[0] != 3 {
+		t.Fatalf("corrupted needles %v, expected [3]", corrupted)
+	}
+	if _, _, err := v.ReadVerifiedNeedleBlob(3); err == nil {
+		t.Fatalf("read corrupted needle without error")
+	}
+
+	blob, size, err := replica.ReadVerifiedNeedleBlob(3)
+	if err != nil {
+		t.Fatalf("read needle from replica: %v", err)
+	}
+	if err = v.WriteNeedleBlob(3, blob, size); err != nil {
+		t.Fatalf("repair needle: %v", err)
+	}
+	if corrupted := scrub(); len(corrupted) != 0 {
+		t.Fatalf("corrupted needles %v after repair", corrupted)
+	}
+	if _, _, err := v.ReadVerifiedNeedleBlob(3); err != nil {
+		t.Fatalf("read repaired needle: %v", err)
+	}
+}
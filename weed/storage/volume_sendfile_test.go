@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+)
+
+func TestVolumeSendNeedleData(t *testing.T) {
+	dir := t.TempDir()
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+	for i := 1; i <= 3; i++ {
+		if _, _, _, err = v.writeNeedle2(newRandomNeedle(uint64(i)), true, false); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	n := newEmptyNeedle(2)
+	if _, err = v.readNeedle(n, nil, nil); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	data := n.Data
+
+	backend.SetSendfileSize(1)
+	defer backend.SetSendfileSize(0)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	received := make(chan []byte)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		b, _ := io.ReadAll(conn)
+		received <- b
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	for _, hasSlowRead := range []bool{false, true} {
+		meta := newEmptyNeedle(2)
+		readOption := &ReadOption{ReadBufferSize: 64, HasSlowRead: hasSlowRead, CanSendfile: true}
+		if _, err = v.readNeedle(meta, readOption, nil); err != nil {
+			t.Fatalf("read meta: %v", err)
+		}
+		if err = v.readNeedleDataInto(meta, readOption, conn, 1, int64(len(data)-2)); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+	conn.Close()
+	expected := append(append([]byte{}, data[1:len(data)-1]...), data[1:len(data)-1]...)
+	if b := <-received; !bytes.Equal(b, expected) {
+		t.Errorf("sent %d bytes, expected %d bytes", len(b), len(expected))
+	}
+}
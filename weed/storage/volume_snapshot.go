@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// A volume snapshot is kept in the snapshots/<snapshot name> folder of the volume data folder.
+// The .dat file is cloned copy-on-write if the file system supports reflinks, or else hard linked,
+// since the volume only appends to the .dat file, and the vacuum replaces the file instead of changing it.
+// The .idx file is copied, and the .snapshot file keeps the .dat and .idx file sizes when the snapshot was taken.
+// A clone is a new volume with the files of a snapshot, cut to the snapshot sizes.
+
+var errReflinkNotSupported = errors.New("reflink is not supported")
+
+type VolumeSnapshot struct {
+	DatFileSize int64 `json:"datFileSize"`
+	IdxFileSize int64 `json:"idxFileSize"`
+	IsReflink   bool  `json:"isReflink"` // the .dat file is a copy-on-write clone, or else a hard link
+	CreatedAtNs int64 `json:"createdAtNs"`
+}
+
+func volumeSnapshotBaseFileName(dir, snapshotName, collection string, vid needle.VolumeId) (string, error) {
+	if snapshotName == "" || snapshotName == "." || snapshotName == ".." || strings.ContainsAny(snapshotName, `/\`) {
+		return "", fmt.Errorf("invalid snapshot name %q", snapshotName)
+	}
+	return VolumeFileName(path.Join(dir, "snapshots", snapshotName), collection, int(vid)), nil
+}
+
+// Snapshot flushes the volume, and keeps its files as they are now in the named snapshot
+func (v *Volume) Snapshot(snapshotName string) (*VolumeSnapshot, error) {
+	if v.HasRemoteFile() {
+		return nil, fmt.Errorf("volume %d is tiered to remote storage", v.Id)
+	}
+	if v.IsObjectStored() {
+		return nil, fmt.Errorf("volume %d is on the object store", v.Id)
+	}
+	baseFileName, err := volumeSnapshotBaseFileName(v.dir, snapshotName, v.Collection, v.Id)
+	if err != nil {
+		return nil, err
+	}
+	if util.FileExists(baseFileName + ".snapshot") {
+		return nil, fmt.Errorf("volume %d snapshot %s already exists", v.Id, snapshotName)
+	}
+	if err = os.MkdirAll(path.Dir(baseFileName), 0755); err != nil {
+		return nil, err
+	}
+
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+
+	if err = v.nm.Sync(); err != nil {
+		return nil, fmt.Errorf("sync volume %d index: %v", v.Id, err)
+	}
+	if err = v.DataBackend.Sync(); err != nil {
+		return nil, fmt.Errorf("sync volume %d: %v", v.Id, err)
+	}
+	datFileSize, _, err := v.DataBackend.GetStat()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &VolumeSnapshot{
+		DatFileSize: datFileSize,
+		IdxFileSize: int64(v.nm.IndexFileSize()),
+		CreatedAtNs: time.Now().UnixNano(),
+	}
+
+	removeVolumeSnapshotFiles(baseFileName)
+	snapshot.IsReflink = true
+	if err = reflinkFile(v.FileName(".dat"), baseFileName+".dat"); err == errReflinkNotSupported {
+		snapshot.IsReflink = false
+		err = os.Link(v.FileName(".dat"), baseFileName+".dat")
+	}
+	if err == nil {
+		err = copyFilePrefix(v.FileName(".idx"), baseFileName+".idx", snapshot.IdxFileSize)
+	}
+	if err == nil && util.FileExists(v.FileName(".vif")) {
+		err = copyFilePrefix(v.FileName(".vif"), baseFileName+".vif", -1)
+	}
+	if err == nil {
+		err = saveVolumeSnapshot(baseFileName+".snapshot", snapshot)
+	}
+	if err != nil {
+		removeVolumeSnapshotFiles(baseFileName)
+		return nil, fmt.Errorf("snapshot volume %d: %v", v.Id, err)
+	}
+	glog.V(0).Infof("snapshot volume %d as %s, reflink %v", v.Id, snapshotName, snapshot.IsReflink)
+	return snapshot, nil
+}
+
+func saveVolumeSnapshot(fileName string, snapshot *VolumeSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return util.WriteFile(fileName, data, 0644)
+}
+
+func loadVolumeSnapshot(fileName string) (*VolumeSnapshot, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &VolumeSnapshot{}
+	if err = json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", fileName, err)
+	}
+	return snapshot, nil
+}
+
+func removeVolumeSnapshotFiles(baseFileName string) {
+	for _, ext := range []string{".dat", ".idx", ".vif", ".snapshot"} {
+		os.Remove(baseFileName + ext)
+	}
+}
+
+// copyFilePrefix copies the first size bytes of the file, or the whole file if size is negative
+func copyFilePrefix(src, dst string, size int64) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	var reader io.Reader = in
+	if size >= 0 {
+		reader = io.LimitReader(in, size)
+	}
+	if _, err = io.Copy(out, reader); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// cloneDatFile clones the snapshot .dat file copy-on-write if possible, or else copies it, cut to the snapshot size
+func cloneDatFile(src, dst string, size int64) error {
+	err := reflinkFile(src, dst)
+	if err == errReflinkNotSupported {
+		return copyFilePrefix(src, dst, size)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Truncate(dst, size)
+}
+
+// SnapshotVolume keeps the current files of the volume in the named snapshot
+func (s *Store) SnapshotVolume(vid needle.VolumeId, snapshotName string) (*VolumeSnapshot, error) {
+	v := s.findVolume(vid)
+	if v == nil {
+		return nil, fmt.Errorf("volume %d not found", vid)
+	}
+	return v.Snapshot(snapshotName)
+}
+
+// DeleteVolumeSnapshot removes the named snapshot of the volume
+func (s *Store) DeleteVolumeSnapshot(vid needle.VolumeId, snapshotName string) error {
+	v := s.findVolume(vid)
+	if v == nil {
+		return fmt.Errorf("volume %d not found", vid)
+	}
+	baseFileName, err := volumeSnapshotBaseFileName(v.dir, snapshotName, v.Collection, v.Id)
+	if err != nil {
+		return err
+	}
+	if !util.FileExists(baseFileName + ".snapshot") {
+		return fmt.Errorf("volume %d snapshot %s not found", vid, snapshotName)
+	}
+	removeVolumeSnapshotFiles(baseFileName)
+	// only removed once no other volumes have the snapshot
+	os.Remove(path.Dir(baseFileName))
+	return nil
+}
+
+// CloneVolume creates the new volume with the files of the named snapshot of the volume,
+// or of a temporary snapshot if the name is empty, and mounts it.
+func (s *Store) CloneVolume(vid needle.VolumeId, snapshotName string, newVid needle.VolumeId) error {
+	v, location := s.findVolumeAndLocation(vid)
+	if v == nil {
+		return fmt.Errorf("volume %d not found", vid)
+	}
+	if s.findVolume(newVid) != nil {
+		return fmt.Errorf("volume %d already exists", newVid)
+	}
+	newDataBaseFileName := VolumeFileName(location.Directory, v.Collection, int(newVid))
+	newIndexBaseFileName := VolumeFileName(location.IdxDirectory, v.Collection, int(newVid))
+	if util.FileExists(newDataBaseFileName+".dat") || util.FileExists(newIndexBaseFileName+".idx") {
+		return fmt.Errorf("volume %d files already exist", newVid)
+	}
+
+	if snapshotName == "" {
+		snapshotName = fmt.Sprintf("clone-%d-%d", newVid, time.Now().UnixNano())
+		if _, err := v.Snapshot(snapshotName); err != nil {
+			return err
+		}
+		defer s.DeleteVolumeSnapshot(vid, snapshotName)
+	}
+	baseFileName, err := volumeSnapshotBaseFileName(v.dir, snapshotName, v.Collection, v.Id)
+	if err != nil {
+		return err
+	}
+	snapshot, err := loadVolumeSnapshot(baseFileName + ".snapshot")
+	if err != nil {
+		return fmt.Errorf("volume %d snapshot %s: %v", vid, snapshotName, err)
+	}
+
+	err = cloneDatFile(baseFileName+".dat", newDataBaseFileName+".dat", snapshot.DatFileSize)
+	if err == nil {
+		err = copyFilePrefix(baseFileName+".idx", newIndexBaseFileName+".idx", snapshot.IdxFileSize)
+	}
+	if err == nil && util.FileExists(baseFileName+".vif") {
+		err = copyFilePrefix(baseFileName+".vif", newDataBaseFileName+".vif", -1)
+	}
+	if err == nil {
+		err = s.MountVolume(newVid)
+	}
+	if err != nil {
+		removeVolumeFiles(newDataBaseFileName)
+		removeVolumeFiles(newIndexBaseFileName)
+		return fmt.Errorf("clone volume %d snapshot %s to volume %d: %v", vid, snapshotName, newVid, err)
+	}
+	glog.V(0).Infof("cloned volume %d snapshot %s to volume %d", vid, snapshotName, newVid)
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package storage
+
+func reflinkFile(src, dst string) error {
+	return errReflinkNotSupported
+}
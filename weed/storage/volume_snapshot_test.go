@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestVolumeSnapshotAndClone(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(nil, "localhost", 8080, 18080, "localhost:8080", []string{dir}, []int32{10},
+		[]util.MinFreeSpace{{}}, "", NeedleMapInMemory, []types.DiskType{types.HardDriveType}, 0)
+	defer s.Close()
+	if err := s.AddVolume(1, "c", NeedleMapInMemory, "000", "", 0, 0, types.HardDriveType, 0); err != nil {
+		t.Fatalf("add volume: %v", err)
+	}
+	<-s.NewVolumesChan
+
+	before, after := newRandomNeedle(1), newRandomNeedle(2)
+	if _, err := s.WriteVolumeNeedle(1, before, true, false); err != nil {
+		t.Fatalf("write needle: %v", err)
+	}
+	snapshot, err := s.SnapshotVolume(1, "s1")
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if _, err = s.SnapshotVolume(1, "s1"); err == nil {
+		t.Errorf("snapshot taken twice with the same name")
+	}
+	if _, err = s.SnapshotVolume(1, "../s2"); err == nil {
+		t.Errorf("snapshot taken with an invalid name")
+	}
+
+	// the changes after the snapshot are not in the clone
+	if _, err = s.WriteVolumeNeedle(1, after, true, false); err != nil {
+		t.Fatalf("write needle: %v", err)
+	}
+	if _, err = s.DeleteVolumeNeedle(1, newEmptyNeedle(1)); err != nil {
+		t.Fatalf("delete needle: %v", err)
+	}
+	if err = s.CloneVolume(1, "s1", 2); err != nil {
+		t.Fatalf("clone: %v", err)
+	}
+	<-s.NewVolumesChan
+	clone := s.findVolume(2)
+	if clone == nil || clone.Collection != "c" {
+		t.Fatalf("cloned volume %+v", clone)
+	}
+	if datSize, _, _ := clone.FileStat(); int64(datSize) != snapshot.DatFileSize {
+		t.Errorf("cloned .dat file size %d, expected %d", datSize, snapshot.DatFileSize)
+	}
+	n := newEmptyNeedle(1)
+	if _, err = clone.readNeedle(n, nil, nil); err != nil || !bytes.Equal(n.Data, before.Data) {
+		t.Errorf("read needle 1 from the clone: %v", err)
+	}
+	if _, err = clone.readNeedle(newEmptyNeedle(2), nil, nil); err != ErrorNotFound {
+		t.Errorf("read needle 2 written after the snapshot: %v", err)
+	}
+
+	// the clone is writable, without changing the volume
+	if _, err = s.WriteVolumeNeedle(2, newRandomNeedle(3), true, false); err != nil {
+		t.Fatalf("write to the clone: %v", err)
+	}
+	if _, err = s.findVolume(1).readNeedle(newEmptyNeedle(3), nil, nil); err != ErrorNotFound {
+		t.Errorf("read needle 3 written to the clone: %v", err)
+	}
+
+	// clone from a temporary snapshot
+	if err = s.CloneVolume(1, "", 3); err != nil {
+		t.Fatalf("clone the current volume: %v", err)
+	}
+	<-s.NewVolumesChan
+	if _, err = s.findVolume(3).readNeedle(newEmptyNeedle(2), nil, nil); err != nil {
+		t.Errorf("read needle 2 from the current clone: %v", err)
+	}
+	if err = s.CloneVolume(1, "", 3); err == nil {
+		t.Errorf("cloned to an existing volume")
+	}
+
+	if err = s.DeleteVolumeSnapshot(1, "s1"); err != nil {
+		t.Fatalf("delete snapshot: %v", err)
+	}
+	if err = s.CloneVolume(1, "s1", 4); err == nil {
+		t.Errorf("cloned the deleted snapshot")
+	}
+}
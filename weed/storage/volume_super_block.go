@@ -24,7 +24,9 @@ func (v *Volume) maybeWriteSuperBlock() error {
 			//read-only, but zero length - recreate it!
 			var dataFile *os.File
 			if dataFile, e = os.Create(v.DataBackend.Name()); e == nil {
-				v.DataBackend = backend.NewDiskFile(dataFile)
+				if v.DataBackend, e = v.encryptDataFile(backend.NewDiskFile(dataFile), v.SuperBlock.CompactionRevision); e != nil {
+					return e
+				}
 				if _, e = v.DataBackend.WriteAt(v.SuperBlock.Bytes(), 0); e == nil {
 					v.noWriteLock.Lock()
 					v.noWriteOrDelete = false
@@ -65,10 +65,33 @@ func (v *Volume) LoadRemoteFile() error {
 		v.DataBackend.Close()
 	}
 
-	v.DataBackend = backendStorage.NewStorageFile(tierFile.Key, v.volumeInfo)
+	file := backendStorage.NewStorageFile(tierFile.Key, v.volumeInfo)
+	v.archivedFile = nil
+	if archival, ok := backendStorage.(backend.ArchivalBackendStorage); ok && tierFile.IsArchived {
+		v.archivedFile = backend.NewArchivedFile(file, archival, tierFile.Key)
+		file = v.archivedFile
+	}
+	v.DataBackend = backend.MaybeCacheRemoteFile(file, tierFile.BackendName()+"/"+tierFile.Key)
 	return nil
 }
 
+// IsRemoteArchived tells whether the remote file is archived offline and not restored yet,
+// and queues a restore job if not queued yet.
+func (v *Volume) IsRemoteArchived() bool {
+	return v.archivedFile != nil && !v.archivedFile.RequestRestore()
+}
+
+func (v *Volume) RemoteRestoreStatus() (state string, expiresAtSecond int64) {
+	if v.archivedFile == nil {
+		return "", 0
+	}
+	status := v.archivedFile.RestoreStatus()
+	if !status.ExpiresAt.IsZero() {
+		expiresAtSecond = status.ExpiresAt.Unix()
+	}
+	return status.State, expiresAtSecond
+}
+
 func (v *Volume) SaveVolumeInfo() error {
 
 	tierFileName := v.FileName(".vif")
@@ -103,6 +103,10 @@ func (v *Volume) CommitCompact() error {
 	if v.MemoryMapMaxSizeMb != 0 { //it makes no sense to compact in memory
 		return nil
 	}
+	if v.isCompactedInPlace {
+		v.isCompactedInPlace = false
+		return nil
+	}
 	glog.V(0).Infof("Committing volume %d vacuuming...", v.Id)
 
 	v.isCommitCompacting = true
@@ -123,6 +127,8 @@ func (v *Volume) CommitCompact() error {
 			glog.V(0).Infof("failed to close volume %d", v.Id)
 		}
 	}
+	// the logged overwrites are already copied into the compacted data file
+	v.closeOverwriteLog(true)
 	v.DataBackend = nil
 	stats.VolumeServerVolumeCounter.WithLabelValues(v.Collection, "volume").Dec()
 
@@ -138,6 +144,10 @@ func (v *Volume) CommitCompact() error {
 			return e
 		}
 	} else {
+		oldObjectStore, err := v.renewObjectStore()
+		if err != nil {
+			return err
+		}
 		if runtime.GOOS == "windows" {
 			e = os.RemoveAll(v.FileName(".dat"))
 			if e != nil {
@@ -155,12 +165,18 @@ func (v *Volume) CommitCompact() error {
 		if e = os.Rename(v.FileName(".cpx"), v.FileName(".idx")); e != nil {
 			return fmt.Errorf("rename %s: %v", v.FileName(".cpx"), e)
 		}
+		if oldObjectStore != nil {
+			if e = v.commitRenewedObjectStore(oldObjectStore); e != nil {
+				return e
+			}
+		}
 	}
 
 	//glog.V(3).Infof("Pretending to be vacuuming...")
 	//time.Sleep(20 * time.Second)
 
 	os.RemoveAll(v.FileName(".ldb"))
+	os.RemoveAll(v.FileName(".pdb"))
 
 	glog.V(3).Infof("Loading volume %d commit file...", v.Id)
 	if e = v.load(true, false, v.needleMapKind, 0); e != nil {
@@ -176,6 +192,7 @@ func (v *Volume) cleanupCompact() error {
 	e1 := os.Remove(v.FileName(".cpd"))
 	e2 := os.Remove(v.FileName(".cpx"))
 	e3 := os.RemoveAll(v.FileName(".cpldb"))
+	os.Remove(v.FileName(".cpv"))
 	if e1 != nil && !os.IsNotExist(e1) {
 		return e1
 	}
@@ -210,7 +227,11 @@ func (v *Volume) makeupDiff(newDatFileName, newIdxFileName, oldDatFileName, oldI
 	if err != nil {
 		return fmt.Errorf("makeupDiff open %s failed: %v", oldDatFileName, err)
 	}
-	oldDatBackend := backend.NewDiskFile(oldDatFile)
+	oldDatBackend, err := v.encryptDataFile(v.objectStoreView(backend.NewDiskFile(oldDatFile)), v.lastCompactRevision)
+	if err != nil {
+		oldDatFile.Close()
+		return err
+	}
 	defer oldDatBackend.Close()
 
 	// skip if the old .idx file has not changed
@@ -263,7 +284,11 @@ func (v *Volume) makeupDiff(newDatFileName, newIdxFileName, oldDatFileName, oldI
 	if dst, err = os.OpenFile(newDatFileName, os.O_RDWR, 0644); err != nil {
 		return fmt.Errorf("open dat file %s failed: %v", newDatFileName, err)
 	}
-	dstDatBackend := backend.NewDiskFile(dst)
+	dstDatBackend, err := v.encryptDataFile(backend.NewDiskFile(dst), v.lastCompactRevision+1)
+	if err != nil {
+		dst.Close()
+		return err
+	}
 	defer dstDatBackend.Close()
 
 	if idx, err = os.OpenFile(newIdxFileName, os.O_RDWR, 0644); err != nil {
@@ -316,9 +341,11 @@ func (v *Volume) makeupDiff(newDatFileName, newIdxFileName, oldDatFileName, oldI
 			if err != nil {
 				return fmt.Errorf("ReadNeedleBlob %s key %d offset %d size %d failed: %v", oldDatFile.Name(), key, increIdxEntry.offset.ToActualOffset(), increIdxEntry.size, err)
 			}
-			dstDatBackend.Write(needleBytes)
+			if _, err = dstDatBackend.WriteAt(needleBytes, offset); err != nil {
+				return fmt.Errorf("cannot write needle %s: %v", dst.Name(), err)
+			}
 			if err := dstDatBackend.Sync(); err != nil {
-				return fmt.Errorf("cannot sync needle %s: %v", dstDatBackend.File.Name(), err)
+				return fmt.Errorf("cannot sync needle %s: %v", dst.Name(), err)
 			}
 			util.Uint32toBytes(idxEntryBytes[8:12], uint32(offset/NeedlePaddingSize))
 		} else { //deleted needle
@@ -396,6 +423,9 @@ func (v *Volume) copyDataAndGenerateIndexFile(dstName, idxName string, prealloca
 		return err
 	}
 	defer dst.Close()
+	if dst, err = v.encryptDataFile(dst, v.SuperBlock.CompactionRevision+1); err != nil {
+		return err
+	}
 
 	nm := needle_map.NewMemDb()
 	defer nm.Close()
@@ -424,6 +454,9 @@ func (v *Volume) copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, da
 		return err
 	}
 	defer dstDatBackend.Close()
+	if dstDatBackend, err = v.encryptDataFile(dstDatBackend, sb.CompactionRevision+1); err != nil {
+		return err
+	}
 
 	oldNm := needle_map.NewMemDb()
 	defer oldNm.Close()
@@ -435,8 +468,11 @@ func (v *Volume) copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, da
 	if dataFile, err = os.Open(srcDatName); err != nil {
 		return err
 	}
-	srcDatBackend = backend.NewDiskFile(dataFile)
+	srcDatBackend = v.objectStoreView(backend.NewDiskFile(dataFile))
 	defer srcDatBackend.Close()
+	if srcDatBackend, err = v.encryptDataFile(srcDatBackend, sb.CompactionRevision); err != nil {
+		return err
+	}
 
 	now := uint64(time.Now().Unix())
 
@@ -503,26 +539,7 @@ func (v *Volume) copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, da
 		v.tmpNm.Close()
 		v.tmpNm = nil
 	}
-	if v.needleMapKind == NeedleMapInMemory {
-
-		nm := &NeedleMap{
-			m: needle_map.NewCompactMap(),
-		}
-		v.tmpNm = nm
-		//can be optimized, filling nm in oldNm.AscendingVisit
-		err = v.tmpNm.DoOffsetLoading(nil, indexFile, 0)
-		return err
-	} else {
-		dbFileName := v.FileName(".ldb")
-		m := &LevelDbNeedleMap{dbFileName: dbFileName}
-		m.dbFileName = dbFileName
-		mm := &mapMetric{}
-		m.mapMetric = *mm
-		v.tmpNm = m
-		err = v.tmpNm.DoOffsetLoading(v, indexFile, 0)
-		if err != nil {
-			return err
-		}
-	}
-	return
+	//can be optimized, filling the in memory needle map in oldNm.AscendingVisit
+	v.tmpNm = v.newTempNeedleMapper(v.needleMapKind)
+	return v.tmpNm.DoOffsetLoading(v, indexFile, 0)
 }
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	idx2 "github.com/seaweedfs/seaweedfs/weed/storage/idx"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+const (
+	// punchHoleBlockSize aligns the holes to the file system blocks
+	punchHoleBlockSize = 4096
+	// the needle header with the data size, and the checksum with the append timestamp, are kept in the holes,
+	// so the volume can still be scanned, and binary searched by the append time when tailing
+	inPlaceKeepHeadSize = NeedleHeaderSize + 4
+	inPlaceKeepTailSize = needle.NeedleChecksumSize + TimestampSize + NeedlePaddingSize
+)
+
+var errPunchHoleNotSupported = errors.New("punching holes is not supported")
+
+// CompactInPlace reclaims the space of the deleted and overwritten needles by punching holes into the .dat file,
+// without copying the live needles to a new volume file. The offsets and the .idx file stay the same,
+// so the volume is not locked, and the logical size of the .dat file is not changed.
+func (v *Volume) CompactInPlace(progressFn ProgressFunc) (reclaimed int64, err error) {
+	if v.MemoryMapMaxSizeMb != 0 || v.HasRemoteFile() {
+		return 0, fmt.Errorf("volume %d is not on local disk", v.Id)
+	}
+	if v.isCompacting {
+		return 0, fmt.Errorf("volume %d is compacting", v.Id)
+	}
+	v.isCompacting = true
+	defer func() {
+		v.isCompacting = false
+	}()
+
+	if err = v.nm.Sync(); err != nil {
+		glog.V(0).Infof("compact in place failed to sync volume idx %d: %v", v.Id, err)
+	}
+	indexFile, err := os.Open(v.FileName(".idx"))
+	if err != nil {
+		return 0, err
+	}
+	defer indexFile.Close()
+	stat, err := indexFile.Stat()
+	if err != nil {
+		return 0, err
+	}
+	// the later entries are not walked, since their older entries are not visited
+	indexSection := io.NewSectionReader(indexFile, 0, stat.Size()/NeedleMapEntrySize*NeedleMapEntrySize)
+
+	latestOffsets := make(map[NeedleId]Offset)
+	if err = idx2.WalkIndexFile(indexSection, 0, func(key NeedleId, offset Offset, size Size) error {
+		latestOffsets[key] = offset
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("walk %s: %v", indexFile.Name(), err)
+	}
+
+	dataFile, err := os.OpenFile(v.FileName(".dat"), os.O_RDWR, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer dataFile.Close()
+	// the holes would also be punched into the snapshots sharing the file
+	if links, linkErr := hardLinkCount(dataFile); linkErr == nil && links > 1 {
+		return 0, fmt.Errorf("volume %d .dat file is hard linked by %d snapshots", v.Id, links-1)
+	}
+	allocatedBefore, err := allocatedFileSize(dataFile)
+	if err != nil {
+		return 0, err
+	}
+
+	version := v.Version()
+	err = idx2.WalkIndexFile(indexSection, 0, func(key NeedleId, offset Offset, size Size) error {
+		if offset.IsZero() || !size.IsValid() || size == 0 || latestOffsets[key] == offset {
+			return nil
+		}
+		start := offset.ToActualOffset()
+		if progressFn != nil && !progressFn(start) {
+			return fmt.Errorf("interrupted")
+		}
+		end := start + needle.GetActualSize(size, version)
+		holeStart := (start + inPlaceKeepHeadSize + punchHoleBlockSize - 1) / punchHoleBlockSize * punchHoleBlockSize
+		holeEnd := (end - inPlaceKeepTailSize) / punchHoleBlockSize * punchHoleBlockSize
+		if holeStart >= holeEnd {
+			return nil
+		}
+		return punchHole(dataFile, holeStart, holeEnd-holeStart)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	allocatedAfter, err := allocatedFileSize(dataFile)
+	if err != nil {
+		return 0, err
+	}
+	v.isCompactedInPlace = true
+	reclaimed = allocatedBefore - allocatedAfter
+	glog.V(0).Infof("volume %d reclaimed %d bytes in place", v.Id, reclaimed)
+	return reclaimed, nil
+}
+
+// inPlaceGarbageLevel is the ratio of the allocated space in the .dat file which is not used by the live needles
+func (v *Volume) inPlaceGarbageLevel() float64 {
+	if v.DataBackend == nil || v.HasRemoteFile() {
+		return v.garbageLevel()
+	}
+	dataFile, err := os.Open(v.FileName(".dat"))
+	if err != nil {
+		return v.garbageLevel()
+	}
+	defer dataFile.Close()
+	allocated, err := allocatedFileSize(dataFile)
+	if err != nil || allocated == 0 {
+		return v.garbageLevel()
+	}
+	liveCount := int64(v.FileCount() - v.DeletedCount())
+	liveSize := int64(v.ContentSize()-v.DeletedSize()) + liveCount*(NeedleHeaderSize+inPlaceKeepTailSize)
+	if liveSize >= allocated {
+		return 0
+	}
+	return float64(allocated-liveSize) / float64(allocated)
+}
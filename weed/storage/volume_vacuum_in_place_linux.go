@@ -0,0 +1,35 @@
+//go:build linux
+// +build linux
+
+package storage
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func punchHole(f *os.File, offset, length int64) error {
+	err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, offset, length)
+	if err == unix.EOPNOTSUPP {
+		return errPunchHoleNotSupported
+	}
+	return err
+}
+
+func hardLinkCount(f *os.File) (uint64, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(stat.Sys().(*syscall.Stat_t).Nlink), nil
+}
+
+func allocatedFileSize(f *os.File) (int64, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return stat.Sys().(*syscall.Stat_t).Blocks * 512, nil
+}
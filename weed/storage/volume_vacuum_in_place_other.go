@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+package storage
+
+import (
+	"os"
+)
+
+func punchHole(f *os.File, offset, length int64) error {
+	return errPunchHoleNotSupported
+}
+
+func hardLinkCount(f *os.File) (uint64, error) {
+	return 1, nil
+}
+
+func allocatedFileSize(f *os.File) (int64, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return stat.Size(), nil
+}
@@ -69,6 +69,10 @@ func TestLDBIndexCompaction(t *testing.T) {
 	testCompaction(t, NeedleMapLevelDb)
 }
 
+func TestPebbleIndexCompaction(t *testing.T) {
+	testCompaction(t, NeedleMapPebble)
+}
+
 func testCompaction(t *testing.T, needleMapKind NeedleMapKind) {
 	dir := t.TempDir()
 
@@ -106,6 +110,14 @@ func testCompaction(t *testing.T, needleMapKind NeedleMapKind) {
 		if realWatermark != watermark {
 			t.Fatalf("testing watermark failed")
 		}
+	} else if needleMapKind == NeedleMapPebble {
+		nm := v.nm.(*PebbleNeedleMap)
+		watermark := getPebbleWatermark(nm.db)
+		realWatermark := (nm.recordCount / watermarkBatchSize) * watermarkBatchSize
+		t.Logf("watermark from pebble: %d, realWatermark: %d, nm.recordCount: %d, realRecordCount:%d, fileCount=%d, deletedcount:%d", watermark, realWatermark, nm.recordCount, realRecordCount, nm.FileCount(), v.DeletedCount())
+		if watermark != realWatermark && watermark != nm.recordCount {
+			t.Fatalf("testing watermark failed")
+		}
 	} else {
 		t.Logf("realRecordCount:%d, v.FileCount():%d mm.DeletedCount():%d", realRecordCount, v.FileCount(), v.DeletedCount())
 	}
@@ -188,3 +200,86 @@ func newEmptyNeedle(id uint64) *needle.Needle {
 	n.Id = types.Uint64ToNeedleId(id)
 	return n
 }
+
+type needleCounter struct {
+	count int
+}
+
+func (c *needleCounter) VisitSuperBlock(super_block.SuperBlock) error { return nil }
+func (c *needleCounter) ReadNeedleBody() bool                         { return true }
+func (c *needleCounter) VisitNeedle(n *needle.Needle, offset int64, needleHeader, needleBody []byte) error {
+	c.count++
+	return nil
+}
+
+func TestCompactInPlace(t *testing.T) {
+	dir := t.TempDir()
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+
+	written := make(map[uint64][]byte)
+	write := func(i uint64) {
+		n := newEmptyNeedle(i)
+		n.Data = make([]byte, 64*1024+rand.Intn(1024))
+		rand.Read(n.Data)
+		n.Checksum = needle.NewCRC(n.Data)
+		if _, _, _, err := v.writeNeedle2(n, true, false); err != nil {
+			t.Fatalf("write needle %d: %v", i, err)
+		}
+		written[i] = n.Data
+	}
+	for i := uint64(1); i <= 20; i++ {
+		write(i)
+	}
+	for i := uint64(1); i <= 20; i += 2 {
+		if _, err := v.deleteNeedle2(newEmptyNeedle(i)); err != nil {
+			t.Fatalf("delete needle %d: %v", i, err)
+		}
+		delete(written, i)
+	}
+	write(2)
+	datSize, _, _ := v.FileStat()
+
+	garbageBefore := v.inPlaceGarbageLevel()
+	reclaimed, err := v.CompactInPlace(nil)
+	if err == errPunchHoleNotSupported {
+		t.Skipf("compact in place: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("compact in place: %v", err)
+	}
+	if reclaimed < 10*64*1024 {
+		t.Errorf("reclaimed %d bytes", reclaimed)
+	}
+	if garbageAfter := v.inPlaceGarbageLevel(); garbageAfter >= garbageBefore || garbageAfter > 0.1 {
+		t.Errorf("garbage level %f before, %f after", garbageBefore, garbageAfter)
+	}
+	if err = v.CommitCompact(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if size, _, _ := v.FileStat(); size != datSize {
+		t.Errorf("data file size %d, expected %d", size, datSize)
+	}
+
+	for i, data := range written {
+		n := newEmptyNeedle(i)
+		if _, err := v.readNeedle(n, nil, nil); err != nil {
+			t.Fatalf("read needle %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(n.Data, data) {
+			t.Fatalf("needle %d data mismatch", i)
+		}
+	}
+
+	// the headers are kept, so the needles can still be scanned
+	counter := &needleCounter{}
+	if err = ScanVolumeFile(dir, "", 1, NeedleMapInMemory, counter); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if counter.count != 20+10+1 {
+		t.Errorf("scanned %d needles", counter.count)
+	}
+}
@@ -5,16 +5,27 @@ import (
 	"errors"
 	"fmt"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
 	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
 	"os"
+	"time"
 )
 
 var ErrorNotFound = errors.New("not found")
 var ErrorDeleted = errors.New("already deleted")
 var ErrorSizeMismatch = errors.New("size mismatch")
 
+// groupCommitDelay is how long the writes requested with fsync wait for more writes to share one fsync,
+// 0 to fsync the writes only when the volume server is stopping
+var groupCommitDelay time.Duration
+
+// SetGroupCommitDelay fsyncs the writes requested with fsync, grouping the concurrent ones of each volume
+func SetGroupCommitDelay(delay time.Duration) {
+	groupCommitDelay = delay
+}
+
 func (v *Volume) checkReadWriteError(err error) {
 	if err == nil {
 		if v.lastIoError != nil {
@@ -22,8 +33,9 @@ func (v *Volume) checkReadWriteError(err error) {
 		}
 		return
 	}
-	if err.Error() == "input/output error" {
+	if isIoError(err) {
 		v.lastIoError = err
+		v.location.recordIoError(v.Id, err)
 	}
 }
 
@@ -80,6 +92,9 @@ func (v *Volume) Destroy(onlyEmpty bool) (err error) {
 		}
 	}
 	v.doClose()
+	if objectStore := v.volumeInfo.GetObjectStore(); objectStore != nil {
+		v.deleteObjectStoreSegments(objectStore)
+	}
 	removeVolumeFiles(v.DataFileName())
 	removeVolumeFiles(v.IndexFileName())
 	return
@@ -92,13 +107,23 @@ func removeVolumeFiles(filename string) {
 	os.Remove(filename + ".vif")
 	// sorted index file
 	os.Remove(filename + ".sdx")
+	// compact index file
+	os.Remove(filename + ".cdx")
 	// compaction
 	os.Remove(filename + ".cpd")
 	os.Remove(filename + ".cpx")
 	// level db index file
 	os.RemoveAll(filename + ".ldb")
+	// pebble index file
+	os.RemoveAll(filename + ".pdb")
+	// overwrites not synced yet
+	os.Remove(filename + ".owl")
 	// marker for damaged or incomplete volume
 	os.Remove(filename + ".note")
+	// progress of the incomplete copy
+	os.Remove(filename + ".copy")
+	// progress of streaming from the replicas
+	os.Remove(filename + ".sync")
 }
 
 func (v *Volume) asyncRequestAppend(request *needle.AsyncRequest) {
@@ -143,6 +168,19 @@ func (v *Volume) writeNeedle2(n *needle.Needle, checkCookie bool, fsync bool) (o
 
 func (v *Volume) doWriteRequest(n *needle.Needle, checkCookie bool) (offset uint64, size Size, isUnchanged bool, err error) {
 	// glog.V(4).Infof("writing needle %s", needle.NewFileIdFromNeedle(v.Id, n).String())
+	if n.ChecksumAlgorithm != needle.ChecksumCrc32c && v.Version() != needle.Version3 {
+		// only the version 3 needles keep the checksum algorithm
+		n.ChecksumAlgorithm, n.Checksum = needle.ChecksumCrc32c, needle.NewCRC(n.Data)
+	}
+	if v.IsDeduplicated() {
+		var restore func(written bool)
+		if restore, err = v.referToBlob(n); err != nil {
+			return
+		}
+		defer func() {
+			restore(err == nil && !isUnchanged)
+		}()
+	}
 	if v.isFileUnchanged(n) {
 		size = Size(n.DataSize)
 		isUnchanged = true
@@ -170,6 +208,19 @@ func (v *Volume) doWriteRequest(n *needle.Needle, checkCookie bool) (offset uint
 		}
 	}
 
+	if ok && nv.Size.IsValid() && v.canOverwriteInPlace() {
+		var isOverwritten bool
+		if isOverwritten, err = v.overwriteInPlace(n, nv); err != nil || isOverwritten {
+			if err == nil {
+				offset, size = uint64(nv.Offset.ToActualOffset()), n.Size
+				if v.lastModifiedTsSeconds < n.LastModified {
+					v.lastModifiedTsSeconds = n.LastModified
+				}
+			}
+			return
+		}
+	}
+
 	// append to dat file
 	n.UpdateAppendAtNs(v.lastAppendAtNs)
 	offset, size, _, err = n.Append(v.DataBackend, v.Version())
@@ -181,6 +232,7 @@ func (v *Volume) doWriteRequest(n *needle.Needle, checkCookie bool) (offset uint
 
 	// add to needle map
 	if !ok || uint64(nv.Offset.ToActualOffset()) < offset {
+		v.needleFilter.add(n.Id)
 		if err = v.nm.Put(n.Id, ToOffset(int64(offset)), n.Size); err != nil {
 			glog.V(4).Infof("failed to save in needle map %d: %v", n.Id, err)
 		}
@@ -234,6 +286,14 @@ func (v *Volume) doDeleteRequest(n *needle.Needle) (Size, error) {
 		var offset uint64
 		var err error
 		size := nv.Size
+		var blobId NeedleId
+		var hasBlob bool
+		if v.IsDeduplicated() {
+			if IsDedupBlobId(n.Id) {
+				return 0, fmt.Errorf("needle id %s is reserved for the deduplicated contents", n.Id)
+			}
+			blobId, hasBlob = v.findBlobRef(n.Id)
+		}
 		if !v.hasRemoteFile {
 			n.Data = nil
 			n.UpdateAppendAtNs(v.lastAppendAtNs)
@@ -247,6 +307,9 @@ func (v *Volume) doDeleteRequest(n *needle.Needle) (Size, error) {
 		if err = v.nm.Delete(n.Id, ToOffset(int64(offset))); err != nil {
 			return size, err
 		}
+		if hasBlob {
+			v.releaseBlob(blobId)
+		}
 		return size, err
 	}
 	return 0, nil
@@ -262,8 +325,22 @@ func (v *Volume) startWorker() {
 			}
 			currentRequests := make([]*needle.AsyncRequest, 0, 128)
 			currentBytesToWrite := int64(0)
+			var groupCommitTimer *time.Timer
 			for {
-				request, ok := <-v.asyncRequestsChan
+				var request *needle.AsyncRequest
+				var ok bool
+				if groupCommitTimer == nil {
+					request, ok = <-v.asyncRequestsChan
+				} else {
+					select {
+					case request, ok = <-v.asyncRequestsChan:
+					case <-groupCommitTimer.C:
+						groupCommitTimer = nil
+					}
+					if groupCommitTimer == nil {
+						break
+					}
+				}
 				// volume may be closed
 				if !ok {
 					chanClosed = true
@@ -277,14 +354,26 @@ func (v *Volume) startWorker() {
 				currentRequests = append(currentRequests, request)
 				currentBytesToWrite += request.ActualSize
 				// submit at most 4M bytes or 128 requests at one time to decrease request delay.
-				// it also need to break if there is no data in channel to avoid io hang.
-				if currentBytesToWrite >= 4*1024*1024 || len(currentRequests) >= 128 || len(v.asyncRequestsChan) == 0 {
+				if currentBytesToWrite >= 4*1024*1024 || len(currentRequests) >= 128 {
 					break
 				}
+				if len(v.asyncRequestsChan) == 0 {
+					// wait a while for more requests to share the fsync, or else break to avoid io hang.
+					if groupCommitDelay <= 0 {
+						break
+					}
+					if groupCommitTimer == nil {
+						groupCommitTimer = time.NewTimer(groupCommitDelay)
+					}
+				}
+			}
+			if groupCommitTimer != nil {
+				groupCommitTimer.Stop()
 			}
 			if len(currentRequests) == 0 {
 				continue
 			}
+			stats.VolumeServerGroupCommitHistogram.Observe(float64(len(currentRequests)))
 			v.dataFileAccessLock.Lock()
 			end, _, e := v.DataBackend.GetStat()
 			if e != nil {
@@ -336,6 +425,13 @@ func (v *Volume) WriteNeedleBlob(needleId NeedleId, needleBlob []byte, size Size
 		return fmt.Errorf("volume size limit %d exceeded! current size is %d", MaxPossibleVolumeSize, v.nm.ContentSize())
 	}
 
+	var oldBlobId NeedleId
+	var hasOldBlob bool
+	isBlobRef := v.IsDeduplicated() && !IsDedupBlobId(needleId)
+	if isBlobRef {
+		oldBlobId, hasOldBlob = v.findBlobRef(needleId)
+	}
+
 	appendAtNs := needle.GetAppendAtNs(v.lastAppendAtNs)
 	offset, err := needle.WriteNeedleBlob(v.DataBackend, needleBlob, size, appendAtNs, v.Version())
 
@@ -346,9 +442,13 @@ func (v *Volume) WriteNeedleBlob(needleId NeedleId, needleBlob []byte, size Size
 	v.lastAppendAtNs = appendAtNs
 
 	// add to needle map
+	v.needleFilter.add(needleId)
 	if err = v.nm.Put(needleId, ToOffset(int64(offset)), size); err != nil {
 		glog.V(4).Infof("failed to put in needle map %d: %v", needleId, err)
 	}
+	if isBlobRef {
+		v.countRawBlobRef(needleId, needleBlob, size, oldBlobId, hasOldBlob)
+	}
 
 	return err
 }
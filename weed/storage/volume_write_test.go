@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
@@ -164,3 +167,49 @@ func TestDestroyNonemptyVolumeWithoutOnlyEmpty(t *testing.T) {
 	}
 	assertFileExist(t, false, path)
 }
+
+type syncCountingFile struct {
+	backend.BackendStorageFile
+	syncs int32
+}
+
+func (f *syncCountingFile) Sync() error {
+	atomic.AddInt32(&f.syncs, 1)
+	return f.BackendStorageFile.Sync()
+}
+
+func TestGroupCommit(t *testing.T) {
+	defer SetGroupCommitDelay(0)
+	SetGroupCommitDelay(100 * time.Millisecond)
+
+	dir := t.TempDir()
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+	file := &syncCountingFile{BackendStorageFile: v.DataBackend}
+	v.DataBackend = file
+
+	count := 20
+	var wg sync.WaitGroup
+	for i := 1; i <= count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, _, _, err := v.writeNeedle2(newRandomNeedle(uint64(i)), true, true); err != nil {
+				t.Errorf("write needle %d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if syncs := atomic.LoadInt32(&file.syncs); syncs == 0 || syncs > int32(count/2) {
+		t.Errorf("%d fsyncs for %d concurrent writes", syncs, count)
+	}
+	for i := 1; i <= count; i++ {
+		if _, _, err := v.ReadVerifiedNeedleBlob(types.NeedleId(i)); err != nil {
+			t.Errorf("read needle %d: %v", i, err)
+		}
+	}
+}
@@ -76,3 +76,25 @@ func (c *Collection) ListVolumeServers() (nodes []*DataNode) {
 	}
 	return
 }
+
+// volumeUsage counts the logical volumes of the collection, and the sizes of one replica each, with and without the deleted bytes
+func (c *Collection) volumeUsage() (volumeCount int, size, logicalSize uint64) {
+	for _, l := range c.storageType2VolumeLayout.Items() {
+		vl := l.(*VolumeLayout)
+		vl.accessLock.RLock()
+		for vid, locationList := range vl.vid2location {
+			volumeCount++
+			if locationList.Length() == 0 {
+				continue
+			}
+			if v, err := locationList.Head().GetVolumesById(vid); err == nil {
+				size += v.Size
+				if v.Size > v.DeletedByteCount {
+					logicalSize += v.Size - v.DeletedByteCount
+				}
+			}
+		}
+		vl.accessLock.RUnlock()
+	}
+	return
+}
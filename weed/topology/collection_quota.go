@@ -0,0 +1,128 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CollectionQuota caps the growth of a collection, e.g. of one tenant
+type CollectionQuota struct {
+	MaxVolumeCount  int    // no more volumes are grown beyond this, 0 for no limit
+	MaxLogicalBytes uint64 // no more writes are assigned beyond this, 0 for no limit
+	WarnPercent     int    // warn once the usage reaches this percentage of either limit, 0 to not warn
+}
+
+type QuotaState string
+
+const (
+	QuotaOk       QuotaState = "ok"
+	QuotaWarning  QuotaState = "warning"
+	QuotaExceeded QuotaState = "exceeded"
+)
+
+// CollectionQuotaUsage is the usage of the collection, as of the last refresh
+type CollectionQuotaUsage struct {
+	Collection   string
+	Quota        CollectionQuota
+	VolumeCount  int
+	LogicalBytes uint64 // the sizes of one replica of each volume, without the deleted bytes
+	State        QuotaState
+}
+
+func (u *CollectionQuotaUsage) evaluate() QuotaState {
+	q := u.Quota
+	if (q.MaxVolumeCount > 0 && u.VolumeCount >= q.MaxVolumeCount) || (q.MaxLogicalBytes > 0 && u.LogicalBytes >= q.MaxLogicalBytes) {
+		return QuotaExceeded
+	}
+	if q.WarnPercent > 0 &&
+		((q.MaxVolumeCount > 0 && u.VolumeCount*100 >= q.MaxVolumeCount*q.WarnPercent) ||
+			(q.MaxLogicalBytes > 0 && u.LogicalBytes*100 >= q.MaxLogicalBytes*uint64(q.WarnPercent))) {
+		return QuotaWarning
+	}
+	return QuotaOk
+}
+
+// SetCollectionQuota sets or, with a nil quota, removes the quota of the collection
+func (t *Topology) SetCollectionQuota(collection string, quota *CollectionQuota) {
+	t.quotasLock.Lock()
+	defer t.quotasLock.Unlock()
+	if t.quotas == nil {
+		t.quotas = make(map[string]*CollectionQuotaUsage)
+	}
+	if quota == nil {
+		delete(t.quotas, collection)
+		return
+	}
+	usage, found := t.quotas[collection]
+	if !found {
+		usage = &CollectionQuotaUsage{Collection: collection, State: QuotaOk}
+		t.quotas[collection] = usage
+	}
+	usage.Quota = *quota
+}
+
+// ListCollectionQuotas lists the quotas with their usage, by the collection name
+func (t *Topology) ListCollectionQuotas() (usages []CollectionQuotaUsage) {
+	t.quotasLock.RLock()
+	for _, usage := range t.quotas {
+		usages = append(usages, *usage)
+	}
+	t.quotasLock.RUnlock()
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].Collection < usages[j].Collection
+	})
+	return
+}
+
+// RefreshCollectionQuotas counts the usage of the collections with quotas, and returns the ones changing their state
+func (t *Topology) RefreshCollectionQuotas() (changed []CollectionQuotaUsage) {
+	for _, usage := range t.ListCollectionQuotas() {
+		var volumeCount int
+		var logicalBytes uint64
+		if c, found := t.FindCollection(usage.Collection); found {
+			volumeCount, _, logicalBytes = c.volumeUsage()
+		}
+		t.quotasLock.Lock()
+		if current, found := t.quotas[usage.Collection]; found {
+			current.VolumeCount, current.LogicalBytes = volumeCount, logicalBytes
+			if state := current.evaluate(); state != current.State {
+				current.State = state
+				changed = append(changed, *current)
+			}
+		}
+		t.quotasLock.Unlock()
+	}
+	return
+}
+
+// CheckCollectionQuotaForWrite refuses to assign writes to the collection over its max logical bytes
+func (t *Topology) CheckCollectionQuotaForWrite(collection string) error {
+	t.quotasLock.RLock()
+	defer t.quotasLock.RUnlock()
+	if usage, found := t.quotas[collection]; found && usage.Quota.MaxLogicalBytes > 0 && usage.LogicalBytes >= usage.Quota.MaxLogicalBytes {
+		return fmt.Errorf("collection %s is over its quota of %d bytes with %d bytes", collection, usage.Quota.MaxLogicalBytes, usage.LogicalBytes)
+	}
+	return nil
+}
+
+// checkCollectionQuotaForGrowth refuses to grow the collection to more volumes than its quota
+func (t *Topology) checkCollectionQuotaForGrowth(collection string) error {
+	var maxVolumeCount int
+	t.quotasLock.RLock()
+	if usage, found := t.quotas[collection]; found {
+		maxVolumeCount = usage.Quota.MaxVolumeCount
+	}
+	t.quotasLock.RUnlock()
+	if maxVolumeCount <= 0 {
+		return nil
+	}
+	// count again, the volumes grown since the last refresh
+	var volumeCount int
+	if c, hasCollection := t.FindCollection(collection); hasCollection {
+		volumeCount, _, _ = c.volumeUsage()
+	}
+	if volumeCount >= maxVolumeCount {
+		return fmt.Errorf("collection %s has reached its quota of %d volumes", collection, maxVolumeCount)
+	}
+	return nil
+}
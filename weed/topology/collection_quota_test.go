@@ -0,0 +1,65 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func TestCollectionQuota(t *testing.T) {
+	topo := NewTopology("weedfs", sequence.NewMemorySequencer(), 1024, 5, false)
+	dn := topo.GetOrCreateDataCenter("dc1").GetOrCreateRack("rack1").GetOrCreateDataNode("127.0.0.1", 8080, 0, "a", map[string]uint32{"": 10})
+	topo.SetCollectionQuota("tenant1", &CollectionQuota{MaxVolumeCount: 3, MaxLogicalBytes: 1000, WarnPercent: 80})
+	sync := func(sizes ...uint64) {
+		var volumes []*master_pb.VolumeInformationMessage
+		for i, size := range sizes {
+			volumes = append(volumes, &master_pb.VolumeInformationMessage{Id: uint32(i + 1), Collection: "tenant1",
+				Size: size, DeletedByteCount: 100, Version: uint32(needle.CurrentVersion)})
+		}
+		topo.SyncDataNodeRegistration(volumes, dn)
+	}
+
+	sync(300, 300)
+	if changed := topo.RefreshCollectionQuotas(); len(changed) != 0 {
+		t.Errorf("changed %+v with 2 volumes and 400 bytes", changed)
+	}
+	if err := topo.checkCollectionQuotaForGrowth("tenant1"); err != nil {
+		t.Errorf("growth: %v", err)
+	}
+
+	// 3 volumes reaches the max volume count
+	sync(300, 300, 100)
+	changed := topo.RefreshCollectionQuotas()
+	if len(changed) != 1 || changed[0].State != QuotaExceeded || changed[0].VolumeCount != 3 || changed[0].LogicalBytes != 400 {
+		t.Fatalf("changed %+v, expected exceeded by the volumes", changed)
+	}
+	if err := topo.checkCollectionQuotaForGrowth("tenant1"); err == nil {
+		t.Errorf("grew beyond 3 volumes")
+	}
+	if err := topo.CheckCollectionQuotaForWrite("tenant1"); err != nil {
+		t.Errorf("writes refused within the bytes: %v", err)
+	}
+
+	topo.SetCollectionQuota("tenant1", &CollectionQuota{MaxVolumeCount: 10, MaxLogicalBytes: 1000, WarnPercent: 80})
+	sync(500, 500)
+	if changed = topo.RefreshCollectionQuotas(); len(changed) != 1 || changed[0].State != QuotaWarning {
+		t.Fatalf("changed %+v, expected warning at 800 of 1000 bytes", changed)
+	}
+	sync(600, 600)
+	if changed = topo.RefreshCollectionQuotas(); len(changed) != 1 || changed[0].State != QuotaExceeded {
+		t.Fatalf("changed %+v, expected exceeded by the bytes", changed)
+	}
+	if err := topo.CheckCollectionQuotaForWrite("tenant1"); err == nil {
+		t.Errorf("writes assigned beyond the bytes")
+	}
+	if err := topo.CheckCollectionQuotaForWrite("other"); err != nil {
+		t.Errorf("other collection: %v", err)
+	}
+
+	topo.SetCollectionQuota("tenant1", nil)
+	if err := topo.CheckCollectionQuotaForWrite("tenant1"); err != nil || len(topo.ListCollectionQuotas()) != 0 {
+		t.Errorf("quota not deleted: %v", err)
+	}
+}
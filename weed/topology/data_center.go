@@ -20,6 +20,14 @@ func NewDataCenter(id string) *DataCenter {
 	return dc
 }
 
+// AvailableSpaceFor counts only the data nodes matching the placement constraint, if any
+func (dc *DataCenter) AvailableSpaceFor(option *VolumeGrowOption) int64 {
+	if option.Placement != nil {
+		return availableSpaceForPlacement(dc, option)
+	}
+	return dc.NodeImpl.AvailableSpaceFor(option)
+}
+
 func (dc *DataCenter) GetOrCreateRack(rackName string) *Rack {
 	dc.Lock()
 	defer dc.Unlock()
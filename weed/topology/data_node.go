@@ -10,17 +10,25 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 	"sync/atomic"
+	"time"
 )
 
 type DataNode struct {
 	NodeImpl
-	Ip            string
-	Port          int
-	GrpcPort      int
-	PublicUrl     string
-	LastSeen      int64 // unix time in seconds
-	Counter       int   // in race condition, the previous dataNode was not dead
-	IsTerminating bool
+	Ip              string
+	Port            int
+	GrpcPort        int
+	PublicUrl       string
+	LastSeen        int64 // unix time in seconds
+	Counter         int   // in race condition, the previous dataNode was not dead
+	IsTerminating   bool
+	isDraining      bool              // the volumes and ec shards are being moved away
+	IsInMaintenance bool              // no new volumes or writes, e.g. before a planned reboot
+	Tags            map[string]string // e.g. zone=a, hw=nvme, matched by the placement constraints
+	WriteLatency    time.Duration     // the recent write latency, lowering the placement score
+	InFlightWrites  int64             // the most concurrent writes recently, lowering the placement score
+	RequestRates    RequestRates      // the needle requests per second, from the last full heartbeat
+	Weight          float64           // the capacity and performance weight, 0 for the default 1
 }
 
 func NewDataNode(id string) *DataNode {
@@ -45,6 +53,41 @@ func (dn *DataNode) AddOrUpdateVolume(v storage.VolumeInfo) (isNew, isChangedRO
 	return dn.doAddOrUpdateVolume(v)
 }
 
+// AvailableSpaceFor is zero for a draining data node, one in maintenance, or one not matching the placement constraint,
+// so nothing new is placed on it
+func (dn *DataNode) AvailableSpaceFor(option *VolumeGrowOption) int64 {
+	if dn.IsDraining() || dn.IsInMaintenance || !option.Placement.Match(dn.GetTags()) {
+		return 0
+	}
+	return dn.NodeImpl.AvailableSpaceFor(option)
+}
+
+// IsDraining is set by the heartbeats and the drains, while the placement reads it
+func (dn *DataNode) IsDraining() bool {
+	dn.RLock()
+	defer dn.RUnlock()
+	return dn.isDraining
+}
+
+func (dn *DataNode) SetDraining(isDraining bool) {
+	dn.Lock()
+	defer dn.Unlock()
+	dn.isDraining = isDraining
+}
+
+func (dn *DataNode) GetTags() map[string]string {
+	dn.RLock()
+	defer dn.RUnlock()
+	return dn.Tags
+}
+
+// SetTags replaces the tags as a whole, so the map is never modified once read
+func (dn *DataNode) SetTags(tags map[string]string) {
+	dn.Lock()
+	defer dn.Unlock()
+	dn.Tags = tags
+}
+
 func (dn *DataNode) getOrCreateDisk(diskType string) *Disk {
 	c, found := dn.children[NodeId(diskType)]
 	if !found {
@@ -202,6 +245,13 @@ func (dn *DataNode) GetDataCenterId() string {
 	return ""
 }
 
+func (dn *DataNode) GetRackId() string {
+	if rack := dn.Parent(); rack != nil {
+		return string(rack.Id())
+	}
+	return ""
+}
+
 func (dn *DataNode) GetRack() *Rack {
 	return dn.Parent().(*NodeImpl).value.(*Rack)
 }
@@ -228,17 +278,19 @@ func (dn *DataNode) ServerAddress() pb.ServerAddress {
 }
 
 type DataNodeInfo struct {
-	Url       string `json:"Url"`
-	PublicUrl string `json:"PublicUrl"`
-	Volumes   int64  `json:"Volumes"`
-	EcShards  int64  `json:"EcShards"`
-	Max       int64  `json:"Max"`
-	VolumeIds string `json:"VolumeIds"`
+	Url         string `json:"Url"`
+	PublicUrl   string `json:"PublicUrl"`
+	Volumes     int64  `json:"Volumes"`
+	EcShards    int64  `json:"EcShards"`
+	Max         int64  `json:"Max"`
+	VolumeIds   string `json:"VolumeIds"`
+	Maintenance bool   `json:"Maintenance,omitempty"`
 }
 
 func (dn *DataNode) ToInfo() (info DataNodeInfo) {
 	info.Url = dn.Url()
 	info.PublicUrl = dn.PublicUrl
+	info.Maintenance = dn.IsInMaintenance
 
 	// aggregated volume info
 	var volumeCount, ecShardCount, maxVolumeCount int64
@@ -264,9 +316,13 @@ func (dn *DataNode) ToInfo() (info DataNodeInfo) {
 
 func (dn *DataNode) ToDataNodeInfo() *master_pb.DataNodeInfo {
 	m := &master_pb.DataNodeInfo{
-		Id:        string(dn.Id()),
-		DiskInfos: make(map[string]*master_pb.DiskInfo),
-		GrpcPort:  uint32(dn.GrpcPort),
+		Id:              string(dn.Id()),
+		DiskInfos:       make(map[string]*master_pb.DiskInfo),
+		GrpcPort:        uint32(dn.GrpcPort),
+		IsDraining:      dn.IsDraining(),
+		Tags:            dn.GetTags(),
+		IsInMaintenance: dn.IsInMaintenance,
+		Weight:          dn.PlacementWeight(),
 	}
 	for _, c := range dn.Children() {
 		disk := c.(*Disk)
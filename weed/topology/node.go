@@ -20,6 +20,7 @@ type Node interface {
 	Id() NodeId
 	String() string
 	AvailableSpaceFor(option *VolumeGrowOption) int64
+	PlacementScore(option *VolumeGrowOption) int64
 	ReserveOneVolume(r int64, option *VolumeGrowOption) (*DataNode, error)
 	UpAdjustDiskUsageDelta(deltaDiskUsages *DiskUsages)
 	UpAdjustMaxVolumeId(vid needle.VolumeId)
@@ -65,14 +66,18 @@ func (n *NodeImpl) PickNodesByWeight(numberOfNodes int, option *VolumeGrowOption
 	n.RLock()
 	candidates := make([]Node, 0, len(n.children))
 	candidatesWeights := make([]int64, 0, len(n.children))
-	//pick nodes which has enough free volumes as candidates, and use free volumes number as node weight.
+	//pick nodes which has enough free volumes as candidates, and use the placement score as node weight.
 	for _, node := range n.children {
 		if node.AvailableSpaceFor(option) <= 0 {
 			continue
 		}
-		totalWeights += node.AvailableSpaceFor(option)
+		weight := node.PlacementScore(option)
+		if weight <= 0 {
+			continue
+		}
+		totalWeights += weight
 		candidates = append(candidates, node)
-		candidatesWeights = append(candidatesWeights, node.AvailableSpaceFor(option))
+		candidatesWeights = append(candidatesWeights, weight)
 	}
 	n.RUnlock()
 	if len(candidates) < numberOfNodes {
@@ -189,13 +194,13 @@ func (n *NodeImpl) ReserveOneVolume(r int64, option *VolumeGrowOption) (assigned
 	n.RLock()
 	defer n.RUnlock()
 	for _, node := range n.children {
-		freeSpace := node.AvailableSpaceFor(option)
-		// fmt.Println("r =", r, ", node =", node, ", freeSpace =", freeSpace)
-		if freeSpace <= 0 {
+		score := node.PlacementScore(option)
+		// fmt.Println("r =", r, ", node =", node, ", score =", score)
+		if score <= 0 {
 			continue
 		}
-		if r >= freeSpace {
-			r -= freeSpace
+		if r >= score {
+			r -= score
 		} else {
 			if node.IsDataNode() && node.AvailableSpaceFor(option) > 0 {
 				// fmt.Println("vid =", vid, " assigned to node =", node, ", freeSpace =", node.FreeSpace())
@@ -284,7 +289,7 @@ func (n *NodeImpl) CollectDeadNodeAndFullVolumes(freshThreshHold int64, volumeSi
 						//fmt.Println("volume",v.Id,"size",v.Size,">",volumeSizeLimit)
 						topo.chanFullVolumes <- v
 					}
-				} else if float64(v.Size) > float64(volumeSizeLimit)*growThreshold {
+				} else if float64(v.Size) > float64(volumeSizeLimit)*topo.GrowThreshold(v.Collection, growThreshold) {
 					topo.chanCrowdedVolumes <- v
 				}
 				copyCount := v.ReplicaPlacement.GetCopyCount()
@@ -0,0 +1,121 @@
+package topology
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The volume servers register their tags, e.g. "zone=a,hw=nvme,tenant=x", and the placement constraint
+// of a collection or an assign request selects the volume servers for its volumes by their tags,
+// beyond the data center and rack hierarchy. The constraint is comma separated terms, all to match:
+//
+//	key=v1|v2   the tag is one of the values
+//	key!=v1|v2  the tag is missing, or none of the values
+//	key         the tag is set
+//	!key        the tag is missing
+
+type placementTerm struct {
+	key     string
+	values  []string // empty to only check the tag is set
+	negated bool
+}
+
+type PlacementConstraint struct {
+	expression string
+	terms      []placementTerm
+}
+
+// ParsePlacementConstraint parses the constraint expression, and returns nil for an empty expression
+func ParsePlacementConstraint(expression string) (*PlacementConstraint, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, nil
+	}
+	c := &PlacementConstraint{expression: expression}
+	for _, item := range strings.Split(expression, ",") {
+		item = strings.TrimSpace(item)
+		var term placementTerm
+		key, values, hasValues := strings.Cut(item, "=")
+		if hasValues {
+			if strings.HasSuffix(key, "!") {
+				term.negated = true
+				key = strings.TrimSuffix(key, "!")
+			}
+			for _, value := range strings.Split(values, "|") {
+				if value = strings.TrimSpace(value); value == "" {
+					return nil, fmt.Errorf("empty value in placement term %q", item)
+				}
+				term.values = append(term.values, value)
+			}
+		} else if strings.HasPrefix(key, "!") {
+			term.negated = true
+			key = strings.TrimPrefix(key, "!")
+		}
+		term.key = strings.TrimSpace(key)
+		if term.key == "" || strings.ContainsAny(term.key, "!|") {
+			return nil, fmt.Errorf("invalid placement term %q", item)
+		}
+		c.terms = append(c.terms, term)
+	}
+	return c, nil
+}
+
+// Match tells whether the tags satisfy all the terms, and is true for a nil constraint
+func (c *PlacementConstraint) Match(tags map[string]string) bool {
+	if c == nil {
+		return true
+	}
+	for _, term := range c.terms {
+		if term.match(tags) == term.negated {
+			return false
+		}
+	}
+	return true
+}
+
+func (term placementTerm) match(tags map[string]string) bool {
+	tag, found := tags[term.key]
+	if !found || len(term.values) == 0 {
+		return found
+	}
+	for _, value := range term.values {
+		if tag == value {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAll tells whether all the data nodes satisfy the constraint
+func (c *PlacementConstraint) MatchAll(dataNodes []*DataNode) bool {
+	if c == nil {
+		return true
+	}
+	for _, dn := range dataNodes {
+		if !c.Match(dn.GetTags()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *PlacementConstraint) String() string {
+	if c == nil {
+		return ""
+	}
+	return c.expression
+}
+
+func (c *PlacementConstraint) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// availableSpaceForPlacement sums the free slots of the children, which count only the data nodes matching the constraint
+func availableSpaceForPlacement(n Node, option *VolumeGrowOption) (freeSlots int64) {
+	for _, child := range n.Children() {
+		if childSlots := child.AvailableSpaceFor(option); childSlots > 0 {
+			freeSlots += childSlots
+		}
+	}
+	return
+}
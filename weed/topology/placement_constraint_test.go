@@ -0,0 +1,81 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+)
+
+func TestPlacementConstraintMatch(t *testing.T) {
+	tags := map[string]string{"zone": "a", "hw": "nvme", "gpu": ""}
+	for _, tc := range []struct {
+		expression string
+		matched    bool
+	}{
+		{"", true},
+		{"zone=a", true},
+		{"zone=b", false},
+		{"zone=b|a", true},
+		{"zone=a, hw=nvme", true},
+		{"zone=a,hw=hdd", false},
+		{"hw!=hdd", true},
+		{"hw!=hdd|nvme", false},
+		{"tenant!=x", true},
+		{"gpu", true},
+		{"!gpu", false},
+		{"tenant", false},
+		{"!tenant", true},
+	} {
+		c, err := ParsePlacementConstraint(tc.expression)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.expression, err)
+		}
+		if c.Match(tags) != tc.matched {
+			t.Errorf("%q matched %v, expected %v", tc.expression, !tc.matched, tc.matched)
+		}
+		if c.String() != tc.expression {
+			t.Errorf("%q printed as %q", tc.expression, c.String())
+		}
+	}
+	for _, expression := range []string{"zone=", "=a", "zone=a||b", "zone=a,,hw=nvme", "!", "!zone=a"} {
+		if _, err := ParsePlacementConstraint(expression); err == nil {
+			t.Errorf("parsed the invalid %q", expression)
+		}
+	}
+}
+
+func TestFindEmptySlotsWithPlacement(t *testing.T) {
+	topo := setup(topologyLayout)
+	for _, dn := range topo.ListDataNodes() {
+		switch dn.Id() {
+		case "server112", "server122":
+			dn.SetTags(map[string]string{"hw": "nvme"})
+		case "server321":
+			dn.SetTags(map[string]string{"hw": "hdd"})
+		}
+	}
+	placement, _ := ParsePlacementConstraint("hw=nvme")
+	rp, _ := super_block.NewReplicaPlacementFromString("010")
+	option := &VolumeGrowOption{ReplicaPlacement: rp, Placement: placement}
+
+	if free := topo.AvailableSpaceFor(option); free != 7+4 {
+		t.Errorf("free slots on nvme %d", free)
+	}
+	vg := NewDefaultVolumeGrowth()
+	for i := 0; i < 20; i++ {
+		servers, err := vg.findEmptySlotsForOneVolume(topo, option)
+		if err != nil {
+			t.Fatalf("find empty slots: %v", err)
+		}
+		for _, server := range servers {
+			if server.Id() != "server112" && server.Id() != "server122" {
+				t.Fatalf("picked %s without the nvme tag", server.Id())
+			}
+		}
+	}
+
+	option.ReplicaPlacement, _ = super_block.NewReplicaPlacementFromString("001")
+	if servers, err := vg.findEmptySlotsForOneVolume(topo, option); err == nil {
+		t.Errorf("picked %v, but no rack has two nvme servers", servers)
+	}
+}
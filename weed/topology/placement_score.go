@@ -0,0 +1,57 @@
+package topology
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// The volume growth picks the data centers, racks and data nodes randomly by their placement scores,
+// so the fuller, slower or busier data nodes receive fewer new volumes. The score of a data node is
+// its free volume slots, scaled down by its used ratio, its recent write latency and its in flight writes,
+// as reported in its heartbeats, and scaled by its weight. The score of a rack, a data center, or the topology is the sum of its data nodes.
+
+const (
+	placementScoreScale = 1000 // the score of one free slot on an empty, idle data node
+	// the write latency, or the in flight writes, halving the score
+	placementWriteLatencyReference   = 20 * time.Millisecond
+	placementInFlightWritesReference = 64
+)
+
+func (n *NodeImpl) PlacementScore(option *VolumeGrowOption) (score int64) {
+	for _, child := range n.Children() {
+		score += child.PlacementScore(option)
+	}
+	return
+}
+
+// PlacementScore is positive exactly when the data node has a free slot
+func (dn *DataNode) PlacementScore(option *VolumeGrowOption) int64 {
+	free := dn.AvailableSpaceFor(option)
+	if free <= 0 {
+		return 0
+	}
+	score := float64(free * placementScoreScale)
+	t := dn.NodeImpl.getOrCreateDisk(option.DiskType)
+	if slots := atomic.LoadInt64(&t.maxVolumeCount) + atomic.LoadInt64(&t.remoteVolumeCount); slots > free {
+		score = score * float64(free) / float64(slots)
+	}
+	if dn.WriteLatency > 0 {
+		score = score * float64(placementWriteLatencyReference) / float64(placementWriteLatencyReference+dn.WriteLatency)
+	}
+	if dn.InFlightWrites > 0 {
+		score = score * placementInFlightWritesReference / float64(placementInFlightWritesReference+dn.InFlightWrites)
+	}
+	score = score * dn.PlacementWeight()
+	if score < 1 {
+		return 1
+	}
+	return int64(score)
+}
+
+// PlacementWeight is the capacity and performance weight of the data node, 1 if not set
+func (dn *DataNode) PlacementWeight() float64 {
+	if dn.Weight <= 0 {
+		return 1
+	}
+	return dn.Weight
+}
@@ -0,0 +1,87 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+)
+
+func TestPlacementScore(t *testing.T) {
+	topo := NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+	rack := topo.GetOrCreateDataCenter("dc1").GetOrCreateRack("rack1")
+	nodes := make(map[string]*DataNode)
+	for i, id := range []string{"idle", "slow", "busy", "small"} {
+		maxVolumeCount := uint32(10)
+		if id == "small" {
+			maxVolumeCount = 4
+		}
+		nodes[id] = rack.GetOrCreateDataNode("127.0.0.1", 8080+i, 0, id, map[string]uint32{"": maxVolumeCount})
+	}
+	nodes["slow"].WriteLatency = placementWriteLatencyReference
+	nodes["busy"].InFlightWrites = placementInFlightWritesReference * 3
+	option := &VolumeGrowOption{}
+
+	for id, expected := range map[string]int64{
+		"idle":  10 * placementScoreScale,
+		"slow":  10 * placementScoreScale / 2,
+		"busy":  10 * placementScoreScale / 4,
+		"small": 4 * placementScoreScale,
+	} {
+		if score := nodes[id].PlacementScore(option); score != expected {
+			t.Errorf("score of %s is %d, expected %d", id, score, expected)
+		}
+	}
+	if score, expected := rack.PlacementScore(option), int64((10+5+2.5+4)*placementScoreScale); score != expected {
+		t.Errorf("score of the rack is %d, expected %d", score, expected)
+	}
+
+	nodes["idle"].SetDraining(true)
+	if score := nodes["idle"].PlacementScore(option); score != 0 {
+		t.Errorf("score of the draining node is %d", score)
+	}
+	nodes["idle"].SetDraining(false)
+
+	vg := NewDefaultVolumeGrowth()
+	option.ReplicaPlacement, _ = super_block.NewReplicaPlacementFromString("000")
+	picked := make(map[*DataNode]int)
+	for i := 0; i < 2000; i++ {
+		servers, err := vg.findEmptySlotsForOneVolume(topo, option)
+		if err != nil {
+			t.Fatalf("find empty slots: %v", err)
+		}
+		picked[servers[0]]++
+	}
+	if picked[nodes["idle"]] <= picked[nodes["slow"]] || picked[nodes["slow"]] <= picked[nodes["busy"]] {
+		t.Errorf("picked idle %d slow %d busy %d times, expected the idle node most, then the slow node, then the busy node", picked[nodes["idle"]], picked[nodes["slow"]], picked[nodes["busy"]])
+	}
+}
+
+func TestPlacementScoreOfFullerNode(t *testing.T) {
+	dn := NewDataNode("dn")
+	dn.AdjustMaxVolumeCounts(map[string]uint32{"": 10})
+	option := &VolumeGrowOption{}
+	empty := dn.PlacementScore(option)
+
+	usages := newDiskUsages()
+	usages.getOrCreateDisk("").volumeCount = 5
+	dn.UpAdjustDiskUsageDelta(usages)
+	if half := dn.PlacementScore(option); half != empty/4 {
+		t.Errorf("score of the half full node is %d, expected a quarter of %d", half, empty)
+	}
+}
+
+func TestPlacementScoreOfWeightedNode(t *testing.T) {
+	small, large := NewDataNode("small"), NewDataNode("large")
+	for _, dn := range []*DataNode{small, large} {
+		dn.AdjustMaxVolumeCounts(map[string]uint32{"": 10})
+	}
+	large.Weight = 5
+	option := &VolumeGrowOption{}
+	if s, l := small.PlacementScore(option), large.PlacementScore(option); l != 5*s {
+		t.Errorf("score of the node weighted 5 is %d, expected 5 times %d", l, s)
+	}
+	if info := small.ToDataNodeInfo(); info.Weight != 1 {
+		t.Errorf("default weight %v", info.Weight)
+	}
+}
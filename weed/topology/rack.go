@@ -23,6 +23,14 @@ func NewRack(id string) *Rack {
 	return r
 }
 
+// AvailableSpaceFor counts only the data nodes matching the placement constraint, if any
+func (r *Rack) AvailableSpaceFor(option *VolumeGrowOption) int64 {
+	if option.Placement != nil {
+		return availableSpaceForPlacement(r, option)
+	}
+	return r.NodeImpl.AvailableSpaceFor(option)
+}
+
 func (r *Rack) FindDataNode(ip string, port int) *DataNode {
 	for _, c := range r.Children() {
 		dn := c.(*DataNode)
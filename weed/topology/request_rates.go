@@ -0,0 +1,32 @@
+package topology
+
+import (
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+// RequestRates are the needle requests per second served by a volume server
+type RequestRates struct {
+	Reads        float64
+	Writes       float64
+	Deletes      float64
+	ReadBytes    float64
+	WrittenBytes float64
+	Errors       float64
+}
+
+// ToRequestRates divides the requests of a heartbeat by the time they were counted over,
+// with no rates if the time is unknown
+func ToRequestRates(load *master_pb.RequestLoad) (r RequestRates) {
+	if load == nil || load.IntervalMs <= 0 {
+		return
+	}
+	seconds := float64(load.IntervalMs) / 1000
+	return RequestRates{
+		Reads:        float64(load.Reads) / seconds,
+		Writes:       float64(load.Writes) / seconds,
+		Deletes:      float64(load.Deletes) / seconds,
+		ReadBytes:    float64(load.ReadBytes) / seconds,
+		WrittenBytes: float64(load.WrittenBytes) / seconds,
+		Errors:       float64(load.Errors) / seconds,
+	}
+}
@@ -95,6 +95,7 @@ func ReplicatedWrite(masterFn operation.GetMasterFn, grpcDialOption grpc.DialOpt
 				Filename:          string(n.Name),
 				Cipher:            false,
 				IsInputCompressed: n.IsCompressed(),
+				Compression:       n.Compression(),
 				MimeType:          string(n.Mime),
 				PairMap:           pairMap,
 				Jwt:               jwt,
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/pb"
@@ -27,6 +28,7 @@ import (
 
 type Topology struct {
 	vacuumLockCounter int64
+	vacuumingVolumeId int64 // the volume being compacted, 0 for none
 	NodeImpl
 
 	collectionMap  *util.ConcurrentReadMap
@@ -51,6 +53,14 @@ type Topology struct {
 	HashicorpRaft        *hashicorpRaft.Raft
 	UuidAccessLock       sync.RWMutex
 	UuidMap              map[string][]string
+
+	growthPolicies map[string]*VolumeGrowthPolicy
+	quotasLock     sync.RWMutex
+	quotas         map[string]*CollectionQuotaUsage
+
+	volumeIdRange       *VolumeIdRange
+	volumeIdRangeLock   sync.Mutex
+	lastVolumeIdInRange needle.VolumeId
 }
 
 func NewTopology(id string, seq sequence.Sequencer, volumeSizeLimit uint64, pulse int, replicationAsMin bool) *Topology {
@@ -76,6 +86,14 @@ func NewTopology(id string, seq sequence.Sequencer, volumeSizeLimit uint64, puls
 	return t
 }
 
+// AvailableSpaceFor counts only the data nodes matching the placement constraint, if any
+func (t *Topology) AvailableSpaceFor(option *VolumeGrowOption) int64 {
+	if option.Placement != nil {
+		return availableSpaceForPlacement(t, option)
+	}
+	return t.NodeImpl.AvailableSpaceFor(option)
+}
+
 func (t *Topology) IsChildLocked() (bool, error) {
 	if t.IsLocked() {
 		return true, errors.New("topology is locked")
@@ -179,8 +197,10 @@ func (t *Topology) Lookup(collection string, vid needle.VolumeId) (dataNodes []*
 }
 
 func (t *Topology) NextVolumeId() (needle.VolumeId, error) {
-	vid := t.GetMaxVolumeId()
-	next := vid.Next()
+	next, err := t.nextVolumeId()
+	if err != nil {
+		return 0, err
+	}
 
 	t.RaftServerAccessLock.RLock()
 	defer t.RaftServerAccessLock.RUnlock()
@@ -286,6 +306,18 @@ func (t *Topology) UnRegisterVolumeLayout(v storage.VolumeInfo, dn *DataNode) {
 	}
 }
 
+// ListDataNodes returns all the connected data nodes
+func (t *Topology) ListDataNodes() (dataNodes []*DataNode) {
+	for _, dc := range t.Children() {
+		for _, rack := range dc.Children() {
+			for _, dn := range rack.Children() {
+				dataNodes = append(dataNodes, dn.(*DataNode))
+			}
+		}
+	}
+	return
+}
+
 func (t *Topology) GetOrCreateDataCenter(dcName string) *DataCenter {
 	t.Lock()
 	defer t.Unlock()
@@ -377,3 +409,9 @@ func (t *Topology) EnableVacuum() {
 	glog.V(0).Infof("EnableVacuum")
 	t.isDisableVacuum = false
 }
+
+// VacuumingVolumeId is the volume being compacted by the vacuum, if any
+func (t *Topology) VacuumingVolumeId() (needle.VolumeId, bool) {
+	vid := atomic.LoadInt64(&t.vacuumingVolumeId)
+	return needle.VolumeId(vid), vid != 0
+}
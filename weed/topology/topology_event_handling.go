@@ -98,3 +98,17 @@ func (t *Topology) UnRegisterDataNode(dn *DataNode) {
 		dn.Parent().UnlinkChildNode(dn.Id())
 	}
 }
+
+// SetDataNodeMaintenance stops or resumes the writes to the volumes with a replica on the data node,
+// while the volumes stay readable
+func (t *Topology) SetDataNodeMaintenance(dn *DataNode, inMaintenance bool) {
+	if dn.IsInMaintenance == inMaintenance {
+		return
+	}
+	glog.V(0).Infof("volume server %s in maintenance: %v", dn.Url(), inMaintenance)
+	dn.IsInMaintenance = inMaintenance
+	for _, v := range dn.GetVolumes() {
+		vl := t.GetVolumeLayout(v.Collection, v.ReplicaPlacement, v.Ttl, types.ToDiskType(v.DiskType))
+		vl.EnsureCorrectWritables(&v)
+	}
+}
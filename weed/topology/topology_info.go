@@ -80,6 +80,7 @@ func (t *Topology) ToVolumeLocations() (volumeLocations []*master_pb.VolumeLocat
 					Url:        dn.Url(),
 					PublicUrl:  dn.PublicUrl,
 					DataCenter: dn.GetDataCenterId(),
+					Rack:       dn.GetRackId(),
 				}
 				for _, v := range dn.GetVolumes() {
 					volumeLocation.NewVids = append(volumeLocation.NewVids, uint32(v.Id))
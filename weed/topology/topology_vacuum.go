@@ -277,6 +277,8 @@ func (t *Topology) vacuumOneVolumeId(grpcDialOption grpc.DialOption, volumeLayou
 	glog.V(1).Infof("check vacuum on collection:%s volume:%d", c.Name, vid)
 	if vacuumLocationList, needVacuum := t.batchVacuumVolumeCheck(
 		grpcDialOption, vid, locationList, garbageThreshold); needVacuum {
+		atomic.StoreInt64(&t.vacuumingVolumeId, int64(vid))
+		defer atomic.StoreInt64(&t.vacuumingVolumeId, 0)
 		if t.batchVacuumVolumeCompact(grpcDialOption, volumeLayout, vid, vacuumLocationList, preallocate) {
 			t.batchVacuumVolumeCommit(grpcDialOption, volumeLayout, vid, vacuumLocationList, locationList)
 		} else {
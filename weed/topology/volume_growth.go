@@ -42,6 +42,7 @@ type VolumeGrowOption struct {
 	Rack               string                        `json:"rack,omitempty"`
 	DataNode           string                        `json:"dataNode,omitempty"`
 	MemoryMapMaxSizeMb uint32                        `json:"memoryMapMaxSizeMb,omitempty"`
+	Placement          *PlacementConstraint          `json:"placement,omitempty"`
 }
 
 type VolumeGrowth struct {
@@ -81,7 +82,11 @@ func (vg *VolumeGrowth) findVolumeCount(copyCount int) (count int) {
 
 func (vg *VolumeGrowth) AutomaticGrowByType(option *VolumeGrowOption, grpcDialOption grpc.DialOption, topo *Topology, targetCount int) (result []*master_pb.VolumeLocation, err error) {
 	if targetCount == 0 {
-		targetCount = vg.findVolumeCount(option.ReplicaPlacement.GetCopyCount())
+		if p := topo.GetVolumeGrowthPolicy(option.Collection); p != nil && p.Count > 0 {
+			targetCount = p.Count
+		} else {
+			targetCount = vg.findVolumeCount(option.ReplicaPlacement.GetCopyCount())
+		}
 	}
 	result, err = vg.GrowByCountAndType(grpcDialOption, targetCount, option, topo)
 	if len(result) > 0 && len(result)%option.ReplicaPlacement.GetCopyCount() == 0 {
@@ -105,6 +110,12 @@ func (vg *VolumeGrowth) GrowByCountAndType(grpcDialOption grpc.DialOption, targe
 }
 
 func (vg *VolumeGrowth) findAndGrow(grpcDialOption grpc.DialOption, topo *Topology, option *VolumeGrowOption) (result []*master_pb.VolumeLocation, err error) {
+	if err = topo.checkGrowthBudget(option.Collection); err != nil {
+		return nil, err
+	}
+	if err = topo.checkCollectionQuotaForGrowth(option.Collection); err != nil {
+		return nil, err
+	}
 	servers, e := vg.findEmptySlotsForOneVolume(topo, option)
 	if e != nil {
 		return nil, e
@@ -119,6 +130,7 @@ func (vg *VolumeGrowth) findAndGrow(grpcDialOption grpc.DialOption, topo *Topolo
 				Url:        server.Url(),
 				PublicUrl:  server.PublicUrl,
 				DataCenter: server.GetDataCenterId(),
+				Rack:       server.GetRackId(),
 				NewVids:    []uint32{uint32(vid)},
 			})
 		}
@@ -211,7 +223,11 @@ func (vg *VolumeGrowth) findEmptySlotsForOneVolume(topo *Topology, option *Volum
 		servers = append(servers, server.(*DataNode))
 	}
 	for _, rack := range otherRacks {
-		r := rand.Int63n(rack.AvailableSpaceFor(option))
+		score := rack.PlacementScore(option)
+		if score <= 0 {
+			return servers, fmt.Errorf("no free slot in rack %s", rack.Id())
+		}
+		r := rand.Int63n(score)
 		if server, e := rack.ReserveOneVolume(r, option); e == nil {
 			servers = append(servers, server)
 		} else {
@@ -219,7 +235,11 @@ func (vg *VolumeGrowth) findEmptySlotsForOneVolume(topo *Topology, option *Volum
 		}
 	}
 	for _, datacenter := range otherDataCenters {
-		r := rand.Int63n(datacenter.AvailableSpaceFor(option))
+		score := datacenter.PlacementScore(option)
+		if score <= 0 {
+			return servers, fmt.Errorf("no free slot in data center %s", datacenter.Id())
+		}
+		r := rand.Int63n(score)
 		if server, e := datacenter.ReserveOneVolume(r, option); e == nil {
 			servers = append(servers, server)
 		} else {
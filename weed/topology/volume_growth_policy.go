@@ -0,0 +1,61 @@
+package topology
+
+import (
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+// VolumeGrowthPolicy overrides the global volume growth for one collection
+type VolumeGrowthPolicy struct {
+	Threshold      float64 // grow once the writable volumes are this full, 0 for master.volume_growth.threshold
+	Count          int     // the logical volumes to grow each time, 0 for master.volume_growth.copy_*
+	DiskType       string  // for the writes without a disk type, empty for the default disk type
+	MaxVolumeCount int     // the max number of logical volumes of the collection, 0 for no limit
+	MaxSize        uint64  // stop growing once the volumes of the collection hold this many bytes, 0 for no limit
+}
+
+// SetVolumeGrowthPolicies is called before the topology is used, the policies are not changed afterwards
+func (t *Topology) SetVolumeGrowthPolicies(policies map[string]*VolumeGrowthPolicy) {
+	t.growthPolicies = policies
+}
+
+func (t *Topology) GetVolumeGrowthPolicy(collection string) *VolumeGrowthPolicy {
+	return t.growthPolicies[collection]
+}
+
+// GrowThreshold is the fullness of the writable volumes of the collection to grow at
+func (t *Topology) GrowThreshold(collection string, growThreshold float64) float64 {
+	if p := t.GetVolumeGrowthPolicy(collection); p != nil && p.Threshold > 0 {
+		return p.Threshold
+	}
+	return growThreshold
+}
+
+// GrowDiskType is the disk type of the collection to write to, if the write has none
+func (t *Topology) GrowDiskType(collection string, diskType string) types.DiskType {
+	if p := t.GetVolumeGrowthPolicy(collection); p != nil && p.DiskType != "" && diskType == "" {
+		return types.ToDiskType(p.DiskType)
+	}
+	return types.ToDiskType(diskType)
+}
+
+// checkGrowthBudget refuses to grow the collection beyond its max volume count or size
+func (t *Topology) checkGrowthBudget(collection string) error {
+	p := t.GetVolumeGrowthPolicy(collection)
+	if p == nil || (p.MaxVolumeCount <= 0 && p.MaxSize == 0) {
+		return nil
+	}
+	c, found := t.FindCollection(collection)
+	if !found {
+		return nil
+	}
+	volumeCount, size, _ := c.volumeUsage()
+	if p.MaxVolumeCount > 0 && volumeCount >= p.MaxVolumeCount {
+		return fmt.Errorf("collection %s has reached its max volume count %d", collection, p.MaxVolumeCount)
+	}
+	if p.MaxSize > 0 && size >= p.MaxSize {
+		return fmt.Errorf("collection %s has reached its max size of %d bytes with %d bytes", collection, p.MaxSize, size)
+	}
+	return nil
+}
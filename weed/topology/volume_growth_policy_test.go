@@ -0,0 +1,60 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func TestVolumeGrowthPolicy(t *testing.T) {
+	topo := NewTopology("weedfs", sequence.NewMemorySequencer(), 1024, 5, false)
+	dn := topo.GetOrCreateDataCenter("dc1").GetOrCreateRack("rack1").GetOrCreateDataNode("127.0.0.1", 8080, 0, "a", map[string]uint32{"": 10})
+	topo.SetVolumeGrowthPolicies(map[string]*VolumeGrowthPolicy{
+		"logs":   {Threshold: 0.5, DiskType: "ssd", MaxVolumeCount: 2},
+		"images": {MaxSize: 1500},
+	})
+	volume := func(id uint32, collection string, size uint64) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{Id: id, Collection: collection, Size: size, Version: uint32(needle.CurrentVersion)}
+	}
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{
+		volume(1, "logs", 100), volume(2, "images", 700), volume(3, "images", 700),
+	}, dn)
+
+	if threshold := topo.GrowThreshold("logs", 0.9); threshold != 0.5 {
+		t.Errorf("logs threshold %v", threshold)
+	}
+	if threshold := topo.GrowThreshold("other", 0.9); threshold != 0.9 {
+		t.Errorf("other threshold %v", threshold)
+	}
+	if diskType := topo.GrowDiskType("logs", ""); diskType != types.SsdType {
+		t.Errorf("logs disk type %q", diskType)
+	}
+	if diskType := topo.GrowDiskType("logs", "hdd"); diskType != types.HardDriveType {
+		t.Errorf("logs disk type %q, expected the requested one", diskType)
+	}
+
+	if err := topo.checkGrowthBudget("logs"); err != nil {
+		t.Errorf("logs with 1 of 2 volumes: %v", err)
+	}
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{
+		volume(1, "logs", 100), volume(2, "images", 700), volume(3, "images", 700), volume(4, "logs", 0),
+	}, dn)
+	if err := topo.checkGrowthBudget("logs"); err == nil {
+		t.Errorf("logs grew beyond 2 volumes")
+	}
+	if err := topo.checkGrowthBudget("images"); err != nil {
+		t.Errorf("images with 1400 of 1500 bytes: %v", err)
+	}
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{
+		volume(1, "logs", 100), volume(2, "images", 800), volume(3, "images", 700), volume(4, "logs", 0),
+	}, dn)
+	if err := topo.checkGrowthBudget("images"); err == nil {
+		t.Errorf("images grew beyond 1500 bytes")
+	}
+	if err := topo.checkGrowthBudget("other"); err != nil {
+		t.Errorf("other without a policy: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package topology
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+// VolumeIdRange is the volume ids this cluster allocates from. Clusters with disjoint ranges
+// can later be merged or replicate to each other without volume id collisions, and so without
+// file id collisions, since a file id starts with its volume id.
+type VolumeIdRange struct {
+	Start needle.VolumeId
+	End   needle.VolumeId // inclusive
+}
+
+// ParseVolumeIdRange parses the inclusive range, e.g. "1000000-1999999", or an empty string for all volume ids
+func ParseVolumeIdRange(s string) (*VolumeIdRange, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	start, end, found := strings.Cut(s, "-")
+	if !found {
+		return nil, fmt.Errorf("volume id range %q: expect <start>-<end>", s)
+	}
+	r := &VolumeIdRange{}
+	var err error
+	if r.Start, err = needle.NewVolumeId(strings.TrimSpace(start)); err != nil {
+		return nil, fmt.Errorf("volume id range %q: %v", s, err)
+	}
+	if r.End, err = needle.NewVolumeId(strings.TrimSpace(end)); err != nil {
+		return nil, fmt.Errorf("volume id range %q: %v", s, err)
+	}
+	if r.Start == 0 || r.End < r.Start {
+		return nil, fmt.Errorf("volume id range %q is empty", s)
+	}
+	return r, nil
+}
+
+func (r *VolumeIdRange) Contains(vid needle.VolumeId) bool {
+	return r == nil || r.Start <= vid && vid <= r.End
+}
+
+func (r *VolumeIdRange) String() string {
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// SetVolumeIdRange is called before the topology is used, the range is not changed afterwards
+func (t *Topology) SetVolumeIdRange(r *VolumeIdRange) {
+	t.volumeIdRange = r
+}
+
+func (t *Topology) GetVolumeIdRange() *VolumeIdRange {
+	return t.volumeIdRange
+}
+
+// nextVolumeId is the volume id after the max one, kept within the volume id range.
+// The max volume id counts the volumes replicated from the other clusters, so once it is
+// beyond the range, the max volume id within the range is found from the allocated and the known volumes.
+func (t *Topology) nextVolumeId() (needle.VolumeId, error) {
+	maxVolumeId := t.GetMaxVolumeId()
+	r := t.volumeIdRange
+	if r == nil {
+		return maxVolumeId.Next(), nil
+	}
+
+	t.volumeIdRangeLock.Lock()
+	defer t.volumeIdRangeLock.Unlock()
+
+	if !r.Contains(maxVolumeId) {
+		maxVolumeId = t.maxVolumeIdInRange()
+	}
+	if maxVolumeId < r.Start {
+		maxVolumeId = r.Start - 1
+	}
+	if maxVolumeId >= r.End {
+		return 0, fmt.Errorf("volume id range %s is used up", r)
+	}
+	next := maxVolumeId.Next()
+	t.lastVolumeIdInRange = next
+	return next, nil
+}
+
+func (t *Topology) maxVolumeIdInRange() (maxVolumeId needle.VolumeId) {
+	r := t.volumeIdRange
+	if r.Contains(t.lastVolumeIdInRange) {
+		maxVolumeId = t.lastVolumeIdInRange
+	}
+	for _, dn := range t.ListDataNodes() {
+		for _, v := range dn.GetVolumes() {
+			if r.Contains(v.Id) && v.Id > maxVolumeId {
+				maxVolumeId = v.Id
+			}
+		}
+	}
+	t.ecShardMapLock.RLock()
+	for vid := range t.ecShardMap {
+		if r.Contains(vid) && vid > maxVolumeId {
+			maxVolumeId = vid
+		}
+	}
+	t.ecShardMapLock.RUnlock()
+	return
+}
@@ -0,0 +1,60 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func TestParseVolumeIdRange(t *testing.T) {
+	if r, err := ParseVolumeIdRange(""); r != nil || err != nil {
+		t.Errorf("empty range: %v, %v", r, err)
+	}
+	r, err := ParseVolumeIdRange(" 1000-1999 ")
+	if err != nil || r.Start != 1000 || r.End != 1999 {
+		t.Errorf("parse 1000-1999: %v, %v", r, err)
+	}
+	for _, s := range []string{"1000", "0-10", "20-10", "a-b"} {
+		if _, err := ParseVolumeIdRange(s); err == nil {
+			t.Errorf("parsed %q", s)
+		}
+	}
+}
+
+func TestNextVolumeIdInRange(t *testing.T) {
+	topo := NewTopology("weedfs", sequence.NewMemorySequencer(), 1024, 5, false)
+	topo.SetVolumeIdRange(&VolumeIdRange{Start: 100, End: 102})
+
+	// without raft, the allocated volume ids are committed by hand
+	for _, expected := range []needle.VolumeId{100, 101, 102} {
+		vid, err := topo.NextVolumeId()
+		if err != nil || vid != expected {
+			t.Fatalf("next volume id %d, %v, expected %d", vid, err, expected)
+		}
+		topo.UpAdjustMaxVolumeId(vid)
+	}
+	if vid, err := topo.NextVolumeId(); err == nil {
+		t.Errorf("allocated volume id %d beyond the range", vid)
+	}
+
+	// the volumes replicated from another cluster do not move the volume ids of this cluster
+	topo = NewTopology("weedfs", sequence.NewMemorySequencer(), 1024, 5, false)
+	topo.SetVolumeIdRange(&VolumeIdRange{Start: 100, End: 199})
+	dn := topo.GetOrCreateDataCenter("dc1").GetOrCreateRack("rack1").GetOrCreateDataNode("127.0.0.1", 8080, 0, "a", map[string]uint32{"": 10})
+	topo.SyncDataNodeRegistration([]*master_pb.VolumeInformationMessage{
+		{Id: 150, Version: uint32(needle.CurrentVersion)},
+		{Id: 500, Version: uint32(needle.CurrentVersion)},
+	}, dn)
+	if topo.GetMaxVolumeId() != 500 {
+		t.Fatalf("max volume id %d", topo.GetMaxVolumeId())
+	}
+	for _, expected := range []needle.VolumeId{151, 152} {
+		vid, err := topo.NextVolumeId()
+		if err != nil || vid != expected {
+			t.Fatalf("next volume id %d, %v, expected %d", vid, err, expected)
+		}
+		topo.UpAdjustMaxVolumeId(vid)
+	}
+}
@@ -235,6 +235,9 @@ func (vl *VolumeLayout) ensureCorrectWritables(vid needle.VolumeId) {
 
 func (vl *VolumeLayout) isAllWritable(vid needle.VolumeId) bool {
 	for _, dn := range vl.vid2location[vid].list {
+		if dn.IsInMaintenance {
+			return false
+		}
 		if v, getError := dn.GetVolumesById(vid); getError == nil {
 			if v.ReadOnly {
 				return false
@@ -290,7 +293,7 @@ func (vl *VolumeLayout) PickForWrite(count uint64, option *VolumeGrowOption) (*n
 		//glog.V(0).Infoln("No more writable volumes!")
 		return nil, 0, nil, errors.New("No more writable volumes!")
 	}
-	if option.DataCenter == "" && option.Rack == "" && option.DataNode == "" {
+	if option.DataCenter == "" && option.Rack == "" && option.DataNode == "" && option.Placement == nil {
 		vid := vl.writables[rand.Intn(lenWriters)]
 		locationList := vl.vid2location[vid]
 		if locationList != nil {
@@ -303,6 +306,9 @@ func (vl *VolumeLayout) PickForWrite(count uint64, option *VolumeGrowOption) (*n
 	counter := 0
 	for _, v := range vl.writables {
 		volumeLocationList := vl.vid2location[v]
+		if !option.Placement.MatchAll(volumeLocationList.list) {
+			continue
+		}
 		for _, dn := range volumeLocationList.list {
 			if option.DataCenter != "" && dn.GetDataCenter().Id() != NodeId(option.DataCenter) {
 				continue
@@ -348,11 +354,22 @@ func (vl *VolumeLayout) GetActiveVolumeCount(option *VolumeGrowOption) (active,
 	vl.accessLock.RLock()
 	defer vl.accessLock.RUnlock()
 
-	if option.DataCenter == "" {
+	if option.DataCenter == "" && option.Placement == nil {
 		return len(vl.writables), len(vl.crowded)
 	}
 	for _, v := range vl.writables {
-		for _, dn := range vl.vid2location[v].list {
+		locationList := vl.vid2location[v]
+		if !option.Placement.MatchAll(locationList.list) {
+			continue
+		}
+		if option.DataCenter == "" {
+			active++
+			if _, found := vl.crowded[v]; found {
+				crowded++
+			}
+			continue
+		}
+		for _, dn := range locationList.list {
 			if dn.GetDataCenter().Id() == NodeId(option.DataCenter) {
 				if option.Rack != "" && dn.GetRack().Id() != NodeId(option.Rack) {
 					continue
@@ -393,6 +410,14 @@ func (vl *VolumeLayout) setVolumeWritable(vid needle.VolumeId) bool {
 			return false
 		}
 	}
+	if location, found := vl.vid2location[vid]; found {
+		for _, dn := range location.list {
+			if dn.IsInMaintenance {
+				// writable once the maintenance ends
+				return false
+			}
+		}
+	}
 	glog.V(0).Infoln("Volume", vid, "becomes writable")
 	vl.writables = append(vl.writables, vid)
 	return true
@@ -0,0 +1,102 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Thrift compact protocol types, as used by the parquet file and page headers.
+const (
+	thriftBinary = 8
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter writes the thrift compact protocol, enough for the parquet metadata.
+type thriftWriter struct {
+	buf          bytes.Buffer
+	lastFieldIds []int16
+	lastFieldId  int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	t.buf.Write(b[:n])
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	delta := id - t.lastFieldId
+	if delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buf.WriteByte(fieldType)
+		t.zigzag(int64(id))
+	}
+	t.lastFieldId = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.fieldHeader(id, thriftBinary)
+	t.rawBinary(v)
+}
+
+func (t *thriftWriter) rawBinary(v string) {
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+func (t *thriftWriter) list(id int16, elementType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		t.buf.WriteByte(0xf0 | elementType)
+		t.varint(uint64(size))
+	}
+}
+
+func (t *thriftWriter) i32List(id int16, values []int32) {
+	t.list(id, thriftI32, len(values))
+	for _, v := range values {
+		t.zigzag(int64(v))
+	}
+}
+
+func (t *thriftWriter) binaryList(id int16, values []string) {
+	t.list(id, thriftBinary, len(values))
+	for _, v := range values {
+		t.rawBinary(v)
+	}
+}
+
+// structBegin starts a struct field, or a struct element of a list if id is 0.
+func (t *thriftWriter) structBegin(id int16) {
+	if id != 0 {
+		t.fieldHeader(id, thriftStruct)
+	}
+	t.lastFieldIds = append(t.lastFieldIds, t.lastFieldId)
+	t.lastFieldId = 0
+}
+
+func (t *thriftWriter) structEnd() {
+	t.buf.WriteByte(0)
+	t.lastFieldId = t.lastFieldIds[len(t.lastFieldIds)-1]
+	t.lastFieldIds = t.lastFieldIds[:len(t.lastFieldIds)-1]
+}
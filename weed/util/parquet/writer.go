@@ -0,0 +1,295 @@
+// Package parquet writes flat tables of required columns as parquet files,
+// with PLAIN encoded and GZIP compressed pages, readable by Spark, DuckDB and others.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+type ColumnType int
+
+const (
+	Boolean ColumnType = iota
+	Int32
+	Int64
+	String
+	TimestampMillis
+)
+
+// parquet physical types and enums
+const (
+	typeBoolean   = 0
+	typeInt32     = 1
+	typeInt64     = 2
+	typeByteArray = 6
+
+	convertedUtf8            = 0
+	convertedTimestampMillis = 9
+
+	repetitionRequired = 0
+	encodingPlain      = 0
+	encodingRle        = 3
+	codecGzip          = 2
+	pageTypeData       = 0
+
+	magic = "PAR1"
+)
+
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+func (c Column) physicalType() int32 {
+	switch c.Type {
+	case Boolean:
+		return typeBoolean
+	case Int32:
+		return typeInt32
+	case Int64, TimestampMillis:
+		return typeInt64
+	default:
+		return typeByteArray
+	}
+}
+
+func (c Column) accepts(value interface{}) bool {
+	switch value.(type) {
+	case bool:
+		return c.Type == Boolean
+	case int32:
+		return c.Type == Int32
+	case int64:
+		return c.Type == Int64
+	case time.Time:
+		return c.Type == TimestampMillis
+	case string:
+		return c.Type == String
+	}
+	return false
+}
+
+type columnChunk struct {
+	fileOffset       int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+type rowGroup struct {
+	numRows int64
+	columns []columnChunk
+}
+
+// Writer buffers the rows of a row group, and writes each column of the row group as one page.
+type Writer struct {
+	w            io.Writer
+	offset       int64
+	columns      []Column
+	rowGroupSize int
+	values       []bytes.Buffer
+	booleans     [][]bool
+	rowCount     int
+	rowGroups    []rowGroup
+}
+
+func NewWriter(w io.Writer, columns []Column, rowGroupSize int) (*Writer, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns")
+	}
+	pw := &Writer{
+		w:            w,
+		columns:      columns,
+		rowGroupSize: rowGroupSize,
+		values:       make([]bytes.Buffer, len(columns)),
+		booleans:     make([][]bool, len(columns)),
+	}
+	if err := pw.write([]byte(magic)); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (pw *Writer) write(data []byte) error {
+	n, err := pw.w.Write(data)
+	pw.offset += int64(n)
+	return err
+}
+
+// Write adds one row, with one value of the column type for each column:
+// bool, int32, int64, string, or time.Time.
+func (pw *Writer) Write(row ...interface{}) error {
+	if len(row) != len(pw.columns) {
+		return fmt.Errorf("expect %d values, got %d", len(pw.columns), len(row))
+	}
+	for i, column := range pw.columns {
+		if !column.accepts(row[i]) {
+			return fmt.Errorf("column %s: unexpected value %T", column.Name, row[i])
+		}
+	}
+	for i := range pw.columns {
+		buf := &pw.values[i]
+		switch v := row[i].(type) {
+		case bool:
+			pw.booleans[i] = append(pw.booleans[i], v)
+		case int32:
+			binary.Write(buf, binary.LittleEndian, v)
+		case int64:
+			binary.Write(buf, binary.LittleEndian, v)
+		case time.Time:
+			binary.Write(buf, binary.LittleEndian, v.UnixMilli())
+		case string:
+			binary.Write(buf, binary.LittleEndian, uint32(len(v)))
+			buf.WriteString(v)
+		}
+	}
+	pw.rowCount++
+	if pw.rowCount >= pw.rowGroupSize {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+func (pw *Writer) flushRowGroup() error {
+	if pw.rowCount == 0 {
+		return nil
+	}
+	group := rowGroup{
+		numRows: int64(pw.rowCount),
+	}
+	for i, column := range pw.columns {
+		data := pw.values[i].Bytes()
+		if column.Type == Boolean {
+			data = packBooleans(pw.booleans[i])
+		}
+		chunk, err := pw.writePage(data)
+		if err != nil {
+			return fmt.Errorf("write column %s: %v", column.Name, err)
+		}
+		group.columns = append(group.columns, chunk)
+		pw.values[i].Reset()
+		pw.booleans[i] = pw.booleans[i][:0]
+	}
+	pw.rowGroups = append(pw.rowGroups, group)
+	pw.rowCount = 0
+	return nil
+}
+
+func (pw *Writer) writePage(data []byte) (chunk columnChunk, err error) {
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	if _, err = gw.Write(data); err != nil {
+		return
+	}
+	if err = gw.Close(); err != nil {
+		return
+	}
+
+	header := &thriftWriter{}
+	header.structBegin(0)
+	header.i32(1, pageTypeData)
+	header.i32(2, int32(len(data)))
+	header.i32(3, int32(compressed.Len()))
+	header.structBegin(5)
+	header.i32(1, int32(pw.rowCount))
+	header.i32(2, encodingPlain)
+	header.i32(3, encodingRle)
+	header.i32(4, encodingRle)
+	header.structEnd()
+	header.structEnd()
+
+	chunk.fileOffset = pw.offset
+	chunk.uncompressedSize = int64(header.buf.Len() + len(data))
+	chunk.compressedSize = int64(header.buf.Len() + compressed.Len())
+	if err = pw.write(header.buf.Bytes()); err != nil {
+		return
+	}
+	err = pw.write(compressed.Bytes())
+	return
+}
+
+func packBooleans(values []bool) []byte {
+	data := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			data[i/8] |= 1 << (i % 8)
+		}
+	}
+	return data
+}
+
+// Close writes the last row group and the file footer. It does not close the underlying writer.
+func (pw *Writer) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+
+	var numRows int64
+	for _, group := range pw.rowGroups {
+		numRows += group.numRows
+	}
+
+	t := &thriftWriter{}
+	t.structBegin(0)
+	t.i32(1, 1)
+	t.list(2, thriftStruct, len(pw.columns)+1)
+	t.structBegin(0)
+	t.binary(4, "schema")
+	t.i32(5, int32(len(pw.columns)))
+	t.structEnd()
+	for _, column := range pw.columns {
+		t.structBegin(0)
+		t.i32(1, column.physicalType())
+		t.i32(3, repetitionRequired)
+		t.binary(4, column.Name)
+		switch column.Type {
+		case String:
+			t.i32(6, convertedUtf8)
+		case TimestampMillis:
+			t.i32(6, convertedTimestampMillis)
+		}
+		t.structEnd()
+	}
+	t.i64(3, numRows)
+	t.list(4, thriftStruct, len(pw.rowGroups))
+	for _, group := range pw.rowGroups {
+		t.structBegin(0)
+		t.list(1, thriftStruct, len(group.columns))
+		var totalSize int64
+		for i, chunk := range group.columns {
+			totalSize += chunk.uncompressedSize
+			t.structBegin(0)
+			t.i64(2, chunk.fileOffset)
+			t.structBegin(3)
+			t.i32(1, pw.columns[i].physicalType())
+			t.i32List(2, []int32{encodingPlain, encodingRle})
+			t.binaryList(3, []string{pw.columns[i].Name})
+			t.i32(4, codecGzip)
+			t.i64(5, group.numRows)
+			t.i64(6, chunk.uncompressedSize)
+			t.i64(7, chunk.compressedSize)
+			t.i64(9, chunk.fileOffset)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.i64(2, totalSize)
+		t.i64(3, group.numRows)
+		t.structEnd()
+	}
+	t.binary(6, "seaweedfs")
+	t.structEnd()
+
+	if err := pw.write(t.buf.Bytes()); err != nil {
+		return err
+	}
+	footerSize := make([]byte, 4)
+	binary.LittleEndian.PutUint32(footerSize, uint32(t.buf.Len()))
+	if err := pw.write(footerSize); err != nil {
+		return err
+	}
+	return pw.write([]byte(magic))
+}
@@ -0,0 +1,114 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// thriftReader reads the thrift compact protocol into nested maps of field id to value.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(fieldType byte) interface{} {
+	switch fieldType {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.varint())
+		r.pos += n
+		return string(r.data[r.pos-n : r.pos])
+	case thriftList:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		var list []interface{}
+		for i := 0; i < size; i++ {
+			list = append(list, r.value(header&0x0f))
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic("unexpected thrift type")
+}
+
+func (r *thriftReader) readStruct() map[int64]interface{} {
+	fields := make(map[int64]interface{})
+	var lastFieldId int64
+	for {
+		header := r.data[r.pos]
+		r.pos++
+		if header == 0 {
+			return fields
+		}
+		fieldId := lastFieldId + int64(header>>4)
+		if header>>4 == 0 {
+			fieldId = r.zigzag()
+		}
+		fields[fieldId] = r.value(header & 0x0f)
+		lastFieldId = fieldId
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []Column{
+		{Name: "path", Type: String},
+		{Name: "size", Type: Int64},
+		{Name: "is_directory", Type: Boolean},
+		{Name: "mtime", Type: TimestampMillis},
+	}, 2)
+	assert.Nil(t, err)
+	now := time.Now()
+	assert.Nil(t, w.Write("/a", int64(1), false, now))
+	assert.Nil(t, w.Write("/b", int64(2), true, now))
+	assert.Nil(t, w.Write("/c/d", int64(3), false, now))
+	assert.NotNil(t, w.Write("/e", 4, false, now))
+	assert.Nil(t, w.Close())
+
+	data := buf.Bytes()
+	assert.Equal(t, magic, string(data[:4]))
+	assert.Equal(t, magic, string(data[len(data)-4:]))
+	footerSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := (&thriftReader{data: data[len(data)-8-footerSize : len(data)-8]}).readStruct()
+
+	assert.Equal(t, int64(3), footer[3])
+	assert.Equal(t, 5, len(footer[2].([]interface{})))
+	rowGroups := footer[4].([]interface{})
+	assert.Equal(t, 2, len(rowGroups))
+
+	// read the path column of the second row group
+	columns := rowGroups[1].(map[int64]interface{})[1].([]interface{})
+	meta := columns[0].(map[int64]interface{})[3].(map[int64]interface{})
+	offset := int(meta[9].(int64))
+	r := &thriftReader{data: data, pos: offset}
+	pageHeader := r.readStruct()
+	page, err := gzip.NewReader(bytes.NewReader(data[r.pos : r.pos+int(pageHeader[3].(int64))]))
+	assert.Nil(t, err)
+	values, _ := io.ReadAll(page)
+	assert.Equal(t, int64(1), pageHeader[5].(map[int64]interface{})[1])
+	assert.Equal(t, "\x04\x00\x00\x00/c/d", string(values))
+	assert.Equal(t, int64(len(values)), pageHeader[2])
+}
@@ -21,7 +21,6 @@ type MasterClient struct {
 	FilerGroup        string
 	clientType        string
 	clientHost        pb.ServerAddress
-	rack              string
 	currentMaster     pb.ServerAddress
 	currentMasterLock sync.RWMutex
 	masters           pb.ServerDiscovery
@@ -34,16 +33,22 @@ type MasterClient struct {
 }
 
 func NewMasterClient(grpcDialOption grpc.DialOption, filerGroup string, clientType string, clientHost pb.ServerAddress, clientDataCenter string, rack string, masters pb.ServerDiscovery) *MasterClient {
-	return &MasterClient{
+	mc := &MasterClient{
 		FilerGroup:      filerGroup,
 		clientType:      clientType,
 		clientHost:      clientHost,
-		rack:            rack,
 		masters:         masters,
 		grpcDialOption:  grpcDialOption,
 		vidMap:          newVidMap(clientDataCenter),
 		vidMapCacheSize: 5,
 	}
+	mc.Rack = rack
+	return mc
+}
+
+// SetReadPreference sets how to order the replicas by their proximity to this client
+func (mc *MasterClient) SetReadPreference(readPreference ReadPreference) {
+	mc.ReadPreference = readPreference
 }
 
 func (mc *MasterClient) SetOnPeerUpdateFn(onPeerUpdate func(update *master_pb.ClusterNodeUpdate, startFrom time.Time)) {
@@ -64,26 +69,29 @@ func (mc *MasterClient) LookupFileIdWithFallback(fileId string) (fullUrls []stri
 	err = pb.WithMasterClient(false, mc.GetMaster(), mc.grpcDialOption, false, func(client master_pb.SeaweedClient) error {
 		resp, err := client.LookupVolume(context.Background(), &master_pb.LookupVolumeRequest{
 			VolumeOrFileIds: []string{fileId},
+			DataCenter:      mc.DataCenter,
+			Rack:            mc.Rack,
+			ReadPreference:  string(mc.ReadPreference),
 		})
 		if err != nil {
 			return fmt.Errorf("LookupVolume %s failed: %v", fileId, err)
 		}
 		for vid, vidLocation := range resp.VolumeIdLocations {
+			var locations []Location
 			for _, vidLoc := range vidLocation.Locations {
 				loc := Location{
 					Url:        vidLoc.Url,
 					PublicUrl:  vidLoc.PublicUrl,
 					GrpcPort:   int(vidLoc.GrpcPort),
 					DataCenter: vidLoc.DataCenter,
+					Rack:       vidLoc.Rack,
 				}
 				mc.vidMap.addLocation(uint32(vid), loc)
-				httpUrl := "http://" + loc.Url + "/" + fileId
-				// Prefer same data center
-				if mc.DataCenter != "" && mc.DataCenter == loc.DataCenter {
-					fullUrls = append([]string{httpUrl}, fullUrls...)
-				} else {
-					fullUrls = append(fullUrls, httpUrl)
-				}
+				locations = append(locations, loc)
+			}
+			// Prefer the same rack, then the same data center
+			for _, loc := range OrderByProximity(mc.locality(), locations, locateLocation) {
+				fullUrls = append(fullUrls, "http://"+loc.Url+"/"+fileId)
 			}
 		}
 		return nil
@@ -123,6 +131,18 @@ func (mc *MasterClient) WaitUntilConnected() {
 	}
 }
 
+// WaitUntilConnectedWithTimeout returns false if not connected to a master within the timeout
+func (mc *MasterClient) WaitUntilConnectedWithTimeout(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for mc.getCurrentMaster() == "" {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Duration(rand.Int31n(200)) * time.Millisecond)
+	}
+	return true
+}
+
 func (mc *MasterClient) KeepConnectedToMaster() {
 	glog.V(1).Infof("%s.%s masterClient bootstraps with masters %v", mc.FilerGroup, mc.clientType, mc.masters)
 	for {
@@ -186,7 +206,7 @@ func (mc *MasterClient) tryConnectToMaster(master pb.ServerAddress) (nextHintedL
 		if err = stream.Send(&master_pb.KeepConnectedRequest{
 			FilerGroup:    mc.FilerGroup,
 			DataCenter:    mc.DataCenter,
-			Rack:          mc.rack,
+			Rack:          mc.Rack,
 			ClientType:    mc.clientType,
 			ClientAddress: string(mc.clientHost),
 			Version:       util.Version(),
@@ -273,6 +293,7 @@ func (mc *MasterClient) updateVidMap(resp *master_pb.KeepConnectedResponse) {
 		Url:        resp.VolumeLocation.Url,
 		PublicUrl:  resp.VolumeLocation.PublicUrl,
 		DataCenter: resp.VolumeLocation.DataCenter,
+		Rack:       resp.VolumeLocation.Rack,
 		GrpcPort:   int(resp.VolumeLocation.GrpcPort),
 	}
 	for _, newVid := range resp.VolumeLocation.NewVids {
@@ -310,10 +331,13 @@ func (mc *MasterClient) resetVidMap() {
 		vid2Locations:   mc.vid2Locations,
 		ecVid2Locations: mc.ecVid2Locations,
 		DataCenter:      mc.DataCenter,
+		Rack:            mc.Rack,
+		ReadPreference:  mc.ReadPreference,
 		cache:           mc.cache,
 	}
 
 	nvm := newVidMap(mc.DataCenter)
+	nvm.Rack, nvm.ReadPreference = mc.Rack, mc.ReadPreference
 	nvm.cache = tail
 	mc.vidMap = nvm
 
@@ -0,0 +1,71 @@
+package wdclient
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// ReadPreference decides which replicas a client reads from, by their proximity to the client
+type ReadPreference string
+
+const (
+	// ReadPreferLocal reads from the same rack first, then the same data center, then any other
+	ReadPreferLocal ReadPreference = "preferLocal"
+	// ReadLocalOnly reads only from the same data center, unless it has no replica
+	ReadLocalOnly ReadPreference = "localOnly"
+)
+
+func ParseReadPreference(s string) (ReadPreference, error) {
+	switch ReadPreference(s) {
+	case "", ReadPreferLocal:
+		return ReadPreferLocal, nil
+	case ReadLocalOnly:
+		return ReadLocalOnly, nil
+	}
+	return "", fmt.Errorf("unknown read preference %q, expect %s or %s", s, ReadPreferLocal, ReadLocalOnly)
+}
+
+// Locality is where the client is, and how it prefers to read
+type Locality struct {
+	DataCenter string
+	Rack       string
+	Preference ReadPreference
+}
+
+const (
+	proximityOther = iota
+	proximitySameDataCenter
+	proximitySameRack
+)
+
+func (l Locality) proximity(dataCenter, rack string) int {
+	if l.DataCenter == "" || l.DataCenter != dataCenter {
+		return proximityOther
+	}
+	if l.Rack != "" && l.Rack == rack {
+		return proximitySameRack
+	}
+	return proximitySameDataCenter
+}
+
+// OrderByProximity orders the locations from the nearest to the farthest, randomly among the equally near ones.
+// With ReadLocalOnly, the locations outside of the data center are left out if there is any inside.
+func OrderByProximity[T any](l Locality, locations []T, locate func(T) (dataCenter, rack string)) []T {
+	var tiers [proximitySameRack + 1][]T
+	for _, loc := range locations {
+		p := l.proximity(locate(loc))
+		tiers[p] = append(tiers[p], loc)
+	}
+	ordered := make([]T, 0, len(locations))
+	for p := proximitySameRack; p >= proximityOther; p-- {
+		tier := tiers[p]
+		if p == proximityOther && l.Preference == ReadLocalOnly && len(ordered) > 0 {
+			break
+		}
+		rand.Shuffle(len(tier), func(i, j int) {
+			tier[i], tier[j] = tier[j], tier[i]
+		})
+		ordered = append(ordered, tier...)
+	}
+	return ordered
+}
@@ -0,0 +1,55 @@
+package wdclient
+
+import (
+	"testing"
+)
+
+func TestOrderByProximity(t *testing.T) {
+	locations := []Location{
+		{Url: "a", DataCenter: "dc2", Rack: "rack1"},
+		{Url: "b", DataCenter: "dc1", Rack: "rack2"},
+		{Url: "c", DataCenter: "dc1", Rack: "rack1"},
+		{Url: "d"},
+	}
+	urls := func(locations []Location) (urls []string) {
+		for _, loc := range locations {
+			urls = append(urls, loc.Url)
+		}
+		return
+	}
+	check := func(name string, got []Location, expected ...string) {
+		if gotUrls := urls(got); len(gotUrls) != len(expected) {
+			t.Errorf("%s: got %v, expected %v", name, gotUrls, expected)
+		} else {
+			for i := range expected {
+				if expected[i] != "*" && gotUrls[i] != expected[i] {
+					t.Errorf("%s: got %v, expected %v", name, gotUrls, expected)
+					break
+				}
+			}
+		}
+	}
+
+	// the locations out of the data center in any order
+	check("same rack", OrderByProximity(Locality{DataCenter: "dc1", Rack: "rack1"}, locations, locateLocation), "c", "b", "*", "*")
+	check("same data center", OrderByProximity(Locality{DataCenter: "dc1"}, locations, locateLocation), "*", "*", "*", "*")
+	check("rack of another data center", OrderByProximity(Locality{DataCenter: "dc3", Rack: "rack1"}, locations, locateLocation), "*", "*", "*", "*")
+	check("local only", OrderByProximity(Locality{DataCenter: "dc2", Rack: "rack9", Preference: ReadLocalOnly}, locations, locateLocation), "a")
+	check("local only without local replicas", OrderByProximity(Locality{DataCenter: "dc3", Preference: ReadLocalOnly}, locations, locateLocation), "*", "*", "*", "*")
+
+	for _, loc := range OrderByProximity(Locality{DataCenter: "dc1"}, locations, locateLocation)[:2] {
+		if loc.DataCenter != "dc1" {
+			t.Errorf("%s out of the data center ordered first", loc.Url)
+		}
+	}
+	if urls(locations)[0] != "a" {
+		t.Errorf("reordered the locations in place")
+	}
+
+	if _, err := ParseReadPreference("nearest"); err == nil {
+		t.Errorf("parsed an unknown read preference")
+	}
+	if p, _ := ParseReadPreference(""); p != ReadPreferLocal {
+		t.Errorf("default read preference %s", p)
+	}
+}
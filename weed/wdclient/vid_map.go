@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/seaweedfs/seaweedfs/weed/pb"
-	"math/rand"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,10 +22,16 @@ type HasLookupFileIdFunction interface {
 
 type LookupFileIdFunctionType func(fileId string) (targetUrls []string, err error)
 
+// HasReadLocality is a client declaring where it reads from, beyond its data center
+type HasReadLocality interface {
+	GetReadLocality() Locality
+}
+
 type Location struct {
 	Url        string `json:"url,omitempty"`
 	PublicUrl  string `json:"publicUrl,omitempty"`
 	DataCenter string `json:"dataCenter,omitempty"`
+	Rack       string `json:"rack,omitempty"`
 	GrpcPort   int    `json:"grpcPort,omitempty"`
 }
 
@@ -39,6 +44,8 @@ type vidMap struct {
 	vid2Locations   map[uint32][]Location
 	ecVid2Locations map[uint32][]Location
 	DataCenter      string
+	Rack            string
+	ReadPreference  ReadPreference
 	cursor          int32
 	cache           *vidMap
 }
@@ -62,11 +69,12 @@ func (vc *vidMap) getLocationIndex(length int) (int, error) {
 	return int(atomic.AddInt32(&vc.cursor, 1)) % length, nil
 }
 
-func (vc *vidMap) isSameDataCenter(loc *Location) bool {
-	if vc.DataCenter == "" || loc.DataCenter == "" || vc.DataCenter != loc.DataCenter {
-		return false
-	}
-	return true
+func (vc *vidMap) locality() Locality {
+	return Locality{DataCenter: vc.DataCenter, Rack: vc.Rack, Preference: vc.ReadPreference}
+}
+
+func locateLocation(loc Location) (dataCenter, rack string) {
+	return loc.DataCenter, loc.Rack
 }
 
 func (vc *vidMap) LookupVolumeServerUrl(vid string) (serverUrls []string, err error) {
@@ -80,22 +88,10 @@ func (vc *vidMap) LookupVolumeServerUrl(vid string) (serverUrls []string, err er
 	if !found {
 		return nil, fmt.Errorf("volume %d not found", id)
 	}
-	var sameDcServers, otherDcServers []string
-	for _, loc := range locations {
-		if vc.isSameDataCenter(&loc) {
-			sameDcServers = append(sameDcServers, loc.Url)
-		} else {
-			otherDcServers = append(otherDcServers, loc.Url)
-		}
+	// Prefer the same rack, then the same data center
+	for _, loc := range OrderByProximity(vc.locality(), locations, locateLocation) {
+		serverUrls = append(serverUrls, loc.Url)
 	}
-	rand.Shuffle(len(sameDcServers), func(i, j int) {
-		sameDcServers[i], sameDcServers[j] = sameDcServers[j], sameDcServers[i]
-	})
-	rand.Shuffle(len(otherDcServers), func(i, j int) {
-		otherDcServers[i], otherDcServers[j] = otherDcServers[j], otherDcServers[i]
-	})
-	// Prefer same data center
-	serverUrls = append(sameDcServers, otherDcServers...)
 	return
 }
 